@@ -2,18 +2,46 @@ package main
 
 import (
 	"fmt"
-	"os"
-	"strings"
 
 	"github.com/davidjeba/goscript/pkg/buildout"
+	"github.com/davidjeba/goscript/pkg/goscript/cli"
 )
 
 func main() {
-	cfg, err := parseArgs(os.Args[1:])
+	boCommand := &cli.Command{
+		Name:  "bo",
+		Usage: "<manifest> [-] [target]",
+		Short: "build out",
+		Long: "Builds a manifest for a target: exe builds a host executable, goe a portable GOE bundle, " +
+			"and apk, ipa and dmg generate Android, iOS and macOS packaging scaffolds. The target defaults to exe.",
+		Flags: []*cli.Flag{
+			{Name: "dist", Usage: "Output directory", Value: "dist", Placeholder: "dir"},
+			{Name: "go", Usage: "Go binary", Value: "go", Placeholder: "path"},
+			{Name: "dry-run", Usage: "Print the build without running it", Value: false},
+		},
+		Examples: []string{
+			"bo admin.manifest - exe",
+			"bo admin.manifest exe",
+			"bo admin.manifest - goe",
+			"bo calc.manifest - exe",
+		},
+		Run: build,
+	}
+	boCommand.Main()
+}
+
+type cliConfig struct {
+	ManifestPath string
+	Target       buildout.Target
+	DistDir      string
+	GoBin        string
+	DryRun       bool
+}
+
+func build(c *cli.Context) error {
+	cfg, err := parseArgs(c)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		printUsage()
-		os.Exit(1)
+		return err
 	}
 
 	builder := buildout.NewBuilder()
@@ -23,8 +51,7 @@ func main() {
 
 	result, err := builder.Build(cfg.ManifestPath, cfg.Target)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "bo failed: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("bo failed: %v", err)
 	}
 
 	fmt.Printf("bo %s -> %s\n", cfg.ManifestPath, cfg.Target)
@@ -36,90 +63,36 @@ func main() {
 	if result.BundlePath != "" {
 		fmt.Printf("bundle: %s\n", result.BundlePath)
 	}
+	return nil
 }
 
-type cliConfig struct {
-	ManifestPath string
-	Target       buildout.Target
-	DistDir      string
-	GoBin        string
-	DryRun       bool
-}
-
-func parseArgs(args []string) (cliConfig, error) {
+func parseArgs(c *cli.Context) (cliConfig, error) {
 	cfg := cliConfig{
-		DistDir: "dist",
-		GoBin:   "go",
+		DistDir: c.String("dist"),
+		GoBin:   c.String("go"),
+		DryRun:  c.Bool("dry-run"),
 		Target:  buildout.TargetEXE,
 	}
 
 	var positional []string
-	for i := 0; i < len(args); i++ {
-		arg := args[i]
-		switch arg {
-		case "":
-			continue
-		case "-":
-			continue
-		case "--dist", "-dist":
-			if i+1 >= len(args) {
-				return cfg, fmt.Errorf("missing value for %s", arg)
-			}
-			i++
-			cfg.DistDir = args[i]
-		case "--go", "-go":
-			if i+1 >= len(args) {
-				return cfg, fmt.Errorf("missing value for %s", arg)
-			}
-			i++
-			cfg.GoBin = args[i]
-		case "--dry-run":
-			cfg.DryRun = true
-		default:
-			if strings.HasPrefix(arg, "-") {
-				return cfg, fmt.Errorf("unknown flag: %s", arg)
-			}
+	for _, arg := range c.Args {
+		if arg != "" && arg != "-" {
 			positional = append(positional, arg)
 		}
 	}
 
 	if len(positional) == 0 {
-		return cfg, fmt.Errorf("missing manifest file")
+		return cfg, cli.Usagef("missing manifest file")
 	}
 
 	cfg.ManifestPath = positional[0]
 	if len(positional) >= 2 {
 		target, err := buildout.ParseTarget(positional[len(positional)-1])
 		if err != nil {
-			return cfg, err
+			return cfg, cli.Usagef("%v", err)
 		}
 		cfg.Target = target
 	}
 
 	return cfg, nil
 }
-
-func printUsage() {
-	fmt.Println(strings.TrimSpace(`
-bo - build out
-
-Usage:
-  bo <manifest> [target]
-  bo <manifest> - <target>
-  bo [--dist dist] [--go go] [--dry-run] <manifest> [target]
-
-Targets:
-  exe   Build a host executable
-  goe   Build a portable GOE bundle
-  apk   Generate an Android packaging scaffold
-  ipa   Generate an iOS packaging scaffold
-  dmg   Generate a macOS packaging scaffold
-
-Examples:
-  bo admin.manifest - exe
-  bo admin.manifest exe
-  bo admin.manifest - goe
-  bo calc.manifest - exe
-`))
-}
-
@@ -8,6 +8,7 @@ import (
 	"github.com/davidjeba/goscript/pkg/gocsx"
 	"github.com/davidjeba/goscript/pkg/gocsx/components"
 	"github.com/davidjeba/goscript/pkg/gocsx/engine"
+	"github.com/davidjeba/goscript/pkg/goscript"
 )
 
 func main() {
@@ -126,5 +127,7 @@ func main() {
 
 	// Start the server
 	log.Println("Server starting on http://localhost:12000")
-	log.Fatal(http.ListenAndServe("0.0.0.0:12000", nil))
+	if err := goscript.NewServer("0.0.0.0:12000", http.DefaultServeMux).ListenAndServe(); err != nil {
+		log.Fatal(err)
+	}
 }
\ No newline at end of file
@@ -7,6 +7,7 @@ import (
 
 	"github.com/davidjeba/goscript/pkg/gocsx"
 	"github.com/davidjeba/goscript/pkg/gocsx/components"
+	"github.com/davidjeba/goscript/pkg/goscript"
 )
 
 func main() {
@@ -32,45 +33,39 @@ func main() {
 		ClassName: "shadow",
 	})
 
+	// The layout adds the Gocsx styles used on each page to its head
+	layout := goscript.NewLayout()
+	layout.Styles(g)
+
 	// Create a page
-	page := fmt.Sprintf(`
-<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>Gocsx Demo</title>
-    %s
-</head>
-<body>
-    <div class="container py-5">
-        <h1 class="text-center mb-5">Gocsx Demo</h1>
-        
-        <div class="row mb-5">
-            <div class="col-md-6 offset-md-3">
-                <div class="text-center">
-                    %s
-                </div>
-            </div>
-        </div>
-        
-        <div class="row">
-            <div class="col-md-6 offset-md-3">
+	body := fmt.Sprintf(`
+<div class="container py-5">
+    <h1 class="text-center mb-5">Gocsx Demo</h1>
+
+    <div class="row mb-5">
+        <div class="col-md-6 offset-md-3">
+            <div class="text-center">
                 %s
             </div>
         </div>
     </div>
-</body>
-</html>
-	`, g.GenerateStyleTag(), button, card)
+
+    <div class="row">
+        <div class="col-md-6 offset-md-3">
+            %s
+        </div>
+    </div>
+</div>
+	`, button, card)
 
 	// Create a handler for the page
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/html")
-		fmt.Fprint(w, page)
+		layout.Write(w, r, goscript.NewPage("Gocsx Demo", goscript.HTML(body)))
 	})
 
 	// Start the server
 	log.Println("Server starting on http://localhost:12000")
-	log.Fatal(http.ListenAndServe("0.0.0.0:12000", nil))
+	if err := goscript.NewServer("0.0.0.0:12000", http.DefaultServeMux).ListenAndServe(); err != nil {
+		log.Fatal(err)
+	}
 }
\ No newline at end of file
@@ -1,252 +1,354 @@
 package commands
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+
+	"github.com/davidjeba/goscript/pkg/goscript/cli"
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+	"github.com/davidjeba/goscript/pkg/jetpack/frontend"
+	"github.com/davidjeba/goscript/pkg/jetpack/security"
 )
 
-// JetpackCommand handles jetpack performance monitoring commands
-func JetpackCommand(args []string) {
-	if len(args) == 0 {
-		printJetpackHelp()
-		os.Exit(1)
-	}
-
-	command := args[0]
-	cmdArgs := args[1:]
-
-	switch command {
-	case "init":
-		jetpackInit(cmdArgs)
-	case "monitor":
-		jetpackMonitor(cmdArgs)
-	case "lighthouse":
-		jetpackLighthouse(cmdArgs)
-	case "panel":
-		jetpackPanel(cmdArgs)
-	case "metrics":
-		jetpackMetrics(cmdArgs)
-	case "security":
-		jetpackSecurity(cmdArgs)
-	case "export":
-		jetpackExport(cmdArgs)
-	case "report":
-		jetpackReport(cmdArgs)
-	case "chrome":
-		jetpackChrome(cmdArgs)
-	case "help":
-		printJetpackHelp()
-	default:
-		fmt.Printf("Unknown jetpack command: %s\n", command)
-		printJetpackHelp()
-		os.Exit(1)
+// JetpackCommand returns the gopm jetpack performance monitoring commands
+func JetpackCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "jetpack",
+		Short: "Performance monitoring and optimization",
+		Group: "Jetpack Performance Monitoring",
+		Commands: []*cli.Command{
+			{Name: "init", Short: "Initialize Jetpack performance monitoring", Args: cli.NoArgs, Run: jetpackInit},
+			{Name: "monitor", Usage: "<target>", Short: "Start monitoring a target", Args: cli.ExactArgs(1), Run: jetpackMonitor},
+			{Name: "lighthouse", Usage: "<url>", Short: "Run a Lighthouse audit", Args: cli.ExactArgs(1), Run: jetpackLighthouse},
+			{
+				Name: "panel", Short: "Performance panel commands",
+				Commands: []*cli.Command{
+					{Name: "show", Short: "Show the performance panel", Args: cli.NoArgs, Run: jetpackPanel},
+					{Name: "hide", Short: "Hide the performance panel", Args: cli.NoArgs, Run: jetpackPanel},
+					{Name: "config", Short: "Configure the performance panel", Args: cli.NoArgs, Run: jetpackPanel},
+				},
+			},
+			{
+				Name: "metrics", Short: "Metrics commands",
+				Commands: []*cli.Command{
+					{Name: "list", Short: "List available metrics", Args: cli.NoArgs, Run: jetpackMetrics},
+					{Name: "track", Usage: "<metric>", Short: "Track a specific metric", Args: cli.ExactArgs(1), Run: jetpackMetrics},
+					{Name: "untrack", Usage: "<metric>", Short: "Stop tracking a specific metric", Args: cli.ExactArgs(1), Run: jetpackMetrics},
+				},
+			},
+			{
+				Name: "security", Short: "Security commands",
+				Commands: []*cli.Command{
+					{
+						Name: "scan", Usage: "[dir]", Short: "Scan the project for secrets, debug endpoints and vulnerable dependencies",
+						Long: "Scans the project for secrets, debug endpoints and vulnerable dependencies, " +
+							"and the app at --url for exposed secrets, debug endpoints and missing headers, failing when it finds any.",
+						Flags: []*cli.Flag{
+							{Name: "url", Usage: "Also scan the app's responses and headers", Value: "", Placeholder: "url"},
+							{Name: "json", Usage: "Print the vulnerabilities as JSON", Value: false},
+						},
+						Args:     cli.MaxArgs(1),
+						Examples: []string{"gopm jetpack security scan --url http://localhost:8080/"},
+						Run:      jetpackSecurityScan,
+					},
+					{Name: "headers", Usage: "<url>", Short: "Check security headers", Args: cli.ExactArgs(1), Run: jetpackSecurityHeaders},
+					{Name: "tls", Usage: "<host>", Short: "Check TLS configuration", Args: cli.ExactArgs(1), Run: jetpackSecurityTLS},
+				},
+			},
+			{
+				Name: "export", Short: "Export commands",
+				Commands: []*cli.Command{
+					{Name: "json", Short: "Export metrics to JSON", Args: cli.NoArgs, Run: jetpackExport},
+					{Name: "csv", Short: "Export metrics to CSV", Args: cli.NoArgs, Run: jetpackExport},
+					{Name: "prometheus", Short: "Export metrics to Prometheus", Args: cli.NoArgs, Run: jetpackExport},
+				},
+			},
+			{
+				Name: "report", Short: "Report commands",
+				Commands: []*cli.Command{
+					{Name: "performance", Short: "Generate performance report", Args: cli.NoArgs, Run: jetpackReport},
+					{Name: "security", Short: "Generate security report", Args: cli.NoArgs, Run: jetpackReport},
+					{Name: "full", Short: "Generate full report", Args: cli.NoArgs, Run: jetpackReport},
+					{
+						Name: "compliance", Usage: "[dir]", Short: "Score the project against a compliance profile",
+						Long: "Scans the project and the app at --url, then runs a compliance profile's checks " +
+							"on what the scans found, failing when any check fails.",
+						Flags: []*cli.Flag{
+							{Name: "profile", Usage: "Compliance profile", Value: "owasp-asvs", Choices: security.ComplianceProfiles()},
+							{Name: "url", Usage: "Also check the app's TLS and headers", Value: "", Placeholder: "url"},
+							{Name: "format", Usage: "Report format", Value: "text", Choices: []string{"text", "json", "html"}},
+							{Name: "output", Usage: "Write the report to a file", Value: "", Placeholder: "file"},
+						},
+						Args: cli.MaxArgs(1),
+						Run:  jetpackComplianceReport,
+					},
+				},
+			},
+			{
+				Name: "chrome", Short: "Chrome extension commands",
+				Commands: []*cli.Command{
+					{Name: "build", Short: "Build Chrome extension", Args: cli.NoArgs, Run: jetpackChrome},
+					{Name: "install", Short: "Install Chrome extension", Args: cli.NoArgs, Run: jetpackChrome},
+					{Name: "update", Short: "Update Chrome extension", Args: cli.NoArgs, Run: jetpackChrome},
+				},
+			},
+			{
+				Name: "budget", Short: "Check performance budgets, failing when one is over",
+				Flags: []*cli.Flag{
+					{Name: "config", Usage: "Budget config", Value: core.DefaultBudgetFile, Placeholder: "file"},
+					{Name: "url", Usage: "Run a Lighthouse audit of the URL first", Value: "", Placeholder: "url"},
+					{Name: "json", Usage: "Print the results as JSON", Value: false},
+				},
+				Args:     cli.NoArgs,
+				Examples: []string{"gopm jetpack budget --url http://localhost:8080/"},
+				Run:      jetpackBudget,
+			},
+		},
+		Examples: []string{
+			"gopm jetpack init",
+			"gopm jetpack monitor http://localhost:3000",
+			"gopm jetpack lighthouse https://example.com",
+			"gopm jetpack panel show",
+			"gopm jetpack metrics list",
+			"gopm jetpack security scan --url http://localhost:8080/",
+			"gopm jetpack export json",
+			"gopm jetpack report performance",
+			"gopm jetpack chrome build",
+			"gopm jetpack budget --url http://localhost:8080/",
+		},
 	}
 }
 
-func jetpackInit(args []string) {
+func jetpackInit(c *cli.Context) error {
 	fmt.Println("Initializing Jetpack performance monitoring...")
 	// Implementation would initialize the Jetpack system
+	return nil
 }
 
-func jetpackMonitor(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Error: No target specified")
-		return
-	}
-
-	target := args[0]
-	fmt.Printf("Starting performance monitoring for %s...\n", target)
+func jetpackMonitor(c *cli.Context) error {
+	fmt.Printf("Starting performance monitoring for %s...\n", c.Args[0])
 	// Implementation would start monitoring the specified target
+	return nil
 }
 
-func jetpackLighthouse(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Error: No URL specified")
-		return
-	}
-
-	url := args[0]
-	fmt.Printf("Running Lighthouse audit for %s...\n", url)
+func jetpackLighthouse(c *cli.Context) error {
+	fmt.Printf("Running Lighthouse audit for %s...\n", c.Args[0])
 	// Implementation would run a Lighthouse audit
+	return nil
 }
 
-func jetpackPanel(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Error: No panel command specified")
-		return
-	}
-
-	panelCommand := args[0]
-	switch panelCommand {
+func jetpackPanel(c *cli.Context) error {
+	switch c.Command.Name {
 	case "show":
 		fmt.Println("Showing performance panel")
 	case "hide":
 		fmt.Println("Hiding performance panel")
 	case "config":
 		fmt.Println("Configuring performance panel")
-	default:
-		fmt.Printf("Unknown panel command: %s\n", panelCommand)
 	}
+	return nil
 }
 
-func jetpackMetrics(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Error: No metrics command specified")
-		return
-	}
-
-	metricsCommand := args[0]
-	switch metricsCommand {
+func jetpackMetrics(c *cli.Context) error {
+	switch c.Command.Name {
 	case "list":
 		fmt.Println("Listing available metrics")
 	case "track":
-		if len(args) < 2 {
-			fmt.Println("Error: No metric specified")
-			return
-		}
-		fmt.Printf("Tracking metric: %s\n", args[1])
+		fmt.Printf("Tracking metric: %s\n", c.Args[0])
 	case "untrack":
-		if len(args) < 2 {
-			fmt.Println("Error: No metric specified")
-			return
-		}
-		fmt.Printf("Untracking metric: %s\n", args[1])
-	default:
-		fmt.Printf("Unknown metrics command: %s\n", metricsCommand)
+		fmt.Printf("Untracking metric: %s\n", c.Args[0])
 	}
+	return nil
 }
 
-func jetpackSecurity(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Error: No security command specified")
-		return
+func jetpackSecurityHeaders(c *cli.Context) error {
+	fmt.Printf("Checking security headers for %s\n", c.Args[0])
+	return nil
+}
+
+func jetpackSecurityTLS(c *cli.Context) error {
+	fmt.Printf("Checking TLS configuration for %s\n", c.Args[0])
+	return nil
+}
+
+// securityLevelRank orders vulnerabilities most severe first
+var securityLevelRank = map[security.SecurityLevel]int{
+	security.SecurityLevelCritical: 0,
+	security.SecurityLevelHigh:     1,
+	security.SecurityLevelMedium:   2,
+	security.SecurityLevelLow:      3,
+}
+
+// jetpackSecurityScan scans the project for secrets, debug endpoints and
+// vulnerable dependencies, and the app at --url for exposed secrets, debug
+// endpoints and missing headers, exiting with status 1 when it finds any
+func jetpackSecurityScan(c *cli.Context) error {
+	dir := c.Arg(0)
+	if dir == "" {
+		dir = "."
+	}
+
+	jp := core.NewJetpack()
+	sm := security.NewSecurityMonitor(jp)
+	sm.Config.ProjectDir = dir
+	sm.Config.AuditReport = filepath.Join(dir, security.DefaultAuditReport)
+	sm.Config.TargetURL = c.String("url")
+	sm.ScanVulnerabilities()
+
+	for _, report := range jp.GetErrors() {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", report.Message)
 	}
 
-	securityCommand := args[0]
-	switch securityCommand {
-	case "scan":
-		fmt.Println("Scanning for security vulnerabilities")
-	case "headers":
-		if len(args) < 2 {
-			fmt.Println("Error: No URL specified")
-			return
+	vulns := sm.GetVulnerabilities()
+	sort.Slice(vulns, func(i, j int) bool {
+		if securityLevelRank[vulns[i].Level] != securityLevelRank[vulns[j].Level] {
+			return securityLevelRank[vulns[i].Level] < securityLevelRank[vulns[j].Level]
 		}
-		fmt.Printf("Checking security headers for %s\n", args[1])
-	case "tls":
-		if len(args) < 2 {
-			fmt.Println("Error: No host specified")
-			return
+		return vulns[i].ID < vulns[j].ID
+	})
+
+	if c.Bool("json") {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(vulns)
+	} else if len(vulns) == 0 {
+		fmt.Println("No vulnerabilities found")
+	} else {
+		for _, vuln := range vulns {
+			fmt.Printf("[%s] %s\n    at %s\n    %s\n", strings.ToUpper(string(vuln.Level)), vuln.Description, vuln.Location, vuln.Remediation)
 		}
-		fmt.Printf("Checking TLS configuration for %s\n", args[1])
-	default:
-		fmt.Printf("Unknown security command: %s\n", securityCommand)
+		fmt.Printf("%d vulnerabilities found\n", len(vulns))
 	}
-}
-
-func jetpackExport(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Error: No export format specified")
-		return
+	if len(vulns) > 0 || len(jp.GetErrors()) > 0 {
+		return cli.Exit(1)
 	}
+	return nil
+}
 
-	format := args[0]
-	switch format {
+func jetpackExport(c *cli.Context) error {
+	switch c.Command.Name {
 	case "json":
 		fmt.Println("Exporting metrics to JSON")
 	case "csv":
 		fmt.Println("Exporting metrics to CSV")
 	case "prometheus":
 		fmt.Println("Exporting metrics to Prometheus")
-	default:
-		fmt.Printf("Unknown export format: %s\n", format)
 	}
+	return nil
 }
 
-func jetpackReport(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Error: No report type specified")
-		return
-	}
-
-	reportType := args[0]
-	switch reportType {
+func jetpackReport(c *cli.Context) error {
+	switch c.Command.Name {
 	case "performance":
 		fmt.Println("Generating performance report")
 	case "security":
 		fmt.Println("Generating security report")
 	case "full":
 		fmt.Println("Generating full report")
-	default:
-		fmt.Printf("Unknown report type: %s\n", reportType)
 	}
+	return nil
 }
 
-func jetpackChrome(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Error: No Chrome extension command specified")
-		return
+// jetpackComplianceReport scans the project and the app at --url, then runs
+// a compliance profile's checks on what the scans found, exiting with
+// status 1 when any check fails
+func jetpackComplianceReport(c *cli.Context) error {
+	dir := c.Arg(0)
+	if dir == "" {
+		dir = "."
+	}
+
+	jp := core.NewJetpack()
+	sm := security.NewSecurityMonitor(jp)
+	sm.Config.ProjectDir = dir
+	sm.Config.AuditReport = filepath.Join(dir, security.DefaultAuditReport)
+	sm.Config.TargetURL = c.String("url")
+	sm.ScanVulnerabilities()
+
+	report, err := sm.RunCompliance(c.String("profile"))
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	switch c.String("format") {
+	case "json":
+		data, err = report.JSON()
+	case "html":
+		data, err = report.HTML()
+	default:
+		var text strings.Builder
+		fmt.Fprintf(&text, "%s: %.1f%% (%d passed, %d failed, %d skipped)\n", report.Title, report.Score, report.Passed, report.Failed, report.Skipped)
+		for _, result := range report.Results {
+			fmt.Fprintf(&text, "  [%-7s] %-16s %s\n              %s\n", strings.ToUpper(string(result.Status)), result.ID, result.Title, result.Evidence)
+		}
+		data = []byte(text.String())
+	}
+	if err != nil {
+		return err
+	}
+
+	if output := c.String("output"); output == "" {
+		os.Stdout.Write(data)
+	} else if err := ioutil.WriteFile(output, data, 0644); err != nil {
+		return err
+	} else {
+		fmt.Printf("Wrote the %s compliance report to %s\n", report.Title, output)
+	}
+	if report.Failed > 0 {
+		return cli.Exit(1)
 	}
+	return nil
+}
 
-	chromeCommand := args[0]
-	switch chromeCommand {
+func jetpackChrome(c *cli.Context) error {
+	switch c.Command.Name {
 	case "build":
 		fmt.Println("Building Chrome extension")
 	case "install":
 		fmt.Println("Installing Chrome extension")
 	case "update":
 		fmt.Println("Updating Chrome extension")
-	default:
-		fmt.Printf("Unknown Chrome extension command: %s\n", chromeCommand)
 	}
+	return nil
 }
 
-func printJetpackHelp() {
-	help := `
-Jetpack - Performance Monitoring and Optimization
-
-Usage: gopm jetpack [command] [options]
-
-Commands:
-  init                Initialize Jetpack performance monitoring
-  monitor [target]    Start monitoring a target
-  lighthouse [url]    Run a Lighthouse audit
-  panel              Performance panel commands:
-    show              Show the performance panel
-    hide              Hide the performance panel
-    config            Configure the performance panel
-  metrics            Metrics commands:
-    list              List available metrics
-    track [metric]    Track a specific metric
-    untrack [metric]  Stop tracking a specific metric
-  security           Security commands:
-    scan              Scan for security vulnerabilities
-    headers [url]     Check security headers
-    tls [host]        Check TLS configuration
-  export             Export commands:
-    json              Export metrics to JSON
-    csv               Export metrics to CSV
-    prometheus        Export metrics to Prometheus
-  report             Report commands:
-    performance       Generate performance report
-    security          Generate security report
-    full              Generate full report
-  chrome             Chrome extension commands:
-    build             Build Chrome extension
-    install           Install Chrome extension
-    update            Update Chrome extension
-  help               Show this help message
-
-Examples:
-  gopm jetpack init
-  gopm jetpack monitor http://localhost:3000
-  gopm jetpack lighthouse https://example.com
-  gopm jetpack panel show
-  gopm jetpack metrics list
-  gopm jetpack security scan
-  gopm jetpack export json
-  gopm jetpack report performance
-  gopm jetpack chrome build
-`
-	fmt.Println(strings.TrimSpace(help))
-}
\ No newline at end of file
+// jetpackBudget checks the performance budgets, exiting with status 1 when
+// any fails so CI fails the build
+func jetpackBudget(c *cli.Context) error {
+	config, err := core.LoadBudgetConfig(c.String("config"))
+	if err != nil {
+		return err
+	}
+
+	jp := core.NewJetpack()
+	if url := c.String("url"); url != "" {
+		// The audit records the lighthouse_* metrics, such as
+		// lighthouse_largest_contentful_paint, for budgets to cap
+		if _, err := frontend.NewLighthouseMonitor(jp).RunAudit(url); err != nil {
+			return err
+		}
+	}
+
+	report, err := jp.CheckBudgets(config)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("json") {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		fmt.Print(report)
+	}
+	if !report.Passed {
+		return cli.Exit(1)
+	}
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/davidjeba/goscript/pkg/goscale/api"
 	"github.com/davidjeba/goscript/pkg/goscale/db"
 	"github.com/davidjeba/goscript/pkg/goscale/edge"
+	"github.com/davidjeba/goscript/pkg/goscript"
 )
 
 func main() {
@@ -100,15 +101,18 @@ func main() {
 	createPostMutation.AddArg("title", "String", nil, "The post's title")
 	createPostMutation.AddArg("content", "String", nil, "The post's content")
 	createPostMutation.AddArg("authorId", "ID", nil, "The author's ID")
+	newPosts := goscaleAPI.CreateSubscription("newPost")
 	createPostMutation.SetResolver(func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 		// In a real implementation, this would insert into the database
-		return map[string]interface{}{
+		post := map[string]interface{}{
 			"id":        456,
 			"title":     params["title"],
 			"content":   params["content"],
 			"author":    params["authorId"],
 			"createdAt": time.Now().Format(time.RFC3339),
-		}, nil
+		}
+		newPosts.Publish(post)
+		return post, nil
 	})
 	
 	// Add subscriptions
@@ -165,12 +169,11 @@ func main() {
 		edgeNode2.RegisterHandler(path, resolver)
 	}
 	
-	// Create HTTP handlers
-	http.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
-		goscaleAPI.ServeHTTP(w, r)
-	})
+	// The API, edge network, live components and UI share one router
+	app := goscript.NewRouter()
+	app.Mount("/api", goscaleAPI)
 	
-	http.HandleFunc("/edge", func(w http.ResponseWriter, r *http.Request) {
+	app.POST("/edge", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
 		// Parse the request
 		var request struct {
 			Path   string                 `json:"path"`
@@ -200,7 +203,7 @@ func main() {
 		})
 	})
 	
-	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+	app.GET("/metrics", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
 		// Get metrics from the API and edge nodes
 		apiMetrics := goscaleAPI.GetMetrics()
 		edge1Metrics := edgeNode1.GetMetrics()
@@ -217,290 +220,74 @@ func main() {
 		})
 	})
 	
-	// Create a simple UI for testing
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		html := `
-<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>GoScale API Demo</title>
-    <style>
-        body {
-            font-family: Arial, sans-serif;
-            max-width: 800px;
-            margin: 0 auto;
-            padding: 20px;
-        }
-        h1 {
-            color: #333;
-        }
-        .card {
-            background-color: #f5f5f5;
-            border-radius: 5px;
-            padding: 20px;
-            margin-bottom: 20px;
-        }
-        .form-group {
-            margin-bottom: 15px;
-        }
-        label {
-            display: block;
-            margin-bottom: 5px;
-            font-weight: bold;
-        }
-        input, textarea, select {
-            width: 100%;
-            padding: 8px;
-            border: 1px solid #ddd;
-            border-radius: 4px;
-        }
-        button {
-            background-color: #4CAF50;
-            color: white;
-            border: none;
-            padding: 10px 15px;
-            border-radius: 4px;
-            cursor: pointer;
-        }
-        button:hover {
-            background-color: #45a049;
-        }
-        pre {
-            background-color: #f9f9f9;
-            border: 1px solid #ddd;
-            border-radius: 4px;
-            padding: 10px;
-            overflow: auto;
-        }
-        .tabs {
-            display: flex;
-            margin-bottom: 20px;
-        }
-        .tab {
-            padding: 10px 15px;
-            cursor: pointer;
-            border: 1px solid #ddd;
-            background-color: #f5f5f5;
-            margin-right: 5px;
-            border-radius: 4px 4px 0 0;
-        }
-        .tab.active {
-            background-color: #fff;
-            border-bottom: 1px solid #fff;
-        }
-        .tab-content {
-            display: none;
-            border: 1px solid #ddd;
-            padding: 20px;
-            border-radius: 0 4px 4px 4px;
-        }
-        .tab-content.active {
-            display: block;
-        }
-    </style>
-</head>
-<body>
-    <h1>GoScale API Demo</h1>
-    
-    <div class="tabs">
-        <div class="tab active" data-tab="query">Query</div>
-        <div class="tab" data-tab="mutation">Mutation</div>
-        <div class="tab" data-tab="edge">Edge</div>
-        <div class="tab" data-tab="metrics">Metrics</div>
-    </div>
-    
-    <div class="tab-content active" id="query-tab">
-        <div class="card">
-            <h2>Query</h2>
-            <div class="form-group">
-                <label for="query-type">Query Type</label>
-                <select id="query-type">
-                    <option value="getUser">Get User</option>
-                    <option value="getPosts">Get Posts</option>
-                </select>
-            </div>
-            <div class="form-group">
-                <label for="query-params">Parameters (JSON)</label>
-                <textarea id="query-params" rows="5">{"id": 123}</textarea>
-            </div>
-            <button id="run-query">Run Query</button>
-        </div>
-        <h3>Result</h3>
-        <pre id="query-result"></pre>
-    </div>
-    
-    <div class="tab-content" id="mutation-tab">
-        <div class="card">
-            <h2>Mutation</h2>
-            <div class="form-group">
-                <label for="mutation-type">Mutation Type</label>
-                <select id="mutation-type">
-                    <option value="createUser">Create User</option>
-                    <option value="createPost">Create Post</option>
-                </select>
-            </div>
-            <div class="form-group">
-                <label for="mutation-params">Parameters (JSON)</label>
-                <textarea id="mutation-params" rows="5">{"name": "John Doe", "email": "john@example.com"}</textarea>
-            </div>
-            <button id="run-mutation">Run Mutation</button>
-        </div>
-        <h3>Result</h3>
-        <pre id="mutation-result"></pre>
-    </div>
-    
-    <div class="tab-content" id="edge-tab">
-        <div class="card">
-            <h2>Edge Computing</h2>
-            <div class="form-group">
-                <label for="edge-path">Path</label>
-                <input type="text" id="edge-path" value="query:getUser">
-            </div>
-            <div class="form-group">
-                <label for="edge-params">Parameters (JSON)</label>
-                <textarea id="edge-params" rows="5">{"id": 123}</textarea>
-            </div>
-            <button id="run-edge">Run Edge Request</button>
-        </div>
-        <h3>Result</h3>
-        <pre id="edge-result"></pre>
-    </div>
-    
-    <div class="tab-content" id="metrics-tab">
-        <div class="card">
-            <h2>Metrics</h2>
-            <button id="get-metrics">Get Metrics</button>
-        </div>
-        <h3>Result</h3>
-        <pre id="metrics-result"></pre>
-    </div>
-    
-    <script>
-        // Tab switching
-        document.querySelectorAll('.tab').forEach(tab => {
-            tab.addEventListener('click', () => {
-                document.querySelectorAll('.tab').forEach(t => t.classList.remove('active'));
-                document.querySelectorAll('.tab-content').forEach(c => c.classList.remove('active'));
-                
-                tab.classList.add('active');
-                document.getElementById(tab.dataset.tab + '-tab').classList.add('active');
-            });
-        });
-        
-        // Query
-        document.getElementById('run-query').addEventListener('click', async () => {
-            const queryType = document.getElementById('query-type').value;
-            const params = JSON.parse(document.getElementById('query-params').value);
-            
-            try {
-                const response = await fetch('/api', {
-                    method: 'POST',
-                    headers: {
-                        'Content-Type': 'application/json'
-                    },
-                    body: JSON.stringify({
-                        query: queryType,
-                        variables: params,
-                        operation: 'query:' + queryType
-                    })
-                });
-                
-                const result = await response.json();
-                document.getElementById('query-result').textContent = JSON.stringify(result, null, 2);
-            } catch (error) {
-                document.getElementById('query-result').textContent = 'Error: ' + error.message;
-            }
-        });
-        
-        // Mutation
-        document.getElementById('run-mutation').addEventListener('click', async () => {
-            const mutationType = document.getElementById('mutation-type').value;
-            const params = JSON.parse(document.getElementById('mutation-params').value);
-            
-            try {
-                const response = await fetch('/api', {
-                    method: 'POST',
-                    headers: {
-                        'Content-Type': 'application/json'
-                    },
-                    body: JSON.stringify({
-                        query: mutationType,
-                        variables: params,
-                        operation: 'mutation:' + mutationType
-                    })
-                });
-                
-                const result = await response.json();
-                document.getElementById('mutation-result').textContent = JSON.stringify(result, null, 2);
-            } catch (error) {
-                document.getElementById('mutation-result').textContent = 'Error: ' + error.message;
-            }
-        });
-        
-        // Edge
-        document.getElementById('run-edge').addEventListener('click', async () => {
-            const path = document.getElementById('edge-path').value;
-            const params = JSON.parse(document.getElementById('edge-params').value);
-            
-            try {
-                const response = await fetch('/edge', {
-                    method: 'POST',
-                    headers: {
-                        'Content-Type': 'application/json'
-                    },
-                    body: JSON.stringify({
-                        path: path,
-                        params: params
-                    })
-                });
-                
-                const result = await response.json();
-                document.getElementById('edge-result').textContent = JSON.stringify(result, null, 2);
-            } catch (error) {
-                document.getElementById('edge-result').textContent = 'Error: ' + error.message;
-            }
-        });
-        
-        // Metrics
-        document.getElementById('get-metrics').addEventListener('click', async () => {
-            try {
-                const response = await fetch('/metrics');
-                const result = await response.json();
-                document.getElementById('metrics-result').textContent = JSON.stringify(result, null, 2);
-            } catch (error) {
-                document.getElementById('metrics-result').textContent = 'Error: ' + error.message;
-            }
-        });
-        
-        // Set default parameters based on selected query/mutation
-        document.getElementById('query-type').addEventListener('change', () => {
-            const queryType = document.getElementById('query-type').value;
-            if (queryType === 'getUser') {
-                document.getElementById('query-params').value = '{"id": 123}';
-            } else if (queryType === 'getPosts') {
-                document.getElementById('query-params').value = '{"userId": 123}';
-            }
-        });
-        
-        document.getElementById('mutation-type').addEventListener('change', () => {
-            const mutationType = document.getElementById('mutation-type').value;
-            if (mutationType === 'createUser') {
-                document.getElementById('mutation-params').value = '{"name": "John Doe", "email": "john@example.com"}';
-            } else if (mutationType === 'createPost') {
-                document.getElementById('mutation-params').value = '{"title": "New Post", "content": "This is a new post", "authorId": 123}';
-            }
-        });
-    </script>
-</body>
-</html>
-        `;
-        
-        fmt.Fprint(w, html);
-    });
+	// Stream the API metrics to dashboards as server-sent events
+	app.GET("/metrics/stream", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		events, err := goscript.NewEventStream(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer events.Close()
+
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-events.Done():
+				return
+			case <-ticker.C:
+				if err := events.Send(goscript.Event{Name: "metrics", Data: goscaleAPI.GetMetrics()}); err != nil {
+					return
+				}
+			}
+		}
+	})
+
+	// Subscriptions stream over WebSockets
+	app.WS("/subscriptions/:topic", goscaleAPI.ServeSubscription)
+
+	// Explore the API in the browser
+	playground := goscaleAPI.Playground(api.PlaygroundConfig{Endpoint: "/api", SubscriptionPath: "/subscriptions/"})
+	app.GET("/playground", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		playground.ServeHTTP(w, r)
+	})
+	app.GET("/", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		http.Redirect(w, r, "/playground", http.StatusFound)
+	})
+	
+	// The app starts the API, then the edge nodes, then the server, and
+	// stops them in reverse so requests drain before their backends close
+	server := goscript.NewServer("0.0.0.0:12001", app)
+	services := goscript.NewApp(
+		goscript.CloserService("api", goscaleAPI),
+		edgeService(edgeNode1),
+		edgeService(edgeNode2),
+		server.Service(),
+	)
+	app.GET("/health", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		services.HealthHandler().ServeHTTP(w, r)
+	})
 	
-	// Start the server
 	log.Println("Server starting on http://localhost:12001")
-	log.Fatal(http.ListenAndServe("0.0.0.0:12001", nil))
+	if err := services.RunUntilSignal(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// edgeNodeService runs an edge node in the app, reporting the status of
+// the network's health checks.
+type edgeNodeService struct {
+	goscript.Service
+	node *edge.EdgeNode
+}
+
+func edgeService(node *edge.EdgeNode) goscript.Service {
+	return edgeNodeService{goscript.CloserService("edge "+node.ID, node), node}
+}
+
+func (s edgeNodeService) Health(ctx context.Context) error {
+	if s.node.HealthStatus != "healthy" {
+		return fmt.Errorf("edge node %s in %s is %s", s.node.ID, s.node.Region, s.node.HealthStatus)
+	}
+	return nil
 }
\ No newline at end of file
@@ -1,131 +1,127 @@
 package main
 
 import (
-        "fmt"
+        "encoding/json"
         "log"
         "net/http"
         "os"
-        "path/filepath"
 
         "github.com/davidjeba/goscript/pkg/components"
+        "github.com/davidjeba/goscript/pkg/goscript"
+        "github.com/davidjeba/goscript/pkg/gouix"
+        "github.com/davidjeba/goscript/pkg/jetpack/core"
 )
 
+// demoCSS styles the demo pages
+const demoCSS = `
+* {
+    box-sizing: border-box;
+    margin: 0;
+    padding: 0;
+}
+
+body {
+    font-family: system-ui, -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Oxygen, Ubuntu, Cantarell, 'Open Sans', 'Helvetica Neue', sans-serif;
+    line-height: 1.6;
+    color: #333;
+    background-color: #f9f9f9;
+}
+
+.draggable {
+    cursor: move;
+    position: absolute;
+    z-index: 100;
+    box-shadow: 0 8px 16px rgba(0, 0, 0, 0.2);
+    transition: box-shadow 0.2s;
+}
+
+.draggable:hover {
+    box-shadow: 0 12px 24px rgba(0, 0, 0, 0.3);
+}
+
+button:hover {
+    opacity: 0.9;
+    transform: translateY(-1px);
+}
+
+button:active {
+    transform: translateY(1px);
+}
+`
+
 func main() {
         // Create the home page
         home := components.NewGoUIXHomePage("home", nil)
-
-        // Create HTML template
-        htmlTemplate := `<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>GoUIX Demo</title>
-    <style>
-        * {
-            box-sizing: border-box;
-            margin: 0;
-            padding: 0;
-        }
-        
-        body {
-            font-family: system-ui, -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Oxygen, Ubuntu, Cantarell, 'Open Sans', 'Helvetica Neue', sans-serif;
-            line-height: 1.6;
-            color: #333;
-            background-color: #f9f9f9;
-        }
-        
-        .draggable {
-            cursor: move;
-            position: absolute;
-            z-index: 100;
-            box-shadow: 0 8px 16px rgba(0, 0, 0, 0.2);
-            transition: box-shadow 0.2s;
-        }
-        
-        .draggable:hover {
-            box-shadow: 0 12px 24px rgba(0, 0, 0, 0.3);
-        }
         
-        button:hover {
-            opacity: 0.9;
-            transform: translateY(-1px);
+        // Mount it on a live hub so browser events reach the server components
+        // and state changes stream back as patches
+        hub := gouix.NewLiveHub()
+        root := hub.Mount("home", home)
+
+        // GOUIX_DEV=1 shows the devtools overlay on the pages
+        if os.Getenv("GOUIX_DEV") != "" {
+                hub.EnableDevtools(200)
         }
+
+        // Pages share the demo's layout, with the live runtime at the end of
+        // the body
+        layout := goscript.NewLayout()
+        layout.Head.Style("demo", demoCSS)
+        layout.Head.BodyEnd("gouix", hub.ScriptTag("/_gouix/live"))
+
+        // Measure the page in visitors' browsers and record it in Jetpack
+        jp := core.NewJetpack()
+        rum := core.NewRUM(jp, "")
+        layout.Head.BodyEnd("jetpack", rum.ScriptTag())
+
+        router := goscript.NewRouter()
+        router.Use(goscript.Recoverer(nil), goscript.RequestID(), jp.HTTPMiddleware)
         
-        button:active {
-            transform: translateY(1px);
-        }
-    </style>
-</head>
-<body>
-    %s
-</body>
-</html>`
-
-        // Create HTTP server
-        http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-                // Render the home page
-                html := home.Render()
-                
-                // Insert into template
-                fullHTML := fmt.Sprintf(htmlTemplate, html)
-                
-                // Set content type
-                w.Header().Set("Content-Type", "text/html")
-                
-                // Write response
-                w.Write([]byte(fullHTML))
+        // Handle live-update connections
+        router.GET("/_gouix/live", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+                hub.ServeHTTP(w, r)
         })
 
-        // Handle static files
-        http.HandleFunc("/static/", func(w http.ResponseWriter, r *http.Request) {
-                // Get file path
-                path := r.URL.Path[len("/static/"):]
-                
-                // Get current directory
-                dir, err := os.Getwd()
-                if err != nil {
-                        http.Error(w, err.Error(), http.StatusInternalServerError)
-                        return
-                }
-                
-                // Create file path
-                filePath := filepath.Join(dir, "static", path)
-                
-                // Check if file exists
-                if _, err := os.Stat(filePath); os.IsNotExist(err) {
-                        http.NotFound(w, r)
-                        return
-                }
-                
-                // Serve file
-                http.ServeFile(w, r, filePath)
+        // Record the beacons and show what they measured
+        router.POST(rum.Endpoint, func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+                rum.ServeHTTP(w, r)
+        })
+        router.GET("/_jetpack/metrics", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+                w.Header().Set("Content-Type", "application/json")
+                json.NewEncoder(w).Encode(jp.GetPanelData()["metrics"])
         })
+        router.GET(core.DefaultTimelineEndpoint, func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+                jp.Timelines.ServeHTTP(w, r)
+        })
+
+        // Serve the components' scoped styles as one cacheable stylesheet
+        router.GET("/gouix.css", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+                gouix.Styles.ServeHTTP(w, r)
+        })
+
+        // Render the home page, marked so the runtime can hydrate it, and link
+        // the stylesheet with the classes it uses
+        router.GET("/", layout.Handler(func(r *http.Request, params map[string]string) (*goscript.Page, error) {
+                page := goscript.NewPage("GoUIX Demo", goscript.HTML(root.RenderHydratable()))
+                page.Head.Raw("gouix-styles", gouix.Styles.LinkTag("/gouix.css"))
+                return page, nil
+        }))
+
+        // Handle static files
+        router.Static("/static", "static")
 
         // Handle API requests
-        http.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
-                // Set content type
-                w.Header().Set("Content-Type", "application/json")
-                
-                // Get path
-                path := r.URL.Path[len("/api/"):]
-                
-                // Handle different API endpoints
-                if path == "counter/increment" {
-                        // Get counter ID
-                        counterID := r.URL.Query().Get("id")
-                        if counterID == "" {
-                                http.Error(w, "Missing counter ID", http.StatusBadRequest)
-                                return
-                        }
-                        
-                        // Return success
-                        w.Write([]byte(`{"success": true}`))
+        router.GET("/api/counter/increment", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+                // Get counter ID
+                counterID := r.URL.Query().Get("id")
+                if counterID == "" {
+                        http.Error(w, "Missing counter ID", http.StatusBadRequest)
                         return
                 }
                 
-                // Handle unknown endpoint
-                http.NotFound(w, r)
+                // Return success
+                w.Header().Set("Content-Type", "application/json")
+                w.Write([]byte(`{"success": true}`))
         })
 
         // Start server
@@ -143,5 +139,10 @@ func main() {
         log.Printf("Server starting on http://localhost:%s", port)
         
         // Start server
-        log.Fatal(http.ListenAndServe("0.0.0.0:"+port, nil))
-}
\ No newline at end of file
+        server := goscript.NewServer("0.0.0.0:"+port, router)
+        // Live sessions are hijacked connections the server does not drain
+        server.OnShutdown(hub.Close)
+        if err := server.ListenAndServe(); err != nil {
+                log.Fatal(err)
+        }
+}
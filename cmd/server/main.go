@@ -1,9 +1,15 @@
 package main
 
 import (
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/json"
 	"fmt"
+	"html"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/davidjeba/goscript/pkg/goscript"
 	"github.com/davidjeba/goscript/pkg/components"
@@ -11,15 +17,45 @@ import (
 
 func main() {
 	router := goscript.NewRouter()
+	router.Use(
+		goscript.RequestID(),
+		goscript.Logger(nil),
+		goscript.Recoverer(nil),
+		goscript.Gzip(gzip.DefaultCompression),
+		goscript.CORS(goscript.CORSOptions{AllowedOrigins: []string{"*"}}),
+	)
+
+	// Sessions keep visitors signed in
+	sessions := goscript.NewSessionManager(goscript.NewMemorySessionStore(), sessionKey())
+	router.Use(sessions.Middleware())
 
 	// Register routes
-	router.GET("/", homeHandler)
-	router.GET("/api/hello", helloHandler)
+	// The home page is the same for everyone, so it renders once a minute
+	pageCache := goscript.NewResponseCache(goscript.CacheOptions{TTL: time.Minute})
+	router.GET("/", goscript.WithMiddleware(homeHandler, pageCache.Middleware()))
+
+	api := router.Group("/api")
+	api.GET("/hello", helloHandler)
+	api.GET("/hello/:name", helloHandler)
+	api.POST("/greetings", greetingHandler)
+
+	// Pages signed in with the session cookie; CSRF guards their forms
+	pages := router.Group("/")
+	pages.Use(goscript.CSRF())
+	pages.GET("/login", loginPageHandler)
+	pages.POST("/login", loginHandler)
+	pages.POST("/logout", logoutHandler)
+	account := pages.Group("/account")
+	account.Use(goscript.RequireLogin("/login"))
+	account.GET("", accountHandler)
 
 	// Start the server
 	port := 8080
 	fmt.Printf("Server starting on http://localhost:%d\n", port)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), router))
+	server := goscript.NewServer(fmt.Sprintf(":%d", port), router)
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatal(err)
+	}
 }
 
 func homeHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
@@ -29,6 +65,77 @@ func homeHandler(w http.ResponseWriter, r *http.Request, params map[string]strin
 }
 
 func helloHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
-	fmt.Fprintf(w, "Hello from GoScript API!")
+	name := params["name"]
+	if name == "" {
+		name = "GoScript API"
+	}
+	fmt.Fprintf(w, "Hello from %s!", name)
+}
+
+type greetingRequest struct {
+	Name     string `json:"name" validate:"required,max=50"`
+	Language string `json:"language" validate:"oneof=en fr"`
+}
+
+func greetingHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	var request greetingRequest
+	if err := goscript.Bind(r, &request); err != nil {
+		goscript.WriteBindError(w, err)
+		return
+	}
+
+	greeting := "Hello"
+	if request.Language == "fr" {
+		greeting = "Bonjour"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"greeting": greeting + ", " + request.Name + "!"})
+}
+
+// sessionKey reads the session key from GOSCRIPT_SESSION_KEY, or makes one
+// that lasts until the server restarts.
+func sessionKey() []byte {
+	if key := os.Getenv("GOSCRIPT_SESSION_KEY"); key != "" {
+		return []byte(key)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatal(err)
+	}
+	return key
 }
 
+func loginPageHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	w.Header().Set("Content-Type", "text/html")
+	fmt.Fprintf(w, `<form method="post" action="/login">%s
+<input type="hidden" name="next" value="%s">
+<input name="user" placeholder="Name" required>
+<button type="submit">Sign in</button>
+</form>`, goscript.CSRFInput(r), html.EscapeString(r.URL.Query().Get("next")))
+}
+
+func loginHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	user := r.PostFormValue("user")
+	if user == "" {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	// A real app checks a password here
+	if err := goscript.Login(r, user); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, goscript.SafeRedirect(r.PostFormValue("next"), "/account"), http.StatusSeeOther)
+}
+
+func logoutHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	goscript.Logout(r)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func accountHandler(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	w.Header().Set("Content-Type", "text/html")
+	fmt.Fprintf(w, `<p>Signed in as %s</p>
+<form method="post" action="/logout">%s<button type="submit">Sign out</button></form>`,
+		html.EscapeString(goscript.CurrentUser(r)), goscript.CSRFInput(r))
+}
@@ -8,41 +8,39 @@ import (
         "github.com/davidjeba/goscript/pkg/gouix"
 )
 
+//go:generate go run github.com/davidjeba/goscript/cmd/gopm uix:props .
+
 // GoUIXCounterProps defines the props for the GoUIXCounter component
+//gouix:component GoUIXCounter
 type GoUIXCounterProps struct {
         InitialCount int
-        Title        string
-        Theme        string
+        Title        string `default:"Counter"`
+        Theme        string `default:"light"`
 }
 
 // GoUIXCounter is a hyper(reactive) counter component
 type GoUIXCounter struct {
         gouix.HyperComponent
+        props GoUIXCounterProps
 }
 
 // NewGoUIXCounter creates a new counter component
 func NewGoUIXCounter(id gouix.ComponentID, props gouix.Props) *GoUIXCounter {
-        // Extract and validate props
-        initialCount := 0
-        if val, ok := props["initialCount"].(int); ok {
-                initialCount = val
-        }
-        
-        title := "Counter"
-        if val, ok := props["title"].(string); ok {
-                title = val
-        }
+        // Bind the props, applying their defaults
+        var typed GoUIXCounterProps
+        gouix.MustBindProps(props, &typed)
         
         // Create initial state
         initialState := map[string]interface{}{
-                "count": initialCount,
-                "title": title,
+                "count": typed.InitialCount,
+                "title": typed.Title,
         }
         
         // Create hyper(reactive) component
         base := gouix.NewHyperComponent(id, props, initialState)
         counter := &GoUIXCounter{
                 HyperComponent: *base,
+                props:          typed,
         }
         
         // Add event handlers
@@ -69,12 +67,7 @@ func (c *GoUIXCounter) decrement(event gouix.Event) interface{} {
 
 // reset resets the counter
 func (c *GoUIXCounter) reset(event gouix.Event) interface{} {
-        initialCount := 0
-        if val, ok := c.GetProps()["initialCount"].(int); ok {
-                initialCount = val
-        }
-        
-        c.SetState("count", initialCount)
+        c.SetState("count", c.props.InitialCount)
         return nil
 }
 
@@ -83,11 +76,7 @@ func (c *GoUIXCounter) Render() string {
         count := c.GetState("count").(int)
         title := c.GetState("title").(string)
         
-        // Get theme from props
-        theme := "light"
-        if val, ok := c.GetProps()["theme"].(string); ok {
-                theme = val
-        }
+        theme := c.props.Theme
         
         // Define theme styles
         themeStyles := map[string]map[string]string{
@@ -123,8 +112,8 @@ func (c *GoUIXCounter) Render() string {
                 styles = themeStyles["light"]
         }
         
-        // Create container style
-        containerStyle := map[string]interface{}{
+        // Scoped classes for the theme, shared by every counter using it
+        containerClass := gouix.Class("counter", gouix.Style{
                 "background-color": styles["bg"],
                 "color":            styles["text"],
                 "border":           "1px solid " + styles["border"],
@@ -134,10 +123,9 @@ func (c *GoUIXCounter) Render() string {
                 "width":            "300px",
                 "margin":           "20px auto",
                 "box-shadow":       "0 4px 6px rgba(0, 0, 0, 0.1)",
-        }
+        })
         
-        // Create button style
-        buttonStyle := map[string]interface{}{
+        buttonClass := gouix.Class("counter-button", gouix.Style{
                 "background-color": styles["btnBg"],
                 "color":            styles["btnText"],
                 "border":           "none",
@@ -147,36 +135,35 @@ func (c *GoUIXCounter) Render() string {
                 "cursor":           "pointer",
                 "font-size":        "14px",
                 "transition":       "background-color 0.2s",
-        }
+                ":hover":           gouix.Style{"background-color": styles["btnHover"]},
+        })
         
-        // Create count style
-        countStyle := map[string]interface{}{
+        countClass := gouix.Class("counter-count", gouix.Style{
                 "font-size":   "48px",
                 "font-weight": "bold",
                 "margin":      "16px 0",
-        }
+        })
         
-        // Create title style
-        titleStyle := map[string]interface{}{
+        titleClass := gouix.Class("counter-title", gouix.Style{
                 "font-size":   "24px",
                 "font-weight": "bold",
                 "margin":      "0 0 16px 0",
-        }
+        })
         
         // Create component ID for event handling
         componentID := string(c.GetID())
         
-        return gouix.CreateElement("div", gouix.Props{
-                "class": "counter",
-                "style": containerStyle,
+        // Mark the container with the counter's state for hydration
+        return gouix.Hydratable(c, gouix.CreateElement("div", gouix.Props{
+                "class": "counter " + containerClass,
                 "id":    componentID,
         },
                 gouix.CreateElement("h2", gouix.Props{
-                        "style": titleStyle,
+                        "class": titleClass,
                 }, title),
                 
                 gouix.CreateElement("p", gouix.Props{
-                        "style": countStyle,
+                        "class": countClass,
                         "id":    componentID + "-count",
                 }, strconv.Itoa(count)),
                 
@@ -184,24 +171,24 @@ func (c *GoUIXCounter) Render() string {
                         "class": "counter-buttons",
                 },
                         gouix.CreateElement("button", gouix.Props{
-                                "style":   buttonStyle,
+                                "class":   buttonClass,
                                 "onclick": fmt.Sprintf("_gouix.dispatchEvent('%s', 'decrement', {})", componentID),
                                 "id":      componentID + "-decrement",
                         }, "−"),
                         
                         gouix.CreateElement("button", gouix.Props{
-                                "style":   buttonStyle,
+                                "class":   buttonClass,
                                 "onclick": fmt.Sprintf("_gouix.dispatchEvent('%s', 'reset', {})", componentID),
                                 "id":      componentID + "-reset",
                         }, "Reset"),
                         
                         gouix.CreateElement("button", gouix.Props{
-                                "style":   buttonStyle,
+                                "class":   buttonClass,
                                 "onclick": fmt.Sprintf("_gouix.dispatchEvent('%s', 'increment', {})", componentID),
                                 "id":      componentID + "-increment",
                         }, "+"),
                 ),
-        )
+        ))
 }
 
 // DraggableGoUIXCounter is a counter that can be dragged
@@ -233,7 +220,7 @@ func (c *DraggableGoUIXCounter) Render() string {
         baseHTML := c.GoUIXCounter.Render()
         
         // Add draggable attributes
-        return strings.Replace(baseHTML, "class=\"counter\"", "class=\"counter draggable\" draggable=\"true\"", 1)
+        return strings.Replace(baseHTML, "class=\"counter ", "draggable=\"true\" class=\"counter draggable ", 1)
 }
 
 // CounterWithHooks is a functional component that uses hooks
@@ -257,21 +244,8 @@ func CounterWithHooks(props gouix.Props, children ...interface{}) string {
         // Use state hook
         count := gouix.UseState(initialCount)
         
-        // Create container style
-        containerStyle := map[string]interface{}{
-                "background-color": "#f8f9fa",
-                "color":            "#333333",
-                "border":           "1px solid #dee2e6",
-                "border-radius":    "8px",
-                "padding":          "16px",
-                "text-align":       "center",
-                "width":            "300px",
-                "margin":           "20px auto",
-                "box-shadow":       "0 4px 6px rgba(0, 0, 0, 0.1)",
-        }
-        
-        // Create button style
-        buttonStyle := map[string]interface{}{
+        // Scoped classes, in place of inline styles
+        buttonStyle := gouix.Style{
                 "background-color": "#6c757d",
                 "color":            "#ffffff",
                 "border":           "none",
@@ -282,23 +256,37 @@ func CounterWithHooks(props gouix.Props, children ...interface{}) string {
                 "font-size":        "14px",
                 "transition":       "background-color 0.2s",
         }
+        resetStyle := gouix.Style{}
+        for property, value := range buttonStyle {
+                resetStyle[property] = value
+        }
+        resetStyle["background-color"] = "#dc3545"
         
         // Create counter element
         return gouix.CreateElement("div", gouix.Props{
-                "class": "counter-hooks",
-                "style": containerStyle,
-                "id":    id,
+                "class": "counter-hooks " + gouix.Class("counter-hooks", gouix.Style{
+                        "background-color": "#f8f9fa",
+                        "color":            "#333333",
+                        "border":           "1px solid #dee2e6",
+                        "border-radius":    "8px",
+                        "padding":          "16px",
+                        "text-align":       "center",
+                        "width":            "300px",
+                        "margin":           "20px auto",
+                        "box-shadow":       "0 4px 6px rgba(0, 0, 0, 0.1)",
+                }),
+                "id": id,
         },
                 // Title
                 gouix.CreateElement("h3", nil, title),
                 
                 // Count display
                 gouix.CreateElement("div", gouix.Props{
-                        "style": map[string]interface{}{
+                        "class": gouix.Class("counter-hooks-count", gouix.Style{
                                 "font-size":   "24px",
                                 "font-weight": "bold",
                                 "margin":      "16px 0",
-                        },
+                        }),
                 }, fmt.Sprintf("Count: %d", count.Get())),
                 
                 // Children
@@ -306,39 +294,29 @@ func CounterWithHooks(props gouix.Props, children ...interface{}) string {
                 
                 // Buttons
                 gouix.CreateElement("div", gouix.Props{
-                        "style": map[string]interface{}{
+                        "class": gouix.Class("counter-hooks-buttons", gouix.Style{
                                 "display":         "flex",
                                 "justify-content": "center",
                                 "margin-top":      "16px",
-                        },
+                        }),
                 },
                         // Decrement button
                         gouix.CreateElement("button", gouix.Props{
-                                "style":   buttonStyle,
+                                "class":   gouix.Class("counter-hooks-button", buttonStyle),
                                 "onclick": fmt.Sprintf("_gouix.dispatchEvent('%s', 'decrement', {})", id),
                                 "id":      id + "-decrement",
                         }, "-"),
                         
                         // Reset button
                         gouix.CreateElement("button", gouix.Props{
-                                "style": map[string]interface{}{
-                                        "background-color": "#dc3545",
-                                        "color":            "#ffffff",
-                                        "border":           "none",
-                                        "border-radius":    "4px",
-                                        "padding":          "8px 16px",
-                                        "margin":           "0 8px",
-                                        "cursor":           "pointer",
-                                        "font-size":        "14px",
-                                        "transition":       "background-color 0.2s",
-                                },
+                                "class":   gouix.Class("counter-hooks-reset", resetStyle),
                                 "onclick": fmt.Sprintf("_gouix.dispatchEvent('%s', 'reset', {})", id),
                                 "id":      id + "-reset",
                         }, "Reset"),
                         
                         // Increment button
                         gouix.CreateElement("button", gouix.Props{
-                                "style":   buttonStyle,
+                                "class":   gouix.Class("counter-hooks-button", buttonStyle),
                                 "onclick": fmt.Sprintf("_gouix.dispatchEvent('%s', 'increment', {})", id),
                                 "id":      id + "-increment",
                         }, "+"),
@@ -1,15 +1,19 @@
 package components
 
 import (
+	"regexp"
 	"strings"
 	"testing"
 
 	"github.com/davidjeba/goscript/pkg/gouix"
 )
 
-func TestCounter(t *testing.T) {
+// scopedClass matches a counter's container class, generated by gouix.Class
+var scopedClass = regexp.MustCompile(`class="counter (?:draggable )?(counter-[0-9a-f]{8})"`)
+
+func TestGoUIXCounter(t *testing.T) {
 	// Create a counter
-	counter := NewCounter("test-counter", gouix.Props{
+	counter := NewGoUIXCounter("test-counter", gouix.Props{
 		"initialCount": 5,
 		"title":        "Test Counter",
 		"theme":        "light",
@@ -27,6 +31,18 @@ func TestCounter(t *testing.T) {
 		t.Errorf("Counter should display initial count of 5")
 	}
 
+	// Check that the styles are scoped classes, with their rules in the sheet
+	if strings.Contains(html, "style=") {
+		t.Errorf("Counter should not render inline styles: %s", html)
+	}
+	class := scopedClass.FindStringSubmatch(html)
+	if class == nil {
+		t.Fatalf("Counter should have a scoped class: %s", html)
+	}
+	if css := gouix.Styles.CSS(); !strings.Contains(css, "."+class[1]+" {") || !strings.Contains(css, "background-color: #ffffff") {
+		t.Errorf("Styles should hold the rules of %s: %s", class[1], css)
+	}
+
 	// Test increment event
 	counter.HandleEvent(gouix.Event{
 		Type:   "increment",
@@ -64,9 +80,9 @@ func TestCounter(t *testing.T) {
 	}
 }
 
-func TestDraggableCounter(t *testing.T) {
+func TestDraggableGoUIXCounter(t *testing.T) {
 	// Create a draggable counter
-	counter := NewDraggableCounter("test-draggable", gouix.Props{
+	counter := NewDraggableGoUIXCounter("test-draggable", gouix.Props{
 		"initialCount": 10,
 		"title":        "Draggable Counter",
 		"theme":        "dark",
@@ -80,9 +96,15 @@ func TestDraggableCounter(t *testing.T) {
 		t.Errorf("Draggable counter should have draggable attribute")
 	}
 
-	// Check that the counter has the correct class
-	if !strings.Contains(html, "class=\"counter draggable\"") {
-		t.Errorf("Draggable counter should have 'draggable' class")
+	// Check that the counter has the draggable class before its scoped one
+	if !strings.Contains(html, "class=\"counter draggable counter-") {
+		t.Errorf("Draggable counter should have 'draggable' class: %s", html)
+	}
+
+	// Check that the dark theme has its own scoped class
+	light := NewGoUIXCounter("test-light", gouix.Props{"theme": "light"}).Render()
+	if dark := scopedClass.FindStringSubmatch(html); dark == nil || strings.Contains(light, dark[1]) {
+		t.Errorf("Dark and light counters should have different classes: %s", html)
 	}
 
 	// Check that the counter renders correctly
@@ -136,7 +158,7 @@ func TestCounterWithHooks(t *testing.T) {
 		t.Errorf("Counter should contain title 'Hooks Counter'")
 	}
 
-	if !strings.Contains(html, ">15<") {
+	if !strings.Contains(html, "Count: 15") {
 		t.Errorf("Counter should display initial count of 15")
 	}
 
@@ -146,9 +168,9 @@ func TestCounterWithHooks(t *testing.T) {
 	}
 }
 
-func TestHomePage(t *testing.T) {
+func TestGoUIXHomePage(t *testing.T) {
 	// Create a home page
-	home := NewHomePage("test-home", nil)
+	home := NewGoUIXHomePage("test-home", nil)
 
 	// Render the home page
 	html := home.Render()
@@ -187,4 +209,4 @@ func TestHomePage(t *testing.T) {
 	if !strings.Contains(html, "Counter 3") {
 		t.Errorf("Home page should contain 'Counter 3' after adding a counter")
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,33 @@
+// Code generated by gopm uix:props. DO NOT EDIT.
+
+package components
+
+import "github.com/davidjeba/goscript/pkg/gouix"
+
+// Props returns the props of a GoUIXCounter. Fields left at zero are left out, so
+// the component applies its defaults.
+func (p GoUIXCounterProps) Props() gouix.Props {
+	props := gouix.Props{}
+	if p.InitialCount != 0 {
+		props["initialCount"] = p.InitialCount
+	}
+	if p.Title != "" {
+		props["title"] = p.Title
+	}
+	if p.Theme != "" {
+		props["theme"] = p.Theme
+	}
+	return props
+}
+
+// BindGoUIXCounterProps reads GoUIXCounterProps from props, applying the defaults
+func BindGoUIXCounterProps(props gouix.Props) (GoUIXCounterProps, error) {
+	var p GoUIXCounterProps
+	err := gouix.BindProps(props, &p)
+	return p, err
+}
+
+// NewGoUIXCounterWithProps creates a GoUIXCounter from typed props
+func NewGoUIXCounterWithProps(id gouix.ComponentID, props GoUIXCounterProps) *GoUIXCounter {
+	return NewGoUIXCounter(id, props.Props())
+}
@@ -30,16 +30,15 @@ func NewGoUIXHomePage(id gouix.ComponentID, props gouix.Props) *GoUIXHomePage {
         }
         
         // Create counters
-        counter1 := NewGoUIXCounter("counter-1", gouix.Props{
-                "initialCount": 0,
-                "title":        "Counter 1",
-                "theme":        "light",
+        counter1 := NewGoUIXCounterWithProps("counter-1", GoUIXCounterProps{
+                Title: "Counter 1",
+                Theme: "light",
         })
         
-        counter2 := NewGoUIXCounter("counter-2", gouix.Props{
-                "initialCount": 10,
-                "title":        "Counter 2",
-                "theme":        "dark",
+        counter2 := NewGoUIXCounterWithProps("counter-2", GoUIXCounterProps{
+                InitialCount: 10,
+                Title:        "Counter 2",
+                Theme:        "dark",
         })
         
         counter3 := NewDraggableGoUIXCounter("counter-3", gouix.Props{
@@ -65,13 +64,13 @@ func NewGoUIXHomePage(id gouix.ComponentID, props gouix.Props) *GoUIXHomePage {
 
 // addCounter adds a new counter
 func (h *GoUIXHomePage) addCounter(event gouix.Event) interface{} {
-        // Create a new counter with a unique ID
-        id := gouix.ComponentID(fmt.Sprintf("counter-%d", len(h.counters)+1))
+        // Create a new counter with a unique ID; draggable counters share the
+        // ID sequence, so events are not routed to the wrong counter
+        id := gouix.ComponentID(fmt.Sprintf("counter-%d", len(h.counters)+len(h.dragCounters)+1))
         
-        counter := NewGoUIXCounter(id, gouix.Props{
-                "initialCount": 0,
-                "title":        fmt.Sprintf("Counter %d", len(h.counters)+1),
-                "theme":        "light",
+        counter := NewGoUIXCounterWithProps(id, GoUIXCounterProps{
+                Title: fmt.Sprintf("Counter %d", len(h.counters)+1),
+                Theme: "light",
         })
         
         h.counters = append(h.counters, counter)
@@ -79,6 +78,24 @@ func (h *GoUIXHomePage) addCounter(event gouix.Event) interface{} {
         return nil
 }
 
+// FindComponent returns the counter with the given ID, so the live hub can
+// route browser events to it
+func (h *GoUIXHomePage) FindComponent(id gouix.ComponentID) gouix.Component {
+        for _, counter := range h.counters {
+                if counter.GetID() == id {
+                        return counter
+                }
+        }
+        
+        for _, counter := range h.dragCounters {
+                if counter.GetID() == id {
+                        return counter
+                }
+        }
+        
+        return nil
+}
+
 // Render implements the Component interface
 func (h *GoUIXHomePage) Render() string {
         // Create header style
@@ -228,104 +245,75 @@ func (h *GoUIXHomePage) Render() string {
                 
                 // Client-side script
                 gouix.CreateElement("script", nil, `
-                        // Initialize GoUIX
-                        if (typeof _gouix === 'undefined') {
-                                _gouix = {
-                                        components: {},
+                        // Drag and touch helpers; component events are sent to the
+                        // server by the GoUIX live runtime
+                        window._gouix = window._gouix || {};
+                        (function(helpers) {
+                                for (var name in helpers) {
+                                        _gouix[name] = helpers[name];
+                                }
+                        })({
+                                // Drag handling
+                                dragStart: function(event) {
+                                        const el = event.target;
+                                        el.style.opacity = '0.8';
                                         
-                                        // Event handling
-                                        dispatchEvent: function(componentId, eventType, data) {
-                                                const event = {
-                                                        type: eventType,
-                                                        target: componentId,
-                                                        data: data || {},
-                                                        bubbles: true
-                                                };
-                                                
-                                                // Send event to server
-                                                console.log('Event:', event);
-                                                
-                                                // For demo purposes, update the UI directly
-                                                if (eventType === 'increment') {
-                                                        const countEl = document.getElementById(componentId + '-count');
-                                                        if (countEl) {
-                                                                countEl.textContent = (parseInt(countEl.textContent) + 1).toString();
-                                                        }
-                                                } else if (eventType === 'decrement') {
-                                                        const countEl = document.getElementById(componentId + '-count');
-                                                        if (countEl) {
-                                                                countEl.textContent = (parseInt(countEl.textContent) - 1).toString();
-                                                        }
-                                                } else if (eventType === 'reset') {
-                                                        const countEl = document.getElementById(componentId + '-count');
-                                                        if (countEl) {
-                                                                countEl.textContent = '0';
-                                                        }
-                                                }
-                                        },
+                                        // Store initial position
+                                        el._startX = event.clientX;
+                                        el._startY = event.clientY;
+                                        el._initialLeft = parseInt(el.style.left || '0');
+                                        el._initialTop = parseInt(el.style.top || '0');
                                         
-                                        // Drag handling
-                                        dragStart: function(event) {
-                                                const el = event.target;
-                                                el.style.opacity = '0.8';
-                                                
-                                                // Store initial position
-                                                el._startX = event.clientX;
-                                                el._startY = event.clientY;
-                                                el._initialLeft = parseInt(el.style.left || '0');
-                                                el._initialTop = parseInt(el.style.top || '0');
-                                                
-                                                event.dataTransfer.setData('text/plain', el.id);
-                                                event.dataTransfer.effectAllowed = 'move';
-                                        },
+                                        event.dataTransfer.setData('text/plain', el.id);
+                                        event.dataTransfer.effectAllowed = 'move';
+                                },
+                                
+                                drag: function(event) {
+                                        // Handled by the browser
+                                },
+                                
+                                dragEnd: function(event) {
+                                        const el = event.target;
+                                        el.style.opacity = '1';
                                         
-                                        drag: function(event) {
-                                                // Handled by the browser
-                                        },
+                                        // Calculate new position
+                                        const dx = event.clientX - el._startX;
+                                        const dy = event.clientY - el._startY;
                                         
-                                        dragEnd: function(event) {
-                                                const el = event.target;
-                                                el.style.opacity = '1';
-                                                
-                                                // Calculate new position
-                                                const dx = event.clientX - el._startX;
-                                                const dy = event.clientY - el._startY;
-                                                
-                                                el.style.left = (el._initialLeft + dx) + 'px';
-                                                el.style.top = (el._initialTop + dy) + 'px';
-                                        },
+                                        el.style.left = (el._initialLeft + dx) + 'px';
+                                        el.style.top = (el._initialTop + dy) + 'px';
+                                },
+                                
+                                // Touch handling
+                                touchStart: function(event) {
+                                        const el = event.target;
+                                        const touch = event.touches[0];
                                         
-                                        // Touch handling
-                                        touchStart: function(event) {
-                                                const el = event.target;
-                                                const touch = event.touches[0];
-                                                
-                                                // Store initial position
-                                                el._startX = touch.clientX;
-                                                el._startY = touch.clientY;
-                                                el._initialLeft = parseInt(el.style.left || '0');
-                                                el._initialTop = parseInt(el.style.top || '0');
-                                        },
+                                        // Store initial position
+                                        el._startX = touch.clientX;
+                                        el._startY = touch.clientY;
+                                        el._initialLeft = parseInt(el.style.left || '0');
+                                        el._initialTop = parseInt(el.style.top || '0');
+                                },
+                                
+                                touchMove: function(event) {
+                                        const el = event.target;
+                                        const touch = event.touches[0];
                                         
-                                        touchMove: function(event) {
-                                                const el = event.target;
-                                                const touch = event.touches[0];
-                                                
-                                                // Calculate new position
-                                                const dx = touch.clientX - el._startX;
-                                                const dy = touch.clientY - el._startY;
-                                                
-                                                el.style.left = (el._initialLeft + dx) + 'px';
-                                                el.style.top = (el._initialTop + dy) + 'px';
-                                                
-                                                event.preventDefault();
-                                        },
+                                        // Calculate new position
+                                        const dx = touch.clientX - el._startX;
+                                        const dy = touch.clientY - el._startY;
                                         
-                                        touchEnd: function(event) {
-                                                // Touch ended
-                                        }
-                                };
-                        }
+                                        el.style.left = (el._initialLeft + dx) + 'px';
+                                        el.style.top = (el._initialTop + dy) + 'px';
+                                        
+                                        event.preventDefault();
+                                },
+                                
+                                touchEnd: function(event) {
+                                        // Touch ended
+                                }
+                        });
                         
                         // Initialize canvas
                         if (document.getElementById('home-canvas')) {
@@ -0,0 +1,301 @@
+// Package charts draws line, bar, area and sparkline charts of time series,
+// such as the results of GoScaleDB queries, on a Canvas2D context, with
+// axes, a legend and tooltips
+package charts
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/gocsx/engine"
+)
+
+// Kind is how a chart draws its series
+type Kind string
+
+const (
+	Line      Kind = "line"
+	Bar       Kind = "bar"
+	Area      Kind = "area"
+	Sparkline Kind = "sparkline"
+)
+
+// Point is a value of a series at a time. Label, when set, names the point
+// on the axis and in its tooltip instead of its time.
+type Point struct {
+	Time  time.Time `json:"time"`
+	Value float64   `json:"value"`
+	Label string    `json:"label,omitempty"`
+}
+
+// Series is a named line, area or set of bars, its points in time order.
+// An empty Color takes the next of the theme's palette.
+type Series struct {
+	Name   string  `json:"name"`
+	Color  string  `json:"color,omitempty"`
+	Points []Point `json:"points"`
+}
+
+// Theme is the colors and font of a chart
+type Theme struct {
+	// Background fills the chart; empty leaves the canvas transparent
+	Background string
+	Text       string
+	Muted      string
+	Grid       string
+	FontFamily string
+	FontSize   float64
+	Palette    []string
+}
+
+// LightTheme is the theme for charts on a light background
+func LightTheme() Theme {
+	return Theme{
+		Text:       "#333333",
+		Muted:      "#777777",
+		Grid:       "#e5e5e5",
+		FontFamily: "sans-serif",
+		FontSize:   11,
+		Palette:    []string{"#2196f3", "#ff9800", "#4caf50", "#e91e63", "#9c27b0", "#00bcd4", "#795548", "#607d8b"},
+	}
+}
+
+// DarkTheme is the theme for charts on a dark background
+func DarkTheme() Theme {
+	theme := LightTheme()
+	theme.Text = "#eeeeee"
+	theme.Muted = "#aaaaaa"
+	theme.Grid = "#444444"
+	theme.Palette = []string{"#64b5f6", "#ffb74d", "#81c784", "#f06292", "#ba68c8", "#4dd0e1", "#a1887f", "#90a4ae"}
+	return theme
+}
+
+// Chart is a chart of time series, Width by Height CSS pixels
+type Chart struct {
+	Kind   Kind
+	Title  string
+	Width  float64
+	Height float64
+	Series []Series
+
+	// Unit follows the values in tooltips, such as "ms"
+	Unit string
+
+	Theme Theme
+}
+
+// New creates a chart of series in the light theme
+func New(kind Kind, width, height float64, series ...Series) *Chart {
+	return &Chart{Kind: kind, Width: width, Height: height, Series: series, Theme: LightTheme()}
+}
+
+// SeriesFromRows groups the rows of a GoScaleDB time-series query, such as
+//
+//	SELECT time_bucket('1 minute', time) AS time, host, avg(value) AS value ...
+//
+// into a series per value of seriesColumn, in the order they first appear,
+// or into one series named after valueColumn when seriesColumn is empty.
+// Rows without a value, such as the average of an empty bucket, are skipped.
+func SeriesFromRows(rows []map[string]interface{}, timeColumn, valueColumn, seriesColumn string) ([]Series, error) {
+	series := []Series{}
+	index := make(map[string]int)
+	for _, row := range rows {
+		if row[valueColumn] == nil {
+			continue
+		}
+		at, err := rowTime(row[timeColumn])
+		if err != nil {
+			return nil, fmt.Errorf("charts: column %q: %v", timeColumn, err)
+		}
+		value, err := rowValue(row[valueColumn])
+		if err != nil {
+			return nil, fmt.Errorf("charts: column %q: %v", valueColumn, err)
+		}
+
+		name := valueColumn
+		if seriesColumn != "" {
+			name = fmt.Sprint(row[seriesColumn])
+		}
+		i, ok := index[name]
+		if !ok {
+			i = len(series)
+			index[name] = i
+			series = append(series, Series{Name: name})
+		}
+		series[i].Points = append(series[i].Points, Point{Time: at, Value: value})
+	}
+
+	for _, s := range series {
+		points := s.Points
+		sort.SliceStable(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+	}
+	return series, nil
+}
+
+// rowTimeLayouts are the layouts times come back as from the stores
+var rowTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05.999999999"}
+
+// rowTime reads the time of a row
+func rowTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case []byte:
+		return rowTime(string(v))
+	case string:
+		for _, layout := range rowTimeLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("unrecognized time %q", v)
+	}
+	return time.Time{}, fmt.Errorf("unexpected time %T", value)
+}
+
+// rowValue reads the value of a row
+func rowValue(value interface{}) (float64, error) {
+	var f float64
+	switch v := value.(type) {
+	case float64:
+		f = v
+	case float32:
+		f = float64(v)
+	case int:
+		f = float64(v)
+	case int32:
+		f = float64(v)
+	case int64:
+		f = float64(v)
+	case []byte:
+		return rowValue(string(v))
+	case string:
+		parsed, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("unrecognized value %q", v)
+		}
+		f = parsed
+	default:
+		return 0, fmt.Errorf("unexpected value %T", value)
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("value %v is not finite", f)
+	}
+	return f, nil
+}
+
+// Tooltip is what a chart shows for a point or bar under the pointer
+type Tooltip struct {
+	Series string    `json:"series,omitempty"`
+	Time   time.Time `json:"time"`
+	Value  float64   `json:"value"`
+	Text   string    `json:"text"`
+	Color  string    `json:"color"`
+
+	// X, Y is where the point was drawn, and Bounds the bar, in CSS pixels
+	X      float64    `json:"x"`
+	Y      float64    `json:"y"`
+	Bounds [4]float64 `json:"bounds"`
+}
+
+// Plot is where Draw put a chart's data
+type Plot struct {
+	// Area is the rectangle inside the axes
+	Area     [4]float64
+	Tooltips []Tooltip
+}
+
+// hitRadius is how far from a point the pointer shows its tooltip
+const hitRadius = 24
+
+// TooltipAt returns the tooltip of the bar under x, y, or else of the
+// nearest point within hitRadius
+func (p *Plot) TooltipAt(x, y float64) (Tooltip, bool) {
+	var found Tooltip
+	best, ok := float64(hitRadius), false
+	for _, tooltip := range p.Tooltips {
+		b := tooltip.Bounds
+		if x >= b[0] && x <= b[2] && y >= b[1] && y <= b[3] {
+			return tooltip, true
+		}
+		if d := math.Hypot(x-tooltip.X, y-tooltip.Y); d <= best {
+			best, found, ok = d, tooltip, true
+		}
+	}
+	return found, ok
+}
+
+// HTML draws the chart for a browser: a canvas whose drawing Runtime replays
+// and whose tooltips it shows on hover
+func (c *Chart) HTML() (template.HTML, error) {
+	ctx := engine.NewCanvas2DRecorder()
+	plot := c.Draw(ctx)
+
+	commands, err := ctx.Flush()
+	if err != nil {
+		return "", err
+	}
+	tooltips, err := json.Marshal(plot.Tooltips)
+	if err != nil {
+		return "", err
+	}
+
+	label := c.Title
+	if label == "" {
+		label = string(c.Kind) + " chart"
+	}
+	return template.HTML(fmt.Sprintf(
+		`<canvas class="gocsx-chart" width="%d" height="%d" role="img" aria-label="%s" data-gocsx-commands="%s" data-gocsx-tooltips="%s"></canvas>`,
+		int(math.Ceil(c.Width)), int(math.Ceil(c.Height)), template.HTMLEscapeString(label),
+		template.HTMLEscapeString(string(commands)), template.HTMLEscapeString(string(tooltips)),
+	)), nil
+}
+
+// Runtime is the browser script for charts from HTML: it draws them with
+// engine.Canvas2DRuntime, and shows the tooltip under the pointer
+const Runtime = engine.Canvas2DRuntime + tooltipRuntime
+
+const tooltipRuntime = `(function () {
+  var tip = null;
+  function tooltips(canvas) {
+    if (!canvas.gocsxTooltips) {
+      try { canvas.gocsxTooltips = JSON.parse(canvas.getAttribute("data-gocsx-tooltips")) || []; } catch (e) { canvas.gocsxTooltips = []; }
+    }
+    return canvas.gocsxTooltips;
+  }
+  function at(list, x, y) {
+    var found = null, best = 24;
+    for (var i = 0; i < list.length; i++) {
+      var t = list[i], b = t.bounds;
+      if (x >= b[0] && x <= b[2] && y >= b[1] && y <= b[3]) { return t; }
+      var d = Math.sqrt((x - t.x) * (x - t.x) + (y - t.y) * (y - t.y));
+      if (d <= best) { best = d; found = t; }
+    }
+    return found;
+  }
+  function hide() { if (tip) { tip.style.display = "none"; } }
+  document.addEventListener("mousemove", function (event) {
+    var canvas = event.target;
+    if (!canvas.hasAttribute || !canvas.hasAttribute("data-gocsx-tooltips")) { hide(); return; }
+    var rect = canvas.getBoundingClientRect(), size = canvas.gocsxSize || [rect.width, rect.height];
+    var t = at(tooltips(canvas), (event.clientX - rect.left) * size[0] / rect.width, (event.clientY - rect.top) * size[1] / rect.height);
+    if (!t) { hide(); return; }
+    if (!tip) {
+      tip = document.createElement("div");
+      tip.className = "gocsx-chart-tooltip";
+      tip.style.cssText = "position: fixed; pointer-events: none; z-index: 2147483647; padding: 4px 8px; border-radius: 4px; font: 12px sans-serif; background: rgba(0, 0, 0, 0.8); color: #fff; border-left: 3px solid";
+      document.body.appendChild(tip);
+    }
+    tip.textContent = t.text;
+    tip.style.borderLeftColor = t.color;
+    tip.style.left = (event.clientX + 12) + "px";
+    tip.style.top = (event.clientY + 12) + "px";
+    tip.style.display = "block";
+  });
+})();
+`
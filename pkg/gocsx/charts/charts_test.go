@@ -0,0 +1,185 @@
+package charts
+
+import (
+	"encoding/json"
+	"html"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/gocsx/engine"
+)
+
+var start = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func TestSeriesFromRows(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"time": start.Add(time.Minute), "host": "edge-1", "value": 12.5},
+		{"time": start.Format(time.RFC3339Nano), "host": "edge-1", "value": int64(10)},
+		{"time": start, "host": "edge-2", "value": []byte("7")},
+		{"time": start.Add(time.Minute), "host": "edge-2", "value": nil},
+	}
+	series, err := SeriesFromRows(rows, "time", "value", "host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 2 || series[0].Name != "edge-1" || series[1].Name != "edge-2" {
+		t.Fatalf("expected a series per host in order, got %+v", series)
+	}
+	if points := series[0].Points; len(points) != 2 || points[0].Value != 10 || !points[0].Time.Equal(start) {
+		t.Fatalf("expected the points in time order, got %+v", points)
+	}
+	if len(series[1].Points) != 1 {
+		t.Fatalf("expected the empty bucket skipped, got %+v", series[1].Points)
+	}
+
+	single, err := SeriesFromRows(rows[:1], "time", "value", "")
+	if err != nil || len(single) != 1 || single[0].Name != "value" {
+		t.Fatalf("expected one series named after the value column, got %+v, %v", single, err)
+	}
+	if _, err := SeriesFromRows([]map[string]interface{}{{"time": 42, "value": 1.0}}, "time", "value", ""); err == nil {
+		t.Fatalf("expected an unexpected time to be refused")
+	}
+}
+
+func TestNiceTicks(t *testing.T) {
+	ticks, step := niceTicks(3, 57, 4)
+	if step != 20 || len(ticks) != 4 || ticks[0] != 0 || ticks[3] != 60 {
+		t.Fatalf("unexpected ticks %v step %v", ticks, step)
+	}
+	if got := formatValue(0.25, 0.05, 1); got != "0.25" {
+		t.Fatalf("unexpected label %q", got)
+	}
+	if got := formatValue(25000, 5000, 30000); got != "25k" {
+		t.Fatalf("unexpected label %q", got)
+	}
+
+	times := timeTicks(start.Add(10*time.Second), start.Add(time.Hour), 5)
+	if len(times) != 4 || times[0].label != "12:15" || times[3].label != "13:00" {
+		t.Fatalf("unexpected time ticks %+v", times)
+	}
+}
+
+func TestDrawLineChart(t *testing.T) {
+	latency := Series{Name: "p95", Points: []Point{{Time: start, Value: 120}, {Time: start.Add(time.Minute), Value: 80}, {Time: start.Add(2 * time.Minute), Value: 160}}}
+	errors := Series{Name: "errors", Color: "#f44336", Points: []Point{{Time: start, Value: 1}, {Time: start.Add(2 * time.Minute), Value: 4}}}
+	chart := New(Line, 400, 200, latency, errors)
+	chart.Title = "Latency"
+	chart.Unit = "ms"
+
+	ctx := engine.NewCanvas2DRecorder()
+	plot := chart.Draw(ctx)
+	if len(plot.Tooltips) != 5 {
+		t.Fatalf("expected a tooltip per point, got %d", len(plot.Tooltips))
+	}
+
+	// Later points are further right, and larger values higher
+	first, second, third := plot.Tooltips[0], plot.Tooltips[1], plot.Tooltips[2]
+	if !(first.X < second.X && second.X < third.X) || !(third.Y < first.Y && first.Y < second.Y) {
+		t.Fatalf("unexpected positions %+v", plot.Tooltips[:3])
+	}
+	if first.X != plot.Area[0] || third.X != plot.Area[2] {
+		t.Fatalf("expected the points to span the plot, got %v..%v in %v", first.X, third.X, plot.Area)
+	}
+	if third.Text != "p95 · 2024-03-01 12:02:00 · 160 ms" || third.Color != chart.Theme.Palette[0] {
+		t.Fatalf("unexpected tooltip %+v", third)
+	}
+	if tooltip, ok := plot.TooltipAt(third.X-5, third.Y+5); !ok || tooltip.Value != 160 {
+		t.Fatalf("expected the nearest point, got %+v", tooltip)
+	}
+	if _, ok := plot.TooltipAt(plot.Area[0]+100, plot.Area[1]-100); ok {
+		t.Fatalf("expected nothing far from the points")
+	}
+
+	var texts []string
+	for _, command := range ctx.Commands() {
+		if command.Op == "fillText" {
+			texts = append(texts, command.Args[0].(string))
+		}
+	}
+	drawn := strings.Join(texts, "|")
+	for _, want := range []string{"Latency", "p95", "errors", "12:00", "200"} {
+		if !strings.Contains(drawn, want) {
+			t.Fatalf("expected %q among the labels, got %s", want, drawn)
+		}
+	}
+	if ctx.FillStyle != "#000000" || ctx.LineWidth != 1 || ctx.TextAlign != "start" {
+		t.Fatalf("expected the context's styles restored")
+	}
+}
+
+func TestDrawBarChart(t *testing.T) {
+	requests := Series{Name: "requests", Points: []Point{
+		{Time: start, Value: 350, Label: "/main.js"},
+		{Time: start.Add(20 * time.Millisecond), Value: 120, Label: "/styles.css"},
+		{Time: start.Add(20 * time.Millisecond), Value: 40, Label: "/logo.svg"},
+	}}
+	plot := New(Bar, 300, 200, requests).Draw(engine.NewCanvas2DRecorder())
+
+	if len(plot.Tooltips) != 3 {
+		t.Fatalf("expected a bar per request, got %d", len(plot.Tooltips))
+	}
+	main := plot.Tooltips[0]
+	if main.Bounds[3] != plot.Area[3] || main.Bounds[1] >= plot.Tooltips[1].Bounds[1] {
+		t.Fatalf("expected bars up from the axis, the longest tallest, got %+v", plot.Tooltips)
+	}
+	if plot.Tooltips[1].Bounds[0] == plot.Tooltips[2].Bounds[0] {
+		t.Fatalf("expected requests at the same time in their own slots")
+	}
+	if tooltip, ok := plot.TooltipAt(main.Bounds[0]+1, main.Bounds[3]-1); !ok || tooltip.Text != "requests · /main.js · 350" {
+		t.Fatalf("expected the bar under the pointer, got %+v", tooltip)
+	}
+}
+
+func TestDrawSparklineAndEmpty(t *testing.T) {
+	ctx := engine.NewCanvas2DRecorder()
+	plot := New(Sparkline, 100, 20, Series{Points: []Point{{Time: start, Value: 1}, {Time: start.Add(time.Second), Value: 3}}}).Draw(ctx)
+	if plot.Tooltips[0].Y != 18 || plot.Tooltips[1].Y != 2 {
+		t.Fatalf("expected the sparkline to span its height, got %+v", plot.Tooltips)
+	}
+	for _, command := range ctx.Commands() {
+		if command.Op == "fillText" {
+			t.Fatalf("expected no labels on a sparkline, got %v", command.Args)
+		}
+	}
+
+	ctx = engine.NewCanvas2DRecorder()
+	New(Area, 200, 100).Draw(ctx)
+	if !strings.Contains(commandsJSON(t, ctx), `"No data"`) {
+		t.Fatalf("expected an empty chart to say so")
+	}
+}
+
+func TestChartHTML(t *testing.T) {
+	chart := New(Area, 320, 160, Series{Name: "rps", Points: []Point{{Time: start, Value: 5}, {Time: start.Add(time.Minute), Value: 9}}})
+	chart.Title = `Requests "per" second`
+	embedded, err := chart.HTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	match := regexp.MustCompile(`^<canvas class="gocsx-chart" width="320" height="160" role="img" aria-label="Requests &#34;per&#34; second" data-gocsx-commands="([^"]*)" data-gocsx-tooltips="([^"]*)"></canvas>$`).FindStringSubmatch(string(embedded))
+	if match == nil {
+		t.Fatalf("unexpected canvas %s", embedded)
+	}
+	var commands []engine.Canvas2DCommand
+	if err := json.Unmarshal([]byte(html.UnescapeString(match[1])), &commands); err != nil || len(commands) == 0 {
+		t.Fatalf("expected the drawing in the attribute: %v", err)
+	}
+	var tooltips []Tooltip
+	if err := json.Unmarshal([]byte(html.UnescapeString(match[2])), &tooltips); err != nil || len(tooltips) != 2 {
+		t.Fatalf("expected the tooltips in the attribute: %v", err)
+	}
+	if !strings.Contains(Runtime, "window.gocsxCanvas") || !strings.Contains(Runtime, "data-gocsx-tooltips") {
+		t.Fatalf("expected the runtime to draw and show tooltips")
+	}
+}
+
+func commandsJSON(t *testing.T, ctx *engine.Canvas2DContext) string {
+	data, err := ctx.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,521 @@
+package charts
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/gocsx/engine"
+)
+
+// padding is the space around a chart, and sparklinePadding around a
+// sparkline, which keeps its line off the edges
+const (
+	padding          = 8
+	sparklinePadding = 2
+)
+
+// scale maps times and values to pixels inside a plot area
+type scale struct {
+	area   [4]float64
+	t0, t1 time.Time
+	v0, v1 float64
+}
+
+// x returns the pixel of a time
+func (s scale) x(t time.Time) float64 {
+	span := s.t1.Sub(s.t0)
+	if span <= 0 {
+		return (s.area[0] + s.area[2]) / 2
+	}
+	return s.area[0] + float64(t.Sub(s.t0))/float64(span)*(s.area[2]-s.area[0])
+}
+
+// y returns the pixel of a value
+func (s scale) y(v float64) float64 {
+	return s.area[3] - (v-s.v0)/(s.v1-s.v0)*(s.area[3]-s.area[1])
+}
+
+// slot is a bar position: a time, and the label that tells apart points at
+// the same time
+type slot struct {
+	time  time.Time
+	label string
+}
+
+// Draw draws the chart on ctx with its top left corner at the origin,
+// returning where its data went for hit testing. The context's styles are
+// as they were when it returns.
+func (c *Chart) Draw(ctx *engine.Canvas2DContext) *Plot {
+	fill, stroke, width, alpha := ctx.FillStyle, ctx.StrokeStyle, ctx.LineWidth, ctx.GlobalAlpha
+	font, align, baseline := ctx.Font, ctx.TextAlign, ctx.TextBaseline
+	defer func() {
+		ctx.FillStyle, ctx.StrokeStyle, ctx.LineWidth, ctx.GlobalAlpha = fill, stroke, width, alpha
+		ctx.Font, ctx.TextAlign, ctx.TextBaseline = font, align, baseline
+	}()
+
+	theme := c.theme()
+	if theme.Background != "" {
+		ctx.FillStyle = theme.Background
+		ctx.FillRect(0, 0, c.Width, c.Height)
+	}
+
+	if c.Kind == Sparkline {
+		area := [4]float64{sparklinePadding, sparklinePadding, c.Width - sparklinePadding, c.Height - sparklinePadding}
+		low, high := c.valueRange(false)
+		if low == high {
+			low, high = low-1, high+1
+		}
+		t0, t1 := c.timeRange()
+		plot := &Plot{Area: area}
+		c.drawLines(ctx, plot, theme, scale{area, t0, t1, low, high}, false)
+		return plot
+	}
+
+	label := engine.Font{Family: theme.FontFamily, Size: theme.FontSize, Weight: 400}
+	area := [4]float64{padding, padding, c.Width - padding, c.Height - padding}
+
+	if c.Title != "" {
+		title := ctx.LayoutText([]engine.TextSpan{{Text: c.Title}}, engine.TextLayoutOptions{
+			Font:     engine.Font{Family: theme.FontFamily, Size: theme.FontSize * 1.2, Weight: 700},
+			Color:    theme.Text,
+			MaxWidth: area[2] - area[0],
+		})
+		ctx.FillTextLayout(title, area[0], area[1])
+		area[1] += title.Height + padding
+	}
+
+	if len(c.Series) > 1 {
+		area[3] -= c.drawLegend(ctx, theme, label, area) + padding
+	}
+
+	// The time or bar labels under the plot
+	lineHeight := math.Ceil(label.Size * 1.2)
+	area[3] -= lineHeight + padding/2
+
+	low, high := c.valueRange(c.Kind == Bar || c.Kind == Area)
+	ticks, step := niceTicks(low, high, int(math.Max(2, (area[3]-area[1])/40)))
+	labels := make([]string, len(ticks))
+	ctx.Font = label.String()
+	var labelWidth float64
+	for i, tick := range ticks {
+		labels[i] = formatValue(tick, step, high)
+		labelWidth = math.Max(labelWidth, ctx.MeasureText(labels[i]))
+	}
+	area[0] += math.Ceil(labelWidth) + padding
+
+	plot := &Plot{Area: area}
+	t0, t1 := c.timeRange()
+	s := scale{area, t0, t1, ticks[0], ticks[len(ticks)-1]}
+
+	// Grid lines and value labels
+	ctx.LineWidth = 1
+	ctx.StrokeStyle = theme.Grid
+	ctx.FillStyle = theme.Muted
+	ctx.TextAlign, ctx.TextBaseline = "right", "middle"
+	for i, tick := range ticks {
+		y := math.Round(s.y(tick)) + 0.5
+		ctx.BeginPath()
+		ctx.MoveTo(area[0], y)
+		ctx.LineTo(area[2], y)
+		ctx.Stroke()
+		ctx.FillText(labels[i], area[0]-padding/2, y)
+	}
+
+	if !c.hasPoints() {
+		ctx.TextAlign = "center"
+		ctx.FillText("No data", (area[0]+area[2])/2, (area[1]+area[3])/2)
+		return plot
+	}
+
+	ctx.TextAlign, ctx.TextBaseline = "center", "top"
+	below := area[3] + padding/2
+	switch c.Kind {
+	case Bar:
+		c.drawBars(ctx, plot, theme, s, below)
+	default:
+		for _, tick := range timeTicks(t0, t1, int(math.Max(1, (area[2]-area[0])/80))) {
+			ctx.FillStyle = theme.Muted
+			ctx.FillText(tick.label, s.x(tick.time), below)
+		}
+		c.drawLines(ctx, plot, theme, s, c.Kind == Area)
+	}
+	return plot
+}
+
+// theme returns the chart's theme, with the light theme's for what it
+// leaves out
+func (c *Chart) theme() Theme {
+	theme, light := c.Theme, LightTheme()
+	if theme.Text == "" {
+		theme.Text = light.Text
+	}
+	if theme.Muted == "" {
+		theme.Muted = light.Muted
+	}
+	if theme.Grid == "" {
+		theme.Grid = light.Grid
+	}
+	if theme.FontFamily == "" {
+		theme.FontFamily = light.FontFamily
+	}
+	if theme.FontSize <= 0 {
+		theme.FontSize = light.FontSize
+	}
+	if len(theme.Palette) == 0 {
+		theme.Palette = light.Palette
+	}
+	return theme
+}
+
+// color returns the color of the i-th series
+func (c *Chart) color(theme Theme, i int) string {
+	if c.Series[i].Color != "" {
+		return c.Series[i].Color
+	}
+	return theme.Palette[i%len(theme.Palette)]
+}
+
+// hasPoints reports whether any series has a point
+func (c *Chart) hasPoints() bool {
+	for _, series := range c.Series {
+		if len(series.Points) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// valueRange returns the lowest and highest values, including zero when the
+// chart fills down to it
+func (c *Chart) valueRange(zero bool) (float64, float64) {
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, series := range c.Series {
+		for _, point := range series.Points {
+			low, high = math.Min(low, point.Value), math.Max(high, point.Value)
+		}
+	}
+	if math.IsInf(low, 1) {
+		return 0, 1
+	}
+	if zero {
+		low, high = math.Min(low, 0), math.Max(high, 0)
+	}
+	return low, high
+}
+
+// timeRange returns the earliest and latest times
+func (c *Chart) timeRange() (time.Time, time.Time) {
+	var t0, t1 time.Time
+	first := true
+	for _, series := range c.Series {
+		for _, point := range series.Points {
+			if first || point.Time.Before(t0) {
+				t0 = point.Time
+			}
+			if first || point.Time.After(t1) {
+				t1 = point.Time
+			}
+			first = false
+		}
+	}
+	return t0, t1
+}
+
+// drawLegend draws a swatch and the name of each series along the bottom,
+// wrapping to more rows as needed, and returns its height
+func (c *Chart) drawLegend(ctx *engine.Canvas2DContext, theme Theme, font engine.Font, area [4]float64) float64 {
+	const swatch, gap = 10, 16
+	lineHeight := math.Ceil(font.Size * 1.4)
+	ctx.Font = font.String()
+
+	// Lay the items out in rows, starting another when one is full
+	type item struct {
+		x   float64
+		row int
+	}
+	items := make([]item, len(c.Series))
+	x, row := area[0], 0
+	for i, series := range c.Series {
+		width := swatch + 6 + ctx.MeasureText(series.Name)
+		if x > area[0] && x+width > area[2] {
+			x, row = area[0], row+1
+		}
+		items[i] = item{x, row}
+		x += width + gap
+	}
+	rows := row + 1
+
+	ctx.TextAlign, ctx.TextBaseline = "left", "middle"
+	for i, series := range c.Series {
+		y := area[3] - float64(rows-items[i].row)*lineHeight + lineHeight/2
+		ctx.FillStyle = c.color(theme, i)
+		ctx.FillRect(items[i].x, y-swatch/2, swatch, swatch)
+		ctx.FillStyle = theme.Text
+		ctx.FillText(series.Name, items[i].x+swatch+6, y)
+	}
+	return float64(rows) * lineHeight
+}
+
+// drawLines draws each series as a line through its points, filled down to
+// zero when fill is set, keeping their tooltips
+func (c *Chart) drawLines(ctx *engine.Canvas2DContext, plot *Plot, theme Theme, s scale, fill bool) {
+	base := s.y(math.Max(s.v0, math.Min(0, s.v1)))
+	sparkline := c.Kind == Sparkline
+	for i, series := range c.Series {
+		if len(series.Points) == 0 {
+			continue
+		}
+		color := c.color(theme, i)
+
+		if fill {
+			ctx.BeginPath()
+			ctx.MoveTo(s.x(series.Points[0].Time), base)
+			for _, point := range series.Points {
+				ctx.LineTo(s.x(point.Time), s.y(point.Value))
+			}
+			ctx.LineTo(s.x(series.Points[len(series.Points)-1].Time), base)
+			ctx.ClosePath()
+			ctx.FillStyle, ctx.GlobalAlpha = color, 0.2
+			ctx.Fill()
+			ctx.GlobalAlpha = 1
+		}
+
+		ctx.BeginPath()
+		for j, point := range series.Points {
+			if j == 0 {
+				ctx.MoveTo(s.x(point.Time), s.y(point.Value))
+			} else {
+				ctx.LineTo(s.x(point.Time), s.y(point.Value))
+			}
+		}
+		ctx.StrokeStyle, ctx.LineWidth = color, 2
+		if sparkline {
+			ctx.LineWidth = 1.5
+		}
+		ctx.Stroke()
+
+		// Mark the points while there are few enough to tell apart, and a
+		// sparkline's last
+		ctx.FillStyle = color
+		for j, point := range series.Points {
+			if (!sparkline && len(series.Points) <= 24) || (sparkline && j == len(series.Points)-1) {
+				ctx.BeginPath()
+				ctx.Arc(s.x(point.Time), s.y(point.Value), 2.5, 0, 2*math.Pi, false)
+				ctx.Fill()
+			}
+		}
+
+		for _, point := range series.Points {
+			x, y := s.x(point.Time), s.y(point.Value)
+			plot.Tooltips = append(plot.Tooltips, c.tooltip(series, point, color, x, y, [4]float64{x, y, x, y}))
+		}
+	}
+}
+
+// drawBars draws a group of bars per slot, a bar per series, with the
+// slots' labels below
+func (c *Chart) drawBars(ctx *engine.Canvas2DContext, plot *Plot, theme Theme, s scale, below float64) {
+	// The slots are the distinct times, and labels, of all series
+	seen := make(map[slot]bool)
+	var slots []slot
+	for _, series := range c.Series {
+		for _, point := range series.Points {
+			key := slot{point.Time, point.Label}
+			if !seen[key] {
+				seen[key] = true
+				slots = append(slots, key)
+			}
+		}
+	}
+	sort.SliceStable(slots, func(i, j int) bool { return slots[i].time.Before(slots[j].time) })
+	position := make(map[slot]int, len(slots))
+	for i, key := range slots {
+		position[key] = i
+	}
+
+	area := s.area
+	slotWidth := (area[2] - area[0]) / float64(len(slots))
+	barWidth := slotWidth * 0.8 / float64(len(c.Series))
+	base := s.y(math.Max(s.v0, math.Min(0, s.v1)))
+
+	for i, series := range c.Series {
+		color := c.color(theme, i)
+		ctx.FillStyle = color
+		for _, point := range series.Points {
+			x := area[0] + float64(position[slot{point.Time, point.Label}])*slotWidth + slotWidth*0.1 + float64(i)*barWidth
+			top, bottom := math.Min(s.y(point.Value), base), math.Max(s.y(point.Value), base)
+			if bottom-top < 1 {
+				top = bottom - 1
+			}
+			ctx.FillRect(x, top, barWidth, bottom-top)
+			plot.Tooltips = append(plot.Tooltips, c.tooltip(series, point, color, x+barWidth/2, s.y(point.Value), [4]float64{x, top, x + barWidth, bottom}))
+		}
+	}
+
+	// Label every slot that fits, truncating labels to the space they have
+	var step time.Duration
+	if len(slots) > 1 {
+		step = slots[len(slots)-1].time.Sub(slots[0].time) / time.Duration(len(slots)-1)
+	}
+	labels := make([]string, len(slots))
+	var widest float64
+	for i, key := range slots {
+		labels[i] = key.label
+		if labels[i] == "" {
+			labels[i] = key.time.Format(timeFormat(step))
+		}
+		widest = math.Max(widest, ctx.MeasureText(labels[i]))
+	}
+	every := int(math.Max(1, math.Ceil(math.Min(widest, 120)/slotWidth)))
+	ctx.FillStyle = theme.Muted
+	for i := 0; i < len(slots); i += every {
+		text := truncate(ctx, labels[i], slotWidth*float64(every)-4)
+		ctx.FillText(text, area[0]+(float64(i)+0.5)*slotWidth, below)
+	}
+}
+
+// tooltip describes a point
+func (c *Chart) tooltip(series Series, point Point, color string, x, y float64, bounds [4]float64) Tooltip {
+	parts := []string{}
+	if series.Name != "" {
+		parts = append(parts, series.Name)
+	}
+	if point.Label != "" {
+		parts = append(parts, point.Label)
+	} else {
+		parts = append(parts, point.Time.Format("2006-01-02 15:04:05"))
+	}
+	value := strconv.FormatFloat(math.Round(point.Value*100)/100, 'f', -1, 64)
+	if c.Unit != "" {
+		value += " " + c.Unit
+	}
+	parts = append(parts, value)
+
+	return Tooltip{
+		Series: series.Name,
+		Time:   point.Time,
+		Value:  point.Value,
+		Text:   strings.Join(parts, " · "),
+		Color:  color,
+		X:      x,
+		Y:      y,
+		Bounds: bounds,
+	}
+}
+
+// truncate shortens text with an ellipsis to fit width in the context's font
+func truncate(ctx *engine.Canvas2DContext, text string, width float64) string {
+	if ctx.MeasureText(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for n := len(runes) - 1; n > 0; n-- {
+		if shorter := string(runes[:n]) + "…"; ctx.MeasureText(shorter) <= width {
+			return shorter
+		}
+	}
+	return ""
+}
+
+// niceTicks returns about count round values covering low to high, such as
+// 0, 20, 40, 60 for 3 to 57, and the step between them
+func niceTicks(low, high float64, count int) ([]float64, float64) {
+	if low == high {
+		low, high = low-1, high+1
+	}
+	if count < 2 {
+		count = 2
+	}
+	step := niceNumber((high - low) / float64(count-1))
+	first, last := math.Floor(low/step), math.Ceil(high/step)
+
+	ticks := make([]float64, 0, int(last-first)+1)
+	for i := first; i <= last; i++ {
+		ticks = append(ticks, i*step)
+	}
+	return ticks, step
+}
+
+// niceNumber rounds a step up to 1, 2 or 5 times a power of ten
+func niceNumber(step float64) float64 {
+	magnitude := math.Pow(10, math.Floor(math.Log10(step)))
+	switch fraction := step / magnitude; {
+	case fraction <= 1:
+		return magnitude
+	case fraction <= 2:
+		return 2 * magnitude
+	case fraction <= 5:
+		return 5 * magnitude
+	}
+	return 10 * magnitude
+}
+
+// formatValue formats an axis value with as many decimals as the step
+// needs, abbreviating thousands and millions when the axis reaches them
+func formatValue(value, step, high float64) string {
+	suffix := ""
+	switch {
+	case math.Abs(high) >= 1e6:
+		value, step, suffix = value/1e6, step/1e6, "M"
+	case math.Abs(high) >= 1e4:
+		value, step, suffix = value/1e3, step/1e3, "k"
+	}
+	decimals := 0
+	if step < 1 {
+		decimals = int(math.Ceil(-math.Log10(step) - 1e-9))
+	}
+	return strconv.FormatFloat(value, 'f', decimals, 64) + suffix
+}
+
+// timeTick is a labeled time on the axis
+type timeTick struct {
+	time  time.Time
+	label string
+}
+
+// tickSteps are the steps time axes are labeled at
+var tickSteps = []time.Duration{
+	time.Second, 5 * time.Second, 15 * time.Second, 30 * time.Second,
+	time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour,
+	24 * time.Hour, 7 * 24 * time.Hour,
+}
+
+// timeTicks returns at most count round times between t0 and t1
+func timeTicks(t0, t1 time.Time, count int) []timeTick {
+	span := t1.Sub(t0)
+	if span <= 0 {
+		return []timeTick{{t0, t0.Format(timeFormat(time.Second))}}
+	}
+	step := tickSteps[len(tickSteps)-1]
+	for _, candidate := range tickSteps {
+		if span/candidate < time.Duration(count) {
+			step = candidate
+			break
+		}
+	}
+
+	var ticks []timeTick
+	at := t0.Truncate(step)
+	if at.Before(t0) {
+		at = at.Add(step)
+	}
+	for ; !at.After(t1); at = at.Add(step) {
+		ticks = append(ticks, timeTick{at, at.Format(timeFormat(step))})
+	}
+	return ticks
+}
+
+// timeFormat is the layout that tells apart times a step apart
+func timeFormat(step time.Duration) string {
+	switch {
+	case step >= 24*time.Hour:
+		return "Jan 2"
+	case step >= time.Minute:
+		return "15:04"
+	}
+	return "15:04:05"
+}
@@ -291,6 +291,10 @@ func RegisterButtonComponent(gocsx *core.Gocsx) *core.Component {
 		},
 	}
 
-	// Register component
-	return gocsx.RegisterComponent("button", baseClasses, variantClasses)
+	// Register component, previewed in the docs site as a button
+	component := gocsx.RegisterComponent("button", baseClasses, variantClasses)
+	component.Preview = func(classes string) string {
+		return fmt.Sprintf(`<button type="button" class="%s">Button</button>`, classes)
+	}
+	return component
 }
\ No newline at end of file
@@ -206,8 +206,15 @@ func RegisterCardComponent(gocsx *core.Gocsx) *core.Component {
 		},
 	}
 
-	// Register component
-	return gocsx.RegisterComponent("card", baseClasses, variantClasses)
+	// Register component, previewed in the docs site with a body
+	component := gocsx.RegisterComponent("card", baseClasses, variantClasses)
+	component.Preview = func(classes string) string {
+		body := CardBody(CardBodyProps{
+			Children: CardTitle(CardTitleProps{Children: "Card title"}) + CardText(CardTextProps{Children: "Some text in the card's body."}),
+		})
+		return fmt.Sprintf(`<div class="%s">%s</div>`, classes, body)
+	}
+	return component
 }
 
 // CardHeaderProps represents card header props
@@ -25,6 +25,16 @@ type Config struct {
 
 	// Prefix for all classes
 	Prefix string
+
+	// Order of the cascade layers the CSS is emitted in, from the lowest
+	// priority to the highest; DefaultLayers when empty
+	Layers []string
+
+	// Whether to emit the CSS without @layer blocks, for old browsers
+	DisableLayers bool
+
+	// Page setup and styles of the print stylesheet; see PrintCSS
+	Print PrintOptions
 }
 
 // ThemeConfig represents the theme configuration
@@ -262,6 +272,7 @@ func DefaultConfig() *Config {
 		Animations: true,
 		Variants:   make(map[string]VariantConfig),
 		Prefix:     "",
+		Layers:     append([]string(nil), DefaultLayers...),
 	}
 }
 
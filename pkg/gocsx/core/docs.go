@@ -0,0 +1,321 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DocsStylesheet is the docs site's file with the generated CSS, which the
+// pages link to
+const DocsStylesheet = "gocsx.css"
+
+// DocsToken is a value of a theme scale shown on the docs site
+type DocsToken struct {
+	Name  string
+	Value string
+}
+
+// DocsVariant is a component variant in the docs site's gallery
+type DocsVariant struct {
+	Name    string
+	Classes string
+	Preview template.HTML
+}
+
+// DocsComponent is a registered component in the docs site's gallery, the
+// first variant showing its base classes alone
+type DocsComponent struct {
+	Name     string
+	Variants []DocsVariant
+}
+
+// DocsPage is what the docs site shows of a theme and its components
+type DocsPage struct {
+	Colors      map[string][]DocsToken
+	Palettes    []string
+	Spacing     []DocsToken
+	Typography  map[string][]DocsToken
+	Radii       []DocsToken
+	Shadows     []DocsToken
+	Breakpoints []DocsToken
+	Components  []DocsComponent
+	Stylesheet  string
+	Specimen    string
+}
+
+// Docs collects the theme's scales, in increasing order, and a gallery of
+// the registered components' variants
+func (g *Gocsx) Docs() *DocsPage {
+	page := &DocsPage{
+		Colors:     make(map[string][]DocsToken),
+		Typography: make(map[string][]DocsToken),
+		Stylesheet: DocsStylesheet,
+		Specimen:   "The quick brown fox jumps over the lazy dog",
+	}
+
+	if theme := g.Config.Theme; theme != nil {
+		for palette, shades := range theme.Colors {
+			page.Palettes = append(page.Palettes, palette)
+			page.Colors[palette] = scaleTokens(shades)
+		}
+		sort.Strings(page.Palettes)
+		page.Spacing = scaleTokens(theme.Spacing)
+		for name, scale := range theme.Typography {
+			page.Typography[name] = scaleTokens(scale)
+		}
+		page.Radii = scaleTokens(theme.BorderRadius)
+		page.Shadows = scaleTokens(theme.Shadows)
+	}
+	for name, width := range g.Config.Breakpoints {
+		page.Breakpoints = append(page.Breakpoints, DocsToken{Name: name, Value: fmt.Sprintf("%dpx", width)})
+	}
+	sortTokens(page.Breakpoints)
+
+	for _, component := range g.Components() {
+		docs := DocsComponent{Name: component.Name}
+		variants := make([]string, 0, len(component.VariantClasses))
+		for variant := range component.VariantClasses {
+			variants = append(variants, variant)
+		}
+		sort.Strings(variants)
+
+		docs.Variants = append(docs.Variants, component.docsVariant("base", component.GetClasses()))
+		for _, variant := range variants {
+			docs.Variants = append(docs.Variants, component.docsVariant(variant, component.GetClasses(variant)))
+		}
+		page.Components = append(page.Components, docs)
+	}
+	return page
+}
+
+// docsVariant renders the component with the classes of a variant
+func (c *Component) docsVariant(name, classes string) DocsVariant {
+	var preview string
+	if c.Preview != nil {
+		preview = c.Preview(classes)
+	} else {
+		preview = fmt.Sprintf(`<div class="%s">%s</div>`, template.HTMLEscapeString(classes), template.HTMLEscapeString(c.Name))
+	}
+	// The previews are the component's own markup, as the app renders it
+	return DocsVariant{Name: name, Classes: classes, Preview: template.HTML(preview)}
+}
+
+// DocsFiles renders the docs site: index.html and the stylesheet, keyed by
+// their paths
+func (g *Gocsx) DocsFiles() (map[string][]byte, error) {
+	var index bytes.Buffer
+	if err := docsTemplate.Execute(&index, g.Docs()); err != nil {
+		return nil, err
+	}
+	return map[string][]byte{
+		"index.html":   index.Bytes(),
+		DocsStylesheet: []byte(g.GetCSS()),
+	}, nil
+}
+
+// GenerateDocs writes the docs site to dir, creating it if needed
+func (g *Gocsx) GenerateDocs(dir string) error {
+	files, err := g.DocsFiles()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DocsHandler serves the docs site of the Gocsx load returns, loading and
+// rendering it again for each request, so changes to the theme show on
+// reload
+func DocsHandler(load func() (*Gocsx, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+		if name == "" {
+			name = "index.html"
+		}
+
+		g, err := load()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		files, err := g.DocsFiles()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data, ok := files[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		contentType := "text/html; charset=utf-8"
+		if strings.HasSuffix(name, ".css") {
+			contentType = "text/css; charset=utf-8"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(data)
+	})
+}
+
+// scaleTokens returns the values of a scale in increasing order
+func scaleTokens(scale map[string]string) []DocsToken {
+	tokens := make([]DocsToken, 0, len(scale))
+	for name, value := range scale {
+		tokens = append(tokens, DocsToken{Name: name, Value: value})
+	}
+	sortTokens(tokens)
+	return tokens
+}
+
+// sortTokens orders tokens by their lengths, such as "1px" before
+// "0.5rem", or by their names when they are not lengths, such as colors
+// by shade
+func sortTokens(tokens []DocsToken) {
+	sort.SliceStable(tokens, func(i, j int) bool {
+		a, aok := tokenOrder(tokens[i])
+		b, bok := tokenOrder(tokens[j])
+		if aok != bok {
+			return aok
+		}
+		if aok && a != b {
+			return a < b
+		}
+		return tokens[i].Name < tokens[j].Name
+	})
+}
+
+// tokenOrder returns a token's value in pixels, or its name as a number,
+// to sort it by
+func tokenOrder(token DocsToken) (float64, bool) {
+	value := strings.TrimSpace(token.Value)
+	for _, unit := range []struct {
+		suffix string
+		scale  float64
+	}{{"rem", 16}, {"em", 16}, {"px", 1}, {"ms", 1}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			if n, err := strconv.ParseFloat(strings.TrimSuffix(value, unit.suffix), 64); err == nil {
+				return n * unit.scale, true
+			}
+		}
+	}
+	if n, err := strconv.ParseFloat(value, 64); err == nil && !strings.HasPrefix(value, "#") {
+		return n, true
+	}
+	if n, err := strconv.ParseFloat(token.Name, 64); err == nil {
+		return n, true
+	}
+	return sizeRank(token.Name)
+}
+
+// sizeRank orders names such as "sm", "md" and "2xl"
+func sizeRank(name string) (float64, bool) {
+	ranks := map[string]float64{"none": 0, "xs": 1, "sm": 2, "base": 3, "md": 4, "lg": 5, "xl": 6}
+	if rank, ok := ranks[name]; ok {
+		return rank, true
+	}
+	if n, err := strconv.Atoi(strings.TrimSuffix(name, "xl")); err == nil && strings.HasSuffix(name, "xl") {
+		return 5 + float64(n), true
+	}
+	return 0, false
+}
+
+// docsCSS marks a theme value as safe to use in a style attribute, as
+// written by the app's developers; values that could end the declaration
+// are dropped
+func docsCSS(value string) template.CSS {
+	if strings.ContainsAny(value, ";{}<>\\") {
+		return ""
+	}
+	return template.CSS(value)
+}
+
+// docsTemplate is the docs site's page
+var docsTemplate = template.Must(template.New("docs").Funcs(template.FuncMap{"css": docsCSS}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Theme</title>
+<link rel="stylesheet" href="{{.Stylesheet}}">
+<style>
+.docs { font-family: system-ui, sans-serif; margin: 0 auto; max-width: 72rem; padding: 2rem; color: #111827; }
+.docs nav a { margin-right: 1rem; }
+.docs section { margin: 3rem 0; }
+.docs .grid { display: flex; flex-wrap: wrap; gap: 1rem; }
+.docs .swatch { width: 6rem; font-size: 0.75rem; }
+.docs .swatch div { height: 3rem; border-radius: 0.375rem; border: 1px solid #e5e7eb; }
+.docs .row { display: flex; align-items: center; gap: 1rem; margin: 0.5rem 0; }
+.docs .label { width: 8rem; flex: none; font-size: 0.75rem; font-family: ui-monospace, monospace; }
+.docs .bar { height: 1rem; background: #38bdf8; }
+.docs .box { width: 6rem; height: 6rem; background: #f3f4f6; border: 1px solid #d1d5db; }
+.docs .variant { border: 1px solid #e5e7eb; border-radius: 0.5rem; padding: 1rem; min-width: 12rem; }
+.docs .variant code { display: block; margin-top: 0.75rem; font-size: 0.7rem; color: #6b7280; }
+</style>
+</head>
+<body>
+<main class="docs">
+<h1>Theme</h1>
+<nav><a href="#colors">Colors</a><a href="#spacing">Spacing</a><a href="#typography">Typography</a><a href="#radii">Radii and shadows</a><a href="#breakpoints">Breakpoints</a><a href="#components">Components</a></nav>
+
+<section id="colors">
+<h2>Colors</h2>
+{{range $palette := .Palettes}}<h3>{{$palette}}</h3>
+<div class="grid">{{range index $.Colors $palette}}<div class="swatch"><div style="background: {{css .Value}}"></div>{{.Name}}<br><code>{{.Value}}</code></div>{{end}}</div>
+{{end}}</section>
+
+<section id="spacing">
+<h2>Spacing</h2>
+{{range .Spacing}}<div class="row"><span class="label">{{.Name}} · {{.Value}}</span><div class="bar" style="width: {{css .Value}}"></div></div>
+{{end}}</section>
+
+<section id="typography">
+<h2>Typography</h2>
+{{with index .Typography "fontFamily"}}<h3>Font families</h3>
+{{range .}}<div class="row"><span class="label">{{.Name}}</span><span style="font-family: {{css .Value}}">{{$.Specimen}}</span></div>
+{{end}}{{end}}{{with index .Typography "fontSize"}}<h3>Font sizes</h3>
+{{range .}}<div class="row"><span class="label">{{.Name}} · {{.Value}}</span><span style="font-size: {{css .Value}}">{{$.Specimen}}</span></div>
+{{end}}{{end}}{{with index .Typography "fontWeight"}}<h3>Font weights</h3>
+{{range .}}<div class="row"><span class="label">{{.Name}} · {{.Value}}</span><span style="font-weight: {{css .Value}}">{{$.Specimen}}</span></div>
+{{end}}{{end}}{{with index .Typography "lineHeight"}}<h3>Line heights</h3>
+{{range .}}<div class="row"><span class="label">{{.Name}} · {{.Value}}</span><p style="line-height: {{css .Value}}; max-width: 24rem">{{$.Specimen}}. {{$.Specimen}}.</p></div>
+{{end}}{{end}}</section>
+
+<section id="radii">
+<h2>Radii and shadows</h2>
+<div class="grid">{{range .Radii}}<div><div class="box" style="border-radius: {{css .Value}}"></div><code>{{.Name}}</code></div>{{end}}</div>
+<div class="grid" style="margin-top: 2rem">{{range .Shadows}}<div><div class="box" style="box-shadow: {{css .Value}}"></div><code>{{.Name}}</code></div>{{end}}</div>
+</section>
+
+<section id="breakpoints">
+<h2>Breakpoints</h2>
+{{range .Breakpoints}}<div class="row"><span class="label">{{.Name}}</span><code>min-width: {{.Value}}</code></div>
+{{end}}</section>
+
+<section id="components">
+<h2>Components</h2>
+{{range .Components}}<h3 id="component-{{.Name}}">{{.Name}}</h3>
+<div class="grid">{{range .Variants}}<div class="variant">{{.Preview}}<code>{{.Name}}: {{.Classes}}</code></div>{{end}}</div>
+{{else}}<p>No components are registered.</p>
+{{end}}</section>
+</main>
+</body>
+</html>
+`))
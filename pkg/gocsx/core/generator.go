@@ -23,6 +23,12 @@ type Generator struct {
 
 	// Map of variants
 	Variants map[string]VariantFunction
+
+	// CSS added to each cascade layer, such as resets and element styles
+	LayerStyles map[string]string
+
+	// Layer of each generated rule
+	ruleLayers map[string]string
 }
 
 // UtilityFunction is a function that generates CSS for a utility class
@@ -51,7 +57,9 @@ func NewGenerator(config *Config) *Generator {
 		Rules:      make(map[string]string),
 		Utilities:  make(map[string]UtilityFunction),
 		Components: make(map[string]ComponentStyle),
-		Variants:   make(map[string]VariantFunction),
+		Variants:    make(map[string]VariantFunction),
+		LayerStyles: make(map[string]string),
+		ruleLayers:  make(map[string]string),
 	}
 }
 
@@ -70,7 +78,15 @@ func (g *Generator) RegisterVariant(name string, fn VariantFunction) {
 	g.Variants[name] = fn
 }
 
-// GenerateCSS generates CSS for the given classes
+// AddLayerCSS adds CSS to a cascade layer, such as a reset to LayerReset or
+// element styles to LayerBase, ahead of the rules generated for the layer
+func (g *Generator) AddLayerCSS(layer, css string) {
+	g.LayerStyles[layer] += css + "\n"
+}
+
+// GenerateCSS generates CSS for the given classes. Component rules are
+// emitted in the components layer and utilities in the utilities layer,
+// after the CSS added to each layer.
 func (g *Generator) GenerateCSS(classes []string) string {
 	// Process each class
 	for _, class := range classes {
@@ -84,15 +100,31 @@ func (g *Generator) GenerateCSS(classes []string) string {
 	}
 	sort.Strings(keys)
 
-	// Build the CSS
-	var buf bytes.Buffer
+	// Build the CSS of each layer
+	layers := make(map[string]*bytes.Buffer)
+	for layer, css := range g.LayerStyles {
+		layers[layer] = bytes.NewBufferString(css)
+	}
 	for _, key := range keys {
+		layer := g.ruleLayers[key]
+		if layer == "" {
+			layer = LayerUtilities
+		}
+		buf, ok := layers[layer]
+		if !ok {
+			buf = &bytes.Buffer{}
+			layers[layer] = buf
+		}
 		buf.WriteString(fmt.Sprintf(".%s%s {\n", g.Config.Prefix, key))
 		buf.WriteString(g.Rules[key])
 		buf.WriteString("}\n")
 	}
 
-	return buf.String()
+	sections := make(map[string]string, len(layers))
+	for layer, buf := range layers {
+		sections[layer] = buf.String()
+	}
+	return LayerCSS(sections, g.Config)
 }
 
 // processClass processes a single class and adds it to the rules
@@ -105,6 +137,7 @@ func (g *Generator) processClass(class string) {
 	// Check if this is a component
 	if component, ok := g.Components[class]; ok {
 		g.Rules[class] = component.Base
+		g.ruleLayers[class] = LayerComponents
 		return
 	}
 
@@ -153,6 +186,7 @@ func (g *Generator) processClass(class string) {
 
 	// Add the rule
 	g.Rules[class] = css
+	g.ruleLayers[class] = LayerUtilities
 }
 
 // GenerateUtilities generates all utility classes
@@ -160,7 +194,7 @@ func (g *Generator) GenerateUtilities() string {
 	var classes []string
 
 	// Generate classes for each utility
-	for utilityName, utilityFn := range g.Utilities {
+	for utilityName := range g.Utilities {
 		// Get the values for this utility
 		values := g.getUtilityValues(utilityName)
 
@@ -655,6 +689,11 @@ func (g *Generator) RegisterDefaultVariants() {
 		return fmt.Sprintf("  @media (prefers-color-scheme: dark) {\n%s  }\n", indentCSS(css))
 	})
 
+	// Print variant
+	g.RegisterVariant("print", func(css string, config *Config) string {
+		return fmt.Sprintf("  @media print {\n%s  }\n", indentCSS(css))
+	})
+
 	// Responsive variants
 	for breakpoint, width := range g.Config.Breakpoints {
 		breakpointName := breakpoint
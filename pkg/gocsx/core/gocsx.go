@@ -2,6 +2,8 @@ package core
 
 import (
 	"fmt"
+	"io/ioutil"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -24,6 +26,10 @@ type Gocsx struct {
 
 	// Platform adapters
 	platformAdapters map[string]PlatformAdapter
+
+	// Registered components, for the docs site
+	components      map[string]*Component
+	componentsMutex sync.RWMutex
 }
 
 // PlatformAdapter is an interface for platform-specific adapters
@@ -52,6 +58,7 @@ func New(options ...func(*Config)) *Gocsx {
 		Generator:        generator,
 		classCache:       make(map[string]bool),
 		platformAdapters: make(map[string]PlatformAdapter),
+		components:       make(map[string]*Component),
 	}
 }
 
@@ -79,6 +86,16 @@ func (g *Gocsx) AddClasses(classes ...string) {
 	g.regenerateCSS()
 }
 
+// AddCSS adds CSS to a cascade layer, such as a reset to LayerReset, and
+// regenerates the CSS
+func (g *Gocsx) AddCSS(layer, css string) {
+	g.cacheMutex.Lock()
+	defer g.cacheMutex.Unlock()
+
+	g.Generator.AddLayerCSS(layer, css)
+	g.regenerateCSS()
+}
+
 // HasClass checks if a class is in the cache
 func (g *Gocsx) HasClass(class string) bool {
 	g.cacheMutex.RLock()
@@ -174,8 +191,8 @@ func (c *ClassList) AddWhen(conditions map[string]bool) *ClassList {
 // Remove removes classes from the list
 func (c *ClassList) Remove(classes ...string) *ClassList {
 	for _, class := range classes {
-		for i, c := range c.classes {
-			if c == class {
+		for i, existing := range c.classes {
+			if existing == class {
 				c.classes = append(c.classes[:i], c.classes[i+1:]...)
 				break
 			}
@@ -186,8 +203,8 @@ func (c *ClassList) Remove(classes ...string) *ClassList {
 
 // Toggle toggles a class
 func (c *ClassList) Toggle(class string) *ClassList {
-	for i, c := range c.classes {
-		if c == class {
+	for i, existing := range c.classes {
+		if existing == class {
 			c.classes = append(c.classes[:i], c.classes[i+1:]...)
 			return c
 		}
@@ -222,6 +239,10 @@ type Component struct {
 	// Variant classes
 	VariantClasses map[string][]string
 
+	// Preview renders the component with classes for the docs site's
+	// gallery; nil shows a div with the classes
+	Preview func(classes string) string
+
 	// Gocsx instance
 	gocsx *Gocsx
 }
@@ -280,7 +301,26 @@ func (c *Component) GetClassList(variants ...string) *ClassList {
 
 // RegisterComponent registers a component with the generator
 func (g *Gocsx) RegisterComponent(name string, baseClasses []string, variantClasses map[string][]string) *Component {
-	return g.NewComponent(name, baseClasses, variantClasses)
+	component := g.NewComponent(name, baseClasses, variantClasses)
+
+	g.componentsMutex.Lock()
+	g.components[name] = component
+	g.componentsMutex.Unlock()
+
+	return component
+}
+
+// Components returns the registered components, by name
+func (g *Gocsx) Components() []*Component {
+	g.componentsMutex.RLock()
+	defer g.componentsMutex.RUnlock()
+
+	components := make([]*Component, 0, len(g.components))
+	for _, component := range g.components {
+		components = append(components, component)
+	}
+	sort.Slice(components, func(i, j int) bool { return components[i].Name < components[j].Name })
+	return components
 }
 
 // cx is a shorthand function for creating a class list
@@ -305,11 +345,10 @@ func (g *Gocsx) GenerateStyleTag() string {
 
 // GenerateStylesheet generates a stylesheet with the CSS
 func (g *Gocsx) GenerateStylesheet(filename string) error {
-	// Implementation depends on the platform
+	// The platform's adapter transforms the CSS, if there is one
+	css := g.GetCSS()
 	if adapter, ok := g.GetPlatformAdapter(g.Config.Platform.Target); ok {
-		css := adapter.TransformCSS(g.GetCSS())
-		// Write CSS to file
-		// This is platform-specific and would be implemented in the adapter
+		css = adapter.TransformCSS(css)
 	}
-	return nil
+	return ioutil.WriteFile(filename, []byte(css), 0644)
 }
\ No newline at end of file
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"sort"
+	"strings"
+)
+
+// Cascade layers the generated CSS is emitted in
+const (
+	// LayerReset holds resets normalizing the browser defaults
+	LayerReset = "reset"
+
+	// LayerBase holds element styles, such as typography
+	LayerBase = "base"
+
+	// LayerComponents holds the styles of registered components
+	LayerComponents = "components"
+
+	// LayerUtilities holds utility classes, which override components
+	LayerUtilities = "utilities"
+)
+
+// DefaultLayers is the default order of the cascade layers, from the lowest
+// priority to the highest
+var DefaultLayers = []string{LayerReset, LayerBase, LayerComponents, LayerUtilities}
+
+// WithLayers sets the order of the cascade layers, from the lowest priority
+// to the highest. Layers may be added, such as an "overrides" layer after
+// the utilities for the application's own styles.
+func WithLayers(layers ...string) func(*Config) {
+	return func(c *Config) {
+		c.Layers = append([]string(nil), layers...)
+	}
+}
+
+// WithoutLayers emits the CSS without @layer blocks, in the order of the
+// layers, for browsers that do not support cascade layers. The order then
+// only decides between rules of the same specificity.
+func WithoutLayers() func(*Config) {
+	return func(c *Config) {
+		c.DisableLayers = true
+	}
+}
+
+// layerOrder returns the order of the layers that have CSS: the configured
+// order, followed by any other layers by name
+func (c *Config) layerOrder(sections map[string]string) []string {
+	layers := c.Layers
+	if len(layers) == 0 {
+		layers = DefaultLayers
+	}
+
+	order := append([]string(nil), layers...)
+	known := make(map[string]bool, len(layers))
+	for _, layer := range layers {
+		known[layer] = true
+	}
+	var others []string
+	for layer := range sections {
+		if !known[layer] {
+			others = append(others, layer)
+		}
+	}
+	sort.Strings(others)
+	return append(order, others...)
+}
+
+// LayerCSS joins the CSS of each layer in the configured order. Unless
+// layers are disabled, it starts with an @layer statement declaring the
+// order and wraps each layer's CSS in an @layer block, so styles outside
+// the layers, such as the application's, override all of them whatever
+// their specificity.
+func LayerCSS(sections map[string]string, config *Config) string {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	order := config.layerOrder(sections)
+
+	var buf strings.Builder
+	if !config.DisableLayers {
+		buf.WriteString("@layer " + strings.Join(order, ", ") + ";\n")
+	}
+	for _, layer := range order {
+		css := strings.TrimSpace(sections[layer])
+		if css == "" {
+			continue
+		}
+		if config.DisableLayers {
+			buf.WriteString("/* " + layer + " */\n" + css + "\n")
+			continue
+		}
+		buf.WriteString("@layer " + layer + " {\n" + indentLayer(css) + "}\n")
+	}
+	return buf.String()
+}
+
+// indentLayer indents the lines of a layer's CSS by two spaces
+func indentLayer(css string) string {
+	var buf strings.Builder
+	for _, line := range strings.Split(css, "\n") {
+		if strings.TrimSpace(line) != "" {
+			buf.WriteString("  " + line)
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+// assertOrder fails the test unless each marker appears in css, in order
+func assertOrder(t *testing.T, css string, markers ...string) {
+	t.Helper()
+	last := -1
+	for _, marker := range markers {
+		index := strings.Index(css, marker)
+		if index < 0 {
+			t.Fatalf("expected %q in:\n%s", marker, css)
+		}
+		if index < last {
+			t.Fatalf("expected %q later in:\n%s", marker, css)
+		}
+		last = index
+	}
+}
+
+func TestLayerCSS(t *testing.T) {
+	sections := map[string]string{
+		LayerUtilities:  ".p-4 {\n  padding: 1rem;\n}\n",
+		LayerReset:      "* { margin: 0; }",
+		LayerComponents: ".btn {\n  color: red;\n}\n",
+	}
+	css := LayerCSS(sections, nil)
+
+	if !strings.HasPrefix(css, "@layer reset, base, components, utilities;\n") {
+		t.Fatalf("expected the layer order declared first, got:\n%s", css)
+	}
+	assertOrder(t, css, "@layer reset {\n  * { margin: 0; }\n}", "@layer components {\n  .btn {\n    color: red;\n  }\n}", "@layer utilities {")
+	if strings.Contains(css, "@layer base {") {
+		t.Fatalf("expected the empty base layer left out, got:\n%s", css)
+	}
+}
+
+func TestLayerCSSOrder(t *testing.T) {
+	sections := map[string]string{
+		LayerUtilities: ".p-4 { padding: 1rem; }",
+		"overrides":    ".brand { color: teal; }",
+		"vendor":       ".datepicker { z-index: 10; }",
+		"app":          ".header { height: 4rem; }",
+	}
+	config := DefaultConfig()
+	WithLayers(LayerReset, LayerUtilities, "overrides")(config)
+	css := LayerCSS(sections, config)
+
+	// Layers not configured follow the configured ones, by name
+	if !strings.HasPrefix(css, "@layer reset, utilities, overrides, app, vendor;\n") {
+		t.Fatalf("unexpected layer order:\n%s", css)
+	}
+	assertOrder(t, css, "@layer utilities {", "@layer overrides {", "@layer app {", "@layer vendor {")
+}
+
+func TestWithoutLayers(t *testing.T) {
+	config := DefaultConfig()
+	WithoutLayers()(config)
+	css := LayerCSS(map[string]string{
+		LayerUtilities:  ".p-4 { padding: 1rem; }",
+		LayerBase:       "body { font-family: sans-serif; }",
+		LayerComponents: ".btn { color: red; }",
+	}, config)
+
+	if strings.Contains(css, "@layer") {
+		t.Fatalf("expected no @layer rules, got:\n%s", css)
+	}
+	assertOrder(t, css, "/* base */\nbody", "/* components */\n.btn", "/* utilities */\n.p-4")
+}
+
+func TestWithLayersCopies(t *testing.T) {
+	layers := []string{LayerBase, LayerUtilities}
+	config := DefaultConfig()
+	WithLayers(layers...)(config)
+	layers[0] = "changed"
+	if config.Layers[0] != LayerBase {
+		t.Fatalf("expected the layers copied, got %v", config.Layers)
+	}
+}
+
+func TestGeneratorLayers(t *testing.T) {
+	generator := NewGenerator(DefaultConfig())
+	generator.RegisterComponent("btn", ComponentStyle{Base: "  color: red;\n"})
+	generator.RegisterUtility("p", func(value string, config *Config) string {
+		return "  padding: " + value + ";\n"
+	})
+	generator.AddLayerCSS(LayerReset, "* { margin: 0; }")
+	generator.AddLayerCSS(LayerComponents, ".card { border: 1px solid; }")
+
+	css := generator.GenerateCSS([]string{"p-4", "btn"})
+	assertOrder(t, css,
+		"@layer reset, base, components, utilities;",
+		"@layer reset {",
+		"@layer components {\n  .card { border: 1px solid; }\n  .btn {",
+		"@layer utilities {\n  .p-4 {",
+	)
+}
+
+func TestAddCSS(t *testing.T) {
+	gocsx := New()
+	gocsx.AddClasses("p-4")
+	gocsx.AddCSS(LayerReset, "* { box-sizing: border-box; }")
+	assertOrder(t, gocsx.GetCSS(), "@layer reset {\n  * { box-sizing: border-box; }", "@layer utilities {\n  .p-4 {")
+
+	legacy := New(WithoutLayers())
+	legacy.AddClasses("p-4")
+	legacy.AddCSS(LayerReset, "* { box-sizing: border-box; }")
+	if css := legacy.GetCSS(); strings.Contains(css, "@layer") {
+		t.Fatalf("expected no @layer rules, got:\n%s", css)
+	}
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PrintOptions configure the print stylesheet of PrintCSS
+type PrintOptions struct {
+	// PageSize is the size of the printed page, such as "A4" or "letter
+	// landscape"; the printer's by default
+	PageSize string
+
+	// PageMargin is the margin of the printed page, such as "2cm"
+	PageMargin string
+
+	// LinkURLs prints the address after the text of external links
+	LinkURLs bool
+
+	// Plain prints black text on white without shadows, to save ink;
+	// elements marked print-color-exact keep their colors
+	Plain bool
+}
+
+// WithPrint sets the print options
+func WithPrint(options PrintOptions) func(*Config) {
+	return func(c *Config) {
+		c.Print = options
+	}
+}
+
+// printDisplays are the display values of the d-print-* utilities
+var printDisplays = []string{"inline", "inline-block", "block", "flex", "inline-flex", "grid", "table", "table-row", "table-cell"}
+
+// PrintCSS returns the print utilities, in a @media print block:
+//
+//   - d-print-none hides an element when printed, and d-print-block,
+//     d-print-flex and the other d-print-* utilities set its display
+//   - print-only hides an element on screen, showing it only when printed
+//   - break-before-page, break-after-page and break-inside-avoid control
+//     where pages break
+//   - print-color-exact prints backgrounds and colors as they are shown
+//
+// along with the page size and margin and the plain styles of the config's
+// print options. The print variant, as in print:display-hidden, applies any
+// utility only when printing.
+func PrintCSS(config *Config) string {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	options := config.Print
+	class := func(name string) string {
+		return "." + config.Prefix + name
+	}
+
+	var rules strings.Builder
+	var page []string
+	if options.PageSize != "" {
+		page = append(page, "size: "+options.PageSize+";")
+	}
+	if options.PageMargin != "" {
+		page = append(page, "margin: "+options.PageMargin+";")
+	}
+	if len(page) > 0 {
+		fmt.Fprintf(&rules, "@page { %s }\n", strings.Join(page, " "))
+	}
+
+	exact := class("print-color-exact")
+	if options.Plain {
+		fmt.Fprintf(&rules, "*:not(%s):not(%s *) { background: transparent !important; color: #000 !important; box-shadow: none !important; text-shadow: none !important; }\n", exact, exact)
+	}
+	if options.LinkURLs {
+		rules.WriteString("a[href^=\"http\"]::after { content: \" (\" attr(href) \")\"; font-size: 90%; word-break: break-all; }\n")
+	}
+
+	fmt.Fprintf(&rules, "%s { display: none !important; }\n", class("d-print-none"))
+	for _, display := range printDisplays {
+		fmt.Fprintf(&rules, "%s { display: %s !important; }\n", class("d-print-"+display), display)
+	}
+	fmt.Fprintf(&rules, "%s { break-before: page; page-break-before: always; }\n", class("break-before-page"))
+	fmt.Fprintf(&rules, "%s { break-after: page; page-break-after: always; }\n", class("break-after-page"))
+	fmt.Fprintf(&rules, "%s { break-inside: avoid; page-break-inside: avoid; }\n", class("break-inside-avoid"))
+	fmt.Fprintf(&rules, "%s, %s * { -webkit-print-color-adjust: exact; print-color-adjust: exact; }\n", exact, exact)
+
+	return "@media print {\n" + indentLayer(strings.TrimSpace(rules.String())) + "}\n" +
+		fmt.Sprintf("@media screen {\n  %s { display: none !important; }\n}\n", class("print-only"))
+}
@@ -0,0 +1,458 @@
+package email
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// declaration is a property of a rule
+type declaration struct {
+	property  string
+	value     string
+	important bool
+}
+
+// rule is a style rule whose selectors can be matched against elements
+type rule struct {
+	selectors    []*selector
+	declarations []declaration
+
+	// layer ranks the rule's cascade layer, higher winning; rules outside
+	// any layer rank above every layer
+	layer int
+	order int
+}
+
+// stylesheet is a parsed stylesheet: the rules to inline, and the CSS that
+// cannot be, such as media queries and :hover rules
+type stylesheet struct {
+	rules  []*rule
+	kept   []string
+	vars   map[string]string
+	layers map[string]int
+}
+
+// unlayered ranks rules outside any cascade layer
+const unlayered = 1 << 20
+
+// parseStylesheet parses CSS, unwrapping cascade layers
+func parseStylesheet(css string) *stylesheet {
+	s := &stylesheet{vars: make(map[string]string), layers: make(map[string]int)}
+	s.parse(stripComments(css), unlayered)
+
+	// Resolve the custom properties of :root now that all are known
+	for _, r := range s.rules {
+		for i := range r.declarations {
+			r.declarations[i].value = s.resolve(r.declarations[i].value)
+		}
+	}
+	for i, kept := range s.kept {
+		s.kept[i] = s.resolve(kept)
+	}
+	return s
+}
+
+// parse parses a list of rules, such as the stylesheet or a layer's block
+func (s *stylesheet) parse(css string, layer int) {
+	for {
+		css = strings.TrimSpace(css)
+		if css == "" {
+			return
+		}
+		end := scan(css, "{;")
+		if end < 0 {
+			return
+		}
+		prelude := strings.TrimSpace(css[:end])
+		if css[end] == ';' {
+			// Statements such as @layer a, b; @import and @charset
+			if strings.HasPrefix(prelude, "@layer") {
+				for _, name := range strings.Split(strings.TrimSpace(prelude[len("@layer"):]), ",") {
+					s.layerRank(strings.TrimSpace(name))
+				}
+			} else if prelude != "" {
+				s.kept = append(s.kept, prelude+";")
+			}
+			css = css[end+1:]
+			continue
+		}
+
+		close := matching(css, end)
+		body := css[end+1 : close]
+		css = css[close+1:]
+
+		switch {
+		case strings.HasPrefix(prelude, "@layer"):
+			s.parse(body, s.layerRank(strings.TrimSpace(prelude[len("@layer"):])))
+		case strings.HasPrefix(prelude, "@"):
+			s.kept = append(s.kept, prelude+" {"+body+"}")
+		default:
+			s.rule(prelude, body, layer)
+		}
+	}
+}
+
+// layerRank returns the rank of a named layer, ranking it after those seen
+// before if it is new
+func (s *stylesheet) layerRank(name string) int {
+	rank, ok := s.layers[name]
+	if !ok {
+		rank = len(s.layers)
+		s.layers[name] = rank
+	}
+	return rank
+}
+
+// rule adds a style rule. Its declarations are inlined on the elements its
+// simple selectors match; its other selectors, and its nested rules such as
+// &:hover or @media blocks, are kept as CSS.
+func (s *stylesheet) rule(prelude, body string, layer int) {
+	declarations, nested := parseBody(body)
+	selectors := splitList(prelude)
+
+	var simple []*selector
+	var complex []string
+	for _, text := range selectors {
+		if sel := parseSelector(text); sel != nil {
+			simple = append(simple, sel)
+		} else {
+			complex = append(complex, text)
+		}
+	}
+
+	if prelude == ":root" || prelude == "html" {
+		var rest []declaration
+		for _, d := range declarations {
+			if strings.HasPrefix(d.property, "--") {
+				s.vars[d.property] = d.value
+			} else {
+				rest = append(rest, d)
+			}
+		}
+		declarations = rest
+	}
+
+	if len(declarations) > 0 {
+		if len(simple) > 0 {
+			s.rules = append(s.rules, &rule{selectors: simple, declarations: declarations, layer: layer, order: len(s.rules)})
+		}
+		if len(complex) > 0 {
+			s.kept = append(s.kept, strings.Join(complex, ", ")+" { "+formatDeclarations(declarations, true)+" }")
+		}
+	}
+
+	for _, n := range nested {
+		if strings.HasPrefix(n.prelude, "@") {
+			inner := formatDeclarations(n.declarations, true)
+			s.kept = append(s.kept, n.prelude+" { "+strings.Join(selectors, ", ")+" { "+inner+" } }")
+			continue
+		}
+		var expanded []string
+		for _, parent := range selectors {
+			for _, child := range splitList(n.prelude) {
+				if strings.Contains(child, "&") {
+					expanded = append(expanded, strings.ReplaceAll(child, "&", parent))
+				} else {
+					expanded = append(expanded, parent+" "+child)
+				}
+			}
+		}
+		s.kept = append(s.kept, strings.Join(expanded, ", ")+" { "+formatDeclarations(n.declarations, true)+" }")
+	}
+}
+
+// nestedRule is a rule nested in a style rule, such as &:hover { ... }
+type nestedRule struct {
+	prelude      string
+	declarations []declaration
+}
+
+// parseBody parses the declarations of a rule and the rules nested in it
+func parseBody(body string) ([]declaration, []nestedRule) {
+	var declarations []declaration
+	var nested []nestedRule
+	for {
+		body = strings.TrimSpace(body)
+		if body == "" {
+			break
+		}
+		end := scan(body, "{;")
+		if end < 0 {
+			declarations = appendDeclaration(declarations, body)
+			break
+		}
+		if body[end] == ';' {
+			declarations = appendDeclaration(declarations, body[:end])
+			body = body[end+1:]
+			continue
+		}
+		close := matching(body, end)
+		inner, _ := parseBody(body[end+1 : close])
+		nested = append(nested, nestedRule{prelude: strings.TrimSpace(body[:end]), declarations: inner})
+		body = body[close+1:]
+	}
+	return declarations, nested
+}
+
+// appendDeclaration parses a declaration such as "color: red !important"
+func appendDeclaration(declarations []declaration, text string) []declaration {
+	colon := strings.Index(text, ":")
+	if colon < 0 {
+		return declarations
+	}
+	d := declaration{property: strings.ToLower(strings.TrimSpace(text[:colon])), value: strings.TrimSpace(text[colon+1:])}
+	if strings.HasPrefix(d.property, "--") {
+		d.property = strings.TrimSpace(text[:colon])
+	}
+	if i := strings.LastIndex(d.value, "!"); i >= 0 && strings.EqualFold(strings.TrimSpace(d.value[i+1:]), "important") {
+		d.value = strings.TrimSpace(d.value[:i])
+		d.important = true
+	}
+	if d.property == "" || d.value == "" {
+		return declarations
+	}
+	return append(declarations, d)
+}
+
+// formatDeclarations writes declarations as CSS, with their !important if
+// important is set
+func formatDeclarations(declarations []declaration, important bool) string {
+	parts := make([]string, len(declarations))
+	for i, d := range declarations {
+		parts[i] = d.property + ": " + d.value
+		if important && d.important {
+			parts[i] += " !important"
+		}
+	}
+	return strings.Join(parts, "; ") + ";"
+}
+
+// varPattern matches a use of a custom property, with its fallback
+var varPattern = regexp.MustCompile(`var\(\s*(--[\w-]+)\s*(?:,\s*([^()]*(?:\([^()]*\))?[^()]*))?\)`)
+
+// resolve replaces the custom properties of :root in a value by their
+// values, or else their fallbacks, as email clients do not support them
+func (s *stylesheet) resolve(value string) string {
+	for i := 0; i < 8 && strings.Contains(value, "var("); i++ {
+		value = varPattern.ReplaceAllStringFunc(value, func(use string) string {
+			match := varPattern.FindStringSubmatch(use)
+			if v, ok := s.vars[match[1]]; ok {
+				return v
+			}
+			return strings.TrimSpace(match[2])
+		})
+	}
+	return value
+}
+
+// remPattern matches lengths in rem
+var remPattern = regexp.MustCompile(`(-?\d*\.?\d+)rem\b`)
+
+// remToPixels converts the lengths in rem of a value to pixels, as some
+// email clients do not support rem
+func remToPixels(value string, base float64) string {
+	return remPattern.ReplaceAllStringFunc(value, func(length string) string {
+		n, err := strconv.ParseFloat(strings.TrimSuffix(length, "rem"), 64)
+		if err != nil {
+			return length
+		}
+		return strconv.FormatFloat(n*base, 'f', -1, 64) + "px"
+	})
+}
+
+// scan returns the index of the first of chars in css outside strings and
+// parentheses, or -1
+func scan(css, chars string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(css); i++ {
+		c := css[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+		case depth == 0 && strings.IndexByte(chars, c) >= 0:
+			return i
+		}
+	}
+	return -1
+}
+
+// matching returns the index of the brace closing the one at open, or the
+// end of css if it is not closed
+func matching(css string, open int) int {
+	depth := 0
+	for i := open; i < len(css); {
+		next := scan(css[i:], "{}")
+		if next < 0 {
+			break
+		}
+		i += next
+		if css[i] == '{' {
+			depth++
+		} else if depth--; depth == 0 {
+			return i
+		}
+		i++
+	}
+	return len(css)
+}
+
+// splitList splits a comma-separated list, such as a selector list
+func splitList(text string) []string {
+	var parts []string
+	for {
+		end := scan(text, ",")
+		if end < 0 {
+			break
+		}
+		if part := strings.TrimSpace(text[:end]); part != "" {
+			parts = append(parts, part)
+		}
+		text = text[end+1:]
+	}
+	if part := strings.TrimSpace(text); part != "" {
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// stripComments removes the comments of CSS
+func stripComments(css string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(css, "/*")
+		if start < 0 {
+			b.WriteString(css)
+			return b.String()
+		}
+		b.WriteString(css[:start])
+		end := strings.Index(css[start+2:], "*/")
+		if end < 0 {
+			return b.String()
+		}
+		css = css[start+2+end+2:]
+	}
+}
+
+// selector is a selector of tags, IDs and classes, combined by descendant
+// and child combinators, such as "table.receipt > tr td"
+type selector struct {
+	// compounds are the parts of the selector, the subject last
+	compounds []compound
+
+	// specificity counts the IDs, classes and tags, weighted
+	specificity int
+}
+
+// compound is a part of a selector matching one element
+type compound struct {
+	tag     string
+	id      string
+	classes []string
+
+	// child is set if the element must be the child of the previous
+	// compound's, rather than a descendant
+	child bool
+}
+
+// parseSelector parses a selector that can be inlined, or returns nil for
+// those that cannot, such as with pseudo-classes, attributes or sibling
+// combinators
+func parseSelector(text string) *selector {
+	if strings.ContainsAny(text, ":[+~()&") {
+		return nil
+	}
+	text = strings.ReplaceAll(text, ">", " > ")
+
+	sel := &selector{}
+	child := false
+	for _, part := range strings.Fields(text) {
+		if part == ">" {
+			if child || len(sel.compounds) == 0 {
+				return nil
+			}
+			child = true
+			continue
+		}
+
+		c := compound{child: child}
+		child = false
+		for part != "" {
+			end := strings.IndexAny(part[1:], ".#") + 1
+			if end == 0 {
+				end = len(part)
+			}
+			token := part[:end]
+			part = part[end:]
+			switch {
+			case token[0] == '.' && len(token) > 1:
+				c.classes = append(c.classes, token[1:])
+				sel.specificity += 1 << 8
+			case token[0] == '#' && len(token) > 1:
+				c.id = token[1:]
+				sel.specificity += 1 << 16
+			case token == "*":
+			case token[0] != '.' && token[0] != '#':
+				c.tag = strings.ToLower(token)
+				sel.specificity++
+			default:
+				return nil
+			}
+		}
+		sel.compounds = append(sel.compounds, c)
+	}
+	if child || len(sel.compounds) == 0 {
+		return nil
+	}
+	return sel
+}
+
+// matches reports whether the selector matches the last of a stack of open
+// elements
+func (s *selector) matches(stack []*element) bool {
+	return s.match(len(s.compounds)-1, stack, len(stack)-1)
+}
+
+func (s *selector) match(c int, stack []*element, e int) bool {
+	if !s.compounds[c].matches(stack[e]) {
+		return false
+	}
+	if c == 0 {
+		return true
+	}
+	if s.compounds[c].child {
+		return e > 0 && s.match(c-1, stack, e-1)
+	}
+	for ancestor := e - 1; ancestor >= 0; ancestor-- {
+		if s.match(c-1, stack, ancestor) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c compound) matches(e *element) bool {
+	if c.tag != "" && c.tag != e.tag {
+		return false
+	}
+	if c.id != "" && c.id != e.id {
+		return false
+	}
+	for _, class := range c.classes {
+		if !e.classes[class] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,292 @@
+// Package email renders Gocsx markup as email-safe HTML. Email clients
+// drop stylesheets, cascade layers and custom properties, so the rules
+// matching each element are moved into its style attribute, with the
+// theme's custom properties resolved and rem lengths converted to pixels.
+// Transactional emails thereby share the design tokens of the site:
+//
+//	html, err := email.Render(g, receipt.Render(order))
+//
+// Rules that cannot be inlined, such as media queries and :hover rules, are
+// kept in a <style> element for the clients that support one.
+package email
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/davidjeba/goscript/pkg/gocsx/core"
+)
+
+// Inliner inlines CSS into HTML
+type Inliner struct {
+	// RemBase is the size of a rem in pixels; 16 by default
+	RemBase float64
+
+	// KeepInteractive keeps properties that do nothing in an email, such as
+	// transitions, animations and cursors, which are dropped by default
+	KeepInteractive bool
+}
+
+// interactiveProperties are the properties dropped unless KeepInteractive
+// is set, by prefix
+var interactiveProperties = []string{"transition", "animation", "cursor", "pointer-events", "will-change", "user-select", "-webkit-user-select"}
+
+// Inline inlines css into markup with the default options
+func Inline(markup, css string) (string, error) {
+	return (&Inliner{}).Inline(markup, css)
+}
+
+// Render inlines the CSS g generates for the classes of markup, such as a
+// component's render, followed by any other css, such as the web adapter's
+// GenerateFullCSS for the classes it styles
+func Render(g *core.Gocsx, markup string, css ...string) (string, error) {
+	g.AddClasses(Classes(markup)...)
+	return Inline(markup, g.GetCSS()+"\n"+strings.Join(css, "\n"))
+}
+
+// Inline moves the rules of css, and of the <style> elements of markup,
+// into the style attributes of the elements they match. The element's own
+// style attribute wins over the rules; among rules, !important ones win,
+// then those of later cascade layers, then the more specific and the later.
+func (in *Inliner) Inline(markup, css string) (string, error) {
+	var styles []string
+	markup, styles = extractStyles(markup)
+	sheet := parseStylesheet(css + "\n" + strings.Join(styles, "\n"))
+
+	base := in.RemBase
+	if base <= 0 {
+		base = 16
+	}
+
+	var out strings.Builder
+	var stack []*element
+	rest := markup
+	for {
+		start := strings.IndexByte(rest, '<')
+		if start < 0 {
+			out.WriteString(rest)
+			break
+		}
+		out.WriteString(rest[:start])
+		rest = rest[start:]
+
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest, "-->")
+			if end < 0 {
+				return "", fmt.Errorf("email: unclosed comment")
+			}
+			out.WriteString(rest[:end+3])
+			rest = rest[end+3:]
+			continue
+		case strings.HasPrefix(rest, "</"):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return "", fmt.Errorf("email: unclosed tag %q", rest)
+			}
+			tag := strings.ToLower(strings.TrimSpace(rest[2:end]))
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].tag == tag {
+					stack = stack[:i]
+					break
+				}
+			}
+			out.WriteString(rest[:end+1])
+			rest = rest[end+1:]
+			continue
+		case len(rest) < 2 || !isLetter(rest[1]):
+			// A doctype, or a < in text
+			end := 1
+			if strings.HasPrefix(rest, "<!") {
+				end = strings.IndexByte(rest, '>') + 1
+			}
+			out.WriteString(rest[:end])
+			rest = rest[end:]
+			continue
+		}
+
+		tag, err := parseTag(rest)
+		if err != nil {
+			return "", err
+		}
+		rest = rest[tag.length:]
+		stack = append(stack, tag.element)
+		out.WriteString(tag.rewrite(in.style(sheet, stack, tag.style, base)))
+
+		if tag.selfClosing || voidElements[tag.element.tag] {
+			stack = stack[:len(stack)-1]
+		} else if tag.element.tag == "script" || tag.element.tag == "textarea" {
+			// Copy raw text up to the end tag as it is
+			end := strings.Index(strings.ToLower(rest), "</"+tag.element.tag)
+			if end < 0 {
+				end = len(rest)
+			}
+			out.WriteString(rest[:end])
+			rest = rest[end:]
+		}
+	}
+
+	result := out.String()
+	if len(sheet.kept) > 0 {
+		kept := "<style>\n" + strings.Join(sheet.kept, "\n") + "\n</style>"
+		if i := strings.Index(strings.ToLower(result), "</head>"); i >= 0 {
+			result = result[:i] + kept + "\n" + result[i:]
+		} else {
+			result = kept + "\n" + result
+		}
+	}
+	return result, nil
+}
+
+// matched is a declaration of a rule matching an element
+type matched struct {
+	declaration
+	layer       int
+	specificity int
+	order       int
+}
+
+// style returns the style attribute of the last element of stack: the
+// declarations of the rules matching it in cascade order, then its own
+func (in *Inliner) style(sheet *stylesheet, stack []*element, own string, base float64) string {
+	var found []matched
+	for _, r := range sheet.rules {
+		specificity := -1
+		for _, sel := range r.selectors {
+			if sel.specificity > specificity && sel.matches(stack) {
+				specificity = sel.specificity
+			}
+		}
+		if specificity < 0 {
+			continue
+		}
+		for _, d := range r.declarations {
+			found = append(found, matched{declaration: d, layer: r.layer, specificity: specificity, order: r.order})
+		}
+	}
+	if len(found) == 0 {
+		return own
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if a.important != b.important {
+			return !a.important
+		}
+		if a.layer != b.layer {
+			// Earlier layers win among !important declarations
+			return (a.layer < b.layer) != a.important
+		}
+		if a.specificity != b.specificity {
+			return a.specificity < b.specificity
+		}
+		return a.order < b.order
+	})
+
+	declarations := make([]declaration, 0, len(found))
+	for _, m := range found {
+		declarations = setDeclaration(declarations, m.declaration)
+	}
+	ownDeclarations, _ := parseBody(own)
+	for _, d := range ownDeclarations {
+		if !d.important {
+			if i := indexOf(declarations, d.property); i >= 0 && declarations[i].important {
+				continue
+			}
+		}
+		declarations = setDeclaration(declarations, d)
+	}
+
+	var parts []string
+	for _, d := range declarations {
+		if strings.HasPrefix(d.property, "--") || (!in.KeepInteractive && interactive(d.property)) {
+			continue
+		}
+		value := remToPixels(sheet.resolve(d.value), base)
+		parts = append(parts, d.property+": "+strings.ReplaceAll(value, `"`, "'"))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.Join(parts, "; ") + ";"
+}
+
+// setDeclaration sets a property, moving it after those set before
+func setDeclaration(declarations []declaration, d declaration) []declaration {
+	if i := indexOf(declarations, d.property); i >= 0 {
+		declarations = append(declarations[:i], declarations[i+1:]...)
+	}
+	return append(declarations, d)
+}
+
+func indexOf(declarations []declaration, property string) int {
+	for i, d := range declarations {
+		if d.property == property {
+			return i
+		}
+	}
+	return -1
+}
+
+// interactive reports whether a property does nothing in an email
+func interactive(property string) bool {
+	for _, prefix := range interactiveProperties {
+		if strings.HasPrefix(property, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Classes returns the classes of the elements of markup, in the order they
+// first appear, such as to generate their CSS
+func Classes(markup string) []string {
+	var classes []string
+	seen := make(map[string]bool)
+	for rest := markup; ; {
+		start := strings.IndexByte(rest, '<')
+		if start < 0 || start+1 >= len(rest) {
+			break
+		}
+		rest = rest[start:]
+		if !isLetter(rest[1]) {
+			rest = rest[1:]
+			continue
+		}
+		tag, err := parseTag(rest)
+		if err != nil {
+			break
+		}
+		rest = rest[tag.length:]
+		for _, class := range tag.element.order {
+			if !seen[class] {
+				seen[class] = true
+				classes = append(classes, class)
+			}
+		}
+	}
+	return classes
+}
+
+// extractStyles removes the <style> elements of markup, returning their CSS
+func extractStyles(markup string) (string, []string) {
+	var styles []string
+	var b strings.Builder
+	for {
+		lower := strings.ToLower(markup)
+		start := strings.Index(lower, "<style")
+		if start < 0 {
+			b.WriteString(markup)
+			return b.String(), styles
+		}
+		open := strings.IndexByte(lower[start:], '>')
+		end := strings.Index(lower[start:], "</style>")
+		if open < 0 || end < 0 || end < open {
+			b.WriteString(markup)
+			return b.String(), styles
+		}
+		b.WriteString(markup[:start])
+		styles = append(styles, markup[start+open+1:start+end])
+		markup = markup[start+end+len("</style>"):]
+	}
+}
@@ -0,0 +1,80 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/davidjeba/goscript/pkg/gocsx/core"
+)
+
+func TestInlineCascade(t *testing.T) {
+	css := `
+:root { --brand: #0ea5e9; }
+@layer components, utilities;
+@layer utilities {
+  .p-4 { padding: 1rem; }
+  .text-brand { color: var(--brand); }
+}
+@layer components {
+  .btn { display: inline-block; padding: 0.5rem 1rem; color: #fff; transition: color 0.2s; }
+  .btn:hover { color: #eee; }
+}
+table.receipt > tr td { border-bottom: 1px solid var(--line, #ddd); }
+#total { font-weight: bold !important; }
+@media (max-width: 600px) { .btn { display: block; } }
+`
+	markup := `<html><head><title>Receipt</title></head><body>
+<a class="btn p-4 text-brand" href="https://example.com" style="color: red">Pay</a>
+<table class="receipt"><tr><td id="total" style="font-weight: normal">$10</td></tr></table>
+<img src="logo.png" class="p-4"/>
+</body></html>`
+
+	html, err := Inline(markup, css)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		// Utilities win over components, and the element's own style over both
+		`<a class="btn p-4 text-brand" href="https://example.com" style="display: inline-block; padding: 16px; color: red;">`,
+		`<td id="total" style="border-bottom: 1px solid #ddd; font-weight: bold;">`,
+		`<img src="logo.png" class="p-4" style="padding: 16px;"/>`,
+		"<style>\n.btn:hover { color: #eee; }\n@media (max-width: 600px) { .btn { display: block; } }\n</style>\n</head>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("expected %q in\n%s", want, html)
+		}
+	}
+	if strings.Contains(html, "transition") || strings.Contains(html, "var(") || strings.Contains(html, "@layer") {
+		t.Errorf("expected no transitions, custom properties or layers in\n%s", html)
+	}
+}
+
+func TestInlineStyleElementsAndNesting(t *testing.T) {
+	markup := `<style>.card { border-radius: 0.5rem; &:hover { opacity: 0.9; } @media print { display: none; } }</style>` +
+		`<div class="card" title="a &quot;quoted&quot; title"><p>Hi</p></div>`
+
+	html, err := (&Inliner{RemBase: 10}).Inline(markup, `.card p { font-family: "Segoe UI", sans-serif; }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "<style>\n.card:hover { opacity: 0.9; }\n@media print { .card { display: none; } }\n</style>\n" +
+		`<div class="card" title="a &quot;quoted&quot; title" style="border-radius: 5px;"><p style="font-family: 'Segoe UI', sans-serif;">Hi</p></div>`
+	if html != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, html)
+	}
+}
+
+func TestRenderUsesTheTheme(t *testing.T) {
+	g := core.New()
+	html, err := Render(g, `<p class="bg-primary-500 p-2">Thanks for your order</p>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `<p class="bg-primary-500 p-2" style="background-color: #0ea5e9; padding: 8px;">Thanks for your order</p>`
+	if !strings.Contains(html, want) {
+		t.Errorf("expected %q in\n%s", want, html)
+	}
+	if got := Classes(`<div class="a b"><span class="b c">x</span></div>`); strings.Join(got, " ") != "a b c" {
+		t.Errorf("expected the classes a b c, got %v", got)
+	}
+}
@@ -0,0 +1,152 @@
+package email
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// element is an open element, as selectors match it
+type element struct {
+	tag     string
+	id      string
+	classes map[string]bool
+
+	// order is the element's classes in the order of its class attribute
+	order []string
+}
+
+// startTag is a parsed start tag
+type startTag struct {
+	element     *element
+	source      string
+	length      int
+	selfClosing bool
+
+	// style is the tag's own style attribute, and styleStart and styleEnd
+	// the span of its attribute in source, or -1 if it has none
+	style      string
+	styleStart int
+	styleEnd   int
+}
+
+// voidElements have no end tag
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// parseTag parses the start tag at the beginning of markup
+func parseTag(markup string) (*startTag, error) {
+	i := 1
+	for i < len(markup) && !isSpace(markup[i]) && markup[i] != '>' && markup[i] != '/' {
+		i++
+	}
+	e := &element{tag: strings.ToLower(markup[1:i]), classes: make(map[string]bool)}
+	tag := &startTag{element: e, styleStart: -1, styleEnd: -1}
+
+	for {
+		for i < len(markup) && isSpace(markup[i]) {
+			i++
+		}
+		if i >= len(markup) {
+			return nil, fmt.Errorf("email: unclosed tag <%s", e.tag)
+		}
+		if markup[i] == '>' {
+			i++
+			break
+		}
+		if strings.HasPrefix(markup[i:], "/>") {
+			tag.selfClosing = true
+			i += 2
+			break
+		}
+		if markup[i] == '/' {
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(markup) && !isSpace(markup[i]) && markup[i] != '=' && markup[i] != '>' && !strings.HasPrefix(markup[i:], "/>") {
+			i++
+		}
+		name := strings.ToLower(markup[start:i])
+		value := ""
+		for i < len(markup) && isSpace(markup[i]) {
+			i++
+		}
+		if i < len(markup) && markup[i] == '=' {
+			i++
+			for i < len(markup) && isSpace(markup[i]) {
+				i++
+			}
+			if i < len(markup) && (markup[i] == '"' || markup[i] == '\'') {
+				quote := markup[i]
+				end := strings.IndexByte(markup[i+1:], quote)
+				if end < 0 {
+					return nil, fmt.Errorf("email: unclosed attribute %s of <%s", name, e.tag)
+				}
+				value = markup[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				valueStart := i
+				for i < len(markup) && !isSpace(markup[i]) && markup[i] != '>' {
+					i++
+				}
+				value = markup[valueStart:i]
+			}
+		}
+
+		value = html.UnescapeString(value)
+		switch name {
+		case "id":
+			e.id = value
+		case "class":
+			for _, class := range strings.Fields(value) {
+				if !e.classes[class] {
+					e.classes[class] = true
+					e.order = append(e.order, class)
+				}
+			}
+		case "style":
+			tag.style = value
+			tag.styleStart, tag.styleEnd = start, i
+		}
+	}
+
+	tag.source = markup[:i]
+	tag.length = i
+	return tag, nil
+}
+
+// attributeEscaper escapes a double-quoted attribute value
+var attributeEscaper = strings.NewReplacer("&", "&amp;", `"`, "&quot;")
+
+// rewrite returns the tag with a style attribute, replacing its own
+func (t *startTag) rewrite(style string) string {
+	if style == t.style {
+		return t.source
+	}
+	attribute := ""
+	if style != "" {
+		attribute = `style="` + attributeEscaper.Replace(style) + `"`
+	}
+	if t.styleStart >= 0 {
+		return t.source[:t.styleStart] + attribute + t.source[t.styleEnd:]
+	}
+
+	end := len(t.source) - 1
+	if t.selfClosing {
+		end = len(t.source) - 2
+	}
+	head := strings.TrimRight(t.source[:end], " \t\n\r\f")
+	return head + " " + attribute + t.source[end:]
+}
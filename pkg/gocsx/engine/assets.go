@@ -0,0 +1,590 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AssetType represents the kind of an asset
+type AssetType string
+
+const (
+	// AssetImage is a texture image (PNG, JPEG, GIF or KTX2)
+	AssetImage AssetType = "image"
+
+	// AssetModel is a 3D model (OBJ)
+	AssetModel AssetType = "model"
+
+	// AssetAudio is a sound file
+	AssetAudio AssetType = "audio"
+
+	// AssetShader is WGSL shader source
+	AssetShader AssetType = "shader"
+)
+
+// AssetState represents the load state of an asset
+type AssetState string
+
+const (
+	// AssetPending has not been requested
+	AssetPending AssetState = "pending"
+
+	// AssetLoading is being loaded
+	AssetLoading AssetState = "loading"
+
+	// AssetLoaded is ready to use
+	AssetLoaded AssetState = "loaded"
+
+	// AssetFailed could not be loaded
+	AssetFailed AssetState = "failed"
+)
+
+// AssetEntry declares an asset in a manifest
+type AssetEntry struct {
+	Name    string    `json:"name"`
+	Type    AssetType `json:"type"`
+	Path    string    `json:"path"`
+	Preload bool      `json:"preload,omitempty"`
+}
+
+// AssetManifest lists the assets of a game or scene
+type AssetManifest struct {
+	// BaseURL is the URL prefix assets are served from
+	BaseURL string `json:"baseURL"`
+
+	// Assets
+	Assets []AssetEntry `json:"assets"`
+}
+
+// LoadAssetManifest reads a JSON asset manifest
+func LoadAssetManifest(filename string) (*AssetManifest, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &AssetManifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("invalid asset manifest %s: %v", filename, err)
+	}
+
+	if manifest.BaseURL == "" {
+		manifest.BaseURL = "/assets/"
+	}
+
+	seen := make(map[string]bool)
+	for _, entry := range manifest.Assets {
+		if entry.Name == "" || entry.Path == "" {
+			return nil, fmt.Errorf("asset manifest %s has an entry without a name or path", filename)
+		}
+		if seen[entry.Name] {
+			return nil, fmt.Errorf("asset %s is declared twice", entry.Name)
+		}
+		seen[entry.Name] = true
+
+		switch entry.Type {
+		case AssetImage, AssetModel, AssetAudio, AssetShader:
+		default:
+			return nil, fmt.Errorf("asset %s has unknown type %q", entry.Name, entry.Type)
+		}
+	}
+
+	return manifest, nil
+}
+
+// Asset is a loaded asset
+type Asset struct {
+	AssetEntry
+
+	// URL with a content hash for cache busting
+	URL string
+
+	// Hash of the file contents
+	Hash string
+
+	// Raw file contents
+	Data []byte
+
+	// Decoded value: *GPUTexture, *Mesh, *Sound or the shader source string
+	Value interface{}
+
+	// State
+	State AssetState
+
+	// Error when State is AssetFailed
+	Err error
+
+	// Version is incremented every time the asset is reloaded
+	Version int
+
+	// Modification time of the file when it was loaded
+	modTime time.Time
+}
+
+// AssetLoader decodes an asset's data into its value
+type AssetLoader func(asset *Asset) (interface{}, error)
+
+// AssetManager loads the assets declared in a manifest
+type AssetManager struct {
+	// Root directory asset paths are relative to
+	Root string
+
+	// Manifest
+	Manifest *AssetManifest
+
+	// Textures used to load images (optional)
+	Textures *TextureManager
+
+	// Audio engine used to load sounds (optional)
+	Audio *AudioEngine
+
+	// Assets by name
+	assets map[string]*Asset
+
+	// Loaders by type
+	loaders map[AssetType]AssetLoader
+
+	// Reload callbacks
+	reloadCallbacks []func(asset *Asset)
+
+	// Stops the hot reload watcher
+	stopWatch chan struct{}
+
+	// Mutex for thread safety
+	mutex sync.RWMutex
+}
+
+// NewAssetManager creates an asset manager for a manifest
+func NewAssetManager(root string, manifest *AssetManifest) *AssetManager {
+	if manifest == nil {
+		manifest = &AssetManifest{BaseURL: "/assets/"}
+	}
+
+	manager := &AssetManager{
+		Root:     root,
+		Manifest: manifest,
+		assets:   make(map[string]*Asset),
+		loaders:  make(map[AssetType]AssetLoader),
+	}
+
+	for _, entry := range manifest.Assets {
+		manager.assets[entry.Name] = &Asset{AssetEntry: entry, State: AssetPending}
+	}
+
+	manager.loaders[AssetImage] = manager.loadImage
+	manager.loaders[AssetModel] = manager.loadModel
+	manager.loaders[AssetAudio] = manager.loadAudio
+	manager.loaders[AssetShader] = func(asset *Asset) (interface{}, error) {
+		return string(asset.Data), nil
+	}
+
+	return manager
+}
+
+// RegisterLoader replaces the loader for an asset type
+func (m *AssetManager) RegisterLoader(assetType AssetType, loader AssetLoader) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.loaders[assetType] = loader
+}
+
+// Get gets an asset by name
+func (m *AssetManager) Get(name string) *Asset {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.assets[name]
+}
+
+// URL returns the hashed URL of an asset, hashing the file if it has not been loaded
+func (m *AssetManager) URL(name string) (string, error) {
+	m.mutex.RLock()
+	asset, ok := m.assets[name]
+	m.mutex.RUnlock()
+
+	if !ok {
+		return "", fmt.Errorf("asset %s not found", name)
+	}
+
+	if asset.URL != "" {
+		return asset.URL, nil
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(m.Root, asset.Path))
+	if err != nil {
+		return "", err
+	}
+
+	return m.hashedURL(asset.Path, hashAsset(data)), nil
+}
+
+// hashedURL inserts a content hash before the file extension
+func (m *AssetManager) hashedURL(assetPath, hash string) string {
+	assetPath = filepath.ToSlash(assetPath)
+	ext := path.Ext(assetPath)
+	return strings.TrimSuffix(m.Manifest.BaseURL, "/") + "/" + strings.TrimSuffix(assetPath, ext) + "." + hash + ext
+}
+
+// hashAsset returns a short content hash
+func hashAsset(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// Load loads an asset synchronously
+func (m *AssetManager) Load(name string) (*Asset, error) {
+	m.mutex.Lock()
+	asset, ok := m.assets[name]
+	if !ok {
+		m.mutex.Unlock()
+		return nil, fmt.Errorf("asset %s not found", name)
+	}
+	if asset.State == AssetLoaded {
+		m.mutex.Unlock()
+		return asset, nil
+	}
+	asset.State = AssetLoading
+	loader := m.loaders[asset.Type]
+	m.mutex.Unlock()
+
+	return asset, m.loadAsset(asset, loader)
+}
+
+// loadAsset reads, hashes and decodes an asset
+func (m *AssetManager) loadAsset(asset *Asset, loader AssetLoader) error {
+	filename := filepath.Join(m.Root, asset.Path)
+
+	info, err := os.Stat(filename)
+	var data []byte
+	if err == nil {
+		data, err = ioutil.ReadFile(filename)
+	}
+
+	var value interface{}
+	if err == nil && loader != nil {
+		// Loaders see the new data before it is published
+		pending := *asset
+		pending.Data = data
+		pending.Hash = hashAsset(data)
+		pending.URL = m.hashedURL(asset.Path, pending.Hash)
+		value, err = loader(&pending)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if err != nil {
+		asset.Err = fmt.Errorf("failed to load asset %s: %v", asset.Name, err)
+
+		// A failed reload keeps the previous version until the file is fixed
+		if asset.Version > 0 && info != nil {
+			asset.State = AssetLoaded
+			asset.modTime = info.ModTime()
+		} else {
+			asset.State = AssetFailed
+		}
+		return asset.Err
+	}
+
+	asset.Data = data
+	asset.Hash = hashAsset(data)
+	asset.URL = m.hashedURL(asset.Path, asset.Hash)
+	asset.Value = value
+	asset.State = AssetLoaded
+	asset.Err = nil
+	asset.Version++
+	asset.modTime = info.ModTime()
+
+	return nil
+}
+
+// AssetLoad tracks the progress of an asynchronous load
+type AssetLoad struct {
+	// Total number of assets
+	Total int
+
+	// Loaded and failed counters
+	loaded int
+	failed int
+
+	// Errors by asset name
+	errors map[string]error
+
+	// Progress callback
+	onProgress func(loaded, total int)
+
+	// Closed when all assets have finished
+	done chan struct{}
+
+	// Mutex for thread safety
+	mutex sync.Mutex
+}
+
+// Progress returns the fraction of assets that have finished loading
+func (l *AssetLoad) Progress() float64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.Total == 0 {
+		return 1
+	}
+	return float64(l.loaded+l.failed) / float64(l.Total)
+}
+
+// Done returns a channel closed when the load has finished
+func (l *AssetLoad) Done() <-chan struct{} {
+	return l.done
+}
+
+// Wait blocks until the load has finished and returns the first error, if any
+func (l *AssetLoad) Wait() error {
+	<-l.done
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, err := range l.errors {
+		return err
+	}
+	return nil
+}
+
+// Errors returns the errors by asset name
+func (l *AssetLoad) Errors() map[string]error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	errs := make(map[string]error, len(l.errors))
+	for name, err := range l.errors {
+		errs[name] = err
+	}
+	return errs
+}
+
+// finish records a finished asset
+func (l *AssetLoad) finish(name string, err error) {
+	l.mutex.Lock()
+	if err != nil {
+		l.failed++
+		l.errors[name] = err
+	} else {
+		l.loaded++
+	}
+	finished := l.loaded + l.failed
+	callback := l.onProgress
+	l.mutex.Unlock()
+
+	if callback != nil {
+		callback(finished, l.Total)
+	}
+	if finished == l.Total {
+		close(l.done)
+	}
+}
+
+// LoadAsync loads assets in the background. onProgress may be nil.
+func (m *AssetManager) LoadAsync(names []string, onProgress func(loaded, total int)) *AssetLoad {
+	load := &AssetLoad{
+		Total:      len(names),
+		errors:     make(map[string]error),
+		onProgress: onProgress,
+		done:       make(chan struct{}),
+	}
+
+	if len(names) == 0 {
+		close(load.done)
+		return load
+	}
+
+	for _, name := range names {
+		go func(name string) {
+			_, err := m.Load(name)
+			load.finish(name, err)
+		}(name)
+	}
+
+	return load
+}
+
+// Preload loads every asset marked preload in the manifest
+func (m *AssetManager) Preload(onProgress func(loaded, total int)) *AssetLoad {
+	var names []string
+	for _, entry := range m.Manifest.Assets {
+		if entry.Preload {
+			names = append(names, entry.Name)
+		}
+	}
+
+	return m.LoadAsync(names, onProgress)
+}
+
+// OnReload registers a callback for assets reloaded by the watcher
+func (m *AssetManager) OnReload(callback func(asset *Asset)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.reloadCallbacks = append(m.reloadCallbacks, callback)
+}
+
+// Watch polls loaded assets for changes on disk and reloads them. It is meant
+// for development; call StopWatching to stop.
+func (m *AssetManager) Watch(interval time.Duration) {
+	m.mutex.Lock()
+	if m.stopWatch != nil {
+		m.mutex.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	m.stopWatch = stop
+	m.mutex.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				m.CheckForChanges()
+			}
+		}
+	}()
+}
+
+// StopWatching stops the hot reload watcher
+func (m *AssetManager) StopWatching() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.stopWatch != nil {
+		close(m.stopWatch)
+		m.stopWatch = nil
+	}
+}
+
+// CheckForChanges reloads loaded assets whose files changed and returns them
+func (m *AssetManager) CheckForChanges() []*Asset {
+	m.mutex.RLock()
+	var changed []*Asset
+	for _, asset := range m.assets {
+		if asset.State != AssetLoaded {
+			continue
+		}
+
+		info, err := os.Stat(filepath.Join(m.Root, asset.Path))
+		if err != nil || info.ModTime().Equal(asset.modTime) {
+			continue
+		}
+		changed = append(changed, asset)
+	}
+	callbacks := m.reloadCallbacks
+	m.mutex.RUnlock()
+
+	var reloaded []*Asset
+	for _, asset := range changed {
+		// Skip touches that did not change the contents
+		data, err := ioutil.ReadFile(filepath.Join(m.Root, asset.Path))
+		if err == nil && bytes.Equal(data, asset.Data) {
+			if info, err := os.Stat(filepath.Join(m.Root, asset.Path)); err == nil {
+				m.mutex.Lock()
+				asset.modTime = info.ModTime()
+				m.mutex.Unlock()
+			}
+			continue
+		}
+
+		m.mutex.RLock()
+		loader := m.loaders[asset.Type]
+		m.mutex.RUnlock()
+
+		if err := m.loadAsset(asset, loader); err != nil {
+			continue
+		}
+
+		reloaded = append(reloaded, asset)
+		for _, callback := range callbacks {
+			callback(asset)
+		}
+	}
+
+	return reloaded
+}
+
+// Handler serves assets by their hashed URLs with long-lived cache headers.
+// Mount it at the manifest's BaseURL.
+func (m *AssetManager) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Accept requests with or without the base URL stripped
+		base := strings.TrimSuffix(m.Manifest.BaseURL, "/")
+		full := r.URL.Path
+		if !strings.HasPrefix(full, base+"/") {
+			full = base + "/" + strings.TrimPrefix(full, "/")
+		}
+
+		m.mutex.RLock()
+		var match *Asset
+		for _, asset := range m.assets {
+			if asset.URL == full {
+				match = asset
+				break
+			}
+		}
+		m.mutex.RUnlock()
+
+		if match == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		http.ServeFile(w, r, filepath.Join(m.Root, match.Path))
+	})
+}
+
+// loadImage creates a texture from an image asset
+func (m *AssetManager) loadImage(asset *Asset) (interface{}, error) {
+	if m.Textures == nil {
+		return asset.Data, nil
+	}
+
+	// Replace the previous version on reload
+	m.Textures.Unload(asset.Name)
+
+	return m.Textures.LoadBytes(asset.Name, asset.Data, DefaultTextureOptions())
+}
+
+// loadModel parses a model asset
+func (m *AssetManager) loadModel(asset *Asset) (interface{}, error) {
+	switch strings.ToLower(filepath.Ext(asset.Path)) {
+	case ".obj":
+		return ParseOBJ(bytes.NewReader(asset.Data), asset.Name)
+	default:
+		return nil, fmt.Errorf("unsupported model format %s", filepath.Ext(asset.Path))
+	}
+}
+
+// loadAudio registers a sound with the audio engine under its hashed URL
+func (m *AssetManager) loadAudio(asset *Asset) (interface{}, error) {
+	if m.Audio == nil {
+		return asset.Data, nil
+	}
+
+	// Replace the previous version on reload
+	m.Audio.Unload(asset.Name)
+
+	return m.Audio.Load(asset.Name, asset.URL, false)
+}
@@ -0,0 +1,697 @@
+package engine
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// MasterBus is the name of the bus every other bus routes into
+const MasterBus = "master"
+
+// Sound is an audio clip that can be played by voices
+type Sound struct {
+	// Sound ID
+	ID string
+
+	// URL of the audio file
+	URL string
+
+	// Stream long sounds such as music instead of decoding them up front
+	Stream bool
+
+	// Loaded is true once the backend has the sound
+	Loaded bool
+}
+
+// AudioBus groups voices so their volume can be controlled together
+type AudioBus struct {
+	// Bus name
+	Name string
+
+	// Parent bus (nil for the master bus)
+	Parent *AudioBus
+
+	// Volume from 0 to 1
+	Volume float64
+
+	// Muted
+	Muted bool
+}
+
+// Gain returns the bus volume including its parents
+func (b *AudioBus) Gain() float64 {
+	gain := 1.0
+	for bus := b; bus != nil; bus = bus.Parent {
+		if bus.Muted {
+			return 0
+		}
+		gain *= bus.Volume
+	}
+	return gain
+}
+
+// PlayOptions configures a voice
+type PlayOptions struct {
+	// Bus to play on (defaults to the master bus)
+	Bus string
+
+	// Volume from 0 to 1
+	Volume float64
+
+	// Loop the sound
+	Loop bool
+
+	// Playback rate, 1 is normal speed
+	Rate float64
+
+	// Spatial voices are panned and attenuated relative to the listener
+	Spatial bool
+
+	// World position for spatial voices
+	Position [3]float64
+
+	// Distance at which attenuation starts
+	RefDistance float64
+
+	// Distance beyond which the voice is no longer attenuated
+	MaxDistance float64
+}
+
+// DefaultPlayOptions returns the options used by Play
+func DefaultPlayOptions() PlayOptions {
+	return PlayOptions{
+		Bus:         MasterBus,
+		Volume:      1,
+		Rate:        1,
+		RefDistance: 1,
+		MaxDistance: 100,
+	}
+}
+
+// AudioVoice is a playing instance of a sound
+type AudioVoice struct {
+	// Voice ID
+	ID string
+
+	// Sound being played
+	Sound *Sound
+
+	// Bus the voice plays on
+	Bus *AudioBus
+
+	// Options the voice was started with
+	Options PlayOptions
+
+	// Playing is false once the voice has been stopped
+	Playing bool
+}
+
+// Gain returns the voice volume including its bus chain
+func (v *AudioVoice) Gain() float64 {
+	return v.Options.Volume * v.Bus.Gain()
+}
+
+// AudioBackend performs audio operations on a platform
+type AudioBackend interface {
+	// Load prepares a sound for playback
+	Load(sound *Sound) error
+
+	// Play starts a voice
+	Play(voice *AudioVoice) error
+
+	// Stop stops a voice
+	Stop(voice *AudioVoice) error
+
+	// SetGain sets a voice's effective gain
+	SetGain(voice *AudioVoice, gain float64) error
+
+	// SetPosition moves a spatial voice
+	SetPosition(voice *AudioVoice, position [3]float64) error
+
+	// SetListener moves the listener
+	SetListener(position, forward, up [3]float64) error
+}
+
+// AudioEngine manages sounds, buses and voices
+type AudioEngine struct {
+	// Backend
+	Backend AudioBackend
+
+	// Loaded sounds
+	Sounds map[string]*Sound
+
+	// Buses by name
+	Buses map[string]*AudioBus
+
+	// Playing voices
+	Voices map[string]*AudioVoice
+
+	// Voice ID counter
+	nextVoice int
+
+	// Mutex for thread safety
+	mutex sync.RWMutex
+}
+
+// NewAudioEngine creates an audio engine. A nil backend uses Web Audio.
+func NewAudioEngine(backend AudioBackend) *AudioEngine {
+	if backend == nil {
+		backend = NewWebAudioBackend()
+	}
+
+	return &AudioEngine{
+		Backend: backend,
+		Sounds:  make(map[string]*Sound),
+		Buses: map[string]*AudioBus{
+			MasterBus: {Name: MasterBus, Volume: 1},
+		},
+		Voices: make(map[string]*AudioVoice),
+	}
+}
+
+// Load registers and loads a sound
+func (a *AudioEngine) Load(id, url string, stream bool) (*Sound, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if sound, ok := a.Sounds[id]; ok {
+		return sound, nil
+	}
+
+	sound := &Sound{ID: id, URL: url, Stream: stream}
+	if err := a.Backend.Load(sound); err != nil {
+		return nil, fmt.Errorf("failed to load sound %s: %v", id, err)
+	}
+	sound.Loaded = true
+	a.Sounds[id] = sound
+
+	return sound, nil
+}
+
+// Unload forgets a sound so it can be loaded again
+func (a *AudioEngine) Unload(id string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	delete(a.Sounds, id)
+}
+
+// CreateBus creates a bus routed into parent (the master bus if empty)
+func (a *AudioEngine) CreateBus(name, parent string) (*AudioBus, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if _, ok := a.Buses[name]; ok {
+		return nil, fmt.Errorf("bus %s already exists", name)
+	}
+
+	if parent == "" {
+		parent = MasterBus
+	}
+	parentBus, ok := a.Buses[parent]
+	if !ok {
+		return nil, fmt.Errorf("bus %s not found", parent)
+	}
+
+	bus := &AudioBus{Name: name, Parent: parentBus, Volume: 1}
+	a.Buses[name] = bus
+
+	return bus, nil
+}
+
+// SetVolume sets a bus volume and updates the voices affected by it
+func (a *AudioEngine) SetVolume(bus string, volume float64) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	target, ok := a.Buses[bus]
+	if !ok {
+		return fmt.Errorf("bus %s not found", bus)
+	}
+	target.Volume = clamp(volume, 0, 1)
+
+	return a.refreshGains()
+}
+
+// SetMuted mutes or unmutes a bus
+func (a *AudioEngine) SetMuted(bus string, muted bool) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	target, ok := a.Buses[bus]
+	if !ok {
+		return fmt.Errorf("bus %s not found", bus)
+	}
+	target.Muted = muted
+
+	return a.refreshGains()
+}
+
+// SetMasterVolume sets the master volume
+func (a *AudioEngine) SetMasterVolume(volume float64) error {
+	return a.SetVolume(MasterBus, volume)
+}
+
+// refreshGains pushes effective gains for all playing voices to the backend
+func (a *AudioEngine) refreshGains() error {
+	for _, voice := range a.Voices {
+		if err := a.Backend.SetGain(voice, voice.Gain()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Play starts a sound with default options
+func (a *AudioEngine) Play(soundID string) (*AudioVoice, error) {
+	return a.PlayWithOptions(soundID, DefaultPlayOptions())
+}
+
+// Loop starts a looping sound on a bus
+func (a *AudioEngine) Loop(soundID, bus string) (*AudioVoice, error) {
+	options := DefaultPlayOptions()
+	options.Bus = bus
+	options.Loop = true
+	return a.PlayWithOptions(soundID, options)
+}
+
+// PlayAt starts a spatial sound at a world position
+func (a *AudioEngine) PlayAt(soundID string, position [3]float64) (*AudioVoice, error) {
+	options := DefaultPlayOptions()
+	options.Spatial = true
+	options.Position = position
+	return a.PlayWithOptions(soundID, options)
+}
+
+// PlayWithOptions starts a sound
+func (a *AudioEngine) PlayWithOptions(soundID string, options PlayOptions) (*AudioVoice, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	sound, ok := a.Sounds[soundID]
+	if !ok {
+		return nil, fmt.Errorf("sound %s not loaded", soundID)
+	}
+
+	if options.Bus == "" {
+		options.Bus = MasterBus
+	}
+	bus, ok := a.Buses[options.Bus]
+	if !ok {
+		return nil, fmt.Errorf("bus %s not found", options.Bus)
+	}
+
+	if options.Rate <= 0 {
+		options.Rate = 1
+	}
+	options.Volume = clamp(options.Volume, 0, 1)
+
+	a.nextVoice++
+	voice := &AudioVoice{
+		ID:      fmt.Sprintf("voice-%d", a.nextVoice),
+		Sound:   sound,
+		Bus:     bus,
+		Options: options,
+		Playing: true,
+	}
+
+	if err := a.Backend.Play(voice); err != nil {
+		return nil, err
+	}
+	a.Voices[voice.ID] = voice
+
+	return voice, nil
+}
+
+// Stop stops a voice
+func (a *AudioEngine) Stop(voice *AudioVoice) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if voice == nil || !voice.Playing {
+		return nil
+	}
+
+	voice.Playing = false
+	delete(a.Voices, voice.ID)
+
+	return a.Backend.Stop(voice)
+}
+
+// StopAll stops every voice, or only the voices on a bus if one is given
+func (a *AudioEngine) StopAll(bus string) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for id, voice := range a.Voices {
+		if bus != "" && voice.Bus.Name != bus {
+			continue
+		}
+		voice.Playing = false
+		delete(a.Voices, id)
+		if err := a.Backend.Stop(voice); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetVoicePosition moves a spatial voice
+func (a *AudioEngine) SetVoicePosition(voice *AudioVoice, position [3]float64) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if voice == nil || !voice.Playing {
+		return errors.New("voice is not playing")
+	}
+	if !voice.Options.Spatial {
+		return fmt.Errorf("voice %s is not spatial", voice.ID)
+	}
+
+	voice.Options.Position = position
+
+	return a.Backend.SetPosition(voice, position)
+}
+
+// SetListener moves the listener that spatial voices are heard from
+func (a *AudioEngine) SetListener(position, forward, up [3]float64) error {
+	return a.Backend.SetListener(position, forward, up)
+}
+
+// AudioCommand is an operation queued for the browser's Web Audio runtime
+type AudioCommand struct {
+	Op     string                 `json:"op"`
+	Sound  string                 `json:"sound,omitempty"`
+	Voice  string                 `json:"voice,omitempty"`
+	Params map[string]interface{} `json:"params,omitempty"`
+}
+
+// WebAudioBackend queues commands for the Web Audio runtime in the browser.
+// Commands are sent to the page with Flush and applied by WebAudioRuntime.
+type WebAudioBackend struct {
+	// Queued commands
+	commands []AudioCommand
+
+	// Mutex for thread safety
+	mutex sync.Mutex
+}
+
+// NewWebAudioBackend creates a Web Audio backend
+func NewWebAudioBackend() *WebAudioBackend {
+	return &WebAudioBackend{}
+}
+
+// queue appends a command
+func (w *WebAudioBackend) queue(command AudioCommand) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.commands = append(w.commands, command)
+	return nil
+}
+
+// Load queues a sound load
+func (w *WebAudioBackend) Load(sound *Sound) error {
+	return w.queue(AudioCommand{
+		Op:     "load",
+		Sound:  sound.ID,
+		Params: map[string]interface{}{"url": sound.URL, "stream": sound.Stream},
+	})
+}
+
+// Play queues a voice start
+func (w *WebAudioBackend) Play(voice *AudioVoice) error {
+	params := map[string]interface{}{
+		"gain": voice.Gain(),
+		"loop": voice.Options.Loop,
+		"rate": voice.Options.Rate,
+	}
+	if voice.Options.Spatial {
+		params["position"] = voice.Options.Position
+		params["refDistance"] = voice.Options.RefDistance
+		params["maxDistance"] = voice.Options.MaxDistance
+	}
+
+	return w.queue(AudioCommand{Op: "play", Sound: voice.Sound.ID, Voice: voice.ID, Params: params})
+}
+
+// Stop queues a voice stop
+func (w *WebAudioBackend) Stop(voice *AudioVoice) error {
+	return w.queue(AudioCommand{Op: "stop", Voice: voice.ID})
+}
+
+// SetGain queues a gain change
+func (w *WebAudioBackend) SetGain(voice *AudioVoice, gain float64) error {
+	return w.queue(AudioCommand{Op: "gain", Voice: voice.ID, Params: map[string]interface{}{"gain": gain}})
+}
+
+// SetPosition queues a voice move
+func (w *WebAudioBackend) SetPosition(voice *AudioVoice, position [3]float64) error {
+	return w.queue(AudioCommand{Op: "position", Voice: voice.ID, Params: map[string]interface{}{"position": position}})
+}
+
+// SetListener queues a listener move
+func (w *WebAudioBackend) SetListener(position, forward, up [3]float64) error {
+	return w.queue(AudioCommand{
+		Op:     "listener",
+		Params: map[string]interface{}{"position": position, "forward": forward, "up": up},
+	})
+}
+
+// Flush returns the queued commands as JSON and clears the queue
+func (w *WebAudioBackend) Flush() ([]byte, error) {
+	w.mutex.Lock()
+	commands := w.commands
+	w.commands = nil
+	w.mutex.Unlock()
+
+	if commands == nil {
+		commands = []AudioCommand{}
+	}
+
+	return json.Marshal(commands)
+}
+
+// WebAudioRuntime is the browser script that applies flushed commands with
+// window.gocsxAudio.apply(commands). Playback starts after the first user
+// gesture, as browsers require.
+const WebAudioRuntime = `(function () {
+  var ctx = null, buffers = {}, voices = {}, pending = [];
+  function context() {
+    if (!ctx) { ctx = new (window.AudioContext || window.webkitAudioContext)(); }
+    return ctx;
+  }
+  function run(c) {
+    var ac = context(), v = voices[c.voice], p = c.params || {};
+    switch (c.op) {
+    case "load":
+      buffers[c.sound] = fetch(p.url).then(function (r) { return r.arrayBuffer(); })
+        .then(function (data) { return ac.decodeAudioData(data); });
+      break;
+    case "play":
+      buffers[c.sound].then(function (buffer) {
+        var src = ac.createBufferSource(), gain = ac.createGain(), node = gain;
+        src.buffer = buffer; src.loop = !!p.loop; src.playbackRate.value = p.rate || 1;
+        gain.gain.value = p.gain;
+        var voice = { src: src, gain: gain, panner: null };
+        if (p.position) {
+          var panner = ac.createPanner();
+          panner.panningModel = "HRTF";
+          panner.refDistance = p.refDistance; panner.maxDistance = p.maxDistance;
+          panner.positionX.value = p.position[0]; panner.positionY.value = p.position[1]; panner.positionZ.value = p.position[2];
+          gain.connect(panner); node = panner; voice.panner = panner;
+        }
+        src.connect(gain); node.connect(ac.destination);
+        src.onended = function () { delete voices[c.voice]; };
+        voices[c.voice] = voice;
+        src.start();
+      });
+      break;
+    case "stop":
+      if (v) { v.src.stop(); delete voices[c.voice]; }
+      break;
+    case "gain":
+      if (v) { v.gain.gain.setTargetAtTime(p.gain, ac.currentTime, 0.01); }
+      break;
+    case "position":
+      if (v && v.panner) {
+        v.panner.positionX.value = p.position[0]; v.panner.positionY.value = p.position[1]; v.panner.positionZ.value = p.position[2];
+      }
+      break;
+    case "listener":
+      var l = ac.listener;
+      if (l.positionX) {
+        l.positionX.value = p.position[0]; l.positionY.value = p.position[1]; l.positionZ.value = p.position[2];
+        l.forwardX.value = p.forward[0]; l.forwardY.value = p.forward[1]; l.forwardZ.value = p.forward[2];
+        l.upX.value = p.up[0]; l.upY.value = p.up[1]; l.upZ.value = p.up[2];
+      } else {
+        l.setPosition(p.position[0], p.position[1], p.position[2]);
+        l.setOrientation(p.forward[0], p.forward[1], p.forward[2], p.up[0], p.up[1], p.up[2]);
+      }
+      break;
+    }
+  }
+  function unlock() {
+    context().resume();
+    pending.splice(0).forEach(run);
+    window.removeEventListener("pointerdown", unlock);
+    window.removeEventListener("keydown", unlock);
+  }
+  window.addEventListener("pointerdown", unlock);
+  window.addEventListener("keydown", unlock);
+  window.gocsxAudio = {
+    apply: function (commands) {
+      commands.forEach(function (c) {
+        if (ctx && ctx.state === "running") { run(c); } else { pending.push(c); }
+      });
+    }
+  };
+})();`
+
+// AudioSource is a component that plays a sound from its object's position
+type AudioSource struct {
+	BaseComponent
+
+	// Audio engine
+	Audio *AudioEngine
+
+	// Sound to play
+	SoundID string
+
+	// Play options; Spatial voices follow the object
+	Options PlayOptions
+
+	// Start playing when attached
+	AutoPlay bool
+
+	// Current voice
+	Voice *AudioVoice
+}
+
+// NewAudioSource creates a spatial audio source component
+func NewAudioSource(id, name string, audio *AudioEngine, soundID string) *AudioSource {
+	options := DefaultPlayOptions()
+	options.Spatial = true
+
+	return &AudioSource{
+		BaseComponent: BaseComponent{
+			ID:      id,
+			Name:    name,
+			Enabled: true,
+		},
+		Audio:   audio,
+		SoundID: soundID,
+		Options: options,
+	}
+}
+
+// OnAttach starts playback when AutoPlay is set
+func (s *AudioSource) OnAttach() {
+	if s.AutoPlay {
+		s.Play()
+	}
+}
+
+// OnDetach stops playback
+func (s *AudioSource) OnDetach() {
+	s.Stop()
+}
+
+// OnUpdate keeps spatial voices at the object's position
+func (s *AudioSource) OnUpdate(deltaTime float64) {
+	if s.Voice == nil || !s.Voice.Playing || !s.Options.Spatial || s.Object == nil {
+		return
+	}
+
+	position := mat4TransformPoint(s.Object.WorldMatrix(), [3]float64{})
+	if position != s.Voice.Options.Position {
+		s.Audio.SetVoicePosition(s.Voice, position)
+	}
+}
+
+// Play starts the sound, stopping any previous voice
+func (s *AudioSource) Play() error {
+	s.Stop()
+
+	options := s.Options
+	if options.Spatial && s.Object != nil {
+		options.Position = mat4TransformPoint(s.Object.WorldMatrix(), [3]float64{})
+	}
+
+	voice, err := s.Audio.PlayWithOptions(s.SoundID, options)
+	if err != nil {
+		return err
+	}
+	s.Voice = voice
+
+	return nil
+}
+
+// Stop stops the sound
+func (s *AudioSource) Stop() error {
+	if s.Voice == nil {
+		return nil
+	}
+
+	voice := s.Voice
+	s.Voice = nil
+
+	return s.Audio.Stop(voice)
+}
+
+// AudioListener is a component that hears spatial audio from its object,
+// usually the camera
+type AudioListener struct {
+	BaseComponent
+
+	// Audio engine
+	Audio *AudioEngine
+
+	// Last pose sent to the backend
+	position [3]float64
+	forward  [3]float64
+}
+
+// NewAudioListener creates an audio listener component
+func NewAudioListener(id, name string, audio *AudioEngine) *AudioListener {
+	return &AudioListener{
+		BaseComponent: BaseComponent{
+			ID:      id,
+			Name:    name,
+			Enabled: true,
+		},
+		Audio: audio,
+	}
+}
+
+// OnUpdate moves the listener with its object
+func (l *AudioListener) OnUpdate(deltaTime float64) {
+	if l.Object == nil {
+		return
+	}
+
+	world := l.Object.WorldMatrix()
+	position := mat4TransformPoint(world, [3]float64{})
+	forward := vec3Normalize(mat4TransformDirection(world, [3]float64{0, 0, -1}))
+	up := vec3Normalize(mat4TransformDirection(world, [3]float64{0, 1, 0}))
+
+	// Cameras face their target rather than their rotation
+	for _, component := range l.Object.Components {
+		if camera, ok := component.(*Camera); ok {
+			forward = vec3Normalize(vec3Sub(camera.Target, position))
+			up = camera.Up
+			break
+		}
+	}
+
+	if position == l.position && forward == l.forward {
+		return
+	}
+	l.position = position
+	l.forward = forward
+
+	l.Audio.SetListener(position, forward, up)
+}
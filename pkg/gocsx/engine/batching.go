@@ -0,0 +1,477 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BatchKind describes how a batch is submitted to the GPU
+type BatchKind string
+
+const (
+	// BatchSingle draws one renderer
+	BatchSingle BatchKind = "single"
+
+	// BatchStatic draws static renderers sharing a material from one merged mesh
+	BatchStatic BatchKind = "static"
+
+	// BatchInstanced draws renderers sharing a mesh and material in one instanced call
+	BatchInstanced BatchKind = "instanced"
+)
+
+// RenderBatch is a single draw call
+type RenderBatch struct {
+	// Batch kind
+	Kind BatchKind
+
+	// Mesh to draw (the merged mesh for static batches)
+	Mesh *Mesh
+
+	// Material used by every renderer in the batch
+	Material *Material
+
+	// Renderers drawn by the batch
+	Renderers []*MeshRenderer
+
+	// Instance transforms for instanced batches
+	Transforms [][16]float64
+
+	// Triangles drawn by the batch
+	Triangles int
+}
+
+// DrawStats describes the work saved by culling and batching
+type DrawStats struct {
+	// Renderers considered
+	Renderers int
+
+	// Renderers removed by frustum culling, counting every renderer of a
+	// static batch whose merged bounds are outside the frustum
+	Culled int
+
+	// Draw calls needed without batching
+	DrawCallsBefore int
+
+	// Draw calls after batching
+	DrawCallsAfter int
+
+	// Renderers drawn through instancing
+	Instanced int
+
+	// Renderers drawn through static batches
+	StaticBatched int
+
+	// Triangles drawn
+	Triangles int
+}
+
+// DrawList is the ordered list of draw calls for a frame
+type DrawList struct {
+	// Batches in submission order
+	Batches []*RenderBatch
+
+	// Stats
+	Stats DrawStats
+}
+
+// Batcher culls and batches scene renderers into draw calls
+type Batcher struct {
+	// FrustumCulling skips renderers outside the camera frustum
+	FrustumCulling bool
+
+	// StaticBatching merges static renderers that share a material
+	StaticBatching bool
+
+	// Instancing draws repeated meshes with one instanced call
+	Instancing bool
+
+	// Static batches by material, built from every static renderer
+	// sharing it so culling does not change them
+	staticBatches map[string]*staticBatch
+
+	// Mutex for thread safety
+	mutex sync.Mutex
+}
+
+// NewBatcher creates a batcher with culling, static batching and instancing enabled
+func NewBatcher() *Batcher {
+	return &Batcher{
+		FrustumCulling: true,
+		StaticBatching: true,
+		Instancing:     true,
+		staticBatches:  make(map[string]*staticBatch),
+	}
+}
+
+// staticBatch is a merged mesh and the renderers it was merged from
+type staticBatch struct {
+	// Renderer and mesh IDs the mesh was merged from
+	signature string
+
+	// Merged mesh, or the error merging the renderers
+	mesh *Mesh
+	err  error
+}
+
+// Build collects the visible renderers in a scene and groups them into draw calls.
+// The camera may be nil, in which case nothing is culled.
+//
+// Static renderers sharing a material are merged once, whether or not they
+// are visible, and the merged mesh is culled as a whole by its bounds; a
+// static renderer alone with its material, or whose mesh cannot be merged,
+// is culled and drawn on its own.
+func (b *Batcher) Build(scene *Scene, camera *Camera) *DrawList {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	list := &DrawList{}
+
+	var frustum *Frustum
+	if b.FrustumCulling && camera != nil {
+		frustum = NewCameraFrustum(camera)
+	}
+
+	// Collect visible renderers, and static renderers by material
+	var visible []*MeshRenderer
+	statics := make(map[string][]*MeshRenderer)
+	var staticKeys []string
+	scene.mutex.RLock()
+	for _, object := range scene.Objects {
+		if !object.Active || !object.Visible {
+			continue
+		}
+
+		// Switch levels of detail before culling so bounds match the drawn mesh
+		for _, component := range object.Components {
+			if lod, ok := component.(*LODGroup); ok && lod.Enabled && camera != nil {
+				lod.Select(camera)
+			}
+		}
+
+		for _, component := range object.Components {
+			renderer, ok := component.(*MeshRenderer)
+			if !ok || !renderer.Enabled || renderer.Mesh == nil {
+				continue
+			}
+
+			list.Stats.Renderers++
+
+			if b.StaticBatching && renderer.Static {
+				key := fmt.Sprintf("static|%s", materialID(rendererMaterial(renderer)))
+				if _, ok := statics[key]; !ok {
+					staticKeys = append(staticKeys, key)
+				}
+				statics[key] = append(statics[key], renderer)
+				continue
+			}
+
+			if frustum != nil && !frustum.IntersectsBounds(renderer.WorldBounds()) {
+				list.Stats.Culled++
+				continue
+			}
+
+			visible = append(visible, renderer)
+		}
+	}
+	scene.mutex.RUnlock()
+
+	// Cull static batches by their merged bounds
+	sort.Strings(staticKeys)
+	for _, key := range staticKeys {
+		renderers := statics[key]
+		sort.Slice(renderers, func(i, j int) bool { return renderers[i].ID < renderers[j].ID })
+
+		var mesh *Mesh
+		if len(renderers) > 1 {
+			mesh = b.staticMesh(key, renderers)
+		}
+		if mesh == nil {
+			for _, renderer := range renderers {
+				if frustum != nil && !frustum.IntersectsBounds(renderer.WorldBounds()) {
+					list.Stats.Culled++
+					continue
+				}
+				visible = append(visible, renderer)
+			}
+			continue
+		}
+
+		if frustum != nil && !frustum.IntersectsBounds(mesh.Bounds) {
+			list.Stats.Culled += len(renderers)
+			continue
+		}
+
+		list.Batches = append(list.Batches, &RenderBatch{
+			Kind:      BatchStatic,
+			Mesh:      mesh,
+			Material:  rendererMaterial(renderers[0]),
+			Renderers: renderers,
+			Triangles: meshTriangles(mesh),
+		})
+		list.Stats.DrawCallsBefore += len(renderers)
+		list.Stats.StaticBatched += len(renderers)
+	}
+
+	// Forget the batches of materials no static renderer uses any more
+	for key := range b.staticBatches {
+		if _, ok := statics[key]; !ok {
+			delete(b.staticBatches, key)
+		}
+	}
+
+	list.Stats.DrawCallsBefore += len(visible)
+
+	// Group renderers by how they can be drawn together
+	groups := make(map[string][]*MeshRenderer)
+	var keys []string
+	for _, renderer := range visible {
+		material := rendererMaterial(renderer)
+
+		var key string
+		switch {
+		case b.Instancing:
+			key = fmt.Sprintf("instanced|%s|%s", renderer.Mesh.ID, materialID(material))
+		default:
+			key = fmt.Sprintf("single|%s", renderer.ID)
+		}
+
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], renderer)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		renderers := groups[key]
+		sort.Slice(renderers, func(i, j int) bool { return renderers[i].ID < renderers[j].ID })
+		material := rendererMaterial(renderers[0])
+
+		switch {
+		case len(renderers) == 1:
+			list.Batches = append(list.Batches, &RenderBatch{
+				Kind:      BatchSingle,
+				Mesh:      renderers[0].Mesh,
+				Material:  material,
+				Renderers: renderers,
+				Triangles: meshTriangles(renderers[0].Mesh),
+			})
+		default:
+			transforms := make([][16]float64, len(renderers))
+			for i, renderer := range renderers {
+				transforms[i] = renderer.Object.WorldMatrix()
+			}
+			list.Batches = append(list.Batches, &RenderBatch{
+				Kind:       BatchInstanced,
+				Mesh:       renderers[0].Mesh,
+				Material:   material,
+				Renderers:  renderers,
+				Transforms: transforms,
+				Triangles:  meshTriangles(renderers[0].Mesh) * len(renderers),
+			})
+			list.Stats.Instanced += len(renderers)
+		}
+	}
+
+	// Order by render queue and material state to minimise pipeline switches
+	sort.SliceStable(list.Batches, func(i, j int) bool {
+		queueI, queueJ := batchQueue(list.Batches[i]), batchQueue(list.Batches[j])
+		if queueI != queueJ {
+			return queueI < queueJ
+		}
+		return batchStateKey(list.Batches[i]) < batchStateKey(list.Batches[j])
+	})
+
+	list.Stats.DrawCallsAfter = len(list.Batches)
+	for _, batch := range list.Batches {
+		list.Stats.Triangles += batch.Triangles
+	}
+
+	return list
+}
+
+// Invalidate drops cached static batches, call it after moving static objects
+func (b *Batcher) Invalidate() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.staticBatches = make(map[string]*staticBatch)
+}
+
+// staticMesh returns the merged mesh for a static batch, merging it again
+// when the renderers or their meshes changed, or nil if they cannot be merged
+func (b *Batcher) staticMesh(key string, renderers []*MeshRenderer) *Mesh {
+	parts := make([]string, len(renderers))
+	for i, renderer := range renderers {
+		parts[i] = renderer.ID + ":" + renderer.Mesh.ID
+	}
+	signature := strings.Join(parts, ",")
+
+	batch, ok := b.staticBatches[key]
+	if !ok || batch.signature != signature {
+		mesh, err := MergeMeshes(fmt.Sprintf("static-batch-%s", materialID(rendererMaterial(renderers[0]))), renderers)
+		batch = &staticBatch{signature: signature, mesh: mesh, err: err}
+		b.staticBatches[key] = batch
+	}
+	if batch.err != nil {
+		return nil
+	}
+
+	return batch.mesh
+}
+
+// MergeMeshes bakes the world transforms of renderers into a single mesh.
+// Normals are transformed by the inverse-transpose of each world matrix.
+// Normals, UVs and colors a mesh lacks are filled with zero normals and UVs
+// and white, so they stay aligned with the vertices, and a mesh whose
+// normals, UVs or colors do not match its vertices is an error.
+func MergeMeshes(id string, renderers []*MeshRenderer) (*Mesh, error) {
+	merged := &Mesh{
+		ID:   id,
+		Name: fmt.Sprintf("%s Mesh", id),
+	}
+
+	var normals, uvs, colors, indexed bool
+	for _, renderer := range renderers {
+		mesh := renderer.Mesh
+		if mesh == nil {
+			continue
+		}
+		for _, attribute := range []struct {
+			name  string
+			count int
+		}{
+			{"normals", len(mesh.Normals)},
+			{"UVs", len(mesh.UVs)},
+			{"colors", len(mesh.Colors)},
+		} {
+			if attribute.count != 0 && attribute.count != len(mesh.Vertices) {
+				return nil, fmt.Errorf("mesh %s has %d %s for %d vertices", mesh.ID, attribute.count, attribute.name, len(mesh.Vertices))
+			}
+		}
+		for _, index := range mesh.Indices {
+			if index < 0 || index >= len(mesh.Vertices) {
+				return nil, fmt.Errorf("mesh %s has index %d out of its %d vertices", mesh.ID, index, len(mesh.Vertices))
+			}
+		}
+		normals = normals || len(mesh.Normals) > 0
+		uvs = uvs || len(mesh.UVs) > 0
+		colors = colors || len(mesh.Colors) > 0
+		indexed = indexed || len(mesh.Indices) > 0
+	}
+
+	first := true
+	for _, renderer := range renderers {
+		mesh := renderer.Mesh
+		if mesh == nil {
+			continue
+		}
+
+		matrix := mat4Identity()
+		if renderer.Object != nil {
+			matrix = renderer.Object.WorldMatrix()
+		}
+		normalMatrix := mat4NormalMatrix(matrix)
+
+		base := len(merged.Vertices)
+		for i, vertex := range mesh.Vertices {
+			merged.Vertices = append(merged.Vertices, mat4TransformPoint(matrix, vertex))
+
+			if normals {
+				var normal [3]float64
+				if len(mesh.Normals) > 0 {
+					normal = vec3Normalize(mat4TransformDirection(normalMatrix, mesh.Normals[i]))
+				}
+				merged.Normals = append(merged.Normals, normal)
+			}
+			if uvs {
+				var uv [2]float64
+				if len(mesh.UVs) > 0 {
+					uv = mesh.UVs[i]
+				}
+				merged.UVs = append(merged.UVs, uv)
+			}
+			if colors {
+				color := [4]float64{1, 1, 1, 1}
+				if len(mesh.Colors) > 0 {
+					color = mesh.Colors[i]
+				}
+				merged.Colors = append(merged.Colors, color)
+			}
+		}
+
+		// Meshes drawn without indices are indexed in order
+		switch {
+		case len(mesh.Indices) > 0:
+			for _, index := range mesh.Indices {
+				merged.Indices = append(merged.Indices, base+index)
+			}
+		case indexed:
+			for i := range mesh.Vertices {
+				merged.Indices = append(merged.Indices, base+i)
+			}
+		}
+
+		bounds := transformBounds(matrix, mesh.Bounds)
+		if first {
+			merged.Bounds = bounds
+			first = false
+			continue
+		}
+		for axis := 0; axis < 3; axis++ {
+			if bounds[axis] < merged.Bounds[axis] {
+				merged.Bounds[axis] = bounds[axis]
+			}
+			if bounds[axis+3] > merged.Bounds[axis+3] {
+				merged.Bounds[axis+3] = bounds[axis+3]
+			}
+		}
+	}
+
+	return merged, nil
+}
+
+// rendererMaterial returns the renderer's primary material
+func rendererMaterial(renderer *MeshRenderer) *Material {
+	if len(renderer.Materials) == 0 {
+		return nil
+	}
+	return renderer.Materials[0]
+}
+
+// materialID returns a grouping key for a material
+func materialID(material *Material) string {
+	if material == nil {
+		return ""
+	}
+	return material.ID
+}
+
+// meshTriangles returns the number of triangles in a mesh
+func meshTriangles(mesh *Mesh) int {
+	if mesh == nil {
+		return 0
+	}
+	if len(mesh.Indices) > 0 {
+		return len(mesh.Indices) / 3
+	}
+	return len(mesh.Vertices) / 3
+}
+
+// batchQueue returns the render queue of a batch
+func batchQueue(batch *RenderBatch) int {
+	if batch.Material == nil {
+		return RenderQueueOpaque
+	}
+	return batch.Material.RenderQueue
+}
+
+// batchStateKey returns the material state key of a batch
+func batchStateKey(batch *RenderBatch) string {
+	if batch.Material == nil {
+		return ""
+	}
+	return batch.Material.StateKey()
+}
@@ -0,0 +1,157 @@
+package engine
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// batchingScene builds a camera at z=10 looking at the origin, three static
+// cubes and one lone static cube in view, and four moving cubes of which two
+// are behind the camera
+func batchingScene() (*Scene, *Camera) {
+	scene := NewScene("scene", "Scene")
+	stone, wood, glass := &Material{ID: "stone"}, &Material{ID: "wood"}, &Material{ID: "glass"}
+	cube := newCubeMesh(1)
+
+	add := func(id string, position [3]float64, material *Material, static bool) {
+		object := scene.CreateObject(id, id)
+		object.Position = position
+		renderer := NewMeshRenderer(id+"-renderer", id)
+		renderer.Mesh = cube
+		renderer.Materials = []*Material{material}
+		renderer.Static = static
+		scene.AddComponent(object, renderer)
+	}
+	add("wall-1", [3]float64{-2, 0, 0}, stone, true)
+	add("wall-2", [3]float64{0, 0, 0}, stone, true)
+	add("wall-3", [3]float64{2, 0, 0}, stone, true)
+	add("window", [3]float64{0, 2, 0}, glass, true)
+	add("crate-1", [3]float64{-1, -2, 0}, wood, false)
+	add("crate-2", [3]float64{1, -2, 0}, wood, false)
+	add("crate-3", [3]float64{-1, 0, 50}, wood, false)
+	add("crate-4", [3]float64{1, 0, 50}, wood, false)
+
+	cameraObject := scene.CreateObject("camera", "Camera")
+	cameraObject.Position = [3]float64{0, 0, 10}
+	camera := NewCamera("camera", "Camera")
+	scene.AddComponent(cameraObject, camera)
+	camera.LookAt([3]float64{0, 0, 0})
+	return scene, camera
+}
+
+func TestBatcherDrawCalls(t *testing.T) {
+	scene, camera := batchingScene()
+	batcher := NewBatcher()
+
+	list := batcher.Build(scene, camera)
+	stats := list.Stats
+	if stats.Renderers != 8 || stats.Culled != 2 || stats.DrawCallsBefore != 6 || stats.DrawCallsAfter != 3 {
+		t.Fatalf("expected 6 draw calls batched into 3, got %+v", stats)
+	}
+	if stats.StaticBatched != 3 || stats.Instanced != 2 || stats.Triangles != 3*12+2*12+12 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	kinds := make(map[BatchKind]int)
+	var static *RenderBatch
+	for _, batch := range list.Batches {
+		kinds[batch.Kind]++
+		if batch.Kind == BatchStatic {
+			static = batch
+		}
+	}
+	if kinds[BatchStatic] != 1 || kinds[BatchInstanced] != 1 || kinds[BatchSingle] != 1 {
+		t.Fatalf("expected a static, an instanced and a single batch, got %v", kinds)
+	}
+
+	// Culling the moving cubes differently keeps the same static batch
+	scene.Objects["crate-3"].Position = [3]float64{-1, 2, 0}
+	list = batcher.Build(scene, camera)
+	if list.Stats.Culled != 1 || list.Stats.DrawCallsAfter != 3 {
+		t.Fatalf("expected one cube culled, got %+v", list.Stats)
+	}
+	for _, batch := range list.Batches {
+		if batch.Kind == BatchStatic && batch.Mesh != static.Mesh {
+			t.Fatal("expected the static batch reused")
+		}
+	}
+
+	// Looking away culls the whole static batch by its merged bounds
+	camera.LookAt([3]float64{0, 0, 100})
+	list = batcher.Build(scene, camera)
+	if list.Stats.Culled != 7 || list.Stats.StaticBatched != 0 || list.Stats.DrawCallsAfter != 1 {
+		t.Fatalf("expected only the cube left at z=50 drawn, got %+v", list.Stats)
+	}
+	if len(batcher.staticBatches) != 1 {
+		t.Fatalf("expected one static batch kept, got %d", len(batcher.staticBatches))
+	}
+
+	// Changing the static renderers merges them again, replacing the batch
+	scene.Objects["wall-3"].Components["wall-3-renderer"].(*MeshRenderer).Static = false
+	camera.LookAt([3]float64{0, 0, 0})
+	list = batcher.Build(scene, camera)
+	for _, batch := range list.Batches {
+		if batch.Kind == BatchStatic && (batch.Mesh == static.Mesh || len(batch.Renderers) != 2) {
+			t.Fatalf("expected the two walls left merged again, got %+v", batch)
+		}
+	}
+	if len(batcher.staticBatches) != 1 {
+		t.Fatalf("expected one static batch kept, got %d", len(batcher.staticBatches))
+	}
+}
+
+func TestMergeMeshes(t *testing.T) {
+	triangle := &Mesh{
+		ID:       "triangle",
+		Vertices: [][3]float64{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}},
+		Normals:  [][3]float64{{1, 1, 1}, {1, 1, 1}, {1, 1, 1}},
+		UVs:      [][2]float64{{0, 0}, {1, 0}, {0, 1}},
+		Indices:  []int{0, 1, 2},
+	}
+	triangle.ComputeBounds()
+	plain := &Mesh{ID: "plain", Vertices: [][3]float64{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}}}
+	plain.ComputeBounds()
+
+	scene := NewScene("scene", "Scene")
+	stretched := scene.CreateObject("stretched", "Stretched")
+	stretched.Scale = [3]float64{2, 1, 1}
+	moved := scene.CreateObject("moved", "Moved")
+	moved.Position = [3]float64{0, 0, 5}
+	first, second := NewMeshRenderer("first", "First"), NewMeshRenderer("second", "Second")
+	first.Mesh, first.Object = triangle, stretched
+	second.Mesh, second.Object = plain, moved
+
+	merged, err := MergeMeshes("merged", []*MeshRenderer{first, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged.Vertices) != 6 || merged.Vertices[0] != [3]float64{2, 0, 0} || merged.Vertices[3] != [3]float64{0, 0, 5} {
+		t.Fatalf("unexpected vertices %v", merged.Vertices)
+	}
+	if len(merged.Indices) != 6 || merged.Indices[3] != 3 || merged.Indices[5] != 5 {
+		t.Fatalf("expected the unindexed mesh indexed in order, got %v", merged.Indices)
+	}
+	if merged.Bounds != [6]float64{0, 0, 0, 2, 1, 5} {
+		t.Fatalf("unexpected bounds %v", merged.Bounds)
+	}
+
+	// Stretching x turns the normal of the x+y+z plane towards y and z
+	normal := merged.Normals[0]
+	if math.Abs(normal[0]-1.0/3) > 1e-9 || math.Abs(normal[1]-2.0/3) > 1e-9 || math.Abs(normal[2]-2.0/3) > 1e-9 {
+		t.Fatalf("expected the normal (1, 2, 2)/3, got %v", normal)
+	}
+	edge1 := vec3Sub(merged.Vertices[1], merged.Vertices[0])
+	edge2 := vec3Sub(merged.Vertices[2], merged.Vertices[0])
+	if math.Abs(vec3Dot(normal, edge1)) > 1e-9 || math.Abs(vec3Dot(normal, edge2)) > 1e-9 {
+		t.Fatalf("expected the normal perpendicular to the stretched triangle, got %v", normal)
+	}
+
+	if len(merged.Normals) != 6 || len(merged.UVs) != 6 || merged.UVs[4] != [2]float64{} || len(merged.Colors) != 0 {
+		t.Fatalf("expected normals and UVs filled in for the plain mesh, got %v %v %v", merged.Normals, merged.UVs, merged.Colors)
+	}
+
+	plain.Colors = [][4]float64{{1, 0, 0, 1}}
+	if _, err := MergeMeshes("merged", []*MeshRenderer{first, second}); err == nil || !strings.Contains(err.Error(), "1 colors for 3 vertices") {
+		t.Fatalf("expected colors not matching the vertices refused, got %v", err)
+	}
+}
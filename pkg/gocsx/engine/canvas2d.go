@@ -25,6 +25,9 @@ type Canvas2D struct {
 	// Render callback
 	RenderCallback func(context *Canvas2DContext, deltaTime float64)
 	
+	// Pan/zoom controls (nil when disabled)
+	Controls *PanZoomController
+	
 	// Mutex for thread safety
 	mutex sync.RWMutex
 }
@@ -64,6 +67,10 @@ type Canvas2DContext struct {
 	// Text baseline
 	TextBaseline string
 	
+	// Measurer measures text for MeasureText and text layouts, such as
+	// with the browser's metrics; nil estimates them
+	Measurer TextMeasurer
+	
 	// Shadow color
 	ShadowColor string
 	
@@ -77,13 +84,16 @@ type Canvas2DContext struct {
 	ShadowOffsetY float64
 	
 	// Transform matrix
-	Transform [6]float64
+	TransformMatrix [6]float64
 	
 	// Clip region
 	ClipRegion []Path2D
 	
 	// Stats
 	Stats *Canvas2DStats
+
+	// Recorded drawing, for contexts created with NewCanvas2DRecorder
+	recording *canvas2DRecording
 }
 
 // Canvas2DStats represents 2D canvas statistics
@@ -153,7 +163,7 @@ func NewCanvas2D(id string, width, height int, engine *Engine) *Canvas2D {
 		ShadowBlur:              0,
 		ShadowOffsetX:           0,
 		ShadowOffsetY:           0,
-		Transform:               [6]float64{1, 0, 0, 1, 0, 0},
+		TransformMatrix:         [6]float64{1, 0, 0, 1, 0, 0},
 		ClipRegion:              []Path2D{},
 		Stats:                   &Canvas2DStats{},
 	}
@@ -167,6 +177,11 @@ func NewCanvas2D(id string, width, height int, engine *Engine) *Canvas2D {
 		Engine:  engine,
 	}
 	
+	// Attach pan/zoom controls if requested
+	if engine.Config.CameraControls == CameraControlsPanZoom {
+		canvas.Controls = NewPanZoomController(width, height)
+	}
+	
 	// Set render callback
 	engine.SetRenderCallback(canvas.Render)
 	
@@ -181,6 +196,11 @@ func (c *Canvas2D) Render(deltaTime float64) {
 	// Reset stats
 	c.Context.Stats = &Canvas2DStats{}
 	
+	// Apply the pan/zoom view
+	if c.Controls != nil {
+		c.Controls.Apply(c.Context)
+	}
+	
 	// Call render callback
 	if c.RenderCallback != nil {
 		c.RenderCallback(c.Context, deltaTime)
@@ -211,6 +231,35 @@ func (c *Canvas2D) SetSize(width, height int) {
 	
 	c.Width = width
 	c.Height = height
+	
+	if c.Controls != nil {
+		c.Controls.ViewportWidth = float64(width)
+		c.Controls.ViewportHeight = float64(height)
+		c.Controls.clampToBounds()
+	}
+}
+
+// EnablePanZoom enables pan/zoom controls, optionally bounded to a world rectangle
+func (c *Canvas2D) EnablePanZoom(bounds *[4]float64) *PanZoomController {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	
+	c.Controls = NewPanZoomController(c.Width, c.Height)
+	if bounds != nil {
+		c.Controls.SetBounds(bounds[0], bounds[1], bounds[2], bounds[3])
+	}
+	
+	return c.Controls
+}
+
+// HandleInput forwards an input event to the canvas controls
+func (c *Canvas2D) HandleInput(event InputEvent) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	
+	if c.Controls != nil {
+		c.Controls.HandleInput(event)
+	}
 }
 
 // GetContext gets the canvas context
@@ -233,6 +282,7 @@ func (c *Canvas2D) GetStats() *Canvas2DStats {
 func (ctx *Canvas2DContext) ClearRect(x, y, width, height float64) {
 	ctx.Stats.DrawCalls++
 	ctx.Stats.ClearCalls++
+	ctx.record("clearRect", x, y, width, height)
 	
 	// This would normally clear the rectangle on the canvas
 	// For now, we'll just update the stats
@@ -242,6 +292,7 @@ func (ctx *Canvas2DContext) ClearRect(x, y, width, height float64) {
 func (ctx *Canvas2DContext) FillRect(x, y, width, height float64) {
 	ctx.Stats.DrawCalls++
 	ctx.Stats.FillCalls++
+	ctx.record("fillRect", x, y, width, height)
 	
 	// This would normally fill the rectangle on the canvas
 	// For now, we'll just update the stats
@@ -251,6 +302,7 @@ func (ctx *Canvas2DContext) FillRect(x, y, width, height float64) {
 func (ctx *Canvas2DContext) StrokeRect(x, y, width, height float64) {
 	ctx.Stats.DrawCalls++
 	ctx.Stats.StrokeCalls++
+	ctx.record("strokeRect", x, y, width, height)
 	
 	// This would normally stroke the rectangle on the canvas
 	// For now, we'll just update the stats
@@ -261,6 +313,7 @@ func (ctx *Canvas2DContext) FillText(text string, x, y float64) {
 	ctx.Stats.DrawCalls++
 	ctx.Stats.TextCalls++
 	ctx.Stats.FillCalls++
+	ctx.record("fillText", text, x, y)
 	
 	// This would normally fill the text on the canvas
 	// For now, we'll just update the stats
@@ -271,21 +324,21 @@ func (ctx *Canvas2DContext) StrokeText(text string, x, y float64) {
 	ctx.Stats.DrawCalls++
 	ctx.Stats.TextCalls++
 	ctx.Stats.StrokeCalls++
+	ctx.record("strokeText", text, x, y)
 	
 	// This would normally stroke the text on the canvas
 	// For now, we'll just update the stats
 }
 
-// MeasureText measures text
+// MeasureText measures the width of text in the current font
 func (ctx *Canvas2DContext) MeasureText(text string) float64 {
-	// This would normally measure the text
-	// For now, we'll just return a dummy value
-	return float64(len(text) * 10)
+	return ctx.measurer().MeasureText(text, ParseFont(ctx.Font))
 }
 
 // BeginPath begins a path
 func (ctx *Canvas2DContext) BeginPath() {
 	ctx.Stats.PathCalls++
+	ctx.record("beginPath")
 	
 	// This would normally begin a path on the canvas
 	// For now, we'll just update the stats
@@ -294,6 +347,7 @@ func (ctx *Canvas2DContext) BeginPath() {
 // ClosePath closes a path
 func (ctx *Canvas2DContext) ClosePath() {
 	ctx.Stats.PathCalls++
+	ctx.record("closePath")
 	
 	// This would normally close a path on the canvas
 	// For now, we'll just update the stats
@@ -302,6 +356,7 @@ func (ctx *Canvas2DContext) ClosePath() {
 // MoveTo moves to a point
 func (ctx *Canvas2DContext) MoveTo(x, y float64) {
 	ctx.Stats.PathCalls++
+	ctx.record("moveTo", x, y)
 	
 	// This would normally move to a point on the canvas
 	// For now, we'll just update the stats
@@ -310,6 +365,7 @@ func (ctx *Canvas2DContext) MoveTo(x, y float64) {
 // LineTo draws a line to a point
 func (ctx *Canvas2DContext) LineTo(x, y float64) {
 	ctx.Stats.PathCalls++
+	ctx.record("lineTo", x, y)
 	
 	// This would normally draw a line to a point on the canvas
 	// For now, we'll just update the stats
@@ -318,6 +374,7 @@ func (ctx *Canvas2DContext) LineTo(x, y float64) {
 // BezierCurveTo draws a bezier curve
 func (ctx *Canvas2DContext) BezierCurveTo(cp1x, cp1y, cp2x, cp2y, x, y float64) {
 	ctx.Stats.PathCalls++
+	ctx.record("bezierCurveTo", cp1x, cp1y, cp2x, cp2y, x, y)
 	
 	// This would normally draw a bezier curve on the canvas
 	// For now, we'll just update the stats
@@ -326,6 +383,7 @@ func (ctx *Canvas2DContext) BezierCurveTo(cp1x, cp1y, cp2x, cp2y, x, y float64)
 // QuadraticCurveTo draws a quadratic curve
 func (ctx *Canvas2DContext) QuadraticCurveTo(cpx, cpy, x, y float64) {
 	ctx.Stats.PathCalls++
+	ctx.record("quadraticCurveTo", cpx, cpy, x, y)
 	
 	// This would normally draw a quadratic curve on the canvas
 	// For now, we'll just update the stats
@@ -334,6 +392,7 @@ func (ctx *Canvas2DContext) QuadraticCurveTo(cpx, cpy, x, y float64) {
 // Arc draws an arc
 func (ctx *Canvas2DContext) Arc(x, y, radius, startAngle, endAngle float64, anticlockwise bool) {
 	ctx.Stats.PathCalls++
+	ctx.record("arc", x, y, radius, startAngle, endAngle, anticlockwise)
 	
 	// This would normally draw an arc on the canvas
 	// For now, we'll just update the stats
@@ -342,6 +401,7 @@ func (ctx *Canvas2DContext) Arc(x, y, radius, startAngle, endAngle float64, anti
 // ArcTo draws an arc to a point
 func (ctx *Canvas2DContext) ArcTo(x1, y1, x2, y2, radius float64) {
 	ctx.Stats.PathCalls++
+	ctx.record("arcTo", x1, y1, x2, y2, radius)
 	
 	// This would normally draw an arc to a point on the canvas
 	// For now, we'll just update the stats
@@ -350,6 +410,7 @@ func (ctx *Canvas2DContext) ArcTo(x1, y1, x2, y2, radius float64) {
 // Rect adds a rectangle to the path
 func (ctx *Canvas2DContext) Rect(x, y, width, height float64) {
 	ctx.Stats.PathCalls++
+	ctx.record("rect", x, y, width, height)
 	
 	// This would normally add a rectangle to the path on the canvas
 	// For now, we'll just update the stats
@@ -359,6 +420,7 @@ func (ctx *Canvas2DContext) Rect(x, y, width, height float64) {
 func (ctx *Canvas2DContext) Fill() {
 	ctx.Stats.DrawCalls++
 	ctx.Stats.FillCalls++
+	ctx.record("fill")
 	
 	// This would normally fill the current path on the canvas
 	// For now, we'll just update the stats
@@ -368,6 +430,7 @@ func (ctx *Canvas2DContext) Fill() {
 func (ctx *Canvas2DContext) Stroke() {
 	ctx.Stats.DrawCalls++
 	ctx.Stats.StrokeCalls++
+	ctx.record("stroke")
 	
 	// This would normally stroke the current path on the canvas
 	// For now, we'll just update the stats
@@ -376,6 +439,7 @@ func (ctx *Canvas2DContext) Stroke() {
 // Clip clips the current path
 func (ctx *Canvas2DContext) Clip() {
 	ctx.Stats.PathCalls++
+	ctx.record("clip")
 	
 	// This would normally clip the current path on the canvas
 	// For now, we'll just update the stats
@@ -441,6 +505,7 @@ func (ctx *Canvas2DContext) GetImageData(x, y, width, height float64) []byte {
 // Save saves the canvas state
 func (ctx *Canvas2DContext) Save() {
 	ctx.Stats.TransformCalls++
+	ctx.record("save")
 	
 	// This would normally save the canvas state
 	// For now, we'll just update the stats
@@ -449,6 +514,7 @@ func (ctx *Canvas2DContext) Save() {
 // Restore restores the canvas state
 func (ctx *Canvas2DContext) Restore() {
 	ctx.Stats.TransformCalls++
+	ctx.record("restore")
 	
 	// This would normally restore the canvas state
 	// For now, we'll just update the stats
@@ -457,6 +523,7 @@ func (ctx *Canvas2DContext) Restore() {
 // Scale scales the canvas
 func (ctx *Canvas2DContext) Scale(x, y float64) {
 	ctx.Stats.TransformCalls++
+	ctx.record("scale", x, y)
 	
 	// This would normally scale the canvas
 	// For now, we'll just update the stats
@@ -465,6 +532,7 @@ func (ctx *Canvas2DContext) Scale(x, y float64) {
 // Rotate rotates the canvas
 func (ctx *Canvas2DContext) Rotate(angle float64) {
 	ctx.Stats.TransformCalls++
+	ctx.record("rotate", angle)
 	
 	// This would normally rotate the canvas
 	// For now, we'll just update the stats
@@ -473,6 +541,7 @@ func (ctx *Canvas2DContext) Rotate(angle float64) {
 // Translate translates the canvas
 func (ctx *Canvas2DContext) Translate(x, y float64) {
 	ctx.Stats.TransformCalls++
+	ctx.record("translate", x, y)
 	
 	// This would normally translate the canvas
 	// For now, we'll just update the stats
@@ -481,6 +550,7 @@ func (ctx *Canvas2DContext) Translate(x, y float64) {
 // Transform transforms the canvas
 func (ctx *Canvas2DContext) Transform(a, b, c, d, e, f float64) {
 	ctx.Stats.TransformCalls++
+	ctx.record("transform", a, b, c, d, e, f)
 	
 	// This would normally transform the canvas
 	// For now, we'll just update the stats
@@ -489,15 +559,15 @@ func (ctx *Canvas2DContext) Transform(a, b, c, d, e, f float64) {
 // SetTransform sets the canvas transform
 func (ctx *Canvas2DContext) SetTransform(a, b, c, d, e, f float64) {
 	ctx.Stats.TransformCalls++
+	ctx.record("setTransform", a, b, c, d, e, f)
 	
-	// This would normally set the canvas transform
-	// For now, we'll just update the stats
+	ctx.TransformMatrix = [6]float64{a, b, c, d, e, f}
 }
 
 // ResetTransform resets the canvas transform
 func (ctx *Canvas2DContext) ResetTransform() {
 	ctx.Stats.TransformCalls++
+	ctx.record("resetTransform")
 	
-	// This would normally reset the canvas transform
-	// For now, we'll just update the stats
+	ctx.TransformMatrix = [6]float64{1, 0, 0, 1, 0, 0}
 }
\ No newline at end of file
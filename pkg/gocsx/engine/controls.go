@@ -0,0 +1,491 @@
+package engine
+
+import (
+	"math"
+)
+
+// CameraControlMode selects a built-in camera controller
+type CameraControlMode string
+
+const (
+	// CameraControlsNone leaves the camera under manual control
+	CameraControlsNone CameraControlMode = ""
+
+	// CameraControlsOrbit orbits the camera around its target
+	CameraControlsOrbit CameraControlMode = "orbit"
+
+	// CameraControlsFly moves the camera like a first-person fly camera
+	CameraControlsFly CameraControlMode = "fly"
+
+	// CameraControlsPanZoom pans and zooms a 2D canvas
+	CameraControlsPanZoom CameraControlMode = "panzoom"
+)
+
+// InputEvent represents a pointer, wheel or keyboard event forwarded from the client runtime
+type InputEvent struct {
+	// Event type: "pointerdown", "pointermove", "pointerup", "wheel", "keydown", "keyup"
+	Type string
+
+	// Pointer position in canvas pixels
+	X float64
+	Y float64
+
+	// Pointer movement or wheel delta
+	DeltaX float64
+	DeltaY float64
+
+	// Pressed button (0 primary, 1 middle, 2 secondary)
+	Button int
+
+	// Key for keyboard events (KeyboardEvent.code, e.g. "KeyW")
+	Key string
+}
+
+// CameraController is a component that drives a camera from user input
+type CameraController interface {
+	Component
+
+	// HandleInput feeds an input event to the controller
+	HandleInput(event InputEvent)
+
+	// Mode returns the controller mode
+	Mode() CameraControlMode
+}
+
+// OrbitController orbits a camera object around a target point
+type OrbitController struct {
+	BaseComponent
+
+	// Camera driven by the controller
+	Camera *Camera
+
+	// Spherical coordinates around the target (radians)
+	Azimuth  float64
+	Polar    float64
+	Distance float64
+
+	// Distance limits
+	MinDistance float64
+	MaxDistance float64
+
+	// Polar angle limits (radians)
+	MinPolar float64
+	MaxPolar float64
+
+	// Sensitivity
+	RotateSpeed float64
+	ZoomSpeed   float64
+	PanSpeed    float64
+
+	// Damping factor: the share of velocity kept every 60th of a second
+	// (0 disables inertia, values close to 1 glide longer)
+	Damping float64
+
+	// Pending velocities, the motion of a 60th of a second
+	azimuthVelocity float64
+	polarVelocity   float64
+	zoomVelocity    float64
+
+	// Pointer state
+	dragging bool
+	panning  bool
+}
+
+// NewOrbitController creates an orbit controller for a camera
+func NewOrbitController(id string, camera *Camera) *OrbitController {
+	return &OrbitController{
+		BaseComponent: BaseComponent{
+			ID:      id,
+			Name:    "Orbit Controller",
+			Enabled: true,
+		},
+		Camera:      camera,
+		Distance:    5,
+		Polar:       math.Pi / 2,
+		MinDistance: 0.5,
+		MaxDistance: 500,
+		MinPolar:    0.01,
+		MaxPolar:    math.Pi - 0.01,
+		RotateSpeed: 0.005,
+		ZoomSpeed:   0.001,
+		PanSpeed:    0.002,
+		Damping:     0.85,
+	}
+}
+
+// Mode returns the controller mode
+func (o *OrbitController) Mode() CameraControlMode {
+	return CameraControlsOrbit
+}
+
+// OnAttach derives the orbit from the object's current position
+func (o *OrbitController) OnAttach() {
+	if o.Object == nil || o.Camera == nil {
+		return
+	}
+
+	offset := vec3Sub(o.Object.Position, o.Camera.Target)
+	distance := vec3Length(offset)
+	if distance == 0 {
+		return
+	}
+
+	o.Distance = clamp(distance, o.MinDistance, o.MaxDistance)
+	o.Polar = clamp(math.Acos(offset[1]/distance), o.MinPolar, o.MaxPolar)
+	o.Azimuth = math.Atan2(offset[0], offset[2])
+}
+
+// Rotate adds rotational velocity in pixels of pointer movement
+func (o *OrbitController) Rotate(dx, dy float64) {
+	o.azimuthVelocity -= dx * o.RotateSpeed
+	o.polarVelocity -= dy * o.RotateSpeed
+}
+
+// Zoom adds zoom velocity in wheel delta units
+func (o *OrbitController) Zoom(delta float64) {
+	o.zoomVelocity += delta * o.ZoomSpeed
+}
+
+// Pan moves the target in the camera's view plane
+func (o *OrbitController) Pan(dx, dy float64) {
+	if o.Camera == nil || o.Object == nil {
+		return
+	}
+
+	forward := vec3Normalize(vec3Sub(o.Camera.Target, o.Object.Position))
+	right := vec3Normalize(vec3Cross(forward, o.Camera.Up))
+	up := vec3Cross(right, forward)
+
+	scale := o.Distance * o.PanSpeed
+	offset := vec3Add(vec3Scale(right, -dx*scale), vec3Scale(up, dy*scale))
+	o.Camera.Target = vec3Add(o.Camera.Target, offset)
+}
+
+// HandleInput feeds an input event to the controller
+func (o *OrbitController) HandleInput(event InputEvent) {
+	switch event.Type {
+	case "pointerdown":
+		o.dragging = event.Button == 0
+		o.panning = event.Button == 1 || event.Button == 2
+	case "pointerup":
+		o.dragging = false
+		o.panning = false
+	case "pointermove":
+		if o.dragging {
+			o.Rotate(event.DeltaX, event.DeltaY)
+		} else if o.panning {
+			o.Pan(event.DeltaX, event.DeltaY)
+		}
+	case "wheel":
+		o.Zoom(event.DeltaY)
+	}
+}
+
+// OnUpdate applies velocities with damping and repositions the camera. The
+// motion is scaled by deltaTime, so a drag orbits as far at any frame rate.
+func (o *OrbitController) OnUpdate(deltaTime float64) {
+	if !o.Enabled || o.Object == nil || o.Camera == nil || deltaTime < 0 {
+		return
+	}
+
+	// Velocities decay by Damping every 60th of a second; a frame moves by
+	// what those 60ths would have moved, summed, so the total does not
+	// depend on how the time is split into frames
+	frames := deltaTime * 60
+	decay := math.Pow(o.Damping, frames)
+	step := frames
+	if o.Damping < 1 {
+		step = (1 - decay) / (1 - o.Damping)
+	}
+
+	o.Azimuth += o.azimuthVelocity * step
+	o.Polar = clamp(o.Polar+o.polarVelocity*step, o.MinPolar, o.MaxPolar)
+	o.Distance = clamp(o.Distance*math.Exp(o.zoomVelocity*step), o.MinDistance, o.MaxDistance)
+
+	o.azimuthVelocity *= decay
+	o.polarVelocity *= decay
+	o.zoomVelocity *= decay
+
+	sinPolar := math.Sin(o.Polar)
+	offset := [3]float64{
+		o.Distance * sinPolar * math.Sin(o.Azimuth),
+		o.Distance * math.Cos(o.Polar),
+		o.Distance * sinPolar * math.Cos(o.Azimuth),
+	}
+
+	o.Object.Position = vec3Add(o.Camera.Target, offset)
+	o.Camera.UpdateMatrices()
+}
+
+// FlyController moves a camera object like a first-person fly camera
+type FlyController struct {
+	BaseComponent
+
+	// Camera driven by the controller
+	Camera *Camera
+
+	// Orientation (radians)
+	Yaw   float64
+	Pitch float64
+
+	// Movement speed in units per second
+	MoveSpeed float64
+
+	// Speed multiplier while the boost key is held
+	BoostMultiplier float64
+
+	// Look sensitivity in radians per pixel
+	LookSensitivity float64
+
+	// Key bindings (KeyboardEvent.code values)
+	KeyBindings map[string]string
+
+	// Pressed actions
+	pressed map[string]bool
+
+	// Pointer state
+	looking bool
+}
+
+// NewFlyController creates a fly controller for a camera
+func NewFlyController(id string, camera *Camera) *FlyController {
+	return &FlyController{
+		BaseComponent: BaseComponent{
+			ID:      id,
+			Name:    "Fly Controller",
+			Enabled: true,
+		},
+		Camera:          camera,
+		MoveSpeed:       5,
+		BoostMultiplier: 3,
+		LookSensitivity: 0.003,
+		KeyBindings: map[string]string{
+			"KeyW":      "forward",
+			"KeyS":      "backward",
+			"KeyA":      "left",
+			"KeyD":      "right",
+			"KeyE":      "up",
+			"KeyQ":      "down",
+			"ShiftLeft": "boost",
+		},
+		pressed: make(map[string]bool),
+	}
+}
+
+// Mode returns the controller mode
+func (f *FlyController) Mode() CameraControlMode {
+	return CameraControlsFly
+}
+
+// OnAttach derives yaw and pitch from the camera's current target
+func (f *FlyController) OnAttach() {
+	if f.Object == nil || f.Camera == nil {
+		return
+	}
+
+	forward := vec3Normalize(vec3Sub(f.Camera.Target, f.Object.Position))
+	if vec3Length(forward) == 0 {
+		return
+	}
+
+	f.Pitch = math.Asin(clamp(forward[1], -1, 1))
+	f.Yaw = math.Atan2(forward[0], -forward[2])
+}
+
+// Look rotates the view by a pointer movement in pixels
+func (f *FlyController) Look(dx, dy float64) {
+	f.Yaw += dx * f.LookSensitivity
+	f.Pitch = clamp(f.Pitch-dy*f.LookSensitivity, -math.Pi/2+0.01, math.Pi/2-0.01)
+}
+
+// SetAction marks a movement action as pressed or released
+func (f *FlyController) SetAction(action string, pressed bool) {
+	f.pressed[action] = pressed
+}
+
+// HandleInput feeds an input event to the controller
+func (f *FlyController) HandleInput(event InputEvent) {
+	switch event.Type {
+	case "pointerdown":
+		f.looking = true
+	case "pointerup":
+		f.looking = false
+	case "pointermove":
+		if f.looking {
+			f.Look(event.DeltaX, event.DeltaY)
+		}
+	case "keydown", "keyup":
+		if action, ok := f.KeyBindings[event.Key]; ok {
+			f.SetAction(action, event.Type == "keydown")
+		}
+	}
+}
+
+// forward returns the view direction for the current yaw and pitch
+func (f *FlyController) forward() [3]float64 {
+	cosPitch := math.Cos(f.Pitch)
+	return [3]float64{
+		cosPitch * math.Sin(f.Yaw),
+		math.Sin(f.Pitch),
+		-cosPitch * math.Cos(f.Yaw),
+	}
+}
+
+// OnUpdate integrates movement and updates the camera target
+func (f *FlyController) OnUpdate(deltaTime float64) {
+	if !f.Enabled || f.Object == nil || f.Camera == nil {
+		return
+	}
+
+	forward := f.forward()
+	right := vec3Normalize(vec3Cross(forward, f.Camera.Up))
+
+	var move [3]float64
+	if f.pressed["forward"] {
+		move = vec3Add(move, forward)
+	}
+	if f.pressed["backward"] {
+		move = vec3Sub(move, forward)
+	}
+	if f.pressed["right"] {
+		move = vec3Add(move, right)
+	}
+	if f.pressed["left"] {
+		move = vec3Sub(move, right)
+	}
+	if f.pressed["up"] {
+		move = vec3Add(move, f.Camera.Up)
+	}
+	if f.pressed["down"] {
+		move = vec3Sub(move, f.Camera.Up)
+	}
+
+	speed := f.MoveSpeed
+	if f.pressed["boost"] {
+		speed *= f.BoostMultiplier
+	}
+
+	step := vec3Scale(vec3Normalize(move), speed*deltaTime)
+	f.Object.Position = vec3Add(f.Object.Position, step)
+	f.Camera.Target = vec3Add(f.Object.Position, forward)
+	f.Camera.UpdateMatrices()
+}
+
+// PanZoomController pans and zooms a 2D canvas view
+type PanZoomController struct {
+	// View offset in world units (top-left corner of the view)
+	OffsetX float64
+	OffsetY float64
+
+	// Zoom factor
+	Zoom float64
+
+	// Zoom limits
+	MinZoom float64
+	MaxZoom float64
+
+	// World bounds the view must stay inside (nil for unbounded)
+	Bounds *[4]float64 // min x, min y, max x, max y
+
+	// Wheel sensitivity
+	ZoomSpeed float64
+
+	// Viewport size in pixels
+	ViewportWidth  float64
+	ViewportHeight float64
+
+	// Pointer state
+	dragging bool
+}
+
+// NewPanZoomController creates a pan/zoom controller for a viewport
+func NewPanZoomController(width, height int) *PanZoomController {
+	return &PanZoomController{
+		Zoom:           1,
+		MinZoom:        0.1,
+		MaxZoom:        10,
+		ZoomSpeed:      0.001,
+		ViewportWidth:  float64(width),
+		ViewportHeight: float64(height),
+	}
+}
+
+// Mode returns the controller mode
+func (p *PanZoomController) Mode() CameraControlMode {
+	return CameraControlsPanZoom
+}
+
+// SetBounds restricts the view to a world-space rectangle
+func (p *PanZoomController) SetBounds(minX, minY, maxX, maxY float64) {
+	p.Bounds = &[4]float64{minX, minY, maxX, maxY}
+	p.clampToBounds()
+}
+
+// Pan moves the view by a pointer movement in pixels
+func (p *PanZoomController) Pan(dx, dy float64) {
+	p.OffsetX -= dx / p.Zoom
+	p.OffsetY -= dy / p.Zoom
+	p.clampToBounds()
+}
+
+// ZoomAt zooms by factor keeping the world point under the screen position fixed
+func (p *PanZoomController) ZoomAt(factor, screenX, screenY float64) {
+	worldX, worldY := p.ScreenToWorld(screenX, screenY)
+
+	p.Zoom = clamp(p.Zoom*factor, p.MinZoom, p.MaxZoom)
+	p.OffsetX = worldX - screenX/p.Zoom
+	p.OffsetY = worldY - screenY/p.Zoom
+	p.clampToBounds()
+}
+
+// ScreenToWorld converts screen pixels to world coordinates
+func (p *PanZoomController) ScreenToWorld(x, y float64) (float64, float64) {
+	return p.OffsetX + x/p.Zoom, p.OffsetY + y/p.Zoom
+}
+
+// WorldToScreen converts world coordinates to screen pixels
+func (p *PanZoomController) WorldToScreen(x, y float64) (float64, float64) {
+	return (x - p.OffsetX) * p.Zoom, (y - p.OffsetY) * p.Zoom
+}
+
+// HandleInput feeds an input event to the controller
+func (p *PanZoomController) HandleInput(event InputEvent) {
+	switch event.Type {
+	case "pointerdown":
+		p.dragging = true
+	case "pointerup":
+		p.dragging = false
+	case "pointermove":
+		if p.dragging {
+			p.Pan(event.DeltaX, event.DeltaY)
+		}
+	case "wheel":
+		p.ZoomAt(math.Exp(-event.DeltaY*p.ZoomSpeed), event.X, event.Y)
+	}
+}
+
+// Apply sets the context transform for the current view
+func (p *PanZoomController) Apply(ctx *Canvas2DContext) {
+	ctx.SetTransform(p.Zoom, 0, 0, p.Zoom, -p.OffsetX*p.Zoom, -p.OffsetY*p.Zoom)
+}
+
+// clampToBounds keeps the view inside the configured bounds
+func (p *PanZoomController) clampToBounds() {
+	if p.Bounds == nil {
+		return
+	}
+
+	viewWidth := p.ViewportWidth / p.Zoom
+	viewHeight := p.ViewportHeight / p.Zoom
+
+	p.OffsetX = clampAxis(p.OffsetX, p.Bounds[0], p.Bounds[2], viewWidth)
+	p.OffsetY = clampAxis(p.OffsetY, p.Bounds[1], p.Bounds[3], viewHeight)
+}
+
+// clampAxis clamps a view offset along one axis, centering when the view is larger than the bounds
+func clampAxis(offset, min, max, viewSize float64) float64 {
+	if viewSize >= max-min {
+		return min - (viewSize-(max-min))/2
+	}
+	return clamp(offset, min, max-viewSize)
+}
@@ -0,0 +1,190 @@
+package engine
+
+import (
+	"math"
+	"testing"
+)
+
+// project transforms a point by a column-major matrix and divides by w
+func project(m [16]float64, p [3]float64) [3]float64 {
+	var out [4]float64
+	for row := 0; row < 4; row++ {
+		out[row] = m[row]*p[0] + m[4+row]*p[1] + m[8+row]*p[2] + m[12+row]
+	}
+	return [3]float64{out[0] / out[3], out[1] / out[3], out[2] / out[3]}
+}
+
+func near(a, b [3]float64) bool {
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLookAtAndPerspective(t *testing.T) {
+	eye, target := [3]float64{3, 4, 5}, [3]float64{3, 4, 0}
+	view := lookAtMatrix(eye, target, [3]float64{0, 1, 0})
+
+	// The eye is the origin of view space, which looks down -z with +x right
+	// and +y up
+	if p := mat4TransformPoint(view, eye); !near(p, [3]float64{}) {
+		t.Fatalf("expected the eye at the origin, got %v", p)
+	}
+	if p := mat4TransformPoint(view, target); !near(p, [3]float64{0, 0, -5}) {
+		t.Fatalf("expected the target 5 ahead, got %v", p)
+	}
+	if p := mat4TransformPoint(view, [3]float64{4, 5, 0}); !near(p, [3]float64{1, 1, -5}) {
+		t.Fatalf("expected right and up kept, got %v", p)
+	}
+	if up := lookAtMatrix([3]float64{0, 5, 0}, [3]float64{}, [3]float64{0, 1, 0}); math.IsNaN(up[0]) || up[0] == 0 && up[4] == 0 && up[8] == 0 {
+		t.Fatalf("expected a view looking along up to pick another axis, got %v", up)
+	}
+
+	// A 90 degree field of view puts points at 45 degrees on the frustum's
+	// edges, and the near and far planes at -1 and 1
+	projection := perspectiveMatrix(90, 2, 1, 100)
+	if p := project(projection, [3]float64{0, 10, -10}); !near([3]float64{p[0], p[1], 0}, [3]float64{0, 1, 0}) {
+		t.Fatalf("expected the top edge at y=1, got %v", p)
+	}
+	if p := project(projection, [3]float64{20, 0, -10}); math.Abs(p[0]-1) > 1e-9 {
+		t.Fatalf("expected the aspect ratio to widen x, got %v", p)
+	}
+	if p := project(projection, [3]float64{0, 0, -1}); math.Abs(p[2]+1) > 1e-9 {
+		t.Fatalf("expected the near plane at -1, got %v", p)
+	}
+	if p := project(projection, [3]float64{0, 0, -100}); math.Abs(p[2]-1) > 1e-9 {
+		t.Fatalf("expected the far plane at 1, got %v", p)
+	}
+}
+
+// orbitRig attaches an orbit controller to a camera 5 units in front of
+// the origin
+func orbitRig() *OrbitController {
+	scene := NewScene("scene", "Scene")
+	object := scene.CreateObject("camera", "Camera")
+	object.Position = [3]float64{0, 0, 5}
+	camera := NewCamera("camera", "Camera")
+	scene.AddComponent(object, camera)
+	orbit := NewOrbitController("orbit", camera)
+	scene.AddComponent(object, orbit)
+	return orbit
+}
+
+func TestOrbitControllerIsFrameRateIndependent(t *testing.T) {
+	orbit := orbitRig()
+	if orbit.Distance != 5 || math.Abs(orbit.Polar-math.Pi/2) > 1e-9 || orbit.Azimuth != 0 {
+		t.Fatalf("expected the orbit derived from the camera, got %v %v %v", orbit.Distance, orbit.Polar, orbit.Azimuth)
+	}
+
+	// At 60 frames a second a frame moves by the velocity
+	orbit.Rotate(100, 0)
+	orbit.OnUpdate(1.0 / 60)
+	if math.Abs(orbit.Azimuth+100*orbit.RotateSpeed) > 1e-9 {
+		t.Fatalf("expected one frame's rotation, got %v", orbit.Azimuth)
+	}
+
+	// A second of frames at any rate ends in the same place
+	var results [][3]float64
+	for _, fps := range []float64{30, 60, 144} {
+		orbit := orbitRig()
+		orbit.Rotate(100, 40)
+		orbit.Zoom(-200)
+		for i := 0; i < int(fps); i++ {
+			orbit.OnUpdate(1 / fps)
+		}
+		results = append(results, [3]float64{orbit.Azimuth, orbit.Polar, orbit.Distance})
+
+		offset := vec3Sub(orbit.Object.Position, orbit.Camera.Target)
+		if math.Abs(vec3Length(offset)-orbit.Distance) > 1e-9 {
+			t.Fatalf("expected the camera %v from the target, got %v", orbit.Distance, vec3Length(offset))
+		}
+	}
+	for _, result := range results[1:] {
+		if !near(result, results[0]) {
+			t.Fatalf("expected the same orbit at every frame rate, got %v", results)
+		}
+	}
+	if results[0][0] >= 0 || results[0][2] >= 5 {
+		t.Fatalf("expected the camera to have rotated and zoomed in, got %v", results[0])
+	}
+
+	// Without damping a drag moves once
+	orbit = orbitRig()
+	orbit.Damping = 0
+	orbit.Rotate(100, 0)
+	orbit.OnUpdate(1.0 / 30)
+	orbit.OnUpdate(1.0 / 30)
+	if math.Abs(orbit.Azimuth+100*orbit.RotateSpeed) > 1e-9 {
+		t.Fatalf("expected the rotation applied once, got %v", orbit.Azimuth)
+	}
+}
+
+func TestFlyController(t *testing.T) {
+	scene := NewScene("scene", "Scene")
+	object := scene.CreateObject("camera", "Camera")
+	object.Position = [3]float64{0, 0, 5}
+	camera := NewCamera("camera", "Camera")
+	scene.AddComponent(object, camera)
+	fly := NewFlyController("fly", camera)
+	scene.AddComponent(object, fly)
+	if math.Abs(fly.Yaw) > 1e-9 || math.Abs(fly.Pitch) > 1e-9 {
+		t.Fatalf("expected the camera looking down -z, got yaw %v pitch %v", fly.Yaw, fly.Pitch)
+	}
+
+	fly.HandleInput(InputEvent{Type: "keydown", Key: "KeyW"})
+	fly.OnUpdate(0.5)
+	if !near(object.Position, [3]float64{0, 0, 2.5}) || !near(camera.Target, [3]float64{0, 0, 1.5}) {
+		t.Fatalf("expected half a second forward, got %v looking at %v", object.Position, camera.Target)
+	}
+	fly.HandleInput(InputEvent{Type: "keydown", Key: "ShiftLeft"})
+	fly.HandleInput(InputEvent{Type: "keydown", Key: "KeyD"})
+	fly.HandleInput(InputEvent{Type: "keyup", Key: "KeyW"})
+	fly.OnUpdate(0.1)
+	if !near(object.Position, [3]float64{1.5, 0, 2.5}) {
+		t.Fatalf("expected a boosted step right, got %v", object.Position)
+	}
+
+	// Looking only turns while the pointer is down, and stops short of
+	// straight up
+	fly.HandleInput(InputEvent{Type: "pointermove", DeltaY: -10000})
+	if fly.Pitch != 0 {
+		t.Fatalf("expected no look without the pointer down, got %v", fly.Pitch)
+	}
+	fly.HandleInput(InputEvent{Type: "pointerdown"})
+	fly.HandleInput(InputEvent{Type: "pointermove", DeltaY: -10000})
+	if fly.Pitch >= math.Pi/2 || fly.Pitch < math.Pi/2-0.02 {
+		t.Fatalf("expected the pitch clamped below straight up, got %v", fly.Pitch)
+	}
+}
+
+func TestPanZoomController(t *testing.T) {
+	controls := NewPanZoomController(800, 600)
+	controls.Pan(100, 50)
+	if controls.OffsetX != -100 || controls.OffsetY != -50 {
+		t.Fatalf("expected the view dragged, got %v, %v", controls.OffsetX, controls.OffsetY)
+	}
+
+	// Zooming keeps the point under the pointer still
+	x, y := controls.ScreenToWorld(200, 150)
+	controls.ZoomAt(2, 200, 150)
+	if sx, sy := controls.WorldToScreen(x, y); math.Abs(sx-200) > 1e-9 || math.Abs(sy-150) > 1e-9 {
+		t.Fatalf("expected the point to stay at 200, 150, got %v, %v", sx, sy)
+	}
+	controls.ZoomAt(100, 0, 0)
+	if controls.Zoom != controls.MaxZoom {
+		t.Fatalf("expected the zoom capped, got %v", controls.Zoom)
+	}
+
+	// Bounds keep the view inside, or centered when it is larger
+	controls.Zoom = 1
+	controls.SetBounds(0, 0, 1000, 400)
+	if controls.OffsetX != 0 || controls.OffsetY != -100 {
+		t.Fatalf("expected the view clamped and centered vertically, got %v, %v", controls.OffsetX, controls.OffsetY)
+	}
+	controls.Pan(-5000, 0)
+	if controls.OffsetX != 200 {
+		t.Fatalf("expected the view stopped at the right edge, got %v", controls.OffsetX)
+	}
+}
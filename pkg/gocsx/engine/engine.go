@@ -32,7 +32,8 @@ const (
 	// PerformanceHigh is for maximum performance
 	PerformanceHigh PerformanceLevel = "high"
 	
-	// PerformanceAdaptive automatically adjusts based on device capabilities
+	// PerformanceAdaptive scales the rendering quality to hold TargetFPS;
+	// see SetQualityCallback
 	PerformanceAdaptive PerformanceLevel = "adaptive"
 )
 
@@ -44,6 +45,13 @@ type EngineConfig struct {
 	// TargetFPS is the target frames per second
 	TargetFPS int
 	
+	// UpdateRate is the number of fixed simulation updates per second,
+	// independent of the frame rate; zero uses TargetFPS
+	UpdateRate int
+	
+	// MaxUpdatesPerFrame caps the fixed updates run in one frame
+	MaxUpdatesPerFrame int
+	
 	// AutoDetect automatically detects the appropriate context
 	AutoDetect bool
 	
@@ -55,6 +63,9 @@ type EngineConfig struct {
 	
 	// EnableStats enables performance statistics
 	EnableStats bool
+	
+	// CameraControls selects the built-in camera controller attached to new cameras
+	CameraControls CameraControlMode
 }
 
 // DefaultEngineConfig returns the default engine configuration
@@ -62,10 +73,13 @@ func DefaultEngineConfig() *EngineConfig {
 	return &EngineConfig{
 		Context:         Context2D,
 		TargetFPS:       60,
+		UpdateRate:      60,
+		MaxUpdatesPerFrame: DefaultMaxUpdatesPerFrame,
 		AutoDetect:      true,
 		PerformanceLevel: PerformanceAdaptive,
 		EnableDebug:     false,
 		EnableStats:     false,
+		CameraControls:  CameraControlsNone,
 	}
 }
 
@@ -88,12 +102,30 @@ type Engine struct {
 	running        bool
 	paused         bool
 	
-	// Render loop
-	renderCallback func(float64)
+	// Fixed-step simulation callback
+	updateCallback func(float64)
+	
+	// Render callback, called with the frame delta and interpolation factor
+	renderCallback func(float64, float64)
+	
+	// Accumulates frame time into fixed updates
+	timestep       *FixedTimestep
+	
+	// Serializes frames between the loop and Step
+	frameMutex     sync.Mutex
+	
+	// Called with the latest stats after every frame
+	statsCallback  func(*EngineStats)
 	
 	// Performance throttling
 	throttleLevel  float64
 	
+	// Scales rendering quality when the performance level is adaptive
+	quality        *QualityScaler
+	
+	// Called with the new quality whenever it changes
+	qualityCallback func(Quality)
+	
 	// Mutex for thread safety
 	mutex          sync.RWMutex
 	
@@ -103,13 +135,20 @@ type Engine struct {
 
 // EngineStats represents engine performance statistics
 type EngineStats struct {
-	FPS            float64
-	FrameTime      float64
-	DrawCalls      int
-	Triangles      int
-	Textures       int
-	ShaderSwitches int
-	MemoryUsage    float64
+	FPS                     float64
+	FrameTime               float64
+	DrawCalls               int
+	DrawCallsBeforeBatching int
+	CulledObjects           int
+	Triangles               int
+	Textures                int
+	ShaderSwitches          int
+	MemoryUsage             float64
+	
+	// The rendering quality, and the times it has changed
+	QualityLevel            int
+	Quality                 Quality
+	QualityChanges          int
 }
 
 // NewEngine creates a new engine instance
@@ -118,12 +157,23 @@ func NewEngine(config *EngineConfig) *Engine {
 		config = DefaultEngineConfig()
 	}
 	
+	updateRate := config.UpdateRate
+	if updateRate <= 0 {
+		updateRate = config.TargetFPS
+	}
+	timestep := NewFixedTimestep(updateRate)
+	if config.MaxUpdatesPerFrame > 0 {
+		timestep.MaxUpdates = config.MaxUpdatesPerFrame
+	}
+	
 	engine := &Engine{
 		Config:        config,
+		timestep:      timestep,
 		currentContext: config.Context,
 		lastFrameTime: time.Now(),
 		fpsUpdateTime: time.Now(),
 		throttleLevel: 1.0,
+		quality:       NewQualityScaler(nil),
 		stats:         &EngineStats{},
 	}
 	
@@ -177,6 +227,11 @@ func (e *Engine) Pause() {
 
 // Resume resumes the engine
 func (e *Engine) Resume() {
+	// Time spent paused is not simulated
+	e.frameMutex.Lock()
+	e.timestep.Reset()
+	e.frameMutex.Unlock()
+	
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
 	
@@ -184,14 +239,100 @@ func (e *Engine) Resume() {
 	e.lastFrameTime = time.Now()
 }
 
-// SetRenderCallback sets the render callback function
+// Step advances a paused engine by exactly one fixed update and renders the
+// result. It must not be called from an update or render callback.
+func (e *Engine) Step() {
+	e.mutex.RLock()
+	update := e.updateCallback
+	render := e.renderCallback
+	e.mutex.RUnlock()
+	
+	e.frameMutex.Lock()
+	defer e.frameMutex.Unlock()
+	
+	e.timestep.StepOnce(update)
+	if render != nil {
+		render(e.timestep.Step.Seconds(), 0)
+	}
+}
+
+// SetUpdateCallback sets the function called for every fixed simulation update
+// with the fixed step length in seconds
+func (e *Engine) SetUpdateCallback(callback func(float64)) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	
+	e.updateCallback = callback
+}
+
+// SetRenderCallback sets the render callback function, called once per frame
+// with the time since the previous frame
 func (e *Engine) SetRenderCallback(callback func(float64)) {
+	if callback == nil {
+		e.SetInterpolatedRenderCallback(nil)
+		return
+	}
+	
+	e.SetInterpolatedRenderCallback(func(deltaTime, alpha float64) {
+		callback(deltaTime)
+	})
+}
+
+// SetInterpolatedRenderCallback sets the render callback function. alpha is how
+// far the frame lies between the last two fixed updates, for interpolating
+// simulated state.
+func (e *Engine) SetInterpolatedRenderCallback(callback func(deltaTime, alpha float64)) {
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
 	
 	e.renderCallback = callback
 }
 
+// SetStatsCallback sets a function called with the engine statistics after every frame
+func (e *Engine) SetStatsCallback(callback func(*EngineStats)) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	
+	e.statsCallback = callback
+}
+
+// SetQualityCallback sets a function called with the new rendering quality
+// whenever it changes, and once with the current quality. Renderers apply
+// it to their resolution, shadows and particle counts.
+func (e *Engine) SetQualityCallback(callback func(Quality)) {
+	e.mutex.Lock()
+	e.qualityCallback = callback
+	e.mutex.Unlock()
+	
+	if callback != nil {
+		_, quality := e.quality.Level()
+		callback(quality)
+	}
+}
+
+// SetQualityLevels replaces the quality levels, from the best to the
+// cheapest, and returns to the best
+func (e *Engine) SetQualityLevels(levels []Quality) {
+	e.mutex.Lock()
+	e.quality = NewQualityScaler(levels)
+	callback := e.qualityCallback
+	e.mutex.Unlock()
+	
+	if callback != nil {
+		callback(levels[0])
+	}
+}
+
+// GetQuality gets the current rendering quality
+func (e *Engine) GetQuality() Quality {
+	e.mutex.RLock()
+	quality := e.quality
+	e.mutex.RUnlock()
+	
+	_, current := quality.Level()
+	return current
+}
+
 // SetContext sets the rendering context
 func (e *Engine) SetContext(context RenderingContext) {
 	e.mutex.Lock()
@@ -211,6 +352,19 @@ func (e *Engine) GetContext() RenderingContext {
 // SetPerformanceLevel sets the performance level
 func (e *Engine) SetPerformanceLevel(level PerformanceLevel) {
 	e.mutex.Lock()
+	
+	// Only the adaptive level scales quality; the others render at the best
+	reset := e.Config.PerformanceLevel == PerformanceAdaptive && level != PerformanceAdaptive
+	callback := e.qualityCallback
+	if reset {
+		best := e.quality.Reset()
+		// Runs after the unlock deferred below
+		defer func() {
+			if callback != nil {
+				callback(best)
+			}
+		}()
+	}
 	defer e.mutex.Unlock()
 	
 	e.Config.PerformanceLevel = level
@@ -238,39 +392,52 @@ func (e *Engine) GetStats() *EngineStats {
 	e.mutex.RLock()
 	defer e.mutex.RUnlock()
 	
+	level, quality := e.quality.Level()
 	return &EngineStats{
-		FPS:            e.fps,
-		FrameTime:      e.frameTime,
-		DrawCalls:      e.stats.DrawCalls,
-		Triangles:      e.stats.Triangles,
-		Textures:       e.stats.Textures,
-		ShaderSwitches: e.stats.ShaderSwitches,
-		MemoryUsage:    e.stats.MemoryUsage,
+		FPS:                     e.fps,
+		FrameTime:               e.frameTime,
+		DrawCalls:               e.stats.DrawCalls,
+		DrawCallsBeforeBatching: e.stats.DrawCallsBeforeBatching,
+		CulledObjects:           e.stats.CulledObjects,
+		Triangles:               e.stats.Triangles,
+		Textures:                e.stats.Textures,
+		ShaderSwitches:          e.stats.ShaderSwitches,
+		MemoryUsage:             e.stats.MemoryUsage,
+		QualityLevel:            level,
+		Quality:                 quality,
+		QualityChanges:          e.quality.Changes(),
 	}
 }
 
-// renderLoop is the main render loop
+// renderLoop is the main render loop. Each frame runs as many fixed updates
+// as the elapsed time allows, then renders once with the interpolation factor.
 func (e *Engine) renderLoop() {
 	for {
 		e.mutex.RLock()
 		running := e.running
 		paused := e.paused
-		callback := e.renderCallback
+		update := e.updateCallback
+		render := e.renderCallback
+		statsCallback := e.statsCallback
 		targetFPS := e.Config.TargetFPS
+		adaptive := e.Config.PerformanceLevel == PerformanceAdaptive
+		quality := e.quality
+		qualityCallback := e.qualityCallback
+		lastFrameTime := e.lastFrameTime
 		e.mutex.RUnlock()
 		
 		if !running {
 			break
 		}
 		
-		if paused || callback == nil {
+		if paused || (render == nil && update == nil) {
 			time.Sleep(100 * time.Millisecond)
 			continue
 		}
 		
 		// Calculate delta time
 		now := time.Now()
-		deltaTime := now.Sub(e.lastFrameTime).Seconds()
+		elapsed := now.Sub(lastFrameTime)
 		
 		// Update FPS counter
 		e.frameCount++
@@ -282,8 +449,13 @@ func (e *Engine) renderLoop() {
 			e.mutex.Unlock()
 		}
 		
-		// Call render callback
-		callback(deltaTime)
+		// Run fixed updates, then render
+		e.frameMutex.Lock()
+		alpha := e.timestep.Advance(elapsed, update)
+		if render != nil {
+			render(elapsed.Seconds(), alpha)
+		}
+		e.frameMutex.Unlock()
 		
 		// Update frame time
 		frameEnd := time.Now()
@@ -293,6 +465,22 @@ func (e *Engine) renderLoop() {
 		e.mutex.Unlock()
 		
 		// Throttle to target FPS
+		if targetFPS <= 0 {
+			targetFPS = 60
+		}
+		
+		// Lower the quality when frames overrun their budget
+		if adaptive {
+			budget := time.Second / time.Duration(targetFPS)
+			if current, changed := quality.Observe(frameEnd.Sub(now), budget); changed && qualityCallback != nil {
+				qualityCallback(current)
+			}
+		}
+		
+		if statsCallback != nil {
+			statsCallback(e.GetStats())
+		}
+		
 		targetFrameTime := 1.0 / float64(targetFPS)
 		actualFrameTime := frameEnd.Sub(now).Seconds()
 		if actualFrameTime < targetFrameTime {
@@ -326,6 +514,17 @@ func (e *Engine) GetFPS() float64 {
 	return e.fps
 }
 
+// Tick returns the number of fixed updates run so far. It is safe to call from
+// update and render callbacks.
+func (e *Engine) Tick() uint64 {
+	return e.timestep.Tick()
+}
+
+// SimulationTime returns the simulated time in seconds
+func (e *Engine) SimulationTime() float64 {
+	return e.timestep.SimulationTime()
+}
+
 // GetFrameTime gets the current frame time in milliseconds
 func (e *Engine) GetFrameTime() float64 {
 	e.mutex.RLock()
@@ -344,4 +543,13 @@ func (e *Engine) UpdateStats(drawCalls, triangles, textures, shaderSwitches int,
 	e.stats.Textures = textures
 	e.stats.ShaderSwitches = shaderSwitches
 	e.stats.MemoryUsage = memoryUsage
-}
\ No newline at end of file
+}
+
+// UpdateDrawStats records the draw calls needed before batching and the number of culled objects
+func (e *Engine) UpdateDrawStats(drawCallsBeforeBatching, culledObjects int) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	
+	e.stats.DrawCallsBeforeBatching = drawCallsBeforeBatching
+	e.stats.CulledObjects = culledObjects
+}
@@ -0,0 +1,724 @@
+package engine
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strings"
+)
+
+// fbxMagic starts every binary FBX file
+const fbxMagic = "Kaydara FBX Binary  \x00\x1a\x00"
+
+// fbxNode is a node record of a binary FBX file
+type fbxNode struct {
+	Name       string
+	Properties []interface{}
+	Children   []*fbxNode
+}
+
+// child returns the first child with the given name, or nil
+func (n *fbxNode) child(name string) *fbxNode {
+	for _, c := range n.Children {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// childValue returns the first property of a named child, or nil
+func (n *fbxNode) childValue(name string) interface{} {
+	if c := n.child(name); c != nil && len(c.Properties) > 0 {
+		return c.Properties[0]
+	}
+	return nil
+}
+
+// id returns the object ID of an Objects entry
+func (n *fbxNode) id() int64 {
+	if len(n.Properties) > 0 {
+		if id, ok := n.Properties[0].(int64); ok {
+			return id
+		}
+	}
+	return 0
+}
+
+// objectName returns the display name of an Objects entry ("Name\x00\x01Class")
+func (n *fbxNode) objectName() string {
+	if len(n.Properties) > 1 {
+		if name, ok := n.Properties[1].(string); ok {
+			if i := strings.Index(name, "\x00\x01"); i >= 0 {
+				return name[:i]
+			}
+			return name
+		}
+	}
+	return ""
+}
+
+// property70 returns the numeric values of a Properties70 entry
+func (n *fbxNode) property70(name string) []float64 {
+	properties := n.child("Properties70")
+	if properties == nil {
+		return nil
+	}
+	for _, p := range properties.Children {
+		if p.Name != "P" || len(p.Properties) < 5 {
+			continue
+		}
+		if key, _ := p.Properties[0].(string); key != name {
+			continue
+		}
+		var values []float64
+		for _, value := range p.Properties[4:] {
+			if f, ok := fbxFloat(value); ok {
+				values = append(values, f)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// fbxFloat converts a scalar property to float64
+func fbxFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// fbxFloats converts an array property to []float64
+func fbxFloats(value interface{}) []float64 {
+	switch v := value.(type) {
+	case []float64:
+		return v
+	case []float32:
+		out := make([]float64, len(v))
+		for i, f := range v {
+			out[i] = float64(f)
+		}
+		return out
+	}
+	return nil
+}
+
+// fbxInts converts an array property to []int
+func fbxInts(value interface{}) []int {
+	switch v := value.(type) {
+	case []int32:
+		out := make([]int, len(v))
+		for i, n := range v {
+			out[i] = int(n)
+		}
+		return out
+	case []int64:
+		out := make([]int, len(v))
+		for i, n := range v {
+			out[i] = int(n)
+		}
+		return out
+	}
+	return nil
+}
+
+// fbxReader decodes the node records of a binary FBX file
+type fbxReader struct {
+	data    []byte
+	offset  int
+	version uint32
+}
+
+func (r *fbxReader) need(n int) error {
+	if n < 0 || r.offset+n > len(r.data) {
+		return errors.New("FBX file is truncated")
+	}
+	return nil
+}
+
+func (r *fbxReader) uint32() (uint32, error) {
+	if err := r.need(4); err != nil {
+		return 0, err
+	}
+	v := binary.LittleEndian.Uint32(r.data[r.offset:])
+	r.offset += 4
+	return v, nil
+}
+
+// offsetValue reads a record header field, 64-bit from version 7500
+func (r *fbxReader) offsetValue() (uint64, error) {
+	if r.version >= 7500 {
+		if err := r.need(8); err != nil {
+			return 0, err
+		}
+		v := binary.LittleEndian.Uint64(r.data[r.offset:])
+		r.offset += 8
+		return v, nil
+	}
+	v, err := r.uint32()
+	return uint64(v), err
+}
+
+// readNode reads one node record; it returns nil at a list terminator
+func (r *fbxReader) readNode() (*fbxNode, error) {
+	endOffset, err := r.offsetValue()
+	if err != nil {
+		return nil, err
+	}
+	numProperties, err := r.offsetValue()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.offsetValue(); err != nil {
+		return nil, err
+	}
+	if err := r.need(1); err != nil {
+		return nil, err
+	}
+	nameLength := int(r.data[r.offset])
+	r.offset++
+
+	if endOffset == 0 {
+		return nil, nil
+	}
+	if endOffset > uint64(len(r.data)) {
+		return nil, errors.New("FBX node extends past the end of the file")
+	}
+
+	if err := r.need(nameLength); err != nil {
+		return nil, err
+	}
+	node := &fbxNode{Name: string(r.data[r.offset : r.offset+nameLength])}
+	r.offset += nameLength
+
+	for i := uint64(0); i < numProperties; i++ {
+		property, err := r.readProperty()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", node.Name, err)
+		}
+		node.Properties = append(node.Properties, property)
+	}
+
+	for uint64(r.offset) < endOffset {
+		child, err := r.readNode()
+		if err != nil {
+			return nil, err
+		}
+		if child == nil {
+			break
+		}
+		node.Children = append(node.Children, child)
+	}
+	r.offset = int(endOffset)
+
+	return node, nil
+}
+
+// readProperty reads a single typed property value
+func (r *fbxReader) readProperty() (interface{}, error) {
+	if err := r.need(1); err != nil {
+		return nil, err
+	}
+	kind := r.data[r.offset]
+	r.offset++
+
+	scalar := func(size int) ([]byte, error) {
+		if err := r.need(size); err != nil {
+			return nil, err
+		}
+		b := r.data[r.offset : r.offset+size]
+		r.offset += size
+		return b, nil
+	}
+
+	switch kind {
+	case 'Y':
+		b, err := scalar(2)
+		if err != nil {
+			return nil, err
+		}
+		return int16(binary.LittleEndian.Uint16(b)), nil
+	case 'C':
+		b, err := scalar(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case 'I':
+		b, err := scalar(4)
+		if err != nil {
+			return nil, err
+		}
+		return int32(binary.LittleEndian.Uint32(b)), nil
+	case 'F':
+		b, err := scalar(4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
+	case 'D':
+		b, err := scalar(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	case 'L':
+		b, err := scalar(8)
+		if err != nil {
+			return nil, err
+		}
+		return int64(binary.LittleEndian.Uint64(b)), nil
+	case 'S', 'R':
+		length, err := r.uint32()
+		if err != nil {
+			return nil, err
+		}
+		b, err := scalar(int(length))
+		if err != nil {
+			return nil, err
+		}
+		if kind == 'S' {
+			return string(b), nil
+		}
+		return append([]byte(nil), b...), nil
+	case 'f', 'd', 'l', 'i', 'b':
+		return r.readArray(kind)
+	}
+
+	return nil, fmt.Errorf("unknown property type %q", kind)
+}
+
+// readArray reads an array property, inflating it when compressed
+func (r *fbxReader) readArray(kind byte) (interface{}, error) {
+	length, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	encoding, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	compressedLength, err := r.uint32()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.need(int(compressedLength)); err != nil {
+		return nil, err
+	}
+	raw := r.data[r.offset : r.offset+int(compressedLength)]
+	r.offset += int(compressedLength)
+
+	size := map[byte]int{'f': 4, 'd': 8, 'l': 8, 'i': 4, 'b': 1}[kind]
+
+	if encoding == 1 {
+		inflater, err := zlib.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, err
+		}
+		raw, err = ioutil.ReadAll(io.LimitReader(inflater, int64(length)*int64(size)))
+		inflater.Close()
+		if err != nil {
+			return nil, err
+		}
+	} else if encoding != 0 {
+		return nil, fmt.Errorf("unknown array encoding %d", encoding)
+	}
+
+	if len(raw) < int(length)*size {
+		return nil, errors.New("FBX array is truncated")
+	}
+
+	switch kind {
+	case 'f':
+		values := make([]float32, length)
+		for i := range values {
+			values[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:]))
+		}
+		return values, nil
+	case 'd':
+		values := make([]float64, length)
+		for i := range values {
+			values[i] = math.Float64frombits(binary.LittleEndian.Uint64(raw[i*8:]))
+		}
+		return values, nil
+	case 'l':
+		values := make([]int64, length)
+		for i := range values {
+			values[i] = int64(binary.LittleEndian.Uint64(raw[i*8:]))
+		}
+		return values, nil
+	case 'i':
+		values := make([]int32, length)
+		for i := range values {
+			values[i] = int32(binary.LittleEndian.Uint32(raw[i*4:]))
+		}
+		return values, nil
+	default:
+		values := make([]bool, length)
+		for i := range values {
+			values[i] = raw[i] != 0
+		}
+		return values, nil
+	}
+}
+
+// ParseFBX imports the meshes of a binary FBX file. Model transforms are baked
+// into the vertices and each material becomes a submesh; animation, skinning
+// and textures are dropped with a warning. ASCII FBX is not supported.
+func ParseFBX(r io.Reader, name string) (*Model, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(data, []byte(fbxMagic)) {
+		if bytes.Contains(data[:minInt(len(data), 1024)], []byte("FBXHeaderExtension")) {
+			return nil, errors.New("ASCII FBX is not supported, re-export the model as binary FBX")
+		}
+		return nil, errors.New("not an FBX file")
+	}
+
+	reader := &fbxReader{data: data, offset: len(fbxMagic)}
+	if reader.version, err = reader.uint32(); err != nil {
+		return nil, err
+	}
+
+	root := &fbxNode{}
+	for reader.offset < len(data) {
+		node, err := reader.readNode()
+		if err != nil {
+			return nil, err
+		}
+		if node == nil {
+			break
+		}
+		root.Children = append(root.Children, node)
+	}
+
+	return buildFBXModel(root, name)
+}
+
+// minInt returns the smaller of two ints
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// buildFBXModel converts the decoded FBX document into a model
+func buildFBXModel(root *fbxNode, name string) (*Model, error) {
+	model := &Model{Name: name, Mesh: &Mesh{ID: name, Name: name}}
+
+	objects := root.child("Objects")
+	if objects == nil {
+		return nil, errors.New("FBX file has no Objects section")
+	}
+
+	if settings := root.child("GlobalSettings"); settings != nil {
+		if scale := settings.property70("UnitScaleFactor"); len(scale) > 0 && scale[0] != 1 {
+			model.warn("unit scale factor %g was not applied", scale[0])
+		}
+	}
+
+	byID := make(map[int64]*fbxNode)
+	for _, object := range objects.Children {
+		byID[object.id()] = object
+
+		switch object.Name {
+		case "AnimationStack":
+			model.warn("animations were dropped")
+		case "Deformer":
+			model.warn("skinning and blend shapes were dropped")
+		case "Texture":
+			model.warn("textures are not imported from FBX, reassign them after conversion")
+		}
+	}
+
+	// Object-to-object connections: child -> parents in connection order
+	parents := make(map[int64][]int64)
+	children := make(map[int64][]int64)
+	if connections := root.child("Connections"); connections != nil {
+		for _, c := range connections.Children {
+			if c.Name != "C" || len(c.Properties) < 3 {
+				continue
+			}
+			if kind, _ := c.Properties[0].(string); kind != "OO" {
+				continue
+			}
+			child, _ := c.Properties[1].(int64)
+			parent, _ := c.Properties[2].(int64)
+			parents[child] = append(parents[child], parent)
+			children[parent] = append(children[parent], child)
+		}
+	}
+
+	// worldMatrix combines a model's transform with its parent models
+	var worldMatrix func(id int64, depth int) [16]float64
+	worldMatrix = func(id int64, depth int) [16]float64 {
+		node := byID[id]
+		if node == nil || node.Name != "Model" || depth > 64 {
+			return mat4Identity()
+		}
+
+		position := [3]float64{}
+		rotation := [3]float64{}
+		scale := [3]float64{1, 1, 1}
+		if v := node.property70("Lcl Translation"); len(v) == 3 {
+			copy(position[:], v)
+		}
+		if v := node.property70("Lcl Rotation"); len(v) == 3 {
+			rotation = [3]float64{v[0] * math.Pi / 180, v[1] * math.Pi / 180, v[2] * math.Pi / 180}
+		}
+		if v := node.property70("Lcl Scaling"); len(v) == 3 {
+			copy(scale[:], v)
+		}
+		local := composeMatrix(position, rotation, scale)
+
+		for _, parent := range parents[id] {
+			if p := byID[parent]; p != nil && p.Name == "Model" {
+				return mat4Multiply(worldMatrix(parent, depth+1), local)
+			}
+		}
+		return local
+	}
+
+	submeshes := make(map[int64]int)
+	submeshFor := func(materialID int64) int {
+		if index, ok := submeshes[materialID]; ok {
+			return index
+		}
+
+		var material *Material
+		if node := byID[materialID]; node != nil {
+			materialName := node.objectName()
+			if materialName == "" {
+				materialName = fmt.Sprintf("material-%d", len(model.Materials))
+			}
+			material = NewPBRMaterial(materialName, materialName, [4]float64{1, 1, 1, 1}, 0, 0.5)
+			if color := node.property70("DiffuseColor"); len(color) == 3 {
+				material.BaseColor = [4]float64{color[0], color[1], color[2], 1}
+			}
+			if color := node.property70("EmissiveColor"); len(color) == 3 {
+				material.EmissiveColor = [3]float64{color[0], color[1], color[2]}
+			}
+			if opacity := node.property70("Opacity"); len(opacity) == 1 && opacity[0] < 1 {
+				material.BaseColor[3] = opacity[0]
+				material.SetAlphaMode(AlphaBlend)
+			}
+			model.warn("FBX materials were approximated as PBR")
+		} else {
+			material = NewPBRMaterial("default", "default", [4]float64{1, 1, 1, 1}, 0, 0.5)
+		}
+
+		index := len(model.Materials)
+		submeshes[materialID] = index
+		model.Materials = append(model.Materials, material)
+		model.Mesh.Submeshes = append(model.Mesh.Submeshes, nil)
+		return index
+	}
+
+	for _, object := range objects.Children {
+		if object.Name != "Geometry" {
+			continue
+		}
+		if len(object.Properties) > 2 {
+			if class, _ := object.Properties[2].(string); class != "Mesh" {
+				continue
+			}
+		}
+
+		// Instance the geometry once for every model using it
+		for _, parent := range parents[object.id()] {
+			modelNode := byID[parent]
+			if modelNode == nil || modelNode.Name != "Model" {
+				continue
+			}
+
+			var materials []int64
+			for _, child := range children[parent] {
+				if node := byID[child]; node != nil && node.Name == "Material" {
+					materials = append(materials, child)
+				}
+			}
+
+			if err := addFBXGeometry(model, object, worldMatrix(parent, 0), materials, submeshFor); err != nil {
+				return nil, fmt.Errorf("geometry %s: %v", object.objectName(), err)
+			}
+		}
+	}
+
+	if len(model.Mesh.Vertices) == 0 {
+		return nil, errors.New("FBX file contains no mesh geometry")
+	}
+
+	model.Mesh.ComputeBounds()
+
+	return model, nil
+}
+
+// fbxLayer resolves the value index of a layer element for a polygon vertex
+type fbxLayer struct {
+	mapping   string
+	reference string
+	indices   []int
+}
+
+func newFBXLayer(element *fbxNode, indexName string) fbxLayer {
+	layer := fbxLayer{}
+	layer.mapping, _ = element.childValue("MappingInformationType").(string)
+	layer.reference, _ = element.childValue("ReferenceInformationType").(string)
+	if indexName != "" {
+		layer.indices = fbxInts(element.childValue(indexName))
+	}
+	return layer
+}
+
+// index returns the value index for polygon vertex pv of control point cp in polygon p
+func (l fbxLayer) index(pv, cp, p int) int {
+	var i int
+	switch l.mapping {
+	case "ByPolygonVertex":
+		i = pv
+	case "ByPolygon":
+		i = p
+	case "AllSame":
+		i = 0
+	default: // ByControlPoint, ByVertice
+		i = cp
+	}
+	if l.reference == "IndexToDirect" || l.reference == "Index" {
+		if i < 0 || i >= len(l.indices) {
+			return -1
+		}
+		return l.indices[i]
+	}
+	return i
+}
+
+// addFBXGeometry appends a Geometry node, unwelding vertices per polygon corner
+func addFBXGeometry(model *Model, geometry *fbxNode, world [16]float64, materials []int64, submeshFor func(int64) int) error {
+	positions := fbxFloats(geometry.childValue("Vertices"))
+	polygonVertices := fbxInts(geometry.childValue("PolygonVertexIndex"))
+	if len(positions) == 0 || len(polygonVertices) == 0 {
+		return nil
+	}
+
+	var normals, uvs []float64
+	var normalLayer, uvLayer, materialLayer fbxLayer
+	var materialIndices []int
+
+	if element := geometry.child("LayerElementNormal"); element != nil {
+		normals = fbxFloats(element.childValue("Normals"))
+		normalLayer = newFBXLayer(element, "NormalsIndex")
+	}
+	if element := geometry.child("LayerElementUV"); element != nil {
+		uvs = fbxFloats(element.childValue("UV"))
+		uvLayer = newFBXLayer(element, "UVIndex")
+	}
+	if element := geometry.child("LayerElementMaterial"); element != nil {
+		materialLayer = newFBXLayer(element, "")
+		materialIndices = fbxInts(element.childValue("Materials"))
+	}
+	uvSets := 0
+	for _, c := range geometry.Children {
+		switch c.Name {
+		case "LayerElementUV":
+			uvSets++
+		case "LayerElementColor":
+			model.warn("vertex colors were dropped")
+		}
+	}
+	if uvSets > 1 {
+		model.warn("only the first UV set was imported")
+	}
+
+	mesh := model.Mesh
+	var polygon []int
+	polygonIndex := 0
+
+	for pv, raw := range polygonVertices {
+		end := raw < 0
+		cp := raw
+		if end {
+			cp = -raw - 1
+		}
+		if cp*3+2 >= len(positions) {
+			return fmt.Errorf("control point %d out of range", cp)
+		}
+
+		mesh.Vertices = append(mesh.Vertices, mat4TransformPoint(world, [3]float64{positions[cp*3], positions[cp*3+1], positions[cp*3+2]}))
+
+		if normals != nil {
+			normal := [3]float64{}
+			if i := normalLayer.index(pv, cp, polygonIndex); i >= 0 && i*3+2 < len(normals) {
+				normal = vec3Normalize(mat4TransformDirection(world, [3]float64{normals[i*3], normals[i*3+1], normals[i*3+2]}))
+			}
+			padVec3(&mesh.Normals, len(mesh.Vertices)-1)
+			mesh.Normals = append(mesh.Normals, normal)
+		}
+		if uvs != nil {
+			uv := [2]float64{}
+			if i := uvLayer.index(pv, cp, polygonIndex); i >= 0 && i*2+1 < len(uvs) {
+				uv = [2]float64{uvs[i*2], uvs[i*2+1]}
+			}
+			for len(mesh.UVs) < len(mesh.Vertices)-1 {
+				mesh.UVs = append(mesh.UVs, [2]float64{})
+			}
+			mesh.UVs = append(mesh.UVs, uv)
+		}
+
+		polygon = append(polygon, len(mesh.Vertices)-1)
+		if !end {
+			continue
+		}
+
+		var materialID int64
+		if materialIndices != nil {
+			if i := materialLayer.index(pv, cp, polygonIndex); i >= 0 && i < len(materialIndices) {
+				if m := materialIndices[i]; m >= 0 && m < len(materials) {
+					materialID = materials[m]
+				}
+			}
+		} else if len(materials) > 0 {
+			materialID = materials[0]
+		}
+		submesh := submeshFor(materialID)
+
+		// Fan-triangulate the polygon
+		for i := 1; i+1 < len(polygon); i++ {
+			triangle := []int{polygon[0], polygon[i], polygon[i+1]}
+			mesh.Indices = append(mesh.Indices, triangle...)
+			mesh.Submeshes[submesh] = append(mesh.Submeshes[submesh], triangle...)
+		}
+
+		polygon = polygon[:0]
+		polygonIndex++
+	}
+
+	// Keep optional attributes aligned when only some geometries have them
+	if len(mesh.Normals) > 0 {
+		padVec3(&mesh.Normals, len(mesh.Vertices))
+	}
+	for len(mesh.UVs) > 0 && len(mesh.UVs) < len(mesh.Vertices) {
+		mesh.UVs = append(mesh.UVs, [2]float64{})
+	}
+
+	return nil
+}
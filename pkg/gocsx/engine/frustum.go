@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"math"
+)
+
+// Frustum is a view frustum described by six inward-facing planes
+type Frustum struct {
+	// Planes as (a, b, c, d) with a*x + b*y + c*z + d >= 0 inside,
+	// ordered left, right, bottom, top, near, far
+	Planes [6][4]float64
+}
+
+// NewFrustum extracts the frustum planes from a column-major view-projection matrix
+func NewFrustum(viewProjection [16]float64) *Frustum {
+	row := func(i int) [4]float64 {
+		return [4]float64{viewProjection[i], viewProjection[4+i], viewProjection[8+i], viewProjection[12+i]}
+	}
+	combine := func(a, b [4]float64, sign float64) [4]float64 {
+		return [4]float64{a[0] + sign*b[0], a[1] + sign*b[1], a[2] + sign*b[2], a[3] + sign*b[3]}
+	}
+
+	x, y, z, w := row(0), row(1), row(2), row(3)
+
+	frustum := &Frustum{
+		Planes: [6][4]float64{
+			combine(w, x, 1),
+			combine(w, x, -1),
+			combine(w, y, 1),
+			combine(w, y, -1),
+			combine(w, z, 1),
+			combine(w, z, -1),
+		},
+	}
+
+	// Normalize so distances are in world units
+	for i, plane := range frustum.Planes {
+		length := math.Sqrt(plane[0]*plane[0] + plane[1]*plane[1] + plane[2]*plane[2])
+		if length > 0 {
+			frustum.Planes[i] = [4]float64{plane[0] / length, plane[1] / length, plane[2] / length, plane[3] / length}
+		}
+	}
+
+	return frustum
+}
+
+// NewCameraFrustum returns the frustum of a camera's current matrices
+func NewCameraFrustum(camera *Camera) *Frustum {
+	return NewFrustum(mat4Multiply(camera.ProjectionMatrix, camera.ViewMatrix))
+}
+
+// IntersectsBounds reports whether an axis-aligned box (min x, min y, min z,
+// max x, max y, max z) is at least partially inside the frustum
+func (f *Frustum) IntersectsBounds(bounds [6]float64) bool {
+	for _, plane := range f.Planes {
+		// Test the corner furthest along the plane normal
+		x, y, z := bounds[0], bounds[1], bounds[2]
+		if plane[0] >= 0 {
+			x = bounds[3]
+		}
+		if plane[1] >= 0 {
+			y = bounds[4]
+		}
+		if plane[2] >= 0 {
+			z = bounds[5]
+		}
+
+		if plane[0]*x+plane[1]*y+plane[2]*z+plane[3] < 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ContainsPoint reports whether a point is inside the frustum
+func (f *Frustum) ContainsPoint(point [3]float64) bool {
+	for _, plane := range f.Planes {
+		if plane[0]*point[0]+plane[1]*point[1]+plane[2]*point[2]+plane[3] < 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WorldMatrix returns the object's local-to-world transform, including its parents.
+// Rotation is an XYZ Euler rotation in radians.
+func (o *SceneObject) WorldMatrix() [16]float64 {
+	matrix := composeMatrix(o.Position, o.Rotation, o.Scale)
+	for parent := o.Parent; parent != nil; parent = parent.Parent {
+		matrix = mat4Multiply(composeMatrix(parent.Position, parent.Rotation, parent.Scale), matrix)
+	}
+
+	return matrix
+}
+
+// WorldBounds returns the renderer's bounds in world space
+func (r *MeshRenderer) WorldBounds() [6]float64 {
+	bounds := r.Bounds
+	if r.Mesh != nil {
+		bounds = r.Mesh.Bounds
+	}
+
+	if r.Object == nil {
+		return bounds
+	}
+
+	return transformBounds(r.Object.WorldMatrix(), bounds)
+}
@@ -0,0 +1,972 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strings"
+)
+
+// glTF 2.0 constants
+const (
+	glbMagic     = 0x46546C67 // "glTF"
+	glbChunkJSON = 0x4E4F534A // "JSON"
+	glbChunkBIN  = 0x004E4942 // "BIN\x00"
+
+	gltfByte          = 5120
+	gltfUnsignedByte  = 5121
+	gltfShort         = 5122
+	gltfUnsignedShort = 5123
+	gltfUnsignedInt   = 5125
+	gltfFloat         = 5126
+
+	gltfArrayBuffer        = 34962
+	gltfElementArrayBuffer = 34963
+
+	gltfModeTriangles     = 4
+	gltfModeTriangleStrip = 5
+	gltfModeTriangleFan   = 6
+)
+
+// gltfDocument is the subset of the glTF 2.0 schema read and written by the engine
+type gltfDocument struct {
+	Asset              gltfAsset        `json:"asset"`
+	Scene              *int             `json:"scene,omitempty"`
+	Scenes             []gltfScene      `json:"scenes,omitempty"`
+	Nodes              []gltfNode       `json:"nodes,omitempty"`
+	Meshes             []gltfMesh       `json:"meshes,omitempty"`
+	Materials          []gltfMaterial   `json:"materials,omitempty"`
+	Textures           []gltfTexture    `json:"textures,omitempty"`
+	Images             []gltfImage      `json:"images,omitempty"`
+	Accessors          []gltfAccessor   `json:"accessors,omitempty"`
+	BufferViews        []gltfBufferView `json:"bufferViews,omitempty"`
+	Buffers            []gltfBuffer     `json:"buffers,omitempty"`
+	Animations         []interface{}    `json:"animations,omitempty"`
+	Skins              []interface{}    `json:"skins,omitempty"`
+	Cameras            []interface{}    `json:"cameras,omitempty"`
+	ExtensionsUsed     []string         `json:"extensionsUsed,omitempty"`
+	ExtensionsRequired []string         `json:"extensionsRequired,omitempty"`
+}
+
+type gltfAsset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator,omitempty"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Name        string      `json:"name,omitempty"`
+	Mesh        *int        `json:"mesh,omitempty"`
+	Children    []int       `json:"children,omitempty"`
+	Matrix      []float64   `json:"matrix,omitempty"`
+	Translation []float64   `json:"translation,omitempty"`
+	Rotation    []float64   `json:"rotation,omitempty"`
+	Scale       []float64   `json:"scale,omitempty"`
+	Skin        *int        `json:"skin,omitempty"`
+	Camera      *int        `json:"camera,omitempty"`
+	Weights     []float64   `json:"weights,omitempty"`
+	Extras      interface{} `json:"extras,omitempty"`
+}
+
+type gltfMesh struct {
+	Name       string          `json:"name,omitempty"`
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int   `json:"attributes"`
+	Indices    *int             `json:"indices,omitempty"`
+	Material   *int             `json:"material,omitempty"`
+	Mode       *int             `json:"mode,omitempty"`
+	Targets    []map[string]int `json:"targets,omitempty"`
+}
+
+type gltfTextureInfo struct {
+	Index    int `json:"index"`
+	TexCoord int `json:"texCoord,omitempty"`
+}
+
+type gltfPBR struct {
+	BaseColorFactor          []float64        `json:"baseColorFactor,omitempty"`
+	BaseColorTexture         *gltfTextureInfo `json:"baseColorTexture,omitempty"`
+	MetallicFactor           *float64         `json:"metallicFactor,omitempty"`
+	RoughnessFactor          *float64         `json:"roughnessFactor,omitempty"`
+	MetallicRoughnessTexture *gltfTextureInfo `json:"metallicRoughnessTexture,omitempty"`
+}
+
+type gltfMaterial struct {
+	Name                 string           `json:"name,omitempty"`
+	PBRMetallicRoughness *gltfPBR         `json:"pbrMetallicRoughness,omitempty"`
+	NormalTexture        *gltfTextureInfo `json:"normalTexture,omitempty"`
+	OcclusionTexture     *gltfTextureInfo `json:"occlusionTexture,omitempty"`
+	EmissiveTexture      *gltfTextureInfo `json:"emissiveTexture,omitempty"`
+	EmissiveFactor       []float64        `json:"emissiveFactor,omitempty"`
+	AlphaMode            string           `json:"alphaMode,omitempty"`
+	AlphaCutoff          *float64         `json:"alphaCutoff,omitempty"`
+	DoubleSided          bool             `json:"doubleSided,omitempty"`
+}
+
+type gltfTexture struct {
+	Source  *int `json:"source,omitempty"`
+	Sampler *int `json:"sampler,omitempty"`
+}
+
+type gltfImage struct {
+	URI        string `json:"uri,omitempty"`
+	MimeType   string `json:"mimeType,omitempty"`
+	BufferView *int   `json:"bufferView,omitempty"`
+}
+
+type gltfAccessor struct {
+	BufferView    *int        `json:"bufferView,omitempty"`
+	ByteOffset    int         `json:"byteOffset,omitempty"`
+	ComponentType int         `json:"componentType"`
+	Normalized    bool        `json:"normalized,omitempty"`
+	Count         int         `json:"count"`
+	Type          string      `json:"type"`
+	Min           []float64   `json:"min,omitempty"`
+	Max           []float64   `json:"max,omitempty"`
+	Sparse        interface{} `json:"sparse,omitempty"`
+}
+
+type gltfBufferView struct {
+	Buffer     int  `json:"buffer"`
+	ByteOffset int  `json:"byteOffset,omitempty"`
+	ByteLength int  `json:"byteLength"`
+	ByteStride int  `json:"byteStride,omitempty"`
+	Target     *int `json:"target,omitempty"`
+}
+
+type gltfBuffer struct {
+	URI        string `json:"uri,omitempty"`
+	ByteLength int    `json:"byteLength"`
+}
+
+// gltfComponents is the number of components for each accessor type
+var gltfComponents = map[string]int{"SCALAR": 1, "VEC2": 2, "VEC3": 3, "VEC4": 4, "MAT4": 16}
+
+// ParseGLTF reads a glTF 2.0 model from a .gltf or .glb stream. Node transforms
+// are baked into the vertices and primitives are grouped into submeshes by
+// material. open resolves external buffers and may be nil for self-contained files.
+func ParseGLTF(r io.Reader, name string, open func(uri string) (io.ReadCloser, error)) (*Model, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var jsonData, binChunk []byte
+	if len(data) >= 12 && binary.LittleEndian.Uint32(data) == glbMagic {
+		jsonData, binChunk, err = splitGLB(data)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		jsonData = data
+	}
+
+	doc := &gltfDocument{}
+	if err := json.Unmarshal(jsonData, doc); err != nil {
+		return nil, fmt.Errorf("invalid glTF: %v", err)
+	}
+	if !strings.HasPrefix(doc.Asset.Version, "2") {
+		return nil, fmt.Errorf("unsupported glTF version %q", doc.Asset.Version)
+	}
+	if len(doc.ExtensionsRequired) > 0 {
+		return nil, fmt.Errorf("glTF requires unsupported extensions: %s", strings.Join(doc.ExtensionsRequired, ", "))
+	}
+
+	model := &Model{Name: name, Mesh: &Mesh{ID: name, Name: name}}
+
+	for _, extension := range doc.ExtensionsUsed {
+		model.warn("extension %s is not supported and was ignored", extension)
+	}
+	if len(doc.Animations) > 0 {
+		model.warn("%d animation(s) were dropped", len(doc.Animations))
+	}
+	if len(doc.Skins) > 0 {
+		model.warn("skins were dropped, meshes are imported in bind pose")
+	}
+	if len(doc.Cameras) > 0 {
+		model.warn("cameras were dropped")
+	}
+
+	buffers, err := loadGLTFBuffers(doc, binChunk, open)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := &gltfReader{doc: doc, buffers: buffers, model: model, submeshes: make(map[int]int)}
+
+	// Walk the default scene, or every root node when there is none
+	var roots []int
+	switch {
+	case doc.Scene != nil && *doc.Scene < len(doc.Scenes):
+		roots = doc.Scenes[*doc.Scene].Nodes
+	case len(doc.Scenes) > 0:
+		roots = doc.Scenes[0].Nodes
+	default:
+		child := make(map[int]bool)
+		for _, node := range doc.Nodes {
+			for _, c := range node.Children {
+				child[c] = true
+			}
+		}
+		for i := range doc.Nodes {
+			if !child[i] {
+				roots = append(roots, i)
+			}
+		}
+	}
+
+	for _, root := range roots {
+		if err := reader.visit(root, mat4Identity(), 0); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(model.Mesh.Colors) > 0 && len(model.Mesh.Colors) != len(model.Mesh.Vertices) {
+		model.Mesh.Colors = nil
+		model.warn("vertex colors were present on only some primitives and were dropped")
+	}
+
+	model.Mesh.ComputeBounds()
+
+	return model, nil
+}
+
+// splitGLB returns the JSON and binary chunks of a GLB file
+func splitGLB(data []byte) ([]byte, []byte, error) {
+	if binary.LittleEndian.Uint32(data[4:]) != 2 {
+		return nil, nil, fmt.Errorf("unsupported GLB version %d", binary.LittleEndian.Uint32(data[4:]))
+	}
+
+	var jsonData, binChunk []byte
+	offset := 12
+	for offset+8 <= len(data) {
+		length := int(binary.LittleEndian.Uint32(data[offset:]))
+		chunkType := binary.LittleEndian.Uint32(data[offset+4:])
+		offset += 8
+		if offset+length > len(data) {
+			return nil, nil, errors.New("GLB chunk is truncated")
+		}
+
+		switch chunkType {
+		case glbChunkJSON:
+			jsonData = data[offset : offset+length]
+		case glbChunkBIN:
+			binChunk = data[offset : offset+length]
+		}
+		offset += length
+	}
+
+	if jsonData == nil {
+		return nil, nil, errors.New("GLB has no JSON chunk")
+	}
+
+	return jsonData, binChunk, nil
+}
+
+// loadGLTFBuffers resolves every buffer's contents
+func loadGLTFBuffers(doc *gltfDocument, binChunk []byte, open func(uri string) (io.ReadCloser, error)) ([][]byte, error) {
+	buffers := make([][]byte, len(doc.Buffers))
+
+	for i, buffer := range doc.Buffers {
+		switch {
+		case buffer.URI == "":
+			if i != 0 || binChunk == nil {
+				return nil, fmt.Errorf("buffer %d has no data", i)
+			}
+			buffers[i] = binChunk
+		case strings.HasPrefix(buffer.URI, "data:"):
+			comma := strings.Index(buffer.URI, ",")
+			if comma < 0 || !strings.Contains(buffer.URI[:comma], ";base64") {
+				return nil, fmt.Errorf("buffer %d has an unsupported data URI", i)
+			}
+			decoded, err := base64.StdEncoding.DecodeString(buffer.URI[comma+1:])
+			if err != nil {
+				return nil, fmt.Errorf("buffer %d: %v", i, err)
+			}
+			buffers[i] = decoded
+		default:
+			if open == nil {
+				return nil, fmt.Errorf("buffer %d references external file %s", i, buffer.URI)
+			}
+			file, err := open(buffer.URI)
+			if err != nil {
+				return nil, fmt.Errorf("buffer %d: %v", i, err)
+			}
+			contents, err := ioutil.ReadAll(file)
+			file.Close()
+			if err != nil {
+				return nil, fmt.Errorf("buffer %d: %v", i, err)
+			}
+			buffers[i] = contents
+		}
+
+		if len(buffers[i]) < buffer.ByteLength {
+			return nil, fmt.Errorf("buffer %d is shorter than its declared length", i)
+		}
+	}
+
+	return buffers, nil
+}
+
+// gltfReader accumulates glTF primitives into a model
+type gltfReader struct {
+	doc     *gltfDocument
+	buffers [][]byte
+	model   *Model
+
+	// Submesh index by glTF material index (-1 for primitives without one)
+	submeshes map[int]int
+}
+
+// visit adds a node and its children
+func (g *gltfReader) visit(index int, parent [16]float64, depth int) error {
+	if index < 0 || index >= len(g.doc.Nodes) {
+		return fmt.Errorf("node %d does not exist", index)
+	}
+	if depth > 64 {
+		return errors.New("node hierarchy is too deep or cyclic")
+	}
+
+	node := g.doc.Nodes[index]
+	world := mat4Multiply(parent, gltfNodeMatrix(node))
+
+	if node.Weights != nil {
+		g.model.warn("morph target weights were dropped")
+	}
+
+	if node.Mesh != nil {
+		if *node.Mesh < 0 || *node.Mesh >= len(g.doc.Meshes) {
+			return fmt.Errorf("node %d references missing mesh %d", index, *node.Mesh)
+		}
+		for _, primitive := range g.doc.Meshes[*node.Mesh].Primitives {
+			if err := g.addPrimitive(primitive, world); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, child := range node.Children {
+		if err := g.visit(child, world, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// gltfNodeMatrix returns a node's local transform
+func gltfNodeMatrix(node gltfNode) [16]float64 {
+	if len(node.Matrix) == 16 {
+		var m [16]float64
+		copy(m[:], node.Matrix)
+		return m
+	}
+
+	t := [3]float64{}
+	if len(node.Translation) == 3 {
+		copy(t[:], node.Translation)
+	}
+	s := [3]float64{1, 1, 1}
+	if len(node.Scale) == 3 {
+		copy(s[:], node.Scale)
+	}
+	q := [4]float64{0, 0, 0, 1}
+	if len(node.Rotation) == 4 {
+		copy(q[:], node.Rotation)
+	}
+
+	x, y, z, w := q[0], q[1], q[2], q[3]
+	return [16]float64{
+		(1 - 2*(y*y+z*z)) * s[0], 2 * (x*y + z*w) * s[0], 2 * (x*z - y*w) * s[0], 0,
+		2 * (x*y - z*w) * s[1], (1 - 2*(x*x+z*z)) * s[1], 2 * (y*z + x*w) * s[1], 0,
+		2 * (x*z + y*w) * s[2], 2 * (y*z - x*w) * s[2], (1 - 2*(x*x+y*y)) * s[2], 0,
+		t[0], t[1], t[2], 1,
+	}
+}
+
+// addPrimitive appends a primitive's vertices and triangles to the model
+func (g *gltfReader) addPrimitive(primitive gltfPrimitive, world [16]float64) error {
+	mode := gltfModeTriangles
+	if primitive.Mode != nil {
+		mode = *primitive.Mode
+	}
+	if mode != gltfModeTriangles && mode != gltfModeTriangleStrip && mode != gltfModeTriangleFan {
+		g.model.warn("point and line primitives are not supported and were dropped")
+		return nil
+	}
+	if len(primitive.Targets) > 0 {
+		g.model.warn("morph targets were dropped")
+	}
+	for attribute := range primitive.Attributes {
+		switch attribute {
+		case "POSITION", "NORMAL", "TEXCOORD_0", "COLOR_0":
+		default:
+			g.model.warn("vertex attribute %s was dropped", attribute)
+		}
+	}
+
+	positionIndex, ok := primitive.Attributes["POSITION"]
+	if !ok {
+		return errors.New("primitive has no POSITION attribute")
+	}
+	positions, err := g.readAccessor(positionIndex)
+	if err != nil {
+		return err
+	}
+
+	mesh := g.model.Mesh
+	base := len(mesh.Vertices)
+	for _, p := range positions {
+		mesh.Vertices = append(mesh.Vertices, mat4TransformPoint(world, [3]float64{p[0], p[1], p[2]}))
+	}
+
+	// Optional attributes are padded so every array stays aligned with the vertices
+	if index, ok := primitive.Attributes["NORMAL"]; ok {
+		normals, err := g.readAccessor(index)
+		if err != nil {
+			return err
+		}
+		padVec3(&mesh.Normals, base)
+		for _, n := range normals {
+			mesh.Normals = append(mesh.Normals, vec3Normalize(mat4TransformDirection(world, [3]float64{n[0], n[1], n[2]})))
+		}
+	} else if len(mesh.Normals) > 0 {
+		padVec3(&mesh.Normals, len(mesh.Vertices))
+	}
+
+	if index, ok := primitive.Attributes["TEXCOORD_0"]; ok {
+		uvs, err := g.readAccessor(index)
+		if err != nil {
+			return err
+		}
+		for len(mesh.UVs) < base {
+			mesh.UVs = append(mesh.UVs, [2]float64{})
+		}
+		for _, uv := range uvs {
+			mesh.UVs = append(mesh.UVs, [2]float64{uv[0], uv[1]})
+		}
+	} else if len(mesh.UVs) > 0 {
+		for len(mesh.UVs) < len(mesh.Vertices) {
+			mesh.UVs = append(mesh.UVs, [2]float64{})
+		}
+	}
+
+	if index, ok := primitive.Attributes["COLOR_0"]; ok {
+		colors, err := g.readAccessor(index)
+		if err != nil {
+			return err
+		}
+		for len(mesh.Colors) < base {
+			mesh.Colors = append(mesh.Colors, [4]float64{1, 1, 1, 1})
+		}
+		for _, c := range colors {
+			color := [4]float64{1, 1, 1, 1}
+			copy(color[:], c)
+			mesh.Colors = append(mesh.Colors, color)
+		}
+	} else if len(mesh.Colors) > 0 {
+		for len(mesh.Colors) < len(mesh.Vertices) {
+			mesh.Colors = append(mesh.Colors, [4]float64{1, 1, 1, 1})
+		}
+	}
+
+	// Indices, generated for non-indexed primitives
+	var indices []int
+	if primitive.Indices != nil {
+		values, err := g.readAccessor(*primitive.Indices)
+		if err != nil {
+			return err
+		}
+		for _, v := range values {
+			indices = append(indices, int(v[0]))
+		}
+	} else {
+		for i := range positions {
+			indices = append(indices, i)
+		}
+	}
+
+	var triangles []int
+	switch mode {
+	case gltfModeTriangleStrip:
+		for i := 0; i+2 < len(indices); i++ {
+			if i%2 == 0 {
+				triangles = append(triangles, indices[i], indices[i+1], indices[i+2])
+			} else {
+				triangles = append(triangles, indices[i+1], indices[i], indices[i+2])
+			}
+		}
+	case gltfModeTriangleFan:
+		for i := 1; i+1 < len(indices); i++ {
+			triangles = append(triangles, indices[0], indices[i], indices[i+1])
+		}
+	default:
+		triangles = indices[:len(indices)/3*3]
+	}
+
+	for i := range triangles {
+		if triangles[i] < 0 || triangles[i] >= len(positions) {
+			return fmt.Errorf("index %d out of range", triangles[i])
+		}
+		triangles[i] += base
+	}
+
+	materialIndex := -1
+	if primitive.Material != nil {
+		materialIndex = *primitive.Material
+	}
+	submesh, err := g.submesh(materialIndex)
+	if err != nil {
+		return err
+	}
+
+	mesh.Indices = append(mesh.Indices, triangles...)
+	mesh.Submeshes[submesh] = append(mesh.Submeshes[submesh], triangles...)
+
+	return nil
+}
+
+// padVec3 pads a vector array with zero vectors up to length n
+func padVec3(values *[][3]float64, n int) {
+	for len(*values) < n {
+		*values = append(*values, [3]float64{})
+	}
+}
+
+// submesh returns the submesh for a glTF material, creating it on first use
+func (g *gltfReader) submesh(materialIndex int) (int, error) {
+	if index, ok := g.submeshes[materialIndex]; ok {
+		return index, nil
+	}
+
+	var material *Material
+	if materialIndex < 0 {
+		material = NewPBRMaterial("default", "default", [4]float64{1, 1, 1, 1}, 1, 1)
+	} else {
+		if materialIndex >= len(g.doc.Materials) {
+			return 0, fmt.Errorf("material %d does not exist", materialIndex)
+		}
+		material = g.material(materialIndex)
+	}
+
+	index := len(g.model.Materials)
+	g.submeshes[materialIndex] = index
+	g.model.Materials = append(g.model.Materials, material)
+	g.model.Mesh.Submeshes = append(g.model.Mesh.Submeshes, nil)
+
+	return index, nil
+}
+
+// material converts a glTF material
+func (g *gltfReader) material(index int) *Material {
+	source := g.doc.Materials[index]
+
+	name := source.Name
+	if name == "" {
+		name = fmt.Sprintf("material-%d", index)
+	}
+
+	// glTF defaults: white, fully metallic and rough
+	material := NewPBRMaterial(name, name, [4]float64{1, 1, 1, 1}, 1, 1)
+
+	if pbr := source.PBRMetallicRoughness; pbr != nil {
+		if len(pbr.BaseColorFactor) == 4 {
+			copy(material.BaseColor[:], pbr.BaseColorFactor)
+		}
+		if pbr.MetallicFactor != nil {
+			material.Metallic = *pbr.MetallicFactor
+		}
+		if pbr.RoughnessFactor != nil {
+			material.Roughness = *pbr.RoughnessFactor
+		}
+		g.textureURI(material, TextureSlotBaseColor, pbr.BaseColorTexture)
+		g.textureURI(material, TextureSlotMetallicRoughness, pbr.MetallicRoughnessTexture)
+	}
+	g.textureURI(material, TextureSlotNormal, source.NormalTexture)
+	g.textureURI(material, TextureSlotOcclusion, source.OcclusionTexture)
+	g.textureURI(material, TextureSlotEmissive, source.EmissiveTexture)
+
+	if len(source.EmissiveFactor) == 3 {
+		copy(material.EmissiveColor[:], source.EmissiveFactor)
+	}
+
+	switch source.AlphaMode {
+	case "MASK":
+		material.SetAlphaMode(AlphaMask)
+	case "BLEND":
+		material.SetAlphaMode(AlphaBlend)
+	}
+	if source.AlphaCutoff != nil {
+		material.AlphaCutoff = *source.AlphaCutoff
+	}
+	material.DoubleSided = source.DoubleSided
+
+	return material
+}
+
+// textureURI records the image file referenced by a texture slot
+func (g *gltfReader) textureURI(material *Material, slot string, info *gltfTextureInfo) {
+	if info == nil {
+		return
+	}
+	if info.TexCoord != 0 {
+		g.model.warn("material %s: %s texture uses a second UV set, which was dropped", material.Name, slot)
+	}
+	if info.Index < 0 || info.Index >= len(g.doc.Textures) {
+		return
+	}
+
+	texture := g.doc.Textures[info.Index]
+	if texture.Source == nil || *texture.Source < 0 || *texture.Source >= len(g.doc.Images) {
+		return
+	}
+
+	image := g.doc.Images[*texture.Source]
+	if image.URI == "" || strings.HasPrefix(image.URI, "data:") {
+		g.model.warn("material %s: embedded %s image was not extracted", material.Name, slot)
+		return
+	}
+
+	SetTextureURI(material, slot, image.URI)
+}
+
+// readAccessor reads an accessor as float64 tuples
+func (g *gltfReader) readAccessor(index int) ([][]float64, error) {
+	if index < 0 || index >= len(g.doc.Accessors) {
+		return nil, fmt.Errorf("accessor %d does not exist", index)
+	}
+
+	accessor := g.doc.Accessors[index]
+	if accessor.Sparse != nil {
+		return nil, fmt.Errorf("accessor %d is sparse, which is not supported", index)
+	}
+
+	components, ok := gltfComponents[accessor.Type]
+	if !ok {
+		return nil, fmt.Errorf("accessor %d has unknown type %s", index, accessor.Type)
+	}
+
+	size := map[int]int{
+		gltfByte: 1, gltfUnsignedByte: 1, gltfShort: 2, gltfUnsignedShort: 2, gltfUnsignedInt: 4, gltfFloat: 4,
+	}[accessor.ComponentType]
+	if size == 0 {
+		return nil, fmt.Errorf("accessor %d has unknown component type %d", index, accessor.ComponentType)
+	}
+
+	values := make([][]float64, accessor.Count)
+
+	// Accessors without a buffer view are all zeros
+	if accessor.BufferView == nil {
+		for i := range values {
+			values[i] = make([]float64, components)
+		}
+		return values, nil
+	}
+
+	if *accessor.BufferView < 0 || *accessor.BufferView >= len(g.doc.BufferViews) {
+		return nil, fmt.Errorf("accessor %d references missing buffer view", index)
+	}
+	view := g.doc.BufferViews[*accessor.BufferView]
+	if view.Buffer < 0 || view.Buffer >= len(g.buffers) {
+		return nil, fmt.Errorf("buffer view references missing buffer %d", view.Buffer)
+	}
+	buffer := g.buffers[view.Buffer]
+
+	stride := view.ByteStride
+	if stride == 0 {
+		stride = size * components
+	}
+
+	start := view.ByteOffset + accessor.ByteOffset
+	end := start + stride*(accessor.Count-1) + size*components
+	if accessor.Count > 0 && (end > len(buffer) || end > view.ByteOffset+view.ByteLength) {
+		return nil, fmt.Errorf("accessor %d reads past the end of its buffer view", index)
+	}
+
+	for i := range values {
+		tuple := make([]float64, components)
+		for c := 0; c < components; c++ {
+			offset := start + i*stride + c*size
+			var value float64
+			switch accessor.ComponentType {
+			case gltfFloat:
+				value = float64(math.Float32frombits(binary.LittleEndian.Uint32(buffer[offset:])))
+			case gltfUnsignedInt:
+				value = float64(binary.LittleEndian.Uint32(buffer[offset:]))
+			case gltfUnsignedShort:
+				value = float64(binary.LittleEndian.Uint16(buffer[offset:]))
+				if accessor.Normalized {
+					value /= 65535
+				}
+			case gltfShort:
+				value = float64(int16(binary.LittleEndian.Uint16(buffer[offset:])))
+				if accessor.Normalized {
+					value = math.Max(value/32767, -1)
+				}
+			case gltfUnsignedByte:
+				value = float64(buffer[offset])
+				if accessor.Normalized {
+					value /= 255
+				}
+			case gltfByte:
+				value = float64(int8(buffer[offset]))
+				if accessor.Normalized {
+					value = math.Max(value/127, -1)
+				}
+			}
+			tuple[c] = value
+		}
+		values[i] = tuple
+	}
+
+	return values, nil
+}
+
+// gltfWriter builds the binary buffer and accessors for a glTF document
+type gltfWriter struct {
+	doc    *gltfDocument
+	buffer bytes.Buffer
+}
+
+// addAccessor appends float32 or uint32 data as a new buffer view and accessor
+func (w *gltfWriter) addAccessor(values [][]float64, accessorType string, componentType, target int, bounds bool) int {
+	// Buffer views start on 4-byte boundaries
+	for w.buffer.Len()%4 != 0 {
+		w.buffer.WriteByte(0)
+	}
+	offset := w.buffer.Len()
+
+	components := gltfComponents[accessorType]
+	var min, max []float64
+	if bounds {
+		min = make([]float64, components)
+		max = make([]float64, components)
+		for c := range min {
+			min[c] = math.Inf(1)
+			max[c] = math.Inf(-1)
+		}
+	}
+
+	var scratch [4]byte
+	for _, tuple := range values {
+		for c := 0; c < components; c++ {
+			if componentType == gltfFloat {
+				binary.LittleEndian.PutUint32(scratch[:], math.Float32bits(float32(tuple[c])))
+			} else {
+				binary.LittleEndian.PutUint32(scratch[:], uint32(tuple[c]))
+			}
+			w.buffer.Write(scratch[:])
+
+			if bounds {
+				// Bounds must match the stored float32 values
+				value := float64(float32(tuple[c]))
+				min[c] = math.Min(min[c], value)
+				max[c] = math.Max(max[c], value)
+			}
+		}
+	}
+
+	view := len(w.doc.BufferViews)
+	w.doc.BufferViews = append(w.doc.BufferViews, gltfBufferView{
+		Buffer:     0,
+		ByteOffset: offset,
+		ByteLength: w.buffer.Len() - offset,
+		Target:     &target,
+	})
+
+	w.doc.Accessors = append(w.doc.Accessors, gltfAccessor{
+		BufferView:    &view,
+		ComponentType: componentType,
+		Count:         len(values),
+		Type:          accessorType,
+		Min:           min,
+		Max:           max,
+	})
+
+	return len(w.doc.Accessors) - 1
+}
+
+// WriteGLTF writes a model as glTF 2.0. When binary is true the output is a
+// GLB file, otherwise a .gltf file with an embedded base64 buffer.
+func WriteGLTF(out io.Writer, model *Model, binaryOutput bool) error {
+	mesh := model.Mesh
+	if len(mesh.Vertices) == 0 {
+		return errors.New("model has no vertices")
+	}
+
+	zero := 0
+	w := &gltfWriter{doc: &gltfDocument{
+		Asset:  gltfAsset{Version: "2.0", Generator: "gocsx"},
+		Scene:  &zero,
+		Scenes: []gltfScene{{Nodes: []int{0}}},
+		Nodes:  []gltfNode{{Name: model.Name, Mesh: &zero}},
+	}}
+
+	attributes := make(map[string]int)
+
+	positions := make([][]float64, len(mesh.Vertices))
+	for i, v := range mesh.Vertices {
+		positions[i] = []float64{v[0], v[1], v[2]}
+	}
+	attributes["POSITION"] = w.addAccessor(positions, "VEC3", gltfFloat, gltfArrayBuffer, true)
+
+	if len(mesh.Normals) == len(mesh.Vertices) {
+		normals := make([][]float64, len(mesh.Normals))
+		for i, n := range mesh.Normals {
+			normals[i] = []float64{n[0], n[1], n[2]}
+		}
+		attributes["NORMAL"] = w.addAccessor(normals, "VEC3", gltfFloat, gltfArrayBuffer, false)
+	}
+
+	if len(mesh.UVs) == len(mesh.Vertices) {
+		uvs := make([][]float64, len(mesh.UVs))
+		for i, uv := range mesh.UVs {
+			uvs[i] = []float64{uv[0], uv[1]}
+		}
+		attributes["TEXCOORD_0"] = w.addAccessor(uvs, "VEC2", gltfFloat, gltfArrayBuffer, false)
+	}
+
+	if len(mesh.Colors) == len(mesh.Vertices) {
+		colors := make([][]float64, len(mesh.Colors))
+		for i, c := range mesh.Colors {
+			colors[i] = []float64{c[0], c[1], c[2], c[3]}
+		}
+		attributes["COLOR_0"] = w.addAccessor(colors, "VEC4", gltfFloat, gltfArrayBuffer, false)
+	}
+
+	// Materials and their texture images
+	images := make(map[string]int)
+	textureInfo := func(material *Material, slot string) *gltfTextureInfo {
+		uri := TextureURI(material, slot)
+		if uri == "" {
+			return nil
+		}
+		if _, ok := images[uri]; !ok {
+			images[uri] = len(w.doc.Images)
+			w.doc.Images = append(w.doc.Images, gltfImage{URI: uri})
+			source := images[uri]
+			w.doc.Textures = append(w.doc.Textures, gltfTexture{Source: &source})
+		}
+		return &gltfTextureInfo{Index: images[uri]}
+	}
+
+	for _, material := range model.Materials {
+		metallic := material.Metallic
+		roughness := material.Roughness
+		converted := gltfMaterial{
+			Name: material.Name,
+			PBRMetallicRoughness: &gltfPBR{
+				BaseColorFactor:          material.BaseColor[:],
+				BaseColorTexture:         textureInfo(material, TextureSlotBaseColor),
+				MetallicFactor:           &metallic,
+				RoughnessFactor:          &roughness,
+				MetallicRoughnessTexture: textureInfo(material, TextureSlotMetallicRoughness),
+			},
+			NormalTexture:    textureInfo(material, TextureSlotNormal),
+			OcclusionTexture: textureInfo(material, TextureSlotOcclusion),
+			EmissiveTexture:  textureInfo(material, TextureSlotEmissive),
+			DoubleSided:      material.DoubleSided,
+		}
+		if material.EmissiveColor != [3]float64{} {
+			converted.EmissiveFactor = material.EmissiveColor[:]
+		}
+		switch material.AlphaMode {
+		case AlphaMask:
+			converted.AlphaMode = "MASK"
+			cutoff := material.AlphaCutoff
+			converted.AlphaCutoff = &cutoff
+		case AlphaBlend:
+			converted.AlphaMode = "BLEND"
+		}
+		if material.Type == MaterialCustom {
+			model.warn("material %s: custom shaders cannot be stored in glTF, written as PBR", material.Name)
+		}
+		w.doc.Materials = append(w.doc.Materials, converted)
+	}
+
+	// One primitive per submesh
+	gltfMeshData := gltfMesh{Name: mesh.Name}
+	for i, group := range model.submeshGroups() {
+		if len(group) == 0 {
+			continue
+		}
+
+		indices := make([][]float64, len(group))
+		for j, index := range group {
+			indices[j] = []float64{float64(index)}
+		}
+		accessor := w.addAccessor(indices, "SCALAR", gltfUnsignedInt, gltfElementArrayBuffer, false)
+
+		primitive := gltfPrimitive{Attributes: attributes, Indices: &accessor}
+		if i < len(model.Materials) {
+			material := i
+			primitive.Material = &material
+		}
+		gltfMeshData.Primitives = append(gltfMeshData.Primitives, primitive)
+	}
+	w.doc.Meshes = []gltfMesh{gltfMeshData}
+
+	for w.buffer.Len()%4 != 0 {
+		w.buffer.WriteByte(0)
+	}
+
+	if !binaryOutput {
+		w.doc.Buffers = []gltfBuffer{{
+			URI:        "data:application/octet-stream;base64," + base64.StdEncoding.EncodeToString(w.buffer.Bytes()),
+			ByteLength: w.buffer.Len(),
+		}}
+
+		data, err := json.MarshalIndent(w.doc, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = out.Write(data)
+		return err
+	}
+
+	w.doc.Buffers = []gltfBuffer{{ByteLength: w.buffer.Len()}}
+	jsonData, err := json.Marshal(w.doc)
+	if err != nil {
+		return err
+	}
+	for len(jsonData)%4 != 0 {
+		jsonData = append(jsonData, ' ')
+	}
+
+	total := 12 + 8 + len(jsonData) + 8 + w.buffer.Len()
+	header := make([]byte, 12)
+	binary.LittleEndian.PutUint32(header[0:], glbMagic)
+	binary.LittleEndian.PutUint32(header[4:], 2)
+	binary.LittleEndian.PutUint32(header[8:], uint32(total))
+
+	chunk := func(length int, chunkType uint32) []byte {
+		h := make([]byte, 8)
+		binary.LittleEndian.PutUint32(h[0:], uint32(length))
+		binary.LittleEndian.PutUint32(h[4:], chunkType)
+		return h
+	}
+
+	for _, part := range [][]byte{
+		header,
+		chunk(len(jsonData), glbChunkJSON), jsonData,
+		chunk(w.buffer.Len(), glbChunkBIN), w.buffer.Bytes(),
+	} {
+		if _, err := out.Write(part); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+// ReportToJetpack registers the engine's rendering metrics with Jetpack and
+// records them once per second while the engine runs, along with the quality
+// the engine scales to when its performance level is adaptive
+func (e *Engine) ReportToJetpack(jp *core.Jetpack) {
+	jp.RegisterMetric(core.MetricDrawCalls, "draw_calls", "Draw calls per frame", "calls", nil, []string{"engine"})
+	jp.RegisterMetric(core.MetricDrawCalls, "draw_calls_unbatched", "Draw calls per frame without batching", "calls", nil, []string{"engine"})
+	jp.RegisterMetric(core.MetricDrawCalls, "culled_objects", "Objects skipped by frustum culling", "objects", nil, []string{"engine"})
+	jp.RegisterMetric(core.MetricFPS, "frame_time", "Time spent updating and rendering a frame", "ms", nil, []string{"engine"})
+	jp.RegisterMetric(core.MetricFPS, "quality_level", "Rendering quality level, 0 being the best", "level", nil, []string{"engine", "quality"})
+	jp.RegisterMetric(core.MetricFPS, "render_scale", "Render resolution scale", "ratio", nil, []string{"engine", "quality"})
+	jp.RegisterMetric(core.MetricFPS, "shadow_resolution", "Largest shadow map size", "px", nil, []string{"engine", "quality"})
+	jp.RegisterMetric(core.MetricFPS, "particle_scale", "Particle count scale", "ratio", nil, []string{"engine", "quality"})
+	jp.RegisterMetric(core.MetricFPS, "quality_changes", "Rendering quality changes since the engine started", "changes", nil, []string{"engine", "quality"})
+
+	var lastReport time.Time
+	e.SetStatsCallback(func(stats *EngineStats) {
+		now := time.Now()
+		if now.Sub(lastReport) < time.Second {
+			return
+		}
+		lastReport = now
+
+		jp.RecordMetric("draw_calls", float64(stats.DrawCalls))
+		jp.RecordMetric("draw_calls_unbatched", float64(stats.DrawCallsBeforeBatching))
+		jp.RecordMetric("culled_objects", float64(stats.CulledObjects))
+		jp.RecordMetric("frame_time", stats.FrameTime)
+		jp.RecordMetric("quality_level", float64(stats.QualityLevel))
+		jp.RecordMetric("render_scale", stats.Quality.RenderScale)
+		jp.RecordMetric("shadow_resolution", float64(stats.Quality.ShadowResolution))
+		jp.RecordMetric("particle_scale", stats.Quality.ParticleScale)
+		jp.RecordMetric("quality_changes", float64(stats.QualityChanges))
+	})
+}
@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMaxUpdatesPerFrame caps the fixed updates run for one frame so a slow
+// frame cannot trigger an ever-growing backlog of updates
+const DefaultMaxUpdatesPerFrame = 5
+
+// FixedTimestep turns variable frame times into a whole number of fixed-size
+// simulation updates. Time is accumulated in integer nanoseconds, so feeding
+// the same sequence of frame times always produces the same sequence of updates.
+type FixedTimestep struct {
+	// Number of updates run so far, read atomically; first for 64-bit alignment
+	tick uint64
+
+	// Duration of one update
+	Step time.Duration
+
+	// Maximum updates per Advance; excess time is dropped
+	MaxUpdates int
+
+	// Time not yet consumed by an update
+	accumulator time.Duration
+
+	// Time dropped because MaxUpdates was reached
+	dropped time.Duration
+}
+
+// NewFixedTimestep creates a timestep running rate updates per second
+func NewFixedTimestep(rate int) *FixedTimestep {
+	if rate <= 0 {
+		rate = 60
+	}
+
+	return &FixedTimestep{
+		Step:       time.Second / time.Duration(rate),
+		MaxUpdates: DefaultMaxUpdatesPerFrame,
+	}
+}
+
+// Advance adds the elapsed frame time, calls update once per whole step with the
+// step length in seconds and returns the interpolation factor between the last
+// two updates, in [0, 1)
+func (f *FixedTimestep) Advance(elapsed time.Duration, update func(deltaTime float64)) float64 {
+	if elapsed > 0 {
+		f.accumulator += elapsed
+	}
+
+	if f.MaxUpdates > 0 {
+		if limit := f.Step * time.Duration(f.MaxUpdates); f.accumulator > limit {
+			// Keep the fractional part so interpolation stays continuous
+			excess := f.accumulator - limit
+			excess -= excess % f.Step
+			f.dropped += excess
+			f.accumulator -= excess
+		}
+	}
+
+	for f.accumulator >= f.Step {
+		if update != nil {
+			update(f.Step.Seconds())
+		}
+		f.accumulator -= f.Step
+		atomic.AddUint64(&f.tick, 1)
+	}
+
+	return f.Alpha()
+}
+
+// StepOnce runs exactly one update regardless of accumulated time and clears
+// the accumulator, leaving the simulation exactly on a tick
+func (f *FixedTimestep) StepOnce(update func(deltaTime float64)) {
+	if update != nil {
+		update(f.Step.Seconds())
+	}
+	f.accumulator = 0
+	atomic.AddUint64(&f.tick, 1)
+}
+
+// Alpha returns how far the accumulated time is towards the next update
+func (f *FixedTimestep) Alpha() float64 {
+	return float64(f.accumulator) / float64(f.Step)
+}
+
+// Tick returns the number of updates run so far
+func (f *FixedTimestep) Tick() uint64 {
+	return atomic.LoadUint64(&f.tick)
+}
+
+// SimulationTime returns the simulated time in seconds, tick * step
+func (f *FixedTimestep) SimulationTime() float64 {
+	return float64(f.Tick()) * f.Step.Seconds()
+}
+
+// Dropped returns the total time skipped because updates could not keep up
+func (f *FixedTimestep) Dropped() time.Duration {
+	return f.dropped
+}
+
+// Reset discards accumulated time, for example after the loop was paused
+func (f *FixedTimestep) Reset() {
+	f.accumulator = 0
+}
+
+// Lerp interpolates between the previous and current value of a simulated
+// quantity using the factor passed to render callbacks
+func Lerp(previous, current, alpha float64) float64 {
+	return previous + (current-previous)*alpha
+}
+
+// LerpVec3 interpolates between two positions using the render interpolation factor
+func LerpVec3(previous, current [3]float64, alpha float64) [3]float64 {
+	return [3]float64{
+		Lerp(previous[0], current[0], alpha),
+		Lerp(previous[1], current[1], alpha),
+		Lerp(previous[2], current[2], alpha),
+	}
+}
@@ -0,0 +1,93 @@
+package engine
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFixedTimestepIsDeterministic(t *testing.T) {
+	frames := []time.Duration{
+		7 * time.Millisecond, 16 * time.Millisecond, 33 * time.Millisecond,
+		3 * time.Millisecond, 21 * time.Millisecond, 16 * time.Millisecond,
+	}
+
+	run := func() (float64, uint64, float64) {
+		timestep := NewFixedTimestep(60)
+		position, velocity := 0.0, 1.0
+		alpha := 0.0
+		for _, frame := range frames {
+			alpha = timestep.Advance(frame, func(dt float64) {
+				position += velocity * dt
+			})
+		}
+		return position, timestep.Tick(), alpha
+	}
+
+	position, ticks, alpha := run()
+	again, againTicks, againAlpha := run()
+
+	if position != again || ticks != againTicks || alpha != againAlpha {
+		t.Fatalf("identical frame times produced different results: %v/%d/%v vs %v/%d/%v",
+			position, ticks, alpha, again, againTicks, againAlpha)
+	}
+
+	// 96ms at 60Hz is 5 whole updates with 12.67ms left over
+	if ticks != 5 {
+		t.Fatalf("expected 5 updates, got %d", ticks)
+	}
+	if alpha <= 0 || alpha >= 1 {
+		t.Fatalf("expected interpolation factor in (0, 1), got %v", alpha)
+	}
+	if math.Abs(position-float64(ticks)/60) > 1e-6 {
+		t.Fatalf("expected position %v, got %v", float64(ticks)/60, position)
+	}
+}
+
+func TestFixedTimestepCapsUpdatesPerFrame(t *testing.T) {
+	timestep := NewFixedTimestep(60)
+	timestep.MaxUpdates = 3
+
+	updates := 0
+	alpha := timestep.Advance(time.Second+time.Millisecond, func(dt float64) { updates++ })
+
+	if updates != 3 {
+		t.Fatalf("expected 3 updates after a long frame, got %d", updates)
+	}
+	if timestep.Dropped() == 0 {
+		t.Fatalf("expected dropped time to be recorded")
+	}
+	if alpha < 0 || alpha >= 1 {
+		t.Fatalf("expected interpolation factor in [0, 1), got %v", alpha)
+	}
+}
+
+func TestEngineStepWhilePaused(t *testing.T) {
+	engine := NewEngine(&EngineConfig{TargetFPS: 30, UpdateRate: 50})
+
+	updates := 0
+	var renderedAlpha float64 = -1
+	engine.SetUpdateCallback(func(dt float64) {
+		updates++
+		if dt != 0.02 {
+			t.Errorf("expected fixed step of 0.02s, got %v", dt)
+		}
+	})
+	engine.SetInterpolatedRenderCallback(func(deltaTime, alpha float64) {
+		renderedAlpha = alpha
+	})
+
+	engine.Pause()
+	engine.Step()
+	engine.Step()
+
+	if updates != 2 || engine.Tick() != 2 {
+		t.Fatalf("expected 2 updates, got %d (tick %d)", updates, engine.Tick())
+	}
+	if renderedAlpha != 0 {
+		t.Fatalf("expected a stepped frame to render with alpha 0, got %v", renderedAlpha)
+	}
+	if math.Abs(engine.SimulationTime()-0.04) > 1e-9 {
+		t.Fatalf("expected 0.04s of simulated time, got %v", engine.SimulationTime())
+	}
+}
@@ -0,0 +1,321 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MaterialType represents the shading model of a material
+type MaterialType string
+
+const (
+	// MaterialPBR uses the glTF metallic-roughness shading model
+	MaterialPBR MaterialType = "pbr"
+
+	// MaterialUnlit renders the base color without lighting
+	MaterialUnlit MaterialType = "unlit"
+
+	// MaterialCustom renders with a user-provided shader
+	MaterialCustom MaterialType = "custom"
+)
+
+// AlphaMode represents how a material's alpha channel is interpreted
+type AlphaMode string
+
+const (
+	// AlphaOpaque ignores alpha
+	AlphaOpaque AlphaMode = "opaque"
+
+	// AlphaMask discards fragments below AlphaCutoff
+	AlphaMask AlphaMode = "mask"
+
+	// AlphaBlend blends with the framebuffer
+	AlphaBlend AlphaMode = "blend"
+)
+
+// Texture slots used by the built-in shading models
+const (
+	TextureSlotBaseColor         = "baseColor"
+	TextureSlotMetallicRoughness = "metallicRoughness"
+	TextureSlotNormal            = "normal"
+	TextureSlotOcclusion         = "occlusion"
+	TextureSlotEmissive          = "emissive"
+)
+
+// Render queues, lower queues are drawn first
+const (
+	RenderQueueOpaque      = 2000
+	RenderQueueAlphaTest   = 2450
+	RenderQueueTransparent = 3000
+)
+
+// NewPBRMaterial creates a metallic-roughness material
+func NewPBRMaterial(id, name string, baseColor [4]float64, metallic, roughness float64) *Material {
+	return &Material{
+		ID:          id,
+		Name:        name,
+		Type:        MaterialPBR,
+		Textures:    make(map[string]*GPUTexture),
+		Properties:  make(map[string]interface{}),
+		RenderQueue: RenderQueueOpaque,
+		BaseColor:   baseColor,
+		Metallic:    clamp(metallic, 0, 1),
+		Roughness:   clamp(roughness, 0, 1),
+		AlphaMode:   AlphaOpaque,
+		AlphaCutoff: 0.5,
+	}
+}
+
+// NewUnlitMaterial creates a material that ignores scene lighting
+func NewUnlitMaterial(id, name string, color [4]float64) *Material {
+	return &Material{
+		ID:          id,
+		Name:        name,
+		Type:        MaterialUnlit,
+		Textures:    make(map[string]*GPUTexture),
+		Properties:  make(map[string]interface{}),
+		RenderQueue: RenderQueueOpaque,
+		BaseColor:   color,
+		AlphaMode:   AlphaOpaque,
+		AlphaCutoff: 0.5,
+	}
+}
+
+// NewShaderMaterial creates a material rendered with a custom shader
+func NewShaderMaterial(id, name string, shader *GPUShader, properties map[string]interface{}) *Material {
+	if properties == nil {
+		properties = make(map[string]interface{})
+	}
+
+	return &Material{
+		ID:          id,
+		Name:        name,
+		Type:        MaterialCustom,
+		Shader:      shader,
+		Textures:    make(map[string]*GPUTexture),
+		Properties:  properties,
+		RenderQueue: RenderQueueOpaque,
+		BaseColor:   [4]float64{1, 1, 1, 1},
+		AlphaMode:   AlphaOpaque,
+		AlphaCutoff: 0.5,
+	}
+}
+
+// SetTexture binds a texture to a slot
+func (m *Material) SetTexture(slot string, texture *GPUTexture) {
+	if m.Textures == nil {
+		m.Textures = make(map[string]*GPUTexture)
+	}
+
+	if texture == nil {
+		delete(m.Textures, slot)
+		return
+	}
+
+	m.Textures[slot] = texture
+}
+
+// SetAlphaMode sets the alpha mode and moves the material to the matching render queue
+func (m *Material) SetAlphaMode(mode AlphaMode) {
+	m.AlphaMode = mode
+
+	switch mode {
+	case AlphaBlend:
+		m.RenderQueue = RenderQueueTransparent
+	case AlphaMask:
+		m.RenderQueue = RenderQueueAlphaTest
+	default:
+		m.RenderQueue = RenderQueueOpaque
+	}
+}
+
+// NewInstance creates a material instance that shares this material's pipeline
+// state and textures but can override uniform values such as colors
+func (m *Material) NewInstance(id string) *Material {
+	root := m
+	for root.Parent != nil {
+		root = root.Parent
+	}
+
+	instance := *m
+	instance.ID = id
+	instance.Name = fmt.Sprintf("%s Instance", m.Name)
+	instance.Parent = root
+	instance.Textures = make(map[string]*GPUTexture, len(m.Textures))
+	for slot, texture := range m.Textures {
+		instance.Textures[slot] = texture
+	}
+	instance.Properties = make(map[string]interface{}, len(m.Properties))
+	for key, value := range m.Properties {
+		instance.Properties[key] = value
+	}
+
+	return &instance
+}
+
+// StateKey identifies the GPU pipeline and binding state needed to draw the material.
+// Materials with equal keys can be drawn without a state change.
+func (m *Material) StateKey() string {
+	var key strings.Builder
+
+	key.WriteString(string(m.Type))
+	if m.Shader != nil {
+		key.WriteString("|shader=")
+		key.WriteString(m.Shader.ID)
+	}
+	key.WriteString("|alpha=")
+	key.WriteString(string(m.AlphaMode))
+	if m.DoubleSided {
+		key.WriteString("|double")
+	}
+
+	slots := make([]string, 0, len(m.Textures))
+	for slot := range m.Textures {
+		slots = append(slots, slot)
+	}
+	sort.Strings(slots)
+
+	for _, slot := range slots {
+		if texture := m.Textures[slot]; texture != nil {
+			key.WriteString("|")
+			key.WriteString(slot)
+			key.WriteString("=")
+			key.WriteString(texture.ID)
+		}
+	}
+
+	return key.String()
+}
+
+// MaterialLibrary shares materials between meshes to reduce GPU state changes
+type MaterialLibrary struct {
+	// Registered materials
+	materials map[string]*Material
+
+	// Reference counts
+	refs map[string]int
+
+	// Mutex for thread safety
+	mutex sync.RWMutex
+}
+
+// NewMaterialLibrary creates a new material library
+func NewMaterialLibrary() *MaterialLibrary {
+	return &MaterialLibrary{
+		materials: make(map[string]*Material),
+		refs:      make(map[string]int),
+	}
+}
+
+// Register adds a material to the library
+func (l *MaterialLibrary) Register(material *Material) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if material == nil {
+		return fmt.Errorf("material is nil")
+	}
+
+	if _, ok := l.materials[material.ID]; ok {
+		return fmt.Errorf("material with ID %s already exists", material.ID)
+	}
+
+	l.materials[material.ID] = material
+
+	return nil
+}
+
+// Get gets a material by ID
+func (l *MaterialLibrary) Get(id string) *Material {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	return l.materials[id]
+}
+
+// Acquire returns a shared material, creating it on first use, and increments its reference count
+func (l *MaterialLibrary) Acquire(id string, create func() *Material) *Material {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	material, ok := l.materials[id]
+	if !ok {
+		material = create()
+		material.ID = id
+		l.materials[id] = material
+	}
+
+	l.refs[id]++
+
+	return material
+}
+
+// Release decrements a material's reference count and removes it when unused
+func (l *MaterialLibrary) Release(id string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.refs[id] > 1 {
+		l.refs[id]--
+		return
+	}
+
+	delete(l.refs, id)
+	delete(l.materials, id)
+}
+
+// ColorMaterial returns the shared PBR material for a solid color
+func (l *MaterialLibrary) ColorMaterial(color [3]float64) *Material {
+	id := fmt.Sprintf("color-%.3f-%.3f-%.3f", color[0], color[1], color[2])
+
+	return l.Acquire(id, func() *Material {
+		material := NewPBRMaterial(id, fmt.Sprintf("Color %.3f %.3f %.3f", color[0], color[1], color[2]),
+			[4]float64{color[0], color[1], color[2], 1}, 0, 0.5)
+		material.Properties["color"] = color
+		return material
+	})
+}
+
+// Len returns the number of registered materials
+func (l *MaterialLibrary) Len() int {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	return len(l.materials)
+}
+
+// SortByMaterialState orders renderers by render queue and material state so
+// consecutive draws share pipelines and bindings. It returns the number of state
+// changes needed to draw the sorted list.
+func SortByMaterialState(renderers []*MeshRenderer) int {
+	keyOf := func(renderer *MeshRenderer) (int, string) {
+		if len(renderer.Materials) == 0 || renderer.Materials[0] == nil {
+			return RenderQueueOpaque, ""
+		}
+		material := renderer.Materials[0]
+		return material.RenderQueue, material.StateKey()
+	}
+
+	sort.SliceStable(renderers, func(i, j int) bool {
+		queueI, keyI := keyOf(renderers[i])
+		queueJ, keyJ := keyOf(renderers[j])
+		if queueI != queueJ {
+			return queueI < queueJ
+		}
+		return keyI < keyJ
+	})
+
+	switches := 0
+	previous := ""
+	for i, renderer := range renderers {
+		_, key := keyOf(renderer)
+		if i == 0 || key != previous {
+			switches++
+		}
+		previous = key
+	}
+
+	return switches
+}
@@ -0,0 +1,218 @@
+package engine
+
+import (
+	"math"
+)
+
+// Vec3 helpers operate on the [3]float64 tuples used throughout the scene graph
+
+// vec3Add adds two vectors
+func vec3Add(a, b [3]float64) [3]float64 {
+	return [3]float64{a[0] + b[0], a[1] + b[1], a[2] + b[2]}
+}
+
+// vec3Sub subtracts b from a
+func vec3Sub(a, b [3]float64) [3]float64 {
+	return [3]float64{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+// vec3Scale scales a vector by s
+func vec3Scale(a [3]float64, s float64) [3]float64 {
+	return [3]float64{a[0] * s, a[1] * s, a[2] * s}
+}
+
+// vec3Dot returns the dot product of two vectors
+func vec3Dot(a, b [3]float64) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+// vec3Cross returns the cross product of two vectors
+func vec3Cross(a, b [3]float64) [3]float64 {
+	return [3]float64{
+		a[1]*b[2] - a[2]*b[1],
+		a[2]*b[0] - a[0]*b[2],
+		a[0]*b[1] - a[1]*b[0],
+	}
+}
+
+// vec3Length returns the length of a vector
+func vec3Length(a [3]float64) float64 {
+	return math.Sqrt(vec3Dot(a, a))
+}
+
+// vec3Normalize returns a unit vector, or the zero vector for zero input
+func vec3Normalize(a [3]float64) [3]float64 {
+	length := vec3Length(a)
+	if length == 0 {
+		return [3]float64{}
+	}
+	return vec3Scale(a, 1/length)
+}
+
+// clamp limits v to the range [min, max]
+func clamp(v, min, max float64) float64 {
+	if v < min {
+		return min
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
+// lookAtMatrix builds a column-major view matrix looking from eye towards target
+func lookAtMatrix(eye, target, up [3]float64) [16]float64 {
+	forward := vec3Normalize(vec3Sub(target, eye))
+	if vec3Length(forward) == 0 {
+		forward = [3]float64{0, 0, -1}
+	}
+
+	right := vec3Normalize(vec3Cross(forward, up))
+	if vec3Length(right) == 0 {
+		// Up is parallel to the view direction, pick any perpendicular axis
+		right = vec3Normalize(vec3Cross(forward, [3]float64{1, 0, 0}))
+	}
+	trueUp := vec3Cross(right, forward)
+
+	return [16]float64{
+		right[0], trueUp[0], -forward[0], 0,
+		right[1], trueUp[1], -forward[1], 0,
+		right[2], trueUp[2], -forward[2], 0,
+		-vec3Dot(right, eye), -vec3Dot(trueUp, eye), vec3Dot(forward, eye), 1,
+	}
+}
+
+// perspectiveMatrix builds a column-major perspective projection matrix
+func perspectiveMatrix(fovDegrees, aspect, near, far float64) [16]float64 {
+	f := 1 / math.Tan(fovDegrees*math.Pi/360)
+	rangeInv := 1 / (near - far)
+
+	return [16]float64{
+		f / aspect, 0, 0, 0,
+		0, f, 0, 0,
+		0, 0, (near + far) * rangeInv, -1,
+		0, 0, 2 * near * far * rangeInv, 0,
+	}
+}
+
+// orthographicMatrix builds a column-major orthographic projection matrix
+func orthographicMatrix(size, aspect, near, far float64) [16]float64 {
+	halfHeight := size
+	halfWidth := size * aspect
+
+	return [16]float64{
+		1 / halfWidth, 0, 0, 0,
+		0, 1 / halfHeight, 0, 0,
+		0, 0, -2 / (far - near), 0,
+		0, 0, -(far + near) / (far - near), 1,
+	}
+}
+
+// mat4Identity returns the identity matrix
+func mat4Identity() [16]float64 {
+	return [16]float64{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1}
+}
+
+// mat4Multiply returns a*b for column-major matrices
+func mat4Multiply(a, b [16]float64) [16]float64 {
+	var out [16]float64
+	for col := 0; col < 4; col++ {
+		for row := 0; row < 4; row++ {
+			sum := 0.0
+			for k := 0; k < 4; k++ {
+				sum += a[k*4+row] * b[col*4+k]
+			}
+			out[col*4+row] = sum
+		}
+	}
+	return out
+}
+
+// mat4TransformPoint transforms a point by a column-major affine matrix
+func mat4TransformPoint(m [16]float64, p [3]float64) [3]float64 {
+	return [3]float64{
+		m[0]*p[0] + m[4]*p[1] + m[8]*p[2] + m[12],
+		m[1]*p[0] + m[5]*p[1] + m[9]*p[2] + m[13],
+		m[2]*p[0] + m[6]*p[1] + m[10]*p[2] + m[14],
+	}
+}
+
+// mat4TransformDirection transforms a direction by a column-major matrix, ignoring translation
+func mat4TransformDirection(m [16]float64, d [3]float64) [3]float64 {
+	return [3]float64{
+		m[0]*d[0] + m[4]*d[1] + m[8]*d[2],
+		m[1]*d[0] + m[5]*d[1] + m[9]*d[2],
+		m[2]*d[0] + m[6]*d[1] + m[10]*d[2],
+	}
+}
+
+// mat4NormalMatrix returns the inverse-transpose of m's upper 3x3, which
+// keeps normals perpendicular to surfaces under non-uniform scale. A matrix
+// that flattens space has no inverse and is returned unchanged.
+func mat4NormalMatrix(m [16]float64) [16]float64 {
+	x := [3]float64{m[0], m[1], m[2]}
+	y := [3]float64{m[4], m[5], m[6]}
+	z := [3]float64{m[8], m[9], m[10]}
+
+	det := vec3Dot(x, vec3Cross(y, z))
+	if det == 0 {
+		return m
+	}
+
+	// The columns of the inverse-transpose are the cross products of the
+	// other two columns over the determinant
+	nx := vec3Scale(vec3Cross(y, z), 1/det)
+	ny := vec3Scale(vec3Cross(z, x), 1/det)
+	nz := vec3Scale(vec3Cross(x, y), 1/det)
+	return [16]float64{
+		nx[0], nx[1], nx[2], 0,
+		ny[0], ny[1], ny[2], 0,
+		nz[0], nz[1], nz[2], 0,
+		0, 0, 0, 1,
+	}
+}
+
+// composeMatrix builds a column-major TRS matrix from a position, an XYZ Euler
+// rotation in radians and a scale
+func composeMatrix(position, rotation, scale [3]float64) [16]float64 {
+	sx, cx := math.Sincos(rotation[0])
+	sy, cy := math.Sincos(rotation[1])
+	sz, cz := math.Sincos(rotation[2])
+
+	// R = Rz * Ry * Rx
+	return [16]float64{
+		cy * cz * scale[0], cy * sz * scale[0], -sy * scale[0], 0,
+		(sx*sy*cz - cx*sz) * scale[1], (sx*sy*sz + cx*cz) * scale[1], sx * cy * scale[1], 0,
+		(cx*sy*cz + sx*sz) * scale[2], (cx*sy*sz - sx*cz) * scale[2], cx * cy * scale[2], 0,
+		position[0], position[1], position[2], 1,
+	}
+}
+
+// transformBounds returns the axis-aligned box enclosing bounds transformed by m
+func transformBounds(m [16]float64, bounds [6]float64) [6]float64 {
+	out := [6]float64{
+		math.Inf(1), math.Inf(1), math.Inf(1),
+		math.Inf(-1), math.Inf(-1), math.Inf(-1),
+	}
+
+	for i := 0; i < 8; i++ {
+		corner := [3]float64{bounds[0], bounds[1], bounds[2]}
+		if i&1 != 0 {
+			corner[0] = bounds[3]
+		}
+		if i&2 != 0 {
+			corner[1] = bounds[4]
+		}
+		if i&4 != 0 {
+			corner[2] = bounds[5]
+		}
+
+		p := mat4TransformPoint(m, corner)
+		for axis := 0; axis < 3; axis++ {
+			out[axis] = math.Min(out[axis], p[axis])
+			out[axis+3] = math.Max(out[axis+3], p[axis])
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Model is a mesh with one material per submesh, as read from a model file
+type Model struct {
+	// Model name
+	Name string
+
+	// Mesh; Submeshes index into Materials
+	Mesh *Mesh
+
+	// Materials used by the submeshes
+	Materials []*Material
+
+	// Warnings about data that was dropped or approximated
+	Warnings []string
+}
+
+// warn records a lossy conversion
+func (m *Model) warn(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	for _, existing := range m.Warnings {
+		if existing == message {
+			return
+		}
+	}
+	m.Warnings = append(m.Warnings, message)
+}
+
+// TextureURI returns the file a material slot references when read from a model file
+func TextureURI(material *Material, slot string) string {
+	uri, _ := material.Properties["uri:"+slot].(string)
+	return uri
+}
+
+// SetTextureURI records the file a material slot references
+func SetTextureURI(material *Material, slot, uri string) {
+	if material.Properties == nil {
+		material.Properties = make(map[string]interface{})
+	}
+	material.Properties["uri:"+slot] = uri
+}
+
+// submeshGroups returns the triangle index lists of a model, one per material
+func (m *Model) submeshGroups() [][]int {
+	if len(m.Mesh.Submeshes) > 0 {
+		return m.Mesh.Submeshes
+	}
+	return [][]int{m.Mesh.Indices}
+}
+
+// modelMaterial returns the material for a submesh, or nil
+func (m *Model) modelMaterial(submesh int) *Material {
+	if submesh < len(m.Materials) {
+		return m.Materials[submesh]
+	}
+	return nil
+}
+
+// LoadModel reads an OBJ, glTF, GLB or binary FBX model
+func LoadModel(filename string) (*Model, error) {
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	dir := filepath.Dir(filename)
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	open := func(uri string) (io.ReadCloser, error) {
+		return os.Open(filepath.Join(dir, filepath.FromSlash(uri)))
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".obj":
+		return ParseOBJModel(file, name, open)
+	case ".gltf", ".glb":
+		return ParseGLTF(file, name, open)
+	case ".fbx":
+		return ParseFBX(file, name)
+	default:
+		return nil, fmt.Errorf("unsupported model format %s", filepath.Ext(filename))
+	}
+}
+
+// SaveModel writes a model as OBJ (with an MTL file), glTF or GLB
+func SaveModel(filename string, model *Model) error {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == ".fbx" {
+		return fmt.Errorf("writing FBX is not supported, use .gltf or .glb")
+	}
+	if ext != ".obj" && ext != ".gltf" && ext != ".glb" {
+		return fmt.Errorf("unsupported model format %s", filepath.Ext(filename))
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	switch ext {
+	case ".obj":
+		mtlName := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)) + ".mtl"
+		var mtl *os.File
+		if len(model.Materials) > 0 {
+			mtl, err = os.Create(filepath.Join(filepath.Dir(filename), mtlName))
+			if err != nil {
+				file.Close()
+				return err
+			}
+		}
+
+		if mtl != nil {
+			err = WriteOBJModel(file, mtl, mtlName, model)
+			if closeErr := mtl.Close(); err == nil {
+				err = closeErr
+			}
+		} else {
+			err = WriteOBJModel(file, nil, "", model)
+		}
+	case ".gltf":
+		err = WriteGLTF(file, model, false)
+	case ".glb":
+		err = WriteGLTF(file, model, true)
+	}
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -0,0 +1,128 @@
+package engine
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+const texturedOBJ = `mtllib crate.mtl
+v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+v 2 0 0
+v 2 1 0
+vt 0 0
+vt 1 0
+vt 1 1
+vt 0 1
+usemtl wood
+f 1/1 2/2 3/3 4/4
+usemtl glass
+f 2/1 5/2 6/3 3/4
+`
+
+const texturedMTL = `newmtl wood
+Kd 0.6 0.4 0.2
+map_Kd wood.png
+newmtl glass
+Kd 0.8 0.9 1
+d 0.25
+`
+
+func openMTL(name string) (io.ReadCloser, error) {
+	return ioutil.NopCloser(strings.NewReader(texturedMTL)), nil
+}
+
+func TestOBJGLTFRoundTripPreservesMaterials(t *testing.T) {
+	model, err := ParseOBJModel(strings.NewReader(texturedOBJ), "crate", openMTL)
+	if err != nil {
+		t.Fatalf("ParseOBJModel returned error: %v", err)
+	}
+	if len(model.Materials) != 2 || len(model.Mesh.Submeshes) != 2 {
+		t.Fatalf("expected 2 materials and submeshes, got %d and %d", len(model.Materials), len(model.Mesh.Submeshes))
+	}
+
+	for _, binary := range []bool{false, true} {
+		var out bytes.Buffer
+		if err := WriteGLTF(&out, model, binary); err != nil {
+			t.Fatalf("WriteGLTF(binary=%v) returned error: %v", binary, err)
+		}
+
+		back, err := ParseGLTF(&out, "crate", nil)
+		if err != nil {
+			t.Fatalf("ParseGLTF(binary=%v) returned error: %v", binary, err)
+		}
+
+		if got := len(back.Mesh.Indices) / 3; got != 4 {
+			t.Fatalf("expected 4 triangles, got %d", got)
+		}
+		if len(back.Materials) != 2 {
+			t.Fatalf("expected 2 materials, got %d", len(back.Materials))
+		}
+
+		wood, glass := back.Materials[0], back.Materials[1]
+		if wood.Name != "wood" || TextureURI(wood, TextureSlotBaseColor) != "wood.png" {
+			t.Fatalf("wood material not preserved: %s %q", wood.Name, TextureURI(wood, TextureSlotBaseColor))
+		}
+		if glass.AlphaMode != AlphaBlend || glass.BaseColor[3] != 0.25 {
+			t.Fatalf("glass transparency not preserved: %v %v", glass.AlphaMode, glass.BaseColor)
+		}
+		if len(back.Mesh.UVs) != len(back.Mesh.Vertices) {
+			t.Fatalf("expected UVs for every vertex")
+		}
+	}
+}
+
+func TestParseGLTFWarnsAboutDroppedData(t *testing.T) {
+	model, err := ParseOBJModel(strings.NewReader(texturedOBJ), "crate", openMTL)
+	if err != nil {
+		t.Fatalf("ParseOBJModel returned error: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := WriteGLTF(&out, model, false); err != nil {
+		t.Fatalf("WriteGLTF returned error: %v", err)
+	}
+
+	// Add an animation the engine cannot import
+	source := strings.Replace(out.String(), `"asset": {`, `"animations": [{}], "asset": {`, 1)
+
+	back, err := ParseGLTF(strings.NewReader(source), "crate", nil)
+	if err != nil {
+		t.Fatalf("ParseGLTF returned error: %v", err)
+	}
+	if len(back.Warnings) != 1 || !strings.Contains(back.Warnings[0], "animation") {
+		t.Fatalf("expected an animation warning, got %v", back.Warnings)
+	}
+}
+
+func TestWriteOBJModelReportsLossyMaterials(t *testing.T) {
+	model, err := ParseOBJModel(strings.NewReader(texturedOBJ), "crate", openMTL)
+	if err != nil {
+		t.Fatalf("ParseOBJModel returned error: %v", err)
+	}
+	model.Materials[0].DoubleSided = true
+
+	var obj, mtl bytes.Buffer
+	if err := WriteOBJModel(&obj, &mtl, "crate.mtl", model); err != nil {
+		t.Fatalf("WriteOBJModel returned error: %v", err)
+	}
+
+	if !strings.Contains(obj.String(), "usemtl glass") || !strings.Contains(mtl.String(), "map_Kd wood.png") {
+		t.Fatalf("materials missing from output:\n%s\n%s", obj.String(), mtl.String())
+	}
+	if len(model.Warnings) == 0 {
+		t.Fatalf("expected a warning for the double-sided material")
+	}
+}
+
+func TestParseFBXRejectsASCII(t *testing.T) {
+	_, err := ParseFBX(strings.NewReader("; FBX 7.4.0 project file\nFBXHeaderExtension:  {\n}\n"), "scene")
+	if err == nil || !strings.Contains(err.Error(), "ASCII") {
+		t.Fatalf("expected ASCII FBX error, got %v", err)
+	}
+}
@@ -0,0 +1,415 @@
+package engine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseOBJ reads a Wavefront OBJ model into a single mesh. Polygons are
+// triangulated as fans; materials are ignored.
+func ParseOBJ(r io.Reader, id string) (*Mesh, error) {
+	model, err := ParseOBJModel(r, id, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return model.Mesh, nil
+}
+
+// ParseOBJModel reads a Wavefront OBJ model with its materials. open resolves
+// MTL files referenced by mtllib; when nil, materials get default values.
+func ParseOBJModel(r io.Reader, id string, open func(name string) (io.ReadCloser, error)) (*Model, error) {
+	var positions [][3]float64
+	var normals [][3]float64
+	var uvs [][2]float64
+
+	mesh := &Mesh{
+		ID:   id,
+		Name: id,
+	}
+	model := &Model{Name: id, Mesh: mesh}
+
+	// Materials from mtllib files, and the submesh used for each material
+	library := make(map[string]*Material)
+	groups := make(map[string]int)
+	current := -1
+
+	// Unique position/uv/normal combinations become mesh vertices
+	vertices := make(map[[3]int]int)
+
+	resolve := func(index, count int) (int, error) {
+		if index < 0 {
+			index = count + index + 1
+		}
+		if index < 1 || index > count {
+			return 0, fmt.Errorf("index %d out of range", index)
+		}
+		return index - 1, nil
+	}
+
+	vertex := func(token string) (int, error) {
+		parts := strings.Split(token, "/")
+		key := [3]int{-1, -1, -1}
+
+		for i, part := range parts {
+			if i > 2 || part == "" {
+				continue
+			}
+
+			value, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("invalid face index %q", token)
+			}
+
+			count := [3]int{len(positions), len(uvs), len(normals)}[i]
+			if key[i], err = resolve(value, count); err != nil {
+				return 0, err
+			}
+		}
+
+		if key[0] < 0 {
+			return 0, fmt.Errorf("face vertex %q has no position", token)
+		}
+
+		if index, ok := vertices[key]; ok {
+			return index, nil
+		}
+
+		index := len(mesh.Vertices)
+		vertices[key] = index
+		mesh.Vertices = append(mesh.Vertices, positions[key[0]])
+		if key[1] >= 0 {
+			mesh.UVs = append(mesh.UVs, uvs[key[1]])
+		} else if len(uvs) > 0 {
+			mesh.UVs = append(mesh.UVs, [2]float64{})
+		}
+		if key[2] >= 0 {
+			mesh.Normals = append(mesh.Normals, normals[key[2]])
+		} else if len(normals) > 0 {
+			mesh.Normals = append(mesh.Normals, [3]float64{})
+		}
+
+		return index, nil
+	}
+
+	useMaterial := func(name string) {
+		if index, ok := groups[name]; ok {
+			current = index
+			return
+		}
+
+		material, ok := library[name]
+		if !ok {
+			if name == "" {
+				// Faces before the first usemtl
+				material = NewPBRMaterial("default", "default", [4]float64{0.8, 0.8, 0.8, 1}, 0, 0.5)
+			} else {
+				material = NewPBRMaterial(name, name, [4]float64{0.8, 0.8, 0.8, 1}, 0, 0.5)
+				model.warn("material %s is not defined in any MTL file, using defaults", name)
+			}
+		}
+
+		current = len(model.Materials)
+		groups[name] = current
+		model.Materials = append(model.Materials, material)
+		mesh.Submeshes = append(mesh.Submeshes, nil)
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		switch fields[0] {
+		case "v":
+			values, err := parseFloats(fields[1:], 3)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			positions = append(positions, [3]float64{values[0], values[1], values[2]})
+			if len(fields) >= 7 {
+				model.warn("vertex colors are not supported and were dropped")
+			}
+		case "vn":
+			values, err := parseFloats(fields[1:], 3)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			normals = append(normals, [3]float64{values[0], values[1], values[2]})
+		case "vt":
+			values, err := parseFloats(fields[1:], 2)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", line, err)
+			}
+			uvs = append(uvs, [2]float64{values[0], values[1]})
+		case "mtllib":
+			if open == nil {
+				continue
+			}
+			for _, name := range fields[1:] {
+				if err := loadMTL(open, name, library); err != nil {
+					model.warn("could not read material library %s: %v", name, err)
+				}
+			}
+		case "usemtl":
+			useMaterial(strings.Join(fields[1:], " "))
+		case "f":
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("line %d: face needs at least 3 vertices", line)
+			}
+
+			indices := make([]int, 0, len(fields)-1)
+			for _, token := range fields[1:] {
+				index, err := vertex(token)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %v", line, err)
+				}
+				indices = append(indices, index)
+			}
+
+			if current < 0 {
+				useMaterial("")
+			}
+
+			for i := 1; i+1 < len(indices); i++ {
+				triangle := []int{indices[0], indices[i], indices[i+1]}
+				mesh.Indices = append(mesh.Indices, triangle...)
+				mesh.Submeshes[current] = append(mesh.Submeshes[current], triangle...)
+			}
+		case "l", "p":
+			model.warn("line and point elements are not supported and were dropped")
+		case "curv", "curv2", "surf":
+			model.warn("free-form geometry is not supported and was dropped")
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// A model without usemtl has no materials
+	if _, ok := groups[""]; ok && len(groups) == 1 {
+		model.Materials = nil
+		mesh.Submeshes = nil
+	}
+
+	mesh.ComputeBounds()
+
+	return model, nil
+}
+
+// parseFloats parses the first n fields as floats
+func parseFloats(fields []string, n int) ([]float64, error) {
+	if len(fields) < n {
+		return nil, fmt.Errorf("expected %d values, got %d", n, len(fields))
+	}
+
+	values := make([]float64, n)
+	for i := 0; i < n; i++ {
+		value, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+
+	return values, nil
+}
+
+// loadMTL reads the materials of an MTL file into library
+func loadMTL(open func(name string) (io.ReadCloser, error), name string, library map[string]*Material) error {
+	file, err := open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var material *Material
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		if fields[0] == "newmtl" {
+			materialName := strings.Join(fields[1:], " ")
+			material = NewPBRMaterial(materialName, materialName, [4]float64{0.8, 0.8, 0.8, 1}, 0, 0.5)
+			library[materialName] = material
+			continue
+		}
+		if material == nil {
+			continue
+		}
+
+		// Texture maps take the last field as the file so options are skipped
+		texture := fields[len(fields)-1]
+
+		switch fields[0] {
+		case "Kd":
+			if values, err := parseFloats(fields[1:], 3); err == nil {
+				material.BaseColor = [4]float64{values[0], values[1], values[2], material.BaseColor[3]}
+			}
+		case "Ke":
+			if values, err := parseFloats(fields[1:], 3); err == nil {
+				material.EmissiveColor = [3]float64{values[0], values[1], values[2]}
+			}
+		case "d":
+			if values, err := parseFloats(fields[1:], 1); err == nil {
+				material.BaseColor[3] = values[0]
+			}
+		case "Tr":
+			if values, err := parseFloats(fields[1:], 1); err == nil {
+				material.BaseColor[3] = 1 - values[0]
+			}
+		case "Ns":
+			// Convert Phong shininess to an approximate roughness
+			if values, err := parseFloats(fields[1:], 1); err == nil {
+				material.Roughness = clamp(math.Sqrt(2/(values[0]+2)), 0, 1)
+			}
+		case "Pr":
+			if values, err := parseFloats(fields[1:], 1); err == nil {
+				material.Roughness = clamp(values[0], 0, 1)
+			}
+		case "Pm":
+			if values, err := parseFloats(fields[1:], 1); err == nil {
+				material.Metallic = clamp(values[0], 0, 1)
+			}
+		case "map_Kd":
+			SetTextureURI(material, TextureSlotBaseColor, texture)
+		case "map_Bump", "map_bump", "bump", "norm":
+			SetTextureURI(material, TextureSlotNormal, texture)
+		case "map_Ke":
+			SetTextureURI(material, TextureSlotEmissive, texture)
+		}
+
+		if material.BaseColor[3] < 1 {
+			material.SetAlphaMode(AlphaBlend)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// WriteOBJ writes a mesh as a Wavefront OBJ model
+func WriteOBJ(w io.Writer, mesh *Mesh) error {
+	return WriteOBJModel(w, nil, "", &Model{Name: mesh.Name, Mesh: mesh})
+}
+
+// WriteOBJModel writes a model as OBJ. When mtl is not nil the materials are
+// written to it and referenced from the OBJ as mtlName.
+func WriteOBJModel(w io.Writer, mtl io.Writer, mtlName string, model *Model) error {
+	mesh := model.Mesh
+	out := bufio.NewWriter(w)
+
+	fmt.Fprintf(out, "# %s\n", mesh.Name)
+	if mtl != nil && len(model.Materials) > 0 {
+		fmt.Fprintf(out, "mtllib %s\n", mtlName)
+	}
+
+	for _, vertex := range mesh.Vertices {
+		fmt.Fprintf(out, "v %g %g %g\n", vertex[0], vertex[1], vertex[2])
+	}
+
+	hasUVs := len(mesh.UVs) == len(mesh.Vertices) && len(mesh.UVs) > 0
+	hasNormals := len(mesh.Normals) == len(mesh.Vertices) && len(mesh.Normals) > 0
+	if hasUVs {
+		for _, uv := range mesh.UVs {
+			fmt.Fprintf(out, "vt %g %g\n", uv[0], uv[1])
+		}
+	}
+	if hasNormals {
+		for _, normal := range mesh.Normals {
+			fmt.Fprintf(out, "vn %g %g %g\n", normal[0], normal[1], normal[2])
+		}
+	}
+	if len(mesh.Colors) > 0 {
+		model.warn("OBJ does not store vertex colors, they were dropped")
+	}
+
+	corner := func(index int) string {
+		i := index + 1
+		switch {
+		case hasUVs && hasNormals:
+			return fmt.Sprintf("%d/%d/%d", i, i, i)
+		case hasUVs:
+			return fmt.Sprintf("%d/%d", i, i)
+		case hasNormals:
+			return fmt.Sprintf("%d//%d", i, i)
+		}
+		return strconv.Itoa(i)
+	}
+
+	for i, indices := range model.submeshGroups() {
+		if material := model.modelMaterial(i); material != nil && mtl != nil {
+			fmt.Fprintf(out, "usemtl %s\n", material.Name)
+		}
+		for _, triangle := range meshTriangleIndices(&Mesh{Indices: indices}) {
+			fmt.Fprintf(out, "f %s %s %s\n", corner(triangle[0]), corner(triangle[1]), corner(triangle[2]))
+		}
+	}
+
+	if err := out.Flush(); err != nil {
+		return err
+	}
+
+	if mtl == nil || len(model.Materials) == 0 {
+		return nil
+	}
+
+	return writeMTL(mtl, model)
+}
+
+// writeMTL writes a model's materials as an MTL file using the PBR extension
+func writeMTL(w io.Writer, model *Model) error {
+	out := bufio.NewWriter(w)
+
+	for _, material := range model.Materials {
+		fmt.Fprintf(out, "newmtl %s\n", material.Name)
+		fmt.Fprintf(out, "Kd %g %g %g\n", material.BaseColor[0], material.BaseColor[1], material.BaseColor[2])
+		if material.BaseColor[3] < 1 {
+			fmt.Fprintf(out, "d %g\n", material.BaseColor[3])
+		}
+		if material.EmissiveColor != [3]float64{} {
+			fmt.Fprintf(out, "Ke %g %g %g\n", material.EmissiveColor[0], material.EmissiveColor[1], material.EmissiveColor[2])
+		}
+
+		// Shininess for viewers without PBR support, Pr/Pm for those with it
+		roughness := math.Max(material.Roughness, 0.01)
+		fmt.Fprintf(out, "Ns %g\n", math.Min(2/(roughness*roughness)-2, 1000))
+		fmt.Fprintf(out, "Pr %g\n", material.Roughness)
+		fmt.Fprintf(out, "Pm %g\n", material.Metallic)
+
+		if uri := TextureURI(material, TextureSlotBaseColor); uri != "" {
+			fmt.Fprintf(out, "map_Kd %s\n", uri)
+		}
+		if uri := TextureURI(material, TextureSlotNormal); uri != "" {
+			fmt.Fprintf(out, "norm %s\n", uri)
+		}
+		if uri := TextureURI(material, TextureSlotEmissive); uri != "" {
+			fmt.Fprintf(out, "map_Ke %s\n", uri)
+		}
+		if TextureURI(material, TextureSlotMetallicRoughness) != "" || TextureURI(material, TextureSlotOcclusion) != "" {
+			model.warn("material %s: metallic-roughness and occlusion textures have no MTL equivalent and were dropped", material.Name)
+		}
+		if material.AlphaMode == AlphaMask {
+			model.warn("material %s: alpha mask cutoff is not supported by MTL, written as blended", material.Name)
+		}
+		if material.DoubleSided {
+			model.warn("material %s: double-sided flag is not supported by MTL", material.Name)
+		}
+
+		fmt.Fprintln(out)
+	}
+
+	return out.Flush()
+}
@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"sync"
+	"time"
+)
+
+// Quality is a set of rendering settings traded against frame time
+type Quality struct {
+	// Name identifies the level, such as in metrics
+	Name string
+
+	// RenderScale scales the render resolution; 1 renders at the full
+	// pixel ratio
+	RenderScale float64
+
+	// ShadowResolution caps the size of shadow maps in pixels; zero
+	// disables shadows
+	ShadowResolution int
+
+	// ParticleScale scales particle counts; see Particles
+	ParticleScale float64
+}
+
+// Particles returns how many of count particles to emit at this quality
+func (q Quality) Particles(count int) int {
+	if q.ParticleScale <= 0 {
+		return 0
+	}
+	scaled := int(float64(count)*q.ParticleScale + 0.5)
+	if scaled < 1 && count > 0 {
+		scaled = 1
+	}
+	return scaled
+}
+
+// DefaultQualityLevels returns the quality levels of a new QualityScaler,
+// from the best to the cheapest
+func DefaultQualityLevels() []Quality {
+	return []Quality{
+		{Name: "ultra", RenderScale: 1, ShadowResolution: 2048, ParticleScale: 1},
+		{Name: "high", RenderScale: 1, ShadowResolution: 1024, ParticleScale: 0.75},
+		{Name: "medium", RenderScale: 0.85, ShadowResolution: 512, ParticleScale: 0.5},
+		{Name: "low", RenderScale: 0.7, ShadowResolution: 256, ParticleScale: 0.25},
+		{Name: "minimal", RenderScale: 0.5, ShadowResolution: 0, ParticleScale: 0.1},
+	}
+}
+
+// QualityScaler lowers the rendering quality when frames take longer than
+// the frame budget, and raises it again once there is time to spare. Frame
+// times are averaged over a window of frames, and quality is raised only
+// after several windows in a row have headroom, so that it does not
+// oscillate between two levels.
+type QualityScaler struct {
+	// Levels are the quality levels, from the best to the cheapest
+	Levels []Quality
+
+	// Window is the number of frames averaged before each decision
+	Window int
+
+	// Headroom is the fraction of the frame budget the average frame time
+	// must stay under to raise the quality
+	Headroom float64
+
+	// Recovery is the number of windows in a row with headroom needed to
+	// raise the quality
+	Recovery int
+
+	level   int
+	total   time.Duration
+	frames  int
+	calm    int
+	changes int
+	mutex   sync.Mutex
+}
+
+// NewQualityScaler creates a quality scaler starting at the best of levels,
+// or of the default levels if none are given
+func NewQualityScaler(levels []Quality) *QualityScaler {
+	if len(levels) == 0 {
+		levels = DefaultQualityLevels()
+	}
+	return &QualityScaler{
+		Levels:   levels,
+		Window:   30,
+		Headroom: 0.6,
+		Recovery: 3,
+	}
+}
+
+// Observe records the time a frame took against the frame budget. It returns
+// the current quality, and whether it changed with this frame.
+func (s *QualityScaler) Observe(frameTime, budget time.Duration) (Quality, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.total += frameTime
+	s.frames++
+	window := s.Window
+	if window <= 0 {
+		window = 1
+	}
+	if s.frames < window {
+		return s.Levels[s.level], false
+	}
+
+	average := s.total / time.Duration(s.frames)
+	s.total, s.frames = 0, 0
+
+	switch {
+	case average > budget && s.level < len(s.Levels)-1:
+		s.calm = 0
+		s.setLevel(s.level + 1)
+		return s.Levels[s.level], true
+	case float64(average) < float64(budget)*s.Headroom && s.level > 0:
+		s.calm++
+		if s.calm >= s.Recovery {
+			s.calm = 0
+			s.setLevel(s.level - 1)
+			return s.Levels[s.level], true
+		}
+	default:
+		s.calm = 0
+	}
+	return s.Levels[s.level], false
+}
+
+// Reset returns to the best quality and discards the frames observed
+func (s *QualityScaler) Reset() Quality {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.total, s.frames, s.calm = 0, 0, 0
+	s.setLevel(0)
+	return s.Levels[0]
+}
+
+// setLevel moves to a level, counting the change
+func (s *QualityScaler) setLevel(level int) {
+	if level != s.level {
+		s.level = level
+		s.changes++
+	}
+}
+
+// Level returns the index of the current quality level, and the current
+// quality
+func (s *QualityScaler) Level() (int, Quality) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.level, s.Levels[s.level]
+}
+
+// Changes returns the number of times the quality has changed
+func (s *QualityScaler) Changes() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.changes
+}
@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQualityScalerLowersAndRecovers(t *testing.T) {
+	scaler := NewQualityScaler(nil)
+	scaler.Window = 10
+	budget := time.Second / 60
+
+	observe := func(frameTime time.Duration, frames int) (changes int) {
+		for i := 0; i < frames; i++ {
+			if _, changed := scaler.Observe(frameTime, budget); changed {
+				changes++
+			}
+		}
+		return changes
+	}
+
+	// Two windows over budget lower the quality twice
+	if changes := observe(25*time.Millisecond, 20); changes != 2 {
+		t.Fatalf("expected 2 changes, got %d", changes)
+	}
+	level, quality := scaler.Level()
+	if level != 2 || quality.Name != "medium" || quality.Particles(100) != 50 {
+		t.Fatalf("expected the medium level, got %d %+v", level, quality)
+	}
+
+	// Frames within budget but without headroom keep the level
+	if changes := observe(14*time.Millisecond, 50); changes != 0 {
+		t.Fatalf("expected no changes within budget, got %d", changes)
+	}
+
+	// Quality rises only after Recovery windows with headroom
+	if changes := observe(5*time.Millisecond, 20); changes != 0 {
+		t.Fatalf("expected no change before recovery, got %d", changes)
+	}
+	if changes := observe(5*time.Millisecond, 10); changes != 1 {
+		t.Fatalf("expected a change after recovery, got %d", changes)
+	}
+	if level, _ := scaler.Level(); level != 1 || scaler.Changes() != 3 {
+		t.Fatalf("expected level 1 after 3 changes, got %d after %d", level, scaler.Changes())
+	}
+
+	// The cheapest level is as low as it goes
+	observe(100*time.Millisecond, 100)
+	if level, quality := scaler.Level(); level != len(scaler.Levels)-1 || quality.ShadowResolution != 0 {
+		t.Fatalf("expected the cheapest level, got %d %+v", level, quality)
+	}
+}
+
+func TestThreeJSSceneAppliesEngineQuality(t *testing.T) {
+	engine := NewEngine(&EngineConfig{TargetFPS: 60, PerformanceLevel: PerformanceAdaptive})
+	scene := NewThreeJSScene(engine, nil)
+
+	engine.SetQualityLevels([]Quality{
+		{Name: "full", RenderScale: 1, ShadowResolution: 2048, ParticleScale: 1},
+		{Name: "half", RenderScale: 0.5, ShadowResolution: 512, ParticleScale: 0.5},
+	})
+	light := NewLight("sun", "Sun", "directional")
+	light.ShadowResolution = 4096
+	if got := scene.ShadowResolution(light); got != 2048 {
+		t.Fatalf("expected shadows capped at 2048, got %d", got)
+	}
+
+	engine.quality.Window = 1
+	if current, changed := engine.quality.Observe(40*time.Millisecond, time.Second/60); changed {
+		scene.ApplyQuality(current)
+	}
+	if width, height := scene.DrawingBufferSize(); width != 400 || height != 300 {
+		t.Fatalf("expected a 400x300 drawing buffer, got %dx%d", width, height)
+	}
+	if got := scene.ShadowResolution(light); got != 512 {
+		t.Fatalf("expected shadows capped at 512, got %d", got)
+	}
+
+	// Leaving the adaptive level returns to the best quality
+	engine.SetPerformanceLevel(PerformanceHigh)
+	if stats := engine.GetStats(); stats.QualityLevel != 0 || scene.Renderer.RenderScale != 1 {
+		t.Fatalf("expected the best quality, got level %d at scale %v", stats.QualityLevel, scene.Renderer.RenderScale)
+	}
+}
@@ -0,0 +1,178 @@
+package engine
+
+import (
+	"encoding/json"
+)
+
+// Canvas2DCommand is a drawing call recorded for the browser's canvas: a
+// method of CanvasRenderingContext2D and its arguments, or "set" and a
+// property and its value
+type Canvas2DCommand struct {
+	Op   string        `json:"op"`
+	Args []interface{} `json:"args,omitempty"`
+}
+
+// canvas2DRecording is the drawing a context recorded, and the state the
+// browser's context has after replaying it
+type canvas2DRecording struct {
+	commands []Canvas2DCommand
+	state    map[string]interface{}
+	saved    []map[string]interface{}
+}
+
+// canvas2DProperty is a property of the context the browser keeps between
+// calls
+type canvas2DProperty struct {
+	name  string
+	value interface{}
+}
+
+// NewCanvas2DRecorder creates a context with the canvas defaults that records
+// its drawing, for Canvas2DRuntime to replay in a browser canvas
+func NewCanvas2DRecorder() *Canvas2DContext {
+	ctx := &Canvas2DContext{
+		FillStyle:                "#000000",
+		StrokeStyle:              "#000000",
+		LineWidth:                1,
+		LineCap:                  "butt",
+		LineJoin:                 "miter",
+		MiterLimit:               10,
+		GlobalAlpha:              1,
+		GlobalCompositeOperation: "source-over",
+		Font:                     "10px sans-serif",
+		TextAlign:                "start",
+		TextBaseline:             "alphabetic",
+		ShadowColor:              "rgba(0, 0, 0, 0)",
+		TransformMatrix:          [6]float64{1, 0, 0, 1, 0, 0},
+		ClipRegion:               []Path2D{},
+		Stats:                    &Canvas2DStats{},
+	}
+
+	// The browser's context starts in the same state
+	state := make(map[string]interface{})
+	for _, property := range ctx.properties() {
+		state[property.name] = property.value
+	}
+	ctx.recording = &canvas2DRecording{state: state}
+	return ctx
+}
+
+// properties returns the state the browser's context keeps, in a fixed order
+func (ctx *Canvas2DContext) properties() []canvas2DProperty {
+	return []canvas2DProperty{
+		{"fillStyle", ctx.FillStyle},
+		{"strokeStyle", ctx.StrokeStyle},
+		{"lineWidth", ctx.LineWidth},
+		{"lineCap", ctx.LineCap},
+		{"lineJoin", ctx.LineJoin},
+		{"miterLimit", ctx.MiterLimit},
+		{"globalAlpha", ctx.GlobalAlpha},
+		{"globalCompositeOperation", ctx.GlobalCompositeOperation},
+		{"font", ctx.Font},
+		{"textAlign", ctx.TextAlign},
+		{"textBaseline", ctx.TextBaseline},
+	}
+}
+
+// record appends a drawing call when the context is recording, first
+// setting the properties changed since the last one
+func (ctx *Canvas2DContext) record(op string, args ...interface{}) {
+	r := ctx.recording
+	if r == nil {
+		return
+	}
+
+	if op == "restore" {
+		r.commands = append(r.commands, Canvas2DCommand{Op: op})
+		if n := len(r.saved); n > 0 {
+			r.state, r.saved = r.saved[n-1], r.saved[:n-1]
+		}
+		return
+	}
+
+	for _, property := range ctx.properties() {
+		if r.state[property.name] != property.value {
+			r.state[property.name] = property.value
+			r.commands = append(r.commands, Canvas2DCommand{Op: "set", Args: []interface{}{property.name, property.value}})
+		}
+	}
+	r.commands = append(r.commands, Canvas2DCommand{Op: op, Args: args})
+
+	if op == "save" {
+		saved := make(map[string]interface{}, len(r.state))
+		for name, value := range r.state {
+			saved[name] = value
+		}
+		r.saved = append(r.saved, saved)
+	}
+}
+
+// Commands returns the drawing the context recorded, or nil when it isn't
+// recording
+func (ctx *Canvas2DContext) Commands() []Canvas2DCommand {
+	if ctx.recording == nil {
+		return nil
+	}
+	return ctx.recording.commands
+}
+
+// Flush returns the recorded drawing as JSON and clears it, for a canvas's
+// data-gocsx-commands attribute or window.gocsxCanvas.draw
+func (ctx *Canvas2DContext) Flush() ([]byte, error) {
+	commands := []Canvas2DCommand{}
+	if ctx.recording != nil && ctx.recording.commands != nil {
+		commands = ctx.recording.commands
+		ctx.recording.commands = nil
+	}
+	return json.Marshal(commands)
+}
+
+// Canvas2DRuntime is the browser script that replays recorded drawing with
+// window.gocsxCanvas.draw(canvas, commands), and draws every canvas with a
+// data-gocsx-commands attribute when the page loads. Canvases are drawn at
+// the device's pixel ratio, their width and height attributes being CSS
+// pixels.
+const Canvas2DRuntime = `(function () {
+  var ops = {clearRect: 1, fillRect: 1, strokeRect: 1, fillText: 1, strokeText: 1,
+    beginPath: 1, closePath: 1, moveTo: 1, lineTo: 1, bezierCurveTo: 1, quadraticCurveTo: 1,
+    arc: 1, arcTo: 1, rect: 1, fill: 1, stroke: 1, clip: 1, save: 1, restore: 1,
+    scale: 1, rotate: 1, translate: 1, transform: 1};
+  function draw(canvas, commands) {
+    var ratio = window.devicePixelRatio || 1;
+    if (!canvas.gocsxSize) {
+      canvas.gocsxSize = [canvas.width, canvas.height];
+      canvas.style.width = canvas.width + "px";
+      canvas.style.height = canvas.height + "px";
+    }
+    var size = canvas.gocsxSize;
+    canvas.width = size[0] * ratio;
+    canvas.height = size[1] * ratio;
+    var ctx = canvas.getContext("2d");
+    ctx.setTransform(ratio, 0, 0, ratio, 0, 0);
+    (commands || []).forEach(function (c) {
+      var a = c.args || [];
+      if (c.op === "set") {
+        ctx[a[0]] = a[1];
+      } else if (c.op === "setTransform") {
+        ctx.setTransform(a[0] * ratio, a[1] * ratio, a[2] * ratio, a[3] * ratio, a[4] * ratio, a[5] * ratio);
+      } else if (c.op === "resetTransform") {
+        ctx.setTransform(ratio, 0, 0, ratio, 0, 0);
+      } else if (ops[c.op]) {
+        ctx[c.op].apply(ctx, a);
+      }
+    });
+  }
+  function drawAll(root) {
+    var canvases = (root || document).querySelectorAll("canvas[data-gocsx-commands]");
+    Array.prototype.forEach.call(canvases, function (canvas) {
+      try { draw(canvas, JSON.parse(canvas.getAttribute("data-gocsx-commands"))); } catch (e) {}
+    });
+  }
+  window.gocsxCanvas = { draw: draw, drawAll: drawAll };
+  if (document.readyState === "loading") {
+    document.addEventListener("DOMContentLoaded", function () { drawAll(); });
+  } else {
+    drawAll();
+  }
+})();
+`
@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCanvas2DRecorder(t *testing.T) {
+	ctx := NewCanvas2DRecorder()
+	ctx.FillStyle = "#ff0000"
+	ctx.FillRect(0, 0, 10, 10)
+	ctx.FillRect(10, 0, 10, 10)
+	ctx.Save()
+	ctx.FillStyle = "#00ff00"
+	ctx.FillText("hi", 1, 2)
+	ctx.Restore()
+	ctx.FillStyle = "#ff0000"
+	ctx.FillRect(20, 0, 10, 10)
+
+	// Properties are set only when they change, and the browser's restore
+	// brings back the red the green replaced
+	want := []Canvas2DCommand{
+		{Op: "set", Args: []interface{}{"fillStyle", "#ff0000"}},
+		{Op: "fillRect", Args: []interface{}{0.0, 0.0, 10.0, 10.0}},
+		{Op: "fillRect", Args: []interface{}{10.0, 0.0, 10.0, 10.0}},
+		{Op: "save"},
+		{Op: "set", Args: []interface{}{"fillStyle", "#00ff00"}},
+		{Op: "fillText", Args: []interface{}{"hi", 1.0, 2.0}},
+		{Op: "restore"},
+		{Op: "fillRect", Args: []interface{}{20.0, 0.0, 10.0, 10.0}},
+	}
+	if got := ctx.Commands(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected commands\n got %v\nwant %v", got, want)
+	}
+	if ctx.Stats.FillCalls != 4 {
+		t.Fatalf("expected the stats kept while recording, got %d fills", ctx.Stats.FillCalls)
+	}
+
+	data, err := ctx.Flush()
+	if err != nil || !strings.HasPrefix(string(data), `[{"op":"set","args":["fillStyle","#ff0000"]}`) {
+		t.Fatalf("unexpected JSON %s, %v", data, err)
+	}
+	if ctx.Commands() != nil {
+		t.Fatalf("expected Flush to clear the commands")
+	}
+
+	// Contexts that aren't recording keep nothing
+	plain := &Canvas2DContext{Stats: &Canvas2DStats{}}
+	plain.FillRect(0, 0, 1, 1)
+	if plain.Commands() != nil {
+		t.Fatalf("expected no commands without recording")
+	}
+}
@@ -450,6 +450,12 @@ type Camera struct {
 	// Viewport
 	Viewport [4]float64
 	
+	// Target is the world-space point the camera looks at
+	Target [3]float64
+	
+	// Up is the camera's up direction
+	Up [3]float64
+	
 	// Projection matrix
 	ProjectionMatrix [16]float64
 	
@@ -474,6 +480,8 @@ func NewCamera(id, name string) *Camera {
 		ClearColor:       [4]float64{0.2, 0.3, 0.3, 1.0},
 		ClearFlags:       0x1, // Clear color and depth
 		Viewport:         [4]float64{0, 0, 1, 1},
+		Target:           [3]float64{0, 0, 0},
+		Up:               [3]float64{0, 1, 0},
 		ProjectionMatrix: [16]float64{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1},
 		ViewMatrix:       [16]float64{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1},
 	}
@@ -493,10 +501,30 @@ func (c *Camera) OnUpdate(deltaTime float64) {
 
 // UpdateMatrices updates the camera matrices
 func (c *Camera) UpdateMatrices() {
-	// This would normally update the projection and view matrices
-	// For now, we'll just use identity matrices
-	c.ProjectionMatrix = [16]float64{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1}
-	c.ViewMatrix = [16]float64{1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1}
+	aspect := c.AspectRatio
+	if aspect <= 0 {
+		aspect = 1
+	}
+	
+	if c.Orthographic {
+		c.ProjectionMatrix = orthographicMatrix(c.OrthographicSize, aspect, c.NearClip, c.FarClip)
+	} else {
+		c.ProjectionMatrix = perspectiveMatrix(c.FieldOfView, aspect, c.NearClip, c.FarClip)
+	}
+	
+	// Without an object the camera sits at the origin
+	var eye [3]float64
+	if c.Object != nil {
+		eye = c.Object.Position
+	}
+	
+	c.ViewMatrix = lookAtMatrix(eye, c.Target, c.Up)
+}
+
+// LookAt points the camera at a world-space target
+func (c *Camera) LookAt(target [3]float64) {
+	c.Target = target
+	c.UpdateMatrices()
 }
 
 // MeshRenderer represents a mesh renderer component
@@ -515,6 +543,9 @@ type MeshRenderer struct {
 	// Receive shadows
 	ReceiveShadows bool
 	
+	// Static renderers never move and can be merged into static batches
+	Static bool
+	
 	// Bounds
 	Bounds [6]float64 // min x, min y, min z, max x, max y, max z
 }
@@ -572,6 +603,9 @@ type Material struct {
 	// Material name
 	Name string
 	
+	// Material type
+	Type MaterialType
+	
 	// Shader
 	Shader *GPUShader
 	
@@ -583,6 +617,30 @@ type Material struct {
 	
 	// Render queue
 	RenderQueue int
+	
+	// Base color (linear RGBA)
+	BaseColor [4]float64
+	
+	// Metallic factor (PBR only)
+	Metallic float64
+	
+	// Roughness factor (PBR only)
+	Roughness float64
+	
+	// Emissive color
+	EmissiveColor [3]float64
+	
+	// Alpha mode
+	AlphaMode AlphaMode
+	
+	// Alpha cutoff for AlphaMask
+	AlphaCutoff float64
+	
+	// Render both faces
+	DoubleSided bool
+	
+	// Parent material for instances
+	Parent *Material
 }
 
 // Light represents a light component
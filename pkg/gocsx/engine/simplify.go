@@ -0,0 +1,400 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+)
+
+// SimplifyMesh reduces a mesh to roughly ratio of its triangles using vertex
+// clustering. Vertices that fall into the same grid cell are merged and
+// triangles that collapse are removed. The grid resolution is searched so the
+// result has as many triangles as possible without exceeding the target.
+func SimplifyMesh(mesh *Mesh, ratio float64) *Mesh {
+	triangles := meshTriangles(mesh)
+	if ratio >= 1 || triangles == 0 {
+		return cloneMesh(mesh)
+	}
+
+	target := int(float64(triangles) * ratio)
+	if target < 1 {
+		target = 1
+	}
+
+	// Binary search the number of cells along the longest axis. Keep the
+	// largest result within the target; if the mesh cannot be reduced that far
+	// without collapsing entirely, keep the smallest non-empty result instead.
+	var best, fallback *Mesh
+	low, high := 1, 1024
+	for low <= high {
+		cells := (low + high) / 2
+		candidate := clusterMesh(mesh, cells)
+		count := meshTriangles(candidate)
+
+		if count > 0 && (fallback == nil || count < meshTriangles(fallback)) {
+			fallback = candidate
+		}
+
+		if count <= target {
+			if count > 0 && (best == nil || count > meshTriangles(best)) {
+				best = candidate
+			}
+			low = cells + 1
+		} else {
+			high = cells - 1
+		}
+	}
+
+	if best == nil {
+		best = fallback
+	}
+	if best == nil {
+		best = cloneMesh(mesh)
+	}
+
+	best.ID = fmt.Sprintf("%s-lod", mesh.ID)
+	best.Name = fmt.Sprintf("%s LOD", mesh.Name)
+
+	return best
+}
+
+// clusterMesh merges vertices on a grid with the given number of cells along the longest axis
+func clusterMesh(mesh *Mesh, cells int) *Mesh {
+	bounds := mesh.Bounds
+	size := math.Max(bounds[3]-bounds[0], math.Max(bounds[4]-bounds[1], bounds[5]-bounds[2]))
+	if size <= 0 {
+		size = 1
+	}
+	cellSize := size / float64(cells)
+
+	hasNormals := len(mesh.Normals) == len(mesh.Vertices)
+	hasUVs := len(mesh.UVs) == len(mesh.Vertices)
+	hasColors := len(mesh.Colors) == len(mesh.Vertices)
+
+	type cluster struct {
+		position [3]float64
+		normal   [3]float64
+		uv       [2]float64
+		color    [4]float64
+		count    float64
+		index    int
+	}
+
+	clusters := make(map[[3]int]*cluster)
+	var order []*cluster
+	remap := make([]int, len(mesh.Vertices))
+
+	for i, vertex := range mesh.Vertices {
+		var key [3]int
+		for axis := 0; axis < 3; axis++ {
+			// Vertices on the max bound belong to the last cell
+			cell := int(math.Floor((vertex[axis] - bounds[axis]) / cellSize))
+			if cell >= cells {
+				cell = cells - 1
+			}
+			if cell < 0 {
+				cell = 0
+			}
+			key[axis] = cell
+		}
+
+		c, ok := clusters[key]
+		if !ok {
+			c = &cluster{index: len(order)}
+			clusters[key] = c
+			order = append(order, c)
+		}
+
+		c.position = vec3Add(c.position, vertex)
+		if hasNormals {
+			c.normal = vec3Add(c.normal, mesh.Normals[i])
+		}
+		if hasUVs {
+			c.uv[0] += mesh.UVs[i][0]
+			c.uv[1] += mesh.UVs[i][1]
+		}
+		if hasColors {
+			for channel := 0; channel < 4; channel++ {
+				c.color[channel] += mesh.Colors[i][channel]
+			}
+		}
+		c.count++
+		remap[i] = c.index
+	}
+
+	simplified := &Mesh{
+		ID:     mesh.ID,
+		Name:   mesh.Name,
+		Bounds: mesh.Bounds,
+	}
+
+	for _, c := range order {
+		simplified.Vertices = append(simplified.Vertices, vec3Scale(c.position, 1/c.count))
+		if hasNormals {
+			simplified.Normals = append(simplified.Normals, vec3Normalize(c.normal))
+		}
+		if hasUVs {
+			simplified.UVs = append(simplified.UVs, [2]float64{c.uv[0] / c.count, c.uv[1] / c.count})
+		}
+		if hasColors {
+			simplified.Colors = append(simplified.Colors, [4]float64{
+				c.color[0] / c.count, c.color[1] / c.count, c.color[2] / c.count, c.color[3] / c.count,
+			})
+		}
+	}
+
+	// Keep triangles whose corners still map to three distinct clusters
+	seen := make(map[[3]int]bool)
+	for _, triangle := range meshTriangleIndices(mesh) {
+		a, b, c := remap[triangle[0]], remap[triangle[1]], remap[triangle[2]]
+		if a == b || b == c || a == c {
+			continue
+		}
+
+		// Rotate so the smallest index is first, preserving winding
+		key := [3]int{a, b, c}
+		if b < a && b < c {
+			key = [3]int{b, c, a}
+		} else if c < a && c < b {
+			key = [3]int{c, a, b}
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		simplified.Indices = append(simplified.Indices, a, b, c)
+	}
+
+	return simplified
+}
+
+// meshTriangleIndices returns the vertex indices of each triangle in a mesh
+func meshTriangleIndices(mesh *Mesh) [][3]int {
+	var triangles [][3]int
+
+	if len(mesh.Indices) > 0 {
+		for i := 0; i+2 < len(mesh.Indices); i += 3 {
+			triangles = append(triangles, [3]int{mesh.Indices[i], mesh.Indices[i+1], mesh.Indices[i+2]})
+		}
+		return triangles
+	}
+
+	for i := 0; i+2 < len(mesh.Vertices); i += 3 {
+		triangles = append(triangles, [3]int{i, i + 1, i + 2})
+	}
+
+	return triangles
+}
+
+// cloneMesh returns a deep copy of a mesh
+func cloneMesh(mesh *Mesh) *Mesh {
+	clone := *mesh
+	clone.Vertices = append([][3]float64(nil), mesh.Vertices...)
+	clone.Normals = append([][3]float64(nil), mesh.Normals...)
+	clone.UVs = append([][2]float64(nil), mesh.UVs...)
+	clone.Colors = append([][4]float64(nil), mesh.Colors...)
+	clone.Indices = append([]int(nil), mesh.Indices...)
+	clone.Submeshes = nil
+	for _, submesh := range mesh.Submeshes {
+		clone.Submeshes = append(clone.Submeshes, append([]int(nil), submesh...))
+	}
+
+	return &clone
+}
+
+// ComputeBounds recalculates a mesh's bounds from its vertices
+func (m *Mesh) ComputeBounds() {
+	if len(m.Vertices) == 0 {
+		m.Bounds = [6]float64{}
+		return
+	}
+
+	m.Bounds = [6]float64{
+		math.Inf(1), math.Inf(1), math.Inf(1),
+		math.Inf(-1), math.Inf(-1), math.Inf(-1),
+	}
+	for _, vertex := range m.Vertices {
+		for axis := 0; axis < 3; axis++ {
+			m.Bounds[axis] = math.Min(m.Bounds[axis], vertex[axis])
+			m.Bounds[axis+3] = math.Max(m.Bounds[axis+3], vertex[axis])
+		}
+	}
+}
+
+// LODLevel is one level of detail in an LOD chain
+type LODLevel struct {
+	// Mesh drawn at this level
+	Mesh *Mesh
+
+	// ScreenSize is the minimum fraction of the screen height the object must
+	// cover for this level to be used
+	ScreenSize float64
+}
+
+// DefaultLODRatios are the triangle ratios used by GenerateLODs when none are given
+var DefaultLODRatios = []float64{0.5, 0.25, 0.125}
+
+// GenerateLODs builds an LOD chain for a mesh. The first level is the original
+// mesh; each ratio adds a simplified level that is used at half the screen
+// size of the previous one.
+func GenerateLODs(mesh *Mesh, ratios []float64) []LODLevel {
+	if len(ratios) == 0 {
+		ratios = DefaultLODRatios
+	}
+
+	levels := []LODLevel{{Mesh: mesh, ScreenSize: 0.5}}
+	screenSize := 0.5
+	for i, ratio := range ratios {
+		screenSize /= 2
+		lod := SimplifyMesh(mesh, ratio)
+		lod.ID = fmt.Sprintf("%s-lod%d", mesh.ID, i+1)
+		lod.Name = fmt.Sprintf("%s LOD %d", mesh.Name, i+1)
+		levels = append(levels, LODLevel{Mesh: lod, ScreenSize: screenSize})
+	}
+
+	// The last level is used at any distance
+	levels[len(levels)-1].ScreenSize = 0
+
+	return levels
+}
+
+// LODReport summarizes the savings of an LOD chain
+type LODReport struct {
+	// Levels in the chain
+	Levels []LODReportLevel
+}
+
+// LODReportLevel describes one level of an LOD chain
+type LODReportLevel struct {
+	Level      int
+	Vertices   int
+	Triangles  int
+	ScreenSize float64
+
+	// Savings relative to the original mesh, as a percentage
+	VertexSavings   float64
+	TriangleSavings float64
+}
+
+// NewLODReport builds a report for an LOD chain
+func NewLODReport(levels []LODLevel) *LODReport {
+	report := &LODReport{}
+	if len(levels) == 0 {
+		return report
+	}
+
+	baseVertices := len(levels[0].Mesh.Vertices)
+	baseTriangles := meshTriangles(levels[0].Mesh)
+
+	savings := func(value, base int) float64 {
+		if base == 0 {
+			return 0
+		}
+		return 100 * float64(base-value) / float64(base)
+	}
+
+	for i, level := range levels {
+		vertices := len(level.Mesh.Vertices)
+		triangles := meshTriangles(level.Mesh)
+		report.Levels = append(report.Levels, LODReportLevel{
+			Level:           i,
+			Vertices:        vertices,
+			Triangles:       triangles,
+			ScreenSize:      level.ScreenSize,
+			VertexSavings:   savings(vertices, baseVertices),
+			TriangleSavings: savings(triangles, baseTriangles),
+		})
+	}
+
+	return report
+}
+
+// String formats the report as a table
+func (r *LODReport) String() string {
+	out := fmt.Sprintf("%-6s %10s %10s %12s %10s %10s\n", "LOD", "Vertices", "Triangles", "Screen size", "Vert -%", "Tri -%")
+	for _, level := range r.Levels {
+		out += fmt.Sprintf("%-6d %10d %10d %12.3f %9.1f%% %9.1f%%\n",
+			level.Level, level.Vertices, level.Triangles, level.ScreenSize, level.VertexSavings, level.TriangleSavings)
+	}
+	return out
+}
+
+// LODGroup is a component that switches a mesh renderer between levels of
+// detail based on the object's projected size on screen
+type LODGroup struct {
+	BaseComponent
+
+	// Levels ordered from most to least detailed
+	Levels []LODLevel
+
+	// Renderer whose mesh is switched
+	Renderer *MeshRenderer
+
+	// Index of the current level
+	Current int
+}
+
+// NewLODGroup creates a new LOD group component
+func NewLODGroup(id, name string, renderer *MeshRenderer, levels []LODLevel) *LODGroup {
+	return &LODGroup{
+		BaseComponent: BaseComponent{
+			ID:      id,
+			Name:    name,
+			Enabled: true,
+		},
+		Levels:   levels,
+		Renderer: renderer,
+	}
+}
+
+// ScreenSize returns the fraction of the screen height covered by the object's bounds
+func (g *LODGroup) ScreenSize(camera *Camera) float64 {
+	if g.Renderer == nil || g.Renderer.Mesh == nil || camera == nil {
+		return 1
+	}
+
+	bounds := g.Renderer.WorldBounds()
+	center := [3]float64{(bounds[0] + bounds[3]) / 2, (bounds[1] + bounds[4]) / 2, (bounds[2] + bounds[5]) / 2}
+	radius := vec3Length([3]float64{bounds[3] - center[0], bounds[4] - center[1], bounds[5] - center[2]})
+
+	if camera.Orthographic {
+		if camera.OrthographicSize <= 0 {
+			return 1
+		}
+		return radius / camera.OrthographicSize
+	}
+
+	var eye [3]float64
+	if camera.Object != nil {
+		eye = camera.Object.Position
+	}
+
+	distance := vec3Length(vec3Sub(center, eye))
+	if distance <= radius {
+		return 1
+	}
+
+	return radius / (distance * math.Tan(camera.FieldOfView*math.Pi/360))
+}
+
+// Select picks the level for the camera and applies it to the renderer
+func (g *LODGroup) Select(camera *Camera) int {
+	if len(g.Levels) == 0 || g.Renderer == nil {
+		return 0
+	}
+
+	size := g.ScreenSize(camera)
+
+	level := len(g.Levels) - 1
+	for i, candidate := range g.Levels {
+		if size >= candidate.ScreenSize {
+			level = i
+			break
+		}
+	}
+
+	g.Current = level
+	g.Renderer.Mesh = g.Levels[level].Mesh
+
+	return level
+}
@@ -0,0 +1,135 @@
+package engine
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// gridMesh builds a flat n x n grid of quads on the XZ plane
+func gridMesh(n int) *Mesh {
+	mesh := &Mesh{ID: "grid", Name: "Grid"}
+	for z := 0; z <= n; z++ {
+		for x := 0; x <= n; x++ {
+			mesh.Vertices = append(mesh.Vertices, [3]float64{float64(x), 0, float64(z)})
+		}
+	}
+	for z := 0; z < n; z++ {
+		for x := 0; x < n; x++ {
+			a := z*(n+1) + x
+			b := a + 1
+			c := a + n + 1
+			d := c + 1
+			mesh.Indices = append(mesh.Indices, a, c, b, b, c, d)
+		}
+	}
+	mesh.ComputeBounds()
+	return mesh
+}
+
+func TestSimplifyMeshReducesTriangles(t *testing.T) {
+	mesh := gridMesh(32)
+	original := meshTriangles(mesh)
+
+	simplified := SimplifyMesh(mesh, 0.25)
+	count := meshTriangles(simplified)
+
+	if count == 0 || count > original/4 {
+		t.Fatalf("expected between 1 and %d triangles, got %d", original/4, count)
+	}
+
+	for _, index := range simplified.Indices {
+		if index < 0 || index >= len(simplified.Vertices) {
+			t.Fatalf("index %d out of range for %d vertices", index, len(simplified.Vertices))
+		}
+	}
+
+	if meshTriangles(mesh) != original {
+		t.Fatalf("SimplifyMesh modified the source mesh")
+	}
+}
+
+func TestGenerateLODsOrdersLevels(t *testing.T) {
+	levels := GenerateLODs(gridMesh(16), []float64{0.5, 0.25})
+	if len(levels) != 3 {
+		t.Fatalf("expected 3 levels, got %d", len(levels))
+	}
+
+	for i := 1; i < len(levels); i++ {
+		if meshTriangles(levels[i].Mesh) > meshTriangles(levels[i-1].Mesh) {
+			t.Errorf("level %d has more triangles than level %d", i, i-1)
+		}
+		if levels[i].ScreenSize >= levels[i-1].ScreenSize {
+			t.Errorf("level %d screen size %f is not below level %d", i, levels[i].ScreenSize, i-1)
+		}
+	}
+
+	report := NewLODReport(levels)
+	if report.Levels[0].TriangleSavings != 0 || report.Levels[2].TriangleSavings <= 0 {
+		t.Fatalf("unexpected savings: %+v", report.Levels)
+	}
+}
+
+func TestLODGroupSelectsByDistance(t *testing.T) {
+	scene := NewScene("scene", "Scene")
+	object := scene.CreateObject("grid", "Grid")
+	renderer := NewMeshRenderer("grid-renderer", "Grid Renderer")
+	renderer.Mesh = gridMesh(8)
+	scene.AddComponent(object, renderer)
+
+	lod := NewLODGroup("grid-lod", "Grid LOD", renderer, GenerateLODs(renderer.Mesh, nil))
+
+	cameraObject := scene.CreateObject("camera", "Camera")
+	camera := NewCamera("camera", "Camera")
+	scene.AddComponent(cameraObject, camera)
+
+	cameraObject.Position = [3]float64{4, 2, 10}
+	if level := lod.Select(camera); level != 0 {
+		t.Fatalf("expected level 0 up close, got %d", level)
+	}
+
+	cameraObject.Position = [3]float64{4, 2, 1000}
+	if level := lod.Select(camera); level != len(lod.Levels)-1 {
+		t.Fatalf("expected last level far away, got %d", level)
+	}
+	if renderer.Mesh != lod.Levels[len(lod.Levels)-1].Mesh {
+		t.Fatalf("renderer mesh was not switched")
+	}
+}
+
+func TestParseOBJRoundTrip(t *testing.T) {
+	source := `v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+vn 0 0 1
+f 1//1 2//1 3//1 4//1
+`
+	mesh, err := ParseOBJ(strings.NewReader(source), "quad")
+	if err != nil {
+		t.Fatalf("ParseOBJ returned error: %v", err)
+	}
+	if meshTriangles(mesh) != 2 || len(mesh.Vertices) != 4 || len(mesh.Normals) != 4 {
+		t.Fatalf("unexpected mesh: %d triangles, %d vertices, %d normals", meshTriangles(mesh), len(mesh.Vertices), len(mesh.Normals))
+	}
+	if math.Abs(mesh.Bounds[3]-1) > 1e-9 {
+		t.Fatalf("unexpected bounds %v", mesh.Bounds)
+	}
+
+	var out strings.Builder
+	if err := WriteOBJ(&out, mesh); err != nil {
+		t.Fatalf("WriteOBJ returned error: %v", err)
+	}
+
+	again, err := ParseOBJ(strings.NewReader(out.String()), "quad")
+	if err != nil {
+		t.Fatalf("ParseOBJ of written model returned error: %v", err)
+	}
+	if meshTriangles(again) != 2 || len(again.Vertices) != 4 {
+		t.Fatalf("round trip changed the mesh: %d triangles, %d vertices", meshTriangles(again), len(again.Vertices))
+	}
+
+	if _, err := ParseOBJ(strings.NewReader("f 1 2 3\n"), "bad"); err == nil {
+		t.Fatalf("expected error for face without vertices")
+	}
+}
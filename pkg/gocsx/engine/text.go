@@ -0,0 +1,518 @@
+package engine
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Font is a canvas font, as in the CSS font shorthand "italic bold 14px sans-serif"
+type Font struct {
+	Family string
+	Size   float64
+	Weight int
+	Italic bool
+}
+
+// ParseFont parses a CSS font shorthand such as "bold 14px sans-serif",
+// keeping the defaults of "10px sans-serif" for what it leaves out
+func ParseFont(css string) Font {
+	font := Font{Family: "sans-serif", Size: 10, Weight: 400}
+	fields := strings.Fields(css)
+	for i, field := range fields {
+		switch {
+		case field == "italic" || field == "oblique":
+			font.Italic = true
+		case field == "bold":
+			font.Weight = 700
+		case field == "lighter":
+			font.Weight = 300
+		case field == "bolder":
+			font.Weight = 800
+		case len(field) == 3 && strings.HasSuffix(field, "00"):
+			if weight, err := strconv.Atoi(field); err == nil {
+				font.Weight = weight
+			}
+		case strings.HasSuffix(field, "px") || strings.Contains(field, "px/"):
+			size := strings.SplitN(field, "/", 2)[0]
+			if px, err := strconv.ParseFloat(strings.TrimSuffix(size, "px"), 64); err == nil {
+				font.Size = px
+			}
+			// The family is the rest of the shorthand
+			if rest := strings.Join(fields[i+1:], " "); rest != "" {
+				font.Family = rest
+			}
+			return font
+		}
+	}
+	return font
+}
+
+// String returns the font as a CSS font shorthand, for Canvas2DContext.Font
+func (f Font) String() string {
+	var parts []string
+	if f.Italic {
+		parts = append(parts, "italic")
+	}
+	if f.Weight != 0 && f.Weight != 400 {
+		parts = append(parts, strconv.Itoa(f.Weight))
+	}
+	parts = append(parts, strconv.FormatFloat(f.Size, 'f', -1, 64)+"px", f.Family)
+	return strings.Join(parts, " ")
+}
+
+// TextMeasurer measures the advance of text in a font, such as from the
+// browser's measureText or a font file's glyph metrics
+type TextMeasurer interface {
+	MeasureText(text string, font Font) float64
+}
+
+// ApproxMeasurer estimates advances from typical glyph widths of
+// proportional fonts, for layouts computed without the real font
+type ApproxMeasurer struct{}
+
+// MeasureText estimates the advance of text in font
+func (ApproxMeasurer) MeasureText(text string, font Font) float64 {
+	monospace := strings.Contains(font.Family, "mono")
+	var ems float64
+	for _, r := range text {
+		switch {
+		case monospace:
+			ems += 0.6
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+			ems += 1
+		case strings.ContainsRune("iljtfrI.,:;'!|() ", r):
+			ems += 0.3
+		case strings.ContainsRune("mwMW@", r):
+			ems += 0.85
+		case unicode.IsUpper(r):
+			ems += 0.68
+		case unicode.IsDigit(r):
+			ems += 0.56
+		default:
+			ems += 0.52
+		}
+	}
+	if font.Weight >= 600 {
+		ems *= 1.06
+	}
+	return ems * font.Size
+}
+
+// Font metrics relative to the font size, for fonts without their own
+const (
+	fontAscent  = 0.8
+	fontDescent = 0.2
+)
+
+// TextSpan is a run of text with its own style; zero values use the
+// layout's
+type TextSpan struct {
+	Text   string
+	Color  string
+	Size   float64
+	Weight int
+	Italic bool
+	Family string
+}
+
+// TextLayoutOptions are the defaults of the spans and how they are laid out
+type TextLayoutOptions struct {
+	// Font and Color style the spans that do not set their own
+	Font  Font
+	Color string
+
+	// MaxWidth wraps lines at word boundaries; zero only breaks at "\n".
+	// Words wider than it are broken between characters.
+	MaxWidth float64
+
+	// Align is "left" (or "start", the default), "center" or "right" (or
+	// "end"), within MaxWidth or the widest line
+	Align string
+
+	// LineHeight is the height of a line relative to its largest font
+	// size, 1.2 by default
+	LineHeight float64
+}
+
+// TextRun is a piece of a line drawn with one style, positioned relative to
+// the layout's top left corner, with Y its baseline
+type TextRun struct {
+	Text  string
+	Font  Font
+	Color string
+	X, Y  float64
+	Width float64
+
+	// Bounds is {minX, minY, maxX, maxY}, from the font's ascent to its
+	// descent
+	Bounds [4]float64
+}
+
+// TextLine is a laid out line of runs
+type TextLine struct {
+	Runs     []TextRun
+	Width    float64
+	Baseline float64
+	Bounds   [4]float64
+}
+
+// TextLayout is text laid out in lines, ready to be drawn with
+// Canvas2DContext.FillTextLayout and hit tested with its bounds
+type TextLayout struct {
+	Lines  []TextLine
+	Width  float64
+	Height float64
+
+	// Bounds is {minX, minY, maxX, maxY} of the lines' bounds
+	Bounds [4]float64
+}
+
+// textPiece is a word, a space or a line break of a span
+type textPiece struct {
+	text  string
+	span  int
+	space bool
+	br    bool
+}
+
+// splitSpans splits the spans into words, spaces and line breaks
+func splitSpans(spans []TextSpan) []textPiece {
+	var pieces []textPiece
+	for i, span := range spans {
+		text := span.Text
+		for text != "" {
+			r, size := utf8.DecodeRuneInString(text)
+			switch {
+			case r == '\n':
+				pieces = append(pieces, textPiece{span: i, br: true})
+				text = text[size:]
+			case unicode.IsSpace(r):
+				end := strings.IndexFunc(text, func(r rune) bool { return !unicode.IsSpace(r) || r == '\n' })
+				if end < 0 {
+					end = len(text)
+				}
+				pieces = append(pieces, textPiece{text: text[:end], span: i, space: true})
+				text = text[end:]
+			default:
+				end := strings.IndexFunc(text, unicode.IsSpace)
+				if end < 0 {
+					end = len(text)
+				}
+				pieces = append(pieces, textPiece{text: text[:end], span: i})
+				text = text[end:]
+			}
+		}
+	}
+	return pieces
+}
+
+// LayoutText lays out rich text: the spans are broken into lines at "\n"
+// and, past MaxWidth, between words, then aligned, with each run measured
+func LayoutText(spans []TextSpan, options TextLayoutOptions, measurer TextMeasurer) *TextLayout {
+	if measurer == nil {
+		measurer = ApproxMeasurer{}
+	}
+	if options.Font.Size <= 0 {
+		options.Font.Size = 10
+	}
+	if options.Font.Family == "" {
+		options.Font.Family = "sans-serif"
+	}
+	if options.Font.Weight == 0 {
+		options.Font.Weight = 400
+	}
+	if options.LineHeight <= 0 {
+		options.LineHeight = 1.2
+	}
+
+	fonts := make([]Font, len(spans))
+	colors := make([]string, len(spans))
+	for i, span := range spans {
+		fonts[i], colors[i] = options.Font, options.Color
+		if span.Size > 0 {
+			fonts[i].Size = span.Size
+		}
+		if span.Weight > 0 {
+			fonts[i].Weight = span.Weight
+		}
+		if span.Italic {
+			fonts[i].Italic = true
+		}
+		if span.Family != "" {
+			fonts[i].Family = span.Family
+		}
+		if span.Color != "" {
+			colors[i] = span.Color
+		}
+	}
+
+	builder := &lineBuilder{fonts: fonts, colors: colors, measurer: measurer}
+	for _, piece := range splitSpans(spans) {
+		switch {
+		case piece.br:
+			builder.breakLine(piece.span)
+		case piece.space:
+			// Spaces at the start of wrapped lines are dropped
+			if len(builder.line.Runs) > 0 || !builder.wrapped {
+				builder.add(piece.text, piece.span)
+			}
+		default:
+			width := measurer.MeasureText(piece.text, fonts[piece.span])
+			if options.MaxWidth > 0 && builder.width()+width > options.MaxWidth && builder.hasText() {
+				builder.wrap()
+			}
+			if options.MaxWidth > 0 && width > options.MaxWidth {
+				builder.addBroken(piece.text, piece.span, options.MaxWidth)
+				continue
+			}
+			builder.add(piece.text, piece.span)
+		}
+	}
+	builder.finish(len(spans) - 1)
+
+	return arrangeLines(builder.lines, options)
+}
+
+// lineBuilder collects the runs of the lines being laid out
+type lineBuilder struct {
+	fonts    []Font
+	colors   []string
+	measurer TextMeasurer
+	lines    []TextLine
+	line     TextLine
+	spans    []int
+	wrapped  bool
+}
+
+// width returns the width of the current line
+func (b *lineBuilder) width() float64 {
+	return b.line.Width
+}
+
+// hasText reports whether the current line has more than spaces
+func (b *lineBuilder) hasText() bool {
+	for _, run := range b.line.Runs {
+		if strings.TrimSpace(run.Text) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// add appends text to the line, merging it into the last run of the same span
+func (b *lineBuilder) add(text string, span int) {
+	font := b.fonts[span]
+	width := b.measurer.MeasureText(text, font)
+	if n := len(b.line.Runs); n > 0 && b.spans[n-1] == span {
+		run := &b.line.Runs[n-1]
+		run.Text += text
+		run.Width = b.measurer.MeasureText(run.Text, font)
+	} else {
+		b.line.Runs = append(b.line.Runs, TextRun{Text: text, Font: font, Color: b.colors[span], X: b.line.Width, Width: width})
+		b.spans = append(b.spans, span)
+	}
+	b.line.Width = 0
+	for _, run := range b.line.Runs {
+		b.line.Width += run.Width
+	}
+}
+
+// addBroken adds a word wider than maxWidth, breaking it between characters
+func (b *lineBuilder) addBroken(word string, span int, maxWidth float64) {
+	font := b.fonts[span]
+	start := 0
+	for i, r := range word {
+		end := i + utf8.RuneLen(r)
+		if i > start && b.width()+b.measurer.MeasureText(word[start:end], font) > maxWidth {
+			b.add(word[start:i], span)
+			b.wrap()
+			start = i
+		}
+	}
+	b.add(word[start:], span)
+}
+
+// wrap ends the line because it is full
+func (b *lineBuilder) wrap() {
+	b.end(-1)
+	b.wrapped = true
+}
+
+// breakLine ends the line at a "\n"
+func (b *lineBuilder) breakLine(span int) {
+	b.end(span)
+	b.wrapped = false
+}
+
+// finish ends the last line
+func (b *lineBuilder) finish(span int) {
+	if len(b.line.Runs) > 0 || len(b.lines) == 0 {
+		b.end(span)
+	}
+}
+
+// end closes the line, trimming trailing spaces; an empty line takes the
+// height of span's font
+func (b *lineBuilder) end(span int) {
+	for n := len(b.line.Runs); n > 0; n = len(b.line.Runs) {
+		run := &b.line.Runs[n-1]
+		trimmed := strings.TrimRightFunc(run.Text, unicode.IsSpace)
+		if trimmed != "" {
+			run.Text = trimmed
+			run.Width = b.measurer.MeasureText(trimmed, run.Font)
+			break
+		}
+		b.line.Runs = b.line.Runs[:n-1]
+	}
+	b.line.Width = 0
+	for _, run := range b.line.Runs {
+		b.line.Width += run.Width
+	}
+	if len(b.line.Runs) == 0 && span >= 0 && span < len(b.fonts) {
+		// Keep the font of an empty line for its height
+		b.line.Runs = []TextRun{{Font: b.fonts[span], Color: b.colors[span]}}
+	}
+	b.lines = append(b.lines, b.line)
+	b.line, b.spans = TextLine{}, nil
+}
+
+// arrangeLines places the lines below each other and aligns them
+func arrangeLines(lines []TextLine, options TextLayoutOptions) *TextLayout {
+	layout := &TextLayout{Lines: lines}
+	for _, line := range lines {
+		if line.Width > layout.Width {
+			layout.Width = line.Width
+		}
+	}
+	box := layout.Width
+	if options.MaxWidth > 0 {
+		box = options.MaxWidth
+	}
+
+	top := 0.0
+	for i := range layout.Lines {
+		line := &layout.Lines[i]
+		size := options.Font.Size
+		if len(line.Runs) > 0 {
+			size = 0
+			for _, run := range line.Runs {
+				if run.Font.Size > size {
+					size = run.Font.Size
+				}
+			}
+		}
+
+		// Half the leading above the line, half below
+		height := size * options.LineHeight
+		line.Baseline = top + (height-size)/2 + size*fontAscent
+
+		offset := 0.0
+		switch options.Align {
+		case "center":
+			offset = (box - line.Width) / 2
+		case "right", "end":
+			offset = box - line.Width
+		}
+
+		x := offset
+		line.Bounds = [4]float64{offset, top, offset + line.Width, top + height}
+		for j := range line.Runs {
+			run := &line.Runs[j]
+			run.X, run.Y = x, line.Baseline
+			run.Bounds = [4]float64{x, run.Y - run.Font.Size*fontAscent, x + run.Width, run.Y + run.Font.Size*fontDescent}
+			x += run.Width
+		}
+		top += height
+	}
+	layout.Height = top
+
+	for i, line := range layout.Lines {
+		if i == 0 {
+			layout.Bounds = line.Bounds
+			continue
+		}
+		layout.Bounds[0] = math.Min(layout.Bounds[0], line.Bounds[0])
+		layout.Bounds[2] = math.Max(layout.Bounds[2], line.Bounds[2])
+		layout.Bounds[3] = line.Bounds[3]
+	}
+	return layout
+}
+
+// RunAt returns the run at a point relative to the layout's top left
+// corner, such as to find the label a pointer is over
+func (l *TextLayout) RunAt(x, y float64) (*TextRun, bool) {
+	for i := range l.Lines {
+		line := &l.Lines[i]
+		if y < line.Bounds[1] || y >= line.Bounds[3] {
+			continue
+		}
+		for j := range line.Runs {
+			run := &line.Runs[j]
+			if run.Text != "" && x >= run.Bounds[0] && x < run.Bounds[2] {
+				return run, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// Text returns the laid out text, lines joined with "\n"
+func (l *TextLayout) Text() string {
+	lines := make([]string, len(l.Lines))
+	for i, line := range l.Lines {
+		var text strings.Builder
+		for _, run := range line.Runs {
+			text.WriteString(run.Text)
+		}
+		lines[i] = text.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// LayoutText lays out spans in the context's Font and FillStyle, measured
+// with Measurer
+func (ctx *Canvas2DContext) LayoutText(spans []TextSpan, options TextLayoutOptions) *TextLayout {
+	if options.Font.Size <= 0 {
+		options.Font = ParseFont(ctx.Font)
+	}
+	if options.Color == "" {
+		options.Color = ctx.FillStyle
+	}
+	return LayoutText(spans, options, ctx.measurer())
+}
+
+// FillTextLayout draws a layout with its top left corner at x, y, each run
+// in its own font and color
+func (ctx *Canvas2DContext) FillTextLayout(layout *TextLayout, x, y float64) {
+	font, fill, align, baseline := ctx.Font, ctx.FillStyle, ctx.TextAlign, ctx.TextBaseline
+	ctx.TextAlign, ctx.TextBaseline = "left", "alphabetic"
+	for _, line := range layout.Lines {
+		for _, run := range line.Runs {
+			if run.Text == "" {
+				continue
+			}
+			ctx.Font, ctx.FillStyle = run.Font.String(), run.Color
+			ctx.FillText(run.Text, x+run.X, y+run.Y)
+		}
+	}
+	ctx.Font, ctx.FillStyle, ctx.TextAlign, ctx.TextBaseline = font, fill, align, baseline
+}
+
+// FillRichText lays out spans and draws them with their top left corner at
+// x, y, returning the layout for its bounds
+func (ctx *Canvas2DContext) FillRichText(spans []TextSpan, x, y float64, options TextLayoutOptions) *TextLayout {
+	layout := ctx.LayoutText(spans, options)
+	ctx.FillTextLayout(layout, x, y)
+	return layout
+}
+
+// measurer returns the context's measurer, estimating without one
+func (ctx *Canvas2DContext) measurer() TextMeasurer {
+	if ctx.Measurer != nil {
+		return ctx.Measurer
+	}
+	return ApproxMeasurer{}
+}
@@ -0,0 +1,97 @@
+package engine
+
+import (
+	"math"
+	"testing"
+)
+
+// fixedMeasurer gives every character the same advance of half the font size
+type fixedMeasurer struct{}
+
+func (fixedMeasurer) MeasureText(text string, font Font) float64 {
+	return float64(len([]rune(text))) * font.Size / 2
+}
+
+func TestParseFont(t *testing.T) {
+	font := ParseFont("italic bold 14px/1.5 Inter, sans-serif")
+	if !font.Italic || font.Weight != 700 || font.Size != 14 || font.Family != "Inter, sans-serif" {
+		t.Fatalf("unexpected font %+v", font)
+	}
+	if got := font.String(); got != "italic 700 14px Inter, sans-serif" {
+		t.Fatalf("unexpected shorthand %q", got)
+	}
+	if font := ParseFont(""); font.Size != 10 || font.Family != "sans-serif" || font.Weight != 400 {
+		t.Fatalf("expected the canvas default, got %+v", font)
+	}
+}
+
+func TestLayoutTextWraps(t *testing.T) {
+	options := TextLayoutOptions{Font: Font{Size: 10}, MaxWidth: 60, LineHeight: 1.5}
+	layout := LayoutText([]TextSpan{{Text: "the quick brown fox\njumps"}}, options, fixedMeasurer{})
+
+	// 5px a character, so 12 fit on a line
+	if got := layout.Text(); got != "the quick\nbrown fox\njumps" {
+		t.Fatalf("unexpected lines %q", got)
+	}
+	if layout.Height != 45 || layout.Width != 45 {
+		t.Fatalf("expected 3 lines of 15px, 45px wide, got %vx%v", layout.Width, layout.Height)
+	}
+	// The baseline sits at the ascent below half the leading
+	if line := layout.Lines[1]; line.Baseline != 15+2.5+8 || line.Bounds != [4]float64{0, 15, 45, 30} {
+		t.Fatalf("unexpected second line %+v", line)
+	}
+
+	broken := LayoutText([]TextSpan{{Text: "abcdefghijklmnopqrstuvwxyz"}}, options, fixedMeasurer{})
+	if got := broken.Text(); got != "abcdefghijkl\nmnopqrstuvwx\nyz" {
+		t.Fatalf("expected a long word broken between characters, got %q", got)
+	}
+}
+
+func TestLayoutTextRichSpansAndAlignment(t *testing.T) {
+	spans := []TextSpan{
+		{Text: "HP "},
+		{Text: "42", Color: "#ff0000", Weight: 700, Size: 20},
+		{Text: " / 100"},
+	}
+	layout := LayoutText(spans, TextLayoutOptions{Font: Font{Size: 10}, Color: "#ffffff", MaxWidth: 100, Align: "right"}, fixedMeasurer{})
+
+	if len(layout.Lines) != 1 || len(layout.Lines[0].Runs) != 3 {
+		t.Fatalf("expected one line of three runs, got %+v", layout.Lines)
+	}
+	runs := layout.Lines[0].Runs
+	if runs[1].Color != "#ff0000" || runs[1].Font.Weight != 700 || runs[0].Color != "#ffffff" {
+		t.Fatalf("expected the spans' styles, got %+v", runs)
+	}
+	// 15 + 20 + 30 wide, flush right in 100
+	if runs[0].X != 35 || runs[1].X != 50 || runs[2].X != 70 {
+		t.Fatalf("unexpected run positions %v %v %v", runs[0].X, runs[1].X, runs[2].X)
+	}
+	// The line is as tall as its largest font, and the runs share a baseline
+	if layout.Height != 24 || runs[0].Y != runs[1].Y || runs[1].Bounds[1] != runs[1].Y-16 {
+		t.Fatalf("unexpected vertical metrics %v %+v", layout.Height, runs)
+	}
+	if run, ok := layout.RunAt(55, 10); !ok || run.Text != "42" {
+		t.Fatalf("expected the value under the point, got %+v", run)
+	}
+	if _, ok := layout.RunAt(10, 10); ok {
+		t.Fatalf("expected no run left of the text")
+	}
+}
+
+func TestCanvasFillRichText(t *testing.T) {
+	ctx := &Canvas2DContext{Font: "12px sans-serif", FillStyle: "#000000", TextAlign: "center", Stats: &Canvas2DStats{}, Measurer: fixedMeasurer{}}
+	layout := ctx.FillRichText([]TextSpan{{Text: "Score: "}, {Text: "900", Weight: 700}}, 10, 20, TextLayoutOptions{})
+
+	if ctx.Stats.TextCalls != 2 {
+		t.Fatalf("expected a call per run, got %d", ctx.Stats.TextCalls)
+	}
+	if ctx.Font != "12px sans-serif" || ctx.FillStyle != "#000000" || ctx.TextAlign != "center" {
+		t.Fatalf("expected the context's state restored, got %q %q %q", ctx.Font, ctx.FillStyle, ctx.TextAlign)
+	}
+	if layout.Width != 60 || math.Abs(layout.Height-14.4) > 1e-9 {
+		t.Fatalf("unexpected size %vx%v", layout.Width, layout.Height)
+	}
+	if got := ctx.MeasureText("abcd"); got != 24 {
+		t.Fatalf("expected MeasureText to use the measurer, got %v", got)
+	}
+}
@@ -0,0 +1,445 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"  // register GIF decoder
+	_ "image/jpeg" // register JPEG decoder
+	_ "image/png"  // register PNG decoder
+	"io/ioutil"
+	"math"
+	"sync"
+)
+
+// ktx2Identifier is the file identifier at the start of every KTX2 file
+var ktx2Identifier = []byte{0xAB, 'K', 'T', 'X', ' ', '2', '0', 0xBB, '\r', '\n', 0x1A, '\n'}
+
+// ktx2Formats maps common Vulkan formats found in KTX2 files to WebGPU texture
+// formats. Basis Universal files, with VK_FORMAT_UNDEFINED, need transcoding
+// to one of these first and are not supported.
+var ktx2Formats = map[uint32]string{
+	37:  "rgba8unorm",
+	43:  "rgba8unorm-srgb",
+	97:  "rgba16float",
+	109: "rgba32float",
+	131: "bc1-rgba-unorm",
+	132: "bc1-rgba-unorm-srgb",
+	137: "bc3-rgba-unorm",
+	138: "bc3-rgba-unorm-srgb",
+	145: "bc7-rgba-unorm",
+	146: "bc7-rgba-unorm-srgb",
+	147: "etc2-rgb8unorm",
+	148: "etc2-rgb8unorm-srgb",
+	157: "astc-4x4-unorm",
+	158: "astc-4x4-unorm-srgb",
+}
+
+// TextureOptions configures how a texture is loaded
+type TextureOptions struct {
+	// GenerateMipmaps generates a full mip chain when the source has none,
+	// by averaging each 2x2 block of the level above. KTX2 files in
+	// block-compressed or float formats without levels keep their one level.
+	GenerateMipmaps bool
+
+	// SRGB marks color data as sRGB encoded
+	SRGB bool
+
+	// Usage flags for the GPU texture
+	Usage int
+}
+
+// DefaultTextureOptions returns the default texture options for color textures
+func DefaultTextureOptions() TextureOptions {
+	return TextureOptions{
+		GenerateMipmaps: true,
+		SRGB:            true,
+		Usage:           0x4 | 0x2, // TEXTURE_BINDING | COPY_DST
+	}
+}
+
+// KTX2Header contains the fields of a KTX2 header needed to create a texture
+type KTX2Header struct {
+	VkFormat               uint32
+	TypeSize               uint32
+	PixelWidth             uint32
+	PixelHeight            uint32
+	PixelDepth             uint32
+	LayerCount             uint32
+	FaceCount              uint32
+	LevelCount             uint32
+	SupercompressionScheme uint32
+}
+
+// IsKTX2 reports whether data starts with the KTX2 file identifier
+func IsKTX2(data []byte) bool {
+	return len(data) >= len(ktx2Identifier) && bytes.Equal(data[:len(ktx2Identifier)], ktx2Identifier)
+}
+
+// ParseKTX2Header parses the header of a KTX2 file
+func ParseKTX2Header(data []byte) (*KTX2Header, error) {
+	if !IsKTX2(data) {
+		return nil, errors.New("not a KTX2 file")
+	}
+
+	if len(data) < 48 {
+		return nil, errors.New("KTX2 header is truncated")
+	}
+
+	field := func(offset int) uint32 {
+		return binary.LittleEndian.Uint32(data[offset : offset+4])
+	}
+
+	header := &KTX2Header{
+		VkFormat:               field(12),
+		TypeSize:               field(16),
+		PixelWidth:             field(20),
+		PixelHeight:            field(24),
+		PixelDepth:             field(28),
+		LayerCount:             field(32),
+		FaceCount:              field(36),
+		LevelCount:             field(40),
+		SupercompressionScheme: field(44),
+	}
+
+	if header.PixelWidth == 0 {
+		return nil, errors.New("KTX2 texture has zero width")
+	}
+
+	return header, nil
+}
+
+// ktx2LevelIndex is the offset of the level index, after the header and
+// the offsets of the data format descriptor, key/value data and
+// supercompression global data
+const ktx2LevelIndex = 80
+
+// ParseKTX2Levels returns the data of each mip level of a KTX2 file,
+// largest first. Supercompressed files are refused: their levels would
+// need inflating, or transcoding for Basis Universal, before upload.
+func ParseKTX2Levels(data []byte, header *KTX2Header) ([][]byte, error) {
+	if header.SupercompressionScheme != 0 {
+		return nil, fmt.Errorf("KTX2 supercompression scheme %d is not supported", header.SupercompressionScheme)
+	}
+
+	count := int(header.LevelCount)
+	if count == 0 {
+		count = 1
+	}
+	if count > MipLevelCount(int(header.PixelWidth), int(header.PixelHeight)) {
+		return nil, fmt.Errorf("KTX2 texture of %dx%d has %d levels", header.PixelWidth, header.PixelHeight, count)
+	}
+	if len(data) < ktx2LevelIndex+count*24 {
+		return nil, errors.New("KTX2 level index is truncated")
+	}
+
+	levels := make([][]byte, count)
+	for i := range levels {
+		entry := data[ktx2LevelIndex+i*24:]
+		offset := binary.LittleEndian.Uint64(entry[0:8])
+		length := binary.LittleEndian.Uint64(entry[8:16])
+		if offset > uint64(len(data)) || length > uint64(len(data))-offset {
+			return nil, fmt.Errorf("KTX2 level %d is outside the file", i)
+		}
+		levels[i] = data[offset : offset+length]
+	}
+
+	return levels, nil
+}
+
+// MipLevelCount returns the number of levels in a full mip chain
+func MipLevelCount(width, height int) int {
+	size := width
+	if height > size {
+		size = height
+	}
+
+	levels := 1
+	for size > 1 {
+		size >>= 1
+		levels++
+	}
+
+	return levels
+}
+
+// srgbToLinear maps each 8-bit sRGB value to linear light
+var srgbToLinear = func() (table [256]float64) {
+	for i := range table {
+		c := float64(i) / 255
+		if c <= 0.04045 {
+			table[i] = c / 12.92
+		} else {
+			table[i] = math.Pow((c+0.055)/1.055, 2.4)
+		}
+	}
+	return table
+}()
+
+// linearToSRGB encodes a linear light value as 8-bit sRGB
+func linearToSRGB(c float64) byte {
+	if c <= 0.0031308 {
+		c *= 12.92
+	} else {
+		c = 1.055*math.Pow(c, 1/2.4) - 0.055
+	}
+	return byte(math.Round(clamp(c, 0, 1) * 255))
+}
+
+// GenerateMipLevels returns the RGBA8 pixels of a width x height image and
+// of each level below it, down to 1x1, each averaging the 2x2 blocks of
+// the one above. Color is averaged in linear light when srgb is set, so
+// smaller levels do not darken; alpha always is linear.
+func GenerateMipLevels(pixels []byte, width, height int, srgb bool) [][]byte {
+	levels := [][]byte{pixels}
+	for width > 1 || height > 1 {
+		nextWidth, nextHeight := width/2, height/2
+		if nextWidth == 0 {
+			nextWidth = 1
+		}
+		if nextHeight == 0 {
+			nextHeight = 1
+		}
+
+		next := make([]byte, nextWidth*nextHeight*4)
+		for y := 0; y < nextHeight; y++ {
+			for x := 0; x < nextWidth; x++ {
+				var sum [4]float64
+				for _, offset := range [4][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}} {
+					sx, sy := x*2+offset[0], y*2+offset[1]
+					if sx >= width {
+						sx = width - 1
+					}
+					if sy >= height {
+						sy = height - 1
+					}
+					source := pixels[(sy*width+sx)*4:]
+					for c := 0; c < 4; c++ {
+						if srgb && c < 3 {
+							sum[c] += srgbToLinear[source[c]]
+						} else {
+							sum[c] += float64(source[c]) / 255
+						}
+					}
+				}
+
+				target := next[(y*nextWidth+x)*4:]
+				for c := 0; c < 4; c++ {
+					if srgb && c < 3 {
+						target[c] = linearToSRGB(sum[c] / 4)
+					} else {
+						target[c] = byte(math.Round(sum[c] / 4 * 255))
+					}
+				}
+			}
+		}
+
+		levels = append(levels, next)
+		pixels, width, height = next, nextWidth, nextHeight
+	}
+
+	return levels
+}
+
+// TextureManager loads and caches textures
+type TextureManager struct {
+	// WebGPU used to allocate textures (nil for CPU-side descriptions only)
+	WebGPU *WebGPU
+
+	// Loaded textures by name
+	textures map[string]*GPUTexture
+
+	// Mutex for thread safety
+	mutex sync.RWMutex
+}
+
+// NewTextureManager creates a new texture manager
+func NewTextureManager(webgpu *WebGPU) *TextureManager {
+	return &TextureManager{
+		WebGPU:   webgpu,
+		textures: make(map[string]*GPUTexture),
+	}
+}
+
+// Load loads a texture from disk
+func (m *TextureManager) Load(path string, options TextureOptions) (*GPUTexture, error) {
+	if texture := m.Get(path); texture != nil {
+		return texture, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.LoadBytes(path, data, options)
+}
+
+// LoadBytes loads a KTX2, PNG, JPEG or GIF texture from memory and writes
+// the pixels of each of its mip levels
+func (m *TextureManager) LoadBytes(name string, data []byte, options TextureOptions) (*GPUTexture, error) {
+	if texture := m.Get(name); texture != nil {
+		return texture, nil
+	}
+
+	var width, height, depth int
+	var format string
+	var levels [][]byte
+
+	if IsKTX2(data) {
+		header, err := ParseKTX2Header(data)
+		if err != nil {
+			return nil, err
+		}
+
+		var ok bool
+		format, ok = ktx2Formats[header.VkFormat]
+		if !ok {
+			return nil, fmt.Errorf("unsupported KTX2 format %d", header.VkFormat)
+		}
+
+		width = int(header.PixelWidth)
+		height = int(header.PixelHeight)
+		depth = int(header.PixelDepth)
+
+		levels, err = ParseKTX2Levels(data, header)
+		if err != nil {
+			return nil, err
+		}
+
+		// A level count of zero asks the loader to generate the mip chain,
+		// which it can for 8-bit RGBA
+		rgba8 := format == "rgba8unorm" || format == "rgba8unorm-srgb"
+		if header.LevelCount == 0 && options.GenerateMipmaps && rgba8 && depth <= 1 && header.LayerCount <= 1 && header.FaceCount <= 1 {
+			if len(levels[0]) != width*height*4 {
+				return nil, fmt.Errorf("KTX2 level 0 has %d bytes for %dx%d pixels", len(levels[0]), width, height)
+			}
+			levels = GenerateMipLevels(levels[0], width, height, format == "rgba8unorm-srgb")
+		}
+	} else {
+		decoded, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode texture %s: %v", name, err)
+		}
+
+		bounds := decoded.Bounds()
+		width = bounds.Dx()
+		height = bounds.Dy()
+		format = "rgba8unorm"
+		if options.SRGB {
+			format = "rgba8unorm-srgb"
+		}
+
+		pixels := image.NewNRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(pixels, pixels.Bounds(), decoded, bounds.Min, draw.Src)
+
+		levels = [][]byte{pixels.Pix}
+		if options.GenerateMipmaps {
+			levels = GenerateMipLevels(pixels.Pix, width, height, options.SRGB)
+		}
+	}
+
+	if depth == 0 {
+		depth = 1
+	}
+
+	texture, err := m.allocate(name, width, height, depth, format, options.Usage, len(levels))
+	if err != nil {
+		return nil, err
+	}
+	if err := m.write(texture, levels); err != nil {
+		if m.WebGPU != nil {
+			m.WebGPU.DestroyTexture(texture)
+		}
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	m.textures[name] = texture
+	m.mutex.Unlock()
+
+	return texture, nil
+}
+
+// allocate creates the GPU texture, or a description when no device is available
+func (m *TextureManager) allocate(name string, width, height, depth int, format string, usage, mipLevels int) (*GPUTexture, error) {
+	if m.WebGPU != nil && m.WebGPU.GetCurrentDevice() != nil {
+		return m.WebGPU.CreateTexture(width, height, depth, format, usage, mipLevels)
+	}
+
+	return &GPUTexture{
+		ID:        fmt.Sprintf("texture-%s", name),
+		Width:     width,
+		Height:    height,
+		Depth:     depth,
+		Format:    format,
+		Usage:     usage,
+		MipLevels: mipLevels,
+	}, nil
+}
+
+// write writes the pixels of each mip level to the texture, or keeps them
+// in its description when no device is available
+func (m *TextureManager) write(texture *GPUTexture, levels [][]byte) error {
+	if m.WebGPU == nil || m.WebGPU.GetCurrentDevice() == nil {
+		texture.Levels = levels
+		return nil
+	}
+
+	for level, pixels := range levels {
+		if err := m.WebGPU.WriteTexture(texture, level, pixels); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Get gets a loaded texture by name
+func (m *TextureManager) Get(name string) *GPUTexture {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.textures[name]
+}
+
+// Unload releases a texture
+func (m *TextureManager) Unload(name string) {
+	m.mutex.Lock()
+	texture, ok := m.textures[name]
+	delete(m.textures, name)
+	m.mutex.Unlock()
+
+	if ok && m.WebGPU != nil {
+		m.WebGPU.DestroyTexture(texture)
+	}
+}
+
+// MemoryUsage returns the GPU memory used by the pixels of loaded textures
+// in bytes, estimating it for textures without pixel data
+func (m *TextureManager) MemoryUsage() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	total := 0
+	for _, texture := range m.textures {
+		if len(texture.Levels) > 0 {
+			for _, level := range texture.Levels {
+				total += len(level)
+			}
+			continue
+		}
+
+		size := texture.Width * texture.Height * texture.Depth * 4
+		// A full mip chain adds roughly a third
+		if texture.MipLevels > 1 {
+			size += size / 3
+		}
+		total += size
+	}
+
+	return total
+}
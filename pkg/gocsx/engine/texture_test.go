@@ -0,0 +1,140 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// encodePNG encodes a width x height image whose pixels fill returns
+func encodePNG(t *testing.T, width, height int, fill func(x, y int) color.NRGBA) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, fill(x, y))
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// encodeKTX2 builds a KTX2 file of a 2D texture with the given levels,
+// largest first, declaring levelCount of them
+func encodeKTX2(format uint32, width, height, levelCount, supercompression uint32, levels [][]byte) []byte {
+	data := make([]byte, ktx2LevelIndex+24*len(levels))
+	copy(data, ktx2Identifier)
+	for i, value := range []uint32{format, 1, width, height, 0, 0, 1, levelCount, supercompression} {
+		binary.LittleEndian.PutUint32(data[12+i*4:], value)
+	}
+	for i, level := range levels {
+		entry := data[ktx2LevelIndex+i*24:]
+		binary.LittleEndian.PutUint64(entry[0:], uint64(len(data)))
+		binary.LittleEndian.PutUint64(entry[8:], uint64(len(level)))
+		binary.LittleEndian.PutUint64(entry[16:], uint64(len(level)))
+		data = append(data, level...)
+	}
+	return data
+}
+
+func TestLoadPNGGeneratesMipLevels(t *testing.T) {
+	// Black and white columns, fully opaque
+	data := encodePNG(t, 4, 2, func(x, y int) color.NRGBA {
+		if x%2 == 0 {
+			return color.NRGBA{A: 255}
+		}
+		return color.NRGBA{R: 255, G: 255, B: 255, A: 255}
+	})
+
+	webgpu := NewWebGPU()
+	if _, err := webgpu.RequestDevice(); err != nil {
+		t.Fatal(err)
+	}
+	manager := NewTextureManager(webgpu)
+	texture, err := manager.LoadBytes("linear", data, TextureOptions{GenerateMipmaps: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if texture.MipLevels != 3 || len(texture.Levels) != 3 {
+		t.Fatalf("expected 4x2, 2x1 and 1x1 levels, got %d (%d written)", texture.MipLevels, len(texture.Levels))
+	}
+	if len(texture.Levels[0]) != 32 || len(texture.Levels[1]) != 8 || len(texture.Levels[2]) != 4 {
+		t.Fatalf("unexpected level sizes %d %d %d", len(texture.Levels[0]), len(texture.Levels[1]), len(texture.Levels[2]))
+	}
+	if level := texture.Levels[2]; !bytes.Equal(level, []byte{128, 128, 128, 255}) {
+		t.Fatalf("expected the smallest level mid grey, got %v", level)
+	}
+	if usage := manager.MemoryUsage(); usage != 44 {
+		t.Fatalf("expected the written pixels counted, got %d bytes", usage)
+	}
+
+	// Averaged in linear light, black and white is a lighter sRGB grey
+	texture, err = manager.LoadBytes("srgb", data, TextureOptions{GenerateMipmaps: true, SRGB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if texture.Format != "rgba8unorm-srgb" || !bytes.Equal(texture.Levels[1][:4], []byte{188, 188, 188, 255}) {
+		t.Fatalf("expected sRGB grey 188, got %s %v", texture.Format, texture.Levels[1][:4])
+	}
+
+	texture, err = manager.LoadBytes("single", data, TextureOptions{})
+	if err != nil || texture.MipLevels != 1 || len(texture.Levels) != 1 {
+		t.Fatalf("expected a single level without mipmaps, got %+v (%v)", texture, err)
+	}
+}
+
+func TestGenerateMipLevelsOddSizes(t *testing.T) {
+	pixels := make([]byte, 3*1*4)
+	for i := range pixels {
+		pixels[i] = 90
+	}
+	levels := GenerateMipLevels(pixels, 3, 1, false)
+	if len(levels) != MipLevelCount(3, 1) || len(levels[1]) != 4 || !bytes.Equal(levels[1], []byte{90, 90, 90, 90}) {
+		t.Fatalf("unexpected levels %v", levels)
+	}
+}
+
+func TestLoadKTX2Levels(t *testing.T) {
+	base := bytes.Repeat([]byte{10, 20, 30, 255}, 4)
+	small := []byte{1, 2, 3, 4}
+	manager := NewTextureManager(nil)
+
+	texture, err := manager.LoadBytes("stored", encodeKTX2(37, 2, 2, 2, 0, [][]byte{base, small}), DefaultTextureOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if texture.Format != "rgba8unorm" || texture.MipLevels != 2 || !bytes.Equal(texture.Levels[0], base) || !bytes.Equal(texture.Levels[1], small) {
+		t.Fatalf("expected both stored levels written, got %+v", texture)
+	}
+
+	texture, err = manager.LoadBytes("generated", encodeKTX2(37, 2, 2, 0, 0, [][]byte{base}), DefaultTextureOptions())
+	if err != nil || texture.MipLevels != 2 || !bytes.Equal(texture.Levels[1], []byte{10, 20, 30, 255}) {
+		t.Fatalf("expected the missing level generated, got %+v (%v)", texture, err)
+	}
+
+	texture, err = manager.LoadBytes("compressed", encodeKTX2(145, 4, 4, 0, 0, [][]byte{make([]byte, 16)}), DefaultTextureOptions())
+	if err != nil || texture.MipLevels != 1 || len(texture.Levels[0]) != 16 {
+		t.Fatalf("expected a compressed texture kept at one level, got %+v (%v)", texture, err)
+	}
+
+	for name, test := range map[string]struct {
+		data []byte
+		err  string
+	}{
+		"zstd":      {encodeKTX2(37, 2, 2, 1, 2, [][]byte{base}), "supercompression scheme 2 is not supported"},
+		"basis":     {encodeKTX2(0, 2, 2, 1, 1, [][]byte{base}), "unsupported KTX2 format 0"},
+		"truncated": {encodeKTX2(37, 2, 2, 2, 0, [][]byte{base})[:ktx2LevelIndex+24], "level index is truncated"},
+		"too many":  {encodeKTX2(37, 2, 2, 3, 0, [][]byte{base, small, small}), "has 3 levels"},
+		"outside":   {encodeKTX2(37, 2, 2, 1, 0, [][]byte{base})[:ktx2LevelIndex+24+8], "level 0 is outside the file"},
+	} {
+		if _, err := manager.LoadBytes(name, test.data, DefaultTextureOptions()); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Fatalf("%s: expected %q, got %v", name, test.err, err)
+		}
+	}
+}
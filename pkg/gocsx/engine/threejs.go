@@ -19,6 +19,27 @@ type ThreeJSScene struct {
 	// Renderer
 	Renderer *ThreeJSRenderer
 	
+	// Camera controls (nil when disabled)
+	Controls CameraController
+	
+	// Shared materials
+	Materials *MaterialLibrary
+	
+	// Texture cache
+	Textures *TextureManager
+	
+	// Culling and batching
+	Batcher *Batcher
+	
+	// Audio
+	Audio *AudioEngine
+	
+	// Last frame's draw list
+	DrawList *DrawList
+	
+	// Shared primitive meshes
+	meshes map[string]*Mesh
+	
 	// Mutex for thread safety
 	mutex sync.RWMutex
 }
@@ -37,12 +58,21 @@ type ThreeJSRenderer struct {
 	// Renderer pixel ratio
 	PixelRatio float64
 	
+	// Scales the pixel ratio, as set by the engine's quality scaling
+	RenderScale float64
+	
 	// Renderer clear color
 	ClearColor [4]float64
 	
 	// Renderer shadows
 	Shadows bool
 	
+	// Caps the shadow map size of every light; zero leaves it to the light
+	MaxShadowResolution int
+	
+	// Scales particle counts, as set by the engine's quality scaling
+	ParticleScale float64
+	
 	// Renderer tone mapping
 	ToneMapping string
 	
@@ -67,6 +97,15 @@ type RendererStats struct {
 	// Draw calls
 	DrawCalls int
 	
+	// Draw calls that would be needed without batching
+	DrawCallsBeforeBatching int
+	
+	// Objects skipped by frustum culling
+	CulledObjects int
+	
+	// Objects drawn through instancing
+	InstancedObjects int
+	
 	// Triangles
 	Triangles int
 	
@@ -103,6 +142,8 @@ func NewThreeJSScene(engine *Engine, webgpu *WebGPU) *ThreeJSScene {
 		Width:        800,
 		Height:       600,
 		PixelRatio:   1,
+		RenderScale:  1,
+		ParticleScale: 1,
 		ClearColor:   [4]float64{0, 0, 0, 1},
 		Shadows:      true,
 		ToneMapping:  "ACESFilmic",
@@ -114,25 +155,37 @@ func NewThreeJSScene(engine *Engine, webgpu *WebGPU) *ThreeJSScene {
 	
 	// Create a Three.js scene
 	threeJSScene := &ThreeJSScene{
-		Scene:    scene,
-		WebGPU:   webgpu,
-		Engine:   engine,
-		Renderer: renderer,
+		Scene:     scene,
+		WebGPU:    webgpu,
+		Engine:    engine,
+		Renderer:  renderer,
+		Materials: NewMaterialLibrary(),
+		Textures:  NewTextureManager(webgpu),
+		Batcher:   NewBatcher(),
+		Audio:     NewAudioEngine(nil),
+		meshes:    make(map[string]*Mesh),
 	}
 	
-	// Set render callback
+	// Simulate at the engine's fixed update rate and render every frame
+	engine.SetUpdateCallback(threeJSScene.Update)
 	engine.SetRenderCallback(threeJSScene.Render)
+	engine.SetQualityCallback(threeJSScene.ApplyQuality)
 	
 	return threeJSScene
 }
 
-// Render renders the scene
-func (t *ThreeJSScene) Render(deltaTime float64) {
+// Update advances the scene by one fixed simulation step
+func (t *ThreeJSScene) Update(deltaTime float64) {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 	
-	// Update scene
 	t.Scene.Update(deltaTime)
+}
+
+// Render renders the scene
+func (t *ThreeJSScene) Render(deltaTime float64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
 	
 	// Render scene
 	t.RenderScene()
@@ -145,20 +198,41 @@ func (t *ThreeJSScene) Render(deltaTime float64) {
 		t.Renderer.Stats.Programs,
 		float64(t.Renderer.Stats.Memory.Geometries+t.Renderer.Stats.Memory.Textures),
 	)
+	t.Engine.UpdateDrawStats(t.Renderer.Stats.DrawCallsBeforeBatching, t.Renderer.Stats.CulledObjects)
 }
 
 // RenderScene renders the scene
 func (t *ThreeJSScene) RenderScene() {
-	// This would normally render the scene using WebGPU
+	// Cull and batch the scene into draw calls
+	t.DrawList = t.Batcher.Build(t.Scene, t.Scene.ActiveCamera)
+	
+	// This would normally submit the draw list using WebGPU
 	// For now, we'll just update the stats
-	t.Renderer.Stats.DrawCalls = 100
-	t.Renderer.Stats.Triangles = 10000
+	meshes := make(map[*Mesh]bool)
+	textures := make(map[*GPUTexture]bool)
+	programs := make(map[string]bool)
+	for _, batch := range t.DrawList.Batches {
+		meshes[batch.Mesh] = true
+		if batch.Material != nil {
+			programs[batch.Material.StateKey()] = true
+			for _, texture := range batch.Material.Textures {
+				textures[texture] = true
+			}
+		}
+	}
+	
+	stats := t.DrawList.Stats
+	t.Renderer.Stats.DrawCalls = stats.DrawCallsAfter
+	t.Renderer.Stats.DrawCallsBeforeBatching = stats.DrawCallsBefore
+	t.Renderer.Stats.CulledObjects = stats.Culled
+	t.Renderer.Stats.InstancedObjects = stats.Instanced
+	t.Renderer.Stats.Triangles = stats.Triangles
 	t.Renderer.Stats.Points = 0
 	t.Renderer.Stats.Lines = 0
-	t.Renderer.Stats.Textures = 10
-	t.Renderer.Stats.Programs = 5
-	t.Renderer.Stats.Memory.Geometries = 20
-	t.Renderer.Stats.Memory.Textures = 10
+	t.Renderer.Stats.Textures = len(textures)
+	t.Renderer.Stats.Programs = len(programs)
+	t.Renderer.Stats.Memory.Geometries = len(meshes)
+	t.Renderer.Stats.Memory.Textures = len(textures)
 }
 
 // CreateCube creates a cube
@@ -170,10 +244,72 @@ func (t *ThreeJSScene) CreateCube(id, name string, position [3]float64, size flo
 	cube := t.Scene.CreateObject(id, name)
 	cube.Position = position
 	
-	// Create a mesh
-	mesh := &Mesh{
-		ID:   fmt.Sprintf("%s-mesh", id),
-		Name: fmt.Sprintf("%s Mesh", name),
+	// Share the mesh with other cubes of the same size so they can be instanced
+	mesh := t.sharedMesh(fmt.Sprintf("cube-%g", size), func() *Mesh {
+		return newCubeMesh(size)
+	})
+	
+	// Share a material with other meshes of the same color
+	material := t.Materials.ColorMaterial(color)
+	
+	// Create a mesh renderer
+	meshRenderer := NewMeshRenderer(fmt.Sprintf("%s-mesh-renderer", id), fmt.Sprintf("%s Mesh Renderer", name))
+	meshRenderer.Mesh = mesh
+	meshRenderer.Materials = []*Material{material}
+	
+	// Add mesh renderer to cube
+	t.Scene.AddComponent(cube, meshRenderer)
+	
+	return cube
+}
+
+// CreateSphere creates a sphere
+func (t *ThreeJSScene) CreateSphere(id, name string, position [3]float64, radius float64, color [3]float64) *SceneObject {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	
+	// Create a sphere object
+	sphere := t.Scene.CreateObject(id, name)
+	sphere.Position = position
+	
+	// Create a mesh (simplified for this example)
+	mesh := t.sharedMesh(fmt.Sprintf("sphere-%g", radius), func() *Mesh {
+		return &Mesh{
+			Bounds: [6]float64{-radius, -radius, -radius, radius, radius, radius},
+		}
+	})
+	
+	// Share a material with other meshes of the same color
+	material := t.Materials.ColorMaterial(color)
+	
+	// Create a mesh renderer
+	meshRenderer := NewMeshRenderer(fmt.Sprintf("%s-mesh-renderer", id), fmt.Sprintf("%s Mesh Renderer", name))
+	meshRenderer.Mesh = mesh
+	meshRenderer.Materials = []*Material{material}
+	
+	// Add mesh renderer to sphere
+	t.Scene.AddComponent(sphere, meshRenderer)
+	
+	return sphere
+}
+
+// sharedMesh returns the mesh cached under key, creating it on first use
+func (t *ThreeJSScene) sharedMesh(key string, create func() *Mesh) *Mesh {
+	if mesh, ok := t.meshes[key]; ok {
+		return mesh
+	}
+	
+	mesh := create()
+	mesh.ID = fmt.Sprintf("%s-mesh", key)
+	mesh.Name = fmt.Sprintf("%s Mesh", key)
+	t.meshes[key] = mesh
+	
+	return mesh
+}
+
+// newCubeMesh creates a cube mesh centered on the origin
+func newCubeMesh(size float64) *Mesh {
+	return &Mesh{
 		Vertices: [][3]float64{
 			{-size/2, -size/2, -size/2},
 			{size/2, -size/2, -size/2},
@@ -194,61 +330,52 @@ func (t *ThreeJSScene) CreateCube(id, name string, position [3]float64, size flo
 		},
 		Bounds: [6]float64{-size/2, -size/2, -size/2, size/2, size/2, size/2},
 	}
+}
+
+// CreateMesh creates an object rendering a mesh with the given material
+func (t *ThreeJSScene) CreateMesh(id, name string, position [3]float64, mesh *Mesh, material *Material) *SceneObject {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
 	
-	// Create a material
-	material := &Material{
-		ID:   fmt.Sprintf("%s-material", id),
-		Name: fmt.Sprintf("%s Material", name),
-		Properties: map[string]interface{}{
-			"color": color,
-		},
-	}
+	// Create the object
+	object := t.Scene.CreateObject(id, name)
+	object.Position = position
 	
 	// Create a mesh renderer
 	meshRenderer := NewMeshRenderer(fmt.Sprintf("%s-mesh-renderer", id), fmt.Sprintf("%s Mesh Renderer", name))
 	meshRenderer.Mesh = mesh
 	meshRenderer.Materials = []*Material{material}
 	
-	// Add mesh renderer to cube
-	t.Scene.AddComponent(cube, meshRenderer)
+	// Add mesh renderer to object
+	t.Scene.AddComponent(object, meshRenderer)
 	
-	return cube
+	return object
 }
 
-// CreateSphere creates a sphere
-func (t *ThreeJSScene) CreateSphere(id, name string, position [3]float64, radius float64, color [3]float64) *SceneObject {
+// EnableLOD generates an LOD chain for an object's mesh and switches between
+// levels automatically based on its size on screen
+func (t *ThreeJSScene) EnableLOD(object *SceneObject, ratios []float64) (*LODGroup, error) {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
 	
-	// Create a sphere object
-	sphere := t.Scene.CreateObject(id, name)
-	sphere.Position = position
-	
-	// Create a mesh (simplified for this example)
-	mesh := &Mesh{
-		ID:   fmt.Sprintf("%s-mesh", id),
-		Name: fmt.Sprintf("%s Mesh", name),
-		Bounds: [6]float64{-radius, -radius, -radius, radius, radius, radius},
+	var renderer *MeshRenderer
+	for _, component := range object.Components {
+		if meshRenderer, ok := component.(*MeshRenderer); ok && meshRenderer.Mesh != nil {
+			renderer = meshRenderer
+			break
+		}
 	}
 	
-	// Create a material
-	material := &Material{
-		ID:   fmt.Sprintf("%s-material", id),
-		Name: fmt.Sprintf("%s Material", name),
-		Properties: map[string]interface{}{
-			"color": color,
-		},
+	if renderer == nil {
+		return nil, fmt.Errorf("object %s has no mesh renderer", object.ID)
 	}
 	
-	// Create a mesh renderer
-	meshRenderer := NewMeshRenderer(fmt.Sprintf("%s-mesh-renderer", id), fmt.Sprintf("%s Mesh Renderer", name))
-	meshRenderer.Mesh = mesh
-	meshRenderer.Materials = []*Material{material}
-	
-	// Add mesh renderer to sphere
-	t.Scene.AddComponent(sphere, meshRenderer)
+	lod := NewLODGroup(fmt.Sprintf("%s-lod", object.ID), fmt.Sprintf("%s LOD", object.Name), renderer, GenerateLODs(renderer.Mesh, ratios))
+	if err := t.Scene.AddComponent(object, lod); err != nil {
+		return nil, err
+	}
 	
-	return sphere
+	return lod, nil
 }
 
 // CreateCamera creates a camera
@@ -263,15 +390,87 @@ func (t *ThreeJSScene) CreateCamera(id, name string, position [3]float64, target
 	// Create a camera component
 	camera := NewCamera(fmt.Sprintf("%s-camera", id), fmt.Sprintf("%s Camera", name))
 	
+	// Point the camera at the target
+	camera.Target = target
+	
 	// Add camera to object
 	t.Scene.AddComponent(cameraObj, camera)
 	
+	// Hear spatial audio from the camera
+	t.Scene.AddComponent(cameraObj, NewAudioListener(fmt.Sprintf("%s-listener", id), fmt.Sprintf("%s Listener", name), t.Audio))
+	
 	// Set as active camera
 	t.Scene.ActiveCamera = camera
 	
+	// Attach the configured controls
+	t.attachControls(t.Engine.Config.CameraControls)
+	
 	return cameraObj
 }
 
+// SetCameraControls replaces the controller driving the active camera
+func (t *ThreeJSScene) SetCameraControls(mode CameraControlMode) CameraController {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	
+	t.attachControls(mode)
+	
+	return t.Controls
+}
+
+// attachControls attaches a controller of the given mode to the active camera
+func (t *ThreeJSScene) attachControls(mode CameraControlMode) {
+	camera := t.Scene.ActiveCamera
+	if camera == nil || camera.Object == nil {
+		return
+	}
+	
+	if t.Controls != nil && t.Controls.GetObject() != nil {
+		t.Scene.RemoveComponent(t.Controls.GetObject(), t.Controls.GetID())
+	}
+	t.Controls = nil
+	
+	var controller CameraController
+	switch mode {
+	case CameraControlsOrbit:
+		controller = NewOrbitController(fmt.Sprintf("%s-orbit", camera.ID), camera)
+	case CameraControlsFly:
+		controller = NewFlyController(fmt.Sprintf("%s-fly", camera.ID), camera)
+	default:
+		return
+	}
+	
+	if err := t.Scene.AddComponent(camera.Object, controller); err == nil {
+		t.Controls = controller
+	}
+}
+
+// HandleInput forwards an input event to the camera controls
+func (t *ThreeJSScene) HandleInput(event InputEvent) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	
+	if t.Controls != nil {
+		t.Controls.HandleInput(event)
+	}
+}
+
+// CreateAudioSource attaches a spatial sound to an object
+func (t *ThreeJSScene) CreateAudioSource(object *SceneObject, soundID string, loop bool) (*AudioSource, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	
+	source := NewAudioSource(fmt.Sprintf("%s-audio-%s", object.ID, soundID), fmt.Sprintf("%s Audio", object.Name), t.Audio, soundID)
+	source.Options.Loop = loop
+	source.AutoPlay = true
+	
+	if err := t.Scene.AddComponent(object, source); err != nil {
+		return nil, err
+	}
+	
+	return source, nil
+}
+
 // CreateLight creates a light
 func (t *ThreeJSScene) CreateLight(id, name string, position [3]float64, color [3]float64, intensity float64, type_ string) *SceneObject {
 	t.mutex.Lock()
@@ -315,6 +514,44 @@ func (t *ThreeJSScene) SetPixelRatio(ratio float64) {
 	t.Renderer.PixelRatio = ratio
 }
 
+// ApplyQuality applies a rendering quality to the renderer. A quality
+// without shadows turns shadows off; lights keep their own settings and
+// are capped at render time.
+func (t *ThreeJSScene) ApplyQuality(quality Quality) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	
+	t.Renderer.RenderScale = quality.RenderScale
+	t.Renderer.Shadows = quality.ShadowResolution > 0
+	t.Renderer.MaxShadowResolution = quality.ShadowResolution
+	t.Renderer.ParticleScale = quality.ParticleScale
+}
+
+// DrawingBufferSize returns the size of the drawing buffer in pixels, at the
+// renderer's pixel ratio and render scale
+func (t *ThreeJSScene) DrawingBufferSize() (int, int) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	
+	ratio := t.Renderer.PixelRatio * t.Renderer.RenderScale
+	return int(float64(t.Renderer.Width)*ratio + 0.5), int(float64(t.Renderer.Height)*ratio + 0.5)
+}
+
+// ShadowResolution returns the shadow map size a light renders with, or zero
+// if it casts no shadows
+func (t *ThreeJSScene) ShadowResolution(light *Light) int {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	
+	if !t.Renderer.Shadows || !light.Shadows {
+		return 0
+	}
+	if max := t.Renderer.MaxShadowResolution; max > 0 && light.ShadowResolution > max {
+		return max
+	}
+	return light.ShadowResolution
+}
+
 // SetClearColor sets the renderer clear color
 func (t *ThreeJSScene) SetClearColor(color [4]float64) {
 	t.mutex.Lock()
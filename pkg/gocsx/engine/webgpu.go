@@ -58,6 +58,9 @@ type GPUTexture struct {
 	
 	// Texture mip levels
 	MipLevels int
+	
+	// Pixel data written to each mip level, largest first
+	Levels [][]byte
 }
 
 // GPUShader represents a WebGPU shader
@@ -249,6 +252,27 @@ func (w *WebGPU) CreateTexture(width, height, depth int, format string, usage in
 	return texture, nil
 }
 
+// WriteTexture writes the pixel data of one mip level of a texture
+func (w *WebGPU) WriteTexture(texture *GPUTexture, level int, data []byte) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	
+	if w.currentDevice == nil {
+		return errors.New("no WebGPU device selected")
+	}
+	
+	if level < 0 || level >= texture.MipLevels {
+		return fmt.Errorf("texture %s has no mip level %d", texture.ID, level)
+	}
+	
+	for len(texture.Levels) <= level {
+		texture.Levels = append(texture.Levels, nil)
+	}
+	texture.Levels[level] = data
+	
+	return nil
+}
+
 // CreateShader creates a WebGPU shader
 func (w *WebGPU) CreateShader(type_ string, source string, entryPoint string) (*GPUShader, error) {
 	w.mutex.Lock()
@@ -11,9 +11,9 @@ type Gocsx struct {
 	// Core instance
 	Core *core.Gocsx
 
-	// Components
-	Button *core.Component
-	Card   *core.Component
+	// Registered components, rendered with the Button and Card methods
+	ButtonComponent *core.Component
+	CardComponent   *core.Component
 }
 
 // New creates a new Gocsx instance
@@ -31,8 +31,8 @@ func New(options ...func(*core.Config)) *Gocsx {
 	}
 
 	// Register components
-	gocsx.Button = components.RegisterButtonComponent(coreInstance)
-	gocsx.Card = components.RegisterCardComponent(coreInstance)
+	gocsx.ButtonComponent = components.RegisterButtonComponent(coreInstance)
+	gocsx.CardComponent = components.RegisterCardComponent(coreInstance)
 
 	return gocsx
 }
@@ -42,6 +42,11 @@ func (g *Gocsx) GetCSS() string {
 	return g.Core.GetCSS()
 }
 
+// AddCSS adds CSS to a cascade layer, such as a reset to core.LayerReset
+func (g *Gocsx) AddCSS(layer, css string) {
+	g.Core.AddCSS(layer, css)
+}
+
 // GenerateStyleTag generates a style tag with the CSS
 func (g *Gocsx) GenerateStyleTag() string {
 	return g.Core.GenerateStyleTag()
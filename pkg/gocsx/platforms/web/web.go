@@ -1,8 +1,6 @@
 package web
 
 import (
-	"strings"
-
 	"github.com/davidjeba/goscript/pkg/gocsx/core"
 )
 
@@ -402,14 +400,7 @@ func (a *WebAdapter) GenerateUtilitiesCSS() string {
 .overflow-hidden { overflow: hidden !important; }
 .overflow-visible { overflow: visible !important; }
 .overflow-scroll { overflow: scroll !important; }
-
-.d-print-none { @media print { display: none !important; } }
-.d-print-inline { @media print { display: inline !important; } }
-.d-print-inline-block { @media print { display: inline-block !important; } }
-.d-print-block { @media print { display: block !important; } }
-.d-print-flex { @media print { display: flex !important; } }
-.d-print-inline-flex { @media print { display: inline-flex !important; } }
-`
+` + core.PrintCSS(a.Config)
 }
 
 // GenerateComponentsCSS generates components CSS for web
@@ -1269,14 +1260,13 @@ func (a *WebAdapter) GenerateComponentsCSS() string {
 `
 }
 
-// GenerateFullCSS generates the full CSS for web
+// GenerateFullCSS generates the full CSS for web, in the cascade layers of
+// the config
 func (a *WebAdapter) GenerateFullCSS() string {
-	var css strings.Builder
-
-	css.WriteString(a.GenerateResetCSS())
-	css.WriteString(a.GenerateBaseCSS())
-	css.WriteString(a.GenerateUtilitiesCSS())
-	css.WriteString(a.GenerateComponentsCSS())
-
-	return css.String()
+	return core.LayerCSS(map[string]string{
+		core.LayerReset:      a.GenerateResetCSS(),
+		core.LayerBase:       a.GenerateBaseCSS(),
+		core.LayerComponents: a.GenerateComponentsCSS(),
+		core.LayerUtilities:  a.GenerateUtilitiesCSS(),
+	}, a.Config)
 }
\ No newline at end of file
@@ -0,0 +1,288 @@
+// Package scoped generates component-scoped CSS: styles declared in Go, as
+// maps or structs, become classes named after the component with a hash of
+// the styles appended, such as "counter-3f9a1c2e". Components rendering the
+// same styles share a class, and the rules are collected in a Sheet served
+// as one cacheable stylesheet instead of inline style attributes.
+package scoped
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Style is a component's style: CSS properties and their values, such as
+// "background-color": "#fff" or "backgroundColor": "#fff", and nested
+// styles keyed by selector. A key starting with ":" styles a state or
+// pseudo-element of the component, such as ":hover"; one starting with "@"
+// is an at-rule, such as "@media (max-width: 600px)"; one containing "&"
+// replaces the "&" with the component's class, such as "& + &"; any other
+// styles the component's descendants, such as "button".
+type Style map[string]interface{}
+
+// Sheet collects the rules of the classes generated for it. It is safe for
+// concurrent use; rules are only ever added, so a browser holding a version
+// of the sheet only needs the rules added since.
+type Sheet struct {
+	// Prefix is prepended to every class the sheet generates
+	Prefix string
+
+	mutex   sync.RWMutex
+	classes map[string]bool
+	rules   []string
+}
+
+// NewSheet creates an empty sheet
+func NewSheet() *Sheet {
+	return &Sheet{classes: make(map[string]bool)}
+}
+
+// Class returns the class of a style, adding its rules to the sheet the
+// first time it is seen. The style is a Style, a map of properties, or a
+// struct whose fields are properties: named by their css tag, such as
+// `css:"background-color"`, or else by the field name in kebab case. Zero
+// fields are left out; fields holding a Style or a map are nested styles
+// keyed by their tag.
+func (s *Sheet) Class(name string, style interface{}) string {
+	declarations, nested := flatten(style)
+	hash := fnv.New32a()
+	hash.Write([]byte(render("&", declarations, nested)))
+	class := fmt.Sprintf("%s%s-%08x", s.Prefix, sanitize(name), hash.Sum32())
+
+	s.mutex.RLock()
+	known := s.classes[class]
+	s.mutex.RUnlock()
+	if known {
+		return class
+	}
+
+	rules := render("."+class, declarations, nested)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.classes[class] {
+		if s.classes == nil {
+			s.classes = make(map[string]bool)
+		}
+		s.classes[class] = true
+		s.rules = append(s.rules, rules)
+	}
+	return class
+}
+
+// Version returns how many classes the sheet has rules for, to pass to
+// CSSSince later
+func (s *Sheet) Version() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.rules)
+}
+
+// CSS returns the rules of every class, in the order they were added
+func (s *Sheet) CSS() string {
+	return s.CSSSince(0)
+}
+
+// CSSSince returns the rules added after the sheet had version classes
+func (s *Sheet) CSSSince(version int) string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if version < 0 {
+		version = 0
+	}
+	if version >= len(s.rules) {
+		return ""
+	}
+	return strings.Join(s.rules[version:], "")
+}
+
+// Hash returns a hash of the sheet's CSS, to tell its versions apart in
+// URLs
+func (s *Sheet) Hash() string {
+	sum := sha256.Sum256([]byte(s.CSS()))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ServeHTTP serves the sheet's CSS. Requests for a version by hash, as
+// LinkTag links, are cached for a year; others are revalidated by ETag.
+func (s *Sheet) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	css := s.CSS()
+	sum := sha256.Sum256([]byte(css))
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+
+	w.Header().Set("Content-Type", "text/css; charset=utf-8")
+	w.Header().Set("ETag", etag)
+	if r.URL.Query().Get("v") != "" {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Write([]byte(css))
+}
+
+// LinkTag returns a link to the sheet served at href, with its hash for the
+// browser to cache it by, and its version for clients that receive the
+// rules added later, such as GoUIX's live runtime. Render it after the
+// components, so the classes they use are in the linked version.
+func (s *Sheet) LinkTag(href string) string {
+	separator := "?"
+	if strings.Contains(href, "?") {
+		separator = "&"
+	}
+	return fmt.Sprintf(`<link rel="stylesheet" href="%s%sv=%s" data-scoped-version="%d">`, href, separator, s.Hash(), s.Version())
+}
+
+// StyleTag returns the sheet's CSS in a style element, marked with its
+// version as LinkTag's link is
+func (s *Sheet) StyleTag() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return fmt.Sprintf(`<style data-scoped-version="%d">%s</style>`, len(s.rules), strings.Join(s.rules, ""))
+}
+
+// declaration is a CSS property and its value
+type declaration struct {
+	property, value string
+}
+
+// flatten splits a style into its declarations, sorted by property, and
+// its nested styles by selector
+func flatten(style interface{}) ([]declaration, map[string]interface{}) {
+	var declarations []declaration
+	nested := make(map[string]interface{})
+	add := func(key string, value interface{}) {
+		switch value.(type) {
+		case Style, map[string]interface{}, map[string]string:
+			nested[key] = value
+			return
+		}
+		if text := fmt.Sprint(value); text != "" {
+			declarations = append(declarations, declaration{kebab(key), text})
+		}
+	}
+
+	switch style := style.(type) {
+	case nil:
+	case Style:
+		for key, value := range style {
+			add(key, value)
+		}
+	case map[string]interface{}:
+		for key, value := range style {
+			add(key, value)
+		}
+	case map[string]string:
+		for key, value := range style {
+			add(key, value)
+		}
+	default:
+		v := reflect.Indirect(reflect.ValueOf(style))
+		if v.Kind() != reflect.Struct {
+			panic(fmt.Sprintf("scoped: a style must be a map or a struct, not %T", style))
+		}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.PkgPath != "" || v.Field(i).IsZero() {
+				continue
+			}
+			key := field.Tag.Get("css")
+			if key == "" {
+				key = field.Name
+			}
+			add(key, v.Field(i).Interface())
+		}
+	}
+
+	sort.Slice(declarations, func(i, j int) bool { return declarations[i].property < declarations[j].property })
+	return declarations, nested
+}
+
+// render returns the rules of a selector's declarations and nested styles,
+// nested styles by selector
+func render(selector string, declarations []declaration, nested map[string]interface{}) string {
+	var b strings.Builder
+	if len(declarations) > 0 {
+		b.WriteString(selector + " {\n")
+		for _, d := range declarations {
+			b.WriteString("  " + d.property + ": " + d.value + ";\n")
+		}
+		b.WriteString("}\n")
+	}
+
+	keys := make([]string, 0, len(nested))
+	for key := range nested {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		declarations, inner := flatten(nested[key])
+		switch {
+		case strings.HasPrefix(key, "@"):
+			rules := render(selector, declarations, inner)
+			b.WriteString(key + " {\n" + indent(rules) + "}\n")
+		case strings.HasPrefix(key, ":"):
+			b.WriteString(render(selector+key, declarations, inner))
+		case strings.Contains(key, "&"):
+			b.WriteString(render(strings.Replace(key, "&", selector, -1), declarations, inner))
+		default:
+			b.WriteString(render(selector+" "+key, declarations, inner))
+		}
+	}
+	return b.String()
+}
+
+// indent indents the lines of rules nested in an at-rule by two spaces
+func indent(rules string) string {
+	lines := strings.SplitAfter(rules, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) != "" {
+			lines[i] = "  " + line
+		}
+	}
+	return strings.Join(lines, "")
+}
+
+// kebab converts a property name in camel case, such as a field name or
+// "backgroundColor", to CSS's kebab case; names already in kebab case and
+// custom properties are kept
+func kebab(name string) string {
+	if strings.HasPrefix(name, "--") {
+		return name
+	}
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// sanitize keeps the characters of a component name that a class name may
+// have unescaped
+func sanitize(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return '-'
+	}, name)
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "c" + name
+	}
+	return name
+}
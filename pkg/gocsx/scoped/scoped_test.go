@@ -0,0 +1,109 @@
+package scoped
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestClassesAreSharedByStyle(t *testing.T) {
+	sheet := NewSheet()
+	style := Style{
+		"padding":         "8px 16px",
+		"backgroundColor": "#007bff",
+		":hover":          Style{"background-color": "#0069d9"},
+		"@media (max-width: 600px)": Style{
+			"padding": "4px",
+		},
+	}
+
+	class := sheet.Class("counter button", style)
+	if !regexp.MustCompile(`^counter-button-[0-9a-f]{8}$`).MatchString(class) {
+		t.Fatalf("unexpected class %q", class)
+	}
+	if again := sheet.Class("counter button", Style{"padding": "8px 16px", "backgroundColor": "#007bff", ":hover": Style{"background-color": "#0069d9"}, "@media (max-width: 600px)": Style{"padding": "4px"}}); again != class {
+		t.Fatalf("expected the same style to share %s, got %s", class, again)
+	}
+	if other := sheet.Class("counter button", Style{"padding": "4px"}); other == class {
+		t.Fatal("expected another style to get another class")
+	}
+	if sheet.Version() != 2 {
+		t.Fatalf("expected 2 classes, got %d", sheet.Version())
+	}
+
+	css := sheet.CSS()
+	for _, rule := range []string{
+		"." + class + " {\n  background-color: #007bff;\n  padding: 8px 16px;\n}\n",
+		"." + class + ":hover {\n  background-color: #0069d9;\n}\n",
+		"@media (max-width: 600px) {\n  ." + class + " {\n    padding: 4px;\n  }\n}\n",
+	} {
+		if !strings.Contains(css, rule) {
+			t.Errorf("expected %q in\n%s", rule, css)
+		}
+	}
+	if strings.Count(css, "."+class+" {") != 2 {
+		t.Errorf("expected the rules of a class to be added once, got\n%s", css)
+	}
+}
+
+func TestStructStyles(t *testing.T) {
+	type buttonStyle struct {
+		Background   string `css:"background"`
+		BorderRadius string
+		Margin       string
+		Hover        Style `css:":hover"`
+	}
+
+	sheet := NewSheet()
+	sheet.Prefix = "app-"
+	class := sheet.Class("button", buttonStyle{
+		Background:   "#fff",
+		BorderRadius: "4px",
+		Hover:        Style{"background": "#eee"},
+	})
+	if !strings.HasPrefix(class, "app-button-") {
+		t.Fatalf("expected the prefix, got %s", class)
+	}
+	want := "." + class + " {\n  background: #fff;\n  border-radius: 4px;\n}\n." + class + ":hover {\n  background: #eee;\n}\n"
+	if css := sheet.CSS(); css != want {
+		t.Fatalf("expected\n%s\ngot\n%s", want, css)
+	}
+	if flat := sheet.Class("button", map[string]string{"background": "#fff", "border-radius": "4px"}); flat == class {
+		t.Fatal("expected the hover style to be part of the class")
+	}
+}
+
+func TestSheetServesNewRulesAndCaches(t *testing.T) {
+	sheet := NewSheet()
+	first := sheet.Class("card", Style{"padding": "16px"})
+	version := sheet.Version()
+	link := sheet.LinkTag("/gouix.css")
+	if !strings.Contains(link, `href="/gouix.css?v=`+sheet.Hash()+`"`) || !strings.Contains(link, `data-scoped-version="1"`) {
+		t.Fatalf("unexpected link %s", link)
+	}
+
+	second := sheet.Class("card", Style{"padding": "24px"})
+	since := sheet.CSSSince(version)
+	if !strings.Contains(since, second) || strings.Contains(since, first) {
+		t.Fatalf("expected only the new rules, got\n%s", since)
+	}
+	if sheet.CSSSince(sheet.Version()) != "" {
+		t.Fatal("expected no rules past the sheet's version")
+	}
+
+	rec := httptest.NewRecorder()
+	sheet.ServeHTTP(rec, httptest.NewRequest("GET", "/gouix.css?v="+sheet.Hash(), nil))
+	if rec.Body.String() != sheet.CSS() || !strings.Contains(rec.Header().Get("Cache-Control"), "immutable") {
+		t.Fatalf("expected the CSS cached for good, got %q", rec.Header().Get("Cache-Control"))
+	}
+
+	req := httptest.NewRequest("GET", "/gouix.css", nil)
+	req.Header.Set("If-None-Match", rec.Header().Get("ETag"))
+	rec = httptest.NewRecorder()
+	sheet.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified || rec.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("expected an unchanged sheet to be revalidated, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,202 @@
+package gopm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscale/contract"
+	"github.com/davidjeba/goscript/pkg/goscale/schema"
+	"github.com/davidjeba/goscript/pkg/goscale/sdl"
+	"github.com/davidjeba/goscript/pkg/goscript/cli"
+)
+
+// DefaultAPISchema is the schema gopm api:test checks, where gopm api:init
+// --from-schema puts it.
+const DefaultAPISchema = "schema/schema.graphql"
+
+// APITestOptions captures the arguments for gopm api:test.
+type APITestOptions struct {
+	// Schema is the schema file checked.
+	Schema string
+
+	// Against is the previous version of the schema the changes are
+	// classified against: a file, or a git revision to read Schema from,
+	// such as origin/main. Empty only lints.
+	Against string
+
+	// AllowBreaking reports breaking changes without failing.
+	AllowBreaking bool
+
+	// Suites are contract test suites run against the API at Endpoint, or
+	// else at each suite's endpoint.
+	Suites   []string
+	Endpoint string
+
+	// JUnit is the file the suites' JUnit report is written to, if set.
+	JUnit string
+
+	JSON bool
+}
+
+// APITestReport is what gopm api:test found in a schema.
+type APITestReport struct {
+	Schema   string           `json:"schema"`
+	Against  string           `json:"against,omitempty"`
+	Changes  []schema.Change  `json:"changes"`
+	Problems []schema.Problem `json:"problems"`
+
+	Contracts []contract.SuiteResult `json:"contracts,omitempty"`
+}
+
+// Failed reports whether the schema breaks a naming convention or, unless
+// allowed, clients of the previous version, or a contract case failed.
+func (r *APITestReport) Failed(allowBreaking bool) bool {
+	for _, suite := range r.Contracts {
+		if suite.Failed() > 0 {
+			return true
+		}
+	}
+	return len(r.Problems) > 0 || (!allowBreaking && schema.IsBreaking(r.Changes))
+}
+
+// apiTestOptions reads the options of gopm api:test from its flags.
+func apiTestOptions(c *cli.Context) APITestOptions {
+	opts := APITestOptions{
+		Schema:        strings.TrimSpace(c.String("schema")),
+		Against:       strings.TrimSpace(c.String("against")),
+		AllowBreaking: c.Bool("allow-breaking"),
+		Suites:        c.Strings("suite"),
+		Endpoint:      strings.TrimSpace(c.String("endpoint")),
+		JUnit:         strings.TrimSpace(c.String("junit")),
+		JSON:          c.Bool("json"),
+	}
+	if opts.Schema == "" {
+		opts.Schema = DefaultAPISchema
+	}
+	return opts
+}
+
+// RunAPITest lints a schema, classifies its changes from the previous
+// version and runs the contract suites against the API. Suites can run
+// without the schema, which then does not check their results' shape.
+func RunAPITest(opts APITestOptions) (*APITestReport, error) {
+	source, err := ioutil.ReadFile(opts.Schema)
+	if os.IsNotExist(err) && len(opts.Suites) > 0 && opts.Against == "" {
+		return runContracts(&APITestReport{}, nil, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	doc, err := sdl.Parse(string(source))
+	if err != nil {
+		return nil, fmt.Errorf("%s:%v", opts.Schema, err)
+	}
+	report := &APITestReport{Schema: opts.Schema, Against: opts.Against, Problems: schema.Lint(doc)}
+	if opts.Against != "" {
+		previous, err := previousSchema(opts.Schema, opts.Against)
+		if err != nil {
+			return nil, err
+		}
+		old, err := sdl.Parse(previous)
+		if err != nil {
+			return nil, fmt.Errorf("%s (%s):%v", opts.Schema, opts.Against, err)
+		}
+		report.Changes = schema.Diff(old, doc)
+	}
+	return runContracts(report, doc, opts)
+}
+
+// runContracts runs the contract suites of the options into report, and
+// writes their JUnit report.
+func runContracts(report *APITestReport, doc *sdl.Document, opts APITestOptions) (*APITestReport, error) {
+	if len(opts.Suites) == 0 {
+		return report, nil
+	}
+
+	runner := &contract.Runner{Endpoint: opts.Endpoint, Schema: doc}
+	for _, path := range opts.Suites {
+		suite, err := contract.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		if suite.Endpoint == "" && opts.Endpoint == "" {
+			return nil, fmt.Errorf("%s: no endpoint to test; set one in the suite or with --endpoint", path)
+		}
+		report.Contracts = append(report.Contracts, runner.Run(context.Background(), suite))
+	}
+
+	if opts.JUnit != "" {
+		var junit bytes.Buffer
+		if err := contract.WriteJUnit(&junit, report.Contracts...); err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(opts.JUnit, junit.Bytes(), 0644); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// previousSchema reads the previous version of a schema from the file
+// against, or else from the git revision against.
+func previousSchema(path, against string) (string, error) {
+	if data, err := ioutil.ReadFile(against); err == nil {
+		return string(data), nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", "show", against+":./"+filepath.Base(path))
+	cmd.Dir = filepath.Dir(path)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("reading %s at %s: %v: %s", path, against, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// printAPITestReport prints the changes and problems of a report, and the
+// outcome of its contract cases.
+func printAPITestReport(report *APITestReport) {
+	for _, problem := range report.Problems {
+		fmt.Printf("%s:%s\n", report.Schema, problem)
+	}
+	for _, change := range report.Changes {
+		fmt.Println(change)
+	}
+
+	counts := map[schema.Severity]int{}
+	for _, change := range report.Changes {
+		counts[change.Severity]++
+	}
+	if report.Against != "" {
+		fmt.Printf("%d breaking, %d dangerous and %d safe changes since %s\n",
+			counts[schema.Breaking], counts[schema.Dangerous], counts[schema.Safe], report.Against)
+	}
+	if report.Schema != "" {
+		fmt.Printf("%d naming problems in %s\n", len(report.Problems), report.Schema)
+	}
+
+	for _, suite := range report.Contracts {
+		for _, result := range suite.Results {
+			outcome := "PASS"
+			if !result.Passed() {
+				outcome = "FAIL"
+			}
+			fmt.Printf("%s %s/%s (%v)\n", outcome, suite.Suite, result.Case, result.Latency.Round(time.Millisecond))
+			for _, failure := range result.Failures {
+				fmt.Printf("    %s\n", failure)
+			}
+		}
+		fmt.Printf("%d of %d cases of %s passed\n", len(suite.Results)-suite.Failed(), len(suite.Results), suite.Suite)
+	}
+}
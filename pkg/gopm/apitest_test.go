@@ -0,0 +1,139 @@
+package gopm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/davidjeba/goscript/pkg/goscale/schema"
+)
+
+func TestAPITestOptions(t *testing.T) {
+	c, err := parse("api:test", "--against", "origin/main", "--json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := apiTestOptions(c)
+	if opts.Schema != DefaultAPISchema || opts.Against != "origin/main" || !opts.JSON || opts.AllowBreaking {
+		t.Fatalf("unexpected options %+v", opts)
+	}
+
+	c, err = parse("api:test", "--suite", "users.yaml", "--suite", "posts.yaml", "--endpoint", "http://localhost:8080/graphql", "--junit", "report.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts = apiTestOptions(c)
+	if len(opts.Suites) != 2 || opts.Suites[1] != "posts.yaml" || opts.Endpoint != "http://localhost:8080/graphql" || opts.JUnit != "report.xml" {
+		t.Fatalf("unexpected contract options %+v", opts)
+	}
+}
+
+func TestRunAPITestSuites(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data": {"id": "1", "name": null}}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.graphql")
+	suite := filepath.Join(dir, "users.yaml")
+	junit := filepath.Join(dir, "report.xml")
+	ioutil.WriteFile(path, []byte(`type User { id: ID!, name: String! } type Query { user(id: ID!): User }`), 0644)
+	ioutil.WriteFile(suite, []byte("cases:\n  - operation: query:user\n    variables:\n      id: \"1\"\n"), 0644)
+
+	report, err := RunAPITest(APITestOptions{Schema: path, Suites: []string{suite}, Endpoint: server.URL, JUnit: junit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Contracts) != 1 || !report.Failed(true) {
+		t.Fatalf("expected the null name to fail the contract, got %+v", report.Contracts)
+	}
+	if failures := report.Contracts[0].Results[0].Failures; len(failures) != 1 || failures[0] != "data.name: null for non-null String!" {
+		t.Fatalf("unexpected failures %v", failures)
+	}
+	if data, err := ioutil.ReadFile(junit); err != nil || !strings.Contains(string(data), `<testsuite name="users" tests="1" failures="1"`) {
+		t.Fatalf("expected the JUnit report written, got %s, %v", data, err)
+	}
+
+	// Without a schema the shape is not checked
+	report, err = RunAPITest(APITestOptions{Schema: filepath.Join(dir, "missing.graphql"), Suites: []string{suite}, Endpoint: server.URL})
+	if err != nil || report.Failed(false) {
+		t.Fatalf("expected the suite to pass without a schema, got %+v, %v", report, err)
+	}
+}
+
+func TestRunAPITest(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "schema.graphql")
+	old := filepath.Join(dir, "old.graphql")
+	ioutil.WriteFile(old, []byte(`type User { id: ID!, name: String! } type Query { user(id: ID!): User }`), 0644)
+	ioutil.WriteFile(path, []byte(`type User { id: ID!, name: String, email_address: String } type Query { user(id: ID!): User }`), 0644)
+
+	report, err := RunAPITest(APITestOptions{Schema: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Changes) != 0 || len(report.Problems) != 1 || report.Problems[0].Rule != schema.RuleFieldName {
+		t.Fatalf("expected only the lint problem without a previous schema, got %+v", report)
+	}
+
+	report, err = RunAPITest(APITestOptions{Schema: path, Against: old})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Changes) != 2 || !schema.IsBreaking(report.Changes) || !report.Failed(true) {
+		t.Fatalf("expected the nullable name to break clients, got %+v", report.Changes)
+	}
+
+	ioutil.WriteFile(path, []byte(`type User { id: ID!, name: String!, email: String } type Query { user(id: ID!): User }`), 0644)
+	report, err = RunAPITest(APITestOptions{Schema: path, Against: old})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Failed(false) || len(report.Changes) != 1 || report.Changes[0].Severity != schema.Safe {
+		t.Fatalf("expected an added field to pass, got %+v", report)
+	}
+
+	if _, err := RunAPITest(APITestOptions{Schema: filepath.Join(dir, "missing.graphql")}); err == nil {
+		t.Fatal("expected a missing schema to fail")
+	}
+}
+
+func TestRunAPITestAgainstGitRevision(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	path := filepath.Join(dir, "schema", "schema.graphql")
+	git("init", "-q")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	ioutil.WriteFile(path, []byte(`type Post { id: ID!, title: String } type Query { post(id: ID!): Post }`), 0644)
+	git("add", "-A")
+	git("commit", "-q", "-m", "schema")
+
+	ioutil.WriteFile(path, []byte(`type Post { id: ID! } type Query { post(id: ID!): Post }`), 0644)
+	report, err := RunAPITest(APITestOptions{Schema: path, Against: "HEAD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Changes) != 1 || report.Changes[0].Path != "Post.title" || report.Changes[0].Severity != schema.Breaking {
+		t.Fatalf("expected the removed field against HEAD, got %+v", report.Changes)
+	}
+
+	if _, err := RunAPITest(APITestOptions{Schema: path, Against: "no-such-revision"}); err == nil {
+		t.Fatal("expected an unknown revision to fail")
+	}
+}
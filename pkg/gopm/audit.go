@@ -0,0 +1,425 @@
+package gopm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/cli"
+)
+
+// DefaultAuditFile is where gopm audit writes its report, relative to the
+// project; the Jetpack security monitor reads it from there.
+const DefaultAuditFile = ".gopm/audit.json"
+
+// DefaultOSVURL is the OSV vulnerability database gopm audit queries.
+const DefaultOSVURL = "https://api.osv.dev"
+
+// osvBatchSize caps the modules queried in one OSV batch request.
+const osvBatchSize = 500
+
+// AuditOptions captures the arguments for gopm audit.
+type AuditOptions struct {
+	Dir    string
+	Output string
+	JSON   bool
+
+	// OSVURL is the OSV API queried; empty uses DefaultOSVURL.
+	OSVURL string
+
+	// Client sends the OSV requests; nil uses a client with a 30s timeout.
+	Client *http.Client
+}
+
+// AuditModule is a module of the project's dependency tree.
+type AuditModule struct {
+	Path     string `json:"path"`
+	Version  string `json:"version"`
+	Indirect bool   `json:"indirect,omitempty"`
+}
+
+// Advisory is a known vulnerability affecting a module of the project.
+type Advisory struct {
+	ID       string   `json:"id"`
+	Aliases  []string `json:"aliases,omitempty"`
+	Package  string   `json:"package"`
+	Version  string   `json:"version"`
+	Indirect bool     `json:"indirect,omitempty"`
+
+	// FixedIn is the earliest version fixing it, empty when none does yet.
+	FixedIn string `json:"fixed_in,omitempty"`
+
+	// Severity is the database's rating, such as "HIGH", when it has one.
+	Severity string `json:"severity,omitempty"`
+	Summary  string `json:"summary"`
+	URL      string `json:"url"`
+}
+
+// AuditReport is the advisories found in a project's dependency tree.
+type AuditReport struct {
+	Time       time.Time  `json:"time"`
+	Modules    int        `json:"modules"`
+	Advisories []Advisory `json:"advisories"`
+}
+
+// auditOptions reads the options of gopm audit from its flags and directory
+// argument.
+func auditOptions(c *cli.Context) AuditOptions {
+	opts := AuditOptions{Dir: c.Arg(0), JSON: c.Bool("json"), Output: c.String("output")}
+	if opts.Dir == "" {
+		opts.Dir = "."
+	}
+	if opts.Output == "" {
+		opts.Output = filepath.Join(opts.Dir, DefaultAuditFile)
+	}
+	return opts
+}
+
+// RunAudit checks the modules of the project in opts.Dir against the OSV
+// database.
+func RunAudit(ctx context.Context, opts AuditOptions) (*AuditReport, error) {
+	modules, err := listAuditModules(ctx, opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	return auditModules(ctx, modules, opts)
+}
+
+// listAuditModules lists the modules the project depends on with go list,
+// leaving out the project's own.
+func listAuditModules(ctx context.Context, dir string) ([]AuditModule, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "go", "list", "-m", "-json", "all")
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("listing modules: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	var modules []AuditModule
+	decoder := json.NewDecoder(&stdout)
+	for {
+		var module struct {
+			Path     string
+			Version  string
+			Main     bool
+			Indirect bool
+			Replace  *struct {
+				Path    string
+				Version string
+			}
+		}
+		if err := decoder.Decode(&module); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("listing modules: %v", err)
+		}
+		if module.Main {
+			continue
+		}
+
+		// A replacement is what gets built; local ones have no version
+		if module.Replace != nil {
+			module.Path, module.Version = module.Replace.Path, module.Replace.Version
+		}
+		if module.Version == "" {
+			continue
+		}
+		modules = append(modules, AuditModule{Path: module.Path, Version: module.Version, Indirect: module.Indirect})
+	}
+	return modules, nil
+}
+
+// osvVuln is the part of an OSV vulnerability gopm audit reports.
+type osvVuln struct {
+	ID       string   `json:"id"`
+	Summary  string   `json:"summary"`
+	Details  string   `json:"details"`
+	Aliases  []string `json:"aliases"`
+	Affected []struct {
+		Package struct {
+			Name      string `json:"name"`
+			Ecosystem string `json:"ecosystem"`
+		} `json:"package"`
+		Ranges []struct {
+			Type   string `json:"type"`
+			Events []struct {
+				Introduced string `json:"introduced"`
+				Fixed      string `json:"fixed"`
+			} `json:"events"`
+		} `json:"ranges"`
+	} `json:"affected"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+		URL      string `json:"url"`
+	} `json:"database_specific"`
+}
+
+// auditModules finds the advisories affecting modules, querying OSV in
+// batches and then fetching each vulnerability found once.
+func auditModules(ctx context.Context, modules []AuditModule, opts AuditOptions) (*AuditReport, error) {
+	base := strings.TrimSuffix(opts.OSVURL, "/")
+	if base == "" {
+		base = DefaultOSVURL
+	}
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	report := &AuditReport{Time: time.Now(), Modules: len(modules), Advisories: []Advisory{}}
+	vulns := make(map[string]*osvVuln)
+	for start := 0; start < len(modules); start += osvBatchSize {
+		end := start + osvBatchSize
+		if end > len(modules) {
+			end = len(modules)
+		}
+		batch := modules[start:end]
+
+		type query struct {
+			Package struct {
+				Name      string `json:"name"`
+				Ecosystem string `json:"ecosystem"`
+			} `json:"package"`
+			Version string `json:"version"`
+		}
+		queries := make([]query, len(batch))
+		for i, module := range batch {
+			queries[i].Package.Name = module.Path
+			queries[i].Package.Ecosystem = "Go"
+			queries[i].Version = module.Version
+		}
+
+		var results struct {
+			Results []struct {
+				Vulns []struct {
+					ID string `json:"id"`
+				} `json:"vulns"`
+			} `json:"results"`
+		}
+		if err := osvRequest(ctx, client, http.MethodPost, base+"/v1/querybatch", map[string]interface{}{"queries": queries}, &results); err != nil {
+			return nil, err
+		}
+		if len(results.Results) != len(batch) {
+			return nil, fmt.Errorf("osv: expected %d results, got %d", len(batch), len(results.Results))
+		}
+
+		for i, result := range results.Results {
+			for _, found := range result.Vulns {
+				vuln, ok := vulns[found.ID]
+				if !ok {
+					vuln = &osvVuln{}
+					if err := osvRequest(ctx, client, http.MethodGet, base+"/v1/vulns/"+found.ID, nil, vuln); err != nil {
+						return nil, err
+					}
+					vulns[found.ID] = vuln
+				}
+				report.Advisories = append(report.Advisories, newAdvisory(batch[i], vuln))
+			}
+		}
+	}
+
+	sort.Slice(report.Advisories, func(i, j int) bool {
+		a, b := report.Advisories[i], report.Advisories[j]
+		if a.Package != b.Package {
+			return a.Package < b.Package
+		}
+		return a.ID < b.ID
+	})
+	return report, nil
+}
+
+// osvRequest sends a request to the OSV API, decoding its JSON response
+// into out.
+func osvRequest(ctx context.Context, client *http.Client, method, url string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("osv: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("osv: %s %s: %s: %s", method, url, resp.Status, strings.TrimSpace(string(message)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("osv: %s %s: %v", method, url, err)
+	}
+	return nil
+}
+
+// newAdvisory describes how a vulnerability affects a module.
+func newAdvisory(module AuditModule, vuln *osvVuln) Advisory {
+	advisory := Advisory{
+		ID:       vuln.ID,
+		Aliases:  vuln.Aliases,
+		Package:  module.Path,
+		Version:  module.Version,
+		Indirect: module.Indirect,
+		Severity: strings.ToUpper(vuln.DatabaseSpecific.Severity),
+		Summary:  vuln.Summary,
+		URL:      vuln.DatabaseSpecific.URL,
+	}
+	if advisory.Summary == "" {
+		advisory.Summary = strings.SplitN(strings.TrimSpace(vuln.Details), "\n", 2)[0]
+	}
+	if advisory.URL == "" {
+		advisory.URL = "https://osv.dev/vulnerability/" + vuln.ID
+	}
+
+	// The earliest fix after the version in use; the Go database leaves
+	// the "v" off its versions
+	for _, affected := range vuln.Affected {
+		if affected.Package.Name != module.Path {
+			continue
+		}
+		for _, r := range affected.Ranges {
+			if r.Type != "SEMVER" {
+				continue
+			}
+			for _, event := range r.Events {
+				if event.Fixed == "" {
+					continue
+				}
+				fixed := "v" + strings.TrimPrefix(event.Fixed, "v")
+				if compareVersions(fixed, module.Version) > 0 && (advisory.FixedIn == "" || compareVersions(fixed, advisory.FixedIn) < 0) {
+					advisory.FixedIn = fixed
+				}
+			}
+		}
+	}
+	return advisory
+}
+
+// compareVersions compares semantic versions such as "v1.2.3" and
+// "v1.2.3-rc.1", returning -1, 0 or 1. Pre-releases are ordered by their
+// identifiers, numeric ones numerically.
+func compareVersions(a, b string) int {
+	a, b = strings.TrimPrefix(a, "v"), strings.TrimPrefix(b, "v")
+	a, _ = splitVersion(a, "+")
+	b, _ = splitVersion(b, "+")
+	aCore, aPre := splitVersion(a, "-")
+	bCore, bPre := splitVersion(b, "-")
+
+	if c := compareIdentifiers(strings.Split(aCore, "."), strings.Split(bCore, "."), true); c != 0 {
+		return c
+	}
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+	return compareIdentifiers(strings.Split(aPre, "."), strings.Split(bPre, "."), false)
+}
+
+// splitVersion splits a version at the first sep.
+func splitVersion(version, sep string) (string, string) {
+	if i := strings.Index(version, sep); i >= 0 {
+		return version[:i], version[i+1:]
+	}
+	return version, ""
+}
+
+// compareIdentifiers compares dot-separated version identifiers. Missing
+// core ones count as zero; fewer pre-release ones order first.
+func compareIdentifiers(a, b []string, core bool) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		if i >= len(a) || i >= len(b) {
+			if core {
+				if i >= len(a) {
+					a = append(a, "0")
+				} else {
+					b = append(b, "0")
+				}
+			} else if i >= len(a) {
+				return -1
+			} else {
+				return 1
+			}
+		}
+
+		x, xErr := strconv.Atoi(a[i])
+		y, yErr := strconv.Atoi(b[i])
+		switch {
+		case xErr == nil && yErr == nil:
+			if x != y {
+				if x < y {
+					return -1
+				}
+				return 1
+			}
+		case xErr == nil:
+			return -1
+		case yErr == nil:
+			return 1
+		case a[i] != b[i]:
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// writeAuditReport writes the report as JSON for the security monitor.
+func writeAuditReport(path string, report *AuditReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// printAuditReport prints the advisories, one per line.
+func printAuditReport(report *AuditReport) {
+	if len(report.Advisories) == 0 {
+		fmt.Printf("No known vulnerabilities in %d modules\n", report.Modules)
+		return
+	}
+
+	for _, advisory := range report.Advisories {
+		fix := "no fix available"
+		if advisory.FixedIn != "" {
+			fix = "fixed in " + advisory.FixedIn
+		}
+		fmt.Printf("%s  %s@%s (%s): %s\n", advisory.ID, advisory.Package, advisory.Version, fix, advisory.Summary)
+	}
+	fmt.Printf("%d vulnerabilities in %d modules\n", len(report.Advisories), report.Modules)
+}
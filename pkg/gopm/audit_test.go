@@ -0,0 +1,121 @@
+package gopm
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuditModules(t *testing.T) {
+	fetched := 0
+	osv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/querybatch":
+			var body struct {
+				Queries []struct {
+					Package struct{ Name, Ecosystem string }
+					Version string
+				}
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			results := make([]map[string]interface{}, len(body.Queries))
+			for i, query := range body.Queries {
+				results[i] = map[string]interface{}{}
+				if query.Package.Ecosystem == "Go" && query.Package.Name == "golang.org/x/net" && query.Version == "v0.7.0" {
+					results[i]["vulns"] = []map[string]string{{"id": "GO-2023-1988"}}
+				}
+				if query.Package.Name == "golang.org/x/text" {
+					results[i]["vulns"] = []map[string]string{{"id": "GO-2023-1988"}}
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+		case "/v1/vulns/GO-2023-1988":
+			fetched++
+			w.Write([]byte(`{"id": "GO-2023-1988", "aliases": ["CVE-2023-3978"],
+				"details": "Improper rendering of text nodes in golang.org/x/net/html\nMore details.",
+				"affected": [
+					{"package": {"name": "golang.org/x/net", "ecosystem": "Go"}, "ranges": [{"type": "SEMVER", "events": [
+						{"introduced": "0"}, {"fixed": "0.6.0"}, {"introduced": "0.7.0-rc.1"}, {"fixed": "0.13.0"}, {"introduced": "0.14.0"}, {"fixed": "0.14.1"}]}]}
+				]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer osv.Close()
+
+	modules := []AuditModule{
+		{Path: "golang.org/x/net", Version: "v0.7.0"},
+		{Path: "golang.org/x/text", Version: "v0.3.0", Indirect: true},
+		{Path: "github.com/example/safe", Version: "v1.0.0"},
+	}
+	report, err := auditModules(context.Background(), modules, AuditOptions{OSVURL: osv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Modules != 3 || len(report.Advisories) != 2 || fetched != 1 {
+		t.Fatalf("unexpected report %+v, fetched %d", report, fetched)
+	}
+
+	net := report.Advisories[0]
+	if net.Package != "golang.org/x/net" || net.Version != "v0.7.0" || net.FixedIn != "v0.13.0" ||
+		net.Summary != "Improper rendering of text nodes in golang.org/x/net/html" ||
+		net.URL != "https://osv.dev/vulnerability/GO-2023-1988" || net.Aliases[0] != "CVE-2023-3978" {
+		t.Fatalf("unexpected advisory %+v", net)
+	}
+	if text := report.Advisories[1]; text.Package != "golang.org/x/text" || !text.Indirect || text.FixedIn != "" {
+		t.Fatalf("expected no fix for a package the advisory lists no ranges for, got %+v", text)
+	}
+
+	path := filepath.Join(t.TempDir(), DefaultAuditFile)
+	if err := writeAuditReport(path, report); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(path); err != nil || !strings.Contains(string(data), `"fixed_in": "v0.13.0"`) {
+		t.Fatalf("expected the report to be written, got %s %v", data, err)
+	}
+
+	if _, err := auditModules(context.Background(), modules, AuditOptions{OSVURL: osv.URL + "/missing"}); err == nil {
+		t.Fatalf("expected an OSV error to fail the audit")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		want int
+	}{
+		{"v1.2.3", "v1.2.3", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"v1.10.0", "v1.9.0", 1},
+		{"v0.13.0", "v0.7.0", 1},
+		{"v1.2", "v1.2.0", 0},
+		{"v1.0.0-rc.1", "v1.0.0", -1},
+		{"v1.0.0-rc.2", "v1.0.0-rc.10", -1},
+		{"v1.0.0-alpha", "v1.0.0-alpha.1", -1},
+		{"v0.0.0-20230101000000-abcdef", "v0.0.0-20240101000000-abcdef", -1},
+		{"v1.0.0+incompatible", "v1.0.0", 0},
+	} {
+		if got := compareVersions(c.a, c.b); got != c.want {
+			t.Fatalf("compareVersions(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestAuditOptions(t *testing.T) {
+	c, err := parse("audit", "--json", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := auditOptions(c)
+	if !opts.JSON || opts.Dir != "app" || opts.Output != filepath.Join("app", DefaultAuditFile) {
+		t.Fatalf("unexpected options %+v", opts)
+	}
+	if _, err := parse("audit", "--fix"); err == nil {
+		t.Fatalf("expected an unknown flag to be refused")
+	}
+}
@@ -0,0 +1,277 @@
+package gopm
+
+import (
+	"github.com/davidjeba/goscript/pkg/goscript/cli"
+)
+
+// Command groups in gopm's help
+const (
+	basicCommands  = "Basic Commands"
+	cssCommands    = "Gocsx CSS Framework Commands"
+	webGPUCommands = "WebGPU and 3D Commands"
+	canvasCommands = "2D Canvas Commands"
+	uixCommands    = "GoUIX Commands"
+	apiCommands    = "GoScale API Commands"
+	dbCommands     = "GoScale DB Commands"
+)
+
+// Command returns the gopm command line tool, its commands running on pm.
+// Other commands, such as jetpack's, can be added to its Commands.
+func (pm *PackageManager) Command() *cli.Command {
+	return &cli.Command{
+		Name:  "gopm",
+		Short: "Go Package Manager",
+		Flags: []*cli.Flag{
+			{Name: "registry", Usage: "Package registry URL", Value: pm.Config.RegistryURL, Env: "GOPM_REGISTRY", Placeholder: "url"},
+			{Name: "cache-dir", Usage: "Package cache directory", Value: pm.Config.CacheDir, Env: "GOPM_CACHE_DIR", Placeholder: "dir"},
+			{Name: "offline", Usage: "Only use cached packages", Value: pm.Config.OfflineMode, Env: "GOPM_OFFLINE"},
+		},
+		Before: pm.configure,
+		Commands: []*cli.Command{
+			{
+				Name: "get", Usage: "[packages...]", Short: "Install packages", Group: basicCommands,
+				Long: "Adds the packages to the project's modules, vendors them and records the hashes of " +
+					"the vendored files in " + DefaultLockFile + ", as gopm sync does.",
+				Flags: []*cli.Flag{
+					{Name: "save", Usage: "Save to dependencies", Value: false},
+					{Name: "save-dev", Usage: "Save to devDependencies", Value: false},
+					{Name: "global", Usage: "Install globally", Value: false},
+					{Name: "lock", Usage: "Lock file (default ./" + DefaultLockFile + ")", Value: "", Placeholder: "file"},
+				},
+				Run: pm.Get,
+			},
+			{
+				Name: "update", Usage: "[packages...]", Short: "Update packages", Group: basicCommands,
+				Flags: []*cli.Flag{
+					{Name: "latest", Usage: "Update to the latest version", Value: false},
+					{Name: "global", Usage: "Update global packages", Value: false},
+				},
+				Run: pm.Update,
+			},
+			{Name: "clean", Short: "Clean project", Group: basicCommands, Args: cli.NoArgs, Run: pm.Clean},
+			{Name: "run", Usage: "<script> [args...]", Short: "Run a script", Group: basicCommands, Args: cli.MinArgs(1), Run: pm.Run},
+			{
+				Name: "audit", Usage: "[dir]", Short: "Check dependencies for known vulnerabilities", Group: basicCommands,
+				Long: "Checks the modules of the project against the OSV database and writes the report " +
+					"the Jetpack security monitor reads, failing when any module is vulnerable.",
+				Flags: []*cli.Flag{
+					{Name: "json", Usage: "Print the report as JSON", Value: false},
+					{Name: "output", Short: "o", Usage: "Report file (default <dir>/" + DefaultAuditFile + ")", Value: "", Placeholder: "file"},
+				},
+				Args: cli.MaxArgs(1),
+				Run:  pm.Audit,
+			},
+			{Name: "publish", Short: "Publish a package", Group: basicCommands, Args: cli.NoArgs, Run: pm.Publish},
+			{Name: "version", Short: "Show version information", Group: basicCommands, Args: cli.NoArgs, Run: pm.Version},
+			{Name: "cache-clear", Short: "Clear the cache", Group: basicCommands, Args: cli.NoArgs, Run: pm.CacheClear},
+			{Name: "list", Short: "List installed packages", Group: basicCommands, Args: cli.NoArgs, Run: pm.List},
+			{
+				Name: "verify", Usage: "[dir]", Short: "Verify vendored files against the lock file", Group: basicCommands,
+				Long: "Hashes the files of the vendor directory and compares their Merkle tree with the one " +
+					"gopm sync recorded in " + DefaultLockFile + ", reporting modified, missing and added files.",
+				Flags: []*cli.Flag{
+					{Name: "repair", Usage: "Vendor the modules again to restore changed files", Value: false},
+					{Name: "json", Usage: "Print the report as JSON", Value: false},
+					{Name: "lock", Usage: "Lock file (default <dir>/" + DefaultLockFile + ")", Value: "", Placeholder: "file"},
+					{Name: "root", Usage: "Merkle root the lock file must record", Value: "", Placeholder: "hash"},
+				},
+				Args: cli.MaxArgs(1),
+				Run:  pm.Verify,
+			},
+			{Name: "dedupe", Short: "Remove duplicate packages", Group: basicCommands, Args: cli.NoArgs, Run: pm.Dedupe},
+			{Name: "prune", Short: "Remove unused packages", Group: basicCommands, Args: cli.NoArgs, Run: pm.Prune},
+			{
+				Name: "config", Usage: "[registry|cache-dir|global-dir value]", Short: "Manage configuration", Group: basicCommands,
+				Args: cli.MaxArgs(2), Run: pm.Configure,
+			},
+			{Name: "auth", Short: "Authenticate with registry", Group: basicCommands, Args: cli.NoArgs, Run: pm.Auth},
+			{
+				Name: "setup", Usage: "[project]", Short: "Setup project and generate a build manifest", Group: basicCommands,
+				Flags: []*cli.Flag{
+					{Name: "cs", Usage: "Client-server mode", Value: false},
+					{Name: "sw", Usage: "Swarm mode", Value: false},
+					{Name: "mode", Usage: "Topology", Value: "cs", Choices: []string{"cs", "sw"}},
+					{Name: "type", Aliases: []string{"template"}, Usage: "Project type", Value: "app", Choices: []string{"website", "web", "app", "erp"}},
+					{Name: "entrypoint", Usage: "Server package", Value: "./cmd/server", Placeholder: "package"},
+					{Name: "manifest", Usage: "Manifest name (default the project's)", Value: "", Placeholder: "name"},
+					{Name: "force", Usage: "Overwrite existing files", Value: false},
+				},
+				Args:     cli.MaxArgs(1),
+				Examples: []string{"gopm setup my-project", "gopm setup --cs --type website my-site", "gopm setup --sw --type erp my-erp"},
+				Run:      pm.Setup,
+			},
+			{
+				Name: "sync", Usage: "[dir]", Short: "Vendor dependencies and lock their files", Group: basicCommands,
+				Flags: []*cli.Flag{
+					{Name: "lock", Usage: "Lock file (default <dir>/" + DefaultLockFile + ")", Value: "", Placeholder: "file"},
+				},
+				Args: cli.MaxArgs(1),
+				Run:  pm.Sync,
+			},
+			{Name: "doctor", Short: "Diagnose and fix issues", Group: basicCommands, Args: cli.NoArgs, Run: pm.Doctor},
+			{Name: "migrate", Short: "Migrate to a new version", Group: basicCommands, Args: cli.NoArgs, Run: pm.Migrate},
+			{Name: "rollback", Short: "Rollback to a previous version", Group: basicCommands, Args: cli.NoArgs, Run: pm.Rollback},
+
+			{Name: "css:build", Short: "Build CSS", Group: cssCommands, Args: cli.NoArgs, Run: pm.CSSBuild},
+			{Name: "css:watch", Short: "Watch and rebuild CSS", Group: cssCommands, Args: cli.NoArgs, Run: pm.CSSWatch},
+			{Name: "css:optimize", Short: "Optimize CSS", Group: cssCommands, Args: cli.NoArgs, Run: pm.CSSOptimize},
+			{Name: "css:analyze", Short: "Analyze CSS usage", Group: cssCommands, Args: cli.NoArgs, Run: pm.CSSAnalyze},
+			{
+				Name: "css:theme", Short: "Manage themes", Group: cssCommands,
+				Commands: []*cli.Command{
+					{Name: "create", Usage: "<theme>", Short: "Create a theme", Args: cli.ExactArgs(1), Run: pm.CSSThemeCreate},
+					{Name: "list", Short: "List themes", Args: cli.NoArgs, Run: pm.CSSThemeList},
+					{Name: "apply", Usage: "<theme>", Short: "Apply a theme", Args: cli.ExactArgs(1), Run: pm.CSSThemeApply},
+					{
+						Name: "preview", Short: "Serve a docs site of the theme and components",
+						Long: "Shows the theme's colors, spacing, typography, radii and shadows and a gallery of " +
+							"the components' variants, reading the theme again on each reload.",
+						Flags: []*cli.Flag{
+							{Name: "theme", Usage: "Theme JSON merged over the default (default " + DefaultThemeFile + " if it exists)", Value: "", Placeholder: "file"},
+							{Name: "out", Short: "o", Usage: "Write the static site to a directory instead of serving it", Value: "", Placeholder: "dir"},
+							{Name: "addr", Usage: "Address to serve the site at", Value: DefaultPreviewAddr, Placeholder: "host:port"},
+						},
+						Args:     cli.NoArgs,
+						Examples: []string{"gopm css:theme preview", "gopm css:theme preview --theme brand.json --out docs/theme"},
+						Run:      pm.CSSThemePreview,
+					},
+				},
+			},
+
+			{Name: "webgpu:init", Short: "Initialize WebGPU project", Group: webGPUCommands, Args: cli.NoArgs, Run: pm.WebGPUInit},
+			{Name: "webgpu:build", Short: "Build WebGPU shaders", Group: webGPUCommands, Args: cli.NoArgs, Run: pm.WebGPUBuild},
+			{Name: "webgpu:optimize", Short: "Optimize WebGPU performance", Group: webGPUCommands, Args: cli.NoArgs, Run: pm.WebGPUOptimize},
+			{Name: "3d:scene", Short: "Create 3D scene", Group: webGPUCommands, Args: cli.NoArgs, Run: pm.Scene3DCreate},
+			{Name: "3d:model", Usage: "<model>", Short: "Import 3D model", Group: webGPUCommands, Args: cli.ExactArgs(1), Run: pm.Model3DImport},
+			{Name: "3d:export", Usage: "<model> <output>", Short: "Export 3D model", Group: webGPUCommands, Args: cli.ExactArgs(2), Run: pm.Model3DExport},
+			{
+				Name: "3d:optimize", Usage: "<model.obj>", Short: "Generate LOD levels for a 3D model", Group: webGPUCommands,
+				Flags: []*cli.Flag{
+					{Name: "lods", Aliases: []string{"ratios"}, Usage: "Decreasing triangle ratios of the levels, such as 0.5,0.25,0.125", Value: "", Placeholder: "ratios"},
+					{Name: "out", Short: "o", Usage: "Output directory (default the model's)", Value: "", Placeholder: "dir"},
+				},
+				Args: cli.ExactArgs(1),
+				Run:  pm.Model3DOptimize,
+			},
+			{
+				Name: "3d:convert", Usage: "<model> [output]", Short: "Convert models between OBJ, glTF and FBX", Group: webGPUCommands,
+				Long: "Reads .obj, .gltf, .glb and .fbx models and writes .obj, .gltf and .glb ones, " +
+					"to the output file or next to the model with the --to format.",
+				Flags: []*cli.Flag{
+					{Name: "to", Short: "t", Usage: "Output format", Value: "", Choices: []string{"obj", "gltf", "glb"}},
+				},
+				Args:     cli.RangeArgs(1, 2),
+				Examples: []string{"gopm 3d:convert model.fbx model.gltf", "gopm 3d:convert --to glb model.obj"},
+				Run:      pm.Model3DConvert,
+			},
+
+			{Name: "2d:init", Short: "Initialize 2D canvas project", Group: canvasCommands, Args: cli.NoArgs, Run: pm.Canvas2DInit},
+			{Name: "2d:sprite", Usage: "<name>", Short: "Create sprite", Group: canvasCommands, Args: cli.ExactArgs(1), Run: pm.SpriteCreate},
+			{Name: "2d:animation", Usage: "<name>", Short: "Create animation", Group: canvasCommands, Args: cli.ExactArgs(1), Run: pm.AnimationCreate},
+			{Name: "2d:atlas", Usage: "<name>", Short: "Create sprite atlas", Group: canvasCommands, Args: cli.ExactArgs(1), Run: pm.AtlasCreate},
+			{Name: "2d:optimize", Short: "Optimize 2D canvas performance", Group: canvasCommands, Args: cli.NoArgs, Run: pm.Canvas2DOptimize},
+
+			{Name: "uix:init", Short: "Initialize UIX project", Group: uixCommands, Args: cli.NoArgs, Run: pm.UIXInit},
+			{Name: "uix:component", Usage: "<name>", Short: "Create UIX component", Group: uixCommands, Args: cli.ExactArgs(1), Run: pm.UIXComponentCreate},
+			{
+				Name: "uix:test", Usage: "[packages...]", Short: "Test UIX components", Group: uixCommands,
+				Flags: []*cli.Flag{
+					{Name: "update", Short: "u", Usage: "Update the snapshots", Value: false},
+					{Name: "a11y", Usage: "Audit the rendered components for accessibility", Value: false},
+					{Name: "run", Usage: "Only run the tests matching a pattern", Value: "", Placeholder: "pattern"},
+					{Name: "verbose", Short: "v", Usage: "Print each test", Value: false},
+					{Name: "race", Usage: "Detect data races", Value: false},
+				},
+				Run: pm.UIXTest,
+			},
+			{Name: "uix:props", Usage: "[dirs...]", Short: "Generate typed component props", Group: uixCommands, Run: pm.UIXProps},
+			{Name: "uix:storybook", Short: "Start UIX storybook", Group: uixCommands, Args: cli.NoArgs, Run: pm.UIXStorybook},
+			{
+				Name: "uix:build", Short: "Build UIX project", Group: uixCommands,
+				Flags: []*cli.Flag{
+					{Name: "wasm", Usage: "Build the client in a main package for WebAssembly", Value: "", Placeholder: "package"},
+					{Name: "out", Short: "o", Usage: "Output file of the client (default static/app.wasm)", Value: "", Placeholder: "file"},
+				},
+				Examples: []string{"gopm uix:build --wasm ./cmd/client", "gopm uix:build --wasm ./cmd/client -o public/app.wasm"},
+				Args:     cli.NoArgs,
+				Run:      pm.UIXBuild,
+			},
+
+			{
+				Name: "api:init", Short: "Initialize API project", Group: apiCommands,
+				Flags: []*cli.Flag{
+					{Name: "from-schema", Usage: "Scaffold tables, resolvers, a typed client and pages from a GraphQL schema", Value: "", Placeholder: "file"},
+					{Name: "out", Short: "o", Usage: "Output directory", Value: ".", Placeholder: "dir"},
+					{Name: "module", Usage: "Import path of the output directory (default from go.mod)", Value: "", Placeholder: "path"},
+					{Name: "db-schema", Usage: "Database schema of the tables", Value: "public", Placeholder: "name"},
+					{Name: "force", Usage: "Overwrite existing files", Value: false},
+				},
+				Args: cli.NoArgs,
+				Run:  pm.APIInit,
+			},
+			{Name: "api:schema", Usage: "<name>", Short: "Create API schema", Group: apiCommands, Args: cli.ExactArgs(1), Run: pm.APISchemaCreate},
+			{Name: "api:deploy", Short: "Deploy API", Group: apiCommands, Args: cli.NoArgs, Run: pm.APIDeploy},
+			{Name: "api:edge", Short: "Deploy to edge network", Group: apiCommands, Args: cli.NoArgs, Run: pm.APIEdgeDeploy},
+			{
+				Name: "api:test", Short: "Lint the API schema, check it for breaking changes and run contract tests", Group: apiCommands,
+				Long: "Checks the schema's naming conventions and, with --against, classifies its changes from " +
+					"the previous version as breaking, dangerous or safe, failing on breaking changes. " +
+					"With --suite, it also runs contract test suites against the running API, checking each " +
+					"case's status, latency budget and result shape against the schema.",
+				Flags: []*cli.Flag{
+					{Name: "schema", Usage: "Schema file", Value: DefaultAPISchema, Placeholder: "file"},
+					{Name: "against", Usage: "Previous schema: a file, or a git revision such as origin/main", Value: "", Placeholder: "file|rev"},
+					{Name: "allow-breaking", Usage: "Report breaking changes without failing", Value: false},
+					{Name: "suite", Usage: "Contract test suite to run (YAML, TOML or JSON); repeatable", Value: []string{}, Placeholder: "file"},
+					{Name: "endpoint", Usage: "URL of the API the suites test, overriding theirs", Value: "", Placeholder: "url"},
+					{Name: "junit", Usage: "Write the suites' results as a JUnit XML report", Value: "", Placeholder: "file"},
+					{Name: "json", Usage: "Print the report as JSON", Value: false},
+				},
+				Examples: []string{
+					"gopm api:test --against origin/main",
+					"gopm api:test --suite tests/users.yaml --endpoint http://localhost:8080/graphql --junit report.xml",
+				},
+				Args: cli.NoArgs,
+				Run:  pm.APITest,
+			},
+			{Name: "api:doc", Short: "Generate API documentation", Group: apiCommands, Args: cli.NoArgs, Run: pm.APIDocGenerate},
+
+			{Name: "db:init", Short: "Initialize database", Group: dbCommands, Args: cli.NoArgs, Run: pm.DBInit},
+			{Name: "db:migrate", Short: "Run database migrations", Group: dbCommands, Args: cli.NoArgs, Run: pm.DBMigrate},
+			{
+				Name: "db:seed", Short: "Seed database", Group: dbCommands,
+				Long: "With --fake, generates realistic rows for each table of the API schema, chosen by " +
+					"field names and types, and writes them as SQL in one transaction or as NDJSON. " +
+					"Referenced tables come first and foreign keys hold.",
+				Flags: []*cli.Flag{
+					{Name: "fake", Usage: "Generate this many fake rows per table, such as 500 or 10k", Value: "", Placeholder: "count"},
+					{Name: "schema", Usage: "Schema file", Value: DefaultAPISchema, Placeholder: "file"},
+					{Name: "db-schema", Usage: "Database schema of the tables", Value: "public", Placeholder: "name"},
+					{Name: "seed", Usage: "Random seed; the same seed generates the same rows", Value: 1, Placeholder: "n"},
+					{Name: "format", Usage: "Output format: sql or ndjson", Value: "sql", Placeholder: "format"},
+					{Name: "out", Short: "o", Usage: "Output file (default stdout)", Value: "", Placeholder: "file"},
+				},
+				Examples: []string{
+					"gopm db:seed --fake 10k | psql \"$DATABASE_URL\"",
+					"gopm db:seed --fake 500 --format ndjson -o seed.ndjson",
+				},
+				Args: cli.NoArgs,
+				Run:  pm.DBSeed,
+			},
+			{Name: "db:backup", Short: "Backup database", Group: dbCommands, Args: cli.NoArgs, Run: pm.DBBackup},
+			{Name: "db:restore", Short: "Restore database", Group: dbCommands, Args: cli.NoArgs, Run: pm.DBRestore},
+			{Name: "db:schema", Usage: "<name>", Short: "Create database schema", Group: dbCommands, Args: cli.ExactArgs(1), Run: pm.DBSchemaCreate},
+			{Name: "db:timeseries", Usage: "<table>", Short: "Enable time series features", Group: dbCommands, Args: cli.ExactArgs(1), Run: pm.DBTimeSeriesEnable},
+		},
+	}
+}
+
+// configure applies gopm's global flags to its config.
+func (pm *PackageManager) configure(c *cli.Context) error {
+	pm.Config.RegistryURL = c.String("registry")
+	pm.Registry.URL = pm.Config.RegistryURL
+	pm.Config.CacheDir = c.String("cache-dir")
+	pm.Cache.Dir = pm.Config.CacheDir
+	pm.Config.OfflineMode = c.Bool("offline")
+	return nil
+}
@@ -0,0 +1,45 @@
+package gopm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/davidjeba/goscript/pkg/goscript/cli"
+)
+
+// parse parses a gopm command line the way the gopm binary does.
+func parse(args ...string) (*cli.Context, error) {
+	return NewPackageManager().Command().Parse(args)
+}
+
+func TestCommand(t *testing.T) {
+	pm := NewPackageManager()
+	root := pm.Command()
+	var stdout bytes.Buffer
+	root.Stdout = &stdout
+
+	t.Setenv("GOPM_REGISTRY", "https://registry.example")
+	if err := root.Execute([]string{"--offline", "css:theme", "list"}); err != nil {
+		t.Fatal(err)
+	}
+	if pm.Config.RegistryURL != "https://registry.example" || pm.Registry.URL != pm.Config.RegistryURL || !pm.Config.OfflineMode {
+		t.Fatalf("expected the global flags to configure gopm, got %+v", pm.Config)
+	}
+
+	if err := root.Execute([]string{"help"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Basic Commands:", "  audit ", "GoScale DB Commands:", "  db:timeseries "} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Fatalf("expected the help to contain %q, got:\n%s", want, stdout.String())
+		}
+	}
+
+	if _, err := parse("db:schema"); err == nil {
+		t.Fatalf("expected a missing schema name to be refused")
+	}
+	if got := root.Complete([]string{"3d:convert", "--to", "g"}); len(got) != 2 || got[0] != "gltf" {
+		t.Fatalf("expected the formats to complete, got %v", got)
+	}
+}
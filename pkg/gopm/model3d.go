@@ -0,0 +1,194 @@
+package gopm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/davidjeba/goscript/pkg/gocsx/engine"
+	"github.com/davidjeba/goscript/pkg/goscript/cli"
+)
+
+// Model3DOptimizeOptions captures the arguments for gopm 3d:optimize.
+type Model3DOptimizeOptions struct {
+	Input     string
+	OutputDir string
+	Ratios    []float64
+}
+
+// model3DOptimizeOptions reads the options of gopm 3d:optimize from its
+// flags and model argument.
+func model3DOptimizeOptions(c *cli.Context) (Model3DOptimizeOptions, error) {
+	opts := Model3DOptimizeOptions{
+		Input:     strings.TrimSpace(c.Arg(0)),
+		OutputDir: strings.TrimSpace(c.String("out")),
+	}
+
+	if lods := c.String("lods"); lods != "" {
+		ratios, err := parseLODRatios(lods)
+		if err != nil {
+			return Model3DOptimizeOptions{}, cli.Usagef("%v", err)
+		}
+		opts.Ratios = ratios
+	}
+
+	if strings.ToLower(filepath.Ext(opts.Input)) != ".obj" {
+		return Model3DOptimizeOptions{}, cli.Usagef("unsupported model format %q (expected .obj)", filepath.Ext(opts.Input))
+	}
+
+	if opts.OutputDir == "" {
+		opts.OutputDir = filepath.Dir(opts.Input)
+	}
+
+	if len(opts.Ratios) == 0 {
+		opts.Ratios = engine.DefaultLODRatios
+	}
+
+	return opts, nil
+}
+
+// parseLODRatios parses a comma-separated list of triangle ratios such as 0.5,0.25
+func parseLODRatios(raw string) ([]float64, error) {
+	var ratios []float64
+	previous := 1.0
+
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		ratio, err := strconv.ParseFloat(part, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LOD ratio %q", part)
+		}
+		if ratio <= 0 || ratio >= previous {
+			return nil, fmt.Errorf("LOD ratios must be decreasing values between 0 and 1, got %q", raw)
+		}
+
+		ratios = append(ratios, ratio)
+		previous = ratio
+	}
+
+	if len(ratios) == 0 {
+		return nil, fmt.Errorf("no LOD ratios given")
+	}
+
+	return ratios, nil
+}
+
+// optimizeModel generates an LOD chain for a model and writes each level next to
+// the output directory as <name>.lod<N>.obj
+func optimizeModel(opts Model3DOptimizeOptions) ([]string, *engine.LODReport, error) {
+	file, err := os.Open(opts.Input)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	name := strings.TrimSuffix(filepath.Base(opts.Input), filepath.Ext(opts.Input))
+	mesh, err := engine.ParseOBJ(file, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse %s: %w", opts.Input, err)
+	}
+
+	if err := os.MkdirAll(opts.OutputDir, 0755); err != nil {
+		return nil, nil, err
+	}
+
+	levels := engine.GenerateLODs(mesh, opts.Ratios)
+
+	var written []string
+	for i, level := range levels[1:] {
+		path := filepath.Join(opts.OutputDir, fmt.Sprintf("%s.lod%d.obj", name, i+1))
+
+		out, err := os.Create(path)
+		if err != nil {
+			return written, nil, err
+		}
+
+		err = engine.WriteOBJ(out, level.Mesh)
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return written, nil, fmt.Errorf("write %s: %w", path, err)
+		}
+
+		written = append(written, path)
+	}
+
+	return written, engine.NewLODReport(levels), nil
+}
+
+// Model3DConvertOptions captures the arguments for gopm 3d:convert.
+type Model3DConvertOptions struct {
+	Input  string
+	Output string
+}
+
+// modelFormats lists the extensions 3d:convert reads and writes
+var modelFormats = map[string]struct{ read, write bool }{
+	".obj":  {true, true},
+	".gltf": {true, true},
+	".glb":  {true, true},
+	".fbx":  {true, false},
+}
+
+// model3DConvertOptions reads the options of gopm 3d:convert from its flag
+// and model arguments.
+func model3DConvertOptions(c *cli.Context) (Model3DConvertOptions, error) {
+	opts := Model3DConvertOptions{Input: c.Arg(0), Output: c.Arg(1)}
+	format := ""
+	if to := c.String("to"); to != "" {
+		format = "." + strings.TrimPrefix(strings.ToLower(strings.TrimSpace(to)), ".")
+	}
+
+	switch {
+	case opts.Output == "" && format == "":
+		return Model3DConvertOptions{}, cli.Usagef("no output file or --to format specified")
+	case opts.Output == "":
+		opts.Output = strings.TrimSuffix(opts.Input, filepath.Ext(opts.Input)) + format
+	case format != "" && strings.ToLower(filepath.Ext(opts.Output)) != format:
+		return Model3DConvertOptions{}, cli.Usagef("output %s does not match --to %s", opts.Output, strings.TrimPrefix(format, "."))
+	}
+
+	inputExt := strings.ToLower(filepath.Ext(opts.Input))
+	if !modelFormats[inputExt].read {
+		return Model3DConvertOptions{}, cli.Usagef("unsupported input format %q (expected .obj, .gltf, .glb or .fbx)", filepath.Ext(opts.Input))
+	}
+
+	outputExt := strings.ToLower(filepath.Ext(opts.Output))
+	if !modelFormats[outputExt].write {
+		return Model3DConvertOptions{}, cli.Usagef("unsupported output format %q (expected .obj, .gltf or .glb)", filepath.Ext(opts.Output))
+	}
+
+	if filepath.Clean(opts.Input) == filepath.Clean(opts.Output) {
+		return Model3DConvertOptions{}, cli.Usagef("input and output are the same file")
+	}
+
+	return opts, nil
+}
+
+// convertModel reads a model in one format and writes it in another. The returned
+// warnings describe anything that was dropped or approximated along the way.
+func convertModel(opts Model3DConvertOptions) (*engine.Model, error) {
+	model, err := engine.LoadModel(opts.Input)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", opts.Input, err)
+	}
+
+	if dir := filepath.Dir(opts.Output); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := engine.SaveModel(opts.Output, model); err != nil {
+		return nil, fmt.Errorf("write %s: %w", opts.Output, err)
+	}
+
+	return model, nil
+}
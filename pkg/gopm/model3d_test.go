@@ -0,0 +1,111 @@
+package gopm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func parseModel3DOptimizeArgs(args ...string) (Model3DOptimizeOptions, error) {
+	c, err := parse(append([]string{"3d:optimize"}, args...)...)
+	if err != nil {
+		return Model3DOptimizeOptions{}, err
+	}
+	return model3DOptimizeOptions(c)
+}
+
+func parseModel3DConvertArgs(args ...string) (Model3DConvertOptions, error) {
+	c, err := parse(append([]string{"3d:convert"}, args...)...)
+	if err != nil {
+		return Model3DConvertOptions{}, err
+	}
+	return model3DConvertOptions(c)
+}
+
+func TestParseModel3DOptimizeArgs(t *testing.T) {
+	opts, err := parseModel3DOptimizeArgs("--lods", "0.5,0.2", "models/ship.obj")
+	if err != nil {
+		t.Fatalf("parseModel3DOptimizeArgs returned error: %v", err)
+	}
+
+	if opts.Input != "models/ship.obj" {
+		t.Fatalf("expected input models/ship.obj, got %q", opts.Input)
+	}
+	if opts.OutputDir != "models" {
+		t.Fatalf("expected output dir models, got %q", opts.OutputDir)
+	}
+	if len(opts.Ratios) != 2 || opts.Ratios[1] != 0.2 {
+		t.Fatalf("unexpected ratios %v", opts.Ratios)
+	}
+
+	if _, err := parseModel3DOptimizeArgs("--lods", "0.25,0.5", "ship.obj"); err == nil {
+		t.Fatalf("expected error for increasing ratios")
+	}
+	if _, err := parseModel3DOptimizeArgs("ship.fbx"); err == nil {
+		t.Fatalf("expected error for unsupported format")
+	}
+}
+
+func TestOptimizeModelWritesLODs(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "quad.obj")
+	source := "v 0 0 0\nv 1 0 0\nv 1 1 0\nv 0 1 0\nv 2 0 0\nv 2 1 0\nf 1 2 3 4\nf 2 5 6 3\n"
+	if err := os.WriteFile(input, []byte(source), 0644); err != nil {
+		t.Fatalf("write model: %v", err)
+	}
+
+	written, report, err := optimizeModel(Model3DOptimizeOptions{
+		Input:     input,
+		OutputDir: filepath.Join(dir, "out"),
+		Ratios:    []float64{0.5},
+	})
+	if err != nil {
+		t.Fatalf("optimizeModel returned error: %v", err)
+	}
+
+	if len(written) != 1 || filepath.Base(written[0]) != "quad.lod1.obj" {
+		t.Fatalf("unexpected output files %v", written)
+	}
+	if len(report.Levels) != 2 || report.Levels[0].Triangles != 4 {
+		t.Fatalf("unexpected report %+v", report.Levels)
+	}
+}
+
+func TestParseModel3DConvertArgs(t *testing.T) {
+	opts, err := parseModel3DConvertArgs("--to", "glb", "models/ship.fbx")
+	if err != nil {
+		t.Fatalf("parseModel3DConvertArgs returned error: %v", err)
+	}
+	if opts.Output != "models/ship.glb" {
+		t.Fatalf("expected output models/ship.glb, got %q", opts.Output)
+	}
+
+	if _, err := parseModel3DConvertArgs("ship.obj", "ship.fbx"); err == nil {
+		t.Fatalf("expected error for FBX output")
+	}
+	if _, err := parseModel3DConvertArgs("ship.obj"); err == nil {
+		t.Fatalf("expected error without an output")
+	}
+}
+
+func TestConvertModelOBJToGLTF(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "quad.obj")
+	source := "v 0 0 0\nv 1 0 0\nv 1 1 0\nv 0 1 0\nl 1 2\nf 1 2 3 4\n"
+	if err := os.WriteFile(input, []byte(source), 0644); err != nil {
+		t.Fatalf("write model: %v", err)
+	}
+
+	output := filepath.Join(dir, "out", "quad.gltf")
+	model, err := convertModel(Model3DConvertOptions{Input: input, Output: output})
+	if err != nil {
+		t.Fatalf("convertModel returned error: %v", err)
+	}
+
+	if len(model.Warnings) == 0 {
+		t.Fatalf("expected a lossy conversion warning for the line element")
+	}
+	if _, err := os.Stat(output); err != nil {
+		t.Fatalf("expected %s to be written: %v", output, err)
+	}
+}
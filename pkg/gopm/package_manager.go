@@ -1,10 +1,15 @@
 package gopm
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+
+	"github.com/davidjeba/goscript/pkg/goscript/cli"
 )
 
 // PackageManager handles package management operations
@@ -170,87 +175,136 @@ func NewPackageManager() *PackageManager {
 
 // Basic package management commands
 
-// Get installs packages
-func (pm *PackageManager) Get(args []string) {
-	fmt.Println("Installing packages:", strings.Join(args, ", "))
+// Get adds packages to the project's modules, vendors them and records the
+// hashes of the vendored files in the lock file, for gopm verify
+func (pm *PackageManager) Get(c *cli.Context) error {
+	fmt.Println("Installing packages:", strings.Join(c.Args, ", "))
+	locked, err := RunSync(context.Background(), SyncOptions{Dir: ".", Packages: c.Args, Lock: c.String("lock")})
+	if err != nil {
+		return err
+	}
+	printLocked(locked)
+	return nil
 }
 
 // Update updates packages
-func (pm *PackageManager) Update(args []string) {
-	fmt.Println("Updating packages:", strings.Join(args, ", "))
+func (pm *PackageManager) Update(c *cli.Context) error {
+	fmt.Println("Updating packages:", strings.Join(c.Args, ", "))
+	return nil
 }
 
 // Clean cleans the project
-func (pm *PackageManager) Clean(args []string) {
+func (pm *PackageManager) Clean(c *cli.Context) error {
 	fmt.Println("Cleaning project")
+	return nil
 }
 
 // Run runs a script
-func (pm *PackageManager) Run(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Error: No script specified")
-		return
-	}
-	fmt.Println("Running script:", args[0])
+func (pm *PackageManager) Run(c *cli.Context) error {
+	fmt.Println("Running script:", c.Args[0])
+	return nil
 }
 
-// Audit checks for vulnerabilities
-func (pm *PackageManager) Audit(args []string) {
-	fmt.Println("Checking for vulnerabilities")
+// Audit checks the project's dependencies for known vulnerabilities and
+// writes the report for the security monitor
+func (pm *PackageManager) Audit(c *cli.Context) error {
+	opts := auditOptions(c)
+
+	report, err := RunAudit(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	if err := writeAuditReport(opts.Output, report); err != nil {
+		return err
+	}
+
+	if opts.JSON {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		printAuditReport(report)
+	}
+	if len(report.Advisories) > 0 {
+		// Fail the command too, so CI sees the vulnerabilities
+		return cli.Exit(1)
+	}
+	return nil
 }
 
 // Publish publishes a package
-func (pm *PackageManager) Publish(args []string) {
+func (pm *PackageManager) Publish(c *cli.Context) error {
 	fmt.Println("Publishing package")
+	return nil
 }
 
 // Version shows version information
-func (pm *PackageManager) Version(args []string) {
+func (pm *PackageManager) Version(c *cli.Context) error {
 	fmt.Println("GOPM version 1.0.0")
+	return nil
 }
 
 // CacheClear clears the cache
-func (pm *PackageManager) CacheClear(args []string) {
+func (pm *PackageManager) CacheClear(c *cli.Context) error {
 	fmt.Println("Clearing cache")
+	return nil
 }
 
 // List lists installed packages
-func (pm *PackageManager) List(args []string) {
+func (pm *PackageManager) List(c *cli.Context) error {
 	fmt.Println("Listing installed packages")
+	return nil
 }
 
-// Verify verifies package integrity
-func (pm *PackageManager) Verify(args []string) {
-	fmt.Println("Verifying package integrity")
+// Verify checks the vendored files against the hashes gopm sync recorded
+// in the lock file, repairing them with --repair
+func (pm *PackageManager) Verify(c *cli.Context) error {
+	opts := verifyOptions(c)
+
+	report, err := RunVerify(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	if opts.JSON {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		printVerifyReport(report)
+	}
+	if !report.OK() {
+		// Fail the command too, so CI sees the changed files
+		return cli.Exit(1)
+	}
+	return nil
 }
 
 // Dedupe removes duplicate packages
-func (pm *PackageManager) Dedupe(args []string) {
+func (pm *PackageManager) Dedupe(c *cli.Context) error {
 	fmt.Println("Removing duplicate packages")
+	return nil
 }
 
 // Prune removes unused packages
-func (pm *PackageManager) Prune(args []string) {
+func (pm *PackageManager) Prune(c *cli.Context) error {
 	fmt.Println("Removing unused packages")
+	return nil
 }
 
-// Config manages configuration
-func (pm *PackageManager) Config(args []string) {
-	if len(args) == 0 {
+// Configure manages configuration
+func (pm *PackageManager) Configure(c *cli.Context) error {
+	if len(c.Args) == 0 {
 		fmt.Println("Current configuration:")
 		fmt.Printf("  Registry URL: %s\n", pm.Config.RegistryURL)
 		fmt.Printf("  Cache directory: %s\n", pm.Config.CacheDir)
 		fmt.Printf("  Global directory: %s\n", pm.Config.GlobalDir)
-		return
+		return nil
 	}
 
-	if len(args) < 2 {
-		fmt.Println("Error: Missing value")
-		return
+	if len(c.Args) < 2 {
+		return cli.Usagef("missing value for %s", c.Args[0])
 	}
 
-	key := args[0]
-	value := args[1]
+	key := c.Args[0]
+	value := c.Args[1]
 
 	switch key {
 	case "registry":
@@ -263,337 +317,434 @@ func (pm *PackageManager) Config(args []string) {
 		pm.Config.GlobalDir = value
 		fmt.Printf("Set global directory to %s\n", value)
 	default:
-		fmt.Printf("Unknown configuration key: %s\n", key)
-	}
-}
-
-// Help shows help
-func (pm *PackageManager) Help(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Usage: gopm [command] [options]")
-		fmt.Println("Run 'gopm help <command>' for more information on a specific command.")
-		return
-	}
-
-	command := args[0]
-	switch command {
-	case "get":
-		fmt.Println("gopm get [packages...] - Install packages")
-		fmt.Println("Options:")
-		fmt.Println("  --save         Save to dependencies")
-		fmt.Println("  --save-dev     Save to devDependencies")
-		fmt.Println("  --global       Install globally")
-	case "update":
-		fmt.Println("gopm update [packages...] - Update packages")
-		fmt.Println("Options:")
-		fmt.Println("  --latest       Update to latest version")
-		fmt.Println("  --global       Update global packages")
-	default:
-		fmt.Printf("No help available for %s\n", command)
+		return cli.Usagef("unknown configuration key %s, expected registry, cache-dir or global-dir", key)
 	}
+	return nil
 }
 
 // Auth authenticates with registry
-func (pm *PackageManager) Auth(args []string) {
+func (pm *PackageManager) Auth(c *cli.Context) error {
 	fmt.Println("Authenticating with registry")
+	return nil
 }
 
 // Setup sets up a project
-func (pm *PackageManager) Setup(args []string) {
-	opts, err := parseSetupArgs(args)
+func (pm *PackageManager) Setup(c *cli.Context) error {
+	opts, err := setupOptions(c)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		fmt.Println("Usage: gopm setup [--cs|--sw|--mode cs|sw] [--type website|app|erp] [project]")
-		return
+		return err
 	}
 
 	manifestPath, err := pm.setupProject(opts)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		return
+		return err
 	}
 
 	fmt.Printf("Project scaffolded in %s\n", opts.ProjectDir)
 	fmt.Printf("Mode: %s\n", opts.Mode)
 	fmt.Printf("Type: %s\n", opts.Type)
 	fmt.Printf("Manifest: %s\n", manifestPath)
+	return nil
+}
+
+// Sync vendors the project's modules and records the hashes of the
+// vendored files in the lock file, for gopm verify
+func (pm *PackageManager) Sync(c *cli.Context) error {
+	dir := c.Arg(0)
+	if dir == "" {
+		dir = "."
+	}
+	locked, err := RunSync(context.Background(), SyncOptions{Dir: dir, Lock: c.String("lock")})
+	if err != nil {
+		return err
+	}
+	printLocked(locked)
+	return nil
 }
 
-// Sync synchronizes dependencies
-func (pm *PackageManager) Sync(args []string) {
-	fmt.Println("Synchronizing dependencies")
+// printLocked prints what a lock file records, with its whole root for
+// gopm verify --root
+func printLocked(locked *LockFile) {
+	fmt.Printf("Locked %d files in %s (root %s)\n", len(locked.Files), locked.Vendor, locked.Root)
 }
 
 // Doctor diagnoses and fixes issues
-func (pm *PackageManager) Doctor(args []string) {
+func (pm *PackageManager) Doctor(c *cli.Context) error {
 	fmt.Println("Diagnosing and fixing issues")
+	return nil
 }
 
 // Migrate migrates to a new version
-func (pm *PackageManager) Migrate(args []string) {
+func (pm *PackageManager) Migrate(c *cli.Context) error {
 	fmt.Println("Migrating to a new version")
+	return nil
 }
 
 // Rollback rolls back to a previous version
-func (pm *PackageManager) Rollback(args []string) {
+func (pm *PackageManager) Rollback(c *cli.Context) error {
 	fmt.Println("Rolling back to a previous version")
+	return nil
 }
 
 // Gocsx CSS framework commands
 
 // CSSBuild builds CSS
-func (pm *PackageManager) CSSBuild(args []string) {
+func (pm *PackageManager) CSSBuild(c *cli.Context) error {
 	fmt.Println("Building CSS")
+	return nil
 }
 
 // CSSWatch watches and rebuilds CSS
-func (pm *PackageManager) CSSWatch(args []string) {
+func (pm *PackageManager) CSSWatch(c *cli.Context) error {
 	fmt.Println("Watching and rebuilding CSS")
+	return nil
 }
 
 // CSSOptimize optimizes CSS
-func (pm *PackageManager) CSSOptimize(args []string) {
+func (pm *PackageManager) CSSOptimize(c *cli.Context) error {
 	fmt.Println("Optimizing CSS")
+	return nil
 }
 
 // CSSAnalyze analyzes CSS usage
-func (pm *PackageManager) CSSAnalyze(args []string) {
+func (pm *PackageManager) CSSAnalyze(c *cli.Context) error {
 	fmt.Println("Analyzing CSS usage")
+	return nil
 }
 
-// CSSTheme manages themes
-func (pm *PackageManager) CSSTheme(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Error: No theme operation specified")
-		return
-	}
+// CSSThemeCreate creates a theme
+func (pm *PackageManager) CSSThemeCreate(c *cli.Context) error {
+	fmt.Printf("Creating theme: %s\n", c.Args[0])
+	return nil
+}
 
-	operation := args[0]
-	switch operation {
-	case "create":
-		if len(args) < 2 {
-			fmt.Println("Error: No theme name specified")
-			return
-		}
-		fmt.Printf("Creating theme: %s\n", args[1])
-	case "list":
-		fmt.Println("Listing themes")
-	case "apply":
-		if len(args) < 2 {
-			fmt.Println("Error: No theme name specified")
-			return
-		}
-		fmt.Printf("Applying theme: %s\n", args[1])
-	default:
-		fmt.Printf("Unknown theme operation: %s\n", operation)
-	}
+// CSSThemeList lists themes
+func (pm *PackageManager) CSSThemeList(c *cli.Context) error {
+	fmt.Println("Listing themes")
+	return nil
+}
+
+// CSSThemeApply applies a theme
+func (pm *PackageManager) CSSThemeApply(c *cli.Context) error {
+	fmt.Printf("Applying theme: %s\n", c.Args[0])
+	return nil
+}
+
+// CSSThemePreview serves a docs site of the theme and components
+func (pm *PackageManager) CSSThemePreview(c *cli.Context) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	return RunThemePreview(ctx, themePreviewOptions(c))
 }
 
 // WebGPU and 3D commands
 
 // WebGPUInit initializes a WebGPU project
-func (pm *PackageManager) WebGPUInit(args []string) {
+func (pm *PackageManager) WebGPUInit(c *cli.Context) error {
 	fmt.Println("Initializing WebGPU project")
+	return nil
 }
 
 // WebGPUBuild builds WebGPU shaders
-func (pm *PackageManager) WebGPUBuild(args []string) {
+func (pm *PackageManager) WebGPUBuild(c *cli.Context) error {
 	fmt.Println("Building WebGPU shaders")
+	return nil
 }
 
 // WebGPUOptimize optimizes WebGPU performance
-func (pm *PackageManager) WebGPUOptimize(args []string) {
+func (pm *PackageManager) WebGPUOptimize(c *cli.Context) error {
 	fmt.Println("Optimizing WebGPU performance")
+	return nil
 }
 
 // Scene3DCreate creates a 3D scene
-func (pm *PackageManager) Scene3DCreate(args []string) {
+func (pm *PackageManager) Scene3DCreate(c *cli.Context) error {
 	fmt.Println("Creating 3D scene")
+	return nil
 }
 
 // Model3DImport imports a 3D model
-func (pm *PackageManager) Model3DImport(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Error: No model file specified")
-		return
-	}
-	fmt.Printf("Importing 3D model: %s\n", args[0])
+func (pm *PackageManager) Model3DImport(c *cli.Context) error {
+	fmt.Printf("Importing 3D model: %s\n", c.Args[0])
+	return nil
 }
 
 // Model3DExport exports a 3D model
-func (pm *PackageManager) Model3DExport(args []string) {
-	if len(args) < 2 {
-		fmt.Println("Error: Missing arguments")
-		return
-	}
-	fmt.Printf("Exporting 3D model %s to %s\n", args[0], args[1])
+func (pm *PackageManager) Model3DExport(c *cli.Context) error {
+	fmt.Printf("Exporting 3D model %s to %s\n", c.Args[0], c.Args[1])
+	return nil
 }
 
-// Model3DOptimize optimizes a 3D model
-func (pm *PackageManager) Model3DOptimize(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Error: No model file specified")
-		return
+// Model3DOptimize generates LOD levels for a 3D model
+func (pm *PackageManager) Model3DOptimize(c *cli.Context) error {
+	opts, err := model3DOptimizeOptions(c)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Optimizing 3D model: %s\n", opts.Input)
+
+	written, report, err := optimizeModel(opts)
+	if err != nil {
+		return err
 	}
-	fmt.Printf("Optimizing 3D model: %s\n", args[0])
+
+	fmt.Print(report.String())
+	for _, path := range written {
+		fmt.Printf("Wrote %s\n", path)
+	}
+	return nil
 }
 
 // Model3DConvert converts between 3D formats
-func (pm *PackageManager) Model3DConvert(args []string) {
-	if len(args) < 2 {
-		fmt.Println("Error: Missing arguments")
-		return
+func (pm *PackageManager) Model3DConvert(c *cli.Context) error {
+	opts, err := model3DConvertOptions(c)
+	if err != nil {
+		return err
 	}
-	fmt.Printf("Converting 3D model from %s to %s\n", args[0], args[1])
+
+	fmt.Printf("Converting 3D model from %s to %s\n", opts.Input, opts.Output)
+
+	model, err := convertModel(opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Converted %d vertices, %d triangles and %d materials\n",
+		len(model.Mesh.Vertices), len(model.Mesh.Indices)/3, len(model.Materials))
+
+	if len(model.Warnings) > 0 {
+		fmt.Println("Lossy conversion:")
+		for _, warning := range model.Warnings {
+			fmt.Printf("  - %s\n", warning)
+		}
+	}
+
+	fmt.Printf("Wrote %s\n", opts.Output)
+	return nil
 }
 
 // 2D Canvas commands
 
 // Canvas2DInit initializes a 2D canvas project
-func (pm *PackageManager) Canvas2DInit(args []string) {
+func (pm *PackageManager) Canvas2DInit(c *cli.Context) error {
 	fmt.Println("Initializing 2D canvas project")
+	return nil
 }
 
 // SpriteCreate creates a sprite
-func (pm *PackageManager) SpriteCreate(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Error: No sprite name specified")
-		return
-	}
-	fmt.Printf("Creating sprite: %s\n", args[0])
+func (pm *PackageManager) SpriteCreate(c *cli.Context) error {
+	fmt.Printf("Creating sprite: %s\n", c.Args[0])
+	return nil
 }
 
 // AnimationCreate creates an animation
-func (pm *PackageManager) AnimationCreate(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Error: No animation name specified")
-		return
-	}
-	fmt.Printf("Creating animation: %s\n", args[0])
+func (pm *PackageManager) AnimationCreate(c *cli.Context) error {
+	fmt.Printf("Creating animation: %s\n", c.Args[0])
+	return nil
 }
 
 // AtlasCreate creates a sprite atlas
-func (pm *PackageManager) AtlasCreate(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Error: No atlas name specified")
-		return
-	}
-	fmt.Printf("Creating sprite atlas: %s\n", args[0])
+func (pm *PackageManager) AtlasCreate(c *cli.Context) error {
+	fmt.Printf("Creating sprite atlas: %s\n", c.Args[0])
+	return nil
 }
 
 // Canvas2DOptimize optimizes 2D canvas performance
-func (pm *PackageManager) Canvas2DOptimize(args []string) {
+func (pm *PackageManager) Canvas2DOptimize(c *cli.Context) error {
 	fmt.Println("Optimizing 2D canvas performance")
+	return nil
 }
 
 // GoUIX commands
 
 // UIXInit initializes a UIX project
-func (pm *PackageManager) UIXInit(args []string) {
+func (pm *PackageManager) UIXInit(c *cli.Context) error {
 	fmt.Println("Initializing UIX project")
+	return nil
 }
 
 // UIXComponentCreate creates a UIX component
-func (pm *PackageManager) UIXComponentCreate(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Error: No component name specified")
-		return
+func (pm *PackageManager) UIXComponentCreate(c *cli.Context) error {
+	fmt.Printf("Creating UIX component: %s\n", c.Args[0])
+	return nil
+}
+
+// UIXTest runs the UIX component tests, including their snapshots
+func (pm *PackageManager) UIXTest(c *cli.Context) error {
+	opts := uixTestOptions(c)
+
+	if opts.Update {
+		fmt.Println("Testing UIX components and updating snapshots")
+	} else {
+		fmt.Println("Testing UIX components")
+	}
+
+	code, err := runUIXTests(opts)
+	if code != 0 {
+		// Fail the command too, so CI sees the test failures
+		return &cli.ExitError{Code: code, Err: err}
 	}
-	fmt.Printf("Creating UIX component: %s\n", args[0])
+	return err
 }
 
-// UIXTest tests UIX components
-func (pm *PackageManager) UIXTest(args []string) {
-	fmt.Println("Testing UIX components")
+// UIXProps generates the typed props code of the given packages
+func (pm *PackageManager) UIXProps(c *cli.Context) error {
+	for _, dir := range uixPropsDirs(c) {
+		path, err := generateUIXProps(dir)
+		if err != nil {
+			return err
+		}
+		if path == "" {
+			fmt.Printf("No //gouix:component props in %s\n", dir)
+			continue
+		}
+		fmt.Printf("Generated %s\n", path)
+	}
+	return nil
 }
 
 // UIXStorybook starts UIX storybook
-func (pm *PackageManager) UIXStorybook(args []string) {
+func (pm *PackageManager) UIXStorybook(c *cli.Context) error {
 	fmt.Println("Starting UIX storybook")
+	return nil
 }
 
-// UIXBuild builds a UIX project
-func (pm *PackageManager) UIXBuild(args []string) {
-	fmt.Println("Building UIX project")
+// UIXBuild builds a UIX project. With --wasm it builds a client for
+// WebAssembly.
+func (pm *PackageManager) UIXBuild(c *cli.Context) error {
+	opts := uixBuildOptions(c)
+	if opts.Wasm == "" {
+		fmt.Println("Building UIX project")
+		return nil
+	}
+
+	fmt.Printf("Building %s for WebAssembly\n", opts.Wasm)
+	loader, err := buildUIXWasm(opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Built %s and %s\n", opts.Out, loader)
+	return nil
 }
 
 // GoScale API commands
 
-// APIInit initializes an API project
-func (pm *PackageManager) APIInit(args []string) {
-	fmt.Println("Initializing API project")
+// APIInit initializes an API project. With --from-schema it scaffolds the
+// schema's tables, resolvers, typed client and pages.
+func (pm *PackageManager) APIInit(c *cli.Context) error {
+	opts, err := apiScaffoldOptions(c)
+	if err != nil {
+		return err
+	}
+	if opts.SchemaPath == "" {
+		fmt.Println("Initializing API project")
+		return nil
+	}
+
+	written, skipped, err := writeAPIScaffold(opts)
+	for _, name := range skipped {
+		fmt.Printf("Skipped %s: it has no ID field to key its table\n", name)
+	}
+	if err != nil {
+		return err
+	}
+	for _, path := range written {
+		fmt.Printf("Generated %s\n", path)
+	}
+	fmt.Printf("Run the server with: go run %s/cmd/server\n", opts.Module)
+	return nil
 }
 
 // APISchemaCreate creates an API schema
-func (pm *PackageManager) APISchemaCreate(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Error: No schema name specified")
-		return
-	}
-	fmt.Printf("Creating API schema: %s\n", args[0])
+func (pm *PackageManager) APISchemaCreate(c *cli.Context) error {
+	fmt.Printf("Creating API schema: %s\n", c.Args[0])
+	return nil
 }
 
 // APIDeploy deploys an API
-func (pm *PackageManager) APIDeploy(args []string) {
+func (pm *PackageManager) APIDeploy(c *cli.Context) error {
 	fmt.Println("Deploying API")
+	return nil
 }
 
 // APIEdgeDeploy deploys to edge network
-func (pm *PackageManager) APIEdgeDeploy(args []string) {
+func (pm *PackageManager) APIEdgeDeploy(c *cli.Context) error {
 	fmt.Println("Deploying to edge network")
+	return nil
 }
 
-// APITest tests an API
-func (pm *PackageManager) APITest(args []string) {
-	fmt.Println("Testing API")
+// APITest lints the API schema and, given its previous version, fails on
+// changes that break clients
+func (pm *PackageManager) APITest(c *cli.Context) error {
+	opts := apiTestOptions(c)
+
+	report, err := RunAPITest(opts)
+	if err != nil {
+		return err
+	}
+	if opts.JSON {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+	} else {
+		printAPITestReport(report)
+	}
+	if report.Failed(opts.AllowBreaking) {
+		return cli.Exit(1)
+	}
+	return nil
 }
 
 // APIDocGenerate generates API documentation
-func (pm *PackageManager) APIDocGenerate(args []string) {
+func (pm *PackageManager) APIDocGenerate(c *cli.Context) error {
 	fmt.Println("Generating API documentation")
+	return nil
 }
 
 // GoScale DB commands
 
 // DBInit initializes a database
-func (pm *PackageManager) DBInit(args []string) {
+func (pm *PackageManager) DBInit(c *cli.Context) error {
 	fmt.Println("Initializing database")
+	return nil
 }
 
 // DBMigrate runs database migrations
-func (pm *PackageManager) DBMigrate(args []string) {
+func (pm *PackageManager) DBMigrate(c *cli.Context) error {
 	fmt.Println("Running database migrations")
+	return nil
 }
 
-// DBSeed seeds a database
-func (pm *PackageManager) DBSeed(args []string) {
+// DBSeed seeds a database, or with --fake writes fake rows for the tables
+// of the API schema
+func (pm *PackageManager) DBSeed(c *cli.Context) error {
+	if c.String("fake") != "" {
+		return dbSeedFake(c)
+	}
 	fmt.Println("Seeding database")
+	return nil
 }
 
 // DBBackup backs up a database
-func (pm *PackageManager) DBBackup(args []string) {
+func (pm *PackageManager) DBBackup(c *cli.Context) error {
 	fmt.Println("Backing up database")
+	return nil
 }
 
 // DBRestore restores a database
-func (pm *PackageManager) DBRestore(args []string) {
+func (pm *PackageManager) DBRestore(c *cli.Context) error {
 	fmt.Println("Restoring database")
+	return nil
 }
 
 // DBSchemaCreate creates a database schema
-func (pm *PackageManager) DBSchemaCreate(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Error: No schema name specified")
-		return
-	}
-	fmt.Printf("Creating database schema: %s\n", args[0])
+func (pm *PackageManager) DBSchemaCreate(c *cli.Context) error {
+	fmt.Printf("Creating database schema: %s\n", c.Args[0])
+	return nil
 }
 
 // DBTimeSeriesEnable enables time series features
-func (pm *PackageManager) DBTimeSeriesEnable(args []string) {
-	if len(args) == 0 {
-		fmt.Println("Error: No table specified")
-		return
-	}
-	fmt.Printf("Enabling time series features for table: %s\n", args[0])
+func (pm *PackageManager) DBTimeSeriesEnable(c *cli.Context) error {
+	fmt.Printf("Enabling time series features for table: %s\n", c.Args[0])
+	return nil
 }
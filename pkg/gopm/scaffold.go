@@ -0,0 +1,538 @@
+package gopm
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/davidjeba/goscript/pkg/goscale/sdl"
+	"github.com/davidjeba/goscript/pkg/goscript/cli"
+	"github.com/davidjeba/goscript/pkg/goscript/naming"
+)
+
+// APIScaffoldOptions are the options of gopm api:init --from-schema.
+type APIScaffoldOptions struct {
+	// SchemaPath is the GraphQL SDL file to scaffold from
+	SchemaPath string
+
+	// OutDir is the directory the project is written to, and Module its
+	// import path
+	OutDir string
+	Module string
+
+	// DBSchema is the database schema the tables are created in
+	DBSchema string
+
+	Force bool
+}
+
+// apiScaffoldOptions reads the options of gopm api:init from its flags. The
+// module defaults to the import path of the output directory in the
+// enclosing Go module.
+func apiScaffoldOptions(c *cli.Context) (APIScaffoldOptions, error) {
+	opts := APIScaffoldOptions{
+		SchemaPath: strings.TrimSpace(c.String("from-schema")),
+		OutDir:     strings.TrimSpace(c.String("out")),
+		Module:     strings.Trim(strings.TrimSpace(c.String("module")), "/"),
+		DBSchema:   strings.TrimSpace(c.String("db-schema")),
+		Force:      c.Bool("force"),
+	}
+	if opts.OutDir == "" {
+		opts.OutDir = "."
+	}
+	if opts.DBSchema == "" {
+		opts.DBSchema = "public"
+	}
+	if opts.SchemaPath == "" || opts.Module != "" {
+		return opts, nil
+	}
+
+	module, err := moduleImportPath(opts.OutDir)
+	if err != nil {
+		return APIScaffoldOptions{}, err
+	}
+	if module == "" {
+		return APIScaffoldOptions{}, cli.Usagef("%s is not in a Go module; pass --module", opts.OutDir)
+	}
+	opts.Module = module
+	return opts, nil
+}
+
+// moduleImportPath returns the import path of dir, which need not exist
+// yet, from the go.mod of the module enclosing it, or "" if there is none.
+func moduleImportPath(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("resolve %s: %w", dir, err)
+	}
+	for root := abs; ; root = filepath.Dir(root) {
+		data, err := ioutil.ReadFile(filepath.Join(root, "go.mod"))
+		if err == nil {
+			module := modulePath(data)
+			if module == "" {
+				return "", fmt.Errorf("%s has no module line", filepath.Join(root, "go.mod"))
+			}
+			rel, err := filepath.Rel(root, abs)
+			if err != nil {
+				return "", err
+			}
+			return path.Join(module, filepath.ToSlash(rel)), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		if filepath.Dir(root) == root {
+			return "", nil
+		}
+	}
+}
+
+// modulePath returns the module path declared in a go.mod file
+func modulePath(gomod []byte) string {
+	for _, line := range strings.Split(string(gomod), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`)
+		}
+	}
+	return ""
+}
+
+// scaffoldType is a schema type gopm api:init generates a table, resolvers,
+// client methods and pages for
+type scaffoldType struct {
+	Name        string
+	Description string
+
+	// Plural is the plural of Name, as in listUsers
+	Plural string
+
+	// Var and PluralVar are Go variable names, such as user and users
+	Var       string
+	PluralVar string
+
+	// Label and PluralLabel are shown in pages, such as "blog post"
+	Label       string
+	PluralLabel string
+
+	// Route is the path of the list page, such as /blog-posts
+	Route string
+
+	// Table is the table storing the type, File the base name of the files
+	// generated for it, and Slug the prefix of its component IDs
+	Table string
+	File  string
+	Slug  string
+
+	// Key is the primary key field; GenerateKey is true when creates fill in
+	// a random key, so the create form leaves it out
+	Key         *scaffoldField
+	GenerateKey bool
+
+	// Fields are the stored fields, the key included, in schema order
+	Fields []*scaffoldField
+}
+
+// scaffoldField is a field of a type stored in a column
+type scaffoldField struct {
+	Name  string
+	Label string
+
+	// GoName and GoType declare the field in the client's struct
+	GoName string
+	GoType string
+
+	// Type is the field type as api.ParseSchema sees it, with enums and
+	// custom scalars as String
+	Type string
+
+	// Input is the form input type, and Options an enum's values
+	Input   string
+	Options []string
+
+	Required bool
+}
+
+// Others returns the fields other than the key.
+func (t *scaffoldType) Others() []*scaffoldField {
+	var fields []*scaffoldField
+	for _, field := range t.Fields {
+		if field != t.Key {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Listed returns the fields shown as list columns: the key and up to three
+// others that are not edited in a textarea, such as lists.
+func (t *scaffoldType) Listed() []*scaffoldField {
+	fields := []*scaffoldField{t.Key}
+	for _, field := range t.Others() {
+		if len(fields) == 4 {
+			break
+		}
+		if field.Input != "textarea" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Imports returns the packages the type's client file uses.
+func (t *scaffoldType) Imports() []string {
+	imports := []string{"context"}
+	var usesJSON, usesTime bool
+	for _, field := range t.Fields {
+		usesJSON = usesJSON || strings.Contains(field.GoType, "json.")
+		usesTime = usesTime || strings.Contains(field.GoType, "time.")
+	}
+	if usesJSON {
+		imports = append(imports, "encoding/json")
+	}
+	if usesTime {
+		imports = append(imports, "time")
+	}
+	return imports
+}
+
+// Initial returns the Go expression of a form field's value for a new
+// record: false for checkboxes, the first option of a required select and
+// nil otherwise.
+func (f *scaffoldField) Initial() string {
+	switch {
+	case f.Input == "checkbox":
+		return "false"
+	case f.Input == "select" && f.Required && len(f.Options) > 0:
+		return fmt.Sprintf("%q", f.Options[0])
+	}
+	return "nil"
+}
+
+// scaffoldGoTypes are the client's Go types of scalar types
+var scaffoldGoTypes = map[string]string{
+	"ID":       "string",
+	"String":   "string",
+	"Int":      "int",
+	"BigInt":   "int64",
+	"Float":    "float64",
+	"Boolean":  "bool",
+	"Time":     "time.Time",
+	"DateTime": "time.Time",
+	"JSON":     "json.RawMessage",
+}
+
+// scaffoldReserved are names the generated client declares itself
+var scaffoldReserved = map[string]bool{"Client": true, "New": true}
+
+// scaffoldTypes returns the object types of a schema document that have a
+// key, and the names of those skipped for lacking one.
+func scaffoldTypes(doc *sdl.Document) ([]*scaffoldType, []string, error) {
+	var types []*scaffoldType
+	var skipped []string
+	for _, definition := range doc.Definitions {
+		if definition.Kind != sdl.KindType || doc.IsOperationType(definition.Name) {
+			continue
+		}
+		if scaffoldReserved[definition.Name] {
+			return nil, nil, fmt.Errorf("type %s clashes with the generated client's %s; rename it", definition.Name, definition.Name)
+		}
+
+		t := newScaffoldType(definition)
+		for _, field := range definition.Fields {
+			f := newScaffoldField(doc, field)
+			if f == nil {
+				continue
+			}
+			t.Fields = append(t.Fields, f)
+
+			// The key is chosen as api.Schema.Tables chooses the primary key
+			column := naming.SnakeCase(field.Name)
+			if column == "id" || (strings.TrimSuffix(field.Type, "!") == "ID" && t.Key == nil) {
+				t.Key = f
+			}
+		}
+		if t.Key == nil {
+			skipped = append(skipped, definition.Name)
+			continue
+		}
+
+		t.GenerateKey = scaffoldGoTypes[sdl.NamedType(t.Key.Type)] == "string"
+		t.Key.Required = !t.GenerateKey
+		t.Key.GoType = strings.TrimPrefix(t.Key.GoType, "*")
+		types = append(types, t)
+	}
+	return types, skipped, nil
+}
+
+func newScaffoldType(definition *sdl.Definition) *scaffoldType {
+	words := splitWords(definition.Name)
+	plural := pluralize(definition.Name)
+	pluralWords := splitWords(plural)
+
+	t := &scaffoldType{
+		Name:        definition.Name,
+		Description: definition.Description,
+		Plural:      plural,
+		Var:         goVar(words),
+		PluralVar:   goVar(pluralWords),
+		Label:       strings.ToLower(strings.Join(words, " ")),
+		PluralLabel: strings.ToLower(strings.Join(pluralWords, " ")),
+		Route:       "/" + strings.ToLower(strings.Join(pluralWords, "-")),
+		Table:       naming.SnakeCase(definition.Name),
+		File:        naming.SnakeCase(definition.Name),
+		Slug:        strings.ToLower(strings.Join(words, "-")),
+	}
+	if table := definition.Directive("table"); table != nil && table.Args["name"] != "" {
+		t.Table = table.Args["name"]
+	}
+	return t
+}
+
+// newScaffoldField returns a stored field, or nil for fields of object
+// types, which api.Schema.Tables leaves to relationships.
+func newScaffoldField(doc *sdl.Document, field *sdl.Field) *scaffoldField {
+	named := sdl.NamedType(field.Type)
+	fieldType := field.Type
+	var options []string
+	if definition := doc.Definition(named); definition != nil {
+		switch definition.Kind {
+		case sdl.KindEnum:
+			options = definition.Values
+		case sdl.KindScalar:
+		default:
+			return nil
+		}
+		fieldType = strings.Replace(fieldType, named, "String", 1)
+		named = "String"
+	}
+
+	words := splitWords(field.Name)
+	f := &scaffoldField{
+		Name:     field.Name,
+		Label:    label(words),
+		GoName:   goName(words),
+		Type:     fieldType,
+		Options:  options,
+		Required: sdl.IsNonNull(field.Type),
+	}
+
+	goType := scaffoldGoTypes[named]
+	switch {
+	case sdl.IsList(field.Type):
+		f.GoType = "[]" + goType
+		f.Input = "textarea"
+		return f
+	case named == "JSON":
+		f.GoType = goType
+		f.Input = "textarea"
+		return f
+	case !f.Required:
+		f.GoType = "*" + goType
+	default:
+		f.GoType = goType
+	}
+
+	lower := strings.ToLower(field.Name)
+	switch {
+	case options != nil:
+		f.Input = "select"
+	case named == "Boolean":
+		f.Input = "checkbox"
+		f.Required = false
+	case named == "Int" || named == "BigInt" || named == "Float":
+		f.Input = "number"
+	case strings.Contains(lower, "email"):
+		f.Input = "email"
+	case strings.Contains(lower, "password"):
+		f.Input = "password"
+	case lower == "description" || lower == "content" || lower == "body" || lower == "bio":
+		f.Input = "textarea"
+	default:
+		f.Input = "text"
+	}
+	return f
+}
+
+// scaffoldInitialisms are the words written in capitals in Go names
+var scaffoldInitialisms = map[string]bool{
+	"API": true, "HTML": true, "HTTP": true, "ID": true, "IP": true,
+	"JSON": true, "SQL": true, "URL": true, "UUID": true,
+}
+
+// splitWords splits a name such as "BlogPost", "avatarURL" or "created_at"
+// into its words.
+func splitWords(name string) []string {
+	return strings.FieldsFunc(naming.SnakeCase(name), func(r rune) bool { return r == '_' })
+}
+
+// goName joins words into an exported Go name, such as AvatarURL.
+func goName(words []string) string {
+	var b strings.Builder
+	for _, word := range words {
+		if upper := strings.ToUpper(word); scaffoldInitialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return b.String()
+}
+
+// goVar joins words into an unexported Go name that is not a keyword.
+func goVar(words []string) string {
+	name := goName(words)
+	lead := 1
+	for _, word := range words[:1] {
+		if scaffoldInitialisms[strings.ToUpper(word)] {
+			lead = len(word)
+		}
+	}
+	name = strings.ToLower(name[:lead]) + name[lead:]
+	if token.IsKeyword(name) {
+		name += "Value"
+	}
+	return name
+}
+
+// label joins words into a label, such as "Avatar URL".
+func label(words []string) string {
+	labels := make([]string, len(words))
+	for i, word := range words {
+		switch {
+		case scaffoldInitialisms[strings.ToUpper(word)]:
+			labels[i] = strings.ToUpper(word)
+		case i == 0:
+			labels[i] = strings.ToUpper(word[:1]) + word[1:]
+		default:
+			labels[i] = word
+		}
+	}
+	return strings.Join(labels, " ")
+}
+
+// pluralize returns the plural of an English noun in a name, such as
+// Categories for Category.
+func pluralize(name string) string {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, "y") && len(lower) > 1 && !strings.ContainsRune("aeiou", rune(lower[len(lower)-2])):
+		return name[:len(name)-1] + "ies"
+	case strings.HasSuffix(lower, "s"), strings.HasSuffix(lower, "x"), strings.HasSuffix(lower, "z"),
+		strings.HasSuffix(lower, "ch"), strings.HasSuffix(lower, "sh"):
+		return name + "es"
+	}
+	return name + "s"
+}
+
+// scaffoldProject is what the templates render
+type scaffoldProject struct {
+	Module   string
+	DBSchema string
+	Types    []*scaffoldType
+}
+
+// scaffoldFile is a generated file and its contents
+type scaffoldFile struct {
+	Path     string
+	Contents []byte
+}
+
+// scaffoldAPI renders the project gopm api:init generates from a schema.
+// It returns the files and the types skipped for lacking a key.
+func scaffoldAPI(source string, opts APIScaffoldOptions) ([]scaffoldFile, []string, error) {
+	doc, err := sdl.Parse(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	types, skipped, err := scaffoldTypes(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(types) == 0 {
+		return nil, skipped, fmt.Errorf("the schema has no object types with an ID field to scaffold")
+	}
+
+	project := &scaffoldProject{Module: opts.Module, DBSchema: opts.DBSchema, Types: types}
+	files := []scaffoldFile{{Path: filepath.Join("schema", "schema.graphql"), Contents: []byte(source)}}
+	render := func(name string, data interface{}, parts ...string) error {
+		var b bytes.Buffer
+		if err := scaffoldTemplates.ExecuteTemplate(&b, name, data); err != nil {
+			return err
+		}
+		contents, err := format.Source(b.Bytes())
+		if err != nil {
+			return fmt.Errorf("format %s: %w", filepath.Join(parts...), err)
+		}
+		files = append(files, scaffoldFile{Path: filepath.Join(parts...), Contents: contents})
+		return nil
+	}
+
+	steps := []struct {
+		template string
+		parts    []string
+	}{
+		{"schema", []string{"schema", "schema.go"}},
+		{"resolvers", []string{"resolvers", "resolvers.go"}},
+		{"client", []string{"client", "client.go"}},
+		{"pages", []string{"pages", "pages.go"}},
+		{"server", []string{"cmd", "server", "main.go"}},
+	}
+	for _, step := range steps {
+		if err := render(step.template, project, step.parts...); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, t := range types {
+		for _, dir := range []string{"resolvers", "client", "pages"} {
+			if err := render(dir+"Type", t, dir, t.File+".go"); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return files, skipped, nil
+}
+
+// writeAPIScaffold writes the scaffolded files below opts.OutDir, refusing
+// to overwrite any unless opts.Force is set. It returns the paths written
+// and the types skipped.
+func writeAPIScaffold(opts APIScaffoldOptions) ([]string, []string, error) {
+	source, err := ioutil.ReadFile(opts.SchemaPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read schema: %w", err)
+	}
+	files, skipped, err := scaffoldAPI(string(source), opts)
+	if err != nil {
+		return nil, skipped, fmt.Errorf("%s: %w", opts.SchemaPath, err)
+	}
+
+	if !opts.Force {
+		for _, file := range files {
+			target := filepath.Join(opts.OutDir, file.Path)
+			if _, err := os.Stat(target); err == nil {
+				return nil, skipped, fmt.Errorf("%s already exists (use --force to overwrite)", target)
+			} else if !os.IsNotExist(err) {
+				return nil, skipped, fmt.Errorf("check %s: %w", target, err)
+			}
+		}
+	}
+
+	var written []string
+	for _, file := range files {
+		target := filepath.Join(opts.OutDir, file.Path)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return written, skipped, fmt.Errorf("create %s: %w", filepath.Dir(target), err)
+		}
+		if err := os.WriteFile(target, file.Contents, 0o644); err != nil {
+			return written, skipped, fmt.Errorf("write %s: %w", target, err)
+		}
+		written = append(written, target)
+	}
+	return written, skipped, nil
+}
@@ -0,0 +1,1031 @@
+package gopm
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/davidjeba/goscript/pkg/goscript/naming"
+)
+
+// scaffoldTemplates render the Go files of gopm api:init. The output is
+// passed through go/format, so the templates only need to get the
+// statements right.
+var scaffoldTemplates = template.Must(template.New("scaffold").Funcs(template.FuncMap{
+	"quote": func(s string) string { return fmt.Sprintf("%q", s) },
+	"tag": func(f *scaffoldField, key bool) string {
+		if key {
+			return fmt.Sprintf("`json:\"%s,omitempty\"`", f.Name)
+		}
+		return fmt.Sprintf("`json:\"%s\"`", f.Name)
+	},
+	"strings": func(values []string) string {
+		quoted := make([]string, len(values))
+		for i, value := range values {
+			quoted[i] = fmt.Sprintf("%q", value)
+		}
+		return "[]string{" + strings.Join(quoted, ", ") + "}"
+	},
+	"column": naming.SnakeCase,
+	"keyVar": func(f *scaffoldField) string { return goVar(splitWords(f.Name)) },
+	"comment": func(text string) string {
+		return "// " + strings.Replace(strings.TrimSpace(text), "\n", "\n// ", -1)
+	},
+	"title": func(s string) string {
+		if s == "" {
+			return s
+		}
+		return strings.ToUpper(s[:1]) + s[1:]
+	},
+}).Parse(scaffoldSchemaTemplate +
+	scaffoldResolversTemplate +
+	scaffoldClientTemplate +
+	scaffoldPagesTemplate +
+	scaffoldServerTemplate))
+
+const scaffoldSchemaTemplate = `
+{{define "schema"}}
+// Package schema holds the API's schema, written in GraphQL SDL in
+// schema.graphql. Edit the SDL to change the API; the server migrates its
+// tables on start.
+package schema
+
+import (
+	_ "embed"
+
+	"github.com/davidjeba/goscript/pkg/goscale/api"
+)
+
+// SDL is the source of the schema
+//
+//go:embed schema.graphql
+var SDL string
+
+// Load parses the schema
+func Load() (*api.Schema, error) {
+	return api.ParseSchema(SDL)
+}
+{{end}}
+`
+
+const scaffoldResolversTemplate = `
+{{define "resolvers"}}
+// Package resolvers serves the schema's types from GoScaleDB tables, with a
+// file describing each type's table.
+package resolvers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/davidjeba/goscript/pkg/goscale/api"
+	"github.com/davidjeba/goscript/pkg/goscale/db"
+	"github.com/davidjeba/goscript/pkg/goscale/sdl"
+)
+
+// Table maps a schema type to the table storing it
+type Table struct {
+	// Type and Plural name the type's operations, such as getUser and
+	// listUsers
+	Type   string
+	Plural string
+
+	// Name of the table, and Key its primary key column
+	Name string
+	Key  string
+
+	// GenerateKey has creates fill in a random key when none is given
+	GenerateKey bool
+
+	Columns []Column
+}
+
+// Column maps a field to a column
+type Column struct {
+	Field string
+	Name  string
+
+	// Type is the field's schema type, such as "String!"
+	Type string
+}
+
+// Tables are the tables Register serves
+var Tables = []*Table{
+{{- range .Types}}
+	{{.Name}}Table,
+{{- end}}
+}
+
+// Register resolves list, get, create, update and delete operations for
+// each table from the tables in the database schema dbSchema, adding those
+// the schema does not declare, and streams the list queries to the export
+// endpoints. Other operations without a resolver answer
+// an error until one is set.
+func Register(schema *api.Schema, database *db.GoScaleDB, dbSchema string) {
+	for _, table := range Tables {
+		table.register(schema, &store{table: table, database: database, name: quote(dbSchema) + "." + quote(table.Name)})
+	}
+
+	for _, operations := range []map[string]*api.Field{schema.Queries, schema.Mutations, schema.Subscriptions} {
+		for name, field := range operations {
+			if field.Resolver == nil {
+				field.SetResolver(notImplemented(name))
+			}
+		}
+	}
+}
+
+func notImplemented(name string) api.Resolver {
+	return func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		return nil, fmt.Errorf("%s is not implemented", name)
+	}
+}
+
+// register adds the table's operations the schema lacks and resolves them
+func (t *Table) register(schema *api.Schema, s *store) {
+	key := t.key()
+	resolve := func(operations map[string]*api.Field, name string, add func(name string) *api.Field, resolver api.Resolver) {
+		field := operations[name]
+		if field == nil {
+			field = add(name)
+		}
+		if field.Resolver == nil {
+			field.SetResolver(resolver)
+		}
+	}
+
+	resolve(schema.Queries, "list"+t.Plural, func(name string) *api.Field {
+		field := schema.AddQuery(name, "["+t.Type+"!]!", "Lists "+t.Plural+" in key order")
+		field.AddArg("limit", "Int", 100, "Maximum number to list")
+		field.AddArg("offset", "Int", 0, "Number to skip")
+		return field
+	}, s.list)
+	if list := schema.Queries["list"+t.Plural]; list.Export == nil {
+		fields := make([]string, len(t.Columns))
+		for i, column := range t.Columns {
+			fields[i] = column.Field
+		}
+		list.SetExport(s.export, fields...)
+	}
+
+	resolve(schema.Queries, "get"+t.Type, func(name string) *api.Field {
+		field := schema.AddQuery(name, t.Type, "Gets a "+t.Type+" by key")
+		field.AddArg(key.Field, nonNull(key.Type), nil, "Key of the "+t.Type)
+		return field
+	}, s.get)
+
+	resolve(schema.Mutations, "create"+t.Type, func(name string) *api.Field {
+		field := schema.AddMutation(name, t.Type+"!", "Creates a "+t.Type)
+		for _, column := range t.Columns {
+			columnType := column.Type
+			if column.Name == t.Key && t.GenerateKey {
+				columnType = strings.TrimSuffix(columnType, "!")
+			}
+			field.AddArg(column.Field, columnType, nil, "")
+		}
+		return field
+	}, s.create)
+
+	resolve(schema.Mutations, "update"+t.Type, func(name string) *api.Field {
+		field := schema.AddMutation(name, t.Type, "Updates the given fields of a "+t.Type)
+		for _, column := range t.Columns {
+			columnType := strings.TrimSuffix(column.Type, "!")
+			if column.Name == t.Key {
+				columnType = nonNull(column.Type)
+			}
+			field.AddArg(column.Field, columnType, nil, "")
+		}
+		return field
+	}, s.update)
+
+	resolve(schema.Mutations, "delete"+t.Type, func(name string) *api.Field {
+		field := schema.AddMutation(name, "Boolean!", "Deletes a "+t.Type+", reporting whether it existed")
+		field.AddArg(key.Field, nonNull(key.Type), nil, "Key of the "+t.Type)
+		return field
+	}, s.delete)
+}
+
+// key returns the key column
+func (t *Table) key() Column {
+	for _, column := range t.Columns {
+		if column.Name == t.Key {
+			return column
+		}
+	}
+	return Column{Field: t.Key, Name: t.Key, Type: "ID!"}
+}
+
+// holdsJSON reports whether the column holds JSON
+func (c Column) holdsJSON() bool {
+	named := sdl.NamedType(c.Type)
+	return sdl.IsList(c.Type) || named == "JSON"
+}
+
+// holdsText reports whether the column holds text
+func (c Column) holdsText() bool {
+	named := sdl.NamedType(c.Type)
+	return !sdl.IsList(c.Type) && (named == "ID" || named == "String")
+}
+
+// store runs a table's operations
+type store struct {
+	table    *Table
+	database *db.GoScaleDB
+
+	// name is the quoted, qualified table name
+	name string
+}
+
+func (s *store) list(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s ORDER BY %s LIMIT $1 OFFSET $2", s.columns(), s.name, quote(s.table.Key))
+	records := []map[string]interface{}{}
+	err := s.database.QueryEach(ctx, query, func(row map[string]interface{}) error {
+		records = append(records, s.record(row))
+		return nil
+	}, intParam(params, "limit", 100), intParam(params, "offset", 0))
+	return records, err
+}
+
+// export streams every row in key order, for the export endpoints
+func (s *store) export(ctx context.Context, params map[string]interface{}, emit func(row map[string]interface{}) error) error {
+	query := fmt.Sprintf("SELECT %s FROM %s ORDER BY %s", s.columns(), s.name, quote(s.table.Key))
+	return s.database.QueryEach(ctx, query, func(row map[string]interface{}) error {
+		return emit(s.record(row))
+	})
+}
+
+func (s *store) get(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s = $1", s.columns(), s.name, quote(s.table.Key))
+	return s.one(ctx, query, params[s.table.key().Field])
+}
+
+func (s *store) create(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	values, err := s.values(params)
+	if err != nil {
+		return nil, err
+	}
+	if key := values[s.table.Key]; s.table.GenerateKey && (key == nil || key == "") {
+		if values[s.table.Key], err = newKey(); err != nil {
+			return nil, err
+		}
+	}
+
+	var names, placeholders []string
+	var args []interface{}
+	for _, column := range s.table.Columns {
+		if value, ok := values[column.Name]; ok {
+			args = append(args, value)
+			names = append(names, quote(column.Name))
+			placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+		}
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING %s",
+		s.name, strings.Join(names, ", "), strings.Join(placeholders, ", "), s.columns())
+	return s.one(ctx, query, args...)
+}
+
+func (s *store) update(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	values, err := s.values(params)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := values[s.table.Key]
+	if !ok || key == nil {
+		return nil, fmt.Errorf("update%s needs %s", s.table.Type, s.table.key().Field)
+	}
+
+	var sets []string
+	var args []interface{}
+	for _, column := range s.table.Columns {
+		if value, ok := values[column.Name]; ok && column.Name != s.table.Key {
+			args = append(args, value)
+			sets = append(sets, fmt.Sprintf("%s = $%d", quote(column.Name), len(args)))
+		}
+	}
+	if len(sets) == 0 {
+		return s.get(ctx, params)
+	}
+	args = append(args, key)
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = $%d RETURNING %s",
+		s.name, strings.Join(sets, ", "), quote(s.table.Key), len(args), s.columns())
+	return s.one(ctx, query, args...)
+}
+
+func (s *store) delete(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s = $1", s.name, quote(s.table.Key))
+	deleted, err := s.database.Execute(ctx, query, params[s.table.key().Field])
+	return deleted > 0, err
+}
+
+// one runs a query returning at most one row and returns it as a record,
+// or nil
+func (s *store) one(ctx context.Context, query string, args ...interface{}) (interface{}, error) {
+	var record map[string]interface{}
+	err := s.database.QueryEach(ctx, query, func(row map[string]interface{}) error {
+		record = s.record(row)
+		return nil
+	}, args...)
+	if err != nil || record == nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// columns returns the quoted column list of the table
+func (s *store) columns() string {
+	names := make([]string, len(s.table.Columns))
+	for i, column := range s.table.Columns {
+		names[i] = quote(column.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// record converts a row to the fields of the type. GoScaleDB decodes any
+// column value that parses as JSON, so text that looked like a number is
+// turned back into text.
+func (s *store) record(row map[string]interface{}) map[string]interface{} {
+	record := make(map[string]interface{}, len(s.table.Columns))
+	for _, column := range s.table.Columns {
+		value := row[column.Name]
+		if _, ok := value.(string); column.holdsText() && value != nil && !ok {
+			data, _ := json.Marshal(value)
+			value = string(data)
+		}
+		record[column.Field] = value
+	}
+	return record
+}
+
+// values converts the parameters naming fields to column values. Empty
+// strings of columns that are not text are stored as NULL, and JSON columns
+// take either JSON text or a value to encode.
+func (s *store) values(params map[string]interface{}) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(params))
+	for _, column := range s.table.Columns {
+		value, ok := params[column.Field]
+		if !ok {
+			continue
+		}
+		switch {
+		case value == "" && !column.holdsText():
+			value = nil
+		case value == nil:
+		case column.holdsJSON():
+			if text, ok := value.(string); ok && json.Valid([]byte(text)) {
+				break
+			}
+			data, err := json.Marshal(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", column.Field, err)
+			}
+			value = string(data)
+		}
+		values[column.Name] = value
+	}
+	return values, nil
+}
+
+// newKey returns a random key
+func newKey() (string, error) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// intParam returns an integer parameter, or fallback if it is missing
+func intParam(params map[string]interface{}, name string, fallback int) int {
+	switch value := params[name].(type) {
+	case float64:
+		return int(value)
+	case int:
+		return value
+	case int64:
+		return int(value)
+	}
+	return fallback
+}
+
+// nonNull returns a type marked non-null
+func nonNull(typeName string) string {
+	return strings.TrimSuffix(typeName, "!") + "!"
+}
+
+// quote quotes an SQL identifier
+func quote(name string) string {
+	return "\"" + strings.Replace(name, "\"", "\"\"", -1) + "\""
+}
+{{end}}
+
+{{define "resolversType"}}
+package resolvers
+
+// {{.Name}}Table stores {{.Name}} records
+var {{.Name}}Table = &Table{
+	Type:        {{quote .Name}},
+	Plural:      {{quote .Plural}},
+	Name:        {{quote .Table}},
+	Key:         {{quote (column .Key.Name)}},
+	GenerateKey: {{.GenerateKey}},
+	Columns: []Column{
+{{- range .Fields}}
+		{Field: {{quote .Name}}, Name: {{quote (column .Name)}}, Type: {{quote .Type}}},
+{{- end}}
+	},
+}
+{{end}}
+`
+
+const scaffoldClientTemplate = `
+{{define "client"}}
+// Package client calls the API with typed records, with a file of methods
+// for each type.
+package client
+
+import (
+	"encoding/json"
+
+	goscale "github.com/davidjeba/goscript/pkg/goscale/client"
+)
+
+// Client calls the API's operations
+type Client struct {
+	*goscale.Client
+}
+
+// New creates a client for the API served at endpoints
+func New(endpoints ...string) *Client {
+	return &Client{Client: goscale.New(endpoints...)}
+}
+
+// variables converts a record to the variables of a mutation
+func variables(record interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	var variables map[string]interface{}
+	err = json.Unmarshal(data, &variables)
+	return variables, err
+}
+{{end}}
+
+{{define "clientType"}}
+package client
+
+import (
+{{- range .Imports}}
+	{{quote .}}
+{{- end}}
+)
+
+// {{.Name}} is a {{.Label}} record
+{{- if .Description}}
+//
+{{comment .Description}}
+{{- end}}
+type {{.Name}} struct {
+{{- $key := .Key}}
+{{- range .Fields}}
+	{{.GoName}} {{.GoType}} {{tag . (eq . $key)}}
+{{- end}}
+}
+
+// List{{.Plural}} returns up to limit {{.PluralLabel}} in key order, skipping offset
+func (c *Client) List{{.Plural}}(ctx context.Context, limit, offset int) ([]{{.Name}}, error) {
+	var {{.PluralVar}} []{{.Name}}
+	err := c.Query(ctx, "list{{.Plural}}", map[string]interface{}{"limit": limit, "offset": offset}, &{{.PluralVar}})
+	return {{.PluralVar}}, err
+}
+
+// Get{{.Name}} returns the {{.Label}} with a key, or nil if there is none
+func (c *Client) Get{{.Name}}(ctx context.Context, {{keyVar .Key}} {{.Key.GoType}}) (*{{.Name}}, error) {
+	var {{.Var}} *{{.Name}}
+	err := c.Query(ctx, "get{{.Name}}", map[string]interface{}{ {{- quote .Key.Name}}: {{keyVar .Key}}}, &{{.Var}})
+	return {{.Var}}, err
+}
+
+// Create{{.Name}} stores a new {{.Label}} and returns it as stored
+{{- if .GenerateKey}}, with a
+// random key if it has none{{end}}
+func (c *Client) Create{{.Name}}(ctx context.Context, {{.Var}} {{.Name}}) (*{{.Name}}, error) {
+	return c.save{{.Name}}(ctx, "create{{.Name}}", {{.Var}})
+}
+
+// Update{{.Name}} stores the fields of a {{.Label}}, returning it as stored,
+// or nil if there is no {{.Label}} with its key
+func (c *Client) Update{{.Name}}(ctx context.Context, {{.Var}} {{.Name}}) (*{{.Name}}, error) {
+	return c.save{{.Name}}(ctx, "update{{.Name}}", {{.Var}})
+}
+
+func (c *Client) save{{.Name}}(ctx context.Context, mutation string, {{.Var}} {{.Name}}) (*{{.Name}}, error) {
+	vars, err := variables({{.Var}})
+	if err != nil {
+		return nil, err
+	}
+	var saved *{{.Name}}
+	err = c.Mutate(ctx, mutation, vars, &saved)
+	return saved, err
+}
+
+// Delete{{.Name}} deletes the {{.Label}} with a key, reporting whether it
+// existed
+func (c *Client) Delete{{.Name}}(ctx context.Context, {{keyVar .Key}} {{.Key.GoType}}) (bool, error) {
+	var deleted bool
+	err := c.Mutate(ctx, "delete{{.Name}}", map[string]interface{}{ {{- quote .Key.Name}}: {{keyVar .Key}}}, &deleted)
+	return deleted, err
+}
+{{end}}
+`
+
+const scaffoldPagesTemplate = `
+{{define "pages"}}
+// Package pages renders list, detail and edit pages for the API's types
+// with GoUIX components styled with gocsx classes, with a file for each
+// type.
+package pages
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/davidjeba/goscript/pkg/gouix"
+)
+
+// sections are the list pages the navigation links to
+var sections = []struct {
+	Label string
+	Route string
+}{
+{{- range .Types}}
+	{ {{- quote (title .PluralLabel)}}, {{quote .Route}}},
+{{- end}}
+}
+
+// Routes returns the routes of every type's pages
+func Routes() []*gouix.Route {
+	var routes []*gouix.Route
+{{- range .Types}}
+	routes = append(routes, {{.Name}}Routes()...)
+{{- end}}
+	return routes
+}
+
+// NewRouter creates a router showing the pages, with links to each list at
+// "/"
+func NewRouter(id gouix.ComponentID) *gouix.Router {
+	home := &gouix.Route{Path: "/", Component: func(*gouix.RouteMatch) gouix.Component {
+		return gouix.FunctionalComponent(func(gouix.Props, ...interface{}) string {
+			var links strings.Builder
+			for _, section := range sections {
+				links.WriteString("<li>" + gouix.Link(section.Route, nil, text(section.Label)) + "</li>")
+			}
+			return layout("Home", "<ul>"+links.String()+"</ul>")
+		})
+	}}
+
+	router := gouix.NewRouter(id, append([]*gouix.Route{home}, Routes()...)...)
+	router.NotFound = func(match *gouix.RouteMatch) gouix.Component {
+		return gouix.FunctionalComponent(func(gouix.Props, ...interface{}) string {
+			return layout("Not found", "<p class=\"text-muted\">There is no page at "+text(match.Path)+".</p>")
+		})
+	}
+	return router
+}
+
+// layout places a page's body below the navigation and its title
+func layout(title string, body ...string) string {
+	var nav strings.Builder
+	nav.WriteString("<nav class=\"d-flex mb-4\">" + gouix.Link("/", gouix.Props{"class": "btn"}, "Home"))
+	for _, section := range sections {
+		nav.WriteString(gouix.Link(section.Route, gouix.Props{"class": "btn"}, text(section.Label)))
+	}
+	nav.WriteString("</nav>")
+
+	return "<div class=\"container py-4\">" + nav.String() +
+		"<h1 class=\"mb-4\">" + text(title) + "</h1>" + strings.Join(body, "") + "</div>"
+}
+
+// status returns what to show instead of a query's data while it loads or
+// after it failed, or "" once the data is in
+func status(result gouix.QueryResult) string {
+	switch {
+	case result.Err != nil:
+		return alert(result.Err)
+	case result.Data == nil && result.Loading:
+		return "<p class=\"text-muted\">Loading…</p>"
+	}
+	return ""
+}
+
+// alert shows an error
+func alert(err error) string {
+	return "<p class=\"alert alert-danger\" role=\"alert\">" + text(err.Error()) + "</p>"
+}
+
+// notFound tells that a record does not exist
+func notFound(label string) string {
+	return "<p class=\"text-muted\">There is no such " + text(label) + ".</p>"
+}
+
+// object returns query data holding a record, or nil
+func object(data interface{}) map[string]interface{} {
+	record, _ := data.(map[string]interface{})
+	return record
+}
+
+// objects returns query data holding a list of records
+func objects(data interface{}) []map[string]interface{} {
+	items, _ := data.([]interface{})
+	records := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		if record := object(item); record != nil {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// value returns a record's field for a form input, with lists and objects
+// as JSON text, or fallback if the record has none
+func value(record map[string]interface{}, field string, fallback interface{}) interface{} {
+	v := record[field]
+	switch v.(type) {
+	case nil:
+		return fallback
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return v
+}
+
+// text escapes a value for HTML, showing lists and objects as JSON
+func text(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return html.EscapeString(v)
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		return html.EscapeString(string(data))
+	}
+	return html.EscapeString(fmt.Sprint(v))
+}
+
+// href returns the path of a record's page below a list route
+func href(route string, key interface{}, suffix string) string {
+	return route + "/" + url.PathEscape(fmt.Sprint(key)) + suffix
+}
+{{end}}
+
+{{define "pagesType"}}
+{{- $type := .}}
+package pages
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/davidjeba/goscript/pkg/gouix"
+)
+
+// {{.Name}}Routes returns the routes of the {{.Label}} pages
+func {{.Name}}Routes() []*gouix.Route {
+	return []*gouix.Route{
+		{Path: {{quote .Route}}, Component: func(match *gouix.RouteMatch) gouix.Component { return New{{.Name}}ListPage(match) }},
+		{Path: {{quote (print .Route "/new")}}, Component: func(match *gouix.RouteMatch) gouix.Component { return New{{.Name}}EditPage(match) }},
+		{Path: {{quote (print .Route "/:id")}}, Component: func(match *gouix.RouteMatch) gouix.Component { return New{{.Name}}DetailPage(match) }},
+		{Path: {{quote (print .Route "/:id/edit")}}, Component: func(match *gouix.RouteMatch) gouix.Component { return New{{.Name}}EditPage(match) }},
+	}
+}
+
+// {{.Name}}ListPage lists {{.PluralLabel}} in a table
+type {{.Name}}ListPage struct {
+	*gouix.BaseComponent
+	match *gouix.RouteMatch
+}
+
+// New{{.Name}}ListPage creates the page listing {{.PluralLabel}}
+func New{{.Name}}ListPage(match *gouix.RouteMatch) *{{.Name}}ListPage {
+	return &{{.Name}}ListPage{BaseComponent: gouix.NewBaseComponent({{quote (print .Slug "-list")}}, nil), match: match}
+}
+
+// Render implements the Component interface
+func (p *{{.Name}}ListPage) Render() string {
+	title := {{quote (title .PluralLabel)}}
+	header := "<div class=\"d-flex mb-4\">" +
+		gouix.Link({{quote (print .Route "/new")}}, gouix.Props{"class": "btn btn-primary"}, "New {{.Label}}") + "</div>"
+
+	result := gouix.UseQuery("query:list{{.Plural}}", map[string]interface{}{"limit": 100, "offset": 0})
+	if message := status(result); message != "" {
+		return layout(title, header, message)
+	}
+	{{.PluralVar}} := objects(result.Data)
+	if len({{.PluralVar}}) == 0 {
+		return layout(title, header, "<p class=\"text-muted\">No {{.PluralLabel}} yet.</p>")
+	}
+
+	var rows strings.Builder
+	for _, {{.Var}} := range {{.PluralVar}} {
+		rows.WriteString("<tr>")
+{{- range $i, $field := .Listed}}
+{{- if eq $i 0}}
+		rows.WriteString("<td>" + gouix.Link(href({{quote $type.Route}}, {{$type.Var}}[{{quote $field.Name}}], ""), nil, text({{$type.Var}}[{{quote $field.Name}}])) + "</td>")
+{{- else}}
+		rows.WriteString("<td>" + text({{$type.Var}}[{{quote $field.Name}}]) + "</td>")
+{{- end}}
+{{- end}}
+		rows.WriteString("</tr>")
+	}
+	return layout(title, header,
+		"<table class=\"table\"><thead><tr>
+{{- range .Listed}}<th>{{.Label}}</th>{{end -}}
+		</tr></thead><tbody>"+rows.String()+"</tbody></table>")
+}
+
+// {{.Name}}DetailPage shows a {{.Label}}, with links to edit and delete it
+type {{.Name}}DetailPage struct {
+	*gouix.BaseComponent
+	match  *gouix.RouteMatch
+	remove *gouix.Mutation
+	mutex  sync.Mutex
+}
+
+// New{{.Name}}DetailPage creates the page showing the {{.Label}} at /:id
+func New{{.Name}}DetailPage(match *gouix.RouteMatch) *{{.Name}}DetailPage {
+	page := &{{.Name}}DetailPage{BaseComponent: gouix.NewBaseComponent({{quote (print .Slug "-detail")}}, nil), match: match}
+	page.On("delete", func(gouix.Event) interface{} {
+		return page.delete()
+	})
+	return page
+}
+
+// Render implements the Component interface
+func (p *{{.Name}}DetailPage) Render() string {
+	remove := gouix.UseMutation("mutation:delete{{.Name}}")
+	p.mutex.Lock()
+	if p.remove == nil {
+		remove.Invalidates = []string{"query:list{{.Plural}}", "query:get{{.Name}}"}
+		p.remove = remove
+	}
+	p.mutex.Unlock()
+
+	id := p.match.Param("id")
+	title := {{quote (title .Label)}} + " " + id
+	result := gouix.UseQuery("query:get{{.Name}}", map[string]interface{}{ {{- quote .Key.Name}}: id})
+	if message := status(result); message != "" {
+		return layout(title, message)
+	}
+	{{.Var}} := object(result.Data)
+	if {{.Var}} == nil {
+		return layout(title, notFound({{quote .Label}}))
+	}
+
+	var fields strings.Builder
+{{- range .Fields}}
+	fields.WriteString("<dt>{{.Label}}</dt><dd>" + text({{$type.Var}}[{{quote .Name}}]) + "</dd>")
+{{- end}}
+
+	actions := "<div class=\"d-flex mb-4\">" +
+		gouix.Link(href({{quote .Route}}, id, "/edit"), gouix.Props{"class": "btn btn-primary"}, "Edit") +
+		"<button class=\"btn btn-danger\" data-gouix-on=\"click:delete\" data-gouix-target=\"{{.Slug}}-detail\">Delete</button></div>"
+	if err := remove.Err(); err != nil {
+		actions += alert(err)
+	}
+	return layout(title, actions, "<dl class=\"card\">"+fields.String()+"</dl>")
+}
+
+// delete deletes the {{.Label}} and goes back to the list
+func (p *{{.Name}}DetailPage) delete() error {
+	p.mutex.Lock()
+	remove := p.remove
+	p.mutex.Unlock()
+	if remove == nil {
+		return nil
+	}
+
+	if _, err := remove.Mutate(context.Background(), map[string]interface{}{ {{- quote .Key.Name}}: p.match.Param("id")}); err != nil {
+		return err
+	}
+	return p.match.Navigate({{quote .Route}})
+}
+
+// {{.Name}}EditPage creates a {{.Label}} at /new, or edits one at /:id/edit
+type {{.Name}}EditPage struct {
+	*gouix.BaseComponent
+	match *gouix.RouteMatch
+	form  *gouix.Form
+	mutex sync.Mutex
+}
+
+// New{{.Name}}EditPage creates the page creating or editing a {{.Label}}
+func New{{.Name}}EditPage(match *gouix.RouteMatch) *{{.Name}}EditPage {
+	return &{{.Name}}EditPage{BaseComponent: gouix.NewBaseComponent({{quote (print .Slug "-edit")}}, nil), match: match}
+}
+
+// FindComponent implements gouix.ComponentFinder, so the form gets its
+// browser events
+func (p *{{.Name}}EditPage) FindComponent(id gouix.ComponentID) gouix.Component {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.form != nil && p.form.GetID() == id {
+		return p.form
+	}
+	return nil
+}
+
+// Render implements the Component interface
+func (p *{{.Name}}EditPage) Render() string {
+	id := p.match.Param("id")
+	title := "New {{.Label}}"
+	operation := "mutation:create{{.Name}}"
+	var record map[string]interface{}
+	if id != "" {
+		title = "Edit {{.Label}} " + id
+		operation = "mutation:update{{.Name}}"
+		result := gouix.UseQuery("query:get{{.Name}}", map[string]interface{}{ {{- quote .Key.Name}}: id})
+		if message := status(result); message != "" {
+			return layout(title, message)
+		}
+		if record = object(result.Data); record == nil {
+			return layout(title, notFound({{quote .Label}}))
+		}
+	}
+	save := gouix.UseMutation(operation)
+
+	// The form is created once the values to edit are in
+	p.mutex.Lock()
+	if p.form == nil {
+		p.form = p.newForm(id, record, save)
+	}
+	form := p.form
+	p.mutex.Unlock()
+
+	return layout(title, "<div class=\"card\">"+form.Render()+"</div>")
+}
+
+// newForm creates the form saving a {{.Label}} with save, filled in with
+// the values of the record being edited, if any
+func (p *{{.Name}}EditPage) newForm(id string, record map[string]interface{}, save *gouix.Mutation) *gouix.Form {
+	var fields []*gouix.Field
+{{- if not .GenerateKey}}
+	if id == "" {
+		fields = append(fields, {{template "formField" .Key}})
+	}
+{{- end}}
+	fields = append(fields,
+{{- range .Others}}
+		{{template "formField" .}},
+{{- end}}
+	)
+
+	form := gouix.NewForm({{quote (print .Slug "-form")}}, fields...)
+	form.SubmitLabel = "Save"
+	save.Invalidates = []string{"query:list{{.Plural}}", "query:get{{.Name}}"}
+	form.SubmitTo(func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		if id != "" {
+			params[{{quote .Key.Name}}] = id
+		}
+		return save.Mutate(ctx, params)
+	})
+	form.OnSuccess(func(result interface{}) {
+		if saved := object(result); saved != nil {
+			p.match.Navigate(href({{quote .Route}}, saved[{{quote .Key.Name}}], ""))
+		}
+	})
+	return form
+}
+{{end}}
+
+{{define "formField" -}}
+&gouix.Field{Name: {{quote .Name}}, Label: {{quote .Label}}, Type: {{quote .Input}}
+{{- if .Options}}, Options: {{strings .Options}}{{end -}}
+, Initial: value(record, {{quote .Name}}, {{.Initial}})
+{{- if .Required}}, Validators: []gouix.Validator{gouix.Required("")}{{end -}}
+}
+{{- end}}
+`
+
+const scaffoldServerTemplate = `
+{{define "server"}}
+// Command server serves the API at /api and its pages at /, keeping the
+// pages live over a WebSocket. Set DATABASE_URL to the PostgreSQL database
+// to use; the tables are created or migrated on start. Set GOSCALE_MOCK=1 to
+// answer with fake data instead, without a database.
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/davidjeba/goscript/pkg/gocsx"
+	"github.com/davidjeba/goscript/pkg/goscale/api"
+	"github.com/davidjeba/goscript/pkg/goscript"
+	"github.com/davidjeba/goscript/pkg/gouix"
+
+	"{{.Module}}/pages"
+	"{{.Module}}/resolvers"
+	"{{.Module}}/schema"
+)
+
+// dbSchema is the database schema holding the tables
+const dbSchema = {{quote .DBSchema}}
+
+func main() {
+	config := api.DefaultConfig()
+	if url := os.Getenv("DATABASE_URL"); url != "" {
+		config.DBConnectionString = url
+	}
+	goscaleAPI := api.NewGoScaleAPI(config)
+
+	// Serve the schema's types from their tables, creating any missing
+	apiSchema, err := schema.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	mock := os.Getenv("GOSCALE_MOCK") != ""
+	if mock {
+		apiSchema.Mock(1)
+		log.Print("Answering with fake data")
+	} else {
+		resolvers.Register(apiSchema, goscaleAPI.GetDB(), dbSchema)
+	}
+	if err := goscaleAPI.ApplySchema(apiSchema); err != nil {
+		log.Fatal(err)
+	}
+	if !mock {
+		plan, err := goscaleAPI.MigrateSchema(context.Background(), apiSchema, dbSchema)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !plan.Empty() {
+			log.Printf("Migrated the database:\n%s", plan)
+		}
+	}
+
+	// Mount the pages on a live hub; they query the API in process
+	queries := gouix.NewQueryClient(gouix.NewLocalAPIClient(goscaleAPI))
+	router := pages.NewRouter("pages")
+	hub := gouix.NewLiveHub()
+	root := hub.Mount("app", gouix.NewQueryProvider("queries", queries, router))
+
+	layout := goscript.NewLayout()
+	layout.Styles(gocsx.New())
+	layout.Head.BodyEnd("gouix", hub.ScriptTag("/_gouix/live"))
+
+	routes := goscript.NewRouter()
+	routes.Use(goscript.Recoverer(nil), goscript.RequestID())
+	routes.Mount("/api", goscaleAPI)
+	routes.Mount("/api/export", goscaleAPI.ExportHandler())
+	routes.GET("/_gouix/live", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		hub.ServeHTTP(w, r)
+	})
+
+	// Render the page for any other path, answering 404 where there is none
+	page := layout.Handler(func(r *http.Request, params map[string]string) (*goscript.Page, error) {
+		err := router.Navigate(r.URL.RequestURI())
+		if err != nil && !errors.Is(err, gouix.ErrRouteNotFound) {
+			return nil, err
+		}
+		page := goscript.NewPage("App", goscript.RenderFunc(root.RenderHydratable))
+		if err != nil {
+			page.Status = http.StatusNotFound
+		}
+		return page, nil
+	})
+	routes.GET("/", page)
+	routes.GET("/*path", page)
+
+	addr := ":8080"
+	if port := os.Getenv("PORT"); port != "" {
+		addr = ":" + port
+	}
+	app := goscript.NewApp(
+		goscript.CloserService("api", goscaleAPI),
+		goscript.NewService("live", nil, func(context.Context) error {
+			hub.Close()
+			return nil
+		}),
+		goscript.NewServer(addr, routes).Service(),
+	)
+	if err := app.RunUntilSignal(); err != nil {
+		log.Fatal(err)
+	}
+}
+{{end}}
+`
@@ -0,0 +1,135 @@
+package gopm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const scaffoldSchema = `
+"A person who writes posts"
+type User @table(name: "users") {
+  id: ID!
+  name: String!
+  role: Role!
+  tags: [String!]
+}
+
+enum Role { ADMIN READER }
+
+type BlogPost {
+  id: ID!
+  title: String!
+  author: User
+  rating: Float
+}
+
+type Note {
+  text: String
+}
+
+type Query {
+  me: User
+}
+`
+
+func TestParseAPIInitArgs(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/shop\n\ngo 1.17\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := parse("api:init", "--from-schema", "schema.graphql", "--out", filepath.Join(dir, "api"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := apiScaffoldOptions(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Module != "example.com/shop/api" || opts.DBSchema != "public" {
+		t.Errorf("expected the module from go.mod and the public schema, got %+v", opts)
+	}
+
+	c, _ = parse("api:init", "--from-schema", "schema.graphql", "--out", filepath.Join(dir, "api"), "--module", "example.com/other/")
+	if opts, err := apiScaffoldOptions(c); err != nil || opts.Module != "example.com/other" {
+		t.Errorf("expected the module flag to win, got %q, %v", opts.Module, err)
+	}
+}
+
+func TestWriteAPIScaffold(t *testing.T) {
+	dir := t.TempDir()
+	schemaPath := filepath.Join(dir, "schema.graphql")
+	if err := os.WriteFile(schemaPath, []byte(scaffoldSchema), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := APIScaffoldOptions{SchemaPath: schemaPath, OutDir: filepath.Join(dir, "app"), Module: "example.com/app", DBSchema: "public"}
+
+	written, skipped, err := writeAPIScaffold(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(skipped, []string{"Note"}) {
+		t.Errorf("expected Note to be skipped for lacking a key, got %v", skipped)
+	}
+	if len(written) != 12 {
+		t.Errorf("expected 12 files, got %v", written)
+	}
+
+	read := func(parts ...string) string {
+		data, err := os.ReadFile(filepath.Join(append([]string{opts.OutDir}, parts...)...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	for file, expected := range map[string][]string{
+		"resolvers/user.go":   {`Name:        "users"`, `{Field: "role", Name: "role", Type: "String!"}`},
+		"client/user.go":      {"Tags []string `json:\"tags\"`", "func (c *Client) GetUser(ctx context.Context, id string) (*User, error)", "// A person who writes posts"},
+		"client/blog_post.go": {"Rating *float64 `json:\"rating\"`", "func (c *Client) ListBlogPosts("},
+		"pages/user.go":       {`Type: "select", Options: []string{"ADMIN", "READER"}, Initial: value(record, "role", "ADMIN")`, `"/users/:id/edit"`},
+		"pages/blog_post.go":  {`gouix.NewForm("blog-post-form", fields...)`, `"query:listBlogPosts"`},
+		"cmd/server/main.go":  {`"example.com/app/resolvers"`, `const dbSchema = "public"`},
+	} {
+		contents := strings.Join(strings.Fields(read(filepath.FromSlash(file))), " ")
+		for _, want := range expected {
+			if !strings.Contains(contents, strings.Join(strings.Fields(want), " ")) {
+				t.Errorf("expected %s to contain %s", file, want)
+			}
+		}
+	}
+	if read("schema", "schema.graphql") != scaffoldSchema {
+		t.Errorf("expected the schema to be copied")
+	}
+	if strings.Contains(read("client", "blog_post.go"), "Author") {
+		t.Errorf("expected object fields to be left out of the client")
+	}
+
+	if _, _, err := writeAPIScaffold(opts); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("expected existing files to be kept, got %v", err)
+	}
+	opts.Force = true
+	if _, _, err := writeAPIScaffold(opts); err != nil {
+		t.Errorf("expected --force to overwrite, got %v", err)
+	}
+}
+
+func TestScaffoldNames(t *testing.T) {
+	for _, test := range []struct{ name, plural, goName, label string }{
+		{"BlogPost", "BlogPosts", "BlogPost", "Blog post"},
+		{"Category", "Categories", "Category", "Category"},
+		{"avatarURL", "avatarURLs", "AvatarURL", "Avatar URL"},
+		{"user_id", "user_ids", "UserID", "User ID"},
+		{"Box", "Boxes", "Box", "Box"},
+	} {
+		words := splitWords(test.name)
+		if plural, goName, label := pluralize(test.name), goName(words), label(words); plural != test.plural || goName != test.goName || label != test.label {
+			t.Errorf("%s: expected %s, %s, %s, got %s, %s, %s", test.name, test.plural, test.goName, test.label, plural, goName, label)
+		}
+	}
+	if name := goVar(splitWords("Type")); name != "typeValue" {
+		t.Errorf("expected keywords to be avoided, got %s", name)
+	}
+}
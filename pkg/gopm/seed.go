@@ -0,0 +1,192 @@
+package gopm
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/davidjeba/goscript/pkg/goscale/fake"
+	"github.com/davidjeba/goscript/pkg/goscale/sdl"
+	"github.com/davidjeba/goscript/pkg/goscript/cli"
+	"github.com/davidjeba/goscript/pkg/goscript/naming"
+)
+
+// seedBatch is the number of rows in each INSERT statement gopm db:seed
+// writes.
+const seedBatch = 500
+
+// FakeSeedOptions captures the arguments for gopm db:seed --fake.
+type FakeSeedOptions struct {
+	// Schema is the GraphQL schema whose tables are seeded.
+	Schema string
+
+	// DBSchema is the database schema of the tables.
+	DBSchema string
+
+	// Rows is the number of rows generated for each table.
+	Rows int
+
+	// Seed seeds the generator; the same seed generates the same rows.
+	Seed int64
+
+	// Format is "sql" for INSERT statements in a transaction, or "ndjson"
+	// for a JSON object per row.
+	Format string
+
+	// Out is the file the rows are written to, or "" for stdout.
+	Out string
+}
+
+// fakeSeedOptions reads the options of gopm db:seed --fake from its flags.
+func fakeSeedOptions(c *cli.Context) (FakeSeedOptions, error) {
+	opts := FakeSeedOptions{
+		Schema:   strings.TrimSpace(c.String("schema")),
+		DBSchema: strings.TrimSpace(c.String("db-schema")),
+		Seed:     int64(c.Int("seed")),
+		Format:   strings.ToLower(strings.TrimSpace(c.String("format"))),
+		Out:      strings.TrimSpace(c.String("out")),
+	}
+	if opts.Schema == "" {
+		opts.Schema = DefaultAPISchema
+	}
+	if opts.Format != "sql" && opts.Format != "ndjson" {
+		return opts, fmt.Errorf("unknown format %q; use sql or ndjson", opts.Format)
+	}
+	rows, err := fake.ParseCount(c.String("fake"))
+	opts.Rows = rows
+	return opts, err
+}
+
+// FakeSeed writes fake rows for the tables of a schema to w, referenced
+// tables first, so that foreign keys hold as the rows are inserted.
+func FakeSeed(w io.Writer, opts FakeSeedOptions) error {
+	source, err := ioutil.ReadFile(opts.Schema)
+	if err != nil {
+		return err
+	}
+	doc, err := sdl.Parse(string(source))
+	if err != nil {
+		return fmt.Errorf("%s:%v", opts.Schema, err)
+	}
+	tables := fake.Tables(doc)
+	if len(tables) == 0 {
+		return fmt.Errorf("%s: no tables to seed", opts.Schema)
+	}
+
+	out := bufio.NewWriter(w)
+	generator := fake.New(opts.Seed)
+	if opts.Format == "ndjson" {
+		encoder := json.NewEncoder(out)
+		err = generator.Generate(tables, opts.Rows, func(table *fake.Table, row map[string]interface{}) error {
+			return encoder.Encode(map[string]interface{}{"table": table.Name, "row": row})
+		})
+	} else {
+		err = writeSeedSQL(out, generator, tables, opts)
+	}
+	if err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// writeSeedSQL writes the rows as INSERT statements of up to seedBatch rows,
+// in one transaction.
+func writeSeedSQL(w *bufio.Writer, generator *fake.Generator, tables []*fake.Table, opts FakeSeedOptions) error {
+	fmt.Fprintf(w, "-- %d fake rows per table from %s, seed %d\nBEGIN;\n", opts.Rows, opts.Schema, opts.Seed)
+
+	var current *fake.Table
+	batched := 0
+	end := func() {
+		if batched > 0 {
+			w.WriteString(";\n")
+		}
+		batched = 0
+	}
+	err := generator.Generate(tables, opts.Rows, func(table *fake.Table, row map[string]interface{}) error {
+		if table != current || batched == seedBatch {
+			end()
+			current = table
+		}
+		if batched == 0 {
+			columns := make([]string, len(table.Fields))
+			for i, field := range table.Fields {
+				columns[i] = strconv.Quote(naming.SnakeCase(field.Name))
+			}
+			fmt.Fprintf(w, "INSERT INTO %q.%q (%s) VALUES\n", opts.DBSchema, table.Name, strings.Join(columns, ", "))
+		} else {
+			w.WriteString(",\n")
+		}
+
+		values := make([]string, len(table.Fields))
+		for i, field := range table.Fields {
+			value, err := sqlLiteral(row[field.Name])
+			if err != nil {
+				return fmt.Errorf("%s.%s: %v", table.Name, field.Name, err)
+			}
+			values[i] = value
+		}
+		w.WriteString("  (" + strings.Join(values, ", ") + ")")
+		batched++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	end()
+	_, err = w.WriteString("COMMIT;\n")
+	return err
+}
+
+// sqlLiteral returns a generated value as a SQL literal, encoding lists and
+// objects as JSON.
+func sqlLiteral(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "NULL", nil
+	case bool:
+		return strings.ToUpper(strconv.FormatBool(value)), nil
+	case int:
+		return strconv.Itoa(value), nil
+	case int64:
+		return strconv.FormatInt(value, 10), nil
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), nil
+	case string:
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'", nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return "'" + strings.ReplaceAll(string(data), "'", "''") + "'", nil
+}
+
+// dbSeedFake runs gopm db:seed --fake.
+func dbSeedFake(c *cli.Context) error {
+	opts, err := fakeSeedOptions(c)
+	if err != nil {
+		return err
+	}
+	if opts.Out == "" {
+		return FakeSeed(os.Stdout, opts)
+	}
+
+	file, err := os.Create(opts.Out)
+	if err != nil {
+		return err
+	}
+	if err := FakeSeed(file, opts); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d fake rows per table to %s\n", opts.Rows, opts.Out)
+	return nil
+}
@@ -0,0 +1,70 @@
+package gopm
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFakeSeedOptions(t *testing.T) {
+	c, err := parse("db:seed", "--fake", "10k", "--seed", "7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := fakeSeedOptions(c)
+	if err != nil || opts.Rows != 10000 || opts.Seed != 7 || opts.Schema != DefaultAPISchema || opts.DBSchema != "public" || opts.Format != "sql" {
+		t.Fatalf("unexpected options %+v (%v)", opts, err)
+	}
+
+	c, _ = parse("db:seed", "--fake", "100", "--format", "xml")
+	if _, err := fakeSeedOptions(c); err == nil {
+		t.Fatalf("expected an unknown format to be refused")
+	}
+}
+
+func TestFakeSeed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.graphql")
+	ioutil.WriteFile(path, []byte(`
+type Post { id: ID!, title: String!, authorId: ID!, author: User, metadata: JSON }
+type User { id: ID!, name: String!, email: String! }
+`), 0644)
+
+	var sql bytes.Buffer
+	opts := FakeSeedOptions{Schema: path, DBSchema: "app", Rows: 501, Seed: 1, Format: "sql"}
+	if err := FakeSeed(&sql, opts); err != nil {
+		t.Fatal(err)
+	}
+	out := sql.String()
+	users := strings.Index(out, `INSERT INTO "app"."user" ("id", "name", "email") VALUES`)
+	posts := strings.Index(out, `INSERT INTO "app"."post" ("id", "title", "author_id", "metadata") VALUES`)
+	if users < 0 || posts < users || !strings.HasSuffix(out, "COMMIT;\n") {
+		t.Fatalf("expected users inserted before posts in a transaction, got:\n%.600s", out)
+	}
+	if got := strings.Count(out, "INSERT INTO"); got != 4 {
+		t.Fatalf("expected two batches per table, got %d", got)
+	}
+
+	var ndjson bytes.Buffer
+	opts.Format, opts.Rows = "ndjson", 3
+	if err := FakeSeed(&ndjson, opts); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(ndjson.String()), "\n")
+	var line struct {
+		Table string
+		Row   map[string]interface{}
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &line); err != nil || len(lines) != 6 || line.Table != "user" || line.Row["email"] == nil {
+		t.Fatalf("unexpected NDJSON %v (%v)", lines, err)
+	}
+
+	if got, _ := sqlLiteral("O'Brien"); got != "'O''Brien'" {
+		t.Fatalf("expected quotes escaped, got %s", got)
+	}
+	if got, _ := sqlLiteral(map[string]interface{}{"a": 1}); got != `'{"a":1}'` {
+		t.Fatalf("expected JSON, got %s", got)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"strings"
 
 	"github.com/davidjeba/goscript/pkg/buildout"
+	"github.com/davidjeba/goscript/pkg/goscript/cli"
 )
 
 // SetupOptions captures the topology and shape requested for gopm setup.
@@ -21,68 +22,31 @@ type SetupOptions struct {
 	Force        bool
 }
 
-func parseSetupArgs(args []string) (SetupOptions, error) {
+// setupOptions reads the options of gopm setup from its flags and project
+// argument.
+func setupOptions(c *cli.Context) (SetupOptions, error) {
 	opts := SetupOptions{
-		Mode: "cs",
-		Type: "app",
+		ProjectDir:   strings.TrimSpace(c.Arg(0)),
+		Mode:         c.String("mode"),
+		Entrypoint:   strings.TrimSpace(c.String("entrypoint")),
+		ManifestName: strings.TrimSpace(c.String("manifest")),
+		Force:        c.Bool("force"),
 	}
 
-	for i := 0; i < len(args); i++ {
-		arg := strings.TrimSpace(args[i])
-		if arg == "" {
-			continue
-		}
-
-		switch arg {
-		case "--cs":
-			opts.Mode = "cs"
-		case "--sw":
-			opts.Mode = "sw"
-		case "--force":
-			opts.Force = true
-		case "--mode":
-			i++
-			if i >= len(args) {
-				return SetupOptions{}, fmt.Errorf("missing value for --mode")
-			}
-			opts.Mode = strings.ToLower(strings.TrimSpace(args[i]))
-		case "--type", "--template":
-			i++
-			if i >= len(args) {
-				return SetupOptions{}, fmt.Errorf("missing value for %s", arg)
-			}
-
-			projectType, err := normalizeProjectType(args[i])
-			if err != nil {
-				return SetupOptions{}, err
-			}
-			opts.Type = projectType
-		case "--entrypoint":
-			i++
-			if i >= len(args) {
-				return SetupOptions{}, fmt.Errorf("missing value for --entrypoint")
-			}
-			opts.Entrypoint = strings.TrimSpace(args[i])
-		case "--manifest":
-			i++
-			if i >= len(args) {
-				return SetupOptions{}, fmt.Errorf("missing value for --manifest")
-			}
-			opts.ManifestName = strings.TrimSpace(args[i])
-		default:
-			if strings.HasPrefix(arg, "--") {
-				return SetupOptions{}, fmt.Errorf("unknown setup flag %q", arg)
-			}
-			if opts.ProjectDir != "" {
-				return SetupOptions{}, fmt.Errorf("unexpected extra argument %q", arg)
-			}
-			opts.ProjectDir = arg
-		}
+	switch {
+	case c.Bool("cs") && c.Bool("sw"):
+		return SetupOptions{}, cli.Usagef("use either --cs or --sw")
+	case c.Bool("cs"):
+		opts.Mode = "cs"
+	case c.Bool("sw"):
+		opts.Mode = "sw"
 	}
 
-	if opts.Mode != "cs" && opts.Mode != "sw" {
-		return SetupOptions{}, fmt.Errorf("mode must be either cs or sw")
+	projectType, err := normalizeProjectType(c.String("type"))
+	if err != nil {
+		return SetupOptions{}, cli.Usagef("%v", err)
 	}
+	opts.Type = projectType
 
 	if opts.ProjectDir == "" {
 		opts.ProjectDir = "."
@@ -9,8 +9,16 @@ import (
 	"github.com/davidjeba/goscript/pkg/buildout"
 )
 
+func parseSetupArgs(args ...string) (SetupOptions, error) {
+	c, err := parse(append([]string{"setup"}, args...)...)
+	if err != nil {
+		return SetupOptions{}, err
+	}
+	return setupOptions(c)
+}
+
 func TestParseSetupArgsDefaults(t *testing.T) {
-	opts, err := parseSetupArgs([]string{"demo-app"})
+	opts, err := parseSetupArgs("demo-app")
 	if err != nil {
 		t.Fatalf("parseSetupArgs returned error: %v", err)
 	}
@@ -29,7 +37,7 @@ func TestParseSetupArgsDefaults(t *testing.T) {
 }
 
 func TestParseSetupArgsSwarmERP(t *testing.T) {
-	opts, err := parseSetupArgs([]string{"--sw", "--type", "erp", "mesh-suite"})
+	opts, err := parseSetupArgs("--sw", "--type", "erp", "mesh-suite")
 	if err != nil {
 		t.Fatalf("parseSetupArgs returned error: %v", err)
 	}
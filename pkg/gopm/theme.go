@@ -0,0 +1,109 @@
+package gopm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/gocsx/components"
+	"github.com/davidjeba/goscript/pkg/gocsx/core"
+	"github.com/davidjeba/goscript/pkg/goscript/cli"
+)
+
+// DefaultThemeFile is the theme gopm css:theme preview documents when it
+// exists, relative to the project.
+const DefaultThemeFile = "theme.json"
+
+// DefaultPreviewAddr is where gopm css:theme preview serves the docs site.
+const DefaultPreviewAddr = "localhost:3100"
+
+// ThemePreviewOptions captures the arguments for gopm css:theme preview.
+type ThemePreviewOptions struct {
+	// Theme is a JSON file of a core.ThemeConfig, whose values override the
+	// default theme's; empty uses DefaultThemeFile if it exists.
+	Theme string
+
+	// Out, when set, is the directory the static site is written to,
+	// instead of serving it.
+	Out string
+
+	Addr string
+}
+
+// themePreviewOptions reads the options of gopm css:theme preview from its
+// flags.
+func themePreviewOptions(c *cli.Context) ThemePreviewOptions {
+	opts := ThemePreviewOptions{Theme: c.String("theme"), Out: c.String("out"), Addr: c.String("addr")}
+	if opts.Addr == "" {
+		opts.Addr = DefaultPreviewAddr
+	}
+	return opts
+}
+
+// LoadTheme returns a Gocsx with the theme of a JSON file over the default
+// one and the built-in components registered. An empty path uses
+// DefaultThemeFile when it exists and the default theme otherwise.
+func LoadTheme(path string) (*core.Gocsx, error) {
+	config := core.DefaultConfig()
+	file := path
+	if file == "" {
+		file = DefaultThemeFile
+	}
+
+	data, err := ioutil.ReadFile(file)
+	switch {
+	case err == nil:
+		// Scales in the file are merged into the default ones
+		if err := json.Unmarshal(data, config.Theme); err != nil {
+			return nil, fmt.Errorf("%s: %v", file, err)
+		}
+	case path == "" && os.IsNotExist(err):
+	default:
+		return nil, err
+	}
+
+	g := core.New(core.WithTheme(config.Theme))
+	components.RegisterButtonComponent(g)
+	components.RegisterCardComponent(g)
+	return g, nil
+}
+
+// RunThemePreview writes the theme's docs site to Out, or serves it at Addr
+// until ctx is done, reading the theme again for each page so edits show on
+// reload.
+func RunThemePreview(ctx context.Context, opts ThemePreviewOptions) error {
+	if opts.Out != "" {
+		g, err := LoadTheme(opts.Theme)
+		if err != nil {
+			return err
+		}
+		if err := g.GenerateDocs(opts.Out); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote the theme's docs site to %s\n", opts.Out)
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", opts.Addr)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: core.DocsHandler(func() (*core.Gocsx, error) { return LoadTheme(opts.Theme) })}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdown)
+	}()
+
+	fmt.Printf("Previewing the theme at http://%s\n", listener.Addr())
+	if err := server.Serve(listener); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package gopm
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/davidjeba/goscript/pkg/gocsx/core"
+)
+
+func TestThemePreview(t *testing.T) {
+	dir := t.TempDir()
+	theme := filepath.Join(dir, "brand.json")
+	if err := ioutil.WriteFile(theme, []byte(`{"Colors": {"brand": {"500": "#ff3366", "100": "#ffe4ea"}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "site")
+	if err := RunThemePreview(context.Background(), ThemePreviewOptions{Theme: theme, Out: out}); err != nil {
+		t.Fatal(err)
+	}
+	index, err := ioutil.ReadFile(filepath.Join(out, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := string(index)
+	for _, want := range []string{
+		"<h3>brand</h3>", "background: #ff3366", // the file's palette
+		"<h3>primary</h3>",                    // merged with the default theme
+		`<span class="label">4 · 1rem</span>`, // the spacing scale
+		`font-size: 1.125rem`,                 // the typography
+		`<h3 id="component-button">button</h3>`,
+		`<button type="button" class="btn`, // the variants, as they render
+	} {
+		if !strings.Contains(page, want) {
+			t.Fatalf("expected the docs site to contain %q", want)
+		}
+	}
+	if strings.Index(page, "#ffe4ea") > strings.Index(page, "#ff3366") {
+		t.Fatalf("expected the shades in increasing order")
+	}
+	if _, err := ioutil.ReadFile(filepath.Join(out, core.DocsStylesheet)); err != nil {
+		t.Fatalf("expected the stylesheet to be written: %v", err)
+	}
+
+	if _, err := LoadTheme(filepath.Join(dir, "missing.json")); err == nil {
+		t.Fatalf("expected a missing theme file to be refused")
+	}
+}
+
+func TestThemePreviewHandler(t *testing.T) {
+	dir := t.TempDir()
+	theme := filepath.Join(dir, "theme.json")
+	ioutil.WriteFile(theme, []byte(`{"Spacing": {"huge": "40rem"}}`), 0644)
+	handler := core.DocsHandler(func() (*core.Gocsx, error) { return LoadTheme(theme) })
+
+	get := func(path string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+		return recorder
+	}
+	if body := get("/").Body.String(); !strings.Contains(body, "huge · 40rem") {
+		t.Fatalf("expected the theme's spacing, got:\n%s", body)
+	}
+
+	// Edits show on reload
+	ioutil.WriteFile(theme, []byte(`{"Spacing": {"vast": "50rem"}}`), 0644)
+	if body := get("/index.html").Body.String(); !strings.Contains(body, "vast · 50rem") {
+		t.Fatalf("expected the edited theme")
+	}
+	if recorder := get("/" + core.DocsStylesheet); !strings.HasPrefix(recorder.Header().Get("Content-Type"), "text/css") {
+		t.Fatalf("expected the stylesheet, got %q", recorder.Header().Get("Content-Type"))
+	}
+	if recorder := get("/../secret"); recorder.Code != 404 {
+		t.Fatalf("expected 404 outside the site, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,143 @@
+package gopm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/davidjeba/goscript/pkg/goscript/cli"
+	uixtesting "github.com/davidjeba/goscript/pkg/gouix/testing"
+)
+
+// UIXTestOptions captures the arguments for gopm uix:test.
+type UIXTestOptions struct {
+	Packages []string
+	Run      string
+	Update   bool
+	Verbose  bool
+	Race     bool
+	A11y     bool
+}
+
+// uixTestOptions reads the options of gopm uix:test from its flags and
+// package arguments.
+func uixTestOptions(c *cli.Context) UIXTestOptions {
+	opts := UIXTestOptions{
+		Packages: c.Args,
+		Run:      strings.TrimSpace(c.String("run")),
+		Update:   c.Bool("update"),
+		Verbose:  c.Bool("verbose"),
+		Race:     c.Bool("race"),
+		A11y:     c.Bool("a11y"),
+	}
+
+	if len(opts.Packages) == 0 {
+		opts.Packages = []string{"./..."}
+	}
+
+	return opts
+}
+
+// goTestArgs returns the go test arguments for the options
+func (opts UIXTestOptions) goTestArgs() []string {
+	args := []string{"test"}
+	if opts.Verbose {
+		args = append(args, "-v")
+	}
+	if opts.Race {
+		args = append(args, "-race")
+	}
+	if opts.Run != "" {
+		args = append(args, "-run", opts.Run)
+	}
+	if opts.Update || opts.A11y {
+		// Cached results would skip writing the snapshots or the audit
+		args = append(args, "-count=1")
+	}
+	return append(args, opts.Packages...)
+}
+
+// runUIXTests runs the component tests with go test and returns its exit code
+func runUIXTests(opts UIXTestOptions) (int, error) {
+	cmd := exec.Command("go", opts.goTestArgs()...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	if opts.Update {
+		cmd.Env = append(cmd.Env, uixtesting.UpdateSnapshotsEnv+"=1")
+	}
+	if opts.A11y {
+		cmd.Env = append(cmd.Env, uixtesting.A11yEnv+"=1")
+	}
+
+	err := cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 1, err
+	}
+	return 0, nil
+}
+
+// UIXBuildOptions captures the arguments for gopm uix:build.
+type UIXBuildOptions struct {
+	// Wasm is the main package of a client to build for WebAssembly
+	Wasm string
+	Out  string
+}
+
+// uixBuildOptions reads the options of gopm uix:build from its flags
+func uixBuildOptions(c *cli.Context) UIXBuildOptions {
+	opts := UIXBuildOptions{
+		Wasm: strings.TrimSpace(c.String("wasm")),
+		Out:  strings.TrimSpace(c.String("out")),
+	}
+	if opts.Out == "" {
+		opts.Out = filepath.Join("static", "app.wasm")
+	}
+	return opts
+}
+
+// goBuildArgs returns the go build arguments of the client
+func (opts UIXBuildOptions) goBuildArgs() []string {
+	return []string{"build", "-o", opts.Out, opts.Wasm}
+}
+
+// buildUIXWasm builds the client for WebAssembly and copies the wasm_exec.js
+// of the same Go release next to it, which gouix.WasmScriptTag loads it with.
+// It returns the path of the copy.
+func buildUIXWasm(opts UIXBuildOptions) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(opts.Out), 0755); err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command("go", opts.goBuildArgs()...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "GOOS=js", "GOARCH=wasm")
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("building %s for WebAssembly: %w", opts.Wasm, err)
+	}
+
+	goroot, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		return "", err
+	}
+	// Go 1.24 moved it from misc/wasm
+	var source []byte
+	for _, dir := range []string{"lib", "misc"} {
+		source, err = os.ReadFile(filepath.Join(strings.TrimSpace(string(goroot)), dir, "wasm", "wasm_exec.js"))
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return "", fmt.Errorf("finding wasm_exec.js: %w", err)
+	}
+
+	path := filepath.Join(filepath.Dir(opts.Out), "wasm_exec.js")
+	return path, os.WriteFile(path, source, 0644)
+}
@@ -0,0 +1,277 @@
+package gopm
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/davidjeba/goscript/pkg/goscript/cli"
+	"github.com/davidjeba/goscript/pkg/gouix"
+)
+
+// UIXPropsFile is the file gopm uix:props writes in each package
+const UIXPropsFile = "gouix_props.go"
+
+// uixPropsHeader marks the files gopm uix:props generates
+const uixPropsHeader = "// Code generated by gopm uix:props. DO NOT EDIT."
+
+// uixPropsDirective marks a props struct and names its component:
+//
+//	//gouix:component Counter
+//	type CounterProps struct { ... }
+const uixPropsDirective = "//gouix:component"
+
+// propsStruct is an annotated props struct found in a package
+type propsStruct struct {
+	Name      string
+	Component string
+	Fields    []propsField
+
+	// Constructor is true when the package has a New<Component>(id, props)
+	// returning *<Component>
+	Constructor bool
+}
+
+// propsField is a bound field of a props struct
+type propsField struct {
+	Name string
+	Prop string
+
+	// Set is the condition, with %s for the field, that holds when the
+	// field is not zero, such as `%s != ""`; it is empty when the zero value
+	// cannot be told from the source
+	Set string
+}
+
+// generateUIXProps writes the typed props code for the annotated structs in
+// dir. It returns the path written, or "" if the package has none, in which
+// case a file generated before is removed.
+func generateUIXProps(dir string) (string, error) {
+	fset := token.NewFileSet()
+	packages, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		name := info.Name()
+		return !strings.HasSuffix(name, "_test.go") && name != UIXPropsFile
+	}, parser.ParseComments)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, UIXPropsFile)
+	var names []string
+	for name := range packages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		structs, err := findPropsStructs(packages[name])
+		if err != nil {
+			return "", err
+		}
+		if len(structs) == 0 {
+			continue
+		}
+
+		source, err := renderUIXProps(name, structs)
+		if err != nil {
+			return "", err
+		}
+		return path, ioutil.WriteFile(path, source, 0644)
+	}
+
+	// Remove a generated file whose structs are gone, but never a file
+	// written by hand
+	if existing, err := ioutil.ReadFile(path); err == nil && bytes.HasPrefix(existing, []byte(uixPropsHeader)) {
+		return "", os.Remove(path)
+	}
+	return "", nil
+}
+
+// findPropsStructs returns the annotated props structs of a package, sorted
+// by name
+func findPropsStructs(pkg *ast.Package) ([]propsStruct, error) {
+	var structs []propsStruct
+	constructors := make(map[string]string)
+
+	var files []string
+	for name := range pkg.Files {
+		files = append(files, name)
+	}
+	sort.Strings(files)
+
+	for _, filename := range files {
+		for _, decl := range pkg.Files[filename].Decls {
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				if decl.Recv == nil && decl.Type.Params.NumFields() == 2 && decl.Type.Results.NumFields() == 1 {
+					if result, ok := decl.Type.Results.List[0].Type.(*ast.StarExpr); ok {
+						if ident, ok := result.X.(*ast.Ident); ok {
+							constructors[decl.Name.Name] = ident.Name
+						}
+					}
+				}
+			case *ast.GenDecl:
+				if decl.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range decl.Specs {
+					typeSpec := spec.(*ast.TypeSpec)
+					doc := typeSpec.Doc
+					if doc == nil && len(decl.Specs) == 1 {
+						doc = decl.Doc
+					}
+					component := componentDirective(doc)
+					if component == "" {
+						continue
+					}
+
+					structType, ok := typeSpec.Type.(*ast.StructType)
+					if !ok {
+						return nil, fmt.Errorf("%s: %s is marked %s but is not a struct", filename, typeSpec.Name.Name, uixPropsDirective)
+					}
+					structs = append(structs, propsStruct{
+						Name:      typeSpec.Name.Name,
+						Component: component,
+						Fields:    structFields(structType),
+					})
+				}
+			}
+		}
+	}
+
+	for i := range structs {
+		structs[i].Constructor = constructors["New"+structs[i].Component] == structs[i].Component
+	}
+	sort.Slice(structs, func(i, j int) bool { return structs[i].Name < structs[j].Name })
+	return structs, nil
+}
+
+// componentDirective returns the component named by a //gouix:component line
+func componentDirective(doc *ast.CommentGroup) string {
+	if doc == nil {
+		return ""
+	}
+	for _, comment := range doc.List {
+		if strings.HasPrefix(comment.Text, uixPropsDirective+" ") {
+			return strings.TrimSpace(strings.TrimPrefix(comment.Text, uixPropsDirective))
+		}
+	}
+	return ""
+}
+
+// structFields lists the bound fields of a props struct, named as
+// gouix.BindProps names them
+func structFields(structType *ast.StructType) []propsField {
+	var fields []propsField
+	for _, field := range structType.Fields.List {
+		var tag reflect.StructTag
+		if field.Tag != nil {
+			if value, err := strconv.Unquote(field.Tag.Value); err == nil {
+				tag = reflect.StructTag(value)
+			}
+		}
+
+		for _, name := range field.Names {
+			if !name.IsExported() {
+				continue
+			}
+			prop, _, ok := gouix.PropName(name.Name, tag)
+			if !ok {
+				continue
+			}
+			fields = append(fields, propsField{Name: name.Name, Prop: prop, Set: setCondition(field.Type)})
+		}
+	}
+	return fields
+}
+
+// setCondition returns the condition telling a field of a type from its
+// zero value, or "" for types such as structs and named types, whose zero
+// value cannot be told from the source
+func setCondition(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "string":
+			return `%s != ""`
+		case "bool":
+			return "%s"
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64",
+			"uintptr", "float32", "float64", "byte", "rune":
+			return "%s != 0"
+		}
+	case *ast.SelectorExpr:
+		if pkg, ok := t.X.(*ast.Ident); ok && pkg.Name == "time" && t.Sel.Name == "Duration" {
+			return "%s != 0"
+		}
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return "%s != nil"
+		}
+	case *ast.MapType, *ast.StarExpr, *ast.FuncType, *ast.InterfaceType, *ast.ChanType:
+		return "%s != nil"
+	}
+	return ""
+}
+
+// renderUIXProps returns the formatted generated file for a package
+func renderUIXProps(pkg string, structs []propsStruct) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s\n\npackage %s\n\nimport \"github.com/davidjeba/goscript/pkg/gouix\"\n", uixPropsHeader, pkg)
+
+	for _, s := range structs {
+		fmt.Fprintf(&b, "\n// Props returns the props of a %s. Fields left at zero are left out, so\n// the component applies its defaults.\n", s.Component)
+		fmt.Fprintf(&b, "func (p %s) Props() gouix.Props {\n", s.Name)
+		if explicit := allSetConditions(s.Fields); explicit {
+			b.WriteString("\tprops := gouix.Props{}\n")
+			for _, field := range s.Fields {
+				condition := fmt.Sprintf(field.Set, "p."+field.Name)
+				fmt.Fprintf(&b, "\tif %s {\n\t\tprops[%q] = p.%s\n\t}\n", condition, field.Prop, field.Name)
+			}
+			b.WriteString("\treturn props\n}\n")
+		} else {
+			b.WriteString("\treturn gouix.PropsOf(p)\n}\n")
+		}
+
+		fmt.Fprintf(&b, "\n// Bind%s reads %s from props, applying the defaults\n", s.Name, s.Name)
+		fmt.Fprintf(&b, "func Bind%s(props gouix.Props) (%s, error) {\n\tvar p %s\n\terr := gouix.BindProps(props, &p)\n\treturn p, err\n}\n", s.Name, s.Name, s.Name)
+
+		if s.Constructor {
+			fmt.Fprintf(&b, "\n// New%sWithProps creates a %s from typed props\n", s.Component, s.Component)
+			fmt.Fprintf(&b, "func New%sWithProps(id gouix.ComponentID, props %s) *%s {\n\treturn New%s(id, props.Props())\n}\n",
+				s.Component, s.Name, s.Component, s.Component)
+		}
+	}
+
+	return format.Source(b.Bytes())
+}
+
+// allSetConditions reports whether every field's zero value can be told
+// from the source
+func allSetConditions(fields []propsField) bool {
+	for _, field := range fields {
+		if field.Set == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// uixPropsDirs returns the directories gopm uix:props generates props for,
+// the current one by default.
+func uixPropsDirs(c *cli.Context) []string {
+	if len(c.Args) == 0 {
+		return []string{"."}
+	}
+	return c.Args
+}
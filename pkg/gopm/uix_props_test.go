@@ -0,0 +1,100 @@
+package gopm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const cardSource = `package cards
+
+import (
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/gouix"
+)
+
+// CardProps are the props of a Card
+//gouix:component Card
+type CardProps struct {
+	Title   string ` + "`prop:\"heading,required\"`" + `
+	Width   int    ` + "`default:\"320\"`" + `
+	Pinned  bool
+	Timeout time.Duration
+	Skipped string ` + "`prop:\"-\"`" + `
+}
+
+//gouix:component Badge
+type BadgeProps struct {
+	Style gouix.Props
+	Size  Size
+}
+
+type Size int
+
+type Card struct{ gouix.BaseComponent }
+
+func NewCard(id gouix.ComponentID, props gouix.Props) *Card { return &Card{} }
+`
+
+func TestGenerateUIXProps(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "card.go"), []byte(cardSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := generateUIXProps(dir)
+	if err != nil {
+		t.Fatalf("generateUIXProps returned error: %v", err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read generated file: %v", err)
+	}
+	generated := string(data)
+
+	for _, expected := range []string{
+		"// Code generated by gopm uix:props. DO NOT EDIT.\n\npackage cards\n",
+		"func (p BadgeProps) Props() gouix.Props {\n\treturn gouix.PropsOf(p)\n}",
+		"func BindBadgeProps(props gouix.Props) (BadgeProps, error) {",
+		"\tif p.Title != \"\" {\n\t\tprops[\"heading\"] = p.Title\n\t}",
+		"\tif p.Pinned {\n\t\tprops[\"pinned\"] = p.Pinned\n\t}",
+		"\tif p.Timeout != 0 {\n\t\tprops[\"timeout\"] = p.Timeout\n\t}",
+		"func NewCardWithProps(id gouix.ComponentID, props CardProps) *Card {\n\treturn NewCard(id, props.Props())\n}",
+	} {
+		if !strings.Contains(generated, expected) {
+			t.Errorf("expected %q in\n%s", expected, generated)
+		}
+	}
+	if strings.Contains(generated, "Skipped") || strings.Contains(generated, "NewBadgeWithProps") {
+		t.Errorf("expected no skipped field and no constructor without New%s, got\n%s", "Badge", generated)
+	}
+
+	// Regenerating reads the sources, not the generated file
+	if _, err := generateUIXProps(dir); err != nil {
+		t.Fatalf("regenerating returned error: %v", err)
+	}
+
+	// Without annotated structs the generated file is removed
+	if err := ioutil.WriteFile(filepath.Join(dir, "card.go"), []byte("package cards\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := generateUIXProps(dir); err != nil || path != "" {
+		t.Fatalf("expected nothing to generate, got %q, %v", path, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, UIXPropsFile)); !os.IsNotExist(err) {
+		t.Errorf("expected the stale file to be removed, got %v", err)
+	}
+}
+
+func TestParseUIXPropsArgs(t *testing.T) {
+	c, err := parse("uix:props")
+	if dirs := uixPropsDirs(c); err != nil || len(dirs) != 1 || dirs[0] != "." {
+		t.Errorf("expected the current directory, got %v, %v", dirs, err)
+	}
+	if _, err := parse("uix:props", "--bogus"); err == nil {
+		t.Errorf("expected an error for an unknown flag")
+	}
+}
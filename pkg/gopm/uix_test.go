@@ -0,0 +1,70 @@
+package gopm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func parseUIXTestArgs(args ...string) (UIXTestOptions, error) {
+	c, err := parse(append([]string{"uix:test"}, args...)...)
+	if err != nil {
+		return UIXTestOptions{}, err
+	}
+	return uixTestOptions(c), nil
+}
+
+func TestParseUIXTestArgs(t *testing.T) {
+	opts, err := parseUIXTestArgs()
+	if err != nil {
+		t.Fatalf("parseUIXTestArgs returned error: %v", err)
+	}
+	if expected := []string{"test", "./..."}; !reflect.DeepEqual(opts.goTestArgs(), expected) {
+		t.Fatalf("expected %v, got %v", expected, opts.goTestArgs())
+	}
+
+	opts, err = parseUIXTestArgs("--update", "--run", "TestCounter", "-v", "./components")
+	if err != nil {
+		t.Fatalf("parseUIXTestArgs returned error: %v", err)
+	}
+	if !opts.Update {
+		t.Fatalf("expected snapshots to be updated")
+	}
+	expected := []string{"test", "-v", "-run", "TestCounter", "-count=1", "./components"}
+	if !reflect.DeepEqual(opts.goTestArgs(), expected) {
+		t.Fatalf("expected %v, got %v", expected, opts.goTestArgs())
+	}
+
+	opts, err = parseUIXTestArgs("--a11y")
+	if err != nil {
+		t.Fatalf("parseUIXTestArgs returned error: %v", err)
+	}
+	if expected := []string{"test", "-count=1", "./..."}; !opts.A11y || !reflect.DeepEqual(opts.goTestArgs(), expected) {
+		t.Fatalf("expected an uncached accessibility run, got %+v", opts)
+	}
+
+	if _, err := parseUIXTestArgs("--run"); err == nil {
+		t.Fatalf("expected an error for a missing --run value")
+	}
+	if _, err := parseUIXTestArgs("--bogus"); err == nil {
+		t.Fatalf("expected an error for an unknown flag")
+	}
+}
+
+func TestParseUIXBuildArgs(t *testing.T) {
+	c, err := parse("uix:build", "--wasm", "./cmd/client")
+	if err != nil {
+		t.Fatalf("parse returned error: %v", err)
+	}
+	opts := uixBuildOptions(c)
+	if expected := []string{"build", "-o", "static/app.wasm", "./cmd/client"}; !reflect.DeepEqual(opts.goBuildArgs(), expected) {
+		t.Fatalf("expected %v, got %v", expected, opts.goBuildArgs())
+	}
+
+	c, err = parse("uix:build", "--wasm", "./cmd/client", "-o", "public/client.wasm")
+	if err != nil {
+		t.Fatalf("parse returned error: %v", err)
+	}
+	if opts := uixBuildOptions(c); opts.Out != "public/client.wasm" {
+		t.Fatalf("expected the output file, got %+v", opts)
+	}
+}
@@ -0,0 +1,491 @@
+package gopm
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/davidjeba/goscript/pkg/goscript/cli"
+)
+
+// DefaultLockFile is where gopm sync records the hashes of the vendored
+// files, relative to the project, for gopm verify to check them against.
+const DefaultLockFile = "gopm.lock"
+
+// DefaultVendorDir is the directory go mod vendor copies the modules to.
+const DefaultVendorDir = "vendor"
+
+// lockVersion is the format of the lock files gopm writes.
+const lockVersion = 1
+
+// ErrNoLock is returned by RunVerify for a project gopm sync has not locked.
+var ErrNoLock = errors.New("gopm: no lock file, run gopm sync to create it")
+
+// LockFile records the SHA-256 of every vendored file, keyed by its path
+// in the vendor directory with forward slashes, and the root of the Merkle
+// tree of the vendor directory those hashes make.
+type LockFile struct {
+	Version int               `json:"version"`
+	Vendor  string            `json:"vendor"`
+	Root    string            `json:"root"`
+	Files   map[string]string `json:"files"`
+}
+
+// VerifyOptions captures the arguments for gopm verify.
+type VerifyOptions struct {
+	Dir string
+
+	// Lock is the lock file; empty uses DefaultLockFile in Dir.
+	Lock string
+
+	// Root is the Merkle root the lock file must record, when set. A lock
+	// file's own root only shows its hashes are consistent, since whoever
+	// edits the hashes can recompute it; a root kept elsewhere, such as in
+	// the CI configuration, anchors them.
+	Root string
+
+	// Repair vendors the modules again when files were changed, from the
+	// module cache go.sum verifies, and checks them once more.
+	Repair bool
+
+	JSON bool
+
+	// Vendor copies the modules to the vendor directory; nil runs
+	// go mod vendor.
+	Vendor func(ctx context.Context, dir string) error
+}
+
+// VerifyReport is how the vendor directory differs from its lock file.
+type VerifyReport struct {
+	Vendor string `json:"vendor"`
+	Files  int    `json:"files"`
+
+	// Root is the Merkle root of the vendor directory and Locked the one
+	// the lock file records; they are equal when nothing changed.
+	Root   string `json:"root"`
+	Locked string `json:"locked"`
+
+	Modified []string `json:"modified"`
+	Missing  []string `json:"missing"`
+	Added    []string `json:"added"`
+
+	// Repaired is set when --repair vendored the modules again.
+	Repaired bool `json:"repaired,omitempty"`
+}
+
+// OK reports whether the vendor directory is as locked.
+func (r *VerifyReport) OK() bool {
+	return r.Root == r.Locked
+}
+
+// verifyOptions reads the options of gopm verify from its flags and
+// directory argument.
+func verifyOptions(c *cli.Context) VerifyOptions {
+	opts := VerifyOptions{Dir: c.Arg(0), Lock: c.String("lock"), Root: c.String("root"), Repair: c.Bool("repair"), JSON: c.Bool("json")}
+	if opts.Dir == "" {
+		opts.Dir = "."
+	}
+	return opts
+}
+
+// SyncOptions captures the arguments for gopm get and gopm sync.
+type SyncOptions struct {
+	Dir string
+
+	// Packages are added to the project's modules before they are
+	// vendored, as gopm get does.
+	Packages []string
+
+	// Lock is the lock file; empty uses DefaultLockFile in Dir.
+	Lock string
+
+	// Get adds packages to the project's modules; nil runs go get.
+	Get func(ctx context.Context, dir string, packages []string) error
+
+	// Vendor copies the modules to the vendor directory; nil runs
+	// go mod vendor.
+	Vendor func(ctx context.Context, dir string) error
+}
+
+// RunSync adds the packages to the project's modules, vendors them and
+// locks the hashes of the vendored files, so the files installed are the
+// ones gopm verify checks.
+func RunSync(ctx context.Context, opts SyncOptions) (*LockFile, error) {
+	if len(opts.Packages) > 0 {
+		get := opts.Get
+		if get == nil {
+			get = goGet
+		}
+		if err := get(ctx, opts.Dir, opts.Packages); err != nil {
+			return nil, err
+		}
+	}
+
+	vendor := opts.Vendor
+	if vendor == nil {
+		vendor = goModVendor
+	}
+	if err := vendor(ctx, opts.Dir); err != nil {
+		return nil, err
+	}
+	return LockVendor(opts.Dir, opts.Lock)
+}
+
+// lockPath returns the lock file of a project, by default in its directory.
+func lockPath(dir, lock string) string {
+	if lock == "" {
+		return filepath.Join(dir, DefaultLockFile)
+	}
+	return lock
+}
+
+// goModVendor copies the project's modules to its vendor directory.
+func goModVendor(ctx context.Context, dir string) error {
+	return goCommand(ctx, dir, "vendoring modules", "mod", "vendor")
+}
+
+// goGet adds packages to the project's modules.
+func goGet(ctx context.Context, dir string, packages []string) error {
+	return goCommand(ctx, dir, "getting packages", append([]string{"get"}, packages...)...)
+}
+
+// goCommand runs the go command in a project, reporting its errors as
+// failing to do what.
+func goCommand(ctx context.Context, dir, what string, args ...string) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %v: %s", what, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// LockVendor hashes the files of the project's vendor directory and writes
+// them to the lock file, such as after vendoring the modules.
+func LockVendor(dir, lock string) (*LockFile, error) {
+	files, err := hashTree(filepath.Join(dir, DefaultVendorDir))
+	if err != nil {
+		return nil, err
+	}
+	locked := &LockFile{Version: lockVersion, Vendor: DefaultVendorDir, Root: merkleTree(files).hash, Files: files}
+
+	data, err := json.MarshalIndent(locked, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := ioutil.WriteFile(lockPath(dir, lock), append(data, '\n'), 0644); err != nil {
+		return nil, err
+	}
+	return locked, nil
+}
+
+// ReadLock reads a lock file, checking that its hashes add up to its root,
+// which catches a corrupted lock file or one whose hashes were edited by
+// hand. It does not catch a lock file rewritten along with its root; see
+// VerifyOptions.Root.
+func ReadLock(path string) (*LockFile, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNoLock
+	} else if err != nil {
+		return nil, err
+	}
+
+	var locked LockFile
+	if err := json.Unmarshal(data, &locked); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if locked.Version != lockVersion {
+		return nil, fmt.Errorf("%s: unsupported version %d", path, locked.Version)
+	}
+	if root := merkleTree(locked.Files).hash; root != locked.Root {
+		return nil, fmt.Errorf("%s: the file hashes do not match the root %s, it was edited", path, locked.Root)
+	}
+	return &locked, nil
+}
+
+// RunVerify checks the project's vendor directory against its lock file.
+// With Repair, changed files are restored by vendoring the modules again.
+func RunVerify(ctx context.Context, opts VerifyOptions) (*VerifyReport, error) {
+	path := lockPath(opts.Dir, opts.Lock)
+	locked, err := ReadLock(path)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Root != "" && locked.Root != opts.Root {
+		return nil, fmt.Errorf("%s: the root %s is not the expected %s", path, shortHash(locked.Root), shortHash(opts.Root))
+	}
+	report, err := verifyVendor(opts.Dir, locked)
+	if err != nil || report.OK() || !opts.Repair {
+		return report, err
+	}
+
+	vendor := opts.Vendor
+	if vendor == nil {
+		vendor = goModVendor
+	}
+	if err := vendor(ctx, opts.Dir); err != nil {
+		return report, err
+	}
+	report, err = verifyVendor(opts.Dir, locked)
+	if report != nil {
+		report.Repaired = true
+	}
+	return report, err
+}
+
+// verifyVendor hashes the vendor directory and compares its Merkle tree
+// with the locked one, only descending into the directories whose hashes
+// differ.
+func verifyVendor(dir string, locked *LockFile) (*VerifyReport, error) {
+	files, err := hashTree(filepath.Join(dir, locked.Vendor))
+	if err != nil {
+		return nil, err
+	}
+	current := merkleTree(files)
+	report := &VerifyReport{
+		Vendor:   locked.Vendor,
+		Files:    len(files),
+		Root:     current.hash,
+		Locked:   locked.Root,
+		Modified: []string{},
+		Missing:  []string{},
+		Added:    []string{},
+	}
+	diffTrees(merkleTree(locked.Files), current, "", report)
+	return report, nil
+}
+
+// hashTree returns the SHA-256 of each file under root, keyed by its path
+// relative to root with forward slashes. Symbolic links are not followed;
+// their targets are hashed, so retargeting one is a change. Files are hashed
+// in parallel; a missing root has no files.
+func hashTree(root string) (map[string]string, error) {
+	var paths []string
+	links := make(map[string]string)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path == root && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		switch mode := info.Mode(); {
+		case mode.IsRegular():
+			paths = append(paths, path)
+		case mode&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(root, path)
+			links[filepath.ToSlash(rel)] = hashLink(target)
+		case !mode.IsDir():
+			return fmt.Errorf("%s: cannot hash a %s file", path, mode.Type())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]string, len(paths)+len(links))
+	for path, hash := range links {
+		files[path] = hash
+	}
+	var mutex sync.Mutex
+	var firstErr error
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				hash, err := hashFile(path)
+				rel, _ := filepath.Rel(root, path)
+				mutex.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				files[filepath.ToSlash(rel)] = hash
+				mutex.Unlock()
+			}
+		}()
+	}
+	for _, path := range paths {
+		jobs <- path
+	}
+	close(jobs)
+	wg.Wait()
+	return files, firstErr
+}
+
+// hashFile returns the SHA-256 of a file's content.
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashLink returns the SHA-256 of a symbolic link's target, prefixed so a
+// link does not hash as a file holding its target's path.
+func hashLink(target string) string {
+	hash := sha256.Sum256([]byte("symlink " + filepath.ToSlash(target)))
+	return hex.EncodeToString(hash[:])
+}
+
+// merkleNode is a file or directory of a Merkle tree. A file's hash is its
+// content's; a directory's hashes the names, kinds and hashes of its
+// entries, so equal directory hashes mean equal subtrees.
+type merkleNode struct {
+	hash     string
+	children map[string]*merkleNode
+}
+
+// merkleTree builds the Merkle tree of files keyed by slash-separated path.
+func merkleTree(files map[string]string) *merkleNode {
+	root := &merkleNode{children: make(map[string]*merkleNode)}
+	for path, hash := range files {
+		node := root
+		parts := strings.Split(path, "/")
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := node.children[part]
+			if !ok || child.children == nil {
+				child = &merkleNode{children: make(map[string]*merkleNode)}
+				node.children[part] = child
+			}
+			node = child
+		}
+		node.children[parts[len(parts)-1]] = &merkleNode{hash: hash}
+	}
+	root.sum()
+	return root
+}
+
+// sum computes the hashes of a directory and those below it.
+func (n *merkleNode) sum() {
+	if n.children == nil {
+		return
+	}
+	names := make([]string, 0, len(n.children))
+	for name, child := range n.children {
+		child.sum()
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hash := sha256.New()
+	for _, name := range names {
+		child := n.children[name]
+		kind := "file"
+		if child.children != nil {
+			kind = "dir"
+		}
+		fmt.Fprintf(hash, "%s %s %s\n", kind, child.hash, name)
+	}
+	n.hash = hex.EncodeToString(hash.Sum(nil))
+}
+
+// diffTrees adds the files that differ between the locked and current
+// trees to the report, skipping the subtrees whose hashes are equal.
+func diffTrees(locked, current *merkleNode, prefix string, report *VerifyReport) {
+	switch {
+	case locked == nil && current == nil:
+	case locked != nil && current != nil && locked.hash == current.hash:
+	case locked != nil && locked.children == nil && (current == nil || current.children != nil):
+		report.Missing = append(report.Missing, prefix)
+		diffTrees(nil, current, prefix, report)
+	case current != nil && current.children == nil && (locked == nil || locked.children != nil):
+		report.Added = append(report.Added, prefix)
+		diffTrees(locked, nil, prefix, report)
+	case locked != nil && current != nil && locked.children == nil:
+		report.Modified = append(report.Modified, prefix)
+	default:
+		// Directories, one of which may be missing
+		names := make(map[string]bool)
+		if locked != nil {
+			for name := range locked.children {
+				names[name] = true
+			}
+		}
+		if current != nil {
+			for name := range current.children {
+				names[name] = true
+			}
+		}
+		sorted := make([]string, 0, len(names))
+		for name := range names {
+			sorted = append(sorted, name)
+		}
+		sort.Strings(sorted)
+		for _, name := range sorted {
+			var l, c *merkleNode
+			if locked != nil {
+				l = locked.children[name]
+			}
+			if current != nil {
+				c = current.children[name]
+			}
+			path := name
+			if prefix != "" {
+				path = prefix + "/" + name
+			}
+			diffTrees(l, c, path, report)
+		}
+	}
+}
+
+// printVerifyReport prints the changed files, one per line.
+func printVerifyReport(report *VerifyReport) {
+	if report.Repaired {
+		fmt.Println("Vendored the modules again to repair the changed files")
+	}
+	if report.OK() {
+		fmt.Printf("%d files in %s match the lock file (root %s)\n", report.Files, report.Vendor, shortHash(report.Root))
+		return
+	}
+
+	for _, path := range report.Modified {
+		fmt.Printf("modified  %s/%s\n", report.Vendor, path)
+	}
+	for _, path := range report.Missing {
+		fmt.Printf("missing   %s/%s\n", report.Vendor, path)
+	}
+	for _, path := range report.Added {
+		fmt.Printf("added     %s/%s\n", report.Vendor, path)
+	}
+	fmt.Printf("%d modified, %d missing and %d added files in %s (root %s, locked %s)\n",
+		len(report.Modified), len(report.Missing), len(report.Added), report.Vendor, shortHash(report.Root), shortHash(report.Locked))
+}
+
+// shortHash abbreviates a hash for display.
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
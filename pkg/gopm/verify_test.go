@@ -0,0 +1,186 @@
+package gopm
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeVendor writes files under a project's vendor directory.
+func writeVendor(t *testing.T, dir string, files map[string]string) {
+	for path, content := range files {
+		path = filepath.Join(dir, DefaultVendorDir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVerifyOptions(t *testing.T) {
+	c, err := parse("verify", "--repair", "--json", "--root", "abc", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := verifyOptions(c)
+	if opts.Dir != "app" || !opts.Repair || !opts.JSON || opts.Lock != "" || opts.Root != "abc" {
+		t.Fatalf("unexpected options %+v", opts)
+	}
+}
+
+func TestRunVerify(t *testing.T) {
+	dir := t.TempDir()
+	original := map[string]string{
+		"modules.txt":                   "# example.com/a v1.0.0\n",
+		"example.com/a/a.go":            "package a\n",
+		"example.com/a/internal/x/x.go": "package x\n",
+		"example.com/b/b.go":            "package b\n",
+		"example.com/b/LICENSE":         "MIT\n",
+	}
+	writeVendor(t, dir, original)
+	locked, err := LockVendor(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locked.Files) != 5 || locked.Root == "" {
+		t.Fatalf("unexpected lock %+v", locked)
+	}
+
+	report, err := RunVerify(context.Background(), VerifyOptions{Dir: dir})
+	if err != nil || !report.OK() || report.Files != 5 {
+		t.Fatalf("expected the vendored files to match, got %+v %v", report, err)
+	}
+
+	writeVendor(t, dir, map[string]string{"example.com/a/a.go": "package a // patched\n", "example.com/c/c.go": "package c\n"})
+	os.RemoveAll(filepath.Join(dir, DefaultVendorDir, "example.com", "a", "internal"))
+	report, err = RunVerify(context.Background(), VerifyOptions{Dir: dir})
+	if err != nil || report.OK() {
+		t.Fatalf("expected the changes to be found, got %+v %v", report, err)
+	}
+	if !reflect.DeepEqual(report.Modified, []string{"example.com/a/a.go"}) ||
+		!reflect.DeepEqual(report.Missing, []string{"example.com/a/internal/x/x.go"}) ||
+		!reflect.DeepEqual(report.Added, []string{"example.com/c/c.go"}) {
+		t.Fatalf("unexpected changes %+v", report)
+	}
+
+	// Repairing vendors the modules again
+	vendored := 0
+	report, err = RunVerify(context.Background(), VerifyOptions{Dir: dir, Repair: true, Vendor: func(ctx context.Context, dir string) error {
+		vendored++
+		os.RemoveAll(filepath.Join(dir, DefaultVendorDir))
+		writeVendor(t, dir, original)
+		return nil
+	}})
+	if err != nil || !report.OK() || !report.Repaired || vendored != 1 {
+		t.Fatalf("expected the files repaired, got %+v %v", report, err)
+	}
+}
+
+func TestReadLockRejectsEditedLocks(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := RunVerify(context.Background(), VerifyOptions{Dir: dir}); err != ErrNoLock {
+		t.Fatalf("expected ErrNoLock, got %v", err)
+	}
+
+	writeVendor(t, dir, map[string]string{"example.com/a/a.go": "package a\n"})
+	if _, err := LockVendor(dir, ""); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, DefaultLockFile)
+	var locked LockFile
+	data, _ := ioutil.ReadFile(path)
+	json.Unmarshal(data, &locked)
+	locked.Files["example.com/a/a.go"] = strings.Repeat("0", 64)
+	data, _ = json.Marshal(locked)
+	ioutil.WriteFile(path, data, 0644)
+	if _, err := ReadLock(path); err == nil {
+		t.Fatal("expected a lock whose hashes do not match its root to be refused")
+	}
+}
+
+func TestRunSync(t *testing.T) {
+	dir := t.TempDir()
+	var got []string
+	opts := SyncOptions{
+		Dir:      dir,
+		Packages: []string{"example.com/a@v1.2.0"},
+		Get: func(ctx context.Context, dir string, packages []string) error {
+			got = packages
+			return nil
+		},
+		Vendor: func(ctx context.Context, dir string) error {
+			writeVendor(t, dir, map[string]string{"modules.txt": "# example.com/a v1.2.0\n", "example.com/a/a.go": "package a\n"})
+			return nil
+		},
+	}
+	locked, err := RunSync(context.Background(), opts)
+	if err != nil || len(locked.Files) != 2 || !reflect.DeepEqual(got, opts.Packages) {
+		t.Fatalf("expected the packages got and their files locked, got %+v %v %v", locked, got, err)
+	}
+	if read, err := ReadLock(filepath.Join(dir, DefaultLockFile)); err != nil || read.Root != locked.Root {
+		t.Fatalf("expected the lock file written, got %+v %v", read, err)
+	}
+
+	// Without packages, as gopm sync runs, only the modules are vendored
+	got = nil
+	opts.Packages = nil
+	if _, err := RunSync(context.Background(), opts); err != nil || got != nil {
+		t.Fatalf("expected nothing got, got %v %v", got, err)
+	}
+}
+
+func TestRunVerifyRoot(t *testing.T) {
+	dir := t.TempDir()
+	writeVendor(t, dir, map[string]string{"example.com/a/a.go": "package a\n"})
+	locked, err := LockVendor(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report, err := RunVerify(context.Background(), VerifyOptions{Dir: dir, Root: locked.Root}); err != nil || !report.OK() {
+		t.Fatalf("expected the anchored root to match, got %+v %v", report, err)
+	}
+
+	// A lock file rewritten with its root is consistent, but not anchored
+	writeVendor(t, dir, map[string]string{"example.com/a/a.go": "package a // patched\n"})
+	if _, err := LockVendor(dir, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RunVerify(context.Background(), VerifyOptions{Dir: dir, Root: locked.Root}); err == nil || !strings.Contains(err.Error(), "is not the expected") {
+		t.Fatalf("expected the rewritten lock refused, got %v", err)
+	}
+}
+
+func TestVerifySymlinks(t *testing.T) {
+	dir := t.TempDir()
+	writeVendor(t, dir, map[string]string{"example.com/a/a.go": "package a\n", "example.com/a/b.go": "package a\n"})
+	link := filepath.Join(dir, DefaultVendorDir, "example.com", "a", "link.go")
+	if err := os.Symlink("a.go", link); err != nil {
+		t.Skipf("cannot create symbolic links: %v", err)
+	}
+	locked, err := LockVendor(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locked.Files) != 3 {
+		t.Fatalf("expected the link locked, got %v", locked.Files)
+	}
+	if locked.Files["example.com/a/link.go"] == locked.Files["example.com/a/a.go"] {
+		t.Fatal("expected a link to hash apart from its target's content")
+	}
+
+	os.Remove(link)
+	if err := os.Symlink("b.go", link); err != nil {
+		t.Fatal(err)
+	}
+	report, err := RunVerify(context.Background(), VerifyOptions{Dir: dir})
+	if err != nil || !reflect.DeepEqual(report.Modified, []string{"example.com/a/link.go"}) {
+		t.Fatalf("expected the retargeted link modified, got %+v %v", report, err)
+	}
+}
@@ -0,0 +1,246 @@
+// Package admin serves an admin dashboard for a database schema: GoUIX
+// pages, on a goscript router, to browse and filter each table's rows,
+// edit them inline and follow the relationships between tables. Rows are
+// read and written through a Store, such as GoScaleDB through admin/storage,
+// and only signed-in users get in.
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Column kinds, which decide how a column's values are edited and filtered
+const (
+	KindText    = "text"
+	KindInteger = "integer"
+	KindNumber  = "number"
+	KindBoolean = "boolean"
+	KindTime    = "time"
+	KindJSON    = "json"
+)
+
+// Row is a table row by column name.
+type Row map[string]interface{}
+
+// Column is a column of a table.
+type Column struct {
+	Name string
+
+	// Type is the database type, such as "integer" or "varchar(255)"
+	Type     string
+	Nullable bool
+}
+
+// Kind returns the kind of the column's type.
+func (c *Column) Kind() string {
+	name := c.Type
+	if open := strings.Index(name, "("); open >= 0 {
+		name = name[:open]
+	}
+	switch strings.TrimSpace(name) {
+	case "smallint", "integer", "bigint", "int", "int2", "int4", "int8", "serial", "bigserial":
+		return KindInteger
+	case "real", "double precision", "numeric", "decimal", "float4", "float8":
+		return KindNumber
+	case "boolean", "bool":
+		return KindBoolean
+	case "date", "time", "timetz", "timestamp", "timestamptz", "timestamp with time zone", "timestamp without time zone":
+		return KindTime
+	case "json", "jsonb":
+		return KindJSON
+	}
+	return KindText
+}
+
+// Parse converts a value typed into a form to the column's type. An empty
+// value is NULL for a nullable column, and an error for the others unless
+// they hold text.
+func (c *Column) Parse(value string) (interface{}, error) {
+	kind := c.Kind()
+	if value == "" && kind != KindText {
+		if c.Nullable {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("%s is required", c.Name)
+	}
+
+	switch kind {
+	case KindInteger:
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a whole number", c.Name)
+		}
+		return n, nil
+	case KindNumber:
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number", c.Name)
+		}
+		return n, nil
+	case KindBoolean:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be true or false", c.Name)
+		}
+		return b, nil
+	case KindJSON:
+		if !json.Valid([]byte(value)) {
+			return nil, fmt.Errorf("%s must be valid JSON", c.Name)
+		}
+	}
+	return value, nil
+}
+
+// Format returns a column value as text, as forms show and Parse reads it,
+// with lists and objects as JSON.
+func Format(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
+
+// Relation links a column to the key of the table it refers to, such as
+// posts.author_id to users.id.
+type Relation struct {
+	Column string
+	Table  string
+}
+
+// Table is a table the dashboard shows.
+type Table struct {
+	Name string
+
+	// Key names the primary key column; tables without one are shown but
+	// cannot be edited or linked to
+	Key     string
+	Columns []*Column
+
+	// Relations are the table's columns referring to other tables. New
+	// infers those named after a table, such as author_id or user_id, when
+	// none are set.
+	Relations []Relation
+}
+
+// Column returns the column with a name, or nil.
+func (t *Table) Column(name string) *Column {
+	for _, column := range t.Columns {
+		if column.Name == name {
+			return column
+		}
+	}
+	return nil
+}
+
+// relation returns the relation of a column, or nil.
+func (t *Table) relation(column string) *Relation {
+	for i := range t.Relations {
+		if t.Relations[i].Column == column {
+			return &t.Relations[i]
+		}
+	}
+	return nil
+}
+
+// Filter narrows a listing to the rows whose column matches a value: text
+// columns containing it, ignoring case, time columns starting with it, such
+// as "2024-05", and others equal to it.
+type Filter struct {
+	Column *Column
+	Value  interface{}
+}
+
+// Query selects a page of a table's rows.
+type Query struct {
+	Filters []Filter
+
+	// Sort names the column to order by, or "" for the key
+	Sort       string
+	Descending bool
+	Limit      int
+	Offset     int
+}
+
+// ErrNotFound is returned by Store.Update when no row has the key.
+var ErrNotFound = errors.New("admin: no row has this key")
+
+// Store reads and writes the rows of the dashboard's tables.
+type Store interface {
+	// List returns the rows matching a query and how many match in all
+	List(ctx context.Context, table *Table, query Query) ([]Row, int, error)
+
+	// Get returns the row with a key, or nil
+	Get(ctx context.Context, table *Table, key string) (Row, error)
+
+	// Update sets columns of the row with a key and returns it as updated,
+	// or ErrNotFound
+	Update(ctx context.Context, table *Table, key string, values map[string]interface{}) (Row, error)
+}
+
+// inferRelations sets the relations of tables that have none from their
+// column names: a column such as author_id or user_id refers to the table
+// named author, authors, user or users, when it has a key.
+func inferRelations(tables []*Table) {
+	byName := make(map[string]*Table, len(tables))
+	for _, table := range tables {
+		byName[table.Name] = table
+	}
+
+	for _, table := range tables {
+		if len(table.Relations) > 0 {
+			continue
+		}
+		for _, column := range table.Columns {
+			if column.Name == table.Key || !strings.HasSuffix(column.Name, "_id") {
+				continue
+			}
+			name := strings.TrimSuffix(column.Name, "_id")
+			for _, candidate := range []string{name, name + "s", name + "es", strings.TrimSuffix(name, "y") + "ies"} {
+				if target, ok := byName[candidate]; ok && target.Key != "" {
+					table.Relations = append(table.Relations, Relation{Column: column.Name, Table: target.Name})
+					break
+				}
+			}
+		}
+	}
+}
+
+// referrers returns the relations of other tables referring to a table, by
+// table and column name.
+func referrers(tables []*Table, name string) []referrer {
+	var found []referrer
+	for _, table := range tables {
+		for _, relation := range table.Relations {
+			if relation.Table == name {
+				found = append(found, referrer{Table: table, Column: relation.Column})
+			}
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Table.Name != found[j].Table.Name {
+			return found[i].Table.Name < found[j].Table.Name
+		}
+		return found[i].Column < found[j].Column
+	})
+	return found
+}
+
+// referrer is a column of a table referring to another table.
+type referrer struct {
+	Table  *Table
+	Column string
+}
@@ -0,0 +1,291 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/davidjeba/goscript/pkg/goscript"
+)
+
+// memoryStore keeps rows in memory, by table name
+type memoryStore struct {
+	rows map[string][]Row
+}
+
+func (s *memoryStore) List(ctx context.Context, table *Table, query Query) ([]Row, int, error) {
+	var matched []Row
+	for _, row := range s.rows[table.Name] {
+		match := true
+		for _, filter := range query.Filters {
+			value, want := Format(row[filter.Column.Name]), Format(filter.Value)
+			if filter.Column.Kind() == KindText {
+				match = match && strings.Contains(strings.ToLower(value), strings.ToLower(want))
+			} else {
+				match = match && value == want
+			}
+		}
+		if match {
+			matched = append(matched, row)
+		}
+	}
+	if query.Sort != "" {
+		sort.SliceStable(matched, func(i, j int) bool {
+			if query.Descending {
+				i, j = j, i
+			}
+			return Format(matched[i][query.Sort]) < Format(matched[j][query.Sort])
+		})
+	}
+
+	total := len(matched)
+	if query.Offset > len(matched) {
+		query.Offset = len(matched)
+	}
+	matched = matched[query.Offset:]
+	if len(matched) > query.Limit {
+		matched = matched[:query.Limit]
+	}
+	return matched, total, nil
+}
+
+func (s *memoryStore) Get(ctx context.Context, table *Table, key string) (Row, error) {
+	for _, row := range s.rows[table.Name] {
+		if Format(row[table.Key]) == key {
+			return row, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *memoryStore) Update(ctx context.Context, table *Table, key string, values map[string]interface{}) (Row, error) {
+	row, _ := s.Get(ctx, table, key)
+	if row == nil {
+		return nil, ErrNotFound
+	}
+	for name, value := range values {
+		row[name] = value
+	}
+	return row, nil
+}
+
+func testTables() []*Table {
+	return []*Table{
+		{Name: "posts", Key: "id", Columns: []*Column{
+			{Name: "id", Type: "integer"},
+			{Name: "author_id", Type: "integer", Nullable: true},
+			{Name: "title", Type: "text"},
+			{Name: "rating", Type: "numeric(3,1)", Nullable: true},
+		}, Relations: []Relation{{Column: "author_id", Table: "users"}}},
+		{Name: "users", Key: "id", Columns: []*Column{
+			{Name: "id", Type: "integer"},
+			{Name: "name", Type: "varchar(100)"},
+			{Name: "admin", Type: "boolean"},
+		}},
+	}
+}
+
+// adminApp serves a dashboard at /admin, and a login page signing in the
+// user named by the "user" form field
+func adminApp(t *testing.T, store *memoryStore) *goscript.Router {
+	sessions := goscript.NewSessionManager(nil, []byte("0123456789abcdef0123456789abcdef"))
+	routes := goscript.NewRouter()
+	routes.Use(sessions.Middleware())
+	routes.POST("/login", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		if err := goscript.Login(r, r.PostFormValue("user")); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	dashboard := New(store, testTables()...)
+	dashboard.PageSize = 2
+	dashboard.Authorize = func(r *http.Request) bool { return goscript.CurrentUser(r) == "ada" }
+	dashboard.Mount(routes, "/admin")
+	return routes
+}
+
+// client keeps the cookies of the app between requests
+type client struct {
+	routes  http.Handler
+	cookies map[string]*http.Cookie
+}
+
+func (c *client) do(method, path string, form url.Values) *httptest.ResponseRecorder {
+	var request *http.Request
+	if form != nil {
+		request = httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		request = httptest.NewRequest(method, path, nil)
+	}
+	request.Header.Set("Accept", "text/html")
+	for _, cookie := range c.cookies {
+		request.AddCookie(cookie)
+	}
+
+	recorder := httptest.NewRecorder()
+	c.routes.ServeHTTP(recorder, request)
+	for _, cookie := range recorder.Result().Cookies() {
+		c.cookies[cookie.Name] = cookie
+	}
+	return recorder
+}
+
+var csrfInput = regexp.MustCompile(`name="csrf_token" value="([^"]+)"`)
+
+func TestColumnParse(t *testing.T) {
+	for _, test := range []struct {
+		column *Column
+		input  string
+		want   interface{}
+		err    bool
+	}{
+		{&Column{Name: "n", Type: "bigint"}, " 42", int64(42), false},
+		{&Column{Name: "n", Type: "integer"}, "4.2", nil, true},
+		{&Column{Name: "n", Type: "integer", Nullable: true}, "", nil, false},
+		{&Column{Name: "n", Type: "integer"}, "", nil, true},
+		{&Column{Name: "x", Type: "double precision"}, "2.5", 2.5, false},
+		{&Column{Name: "b", Type: "boolean"}, "true", true, false},
+		{&Column{Name: "j", Type: "jsonb"}, `{"a":`, nil, true},
+		{&Column{Name: "j", Type: "jsonb"}, `["a"]`, `["a"]`, false},
+		{&Column{Name: "s", Type: "varchar(10)"}, "", "", false},
+		{&Column{Name: "t", Type: "timestamptz", Nullable: true}, "2024-05-01", "2024-05-01", false},
+	} {
+		got, err := test.column.Parse(test.input)
+		if (err != nil) != test.err || got != test.want {
+			t.Errorf("%s %q: expected %v (error %v), got %v, %v", test.column.Type, test.input, test.want, test.err, got, err)
+		}
+	}
+}
+
+func TestInferRelations(t *testing.T) {
+	tables := append(testTables(),
+		&Table{Name: "categories", Key: "id", Columns: []*Column{{Name: "id", Type: "integer"}}},
+		&Table{Name: "tags", Columns: []*Column{{Name: "post_id", Type: "integer"}, {Name: "category_id", Type: "integer"}, {Name: "owner_id", Type: "integer"}}},
+	)
+	inferRelations(tables)
+
+	if got := tables[0].Relations; len(got) != 1 || got[0].Table != "users" {
+		t.Errorf("expected the relations set to be kept, got %v", got)
+	}
+	want := []Relation{{Column: "post_id", Table: "posts"}, {Column: "category_id", Table: "categories"}}
+	if got := tables[3].Relations; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	found := referrers(tables, "posts")
+	if len(found) != 1 || found[0].Table.Name != "tags" || found[0].Column != "post_id" {
+		t.Errorf("expected tags.post_id to refer to posts, got %v", found)
+	}
+	if found := referrers(tables, "users"); len(found) != 1 || found[0].Table.Name != "posts" {
+		t.Errorf("expected posts.author_id to refer to users, got %v", found)
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	store := &memoryStore{rows: map[string][]Row{
+		"users": {
+			{"id": int64(1), "name": "Ada", "admin": true},
+			{"id": int64(2), "name": "Grace", "admin": false},
+		},
+		"posts": {
+			{"id": int64(1), "author_id": int64(1), "title": "Engines", "rating": 4.5},
+			{"id": int64(2), "author_id": int64(2), "title": "Compilers", "rating": nil},
+			{"id": int64(3), "author_id": int64(1), "title": "Notes on the engine", "rating": 3.0},
+		},
+	}}
+	c := &client{routes: adminApp(t, store), cookies: map[string]*http.Cookie{}}
+
+	recorder := c.do("GET", "/admin/posts", nil)
+	if recorder.Code != http.StatusSeeOther || recorder.Header().Get("Location") != "/login?next=%2Fadmin%2Fposts" {
+		t.Fatalf("expected signed-out users to be sent to log in, got %d %v", recorder.Code, recorder.Header())
+	}
+	c.do("POST", "/login", url.Values{"user": {"grace"}})
+	if code := c.do("GET", "/admin", nil).Code; code != http.StatusForbidden {
+		t.Fatalf("expected users Authorize rejects to be refused, got %d", code)
+	}
+	c.do("POST", "/login", url.Values{"user": {"ada"}})
+
+	// Without Authorize, even signed-in users are refused
+	open := New(store, testTables()...)
+	routes := goscript.NewRouter()
+	routes.Use(goscript.NewSessionManager(nil, []byte("0123456789abcdef0123456789abcdef")).Middleware())
+	open.Mount(routes, "/admin")
+	recorder = httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/admin", nil)
+	for _, cookie := range c.cookies {
+		request.AddCookie(cookie)
+	}
+	routes.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("expected a dashboard without Authorize to refuse everyone, got %d", recorder.Code)
+	}
+
+	body := c.do("GET", "/admin", nil).Body.String()
+	if !strings.Contains(body, `<a href="/admin/posts">posts</a>`) || !strings.Contains(body, "author_id → users") {
+		t.Errorf("expected the tables and their relations, got %s", body)
+	}
+
+	// Filters, relation links and pages
+	body = c.do("GET", "/admin/posts?title=ENGINE", nil).Body.String()
+	if !strings.Contains(body, "Engines") || !strings.Contains(body, "Notes on the engine") || strings.Contains(body, "Compilers") {
+		t.Errorf("expected the rows whose title contains engine, got %s", body)
+	}
+	if !strings.Contains(body, `<a href="/admin/users/1">1</a>`) {
+		t.Errorf("expected author_id to link to the user, got %s", body)
+	}
+	body = c.do("GET", "/admin/posts?_sort=title", nil).Body.String()
+	if !strings.Contains(body, "Rows 1–2 of 3") || !strings.Contains(body, `href="/admin/posts?_page=2&amp;_sort=title"`) {
+		t.Errorf("expected the first page of rows with a link to the next, got %s", body)
+	}
+	if body := c.do("GET", "/admin/posts?author_id=x", nil).Body.String(); !strings.Contains(body, "Filter ignored: author_id must be a whole number") {
+		t.Errorf("expected an invalid filter to be reported, got %s", body)
+	}
+
+	// Related rows
+	body = c.do("GET", "/admin/users/1", nil).Body.String()
+	if !strings.Contains(body, `<a href="/admin/posts?author_id=1">posts by author_id</a>`) {
+		t.Errorf("expected a link to the user's posts, got %s", body)
+	}
+	if code := c.do("GET", "/admin/users/9", nil).Code; code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing row, got %d", code)
+	}
+
+	// Inline editing
+	body = c.do("GET", "/admin/posts?author_id=1&_edit=3", nil).Body.String()
+	if !strings.Contains(body, `<input form="admin-edit" name="title" value="Notes on the engine">`) {
+		t.Fatalf("expected the row to be open for editing, got %s", body)
+	}
+	match := csrfInput.FindStringSubmatch(body)
+	if match == nil {
+		t.Fatalf("expected the edit form to carry a CSRF token, got %s", body)
+	}
+	edit := url.Values{"title": {"Engine notes"}, "rating": {"high"}, "author_id": {"1"}, "_next": {"/admin/posts?author_id=1"}}
+	if code := c.do("POST", "/admin/posts/3", edit).Code; code != http.StatusForbidden {
+		t.Errorf("expected an edit without a CSRF token to be refused, got %d", code)
+	}
+
+	edit.Set(goscript.CSRFFormField, match[1])
+	recorder = c.do("POST", "/admin/posts/3", edit)
+	if recorder.Code != http.StatusUnprocessableEntity || !strings.Contains(recorder.Body.String(), "rating must be a number") ||
+		!strings.Contains(recorder.Body.String(), `name="rating" value="high"`) {
+		t.Errorf("expected the row to be shown again with the error, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	if store.rows["posts"][2]["title"] != "Notes on the engine" {
+		t.Errorf("expected nothing to be saved, got %v", store.rows["posts"][2])
+	}
+
+	edit.Set("rating", "")
+	recorder = c.do("POST", "/admin/posts/3", edit)
+	if recorder.Code != http.StatusSeeOther || recorder.Header().Get("Location") != "/admin/posts?author_id=1" {
+		t.Fatalf("expected a redirect to the listing, got %d %v", recorder.Code, recorder.Header())
+	}
+	if row := store.rows["posts"][2]; row["title"] != "Engine notes" || row["rating"] != nil || row["author_id"] != int64(1) {
+		t.Errorf("expected the edit to be saved, got %v", row)
+	}
+}
@@ -0,0 +1,243 @@
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/davidjeba/goscript/pkg/goscript"
+)
+
+// Dashboard serves the admin pages of a set of tables.
+type Dashboard struct {
+	// LoginPath is where signed-out users are sent to sign in; "/login" by
+	// default
+	LoginPath string
+
+	// Authorize decides whether a signed-in user may use the dashboard,
+	// such as by role; the others get 403 Forbidden. Until it is set,
+	// every user is refused.
+	Authorize func(r *http.Request) bool
+
+	// PageSize is how many rows a page lists; 50 by default
+	PageSize int
+
+	// Layout renders the pages; its TitleFormat and head can be changed
+	Layout *goscript.Layout
+
+	store  Store
+	tables []*Table
+	path   string
+}
+
+// New creates a dashboard for tables, whose rows store reads and writes.
+// Relations are inferred from column names for tables that have none.
+func New(store Store, tables ...*Table) *Dashboard {
+	sorted := append([]*Table(nil), tables...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	inferRelations(sorted)
+
+	layout := goscript.NewLayout()
+	layout.TitleFormat = "%s | Admin"
+	layout.Head.Style("admin", styles)
+
+	return &Dashboard{LoginPath: "/login", PageSize: 50, Layout: layout, store: store, tables: sorted}
+}
+
+// Mount serves the dashboard below prefix, such as "/admin". Only users
+// signed in with goscript.Login whom Authorize accepts get in, and edits
+// need the session's CSRF token, so the router must run
+// SessionManager.Middleware first.
+func (d *Dashboard) Mount(routes *goscript.Router, prefix string) {
+	d.path = strings.TrimSuffix("/"+strings.Trim(prefix, "/"), "/")
+
+	group := routes.Group(prefix)
+	group.Use(goscript.RequireLogin(d.LoginPath), d.authorize, goscript.CSRF())
+	group.GET("", d.Layout.Handler(d.index))
+	group.GET("/:table", d.Layout.Handler(d.browse))
+	group.GET("/:table/:key", d.Layout.Handler(d.record))
+	group.POST("/:table/:key", d.update)
+}
+
+// authorize refuses the users Authorize rejects, and every user when it is
+// not set.
+func (d *Dashboard) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d.Authorize == nil || !d.Authorize(r) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// table returns the table with a name, or nil.
+func (d *Dashboard) table(name string) *Table {
+	for _, table := range d.tables {
+		if table.Name == name {
+			return table
+		}
+	}
+	return nil
+}
+
+// href returns the path of a table's page, or of one of its rows.
+func (d *Dashboard) href(table string, key ...interface{}) string {
+	path := d.path + "/" + url.PathEscape(table)
+	for _, k := range key {
+		path += "/" + url.PathEscape(Format(k))
+	}
+	return path
+}
+
+// index lists the tables.
+func (d *Dashboard) index(r *http.Request, params map[string]string) (*goscript.Page, error) {
+	return goscript.NewPage("Tables", newIndexPage(d.frame(r))), nil
+}
+
+// browse lists a table's rows, with the row named by the _edit parameter
+// open for editing.
+func (d *Dashboard) browse(r *http.Request, params map[string]string) (*goscript.Page, error) {
+	table := d.table(params["table"])
+	if table == nil {
+		return d.notFound(r), nil
+	}
+	page, err := d.tablePage(r, table, r.URL.Query(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return goscript.NewPage(table.Name, page), nil
+}
+
+// tablePage lists the rows of a table selected by query parameters: a
+// filter per column, _sort and _desc, and _page. edit carries the values
+// of a failed edit to show again.
+func (d *Dashboard) tablePage(r *http.Request, table *Table, values url.Values, edit *rowEdit) (*tablePage, error) {
+	page := newTablePage(d.frame(r), table, values, edit)
+
+	query := Query{Limit: d.PageSize}
+	for _, column := range table.Columns {
+		text := strings.TrimSpace(values.Get(column.Name))
+		if text == "" {
+			continue
+		}
+		value, err := column.Parse(text)
+		if err != nil {
+			page.errors = append(page.errors, "Filter ignored: "+err.Error())
+			continue
+		}
+		query.Filters = append(query.Filters, Filter{Column: column, Value: value})
+	}
+	if sortColumn := values.Get("_sort"); table.Column(sortColumn) != nil {
+		query.Sort = sortColumn
+		query.Descending = values.Get("_desc") != ""
+	}
+	if number, err := strconv.Atoi(values.Get("_page")); err == nil && number > 1 {
+		page.number = number
+		query.Offset = (number - 1) * query.Limit
+	}
+
+	if page.edit == nil && table.Key != "" && values.Get("_edit") != "" {
+		page.edit = &rowEdit{key: values.Get("_edit")}
+	}
+
+	var err error
+	page.rows, page.total, err = d.store.List(r.Context(), table, query)
+	if err != nil {
+		return nil, err
+	}
+	page.query = query
+	return page, nil
+}
+
+// record shows a row, linking to the rows it refers to and to those
+// referring to it.
+func (d *Dashboard) record(r *http.Request, params map[string]string) (*goscript.Page, error) {
+	table := d.table(params["table"])
+	if table == nil || table.Key == "" {
+		return d.notFound(r), nil
+	}
+	row, err := d.store.Get(r.Context(), table, params["key"])
+	if err != nil {
+		return nil, err
+	}
+	if row == nil {
+		return d.notFound(r), nil
+	}
+
+	page := newRecordPage(d.frame(r), table, params["key"], row, referrers(d.tables, table.Name))
+	return goscript.NewPage(table.Name+" "+params["key"], page), nil
+}
+
+// update saves an inline edit of a row and returns to the listing it was
+// made on, or shows the listing again with the errors.
+func (d *Dashboard) update(w http.ResponseWriter, r *http.Request, params map[string]string) {
+	table := d.table(params["table"])
+	if table == nil || table.Key == "" {
+		d.Layout.Write(w, r, d.notFound(r))
+		return
+	}
+	key := params["key"]
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	next := goscript.SafeRedirect(r.PostFormValue("_next"), d.href(table.Name))
+	edit := &rowEdit{key: key, values: make(map[string]string)}
+	changes := make(map[string]interface{})
+	for _, column := range table.Columns {
+		posted, ok := r.PostForm[column.Name]
+		if !ok || column.Name == table.Key {
+			continue
+		}
+		edit.values[column.Name] = posted[0]
+		value, err := column.Parse(posted[0])
+		if err != nil {
+			edit.errors = append(edit.errors, err.Error())
+			continue
+		}
+		changes[column.Name] = value
+	}
+
+	if len(edit.errors) == 0 {
+		_, err := d.store.Update(r.Context(), table, key, changes)
+		switch {
+		case err == nil:
+			http.Redirect(w, r, next, http.StatusSeeOther)
+			return
+		case errors.Is(err, ErrNotFound):
+			d.Layout.Write(w, r, d.notFound(r))
+			return
+		}
+		edit.errors = append(edit.errors, err.Error())
+	}
+
+	var values url.Values
+	if target, err := url.Parse(next); err == nil {
+		values = target.Query()
+	}
+	page, err := d.tablePage(r, table, values, edit)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	result := goscript.NewPage(table.Name, page)
+	result.Status = http.StatusUnprocessableEntity
+	d.Layout.Write(w, r, result)
+}
+
+// notFound is the page for a table or row that does not exist.
+func (d *Dashboard) notFound(r *http.Request) *goscript.Page {
+	page := goscript.NewPage("Not found", newNotFoundPage(d.frame(r), r.URL.Path))
+	page.Status = http.StatusNotFound
+	return page
+}
+
+// frame returns what every page of a request shows around its content.
+func (d *Dashboard) frame(r *http.Request) frame {
+	return frame{dashboard: d, user: goscript.CurrentUser(r), csrf: goscript.CSRFInput(r)}
+}
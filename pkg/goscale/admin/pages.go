@@ -0,0 +1,432 @@
+package admin
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/davidjeba/goscript/pkg/gouix"
+)
+
+// cellLength is how many characters of a value the listings show.
+const cellLength = 80
+
+// frame is what every page shows around its content: the navigation
+// between tables and the signed-in user.
+type frame struct {
+	dashboard *Dashboard
+	user      string
+	csrf      string
+}
+
+// render places a page's content below the navigation and its title.
+func (f frame) render(title string, content ...string) string {
+	var nav strings.Builder
+	nav.WriteString(`<nav class="admin-nav">` + link(f.dashboard.path+"/", "Tables"))
+	for _, table := range f.dashboard.tables {
+		nav.WriteString(link(f.dashboard.href(table.Name), table.Name))
+	}
+	if f.user != "" {
+		nav.WriteString(`<span class="admin-user">` + text(f.user) + `</span>`)
+	}
+	nav.WriteString("</nav>")
+
+	return `<div class="admin">` + nav.String() + "<h1>" + text(title) + "</h1>" + strings.Join(content, "") + "</div>"
+}
+
+// indexPage lists the tables.
+type indexPage struct {
+	*gouix.BaseComponent
+	frame
+}
+
+var _ gouix.Component = (*indexPage)(nil)
+
+func newIndexPage(f frame) *indexPage {
+	return &indexPage{BaseComponent: gouix.NewBaseComponent("admin-tables", nil), frame: f}
+}
+
+// Render implements the Component interface
+func (p *indexPage) Render() string {
+	if len(p.dashboard.tables) == 0 {
+		return p.render("Tables", `<p class="admin-muted">There are no tables.</p>`)
+	}
+
+	var rows strings.Builder
+	for _, table := range p.dashboard.tables {
+		relations := make([]string, 0, len(table.Relations))
+		for _, relation := range table.Relations {
+			relations = append(relations, relation.Column+" → "+relation.Table)
+		}
+		rows.WriteString("<tr><td>" + link(p.dashboard.href(table.Name), table.Name) + "</td><td>" +
+			strconv.Itoa(len(table.Columns)) + "</td><td>" + text(table.Key) + "</td><td>" +
+			text(strings.Join(relations, ", ")) + "</td></tr>")
+	}
+	return p.render("Tables", `<table class="admin-table"><thead><tr><th>Table</th><th>Columns</th><th>Key</th>`+
+		`<th>Relations</th></tr></thead><tbody>`+rows.String()+"</tbody></table>")
+}
+
+// rowEdit is a row being edited inline, with the values and errors of a
+// save that failed.
+type rowEdit struct {
+	key    string
+	values map[string]string
+	errors []string
+}
+
+// tablePage lists a page of a table's rows, filtered and sorted, with a
+// row open for editing.
+type tablePage struct {
+	*gouix.BaseComponent
+	frame
+	table  *Table
+	values url.Values
+	query  Query
+	rows   []Row
+	total  int
+	number int
+	edit   *rowEdit
+	errors []string
+}
+
+var _ gouix.Component = (*tablePage)(nil)
+
+func newTablePage(f frame, table *Table, values url.Values, edit *rowEdit) *tablePage {
+	return &tablePage{BaseComponent: gouix.NewBaseComponent("admin-table", nil), frame: f, table: table, values: values, edit: edit, number: 1}
+}
+
+// Render implements the Component interface
+func (p *tablePage) Render() string {
+	var alerts []string
+	for _, message := range p.errors {
+		alerts = append(alerts, alert(message))
+	}
+	if p.edit != nil {
+		for _, message := range p.edit.errors {
+			alerts = append(alerts, alert(message))
+		}
+	}
+
+	editing := p.edit != nil
+	forms := `<form id="admin-filter" method="get" action="` + text(p.dashboard.href(p.table.Name)) + `">`
+	if p.query.Sort != "" {
+		forms += hidden("_sort", p.query.Sort)
+		if p.query.Descending {
+			forms += hidden("_desc", "1")
+		}
+	}
+	forms += "</form>"
+	if editing {
+		forms += `<form id="admin-edit" method="post" action="` + text(p.dashboard.href(p.table.Name, p.edit.key)) + `">` +
+			p.csrf + hidden("_next", p.link(func(values url.Values) { values.Del("_edit") })) + "</form>"
+	}
+
+	var head, filters strings.Builder
+	for _, column := range p.table.Columns {
+		head.WriteString("<th>" + p.sortLink(column) + "</th>")
+		filters.WriteString("<td>" + p.filterInput(column) + "</td>")
+	}
+	head.WriteString("<th></th>")
+	filters.WriteString(`<td><button form="admin-filter" type="submit">Filter</button> ` +
+		link(p.dashboard.href(p.table.Name), "Clear") + "</td>")
+
+	var rows strings.Builder
+	for _, row := range p.rows {
+		if editing && p.table.Key != "" && Format(row[p.table.Key]) == p.edit.key {
+			rows.WriteString(p.editRow(row))
+		} else {
+			rows.WriteString(p.row(row))
+		}
+	}
+	if len(p.rows) == 0 {
+		rows.WriteString(`<tr><td class="admin-muted" colspan="` + strconv.Itoa(len(p.table.Columns)+1) + `">No rows match.</td></tr>`)
+	}
+
+	return p.render(p.table.Name, strings.Join(alerts, ""), forms,
+		`<table class="admin-table"><thead><tr>`+head.String()+`</tr><tr class="admin-filters">`+filters.String()+
+			"</tr></thead><tbody>"+rows.String()+"</tbody></table>", p.pager())
+}
+
+// row renders a row, linking its key to its page and the columns of
+// relations to the rows they refer to.
+func (p *tablePage) row(row Row) string {
+	var b strings.Builder
+	b.WriteString("<tr>")
+	for _, column := range p.table.Columns {
+		b.WriteString("<td>" + p.cell(column, row[column.Name]) + "</td>")
+	}
+	b.WriteString("<td>")
+	if p.table.Key != "" {
+		key := Format(row[p.table.Key])
+		b.WriteString(link(p.link(func(values url.Values) { values.Set("_edit", key) }), "Edit"))
+	}
+	b.WriteString("</td></tr>")
+	return b.String()
+}
+
+// cell renders a column's value.
+func (p *tablePage) cell(column *Column, value interface{}) string {
+	shown := Format(value)
+	if len([]rune(shown)) > cellLength {
+		shown = string([]rune(shown)[:cellLength-1]) + "…"
+	}
+	switch {
+	case value == nil:
+		return `<span class="admin-muted">null</span>`
+	case column.Name == p.table.Key:
+		return link(p.dashboard.href(p.table.Name, value), shown)
+	case p.table.relation(column.Name) != nil:
+		return link(p.dashboard.href(p.table.relation(column.Name).Table, value), shown)
+	}
+	return text(shown)
+}
+
+// editRow renders a row as the inputs of the edit form, filled in with the
+// values of a failed save, if any.
+func (p *tablePage) editRow(row Row) string {
+	var b strings.Builder
+	b.WriteString(`<tr class="admin-editing">`)
+	for _, column := range p.table.Columns {
+		if column.Name == p.table.Key {
+			b.WriteString("<td>" + p.cell(column, row[column.Name]) + "</td>")
+			continue
+		}
+		value, ok := p.edit.values[column.Name]
+		if !ok {
+			value = Format(row[column.Name])
+		}
+		b.WriteString("<td>" + input("admin-edit", column, value) + "</td>")
+	}
+	b.WriteString(`<td><button form="admin-edit" type="submit">Save</button> ` +
+		link(p.link(func(values url.Values) { values.Del("_edit") }), "Cancel") + "</td></tr>")
+	return b.String()
+}
+
+// filterInput renders the input filtering a column.
+func (p *tablePage) filterInput(column *Column) string {
+	value := p.values.Get(column.Name)
+	if column.Kind() == KindBoolean {
+		return selectInput("admin-filter", column.Name, value, "", "true", "false")
+	}
+	return `<input form="admin-filter" name="` + text(column.Name) + `" value="` + text(value) + `" placeholder="` +
+		text(filterHint(column)) + `">`
+}
+
+// sortLink renders a column heading linking to the rows sorted by it, in
+// reverse if they already are.
+func (p *tablePage) sortLink(column *Column) string {
+	label := column.Name
+	descending := false
+	if p.query.Sort == column.Name {
+		descending = !p.query.Descending
+		if p.query.Descending {
+			label += " ▼"
+		} else {
+			label += " ▲"
+		}
+	}
+	return link(p.link(func(values url.Values) {
+		values.Set("_sort", column.Name)
+		values.Del("_desc")
+		values.Del("_page")
+		if descending {
+			values.Set("_desc", "1")
+		}
+	}), label)
+}
+
+// pager renders how many rows match and links to the pages before and
+// after.
+func (p *tablePage) pager() string {
+	if p.total == 0 {
+		return ""
+	}
+	first := p.query.Offset + 1
+	last := p.query.Offset + len(p.rows)
+	summary := fmt.Sprintf("Rows %d–%d of %d", first, last, p.total)
+	if len(p.rows) == 0 {
+		summary = fmt.Sprintf("%d rows", p.total)
+	}
+
+	links := ""
+	if p.number > 1 {
+		links += link(p.page(p.number-1), "Previous")
+	}
+	if last < p.total && len(p.rows) > 0 {
+		links += link(p.page(p.number+1), "Next")
+	}
+	return `<p class="admin-pager"><span class="admin-muted">` + summary + "</span>" + links + "</p>"
+}
+
+// page returns the link to another page of the rows.
+func (p *tablePage) page(number int) string {
+	return p.link(func(values url.Values) {
+		values.Del("_edit")
+		values.Set("_page", strconv.Itoa(number))
+		if number == 1 {
+			values.Del("_page")
+		}
+	})
+}
+
+// link returns the listing's path with its query parameters changed.
+func (p *tablePage) link(change func(values url.Values)) string {
+	values := url.Values{}
+	for name, value := range p.values {
+		values[name] = value
+	}
+	change(values)
+	if len(values) == 0 {
+		return p.dashboard.href(p.table.Name)
+	}
+	return p.dashboard.href(p.table.Name) + "?" + values.Encode()
+}
+
+// recordPage shows a row, linking to the rows it refers to and listing
+// those that refer to it.
+type recordPage struct {
+	*gouix.BaseComponent
+	frame
+	table     *Table
+	key       string
+	row       Row
+	referrers []referrer
+}
+
+var _ gouix.Component = (*recordPage)(nil)
+
+func newRecordPage(f frame, table *Table, key string, row Row, referrers []referrer) *recordPage {
+	return &recordPage{BaseComponent: gouix.NewBaseComponent("admin-record", nil), frame: f, table: table, key: key, row: row, referrers: referrers}
+}
+
+// Render implements the Component interface
+func (p *recordPage) Render() string {
+	var fields strings.Builder
+	for _, column := range p.table.Columns {
+		value := p.row[column.Name]
+		shown := text(Format(value))
+		switch relation := p.table.relation(column.Name); {
+		case value == nil:
+			shown = `<span class="admin-muted">null</span>`
+		case relation != nil:
+			shown = link(p.dashboard.href(relation.Table, value), Format(value))
+		}
+		fields.WriteString("<dt>" + text(column.Name) + "</dt><dd>" + shown + "</dd>")
+	}
+
+	edit := url.Values{p.table.Key: {p.key}, "_edit": {p.key}}
+	actions := `<p class="admin-actions">` + link(p.dashboard.href(p.table.Name)+"?"+edit.Encode(), "Edit") +
+		link(p.dashboard.href(p.table.Name), "All "+p.table.Name) + "</p>"
+
+	related := ""
+	if len(p.referrers) > 0 {
+		var links strings.Builder
+		for _, referrer := range p.referrers {
+			filter := url.Values{referrer.Column: {p.key}}
+			links.WriteString("<li>" + link(p.dashboard.href(referrer.Table.Name)+"?"+filter.Encode(),
+				referrer.Table.Name+" by "+referrer.Column) + "</li>")
+		}
+		related = "<h2>Related</h2><ul>" + links.String() + "</ul>"
+	}
+	return p.render(p.table.Name+" "+p.key, actions, `<dl class="admin-record">`+fields.String()+"</dl>", related)
+}
+
+// notFoundPage tells that a table or row does not exist.
+type notFoundPage struct {
+	*gouix.BaseComponent
+	frame
+	path string
+}
+
+var _ gouix.Component = (*notFoundPage)(nil)
+
+func newNotFoundPage(f frame, path string) *notFoundPage {
+	return &notFoundPage{BaseComponent: gouix.NewBaseComponent("admin-not-found", nil), frame: f, path: path}
+}
+
+// Render implements the Component interface
+func (p *notFoundPage) Render() string {
+	return p.render("Not found", `<p class="admin-muted">There is nothing at `+text(p.path)+".</p>")
+}
+
+// input renders the input editing a column, in a form.
+func input(form string, column *Column, value string) string {
+	switch column.Kind() {
+	case KindBoolean:
+		options := []string{"true", "false"}
+		if column.Nullable {
+			options = append([]string{""}, options...)
+		}
+		return selectInput(form, column.Name, value, options...)
+	case KindInteger:
+		return `<input form="` + form + `" type="number" name="` + text(column.Name) + `" value="` + text(value) + `">`
+	case KindNumber:
+		return `<input form="` + form + `" type="number" step="any" name="` + text(column.Name) + `" value="` + text(value) + `">`
+	}
+	return `<input form="` + form + `" name="` + text(column.Name) + `" value="` + text(value) + `">`
+}
+
+// selectInput renders a select of options, with value selected.
+func selectInput(form, name, value string, options ...string) string {
+	var b strings.Builder
+	b.WriteString(`<select form="` + form + `" name="` + text(name) + `">`)
+	for _, option := range options {
+		selected := ""
+		if option == value {
+			selected = " selected"
+		}
+		b.WriteString(`<option value="` + text(option) + `"` + selected + ">" + text(option) + "</option>")
+	}
+	b.WriteString("</select>")
+	return b.String()
+}
+
+// filterHint tells how a column's filter matches.
+func filterHint(column *Column) string {
+	switch column.Kind() {
+	case KindText, KindJSON:
+		return "contains"
+	case KindTime:
+		return "starts with"
+	}
+	return "equals"
+}
+
+// link renders a link with text.
+func link(href, label string) string {
+	return `<a href="` + text(href) + `">` + text(label) + "</a>"
+}
+
+// hidden renders a hidden input.
+func hidden(name, value string) string {
+	return `<input type="hidden" name="` + text(name) + `" value="` + text(value) + `">`
+}
+
+// alert renders an error message.
+func alert(message string) string {
+	return `<p class="admin-alert" role="alert">` + text(message) + "</p>"
+}
+
+// text escapes text for HTML, in content and attribute values.
+func text(s string) string {
+	return html.EscapeString(s)
+}
+
+// styles lay the pages out without a stylesheet of the app's.
+const styles = `.admin{font-family:system-ui,sans-serif;margin:0 auto;max-width:96rem;padding:1rem 1.5rem}
+.admin-nav{display:flex;flex-wrap:wrap;gap:.25rem 1rem;border-bottom:1px solid #ddd;padding-bottom:.75rem}
+.admin-user{margin-left:auto;color:#666}
+.admin-table{border-collapse:collapse;width:100%;font-size:.875rem}
+.admin-table th,.admin-table td{border-bottom:1px solid #eee;padding:.375rem .5rem;text-align:left;vertical-align:top}
+.admin-table input,.admin-table select{width:100%;box-sizing:border-box;min-width:5rem}
+.admin-filters td{background:#fafafa}
+.admin-editing td{background:#fffbe6}
+.admin-record{display:grid;grid-template-columns:max-content 1fr;gap:.375rem 1.5rem}
+.admin-record dt{font-weight:600}
+.admin-record dd{margin:0;word-break:break-word}
+.admin-actions a,.admin-pager a{margin-right:1rem}
+.admin-pager span{margin-right:1rem}
+.admin-muted{color:#888}
+.admin-alert{background:#fdecea;border:1px solid #f5c2c0;color:#8a1c17;padding:.5rem .75rem}`
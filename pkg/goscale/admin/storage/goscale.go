@@ -0,0 +1,170 @@
+// Package storage reads and writes the admin dashboard's rows in GoScaleDB,
+// with the dashboard's tables taken from a GoScaleDB schema.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/davidjeba/goscript/pkg/goscale/admin"
+	"github.com/davidjeba/goscript/pkg/goscale/db"
+)
+
+// Tables returns the dashboard tables of a schema, such as
+// IntrospectSchema or api.Schema.Tables returns it. Each table's primary
+// key comes first and the other columns follow by name.
+func Tables(schema *db.Schema) []*admin.Table {
+	tables := make([]*admin.Table, 0, len(schema.Tables))
+	for _, t := range schema.Tables {
+		table := &admin.Table{Name: t.Name, Key: t.PrimaryKey}
+		for _, c := range t.Columns {
+			table.Columns = append(table.Columns, &admin.Column{Name: c.Name, Type: c.Type, Nullable: c.Nullable})
+		}
+		sort.Slice(table.Columns, func(i, j int) bool {
+			if (table.Columns[i].Name == t.PrimaryKey) != (table.Columns[j].Name == t.PrimaryKey) {
+				return table.Columns[i].Name == t.PrimaryKey
+			}
+			return table.Columns[i].Name < table.Columns[j].Name
+		})
+		tables = append(tables, table)
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+	return tables
+}
+
+// GoScaleStore keeps the rows in the tables of a database schema.
+type GoScaleStore struct {
+	DB *db.GoScaleDB
+
+	// Schema names the database schema of the tables
+	Schema string
+}
+
+var _ admin.Store = (*GoScaleStore)(nil)
+
+// NewGoScaleStore creates a store for the tables of database's schema name
+func NewGoScaleStore(database *db.GoScaleDB, name string) *GoScaleStore {
+	return &GoScaleStore{DB: database, Schema: name}
+}
+
+// List returns a page of the rows matching a query, and how many match.
+func (s *GoScaleStore) List(ctx context.Context, table *admin.Table, query admin.Query) ([]admin.Row, int, error) {
+	var conditions []string
+	var args []interface{}
+	for _, filter := range query.Filters {
+		column := quote(filter.Column.Name)
+		switch filter.Column.Kind() {
+		case admin.KindText, admin.KindJSON:
+			args = append(args, "%"+escapeLike(admin.Format(filter.Value))+"%")
+			conditions = append(conditions, fmt.Sprintf("CAST(%s AS text) ILIKE $%d", column, len(args)))
+		case admin.KindTime:
+			args = append(args, escapeLike(admin.Format(filter.Value))+"%")
+			conditions = append(conditions, fmt.Sprintf("CAST(%s AS text) LIKE $%d", column, len(args)))
+		default:
+			args = append(args, filter.Value)
+			conditions = append(conditions, fmt.Sprintf("%s = $%d", column, len(args)))
+		}
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	total := 0
+	err := s.DB.QueryEach(ctx, "SELECT count(*) AS total FROM "+s.table(table)+where, func(row map[string]interface{}) error {
+		total, _ = strconv.Atoi(fmt.Sprint(row["total"]))
+		return nil
+	}, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var order []string
+	if query.Sort != "" {
+		direction := ""
+		if query.Descending {
+			direction = " DESC"
+		}
+		order = append(order, quote(query.Sort)+direction)
+	}
+	if table.Key != "" && table.Key != query.Sort {
+		order = append(order, quote(table.Key))
+	}
+	statement := "SELECT * FROM " + s.table(table) + where
+	if len(order) > 0 {
+		statement += " ORDER BY " + strings.Join(order, ", ")
+	}
+	statement += fmt.Sprintf(" LIMIT %d OFFSET %d", query.Limit, query.Offset)
+
+	var rows []admin.Row
+	err = s.DB.QueryEach(ctx, statement, func(row map[string]interface{}) error {
+		rows = append(rows, admin.Row(row))
+		return nil
+	}, args...)
+	return rows, total, err
+}
+
+// Get returns the row with a key, or nil.
+func (s *GoScaleStore) Get(ctx context.Context, table *admin.Table, key string) (admin.Row, error) {
+	var found admin.Row
+	err := s.DB.QueryEach(ctx, "SELECT * FROM "+s.table(table)+" WHERE "+quote(table.Key)+" = $1", func(row map[string]interface{}) error {
+		found = admin.Row(row)
+		return nil
+	}, key)
+	return found, err
+}
+
+// Update sets columns of the row with a key, returning it as updated.
+func (s *GoScaleStore) Update(ctx context.Context, table *admin.Table, key string, values map[string]interface{}) (admin.Row, error) {
+	if len(values) == 0 {
+		row, err := s.Get(ctx, table, key)
+		if err == nil && row == nil {
+			err = admin.ErrNotFound
+		}
+		return row, err
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	assignments := make([]string, len(names))
+	args := make([]interface{}, 0, len(names)+1)
+	for i, name := range names {
+		args = append(args, values[name])
+		assignments[i] = fmt.Sprintf("%s = $%d", quote(name), len(args))
+	}
+	args = append(args, key)
+	statement := fmt.Sprintf("UPDATE %s SET %s WHERE %s = $%d RETURNING *",
+		s.table(table), strings.Join(assignments, ", "), quote(table.Key), len(args))
+
+	var updated admin.Row
+	err := s.DB.QueryEach(ctx, statement, func(row map[string]interface{}) error {
+		updated = admin.Row(row)
+		return nil
+	}, args...)
+	if err == nil && updated == nil {
+		err = admin.ErrNotFound
+	}
+	return updated, err
+}
+
+// table returns the qualified name of a table.
+func (s *GoScaleStore) table(table *admin.Table) string {
+	return quote(s.Schema) + "." + quote(table.Name)
+}
+
+// quote quotes an identifier.
+func quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern.
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+// DefaultAPIKeyHeader is the header EnableAnalytics reads consumers' API
+// keys from unless told otherwise.
+const DefaultAPIKeyHeader = "X-API-Key"
+
+// analytics records the API's usage per operation and per consumer.
+type analytics struct {
+	jetpack *core.Jetpack
+	header  string
+}
+
+// EnableAnalytics records every call of a known operation with
+// jp.RecordOperation: its latency, whether it failed, and the size of its
+// request and response, per operation and per consumer. Consumers are told
+// apart by the API key they send in header, X-API-Key if empty, and are
+// named by APIKeyID so the keys themselves are never stored. Give jp a
+// History, such as storage.GoScaleHistory, to keep the usage in GoScaleDB
+// time series.
+func (g *GoScaleAPI) EnableAnalytics(jp *core.Jetpack, header string) {
+	if header == "" {
+		header = DefaultAPIKeyHeader
+	}
+	g.analytics = &analytics{jetpack: jp, header: header}
+}
+
+// APIKeyID names the consumer of an API key in the analytics, as "key_"
+// and the start of the key's SHA-256 hash.
+func APIKeyID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "key_" + hex.EncodeToString(sum[:6])
+}
+
+// record records a call, if analytics are enabled.
+func (a *analytics) record(r *http.Request, operation string, start time.Time, request *countingBody, response *countingWriter, failed bool) {
+	if a == nil {
+		return
+	}
+	call := core.OperationCall{
+		Operation:    operation,
+		Duration:     time.Since(start),
+		RequestSize:  request.size,
+		ResponseSize: response.size,
+		Failed:       failed,
+	}
+	if key := r.Header.Get(a.header); key != "" {
+		call.Consumer = APIKeyID(key)
+	}
+	a.jetpack.RecordOperation(call)
+}
+
+// AnalyticsHandler serves the usage EnableAnalytics records as JSON: the
+// operations and the consumers, busiest first, over the window given by the
+// window parameter, such as "15m", or everything retained. With
+// sort=latency they are slowest first instead, and limit caps how many of
+// each are listed.
+func (g *GoScaleAPI) AnalyticsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if g.analytics == nil {
+			http.Error(w, "analytics are not enabled", http.StatusNotFound)
+			return
+		}
+
+		query := r.URL.Query()
+		var window time.Duration
+		if value := query.Get("window"); value != "" {
+			var err error
+			if window, err = time.ParseDuration(value); err != nil || window < 0 {
+				http.Error(w, "invalid window", http.StatusBadRequest)
+				return
+			}
+		}
+		limit := 0
+		if value := query.Get("limit"); value != "" {
+			var err error
+			if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+		}
+
+		jp := g.analytics.jetpack
+		operations, err := jp.OperationUsage(r.Context(), window)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		consumers, err := jp.ConsumerUsage(r.Context(), window)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if query.Get("sort") == "latency" {
+			operations = core.SlowestUsage(operations)
+			consumers = core.SlowestUsage(consumers)
+		}
+		if limit > 0 && len(operations) > limit {
+			operations = operations[:limit]
+		}
+		if limit > 0 && len(consumers) > limit {
+			consumers = consumers[:limit]
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"window":     window.String(),
+			"operations": operations,
+			"consumers":  consumers,
+		})
+	})
+}
+
+// countingBody counts the bytes read from a request body, as sent.
+type countingBody struct {
+	io.ReadCloser
+	size int64
+}
+
+func (b *countingBody) Read(data []byte) (int, error) {
+	n, err := b.ReadCloser.Read(data)
+	b.size += int64(n)
+	return n, err
+}
+
+// countingWriter counts the bytes of a response body.
+type countingWriter struct {
+	http.ResponseWriter
+	size int64
+}
+
+func (w *countingWriter) Write(data []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(data)
+	w.size += int64(n)
+	return n, err
+}
+
+// Flush sends the buffered response to the client, as for streamed exports.
+func (w *countingWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/davidjeba/goscript/pkg/goscript/config"
+)
+
+// LoadConfig loads the API's settings over DefaultConfig, such as from the
+// "api" section of the app's config:
+//
+//	apiConfig, err := api.LoadConfig(loader.Section("api"))
+func LoadConfig(loader *config.Loader) (*Config, error) {
+	c := DefaultConfig()
+	if err := loader.Load(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks the settings an API cannot run with
+func (c *Config) Validate() error {
+	switch {
+	case c.DBConnectionString == "":
+		return errors.New("db_connection_string is required")
+	case c.MaxDBConnections <= 0:
+		return errors.New("max_db_connections must be positive")
+	case c.CompressionLevel < 0 || c.CompressionLevel > 9:
+		return errors.New("compression_level must be between 0 and 9")
+	case c.BatchSize <= 0:
+		return errors.New("batch_size must be positive")
+	case c.Timeout <= 0:
+		return errors.New("timeout must be positive")
+	case c.MaxConcurrent <= 0:
+		return errors.New("max_concurrent must be positive")
+	case c.EdgeEnabled && len(c.EdgeNodes) == 0:
+		return errors.New("edge_nodes are required when edge_enabled is set")
+	}
+	return nil
+}
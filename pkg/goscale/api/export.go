@@ -0,0 +1,388 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/reqctx"
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+// Exporter streams the rows of a list query, calling emit with each, such as
+// from GoScaleDB's QueryEach, so an export never holds the whole result.
+type Exporter func(ctx context.Context, params map[string]interface{}, emit func(row map[string]interface{}) error) error
+
+// Export streams a list query to ExportHandler.
+type Export struct {
+	// Columns are the fields exported as CSV, in order; without them the
+	// first row's fields are, sorted.
+	Columns []string
+
+	Rows Exporter
+}
+
+// exportFlushEvery is how many rows are written between flushes.
+const exportFlushEvery = 100
+
+// exportFormats are the content types of the export formats.
+var exportFormats = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"ndjson": "application/x-ndjson",
+}
+
+// SetExport has ExportHandler stream the list query's rows with rows rather
+// than its resolver's result, exporting columns, in order, as CSV.
+func (f *Field) SetExport(rows Exporter, columns ...string) *Field {
+	f.Export = &Export{Columns: columns, Rows: rows}
+	return f
+}
+
+// RegisterExport streams the rows of a list operation, such as
+// "query:listUsers", to ExportHandler. ApplySchema registers the exports
+// of the schema's queries.
+func (g *GoScaleAPI) RegisterExport(operation string, export *Export) {
+	g.exports[operation] = export
+}
+
+// ExportHandler serves the results of list queries as files for analysts:
+// GET /listUsers.csv or /listUsers.ndjson streams query:listUsers as CSV or
+// newline-delimited JSON, with the query string as its arguments, typed by
+// the schema. Mount it beside the API:
+//
+//	routes.Mount("/api/export", goscaleAPI.ExportHandler())
+//
+// Queries with an export stream their rows as they are read; any other list
+// query's result is exported once it resolves. Exports run through the API's
+// middleware and mask personal data as its responses do. They are not bound
+// by its timeout, since a large table takes a while to stream, and stop when
+// the client goes away. Once the first row is sent the status cannot change,
+// so an export that fails midway is cut short.
+func (g *GoScaleAPI) ExportHandler() http.Handler {
+	return http.HandlerFunc(g.serveExport)
+}
+
+// serveExport serves an export.
+func (g *GoScaleAPI) serveExport(w http.ResponseWriter, r *http.Request) {
+	startTime := time.Now()
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	file := path.Base(r.URL.Path)
+	format := strings.TrimPrefix(path.Ext(file), ".")
+	name := strings.TrimSuffix(file, path.Ext(file))
+	contentType, ok := exportFormats[format]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unsupported export format %q; use csv or ndjson", format), http.StatusBadRequest)
+		return
+	}
+
+	operation := "query:" + name
+	resolver, ok := g.resolvers[operation]
+	if !ok {
+		http.Error(w, "Unknown operation", http.StatusNotFound)
+		return
+	}
+	var field *Field
+	if g.schema != nil {
+		field = g.schema.Queries[name]
+		if field != nil && !strings.HasPrefix(strings.TrimSuffix(field.Type, "!"), "[") {
+			http.Error(w, operation+" does not return a list", http.StatusBadRequest)
+			return
+		}
+	}
+	params, err := exportParams(field, r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	sent := &countingBody{ReadCloser: r.Body}
+	r.Body = sent
+	response := &countingWriter{ResponseWriter: w}
+
+	ctx := reqctx.Ensure(reqctx.ExtractRequest(r, g.trustedProxies))
+	w.Header().Set(reqctx.RequestIDHeader, reqctx.RequestID(ctx))
+	core.SetRoute(r, "goscale:"+operation)
+
+	rows := newExportWriter(format, response, func() {
+		header := w.Header()
+		header.Set("Content-Type", contentType)
+		header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + "." + format}))
+		header.Set("X-Content-Type-Options", "nosniff")
+	})
+	if export := g.exports[operation]; export != nil {
+		rows.columns = export.Columns
+	}
+
+	// Run the export as a resolver, so the middleware can refuse it
+	export := g.exportResolver(operation, field, resolver, rows)
+	for i := len(g.middlewares) - 1; i >= 0; i-- {
+		export = g.middlewares[i](ctx, export)
+	}
+
+	_, err = export(ctx, params)
+	if err == nil {
+		err = rows.Close()
+	}
+	if err != nil {
+		if !rows.started {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		g.updateMetrics(startTime, false)
+		g.analytics.record(r, operation, startTime, sent, response, true)
+		return
+	}
+
+	g.updateMetrics(startTime, true)
+	g.analytics.record(r, operation, startTime, sent, response, false)
+}
+
+// exportResolver returns a resolver writing an operation's rows, masked for
+// the caller, to rows: streamed by its export, or else taken from the list
+// its resolver returns.
+func (g *GoScaleAPI) exportResolver(operation string, field *Field, resolver Resolver, rows *exportWriter) Resolver {
+	if export := g.exports[operation]; export != nil {
+		return func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+			role := Role(ctx)
+			return nil, export.Rows(ctx, params, func(row map[string]interface{}) error {
+				if field != nil && g.schema != nil {
+					masked, err := g.schema.MaskValue(baseType(field.Type), row, role)
+					if err != nil {
+						return err
+					}
+					row, _ = masked.(map[string]interface{})
+				}
+				return rows.Write(row)
+			})
+		}
+	}
+
+	return func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		result, err := resolver(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		if result, err = g.MaskResult(operation, result, Role(ctx)); err != nil {
+			return nil, err
+		}
+		items, err := exportItems(result)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", operation, err)
+		}
+		for _, item := range items {
+			row, ok := item.(map[string]interface{})
+			if !ok {
+				row = map[string]interface{}{"value": item}
+			}
+			if err := rows.Write(row); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	}
+}
+
+// exportItems returns the elements of a list result as JSON values.
+func exportItems(result interface{}) ([]interface{}, error) {
+	if items, ok := result.([]interface{}); ok {
+		return items, nil
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	var items []interface{}
+	if err := decoder.Decode(&items); err != nil {
+		return nil, fmt.Errorf("the result is not a list")
+	}
+	return items, nil
+}
+
+// exportParams converts the query string of an export to the arguments of
+// field, typed as it declares them. Without a field the values are passed on
+// as strings.
+func exportParams(field *Field, query url.Values) (map[string]interface{}, error) {
+	params := make(map[string]interface{}, len(query))
+	for name, values := range query {
+		value := values[len(values)-1]
+		if field == nil {
+			params[name] = value
+			continue
+		}
+		arg, ok := field.Args[name]
+		if !ok {
+			return nil, fmt.Errorf("%s has no argument %s", field.Name, name)
+		}
+
+		var err error
+		switch baseType(arg.Type) {
+		case "Int", "BigInt":
+			params[name], err = strconv.ParseInt(value, 10, 64)
+		case "Float":
+			params[name], err = strconv.ParseFloat(value, 64)
+		case "Boolean":
+			params[name], err = strconv.ParseBool(value)
+		default:
+			params[name] = value
+		}
+		if err != nil {
+			return nil, fmt.Errorf("argument %s: %q is not a valid %s", name, value, baseType(arg.Type))
+		}
+	}
+	return params, nil
+}
+
+// exportWriter writes the rows of an export in its format, starting the
+// response with the first.
+type exportWriter struct {
+	format  string
+	columns []string
+	start   func()
+	started bool
+	count   int
+
+	w       *countingWriter
+	buffer  *bufio.Writer
+	csv     *csv.Writer
+	encoder *json.Encoder
+}
+
+// newExportWriter creates a writer of rows in format, calling start to set
+// the response's headers before the first is sent.
+func newExportWriter(format string, w *countingWriter, start func()) *exportWriter {
+	e := &exportWriter{format: format, start: start, w: w, buffer: bufio.NewWriter(w)}
+	if format == "csv" {
+		e.csv = csv.NewWriter(e.buffer)
+	} else {
+		e.encoder = json.NewEncoder(e.buffer)
+	}
+	return e
+}
+
+// begin starts the response, writing the CSV header row.
+func (e *exportWriter) begin(first map[string]interface{}) error {
+	e.started = true
+	e.start()
+	if e.csv == nil {
+		return nil
+	}
+	if len(e.columns) == 0 {
+		for column := range first {
+			e.columns = append(e.columns, column)
+		}
+		sort.Strings(e.columns)
+	}
+	return e.csv.Write(e.columns)
+}
+
+// Write writes a row.
+func (e *exportWriter) Write(row map[string]interface{}) error {
+	if !e.started {
+		if err := e.begin(row); err != nil {
+			return err
+		}
+	}
+
+	if e.csv != nil {
+		record := make([]string, len(e.columns))
+		for i, column := range e.columns {
+			record[i] = csvValue(row[column])
+		}
+		if err := e.csv.Write(record); err != nil {
+			return err
+		}
+	} else if err := e.encoder.Encode(row); err != nil {
+		return err
+	}
+
+	e.count++
+	if e.count%exportFlushEvery == 0 {
+		return e.flush()
+	}
+	return nil
+}
+
+// Close flushes the rows left; an export without rows is a CSV header row
+// of the columns, if known, or an empty file.
+func (e *exportWriter) Close() error {
+	if !e.started {
+		if err := e.begin(nil); err != nil {
+			return err
+		}
+	}
+	return e.flush()
+}
+
+// flush sends the buffered rows to the client.
+func (e *exportWriter) flush() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		if err := e.csv.Error(); err != nil {
+			return err
+		}
+	}
+	if err := e.buffer.Flush(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return nil
+}
+
+// csvValue formats a field as a CSV cell: null as empty, times as RFC 3339
+// and lists and objects as JSON. Text that a spreadsheet would run as a
+// formula is quoted as text; see neutralizeFormula.
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return neutralizeFormula(v)
+	case json.Number:
+		return string(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []byte:
+		return neutralizeFormula(string(v))
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return neutralizeFormula(fmt.Sprint(value))
+	}
+	return string(data)
+}
+
+// neutralizeFormula prefixes text starting with =, +, -, @, a tab or a
+// carriage return with ', which spreadsheets read as "text" rather than
+// run as a formula, so an exported "=HYPERLINK(...)" cannot act on the
+// analyst's machine. Numbers are formatted by csvValue, so only text is
+// prefixed.
+func neutralizeFormula(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
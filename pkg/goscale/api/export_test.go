@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// exportAPI serves listUsers, whose emails are masked for callers without
+// the admin role, streamed by an export when streamed is set
+func exportAPI(t *testing.T, streamed bool) *GoScaleAPI {
+	users := []map[string]interface{}{
+		{"id": "1", "name": "Ada", "email": "ada@example.com"},
+		{"id": "2", "name": "=HYPERLINK(\"http://evil\")", "email": "bob@example.com"},
+	}
+
+	schema := NewSchema()
+	user := schema.AddType("User", "")
+	user.AddField("id", "ID!", "")
+	user.AddField("name", "String", "")
+	user.AddField("email", "String", "").Mask(MaskRemove, "admin")
+	list := schema.AddQuery("listUsers", "[User!]!", "")
+	list.AddArg("limit", "Int", nil, "")
+	list.AddArg("active", "Boolean", nil, "")
+	list.SetResolver(func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		return users, nil
+	})
+	if streamed {
+		list.SetExport(func(ctx context.Context, params map[string]interface{}, emit func(map[string]interface{}) error) error {
+			for _, u := range users {
+				if err := emit(u); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, "id", "name", "email")
+	}
+
+	api := NewGoScaleAPI(nil)
+	if err := api.ApplySchema(schema); err != nil {
+		t.Fatal(err)
+	}
+	return api
+}
+
+func TestExportParams(t *testing.T) {
+	field := &Field{Name: "listUsers", Args: map[string]*Argument{
+		"limit":  {Name: "limit", Type: "Int!"},
+		"ratio":  {Name: "ratio", Type: "Float"},
+		"active": {Name: "active", Type: "Boolean"},
+		"name":   {Name: "name", Type: "String"},
+	}}
+	params, err := exportParams(field, url.Values{"limit": {"5", "10"}, "ratio": {"0.5"}, "active": {"true"}, "name": {"Ada"}})
+	if err != nil || params["limit"] != int64(10) || params["ratio"] != 0.5 || params["active"] != true || params["name"] != "Ada" {
+		t.Fatalf("unexpected params %v (%v)", params, err)
+	}
+
+	if _, err := exportParams(field, url.Values{"limit": {"ten"}}); err == nil || !strings.Contains(err.Error(), `"ten" is not a valid Int`) {
+		t.Fatalf("expected an invalid Int refused, got %v", err)
+	}
+	if _, err := exportParams(field, url.Values{"offset": {"1"}}); err == nil {
+		t.Fatalf("expected an unknown argument refused")
+	}
+	if params, err := exportParams(nil, url.Values{"limit": {"5"}}); err != nil || params["limit"] != "5" {
+		t.Fatalf("expected strings without a field, got %v (%v)", params, err)
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	for _, streamed := range []bool{false, true} {
+		api := exportAPI(t, streamed)
+		recorder := httptest.NewRecorder()
+		api.ExportHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/export/listUsers.csv?limit=2", nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", recorder.Code, recorder.Body)
+		}
+
+		header := recorder.Header()
+		if header.Get("Content-Type") != "text/csv; charset=utf-8" || header.Get("Content-Disposition") != `attachment; filename=listUsers.csv` || header.Get("X-Content-Type-Options") != "nosniff" {
+			t.Fatalf("unexpected headers %v", header)
+		}
+		records, err := csv.NewReader(recorder.Body).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		columns := "id,name"
+		if streamed {
+			columns = "id,name,email"
+		}
+		if len(records) != 3 || strings.Join(records[0], ",") != columns {
+			t.Fatalf("unexpected records %v", records)
+		}
+		if name := records[2][1]; name != `'=HYPERLINK("http://evil")` {
+			t.Fatalf("expected the formula neutralized, got %q", name)
+		}
+		for _, record := range records[1:] {
+			if len(record) == 3 && record[2] != "" {
+				t.Fatalf("expected the emails masked, got %v", record)
+			}
+		}
+	}
+}
+
+func TestExportNDJSON(t *testing.T) {
+	api := exportAPI(t, true)
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodGet, "/api/export/listUsers.ndjson", nil)
+	api.ExportHandler().ServeHTTP(recorder, request.WithContext(WithRole(request.Context(), "admin")))
+
+	if recorder.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("unexpected headers %v", recorder.Header())
+	}
+	lines := strings.Split(strings.TrimSpace(recorder.Body.String()), "\n")
+	var first map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil || len(lines) != 2 || first["email"] != "ada@example.com" {
+		t.Fatalf("expected the admin to see the emails, got %v (%v)", lines, err)
+	}
+
+	recorder = httptest.NewRecorder()
+	api.ExportHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/export/listUsers.xml", nil))
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected an unknown format refused, got %d", recorder.Code)
+	}
+}
+
+func TestCSVValue(t *testing.T) {
+	for value, want := range map[interface{}]string{
+		"=1+2":              "'=1+2",
+		"+1":                "'+1",
+		"-1":                "'-1",
+		"@SUM(A1)":          "'@SUM(A1)",
+		"\tcmd":             "'\tcmd",
+		"Ada":               "Ada",
+		"":                  "",
+		-1:                  "-1",
+		json.Number("-2.5"): "-2.5",
+		nil:                 "",
+		true:                "true",
+	} {
+		if got := csvValue(value); got != want {
+			t.Errorf("csvValue(%#v) = %q, want %q", value, got, want)
+		}
+	}
+}
@@ -5,17 +5,20 @@ import (
         "encoding/json"
         "fmt"
         "net/http"
-        "reflect"
         "sync"
         "time"
 
         "github.com/davidjeba/goscript/pkg/goscale/db"
+        "github.com/davidjeba/goscript/pkg/goscript/events"
+        "github.com/davidjeba/goscript/pkg/goscript/reqctx"
+        "github.com/davidjeba/goscript/pkg/jetpack/core"
 )
 
 // GoScaleAPI represents the main API system that combines gRPC-like performance
 // with GraphQL-like flexibility
 type GoScaleAPI struct {
         resolvers      map[string]Resolver
+        exports        map[string]*Export
         middlewares    []Middleware
         subscriptions  map[string]*Subscription
         subMutex       sync.RWMutex
@@ -27,6 +30,11 @@ type GoScaleAPI struct {
         timeout        time.Duration
         maxConcurrent  int
         metrics        *Metrics
+        persisted      persistedQueries
+        schema         *Schema
+        analytics      *analytics
+        events         *events.Bus
+        trustedProxies []string
 }
 
 // Resolver is a function that resolves a specific API request
@@ -35,13 +43,22 @@ type Resolver func(ctx context.Context, params map[string]interface{}) (interfac
 // Middleware processes requests before they reach resolvers
 type Middleware func(ctx context.Context, next Resolver) Resolver
 
-// Subscription represents a real-time data subscription
+// Subscription represents a real-time data subscription. Its values are
+// published on the API's event bus as "api.<topic>", so subscribers of
+// every instance sharing a broker receive them.
 type Subscription struct {
         topic     string
-        clients   map[string]chan interface{}
+        api       *GoScaleAPI
+        clients   map[string]*subscriber
         mutex     sync.RWMutex
 }
 
+// subscriber is a client's channel and its subscription on the event bus
+type subscriber struct {
+        updates     chan interface{}
+        unsubscribe func()
+}
+
 // Metrics tracks API performance metrics
 type Metrics struct {
         RequestCount      int64
@@ -68,17 +85,24 @@ func NewGoScaleAPI(config *Config) *GoScaleAPI {
                 EnableNoCode: config.EnableNoCode,
         }
         
+        database := db.NewGoScaleDB(dbConfig)
+        bus := events.NewBus()
+        database.SetEventBus(bus)
+        
         return &GoScaleAPI{
                 resolvers:      make(map[string]Resolver),
+                exports:        make(map[string]*Export),
                 middlewares:    []Middleware{},
                 subscriptions:  make(map[string]*Subscription),
-                dbConnection:   db.NewGoScaleDB(dbConfig),
+                dbConnection:   database,
                 edgeEnabled:    config.EdgeEnabled,
                 edgeNodes:      config.EdgeNodes,
                 compressionLevel: config.CompressionLevel,
                 batchSize:      config.BatchSize,
                 timeout:        config.Timeout,
                 maxConcurrent:  config.MaxConcurrent,
+                events:         bus,
+                trustedProxies: config.TrustedProxies,
                 metrics:        &Metrics{
                         clients: make(map[string]chan interface{}),
                 },
@@ -98,6 +122,12 @@ type Config struct {
         EnableTimeSeries   bool
         EnableRelationships bool
         EnableNoCode       bool
+        
+        // TrustedProxies are the addresses or CIDR ranges of the app's own
+        // services, such as its edge nodes, whose requests carry who they
+        // are for in their X-Principal and X-Tenant-ID headers; see
+        // reqctx.Trusted. Other requests' headers are not believed.
+        TrustedProxies     []string
 }
 
 // DefaultConfig returns the default configuration
@@ -134,21 +164,42 @@ func (g *GoScaleAPI) CreateSubscription(topic string) *Subscription {
         
         sub := &Subscription{
                 topic:   topic,
-                clients: make(map[string]chan interface{}),
+                api:     g,
+                clients: make(map[string]*subscriber),
         }
         
         g.subscriptions[topic] = sub
         return sub
 }
 
-// Subscribe adds a client to a subscription
+// Subscribe adds a client to a subscription. Values published by other
+// instances arrive decoded from JSON, as json.RawMessage.
 func (s *Subscription) Subscribe(clientID string) chan interface{} {
         s.mutex.Lock()
         defer s.mutex.Unlock()
         
-        ch := make(chan interface{}, 100)
-        s.clients[clientID] = ch
-        return ch
+        if existing, ok := s.clients[clientID]; ok {
+                existing.unsubscribe()
+                close(existing.updates)
+        }
+        
+        client := &subscriber{updates: make(chan interface{}, 100)}
+        client.unsubscribe = s.api.events.Subscribe("api."+s.topic, func(ctx context.Context, event events.Event) {
+                s.mutex.RLock()
+                defer s.mutex.RUnlock()
+                
+                if s.clients[clientID] != client {
+                        return
+                }
+                select {
+                case client.updates <- event.Data:
+                        // Data sent successfully
+                default:
+                        // Channel buffer is full, skip this message
+                }
+        })
+        s.clients[clientID] = client
+        return client.updates
 }
 
 // Unsubscribe removes a client from a subscription
@@ -156,46 +207,65 @@ func (s *Subscription) Unsubscribe(clientID string) {
         s.mutex.Lock()
         defer s.mutex.Unlock()
         
-        if ch, ok := s.clients[clientID]; ok {
-                close(ch)
+        s.remove(clientID)
+}
+
+// remove closes a client's channel; the caller holds the mutex
+func (s *Subscription) remove(clientID string) {
+        if client, ok := s.clients[clientID]; ok {
+                client.unsubscribe()
+                close(client.updates)
                 delete(s.clients, clientID)
         }
 }
 
 // Publish sends data to all subscribers
 func (s *Subscription) Publish(data interface{}) {
-        s.mutex.RLock()
-        defer s.mutex.RUnlock()
-        
-        for _, ch := range s.clients {
-                select {
-                case ch <- data:
-                        // Data sent successfully
-                default:
-                        // Channel buffer is full, skip this message
-                }
-        }
+        s.api.events.Publish(context.Background(), "api."+s.topic, data)
 }
 
 // ServeHTTP implements the http.Handler interface
 func (g *GoScaleAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
         startTime := time.Now()
         
+        // Count the request and response sizes for the analytics
+        sent := &countingBody{ReadCloser: r.Body}
+        r.Body = sent
+        response := &countingWriter{ResponseWriter: w}
+        w = response
+        
         // Parse the request
         var request struct {
                 Query     string                 `json:"query"`
                 Variables map[string]interface{} `json:"variables"`
                 Operation string                 `json:"operation"`
+                Extensions struct {
+                        PersistedQuery *persistedQuery `json:"persistedQuery"`
+                } `json:"extensions"`
         }
         
-        if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+        body, err := requestBody(r)
+        if err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+        }
+        defer body.Close()
+        if err := json.NewDecoder(body).Decode(&request); err != nil {
                 http.Error(w, err.Error(), http.StatusBadRequest)
                 return
         }
         
-        // Create context with timeout
-        ctx, cancel := context.WithTimeout(r.Context(), g.timeout)
+        // Look up or remember a persisted query
+        if request.Query, err = g.persisted.resolve(request.Query, request.Extensions.PersistedQuery); err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+        }
+        
+        // Create context with timeout, carrying the request's ID and trace
+        // to the resolvers, and echo the ID for the client's logs
+        ctx, cancel := context.WithTimeout(reqctx.Ensure(reqctx.ExtractRequest(r, g.trustedProxies)), g.timeout)
         defer cancel()
+        w.Header().Set(reqctx.RequestIDHeader, reqctx.RequestID(ctx))
         
         // Apply middlewares
         var resolver Resolver
@@ -206,15 +276,22 @@ func (g *GoScaleAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
                 return
         }
         
+        // Record Jetpack's HTTP metrics per operation
+        core.SetRoute(r, "goscale:"+request.Operation)
+        
         for i := len(g.middlewares) - 1; i >= 0; i-- {
                 resolver = g.middlewares[i](ctx, resolver)
         }
         
-        // Execute the resolver
+        // Execute the resolver, masking personal data the caller may not see
         result, err := resolver(ctx, request.Variables)
+        if err == nil {
+                result, err = g.MaskResult(request.Operation, result, Role(ctx))
+        }
         if err != nil {
                 http.Error(w, err.Error(), http.StatusInternalServerError)
                 g.updateMetrics(startTime, false)
+                g.analytics.record(r, request.Operation, startTime, sent, response, true)
                 return
         }
         
@@ -225,6 +302,17 @@ func (g *GoScaleAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
         })
         
         g.updateMetrics(startTime, true)
+        g.analytics.record(r, request.Operation, startTime, sent, response, false)
+}
+
+// MaskResult masks the personal data in the result of an operation for a
+// caller with role, as the applied schema tags it. Without a schema the
+// result is returned as it is.
+func (g *GoScaleAPI) MaskResult(operation string, result interface{}, role string) (interface{}, error) {
+        if g.schema == nil {
+                return result, nil
+        }
+        return g.schema.MaskResult(operation, result, role)
 }
 
 // updateMetrics updates the API metrics
@@ -272,6 +360,10 @@ type Type struct {
         Fields      map[string]*Field
         Implements  []string
         Description string
+        
+        // Table is the table MigrateSchema stores the type in, the snake
+        // case of its name by default
+        Table       string
 }
 
 // Field represents a field in a type
@@ -281,6 +373,17 @@ type Field struct {
         Args        map[string]*Argument
         Resolver    Resolver
         Description string
+        
+        // Indexed and Unique have MigrateSchema index the field's column
+        Indexed     bool
+        Unique      bool
+        
+        // Masking tags the field as personal data, masked for callers whose
+        // role may not see it
+        Masking     *Masking
+        
+        // Export streams the rows of a list query to ExportHandler
+        Export      *Export
 }
 
 // Argument represents a field argument
@@ -385,6 +488,9 @@ func (g *GoScaleAPI) ApplySchema(schema *Schema) error {
                         return fmt.Errorf("query %s has no resolver", name)
                 }
                 g.RegisterResolver("query:"+name, field.Resolver)
+                if field.Export != nil {
+                        g.RegisterExport("query:"+name, field.Export)
+                }
         }
         
         // Register mutation resolvers
@@ -404,6 +510,12 @@ func (g *GoScaleAPI) ApplySchema(schema *Schema) error {
                 g.CreateSubscription(name)
         }
         
+        // Describe the schema to explorers such as the playground
+        g.schema = schema
+        g.RegisterResolver(introspectionOperation, func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+                return g.Introspect(), nil
+        })
+        
         return nil
 }
 
@@ -441,9 +553,8 @@ func (g *GoScaleAPI) Close() error {
         g.subMutex.Lock()
         for _, sub := range g.subscriptions {
                 sub.mutex.Lock()
-                for clientID, ch := range sub.clients {
-                        close(ch)
-                        delete(sub.clients, clientID)
+                for clientID := range sub.clients {
+                        sub.remove(clientID)
                 }
                 sub.mutex.Unlock()
         }
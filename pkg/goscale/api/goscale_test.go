@@ -0,0 +1,48 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/davidjeba/goscript/pkg/goscript/reqctx"
+)
+
+func TestTrustedProxies(t *testing.T) {
+	config := DefaultConfig()
+	config.TrustedProxies = []string{"10.0.0.0/8"}
+	api := NewGoScaleAPI(config)
+	api.RegisterResolver("query:whoami", func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		return []map[string]interface{}{{"principal": reqctx.Principal(ctx), "tenant": reqctx.Tenant(ctx)}}, nil
+	})
+
+	for remote, trusted := range map[string]bool{"10.1.2.3:4000": true, "203.0.113.9:4000": false} {
+		want := ""
+		if trusted {
+			want = "ada"
+		}
+		serve := func(handler http.Handler, r *http.Request) *httptest.ResponseRecorder {
+			r.RemoteAddr = remote
+			r.Header.Set(reqctx.PrincipalHeader, "ada")
+			r.Header.Set(reqctx.TenantHeader, "acme")
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, r)
+			return recorder
+		}
+
+		recorder := serve(api, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"operation":"query:whoami"}`)))
+		var response struct{ Data []map[string]string }
+		if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil || len(response.Data) != 1 || response.Data[0]["principal"] != want {
+			t.Fatalf("expected principal %q from %s, got %v (%v)", want, remote, response.Data, err)
+		}
+
+		recorder = serve(api.ExportHandler(), httptest.NewRequest(http.MethodGet, "/api/export/whoami.ndjson", nil))
+		var row map[string]string
+		if err := json.NewDecoder(recorder.Body).Decode(&row); err != nil || row["principal"] != want {
+			t.Fatalf("expected principal %q exported from %s, got %v (%v)", want, remote, row, err)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"sort"
+)
+
+// introspectionOperation is the query describing the applied schema.
+const introspectionOperation = "query:__schema"
+
+// SchemaDescription describes the applied schema, as the query "__schema"
+// returns it, for explorers and code generators.
+type SchemaDescription struct {
+	Types         []TypeDescription  `json:"types"`
+	Queries       []FieldDescription `json:"queries"`
+	Mutations     []FieldDescription `json:"mutations"`
+	Subscriptions []FieldDescription `json:"subscriptions"`
+}
+
+// TypeDescription describes a type of the schema.
+type TypeDescription struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Implements  []string           `json:"implements,omitempty"`
+	Fields      []FieldDescription `json:"fields"`
+}
+
+// FieldDescription describes a field of a type or an operation.
+type FieldDescription struct {
+	Name        string                `json:"name"`
+	Type        string                `json:"type"`
+	Description string                `json:"description,omitempty"`
+	Args        []ArgumentDescription `json:"args,omitempty"`
+
+	// Mask is the rule masking the field for callers whose role may not
+	// see it
+	Mask MaskRule `json:"mask,omitempty"`
+}
+
+// ArgumentDescription describes an argument of a field.
+type ArgumentDescription struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Description string      `json:"description,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+}
+
+// Introspect describes the schema ApplySchema applied, sorted by name, or
+// returns an empty description before it is applied.
+func (g *GoScaleAPI) Introspect() *SchemaDescription {
+	description := &SchemaDescription{
+		Types:         []TypeDescription{},
+		Queries:       []FieldDescription{},
+		Mutations:     []FieldDescription{},
+		Subscriptions: []FieldDescription{},
+	}
+	schema := g.schema
+	if schema == nil {
+		return description
+	}
+
+	for _, t := range schema.Types {
+		description.Types = append(description.Types, TypeDescription{
+			Name:        t.Name,
+			Description: t.Description,
+			Implements:  t.Implements,
+			Fields:      describeFields(t.Fields),
+		})
+	}
+	sort.Slice(description.Types, func(i, j int) bool {
+		return description.Types[i].Name < description.Types[j].Name
+	})
+	description.Queries = describeFields(schema.Queries)
+	description.Mutations = describeFields(schema.Mutations)
+	description.Subscriptions = describeFields(schema.Subscriptions)
+	return description
+}
+
+// describeFields describes fields sorted by name.
+func describeFields(fields map[string]*Field) []FieldDescription {
+	descriptions := []FieldDescription{}
+	for _, field := range fields {
+		description := FieldDescription{Name: field.Name, Type: field.Type, Description: field.Description}
+		if field.Masking != nil {
+			description.Mask = field.Masking.Rule
+		}
+		for _, arg := range field.Args {
+			description.Args = append(description.Args, ArgumentDescription{
+				Name:        arg.Name,
+				Type:        arg.Type,
+				Description: arg.Description,
+				Default:     arg.Default,
+			})
+		}
+		sort.Slice(description.Args, func(i, j int) bool {
+			return description.Args[i].Name < description.Args[j].Name
+		})
+		descriptions = append(descriptions, description)
+	}
+	sort.Slice(descriptions, func(i, j int) bool {
+		return descriptions[i].Name < descriptions[j].Name
+	})
+	return descriptions
+}
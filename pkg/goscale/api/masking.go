@@ -0,0 +1,296 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/davidjeba/goscript/pkg/goscript/naming"
+)
+
+// MaskRule is how a field holding personal data is masked for callers whose
+// role may not see it.
+type MaskRule string
+
+const (
+	// MaskHash replaces the value with the hex SHA-256 of it, so equal
+	// values can still be matched up.
+	MaskHash MaskRule = "hash"
+
+	// MaskPartial keeps the end of the value, or the first letter and
+	// domain of an email address, and stars out the rest.
+	MaskPartial MaskRule = "partial"
+
+	// MaskRemove leaves the field out.
+	MaskRemove MaskRule = "remove"
+)
+
+// Masking tags a field as personal data.
+type Masking struct {
+	Rule MaskRule
+
+	// Roles see the value unmasked; every other caller gets it masked.
+	Roles []string
+}
+
+// Mask tags the field as personal data, masked by rule for every caller
+// but those with one of roles.
+func (f *Field) Mask(rule MaskRule, roles ...string) *Field {
+	f.Masking = &Masking{Rule: rule, Roles: roles}
+	return f
+}
+
+// unmasked reports whether a caller with role sees the value unmasked.
+func (m *Masking) unmasked(role string) bool {
+	for _, allowed := range m.Roles {
+		if role != "" && role == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// roleKey is the context key of the caller's role.
+type roleKey struct{}
+
+// WithRole returns a context of a caller with role, such as "admin", which
+// decides the personal data the API answers it with unmasked. Middleware
+// authenticating requests sets it before they reach the API.
+func WithRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, roleKey{}, role)
+}
+
+// Role is the role of WithRole, or "" for callers without one.
+func Role(ctx context.Context) string {
+	role, _ := ctx.Value(roleKey{}).(string)
+	return role
+}
+
+// MaskResult masks the personal data in the result of an operation, such
+// as "query:user", for a caller with role, returning the result as JSON
+// values. Results of operations the schema does not declare are returned
+// as they are.
+func (s *Schema) MaskResult(operation string, result interface{}, role string) (interface{}, error) {
+	var fields map[string]*Field
+	kind := operation
+	name := ""
+	if i := strings.Index(operation, ":"); i >= 0 {
+		kind, name = operation[:i], operation[i+1:]
+	}
+	switch kind {
+	case "query":
+		fields = s.Queries
+	case "mutation":
+		fields = s.Mutations
+	case "subscription":
+		fields = s.Subscriptions
+	}
+	field, ok := fields[name]
+	if !ok {
+		return result, nil
+	}
+	return s.MaskValue(field.Type, result, role)
+}
+
+// MaskValue masks the personal data in a value of a field type, such as
+// "User" or "[User!]!", for a caller with role, returning it as JSON values.
+// The value is copied first, so a resolver's own data is left alone.
+func (s *Schema) MaskValue(fieldType string, value interface{}, role string) (interface{}, error) {
+	typeName := baseType(fieldType)
+	if !s.masks(typeName, map[string]bool{}) {
+		return value, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("masking %s: %v", typeName, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var copied interface{}
+	if err := decoder.Decode(&copied); err != nil {
+		return nil, fmt.Errorf("masking %s: %v", typeName, err)
+	}
+	return s.mask(typeName, copied, role), nil
+}
+
+// RedactAudit masks the personal data in a change audited for a component
+// named after a type, such as "User", and a setting named after one of its
+// fields. Every caller's mask applies, as for a caller without a role, so
+// the audit log never holds the data unmasked:
+//
+//	jp.Audit.Redact = schema.RedactAudit
+func (s *Schema) RedactAudit(component, setting string, value json.RawMessage) json.RawMessage {
+	t, ok := s.Types[component]
+	if !ok {
+		return value
+	}
+	field := t.field(setting)
+	if field == nil || (field.Masking == nil && !s.masks(baseType(field.Type), map[string]bool{})) {
+		return value
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+	var decoded interface{}
+	if err := decoder.Decode(&decoded); err != nil {
+		return json.RawMessage("null")
+	}
+	if decoded == nil {
+		return value
+	}
+	masked, kept := s.maskField(field, decoded, "")
+	if !kept {
+		return json.RawMessage("null")
+	}
+	data, err := json.Marshal(masked)
+	if err != nil {
+		return json.RawMessage("null")
+	}
+	return data
+}
+
+// MaskedColumns returns the columns, as Tables names them, of the fields
+// tagged as personal data, so the database's statement log redacts the
+// arguments bound to them:
+//
+//	database.SetQueryLog(db.QueryLogConfig{SampleRate: 0.01, Args: true, Redact: schema.MaskedColumns()})
+func (s *Schema) MaskedColumns() []string {
+	seen := make(map[string]bool)
+	var columns []string
+	for _, t := range s.Types {
+		for _, field := range t.Fields {
+			column := naming.SnakeCase(field.Name)
+			if field.Masking != nil && !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// masks reports whether values of a type hold fields to mask, directly or
+// in the types of its fields.
+func (s *Schema) masks(typeName string, seen map[string]bool) bool {
+	t, ok := s.Types[typeName]
+	if !ok || seen[typeName] {
+		return false
+	}
+	seen[typeName] = true
+	for _, field := range t.Fields {
+		if field.Masking != nil || s.masks(baseType(field.Type), seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// mask masks a JSON value of a type in place.
+func (s *Schema) mask(typeName string, value interface{}, role string) interface{} {
+	switch value := value.(type) {
+	case []interface{}:
+		for i, element := range value {
+			value[i] = s.mask(typeName, element, role)
+		}
+	case map[string]interface{}:
+		t, ok := s.Types[typeName]
+		if !ok {
+			return value
+		}
+		for key, fieldValue := range value {
+			field := t.field(key)
+			if field == nil {
+				continue
+			}
+			if masked, kept := s.maskField(field, fieldValue, role); kept {
+				value[key] = masked
+			} else {
+				delete(value, key)
+			}
+		}
+	}
+	return value
+}
+
+// maskField masks the JSON value of a field, reporting false if it is
+// removed instead.
+func (s *Schema) maskField(field *Field, value interface{}, role string) (interface{}, bool) {
+	if field.Masking == nil || field.Masking.unmasked(role) {
+		return s.mask(baseType(field.Type), value, role), true
+	}
+	if field.Masking.Rule != MaskHash && field.Masking.Rule != MaskPartial {
+		return nil, false
+	}
+	return maskScalars(field.Masking.Rule, value), true
+}
+
+// field returns the field of a JSON key, which is its name or, for rows
+// read from GoScaleDB, the snake case of it.
+func (t *Type) field(key string) *Field {
+	if field, ok := t.Fields[key]; ok {
+		return field
+	}
+	for _, field := range t.Fields {
+		if field.Name == key || naming.SnakeCase(field.Name) == key {
+			return field
+		}
+	}
+	return nil
+}
+
+// maskScalars hashes or partially masks a value, or each value of a list.
+// Values that are objects are masked whole.
+func maskScalars(rule MaskRule, value interface{}) interface{} {
+	var text string
+	switch value := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		for i, element := range value {
+			value[i] = maskScalars(rule, element)
+		}
+		return value
+	case string:
+		text = value
+	case map[string]interface{}:
+		data, _ := json.Marshal(value)
+		text = string(data)
+	default:
+		text = fmt.Sprint(value)
+	}
+
+	if rule == MaskHash {
+		sum := sha256.Sum256([]byte(text))
+		return hex.EncodeToString(sum[:])
+	}
+	return maskPartial(text)
+}
+
+// maskPartial stars out all but the first letter and domain of an email
+// address, or all but the last quarter of other text, up to 4 characters.
+func maskPartial(text string) string {
+	if at := strings.LastIndex(text, "@"); at > 0 {
+		local := []rune(text[:at])
+		return string(local[0]) + strings.Repeat("*", len(local)-1) + text[at:]
+	}
+
+	runes := []rune(text)
+	keep := len(runes) / 4
+	if keep > 4 {
+		keep = 4
+	}
+	return strings.Repeat("*", len(runes)-keep) + string(runes[len(runes)-keep:])
+}
+
+// baseType is the type of the values of a field type, such as "User" for
+// "[User!]!".
+func baseType(fieldType string) string {
+	return strings.Trim(fieldType, "[]!")
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"strings"
+
+	"github.com/davidjeba/goscript/pkg/goscale/db"
+	"github.com/davidjeba/goscript/pkg/goscript/naming"
+)
+
+// scalarColumnTypes are the column types of the schema's scalar types.
+var scalarColumnTypes = map[string]string{
+	"ID":       "text",
+	"String":   "text",
+	"Int":      "integer",
+	"BigInt":   "bigint",
+	"Float":    "double precision",
+	"Boolean":  "boolean",
+	"Time":     "timestamptz",
+	"DateTime": "timestamptz",
+	"JSON":     "jsonb",
+}
+
+// Index has MigrateSchema index the field's column, unique or not.
+func (f *Field) Index(unique bool) *Field {
+	f.Indexed = true
+	f.Unique = unique
+	return f
+}
+
+// Tables returns the GoScaleDB tables storing the schema's types in the
+// database schema name. Each type's scalar fields become columns, NOT NULL
+// for types ending in "!", and lists of scalars become jsonb columns. Fields
+// of object types are left to relationships. A type's "id" field, or else
+// its first of type ID, is its primary key.
+func (s *Schema) Tables(name string) *db.Schema {
+	schema := &db.Schema{Name: name, Tables: make(map[string]*db.Table), Version: 1}
+
+	for _, t := range s.Types {
+		tableName := t.Table
+		if tableName == "" {
+			tableName = naming.SnakeCase(t.Name)
+		}
+		table := &db.Table{
+			Name:    tableName,
+			Columns: make(map[string]*db.Column),
+			Indexes: make(map[string]*db.Index),
+		}
+
+		for _, field := range t.Fields {
+			columnType, nullable, ok := columnType(field.Type)
+			if !ok {
+				continue
+			}
+			column := &db.Column{Name: naming.SnakeCase(field.Name), Type: columnType, Nullable: nullable}
+			table.Columns[column.Name] = column
+
+			baseType := strings.TrimSuffix(field.Type, "!")
+			if column.Name == "id" || (baseType == "ID" && table.PrimaryKey == "") {
+				table.PrimaryKey = column.Name
+			}
+			if field.Indexed {
+				suffix := "_idx"
+				if field.Unique {
+					suffix = "_key"
+				}
+				index := &db.Index{Name: tableName + "_" + column.Name + suffix, Columns: []string{column.Name}, Unique: field.Unique}
+				table.Indexes[index.Name] = index
+			}
+		}
+
+		if len(table.Columns) > 0 {
+			if primaryKey := table.Columns[table.PrimaryKey]; primaryKey != nil {
+				primaryKey.Nullable = false
+			}
+			schema.Tables[tableName] = table
+		}
+	}
+	return schema
+}
+
+// columnType returns the column type of a field type, such as "Int!", and
+// whether it is nullable, or false for object types.
+func columnType(fieldType string) (string, bool, bool) {
+	nullable := !strings.HasSuffix(fieldType, "!")
+	fieldType = strings.TrimSuffix(fieldType, "!")
+
+	if strings.HasPrefix(fieldType, "[") && strings.HasSuffix(fieldType, "]") {
+		element := strings.TrimSuffix(strings.Trim(fieldType, "[]"), "!")
+		if _, ok := scalarColumnTypes[element]; !ok {
+			return "", false, false
+		}
+		return "jsonb", nullable, true
+	}
+	columnType, ok := scalarColumnTypes[fieldType]
+	return columnType, nullable, ok
+}
+
+// PlanSchemaMigration compares the tables of a schema, as Schema.Tables
+// returns them, with those in the database schema name, returning the DDL
+// that would bring the database in line without applying it.
+func (g *GoScaleAPI) PlanSchemaMigration(ctx context.Context, schema *Schema, name string) (*db.MigrationPlan, error) {
+	return g.dbConnection.PlanMigration(ctx, schema.Tables(name))
+}
+
+// MigrateSchema plans the migration of PlanSchemaMigration and applies it,
+// returning the plan it applied. Changes that could lose data are never
+// applied; they are listed in the plan's Skipped.
+func (g *GoScaleAPI) MigrateSchema(ctx context.Context, schema *Schema, name string) (*db.MigrationPlan, error) {
+	plan, err := g.PlanSchemaMigration(ctx, schema, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.dbConnection.ApplyMigration(ctx, plan); err != nil {
+		return plan, err
+	}
+	return plan, nil
+}
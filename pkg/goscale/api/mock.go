@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/davidjeba/goscript/pkg/goscale/fake"
+	"github.com/davidjeba/goscript/pkg/goscript/naming"
+)
+
+const (
+	// mockDepth is how deep Mock nests objects in its answers.
+	mockDepth = 3
+
+	// mockListLength is the length of the lists Mock answers with, unless
+	// a limit argument asks for another.
+	mockListLength = 10
+)
+
+// Mock replaces the resolvers of the schema's queries and mutations with
+// ones answering with fake data of their types, chosen by field names and
+// types as gopm db:seed --fake chooses rows, so clients and pages can be
+// built before the resolvers are. Answers are seeded by seed, the operation
+// and its arguments, so the same request gets the same answer. Lists are as
+// long as a limit argument asks, or 10, objects nest 3 deep, and arguments
+// named after fields of the result set them, so getUser(id: "1") answers
+// with the user 1 and createUser(name: "Ada") with Ada.
+func (s *Schema) Mock(seed int64) {
+	for name, field := range s.Queries {
+		field.Resolver = s.mockResolver(seed, "query:"+name, field)
+	}
+	for name, field := range s.Mutations {
+		field.Resolver = s.mockResolver(seed, "mutation:"+name, field)
+	}
+}
+
+// mockResolver returns a resolver answering an operation with fake data.
+func (s *Schema) mockResolver(seed int64, operation string, field *Field) Resolver {
+	return func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		hash := fnv.New64a()
+		hash.Write([]byte(operation))
+		if args, err := json.Marshal(params); err == nil {
+			hash.Write(args)
+		}
+
+		m := &mocker{schema: s, generator: fake.New(seed ^ int64(hash.Sum64())), tables: make(map[string]*fake.Table)}
+		length := mockListLength
+		if limit, ok := params["limit"].(int); ok && limit >= 0 {
+			length = limit
+		} else if limit, ok := params["limit"].(float64); ok && limit >= 0 {
+			length = int(limit)
+		}
+		return m.value(field.Name, field.Type, length, params, 0)
+	}
+}
+
+// mocker generates the fake answer to a request.
+type mocker struct {
+	schema    *Schema
+	generator *fake.Generator
+	tables    map[string]*fake.Table
+}
+
+// value generates a value of a field type: objects of the schema's types,
+// lists of length of them, or scalars.
+func (m *mocker) value(name, fieldType string, length int, fields map[string]interface{}, depth int) (interface{}, error) {
+	t := m.schema.Types[baseType(fieldType)]
+	if t == nil {
+		table := &fake.Table{Name: name, Fields: []fake.Field{{Name: name, Type: strings.TrimSuffix(fieldType, "!") + "!"}}}
+		row, err := m.generator.Row(table, 0)
+		if err != nil {
+			return nil, err
+		}
+		return row[name], nil
+	}
+
+	if !strings.HasPrefix(fieldType, "[") {
+		return m.object(t, 0, fields, depth)
+	}
+	items := make([]interface{}, length)
+	for i := range items {
+		item, err := m.object(t, i, nil, depth)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = item
+	}
+	return items, nil
+}
+
+// object generates an object of a type, the index'th of a list, with the
+// given fields set.
+func (m *mocker) object(t *Type, index int, fields map[string]interface{}, depth int) (map[string]interface{}, error) {
+	object, err := m.generator.Row(m.table(t), index)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range fields {
+		if t.Fields[name] != nil && value != nil {
+			object[name] = value
+		}
+	}
+	if depth+1 >= mockDepth {
+		return object, nil
+	}
+
+	for _, name := range sortedFields(t) {
+		field := t.Fields[name]
+		if _, _, ok := columnType(field.Type); ok || m.schema.Types[baseType(field.Type)] == nil {
+			continue
+		}
+		value, err := m.value(name, field.Type, 3, nil, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		object[name] = value
+	}
+	return object, nil
+}
+
+// table returns the table of fake rows of a type's scalar fields, keyed as
+// Tables keys the type's table.
+func (m *mocker) table(t *Type) *fake.Table {
+	if table := m.tables[t.Name]; table != nil {
+		return table
+	}
+
+	table := &fake.Table{Name: t.Table, Type: t.Name}
+	if table.Name == "" {
+		table.Name = naming.SnakeCase(t.Name)
+	}
+	for _, name := range sortedFields(t) {
+		field := t.Fields[name]
+		if _, _, ok := columnType(field.Type); !ok {
+			continue
+		}
+		if naming.SnakeCase(name) == "id" || (strings.TrimSuffix(field.Type, "!") == "ID" && table.Key == "") {
+			table.Key = name
+		}
+		table.Fields = append(table.Fields, fake.Field{Name: name, Type: field.Type})
+	}
+	m.tables[t.Name] = table
+	return table
+}
+
+// sortedFields returns the names of a type's fields in order, so the same
+// seed generates the same values.
+func sortedFields(t *Type) []string {
+	names := make([]string, 0, len(t.Fields))
+	for name := range t.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
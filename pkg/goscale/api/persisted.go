@@ -0,0 +1,71 @@
+package api
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// errPersistedQueryNotFound answers a persisted query hash the API does not
+// know, which has the client send the query along.
+var errPersistedQueryNotFound = errors.New("PersistedQueryNotFound")
+
+// persistedQueries holds the queries clients sent with their SHA-256 hash,
+// so later requests can send only the hash.
+type persistedQueries struct {
+	mutex   sync.RWMutex
+	queries map[string]string
+}
+
+// persistedQuery is the persisted query extension of a request.
+type persistedQuery struct {
+	Version    int    `json:"version"`
+	SHA256Hash string `json:"sha256Hash"`
+}
+
+// resolve returns the query of a request: the query it sent, which is
+// remembered under its hash, or the one remembered for the hash it sent.
+func (p *persistedQueries) resolve(query string, persisted *persistedQuery) (string, error) {
+	if persisted == nil {
+		return query, nil
+	}
+	hash := strings.ToLower(persisted.SHA256Hash)
+
+	if query == "" {
+		p.mutex.RLock()
+		defer p.mutex.RUnlock()
+
+		query, ok := p.queries[hash]
+		if !ok {
+			return "", errPersistedQueryNotFound
+		}
+		return query, nil
+	}
+
+	sum := sha256.Sum256([]byte(query))
+	if hex.EncodeToString(sum[:]) != hash {
+		return "", errors.New("persisted query hash does not match the query")
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.queries == nil {
+		p.queries = make(map[string]string)
+	}
+	p.queries[hash] = query
+	return query, nil
+}
+
+// requestBody returns the body of a request, decompressing one a client
+// such as pkg/goscale/client gzipped.
+func requestBody(r *http.Request) (io.ReadCloser, error) {
+	if !strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		return r.Body, nil
+	}
+	return gzip.NewReader(r.Body)
+}
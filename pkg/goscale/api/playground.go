@@ -0,0 +1,411 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+// PlaygroundConfig configures the explorer Playground serves.
+type PlaygroundConfig struct {
+	// Endpoint is the path the API is served at, such as "/api".
+	Endpoint string
+
+	// SubscriptionPath is the path ServeSubscription is mounted at,
+	// followed by the topic; "/subscriptions/" by default.
+	SubscriptionPath string
+
+	// Title is the page title; "GoScale Playground" by default.
+	Title string
+}
+
+// Playground serves an interactive explorer of the API, such as at
+// "/playground": it completes operations and their arguments from the
+// schema's introspection, keeps a history of the operations run in the
+// browser, edits their variables as JSON, and streams subscriptions over
+// WebSocket. Its inline script and style carry the CSP nonce of the
+// request's context, if any.
+func (g *GoScaleAPI) Playground(config PlaygroundConfig) http.Handler {
+	if config.Endpoint == "" {
+		config.Endpoint = "/"
+	}
+	if config.SubscriptionPath == "" {
+		config.SubscriptionPath = "/subscriptions/"
+	}
+	if config.Title == "" {
+		config.Title = "GoScale Playground"
+	}
+
+	// json.Marshal escapes <, > and &, so the settings are safe in a script
+	settings, _ := json.Marshal(map[string]string{
+		"endpoint":      config.Endpoint,
+		"subscriptions": strings.TrimSuffix(config.SubscriptionPath, "/") + "/",
+		"introspection": introspectionOperation,
+	})
+	page := strings.NewReplacer(
+		"{{title}}", htmlEscaper.Replace(config.Title),
+		"{{settings}}", string(settings),
+	).Replace(playgroundHTML)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Write([]byte(core.AddNonce(page, core.CSPNonce(r.Context()))))
+	})
+}
+
+var htmlEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;", "'", "&#39;")
+
+// playgroundHTML is the explorer page. It builds its elements with
+// textContent only, so nothing the API returns is parsed as markup.
+const playgroundHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{title}}</title>
+<style>
+*{box-sizing:border-box}
+body{margin:0;font:14px system-ui,sans-serif;color:#1f2328;display:grid;grid-template-columns:280px 1fr 1fr;grid-template-rows:48px 1fr;height:100vh}
+header{grid-column:1/4;display:flex;align-items:center;gap:12px;padding:0 16px;background:#24292f;color:#fff}
+header h1{font-size:16px;margin:0;flex:1}
+aside{overflow:auto;border-right:1px solid #d0d7de;padding:8px 12px;background:#f6f8fa}
+aside h2{font-size:12px;text-transform:uppercase;color:#57606a;margin:16px 0 4px}
+aside ul{list-style:none;margin:0;padding:0}
+aside li{padding:3px 4px;cursor:pointer;border-radius:4px;overflow:hidden;text-overflow:ellipsis;white-space:nowrap}
+aside li:hover{background:#eaeef2}
+aside .type{color:#8250df}
+aside .doc{font-size:12px;color:#57606a;white-space:normal}
+main,section{display:flex;flex-direction:column;padding:12px;gap:8px;min-height:0}
+section{border-left:1px solid #d0d7de}
+label{font-size:12px;font-weight:600;color:#57606a}
+.complete{position:relative}
+input,textarea{width:100%;font:13px ui-monospace,monospace;padding:8px;border:1px solid #d0d7de;border-radius:6px}
+textarea{flex:1;resize:none}
+textarea.invalid{border-color:#cf222e}
+#suggestions{position:absolute;top:100%;left:0;right:0;z-index:1;background:#fff;border:1px solid #d0d7de;border-radius:6px;margin:2px 0 0;padding:4px 0;list-style:none;max-height:260px;overflow:auto;box-shadow:0 4px 12px rgba(0,0,0,.12)}
+#suggestions li{padding:4px 8px;cursor:pointer}
+#suggestions li.active{background:#ddf4ff}
+#suggestions small{display:block;color:#57606a}
+.actions{display:flex;gap:8px;align-items:center}
+button{font:inherit;padding:6px 14px;border:0;border-radius:6px;background:#1f883d;color:#fff;cursor:pointer}
+button.secondary{background:#eaeef2;color:#1f2328}
+#status{font-size:12px;color:#57606a}
+pre{flex:1;margin:0;overflow:auto;background:#f6f8fa;border:1px solid #d0d7de;border-radius:6px;padding:8px;font:13px ui-monospace,monospace}
+</style>
+</head>
+<body>
+<header><h1>{{title}}</h1><span id="endpoint"></span></header>
+<aside>
+<h2>History</h2><ul id="history"></ul>
+<h2>Queries</h2><ul id="queries"></ul>
+<h2>Mutations</h2><ul id="mutations"></ul>
+<h2>Subscriptions</h2><ul id="subscriptions"></ul>
+<h2>Types</h2><ul id="types"></ul>
+</aside>
+<main>
+<label for="operation">Operation</label>
+<div class="complete">
+<input id="operation" autocomplete="off" spellcheck="false" placeholder="query:…">
+<ul id="suggestions" hidden></ul>
+</div>
+<p id="signature" class="doc"></p>
+<label for="variables">Variables</label>
+<textarea id="variables" spellcheck="false">{}</textarea>
+<div class="actions">
+<button id="run">Run</button>
+<button id="stop" class="secondary" hidden>Stop</button>
+<button id="fill" class="secondary">Fill arguments</button>
+<span id="status"></span>
+</div>
+</main>
+<section>
+<label>Response</label>
+<pre id="response"></pre>
+</section>
+<script>
+(function () {
+  var settings = {{settings}};
+  var historyKey = "goscale-playground:" + settings.endpoint;
+  var schema = {types: [], queries: [], mutations: [], subscriptions: []};
+  var operations = [];
+  var socket = null;
+
+  var $ = function (id) { return document.getElementById(id); };
+  var input = $("operation"), variables = $("variables"), suggestions = $("suggestions");
+  var response = $("response"), status = $("status");
+  $("endpoint").textContent = settings.endpoint;
+
+  function item(list, text, title, onclick) {
+    var li = document.createElement("li");
+    li.textContent = text;
+    if (title) li.title = title;
+    li.addEventListener("click", onclick);
+    list.appendChild(li);
+    return li;
+  }
+
+  function signature(op) {
+    var args = (op.args || []).map(function (arg) { return arg.name + ": " + arg.type; });
+    return op.kind + ":" + op.name + "(" + args.join(", ") + "): " + op.type;
+  }
+
+  function find(name) {
+    for (var i = 0; i < operations.length; i++) {
+      if (operations[i].kind + ":" + operations[i].name === name) return operations[i];
+    }
+    return null;
+  }
+
+  // placeholder is a sample value for an argument of a type
+  function placeholder(arg) {
+    if (arg.default !== undefined && arg.default !== null) return arg.default;
+    var type = arg.type.replace(/!$/, "");
+    if (type.charAt(0) === "[") return [];
+    switch (type) {
+    case "Int": case "BigInt": case "Float": return 0;
+    case "Boolean": return false;
+    case "JSON": return {};
+    default: return "";
+    }
+  }
+
+  // fill adds the operation's missing arguments to the variables
+  function fill(op) {
+    var current = {};
+    try { current = JSON.parse(variables.value || "{}"); } catch (e) {}
+    (op.args || []).forEach(function (arg) {
+      if (!(arg.name in current)) current[arg.name] = placeholder(arg);
+    });
+    variables.value = JSON.stringify(current, null, 2);
+    validate();
+  }
+
+  function choose(op) {
+    input.value = op.kind + ":" + op.name;
+    hideSuggestions();
+    describe();
+    variables.value = "{}";
+    fill(op);
+  }
+
+  function describe() {
+    var op = find(input.value.trim());
+    $("signature").textContent = op ? signature(op) + (op.description ? " — " + op.description : "") : "";
+  }
+
+  // Autocompletion of operations
+  var active = -1, matches = [];
+  function showSuggestions() {
+    var text = input.value.trim().toLowerCase();
+    matches = operations.filter(function (op) {
+      return (op.kind + ":" + op.name).toLowerCase().indexOf(text) >= 0;
+    }).slice(0, 30);
+    suggestions.textContent = "";
+    active = matches.length ? 0 : -1;
+    matches.forEach(function (op, i) {
+      var li = item(suggestions, op.kind + ":" + op.name, "", function () { choose(op); });
+      var small = document.createElement("small");
+      small.textContent = signature(op) + (op.description ? " — " + op.description : "");
+      li.appendChild(small);
+      if (i === active) li.className = "active";
+    });
+    suggestions.hidden = matches.length === 0;
+  }
+  function hideSuggestions() { suggestions.hidden = true; active = -1; }
+  function highlight(next) {
+    var items = suggestions.children;
+    if (!items.length) return;
+    if (active >= 0) items[active].className = "";
+    active = (next + items.length) % items.length;
+    items[active].className = "active";
+    items[active].scrollIntoView({block: "nearest"});
+  }
+
+  input.addEventListener("input", function () { showSuggestions(); describe(); });
+  input.addEventListener("focus", showSuggestions);
+  input.addEventListener("blur", function () { setTimeout(hideSuggestions, 150); });
+  input.addEventListener("keydown", function (e) {
+    if (e.key === " " && e.ctrlKey) { e.preventDefault(); showSuggestions(); return; }
+    if (suggestions.hidden) {
+      if (e.key === "Enter") run();
+      return;
+    }
+    if (e.key === "ArrowDown") { e.preventDefault(); highlight(active + 1); }
+    else if (e.key === "ArrowUp") { e.preventDefault(); highlight(active - 1); }
+    else if ((e.key === "Enter" || e.key === "Tab") && active >= 0) { e.preventDefault(); choose(matches[active]); }
+    else if (e.key === "Escape") hideSuggestions();
+  });
+
+  // The variables editor checks its JSON as it is typed
+  function validate() {
+    try {
+      JSON.parse(variables.value || "{}");
+      variables.className = "";
+      return true;
+    } catch (e) {
+      variables.className = "invalid";
+      return false;
+    }
+  }
+  variables.addEventListener("input", validate);
+  variables.addEventListener("keydown", function (e) {
+    if (e.key === "Enter" && (e.ctrlKey || e.metaKey)) { e.preventDefault(); run(); }
+    if (e.key === " " && e.ctrlKey) {
+      e.preventDefault();
+      var op = find(input.value.trim());
+      if (op) fill(op);
+    }
+  });
+  $("fill").addEventListener("click", function () {
+    var op = find(input.value.trim());
+    if (op) fill(op);
+  });
+
+  // History is kept in the browser, newest first
+  function loadHistory() {
+    try { return JSON.parse(localStorage.getItem(historyKey) || "[]"); } catch (e) { return []; }
+  }
+  function remember(operation, vars) {
+    var entries = loadHistory().filter(function (entry) {
+      return entry.operation !== operation || entry.variables !== vars;
+    });
+    entries.unshift({operation: operation, variables: vars, at: new Date().toISOString()});
+    try { localStorage.setItem(historyKey, JSON.stringify(entries.slice(0, 50))); } catch (e) {}
+    renderHistory();
+  }
+  function renderHistory() {
+    var list = $("history");
+    list.textContent = "";
+    loadHistory().forEach(function (entry) {
+      item(list, entry.operation, entry.at + "\n" + entry.variables, function () {
+        input.value = entry.operation;
+        variables.value = entry.variables;
+        validate();
+        describe();
+      });
+    });
+    if (!list.children.length) {
+      var li = document.createElement("li");
+      li.className = "doc";
+      li.textContent = "Operations you run appear here";
+      list.appendChild(li);
+    }
+  }
+
+  function show(text, state) {
+    response.textContent = text;
+    status.textContent = state;
+  }
+
+  function stop() {
+    if (socket) socket.close();
+    socket = null;
+    $("stop").hidden = true;
+  }
+  $("stop").addEventListener("click", stop);
+
+  function subscribe(topic) {
+    var url = (location.protocol === "https:" ? "wss://" : "ws://") + location.host + settings.subscriptions + encodeURIComponent(topic);
+    var received = [];
+    socket = new WebSocket(url);
+    $("stop").hidden = false;
+    show("", "Connecting to " + topic + "…");
+    socket.onopen = function () { status.textContent = "Subscribed to " + topic; };
+    socket.onmessage = function (event) {
+      var text = event.data;
+      try { text = JSON.stringify(JSON.parse(event.data), null, 2); } catch (e) {}
+      received.unshift(new Date().toLocaleTimeString() + "\n" + text);
+      response.textContent = received.slice(0, 100).join("\n\n");
+      status.textContent = received.length + " updates from " + topic;
+    };
+    socket.onclose = function (event) {
+      status.textContent = "Subscription closed" + (event.reason ? ": " + event.reason : "");
+      $("stop").hidden = true;
+    };
+  }
+
+  function run() {
+    var operation = input.value.trim();
+    if (!operation || !validate()) return;
+    var vars = variables.value.trim() || "{}";
+    remember(operation, vars);
+    stop();
+
+    if (operation.indexOf("subscription:") === 0) {
+      subscribe(operation.slice("subscription:".length));
+      return;
+    }
+
+    var started = performance.now();
+    show("", "Running…");
+    fetch(settings.endpoint, {
+      method: "POST",
+      headers: {"Content-Type": "application/json"},
+      body: JSON.stringify({operation: operation, variables: JSON.parse(vars)})
+    }).then(function (res) {
+      return res.text().then(function (body) {
+        var text = body;
+        try { text = JSON.stringify(JSON.parse(body), null, 2); } catch (e) {}
+        show(text, res.status + " " + res.statusText + " in " + Math.round(performance.now() - started) + "ms");
+      });
+    }).catch(function (err) {
+      show(String(err), "Request failed");
+    });
+  }
+  $("run").addEventListener("click", run);
+
+  // Load the schema for the docs and completions
+  function renderSchema() {
+    [["queries", "query"], ["mutations", "mutation"], ["subscriptions", "subscription"]].forEach(function (group) {
+      var list = $(group[0]);
+      list.textContent = "";
+      (schema[group[0]] || []).forEach(function (op) {
+        op.kind = group[1];
+        operations.push(op);
+        item(list, op.name, signature(op) + (op.description ? "\n" + op.description : ""), function () { choose(op); });
+      });
+    });
+    var types = $("types");
+    types.textContent = "";
+    (schema.types || []).forEach(function (type) {
+      var li = item(types, type.name, type.description || "", function () {
+        var doc = li.querySelector(".doc");
+        if (doc) { li.removeChild(doc); return; }
+        doc = document.createElement("div");
+        doc.className = "doc";
+        doc.textContent = (type.fields || []).map(function (field) { return field.name + ": " + field.type; }).join("\n");
+        doc.style.whiteSpace = "pre";
+        li.appendChild(doc);
+      });
+      li.className = "type";
+    });
+  }
+
+  fetch(settings.endpoint, {
+    method: "POST",
+    headers: {"Content-Type": "application/json"},
+    body: JSON.stringify({operation: settings.introspection})
+  }).then(function (res) {
+    if (!res.ok) throw new Error(res.status + " " + res.statusText);
+    return res.json();
+  }).then(function (body) {
+    schema = body.data || schema;
+    renderSchema();
+    status.textContent = operations.length + " operations";
+  }).catch(function (err) {
+    status.textContent = "Could not load the schema: " + err.message;
+  });
+  renderHistory();
+})();
+</script>
+</body>
+</html>
+`
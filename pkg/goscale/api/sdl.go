@@ -0,0 +1,93 @@
+package api
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/davidjeba/goscript/pkg/goscale/sdl"
+)
+
+// ParseSchema parses a schema written in GraphQL SDL, such as an embedded
+// schema.graphql. Object types become the schema's types and the fields of
+// the query, mutation and subscription types its operations, with no
+// resolvers yet. Directives set how types are stored: @table(name: "...")
+// on a type, and @index or @index(unique: true) on a field. Enums and custom
+// scalars are typed String, so they are stored as text.
+func ParseSchema(source string) (*Schema, error) {
+	doc, err := sdl.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := NewSchema()
+	operations := map[string]func(name, typeName, description string) *Field{
+		"query":        schema.AddQuery,
+		"mutation":     schema.AddMutation,
+		"subscription": schema.AddSubscription,
+	}
+	for operation, root := range doc.Operations {
+		for _, field := range doc.Definition(root).Fields {
+			f := operations[operation](field.Name, schemaType(doc, field.Type), field.Description)
+			addArgs(doc, f, field.Args)
+		}
+	}
+
+	for _, definition := range doc.Definitions {
+		if definition.Kind != sdl.KindType || doc.IsOperationType(definition.Name) {
+			continue
+		}
+		t := schema.AddType(definition.Name, definition.Description)
+		t.Implements = definition.Interfaces
+		if table := definition.Directive("table"); table != nil {
+			t.Table = table.Args["name"]
+		}
+		for _, field := range definition.Fields {
+			f := t.AddField(field.Name, schemaType(doc, field.Type), field.Description)
+			addArgs(doc, f, field.Args)
+			if index := field.Directive("index"); index != nil {
+				f.Index(index.Args["unique"] == "true")
+			}
+		}
+	}
+	return schema, nil
+}
+
+// addArgs adds the arguments of a parsed field.
+func addArgs(doc *sdl.Document, f *Field, args []*sdl.Field) {
+	for _, arg := range args {
+		f.AddArg(arg.Name, schemaType(doc, arg.Type), defaultValue(arg.Default), arg.Description)
+	}
+}
+
+// schemaType returns the type of a field, with enums and custom scalars
+// replaced by String.
+func schemaType(doc *sdl.Document, typeName string) string {
+	named := sdl.NamedType(typeName)
+	if definition := doc.Definition(named); definition != nil &&
+		(definition.Kind == sdl.KindEnum || definition.Kind == sdl.KindScalar) {
+		return strings.Replace(typeName, named, "String", 1)
+	}
+	return typeName
+}
+
+// defaultValue converts the source text of a default value to a bool,
+// number, nil or string.
+func defaultValue(text string) interface{} {
+	switch text {
+	case "":
+		return nil
+	case "null":
+		return nil
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if n, err := strconv.Atoi(text); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(text, 64); err == nil {
+		return f
+	}
+	return text
+}
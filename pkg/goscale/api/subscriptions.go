@@ -0,0 +1,73 @@
+package api
+
+import (
+	"github.com/davidjeba/goscript/pkg/goscript"
+	"github.com/davidjeba/goscript/pkg/goscript/events"
+)
+
+// SetEventBus makes the API publish subscription values and the changes of
+// its database on bus, which may be shared with the rest of the app and,
+// through a broker, with other instances. Call it before clients subscribe.
+func (g *GoScaleAPI) SetEventBus(bus *events.Bus) {
+	g.events = bus
+	g.dbConnection.SetEventBus(bus)
+}
+
+// EventBus returns the bus subscription values are published on
+func (g *GoScaleAPI) EventBus() *events.Bus {
+	return g.events
+}
+
+// Subscription returns a subscription topic created with CreateSubscription,
+// or nil.
+func (g *GoScaleAPI) Subscription(topic string) *Subscription {
+	g.subMutex.RLock()
+	defer g.subMutex.RUnlock()
+
+	return g.subscriptions[topic]
+}
+
+// ServeSubscription streams the topic named by the "topic" route parameter
+// to a WebSocket client, each published value as a JSON message, until
+// either side closes. Personal data is masked for the role of the upgrade
+// request's context. Mount it on a goscript router:
+//
+//	router.WS("/subscriptions/:topic", goscaleAPI.ServeSubscription)
+func (g *GoScaleAPI) ServeSubscription(ws *goscript.WebSocket, params map[string]string) {
+	subscription := g.Subscription(params["topic"])
+	if subscription == nil {
+		ws.CloseWithReason(goscript.ClosePolicyViolation, "unknown topic")
+		return
+	}
+
+	updates := subscription.Subscribe(ws.ID)
+	defer subscription.Unsubscribe(ws.ID)
+
+	// Reading handles pings and notices when the client leaves
+	go func() {
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				ws.Close()
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ws.Context().Done():
+			return
+		case data, ok := <-updates:
+			if !ok {
+				return
+			}
+			data, err := g.MaskResult("subscription:"+params["topic"], data, Role(ws.Context()))
+			if err != nil {
+				return
+			}
+			if err := ws.WriteJSON(data); err != nil {
+				return
+			}
+		}
+	}
+}
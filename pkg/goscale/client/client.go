@@ -0,0 +1,293 @@
+// Package client calls a GoScaleAPI from Go: queries and mutations over
+// HTTP and subscriptions over WebSocket, retrying failed requests on the
+// next endpoint, so services need not build request JSON by hand.
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/reqctx"
+)
+
+// persistedQueryNotFound is the error a GoScaleAPI answers a persisted
+// query hash it does not know with.
+const persistedQueryNotFound = "PersistedQueryNotFound"
+
+var errNoEndpoints = errors.New("goscale: no endpoints")
+
+// Config configures a Client.
+type Config struct {
+	// Endpoints are the URLs the API is served at, such as the origin
+	// followed by its edge nodes. Requests go to the first that works,
+	// which the client sticks to until it fails.
+	Endpoints []string
+
+	// SubscriptionPath is the path below an endpoint's host that
+	// GoScaleAPI.ServeSubscription is mounted at, followed by the topic;
+	// "/subscriptions/" by default.
+	SubscriptionPath string
+
+	// HTTPClient sends the requests; http.DefaultClient by default.
+	HTTPClient *http.Client
+
+	// Header is sent with every request, such as an Authorization header.
+	Header http.Header
+
+	// Retries is how many times a failed request is tried again, each time
+	// on the next endpoint; 2 by default, and negative never.
+	Retries int
+
+	// Backoff is the wait before the first retry, doubling for each one
+	// after; 100ms by default.
+	Backoff time.Duration
+
+	// MinCompressSize is the size from which request bodies are gzipped;
+	// 1KB by default, and negative never. Responses are decompressed
+	// whenever the server compresses them.
+	MinCompressSize int
+
+	// PersistedQueries sends the SHA-256 hash of a request's query instead
+	// of the query, which the server remembers after the first time.
+	PersistedQueries bool
+}
+
+// Client calls a GoScaleAPI. It is safe for concurrent use.
+type Client struct {
+	config Config
+
+	mutex   sync.Mutex
+	current int
+}
+
+// Request is an operation to call, such as "query:user", and its variables.
+// Query is the operation's query document, if it has one, which
+// Config.PersistedQueries sends as its hash.
+type Request struct {
+	Operation string
+	Query     string
+	Variables map[string]interface{}
+}
+
+// Error is an error response from the API.
+type Error struct {
+	Endpoint   string
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("goscale: %s: %d %s", e.Endpoint, e.StatusCode, e.Message)
+}
+
+// Temporary reports whether the request may succeed when tried again, as
+// for overloaded or unavailable servers.
+func (e *Error) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// New creates a client for the API served at endpoints.
+func New(endpoints ...string) *Client {
+	return NewWithConfig(Config{Endpoints: endpoints})
+}
+
+// NewWithConfig creates a client with a config.
+func NewWithConfig(config Config) *Client {
+	if config.SubscriptionPath == "" {
+		config.SubscriptionPath = "/subscriptions/"
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = http.DefaultClient
+	}
+	if config.Retries == 0 {
+		config.Retries = 2
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = 100 * time.Millisecond
+	}
+	if config.MinCompressSize == 0 {
+		config.MinCompressSize = 1 << 10
+	}
+	return &Client{config: config}
+}
+
+// Query calls the query name, decoding its data into out unless out is nil.
+func (c *Client) Query(ctx context.Context, name string, variables map[string]interface{}, out interface{}) error {
+	return c.Do(ctx, &Request{Operation: "query:" + name, Variables: variables}, out)
+}
+
+// Mutate calls the mutation name, decoding its data into out unless out is
+// nil. Mutations are only retried when they fail to reach a server, as one
+// that failed there may have changed data already.
+func (c *Client) Mutate(ctx context.Context, name string, variables map[string]interface{}, out interface{}) error {
+	return c.Do(ctx, &Request{Operation: "mutation:" + name, Variables: variables}, out)
+}
+
+// Do calls an operation, decoding its data into out unless out is nil.
+func (c *Client) Do(ctx context.Context, request *Request, out interface{}) error {
+	if len(c.config.Endpoints) == 0 {
+		return errNoEndpoints
+	}
+	mutation := strings.HasPrefix(request.Operation, "mutation:")
+
+	backoff := c.config.Backoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		endpoint := c.endpoint()
+		var data json.RawMessage
+		data, err = c.send(ctx, endpoint, request)
+		if err == nil {
+			if out == nil || len(data) == 0 {
+				return nil
+			}
+			return json.Unmarshal(data, out)
+		}
+
+		if !retryable(err, mutation) || attempt >= c.config.Retries || ctx.Err() != nil {
+			return err
+		}
+		c.failed(endpoint)
+
+		if !sleep(ctx, backoff) {
+			return err
+		}
+		backoff *= 2
+	}
+}
+
+// retryable reports whether a failed request is tried again: any that did
+// not reach a server, and queries the server could not answer for now.
+func retryable(err error, mutation bool) bool {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	if mutation {
+		return false
+	}
+	return apiErr.Temporary() || apiErr.StatusCode == http.StatusInternalServerError
+}
+
+// endpoint is the endpoint requests currently go to.
+func (c *Client) endpoint() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.config.Endpoints[c.current]
+}
+
+// failed moves requests on to the endpoint after one that failed, unless
+// another request has already.
+func (c *Client) failed(endpoint string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.config.Endpoints[c.current] == endpoint {
+		c.current = (c.current + 1) % len(c.config.Endpoints)
+	}
+}
+
+// wireRequest is a request as it is posted.
+type wireRequest struct {
+	Operation  string                 `json:"operation"`
+	Query      string                 `json:"query,omitempty"`
+	Variables  map[string]interface{} `json:"variables,omitempty"`
+	Extensions *extensions            `json:"extensions,omitempty"`
+}
+
+type extensions struct {
+	PersistedQuery *persistedQuery `json:"persistedQuery,omitempty"`
+}
+
+type persistedQuery struct {
+	Version    int    `json:"version"`
+	SHA256Hash string `json:"sha256Hash"`
+}
+
+// send posts a request to an endpoint, returning its data. A persisted
+// query the server does not know is sent again with its query.
+func (c *Client) send(ctx context.Context, endpoint string, request *Request) (json.RawMessage, error) {
+	wire := &wireRequest{Operation: request.Operation, Query: request.Query, Variables: request.Variables}
+	if !c.config.PersistedQueries || request.Query == "" {
+		return c.post(ctx, endpoint, wire)
+	}
+
+	sum := sha256.Sum256([]byte(request.Query))
+	wire.Query = ""
+	wire.Extensions = &extensions{PersistedQuery: &persistedQuery{Version: 1, SHA256Hash: hex.EncodeToString(sum[:])}}
+	data, err := c.post(ctx, endpoint, wire)
+	var apiErr *Error
+	if errors.As(err, &apiErr) && apiErr.Message == persistedQueryNotFound {
+		// Sending the query along has the server remember it
+		wire.Query = request.Query
+		return c.post(ctx, endpoint, wire)
+	}
+	return data, err
+}
+
+// post sends a request, gzipping large bodies, and returns the data of the
+// response.
+func (c *Client) post(ctx context.Context, endpoint string, wire *wireRequest) (json.RawMessage, error) {
+	body, err := json.Marshal(wire)
+	if err != nil {
+		return nil, err
+	}
+	compressed := c.config.MinCompressSize > 0 && len(body) >= c.config.MinCompressSize
+	if compressed {
+		var buffer bytes.Buffer
+		writer := gzip.NewWriter(&buffer)
+		writer.Write(body)
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		body = buffer.Bytes()
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range c.config.Header {
+		httpRequest.Header[name] = values
+	}
+	// The API logs the call as part of the request ctx was made for
+	reqctx.Inject(ctx, httpRequest.Header)
+	httpRequest.Header.Set("Content-Type", "application/json")
+	if compressed {
+		httpRequest.Header.Set("Content-Encoding", "gzip")
+	}
+
+	response, err := c.config.HTTPClient.Do(httpRequest)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		message, _ := ioutil.ReadAll(io.LimitReader(response.Body, 4<<10))
+		return nil, &Error{Endpoint: endpoint, StatusCode: response.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+	var envelope struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("goscale: %s: decoding response: %v", endpoint, err)
+	}
+	return envelope.Data, nil
+}
@@ -0,0 +1,203 @@
+package client
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript"
+	"github.com/davidjeba/goscript/pkg/goscript/reqctx"
+)
+
+// apiServer answers requests as GoScaleAPI does, echoing each operation and
+// its variables, and remembering persisted queries.
+type apiServer struct {
+	mutex      sync.Mutex
+	persisted  map[string]string
+	requests   []map[string]interface{}
+	compressed int
+	status     int
+}
+
+func (s *apiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.status != 0 {
+		http.Error(w, http.StatusText(s.status), s.status)
+		return
+	}
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		s.compressed++
+		body, _ = gzip.NewReader(r.Body)
+	}
+	var request map[string]interface{}
+	if err := json.NewDecoder(body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.requests = append(s.requests, request)
+
+	if extensions, ok := request["extensions"].(map[string]interface{}); ok {
+		hash := extensions["persistedQuery"].(map[string]interface{})["sha256Hash"].(string)
+		if query, ok := request["query"].(string); ok {
+			s.persisted[hash] = query
+		} else if _, ok := s.persisted[hash]; !ok {
+			http.Error(w, persistedQueryNotFound, http.StatusBadRequest)
+			return
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": map[string]interface{}{"operation": request["operation"], "variables": request["variables"]},
+	})
+}
+
+type echo struct {
+	Operation string                 `json:"operation"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+func TestClientQueryAndFailover(t *testing.T) {
+	down := &apiServer{status: http.StatusServiceUnavailable}
+	origin := httptest.NewServer(down)
+	defer origin.Close()
+	edge := &apiServer{persisted: map[string]string{}}
+	edgeServer := httptest.NewServer(edge)
+	defer edgeServer.Close()
+
+	client := NewWithConfig(Config{Endpoints: []string{origin.URL, edgeServer.URL}, Backoff: time.Millisecond})
+	var result echo
+	if err := client.Query(context.Background(), "user", map[string]interface{}{"id": "7"}, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Operation != "query:user" || result.Variables["id"] != "7" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if client.endpoint() != edgeServer.URL {
+		t.Fatalf("expected the client to stick to the edge node, got %s", client.endpoint())
+	}
+
+	// Mutations that reach a failing server are not tried again
+	edge.status = http.StatusInternalServerError
+	err := client.Mutate(context.Background(), "createUser", nil, nil)
+	if apiErr, ok := err.(*Error); !ok || apiErr.StatusCode != http.StatusInternalServerError || apiErr.Endpoint != edgeServer.URL {
+		t.Fatalf("expected the edge node's error, got %v", err)
+	}
+
+	// Queries are, on the next endpoint
+	down.status = 0
+	down.persisted = map[string]string{}
+	if err := client.Query(context.Background(), "user", nil, &result); err != nil {
+		t.Fatalf("expected the query to fail over to the origin, got %v", err)
+	}
+}
+
+func TestClientSendsRequestContext(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		w.Write([]byte(`{"data":{}}`))
+	}))
+	defer server.Close()
+
+	ctx := reqctx.Ensure(reqctx.With(context.Background(), reqctx.Info{RequestID: "req-1", Principal: "ada", Tenant: "acme"}))
+	if err := New(server.URL).Mutate(ctx, "createPost", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	received := reqctx.ExtractTrusted(context.Background(), header)
+	if info := reqctx.From(received); info.RequestID != "req-1" || info.Principal != "ada" || info.Tenant != "acme" || info.TraceID != reqctx.TraceID(ctx) {
+		t.Fatalf("expected the request context sent, got %+v from %v", info, header)
+	}
+}
+
+func TestClientCompressionAndPersistedQueries(t *testing.T) {
+	api := &apiServer{persisted: map[string]string{}}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	client := NewWithConfig(Config{Endpoints: []string{server.URL}, PersistedQueries: true, MinCompressSize: 64})
+	request := &Request{
+		Operation: "query:posts",
+		Query:     "query posts($tag: String) { posts(tag: $tag) { id title } }",
+		Variables: map[string]interface{}{"tag": strings.Repeat("go", 40)},
+	}
+	for i := 0; i < 2; i++ {
+		var result echo
+		if err := client.Do(context.Background(), request, &result); err != nil {
+			t.Fatal(err)
+		}
+		if result.Operation != "query:posts" {
+			t.Fatalf("unexpected result %+v", result)
+		}
+	}
+
+	// The hash alone, then with the query, then the hash alone again
+	if len(api.requests) != 3 {
+		t.Fatalf("expected 3 requests, got %v", api.requests)
+	}
+	for i, sent := range []bool{false, true, false} {
+		if _, ok := api.requests[i]["query"]; ok != sent {
+			t.Fatalf("request %d: expected query sent %v, got %v", i, sent, api.requests[i])
+		}
+	}
+	if api.compressed != 3 {
+		t.Fatalf("expected every request to be gzipped, got %d", api.compressed)
+	}
+}
+
+func TestClientSubscribe(t *testing.T) {
+	hub := goscript.NewWebSocketHub()
+	defer hub.Close()
+
+	router := goscript.NewRouter()
+	router.WS("/subscriptions/:topic", func(ws *goscript.WebSocket, params map[string]string) {
+		if params["topic"] != "posts" {
+			ws.CloseWithReason(goscript.ClosePolicyViolation, "unknown topic")
+			return
+		}
+		hub.Join(ws, "posts")
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	client := New(server.URL + "/api")
+	subscription, err := client.Subscribe(context.Background(), "posts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for hub.Count("posts") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	hub.BroadcastJSON("posts", map[string]string{"title": "Hello"})
+
+	var post map[string]string
+	if err := subscription.Next(&post); err != nil || post["title"] != "Hello" {
+		t.Fatalf("unexpected update %v %v", post, err)
+	}
+	subscription.Close()
+	if err := subscription.Next(&post); err != context.Canceled {
+		t.Fatalf("expected the closed subscription to end, got %v", err)
+	}
+
+	unknown, err := client.Subscribe(context.Background(), "missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = unknown.Next(&post)
+	if closeErr, ok := err.(*CloseError); !ok || closeErr.Code != goscript.ClosePolicyViolation || closeErr.Reason != "unknown topic" {
+		t.Fatalf("expected the server to end the subscription, got %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxBackoff caps the wait between reconnects of a subscription.
+const maxBackoff = 30 * time.Second
+
+// Subscription receives the values published to a topic. When its
+// connection drops it reconnects, failing over to the next endpoint, and
+// values published in between are missed.
+type Subscription struct {
+	// Topic is the topic subscribed to.
+	Topic string
+
+	updates chan json.RawMessage
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mutex sync.Mutex
+	conn  *wsConn
+	err   error
+}
+
+// Subscribe subscribes to a topic, such as one of a schema's subscriptions,
+// until ctx is done or the subscription is closed.
+func (c *Client) Subscribe(ctx context.Context, topic string) (*Subscription, error) {
+	conn, err := c.dialTopic(ctx, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Subscription{
+		Topic:   topic,
+		updates: make(chan json.RawMessage, 64),
+		ctx:     ctx,
+		cancel:  cancel,
+		conn:    conn,
+	}
+	go s.run(c)
+	return s, nil
+}
+
+// dialTopic connects to a topic, trying the endpoints in turn as Do does.
+func (c *Client) dialTopic(ctx context.Context, topic string) (*wsConn, error) {
+	if len(c.config.Endpoints) == 0 {
+		return nil, errNoEndpoints
+	}
+
+	backoff := c.config.Backoff
+	for attempt := 0; ; attempt++ {
+		endpoint := c.endpoint()
+		conn, err := dialWebSocket(ctx, c.topicURL(endpoint, topic), c.config.Header)
+		if err == nil {
+			return conn, nil
+		}
+		if attempt >= c.config.Retries || ctx.Err() != nil {
+			return nil, err
+		}
+		c.failed(endpoint)
+		if !sleep(ctx, backoff) {
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
+
+// topicURL is the WebSocket URL of a topic on the host of an endpoint.
+func (c *Client) topicURL(endpoint, topic string) string {
+	target, err := url.Parse(endpoint)
+	if err != nil {
+		return endpoint
+	}
+	if target.Scheme == "https" {
+		target.Scheme = "wss"
+	} else {
+		target.Scheme = "ws"
+	}
+	target.Path = strings.TrimSuffix(c.config.SubscriptionPath, "/") + "/" + url.PathEscape(topic)
+	target.RawPath = ""
+	target.RawQuery = ""
+	return target.String()
+}
+
+// Updates delivers the values published to the topic, as JSON. It is closed
+// once the subscription ends, after which Err says why.
+func (s *Subscription) Updates() <-chan json.RawMessage {
+	return s.updates
+}
+
+// Next decodes the next value published to the topic into v, returning
+// Err once the subscription has ended.
+func (s *Subscription) Next(v interface{}) error {
+	data, ok := <-s.updates
+	if !ok {
+		return s.Err()
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Err is why the subscription ended: nil while it runs, the context's error
+// once closed, or a *CloseError when the server ended it, such as for an
+// unknown topic.
+func (s *Subscription) Err() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.err
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() error {
+	s.cancel()
+	return nil
+}
+
+// run reads updates, reconnecting until the subscription ends.
+func (s *Subscription) run(c *Client) {
+	defer close(s.updates)
+
+	// Closing the connection unblocks the read when the subscription ends
+	go func() {
+		<-s.ctx.Done()
+		s.mutex.Lock()
+		if s.conn != nil {
+			s.conn.close()
+		}
+		s.mutex.Unlock()
+	}()
+
+	conn := s.conn
+	for {
+		err := s.read(conn)
+		if s.ctx.Err() != nil {
+			s.end(s.ctx.Err())
+			return
+		}
+		if closeErr, ok := err.(*CloseError); ok && closeErr.Code != 1001 {
+			// The server ended it on purpose, rather than going away
+			s.end(err)
+			return
+		}
+
+		c.failed(c.endpoint())
+		conn, err = s.reconnect(c)
+		if err != nil {
+			s.end(err)
+			return
+		}
+	}
+}
+
+// read delivers the updates of one connection until it fails.
+func (s *Subscription) read(conn *wsConn) error {
+	for {
+		message, err := conn.readMessage()
+		if err != nil {
+			return err
+		}
+		select {
+		case s.updates <- message:
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+	}
+}
+
+// reconnect connects again with a growing backoff, until it succeeds or the
+// subscription ends.
+func (s *Subscription) reconnect(c *Client) (*wsConn, error) {
+	backoff := c.config.Backoff
+	for {
+		if !sleep(s.ctx, backoff) {
+			return nil, s.ctx.Err()
+		}
+		conn, err := c.dialTopic(s.ctx, s.Topic)
+		if err == nil {
+			s.mutex.Lock()
+			s.conn = conn
+			s.mutex.Unlock()
+			if s.ctx.Err() != nil {
+				conn.close()
+				return nil, s.ctx.Err()
+			}
+			return conn, nil
+		}
+		if s.ctx.Err() != nil {
+			return nil, s.ctx.Err()
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// end records why the subscription ended.
+func (s *Subscription) end(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.err = err
+}
+
+// sleep waits for d, reporting false if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,320 @@
+// Package contract runs contract tests against a running GoScaleAPI. A
+// suite's cases call operations with variables and check the status of the
+// response, its shape against the schema and its latency:
+//
+//	name: users
+//	endpoint: http://localhost:8080/graphql
+//	latency: 250ms
+//	cases:
+//	  - name: user by id
+//	    operation: query:user
+//	    variables:
+//	      id: "1"
+//	  - name: unknown user
+//	    operation: query:user
+//	    variables:
+//	      id: "missing"
+//	    status: 500
+//	    error: not found
+//
+// Suites are read from YAML, TOML or JSON files with Load, or written in Go
+// and run from a test with Runner.Test. gopm api:test --suite runs the files
+// and writes JUnit reports for CI.
+package contract
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscale/sdl"
+	"github.com/davidjeba/goscript/pkg/goscript/config"
+)
+
+// Suite is a set of contract test cases against one API
+type Suite struct {
+	Name string `json:"name"`
+
+	// Endpoint is the URL GoScaleAPI serves requests on
+	Endpoint string `json:"endpoint"`
+
+	// Headers are sent with every case, such as an Authorization header
+	Headers map[string]string `json:"headers"`
+
+	// Latency is the budget of cases that set none; zero has none
+	Latency time.Duration `json:"latency"`
+
+	Cases []Case `json:"cases"`
+}
+
+// Case calls an operation and checks the response
+type Case struct {
+	Name string `json:"name"`
+
+	// Operation is the resolver called, such as "query:user"
+	Operation string                 `json:"operation"`
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+	Headers   map[string]string      `json:"headers"`
+
+	// Status is the expected HTTP status; 200 by default
+	Status int `json:"status"`
+
+	// Error is text the body of a failed response must contain
+	Error string `json:"error"`
+
+	// Latency is the time the response may take; the suite's by default
+	Latency time.Duration `json:"latency"`
+}
+
+// Result is the outcome of a case
+type Result struct {
+	Case      string        `json:"case"`
+	Operation string        `json:"operation"`
+	Status    int           `json:"status"`
+	Latency   time.Duration `json:"latency"`
+
+	// Failures are the checks the response failed; none means it passed
+	Failures []string `json:"failures,omitempty"`
+}
+
+// Passed reports whether the case passed
+func (r Result) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// SuiteResult is the outcome of a suite
+type SuiteResult struct {
+	Suite    string        `json:"suite"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+	Results  []Result      `json:"results"`
+}
+
+// Failed returns the number of cases that failed
+func (s SuiteResult) Failed() int {
+	failed := 0
+	for _, result := range s.Results {
+		if !result.Passed() {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Load reads a suite from a YAML, TOML or JSON file. A suite without a name
+// is named after the file.
+func Load(path string) (Suite, error) {
+	if _, err := os.Stat(path); err != nil {
+		return Suite{}, err
+	}
+
+	var suite Suite
+	if err := config.New("", path).Load(&suite); err != nil {
+		return Suite{}, err
+	}
+	if suite.Name == "" {
+		suite.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	for i, c := range suite.Cases {
+		if c.Operation == "" {
+			return Suite{}, fmt.Errorf("%s: case %d has no operation", path, i+1)
+		}
+		if c.Name == "" {
+			suite.Cases[i].Name = c.Operation
+		}
+	}
+	return suite, nil
+}
+
+// Runner runs suites
+type Runner struct {
+	// Endpoint, if set, overrides the suites' endpoints, such as to run them
+	// against staging
+	Endpoint string
+
+	// Schema checks the operations, their variables and the shape of their
+	// results; nil checks only the status and latency
+	Schema *sdl.Document
+
+	// Client sends the requests; http.DefaultClient if nil
+	Client *http.Client
+}
+
+// Run runs a suite's cases in order
+func (r *Runner) Run(ctx context.Context, suite Suite) SuiteResult {
+	result := SuiteResult{Suite: suite.Name, Started: time.Now(), Results: []Result{}}
+	for _, c := range suite.Cases {
+		result.Results = append(result.Results, r.runCase(ctx, suite, c))
+	}
+	result.Duration = time.Since(result.Started)
+	return result
+}
+
+// Test runs a suite written in Go as subtests of t, one per case
+func (r *Runner) Test(t *testing.T, suite Suite) {
+	for _, c := range suite.Cases {
+		c := c
+		name := c.Name
+		if name == "" {
+			name = c.Operation
+		}
+		t.Run(name, func(t *testing.T) {
+			result := r.runCase(context.Background(), suite, c)
+			for _, failure := range result.Failures {
+				t.Error(failure)
+			}
+		})
+	}
+}
+
+// runCase calls a case's operation and checks the response
+func (r *Runner) runCase(ctx context.Context, suite Suite, c Case) Result {
+	result := Result{Case: c.Name, Operation: c.Operation}
+	fail := func(format string, args ...interface{}) {
+		result.Failures = append(result.Failures, fmt.Sprintf(format, args...))
+	}
+
+	var resultType string
+	if r.Schema != nil {
+		field, err := operationField(r.Schema, c.Operation)
+		if err != nil {
+			fail("%v", err)
+			return result
+		}
+		resultType = field.Type
+		for _, problem := range checkVariables(field, c.Variables) {
+			fail("%s", problem)
+		}
+	}
+
+	endpoint := r.Endpoint
+	if endpoint == "" {
+		endpoint = suite.Endpoint
+	}
+	body, _ := json.Marshal(map[string]interface{}{"query": c.Query, "variables": c.Variables, "operation": c.Operation})
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		fail("%v", err)
+		return result
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for name, value := range suite.Headers {
+		request.Header.Set(name, value)
+	}
+	for name, value := range c.Headers {
+		request.Header.Set(name, value)
+	}
+
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	started := time.Now()
+	response, err := client.Do(request)
+	if err != nil {
+		fail("%v", err)
+		return result
+	}
+	data, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	result.Latency = time.Since(started)
+	result.Status = response.StatusCode
+	if err != nil {
+		fail("reading the response: %v", err)
+		return result
+	}
+
+	budget := c.Latency
+	if budget == 0 {
+		budget = suite.Latency
+	}
+	if budget > 0 && result.Latency > budget {
+		fail("took %v, over the budget of %v", result.Latency.Round(time.Millisecond), budget)
+	}
+
+	status := c.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if result.Status != status {
+		fail("status %d, expected %d: %s", result.Status, status, snippet(data))
+		return result
+	}
+	if c.Error != "" && !strings.Contains(string(data), c.Error) {
+		fail("expected the response to contain %q, got %s", c.Error, snippet(data))
+	}
+	if result.Status != http.StatusOK {
+		return result
+	}
+
+	var decoded struct {
+		Data interface{} `json:"data"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		fail("the response is not JSON: %s", snippet(data))
+		return result
+	}
+	if resultType != "" {
+		for _, problem := range Validate(r.Schema, resultType, decoded.Data) {
+			fail("%s", problem)
+		}
+	}
+	return result
+}
+
+// snippet shortens a response body for a failure message
+func snippet(data []byte) string {
+	text := strings.TrimSpace(string(data))
+	if len(text) > 200 {
+		text = text[:200] + "…"
+	}
+	return text
+}
+
+// operationField finds the root field an operation such as "query:user"
+// resolves
+func operationField(doc *sdl.Document, operation string) (*sdl.Field, error) {
+	i := strings.Index(operation, ":")
+	if i < 0 {
+		return nil, fmt.Errorf("operation %q is not of the form query:name, mutation:name or subscription:name", operation)
+	}
+	kind, name := operation[:i], operation[i+1:]
+	if root := doc.Definition(doc.Operations[kind]); root != nil {
+		if field := root.Field(name); field != nil {
+			return field, nil
+		}
+	}
+	return nil, fmt.Errorf("operation %s is not in the schema", operation)
+}
+
+// checkVariables checks variables are arguments of field, and that none of
+// its required arguments are missing
+func checkVariables(field *sdl.Field, variables map[string]interface{}) []string {
+	var problems []string
+	for name := range variables {
+		found := false
+		for _, arg := range field.Args {
+			found = found || arg.Name == name
+		}
+		if !found {
+			problems = append(problems, fmt.Sprintf("variable %s is not an argument of %s", name, field.Name))
+		}
+	}
+	for _, arg := range field.Args {
+		if _, ok := variables[arg.Name]; !ok && sdl.IsNonNull(arg.Type) && arg.Default == "" {
+			problems = append(problems, fmt.Sprintf("required argument %s of %s is missing", arg.Name, field.Name))
+		}
+	}
+	return problems
+}
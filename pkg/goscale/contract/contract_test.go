@@ -0,0 +1,213 @@
+package contract
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscale/sdl"
+)
+
+const testSchema = `
+enum Role { ADMIN MEMBER }
+interface Node { id: ID! }
+type User implements Node { id: ID!, name: String!, role: Role, age: Int }
+type Post implements Node { id: ID!, title: String! }
+union SearchResult = User | Post
+type Query {
+  user(id: ID!): User
+  users(limit: Int = 10): [User!]!
+  search(text: String!): [SearchResult!]!
+}
+`
+
+// testAPI answers like a GoScaleAPI, with the results of resolvers by
+// operation
+func testAPI(t *testing.T, results map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Operation string                 `json:"operation"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if request.Operation == "query:user" && request.Variables["id"] == "missing" {
+			http.Error(w, "user not found", http.StatusInternalServerError)
+			return
+		}
+		if request.Operation == "query:users" {
+			time.Sleep(30 * time.Millisecond)
+		}
+		result, ok := results[request.Operation]
+		if !ok {
+			http.Error(w, "Unknown operation", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": result})
+	}))
+}
+
+func TestRunSuiteFile(t *testing.T) {
+	server := testAPI(t, map[string]interface{}{
+		"query:user":  map[string]interface{}{"id": "1", "name": "Ada", "role": "ADMIN", "age": 36},
+		"query:users": []interface{}{map[string]interface{}{"id": "1", "name": "Ada"}},
+	})
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "users.yaml")
+	ioutil.WriteFile(path, []byte(`endpoint: `+server.URL+`
+headers:
+  Authorization: Bearer token
+latency: 1s
+cases:
+  - name: user by id
+    operation: query:user
+    variables:
+      id: "1"
+  - name: unknown user
+    operation: query:user
+    variables:
+      id: missing
+    status: 500
+    error: not found
+  - operation: query:users
+    latency: 5ms
+  - name: anonymous
+    operation: query:user
+    variables:
+      id: "1"
+    headers:
+      Authorization: ""
+`), 0644)
+
+	suite, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if suite.Name != "users" || len(suite.Cases) != 4 || suite.Cases[2].Name != "query:users" || suite.Cases[2].Latency != 5*time.Millisecond {
+		t.Fatalf("unexpected suite %+v", suite)
+	}
+
+	doc, err := sdl.Parse(testSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := (&Runner{Schema: doc}).Run(context.Background(), suite)
+	if len(result.Results) != 4 || result.Failed() != 2 {
+		t.Fatalf("expected the slow and the anonymous case to fail, got %+v", result.Results)
+	}
+	if !result.Results[0].Passed() || !result.Results[1].Passed() || result.Results[1].Status != 500 {
+		t.Fatalf("expected the user and the expected error to pass, got %+v", result.Results[:2])
+	}
+	if failures := result.Results[2].Failures; len(failures) != 1 || !strings.Contains(failures[0], "over the budget of 5ms") {
+		t.Fatalf("expected the latency budget exceeded, got %v", failures)
+	}
+	if failures := result.Results[3].Failures; len(failures) != 1 || !strings.HasPrefix(failures[0], "status 401, expected 200: unauthorized") {
+		t.Fatalf("expected the case's header to override the suite's, got %v", failures)
+	}
+
+	var report bytes.Buffer
+	if err := WriteJUnit(&report, result); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<testsuites tests="4" failures="2"`,
+		`<testsuite name="users" tests="4" failures="2"`,
+		`<testcase name="user by id" classname="users"`,
+		`<failure message="status 401, expected 200: unauthorized" type="contract">`,
+	} {
+		if !strings.Contains(report.String(), want) {
+			t.Fatalf("expected %s in the report\n%s", want, report.String())
+		}
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Fatalf("expected a missing suite to fail")
+	}
+}
+
+func TestRunChecksShapeAndVariables(t *testing.T) {
+	server := testAPI(t, map[string]interface{}{
+		"query:user":   map[string]interface{}{"id": "1", "role": "OWNER", "email": "ada@example.com"},
+		"query:search": []interface{}{map[string]interface{}{"__typename": "Post", "id": "2", "title": "Hi"}, map[string]interface{}{"id": "1", "name": "Ada"}},
+	})
+	defer server.Close()
+
+	doc, err := sdl.Parse(testSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	suite := Suite{
+		Endpoint: server.URL,
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+		Cases: []Case{
+			{Name: "user", Operation: "query:user", Variables: map[string]interface{}{"id": "1", "expand": true}},
+			{Name: "search", Operation: "query:search", Variables: map[string]interface{}{"text": "a"}},
+			{Name: "posts", Operation: "query:posts"},
+		},
+	}
+	result := (&Runner{Schema: doc}).Run(context.Background(), suite)
+
+	want := []string{
+		"variable expand is not an argument of user",
+		`data: User has no field email`,
+		`data.role: expected a value of enum Role, got "OWNER"`,
+		"data: missing non-null field User.name",
+	}
+	if got := result.Results[0].Failures; strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected failures\n got %q\nwant %q", got, want)
+	}
+	if !result.Results[1].Passed() {
+		t.Fatalf("expected the union results to match, got %v", result.Results[1].Failures)
+	}
+	if failures := result.Results[2].Failures; len(failures) != 1 || failures[0] != "operation query:posts is not in the schema" {
+		t.Fatalf("expected an unknown operation to fail, got %v", failures)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	doc, err := sdl.Parse(testSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decode := func(text string) interface{} {
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.UseNumber()
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+
+	if problems := Validate(doc, "[User!]!", decode(`[{"id": 1, "name": "Ada", "age": 36}]`)); len(problems) != 0 {
+		t.Fatalf("expected a valid list, got %v", problems)
+	}
+	for text, want := range map[string]string{
+		`null`:        "data: null for non-null [User!]!",
+		`[null]`:      "data[0]: null for non-null User!",
+		`{"id": "1"}`: "data: expected a list of User!, got an object",
+		`[{"id": "1", "name": "Ada", "age": 1.5}]`:           "data[0].age: expected Int, got 1.5",
+		`[{"__typename": "Post", "id": "1", "name": "Ada"}]`: `data[0]: __typename "Post" in a User`,
+	} {
+		if problems := Validate(doc, "[User!]!", decode(text)); len(problems) == 0 || problems[0] != want {
+			t.Errorf("%s: expected %q, got %v", text, want, problems)
+		}
+	}
+	if problems := Validate(doc, "Node", decode(`{"id": "1", "title": "Hi", "body": "…"}`)); len(problems) != 1 || !strings.Contains(problems[0], "matches none of the types of Node (User, Post)") {
+		t.Fatalf("expected an unknown field to match no type, got %v", problems)
+	}
+}
@@ -0,0 +1,85 @@
+package contract
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// junitSuites is the root of a JUnit XML report
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// seconds formats a duration as JUnit's seconds
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// WriteJUnit writes the results of suites as a JUnit XML report, which CI
+// servers show the failed cases of: a testsuite per suite and a testcase
+// per case, timed by its latency
+func WriteJUnit(w io.Writer, results ...SuiteResult) error {
+	report := junitSuites{}
+	var total time.Duration
+	for _, result := range results {
+		suite := junitSuite{
+			Name:      result.Suite,
+			Tests:     len(result.Results),
+			Failures:  result.Failed(),
+			Time:      seconds(result.Duration),
+			Timestamp: result.Started.UTC().Format("2006-01-02T15:04:05"),
+		}
+		for _, r := range result.Results {
+			testCase := junitCase{Name: r.Case, ClassName: result.Suite, Time: seconds(r.Latency)}
+			if !r.Passed() {
+				testCase.Failure = &junitFailure{Message: r.Failures[0], Type: "contract", Text: strings.Join(r.Failures, "\n")}
+			}
+			suite.Cases = append(suite.Cases, testCase)
+		}
+		report.Suites = append(report.Suites, suite)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		total += result.Duration
+	}
+	report.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
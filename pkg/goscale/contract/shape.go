@@ -0,0 +1,227 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/davidjeba/goscript/pkg/goscale/sdl"
+)
+
+// Validate checks a JSON value, decoded with UseNumber, has the shape of a
+// schema type such as "[Post!]!", returning where it does not. Objects may
+// not have fields the type lacks, nor lack its non-null fields; interface and
+// union values are checked against the type their __typename names, or else
+// the types they could be.
+func Validate(doc *sdl.Document, typeName string, value interface{}) []string {
+	v := &validator{doc: doc}
+	v.value("data", typeName, value)
+	return v.problems
+}
+
+// validator collects the problems of a value
+type validator struct {
+	doc      *sdl.Document
+	problems []string
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	v.problems = append(v.problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+// value checks a value of a type
+func (v *validator) value(path, typeName string, value interface{}) {
+	if value == nil {
+		if sdl.IsNonNull(typeName) {
+			v.fail(path, "null for non-null %s", typeName)
+		}
+		return
+	}
+
+	typeName = strings.TrimSuffix(typeName, "!")
+	if sdl.IsList(typeName) {
+		items, ok := value.([]interface{})
+		if !ok {
+			v.fail(path, "expected a list of %s, got %s", typeName[1:len(typeName)-1], describe(value))
+			return
+		}
+		for i, item := range items {
+			v.value(fmt.Sprintf("%s[%d]", path, i), typeName[1:len(typeName)-1], item)
+		}
+		return
+	}
+
+	if sdl.Scalars[typeName] {
+		if !scalar(typeName, value) {
+			v.fail(path, "expected %s, got %s", typeName, describe(value))
+		}
+		return
+	}
+
+	definition := v.doc.Definition(typeName)
+	if definition == nil {
+		v.fail(path, "unknown type %s", typeName)
+		return
+	}
+	switch definition.Kind {
+	case sdl.KindScalar:
+		// Custom scalars can be anything
+	case sdl.KindEnum:
+		text, ok := value.(string)
+		if !ok || !contains(definition.Values, text) {
+			v.fail(path, "expected a value of enum %s, got %s", typeName, describe(value))
+		}
+	case sdl.KindType:
+		v.object(path, definition, value)
+	case sdl.KindInterface, sdl.KindUnion:
+		v.abstract(path, definition, value)
+	default:
+		v.fail(path, "%s %s cannot be a result", definition.Kind, typeName)
+	}
+}
+
+// object checks a value of an object type
+func (v *validator) object(path string, definition *sdl.Definition, value interface{}) {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		v.fail(path, "expected a %s object, got %s", definition.Name, describe(value))
+		return
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "__typename" {
+			if fields[name] != definition.Name {
+				v.fail(path, "__typename %s in a %s", describe(fields[name]), definition.Name)
+			}
+			continue
+		}
+		field := definition.Field(name)
+		if field == nil {
+			v.fail(path, "%s has no field %s", definition.Name, name)
+			continue
+		}
+		v.value(path+"."+name, field.Type, fields[name])
+	}
+	for _, field := range definition.Fields {
+		if _, ok := fields[field.Name]; !ok && sdl.IsNonNull(field.Type) {
+			v.fail(path, "missing non-null field %s.%s", definition.Name, field.Name)
+		}
+	}
+}
+
+// abstract checks a value of an interface or union against the type its
+// __typename names, or else passes if it has the shape of any of them
+func (v *validator) abstract(path string, definition *sdl.Definition, value interface{}) {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		v.fail(path, "expected a %s object, got %s", definition.Name, describe(value))
+		return
+	}
+
+	possible := v.possibleTypes(definition)
+	if typename, ok := fields["__typename"].(string); ok {
+		if !contains(possible, typename) {
+			v.fail(path, "%s is not a %s", typename, definition.Name)
+			return
+		}
+		v.object(path, v.doc.Definition(typename), value)
+		return
+	}
+
+	for _, name := range possible {
+		candidate := &validator{doc: v.doc}
+		candidate.object(path, v.doc.Definition(name), value)
+		if len(candidate.problems) == 0 {
+			return
+		}
+	}
+	v.fail(path, "matches none of the types of %s (%s)", definition.Name, strings.Join(possible, ", "))
+}
+
+// possibleTypes returns the object types a value of an interface or union
+// can have
+func (v *validator) possibleTypes(definition *sdl.Definition) []string {
+	if definition.Kind == sdl.KindUnion {
+		return definition.Types
+	}
+	var types []string
+	for _, candidate := range v.doc.Definitions {
+		if candidate.Kind == sdl.KindType && contains(candidate.Interfaces, definition.Name) {
+			types = append(types, candidate.Name)
+		}
+	}
+	return types
+}
+
+// scalar reports whether a value can be a built-in scalar
+func scalar(typeName string, value interface{}) bool {
+	switch typeName {
+	case "Int":
+		number, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := strconv.ParseInt(string(number), 10, 32)
+		return err == nil
+	case "BigInt":
+		switch typed := value.(type) {
+		case json.Number:
+			_, err := strconv.ParseInt(string(typed), 10, 64)
+			return err == nil
+		case string:
+			_, err := strconv.ParseInt(typed, 10, 64)
+			return err == nil
+		}
+		return false
+	case "Float":
+		_, ok := value.(json.Number)
+		return ok
+	case "Boolean":
+		_, ok := value.(bool)
+		return ok
+	case "ID":
+		switch value.(type) {
+		case string, json.Number:
+			return true
+		}
+		return false
+	case "JSON":
+		return true
+	}
+	// String, Time and DateTime
+	_, ok := value.(string)
+	return ok
+}
+
+// describe names a JSON value in a failure message
+func describe(value interface{}) string {
+	switch typed := value.(type) {
+	case string:
+		return strconv.Quote(typed)
+	case json.Number:
+		return string(typed)
+	case bool:
+		return strconv.FormatBool(typed)
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "an object"
+	}
+	return fmt.Sprint(value)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+
+	"github.com/davidjeba/goscript/pkg/goscript/events"
+	"github.com/davidjeba/goscript/pkg/goscript/reqctx"
+)
+
+// ChangeEvent is published on the event bus after Insert, Update and
+// Delete change rows, on the topic "db.<schema>.<table>.<operation>". It
+// carries who made the change and the request it was made for, as reqctx
+// found them in the write's context.
+type ChangeEvent struct {
+	Schema    string                 `json:"schema"`
+	Table     string                 `json:"table"`
+	Operation string                 `json:"operation"`
+	ID        int64                  `json:"id,omitempty"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	Where     string                 `json:"where,omitempty"`
+	Args      []interface{}          `json:"args,omitempty"`
+	Rows      int64                  `json:"rows"`
+	Request   reqctx.Info            `json:"request"`
+}
+
+// SetEventBus publishes a ChangeEvent on bus for each write made through
+// Insert, Update and Delete. Statements run with Execute or in a
+// Transaction are not published.
+func (db *GoScaleDB) SetEventBus(bus *events.Bus) {
+	db.events = bus
+}
+
+// EventBus returns the bus changes are published on, or nil
+func (db *GoScaleDB) EventBus() *events.Bus {
+	return db.events
+}
+
+// publishChange publishes a change if a bus is set and rows changed
+func (db *GoScaleDB) publishChange(ctx context.Context, change ChangeEvent) {
+	if db.events == nil || change.Rows == 0 {
+		return
+	}
+	change.Request = reqctx.From(ctx)
+	db.events.Publish(ctx, "db."+change.Schema+"."+change.Table+"."+change.Operation, change)
+}
@@ -0,0 +1,436 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/events"
+)
+
+// ErrNoPrimary is returned for writes to a Cluster while no node is
+// primary, such as during a failover
+var ErrNoPrimary = errors.New("no primary node")
+
+// PrimaryTopic is the event bus topic PrimaryEvents are published on
+const PrimaryTopic = "db.cluster.primary"
+
+// Node is a database of a Cluster and the region it runs in
+type Node struct {
+	Name   string
+	Region string
+	Store  Store
+}
+
+// ClusterConfig configures a Cluster
+type ClusterConfig struct {
+	// Nodes are the cluster's databases. Any of them may become primary;
+	// the others replicate from it.
+	Nodes []*Node
+
+	// Region is where this process runs. Reads go to a healthy node in it,
+	// and to the primary when there is none.
+	Region string
+
+	// Candidate names the node this process campaigns for, usually the one
+	// on its machine. Processes with no candidate follow the election.
+	Candidate string
+
+	// Coordinator grants the primary lease
+	Coordinator Coordinator
+
+	// Lease names the primary lease, "goscale.primary" by default; clusters
+	// sharing a coordinator need their own
+	Lease string
+
+	// LeaseTTL is how long a primary stays primary without renewing its
+	// lease, and so how long a failover takes at most; 10s by default
+	LeaseTTL time.Duration
+
+	// Interval is how often the lease is renewed or read and the nodes
+	// checked, LeaseTTL/3 by default
+	Interval time.Duration
+
+	// Check reports whether a node can serve; the default runs SELECT 1.
+	// An unhealthy candidate gives up the lease, so another node takes
+	// over at once.
+	Check func(ctx context.Context, node *Node) error
+
+	// OnError is called with the errors of the background election, which
+	// otherwise retries on its next round
+	OnError func(err error)
+}
+
+// PrimaryEvent tells clients the primary changed, on PrimaryTopic and to
+// the functions added with OnPrimaryChange
+type PrimaryEvent struct {
+	// Previous and Primary name the nodes; Primary is empty when the
+	// cluster lost its primary
+	Previous string `json:"previous,omitempty"`
+	Primary  string `json:"primary,omitempty"`
+	Region   string `json:"region,omitempty"`
+
+	// Term is the lease's term, counting up with each new primary
+	Term int64 `json:"term"`
+
+	// Reason is "elected" for the first primary, "failover" when another
+	// node took over and "lost" when none is primary
+	Reason string    `json:"reason"`
+	Time   time.Time `json:"time"`
+}
+
+// Cluster routes statements to the nodes of a multi-region database: writes
+// always go to the primary and reads to a node in the process's region. The
+// primary is elected with a lease from the Coordinator, which its candidate
+// renews while its node is healthy; when it stops, because the node or the
+// process failed, another candidate takes the lease and the cluster fails
+// over. A Cluster is a Store, so code written for one database can use it.
+type Cluster struct {
+	config    ClusterConfig
+	lease     Lease
+	primary   *Node
+	healthy   map[string]bool
+	listeners map[int]func(PrimaryEvent)
+	nextID    int
+	events    *events.Bus
+	stop      chan struct{}
+	done      chan struct{}
+	mutex     sync.RWMutex
+}
+
+var _ Store = (*Cluster)(nil)
+
+// NewCluster creates a cluster of nodes; call Start to join the election
+func NewCluster(config ClusterConfig) (*Cluster, error) {
+	if len(config.Nodes) == 0 {
+		return nil, errors.New("a cluster needs nodes")
+	}
+	if config.Coordinator == nil {
+		return nil, errors.New("a cluster needs a coordinator")
+	}
+	names := make(map[string]bool)
+	for _, node := range config.Nodes {
+		if node.Name == "" || node.Store == nil || names[node.Name] {
+			return nil, fmt.Errorf("cluster node %q needs a unique name and a store", node.Name)
+		}
+		names[node.Name] = true
+	}
+	if config.Candidate != "" && !names[config.Candidate] {
+		return nil, fmt.Errorf("candidate %q is not a node of the cluster", config.Candidate)
+	}
+
+	if config.Lease == "" {
+		config.Lease = "goscale.primary"
+	}
+	if config.LeaseTTL <= 0 {
+		config.LeaseTTL = 10 * time.Second
+	}
+	if config.Interval <= 0 {
+		config.Interval = config.LeaseTTL / 3
+	}
+	if config.Check == nil {
+		config.Check = func(ctx context.Context, node *Node) error {
+			return node.Store.QueryEach(ctx, "SELECT 1", func(map[string]interface{}) error { return nil })
+		}
+	}
+
+	healthy := make(map[string]bool, len(config.Nodes))
+	for _, node := range config.Nodes {
+		healthy[node.Name] = true
+	}
+	return &Cluster{config: config, healthy: healthy, listeners: make(map[int]func(PrimaryEvent))}, nil
+}
+
+// Start runs a first election round, so writes can be routed once it
+// returns, and keeps running them in the background until Stop
+func (c *Cluster) Start(ctx context.Context) error {
+	err := c.elect(ctx, time.Now())
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.stop == nil {
+		c.stop = make(chan struct{})
+		c.done = make(chan struct{})
+		go c.run(c.stop, c.done)
+	}
+	return err
+}
+
+// run runs election rounds every Interval until stop is closed
+func (c *Cluster) run(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(c.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), c.config.Interval)
+			if err := c.elect(ctx, now); err != nil && c.config.OnError != nil {
+				c.config.OnError(err)
+			}
+			cancel()
+		}
+	}
+}
+
+// Stop stops the election and gives up the primary lease if this process
+// holds it, so another candidate takes over without waiting for it to
+// expire
+func (c *Cluster) Stop(ctx context.Context) error {
+	c.mutex.Lock()
+	stop, done := c.stop, c.done
+	c.stop = nil
+	c.mutex.Unlock()
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	if c.config.Candidate == "" {
+		return nil
+	}
+	return c.config.Coordinator.Release(ctx, c.config.Lease, c.config.Candidate)
+}
+
+// elect runs an election round: it checks the nodes, takes or renews the
+// lease for a healthy candidate or gives it up for an unhealthy one, and
+// follows the lease to the primary
+func (c *Cluster) elect(ctx context.Context, now time.Time) error {
+	healthy := make(map[string]bool, len(c.config.Nodes))
+	for _, node := range c.config.Nodes {
+		healthy[node.Name] = c.config.Check(ctx, node) == nil
+	}
+
+	coordinator, name := c.config.Coordinator, c.config.Lease
+	var lease Lease
+	var err error
+	switch candidate := c.config.Candidate; {
+	case candidate != "" && healthy[candidate]:
+		lease, err = coordinator.Acquire(ctx, name, candidate, now, c.config.LeaseTTL)
+	case candidate != "":
+		if err = coordinator.Release(ctx, name, candidate); err == nil {
+			lease, err = coordinator.Current(ctx, name)
+		}
+	default:
+		lease, err = coordinator.Current(ctx, name)
+	}
+
+	c.mutex.Lock()
+	c.healthy = healthy
+	if err != nil {
+		// Without the lease, the primary is only known until it expires
+		lease = c.lease
+	}
+	var primary *Node
+	if lease.HeldAt(now) {
+		primary = c.node(lease.Holder)
+	}
+	previous := c.primary
+	c.lease, c.primary = lease, primary
+	c.mutex.Unlock()
+
+	if primary != previous {
+		c.publish(previous, primary, lease.Term, now)
+	}
+	return err
+}
+
+// node returns the node named name, or nil
+func (c *Cluster) node(name string) *Node {
+	for _, node := range c.config.Nodes {
+		if node.Name == name {
+			return node
+		}
+	}
+	return nil
+}
+
+// publish tells the listeners and the event bus the primary changed
+func (c *Cluster) publish(previous, primary *Node, term int64, now time.Time) {
+	event := PrimaryEvent{Term: term, Time: now, Reason: "failover"}
+	if previous != nil {
+		event.Previous = previous.Name
+	} else {
+		event.Reason = "elected"
+	}
+	if primary != nil {
+		event.Primary, event.Region = primary.Name, primary.Region
+	} else {
+		event.Reason = "lost"
+	}
+
+	c.mutex.RLock()
+	listeners := make([]func(PrimaryEvent), 0, len(c.listeners))
+	for _, listener := range c.listeners {
+		listeners = append(listeners, listener)
+	}
+	bus := c.events
+	c.mutex.RUnlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+	if bus != nil {
+		bus.Publish(context.Background(), PrimaryTopic, event)
+	}
+}
+
+// OnPrimaryChange adds a function called when the primary changes, and
+// returns a function removing it
+func (c *Cluster) OnPrimaryChange(fn func(PrimaryEvent)) func() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	id := c.nextID
+	c.nextID++
+	c.listeners[id] = fn
+	return func() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		delete(c.listeners, id)
+	}
+}
+
+// SetEventBus publishes a PrimaryEvent on bus, on PrimaryTopic, each time
+// the primary changes, so clients of every instance can follow failovers
+func (c *Cluster) SetEventBus(bus *events.Bus) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.events = bus
+}
+
+// Primary returns the primary node, or nil while there is none
+func (c *Cluster) Primary() *Node {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.primary
+}
+
+// Lease returns the primary lease as of the last election round
+func (c *Cluster) Lease() Lease {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.lease
+}
+
+// Healthy reports whether a node passed its last check
+func (c *Cluster) Healthy(name string) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.healthy[name]
+}
+
+// writer returns the primary's store
+func (c *Cluster) writer() (Store, error) {
+	primary := c.Primary()
+	if primary == nil {
+		return nil, ErrNoPrimary
+	}
+	return primary.Store, nil
+}
+
+// reader returns the store a read goes to: a healthy node in the region,
+// else the primary, else any healthy node
+func (c *Cluster) reader(ctx context.Context) (Store, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if ctx.Value(readPrimaryKey{}) == nil {
+		for _, node := range c.config.Nodes {
+			if node.Region == c.config.Region && c.healthy[node.Name] {
+				return node.Store, nil
+			}
+		}
+	}
+	if c.primary != nil {
+		return c.primary.Store, nil
+	}
+	for _, node := range c.config.Nodes {
+		if c.healthy[node.Name] {
+			return node.Store, nil
+		}
+	}
+	return nil, ErrNoPrimary
+}
+
+// route returns the store a statement goes to: the primary for statements
+// that may write or lock rows, such as UPDATE ... RETURNING run with
+// QueryEach, and a reader otherwise
+func (c *Cluster) route(ctx context.Context, query string) (Store, error) {
+	if readOnly(query) {
+		return c.reader(ctx)
+	}
+	return c.writer()
+}
+
+// readOnly reports whether a statement only reads: a SELECT without a
+// locking clause
+func readOnly(query string) bool {
+	statement := strings.ToUpper(strings.TrimSpace(query))
+	if !strings.HasPrefix(statement, "SELECT") {
+		return false
+	}
+	for _, lock := range []string{"FOR UPDATE", "FOR NO KEY UPDATE", "FOR SHARE", "FOR KEY SHARE"} {
+		if strings.Contains(statement, lock) {
+			return false
+		}
+	}
+	return true
+}
+
+// readPrimaryKey marks contexts whose reads go to the primary
+type readPrimaryKey struct{}
+
+// ReadPrimary returns a context whose reads go to the primary, for reads
+// that must see the writes just made, which replicas may not have yet
+func ReadPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readPrimaryKey{}, true)
+}
+
+// Query runs a query on the node its statement is routed to
+func (c *Cluster) Query(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	store, err := c.route(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return store.Query(ctx, query, args...)
+}
+
+// QueryEach runs a query on the node its statement is routed to
+func (c *Cluster) QueryEach(ctx context.Context, query string, fn func(row map[string]interface{}) error, args ...interface{}) error {
+	store, err := c.route(ctx, query)
+	if err != nil {
+		return err
+	}
+	return store.QueryEach(ctx, query, fn, args...)
+}
+
+// Execute runs a statement on the primary, or returns ErrNoPrimary
+func (c *Cluster) Execute(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	store, err := c.writer()
+	if err != nil {
+		return 0, err
+	}
+	return store.Execute(ctx, query, args...)
+}
+
+// Close closes the nodes' stores. Call Stop first to hand over the lease.
+func (c *Cluster) Close() error {
+	var first error
+	for _, node := range c.config.Nodes {
+		if err := node.Store.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,195 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/events"
+)
+
+// recordingStore is a Store recording the statements it runs
+type recordingStore struct {
+	statements []string
+	mutex      sync.Mutex
+}
+
+func (s *recordingStore) run(query string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.statements = append(s.statements, query)
+}
+
+func (s *recordingStore) last() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.statements) == 0 {
+		return ""
+	}
+	return s.statements[len(s.statements)-1]
+}
+
+func (s *recordingStore) Query(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	s.run(query)
+	return nil, nil
+}
+
+func (s *recordingStore) QueryEach(ctx context.Context, query string, fn func(row map[string]interface{}) error, args ...interface{}) error {
+	s.run(query)
+	return nil
+}
+
+func (s *recordingStore) Execute(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	s.run(query)
+	return 1, nil
+}
+
+func (s *recordingStore) Close() error {
+	return nil
+}
+
+func TestClusterRoutesWritesToTheElectedPrimary(t *testing.T) {
+	ctx := context.Background()
+	us, eu := &recordingStore{}, &recordingStore{}
+	nodes := []*Node{{Name: "us-1", Region: "us", Store: us}, {Name: "eu-1", Region: "eu", Store: eu}}
+	down := map[string]bool{}
+	var downMutex sync.Mutex
+	check := func(ctx context.Context, node *Node) error {
+		downMutex.Lock()
+		defer downMutex.Unlock()
+		if down[node.Name] {
+			return errors.New("down")
+		}
+		return nil
+	}
+
+	coordinator := NewMemoryCoordinator()
+	usCluster, err := NewCluster(ClusterConfig{Nodes: nodes, Region: "us", Candidate: "us-1", Coordinator: coordinator, LeaseTTL: 10 * time.Second, Check: check})
+	if err != nil {
+		t.Fatal(err)
+	}
+	euCluster, err := NewCluster(ClusterConfig{Nodes: nodes, Region: "eu", Candidate: "eu-1", Coordinator: coordinator, LeaseTTL: 10 * time.Second, Check: check})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var changes []PrimaryEvent
+	euCluster.OnPrimaryChange(func(event PrimaryEvent) { changes = append(changes, event) })
+	bus := events.NewBus()
+	published, unsubscribe := bus.SubscribeChan(PrimaryTopic, 4)
+	defer unsubscribe()
+	euCluster.SetEventBus(bus)
+
+	if _, err := euCluster.Execute(ctx, "INSERT INTO posts DEFAULT VALUES"); err != ErrNoPrimary {
+		t.Fatalf("expected no primary before the election, got %v", err)
+	}
+
+	now := time.Now()
+	usCluster.elect(ctx, now)
+	euCluster.elect(ctx, now)
+	if primary := euCluster.Primary(); primary == nil || primary.Name != "us-1" {
+		t.Fatalf("expected us-1 to be elected, got %+v", primary)
+	}
+	if len(changes) != 1 || changes[0].Reason != "elected" || changes[0].Primary != "us-1" || changes[0].Term != 1 {
+		t.Fatalf("unexpected events %+v", changes)
+	}
+	select {
+	case event := <-published:
+		var change PrimaryEvent
+		if err := event.Decode(&change); err != nil || change.Primary != "us-1" {
+			t.Fatalf("expected the change published, got %+v (%v)", change, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the change published on the bus")
+	}
+
+	// Writes go to the primary and reads stay in the region
+	euCluster.Execute(ctx, "INSERT INTO posts DEFAULT VALUES")
+	euCluster.QueryEach(ctx, "UPDATE posts SET views = views + 1 RETURNING views", func(map[string]interface{}) error { return nil })
+	if len(us.statements) != 2 {
+		t.Fatalf("expected the writes on the primary, got %v", us.statements)
+	}
+	euCluster.Query(ctx, "SELECT * FROM posts")
+	if eu.last() != "SELECT * FROM posts" {
+		t.Fatalf("expected the read in the region, got %v", eu.statements)
+	}
+	euCluster.Query(ctx, "SELECT * FROM posts FOR UPDATE")
+	euCluster.Query(ReadPrimary(ctx), "SELECT count(*) FROM posts")
+	if len(us.statements) != 4 || us.last() != "SELECT count(*) FROM posts" {
+		t.Fatalf("expected locking reads and ReadPrimary reads on the primary, got %v", us.statements)
+	}
+
+	// An unhealthy primary gives up its lease and the other node takes over
+	downMutex.Lock()
+	down["us-1"] = true
+	downMutex.Unlock()
+	now = now.Add(time.Second)
+	usCluster.elect(ctx, now)
+	euCluster.elect(ctx, now)
+	if primary := euCluster.Primary(); primary == nil || primary.Name != "eu-1" || euCluster.Lease().Term != 2 {
+		t.Fatalf("expected eu-1 to take over, got %+v", euCluster.Lease())
+	}
+	if last := changes[len(changes)-1]; last.Reason != "failover" || last.Previous != "us-1" || last.Primary != "eu-1" || last.Region != "eu" {
+		t.Fatalf("unexpected failover event %+v", last)
+	}
+	if usCluster.Healthy("us-1") {
+		t.Error("expected us-1 to be reported unhealthy")
+	}
+	usCluster.Query(ctx, "SELECT 2")
+	if eu.last() != "SELECT 2" {
+		t.Error("expected reads to leave an unhealthy region for the primary")
+	}
+
+	// A primary that stops renewing loses its lease when it expires
+	downMutex.Lock()
+	down["us-1"] = false
+	downMutex.Unlock()
+	usCluster.elect(ctx, now)
+	now = now.Add(11 * time.Second)
+	usCluster.elect(ctx, now)
+	if primary := usCluster.Primary(); primary == nil || primary.Name != "us-1" || usCluster.Lease().Term != 3 {
+		t.Fatalf("expected us-1 to take over the expired lease, got %+v", usCluster.Lease())
+	}
+
+	// Stopping hands the lease over at once
+	if err := usCluster.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	euCluster.elect(ctx, now)
+	if primary := euCluster.Primary(); primary == nil || primary.Name != "eu-1" {
+		t.Fatalf("expected eu-1 to take over from a stopped primary, got %+v", primary)
+	}
+}
+
+func TestFollowerClusterLosesExpiredPrimary(t *testing.T) {
+	ctx := context.Background()
+	coordinator := NewMemoryCoordinator()
+	now := time.Now()
+	coordinator.Acquire(ctx, "goscale.primary", "db-1", now, time.Second)
+
+	cluster, err := NewCluster(ClusterConfig{
+		Nodes:       []*Node{{Name: "db-1", Store: &recordingStore{}}},
+		Coordinator: coordinator,
+		Check:       func(context.Context, *Node) error { return nil },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reasons []string
+	cluster.OnPrimaryChange(func(event PrimaryEvent) { reasons = append(reasons, event.Reason) })
+
+	cluster.elect(ctx, now)
+	cluster.elect(ctx, now.Add(2*time.Second))
+	if cluster.Primary() != nil || len(reasons) != 2 || reasons[1] != "lost" {
+		t.Fatalf("expected the primary lost with its lease, got %v", reasons)
+	}
+	if _, err := cluster.Execute(ctx, "DELETE FROM posts"); err != ErrNoPrimary {
+		t.Fatalf("expected writes refused without a primary, got %v", err)
+	}
+
+	if _, err := NewCluster(ClusterConfig{Nodes: cluster.config.Nodes, Coordinator: coordinator, Candidate: "db-2"}); err == nil {
+		t.Fatal("expected an unknown candidate to be refused")
+	}
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/davidjeba/goscript/pkg/goscript/config"
+)
+
+// LoadConfig loads the database's settings over DefaultConfig, such as
+// from the "db" section of the app's config:
+//
+//	dbConfig, err := db.LoadConfig(loader.Section("db"))
+func LoadConfig(loader *config.Loader) (*Config, error) {
+	c := DefaultConfig()
+	if err := loader.Load(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks the settings a database cannot run with
+func (c *Config) Validate() error {
+	switch {
+	case c.ConnectionString == "":
+		return errors.New("connection_string is required")
+	case c.MaxConnections <= 0:
+		return errors.New("max_connections must be positive")
+	case c.QueryTimeout < 0 || c.CacheTTL < 0:
+		return errors.New("query_timeout and cache_ttl cannot be negative")
+	case c.ShardingEnabled && c.ShardCount <= 0:
+		return errors.New("shard_count must be positive when sharding_enabled is set")
+	case c.ReplicationMode != "" && c.ReplicationMode != "sync" && c.ReplicationMode != "async":
+		return errors.New("replication_mode must be sync or async")
+	case c.CacheSize < 0:
+		return errors.New("cache_size cannot be negative")
+	}
+	return nil
+}
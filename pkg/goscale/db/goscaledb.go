@@ -6,9 +6,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"reflect"
+	"math"
+	"strings"
 	"sync"
 	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/events"
 )
 
 // GoScaleDB is a high-performance database that combines features of
@@ -30,6 +33,8 @@ type GoScaleDB struct {
 	replicaNodes    []string
 	replicationMode string
 	migrationLock   sync.Mutex
+	events          *events.Bus
+	queryLog        queryLog
 }
 
 // Config contains configuration options for GoScaleDB
@@ -190,6 +195,7 @@ func NewGoScaleDB(config *Config) *GoScaleDB {
 		replicationMode: config.ReplicationMode,
 		metrics:         &Metrics{},
 	}
+	db.queryLog.config = DefaultQueryLogConfig()
 	
 	// Initialize time series manager if enabled
 	if config.EnableTimeSeries {
@@ -224,7 +230,7 @@ func NewGoScaleDB(config *Config) *GoScaleDB {
 			// In a real implementation, we would connect to different shards
 			db.shards[i] = &Shard{
 				ID:       i,
-				KeyRange: [2]int64{int64(i) * (1<<63) / int64(config.ShardCount), (int64(i)+1) * (1<<63) / int64(config.ShardCount)},
+				KeyRange: [2]int64{int64(i) * (math.MaxInt64 / int64(config.ShardCount)), (int64(i) + 1) * (math.MaxInt64 / int64(config.ShardCount))},
 				Tables:   []string{},
 			}
 		}
@@ -288,6 +294,14 @@ func (db *GoScaleDB) Close() error {
 // Query executes a query and returns the results
 func (db *GoScaleDB) Query(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
 	startTime := time.Now()
+	result, err := db.runQuery(ctx, query, args...)
+	db.logStatement(ctx, query, startTime, err, args...)
+	return result, err
+}
+
+// runQuery executes a query for Query
+func (db *GoScaleDB) runQuery(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	startTime := time.Now()
 	
 	// Check cache first
 	cacheKey := fmt.Sprintf("%s:%v", query, args)
@@ -319,50 +333,12 @@ func (db *GoScaleDB) Query(ctx context.Context, query string, args ...interface{
 	
 	// Scan rows
 	for rows.Next() {
-		// Create a slice of interface{} to hold the values
-		values := make([]interface{}, len(columns))
-		valuePtrs := make([]interface{}, len(columns))
-		
-		// Initialize the pointers
-		for i := range columns {
-			valuePtrs[i] = &values[i]
-		}
-		
-		// Scan the row into the slice
-		if err := rows.Scan(valuePtrs...); err != nil {
+		row, err := scanRow(rows, columns)
+		if err != nil {
 			db.updateMetrics(startTime, false, false, false)
 			return nil, err
 		}
 		
-		// Create a map for this row
-		row := make(map[string]interface{})
-		
-		// Convert the values to their appropriate types
-		for i, col := range columns {
-			val := values[i]
-			
-			// Handle null values
-			if val == nil {
-				row[col] = nil
-				continue
-			}
-			
-			// Handle different types
-			switch v := val.(type) {
-			case []byte:
-				// Try to unmarshal as JSON first
-				var jsonVal interface{}
-				if err := json.Unmarshal(v, &jsonVal); err == nil {
-					row[col] = jsonVal
-				} else {
-					// If not JSON, use as string
-					row[col] = string(v)
-				}
-			default:
-				row[col] = v
-			}
-		}
-		
 		result = append(result, row)
 	}
 	
@@ -385,9 +361,112 @@ func (db *GoScaleDB) Query(ctx context.Context, query string, args ...interface{
 	return result, nil
 }
 
+// QueryEach executes a query and calls fn with each row as it is read,
+// without holding the whole result in memory or caching it. An error from
+// fn stops the query and is returned.
+func (db *GoScaleDB) QueryEach(ctx context.Context, query string, fn func(row map[string]interface{}) error, args ...interface{}) error {
+	startTime := time.Now()
+	err := db.runQueryEach(ctx, query, fn, args...)
+	db.logStatement(ctx, query, startTime, err, args...)
+	return err
+}
+
+// runQueryEach executes a query for QueryEach
+func (db *GoScaleDB) runQueryEach(ctx context.Context, query string, fn func(row map[string]interface{}) error, args ...interface{}) error {
+	startTime := time.Now()
+	
+	rows, err := db.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		db.updateMetrics(startTime, false, false, false)
+		return err
+	}
+	defer rows.Close()
+	
+	columns, err := rows.Columns()
+	if err != nil {
+		db.updateMetrics(startTime, false, false, false)
+		return err
+	}
+	
+	for rows.Next() {
+		row, err := scanRow(rows, columns)
+		if err == nil {
+			err = fn(row)
+		}
+		if err != nil {
+			db.updateMetrics(startTime, false, false, false)
+			return err
+		}
+	}
+	
+	if err := rows.Err(); err != nil {
+		db.updateMetrics(startTime, false, false, false)
+		return err
+	}
+	
+	db.updateMetrics(startTime, true, false, false)
+	return nil
+}
+
+// scanRow reads the current row into a map of column names to values
+func scanRow(rows *sql.Rows, columns []string) (map[string]interface{}, error) {
+	// Create a slice of interface{} to hold the values
+	values := make([]interface{}, len(columns))
+	valuePtrs := make([]interface{}, len(columns))
+	
+	// Initialize the pointers
+	for i := range columns {
+		valuePtrs[i] = &values[i]
+	}
+	
+	// Scan the row into the slice
+	if err := rows.Scan(valuePtrs...); err != nil {
+		return nil, err
+	}
+	
+	// Create a map for this row
+	row := make(map[string]interface{})
+	
+	// Convert the values to their appropriate types
+	for i, col := range columns {
+		val := values[i]
+		
+		// Handle null values
+		if val == nil {
+			row[col] = nil
+			continue
+		}
+		
+		// Handle different types
+		switch v := val.(type) {
+		case []byte:
+			// Try to unmarshal as JSON first
+			var jsonVal interface{}
+			if err := json.Unmarshal(v, &jsonVal); err == nil {
+				row[col] = jsonVal
+			} else {
+				// If not JSON, use as string
+				row[col] = string(v)
+			}
+		default:
+			row[col] = v
+		}
+	}
+	
+	return row, nil
+}
+
 // Execute executes a non-query SQL statement
 func (db *GoScaleDB) Execute(ctx context.Context, query string, args ...interface{}) (int64, error) {
 	startTime := time.Now()
+	rows, err := db.runExecute(ctx, query, args...)
+	db.logStatement(ctx, query, startTime, err, args...)
+	return rows, err
+}
+
+// runExecute executes a statement for Execute
+func (db *GoScaleDB) runExecute(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	startTime := time.Now()
 	
 	// Execute the statement
 	result, err := db.conn.ExecContext(ctx, query, args...)
@@ -435,6 +514,11 @@ func (db *GoScaleDB) Transaction(ctx context.Context, fn func(*sql.Tx) error) er
 
 // CreateSchema creates a new database schema
 func (db *GoScaleDB) CreateSchema(name string) (*Schema, error) {
+	quoted, err := QuoteIdentifier(name)
+	if err != nil {
+		return nil, err
+	}
+	
 	db.schemaMutex.Lock()
 	defer db.schemaMutex.Unlock()
 	
@@ -451,7 +535,7 @@ func (db *GoScaleDB) CreateSchema(name string) (*Schema, error) {
 	db.schemas[name] = schema
 	
 	// Create the schema in the database
-	_, err := db.Execute(context.Background(), fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", name))
+	_, err = db.Execute(context.Background(), "CREATE SCHEMA IF NOT EXISTS "+quoted)
 	if err != nil {
 		delete(db.schemas, name)
 		return nil, err
@@ -487,45 +571,35 @@ func (db *GoScaleDB) CreateTable(schemaName, tableName string, columns map[strin
 		return nil, fmt.Errorf("table %s already exists in schema %s", tableName, schemaName)
 	}
 	
+	qualified, err := QualifiedName(schemaName, tableName)
+	if err != nil {
+		return nil, err
+	}
+	for name, column := range columns {
+		column.Name = name
+	}
+	
 	table := &Table{
 		Name:       tableName,
 		Columns:    columns,
 		Indexes:    make(map[string]*Index),
 		PrimaryKey: primaryKey,
 	}
+	if err := validateColumns(table); err != nil {
+		return nil, err
+	}
 	
 	schema.Tables[tableName] = table
 	
-	// Build the CREATE TABLE statement
-	query := fmt.Sprintf("CREATE TABLE %s.%s (", schemaName, tableName)
-	
-	i := 0
-	for name, column := range columns {
-		if i > 0 {
-			query += ", "
-		}
-		
-		query += fmt.Sprintf("%s %s", name, column.Type)
-		
-		if !column.Nullable {
-			query += " NOT NULL"
-		}
-		
-		if column.Default != nil {
-			query += fmt.Sprintf(" DEFAULT %v", column.Default)
-		}
-		
-		if name == primaryKey {
-			query += " PRIMARY KEY"
-		}
-		
-		i++
+	// Build the CREATE TABLE statement, as migrations do
+	definitions := make([]string, 0, len(columns))
+	for _, column := range sortedColumns(table) {
+		definitions = append(definitions, columnDefinition(column, column.Name == primaryKey))
 	}
-	
-	query += ")"
+	query := fmt.Sprintf("CREATE TABLE %s (%s)", qualified, strings.Join(definitions, ", "))
 	
 	// Create the table in the database
-	_, err := db.Execute(context.Background(), query)
+	_, err = db.Execute(context.Background(), query)
 	if err != nil {
 		delete(schema.Tables, tableName)
 		return nil, err
@@ -579,6 +653,19 @@ func (db *GoScaleDB) CreateIndex(schemaName, tableName, indexName string, column
 		return nil, fmt.Errorf("index %s already exists on table %s.%s", indexName, schemaName, tableName)
 	}
 	
+	quotedIndex, err := QuoteIdentifier(indexName)
+	if err != nil {
+		return nil, err
+	}
+	qualified, err := QualifiedName(schemaName, tableName)
+	if err != nil {
+		return nil, err
+	}
+	columnStr, err := quoteIdentifiers(columns)
+	if err != nil {
+		return nil, err
+	}
+	
 	index := &Index{
 		Name:    indexName,
 		Columns: columns,
@@ -593,18 +680,10 @@ func (db *GoScaleDB) CreateIndex(schemaName, tableName, indexName string, column
 		uniqueStr = "UNIQUE "
 	}
 	
-	columnStr := ""
-	for i, col := range columns {
-		if i > 0 {
-			columnStr += ", "
-		}
-		columnStr += col
-	}
-	
-	query := fmt.Sprintf("CREATE %sINDEX %s ON %s.%s (%s)", uniqueStr, indexName, schemaName, tableName, columnStr)
+	query := fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)", uniqueStr, quotedIndex, qualified, columnStr)
 	
 	// Create the index in the database
-	_, err := db.Execute(context.Background(), query)
+	_, err = db.Execute(context.Background(), query)
 	if err != nil {
 		delete(table.Indexes, indexName)
 		return nil, err
@@ -620,6 +699,14 @@ func (db *GoScaleDB) Insert(ctx context.Context, schemaName, tableName string, d
 	if err != nil {
 		return 0, err
 	}
+	qualified, err := QualifiedName(schemaName, tableName)
+	if err != nil {
+		return 0, err
+	}
+	primaryKey, err := QuoteIdentifier(table.PrimaryKey)
+	if err != nil {
+		return 0, err
+	}
 	
 	// Build the INSERT statement
 	columns := ""
@@ -637,14 +724,14 @@ func (db *GoScaleDB) Insert(ctx context.Context, schemaName, tableName string, d
 			values += ", "
 		}
 		
-		columns += col
+		columns += quoteIdent(col)
 		values += fmt.Sprintf("$%d", i+1)
 		args = append(args, val)
 		
 		i++
 	}
 	
-	query := fmt.Sprintf("INSERT INTO %s.%s (%s) VALUES (%s) RETURNING %s", schemaName, tableName, columns, values, table.PrimaryKey)
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) RETURNING %s", qualified, columns, values, primaryKey)
 	
 	// Execute the query
 	rows, err := db.Query(ctx, query, args...)
@@ -675,6 +762,7 @@ func (db *GoScaleDB) Insert(ctx context.Context, schemaName, tableName string, d
 		return 0, fmt.Errorf("primary key %s is not a number", table.PrimaryKey)
 	}
 	
+	db.publishChange(ctx, ChangeEvent{Schema: schemaName, Table: tableName, Operation: "insert", ID: id, Data: data, Rows: 1})
 	return id, nil
 }
 
@@ -685,6 +773,10 @@ func (db *GoScaleDB) Update(ctx context.Context, schemaName, tableName string, d
 	if err != nil {
 		return 0, err
 	}
+	qualified, err := QualifiedName(schemaName, tableName)
+	if err != nil {
+		return 0, err
+	}
 	
 	// Build the UPDATE statement
 	set := ""
@@ -700,7 +792,7 @@ func (db *GoScaleDB) Update(ctx context.Context, schemaName, tableName string, d
 			set += ", "
 		}
 		
-		set += fmt.Sprintf("%s = $%d", col, i+1)
+		set += fmt.Sprintf("%s = $%d", quoteIdent(col), i+1)
 		setArgs = append(setArgs, val)
 		
 		i++
@@ -711,13 +803,19 @@ func (db *GoScaleDB) Update(ctx context.Context, schemaName, tableName string, d
 		where = fmt.Sprintf(where, fmt.Sprintf("$%d", i+j+1))
 	}
 	
-	query := fmt.Sprintf("UPDATE %s.%s SET %s WHERE %s", schemaName, tableName, set, where)
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", qualified, set, where)
 	
 	// Combine the arguments
 	allArgs := append(setArgs, args...)
 	
 	// Execute the query
-	return db.Execute(ctx, query, allArgs...)
+	rows, err := db.Execute(ctx, query, allArgs...)
+	if err != nil {
+		return 0, err
+	}
+	
+	db.publishChange(ctx, ChangeEvent{Schema: schemaName, Table: tableName, Operation: "update", Data: data, Where: where, Args: args, Rows: rows})
+	return rows, nil
 }
 
 // Delete deletes rows from a table
@@ -727,12 +825,22 @@ func (db *GoScaleDB) Delete(ctx context.Context, schemaName, tableName string, w
 	if err != nil {
 		return 0, err
 	}
+	qualified, err := QualifiedName(schemaName, tableName)
+	if err != nil {
+		return 0, err
+	}
 	
 	// Build the DELETE statement
-	query := fmt.Sprintf("DELETE FROM %s.%s WHERE %s", schemaName, tableName, where)
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s", qualified, where)
 	
 	// Execute the query
-	return db.Execute(ctx, query, args...)
+	rows, err := db.Execute(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	
+	db.publishChange(ctx, ChangeEvent{Schema: schemaName, Table: tableName, Operation: "delete", Where: where, Args: args, Rows: rows})
+	return rows, nil
 }
 
 // initializeTimeSeries initializes time series features
@@ -741,17 +849,31 @@ func (db *GoScaleDB) initializeTimeSeries() error {
 	return nil
 }
 
+// TimeSeries returns the time series manager, or nil when time series are
+// disabled
+func (db *GoScaleDB) TimeSeries() *TimeSeriesManager {
+	return db.timeSeries
+}
+
 // EnableTimeSeriesForTable enables time series features for a table
 func (ts *TimeSeriesManager) EnableTimeSeriesForTable(schemaName, tableName, timeColumn string) error {
 	ts.mutex.Lock()
 	defer ts.mutex.Unlock()
 	
+	qualified, err := QualifiedName(schemaName, tableName)
+	if err != nil {
+		return err
+	}
+	if err := ValidateIdentifier(timeColumn); err != nil {
+		return err
+	}
+	
 	key := fmt.Sprintf("%s.%s", schemaName, tableName)
 	ts.enabledTables[key] = true
 	
 	// In a real implementation, this would create a hypertable
-	query := fmt.Sprintf("SELECT create_hypertable('%s.%s', '%s')", schemaName, tableName, timeColumn)
-	_, err := ts.db.Execute(context.Background(), query)
+	query := fmt.Sprintf("SELECT create_hypertable(%s, %s, if_not_exists => TRUE)", quoteLiteral(qualified), quoteLiteral(timeColumn))
+	_, err = ts.db.Execute(context.Background(), query)
 	
 	return err
 }
@@ -768,8 +890,16 @@ func (ts *TimeSeriesManager) SetRetentionPolicy(schemaName, tableName string, re
 	
 	ts.retentionPolicies[key] = retention
 	
-	// In a real implementation, this would set a retention policy
-	query := fmt.Sprintf("SELECT add_retention_policy('%s.%s', INTERVAL '%d seconds')", schemaName, tableName, int(retention.Seconds()))
+	// Tables are validated when time series are enabled for them
+	qualified := quoteLiteral(quoteIdent(schemaName) + "." + quoteIdent(tableName))
+	
+	// Replace any policy set before, such as by a previous run
+	query := fmt.Sprintf("SELECT remove_retention_policy(%s, if_exists => TRUE)", qualified)
+	if _, err := ts.db.Execute(context.Background(), query); err != nil {
+		return err
+	}
+	
+	query = fmt.Sprintf("SELECT add_retention_policy(%s, INTERVAL '%d seconds')", qualified, int(retention.Seconds()))
 	_, err := ts.db.Execute(context.Background(), query)
 	
 	return err
@@ -777,6 +907,12 @@ func (ts *TimeSeriesManager) SetRetentionPolicy(schemaName, tableName string, re
 
 // CreateRelationship creates a relationship between two tables
 func (rm *RelationshipManager) CreateRelationship(name, sourceTable, targetTable, relType, sourceKey, targetKey string) (*Relationship, error) {
+	for _, name := range []string{sourceTable, targetTable, sourceKey, targetKey} {
+		if err := ValidateIdentifier(name); err != nil {
+			return nil, err
+		}
+	}
+	
 	rm.mutex.Lock()
 	defer rm.mutex.Unlock()
 	
@@ -830,11 +966,11 @@ func (rm *RelationshipManager) GetRelatedEntities(ctx context.Context, sourceTab
 	
 	switch rel.Type {
 	case "OneToOne", "OneToMany":
-		query = fmt.Sprintf("SELECT * FROM %s WHERE %s = $1", rel.TargetTable, rel.TargetKey)
+		query = fmt.Sprintf("SELECT * FROM %s WHERE %s = $1", quoteIdent(rel.TargetTable), quoteIdent(rel.TargetKey))
 		args = []interface{}{sourceID}
 	case "ManyToMany":
 		query = fmt.Sprintf("SELECT t.* FROM %s t JOIN %s j ON t.%s = j.%s WHERE j.%s = $1",
-			rel.TargetTable, rel.JoinTable, rel.TargetKey, rel.TargetKey, rel.SourceKey)
+			quoteIdent(rel.TargetTable), quoteIdent(rel.JoinTable), quoteIdent(rel.TargetKey), quoteIdent(rel.TargetKey), quoteIdent(rel.SourceKey))
 		args = []interface{}{sourceID}
 	default:
 		return nil, fmt.Errorf("unknown relationship type: %s", rel.Type)
@@ -845,6 +981,10 @@ func (rm *RelationshipManager) GetRelatedEntities(ctx context.Context, sourceTab
 
 // CreateNoCodeSchema creates a new NoCode schema
 func (nc *NoCodeManager) CreateNoCodeSchema(name string) (*NoCodeSchema, error) {
+	if err := ValidateIdentifier(name); err != nil {
+		return nil, err
+	}
+	
 	nc.mutex.Lock()
 	defer nc.mutex.Unlock()
 	
@@ -865,6 +1005,10 @@ func (nc *NoCodeManager) CreateNoCodeSchema(name string) (*NoCodeSchema, error)
 
 // AddNoCodeField adds a field to a NoCode schema
 func (nc *NoCodeManager) AddNoCodeField(schemaName, fieldName, fieldType string, required bool, defaultValue interface{}, validators []string) (*NoCodeField, error) {
+	if err := ValidateIdentifier(fieldName); err != nil {
+		return nil, err
+	}
+	
 	nc.mutex.Lock()
 	defer nc.mutex.Unlock()
 	
@@ -937,7 +1081,7 @@ func (nc *NoCodeManager) CreateNoCodeEntity(ctx context.Context, schemaName stri
 			}
 			
 			// Apply validators
-			for _, validator := range field.Validators {
+			for range field.Validators {
 				// In a real implementation, we would apply the validators
 			}
 		} else if field.Default != nil {
@@ -961,7 +1105,7 @@ func (nc *NoCodeManager) GetNoCodeEntity(ctx context.Context, schemaName string,
 	nc.mutex.RUnlock()
 	
 	// Get the entity
-	rows, err := nc.db.Query(ctx, fmt.Sprintf("SELECT * FROM nocode.%s WHERE id = $1", schemaName), id)
+	rows, err := nc.db.Query(ctx, fmt.Sprintf("SELECT * FROM nocode.%s WHERE id = $1", quoteIdent(schemaName)), id)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxIdentifierLength is the longest name PostgreSQL keeps, in bytes; it
+// truncates longer ones, so two of them could name the same table
+const MaxIdentifierLength = 63
+
+// identifierPattern is the names ValidateIdentifier accepts
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateIdentifier checks that a name of a schema, table, column, index
+// or relationship is a letter or underscore followed by letters, digits and
+// underscores, and at most MaxIdentifierLength bytes. The statements
+// GoScaleDB builds validate every name they are given, and quote it, so
+// names coming from a request cannot change what a statement does.
+func ValidateIdentifier(name string) error {
+	if len(name) > MaxIdentifierLength {
+		return fmt.Errorf("invalid identifier %.20q...: longer than %d bytes", name, MaxIdentifierLength)
+	}
+	if !identifierPattern.MatchString(name) {
+		return fmt.Errorf("invalid identifier %q: use letters, digits and underscores, starting with a letter or underscore", name)
+	}
+	return nil
+}
+
+// columnTypePattern is the column types ValidateColumnType accepts: words,
+// with an optional length or precision and scale, and array brackets
+var columnTypePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*( [A-Za-z_][A-Za-z0-9_]*)*( ?\( ?[0-9]+ ?(, ?[0-9]+ ?)?\))?( [A-Za-z_][A-Za-z0-9_]*)*(\[\])*$`)
+
+// ValidateColumnType checks that a column type is written as words, such as
+// "double precision", with an optional modifier, as in "varchar(255)" or
+// "timestamp(3) with time zone", and array brackets, as in "text[]". Types
+// are put in DDL as written, so one holding anything else, such as a
+// quote or a semicolon, is refused.
+func ValidateColumnType(columnType string) error {
+	if !columnTypePattern.MatchString(columnType) {
+		return fmt.Errorf("invalid column type %q", columnType)
+	}
+	return nil
+}
+
+// QuoteIdentifier validates a name and quotes it, so names that are also
+// keywords, such as "user" or "order", may be used. Quoted names keep their
+// case.
+func QuoteIdentifier(name string) (string, error) {
+	if err := ValidateIdentifier(name); err != nil {
+		return "", err
+	}
+	return quoteIdent(name), nil
+}
+
+// QualifiedName validates and quotes a table's name with its schema's, as
+// "schema"."table"
+func QualifiedName(schema, table string) (string, error) {
+	quotedSchema, err := QuoteIdentifier(schema)
+	if err != nil {
+		return "", err
+	}
+	quotedTable, err := QuoteIdentifier(table)
+	if err != nil {
+		return "", err
+	}
+	return quotedSchema + "." + quotedTable, nil
+}
+
+// quoteIdentifiers validates and quotes names, joined with commas, as in a
+// column list
+func quoteIdentifiers(names []string) (string, error) {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		var err error
+		if quoted[i], err = QuoteIdentifier(name); err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(quoted, ", "), nil
+}
+
+// quoteIdent quotes an identifier, so names such as "user" may be used. It
+// does not validate the name, for names read from the database itself.
+func quoteIdent(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// quoteLiteral quotes a string literal, for the functions that take names
+// as text, such as create_hypertable
+func quoteLiteral(value string) string {
+	return `'` + strings.Replace(value, `'`, `''`, -1) + `'`
+}
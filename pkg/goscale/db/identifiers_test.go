@@ -0,0 +1,98 @@
+package db
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQuoteIdentifier(t *testing.T) {
+	for name, want := range map[string]string{
+		"users":     `"users"`,
+		"user":      `"user"`,
+		"Orders":    `"Orders"`,
+		"_draft_v2": `"_draft_v2"`,
+	} {
+		quoted, err := QuoteIdentifier(name)
+		if err != nil || quoted != want {
+			t.Errorf("QuoteIdentifier(%q) = %s, %v; want %s", name, quoted, err, want)
+		}
+	}
+
+	for _, name := range []string{
+		"",
+		"2fa",
+		"users; DROP TABLE users",
+		`users" --`,
+		"users.name",
+		"naïve",
+		"users\x00",
+		strings.Repeat("a", MaxIdentifierLength+1),
+	} {
+		if quoted, err := QuoteIdentifier(name); err == nil {
+			t.Errorf("QuoteIdentifier(%q) = %s, want an error", name, quoted)
+		}
+	}
+
+	qualified, err := QualifiedName("app", "order")
+	if err != nil || qualified != `"app"."order"` {
+		t.Fatalf(`expected "app"."order", got %s, %v`, qualified, err)
+	}
+	if _, err := QualifiedName("app", "orders where 1=1"); err == nil {
+		t.Fatal("expected an invalid table name to be rejected")
+	}
+}
+
+func TestQuotedIdentifierCannotEscape(t *testing.T) {
+	names := []string{"users", "user", `a"b`, "a;b", "", "_x1", "é", "a b", "a-b", "a/*b", "a\tb", "a'b", "a(b)", "a.b", "a\\b", "a\x00b", strings.Repeat("z", 64)}
+	for _, name := range names {
+		quoted, err := QuoteIdentifier(name)
+		if err != nil {
+			continue
+		}
+		if len(name) == 0 || len(name) > MaxIdentifierLength {
+			t.Fatalf("accepted %q of %d bytes", name, len(name))
+		}
+		// A quoted name is the name between quotes, with nothing that could
+		// end the identifier or the statement
+		if quoted != `"`+name+`"` {
+			t.Fatalf("quoted %q as %s", name, quoted)
+		}
+		if strings.ContainsAny(name, "\"';-/*\\ \t\r\n\x00.()") {
+			t.Fatalf("accepted %q", name)
+		}
+	}
+}
+
+func TestQuoteLiteral(t *testing.T) {
+	for value, want := range map[string]string{
+		"app.metrics":               `'app.metrics'`,
+		"it's":                      `'it''s'`,
+		"''":                        `''''''`,
+		`"app"."x"`:                 `'"app"."x"'`,
+		"x'); DROP TABLE users; --": `'x''); DROP TABLE users; --'`,
+		"":                          `''`,
+	} {
+		if got := quoteLiteral(value); got != want {
+			t.Errorf("quoteLiteral(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
+
+func TestValidateColumnType(t *testing.T) {
+	for _, columnType := range []string{
+		"text", "bigint", "double precision", "varchar(255)", "numeric(10,2)", "numeric( 10, 2 )",
+		"timestamp with time zone", "timestamp(3) with time zone", "text[]", "integer[][]",
+	} {
+		if err := ValidateColumnType(columnType); err != nil {
+			t.Errorf("ValidateColumnType(%q) = %v", columnType, err)
+		}
+	}
+	for _, columnType := range []string{
+		"", "text; DROP TABLE users", "text DEFAULT 'x'", "text --", "varchar(255) /* */", "text NOT NULL,\n\"x\" text",
+		"numeric(10,2,3)", "varchar(x)", " text", "text ", "text  text",
+	} {
+		if err := ValidateColumnType(columnType); err == nil {
+			t.Errorf("ValidateColumnType(%q) accepted", columnType)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Lease is a named lease held by one holder at a time until it expires,
+// such as a cluster's primary lease held by the primary node
+type Lease struct {
+	Name    string    `json:"name"`
+	Holder  string    `json:"holder"`
+	Term    int64     `json:"term"`
+	Expires time.Time `json:"expires"`
+}
+
+// HeldAt reports whether the lease has a holder at a time
+func (l Lease) HeldAt(now time.Time) bool {
+	return l.Holder != "" && now.Before(l.Expires)
+}
+
+// Coordinator grants leases, so that processes agree on a single holder,
+// such as the primary of a Cluster. MemoryCoordinator serves the processes
+// of one machine and StoreCoordinator those sharing a database; an external
+// coordinator, such as etcd or Consul, can implement it too.
+type Coordinator interface {
+	// Acquire takes a lease for holder until now+ttl if it is free or has
+	// expired, or renews it if holder has it, and returns the lease as it
+	// is afterwards, whoever holds it. Term counts up each time the lease
+	// changes holder, so a stale holder can be told apart.
+	Acquire(ctx context.Context, name, holder string, now time.Time, ttl time.Duration) (Lease, error)
+
+	// Release ends a lease early if holder has it, so another can take it
+	Release(ctx context.Context, name, holder string) error
+
+	// Current returns a lease, with no holder if it was never taken
+	Current(ctx context.Context, name string) (Lease, error)
+}
+
+var (
+	_ Coordinator = (*MemoryCoordinator)(nil)
+	_ Coordinator = (*StoreCoordinator)(nil)
+)
+
+// MemoryCoordinator keeps leases in memory, for clusters run by a single
+// process and for tests
+type MemoryCoordinator struct {
+	leases map[string]Lease
+	mutex  sync.Mutex
+}
+
+// NewMemoryCoordinator creates a coordinator with no leases taken
+func NewMemoryCoordinator() *MemoryCoordinator {
+	return &MemoryCoordinator{leases: make(map[string]Lease)}
+}
+
+// Acquire takes or renews a lease
+func (m *MemoryCoordinator) Acquire(ctx context.Context, name, holder string, now time.Time, ttl time.Duration) (Lease, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	lease := m.leases[name]
+	lease.Name = name
+	if lease.Holder == holder || !lease.HeldAt(now) {
+		if lease.Holder != holder {
+			lease.Holder = holder
+			lease.Term++
+		}
+		lease.Expires = now.Add(ttl)
+		m.leases[name] = lease
+	}
+	return lease, nil
+}
+
+// Release ends a lease if holder has it
+func (m *MemoryCoordinator) Release(ctx context.Context, name, holder string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if lease, ok := m.leases[name]; ok && lease.Holder == holder {
+		lease.Expires = time.Time{}
+		m.leases[name] = lease
+	}
+	return nil
+}
+
+// Current returns a lease
+func (m *MemoryCoordinator) Current(ctx context.Context, name string) (Lease, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	lease := m.leases[name]
+	lease.Name = name
+	return lease, nil
+}
+
+// StoreCoordinator keeps leases in a table of a database every process
+// reaches, such as a GoScaleDB outside the cluster it elects for, or a
+// SQLiteStore on a shared volume. Leases are taken with a single upsert, so
+// two processes never both get one.
+type StoreCoordinator struct {
+	Store Store
+
+	// Table names the leases table, "goscale_leases" by default
+	Table string
+}
+
+// NewStoreCoordinator creates a coordinator keeping leases in store; call
+// Init before using it
+func NewStoreCoordinator(store Store) *StoreCoordinator {
+	return &StoreCoordinator{Store: store, Table: "goscale_leases"}
+}
+
+// table returns the quoted name of the leases table
+func (s *StoreCoordinator) table() (string, error) {
+	return QuoteIdentifier(s.Table)
+}
+
+// Init creates the leases table. It can be called on every start.
+func (s *StoreCoordinator) Init(ctx context.Context) error {
+	table, err := s.table()
+	if err != nil {
+		return err
+	}
+	_, err = s.Store.Execute(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		name TEXT PRIMARY KEY,
+		holder TEXT NOT NULL,
+		term BIGINT NOT NULL,
+		expires_at BIGINT NOT NULL
+	)`, table))
+	return err
+}
+
+// Acquire takes or renews a lease. Expiry times are stored as Unix
+// milliseconds of the callers' clocks, which must agree to well within the
+// leases' ttl.
+func (s *StoreCoordinator) Acquire(ctx context.Context, name, holder string, now time.Time, ttl time.Duration) (Lease, error) {
+	table, err := s.table()
+	if err != nil {
+		return Lease{}, err
+	}
+	_, err = s.Store.Execute(ctx, fmt.Sprintf(`INSERT INTO %[1]s (name, holder, term, expires_at) VALUES ($1, $2, 1, $3)
+		ON CONFLICT (name) DO UPDATE SET
+			term = CASE WHEN %[1]s.holder = $2 THEN %[1]s.term ELSE %[1]s.term + 1 END,
+			holder = $2,
+			expires_at = $3
+		WHERE %[1]s.holder = $2 OR %[1]s.expires_at <= $4`, table),
+		name, holder, unixMilli(now.Add(ttl)), unixMilli(now))
+	if err != nil {
+		return Lease{}, err
+	}
+	return s.Current(ctx, name)
+}
+
+// Release ends a lease if holder has it
+func (s *StoreCoordinator) Release(ctx context.Context, name, holder string) error {
+	table, err := s.table()
+	if err != nil {
+		return err
+	}
+	_, err = s.Store.Execute(ctx, fmt.Sprintf("UPDATE %s SET expires_at = 0 WHERE name = $1 AND holder = $2", table), name, holder)
+	return err
+}
+
+// Current returns a lease
+func (s *StoreCoordinator) Current(ctx context.Context, name string) (Lease, error) {
+	table, err := s.table()
+	if err != nil {
+		return Lease{}, err
+	}
+
+	// QueryEach, unlike Query, does not cache the rows
+	lease := Lease{Name: name}
+	err = s.Store.QueryEach(ctx, fmt.Sprintf("SELECT holder, term, expires_at FROM %s WHERE name = $1", table), func(row map[string]interface{}) error {
+		holder, _ := row["holder"].(string)
+		term, ok := row["term"].(int64)
+		expires, ok2 := row["expires_at"].(int64)
+		if !ok || !ok2 {
+			return fmt.Errorf("lease %s has an invalid term or expiry: %v", name, row)
+		}
+		lease.Holder, lease.Term = holder, term
+		if expires > 0 {
+			lease.Expires = time.Unix(0, expires*int64(time.Millisecond))
+		}
+		return nil
+	}, name)
+	return lease, err
+}
+
+// unixMilli returns a time in Unix milliseconds
+func unixMilli(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
@@ -0,0 +1,403 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/reqctx"
+)
+
+// QueryLogConfig controls the statement log SetLogger starts.
+type QueryLogConfig struct {
+	// Disabled stops logging without removing the logger
+	Disabled bool
+
+	// SampleRate is the share of statements logged, from 0 to 1. Requests
+	// are sampled by request ID, so all statements of a sampled request are
+	// logged. Failed statements and those slower than Slow are always
+	// logged.
+	SampleRate float64
+
+	// Slow is the duration from which statements are always logged, or 0
+	Slow time.Duration
+
+	// MaxLength truncates statements and arguments longer than it, or 0
+	MaxLength int
+
+	// Args logs statements' arguments, which are left out by default as
+	// they may hold personal data
+	Args bool
+
+	// Redact names the columns holding personal data, such as those of
+	// api.Schema.MaskedColumns. Arguments compared with, set or inserted
+	// into them are logged as [redacted], as are those whose column cannot
+	// be told from the statement, such as a function's. So are the errors
+	// of statements naming one, as a database's error may quote the row,
+	// such as a unique violation's Key (email)=(ada@example.com).
+	Redact []string
+}
+
+// DefaultQueryLogConfig logs every statement, without its arguments
+func DefaultQueryLogConfig() QueryLogConfig {
+	return QueryLogConfig{SampleRate: 1}
+}
+
+// queryLog is the statement log's logger and config
+type queryLog struct {
+	mu     sync.RWMutex
+	logger *log.Logger
+	config QueryLogConfig
+	redact map[string]bool
+}
+
+// SetLogger logs each statement run with Query, QueryEach and Execute, with
+// how long it took, whether it failed, and the request ID, tenant and
+// principal reqctx finds in its context, so a slow or failing statement can
+// be traced to the request that ran it. Arguments are not logged unless
+// SetQueryLog asks for them, as they may hold personal data. A nil logger
+// stops logging.
+func (db *GoScaleDB) SetLogger(logger *log.Logger) {
+	db.queryLog.mu.Lock()
+	db.queryLog.logger = logger
+	db.queryLog.mu.Unlock()
+}
+
+// SetQueryLog configures which statements the logger logs, and how
+func (db *GoScaleDB) SetQueryLog(config QueryLogConfig) {
+	redact := make(map[string]bool, len(config.Redact))
+	for _, column := range config.Redact {
+		redact[strings.ToLower(column)] = true
+	}
+	config.Redact = append([]string(nil), config.Redact...)
+
+	db.queryLog.mu.Lock()
+	db.queryLog.config = config
+	db.queryLog.redact = redact
+	db.queryLog.mu.Unlock()
+}
+
+// QueryLog returns the statement log's config
+func (db *GoScaleDB) QueryLog() QueryLogConfig {
+	db.queryLog.mu.RLock()
+	defer db.queryLog.mu.RUnlock()
+	config := db.queryLog.config
+	config.Redact = append([]string(nil), config.Redact...)
+	return config
+}
+
+// logStatement logs a statement if a logger is set and the config samples
+// it
+func (db *GoScaleDB) logStatement(ctx context.Context, query string, start time.Time, err error, args ...interface{}) {
+	elapsed := time.Since(start)
+
+	db.queryLog.mu.RLock()
+	logger, config, redact := db.queryLog.logger, db.queryLog.config, db.queryLog.redact
+	db.queryLog.mu.RUnlock()
+	if logger == nil || config.Disabled {
+		return
+	}
+	if err == nil && (config.Slow <= 0 || elapsed < config.Slow) && !sampled(ctx, config.SampleRate) {
+		return
+	}
+
+	line := reqctx.LogPrefix(ctx) + "db: " + truncate(query, config.MaxLength)
+	if config.Args && len(args) > 0 {
+		line += " " + formatArgs(query, args, redact, config.MaxLength)
+	}
+	line += " (" + elapsed.String() + ")"
+	if err != nil {
+		if namesColumn(query, redact) {
+			line += ": [redacted]"
+		} else {
+			line += ": " + err.Error()
+		}
+	}
+	logger.Print(line)
+}
+
+// namesColumn reports whether a statement names any of the columns,
+// quoted or not
+func namesColumn(query string, columns map[string]bool) bool {
+	if len(columns) == 0 {
+		return false
+	}
+	for _, name := range identifierToken.FindAllString(query, -1) {
+		if columns[strings.ToLower(name)] {
+			return true
+		}
+	}
+	return false
+}
+
+// sampled reports whether a statement of ctx's request falls in the sample
+func sampled(ctx context.Context, rate float64) bool {
+	switch {
+	case rate >= 1:
+		return true
+	case rate <= 0:
+		return false
+	}
+	id := reqctx.From(ctx).RequestID
+	if id == "" {
+		return rand.Float64() < rate
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(id))
+	return float64(hash.Sum32()%10000) < rate*10000
+}
+
+// truncate shortens text to max bytes, if max is positive
+func truncate(text string, max int) string {
+	if max <= 0 || len(text) <= max {
+		return text
+	}
+	return text[:max] + "..."
+}
+
+// formatArgs formats a statement's arguments as [$1=42 $2="ada"], redacting
+// those of the redacted columns. When any are redacted, so are those whose
+// column is not known, so redaction fails closed.
+func formatArgs(query string, args []interface{}, redact map[string]bool, max int) string {
+	columns := argColumns(query, len(args))
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		value := "[redacted]"
+		if len(redact) == 0 || (columns[i] != "" && !redact[columns[i]]) {
+			switch arg := arg.(type) {
+			case string:
+				value = strconv.Quote(truncate(arg, max))
+			case []byte:
+				value = fmt.Sprintf("<%d bytes>", len(arg))
+			default:
+				value = truncate(fmt.Sprint(arg), max)
+			}
+		}
+		parts[i] = fmt.Sprintf("$%d=%s", i+1, value)
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+var (
+	// comparedArg matches a column compared with or set to a placeholder,
+	// as in "email" = $1 or name LIKE ?
+	comparedArg = regexp.MustCompile(`(?i)"?([a-z_][a-z0-9_]*)"?\s*(?:=|<>|!=|<=|>=|<|>|\s(?:not\s+)?i?like\s)\s*(\$\d+|\?)`)
+
+	// inList matches a column compared with a list, as in id IN ($1, $2)
+	inList = regexp.MustCompile(`(?i)"?([a-z_][a-z0-9_]*)"?\s+(?:not\s+)?in\s*\(([^()]*)\)`)
+
+	// subquery matches the start of a subquery
+	subquery = regexp.MustCompile(`(?i)^\s*select\s`)
+
+	// insertColumns matches the columns of an INSERT statement, up to its
+	// VALUES
+	insertColumns = regexp.MustCompile(`(?is)insert\s+into\s+\S+\s*\(([^)]*)\)\s*values\s*`)
+
+	// placeholder matches the placeholders of a statement
+	placeholder = regexp.MustCompile(`\$\d+|\?`)
+
+	// identifierToken matches the words of a statement that could name a
+	// column
+	identifierToken = regexp.MustCompile(`(?i)[a-z_][a-z0-9_]*`)
+)
+
+// argColumns returns the lowercase column each of a statement's n arguments
+// is compared with, set or inserted into, or "" where it is not known
+func argColumns(query string, n int) []string {
+	columns := make([]string, n)
+
+	// The argument of each placeholder by its offset: $n's is the n-th, and
+	// ?'s are counted in order
+	args := make(map[int]int)
+	sequential := 0
+	for _, loc := range placeholder.FindAllStringIndex(query, -1) {
+		if p := query[loc[0]:loc[1]]; p == "?" {
+			args[loc[0]] = sequential
+			sequential++
+		} else {
+			index, _ := strconv.Atoi(p[1:])
+			args[loc[0]] = index - 1
+		}
+	}
+	set := func(offset int, column string) {
+		if index, ok := args[offset]; ok && index >= 0 && index < n && columns[index] == "" {
+			columns[index] = strings.ToLower(strings.Trim(strings.TrimSpace(column), `"`))
+		}
+	}
+	setAll := func(start, end int, column string) {
+		for _, loc := range placeholder.FindAllStringIndex(query[start:end], -1) {
+			set(start+loc[0], column)
+		}
+	}
+
+	if match := insertColumns.FindStringSubmatchIndex(query); match != nil {
+		names := strings.Split(query[match[2]:match[3]], ",")
+		valueTuples(query, match[1], func(index, start, end int) {
+			if index < len(names) {
+				setAll(start, end, names[index])
+			}
+		})
+	}
+	for _, match := range comparedArg.FindAllStringSubmatchIndex(query, -1) {
+		set(match[4], query[match[2]:match[3]])
+	}
+	for _, match := range inList.FindAllStringSubmatchIndex(query, -1) {
+		if !subquery.MatchString(query[match[4]:match[5]]) {
+			setAll(match[4], match[5], query[match[2]:match[3]])
+		}
+	}
+	return columns
+}
+
+// valueTuples calls fn with the index, start and end of each value of the
+// VALUES tuples at the start of query[start:], such as ($1, lower($2)),
+// ($3, $4), stopping at what follows them, such as ON CONFLICT
+func valueTuples(query string, start int, fn func(index, start, end int)) {
+	i := start
+	for {
+		for i < len(query) && (query[i] == ' ' || query[i] == '\t' || query[i] == '\n' || query[i] == '\r') {
+			i++
+		}
+		if i >= len(query) || query[i] != '(' {
+			return
+		}
+
+		depth, index, valueStart := 0, 0, i+1
+		var quote byte
+	tuple:
+		for ; i < len(query); i++ {
+			c := query[i]
+			switch {
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+			case c == '\'' || c == '"':
+				quote = c
+			case c == '(':
+				depth++
+			case c == ')':
+				if depth--; depth == 0 {
+					fn(index, valueStart, i)
+					i++
+					break tuple
+				}
+			case c == ',' && depth == 1:
+				fn(index, valueStart, i)
+				index++
+				valueStart = i + 1
+			}
+		}
+
+		for i < len(query) && (query[i] == ' ' || query[i] == '\t' || query[i] == '\n' || query[i] == '\r') {
+			i++
+		}
+		if i >= len(query) || query[i] != ',' {
+			return
+		}
+		i++
+	}
+}
+
+// queryLogRequest is the body of an update to the query log handler.
+// Fields left out keep their values.
+type queryLogRequest struct {
+	Disabled   *bool     `json:"disabled"`
+	SampleRate *float64  `json:"sample_rate"`
+	Slow       *string   `json:"slow"`
+	MaxLength  *int      `json:"max_length"`
+	Args       *bool     `json:"args"`
+	Redact     *[]string `json:"redact"`
+}
+
+// QueryLogHandler serves the statement log's config, so it can be turned on
+// or sampled more while debugging production without a redeploy: GET
+// returns it and PUT or PATCH updates the {"disabled", "sample_rate",
+// "slow", "max_length", "args", "redact"} of its JSON body, such as
+// {"sample_rate": 1, "args": true}. Turning on arguments logs data, so
+// authorize decides who may use it, such as by admin role; the others get
+// 403 Forbidden. A nil authorize refuses everyone.
+func (db *GoScaleDB) QueryLogHandler(authorize func(r *http.Request) bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authorize == nil || !authorize(r) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPatch:
+			var request queryLogRequest
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&request); err != nil {
+				http.Error(w, "invalid query log config: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			config, err := request.apply(db.QueryLog())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			db.SetQueryLog(config)
+		default:
+			w.Header().Set("Allow", "GET, PUT, PATCH")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		config := db.QueryLog()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"disabled":    config.Disabled,
+			"sample_rate": config.SampleRate,
+			"slow":        config.Slow.String(),
+			"max_length":  config.MaxLength,
+			"args":        config.Args,
+			"redact":      config.Redact,
+		})
+	})
+}
+
+// apply returns config with the request's fields set
+func (r *queryLogRequest) apply(config QueryLogConfig) (QueryLogConfig, error) {
+	if r.Disabled != nil {
+		config.Disabled = *r.Disabled
+	}
+	if r.SampleRate != nil {
+		if *r.SampleRate < 0 || *r.SampleRate > 1 {
+			return config, fmt.Errorf("invalid sample_rate %v; use 0 to 1", *r.SampleRate)
+		}
+		config.SampleRate = *r.SampleRate
+	}
+	if r.Slow != nil {
+		var slow time.Duration
+		if *r.Slow != "" {
+			var err error
+			if slow, err = time.ParseDuration(*r.Slow); err != nil || slow < 0 {
+				return config, fmt.Errorf("invalid slow %q", *r.Slow)
+			}
+		}
+		config.Slow = slow
+	}
+	if r.MaxLength != nil {
+		if *r.MaxLength < 0 {
+			return config, fmt.Errorf("invalid max_length %d", *r.MaxLength)
+		}
+		config.MaxLength = *r.MaxLength
+	}
+	if r.Args != nil {
+		config.Args = *r.Args
+	}
+	if r.Redact != nil {
+		config.Redact = *r.Redact
+	}
+	return config, nil
+}
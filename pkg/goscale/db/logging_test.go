@@ -0,0 +1,175 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/events"
+	"github.com/davidjeba/goscript/pkg/goscript/reqctx"
+)
+
+func TestStatementsLoggedWithTheirRequest(t *testing.T) {
+	db := NewGoScaleDB(nil)
+	var logs bytes.Buffer
+	db.SetLogger(log.New(&logs, "", 0))
+
+	ctx := reqctx.With(context.Background(), reqctx.Info{RequestID: "req-1", Tenant: "acme", Principal: "ada"})
+	db.logStatement(ctx, "SELECT * FROM posts", time.Now(), nil)
+	db.logStatement(context.Background(), "DELETE FROM posts", time.Now(), errors.New("locked"))
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "[req-1 tenant=acme user=ada] db: SELECT * FROM posts (") {
+		t.Fatalf("unexpected log output %q", logs.String())
+	}
+	if !strings.HasPrefix(lines[1], "db: DELETE FROM posts (") || !strings.HasSuffix(lines[1], "): locked") {
+		t.Fatalf("expected the failed statement logged, got %q", lines[1])
+	}
+}
+
+func TestChangeEventsCarryTheirRequest(t *testing.T) {
+	db := NewGoScaleDB(nil)
+	bus := events.NewBus()
+	published, unsubscribe := bus.SubscribeChan("db.public.posts.insert", 1)
+	defer unsubscribe()
+	db.SetEventBus(bus)
+
+	ctx := reqctx.With(context.Background(), reqctx.Info{RequestID: "req-1", Principal: "ada"})
+	db.publishChange(ctx, ChangeEvent{Schema: "public", Table: "posts", Operation: "insert", ID: 1, Rows: 1})
+	select {
+	case event := <-published:
+		var change ChangeEvent
+		if err := event.Decode(&change); err != nil || change.Request.Principal != "ada" || change.Request.RequestID != "req-1" {
+			t.Fatalf("expected the change made by ada, got %+v (%v)", change, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the change published")
+	}
+}
+
+func TestQueryLogSamplesAndRedacts(t *testing.T) {
+	db := NewGoScaleDB(nil)
+	var logs bytes.Buffer
+	db.SetLogger(log.New(&logs, "", 0))
+	db.SetQueryLog(QueryLogConfig{SampleRate: 0, Slow: time.Hour, MaxLength: 40, Args: true, Redact: []string{"Email"}})
+
+	// Unsampled statements are left out, unless they fail
+	db.logStatement(context.Background(), "SELECT 1", time.Now(), nil)
+	insert := `INSERT INTO "public"."users" ("name", "email") VALUES ($1, $2)`
+	db.logStatement(context.Background(), insert, time.Now(), errors.New("duplicate"), "Ada", "ada@example.com")
+	db.logStatement(context.Background(), `UPDATE users SET name = ? WHERE "email" = ?`, time.Now().Add(-2*time.Hour), nil, "Grace", "grace@example.com")
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected the failed and slow statements logged, got %q", logs.String())
+	}
+	if !strings.HasPrefix(lines[0], `db: INSERT INTO "public"."users" ("name", "e... [$1="Ada" $2=[redacted]] (`) || !strings.HasSuffix(lines[0], "): [redacted]") {
+		t.Fatalf("expected the insert truncated with its email and error redacted, got %q", lines[0])
+	}
+	if !strings.Contains(lines[1], `[$1="Grace" $2=[redacted]]`) {
+		t.Fatalf("expected the compared email redacted, got %q", lines[1])
+	}
+
+	// The errors of statements naming a redacted column may quote the row
+	logs.Reset()
+	duplicate := errors.New(`duplicate key value violates unique constraint "users_email_key": Key (email)=(ada@example.com) already exists`)
+	db.logStatement(context.Background(), `INSERT INTO users (name, "EMAIL") VALUES ($1, $2)`, time.Now(), duplicate, "Ada", "ada@example.com")
+	db.logStatement(context.Background(), `DELETE FROM posts WHERE id = $1`, time.Now(), errors.New("posts is locked"), 1)
+	if lines := strings.Split(strings.TrimSpace(logs.String()), "\n"); len(lines) != 2 || strings.Contains(lines[0], "ada@example.com") || !strings.HasSuffix(lines[0], "): [redacted]") {
+		t.Fatalf("expected the error of the email's insert redacted, got %q", logs.String())
+	} else if !strings.HasSuffix(lines[1], "): posts is locked") {
+		t.Fatalf("expected the error of a statement without redacted columns kept, got %q", lines[1])
+	}
+
+	// A request's statements are sampled together
+	db.SetQueryLog(QueryLogConfig{SampleRate: 0.5})
+	for _, id := range []string{"req-1", "req-2", "req-3", "req-4"} {
+		ctx := reqctx.With(context.Background(), reqctx.Info{RequestID: id})
+		logs.Reset()
+		db.logStatement(ctx, "SELECT 1", time.Now(), nil)
+		db.logStatement(ctx, "SELECT 2", time.Now(), nil, "secret")
+		if n := strings.Count(logs.String(), "\n"); n != 0 && n != 2 {
+			t.Fatalf("expected both or neither statement of %s logged, got %q", id, logs.String())
+		}
+		if strings.Contains(logs.String(), "secret") {
+			t.Fatalf("expected arguments left out by default, got %q", logs.String())
+		}
+	}
+}
+
+func TestArgColumns(t *testing.T) {
+	for _, test := range []struct {
+		query   string
+		columns []string
+	}{
+		{`INSERT INTO users (id, email) VALUES (?, ?) ON CONFLICT (id) DO UPDATE SET email = ?`, []string{"id", "email", "email"}},
+		{`INSERT INTO users ("id", "email", "name") VALUES ($1, lower($2), $3) ON CONFLICT ("id") DO UPDATE SET "name" = $3, "email" = excluded."email"`, []string{"id", "email", "name"}},
+		{`INSERT INTO users (id, email) VALUES ($1, $2), ($3, $4)`, []string{"id", "email", "id", "email"}},
+		{`SELECT * FROM posts WHERE author_id = (SELECT id FROM users WHERE email = $1) AND status = $2`, []string{"email", "status"}},
+		{`SELECT * FROM posts WHERE author_id IN (SELECT id FROM users WHERE email = ?) AND title LIKE ?`, []string{"email", "title"}},
+		{`DELETE FROM users WHERE id IN (?, ?, ?) AND email NOT IN ($4, $5) LIMIT ?`, []string{"id", "id", "id", "email", "email", ""}},
+		{`SELECT * FROM users WHERE u.email = ? OR id = ANY(?)`, []string{"email", ""}},
+	} {
+		if got := argColumns(test.query, len(test.columns)); strings.Join(got, ",") != strings.Join(test.columns, ",") {
+			t.Errorf("argColumns(%q) = %q, want %q", test.query, got, test.columns)
+		}
+	}
+}
+
+func TestRedactionFailsClosed(t *testing.T) {
+	redact := map[string]bool{"email": true}
+	upsert := `INSERT INTO users (id, name) VALUES (?, ?) ON CONFLICT (id) DO UPDATE SET email = ?`
+	if got := formatArgs(upsert, []interface{}{1, "Ada", "ada@example.com"}, redact, 0); got != `[$1=1 $2="Ada" $3=[redacted]]` {
+		t.Fatalf("expected the upserted email redacted, got %s", got)
+	}
+	unknown := `SELECT * FROM users WHERE id = $1 AND lower(email) = lower($2) LIMIT $3`
+	if got := formatArgs(unknown, []interface{}{1, "ada@example.com", 10}, redact, 0); got != `[$1=1 $2=[redacted] $3=[redacted]]` {
+		t.Fatalf("expected the arguments of unknown columns redacted, got %s", got)
+	}
+	if got := formatArgs(unknown, []interface{}{1, "ada@example.com", 10}, nil, 0); got != `[$1=1 $2="ada@example.com" $3=10]` {
+		t.Fatalf("expected every argument logged without a redact list, got %s", got)
+	}
+}
+
+func TestQueryLogHandler(t *testing.T) {
+	db := NewGoScaleDB(nil)
+	handler := db.QueryLogHandler(func(r *http.Request) bool { return r.Header.Get("X-Role") == "admin" })
+
+	for _, handler := range []http.Handler{handler, db.QueryLogHandler(nil)} {
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(`{"args": true}`)))
+		if response.Code != http.StatusForbidden || db.QueryLog().Args {
+			t.Fatalf("expected the update refused, got %d", response.Code)
+		}
+	}
+
+	request := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(`{"sample_rate": 0.1, "slow": "250ms", "args": true}`))
+	request.Header.Set("X-Role", "admin")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if config := db.QueryLog(); response.Code != http.StatusOK || config.SampleRate != 0.1 || config.Slow != 250*time.Millisecond || !config.Args {
+		t.Fatalf("expected the config updated, got %d %+v", response.Code, config)
+	}
+	if !strings.Contains(response.Body.String(), `"slow":"250ms"`) {
+		t.Fatalf("expected the config returned, got %s", response.Body)
+	}
+
+	for _, body := range []string{`{"sample_rate": 2}`, `{"slow": "soon"}`, `not json`} {
+		request := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body))
+		request.Header.Set("X-Role", "admin")
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		if response.Code != http.StatusBadRequest {
+			t.Fatalf("expected %s refused, got %d", body, response.Code)
+		}
+	}
+	if config := db.QueryLog(); config.SampleRate != 0.1 {
+		t.Fatalf("expected refused updates to keep the config, got %+v", config)
+	}
+}
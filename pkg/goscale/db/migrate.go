@@ -0,0 +1,497 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MigrationStep is a statement of a migration plan
+type MigrationStep struct {
+	// Kind is what the step does: create_schema, create_table, add_column,
+	// widen_column, drop_not_null or create_index
+	Kind  string
+	Table string
+	SQL   string
+}
+
+// MigrationPlan is the DDL bringing a database schema in line with a
+// desired one. Only changes that cannot lose data are planned; the others,
+// such as narrowing a column or dropping one, are listed in Skipped for a
+// hand-written migration.
+type MigrationPlan struct {
+	Schema  string
+	Steps   []MigrationStep
+	Skipped []string
+}
+
+// Empty reports whether the plan has nothing to apply
+func (p *MigrationPlan) Empty() bool {
+	return len(p.Steps) == 0
+}
+
+// String renders the plan as a SQL script, with the skipped changes as
+// comments, for review before ApplyMigration runs it
+func (p *MigrationPlan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- Migration plan for schema %s: %d steps\n", p.Schema, len(p.Steps))
+	for _, step := range p.Steps {
+		b.WriteString(step.SQL + ";\n")
+	}
+	for _, skipped := range p.Skipped {
+		b.WriteString("-- skipped: " + skipped + "\n")
+	}
+	return b.String()
+}
+
+// IntrospectSchema reads the tables, columns, primary keys and indexes of a
+// PostgreSQL schema, returning nil when the schema does not exist
+func (db *GoScaleDB) IntrospectSchema(ctx context.Context, name string) (*Schema, error) {
+	exists := false
+	err := db.QueryEach(ctx, "SELECT 1 AS found FROM information_schema.schemata WHERE schema_name = $1", func(row map[string]interface{}) error {
+		exists = true
+		return nil
+	}, name)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	schema := &Schema{Name: name, Tables: make(map[string]*Table), Version: 1}
+	table := func(name string) *Table {
+		t, ok := schema.Tables[name]
+		if !ok {
+			t = &Table{Name: name, Columns: make(map[string]*Column), Indexes: make(map[string]*Index)}
+			schema.Tables[name] = t
+		}
+		return t
+	}
+
+	err = db.QueryEach(ctx, `SELECT c.table_name, c.column_name, c.data_type, c.character_maximum_length,
+		c.numeric_precision, c.numeric_scale, c.is_nullable, c.column_default
+		FROM information_schema.columns c
+		JOIN information_schema.tables t ON t.table_schema = c.table_schema AND t.table_name = c.table_name
+		WHERE c.table_schema = $1 AND t.table_type = 'BASE TABLE'
+		ORDER BY c.table_name, c.ordinal_position`, func(row map[string]interface{}) error {
+		columnType := fmt.Sprint(row["data_type"])
+		switch columnType {
+		case "character varying", "character":
+			if length := toInt(row["character_maximum_length"]); length > 0 {
+				columnType = fmt.Sprintf("%s(%d)", columnType, length)
+			}
+		case "numeric":
+			if precision := toInt(row["numeric_precision"]); precision > 0 {
+				columnType = fmt.Sprintf("numeric(%d,%d)", precision, toInt(row["numeric_scale"]))
+			}
+		}
+		column := &Column{
+			Name:     fmt.Sprint(row["column_name"]),
+			Type:     NormalizeColumnType(columnType),
+			Nullable: row["is_nullable"] == "YES",
+		}
+		if expression, ok := row["column_default"].(string); ok {
+			column.Default = DefaultExpression(expression)
+		}
+		table(fmt.Sprint(row["table_name"])).Columns[column.Name] = column
+		return nil
+	}, name)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.QueryEach(ctx, `SELECT tc.table_name, kcu.column_name
+		FROM information_schema.table_constraints tc
+		JOIN information_schema.key_column_usage kcu
+			ON kcu.constraint_name = tc.constraint_name AND kcu.table_schema = tc.table_schema
+		WHERE tc.table_schema = $1 AND tc.constraint_type = 'PRIMARY KEY'`, func(row map[string]interface{}) error {
+		table(fmt.Sprint(row["table_name"])).PrimaryKey = fmt.Sprint(row["column_name"])
+		return nil
+	}, name)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.QueryEach(ctx, `SELECT tbl.relname AS table_name, idx.relname AS index_name,
+		ix.indisunique AS is_unique, a.attname AS column_name
+		FROM pg_index ix
+		JOIN pg_class tbl ON tbl.oid = ix.indrelid
+		JOIN pg_class idx ON idx.oid = ix.indexrelid
+		JOIN pg_namespace ns ON ns.oid = tbl.relnamespace
+		JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, position) ON TRUE
+		JOIN pg_attribute a ON a.attrelid = tbl.oid AND a.attnum = k.attnum
+		WHERE ns.nspname = $1 AND NOT ix.indisprimary
+		ORDER BY tbl.relname, idx.relname, k.position`, func(row map[string]interface{}) error {
+		t := table(fmt.Sprint(row["table_name"]))
+		indexName := fmt.Sprint(row["index_name"])
+		index, ok := t.Indexes[indexName]
+		if !ok {
+			unique, _ := row["is_unique"].(bool)
+			index = &Index{Name: indexName, Unique: unique}
+			t.Indexes[indexName] = index
+		}
+		index.Columns = append(index.Columns, fmt.Sprint(row["column_name"]))
+		return nil
+	}, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
+
+// toInt converts a number scanned from the database
+func toInt(value interface{}) int {
+	switch v := value.(type) {
+	case int64:
+		return int(v)
+	case int:
+		return v
+	case float64:
+		return int(v)
+	case string:
+		n, _ := strconv.Atoi(v)
+		return n
+	}
+	return 0
+}
+
+// columnTypeAliases maps PostgreSQL type names to the ones plans use
+var columnTypeAliases = map[string]string{
+	"int":                         "integer",
+	"int4":                        "integer",
+	"serial":                      "integer",
+	"int8":                        "bigint",
+	"bigserial":                   "bigint",
+	"int2":                        "smallint",
+	"float4":                      "real",
+	"float8":                      "double precision",
+	"float":                       "double precision",
+	"bool":                        "boolean",
+	"decimal":                     "numeric",
+	"character varying":           "varchar",
+	"character":                   "char",
+	"timestamp without time zone": "timestamp",
+	"timestamp with time zone":    "timestamptz",
+}
+
+// typeModifier splits a type such as "varchar(255)" into its name and
+// modifiers
+var typeModifier = regexp.MustCompile(`^([a-z0-9 ]+?)\s*(\(\s*[0-9, ]+\))?$`)
+
+// NormalizeColumnType lowercases a column type and replaces aliases, such as
+// int8 for bigint, so types written either way compare equal
+func NormalizeColumnType(columnType string) string {
+	columnType = strings.Join(strings.Fields(strings.ToLower(columnType)), " ")
+	match := typeModifier.FindStringSubmatch(columnType)
+	if match == nil {
+		return columnType
+	}
+	name := match[1]
+	if alias, ok := columnTypeAliases[name]; ok {
+		name = alias
+	}
+	return name + strings.Replace(match[2], " ", "", -1)
+}
+
+// typeArgs returns the name of a normalized type and its modifiers, such as
+// "numeric" and [10 2] for "numeric(10,2)"
+func typeArgs(columnType string) (string, []int) {
+	open := strings.Index(columnType, "(")
+	if open < 0 {
+		return columnType, nil
+	}
+	var args []int
+	for _, arg := range strings.Split(strings.TrimSuffix(columnType[open+1:], ")"), ",") {
+		n, _ := strconv.Atoi(arg)
+		args = append(args, n)
+	}
+	return columnType[:open], args
+}
+
+// integerWidth orders the integer types by the values they hold
+var integerWidth = map[string]int{"smallint": 1, "integer": 2, "bigint": 3}
+
+// SafeWidening reports whether a column can change from one type to another
+// without losing or changing any value it holds, such as integer to bigint
+// or varchar(50) to text
+func SafeWidening(from, to string) bool {
+	from, to = NormalizeColumnType(from), NormalizeColumnType(to)
+	if from == to {
+		return true
+	}
+	fromName, fromArgs := typeArgs(from)
+	toName, toArgs := typeArgs(to)
+
+	switch {
+	case integerWidth[fromName] > 0 && integerWidth[toName] > 0:
+		return integerWidth[fromName] < integerWidth[toName]
+	case integerWidth[fromName] > 0 && toName == "numeric":
+		// Unbounded, or with enough digits before the point
+		digits := map[string]int{"smallint": 5, "integer": 10, "bigint": 19}[fromName]
+		return len(toArgs) == 0 || toArgs[0]-scale(toArgs) >= digits
+	case fromName == "real" && toName == "double precision":
+		return true
+	case fromName == "numeric" && toName == "numeric":
+		if len(toArgs) == 0 {
+			return true
+		}
+		if len(fromArgs) == 0 {
+			return false
+		}
+		return scale(toArgs) >= scale(fromArgs) && toArgs[0]-scale(toArgs) >= fromArgs[0]-scale(fromArgs)
+	case fromName == "varchar" || fromName == "char":
+		if toName == "text" || (toName == "varchar" && len(toArgs) == 0) {
+			// char pads values with spaces, which text keeps
+			return fromName == "varchar"
+		}
+		return toName == fromName && len(fromArgs) > 0 && len(toArgs) > 0 && toArgs[0] >= fromArgs[0]
+	}
+	return false
+}
+
+// scale is the scale of numeric modifiers, 0 when not given
+func scale(args []int) int {
+	if len(args) > 1 {
+		return args[1]
+	}
+	return 0
+}
+
+// DefaultExpression is a column default written in SQL, such as now() or
+// nextval('posts_id_seq'), rather than a value. IntrospectSchema returns
+// defaults as expressions; any other Default is a value, quoted unless it is
+// a number or a boolean. Expressions are put in DDL as written, so they must
+// never come from a request.
+type DefaultExpression string
+
+// defaultValue renders a column default in SQL
+func defaultValue(value interface{}) string {
+	switch v := value.(type) {
+	case DefaultExpression:
+		return string(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case []byte:
+		return quoteLiteral(string(v))
+	}
+	return quoteLiteral(fmt.Sprint(value))
+}
+
+// columnDefinition is the DDL of a column, as CREATE TABLE and ADD COLUMN
+// take it
+func columnDefinition(column *Column, primaryKey bool) string {
+	definition := quoteIdent(column.Name) + " " + column.Type
+	if !column.Nullable {
+		definition += " NOT NULL"
+	}
+	if column.Default != nil {
+		definition += " DEFAULT " + defaultValue(column.Default)
+	}
+	if primaryKey {
+		definition += " PRIMARY KEY"
+	}
+	return definition
+}
+
+// validateColumns checks the names and types of a table's columns, which
+// columnDefinition puts in DDL
+func validateColumns(table *Table) error {
+	for _, column := range table.Columns {
+		if err := ValidateIdentifier(column.Name); err != nil {
+			return err
+		}
+		if err := ValidateColumnType(column.Type); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedColumns returns a table's columns, the primary key first and the
+// others by name, so plans are the same from run to run
+func sortedColumns(table *Table) []*Column {
+	var columns []*Column
+	for _, column := range table.Columns {
+		columns = append(columns, column)
+	}
+	sort.Slice(columns, func(i, j int) bool {
+		if (columns[i].Name == table.PrimaryKey) != (columns[j].Name == table.PrimaryKey) {
+			return columns[i].Name == table.PrimaryKey
+		}
+		return columns[i].Name < columns[j].Name
+	})
+	return columns
+}
+
+// DiffSchema plans the migration from the current schema, as
+// IntrospectSchema returns it, to the desired one. Tables, columns and
+// indexes the database has beyond the desired schema are left alone.
+func DiffSchema(current, desired *Schema) *MigrationPlan {
+	plan := &MigrationPlan{Schema: desired.Name}
+	qualified := func(table string) string {
+		return quoteIdent(desired.Name) + "." + quoteIdent(table)
+	}
+	if current == nil {
+		plan.Steps = append(plan.Steps, MigrationStep{
+			Kind: "create_schema",
+			SQL:  "CREATE SCHEMA IF NOT EXISTS " + quoteIdent(desired.Name),
+		})
+		current = &Schema{Name: desired.Name, Tables: map[string]*Table{}}
+	}
+
+	var tableNames []string
+	for name := range desired.Tables {
+		tableNames = append(tableNames, name)
+	}
+	sort.Strings(tableNames)
+
+	for _, tableName := range tableNames {
+		want := desired.Tables[tableName]
+		have, exists := current.Tables[tableName]
+		if err := validateColumns(want); err != nil {
+			plan.Skipped = append(plan.Skipped, fmt.Sprintf("%s: %v", tableName, err))
+			continue
+		}
+
+		if !exists {
+			var definitions []string
+			for _, column := range sortedColumns(want) {
+				definitions = append(definitions, columnDefinition(column, column.Name == want.PrimaryKey))
+			}
+			plan.Steps = append(plan.Steps, MigrationStep{
+				Kind:  "create_table",
+				Table: tableName,
+				SQL:   fmt.Sprintf("CREATE TABLE %s (%s)", qualified(tableName), strings.Join(definitions, ", ")),
+			})
+			have = &Table{Name: tableName, Columns: map[string]*Column{}, Indexes: map[string]*Index{}, PrimaryKey: want.PrimaryKey}
+			for name, column := range want.Columns {
+				have.Columns[name] = column
+			}
+		}
+
+		for _, column := range sortedColumns(want) {
+			existing, ok := have.Columns[column.Name]
+			if !ok {
+				if !column.Nullable && column.Default == nil {
+					plan.Skipped = append(plan.Skipped, fmt.Sprintf("%s.%s: a NOT NULL column needs a default to be added to existing rows", tableName, column.Name))
+					continue
+				}
+				plan.Steps = append(plan.Steps, MigrationStep{
+					Kind:  "add_column",
+					Table: tableName,
+					SQL:   fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", qualified(tableName), columnDefinition(column, false)),
+				})
+				continue
+			}
+
+			from, to := NormalizeColumnType(existing.Type), NormalizeColumnType(column.Type)
+			if from != to {
+				if SafeWidening(from, to) {
+					plan.Steps = append(plan.Steps, MigrationStep{
+						Kind:  "widen_column",
+						Table: tableName,
+						SQL:   fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s", qualified(tableName), quoteIdent(column.Name), to),
+					})
+				} else {
+					plan.Skipped = append(plan.Skipped, fmt.Sprintf("%s.%s: changing %s to %s may lose data", tableName, column.Name, from, to))
+				}
+			}
+			if column.Nullable && !existing.Nullable && column.Name != have.PrimaryKey {
+				plan.Steps = append(plan.Steps, MigrationStep{
+					Kind:  "drop_not_null",
+					Table: tableName,
+					SQL:   fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL", qualified(tableName), quoteIdent(column.Name)),
+				})
+			} else if !column.Nullable && existing.Nullable {
+				plan.Skipped = append(plan.Skipped, fmt.Sprintf("%s.%s: making the column NOT NULL fails if rows hold NULL", tableName, column.Name))
+			}
+		}
+
+		if exists && want.PrimaryKey != "" && want.PrimaryKey != have.PrimaryKey {
+			plan.Skipped = append(plan.Skipped, fmt.Sprintf("%s: changing the primary key from %q to %q", tableName, have.PrimaryKey, want.PrimaryKey))
+		}
+
+		var indexNames []string
+		for name := range want.Indexes {
+			indexNames = append(indexNames, name)
+		}
+		sort.Strings(indexNames)
+		for _, indexName := range indexNames {
+			index := want.Indexes[indexName]
+			if existing, ok := have.Indexes[indexName]; ok {
+				if existing.Unique != index.Unique || strings.Join(existing.Columns, ",") != strings.Join(index.Columns, ",") {
+					plan.Skipped = append(plan.Skipped, fmt.Sprintf("%s: index %s differs from the one in the database", tableName, indexName))
+				}
+				continue
+			}
+			unique := ""
+			if index.Unique {
+				unique = "UNIQUE "
+			}
+			var columns []string
+			for _, column := range index.Columns {
+				columns = append(columns, quoteIdent(column))
+			}
+			plan.Steps = append(plan.Steps, MigrationStep{
+				Kind:  "create_index",
+				Table: tableName,
+				SQL:   fmt.Sprintf("CREATE %sINDEX IF NOT EXISTS %s ON %s (%s)", unique, quoteIdent(indexName), qualified(tableName), strings.Join(columns, ", ")),
+			})
+		}
+	}
+	return plan
+}
+
+// PlanMigration introspects the database's schema of the desired schema's
+// name and plans the migration to it
+func (db *GoScaleDB) PlanMigration(ctx context.Context, desired *Schema) (*MigrationPlan, error) {
+	current, err := db.IntrospectSchema(ctx, desired.Name)
+	if err != nil {
+		return nil, fmt.Errorf("introspecting schema %s: %v", desired.Name, err)
+	}
+	return DiffSchema(current, desired), nil
+}
+
+// ApplyMigration runs a plan's steps in one transaction, so a failing step
+// leaves the database as it was, and then keeps the schema for GetTable,
+// Insert and the other table methods
+func (db *GoScaleDB) ApplyMigration(ctx context.Context, plan *MigrationPlan) error {
+	db.migrationLock.Lock()
+	defer db.migrationLock.Unlock()
+
+	if !plan.Empty() {
+		err := db.Transaction(ctx, func(tx *sql.Tx) error {
+			for _, step := range plan.Steps {
+				if _, err := tx.ExecContext(ctx, step.SQL); err != nil {
+					return fmt.Errorf("%s: %v", step.SQL, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		db.cacheMutex.Lock()
+		db.queryCache = make(map[string]*CachedQuery)
+		db.cacheMutex.Unlock()
+	}
+
+	current, err := db.IntrospectSchema(ctx, plan.Schema)
+	if err != nil || current == nil {
+		return err
+	}
+	db.schemaMutex.Lock()
+	db.schemas[plan.Schema] = current
+	db.schemaMutex.Unlock()
+	return nil
+}
@@ -0,0 +1,193 @@
+package db
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeColumnType(t *testing.T) {
+	for columnType, want := range map[string]string{
+		"INT8":                        "bigint",
+		"int":                         "integer",
+		"Character Varying(255)":      "varchar(255)",
+		"numeric( 10, 2 )":            "numeric(10,2)",
+		"decimal(10,2)":               "numeric(10,2)",
+		"timestamp  with time zone":   "timestamptz",
+		"timestamp without time zone": "timestamp",
+		"double precision":            "double precision",
+		"text":                        "text",
+	} {
+		if got := NormalizeColumnType(columnType); got != want {
+			t.Errorf("NormalizeColumnType(%q) = %q, want %q", columnType, got, want)
+		}
+	}
+}
+
+func TestSafeWidening(t *testing.T) {
+	for _, test := range []struct {
+		from, to string
+		safe     bool
+	}{
+		{"integer", "int4", true},
+		{"smallint", "integer", true},
+		{"integer", "bigint", true},
+		{"bigint", "integer", false},
+		{"integer", "numeric", true},
+		{"integer", "numeric(10)", true},
+		{"integer", "numeric(10,2)", false},
+		{"bigint", "numeric(21,2)", true},
+		{"real", "double precision", true},
+		{"double precision", "real", false},
+		{"numeric(10,2)", "numeric(12,2)", true},
+		{"numeric(10,2)", "numeric(10,3)", false},
+		{"numeric(10,2)", "numeric", true},
+		{"numeric", "numeric(38,4)", false},
+		{"varchar(50)", "varchar(100)", true},
+		{"varchar(100)", "varchar(50)", false},
+		{"varchar(50)", "text", true},
+		{"varchar(50)", "varchar", true},
+		{"char(10)", "text", false},
+		{"char(10)", "char(20)", true},
+		{"text", "varchar(255)", false},
+		{"integer", "text", false},
+	} {
+		if got := SafeWidening(test.from, test.to); got != test.safe {
+			t.Errorf("SafeWidening(%q, %q) = %v, want %v", test.from, test.to, got, test.safe)
+		}
+	}
+}
+
+// table builds a table of columns
+func table(name, primaryKey string, columns ...*Column) *Table {
+	t := &Table{Name: name, Columns: map[string]*Column{}, Indexes: map[string]*Index{}, PrimaryKey: primaryKey}
+	for _, column := range columns {
+		t.Columns[column.Name] = column
+	}
+	return t
+}
+
+func TestDiffSchema(t *testing.T) {
+	posts := func(columns ...*Column) *Table {
+		return table("posts", "id", append([]*Column{{Name: "id", Type: "bigint"}}, columns...)...)
+	}
+
+	for _, test := range []struct {
+		name    string
+		current *Table
+		desired *Table
+		steps   []string
+		skipped []string
+	}{
+		{
+			name:    "create table",
+			desired: posts(&Column{Name: "title", Type: "text", Default: "Untitled"}),
+			steps:   []string{`CREATE TABLE "app"."posts" ("id" bigint NOT NULL PRIMARY KEY, "title" text NOT NULL DEFAULT 'Untitled')`},
+		},
+		{
+			name:    "up to date",
+			current: posts(&Column{Name: "title", Type: "varchar(255)", Nullable: true}),
+			desired: posts(&Column{Name: "title", Type: "character varying(255)", Nullable: true}),
+		},
+		{
+			name:    "add columns",
+			current: posts(),
+			desired: posts(
+				&Column{Name: "views", Type: "integer", Default: 0},
+				&Column{Name: "summary", Type: "text", Nullable: true},
+				&Column{Name: "slug", Type: "text"},
+			),
+			steps: []string{
+				`ALTER TABLE "app"."posts" ADD COLUMN "summary" text`,
+				`ALTER TABLE "app"."posts" ADD COLUMN "views" integer NOT NULL DEFAULT 0`,
+			},
+			skipped: []string{"posts.slug: a NOT NULL column needs a default to be added to existing rows"},
+		},
+		{
+			name:    "change types",
+			current: posts(&Column{Name: "views", Type: "integer"}, &Column{Name: "title", Type: "text"}),
+			desired: posts(&Column{Name: "views", Type: "bigint"}, &Column{Name: "title", Type: "varchar(80)"}),
+			steps:   []string{`ALTER TABLE "app"."posts" ALTER COLUMN "views" TYPE bigint`},
+			skipped: []string{"posts.title: changing text to varchar(80) may lose data"},
+		},
+		{
+			name:    "change nullability",
+			current: posts(&Column{Name: "title", Type: "text"}, &Column{Name: "body", Type: "text", Nullable: true}),
+			desired: posts(&Column{Name: "title", Type: "text", Nullable: true}, &Column{Name: "body", Type: "text"}),
+			steps:   []string{`ALTER TABLE "app"."posts" ALTER COLUMN "title" DROP NOT NULL`},
+			skipped: []string{"posts.body: making the column NOT NULL fails if rows hold NULL"},
+		},
+		{
+			name:    "invalid type",
+			desired: posts(&Column{Name: "title", Type: "text, \"admin\" boolean"}),
+			skipped: []string{`posts: invalid column type "text, \"admin\" boolean"`},
+		},
+		{
+			name:    "change primary key",
+			current: table("posts", "id", &Column{Name: "id", Type: "bigint"}, &Column{Name: "slug", Type: "text"}),
+			desired: table("posts", "slug", &Column{Name: "id", Type: "bigint"}, &Column{Name: "slug", Type: "text"}),
+			skipped: []string{`posts: changing the primary key from "id" to "slug"`},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			current := &Schema{Name: "app", Tables: map[string]*Table{}}
+			if test.current != nil {
+				current.Tables["posts"] = test.current
+			}
+			plan := DiffSchema(current, &Schema{Name: "app", Tables: map[string]*Table{"posts": test.desired}})
+
+			var steps []string
+			for _, step := range plan.Steps {
+				steps = append(steps, step.SQL)
+			}
+			if strings.Join(steps, "\n") != strings.Join(test.steps, "\n") {
+				t.Fatalf("unexpected steps:\n%s", strings.Join(steps, "\n"))
+			}
+			if strings.Join(plan.Skipped, "\n") != strings.Join(test.skipped, "\n") {
+				t.Fatalf("unexpected skipped changes:\n%s", strings.Join(plan.Skipped, "\n"))
+			}
+		})
+	}
+}
+
+func TestDiffSchemaCreatesSchemaAndIndexes(t *testing.T) {
+	desired := &Schema{Name: "app", Tables: map[string]*Table{
+		"users": table("users", "id", &Column{Name: "id", Type: "bigint"}, &Column{Name: "email", Type: "text"}),
+	}}
+	desired.Tables["users"].Indexes["users_email"] = &Index{Name: "users_email", Columns: []string{"email"}, Unique: true}
+
+	plan := DiffSchema(nil, desired)
+	if len(plan.Steps) != 3 || plan.Steps[0].Kind != "create_schema" || plan.Steps[1].Kind != "create_table" {
+		t.Fatalf("unexpected plan %v", plan.Steps)
+	}
+	if index := plan.Steps[2].SQL; index != `CREATE UNIQUE INDEX IF NOT EXISTS "users_email" ON "app"."users" ("email")` {
+		t.Fatalf("unexpected index %s", index)
+	}
+
+	current := &Schema{Name: "app", Tables: map[string]*Table{
+		"users": table("users", "id", &Column{Name: "id", Type: "bigint"}, &Column{Name: "email", Type: "text"}),
+	}}
+	current.Tables["users"].Indexes["users_email"] = &Index{Name: "users_email", Columns: []string{"email"}}
+	plan = DiffSchema(current, desired)
+	if !plan.Empty() || len(plan.Skipped) != 1 || !strings.Contains(plan.Skipped[0], "index users_email differs") {
+		t.Fatalf("expected the differing index skipped, got %v %v", plan.Steps, plan.Skipped)
+	}
+}
+
+func TestColumnDefault(t *testing.T) {
+	for _, test := range []struct {
+		value interface{}
+		want  string
+	}{
+		{"draft", `"status" text NOT NULL DEFAULT 'draft'`},
+		{"x'); DROP TABLE users; --", `"status" text NOT NULL DEFAULT 'x''); DROP TABLE users; --'`},
+		{[]byte("draft"), `"status" text NOT NULL DEFAULT 'draft'`},
+		{0, `"status" text NOT NULL DEFAULT 0`},
+		{2.5, `"status" text NOT NULL DEFAULT 2.5`},
+		{false, `"status" text NOT NULL DEFAULT false`},
+		{DefaultExpression("now()"), `"status" text NOT NULL DEFAULT now()`},
+	} {
+		if got := columnDefinition(&Column{Name: "status", Type: "text", Default: test.value}, false); got != test.want {
+			t.Errorf("default %#v: got %s, want %s", test.value, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// SQLiteDriver names the database/sql driver OpenSQLite uses. GoScaleDB
+// does not ship one, to stay free of cgo and dependencies: the app registers
+// it by importing one, such as github.com/mattn/go-sqlite3 for "sqlite3" or
+// modernc.org/sqlite for "sqlite".
+var SQLiteDriver = "sqlite3"
+
+// SQLiteStore is an embedded SQLite database implementing Store, for data
+// kept on the machine itself, such as an edge node's cache and the writes
+// it holds while its origin is unreachable, without a PostgreSQL server.
+type SQLiteStore struct {
+	conn *sql.DB
+	path string
+}
+
+// OpenSQLite opens the SQLite database at path, creating it if it does not
+// exist, or an in-memory one for ":memory:". The database is journaled
+// with a write-ahead log, so reads do not wait for writes. It fails unless
+// the app registered the SQLiteDriver driver.
+func OpenSQLite(path string) (*SQLiteStore, error) {
+	if !driverRegistered(SQLiteDriver) {
+		return nil, fmt.Errorf("db: no %q driver registered; import a SQLite driver, such as github.com/mattn/go-sqlite3, or set SQLiteDriver", SQLiteDriver)
+	}
+	conn, err := sql.Open(SQLiteDriver, path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; one connection queues writes here
+	// instead of failing them with SQLITE_BUSY, and shares an in-memory
+	// database between statements
+	conn.SetMaxOpenConns(1)
+
+	for _, pragma := range []string{"PRAGMA journal_mode = WAL", "PRAGMA busy_timeout = 5000", "PRAGMA foreign_keys = ON"} {
+		if _, err := conn.Exec(pragma); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return &SQLiteStore{conn: conn, path: path}, nil
+}
+
+// driverRegistered reports whether a database/sql driver is registered
+func driverRegistered(name string) bool {
+	for _, driver := range sql.Drivers() {
+		if driver == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Path returns the path the database was opened at
+func (s *SQLiteStore) Path() string {
+	return s.path
+}
+
+// Query runs a query and returns its rows
+func (s *SQLiteStore) Query(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	var result []map[string]interface{}
+	err := s.QueryEach(ctx, query, func(row map[string]interface{}) error {
+		result = append(result, row)
+		return nil
+	}, args...)
+	return result, err
+}
+
+// QueryEach runs a query and calls fn with each row as it is read. An
+// error from fn stops the query and is returned.
+func (s *SQLiteStore) QueryEach(ctx context.Context, query string, fn func(row map[string]interface{}) error, args ...interface{}) error {
+	rows, err := s.conn.QueryContext(ctx, sqlitePlaceholders(query), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		row, err := scanRow(rows, columns)
+		if err == nil {
+			err = fn(row)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Execute runs a statement and returns how many rows it changed
+func (s *SQLiteStore) Execute(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	result, err := s.conn.ExecContext(ctx, sqlitePlaceholders(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Transaction runs fn in a transaction, committing it unless fn fails.
+// Statements run on the transaction take SQLite's ?1, ?2 placeholders.
+func (s *SQLiteStore) Transaction(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Close closes the database
+func (s *SQLiteStore) Close() error {
+	return s.conn.Close()
+}
+
+// sqlitePlaceholders rewrites PostgreSQL's $1 placeholders as SQLite's ?1,
+// leaving quoted strings and identifiers alone
+func sqlitePlaceholders(query string) string {
+	if !strings.Contains(query, "$") {
+		return query
+	}
+
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '$' && i+1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9':
+			c = '?'
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
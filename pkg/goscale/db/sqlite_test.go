@@ -0,0 +1,225 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSQLite is a database/sql driver standing in for a SQLite one: it
+// records the statements run, with their arguments, and answers every
+// query with rows of id and name.
+type fakeSQLite struct {
+	mutex      sync.Mutex
+	statements []string
+	args       [][]driver.Value
+}
+
+func (d *fakeSQLite) Open(name string) (driver.Conn, error) {
+	return &fakeConn{driver: d}, nil
+}
+
+func (d *fakeSQLite) record(query string, args []driver.Value) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.statements = append(d.statements, query)
+	d.args = append(d.args, args)
+}
+
+func (d *fakeSQLite) recorded() []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return append([]string(nil), d.statements...)
+}
+
+type fakeConn struct{ driver *fakeSQLite }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.driver.record("BEGIN", nil)
+	return fakeTx{c}, nil
+}
+
+type fakeTx struct{ conn *fakeConn }
+
+func (tx fakeTx) Commit() error {
+	tx.conn.driver.record("COMMIT", nil)
+	return nil
+}
+
+func (tx fakeTx) Rollback() error {
+	tx.conn.driver.record("ROLLBACK", nil)
+	return nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.conn.driver.record(s.query, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.conn.driver.record(s.query, args)
+	return &fakeRows{rows: [][]driver.Value{{int64(1), "Ada"}, {int64(2), "Grace"}}}, nil
+}
+
+type fakeRows struct{ rows [][]driver.Value }
+
+func (r *fakeRows) Columns() []string { return []string{"id", "name"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+var (
+	fakeSQLiteDriver = &fakeSQLite{}
+	registerFake     sync.Once
+)
+
+// withFakeSQLite has OpenSQLite use the fake driver for the test
+func withFakeSQLite(t *testing.T) *fakeSQLite {
+	registerFake.Do(func() { sql.Register("fake-sqlite", fakeSQLiteDriver) })
+	driver := SQLiteDriver
+	SQLiteDriver = "fake-sqlite"
+	t.Cleanup(func() { SQLiteDriver = driver })
+
+	fakeSQLiteDriver.mutex.Lock()
+	fakeSQLiteDriver.statements, fakeSQLiteDriver.args = nil, nil
+	fakeSQLiteDriver.mutex.Unlock()
+	return fakeSQLiteDriver
+}
+
+func TestOpenSQLiteWithoutDriver(t *testing.T) {
+	driver := SQLiteDriver
+	SQLiteDriver = "unregistered-sqlite"
+	defer func() { SQLiteDriver = driver }()
+
+	if _, err := OpenSQLite(":memory:"); err == nil || !strings.Contains(err.Error(), `no "unregistered-sqlite" driver registered`) {
+		t.Fatalf("expected a missing driver reported, got %v", err)
+	}
+}
+
+func TestSQLiteStore(t *testing.T) {
+	fake := withFakeSQLite(t)
+	ctx := context.Background()
+
+	store, err := OpenSQLite("edge.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if store.Path() != "edge.db" {
+		t.Fatalf("unexpected path %q", store.Path())
+	}
+
+	if _, err := store.Execute(ctx, "UPDATE posts SET title = $1 WHERE note = '$2' AND id = $2", "Hello", 7); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := store.Query(ctx, `SELECT id, name FROM "$1" WHERE id > $1`, 0)
+	if err != nil || len(rows) != 2 || rows[0]["id"] != int64(1) || rows[1]["name"] != "Grace" {
+		t.Fatalf("unexpected rows %v (%v)", rows, err)
+	}
+
+	want := []string{
+		"PRAGMA journal_mode = WAL",
+		"PRAGMA busy_timeout = 5000",
+		"PRAGMA foreign_keys = ON",
+		"UPDATE posts SET title = ?1 WHERE note = '$2' AND id = ?2",
+		`SELECT id, name FROM "$1" WHERE id > ?1`,
+	}
+	if got := fake.recorded(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected statements:\n%s", strings.Join(got, "\n"))
+	}
+	if args := fake.args[3]; len(args) != 2 || args[0] != "Hello" || args[1] != int64(7) {
+		t.Fatalf("unexpected arguments %v", args)
+	}
+}
+
+func TestSQLiteTransaction(t *testing.T) {
+	fake := withFakeSQLite(t)
+	ctx := context.Background()
+	store, err := OpenSQLite(":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	err = store.Transaction(ctx, func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT INTO posts (title) VALUES (?1)", "Hello")
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := errors.New("failed")
+	if err := store.Transaction(ctx, func(tx *sql.Tx) error { return failed }); err != failed {
+		t.Fatalf("expected the transaction's error, got %v", err)
+	}
+
+	got := strings.Join(fake.recorded()[3:], "\n")
+	if want := "BEGIN\nINSERT INTO posts (title) VALUES (?1)\nCOMMIT\nBEGIN\nROLLBACK"; got != want {
+		t.Fatalf("unexpected statements:\n%s", got)
+	}
+}
+
+func TestCreateTable(t *testing.T) {
+	fake := withFakeSQLite(t)
+	conn, err := sql.Open("fake-sqlite", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := NewGoScaleDB(nil)
+	db.conn = conn
+	if _, err := db.CreateSchema("app"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.CreateTable("app", "posts", map[string]*Column{
+		"id":      {Type: "bigint"},
+		"status":  {Type: "text", Default: "draft'); DROP TABLE users; --"},
+		"created": {Type: "timestamptz", Default: DefaultExpression("now()")},
+		"summary": {Type: "text", Nullable: true},
+	}, "id"); err != nil {
+		t.Fatal(err)
+	}
+	want := `CREATE TABLE "app"."posts" ("id" bigint NOT NULL PRIMARY KEY, "created" timestamptz NOT NULL DEFAULT now(), ` +
+		`"status" text NOT NULL DEFAULT 'draft''); DROP TABLE users; --', "summary" text)`
+	if got := fake.recorded(); len(got) != 2 || got[1] != want {
+		t.Fatalf("unexpected statements:\n%s", strings.Join(got, "\n"))
+	}
+
+	if _, err := db.CreateTable("app", "users", map[string]*Column{
+		"id": {Type: "bigint); DROP TABLE posts; --"},
+	}, "id"); err == nil || !strings.Contains(err.Error(), "invalid column type") {
+		t.Fatalf("expected the type refused, got %v", err)
+	}
+	if _, err := db.GetTable("app", "users"); err == nil {
+		t.Fatal("expected the refused table not kept")
+	}
+	if got := fake.recorded(); len(got) != 2 {
+		t.Fatalf("expected nothing run for the refused table, got:\n%s", strings.Join(got, "\n"))
+	}
+}
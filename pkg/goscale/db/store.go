@@ -0,0 +1,25 @@
+package db
+
+import "context"
+
+// Store is the part of GoScaleDB's interface that embedded databases, such
+// as SQLiteStore, implement too, for code that only needs to run
+// statements, such as an edge node keeping its data locally. Statements use
+// PostgreSQL's $1, $2 placeholders with every Store.
+type Store interface {
+	// Query runs a query and returns its rows
+	Query(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error)
+
+	// QueryEach runs a query and calls fn with each row as it is read
+	QueryEach(ctx context.Context, query string, fn func(row map[string]interface{}) error, args ...interface{}) error
+
+	// Execute runs a statement and returns how many rows it changed
+	Execute(ctx context.Context, query string, args ...interface{}) (int64, error)
+
+	Close() error
+}
+
+var (
+	_ Store = (*GoScaleDB)(nil)
+	_ Store = (*SQLiteStore)(nil)
+)
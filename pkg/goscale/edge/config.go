@@ -0,0 +1,42 @@
+package edge
+
+import (
+	"errors"
+
+	"github.com/davidjeba/goscript/pkg/goscript/config"
+)
+
+// LoadConfig loads an edge node's settings over DefaultConfig, such as from
+// the "edge" section of the app's config. The node's database settings are
+// under db_config, or local_store_path names a SQLite file to use instead.
+//
+//	edgeConfig, err := edge.LoadConfig(loader.Section("edge"))
+func LoadConfig(loader *config.Loader) (*Config, error) {
+	c := DefaultConfig()
+	if err := loader.Load(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks the settings an edge node cannot run with; DBConfig is
+// validated by its own Validate
+func (c *Config) Validate() error {
+	switch {
+	case c.ID == "":
+		return errors.New("id is required")
+	case c.Capacity <= 0:
+		return errors.New("capacity must be positive")
+	case c.CacheEnabled && c.CacheTTL <= 0:
+		return errors.New("cache_ttl must be positive when cache_enabled is set")
+	case c.SyncInterval <= 0:
+		return errors.New("sync_interval must be positive")
+	case c.MaxConcurrent <= 0:
+		return errors.New("max_concurrent must be positive")
+	case c.CompressionLevel < 0 || c.CompressionLevel > 9:
+		return errors.New("compression_level must be between 0 and 9")
+	case c.DBConfig == nil && c.LocalStorePath == "":
+		return errors.New("db_config is required unless local_store_path is set")
+	}
+	return nil
+}
@@ -1,15 +1,25 @@
 package edge
 
 import (
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/davidjeba/goscript/pkg/goscale/api"
+	"github.com/davidjeba/goscript/pkg/goscale/client"
 	"github.com/davidjeba/goscript/pkg/goscale/db"
+	"github.com/davidjeba/goscript/pkg/goscale/flags"
+	"github.com/davidjeba/goscript/pkg/goscript/cache"
+	"github.com/davidjeba/goscript/pkg/goscript/events"
+	"github.com/davidjeba/goscript/pkg/goscript/reqctx"
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
 )
 
 // EdgeNode represents an edge computing node that can process API requests
@@ -21,10 +31,49 @@ type EdgeNode struct {
 	Load            int
 	APIHandlers     map[string]api.Resolver
 	CacheEnabled    bool
+	// CoalesceRequests makes concurrent requests for the same path and
+	// params share one handler call; see call
+	CoalesceRequests bool
 	Cache           map[string]*CacheEntry
 	CacheTTL        time.Duration
 	CacheMutex      sync.RWMutex
+	SharedCache     cache.Cache
 	LocalDB         *db.GoScaleDB
+	// LocalStore keeps the cache and the writes waiting for the origin on
+	// the node itself, such as in SQLite; see SetLocalStore
+	LocalStore      db.Store
+	// Origin, when set, sends Write's writes to the origin, such as a
+	// goscale client's Mutate; otherwise they go to ParentAPI's resolvers
+	Origin          func(ctx context.Context, path string, params map[string]interface{}) (interface{}, error)
+	// OriginSubscribe, when set, subscribes to a topic on the origin, such
+	// as a goscale client's Subscribe; ServeSubscription shares one
+	// subscription per topic between the node's clients
+	OriginSubscribe func(ctx context.Context, topic string) (*client.Subscription, error)
+	// SubscriptionLinger keeps a topic's subscription on the origin open
+	// this long after its last client leaves, for clients reconnecting
+	SubscriptionLinger time.Duration
+	// AuthorizeSubscription, when set, decides whether a client may
+	// subscribe to a topic, by its upgraded request, such as by its session.
+	// The node subscribes with its own credentials, so without it any
+	// client of the node receives every topic the node can.
+	AuthorizeSubscription func(r *http.Request, topic string) bool
+	// TrustedProxies are the addresses or CIDR ranges of the app's own
+	// services in front of the node, such as an authenticating gateway,
+	// whose requests carry who they are for; see reqctx.Trusted. The
+	// principal and tenant are forwarded to the origin, and kept with
+	// queued writes, so the origin's TrustedProxies must list the node.
+	TrustedProxies []string
+	// Flags, when set, are evaluated for each request ServeHTTP serves, for
+	// the handlers to branch on with flags.Enabled; responses that evaluated
+	// any are not cached
+	Flags           *flags.Set
+	// FlagsURL is where Flags are fetched from on each sync, such as the
+	// origin's flags.Set Handler
+	FlagsURL        string
+	// Jetpack records the node's request metrics, pushed to a central
+	// Jetpack's PushGateway when Config.JetpackURL is set
+	Jetpack         *core.Jetpack
+	pusher          *core.Pusher
 	SyncInterval    time.Duration
 	LastSyncTime    time.Time
 	SyncMutex       sync.Mutex
@@ -35,6 +84,33 @@ type EdgeNode struct {
 	RequestQueue    chan *EdgeRequest
 	WorkerPool      []*EdgeWorker
 	CompressionLevel int
+	Events          *events.Bus
+	writeMutex      sync.Mutex
+	inflight        map[string]*flight
+	flightMutex     sync.Mutex
+	// purges counts the node's purges, so results read before one are not
+	// cached after it
+	purges          uint64
+	upstreams       map[string]*upstream
+	upstreamMutex   sync.Mutex
+}
+
+// flight is a handler call that concurrent requests for the same path and
+// params wait for
+type flight struct {
+	done   chan struct{}
+	result interface{}
+	tags   []string
+	err    error
+	shared bool
+}
+
+// SyncEvent is published on the node's event bus as "edge.<id>.sync"
+// after it syncs with the parent API
+type SyncEvent struct {
+	Node   string    `json:"node"`
+	Region string    `json:"region"`
+	Time   time.Time `json:"time"`
 }
 
 // EdgeRequest represents a request to be processed by the edge node
@@ -43,12 +119,17 @@ type EdgeRequest struct {
 	Params     map[string]interface{}
 	Context    context.Context
 	ResultChan chan *EdgeResponse
+	// generation is the node's purge count when its handler was called
+	generation uint64
 }
 
 // EdgeResponse represents a response from the edge node
 type EdgeResponse struct {
 	Result interface{}
 	Error  error
+	// Duration is how long the node took to serve the request once a
+	// worker picked it up, with its cache, handler and origin
+	Duration time.Duration
 }
 
 // EdgeWorker represents a worker that processes edge requests
@@ -64,6 +145,8 @@ type CacheEntry struct {
 	Path       string
 	Params     map[string]interface{}
 	Result     interface{}
+	// Tags are the tags the handler gave the result with Tag, for PurgeTags
+	Tags       []string
 	Expiration time.Time
 }
 
@@ -77,6 +160,13 @@ type EdgeMetrics struct {
 	MemoryUsage     float64
 	NetworkIn       int64
 	NetworkOut      int64
+	// CoalescedRequests counts the requests served the result of another
+	// request's handler call instead of calling it themselves
+	CoalescedRequests int64
+	// Subscribers counts the clients of ServeSubscription, and
+	// OriginSubscriptions the subscriptions on the origin they share
+	Subscribers         int64
+	OriginSubscriptions int64
 	mutex           sync.RWMutex
 }
 
@@ -86,8 +176,32 @@ type Config struct {
 	Region           string
 	Capacity         int
 	CacheEnabled     bool
+	// CoalesceRequests makes concurrent requests for the same path and
+	// params share one handler call; turn it off for nodes whose handlers
+	// are not idempotent
+	CoalesceRequests bool
 	CacheTTL         time.Duration
+	SharedCache      cache.Cache `config:"-"`
 	DBConfig         *db.Config
+	// LocalStorePath, when set, is the SQLite file the node keeps its cache
+	// and offline writes in, instead of a database from DBConfig
+	LocalStorePath   string
+	// FlagsURL, when set, is where the node fetches its feature flags from,
+	// such as the origin's flags.Set Handler
+	FlagsURL         string
+	// OriginURL, when set, is the origin's API endpoint, which the node's
+	// subscriptions are fanned out from; see EdgeNode.ServeSubscription
+	OriginURL          string
+	SubscriptionLinger time.Duration
+	// TrustedProxies are the services in front of the node whose requests
+	// carry who they are for; see EdgeNode.TrustedProxies
+	TrustedProxies     []string
+	// JetpackURL, when set, is the central Jetpack's PushGateway the node
+	// pushes its metrics to every PushInterval, labeled with its ID and
+	// region, authenticated with JetpackToken
+	JetpackURL       string
+	JetpackToken     string
+	PushInterval     time.Duration
 	SyncInterval     time.Duration
 	MaxConcurrent    int
 	CompressionLevel int
@@ -100,8 +214,11 @@ func DefaultConfig() *Config {
 		Region:           "us-east",
 		Capacity:         1000,
 		CacheEnabled:     true,
+		CoalesceRequests: true,
 		CacheTTL:         time.Minute * 5,
+		SubscriptionLinger: time.Second * 10,
 		DBConfig:         db.DefaultConfig(),
+		PushInterval:     time.Second * 10,
 		SyncInterval:     time.Minute * 15,
 		MaxConcurrent:    100,
 		CompressionLevel: 5,
@@ -121,9 +238,10 @@ func NewEdgeNode(config *Config, parentAPI *api.GoScaleAPI) *EdgeNode {
 		Load:            0,
 		APIHandlers:     make(map[string]api.Resolver),
 		CacheEnabled:    config.CacheEnabled,
+		CoalesceRequests: config.CoalesceRequests,
 		Cache:           make(map[string]*CacheEntry),
 		CacheTTL:        config.CacheTTL,
-		LocalDB:         db.NewGoScaleDB(config.DBConfig),
+		SharedCache:     config.SharedCache,
 		SyncInterval:    config.SyncInterval,
 		LastSyncTime:    time.Now(),
 		HealthStatus:    "healthy",
@@ -132,6 +250,46 @@ func NewEdgeNode(config *Config, parentAPI *api.GoScaleAPI) *EdgeNode {
 		MaxConcurrent:   config.MaxConcurrent,
 		RequestQueue:    make(chan *EdgeRequest, config.MaxConcurrent*10),
 		CompressionLevel: config.CompressionLevel,
+		Events:          events.NewBus(),
+		SubscriptionLinger: config.SubscriptionLinger,
+		TrustedProxies:  config.TrustedProxies,
+		upstreams:       make(map[string]*upstream),
+	}
+	if config.OriginURL != "" {
+		node.OriginSubscribe = client.New(config.OriginURL).Subscribe
+	}
+	if config.FlagsURL != "" {
+		node.Flags, _ = flags.NewSet()
+		node.Flags.Source = node.ID
+		node.FlagsURL = config.FlagsURL
+	}
+	node.Jetpack = core.NewJetpack()
+	node.Jetpack.RegisterMetric(core.MetricAPILatency, "edge_response_time", "Edge response time", "ms", nil, []string{"edge"})
+	node.Jetpack.RegisterMetric(core.MetricErrorRate, "edge_errors", "Edge failed requests", "", nil, []string{"edge"})
+	node.Jetpack.RegisterMetric(core.MetricType("cache_hits"), "edge_cache_hits", "Edge cache hits", "", nil, []string{"edge"})
+	node.Jetpack.RegisterMetric(core.MetricType("purge_latency"), "edge_purge_latency", "Edge purge latency", "ms", nil, []string{"edge"})
+	node.Jetpack.RegisterMetric(core.MetricType("purged"), "edge_purged", "Edge cache entries purged", "", nil, []string{"edge"})
+	if config.JetpackURL != "" {
+		interval := config.PushInterval
+		if interval <= 0 {
+			interval = time.Second * 10
+		}
+		node.pusher = core.NewPusher(node.Jetpack, config.JetpackURL, node.ID)
+		node.pusher.Labels = core.Labels{"region": node.Region}
+		node.pusher.Token = config.JetpackToken
+		node.pusher.Start(interval)
+	}
+	
+	// Share the parent's event bus, so sync events and the local
+	// database's changes reach the same subscribers
+	if parentAPI != nil {
+		node.Events = parentAPI.EventBus()
+	}
+	if config.LocalStorePath != "" {
+		node.openLocalStore(config.LocalStorePath)
+	} else {
+		node.LocalDB = db.NewGoScaleDB(config.DBConfig)
+		node.LocalDB.SetEventBus(node.Events)
 	}
 	
 	// Initialize worker pool
@@ -169,16 +327,11 @@ func (w *EdgeWorker) Start() {
 			
 			// Check cache first if enabled
 			if w.Node.CacheEnabled {
-				cacheKey := fmt.Sprintf("%s:%v", req.Path, req.Params)
-				w.Node.CacheMutex.RLock()
-				if entry, ok := w.Node.Cache[cacheKey]; ok && time.Now().Before(entry.Expiration) {
-					result = entry.Result
-					w.Node.CacheMutex.RUnlock()
+				if result, ok := w.Node.cached(req); ok {
 					w.Node.updateMetrics(startTime, true, true)
-					req.ResultChan <- &EdgeResponse{Result: result, Error: nil}
+					req.ResultChan <- &EdgeResponse{Result: result, Error: nil, Duration: time.Since(startTime)}
 					continue
 				}
-				w.Node.CacheMutex.RUnlock()
 			}
 			
 			// Get the handler
@@ -186,30 +339,79 @@ func (w *EdgeWorker) Start() {
 			if !ok {
 				err = fmt.Errorf("no handler found for path %s", req.Path)
 				w.Node.updateMetrics(startTime, false, false)
-				req.ResultChan <- &EdgeResponse{Result: nil, Error: err}
+				req.ResultChan <- &EdgeResponse{Result: nil, Error: err, Duration: time.Since(startTime)}
 				continue
 			}
 			
 			// Execute the handler
-			result, err = handler(req.Context, req.Params)
+			req.generation = atomic.LoadUint64(&w.Node.purges)
+			var tags []string
+			result, tags, err = w.Node.call(req, handler)
 			
-			// Cache the result if successful and caching is enabled
-			if err == nil && w.Node.CacheEnabled {
-				cacheKey := fmt.Sprintf("%s:%v", req.Path, req.Params)
-				w.Node.CacheMutex.Lock()
-				w.Node.Cache[cacheKey] = &CacheEntry{
-					Path:       req.Path,
-					Params:     req.Params,
-					Result:     result,
-					Expiration: time.Now().Add(w.Node.CacheTTL),
-				}
-				w.Node.CacheMutex.Unlock()
+			// Cache the result if successful and caching is enabled,
+			// unless it depends on the subject's feature flags
+			if err == nil && w.Node.CacheEnabled && len(flags.Evaluated(req.Context)) == 0 {
+				w.Node.cache(req, result, tags)
 			}
 			
 			w.Node.updateMetrics(startTime, err == nil, false)
-			req.ResultChan <- &EdgeResponse{Result: result, Error: err}
+			req.ResultChan <- &EdgeResponse{Result: result, Error: err, Duration: time.Since(startTime)}
+		}
+	}
+}
+
+// cached returns a request's cached result, from the shared cache if the
+// node has one. Shared results come back decoded from JSON.
+func (n *EdgeNode) cached(req *EdgeRequest) (interface{}, bool) {
+	cacheKey := fmt.Sprintf("%s:%v", req.Path, req.Params)
+	if n.SharedCache != nil {
+		data, found, err := n.SharedCache.Get(req.Context, "edge:"+cacheKey)
+		var result interface{}
+		if err != nil || !found || json.Unmarshal(data, &result) != nil {
+			return nil, false
 		}
+		return result, true
+	}
+
+	n.CacheMutex.RLock()
+	defer n.CacheMutex.RUnlock()
+	if entry, ok := n.Cache[cacheKey]; ok && time.Now().Before(entry.Expiration) {
+		return entry.Result, true
+	}
+	return nil, false
+}
+
+// cache keeps a request's result for CacheTTL, unless the node was purged
+// since its handler was called. Shared entries are tagged "edge",
+// "edge:<path>" and "edge-tag:<tag>" for each of the handler's tags, for
+// ClearCache, InvalidatePath and PurgeTags.
+func (n *EdgeNode) cache(req *EdgeRequest, result interface{}, tags []string) {
+	cacheKey := fmt.Sprintf("%s:%v", req.Path, req.Params)
+	if n.SharedCache != nil {
+		if data, err := json.Marshal(result); err == nil && atomic.LoadUint64(&n.purges) == req.generation {
+			shared := []string{"edge", "edge:" + req.Path}
+			for _, tag := range tags {
+				shared = append(shared, "edge-tag:"+tag)
+			}
+			n.SharedCache.Set(req.Context, "edge:"+cacheKey, data, n.CacheTTL, shared...)
+		}
+		return
+	}
+
+	entry := &CacheEntry{
+		Path:       req.Path,
+		Params:     req.Params,
+		Result:     result,
+		Tags:       tags,
+		Expiration: time.Now().Add(n.CacheTTL),
+	}
+	n.CacheMutex.Lock()
+	defer n.CacheMutex.Unlock()
+	if atomic.LoadUint64(&n.purges) != req.generation {
+		return
 	}
+	n.Cache[cacheKey] = entry
+	n.persist(cacheKey, entry)
 }
 
 // startDispatcher starts the request dispatcher
@@ -245,32 +447,132 @@ func (n *EdgeNode) startDispatcher() {
 			}
 			
 			// Execute the handler
-			result, err := handler(req.Context, req.Params)
+			result, _, err := n.call(req, handler)
 			n.updateMetrics(startTime, err == nil, false)
 			req.ResultChan <- &EdgeResponse{Result: result, Error: err}
 		}
 	}
 }
 
+// call runs a request's handler and masks its result. Concurrent requests
+// for the same path and params, by the same principal of the same tenant,
+// wait for the first one's call and share its result, rather than each
+// reaching the origin, as when a popular cached result expires. A result is
+// not shared if it evaluated feature flags for its subject, its request was
+// cancelled or the node was purged meanwhile; the waiters then call the
+// handler themselves. It returns the tags the handler gave the result too.
+func (n *EdgeNode) call(req *EdgeRequest, handler api.Resolver) (interface{}, []string, error) {
+	if !n.CoalesceRequests {
+		return n.run(req, handler)
+	}
+
+	key := flightKey(req)
+	n.flightMutex.Lock()
+	if f, ok := n.inflight[key]; ok {
+		n.flightMutex.Unlock()
+		select {
+		case <-f.done:
+		case <-req.Context.Done():
+			return nil, nil, req.Context.Err()
+		}
+		if !f.shared {
+			return n.run(req, handler)
+		}
+		n.Metrics.mutex.Lock()
+		n.Metrics.CoalescedRequests++
+		n.Metrics.mutex.Unlock()
+		return f.result, f.tags, f.err
+	}
+	if n.inflight == nil {
+		n.inflight = make(map[string]*flight)
+	}
+	f := &flight{done: make(chan struct{})}
+	n.inflight[key] = f
+	n.flightMutex.Unlock()
+
+	generation := atomic.LoadUint64(&n.purges)
+	f.result, f.tags, f.err = n.run(req, handler)
+	f.shared = req.Context.Err() == nil && len(flags.Evaluated(req.Context)) == 0 && atomic.LoadUint64(&n.purges) == generation
+
+	n.flightMutex.Lock()
+	delete(n.inflight, key)
+	n.flightMutex.Unlock()
+	close(f.done)
+	return f.result, f.tags, f.err
+}
+
+// flightKey is the key of the requests that share a handler call: those
+// for the same path and params, by the same principal of the same tenant,
+// as handlers may answer each principal differently
+func flightKey(req *EdgeRequest) string {
+	info := reqctx.From(req.Context)
+	return fmt.Sprintf("%s:%v:%q:%q", req.Path, req.Params, info.Tenant, info.Principal)
+}
+
+// run runs a request's handler and masks its result, returning the tags
+// the handler gave it
+func (n *EdgeNode) run(req *EdgeRequest, handler api.Resolver) (interface{}, []string, error) {
+	ctx, tags := withTags(req.Context)
+	result, err := handler(ctx, req.Params)
+	if err == nil {
+		result, err = n.mask(req.Path, result)
+	}
+	return result, tags.list(), err
+}
+
+// mask masks the personal data in a result as the parent API's schema tags
+// it, for a caller without a role, so the edge never caches or serves it
+// unmasked; callers whose role may see it are served by the origin.
+func (n *EdgeNode) mask(path string, result interface{}) (interface{}, error) {
+	if n.ParentAPI == nil {
+		return result, nil
+	}
+	return n.ParentAPI.MaskResult(path, result, "")
+}
+
 // startSyncProcess starts the sync process
 func (n *EdgeNode) startSyncProcess() {
 	ticker := time.NewTicker(n.SyncInterval)
 	defer ticker.Stop()
 	
+	n.syncFlags(context.Background())
 	for range ticker.C {
 		n.SyncWithParent()
 	}
 }
 
-// SyncWithParent synchronizes the edge node with the parent API
+// syncFlags fetches the node's flags from FlagsURL, keeping those it has
+// if the origin cannot be reached
+func (n *EdgeNode) syncFlags(ctx context.Context) error {
+	if n.Flags == nil || n.FlagsURL == "" {
+		return nil
+	}
+	return n.Flags.Fetch(ctx, n.FlagsURL)
+}
+
+// SyncWithParent synchronizes the edge node with the parent API, sending
+// the writes queued while it was unreachable and fetching its flags
 func (n *EdgeNode) SyncWithParent() error {
 	n.SyncMutex.Lock()
 	defer n.SyncMutex.Unlock()
 	
-	// In a real implementation, this would sync data with the parent API
+	var replayErr error
+	if n.LocalStore != nil {
+		n.writeMutex.Lock()
+		_, replayErr = n.replayWrites(context.Background())
+		n.writeMutex.Unlock()
+	}
+	if err := n.syncFlags(context.Background()); err != nil && replayErr == nil {
+		replayErr = err
+	}
 	n.LastSyncTime = time.Now()
 	
-	return nil
+	if n.Events != nil {
+		if err := n.Events.Publish(context.Background(), "edge."+n.ID+".sync", SyncEvent{Node: n.ID, Region: n.Region, Time: n.LastSyncTime}); err != nil && replayErr == nil {
+			return err
+		}
+	}
+	return replayErr
 }
 
 // RegisterHandler registers a handler for a specific path
@@ -280,6 +582,13 @@ func (n *EdgeNode) RegisterHandler(path string, handler api.Resolver) {
 
 // ProcessRequest processes an API request
 func (n *EdgeNode) ProcessRequest(ctx context.Context, path string, params map[string]interface{}) (interface{}, error) {
+	resp := n.process(ctx, path, params)
+	return resp.Result, resp.Error
+}
+
+// process queues a request for the node's workers and waits for its
+// response
+func (n *EdgeNode) process(ctx context.Context, path string, params map[string]interface{}) *EdgeResponse {
 	// Create a request
 	resultChan := make(chan *EdgeResponse, 1)
 	req := &EdgeRequest{
@@ -293,27 +602,49 @@ func (n *EdgeNode) ProcessRequest(ctx context.Context, path string, params map[s
 	n.RequestQueue <- req
 	
 	// Wait for the response
-	resp := <-resultChan
-	return resp.Result, resp.Error
+	return <-resultChan
 }
 
 // ServeHTTP implements the http.Handler interface
 func (n *EdgeNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	startTime := time.Now()
 	
-	// Parse the request
+	// Parse the request, which may also be in GoScaleAPI's form, so clients
+	// can fail over between the origin and its edge nodes
 	var request struct {
 		Path       string                 `json:"path"`
 		Params     map[string]interface{} `json:"params"`
+		Operation  string                 `json:"operation"`
+		Variables  map[string]interface{} `json:"variables"`
 	}
 	
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+	var body io.Reader = r.Body
+	if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+	if err := json.NewDecoder(body).Decode(&request); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if request.Path == "" {
+		request.Path, request.Params = request.Operation, request.Variables
+	}
 	
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(r.Context(), time.Second*30)
+	// Create context with timeout, carrying the request's ID and trace to
+	// the handlers and the origin, and evaluating the flags for the
+	// request's subject, targetable by the node's region
+	ctx := reqctx.Ensure(reqctx.ExtractRequest(r, n.TrustedProxies))
+	w.Header().Set(reqctx.RequestIDHeader, reqctx.RequestID(ctx))
+	if n.Flags != nil {
+		ctx = n.Flags.Bind(w, r.WithContext(ctx), map[string]string{"region": n.Region, "node": n.ID})
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
 	defer cancel()
 	
 	// Process the request
@@ -354,6 +685,21 @@ func (n *EdgeNode) updateMetrics(startTime time.Time, success, cacheHit bool) {
 	} else {
 		n.Metrics.CacheHitRate = (n.Metrics.CacheHitRate * float64(n.Metrics.RequestCount-1)) / float64(n.Metrics.RequestCount)
 	}
+	
+	// Record each request too, for the fleet's panel and alerts
+	if n.Jetpack != nil {
+		n.Jetpack.RecordMetric("edge_response_time", duration*1000)
+		n.Jetpack.RecordMetric("edge_errors", boolValue(!success))
+		n.Jetpack.RecordMetric("edge_cache_hits", boolValue(cacheHit))
+	}
+}
+
+// boolValue records a condition as 1 or 0
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
 }
 
 // GetMetrics returns the current edge node metrics
@@ -370,19 +716,54 @@ func (n *EdgeNode) GetMetrics() *EdgeMetrics {
 		MemoryUsage:     n.Metrics.MemoryUsage,
 		NetworkIn:       n.Metrics.NetworkIn,
 		NetworkOut:      n.Metrics.NetworkOut,
+		CoalescedRequests: n.Metrics.CoalescedRequests,
+		Subscribers:     n.Metrics.Subscribers,
+		OriginSubscriptions: n.Metrics.OriginSubscriptions,
 	}
 }
 
-// ClearCache clears the edge node cache
+// ClearCache clears the edge node cache, and the shared cache's edge
+// entries for every node sharing it
 func (n *EdgeNode) ClearCache() {
 	n.CacheMutex.Lock()
 	defer n.CacheMutex.Unlock()
 	
 	n.Cache = make(map[string]*CacheEntry)
+	n.unpersist("")
+	if n.SharedCache != nil {
+		n.SharedCache.Invalidate(context.Background(), "edge")
+	}
+}
+
+// InvalidatePath drops the cached results of a path, such as after the
+// data behind it changed
+func (n *EdgeNode) InvalidatePath(path string) {
+	n.CacheMutex.Lock()
+	defer n.CacheMutex.Unlock()
+	
+	for key, entry := range n.Cache {
+		if entry.Path == path {
+			delete(n.Cache, key)
+		}
+	}
+	n.unpersist(path)
+	if n.SharedCache != nil {
+		n.SharedCache.Invalidate(context.Background(), "edge:"+path)
+	}
 }
 
 // Close closes the edge node and all its resources
 func (n *EdgeNode) Close() error {
+	// Push the metrics left
+	if n.pusher != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+		n.pusher.Stop(ctx)
+		cancel()
+	}
+	
+	// End the subscriptions on the origin, closing their clients
+	n.closeSubscriptions()
+	
 	// Stop all workers
 	for _, worker := range n.WorkerPool {
 		worker.Active = false
@@ -392,6 +773,9 @@ func (n *EdgeNode) Close() error {
 	close(n.RequestQueue)
 	
 	// Close the local database
+	if n.LocalStore != nil {
+		return n.LocalStore.Close()
+	}
 	return n.LocalDB.Close()
 }
 
@@ -402,15 +786,27 @@ type EdgeNetwork struct {
 	HealthChecker   *HealthChecker
 	SyncManager     *SyncManager
 	ParentAPI       *api.GoScaleAPI
+	// Latency records how long each region's requests take to reach each
+	// node and back, for the "nearest" strategy and RoutingHandler
+	Latency         *LatencyMap
 	mutex           sync.RWMutex
+	// The last purges' statuses, for PurgeStatus
+	purges          []*purge
+	purgeCount      uint64
+	purgeMutex      sync.Mutex
 }
 
 // LoadBalancer distributes requests across edge nodes
 type LoadBalancer struct {
 	Strategy        string
+	// AffinityParam, when set, names the request param, such as "userId",
+	// whose value pins requests to a node; see Route
+	AffinityParam   string
 	Network         *EdgeNetwork
 	RequestCounter  int64
 	mutex           sync.Mutex
+	// The last routing decisions, for Explain
+	decisions       []*RoutingDecision
 }
 
 // HealthChecker monitors the health of edge nodes
@@ -434,6 +830,7 @@ func NewEdgeNetwork(parentAPI *api.GoScaleAPI) *EdgeNetwork {
 	network := &EdgeNetwork{
 		Nodes:     make(map[string]*EdgeNode),
 		ParentAPI: parentAPI,
+		Latency:   NewLatencyMap(),
 	}
 	
 	// Create the load balancer
@@ -481,6 +878,7 @@ func (n *EdgeNetwork) RemoveNode(nodeID string) {
 	if node, ok := n.Nodes[nodeID]; ok {
 		node.Close()
 		delete(n.Nodes, nodeID)
+		n.Latency.Remove(nodeID)
 	}
 }
 
@@ -500,13 +898,20 @@ func (n *EdgeNetwork) GetNode(nodeID string) (*EdgeNode, error) {
 // ProcessRequest processes a request through the edge network
 func (n *EdgeNetwork) ProcessRequest(ctx context.Context, path string, params map[string]interface{}) (interface{}, error) {
 	// Get the best node for this request
-	node, err := n.LoadBalancer.GetBestNode(path, params)
+	node, _, err := n.LoadBalancer.Route(ctx, path, params)
 	if err != nil {
 		return nil, err
 	}
 	
-	// Process the request on the selected node
-	return node.ProcessRequest(ctx, path, params)
+	// Process the request on the selected node, measuring its latency from
+	// the request's region: the time to reach the node and back, without
+	// the time the node spent serving it
+	start := time.Now()
+	resp := node.process(ctx, path, params)
+	if resp.Error == nil {
+		n.Latency.Record(node.ID, ClientRegion(ctx), time.Since(start)-resp.Duration)
+	}
+	return resp.Result, resp.Error
 }
 
 // Start starts the health checker
@@ -573,65 +978,8 @@ func (s *SyncManager) SyncNodes() {
 	s.LastSyncTime = time.Now()
 }
 
-// GetBestNode returns the best node for a request
+// GetBestNode returns the best node for a request; see Route
 func (l *LoadBalancer) GetBestNode(path string, params map[string]interface{}) (*EdgeNode, error) {
-	l.mutex.Lock()
-	defer l.mutex.Unlock()
-	
-	l.Network.mutex.RLock()
-	defer l.Network.mutex.RUnlock()
-	
-	if len(l.Network.Nodes) == 0 {
-		return nil, errors.New("no nodes available")
-	}
-	
-	// Get all healthy nodes
-	healthyNodes := make([]*EdgeNode, 0)
-	for _, node := range l.Network.Nodes {
-		if node.HealthStatus == "healthy" {
-			healthyNodes = append(healthyNodes, node)
-		}
-	}
-	
-	if len(healthyNodes) == 0 {
-		return nil, errors.New("no healthy nodes available")
-	}
-	
-	// Select a node based on the strategy
-	var selectedNode *EdgeNode
-	
-	switch l.Strategy {
-	case "round-robin":
-		// Simple round-robin
-		l.RequestCounter++
-		selectedNode = healthyNodes[l.RequestCounter%int64(len(healthyNodes))]
-	case "least-loaded":
-		// Select the node with the lowest load
-		minLoad := healthyNodes[0].Load
-		selectedNode = healthyNodes[0]
-		
-		for _, node := range healthyNodes {
-			if node.Load < minLoad {
-				minLoad = node.Load
-				selectedNode = node
-			}
-		}
-	case "fastest":
-		// Select the node with the lowest average response time
-		minTime := healthyNodes[0].Metrics.AvgResponseTime
-		selectedNode = healthyNodes[0]
-		
-		for _, node := range healthyNodes {
-			if node.Metrics.AvgResponseTime < minTime {
-				minTime = node.Metrics.AvgResponseTime
-				selectedNode = node
-			}
-		}
-	default:
-		// Default to round-robin
-		l.RequestCounter++
-		selectedNode = healthyNodes[l.RequestCounter%int64(len(healthyNodes))]
-	}
-	
-	return selectedNode, nil
-}
\ No newline at end of file
+	node, _, err := l.Route(context.Background(), path, params)
+	return node, err
+}
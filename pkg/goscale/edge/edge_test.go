@@ -0,0 +1,207 @@
+package edge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscale/flags"
+	"github.com/davidjeba/goscript/pkg/goscript/reqctx"
+)
+
+// slowHandler counts its calls and blocks each until release is closed,
+// signalling started as each begins.
+type slowHandler struct {
+	calls   int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func newSlowHandler() *slowHandler {
+	return &slowHandler{started: make(chan struct{}, 100), release: make(chan struct{})}
+}
+
+func (h *slowHandler) resolve(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	n := atomic.AddInt32(&h.calls, 1)
+	h.started <- struct{}{}
+	<-h.release
+	if flag, ok := params["flag"].(string); ok {
+		flags.Enabled(ctx, flag)
+	}
+	return map[string]interface{}{"call": n, "principal": reqctx.Principal(ctx)}, nil
+}
+
+// coalescingNode returns a node coalescing requests, without workers
+func coalescingNode() *EdgeNode {
+	return &EdgeNode{CoalesceRequests: true, Metrics: &EdgeMetrics{}}
+}
+
+// request returns a request for a post by principal
+func request(ctx context.Context, principal string, params map[string]interface{}) *EdgeRequest {
+	if principal != "" {
+		ctx = reqctx.WithPrincipal(ctx, principal)
+	}
+	return &EdgeRequest{Path: "getPost", Params: params, Context: ctx}
+}
+
+// callAll calls the handler for each request concurrently, once the first
+// has started, and releases the handler once the others are waiting
+func callAll(n *EdgeNode, h *slowHandler, reqs ...*EdgeRequest) ([]interface{}, []error) {
+	results := make([]interface{}, len(reqs))
+	errs := make([]error, len(reqs))
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		wg.Add(1)
+		go func(i int, req *EdgeRequest) {
+			defer wg.Done()
+			results[i], _, errs[i] = n.call(req, h.resolve)
+		}(i, req)
+		if i == 0 {
+			<-h.started
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(h.release)
+	wg.Wait()
+	return results, errs
+}
+
+func TestCoalescingSharesResult(t *testing.T) {
+	n, h := coalescingNode(), newSlowHandler()
+	params := map[string]interface{}{"id": 1}
+	reqs := make([]*EdgeRequest, 5)
+	for i := range reqs {
+		reqs[i] = request(context.Background(), "ada", params)
+	}
+
+	results, errs := callAll(n, h, reqs...)
+	if calls := atomic.LoadInt32(&h.calls); calls != 1 {
+		t.Fatalf("expected one handler call, got %d", calls)
+	}
+	for i := range results {
+		if errs[i] != nil || results[i].(map[string]interface{})["call"] != int32(1) {
+			t.Fatalf("request %d: expected the shared result, got %v (%v)", i, results[i], errs[i])
+		}
+	}
+	if coalesced := n.GetMetrics().CoalescedRequests; coalesced != 4 {
+		t.Fatalf("expected 4 coalesced requests, got %d", coalesced)
+	}
+}
+
+func TestCoalescingSeparatesPrincipals(t *testing.T) {
+	n, h := coalescingNode(), newSlowHandler()
+	params := map[string]interface{}{"id": 1}
+	tenant := reqctx.WithTenant(context.Background(), "acme")
+
+	results, _ := callAll(n, h,
+		request(context.Background(), "ada", params),
+		request(context.Background(), "bob", params),
+		request(tenant, "ada", params),
+	)
+	if calls := atomic.LoadInt32(&h.calls); calls != 3 {
+		t.Fatalf("expected a handler call per principal and tenant, got %d", calls)
+	}
+	if principal := results[1].(map[string]interface{})["principal"]; principal != "bob" {
+		t.Fatalf("expected bob's own result, got %v", results[1])
+	}
+	if coalesced := n.GetMetrics().CoalescedRequests; coalesced != 0 {
+		t.Fatalf("expected no coalesced requests, got %d", coalesced)
+	}
+}
+
+func TestCoalescingWaiterCancels(t *testing.T) {
+	n, h := coalescingNode(), newSlowHandler()
+	params := map[string]interface{}{"id": 1}
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results, errs := callAll(n, h,
+		request(context.Background(), "", params),
+		request(cancelled, "", params),
+		request(context.Background(), "", params),
+	)
+	if errs[1] != context.Canceled {
+		t.Fatalf("expected the cancelled waiter to stop, got %v (%v)", results[1], errs[1])
+	}
+	if errs[0] != nil || errs[2] != nil || atomic.LoadInt32(&h.calls) != 1 {
+		t.Fatalf("expected the others to share one call, got %v %v after %d calls", errs[0], errs[2], h.calls)
+	}
+	if coalesced := n.GetMetrics().CoalescedRequests; coalesced != 1 {
+		t.Fatalf("expected 1 coalesced request, got %d", coalesced)
+	}
+}
+
+func TestCoalescingLeaderCancels(t *testing.T) {
+	n, h := coalescingNode(), newSlowHandler()
+	params := map[string]interface{}{"id": 1}
+	leader, cancel := context.WithCancel(context.Background())
+
+	results := make(chan interface{}, 1)
+	go func() {
+		<-h.started
+		go func() {
+			result, _, _ := n.call(request(context.Background(), "", params), h.resolve)
+			results <- result
+		}()
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+		close(h.release)
+	}()
+	n.call(request(leader, "", params), h.resolve)
+
+	if result := <-results; result.(map[string]interface{})["call"] != int32(2) {
+		t.Fatalf("expected the waiter to call the handler itself, got %v", result)
+	}
+	if coalesced := n.GetMetrics().CoalescedRequests; coalesced != 0 {
+		t.Fatalf("expected no coalesced requests, got %d", coalesced)
+	}
+}
+
+func TestCoalescingSkipsFlaggedResults(t *testing.T) {
+	n, h := coalescingNode(), newSlowHandler()
+	set, _ := flags.NewSet(&flags.Flag{Key: "new-post", Enabled: true, Percentage: 50})
+	flagged := flags.NewContext(context.Background(), set, flags.Subject{Key: "ada"})
+	params := map[string]interface{}{"id": 1, "flag": "new-post"}
+
+	results, errs := callAll(n, h,
+		request(flagged, "", params),
+		request(context.Background(), "", params),
+	)
+	if errs[0] != nil || errs[1] != nil || atomic.LoadInt32(&h.calls) != 2 {
+		t.Fatalf("expected the waiter to call the handler itself, got %v after %d calls", results, h.calls)
+	}
+	if coalesced := n.GetMetrics().CoalescedRequests; coalesced != 0 {
+		t.Fatalf("expected no coalesced requests, got %d", coalesced)
+	}
+}
+
+func TestServeHTTPTrustedProxies(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxConcurrent = 1
+	config.CacheEnabled = false
+	config.TrustedProxies = []string{"10.0.0.0/8"}
+	n := NewEdgeNode(config, nil)
+	n.RegisterHandler("whoami", func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		return reqctx.Principal(ctx) + "@" + reqctx.Tenant(ctx), nil
+	})
+
+	for remote, want := range map[string]string{"10.1.2.3:4000": "ada@acme", "203.0.113.9:4000": "@"} {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"path":"whoami"}`))
+		r.RemoteAddr = remote
+		r.Header.Set(reqctx.PrincipalHeader, "ada")
+		r.Header.Set(reqctx.TenantHeader, "acme")
+		recorder := httptest.NewRecorder()
+		n.ServeHTTP(recorder, r)
+
+		var response struct{ Data string }
+		if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil || response.Data != want {
+			t.Fatalf("expected %q for a request from %s, got %q (%v)", want, remote, response.Data, err)
+		}
+	}
+}
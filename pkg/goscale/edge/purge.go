@@ -0,0 +1,261 @@
+package edge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxPurges caps the purges a network keeps the status of
+const maxPurges = 100
+
+// tagsKey is the context key of the tags a request's handler gave its
+// response
+type tagsKey struct{}
+
+// responseTags are the tags a handler gave its response
+type responseTags struct {
+	mutex sync.Mutex
+	tags  []string
+}
+
+// Tag tags the response of the request ctx belongs to, such as "user:123"
+// for a result showing that user, so PurgeTags drops it from the nodes'
+// caches when the user changes. Outside a node's request it does nothing.
+func Tag(ctx context.Context, tags ...string) {
+	t, ok := ctx.Value(tagsKey{}).(*responseTags)
+	if !ok {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, tag := range tags {
+		if tag != "" && !hasTag(t.tags, tag) {
+			t.tags = append(t.tags, tag)
+		}
+	}
+}
+
+// withTags returns a context for a handler to Tag its response in
+func withTags(ctx context.Context) (context.Context, *responseTags) {
+	t := &responseTags{}
+	return context.WithValue(ctx, tagsKey{}, t), t
+}
+
+// list returns the tags given so far
+func (t *responseTags) list() []string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]string(nil), t.tags...)
+}
+
+// hasTag reports whether tags holds tag
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// PurgeTags drops the cached results tagged with any of tags from the
+// node's cache, its local store and the shared cache, returning how many the
+// node held. Results of requests already running are not cached, as they
+// may have read the data from before the change.
+func (n *EdgeNode) PurgeTags(tags ...string) (int, error) {
+	return n.purgeTags(time.Now(), tags)
+}
+
+// purgeTags purges tags, recording the time since the purge started
+func (n *EdgeNode) purgeTags(started time.Time, tags []string) (int, error) {
+	atomic.AddUint64(&n.purges, 1)
+
+	n.CacheMutex.Lock()
+	var keys []string
+	for key, entry := range n.Cache {
+		for _, tag := range tags {
+			if hasTag(entry.Tags, tag) {
+				keys = append(keys, key)
+				delete(n.Cache, key)
+				break
+			}
+		}
+	}
+	err := n.unpersistKeys(keys)
+	n.CacheMutex.Unlock()
+
+	if n.SharedCache != nil {
+		shared := make([]string, len(tags))
+		for i, tag := range tags {
+			shared[i] = "edge-tag:" + tag
+		}
+		if sharedErr := n.SharedCache.Invalidate(context.Background(), shared...); sharedErr != nil && err == nil {
+			err = sharedErr
+		}
+	}
+
+	if n.Jetpack != nil {
+		n.Jetpack.RecordMetric("edge_purge_latency", float64(time.Since(started))/float64(time.Millisecond))
+		n.Jetpack.RecordMetric("edge_purged", float64(len(keys)))
+	}
+	return len(keys), err
+}
+
+// PurgeAck is a node's acknowledgement of a purge
+type PurgeAck struct {
+	Node   string `json:"node"`
+	Purged int    `json:"purged"`
+
+	// Latency is the time from the purge's start to the node's cache being
+	// purged
+	Latency time.Duration `json:"latency"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// PurgeStatus is the progress of a purge through the network: the nodes
+// that acknowledged it, and those still Pending
+type PurgeStatus struct {
+	ID        string     `json:"id"`
+	Tags      []string   `json:"tags"`
+	Started   time.Time  `json:"started"`
+	Completed time.Time  `json:"completed,omitempty"`
+	Acks      []PurgeAck `json:"acks"`
+	Pending   []string   `json:"pending"`
+}
+
+// Done reports whether every node acknowledged the purge
+func (s PurgeStatus) Done() bool {
+	return len(s.Pending) == 0
+}
+
+// purge is a purge the network is tracking
+type purge struct {
+	mutex  sync.Mutex
+	status PurgeStatus
+	done   chan struct{}
+}
+
+// ack records a node's acknowledgement, closing done after the last
+func (p *purge) ack(ack PurgeAck) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for i, node := range p.status.Pending {
+		if node == ack.Node {
+			p.status.Pending = append(p.status.Pending[:i], p.status.Pending[i+1:]...)
+			p.status.Acks = append(p.status.Acks, ack)
+			break
+		}
+	}
+	if len(p.status.Pending) == 0 && p.status.Completed.IsZero() {
+		p.status.Completed = time.Now()
+		close(p.done)
+	}
+}
+
+// snapshot copies the purge's status
+func (p *purge) snapshot() PurgeStatus {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	status := p.status
+	status.Tags = append([]string{}, status.Tags...)
+	status.Acks = append([]PurgeAck{}, status.Acks...)
+	status.Pending = append([]string{}, status.Pending...)
+	return status
+}
+
+// PurgeTags has every node purge the cached results tagged with any of
+// tags, as when the origin changes the data behind them. It waits for the
+// nodes to acknowledge, or for ctx to be done, and returns the purge's
+// status: the nodes that had not acknowledged by then are Pending, and
+// PurgeStatus shows their acknowledgements as they arrive.
+func (n *EdgeNetwork) PurgeTags(ctx context.Context, tags ...string) (PurgeStatus, error) {
+	if len(tags) == 0 {
+		return PurgeStatus{}, errors.New("edge: no tags to purge")
+	}
+
+	n.mutex.RLock()
+	nodes := make([]*EdgeNode, 0, len(n.Nodes))
+	for _, node := range n.Nodes {
+		nodes = append(nodes, node)
+	}
+	n.mutex.RUnlock()
+
+	p := &purge{
+		status: PurgeStatus{
+			ID:      fmt.Sprintf("purge-%d", atomic.AddUint64(&n.purgeCount, 1)),
+			Tags:    append([]string{}, tags...),
+			Started: time.Now(),
+			Acks:    []PurgeAck{},
+			Pending: make([]string, len(nodes)),
+		},
+		done: make(chan struct{}),
+	}
+	for i, node := range nodes {
+		p.status.Pending[i] = node.ID
+	}
+	if len(nodes) == 0 {
+		p.status.Completed = p.status.Started
+		close(p.done)
+	}
+	n.track(p)
+
+	for _, node := range nodes {
+		go func(node *EdgeNode) {
+			purged, err := node.purgeTags(p.status.Started, tags)
+			ack := PurgeAck{Node: node.ID, Purged: purged, Latency: time.Since(p.status.Started)}
+			if err != nil {
+				ack.Error = err.Error()
+			}
+			p.ack(ack)
+		}(node)
+	}
+
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return p.snapshot(), ctx.Err()
+	}
+
+	status := p.snapshot()
+	failed := 0
+	for _, ack := range status.Acks {
+		if ack.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return status, fmt.Errorf("edge: %s failed on %d of %d nodes", status.ID, failed, len(status.Acks))
+	}
+	return status, nil
+}
+
+// track keeps a purge's status, dropping the oldest past maxPurges
+func (n *EdgeNetwork) track(p *purge) {
+	n.purgeMutex.Lock()
+	defer n.purgeMutex.Unlock()
+
+	n.purges = append(n.purges, p)
+	if over := len(n.purges) - maxPurges; over > 0 {
+		n.purges = append(n.purges[:0], n.purges[over:]...)
+	}
+}
+
+// PurgeStatus returns the status of one of the last purges by ID
+func (n *EdgeNetwork) PurgeStatus(id string) (PurgeStatus, bool) {
+	n.purgeMutex.Lock()
+	defer n.purgeMutex.Unlock()
+
+	for _, p := range n.purges {
+		if p.status.ID == id {
+			return p.snapshot(), true
+		}
+	}
+	return PurgeStatus{}, false
+}
@@ -0,0 +1,226 @@
+package edge
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/cache"
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+// cachePosts caches the results of getPost for posts 1 and 2, tagged with
+// the post and its author, and of listPosts, tagged with both posts
+func cachePosts(n *EdgeNode) {
+	ctx := context.Background()
+	post := func(id int) *EdgeRequest {
+		return &EdgeRequest{Path: "getPost", Params: map[string]interface{}{"id": id}, Context: ctx}
+	}
+	n.cache(post(1), map[string]interface{}{"id": 1}, []string{"post:1", "user:ada"})
+	n.cache(post(2), map[string]interface{}{"id": 2}, []string{"post:2", "user:grace"})
+	n.cache(&EdgeRequest{Path: "listPosts", Params: map[string]interface{}{}, Context: ctx}, []interface{}{1, 2}, []string{"post:1", "post:2"})
+}
+
+// cachedPaths returns which of the results cachePosts cached are still
+// cached, such as "getPost:1" and "listPosts"
+func cachedPaths(n *EdgeNode) string {
+	ctx := context.Background()
+	var paths []string
+	for name, req := range map[string]*EdgeRequest{
+		"getPost:1": {Path: "getPost", Params: map[string]interface{}{"id": 1}, Context: ctx},
+		"getPost:2": {Path: "getPost", Params: map[string]interface{}{"id": 2}, Context: ctx},
+		"listPosts": {Path: "listPosts", Params: map[string]interface{}{}, Context: ctx},
+	} {
+		if _, ok := n.cached(req); ok {
+			paths = append(paths, name)
+		}
+	}
+	sort.Strings(paths)
+	return strings.Join(paths, " ")
+}
+
+func TestPurgeTags(t *testing.T) {
+	store := newMemStore()
+	n := storeNode(t, store)
+	n.Jetpack = core.NewJetpack()
+	n.Jetpack.RegisterMetric(core.MetricType("purge_latency"), "edge_purge_latency", "Edge purge latency", "ms", nil, nil)
+	n.Jetpack.RegisterMetric(core.MetricType("purged"), "edge_purged", "Edge cache entries purged", "", nil, nil)
+	cachePosts(n)
+
+	purged, err := n.PurgeTags("user:ada", "missing")
+	if err != nil || purged != 1 {
+		t.Fatalf("expected one entry purged, got %d (%v)", purged, err)
+	}
+	if paths := cachedPaths(n); paths != "getPost:2 listPosts" {
+		t.Fatalf("expected post 1 purged, got %s", paths)
+	}
+	if keys := strings.Join(store.cachedKeys(), " "); keys != "getPost:map[id:2] listPosts:map[]" {
+		t.Fatalf("expected the purge to reach the local store, got %s", keys)
+	}
+
+	// A restarted node does not load the purged entries back
+	if restarted := storeNode(t, store); len(restarted.Cache) != 2 {
+		t.Fatalf("expected two entries loaded, got %v", restarted.Cache)
+	}
+
+	if purged, _ := n.PurgeTags("post:2"); purged != 2 || len(n.Cache) != 0 || len(store.cachedKeys()) != 0 {
+		t.Fatalf("expected the rest purged, got %d with %v left", purged, store.cachedKeys())
+	}
+	stats, err := n.Jetpack.GetMetricStats("edge_purged", time.Minute)
+	if err != nil || stats.Count != 2 || stats.Max != 2 {
+		t.Fatalf("expected the purges recorded, got %+v (%v)", stats, err)
+	}
+	if stats, err := n.Jetpack.GetMetricStats("edge_purge_latency", time.Minute); err != nil || stats.Count != 2 {
+		t.Fatalf("expected the purge latency recorded, got %+v (%v)", stats, err)
+	}
+}
+
+func TestPurgeDropsRunningResults(t *testing.T) {
+	n := storeNode(t, newMemStore())
+	req := &EdgeRequest{Path: "getPost", Params: map[string]interface{}{"id": 1}, Context: context.Background()}
+	req.generation = atomic.LoadUint64(&n.purges)
+
+	// The handler read the post before it changed and was purged
+	n.PurgeTags("post:1")
+	n.cache(req, map[string]interface{}{"id": 1}, []string{"post:2"})
+	if _, ok := n.cached(req); ok {
+		t.Fatal("expected a result from before the purge not cached")
+	}
+}
+
+func TestPurgeSharedCache(t *testing.T) {
+	n := storeNode(t, newMemStore())
+	n.SharedCache = cache.NewMemory(100)
+	other := storeNode(t, newMemStore())
+	other.SharedCache = n.SharedCache
+	cachePosts(n)
+
+	// Nodes sharing the cache see each other's entries and purges
+	if paths := cachedPaths(other); paths != "getPost:1 getPost:2 listPosts" {
+		t.Fatalf("expected the shared entries, got %s", paths)
+	}
+	if _, err := other.PurgeTags("user:grace"); err != nil {
+		t.Fatal(err)
+	}
+	if paths := cachedPaths(n); paths != "getPost:1 listPosts" {
+		t.Fatalf("expected post 2 purged by tag, got %s", paths)
+	}
+
+	// Invalidating a path drops the entries under its prefix
+	other.InvalidatePath("getPost")
+	if paths := cachedPaths(n); paths != "listPosts" {
+		t.Fatalf("expected the path's entries purged, got %s", paths)
+	}
+	other.ClearCache()
+	if paths := cachedPaths(n); paths != "" {
+		t.Fatalf("expected every entry purged, got %v", paths)
+	}
+}
+
+// purgingStore is a memStore failing to delete cache entries when fail is
+// set, and blocking until block is closed when it is set
+type purgingStore struct {
+	*memStore
+	fail  bool
+	block chan struct{}
+}
+
+func (s *purgingStore) Execute(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	if strings.HasPrefix(query, "DELETE FROM edge_cache WHERE key") {
+		if s.block != nil {
+			<-s.block
+		}
+		if s.fail {
+			return 0, errors.New("disk I/O error")
+		}
+	}
+	return s.memStore.Execute(ctx, query, args...)
+}
+
+// purgeNetwork returns a network of nodes caching in stores, without its
+// health checks
+func purgeNetwork(t *testing.T, stores map[string]*purgingStore) *EdgeNetwork {
+	network := &EdgeNetwork{Nodes: make(map[string]*EdgeNode)}
+	for id, store := range stores {
+		node := storeNode(t, store)
+		node.ID = id
+		cachePosts(node)
+		network.Nodes[id] = node
+	}
+	return network
+}
+
+func TestNetworkPurgeTags(t *testing.T) {
+	network := purgeNetwork(t, map[string]*purgingStore{
+		"edge-1": {memStore: newMemStore()},
+		"edge-2": {memStore: newMemStore()},
+	})
+	if _, err := network.PurgeTags(context.Background()); err == nil {
+		t.Fatal("expected a purge without tags refused")
+	}
+
+	status, err := network.PurgeTags(context.Background(), "post:1")
+	if err != nil || !status.Done() || status.Completed.IsZero() || len(status.Acks) != 2 {
+		t.Fatalf("expected both nodes to acknowledge, got %+v (%v)", status, err)
+	}
+	for _, ack := range status.Acks {
+		if ack.Purged != 2 || ack.Error != "" || ack.Latency <= 0 {
+			t.Fatalf("unexpected acknowledgement %+v", ack)
+		}
+	}
+	for id, node := range network.Nodes {
+		if paths := cachedPaths(node); paths != "getPost:2" {
+			t.Fatalf("expected %s purged, got %s", id, paths)
+		}
+	}
+
+	tracked, ok := network.PurgeStatus(status.ID)
+	if !ok || tracked.ID != "purge-1" || !tracked.Done() {
+		t.Fatalf("expected the purge tracked, got %+v", tracked)
+	}
+	if _, ok := network.PurgeStatus("purge-9"); ok {
+		t.Fatal("expected an unknown purge not found")
+	}
+}
+
+func TestNetworkPurgeFailures(t *testing.T) {
+	network := purgeNetwork(t, map[string]*purgingStore{
+		"edge-1": {memStore: newMemStore()},
+		"edge-2": {memStore: newMemStore(), fail: true},
+	})
+	status, err := network.PurgeTags(context.Background(), "post:1")
+	if err == nil || !strings.Contains(err.Error(), "failed on 1 of 2 nodes") {
+		t.Fatalf("expected the failing node reported, got %v", err)
+	}
+	for _, ack := range status.Acks {
+		if (ack.Error != "") != (ack.Node == "edge-2") {
+			t.Fatalf("unexpected acknowledgement %+v", ack)
+		}
+	}
+}
+
+func TestNetworkPurgePending(t *testing.T) {
+	slow := &purgingStore{memStore: newMemStore(), block: make(chan struct{})}
+	network := purgeNetwork(t, map[string]*purgingStore{
+		"edge-1": {memStore: newMemStore()},
+		"edge-2": slow,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	status, err := network.PurgeTags(ctx, "post:1")
+	if err != context.DeadlineExceeded || status.Done() || len(status.Pending) != 1 || status.Pending[0] != "edge-2" {
+		t.Fatalf("expected edge-2 pending, got %+v (%v)", status, err)
+	}
+
+	// The acknowledgement shows in the status once it arrives
+	close(slow.block)
+	eventually(t, "edge-2 to acknowledge", func() bool {
+		tracked, _ := network.PurgeStatus(status.ID)
+		return tracked.Done() && len(tracked.Acks) == 2
+	})
+}
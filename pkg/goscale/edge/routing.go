@@ -0,0 +1,432 @@
+package edge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/reqctx"
+)
+
+// maxDecisions caps the routing decisions a load balancer keeps for Explain
+const maxDecisions = 1000
+
+type regionKey struct{}
+
+// WithClientRegion returns a context of a request from region, such as
+// "eu-west" as the CDN in front of the network tells it, which the network
+// measures its nodes' latency from and routes "nearest" requests by
+func WithClientRegion(ctx context.Context, region string) context.Context {
+	return context.WithValue(ctx, regionKey{}, region)
+}
+
+// ClientRegion returns the region a context's request comes from, or ""
+func ClientRegion(ctx context.Context) string {
+	region, _ := ctx.Value(regionKey{}).(string)
+	return region
+}
+
+// LatencyStats are the latency measurements of a node from a region
+type LatencyStats struct {
+	Node   string `json:"node"`
+	Region string `json:"region"`
+
+	// Average weighs recent measurements more, as LatencyMap.Smoothing
+	// sets, and Last is the latest
+	Average time.Duration `json:"average"`
+	Last    time.Duration `json:"last"`
+	Samples int64         `json:"samples"`
+	Updated time.Time     `json:"updated"`
+}
+
+type latencyKey struct {
+	node, region string
+}
+
+// LatencyMap records how long requests from each region take to reach each
+// node and come back, for routing requests to the nearest node and
+// explaining why. Latency leaves out the time the node spends serving a
+// request, with its handler and the origin, which the "fastest" strategy
+// compares instead: for the nodes of an EdgeNetwork it is the time waiting
+// for a worker, plus the round trip to a node in another process. Clients
+// and CDNs measuring round trips from their region can Record them too.
+type LatencyMap struct {
+	// Smoothing is the weight of each measurement in the average, from 0
+	// to 1; 0.2 by default
+	Smoothing float64
+
+	mutex sync.RWMutex
+	stats map[latencyKey]*LatencyStats
+}
+
+// NewLatencyMap creates an empty latency map
+func NewLatencyMap() *LatencyMap {
+	return &LatencyMap{Smoothing: 0.2, stats: make(map[latencyKey]*LatencyStats)}
+}
+
+// Record records a node's latency to a request from region
+func (m *LatencyMap) Record(node, region string, latency time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key := latencyKey{node, region}
+	stats := m.stats[key]
+	if stats == nil {
+		stats = &LatencyStats{Node: node, Region: region, Average: latency}
+		m.stats[key] = stats
+	} else {
+		smoothing := m.Smoothing
+		if smoothing <= 0 || smoothing > 1 {
+			smoothing = 0.2
+		}
+		stats.Average += time.Duration(smoothing * float64(latency-stats.Average))
+	}
+	stats.Last = latency
+	stats.Samples++
+	stats.Updated = time.Now()
+}
+
+// Get returns a node's latency from region, if it was measured
+func (m *LatencyMap) Get(node, region string) (LatencyStats, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	stats, ok := m.stats[latencyKey{node, region}]
+	if !ok {
+		return LatencyStats{}, false
+	}
+	return *stats, true
+}
+
+// Snapshot returns every node's latency from every region, by region and
+// then node
+func (m *LatencyMap) Snapshot() []LatencyStats {
+	m.mutex.RLock()
+	snapshot := make([]LatencyStats, 0, len(m.stats))
+	for _, stats := range m.stats {
+		snapshot = append(snapshot, *stats)
+	}
+	m.mutex.RUnlock()
+
+	sort.Slice(snapshot, func(i, j int) bool {
+		if snapshot[i].Region != snapshot[j].Region {
+			return snapshot[i].Region < snapshot[j].Region
+		}
+		return snapshot[i].Node < snapshot[j].Node
+	})
+	return snapshot
+}
+
+// Remove forgets a node's measurements, as when it leaves the network
+func (m *LatencyMap) Remove(node string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for key := range m.stats {
+		if key.node == node {
+			delete(m.stats, key)
+		}
+	}
+}
+
+// Candidate is a node a routing decision considered
+type Candidate struct {
+	Node     string `json:"node"`
+	Region   string `json:"region"`
+	Healthy  bool   `json:"healthy"`
+	Load     int    `json:"load"`
+	Capacity int    `json:"capacity"`
+
+	// ResponseTime is the node's average response time in seconds, which
+	// the "fastest" strategy compares
+	ResponseTime float64 `json:"response_time"`
+
+	// Latency is the node's average latency from the request's region,
+	// over Samples measurements; 0 when it was not measured
+	Latency time.Duration `json:"latency"`
+	Samples int64         `json:"samples"`
+
+	// Excluded says why the node could not be selected, such as
+	// "unhealthy"
+	Excluded string `json:"excluded,omitempty"`
+}
+
+// RoutingDecision explains why a request was routed to a node: the
+// strategy, the affinity pinning it, and how each node compared
+type RoutingDecision struct {
+	RequestID string `json:"request_id,omitempty"`
+	Path      string `json:"path"`
+	Region    string `json:"region,omitempty"`
+	Strategy  string `json:"strategy"`
+
+	// Affinity is the value of the balancer's AffinityParam the request
+	// was pinned to a node by, if any
+	Affinity string `json:"affinity,omitempty"`
+
+	Selected string `json:"selected,omitempty"`
+
+	// Reason says why Selected was chosen over the other candidates, or
+	// why none could be
+	Reason     string      `json:"reason"`
+	Candidates []Candidate `json:"candidates"`
+	Time       time.Time   `json:"time"`
+}
+
+// Route selects the node for a request from the region ClientRegion finds
+// in ctx and explains the choice, keeping the decision for Explain. Only
+// healthy nodes are selected. A request with the balancer's AffinityParam
+// is pinned to the node the param's value hashes to, so requests for the
+// same user or document share a node's cache; others are routed by the
+// Strategy:
+//
+//   - "round-robin", the default, takes turns
+//   - "least-loaded" takes the node with the lowest Load
+//   - "fastest" takes the node with the lowest average response time
+//   - "nearest" takes the node with the lowest latency measured from the
+//     request's region, or else the least loaded in its region
+func (l *LoadBalancer) Route(ctx context.Context, path string, params map[string]interface{}) (*EdgeNode, *RoutingDecision, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	node, decision, err := l.decide(ctx, path, params, false)
+	decision.RequestID = reqctx.From(ctx).RequestID
+	l.decisions = append(l.decisions, decision)
+	if over := len(l.decisions) - maxDecisions; over > 0 {
+		l.decisions = append(l.decisions[:0], l.decisions[over:]...)
+	}
+	return node, decision, err
+}
+
+// Explain returns the decision Route made for the request with an ID, if
+// it is among the last kept
+func (l *LoadBalancer) Explain(requestID string) (*RoutingDecision, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for i := len(l.decisions) - 1; i >= 0; i-- {
+		if l.decisions[i].RequestID == requestID {
+			return l.decisions[i], true
+		}
+	}
+	return nil, false
+}
+
+// Decisions returns up to limit of the last routing decisions, newest
+// first
+func (l *LoadBalancer) Decisions(limit int) []*RoutingDecision {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	decisions := make([]*RoutingDecision, 0, len(l.decisions))
+	for i := len(l.decisions) - 1; i >= 0 && (limit <= 0 || len(decisions) < limit); i-- {
+		decisions = append(decisions, l.decisions[i])
+	}
+	return decisions
+}
+
+// Simulate explains where Route would send a request, without routing it
+func (l *LoadBalancer) Simulate(ctx context.Context, path string, params map[string]interface{}) *RoutingDecision {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	_, decision, _ := l.decide(ctx, path, params, true)
+	return decision
+}
+
+// decide selects a node, advancing the round-robin turn unless simulating.
+// The caller holds l.mutex.
+func (l *LoadBalancer) decide(ctx context.Context, path string, params map[string]interface{}, simulate bool) (*EdgeNode, *RoutingDecision, error) {
+	region := ClientRegion(ctx)
+	decision := &RoutingDecision{Path: path, Region: region, Strategy: l.Strategy, Time: time.Now()}
+	if decision.Strategy == "" {
+		decision.Strategy = "round-robin"
+	}
+
+	l.Network.mutex.RLock()
+	nodes := make([]*EdgeNode, 0, len(l.Network.Nodes))
+	for _, node := range l.Network.Nodes {
+		nodes = append(nodes, node)
+	}
+	l.Network.mutex.RUnlock()
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+
+	var healthy []*EdgeNode
+	decision.Candidates = make([]Candidate, 0, len(nodes))
+	candidates := make(map[*EdgeNode]*Candidate, len(nodes))
+	for _, node := range nodes {
+		node.Metrics.mutex.RLock()
+		candidate := Candidate{
+			Node:         node.ID,
+			Region:       node.Region,
+			Healthy:      node.HealthStatus == "healthy",
+			Load:         node.Load,
+			Capacity:     node.Capacity,
+			ResponseTime: node.Metrics.AvgResponseTime,
+		}
+		errorRate := node.Metrics.ErrorRate
+		node.Metrics.mutex.RUnlock()
+		if stats, ok := l.Network.Latency.Get(node.ID, region); ok {
+			candidate.Latency, candidate.Samples = stats.Average, stats.Samples
+		}
+		if candidate.Healthy {
+			healthy = append(healthy, node)
+		} else {
+			candidate.Excluded = fmt.Sprintf("%s (error rate %.0f%%)", node.HealthStatus, errorRate*100)
+		}
+		decision.Candidates = append(decision.Candidates, candidate)
+		candidates[node] = &decision.Candidates[len(decision.Candidates)-1]
+	}
+
+	switch {
+	case len(nodes) == 0:
+		decision.Reason = "no nodes available"
+		return nil, decision, errors.New("no nodes available")
+	case len(healthy) == 0:
+		decision.Reason = "no healthy nodes available"
+		return nil, decision, errors.New("no healthy nodes available")
+	}
+
+	var selected *EdgeNode
+	if value, ok := params[l.AffinityParam]; ok && l.AffinityParam != "" && value != nil {
+		decision.Affinity = fmt.Sprint(value)
+		var best uint64
+		for _, node := range healthy {
+			hash := fnv.New64a()
+			hash.Write([]byte(node.ID + "\x00" + decision.Affinity))
+			if score := hash.Sum64(); selected == nil || score > best {
+				selected, best = node, score
+			}
+		}
+		decision.Reason = fmt.Sprintf("pinned by %s=%s to one of %d healthy nodes", l.AffinityParam, decision.Affinity, len(healthy))
+	} else {
+		selected, decision.Reason = l.pick(decision.Strategy, healthy, candidates, region, simulate)
+	}
+
+	decision.Selected = selected.ID
+	return selected, decision, nil
+}
+
+// pick selects one of the healthy nodes by a strategy, saying why
+func (l *LoadBalancer) pick(strategy string, healthy []*EdgeNode, candidates map[*EdgeNode]*Candidate, region string, simulate bool) (*EdgeNode, string) {
+	leastLoaded := func(nodes []*EdgeNode) *EdgeNode {
+		selected := nodes[0]
+		for _, node := range nodes {
+			if node.Load < selected.Load {
+				selected = node
+			}
+		}
+		return selected
+	}
+
+	switch strategy {
+	case "least-loaded":
+		selected := leastLoaded(healthy)
+		return selected, fmt.Sprintf("lowest load (%d of %d) of %d healthy nodes", selected.Load, selected.Capacity, len(healthy))
+	case "fastest":
+		selected := healthy[0]
+		for _, node := range healthy {
+			if candidates[node].ResponseTime < candidates[selected].ResponseTime {
+				selected = node
+			}
+		}
+		return selected, fmt.Sprintf("lowest average response time (%.1fms) of %d healthy nodes", candidates[selected].ResponseTime*1000, len(healthy))
+	case "nearest":
+		var selected *EdgeNode
+		for _, node := range healthy {
+			if c := candidates[node]; c.Samples > 0 && (selected == nil || c.Latency < candidates[selected].Latency) {
+				selected = node
+			}
+		}
+		if selected != nil {
+			c := candidates[selected]
+			return selected, fmt.Sprintf("lowest latency from %s (%s average, %d measured)", regionName(region), c.Latency.Round(time.Microsecond), c.Samples)
+		}
+		var local []*EdgeNode
+		for _, node := range healthy {
+			if node.Region == region {
+				local = append(local, node)
+			}
+		}
+		if len(local) > 0 {
+			return leastLoaded(local), fmt.Sprintf("no latency measured from %s; least loaded of its %d nodes", region, len(local))
+		}
+		return leastLoaded(healthy), fmt.Sprintf("no latency measured from %s and no nodes in it; least loaded", regionName(region))
+	}
+
+	turn := l.RequestCounter + 1
+	if !simulate {
+		l.RequestCounter = turn
+	}
+	selected := healthy[turn%int64(len(healthy))]
+	return selected, fmt.Sprintf("round-robin turn %d of %d healthy nodes", turn, len(healthy))
+}
+
+// regionName names a region in explanations
+func regionName(region string) string {
+	if region == "" {
+		return "an unknown region"
+	}
+	return region
+}
+
+// RoutingHandler serves the network's routing for debugging uneven
+// traffic. GET with a request query parameter explains the decision for
+// that request ID; with a path, it explains where a request for the path
+// from a region would go, taking its other query parameters as the
+// request's params, such as ?path=query:getUser&region=eu-west&userId=42.
+// Otherwise it returns the latency map and up to limit of the last
+// decisions, 100 by default. It does no authorization; mount it behind the
+// app's admin login.
+func (n *EdgeNetwork) RoutingHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		var response interface{}
+		switch {
+		case query.Get("request") != "":
+			decision, ok := n.LoadBalancer.Explain(query.Get("request"))
+			if !ok {
+				http.Error(w, fmt.Sprintf("no routing decision for request %q", query.Get("request")), http.StatusNotFound)
+				return
+			}
+			response = decision
+		case query.Get("path") != "":
+			params := make(map[string]interface{})
+			for name, values := range query {
+				if name != "path" && name != "region" {
+					params[name] = values[0]
+				}
+			}
+			ctx := WithClientRegion(r.Context(), query.Get("region"))
+			response = n.LoadBalancer.Simulate(ctx, query.Get("path"), params)
+		default:
+			limit := 100
+			if value := query.Get("limit"); value != "" {
+				var err error
+				if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+					http.Error(w, "invalid limit", http.StatusBadRequest)
+					return
+				}
+			}
+			response = map[string]interface{}{
+				"latency":   n.Latency.Snapshot(),
+				"decisions": n.LoadBalancer.Decisions(limit),
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	})
+}
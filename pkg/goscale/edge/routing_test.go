@@ -0,0 +1,235 @@
+package edge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/reqctx"
+)
+
+func TestLatencyMap(t *testing.T) {
+	m := NewLatencyMap()
+	m.Smoothing = 0.5
+	m.Record("edge-1", "eu-west", 10*time.Millisecond)
+	m.Record("edge-1", "eu-west", 30*time.Millisecond)
+	m.Record("edge-2", "eu-west", 5*time.Millisecond)
+	m.Record("edge-1", "ap-south", 80*time.Millisecond)
+
+	stats, ok := m.Get("edge-1", "eu-west")
+	if !ok || stats.Average != 20*time.Millisecond || stats.Last != 30*time.Millisecond || stats.Samples != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if _, ok := m.Get("edge-2", "ap-south"); ok {
+		t.Fatal("expected no latency measured from ap-south")
+	}
+
+	var order []string
+	for _, stats := range m.Snapshot() {
+		order = append(order, stats.Region+"/"+stats.Node)
+	}
+	if strings.Join(order, " ") != "ap-south/edge-1 eu-west/edge-1 eu-west/edge-2" {
+		t.Fatalf("unexpected snapshot order %v", order)
+	}
+
+	m.Remove("edge-1")
+	if snapshot := m.Snapshot(); len(snapshot) != 1 || snapshot[0].Node != "edge-2" {
+		t.Fatalf("expected only edge-2 left, got %+v", snapshot)
+	}
+}
+
+func TestProcessRequestRecordsLatency(t *testing.T) {
+	config := DefaultConfig()
+	config.CacheEnabled = false
+	node := NewEdgeNode(config, nil)
+	node.RegisterHandler("slow", func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		time.Sleep(50 * time.Millisecond)
+		return "done", nil
+	})
+	network := &EdgeNetwork{Nodes: map[string]*EdgeNode{node.ID: node}, Latency: NewLatencyMap()}
+	network.LoadBalancer = &LoadBalancer{Network: network}
+
+	ctx := WithClientRegion(context.Background(), "eu-west")
+	if result, err := network.ProcessRequest(ctx, "slow", nil); err != nil || result != "done" {
+		t.Fatalf("unexpected result %v (%v)", result, err)
+	}
+
+	// The latency leaves out the time the handler took
+	stats, ok := network.Latency.Get(node.ID, "eu-west")
+	if !ok || stats.Samples != 1 || stats.Last >= 50*time.Millisecond {
+		t.Fatalf("expected the latency without the handler's time, got %+v", stats)
+	}
+	if _, err := network.ProcessRequest(ctx, "missing", nil); err == nil {
+		t.Fatal("expected a request without a handler to fail")
+	}
+	if stats, _ := network.Latency.Get(node.ID, "eu-west"); stats.Samples != 1 {
+		t.Fatalf("expected a failed request not measured, got %+v", stats)
+	}
+}
+
+// routingNetwork returns a network of nodes in eu-west, edge-1 and edge-2,
+// and us-east, edge-3, which is unhealthy
+func routingNetwork() *EdgeNetwork {
+	network := &EdgeNetwork{Nodes: make(map[string]*EdgeNode), Latency: NewLatencyMap()}
+	network.LoadBalancer = &LoadBalancer{Network: network}
+	for _, node := range []*EdgeNode{
+		{ID: "edge-1", Region: "eu-west", Load: 40, Capacity: 100, HealthStatus: "healthy", Metrics: &EdgeMetrics{AvgResponseTime: 0.030}},
+		{ID: "edge-2", Region: "eu-west", Load: 10, Capacity: 100, HealthStatus: "healthy", Metrics: &EdgeMetrics{AvgResponseTime: 0.050}},
+		{ID: "edge-3", Region: "us-east", Load: 0, Capacity: 100, HealthStatus: "unhealthy", Metrics: &EdgeMetrics{AvgResponseTime: 0.001, ErrorRate: 0.5}},
+	} {
+		network.Nodes[node.ID] = node
+	}
+	return network
+}
+
+func TestRouteStrategies(t *testing.T) {
+	for _, test := range []struct {
+		strategy string
+		region   string
+		selected string
+		reason   string
+	}{
+		{"least-loaded", "", "edge-2", "lowest load (10 of 100) of 2 healthy nodes"},
+		{"fastest", "", "edge-1", "lowest average response time (30.0ms) of 2 healthy nodes"},
+		{"nearest", "eu-west", "edge-1", "lowest latency from eu-west (2ms average, 1 measured)"},
+		{"nearest", "", "edge-2", "no latency measured from an unknown region and no nodes in it; least loaded"},
+		{"round-robin", "", "edge-2", "round-robin turn 1 of 2 healthy nodes"},
+	} {
+		network := routingNetwork()
+		network.LoadBalancer.Strategy = test.strategy
+		network.Latency.Record("edge-1", "eu-west", 2*time.Millisecond)
+		network.Latency.Record("edge-2", "eu-west", 9*time.Millisecond)
+		network.Latency.Record("edge-3", "eu-west", time.Millisecond)
+
+		ctx := WithClientRegion(context.Background(), test.region)
+		node, decision, err := network.LoadBalancer.Route(ctx, "getPost", nil)
+		if err != nil || node.ID != test.selected || decision.Selected != test.selected {
+			t.Fatalf("%s: expected %s selected, got %+v (%v)", test.strategy, test.selected, decision, err)
+		}
+		if decision.Reason != test.reason {
+			t.Fatalf("%s: unexpected reason %q", test.strategy, decision.Reason)
+		}
+	}
+}
+
+func TestRouteCandidates(t *testing.T) {
+	network := routingNetwork()
+	network.Latency.Record("edge-1", "eu-west", 2*time.Millisecond)
+	_, decision, _ := network.LoadBalancer.Route(WithClientRegion(context.Background(), "eu-west"), "getPost", nil)
+
+	if decision.Strategy != "round-robin" || len(decision.Candidates) != 3 {
+		t.Fatalf("unexpected decision %+v", decision)
+	}
+	measured, unmeasured, unhealthy := decision.Candidates[0], decision.Candidates[1], decision.Candidates[2]
+	if measured.Latency != 2*time.Millisecond || measured.Samples != 1 || measured.Load != 40 || measured.Excluded != "" {
+		t.Fatalf("unexpected candidate %+v", measured)
+	}
+	if unmeasured.Latency != 0 || unmeasured.Samples != 0 {
+		t.Fatalf("expected edge-2 unmeasured, got %+v", unmeasured)
+	}
+	if unhealthy.Healthy || unhealthy.Excluded != "unhealthy (error rate 50%)" {
+		t.Fatalf("expected edge-3 excluded, got %+v", unhealthy)
+	}
+
+	network.Nodes["edge-1"].HealthStatus = "degraded"
+	network.Nodes["edge-2"].HealthStatus = "degraded"
+	if _, decision, err := network.LoadBalancer.Route(context.Background(), "getPost", nil); err == nil || decision.Reason != "no healthy nodes available" {
+		t.Fatalf("expected no node selected, got %+v (%v)", decision, err)
+	}
+}
+
+func TestRouteAffinity(t *testing.T) {
+	network := routingNetwork()
+	network.LoadBalancer.AffinityParam = "userId"
+
+	var selected string
+	for i := 0; i < 5; i++ {
+		node, decision, err := network.LoadBalancer.Route(context.Background(), "getUser", map[string]interface{}{"userId": 42})
+		if err != nil || decision.Affinity != "42" || decision.Reason != "pinned by userId=42 to one of 2 healthy nodes" {
+			t.Fatalf("unexpected decision %+v (%v)", decision, err)
+		}
+		if selected != "" && node.ID != selected {
+			t.Fatalf("expected user 42 pinned to %s, got %s", selected, node.ID)
+		}
+		selected = node.ID
+	}
+	if network.LoadBalancer.RequestCounter != 0 {
+		t.Fatal("expected pinned requests not to take round-robin turns")
+	}
+}
+
+func TestExplain(t *testing.T) {
+	network := routingNetwork()
+	for _, id := range []string{"req-1", "req-2", "req-3"} {
+		ctx := reqctx.With(context.Background(), reqctx.Info{RequestID: id})
+		network.LoadBalancer.Route(ctx, "getPost", nil)
+	}
+
+	decision, ok := network.LoadBalancer.Explain("req-2")
+	if !ok || decision.RequestID != "req-2" || decision.Reason != "round-robin turn 2 of 2 healthy nodes" {
+		t.Fatalf("unexpected explanation %+v", decision)
+	}
+	if _, ok := network.LoadBalancer.Explain("req-9"); ok {
+		t.Fatal("expected an unknown request not explained")
+	}
+	if decisions := network.LoadBalancer.Decisions(2); len(decisions) != 2 || decisions[0].RequestID != "req-3" {
+		t.Fatalf("expected the last two decisions, newest first, got %+v", decisions)
+	}
+
+	// Simulating does not take a turn or keep the decision
+	simulated := network.LoadBalancer.Simulate(context.Background(), "getPost", nil)
+	if simulated.Reason != "round-robin turn 4 of 2 healthy nodes" || network.LoadBalancer.RequestCounter != 3 {
+		t.Fatalf("unexpected simulation %+v", simulated)
+	}
+	if decisions := network.LoadBalancer.Decisions(0); len(decisions) != 3 {
+		t.Fatalf("expected three decisions kept, got %d", len(decisions))
+	}
+}
+
+func TestRoutingHandler(t *testing.T) {
+	network := routingNetwork()
+	network.Latency.Record("edge-1", "eu-west", 2*time.Millisecond)
+	network.LoadBalancer.Route(reqctx.With(context.Background(), reqctx.Info{RequestID: "req-1"}), "getPost", nil)
+	handler := network.RoutingHandler()
+
+	get := func(target string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+		return recorder
+	}
+
+	var decision RoutingDecision
+	if recorder := get("/?request=req-1"); recorder.Code != http.StatusOK || json.Unmarshal(recorder.Body.Bytes(), &decision) != nil || decision.RequestID != "req-1" {
+		t.Fatalf("unexpected explanation %d %s", recorder.Code, recorder.Body)
+	}
+	if recorder := get("/?request=req-9"); recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected an unknown request not found, got %d", recorder.Code)
+	}
+
+	network.LoadBalancer.Strategy = "nearest"
+	decision = RoutingDecision{}
+	if recorder := get("/?path=getUser&region=eu-west&userId=42"); json.Unmarshal(recorder.Body.Bytes(), &decision) != nil || decision.Region != "eu-west" || decision.Affinity != "" || decision.Selected != "edge-1" {
+		t.Fatalf("unexpected simulation %s", recorder.Body)
+	}
+
+	var overview struct {
+		Latency   []LatencyStats     `json:"latency"`
+		Decisions []*RoutingDecision `json:"decisions"`
+	}
+	if recorder := get("/?limit=5"); json.Unmarshal(recorder.Body.Bytes(), &overview) != nil || len(overview.Latency) != 1 || len(overview.Decisions) != 1 {
+		t.Fatalf("unexpected overview %s", recorder.Body)
+	}
+	if recorder := get("/?limit=-1"); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected an invalid limit refused, got %d", recorder.Code)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected POST refused, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build sqlite
+// +build sqlite
+
+package edge
+
+import (
+	"github.com/davidjeba/goscript/pkg/goscale/db"
+
+	// The module does not require a SQLite driver; add it to a working copy
+	// with go get modernc.org/sqlite, then go test -tags sqlite
+	_ "modernc.org/sqlite"
+)
+
+func init() {
+	db.SQLiteDriver = "sqlite"
+}
@@ -0,0 +1,423 @@
+package edge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscale/db"
+	"github.com/davidjeba/goscript/pkg/goscript/reqctx"
+)
+
+// ErrWriteQueued is returned by Write when the origin could not be reached
+// and the write was kept in the local store, to be sent by the next sync.
+var ErrWriteQueued = errors.New("edge: origin unreachable, write queued")
+
+// errNoOrigin is the error of writes made with neither an Origin nor a
+// parent API to send them to
+var errNoOrigin = errors.New("edge: no origin to send writes to")
+
+// QueuedWrite is a write the local store holds until the origin can be
+// reached. It is sent for the request it was made for, with its principal,
+// tenant and request ID.
+type QueuedWrite struct {
+	ID        int64
+	Path      string
+	Params    map[string]interface{}
+	Request   reqctx.Info
+	Attempts  int
+	LastError string
+	CreatedAt time.Time
+}
+
+// localStoreTables are the tables the local store keeps the cache, the
+// queued writes and the dead writes in. The statements run on SQLite and
+// PostgreSQL alike, but for the queue's key, which localStoreKey gives.
+var localStoreTables = []string{
+	`CREATE TABLE IF NOT EXISTS edge_cache (
+		key TEXT PRIMARY KEY,
+		path TEXT NOT NULL,
+		result TEXT NOT NULL,
+		expires_at BIGINT NOT NULL,
+		tags TEXT NOT NULL DEFAULT '[]'
+	)`,
+	`CREATE TABLE IF NOT EXISTS edge_write_queue (
+		id %s,
+		path TEXT NOT NULL,
+		params TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		created_at BIGINT NOT NULL,
+		request TEXT NOT NULL DEFAULT '{}'
+	)`,
+	`CREATE TABLE IF NOT EXISTS edge_dead_writes (
+		id BIGINT PRIMARY KEY,
+		path TEXT NOT NULL,
+		params TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		last_error TEXT NOT NULL DEFAULT '',
+		created_at BIGINT NOT NULL,
+		request TEXT NOT NULL DEFAULT '{}',
+		dead_at BIGINT NOT NULL
+	)`,
+}
+
+// localStoreKey is the column type of the write queue's key, which the
+// database assigns in order and never reuses, so concurrent writes cannot
+// take the same one
+func localStoreKey(store db.Store) string {
+	if _, ok := store.(*db.SQLiteStore); ok {
+		return "INTEGER PRIMARY KEY AUTOINCREMENT"
+	}
+	return "BIGSERIAL PRIMARY KEY"
+}
+
+// openLocalStore opens the SQLite database at the config's LocalStorePath
+// for the node. A node whose store cannot be opened runs on, with its cache
+// in memory and writes failing while the origin is unreachable.
+func (n *EdgeNode) openLocalStore(path string) {
+	store, err := db.OpenSQLite(path)
+	if err == nil {
+		if err = n.SetLocalStore(context.Background(), store); err != nil {
+			store.Close()
+		}
+	}
+	if err != nil {
+		log.Printf("edge %s: cannot open the local store %s: %v", n.ID, path, err)
+	}
+}
+
+// SetLocalStore keeps the node's cache and the writes waiting for the
+// origin in store, such as a db.SQLiteStore, so they survive restarts. It
+// creates the tables and loads the cache entries a previous run left that
+// have not expired.
+func (n *EdgeNode) SetLocalStore(ctx context.Context, store db.Store) error {
+	for _, statement := range localStoreTables {
+		if strings.Contains(statement, "%s") {
+			statement = fmt.Sprintf(statement, localStoreKey(store))
+		}
+		if _, err := store.Execute(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	if _, err := store.Execute(ctx, "DELETE FROM edge_cache WHERE expires_at <= $1", now.UnixNano()); err != nil {
+		return err
+	}
+	entries := make(map[string]*CacheEntry)
+	err := store.QueryEach(ctx, "SELECT key, path, result, expires_at, tags FROM edge_cache", func(row map[string]interface{}) error {
+		var result interface{}
+		if err := decodeJSON(row["result"], &result); err != nil {
+			return nil
+		}
+		var tags []string
+		decodeJSON(row["tags"], &tags)
+		entries[fmt.Sprint(row["key"])] = &CacheEntry{
+			Path:       fmt.Sprint(row["path"]),
+			Result:     result,
+			Tags:       tags,
+			Expiration: time.Unix(0, toInt64(row["expires_at"])),
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	n.CacheMutex.Lock()
+	for key, entry := range entries {
+		n.Cache[key] = entry
+	}
+	n.LocalStore = store
+	n.CacheMutex.Unlock()
+	return nil
+}
+
+// persist writes a cache entry to the local store, if the node has one.
+// Results are kept as JSON, so those loaded on start come back decoded
+// from it.
+func (n *EdgeNode) persist(key string, entry *CacheEntry) {
+	if n.LocalStore == nil {
+		return
+	}
+	data, err := json.Marshal(entry.Result)
+	if err != nil {
+		return
+	}
+	tags, _ := json.Marshal(entry.Tags)
+	if entry.Tags == nil {
+		tags = []byte("[]")
+	}
+	n.LocalStore.Execute(context.Background(), `INSERT INTO edge_cache (key, path, result, expires_at, tags) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (key) DO UPDATE SET path = excluded.path, result = excluded.result, expires_at = excluded.expires_at, tags = excluded.tags`,
+		key, entry.Path, string(data), entry.Expiration.UnixNano(), string(tags))
+}
+
+// unpersist deletes a path's cache entries from the local store, or every
+// entry for "".
+func (n *EdgeNode) unpersist(path string) {
+	if n.LocalStore == nil {
+		return
+	}
+	if path == "" {
+		n.LocalStore.Execute(context.Background(), "DELETE FROM edge_cache")
+		return
+	}
+	n.LocalStore.Execute(context.Background(), "DELETE FROM edge_cache WHERE path = $1", path)
+}
+
+// unpersistKeys deletes cache entries from the local store by key
+func (n *EdgeNode) unpersistKeys(keys []string) error {
+	if n.LocalStore == nil {
+		return nil
+	}
+	for _, key := range keys {
+		if _, err := n.LocalStore.Execute(context.Background(), "DELETE FROM edge_cache WHERE key = $1", key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write sends a write, such as a mutation, to the origin: through Origin
+// if it is set, or else the parent API's resolver for the path. When the
+// origin is unreachable and the node has a local store, the write is kept
+// there and ErrWriteQueued returned; the next sync sends it. Writes are
+// sent one at a time and after those already queued, so the origin gets
+// them in order.
+func (n *EdgeNode) Write(ctx context.Context, path string, params map[string]interface{}) (interface{}, error) {
+	n.writeMutex.Lock()
+	defer n.writeMutex.Unlock()
+
+	if n.LocalStore == nil {
+		return n.forward(ctx, path, params)
+	}
+
+	// Writes queued before this one go first; while any are left, this one
+	// waits behind them untried
+	queued, err := n.replayWrites(ctx)
+	if err != nil && queued == 0 {
+		log.Printf("edge %s: %v", n.ID, err)
+	}
+	var reason error
+	if queued == 0 {
+		result, err := n.forward(ctx, path, params)
+		if !unreachable(err) {
+			return result, err
+		}
+		reason = err
+	}
+	if err := n.queueWrite(ctx, path, params, reason); err != nil {
+		return nil, err
+	}
+	return nil, ErrWriteQueued
+}
+
+// QueuedWrites returns the writes waiting for the origin, oldest first.
+// Writes that cannot be decoded are left out; the next sync moves them to
+// the dead writes.
+func (n *EdgeNode) QueuedWrites(ctx context.Context) ([]*QueuedWrite, error) {
+	if n.LocalStore == nil {
+		return nil, nil
+	}
+	writes, undecodable, err := n.readWrites(ctx, "edge_write_queue")
+	if err != nil {
+		return nil, err
+	}
+	queued := writes[:0]
+	for _, write := range writes {
+		if undecodable[write.ID] == nil {
+			queued = append(queued, write)
+		}
+	}
+	return queued, nil
+}
+
+// DeadWrites returns the writes the origin rejected, or that could not be
+// decoded, oldest first, with why in LastError. The local store keeps them
+// for an operator to inspect, rather than dropping them or retrying them
+// forever.
+func (n *EdgeNode) DeadWrites(ctx context.Context) ([]*QueuedWrite, error) {
+	if n.LocalStore == nil {
+		return nil, nil
+	}
+	writes, _, err := n.readWrites(ctx, "edge_dead_writes")
+	return writes, err
+}
+
+// readWrites reads the writes of a table, oldest first, with the errors of
+// those whose params or request cannot be decoded by ID
+func (n *EdgeNode) readWrites(ctx context.Context, table string) ([]*QueuedWrite, map[int64]error, error) {
+	var writes []*QueuedWrite
+	undecodable := make(map[int64]error)
+	err := n.LocalStore.QueryEach(ctx, "SELECT id, path, params, request, attempts, last_error, created_at FROM "+table+" ORDER BY id", func(row map[string]interface{}) error {
+		write := &QueuedWrite{
+			ID:        toInt64(row["id"]),
+			Path:      fmt.Sprint(row["path"]),
+			Attempts:  int(toInt64(row["attempts"])),
+			LastError: fmt.Sprint(row["last_error"]),
+			CreatedAt: time.Unix(0, toInt64(row["created_at"])),
+		}
+		err := decodeJSON(row["params"], &write.Params)
+		if err == nil {
+			err = decodeJSON(row["request"], &write.Request)
+		}
+		if err != nil {
+			undecodable[write.ID] = err
+		}
+		writes = append(writes, write)
+		return nil
+	})
+	return writes, undecodable, err
+}
+
+// forward sends a write to the origin.
+func (n *EdgeNode) forward(ctx context.Context, path string, params map[string]interface{}) (interface{}, error) {
+	if n.Origin != nil {
+		return n.Origin(ctx, path, params)
+	}
+	if n.ParentAPI == nil {
+		return nil, errNoOrigin
+	}
+	resolver, ok := n.ParentAPI.GetResolvers()[path]
+	if !ok {
+		return nil, fmt.Errorf("no resolver found for path %s", path)
+	}
+	return resolver(ctx, params)
+}
+
+// queueWrite keeps a write for the origin, with the request it was made
+// for and the error sending it failed with, or nil if it was not tried.
+func (n *EdgeNode) queueWrite(ctx context.Context, path string, params map[string]interface{}, reason error) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	request, err := json.Marshal(reqctx.From(ctx))
+	if err != nil {
+		return err
+	}
+	attempts, lastError := 0, ""
+	if reason != nil {
+		attempts, lastError = 1, reason.Error()
+	}
+
+	_, err = n.LocalStore.Execute(ctx, "INSERT INTO edge_write_queue (path, params, request, attempts, last_error, created_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		path, string(data), string(request), attempts, lastError, time.Now().UnixNano())
+	return err
+}
+
+// replayWrites sends the queued writes to the origin, oldest first, each
+// for the request it was made for, returning how many are still queued. It
+// stops at a write the origin is unreachable for. Writes the origin rejects,
+// and those that cannot be decoded, are moved to the dead writes, as sending
+// them again cannot succeed, and the first of their errors is returned.
+func (n *EdgeNode) replayWrites(ctx context.Context) (int, error) {
+	writes, undecodable, err := n.readWrites(ctx, "edge_write_queue")
+	if err != nil {
+		return 0, err
+	}
+
+	var dead error
+	for i, write := range writes {
+		if err := undecodable[write.ID]; err != nil {
+			if dead == nil {
+				dead = fmt.Errorf("edge: write %d to %s cannot be decoded: %w", write.ID, write.Path, err)
+			}
+			if err := n.deadLetter(ctx, write.ID, "cannot be decoded: "+err.Error(), 0); err != nil {
+				return len(writes) - i, err
+			}
+			continue
+		}
+
+		_, err := n.forward(reqctx.With(ctx, write.Request), write.Path, write.Params)
+		if unreachable(err) {
+			n.LocalStore.Execute(ctx, "UPDATE edge_write_queue SET attempts = attempts + 1, last_error = $1 WHERE id = $2", err.Error(), write.ID)
+			return len(writes) - i, err
+		}
+		if err != nil {
+			if dead == nil {
+				dead = fmt.Errorf("edge: write %d to %s rejected: %w", write.ID, write.Path, err)
+			}
+			err = n.deadLetter(ctx, write.ID, err.Error(), 1)
+		} else {
+			_, err = n.LocalStore.Execute(ctx, "DELETE FROM edge_write_queue WHERE id = $1", write.ID)
+		}
+		if err != nil {
+			return len(writes) - i, err
+		}
+	}
+	return 0, dead
+}
+
+// deadLetter moves a queued write to the dead writes, with why it cannot be
+// sent and the attempts it took
+func (n *EdgeNode) deadLetter(ctx context.Context, id int64, reason string, attempts int) error {
+	_, err := n.LocalStore.Execute(ctx, `INSERT INTO edge_dead_writes (id, path, params, request, attempts, last_error, created_at, dead_at)
+		SELECT id, path, params, request, attempts + $1, $2, created_at, $3 FROM edge_write_queue WHERE id = $4`,
+		attempts, reason, time.Now().UnixNano(), id)
+	if err != nil {
+		return err
+	}
+	_, err = n.LocalStore.Execute(ctx, "DELETE FROM edge_write_queue WHERE id = $1", id)
+	return err
+}
+
+// unreachable reports whether a write failed for want of reaching the
+// origin, rather than being rejected by it: network errors and others that
+// say they are temporary, such as a goscale client's, and writes made
+// without an origin.
+func unreachable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, errNoOrigin) {
+		return true
+	}
+	var temporary interface{ Temporary() bool }
+	if errors.As(err, &temporary) && temporary.Temporary() {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// decodeJSON decodes a column holding JSON text into v. Drivers returning
+// text as bytes have it decoded by the scan already.
+func decodeJSON(value interface{}, v interface{}) error {
+	data, ok := value.(string)
+	if !ok {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		data = string(encoded)
+	}
+	return json.Unmarshal([]byte(data), v)
+}
+
+// toInt64 converts an integer read from the local store.
+func toInt64(value interface{}) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	case []byte:
+		n, _ := strconv.ParseInt(string(v), 10, 64)
+		return n
+	case string:
+		n, _ := strconv.ParseInt(v, 10, 64)
+		return n
+	}
+	return 0
+}
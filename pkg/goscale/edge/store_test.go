@@ -0,0 +1,369 @@
+package edge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscale/db"
+	"github.com/davidjeba/goscript/pkg/goscript/reqctx"
+)
+
+// memStore is a db.Store keeping the local store's tables in memory. It
+// runs the statements the node runs, matched by their start, and fails any
+// other.
+type memStore struct {
+	mutex      sync.Mutex
+	statements []string
+	cache      map[string]map[string]interface{}
+	queue      []map[string]interface{}
+	dead       []map[string]interface{}
+	nextID     int64
+}
+
+func newMemStore() *memStore {
+	return &memStore{cache: make(map[string]map[string]interface{}), nextID: 1}
+}
+
+func (s *memStore) Query(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	var rows []map[string]interface{}
+	err := s.QueryEach(ctx, query, func(row map[string]interface{}) error {
+		rows = append(rows, row)
+		return nil
+	}, args...)
+	return rows, err
+}
+
+func (s *memStore) QueryEach(ctx context.Context, query string, fn func(row map[string]interface{}) error, args ...interface{}) error {
+	s.mutex.Lock()
+	query = strings.Join(strings.Fields(query), " ")
+	s.statements = append(s.statements, query)
+	var rows []map[string]interface{}
+	switch {
+	case strings.HasPrefix(query, "SELECT key, path, result, expires_at, tags FROM edge_cache"):
+		for _, row := range s.cache {
+			rows = append(rows, copyRow(row))
+		}
+	case strings.HasSuffix(query, "FROM edge_write_queue ORDER BY id"):
+		rows = copyRows(s.queue)
+	case strings.HasSuffix(query, "FROM edge_dead_writes ORDER BY id"):
+		rows = copyRows(s.dead)
+	default:
+		s.mutex.Unlock()
+		return fmt.Errorf("memStore: unexpected query %q", query)
+	}
+	s.mutex.Unlock()
+
+	for _, row := range rows {
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *memStore) Execute(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	query = strings.Join(strings.Fields(query), " ")
+	s.statements = append(s.statements, query)
+	switch {
+	case strings.HasPrefix(query, "CREATE TABLE"):
+	case strings.HasPrefix(query, "INSERT INTO edge_write_queue"):
+		s.queue = append(s.queue, map[string]interface{}{
+			"id": s.nextID, "path": args[0], "params": args[1], "request": args[2],
+			"attempts": int64(args[3].(int)), "last_error": args[4], "created_at": args[5],
+		})
+		s.nextID++
+	case strings.HasPrefix(query, "UPDATE edge_write_queue SET attempts = attempts + 1"):
+		if row := find(s.queue, args[1]); row != nil {
+			row["attempts"] = row["attempts"].(int64) + 1
+			row["last_error"] = args[0]
+		}
+	case strings.HasPrefix(query, "INSERT INTO edge_dead_writes"):
+		if row := find(s.queue, args[3]); row != nil {
+			row = copyRow(row)
+			row["attempts"] = row["attempts"].(int64) + int64(args[0].(int))
+			row["last_error"] = args[1]
+			row["dead_at"] = args[2]
+			s.dead = append(s.dead, row)
+		}
+	case query == "DELETE FROM edge_write_queue WHERE id = $1":
+		for i, row := range s.queue {
+			if row["id"] == args[0] {
+				s.queue = append(s.queue[:i], s.queue[i+1:]...)
+				return 1, nil
+			}
+		}
+	case strings.HasPrefix(query, "INSERT INTO edge_cache"):
+		s.cache[args[0].(string)] = map[string]interface{}{"key": args[0], "path": args[1], "result": args[2], "expires_at": args[3], "tags": args[4]}
+	case query == "DELETE FROM edge_cache WHERE expires_at <= $1":
+		for key, row := range s.cache {
+			if row["expires_at"].(int64) <= args[0].(int64) {
+				delete(s.cache, key)
+			}
+		}
+	case query == "DELETE FROM edge_cache":
+		s.cache = make(map[string]map[string]interface{})
+	case query == "DELETE FROM edge_cache WHERE path = $1":
+		for key, row := range s.cache {
+			if row["path"] == args[0] {
+				delete(s.cache, key)
+			}
+		}
+	case query == "DELETE FROM edge_cache WHERE key = $1":
+		delete(s.cache, args[0].(string))
+	default:
+		return 0, fmt.Errorf("memStore: unexpected statement %q", query)
+	}
+	return 1, nil
+}
+
+func (s *memStore) Close() error {
+	return nil
+}
+
+// cachedKeys returns the keys of the persisted cache entries, sorted
+func (s *memStore) cachedKeys() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	keys := make([]string, 0, len(s.cache))
+	for key := range s.cache {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func find(rows []map[string]interface{}, id interface{}) map[string]interface{} {
+	for _, row := range rows {
+		if row["id"] == id {
+			return row
+		}
+	}
+	return nil
+}
+
+func copyRow(row map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(row))
+	for column, value := range row {
+		copied[column] = value
+	}
+	return copied
+}
+
+func copyRows(rows []map[string]interface{}) []map[string]interface{} {
+	copied := make([]map[string]interface{}, len(rows))
+	for i, row := range rows {
+		copied[i] = copyRow(row)
+	}
+	return copied
+}
+
+// storeNode returns a node caching results in store
+func storeNode(t *testing.T, store db.Store) *EdgeNode {
+	n := &EdgeNode{
+		ID:           "edge-1",
+		CacheEnabled: true,
+		CacheTTL:     time.Minute,
+		Cache:        make(map[string]*CacheEntry),
+		Metrics:      &EdgeMetrics{},
+	}
+	if err := n.SetLocalStore(context.Background(), store); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// flakyOrigin records the writes it is sent, failing them as unreachable
+// while down and rejecting those to "rejected"
+type flakyOrigin struct {
+	mutex sync.Mutex
+	down  bool
+	sent  []string
+}
+
+func (o *flakyOrigin) write(ctx context.Context, path string, params map[string]interface{}) (interface{}, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.down {
+		return nil, &net.DNSError{Err: "no such host", Name: "origin", IsTemporary: true}
+	}
+	if path == "rejected" {
+		return nil, errors.New("title is required")
+	}
+	o.sent = append(o.sent, fmt.Sprintf("%s:%v:%s", path, params["title"], reqctx.Principal(ctx)))
+	return params, nil
+}
+
+func TestLocalStoreKey(t *testing.T) {
+	store := newMemStore()
+	storeNode(t, store)
+	if !strings.Contains(strings.Join(store.statements, "\n"), "CREATE TABLE IF NOT EXISTS edge_write_queue ( id BIGSERIAL PRIMARY KEY,") {
+		t.Fatalf("expected a serial key, got %v", store.statements)
+	}
+	if key := localStoreKey(&db.SQLiteStore{}); key != "INTEGER PRIMARY KEY AUTOINCREMENT" {
+		t.Fatalf("expected an autoincrement key on SQLite, got %q", key)
+	}
+}
+
+func TestWriteQueue(t *testing.T) {
+	ctx := reqctx.WithPrincipal(context.Background(), "ada")
+	store := newMemStore()
+	n := storeNode(t, store)
+	origin := &flakyOrigin{down: true}
+	n.Origin = origin.write
+
+	for _, path := range []string{"createPost", "rejected", "createPost"} {
+		if _, err := n.Write(ctx, path, map[string]interface{}{"title": path}); err != ErrWriteQueued {
+			t.Fatalf("expected the write queued, got %v", err)
+		}
+	}
+	queued, err := n.QueuedWrites(ctx)
+	if err != nil || len(queued) != 3 {
+		t.Fatalf("expected three queued writes, got %v (%v)", queued, err)
+	}
+	if queued[0].Attempts != 3 || queued[1].Attempts != 0 || queued[0].Request.Principal != "ada" || queued[0].LastError == "" {
+		t.Fatalf("unexpected first writes %+v %+v", queued[0], queued[1])
+	}
+
+	origin.down = false
+	err = n.SyncWithParent()
+	if err == nil || !strings.Contains(err.Error(), "edge: write 2 to rejected rejected: title is required") {
+		t.Fatalf("expected the rejected write reported, got %v", err)
+	}
+	if sent := strings.Join(origin.sent, " "); sent != "createPost:createPost:ada createPost:createPost:ada" {
+		t.Fatalf("expected the writes sent in order for their principal, got %s", sent)
+	}
+	if queued, _ := n.QueuedWrites(ctx); len(queued) != 0 {
+		t.Fatalf("expected the queue emptied, got %v", queued)
+	}
+	dead, err := n.DeadWrites(ctx)
+	if err != nil || len(dead) != 1 || dead[0].Path != "rejected" || dead[0].LastError != "title is required" || dead[0].Attempts != 1 {
+		t.Fatalf("expected the rejected write kept as dead, got %+v (%v)", dead, err)
+	}
+
+	if _, err := n.Write(ctx, "createPost", map[string]interface{}{"title": "online"}); err != nil {
+		t.Fatalf("expected the write sent, got %v", err)
+	}
+}
+
+func TestUndecodableWriteDoesNotBlockQueue(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	n := storeNode(t, store)
+	origin := &flakyOrigin{}
+	n.Origin = origin.write
+
+	store.queue = append(store.queue,
+		map[string]interface{}{"id": int64(1), "path": "createPost", "params": "{not json", "request": "{}", "attempts": int64(0), "last_error": "", "created_at": int64(0)},
+		map[string]interface{}{"id": int64(2), "path": "createPost", "params": `{"title":"Hello"}`, "request": "{}", "attempts": int64(0), "last_error": "", "created_at": int64(0)},
+	)
+	store.nextID = 3
+	if queued, err := n.QueuedWrites(ctx); err != nil || len(queued) != 1 || queued[0].ID != 2 {
+		t.Fatalf("expected the undecodable write left out, got %v (%v)", queued, err)
+	}
+
+	if err := n.SyncWithParent(); err == nil || !strings.Contains(err.Error(), "write 1 to createPost cannot be decoded") {
+		t.Fatalf("expected the undecodable write reported, got %v", err)
+	}
+	if len(origin.sent) != 1 || len(store.queue) != 0 {
+		t.Fatalf("expected the write behind it sent, got %v with %v queued", origin.sent, store.queue)
+	}
+	if dead, _ := n.DeadWrites(ctx); len(dead) != 1 || dead[0].ID != 1 || !strings.HasPrefix(dead[0].LastError, "cannot be decoded") {
+		t.Fatalf("expected the undecodable write kept as dead, got %+v", dead)
+	}
+}
+
+// TestLocalStoreOnSQLite runs the node's statements on SQLite, which
+// memStore only matches by their text. The module ships no SQLite driver;
+// the test is skipped unless one is registered, as sqlite_driver_test.go
+// does when the tests are built with -tags sqlite.
+func TestLocalStoreOnSQLite(t *testing.T) {
+	store, err := db.OpenSQLite(filepath.Join(t.TempDir(), "edge.db"))
+	if err != nil {
+		t.Skipf("no SQLite driver: %v", err)
+	}
+	defer store.Close()
+	ctx := reqctx.WithPrincipal(context.Background(), "ada")
+
+	n := storeNode(t, store)
+	origin := &flakyOrigin{down: true}
+	n.Origin = origin.write
+	n.cache(&EdgeRequest{Path: "getPost", Params: map[string]interface{}{"id": 1}, Context: ctx}, map[string]interface{}{"title": "Hello"}, []string{"post:1"})
+	n.cache(&EdgeRequest{Path: "getPost", Params: map[string]interface{}{"id": 1}, Context: ctx}, map[string]interface{}{"title": "Hello again"}, []string{"post:1"})
+	for _, path := range []string{"createPost", "rejected", "createPost"} {
+		if _, err := n.Write(ctx, path, map[string]interface{}{"title": path}); err != ErrWriteQueued {
+			t.Fatalf("expected the write queued, got %v", err)
+		}
+	}
+
+	// A restarted node finds the tables, its cache and its queue
+	restarted := storeNode(t, store)
+	restarted.Origin = origin.write
+	if entry := restarted.Cache["getPost:map[id:1]"]; entry == nil || entry.Result.(map[string]interface{})["title"] != "Hello again" || len(entry.Tags) != 1 {
+		t.Fatalf("expected the updated entry loaded, got %+v", entry)
+	}
+	queued, err := restarted.QueuedWrites(ctx)
+	if err != nil || len(queued) != 3 || queued[0].ID >= queued[1].ID || queued[0].Attempts != 3 || queued[0].Request.Principal != "ada" {
+		t.Fatalf("expected the writes queued in order, got %+v (%v)", queued, err)
+	}
+
+	origin.down = false
+	if err := restarted.SyncWithParent(); err == nil || !strings.Contains(err.Error(), "rejected: title is required") {
+		t.Fatalf("expected the rejected write reported, got %v", err)
+	}
+	if queued, err := restarted.QueuedWrites(ctx); err != nil || len(queued) != 0 {
+		t.Fatalf("expected the queue emptied, got %+v (%v)", queued, err)
+	}
+	dead, err := restarted.DeadWrites(ctx)
+	if err != nil || len(dead) != 1 || dead[0].Path != "rejected" || dead[0].Attempts != 1 || dead[0].Request.Principal != "ada" {
+		t.Fatalf("expected the rejected write kept as dead, got %+v (%v)", dead, err)
+	}
+
+	restarted.InvalidatePath("getPost")
+	if rows, err := store.Query(ctx, "SELECT key FROM edge_cache"); err != nil || len(rows) != 0 {
+		t.Fatalf("expected the entry deleted, got %v (%v)", rows, err)
+	}
+}
+
+func TestCachePersistence(t *testing.T) {
+	ctx := context.Background()
+	store := newMemStore()
+	n := storeNode(t, store)
+
+	for _, path := range []string{"getPost", "listPosts"} {
+		n.cache(&EdgeRequest{Path: path, Params: map[string]interface{}{"id": 1}, Context: ctx}, map[string]interface{}{"path": path}, []string{"post:1"})
+	}
+	store.cache["expired"] = map[string]interface{}{"key": "expired", "path": "getPost", "result": "{}", "expires_at": time.Now().UnixNano(), "tags": "[]"}
+
+	// A restarted node loads the entries that have not expired
+	restarted := storeNode(t, store)
+	result, ok := restarted.cached(&EdgeRequest{Path: "getPost", Params: map[string]interface{}{"id": 1}, Context: ctx})
+	if !ok || result.(map[string]interface{})["path"] != "getPost" {
+		t.Fatalf("expected the cached result loaded, got %v", result)
+	}
+	if entry := restarted.Cache["listPosts:map[id:1]"]; entry == nil || len(entry.Tags) != 1 || entry.Tags[0] != "post:1" {
+		t.Fatalf("expected the entry's tags loaded, got %+v", entry)
+	}
+	if keys := store.cachedKeys(); len(keys) != 2 || len(restarted.Cache) != 2 {
+		t.Fatalf("expected the expired entry deleted, got %v", keys)
+	}
+
+	restarted.InvalidatePath("getPost")
+	if keys := store.cachedKeys(); len(keys) != 1 || keys[0] != "listPosts:map[id:1]" {
+		t.Fatalf("expected the path's entries deleted, got %v", keys)
+	}
+	restarted.ClearCache()
+	if keys := store.cachedKeys(); len(keys) != 0 {
+		t.Fatalf("expected every entry deleted, got %v", keys)
+	}
+}
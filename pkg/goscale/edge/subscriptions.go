@@ -0,0 +1,220 @@
+package edge
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscale/client"
+	"github.com/davidjeba/goscript/pkg/goscript"
+)
+
+// upstream is the node's one subscription to a topic on the origin, whose
+// values are sent to each of the node's clients of the topic
+type upstream struct {
+	topic   string
+	clients map[*goscript.WebSocket]bool
+	cancel  context.CancelFunc
+	linger  *time.Timer
+
+	// ready is closed once the subscription is open, or failed with err
+	ready chan struct{}
+	err   error
+}
+
+// ServeSubscription streams a topic, named by the "topic" route parameter,
+// to a WebSocket client of the node. The node's clients of a topic share one
+// subscription to it on the origin, opened with OriginSubscribe for the first
+// and kept for SubscriptionLinger after the last leaves, so a popular topic
+// costs the origin one connection per node rather than one per client.
+// Values are sent as the origin masks them for the node's own connection,
+// so set AuthorizeSubscription to check each client may see the topic.
+// Mount it at the origin's path, so clients fail over between the two:
+//
+//	router.WS("/subscriptions/:topic", node.ServeSubscription)
+//
+// Clients AuthorizeSubscription rejects are closed with 1008 before joining.
+// When the origin ends the subscription the node's clients are closed with
+// its code, and when it is unreachable with 1001, so they reconnect.
+func (n *EdgeNode) ServeSubscription(ws *goscript.WebSocket, params map[string]string) {
+	topic := params["topic"]
+	if n.OriginSubscribe == nil {
+		ws.CloseWithReason(goscript.CloseInternalError, "no origin to subscribe to")
+		return
+	}
+	if n.AuthorizeSubscription != nil && !n.AuthorizeSubscription(ws.Request(), topic) {
+		ws.CloseWithReason(goscript.ClosePolicyViolation, "not authorized")
+		return
+	}
+
+	u, err := n.join(topic, ws)
+	if err != nil {
+		ws.CloseWithReason(goscript.CloseGoingAway, "origin unreachable")
+		return
+	}
+	defer n.leave(u, ws)
+
+	// Reading handles pings and notices when the client leaves
+	for {
+		if _, _, err := ws.ReadMessage(); err != nil {
+			ws.Close()
+			return
+		}
+	}
+}
+
+// join adds a client to a topic, opening the topic's subscription on the
+// origin for its first
+func (n *EdgeNode) join(topic string, ws *goscript.WebSocket) (*upstream, error) {
+	n.upstreamMutex.Lock()
+	u := n.upstreams[topic]
+	opening := u == nil
+	if opening {
+		u = &upstream{topic: topic, clients: make(map[*goscript.WebSocket]bool), ready: make(chan struct{})}
+		n.upstreams[topic] = u
+	}
+	if u.linger != nil {
+		u.linger.Stop()
+		u.linger = nil
+	}
+	u.clients[ws] = true
+	n.upstreamMutex.Unlock()
+	n.countSubscribers(1, 0)
+
+	if opening {
+		n.open(u)
+	}
+	<-u.ready
+	if u.err != nil {
+		n.upstreamMutex.Lock()
+		delete(u.clients, ws)
+		n.upstreamMutex.Unlock()
+		n.countSubscribers(-1, 0)
+		return nil, u.err
+	}
+	return u, nil
+}
+
+// open subscribes to a topic on the origin and fans its values out until the
+// subscription ends
+func (n *EdgeNode) open(u *upstream) {
+	ctx, cancel := context.WithCancel(context.Background())
+	subscription, err := n.OriginSubscribe(ctx, u.topic)
+	if err != nil {
+		cancel()
+		n.upstreamMutex.Lock()
+		if n.upstreams[u.topic] == u {
+			delete(n.upstreams, u.topic)
+		}
+		u.err = err
+		n.upstreamMutex.Unlock()
+		close(u.ready)
+		return
+	}
+	u.cancel = cancel
+	close(u.ready)
+	n.countSubscribers(0, 1)
+
+	go func() {
+		for data := range subscription.Updates() {
+			for _, ws := range n.clients(u) {
+				ws.Send(data)
+			}
+		}
+		n.end(u, subscription.Err())
+	}()
+}
+
+// clients returns a topic's clients
+func (n *EdgeNode) clients(u *upstream) []*goscript.WebSocket {
+	n.upstreamMutex.Lock()
+	defer n.upstreamMutex.Unlock()
+
+	clients := make([]*goscript.WebSocket, 0, len(u.clients))
+	for ws := range u.clients {
+		clients = append(clients, ws)
+	}
+	return clients
+}
+
+// leave removes a client from its topic, closing the topic's subscription on
+// the origin once SubscriptionLinger passes without another
+func (n *EdgeNode) leave(u *upstream, ws *goscript.WebSocket) {
+	n.upstreamMutex.Lock()
+	defer n.upstreamMutex.Unlock()
+
+	if !u.clients[ws] {
+		return
+	}
+	delete(u.clients, ws)
+	n.countSubscribers(-1, 0)
+	if len(u.clients) > 0 || n.upstreams[u.topic] != u {
+		return
+	}
+	if n.SubscriptionLinger <= 0 {
+		delete(n.upstreams, u.topic)
+		u.cancel()
+		return
+	}
+	u.linger = time.AfterFunc(n.SubscriptionLinger, func() {
+		n.upstreamMutex.Lock()
+		defer n.upstreamMutex.Unlock()
+
+		if len(u.clients) == 0 && n.upstreams[u.topic] == u {
+			delete(n.upstreams, u.topic)
+			u.cancel()
+		}
+	})
+}
+
+// end closes a topic's clients once its subscription on the origin ends:
+// with the origin's code if it ended it, or else as going away
+func (n *EdgeNode) end(u *upstream, err error) {
+	n.upstreamMutex.Lock()
+	if n.upstreams[u.topic] == u {
+		delete(n.upstreams, u.topic)
+	}
+	if u.linger != nil {
+		u.linger.Stop()
+	}
+	n.upstreamMutex.Unlock()
+	u.cancel()
+	n.countSubscribers(0, -1)
+
+	code, reason := goscript.CloseGoingAway, "origin subscription ended"
+	var closeErr *client.CloseError
+	if errors.As(err, &closeErr) {
+		code, reason = closeErr.Code, closeErr.Reason
+	}
+	for _, ws := range n.clients(u) {
+		ws.CloseWithReason(code, reason)
+	}
+}
+
+// closeSubscriptions ends the node's subscriptions on the origin, closing
+// their clients
+func (n *EdgeNode) closeSubscriptions() {
+	n.upstreamMutex.Lock()
+	upstreams := make([]*upstream, 0, len(n.upstreams))
+	for _, u := range n.upstreams {
+		upstreams = append(upstreams, u)
+	}
+	n.upstreamMutex.Unlock()
+
+	for _, u := range upstreams {
+		<-u.ready
+		if u.cancel != nil {
+			u.cancel()
+		}
+	}
+}
+
+// countSubscribers updates the node's counts of subscribed clients and of
+// its subscriptions on the origin
+func (n *EdgeNode) countSubscribers(clients, upstreams int64) {
+	n.Metrics.mutex.Lock()
+	defer n.Metrics.mutex.Unlock()
+
+	n.Metrics.Subscribers += clients
+	n.Metrics.OriginSubscriptions += upstreams
+}
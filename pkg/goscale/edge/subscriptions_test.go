@@ -0,0 +1,236 @@
+package edge
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscale/client"
+	"github.com/davidjeba/goscript/pkg/goscript"
+)
+
+// origin serves topics as GoScaleAPI does, counting the subscriptions it
+// opened and keeping the open ones so a test can end them.
+type origin struct {
+	hub    *goscript.WebSocketHub
+	server *httptest.Server
+
+	mutex   sync.Mutex
+	opened  int
+	sockets map[string][]*goscript.WebSocket
+}
+
+func newOrigin() *origin {
+	o := &origin{hub: goscript.NewWebSocketHub(), sockets: make(map[string][]*goscript.WebSocket)}
+	router := goscript.NewRouter()
+	router.WS("/subscriptions/:topic", func(ws *goscript.WebSocket, params map[string]string) {
+		o.mutex.Lock()
+		o.opened++
+		o.sockets[params["topic"]] = append(o.sockets[params["topic"]], ws)
+		o.mutex.Unlock()
+		o.hub.Join(ws, params["topic"])
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	o.server = httptest.NewServer(router)
+	return o
+}
+
+func (o *origin) close() {
+	o.hub.Close()
+	o.server.Close()
+}
+
+// subscriptions returns how many subscriptions the origin opened
+func (o *origin) subscriptions() int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.opened
+}
+
+// end ends a topic's subscriptions as the origin does for a deleted topic
+func (o *origin) end(topic string) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	for _, ws := range o.sockets[topic] {
+		ws.CloseWithReason(goscript.ClosePolicyViolation, "topic ended")
+	}
+}
+
+// subscriptionNode returns a node fanning the origin's topics out, served
+// at the returned URL
+func subscriptionNode(t *testing.T, o *origin, linger time.Duration) (*EdgeNode, string) {
+	n := &EdgeNode{
+		Metrics:            &EdgeMetrics{},
+		OriginSubscribe:    client.New(o.server.URL).Subscribe,
+		SubscriptionLinger: linger,
+		upstreams:          make(map[string]*upstream),
+	}
+	router := goscript.NewRouter()
+	router.WS("/subscriptions/:topic", n.ServeSubscription)
+	server := httptest.NewServer(router)
+	t.Cleanup(func() {
+		n.closeSubscriptions()
+		server.Close()
+	})
+	return n, server.URL
+}
+
+// eventually fails the test unless condition holds within a second
+func eventually(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); !condition(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+// subscribers returns the node's subscribed clients and origin
+// subscriptions
+func subscribers(n *EdgeNode) (int64, int64) {
+	metrics := n.GetMetrics()
+	return metrics.Subscribers, metrics.OriginSubscriptions
+}
+
+func TestSubscriptionsShareOrigin(t *testing.T) {
+	o := newOrigin()
+	defer o.close()
+	n, url := subscriptionNode(t, o, 0)
+
+	first, err := client.New(url).Subscribe(context.Background(), "posts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := client.New(url).Subscribe(context.Background(), "posts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "both clients to join", func() bool {
+		clients, _ := subscribers(n)
+		return clients == 2 && o.hub.Count("posts") == 1
+	})
+	if opened := o.subscriptions(); opened != 1 {
+		t.Fatalf("expected one subscription on the origin, got %d", opened)
+	}
+
+	o.hub.BroadcastJSON("posts", map[string]string{"title": "Hello"})
+	for _, subscription := range []*client.Subscription{first, second} {
+		var post map[string]string
+		if err := subscription.Next(&post); err != nil || post["title"] != "Hello" {
+			t.Fatalf("unexpected update %v %v", post, err)
+		}
+	}
+
+	first.Close()
+	eventually(t, "the first client to leave", func() bool {
+		clients, upstreams := subscribers(n)
+		return clients == 1 && upstreams == 1
+	})
+	second.Close()
+	eventually(t, "the origin subscription to close", func() bool {
+		clients, upstreams := subscribers(n)
+		return clients == 0 && upstreams == 0 && o.hub.Count("posts") == 0
+	})
+}
+
+func TestSubscriptionLinger(t *testing.T) {
+	o := newOrigin()
+	defer o.close()
+	n, url := subscriptionNode(t, o, 200*time.Millisecond)
+
+	subscription, err := client.New(url).Subscribe(context.Background(), "posts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the client to join", func() bool { return o.hub.Count("posts") == 1 })
+	subscription.Close()
+	eventually(t, "the client to leave", func() bool {
+		clients, _ := subscribers(n)
+		return clients == 0
+	})
+
+	// A client reconnecting within the linger shares the open subscription
+	subscription, err = client.New(url).Subscribe(context.Background(), "posts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the client to rejoin", func() bool {
+		clients, _ := subscribers(n)
+		return clients == 1
+	})
+	if opened := o.subscriptions(); opened != 1 {
+		t.Fatalf("expected the lingering subscription reused, got %d subscriptions", opened)
+	}
+
+	subscription.Close()
+	time.Sleep(50 * time.Millisecond)
+	if _, upstreams := subscribers(n); upstreams != 1 || o.hub.Count("posts") != 1 {
+		t.Fatalf("expected the subscription to linger, got %d", upstreams)
+	}
+	eventually(t, "the linger to pass", func() bool {
+		_, upstreams := subscribers(n)
+		return upstreams == 0 && o.hub.Count("posts") == 0
+	})
+}
+
+func TestSubscriptionEnd(t *testing.T) {
+	o := newOrigin()
+	defer o.close()
+	n, url := subscriptionNode(t, o, time.Minute)
+
+	subscription, err := client.New(url).Subscribe(context.Background(), "posts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the client to join", func() bool { return o.hub.Count("posts") == 1 })
+	o.end("posts")
+
+	var post map[string]string
+	err = subscription.Next(&post)
+	if closeErr, ok := err.(*client.CloseError); !ok || closeErr.Code != goscript.ClosePolicyViolation || closeErr.Reason != "topic ended" {
+		t.Fatalf("expected the origin's close passed on, got %v", err)
+	}
+	eventually(t, "the topic to end", func() bool {
+		clients, upstreams := subscribers(n)
+		n.upstreamMutex.Lock()
+		defer n.upstreamMutex.Unlock()
+		return clients == 0 && upstreams == 0 && len(n.upstreams) == 0
+	})
+}
+
+func TestSubscriptionAuthorize(t *testing.T) {
+	o := newOrigin()
+	defer o.close()
+	n, url := subscriptionNode(t, o, 0)
+	n.AuthorizeSubscription = func(r *http.Request, topic string) bool {
+		return topic != "payroll" || r.Header.Get("X-Role") == "admin"
+	}
+
+	subscription, err := client.New(url).Subscribe(context.Background(), "payroll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payroll map[string]string
+	err = subscription.Next(&payroll)
+	if closeErr, ok := err.(*client.CloseError); !ok || closeErr.Code != goscript.ClosePolicyViolation || closeErr.Reason != "not authorized" {
+		t.Fatalf("expected the client refused, got %v", err)
+	}
+	if opened := o.subscriptions(); opened != 0 {
+		t.Fatalf("expected no subscription on the origin, got %d", opened)
+	}
+
+	admin := client.NewWithConfig(client.Config{Endpoints: []string{url}, Header: http.Header{"X-Role": {"admin"}}})
+	subscription, err = admin.Subscribe(context.Background(), "payroll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer subscription.Close()
+	eventually(t, "the admin to join", func() bool { return o.hub.Count("payroll") == 1 })
+}
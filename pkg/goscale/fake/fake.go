@@ -0,0 +1,617 @@
+// Package fake generates realistic rows for the tables of a GraphQL schema,
+// for seeding development databases and mocking APIs. Values are chosen by
+// each field's name and type: a field named email gets an email address,
+// createdAt a time in the past year and updatedAt a later one, and a field
+// typed with an enum one of its values. A field holding another table's key,
+// such as authorId, gets the key of a row generated for that table, so
+// foreign keys hold. Generators are seeded, so the same seed generates the
+// same rows.
+package fake
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscale/sdl"
+	"github.com/davidjeba/goscript/pkg/goscript/naming"
+)
+
+// Field is a field to generate values for
+type Field struct {
+	// Name of the field, such as email or createdAt
+	Name string
+
+	// Type in schema notation, such as "String!" or "[String!]"; enums and
+	// custom scalars are typed String
+	Type string
+
+	// Values are the values of an enum field
+	Values []string
+
+	// References names the table whose keys the field holds, if any
+	References string
+}
+
+// Table is a table to generate rows for
+type Table struct {
+	// Name of the table, such as blog_post, and Type the schema type it
+	// stores, such as BlogPost
+	Name string
+	Type string
+
+	// Key is the name of the key field, or "" if the table has none
+	Key string
+
+	Fields []Field
+}
+
+// Field returns the field with a name, or nil
+func (t *Table) Field(name string) *Field {
+	for i := range t.Fields {
+		if t.Fields[i].Name == name {
+			return &t.Fields[i]
+		}
+	}
+	return nil
+}
+
+// scalarTypes are the built-in scalar types values are generated for
+var scalarTypes = map[string]bool{
+	"ID": true, "String": true, "Int": true, "BigInt": true, "Float": true,
+	"Boolean": true, "Time": true, "DateTime": true, "JSON": true,
+}
+
+// Tables returns the tables storing the object types of a schema, named and
+// keyed as api.Schema.Tables names and keys them: the snake case of the type
+// or its @table name, keyed by its "id" field or else its first of type ID.
+// Fields of object types are left out, as they are not stored in columns.
+//
+// A field named after another type's table and ending in Id, such as
+// authorId next to a field author of type User, or userId, references that
+// table; @references(type: "User") names the type explicitly. Tables are
+// ordered so that those referenced come first, where no cycle prevents it.
+func Tables(doc *sdl.Document) []*Table {
+	var tables []*Table
+	byType := make(map[string]*Table)
+	byName := make(map[string]*Table)
+	definitions := make(map[*Table]*sdl.Definition)
+
+	for _, definition := range doc.Definitions {
+		if definition.Kind != sdl.KindType || doc.IsOperationType(definition.Name) {
+			continue
+		}
+		t := &Table{Name: naming.SnakeCase(definition.Name), Type: definition.Name}
+		if table := definition.Directive("table"); table != nil && table.Args["name"] != "" {
+			t.Name = table.Args["name"]
+		}
+		for _, field := range definition.Fields {
+			f := Field{Name: field.Name, Type: field.Type}
+			named := sdl.NamedType(field.Type)
+			if d := doc.Definition(named); d != nil {
+				switch d.Kind {
+				case sdl.KindEnum:
+					f.Values = d.Values
+				case sdl.KindScalar:
+				default:
+					continue
+				}
+				f.Type = strings.Replace(field.Type, named, "String", 1)
+			} else if !scalarTypes[named] {
+				continue
+			}
+			if naming.SnakeCase(field.Name) == "id" || (strings.TrimSuffix(field.Type, "!") == "ID" && t.Key == "") {
+				t.Key = field.Name
+			}
+			t.Fields = append(t.Fields, f)
+		}
+		if len(t.Fields) == 0 {
+			continue
+		}
+		tables = append(tables, t)
+		byType[t.Type] = t
+		byName[naming.SnakeCase(t.Type)] = t
+		definitions[t] = definition
+	}
+
+	for _, t := range tables {
+		for i := range t.Fields {
+			f := &t.Fields[i]
+			if f.Name == t.Key {
+				continue
+			}
+			if target := references(definitions[t], f.Name, byType, byName); target != nil && target.Key != "" {
+				f.References = target.Name
+			}
+		}
+	}
+	return ordered(tables)
+}
+
+// references returns the table a field of a definition holds keys of
+func references(definition *sdl.Definition, name string, byType, byName map[string]*Table) *Table {
+	if field := definition.Field(name); field != nil {
+		if directive := field.Directive("references"); directive != nil {
+			return byType[directive.Args["type"]]
+		}
+	}
+
+	column := naming.SnakeCase(name)
+	if !strings.HasSuffix(column, "_id") {
+		return nil
+	}
+	prefix := strings.TrimSuffix(column, "_id")
+	for _, field := range definition.Fields {
+		if naming.SnakeCase(field.Name) == prefix {
+			if target := byType[sdl.NamedType(field.Type)]; target != nil {
+				return target
+			}
+		}
+	}
+	return byName[prefix]
+}
+
+// ordered sorts tables after the tables they reference, keeping the schema
+// order otherwise
+func ordered(tables []*Table) []*Table {
+	byName := make(map[string]*Table, len(tables))
+	for _, t := range tables {
+		byName[t.Name] = t
+	}
+
+	var sorted []*Table
+	visited := make(map[*Table]bool)
+	var visit func(t *Table)
+	visit = func(t *Table) {
+		if visited[t] {
+			return
+		}
+		visited[t] = true
+		for _, f := range t.Fields {
+			if target := byName[f.References]; target != nil {
+				visit(target)
+			}
+		}
+		sorted = append(sorted, t)
+	}
+	for _, t := range tables {
+		visit(t)
+	}
+	return sorted
+}
+
+// Generator generates rows. A generator remembers the keys of the rows it
+// generated, for the fields referencing them; it is not safe for concurrent
+// use.
+type Generator struct {
+	// Now is the time generated times are around
+	Now time.Time
+
+	// NullRate is the share of nullable fields left null, 0.1 by default
+	NullRate float64
+
+	rand *rand.Rand
+	keys map[string][]interface{}
+}
+
+// New creates a generator with a seed
+func New(seed int64) *Generator {
+	return &Generator{
+		Now:      time.Now().UTC().Truncate(time.Second),
+		NullRate: 0.1,
+		rand:     rand.New(rand.NewSource(seed)),
+		keys:     make(map[string][]interface{}),
+	}
+}
+
+// Generate generates count rows for each table, in order, calling emit with
+// each
+func (g *Generator) Generate(tables []*Table, count int, emit func(table *Table, row map[string]interface{}) error) error {
+	for _, table := range tables {
+		for i := 0; i < count; i++ {
+			row, err := g.Row(table, i)
+			if err != nil {
+				return err
+			}
+			if err := emit(table, row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Row generates the row numbered index of a table, keyed by field name.
+// Values that must be unique, such as keys and email addresses, include the
+// index.
+func (g *Generator) Row(table *Table, index int) (map[string]interface{}, error) {
+	r := &row{table: table, index: index, values: make(map[string]interface{}, len(table.Fields))}
+
+	// The key comes first, so a row can reference itself
+	if key := table.Field(table.Key); key != nil {
+		r.values[key.Name] = g.key(r, *key)
+	}
+	for _, field := range table.Fields {
+		if field.Name == table.Key {
+			continue
+		}
+		value, err := g.value(r, field)
+		if err != nil {
+			return nil, err
+		}
+		r.values[field.Name] = value
+	}
+
+	if table.Key != "" {
+		g.keys[table.Name] = append(g.keys[table.Name], r.values[table.Key])
+	}
+	return r.values, nil
+}
+
+// row is a row being generated. The person and times of a row are chosen
+// once, so its name, email address and username match, and it is updated
+// after it is created.
+type row struct {
+	table  *Table
+	index  int
+	values map[string]interface{}
+
+	first, last string
+	created     time.Time
+}
+
+// key generates a key: the row number for integers, else a UUID
+func (g *Generator) key(r *row, field Field) interface{} {
+	switch sdl.NamedType(field.Type) {
+	case "Int":
+		return r.index + 1
+	case "BigInt":
+		return int64(r.index + 1)
+	}
+	return g.uuid()
+}
+
+// value generates a value for a field
+func (g *Generator) value(r *row, field Field) (interface{}, error) {
+	nullable := !sdl.IsNonNull(field.Type)
+	words := splitWords(field.Name)
+
+	if field.References != "" {
+		keys := g.keys[field.References]
+		switch {
+		case nullable && g.rand.Float64() < g.NullRate:
+			return nil, nil
+		case len(keys) > 0:
+			return keys[g.rand.Intn(len(keys))], nil
+		case field.References == r.table.Name && r.table.Key != "":
+			return r.values[r.table.Key], nil
+		case nullable:
+			return nil, nil
+		}
+		return nil, fmt.Errorf("fake: %s.%s references %s, which has no rows", r.table.Name, field.Name, field.References)
+	}
+
+	if nullable {
+		rate := g.NullRate
+		if has(words, "deleted", "archived", "canceled", "cancelled") {
+			rate = 0.9
+		}
+		if g.rand.Float64() < rate {
+			return nil, nil
+		}
+	}
+
+	if sdl.IsList(field.Type) {
+		element := strings.TrimSuffix(strings.TrimSuffix(field.Type, "!")[1:], "]")
+		item := Field{Name: singular(field.Name), Type: strings.TrimSuffix(element, "!") + "!", Values: field.Values}
+		list := make([]interface{}, g.rand.Intn(4))
+		for i := range list {
+			value, err := g.value(r, item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = value
+		}
+		return list, nil
+	}
+
+	if len(field.Values) > 0 {
+		return field.Values[g.rand.Intn(len(field.Values))], nil
+	}
+
+	switch named := sdl.NamedType(field.Type); named {
+	case "Boolean":
+		if has(words, "active", "enabled", "verified", "published", "visible", "available", "confirmed") {
+			return g.rand.Float64() < 0.8, nil
+		}
+		return g.rand.Intn(2) == 0, nil
+	case "Time", "DateTime":
+		return g.time(r, words).Format(time.RFC3339), nil
+	case "JSON":
+		return map[string]interface{}{"source": "fake", "score": g.rand.Intn(100), "tags": []interface{}{g.pick(loremWords), g.pick(loremWords)}}, nil
+	case "Int", "BigInt", "Float":
+		return g.number(r, words, named), nil
+	case "ID":
+		return g.uuid(), nil
+	}
+	return g.text(r, words), nil
+}
+
+// isTime reports whether a field's words name a time, as createdAt or
+// birthDate do
+func isTime(words []string) bool {
+	last := words[len(words)-1]
+	return last == "at" || last == "date" || last == "time" || last == "timestamp" || last == "on" ||
+		has(words, "birthday", "dob", "deadline")
+}
+
+// time generates a time around Now: birthdays decades ago, deadlines and
+// expiries ahead, updates after the row was created, and other times within
+// the past year
+func (g *Generator) time(r *row, words []string) time.Time {
+	const day = 24 * time.Hour
+	switch {
+	case has(words, "birth", "birthday", "dob"):
+		return g.Now.AddDate(-18-g.rand.Intn(62), 0, -g.rand.Intn(365))
+	case has(words, "expires", "expiry", "due", "ends", "end", "until", "scheduled", "starts", "deadline", "next"):
+		return g.Now.Add(time.Hour + time.Duration(g.rand.Int63n(int64(180*day))))
+	}
+
+	if r.created.IsZero() {
+		r.created = g.Now.Add(-time.Duration(g.rand.Int63n(int64(365 * day)))).Truncate(time.Second)
+	}
+	if has(words, "created", "joined", "registered", "signed") {
+		return r.created
+	}
+	since := g.Now.Sub(r.created)
+	if since <= 0 {
+		return g.Now
+	}
+	return r.created.Add(time.Duration(g.rand.Int63n(int64(since)))).Truncate(time.Second)
+}
+
+// number generates a number of a scalar type for a field's words
+func (g *Generator) number(r *row, words []string, named string) interface{} {
+	var value float64
+	decimals := 0
+	switch {
+	case isTime(words):
+		value = float64(g.time(r, words).Unix())
+	case has(words, "price", "amount", "total", "cost", "balance", "salary", "fee", "revenue", "subtotal", "tax"):
+		value, decimals = 1+g.rand.Float64()*999, 2
+		if named != "Float" {
+			value *= 100 // integer amounts are in cents
+		}
+	case has(words, "age"):
+		value = float64(18 + g.rand.Intn(72))
+	case has(words, "rating", "stars"):
+		value, decimals = 1+g.rand.Float64()*4, 1
+	case has(words, "score", "percent", "percentage", "progress"):
+		value = float64(g.rand.Intn(101))
+	case has(words, "year"):
+		value = float64(g.Now.Year() - g.rand.Intn(30))
+	case has(words, "lat", "latitude"):
+		value, decimals = g.rand.Float64()*180-90, 6
+	case has(words, "lng", "lon", "long", "longitude"):
+		value, decimals = g.rand.Float64()*360-180, 6
+	case has(words, "weight", "height", "width", "length", "size", "duration"):
+		value, decimals = 1+g.rand.Float64()*199, 1
+	default:
+		value, decimals = g.rand.Float64()*1000, 2
+	}
+
+	switch named {
+	case "Int":
+		return int(value)
+	case "BigInt":
+		return int64(value)
+	}
+	scale := 1.0
+	for i := 0; i < decimals; i++ {
+		scale *= 10
+	}
+	return float64(int64(value*scale)) / scale
+}
+
+// personTables are words of the names of tables holding people, whose name
+// fields hold people's names
+var personTables = []string{
+	"user", "users", "person", "people", "customer", "author", "member", "employee", "contact",
+	"profile", "account", "student", "patient", "client", "owner", "staff", "attendee", "guest",
+}
+
+// companyTables are words of the names of tables holding organizations
+var companyTables = []string{"company", "companies", "organization", "organisation", "team", "brand", "vendor", "supplier", "tenant"}
+
+// text generates text for a field's words
+func (g *Generator) text(r *row, words []string) string {
+	switch {
+	case has(words, "email"):
+		return g.email(r)
+	case has(words, "username", "login", "handle", "nickname", "screen") || (has(words, "user") && has(words, "name")):
+		first, last := g.person(r)
+		return strings.ToLower(first[:1]+last) + strconv.Itoa(r.index+1)
+	case has(words, "password", "hash", "digest"):
+		return "$2a$10$" + g.chars("./ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789", 53)
+	case has(words, "first", "given", "forename"):
+		first, _ := g.person(r)
+		return first
+	case has(words, "last", "surname", "family"):
+		_, last := g.person(r)
+		return last
+	case has(words, "phone", "mobile", "fax", "tel", "telephone"):
+		return fmt.Sprintf("+1-555-%03d-%04d", g.rand.Intn(1000), g.rand.Intn(10000))
+	case has(words, "avatar", "image", "photo", "picture", "thumbnail", "logo", "icon", "cover"):
+		return fmt.Sprintf("https://picsum.photos/seed/%s-%d/400", r.table.Name, r.index+1)
+	case has(words, "url", "website", "homepage", "link", "href", "uri"):
+		return "https://www." + g.pick(domains) + "/" + g.slug(r)
+	case has(words, "slug", "permalink"):
+		return g.slug(r)
+	case has(words, "city", "town"):
+		return g.pick(cities)
+	case has(words, "country") && has(words, "code"):
+		return countries[g.rand.Intn(len(countries))].code
+	case has(words, "country", "nationality"):
+		return countries[g.rand.Intn(len(countries))].name
+	case has(words, "zip", "postal", "postcode"):
+		return fmt.Sprintf("%05d", g.rand.Intn(100000))
+	case has(words, "address", "street", "line1"):
+		return fmt.Sprintf("%d %s", 1+g.rand.Intn(999), g.pick(streets))
+	case has(words, "company", "organization", "organisation", "employer", "business"):
+		return g.company()
+	case has(words, "job", "occupation", "position", "profession"):
+		return g.pick(jobTitles)
+	case has(words, "currency"):
+		return g.pick(currencies)
+	case has(words, "color", "colour"):
+		return fmt.Sprintf("#%06x", g.rand.Intn(1<<24))
+	case has(words, "ip"):
+		return fmt.Sprintf("192.0.2.%d", 1+g.rand.Intn(254))
+	case has(words, "uuid", "guid"):
+		return g.uuid()
+	case has(words, "token", "secret", "nonce"):
+		return g.chars("0123456789abcdef", 32)
+	case has(words, "locale", "language", "lang"):
+		return g.pick([]string{"en-US", "en-GB", "es-ES", "fr-FR", "de-DE", "pt-BR", "ja-JP", "hi-IN"})
+	case has(words, "timezone", "tz"):
+		return g.pick([]string{"UTC", "America/New_York", "America/Sao_Paulo", "Europe/London", "Europe/Berlin", "Africa/Lagos", "Asia/Kolkata", "Asia/Tokyo"})
+	case isTime(words):
+		return g.time(r, words).Format(time.RFC3339)
+	case has(words, "name", "fullname") || has(words, "display"):
+		tableWords := splitWords(r.table.Name)
+		switch {
+		case has(tableWords, personTables...):
+			first, last := g.person(r)
+			return first + " " + last
+		case has(tableWords, companyTables...):
+			return g.company()
+		}
+		return g.pick(adjectives) + " " + g.pick(nouns)
+	case has(words, "title", "subject", "headline", "heading", "caption"):
+		return g.sentence(3, 6, true)
+	case has(words, "description", "body", "content", "bio", "summary", "text", "comment", "message",
+		"note", "notes", "excerpt", "about", "details", "review", "reply"):
+		paragraph := make([]string, 2+g.rand.Intn(3))
+		for i := range paragraph {
+			paragraph[i] = g.sentence(6, 12, false) + "."
+		}
+		return strings.Join(paragraph, " ")
+	case has(words, "status"):
+		return g.pick([]string{"active", "pending", "completed", "archived"})
+	case has(words, "code", "sku", "reference", "ref", "number"):
+		return g.chars("ABCDEFGHJKLMNPQRSTUVWXYZ", 2) + "-" + g.chars("0123456789", 5)
+	case has(words, "tag", "category", "label", "keyword", "topic", "genre"):
+		return g.pick(loremWords)
+	}
+	return g.sentence(2, 4, false)
+}
+
+// person returns the first and last name of the row's person
+func (g *Generator) person(r *row) (string, string) {
+	if r.first == "" {
+		r.first, r.last = g.pick(firstNames), g.pick(lastNames)
+	}
+	return r.first, r.last
+}
+
+// email returns the row's person's email address, unique within the table
+func (g *Generator) email(r *row) string {
+	first, last := g.person(r)
+	return fmt.Sprintf("%s.%s%d@%s", strings.ToLower(first), strings.ToLower(last), r.index+1, g.pick(domains))
+}
+
+// slug returns a slug unique within the table
+func (g *Generator) slug(r *row) string {
+	return strings.ToLower(g.pick(adjectives) + "-" + g.pick(nouns) + "-" + strconv.Itoa(r.index+1))
+}
+
+func (g *Generator) company() string {
+	return g.pick(nouns) + " " + g.pick(companySuffixes)
+}
+
+// sentence returns between min and max words of lorem ipsum, capitalized,
+// or in title case
+func (g *Generator) sentence(min, max int, title bool) string {
+	words := make([]string, min+g.rand.Intn(max-min+1))
+	for i := range words {
+		words[i] = g.pick(loremWords)
+		if i == 0 || title {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func (g *Generator) pick(words []string) string {
+	return words[g.rand.Intn(len(words))]
+}
+
+// chars returns n characters chosen from alphabet
+func (g *Generator) chars(alphabet string, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = alphabet[g.rand.Intn(len(alphabet))]
+	}
+	return string(b)
+}
+
+// uuid returns a random version 4 UUID
+func (g *Generator) uuid() string {
+	return fmt.Sprintf("%08x-%04x-4%03x-%04x-%012x",
+		g.rand.Uint32(), g.rand.Intn(1<<16), g.rand.Intn(1<<12), 0x8000|g.rand.Intn(0x4000), g.rand.Int63n(1<<48))
+}
+
+// ParseCount parses a number of rows such as "500", "10k" or "1.5m"
+func ParseCount(text string) (int, error) {
+	number := strings.ToLower(strings.TrimSpace(text))
+	scale := 1.0
+	switch {
+	case strings.HasSuffix(number, "k"):
+		scale, number = 1e3, strings.TrimSuffix(number, "k")
+	case strings.HasSuffix(number, "m"):
+		scale, number = 1e6, strings.TrimSuffix(number, "m")
+	}
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n*scale < 1 || n*scale > 1e9 {
+		return 0, fmt.Errorf("fake: %q is not a number of rows, such as 500 or 10k", text)
+	}
+	return int(n * scale), nil
+}
+
+// has reports whether words include any of names
+func has(words []string, names ...string) bool {
+	for _, word := range words {
+		for _, name := range names {
+			if word == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// singular returns the singular of a plural field name, such as tag for
+// tags, for the items of a list
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies"):
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "ses"), strings.HasSuffix(name, "xes"):
+		return strings.TrimSuffix(name, "es")
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss"):
+		return strings.TrimSuffix(name, "s")
+	}
+	return name
+}
+
+// splitWords splits a name such as "createdAt" or "avatar_url" into its
+// lowercase words
+func splitWords(name string) []string {
+	words := strings.FieldsFunc(naming.SnakeCase(name), func(r rune) bool { return r == '_' || r == '-' || r == ' ' })
+	if len(words) == 0 {
+		return []string{""}
+	}
+	return words
+}
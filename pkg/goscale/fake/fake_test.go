@@ -0,0 +1,136 @@
+package fake
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscale/sdl"
+)
+
+const schema = `
+enum Role { ADMIN EDITOR VIEWER }
+
+type Query { posts: [Post!]! }
+
+type Post {
+  id: ID!
+  title: String!
+  slug: String!
+  authorId: ID!
+  author: User
+  parentId: ID
+  tags: [String!]
+  price: Float!
+  createdAt: DateTime!
+  updatedAt: DateTime!
+}
+
+type User @table(name: "people") {
+  id: ID!
+  name: String!
+  email: String!
+  role: Role!
+  active: Boolean!
+  deletedAt: DateTime
+}
+`
+
+func TestTables(t *testing.T) {
+	doc, err := sdl.Parse(schema)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	tables := Tables(doc)
+
+	if len(tables) != 2 || tables[0].Name != "people" || tables[1].Name != "post" {
+		t.Fatalf("expected people before post, got %+v", tables)
+	}
+	post := tables[1]
+	if post.Key != "id" || post.Field("author") != nil {
+		t.Fatalf("expected post keyed by id without the author object, got %+v", post)
+	}
+	if got := post.Field("authorId").References; got != "people" {
+		t.Fatalf("expected authorId to reference people, got %q", got)
+	}
+	if got := post.Field("parentId").References; got != "" {
+		t.Fatalf("expected parentId to reference nothing, got %q", got)
+	}
+	if got := tables[0].Field("role").Values; !reflect.DeepEqual(got, []string{"ADMIN", "EDITOR", "VIEWER"}) {
+		t.Fatalf("expected the role values, got %v", got)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	doc, _ := sdl.Parse(schema)
+	tables := Tables(doc)
+
+	generate := func() map[string][]map[string]interface{} {
+		g := New(42)
+		g.Now = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+		rows := make(map[string][]map[string]interface{})
+		err := g.Generate(tables, 50, func(table *Table, row map[string]interface{}) error {
+			rows[table.Name] = append(rows[table.Name], row)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		return rows
+	}
+	rows := generate()
+	if !reflect.DeepEqual(rows, generate()) {
+		t.Fatalf("expected the same seed to generate the same rows")
+	}
+
+	users := make(map[interface{}]bool)
+	emails := make(map[interface{}]bool)
+	for _, user := range rows["people"] {
+		users[user["id"]] = true
+		emails[user["email"]] = true
+		name := strings.ToLower(strings.Fields(user["name"].(string))[0])
+		if email := user["email"].(string); !strings.HasPrefix(email, name+".") || !strings.Contains(email, "@example.") {
+			t.Fatalf("expected an email address for %q, got %q", user["name"], email)
+		}
+	}
+	if len(users) != 50 || len(emails) != 50 {
+		t.Fatalf("expected unique keys and email addresses, got %d and %d", len(users), len(emails))
+	}
+
+	for _, post := range rows["post"] {
+		if !users[post["authorId"]] {
+			t.Fatalf("expected authorId to be a user's key, got %v", post["authorId"])
+		}
+		created, _ := time.Parse(time.RFC3339, post["createdAt"].(string))
+		updated, _ := time.Parse(time.RFC3339, post["updatedAt"].(string))
+		if updated.Before(created) || created.After(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)) {
+			t.Fatalf("expected a past createdAt before updatedAt, got %v and %v", created, updated)
+		}
+		if price := post["price"].(float64); price < 1 || price > 1000 {
+			t.Fatalf("expected a price, got %v", price)
+		}
+		if _, ok := post["tags"].([]interface{}); !ok && post["tags"] != nil {
+			t.Fatalf("expected a list of tags, got %#v", post["tags"])
+		}
+	}
+}
+
+func TestGenerateRequiresReferencedRows(t *testing.T) {
+	doc, _ := sdl.Parse(schema)
+	tables := Tables(doc)
+
+	err := New(1).Generate(tables[1:], 1, func(*Table, map[string]interface{}) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "references people") {
+		t.Fatalf("expected an error for the missing people, got %v", err)
+	}
+
+	for text, want := range map[string]int{"500": 500, "10k": 10000, "1.5K": 1500, "2m": 2000000} {
+		if got, err := ParseCount(text); err != nil || got != want {
+			t.Fatalf("ParseCount(%q) = %d, %v; expected %d", text, got, err, want)
+		}
+	}
+	if _, err := ParseCount("lots"); err == nil {
+		t.Fatalf("expected an error for an invalid count")
+	}
+}
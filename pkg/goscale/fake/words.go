@@ -0,0 +1,50 @@
+package fake
+
+// The words values are made of. They are plain ASCII, so generated rows
+// survive any encoding a database or client uses.
+var (
+	firstNames = []string{
+		"Ada", "Alan", "Amara", "Ana", "Arjun", "Beatriz", "Ben", "Chen", "Chloe", "Daniel",
+		"Diego", "Elena", "Emeka", "Emma", "Farah", "Grace", "Hana", "Hugo", "Ines", "Isaac",
+		"Jonas", "Julia", "Kai", "Kenji", "Lara", "Leila", "Liam", "Lucia", "Maya", "Mateo",
+		"Mei", "Nadia", "Noah", "Omar", "Priya", "Rafael", "Sara", "Sofia", "Tariq", "Zoe",
+	}
+	lastNames = []string{
+		"Adeyemi", "Alvarez", "Andersen", "Bauer", "Chen", "Costa", "Dubois", "Evans", "Fischer", "Garcia",
+		"Haddad", "Hopper", "Ivanova", "Jensen", "Kim", "Kowalski", "Lovelace", "Martin", "Mendes", "Moreau",
+		"Nakamura", "Novak", "Okafor", "Olsen", "Patel", "Petrov", "Quinn", "Rossi", "Santos", "Schmidt",
+		"Silva", "Singh", "Suzuki", "Tanaka", "Turing", "Vargas", "Walker", "Weber", "Yilmaz", "Zhang",
+	}
+	adjectives = []string{
+		"Amber", "Bold", "Bright", "Calm", "Clever", "Crimson", "Swift", "Gentle", "Golden", "Silent",
+		"Hidden", "Lucky", "Modern", "Noble", "Quiet", "Rapid", "Silver", "Simple", "Solid", "Wild",
+	}
+	nouns = []string{
+		"Anchor", "Beacon", "Bridge", "Canyon", "Cedar", "Comet", "Falcon", "Forest", "Garden", "Harbor",
+		"Horizon", "Island", "Lantern", "Meadow", "Orbit", "Pioneer", "River", "Summit", "Valley", "Willow",
+	}
+	companySuffixes = []string{"Labs", "Systems", "Group", "Works", "Studio", "Partners", "Inc", "Co"}
+	loremWords      = []string{
+		"lorem", "ipsum", "dolor", "sit", "amet", "consectetur", "adipiscing", "elit", "sed", "do",
+		"eiusmod", "tempor", "incididunt", "ut", "labore", "et", "dolore", "magna", "aliqua", "enim",
+		"ad", "minim", "veniam", "quis", "nostrud", "exercitation", "ullamco", "laboris", "nisi", "aliquip",
+		"ex", "ea", "commodo", "consequat", "duis", "aute", "irure", "in", "reprehenderit", "voluptate",
+	}
+	cities = []string{
+		"Amsterdam", "Austin", "Bangalore", "Berlin", "Bogota", "Cape Town", "Chicago", "Dublin", "Lagos", "Lisbon",
+		"London", "Melbourne", "Mexico City", "Montreal", "Nairobi", "Osaka", "Paris", "Seoul", "Singapore", "Toronto",
+	}
+	countries = []struct{ name, code string }{
+		{"Australia", "AU"}, {"Brazil", "BR"}, {"Canada", "CA"}, {"France", "FR"}, {"Germany", "DE"},
+		{"India", "IN"}, {"Ireland", "IE"}, {"Japan", "JP"}, {"Kenya", "KE"}, {"Mexico", "MX"},
+		{"Netherlands", "NL"}, {"Nigeria", "NG"}, {"Portugal", "PT"}, {"Singapore", "SG"}, {"South Korea", "KR"},
+		{"Spain", "ES"}, {"Sweden", "SE"}, {"United Kingdom", "GB"}, {"United States", "US"}, {"Colombia", "CO"},
+	}
+	streets    = []string{"Main St", "Oak Ave", "Park Rd", "Maple Dr", "Cedar Ln", "Elm St", "Lake View", "Hill Rd", "Station Rd", "Church St"}
+	domains    = []string{"example.com", "example.org", "example.net"}
+	currencies = []string{"USD", "EUR", "GBP", "JPY", "INR", "BRL", "CAD", "AUD"}
+	jobTitles  = []string{
+		"Engineer", "Designer", "Product Manager", "Data Analyst", "Support Lead",
+		"Marketing Manager", "Accountant", "Sales Director", "Researcher", "Consultant",
+	}
+)
@@ -0,0 +1,190 @@
+package flags
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/reqctx"
+)
+
+// CookieName is the cookie Middleware keeps a visitor's subject key in, so
+// it stays in its buckets across visits
+const CookieName = "gs_flags"
+
+// contextKey is the context key of a request's bound set
+type contextKey struct{}
+
+// binding is a set bound to a subject, with the variants it served, so a
+// request is served one variant per flag and exposed to it once
+type binding struct {
+	set     *Set
+	subject Subject
+
+	mutex     sync.Mutex
+	evaluated map[string]Evaluation
+}
+
+// NewContext returns a context evaluating the set's flags for the subject,
+// for Enabled and VariantOf to branch on in resolvers and in the handlers
+// rendering components
+func NewContext(ctx context.Context, set *Set, subject Subject) context.Context {
+	return context.WithValue(ctx, contextKey{}, &binding{set: set, subject: subject, evaluated: make(map[string]Evaluation)})
+}
+
+// SubjectFromContext returns the subject flags are evaluated for in ctx
+func SubjectFromContext(ctx context.Context) (Subject, bool) {
+	b, ok := ctx.Value(contextKey{}).(*binding)
+	if !ok {
+		return Subject{}, false
+	}
+	return b.subject, true
+}
+
+// Evaluate returns the variant a flag serves the subject of ctx. A flag is
+// evaluated once per context, so the subject is exposed to it once however
+// often it is checked. Contexts without a set serve no variant.
+func Evaluate(ctx context.Context, key string) Evaluation {
+	b, ok := ctx.Value(contextKey{}).(*binding)
+	if !ok {
+		return Evaluation{Flag: key, Reason: ReasonUnknown}
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if evaluation, ok := b.evaluated[key]; ok {
+		return evaluation
+	}
+	evaluation := b.set.Evaluate(b.subject, key)
+	b.evaluated[key] = evaluation
+	return evaluation
+}
+
+// Enabled reports whether a flag serves the subject of ctx a variant other
+// than Off
+//
+//	if flags.Enabled(ctx, "new-checkout") {
+//		return newCheckout(ctx, params)
+//	}
+func Enabled(ctx context.Context, key string) bool {
+	return Evaluate(ctx, key).Enabled()
+}
+
+// VariantOf returns the variant a flag serves the subject of ctx, or "" if
+// ctx has no set or the flag is unknown
+func VariantOf(ctx context.Context, key string) string {
+	return Evaluate(ctx, key).Variant
+}
+
+// Evaluated returns the flags evaluated in ctx so far with their variants.
+// A response that depends on them must not be cached for other subjects.
+func Evaluated(ctx context.Context) map[string]string {
+	b, ok := ctx.Value(contextKey{}).(*binding)
+	if !ok {
+		return nil
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	variants := make(map[string]string, len(b.evaluated))
+	for key, evaluation := range b.evaluated {
+		variants[key] = evaluation.Variant
+	}
+	return variants
+}
+
+// Bind returns the request's context evaluating the set's flags for its
+// subject: the key Identify returns, or else the principal reqctx finds in
+// the request's context, such as the signed-in user, or else the visitor's
+// CookieName cookie, set on w for a year when the visitor has none yet. The
+// subject's attributes are its reqctx tenant, as "tenant", then those of
+// Attributes, then attributes.
+func (s *Set) Bind(w http.ResponseWriter, r *http.Request, attributes map[string]string) context.Context {
+	subject := Subject{Attributes: make(map[string]string)}
+	if s.Identify != nil {
+		subject.Key = s.Identify(r)
+	}
+	if subject.Key == "" {
+		subject.Key = reqctx.Principal(r.Context())
+	}
+	if subject.Key == "" {
+		if cookie, err := r.Cookie(CookieName); err == nil && cookie.Value != "" {
+			subject.Key = cookie.Value
+		} else {
+			subject.Key = newSubjectKey()
+			http.SetCookie(w, &http.Cookie{
+				Name:     CookieName,
+				Value:    subject.Key,
+				Path:     "/",
+				Expires:  time.Now().AddDate(1, 0, 0),
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+	}
+
+	if tenant := reqctx.Tenant(r.Context()); tenant != "" {
+		subject.Attributes["tenant"] = tenant
+	}
+	if s.Attributes != nil {
+		for name, value := range s.Attributes(r) {
+			subject.Attributes[name] = value
+		}
+	}
+	for name, value := range attributes {
+		subject.Attributes[name] = value
+	}
+	return NewContext(r.Context(), s, subject)
+}
+
+// Middleware binds each request's context to its subject, as Bind does,
+// so the handlers below it can branch with Enabled and VariantOf
+func (s *Set) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(s.Bind(w, r, nil)))
+	})
+}
+
+// Handler serves the set's flags as JSON, for edge nodes to Fetch from the
+// origin
+func (s *Set) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.Flags())
+	})
+}
+
+// Fetch replaces the set's flags with those served at url, such as by the
+// origin's Handler. On failure the set keeps its flags.
+func (s *Set) Fetch(ctx context.Context, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("flags: fetching %s: %s", url, resp.Status)
+	}
+
+	var flags []*Flag
+	if err := json.NewDecoder(resp.Body).Decode(&flags); err != nil {
+		return fmt.Errorf("flags: fetching %s: %w", url, err)
+	}
+	return s.Replace(flags)
+}
+
+// newSubjectKey returns a random key for a new visitor
+func newSubjectKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,460 @@
+// Package flags evaluates feature flags and A/B tests: flags with
+// variants, percentage rollouts and targeting rules, evaluated where a
+// request is served, such as at an edge node. Bucketing is sticky: a
+// subject is hashed into the same bucket of a flag on every node and every
+// request, so it keeps its variant without any state being shared.
+package flags
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+const (
+	// MetricExposures is the type of the Jetpack metric exposures are
+	// recorded in
+	MetricExposures core.MetricType = "flag_exposures"
+
+	// ExposureMetric names the Jetpack counter of exposures, labeled with
+	// the flag, the variant and the Set's Source
+	ExposureMetric = "flag_exposures"
+)
+
+// Variants of boolean flags, which have no Variants of their own
+const (
+	On  = "on"
+	Off = "off"
+)
+
+// buckets is how many buckets subjects are hashed into per flag; rollouts
+// are precise to a hundredth of a percent
+const buckets = 10000
+
+// Reasons an Evaluation gives for its variant
+const (
+	// ReasonUnknown is given for flags the Set does not define, which
+	// serve no variant
+	ReasonUnknown = "unknown"
+
+	// ReasonDisabled is given when the flag is disabled and serves its
+	// default
+	ReasonDisabled = "disabled"
+
+	// ReasonRule is given when one of the flag's rules matched the subject
+	ReasonRule = "rule"
+
+	// ReasonRollout is given when the subject's bucket chose the variant
+	ReasonRollout = "rollout"
+)
+
+// Operators of a Condition
+const (
+	// OpIn matches attributes with one of the values
+	OpIn = "in"
+
+	// OpNotIn matches attributes that are missing or have none of the
+	// values
+	OpNotIn = "not_in"
+
+	// OpPrefix matches attributes starting with one of the values
+	OpPrefix = "prefix"
+)
+
+// Subject is who a flag is evaluated for: a signed-in user, or a visitor
+// told apart by a cookie. Its Key decides its bucket; its Attributes, such
+// as "country" or "plan", are matched by targeting rules.
+type Subject struct {
+	Key        string            `json:"key"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// attribute returns one of the subject's attributes; "key" is its Key
+func (s Subject) attribute(name string) (string, bool) {
+	if name == "key" {
+		return s.Key, s.Key != ""
+	}
+	value, ok := s.Attributes[name]
+	return value, ok
+}
+
+// Variant is one of the values a flag serves, with its share of a rollout
+type Variant struct {
+	Name string `json:"name"`
+
+	// Weight is the share of the subjects the rollout serves the variant
+	// to, relative to the weights of the others
+	Weight int `json:"weight"`
+}
+
+// Condition matches a subject's attribute against values
+type Condition struct {
+	Attribute string   `json:"attribute"`
+	Operator  string   `json:"operator,omitempty"` // OpIn when empty
+	Values    []string `json:"values"`
+}
+
+// Match reports whether the subject's attribute matches the condition
+func (c Condition) Match(subject Subject) bool {
+	value, ok := subject.attribute(c.Attribute)
+	switch c.Operator {
+	case OpNotIn:
+		return !ok || !contains(c.Values, value)
+	case OpPrefix:
+		for _, prefix := range c.Values {
+			if ok && strings.HasPrefix(value, prefix) {
+				return true
+			}
+		}
+		return false
+	}
+	return ok && contains(c.Values, value)
+}
+
+// Rule serves a variant to the subjects matching all its conditions: its
+// Variant, or one chosen by the subject's bucket from its Rollout
+type Rule struct {
+	Conditions []Condition `json:"conditions"`
+	Variant    string      `json:"variant,omitempty"`
+	Rollout    []Variant   `json:"rollout,omitempty"`
+}
+
+// Flag is a feature flag or an A/B test. A flag without Variants is a
+// boolean flag serving On to Percentage percent of the subjects and Off to
+// the others; one with Variants splits the subjects across them by their
+// weights. Raising a boolean flag's Percentage keeps the subjects it
+// already served On.
+type Flag struct {
+	Key         string `json:"key"`
+	Description string `json:"description,omitempty"`
+
+	// Enabled turns the flag on; a disabled flag serves Default to every
+	// subject
+	Enabled bool `json:"enabled"`
+
+	// Variants of a multivariate flag, with their weights in the rollout
+	// of the subjects no rule matches
+	Variants []Variant `json:"variants,omitempty"`
+
+	// Percentage of the subjects a boolean flag serves On, from 0 to 100
+	Percentage float64 `json:"percentage,omitempty"`
+
+	// Rules target subjects by their attributes; the first that matches
+	// decides the variant
+	Rules []Rule `json:"rules,omitempty"`
+
+	// Default is served when the flag is disabled, and by rollouts to
+	// subjects without a Key: Off for boolean flags and the first variant
+	// for others when empty
+	Default string `json:"default,omitempty"`
+
+	// Salt changes the buckets subjects are hashed into, so the subjects
+	// of a new experiment are split independently of an earlier one
+	Salt string `json:"salt,omitempty"`
+}
+
+// Validate checks the flag's key, variants and rules
+func (f *Flag) Validate() error {
+	if f.Key == "" {
+		return errors.New("flags: a flag needs a key")
+	}
+	if f.Percentage < 0 || f.Percentage > 100 {
+		return fmt.Errorf("flags: %s: percentage must be between 0 and 100", f.Key)
+	}
+
+	names := make(map[string]bool)
+	for _, variant := range f.variants() {
+		if variant.Name == "" || names[variant.Name] {
+			return fmt.Errorf("flags: %s: variant names must be unique and not empty", f.Key)
+		}
+		if variant.Weight < 0 {
+			return fmt.Errorf("flags: %s: variant %s has a negative weight", f.Key, variant.Name)
+		}
+		names[variant.Name] = true
+	}
+	known := func(name string) error {
+		if !names[name] {
+			return fmt.Errorf("flags: %s: unknown variant %q", f.Key, name)
+		}
+		return nil
+	}
+
+	if f.Default != "" {
+		if err := known(f.Default); err != nil {
+			return err
+		}
+	}
+	for _, rule := range f.Rules {
+		if rule.Variant == "" && len(rule.Rollout) == 0 {
+			return fmt.Errorf("flags: %s: a rule needs a variant or a rollout", f.Key)
+		}
+		if rule.Variant != "" {
+			if err := known(rule.Variant); err != nil {
+				return err
+			}
+		}
+		for _, variant := range rule.Rollout {
+			if err := known(variant.Name); err != nil {
+				return err
+			}
+		}
+		for _, condition := range rule.Conditions {
+			switch condition.Operator {
+			case "", OpIn, OpNotIn, OpPrefix:
+			default:
+				return fmt.Errorf("flags: %s: unknown operator %q", f.Key, condition.Operator)
+			}
+		}
+	}
+	return nil
+}
+
+// variants returns the flag's variants, On and Off split by Percentage for
+// boolean flags
+func (f *Flag) variants() []Variant {
+	if len(f.Variants) > 0 {
+		return f.Variants
+	}
+	on := int(f.Percentage*buckets/100 + 0.5)
+	return []Variant{{Name: On, Weight: on}, {Name: Off, Weight: buckets - on}}
+}
+
+// defaultVariant returns the variant served when no other can be
+func (f *Flag) defaultVariant() string {
+	switch {
+	case f.Default != "":
+		return f.Default
+	case len(f.Variants) > 0:
+		return f.Variants[0].Name
+	}
+	return Off
+}
+
+// Evaluate returns the variant the flag serves the subject, without
+// recording an exposure
+func (f *Flag) Evaluate(subject Subject) Evaluation {
+	evaluation := Evaluation{Flag: f.Key, Reason: ReasonDisabled, Variant: f.defaultVariant()}
+	if !f.Enabled {
+		return evaluation
+	}
+
+	for i, rule := range f.Rules {
+		matched := true
+		for _, condition := range rule.Conditions {
+			matched = matched && condition.Match(subject)
+		}
+		if !matched {
+			continue
+		}
+		evaluation.Reason, evaluation.Rule = ReasonRule, i+1
+		evaluation.Variant = rule.Variant
+		if evaluation.Variant == "" {
+			evaluation.Variant = f.rollout(rule.Rollout, subject)
+		}
+		return evaluation
+	}
+
+	evaluation.Reason = ReasonRollout
+	evaluation.Variant = f.rollout(f.variants(), subject)
+	return evaluation
+}
+
+// rollout chooses a variant by the subject's bucket, each variant taking a
+// range of the buckets as wide as its share of the weights
+func (f *Flag) rollout(variants []Variant, subject Subject) string {
+	total := 0
+	for _, variant := range variants {
+		total += variant.Weight
+	}
+	if subject.Key == "" || total == 0 {
+		return f.defaultVariant()
+	}
+
+	point := f.bucket(subject.Key) * total / buckets
+	for _, variant := range variants {
+		if point < variant.Weight {
+			return variant.Name
+		}
+		point -= variant.Weight
+	}
+	return variants[len(variants)-1].Name
+}
+
+// bucket hashes a subject's key into one of the flag's buckets
+func (f *Flag) bucket(key string) int {
+	salt := f.Salt
+	if salt == "" {
+		salt = f.Key
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(salt + "." + key))
+	return int(hash.Sum64() % buckets)
+}
+
+// Evaluation is the variant a flag served a subject, and why
+type Evaluation struct {
+	Flag    string `json:"flag"`
+	Variant string `json:"variant"`
+	Reason  string `json:"reason"`
+
+	// Rule is the number of the rule that matched, from 1, with ReasonRule
+	Rule int `json:"rule,omitempty"`
+}
+
+// Enabled reports whether the flag serves the subject a variant other than
+// Off
+func (e Evaluation) Enabled() bool {
+	return e.Variant != "" && e.Variant != Off
+}
+
+// Set holds the flags of an app or an edge node and evaluates them, such
+// as for a request's subject through Middleware. It is safe for concurrent
+// use, and its flags can be replaced while it is in use, such as by Fetch.
+type Set struct {
+	// Jetpack, when set, records an exposure each time a subject is served
+	// a variant of a defined flag, in the ExposureMetric counter;
+	// GetMetricBreakdown(ExposureMetric, window, []string{"flag",
+	// "variant"}) counts the subjects of each variant
+	Jetpack *core.Jetpack
+
+	// Source labels the exposures with where the flags were evaluated,
+	// such as an edge node's ID
+	Source string
+
+	// Identify returns the subject key of a request, such as the signed-in
+	// user's ID; Middleware uses the reqctx principal, or else a cookie,
+	// when it is nil or returns ""
+	Identify func(r *http.Request) string
+
+	// Attributes returns the attributes of a request's subject, such as
+	// its country, for targeting rules
+	Attributes func(r *http.Request) map[string]string
+
+	mutex      sync.RWMutex
+	flags      map[string]*Flag
+	registered sync.Once
+}
+
+// NewSet creates a set of flags, which must be valid
+func NewSet(flags ...*Flag) (*Set, error) {
+	s := &Set{flags: make(map[string]*Flag)}
+	if err := s.Replace(flags); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Define adds a flag, or replaces the one with its key
+func (s *Set) Define(flag *Flag) error {
+	if err := flag.Validate(); err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.flags == nil {
+		s.flags = make(map[string]*Flag)
+	}
+	s.flags[flag.Key] = flag
+	return nil
+}
+
+// Remove removes a flag
+func (s *Set) Remove(key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.flags, key)
+}
+
+// Replace replaces all the flags at once, as fetched from the origin; if
+// any is invalid, the set is left as it was
+func (s *Set) Replace(flags []*Flag) error {
+	replaced := make(map[string]*Flag, len(flags))
+	for _, flag := range flags {
+		if err := flag.Validate(); err != nil {
+			return err
+		}
+		replaced[flag.Key] = flag
+	}
+	s.mutex.Lock()
+	s.flags = replaced
+	s.mutex.Unlock()
+	return nil
+}
+
+// Flag returns a flag by key
+func (s *Set) Flag(key string) (*Flag, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	flag, ok := s.flags[key]
+	return flag, ok
+}
+
+// Flags returns the flags by key
+func (s *Set) Flags() []*Flag {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	flags := make([]*Flag, 0, len(s.flags))
+	for _, flag := range s.flags {
+		flags = append(flags, flag)
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Key < flags[j].Key })
+	return flags
+}
+
+// Evaluate returns the variant a flag serves the subject, recording the
+// exposure in Jetpack. Unknown flags serve no variant.
+func (s *Set) Evaluate(subject Subject, key string) Evaluation {
+	flag, ok := s.Flag(key)
+	if !ok {
+		return Evaluation{Flag: key, Reason: ReasonUnknown}
+	}
+	evaluation := flag.Evaluate(subject)
+	s.expose(evaluation)
+	return evaluation
+}
+
+// Enabled reports whether a flag serves the subject a variant other than
+// Off
+func (s *Set) Enabled(subject Subject, key string) bool {
+	return s.Evaluate(subject, key).Enabled()
+}
+
+// Variant returns the variant a flag serves the subject, or "" for unknown
+// flags
+func (s *Set) Variant(subject Subject, key string) string {
+	return s.Evaluate(subject, key).Variant
+}
+
+// expose records an exposure in Jetpack, if the set has one
+func (s *Set) expose(evaluation Evaluation) {
+	if s.Jetpack == nil {
+		return
+	}
+	s.registered.Do(func() {
+		if _, err := s.Jetpack.GetMetric(ExposureMetric); err != nil {
+			s.Jetpack.RegisterMetric(MetricExposures, ExposureMetric, "Feature flag variants served", "exposures", nil, []string{"flags"})
+		}
+	})
+
+	labels := core.Labels{"flag": evaluation.Flag, "variant": evaluation.Variant}
+	if s.Source != "" {
+		labels["source"] = s.Source
+	}
+	s.Jetpack.RecordMetricWithLabels(ExposureMetric, 1, labels)
+}
+
+// contains reports whether values has value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+func TestRolloutsAreStickyAndTargeted(t *testing.T) {
+	checkout := &Flag{
+		Key:        "new-checkout",
+		Enabled:    true,
+		Percentage: 20,
+		Rules: []Rule{
+			{Conditions: []Condition{{Attribute: "plan", Values: []string{"beta"}}}, Variant: On},
+			{Conditions: []Condition{{Attribute: "country", Operator: OpNotIn, Values: []string{"US", "CA"}}}, Variant: Off},
+		},
+	}
+	set, err := NewSet(checkout)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	served := make(map[string]bool)
+	on := 0
+	for i := 0; i < 2000; i++ {
+		subject := Subject{Key: fmt.Sprint("user-", i), Attributes: map[string]string{"country": "US"}}
+		evaluation := set.Evaluate(subject, "new-checkout")
+		if evaluation.Reason != ReasonRollout {
+			t.Fatalf("expected a rollout, got %+v", evaluation)
+		}
+		if evaluation.Enabled() != set.Enabled(subject, "new-checkout") {
+			t.Fatalf("%s was served different variants", subject.Key)
+		}
+		served[subject.Key] = evaluation.Enabled()
+		if evaluation.Enabled() {
+			on++
+		}
+	}
+	if on < 320 || on > 480 {
+		t.Fatalf("expected about 20%% of the subjects on, got %d of 2000", on)
+	}
+
+	// Raising the percentage keeps the subjects already on
+	checkout.Percentage = 50
+	for key, wasOn := range served {
+		if wasOn && !set.Enabled(Subject{Key: key, Attributes: map[string]string{"country": "US"}}, "new-checkout") {
+			t.Fatalf("%s was turned off by raising the rollout", key)
+		}
+	}
+
+	beta := set.Evaluate(Subject{Key: "user-1", Attributes: map[string]string{"plan": "beta", "country": "FR"}}, "new-checkout")
+	if beta.Variant != On || beta.Reason != ReasonRule || beta.Rule != 1 {
+		t.Fatalf("expected the beta rule to serve on, got %+v", beta)
+	}
+	abroad := set.Evaluate(Subject{Key: "user-1", Attributes: map[string]string{"country": "FR"}}, "new-checkout")
+	if abroad.Variant != Off || abroad.Rule != 2 {
+		t.Fatalf("expected the country rule to serve off, got %+v", abroad)
+	}
+
+	checkout.Enabled = false
+	if evaluation := set.Evaluate(Subject{Key: "user-1", Attributes: map[string]string{"plan": "beta"}}, "new-checkout"); evaluation.Enabled() || evaluation.Reason != ReasonDisabled {
+		t.Fatalf("expected a disabled flag to serve off, got %+v", evaluation)
+	}
+	if evaluation := set.Evaluate(Subject{Key: "user-1"}, "missing"); evaluation.Variant != "" || evaluation.Reason != ReasonUnknown {
+		t.Fatalf("expected an unknown flag to serve nothing, got %+v", evaluation)
+	}
+}
+
+func TestExperimentsRecordExposures(t *testing.T) {
+	set, err := NewSet(&Flag{
+		Key:      "pricing-page",
+		Enabled:  true,
+		Variants: []Variant{{Name: "control", Weight: 1}, {Name: "annual-first", Weight: 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	set.Jetpack = core.NewJetpack()
+	set.Source = "edge-1"
+
+	for i := 0; i < 100; i++ {
+		ctx := NewContext(context.Background(), set, Subject{Key: fmt.Sprint("visitor-", i)})
+		variant := VariantOf(ctx, "pricing-page")
+		if VariantOf(ctx, "pricing-page") != variant {
+			t.Fatal("expected one variant per context")
+		}
+		if Evaluated(ctx)["pricing-page"] != variant {
+			t.Fatalf("expected the evaluation to be tracked, got %v", Evaluated(ctx))
+		}
+	}
+
+	breakdown, err := set.Jetpack.GetMetricBreakdown(ExposureMetric, time.Minute, []string{"variant"}, core.LabelEquals("source", "edge-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	total := 0
+	for _, stats := range breakdown {
+		if stats.Count < 25 {
+			t.Fatalf("expected both variants to be served, got %v", breakdown)
+		}
+		total += stats.Count
+	}
+	if len(breakdown) != 2 || total != 100 {
+		t.Fatalf("expected one exposure per visitor, got %d in %d variants", total, len(breakdown))
+	}
+
+	if Enabled(context.Background(), "pricing-page") {
+		t.Fatal("expected a context without a set to serve nothing")
+	}
+}
+
+func TestMiddlewareKeepsVisitorsInTheirBuckets(t *testing.T) {
+	origin, err := NewSet(&Flag{Key: "banner", Enabled: true, Percentage: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(origin.Handler())
+	defer server.Close()
+
+	edge, _ := NewSet()
+	if err := edge.Fetch(context.Background(), server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := edge.Flag("banner"); !ok {
+		t.Fatal("expected the edge to fetch the origin's flags")
+	}
+	edge.Attributes = func(r *http.Request) map[string]string {
+		return map[string]string{"country": r.Header.Get("X-Country")}
+	}
+
+	var variant string
+	handler := edge.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject, _ := SubjectFromContext(r.Context())
+		if subject.Attributes["country"] != "DE" {
+			t.Errorf("expected the request's attributes, got %v", subject.Attributes)
+		}
+		variant = VariantOf(r.Context(), "banner")
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Country", "DE")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != CookieName {
+		t.Fatalf("expected a subject cookie, got %v", cookies)
+	}
+	first := variant
+
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Country", "DE")
+		req.AddCookie(cookies[0])
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if len(rec.Result().Cookies()) != 0 || variant != first {
+			t.Fatalf("expected the visitor to keep %s, got %s", first, variant)
+		}
+	}
+
+	if err := edge.Replace([]*Flag{{Key: "broken", Rules: []Rule{{Variant: "blue"}}}}); err == nil {
+		t.Fatal("expected a rule with an unknown variant to be rejected")
+	}
+	if _, ok := edge.Flag("banner"); !ok {
+		t.Fatal("expected a rejected replacement to keep the flags")
+	}
+}
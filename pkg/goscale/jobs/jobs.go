@@ -0,0 +1,96 @@
+// Package jobs runs background jobs, such as sending webhooks and emails or
+// syncing data, outside the requests that enqueue them. Jobs are kept in a
+// Store, such as GoScaleDB through jobs/storage, so they survive restarts
+// and are shared by every instance of the app; failed jobs are retried with
+// a growing backoff and end up in a dead-letter table.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrDuplicate is returned by Enqueue for a job whose Key is already taken
+// by a queued job.
+var ErrDuplicate = errors.New("jobs: a job with this key is already queued")
+
+// Job is a unit of background work of a type, with a JSON payload.
+type Job struct {
+	ID      int64           `json:"id"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+
+	// Key, when set, makes enqueueing idempotent: while a job with the same
+	// key is queued, others are dropped
+	Key string `json:"key,omitempty"`
+
+	// Attempts counts the runs started so far, including the current one
+	Attempts    int `json:"attempts"`
+	MaxAttempts int `json:"max_attempts"`
+
+	// RunAt is when the job is due
+	RunAt     time.Time `json:"run_at"`
+	LastError string    `json:"last_error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// FailedAt is when a dead letter failed for the last time
+	FailedAt time.Time `json:"failed_at,omitempty"`
+}
+
+// Decode decodes the job's payload into v.
+func (j *Job) Decode(v interface{}) error {
+	return json.Unmarshal(j.Payload, v)
+}
+
+// Handler runs jobs of a type. Errors have the job retried, unless it has
+// run out of attempts or the error is Permanent.
+type Handler func(ctx context.Context, job *Job) error
+
+// Store keeps the queued jobs and the dead letters.
+type Store interface {
+	// Enqueue stores a new job, setting its ID, or returns ErrDuplicate
+	Enqueue(ctx context.Context, job *Job) error
+
+	// Claim leases up to limit jobs of a type due by now, oldest first,
+	// counting up their Attempts. Claimed jobs are not claimed again until
+	// the lease ends, in case the worker running them dies.
+	Claim(ctx context.Context, jobType string, now time.Time, limit int, lease time.Duration) ([]*Job, error)
+
+	// Complete removes a job that ran successfully
+	Complete(ctx context.Context, job *Job) error
+
+	// Retry releases a failed job to run again at runAt
+	Retry(ctx context.Context, job *Job, runAt time.Time, reason string) error
+
+	// Bury moves a job that failed for good to the dead letters
+	Bury(ctx context.Context, job *Job, reason string) error
+
+	// DeadLetters returns the latest dead letters, up to limit
+	DeadLetters(ctx context.Context, limit int) ([]*Job, error)
+}
+
+// permanentError is an error not worth retrying.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a handler's error as not worth retrying, such as for a
+// payload that cannot be decoded, so the job goes to the dead letters at
+// once.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// isPermanent reports whether err was marked by Permanent.
+func isPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
@@ -0,0 +1,151 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+func TestQueueRetriesAndDeadLetters(t *testing.T) {
+	store := NewMemoryStore()
+	queue := New(store)
+	queue.Jetpack = core.NewJetpack()
+	queue.Backoff = 0
+
+	var mutex sync.Mutex
+	sent := map[string]int{}
+	queue.Handle("email", 0, func(ctx context.Context, job *Job) error {
+		var email struct{ To string }
+		if err := job.Decode(&email); err != nil {
+			return Permanent(err)
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		sent[email.To]++
+		if email.To == "flaky@example.com" && job.Attempts < 3 {
+			return errors.New("mail server unavailable")
+		}
+		if email.To == "broken@example.com" {
+			panic("no template")
+		}
+		return nil
+	})
+
+	ctx := context.Background()
+	for _, to := range []string{"ada@example.com", "flaky@example.com", "broken@example.com"} {
+		if _, err := queue.Enqueue(ctx, "email", map[string]string{"To": to}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := queue.EnqueueAt(ctx, "email", "not an email", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := queue.RunDue(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if sent["ada@example.com"] != 1 || sent["flaky@example.com"] != 3 || sent["broken@example.com"] != 5 {
+		t.Fatalf("unexpected attempts %v", sent)
+	}
+	if store.Len() != 1 {
+		t.Fatalf("expected only the job scheduled later to be queued, got %d", store.Len())
+	}
+
+	dead, _ := store.DeadLetters(ctx, 10)
+	if len(dead) != 1 || dead[0].Attempts != 5 || dead[0].LastError != "jobs: email job panicked: no template" {
+		t.Fatalf("unexpected dead letters %+v", dead)
+	}
+	for name, count := range map[string]int{"jobs_enqueued@email": 4, "jobs_completed": 2, "jobs_retried": 6, "jobs_dead@email": 1, "job_duration@email": 9} {
+		if stats, err := queue.Jetpack.GetMetricStats(name, 0); err != nil || stats.Count != count {
+			t.Fatalf("expected %d values of %s, got %+v %v", count, name, stats, err)
+		}
+	}
+}
+
+func TestQueueConcurrencyAndKeys(t *testing.T) {
+	queue := New(NewMemoryStore())
+	queue.PollInterval = time.Millisecond
+
+	var mutex sync.Mutex
+	running, most, done := 0, 0, 0
+	queue.Handle("webhook", 2, func(ctx context.Context, job *Job) error {
+		mutex.Lock()
+		running++
+		if running > most {
+			most = running
+		}
+		mutex.Unlock()
+		time.Sleep(5 * time.Millisecond)
+		mutex.Lock()
+		running--
+		done++
+		mutex.Unlock()
+		return nil
+	})
+
+	ctx := context.Background()
+	for i := 0; i < 6; i++ {
+		queue.Enqueue(ctx, "webhook", i)
+	}
+	if err := queue.EnqueueJob(ctx, &Job{Type: "webhook", Key: "sync"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := queue.EnqueueJob(ctx, &Job{Type: "webhook", Key: "sync"}); err != ErrDuplicate {
+		t.Fatalf("expected the second keyed job to be dropped, got %v", err)
+	}
+
+	queue.Start()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mutex.Lock()
+		finished := done
+		mutex.Unlock()
+		if finished == 7 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := queue.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if done != 7 || most != 2 {
+		t.Fatalf("expected 7 jobs run 2 at a time, got %d with up to %d at once", done, most)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	from := time.Date(2024, 3, 1, 9, 7, 30, 0, time.UTC) // a Friday
+	for spec, want := range map[string]string{
+		"*/15 * * * *":   "2024-03-01T09:15:00Z",
+		"0 9-17 * * 1-5": "2024-03-01T10:00:00Z",
+		"30 8 * * 1":     "2024-03-04T08:30:00Z",
+		"0 0 29 2 *":     "2028-02-29T00:00:00Z",
+		"0 12 15 * 0":    "2024-03-03T12:00:00Z",
+		"@monthly":       "2024-04-01T00:00:00Z",
+		"@every 90s":     "2024-03-01T09:09:00Z",
+		"5,10 7 1,2 3 *": "2024-03-02T07:05:00Z",
+		"0 0 * * 7":      "2024-03-03T00:00:00Z",
+	} {
+		schedule, err := ParseSchedule(spec)
+		if err != nil {
+			t.Fatalf("%s: %v", spec, err)
+		}
+		if next := schedule.Next(from).Format(time.RFC3339); next != want {
+			t.Fatalf("%s: expected %s, got %s", spec, want, next)
+		}
+	}
+	for _, spec := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "@every 1ms", "@often"} {
+		if _, err := ParseSchedule(spec); err == nil {
+			t.Fatalf("expected %q to be refused", spec)
+		}
+	}
+	if next := (&Schedule{}).Next(from); !next.IsZero() {
+		t.Fatalf("expected a schedule matching nothing never to run, got %v", next)
+	}
+}
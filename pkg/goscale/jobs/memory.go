@@ -0,0 +1,139 @@
+package jobs
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps jobs in memory, for tests and single instances whose
+// jobs need not survive a restart.
+type MemoryStore struct {
+	mutex  sync.Mutex
+	jobs   map[int64]*memoryJob
+	dead   []*Job
+	lastID int64
+}
+
+// memoryJob is a queued job and its lease.
+type memoryJob struct {
+	job         Job
+	lockedUntil time.Time
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{jobs: make(map[int64]*memoryJob)}
+}
+
+// Enqueue stores a new job.
+func (s *MemoryStore) Enqueue(ctx context.Context, job *Job) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if job.Key != "" {
+		for _, queued := range s.jobs {
+			if queued.job.Key == job.Key {
+				return ErrDuplicate
+			}
+		}
+	}
+	s.lastID++
+	job.ID = s.lastID
+	s.jobs[job.ID] = &memoryJob{job: *job}
+	return nil
+}
+
+// Claim leases the due jobs of a type.
+func (s *MemoryStore) Claim(ctx context.Context, jobType string, now time.Time, limit int, lease time.Duration) ([]*Job, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var due []*memoryJob
+	for _, queued := range s.jobs {
+		if queued.job.Type == jobType && !queued.job.RunAt.After(now) && !queued.lockedUntil.After(now) {
+			due = append(due, queued)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].job.RunAt.Equal(due[j].job.RunAt) {
+			return due[i].job.RunAt.Before(due[j].job.RunAt)
+		}
+		return due[i].job.ID < due[j].job.ID
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*Job, 0, len(due))
+	for _, queued := range due {
+		queued.job.Attempts++
+		queued.lockedUntil = now.Add(lease)
+		job := queued.job
+		claimed = append(claimed, &job)
+	}
+	return claimed, nil
+}
+
+// Complete removes a job.
+func (s *MemoryStore) Complete(ctx context.Context, job *Job) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.jobs, job.ID)
+	return nil
+}
+
+// Retry releases a job to run again at runAt.
+func (s *MemoryStore) Retry(ctx context.Context, job *Job, runAt time.Time, reason string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if queued, ok := s.jobs[job.ID]; ok {
+		queued.job.RunAt = runAt
+		queued.job.LastError = reason
+		queued.lockedUntil = time.Time{}
+	}
+	return nil
+}
+
+// Bury moves a job to the dead letters.
+func (s *MemoryStore) Bury(ctx context.Context, job *Job, reason string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	queued, ok := s.jobs[job.ID]
+	if !ok {
+		return nil
+	}
+	delete(s.jobs, job.ID)
+	dead := queued.job
+	dead.LastError = reason
+	dead.FailedAt = time.Now()
+	s.dead = append(s.dead, &dead)
+	return nil
+}
+
+// DeadLetters returns the latest dead letters, latest first.
+func (s *MemoryStore) DeadLetters(ctx context.Context, limit int) ([]*Job, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	letters := []*Job{}
+	for i := len(s.dead) - 1; i >= 0 && len(letters) < limit; i-- {
+		letter := *s.dead[i]
+		letters = append(letters, &letter)
+	}
+	return letters, nil
+}
+
+// Len is the number of queued jobs.
+func (s *MemoryStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.jobs)
+}
@@ -0,0 +1,422 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+const (
+	// Metric types of the Jetpack metrics a Queue records
+	MetricJobs        core.MetricType = "jobs"
+	MetricJobDuration core.MetricType = "job_duration"
+)
+
+// Queue runs the jobs of its Store with a pool of workers. Create it with
+// New and change its settings before calling Start.
+type Queue struct {
+	Store Store
+
+	// Jetpack, when set, records job metrics: jobs_enqueued,
+	// jobs_completed, jobs_retried and jobs_dead, job_duration and
+	// job_wait in ms, each also per job type, such as
+	// "job_duration@email"
+	Jetpack *core.Jetpack
+
+	// Concurrency caps the jobs run at once; Handle can cap each type lower
+	Concurrency int
+
+	// PollInterval is how often the store is checked for due jobs
+	PollInterval time.Duration
+
+	// Lease is how long a job may run before it is cancelled, and claimed
+	// again by another worker if this one died
+	Lease time.Duration
+
+	// MaxAttempts is how often jobs enqueued without their own are tried
+	// before they go to the dead letters
+	MaxAttempts int
+
+	// Backoff is the wait before the first retry, doubling with each
+	// retry up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	mutex     sync.Mutex
+	handlers  map[string]*handler
+	schedules []*scheduledJob
+	running   int
+	jobs      sync.WaitGroup
+	wake      chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// handler is the handler of a job type and the jobs of it running.
+type handler struct {
+	run         Handler
+	concurrency int
+	running     int
+}
+
+// scheduledJob is a job enqueued on a schedule.
+type scheduledJob struct {
+	spec     string
+	schedule *Schedule
+	jobType  string
+	payload  json.RawMessage
+}
+
+// New creates a queue of the jobs in store with the default settings:
+// 10 workers polling every second, 5 attempts per job and a backoff from a
+// second up to an hour.
+func New(store Store) *Queue {
+	return &Queue{
+		Store:        store,
+		Concurrency:  10,
+		PollInterval: time.Second,
+		Lease:        5 * time.Minute,
+		MaxAttempts:  5,
+		Backoff:      time.Second,
+		MaxBackoff:   time.Hour,
+		handlers:     make(map[string]*handler),
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// Handle runs jobs of a type with run, at most concurrency at once, or up
+// to the queue's Concurrency if it is 0.
+func (q *Queue) Handle(jobType string, concurrency int, run Handler) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.handlers[jobType] = &handler{run: run, concurrency: concurrency}
+}
+
+// Enqueue queues a job of a type to run as soon as a worker is free, with
+// payload encoded as JSON. Resolvers enqueue the work that need not hold
+// up their response, such as sending an email.
+func (q *Queue) Enqueue(ctx context.Context, jobType string, payload interface{}) (*Job, error) {
+	return q.EnqueueAt(ctx, jobType, payload, time.Now())
+}
+
+// EnqueueAt queues a job of a type to run at runAt.
+func (q *Queue) EnqueueAt(ctx context.Context, jobType string, payload interface{}, runAt time.Time) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("jobs: encoding %s payload: %v", jobType, err)
+	}
+	job := &Job{Type: jobType, Payload: data, RunAt: runAt}
+	if err := q.EnqueueJob(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// EnqueueJob queues a job as it is given, such as with a Key or its own
+// MaxAttempts. Jobs without a RunAt run as soon as a worker is free.
+func (q *Queue) EnqueueJob(ctx context.Context, job *Job) error {
+	now := time.Now()
+	if job.RunAt.IsZero() {
+		job.RunAt = now
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = q.MaxAttempts
+	}
+	if len(job.Payload) == 0 {
+		job.Payload = json.RawMessage("null")
+	}
+	job.CreatedAt = now
+
+	if err := q.Store.Enqueue(ctx, job); err != nil {
+		return err
+	}
+	q.record(MetricJobs, "jobs_enqueued", "Jobs enqueued", "jobs", job.Type, 1)
+
+	// A due job is picked up without waiting for the next poll
+	if !job.RunAt.After(now) {
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Schedule enqueues a job of a type with payload at the times of a cron
+// spec, as ParseSchedule reads it, while the queue is started. Each run is
+// enqueued ahead with a key of its time, so instances sharing a store each
+// schedule it but enqueue it once.
+func (q *Queue) Schedule(spec, jobType string, payload interface{}) error {
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("jobs: encoding %s payload: %v", jobType, err)
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.schedules = append(q.schedules, &scheduledJob{spec: spec, schedule: schedule, jobType: jobType, payload: data})
+	return nil
+}
+
+// Start starts polling for due jobs and enqueueing scheduled ones, until
+// Stop.
+func (q *Queue) Start() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.stop != nil {
+		return
+	}
+	q.stop = make(chan struct{})
+	q.done = make(chan struct{})
+
+	go q.poll(q.stop, q.done)
+}
+
+// Stop stops polling and waits for the running jobs to finish, or for ctx
+// to be done.
+func (q *Queue) Stop(ctx context.Context) error {
+	q.mutex.Lock()
+	stop, done := q.stop, q.done
+	q.stop = nil
+	q.mutex.Unlock()
+	if stop == nil {
+		return nil
+	}
+
+	close(stop)
+	<-done
+
+	finished := make(chan struct{})
+	go func() {
+		q.jobs.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RunDue runs the jobs due now that the workers have room for and waits
+// for them, returning how many ran. It suits running the queue from a
+// command, such as a cron task, instead of starting it.
+func (q *Queue) RunDue(ctx context.Context) (int, error) {
+	var wg sync.WaitGroup
+	count, err := q.claim(ctx, &wg)
+	wg.Wait()
+	return count, err
+}
+
+// poll claims due jobs every PollInterval, or when a due job is enqueued,
+// and enqueues the runs of the schedules.
+func (q *Queue) poll(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(q.PollInterval)
+	defer ticker.Stop()
+
+	ctx := context.Background()
+	next := q.scheduleRuns(ctx, time.Now(), nil)
+	for {
+		if _, err := q.claim(ctx, nil); err != nil {
+			q.reportError("claiming jobs", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-q.wake:
+		case now := <-ticker.C:
+			next = q.scheduleRuns(ctx, now, next)
+		}
+	}
+}
+
+// scheduleRuns enqueues the next run of each schedule whose last one is
+// due by now, returning when each runs next.
+func (q *Queue) scheduleRuns(ctx context.Context, now time.Time, next map[*scheduledJob]time.Time) map[*scheduledJob]time.Time {
+	q.mutex.Lock()
+	schedules := append([]*scheduledJob(nil), q.schedules...)
+	q.mutex.Unlock()
+
+	if next == nil {
+		next = make(map[*scheduledJob]time.Time)
+	}
+	for _, scheduled := range schedules {
+		if runAt, ok := next[scheduled]; ok && runAt.After(now) {
+			continue
+		}
+		runAt := scheduled.schedule.Next(now)
+		if runAt.IsZero() {
+			continue
+		}
+		next[scheduled] = runAt
+
+		job := &Job{
+			Type:    scheduled.jobType,
+			Payload: scheduled.payload,
+			Key:     "schedule:" + scheduled.jobType + ":" + scheduled.spec + ":" + strconv.FormatInt(runAt.Unix(), 10),
+			RunAt:   runAt,
+		}
+		if err := q.EnqueueJob(ctx, job); err != nil && err != ErrDuplicate {
+			q.reportError("scheduling "+scheduled.jobType, err)
+		}
+	}
+	return next
+}
+
+// claim claims as many due jobs of each type as there are free workers
+// for and starts running them, adding them to wg if it is not nil.
+func (q *Queue) claim(ctx context.Context, wg *sync.WaitGroup) (int, error) {
+	q.mutex.Lock()
+	types := make([]string, 0, len(q.handlers))
+	for jobType := range q.handlers {
+		types = append(types, jobType)
+	}
+	q.mutex.Unlock()
+	sort.Strings(types)
+
+	count := 0
+	for _, jobType := range types {
+		q.mutex.Lock()
+		h := q.handlers[jobType]
+		free := q.Concurrency - q.running
+		if h.concurrency > 0 && h.concurrency-h.running < free {
+			free = h.concurrency - h.running
+		}
+		q.mutex.Unlock()
+		if free <= 0 {
+			continue
+		}
+
+		jobs, err := q.Store.Claim(ctx, jobType, time.Now(), free, q.Lease)
+		if err != nil {
+			return count, err
+		}
+		for _, job := range jobs {
+			q.mutex.Lock()
+			q.running++
+			h.running++
+			q.mutex.Unlock()
+
+			q.jobs.Add(1)
+			if wg != nil {
+				wg.Add(1)
+			}
+			go func(job *Job) {
+				defer q.jobs.Done()
+				if wg != nil {
+					defer wg.Done()
+				}
+				q.run(h, job)
+			}(job)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// run runs a claimed job, then completes, retries or buries it.
+func (q *Queue) run(h *handler, job *Job) {
+	defer func() {
+		q.mutex.Lock()
+		q.running--
+		h.running--
+		q.mutex.Unlock()
+
+		// The freed worker picks up the next due job without waiting
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}()
+
+	start := time.Now()
+	q.record(MetricJobDuration, "job_wait", "Time jobs waited past their run time", "ms", job.Type, float64(start.Sub(job.RunAt))/float64(time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), q.Lease)
+	err := call(ctx, h.run, job)
+	cancel()
+	q.record(MetricJobDuration, "job_duration", "Job run duration", "ms", job.Type, float64(time.Since(start))/float64(time.Millisecond))
+
+	ctx = context.Background()
+	switch {
+	case err == nil:
+		q.record(MetricJobs, "jobs_completed", "Jobs completed", "jobs", job.Type, 1)
+		err = q.Store.Complete(ctx, job)
+	case isPermanent(err) || job.Attempts >= job.MaxAttempts:
+		q.record(MetricJobs, "jobs_dead", "Jobs moved to the dead letters", "jobs", job.Type, 1)
+		err = q.Store.Bury(ctx, job, err.Error())
+	default:
+		q.record(MetricJobs, "jobs_retried", "Jobs retried", "jobs", job.Type, 1)
+		err = q.Store.Retry(ctx, job, time.Now().Add(q.backoff(job.Attempts)), err.Error())
+	}
+	if err != nil {
+		q.reportError("finishing "+job.Type+" job", err)
+	}
+}
+
+// call runs a handler, turning a panic into an error.
+func call(ctx context.Context, run Handler, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("jobs: %s job panicked: %v", job.Type, r)
+		}
+	}()
+	return run(ctx, job)
+}
+
+// backoff is the wait before the retry after attempts.
+func (q *Queue) backoff(attempts int) time.Duration {
+	backoff := q.Backoff
+	for i := 1; i < attempts && backoff < q.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if q.MaxBackoff > 0 && backoff > q.MaxBackoff {
+		backoff = q.MaxBackoff
+	}
+	return backoff
+}
+
+// record records a value of a job metric overall and for the job's type,
+// registering the metrics the first time they are seen.
+func (q *Queue) record(metricType core.MetricType, name, description, unit, jobType string, value float64) {
+	if q.Jetpack == nil {
+		return
+	}
+	for _, metric := range []struct{ name, tag string }{{name, ""}, {name + "@" + jobType, "job:" + jobType}} {
+		q.mutex.Lock()
+		if _, err := q.Jetpack.GetMetric(metric.name); err != nil {
+			tags := []string{"jobs"}
+			if metric.tag != "" {
+				tags = append(tags, metric.tag)
+			}
+			q.Jetpack.RegisterMetric(metricType, metric.name, description, unit, nil, tags)
+		}
+		q.mutex.Unlock()
+
+		q.Jetpack.RecordMetric(metric.name, value)
+	}
+}
+
+// reportError reports a failure of the queue itself as a Jetpack error.
+func (q *Queue) reportError(action string, err error) {
+	if q.Jetpack == nil {
+		return
+	}
+	q.Jetpack.ReportError(core.ErrorReport{Source: "jobs", Component: action, Message: err.Error()})
+}
@@ -0,0 +1,265 @@
+// Package storage keeps background jobs in GoScaleDB, so they survive
+// restarts and every instance of the app works the same queue.
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscale/db"
+	"github.com/davidjeba/goscript/pkg/goscale/jobs"
+)
+
+// identifier matches the schema names GoScaleStore accepts, which it
+// writes into its SQL
+var identifier = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// jobColumns are the columns a job is read from
+const jobColumns = "id, type, payload, key, attempts, max_attempts, run_at, last_error, created_at"
+
+// GoScaleStore keeps queued jobs in a jobs table and the jobs that failed
+// for good in a dead_letters table. Workers claim jobs with SELECT ... FOR
+// UPDATE SKIP LOCKED, so instances never run the same job at once.
+type GoScaleStore struct {
+	DB *db.GoScaleDB
+
+	// Schema names the database schema of the tables
+	Schema string
+}
+
+var _ jobs.Store = (*GoScaleStore)(nil)
+
+// NewGoScaleStore creates a store in database's jobs schema; call Init
+// before using it
+func NewGoScaleStore(database *db.GoScaleDB) *GoScaleStore {
+	return &GoScaleStore{DB: database, Schema: "jobs"}
+}
+
+// Init creates the tables. It can be called on every start.
+func (s *GoScaleStore) Init(ctx context.Context) error {
+	if !identifier.MatchString(s.Schema) {
+		return fmt.Errorf("jobs: invalid schema name %s", s.Schema)
+	}
+
+	statements := []string{
+		fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", s.Schema),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s.jobs (
+			id BIGSERIAL PRIMARY KEY,
+			type TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			key TEXT UNIQUE,
+			attempts INTEGER NOT NULL DEFAULT 0,
+			max_attempts INTEGER NOT NULL,
+			run_at TIMESTAMPTZ NOT NULL,
+			locked_until TIMESTAMPTZ,
+			last_error TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL
+		)`, s.Schema),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS jobs_type_run_at ON %s.jobs (type, run_at)", s.Schema),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s.dead_letters (
+			id BIGINT PRIMARY KEY,
+			type TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			key TEXT,
+			attempts INTEGER NOT NULL,
+			max_attempts INTEGER NOT NULL,
+			run_at TIMESTAMPTZ NOT NULL,
+			last_error TEXT NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL,
+			failed_at TIMESTAMPTZ NOT NULL
+		)`, s.Schema),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS dead_letters_failed_at ON %s.dead_letters (failed_at DESC)", s.Schema),
+	}
+	for _, statement := range statements {
+		if _, err := s.DB.Execute(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Enqueue inserts a job, or returns jobs.ErrDuplicate if its key is taken
+func (s *GoScaleStore) Enqueue(ctx context.Context, job *jobs.Job) error {
+	var key interface{}
+	if job.Key != "" {
+		key = job.Key
+	}
+	query := fmt.Sprintf(`INSERT INTO %s.jobs (type, payload, key, max_attempts, run_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6) ON CONFLICT (key) DO NOTHING RETURNING id`, s.Schema)
+
+	inserted := false
+	err := s.DB.QueryEach(ctx, query, func(row map[string]interface{}) error {
+		id, err := integer(row, "id")
+		job.ID = id
+		inserted = true
+		return err
+	}, job.Type, string(job.Payload), key, job.MaxAttempts, job.RunAt, job.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if !inserted {
+		return jobs.ErrDuplicate
+	}
+	return nil
+}
+
+// Claim leases the due jobs of a type, skipping those other workers are
+// claiming
+func (s *GoScaleStore) Claim(ctx context.Context, jobType string, now time.Time, limit int, lease time.Duration) ([]*jobs.Job, error) {
+	query := fmt.Sprintf(`UPDATE %[1]s.jobs SET attempts = attempts + 1, locked_until = $3
+		WHERE id IN (
+			SELECT id FROM %[1]s.jobs
+			WHERE type = $1 AND run_at <= $2 AND (locked_until IS NULL OR locked_until <= $2)
+			ORDER BY run_at, id LIMIT $4
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING %[2]s`, s.Schema, jobColumns)
+
+	// QueryEach, unlike Query, does not cache the rows
+	claimed := []*jobs.Job{}
+	err := s.DB.QueryEach(ctx, query, func(row map[string]interface{}) error {
+		job, err := readJob(row)
+		if err != nil {
+			return err
+		}
+		claimed = append(claimed, job)
+		return nil
+	}, jobType, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+// Complete deletes a job
+func (s *GoScaleStore) Complete(ctx context.Context, job *jobs.Job) error {
+	_, err := s.DB.Execute(ctx, fmt.Sprintf("DELETE FROM %s.jobs WHERE id = $1", s.Schema), job.ID)
+	return err
+}
+
+// Retry releases a job to run again at runAt
+func (s *GoScaleStore) Retry(ctx context.Context, job *jobs.Job, runAt time.Time, reason string) error {
+	query := fmt.Sprintf("UPDATE %s.jobs SET run_at = $2, locked_until = NULL, last_error = $3 WHERE id = $1", s.Schema)
+	_, err := s.DB.Execute(ctx, query, job.ID, runAt, reason)
+	return err
+}
+
+// Bury moves a job to the dead letters in one transaction
+func (s *GoScaleStore) Bury(ctx context.Context, job *jobs.Job, reason string) error {
+	return s.DB.Transaction(ctx, func(tx *sql.Tx) error {
+		insert := fmt.Sprintf(`INSERT INTO %[1]s.dead_letters (%[2]s, failed_at)
+			SELECT id, type, payload, key, attempts, max_attempts, run_at, $2, created_at, $3 FROM %[1]s.jobs WHERE id = $1`, s.Schema, jobColumns)
+		if _, err := tx.ExecContext(ctx, insert, job.ID, reason, time.Now()); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s.jobs WHERE id = $1", s.Schema), job.ID)
+		return err
+	})
+}
+
+// DeadLetters returns the latest dead letters, latest first
+func (s *GoScaleStore) DeadLetters(ctx context.Context, limit int) ([]*jobs.Job, error) {
+	query := fmt.Sprintf("SELECT %s, failed_at FROM %s.dead_letters ORDER BY failed_at DESC LIMIT $1", jobColumns, s.Schema)
+
+	letters := []*jobs.Job{}
+	err := s.DB.QueryEach(ctx, query, func(row map[string]interface{}) error {
+		job, err := readJob(row)
+		if err != nil {
+			return err
+		}
+		if job.FailedAt, err = timestamp(row, "failed_at"); err != nil {
+			return err
+		}
+		letters = append(letters, job)
+		return nil
+	}, limit)
+	if err != nil {
+		return nil, err
+	}
+	return letters, nil
+}
+
+// readJob reads a row of the jobColumns
+func readJob(row map[string]interface{}) (*jobs.Job, error) {
+	job := &jobs.Job{}
+	var err error
+	if job.ID, err = integer(row, "id"); err != nil {
+		return nil, err
+	}
+	attempts, err := integer(row, "attempts")
+	if err != nil {
+		return nil, err
+	}
+	maxAttempts, err := integer(row, "max_attempts")
+	if err != nil {
+		return nil, err
+	}
+	job.Attempts, job.MaxAttempts = int(attempts), int(maxAttempts)
+
+	if job.Type, err = text(row, "type"); err != nil {
+		return nil, err
+	}
+	payload, err := text(row, "payload")
+	if err != nil {
+		return nil, err
+	}
+	job.Payload = json.RawMessage(payload)
+	if row["key"] != nil {
+		if job.Key, err = text(row, "key"); err != nil {
+			return nil, err
+		}
+	}
+	if job.LastError, err = text(row, "last_error"); err != nil {
+		return nil, err
+	}
+	if job.RunAt, err = timestamp(row, "run_at"); err != nil {
+		return nil, err
+	}
+	if job.CreatedAt, err = timestamp(row, "created_at"); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// integer reads an integer column
+func integer(row map[string]interface{}, column string) (int64, error) {
+	switch value := row[column].(type) {
+	case int64:
+		return value, nil
+	case int32:
+		return int64(value), nil
+	case float64:
+		return int64(value), nil
+	}
+	return 0, fmt.Errorf("jobs: unexpected %s %T", column, row[column])
+}
+
+// text reads a text column, which drivers return as a string or bytes
+func text(row map[string]interface{}, column string) (string, error) {
+	switch value := row[column].(type) {
+	case string:
+		return value, nil
+	case []byte:
+		return string(value), nil
+	}
+	return "", fmt.Errorf("jobs: unexpected %s %T", column, row[column])
+}
+
+// timestamp reads a timestamptz column
+func timestamp(row map[string]interface{}, column string) (time.Time, error) {
+	switch value := row[column].(type) {
+	case time.Time:
+		return value, nil
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("jobs: reading %s: %v", column, err)
+		}
+		return parsed, nil
+	}
+	return time.Time{}, fmt.Errorf("jobs: unexpected %s %T", column, row[column])
+}
@@ -0,0 +1,279 @@
+// Package schema checks GraphQL schemas parsed by the sdl package: Diff
+// classifies the changes between two versions of a schema by whether they
+// break clients, and Lint checks a schema's naming conventions. gopm api:test
+// runs both, so CI can fail on a breaking change.
+package schema
+
+import (
+	"fmt"
+
+	"github.com/davidjeba/goscript/pkg/goscale/sdl"
+)
+
+// Severity is how a change affects existing clients
+type Severity string
+
+const (
+	// Breaking changes fail queries that worked before, such as removing a
+	// field or adding a required argument
+	Breaking Severity = "breaking"
+
+	// Dangerous changes keep queries valid but may change what clients get,
+	// such as adding an enum value a client does not handle
+	Dangerous Severity = "dangerous"
+
+	// Safe changes cannot affect existing clients, such as adding a field
+	Safe Severity = "safe"
+)
+
+// Change is a difference between two versions of a schema
+type Change struct {
+	Severity Severity `json:"severity"`
+
+	// Path is what changed, such as "User", "User.email" or
+	// "Query.posts(limit)"
+	Path string `json:"path"`
+
+	Message string `json:"message"`
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s %s: %s", c.Severity, c.Path, c.Message)
+}
+
+// IsBreaking reports whether any of the changes is breaking
+func IsBreaking(changes []Change) bool {
+	for _, change := range changes {
+		if change.Severity == Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff returns the changes from old to new, in the order of old's
+// definitions followed by those new adds. Field types are compared the way
+// clients use them: an output field may become non-null, but not nullable
+// or another type, and an input field or argument may become nullable but
+// not required.
+func Diff(old, new *sdl.Document) []Change {
+	d := &differ{}
+
+	for _, operation := range []string{"query", "mutation", "subscription"} {
+		before, after := old.Operations[operation], new.Operations[operation]
+		switch {
+		case before != "" && after == "":
+			d.add(Breaking, operation, "%s operations were removed", operation)
+		case before != "" && after != before:
+			d.add(Breaking, operation, "%s type changed from %s to %s", operation, before, after)
+		}
+	}
+
+	for _, before := range old.Definitions {
+		after := new.Definition(before.Name)
+		switch {
+		case after == nil:
+			d.add(Breaking, before.Name, "%s %s was removed", before.Kind, before.Name)
+		case after.Kind != before.Kind:
+			d.add(Breaking, before.Name, "%s changed from %s to %s", before.Name, before.Kind, after.Kind)
+		default:
+			d.definition(before, after)
+		}
+	}
+	for _, after := range new.Definitions {
+		if old.Definition(after.Name) == nil {
+			d.add(Safe, after.Name, "%s %s was added", after.Kind, after.Name)
+		}
+	}
+
+	return d.changes
+}
+
+// differ collects the changes Diff finds
+type differ struct {
+	changes []Change
+}
+
+func (d *differ) add(severity Severity, path, format string, args ...interface{}) {
+	d.changes = append(d.changes, Change{Severity: severity, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// definition compares two versions of a definition of the same kind
+func (d *differ) definition(before, after *sdl.Definition) {
+	if before.Description != after.Description {
+		d.add(Safe, before.Name, "description changed")
+	}
+
+	switch before.Kind {
+	case sdl.KindEnum:
+		d.members(before.Name, "value", before.Values, after.Values, Breaking, Dangerous)
+	case sdl.KindUnion:
+		d.members(before.Name, "member", before.Types, after.Types, Breaking, Dangerous)
+	case sdl.KindType, sdl.KindInterface:
+		d.members(before.Name, "interface", before.Interfaces, after.Interfaces, Breaking, Dangerous)
+		d.fields(before, after, false)
+	case sdl.KindInput:
+		d.fields(before, after, true)
+	}
+}
+
+// members compares the enum values, union members or interfaces of a
+// definition, with the severities of removing and adding one
+func (d *differ) members(name, noun string, before, after []string, removed, added Severity) {
+	for _, member := range before {
+		if !contains(after, member) {
+			d.add(removed, name+"."+member, "%s %s was removed", noun, member)
+		}
+	}
+	for _, member := range after {
+		if !contains(before, member) {
+			d.add(added, name+"."+member, "%s %s was added", noun, member)
+		}
+	}
+}
+
+// fields compares the fields of a type, interface or input
+func (d *differ) fields(before, after *sdl.Definition, input bool) {
+	for _, field := range before.Fields {
+		path := before.Name + "." + field.Name
+		next := after.Field(field.Name)
+		if next == nil {
+			d.add(Breaking, path, "field %s was removed", field.Name)
+			continue
+		}
+
+		if input {
+			d.input(path, field, next)
+		} else if !outputCompatible(field.Type, next.Type) {
+			d.add(Breaking, path, "type changed from %s to %s", field.Type, next.Type)
+		} else if field.Type != next.Type {
+			d.add(Safe, path, "type changed from %s to %s", field.Type, next.Type)
+		}
+		if field.Description != next.Description {
+			d.add(Safe, path, "description changed")
+		}
+		if field.Directive("deprecated") == nil && next.Directive("deprecated") != nil {
+			d.add(Safe, path, "field %s was deprecated", field.Name)
+		}
+
+		for _, arg := range field.Args {
+			argPath := path + "(" + arg.Name + ")"
+			if nextArg := argument(next, arg.Name); nextArg == nil {
+				d.add(Breaking, argPath, "argument %s was removed", arg.Name)
+			} else {
+				d.input(argPath, arg, nextArg)
+			}
+		}
+		for _, arg := range next.Args {
+			if argument(field, arg.Name) == nil {
+				d.added(path+"("+arg.Name+")", "argument", arg)
+			}
+		}
+	}
+
+	for _, field := range after.Fields {
+		if before.Field(field.Name) != nil {
+			continue
+		}
+		if input {
+			d.added(before.Name+"."+field.Name, "input field", field)
+		} else {
+			d.add(Safe, before.Name+"."+field.Name, "field %s was added", field.Name)
+		}
+	}
+}
+
+// input compares an argument or input field, which clients send
+func (d *differ) input(path string, before, after *sdl.Field) {
+	switch {
+	case !inputCompatible(before.Type, after.Type):
+		d.add(Breaking, path, "type changed from %s to %s", before.Type, after.Type)
+	case before.Type != after.Type:
+		d.add(Safe, path, "type changed from %s to %s", before.Type, after.Type)
+	}
+	if before.Default != after.Default {
+		d.add(Dangerous, path, "default changed from %s to %s", literal(before.Default), literal(after.Default))
+	}
+}
+
+// added reports an argument or input field that was added: breaking when
+// clients must now send it
+func (d *differ) added(path, noun string, field *sdl.Field) {
+	if sdl.IsNonNull(field.Type) && field.Default == "" {
+		d.add(Breaking, path, "required %s %s was added", noun, field.Name)
+		return
+	}
+	d.add(Safe, path, "%s %s was added", noun, field.Name)
+}
+
+// outputCompatible reports whether clients reading a field of type before
+// can read one of type after: the same type, or one that is non-null where
+// before was nullable
+func outputCompatible(before, after string) bool {
+	if sdl.IsNonNull(after) {
+		return outputCompatible(nullable(before), nullable(after))
+	}
+	if sdl.IsNonNull(before) {
+		return false
+	}
+	if sdl.IsList(before) || sdl.IsList(after) {
+		return sdl.IsList(before) && sdl.IsList(after) && outputCompatible(element(before), element(after))
+	}
+	return before == after
+}
+
+// inputCompatible reports whether values clients sent for type before are
+// still valid for type after: the same type, or one that is nullable where
+// before was non-null
+func inputCompatible(before, after string) bool {
+	if sdl.IsNonNull(before) {
+		return inputCompatible(nullable(before), nullable(after))
+	}
+	if sdl.IsNonNull(after) {
+		return false
+	}
+	if sdl.IsList(before) || sdl.IsList(after) {
+		return sdl.IsList(before) && sdl.IsList(after) && inputCompatible(element(before), element(after))
+	}
+	return before == after
+}
+
+// nullable strips a type's non-null marker
+func nullable(typeName string) string {
+	if sdl.IsNonNull(typeName) {
+		return typeName[:len(typeName)-1]
+	}
+	return typeName
+}
+
+// element returns the element type of a nullable list type
+func element(typeName string) string {
+	return typeName[1 : len(typeName)-1]
+}
+
+// argument returns a field's argument named name, or nil
+func argument(field *sdl.Field, name string) *sdl.Field {
+	for _, arg := range field.Args {
+		if arg.Name == name {
+			return arg
+		}
+	}
+	return nil
+}
+
+// literal shows a default value's source text, or "none"
+func literal(text string) string {
+	if text == "" {
+		return "none"
+	}
+	return text
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/davidjeba/goscript/pkg/goscale/sdl"
+)
+
+func parse(t *testing.T, source string) *sdl.Document {
+	t.Helper()
+	doc, err := sdl.Parse(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+const oldSchema = `
+type User {
+  id: ID!
+  name: String!
+  email: String
+  nickname: String
+  role: Role
+  posts(limit: Int = 10, tag: String!): [Post!]!
+}
+type Post { id: ID!, title: String! }
+enum Role { ADMIN EDITOR }
+union SearchResult = User | Post
+input PostInput { title: String!, body: String }
+type Legacy { id: ID! }
+type Query { user(id: ID!): User }
+`
+
+const newSchema = `
+type User {
+  id: ID!
+  name: String
+  email: String!
+  role: Role
+  posts(limit: Int = 20, tag: String, after: ID!): [Post!]!
+  avatar: String
+}
+type Post { id: ID!, title: String! }
+enum Role { ADMIN EDITOR VIEWER }
+union SearchResult = User
+input PostInput { title: String, body: String, draft: Boolean!, tags: [String!] }
+type Query { user(id: ID!): User, search(text: String!): [SearchResult!]! }
+type Comment { id: ID! }
+`
+
+func TestDiffClassifiesChanges(t *testing.T) {
+	changes := Diff(parse(t, oldSchema), parse(t, newSchema))
+
+	want := map[string]Severity{
+		"User.name":         Breaking,  // nullable now
+		"User.email":        Safe,      // non-null now
+		"User.nickname":     Breaking,  // removed
+		"User.posts(limit)": Dangerous, // default changed
+		"User.posts(tag)":   Safe,      // optional now
+		"User.posts(after)": Breaking,  // required argument added
+		"User.avatar":       Safe,
+		"Role.VIEWER":       Dangerous,
+		"SearchResult.Post": Breaking,
+		"PostInput.title":   Safe,
+		"PostInput.draft":   Breaking, // required input field added
+		"PostInput.tags":    Safe,
+		"Legacy":            Breaking,
+		"Query.search":      Safe,
+		"Comment":           Safe,
+	}
+	got := make(map[string]Severity)
+	for _, change := range changes {
+		if _, seen := got[change.Path]; seen {
+			t.Errorf("expected one change for %s, got another: %s", change.Path, change)
+		}
+		got[change.Path] = change.Severity
+	}
+	for path, severity := range want {
+		if got[path] != severity {
+			t.Errorf("expected %s to be %s, got %q", path, severity, got[path])
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("expected %d changes, got %v", len(want), changes)
+	}
+	if !IsBreaking(changes) {
+		t.Error("expected the changes to be breaking")
+	}
+	if changes[0].Path != "User.name" || changes[len(changes)-1].Path != "Comment" {
+		t.Errorf("expected changes in schema order, got %v", changes)
+	}
+}
+
+func TestDiffComparesTypesAsClientsUseThem(t *testing.T) {
+	for _, test := range []struct {
+		before, after string
+		output, input bool
+	}{
+		{"String", "String", true, true},
+		{"String", "String!", true, false},
+		{"String!", "String", false, true},
+		{"[String]", "[String!]!", true, false},
+		{"[String!]!", "[String]", false, true},
+		{"String", "[String]", false, false},
+		{"Int", "Float", false, false},
+	} {
+		if got := outputCompatible(test.before, test.after); got != test.output {
+			t.Errorf("output %s to %s: expected %v", test.before, test.after, test.output)
+		}
+		if got := inputCompatible(test.before, test.after); got != test.input {
+			t.Errorf("input %s to %s: expected %v", test.before, test.after, test.input)
+		}
+	}
+}
+
+func TestDiffOfTheSameSchema(t *testing.T) {
+	if changes := Diff(parse(t, oldSchema), parse(t, oldSchema)); len(changes) != 0 {
+		t.Fatalf("expected no changes, got %v", changes)
+	}
+
+	changes := Diff(parse(t, oldSchema), parse(t, `
+		type User { id: ID! }
+		schema { query: Root }
+		type Root { user: User }
+	`))
+	if changes[0].Path != "query" || changes[0].Severity != Breaking {
+		t.Fatalf("expected the query type change first, got %v", changes)
+	}
+}
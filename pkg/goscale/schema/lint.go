@@ -0,0 +1,119 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/davidjeba/goscript/pkg/goscale/sdl"
+)
+
+// Lint rules
+const (
+	// RuleTypeName: types, inputs, interfaces, enums, unions and scalars
+	// are PascalCase, such as BlogPost
+	RuleTypeName = "type-name"
+
+	// RuleFieldName: fields and arguments are camelCase, such as createdAt
+	RuleFieldName = "field-name"
+
+	// RuleEnumValue: enum values are UPPER_SNAKE_CASE, such as IN_REVIEW
+	RuleEnumValue = "enum-value"
+
+	// RuleInputName: input type names end in Input, such as PostInput
+	RuleInputName = "input-name"
+)
+
+// Problem is a place where a schema breaks a naming convention
+type Problem struct {
+	Rule    string `json:"rule"`
+	Path    string `json:"path"`
+	Message string `json:"message"`
+
+	// Line and Column locate the definition or field in the source
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s (%s)", p.Line, p.Column, p.Path, p.Message, p.Rule)
+}
+
+// Lint checks a schema's names follow the GraphQL conventions, returning
+// the problems in source order
+func Lint(doc *sdl.Document) []Problem {
+	var problems []Problem
+	report := func(rule, path string, line, column int, format string, args ...interface{}) {
+		problems = append(problems, Problem{Rule: rule, Path: path, Message: fmt.Sprintf(format, args...), Line: line, Column: column})
+	}
+
+	for _, definition := range doc.Definitions {
+		name := definition.Name
+		if !isPascalCase(name) {
+			report(RuleTypeName, name, definition.Line, definition.Column, "%s %s should be PascalCase", definition.Kind, name)
+		}
+		if definition.Kind == sdl.KindInput && !strings.HasSuffix(name, "Input") {
+			report(RuleInputName, name, definition.Line, definition.Column, "input %s should end in Input", name)
+		}
+		for _, value := range definition.Values {
+			if !isUpperSnakeCase(value) {
+				report(RuleEnumValue, name+"."+value, definition.Line, definition.Column, "enum value %s should be UPPER_SNAKE_CASE", value)
+			}
+		}
+
+		for _, field := range definition.Fields {
+			path := name + "." + field.Name
+			if !isCamelCase(field.Name) {
+				report(RuleFieldName, path, field.Line, field.Column, "field %s should be camelCase", field.Name)
+			}
+			for _, arg := range field.Args {
+				if !isCamelCase(arg.Name) {
+					report(RuleFieldName, path+"("+arg.Name+")", arg.Line, arg.Column, "argument %s should be camelCase", arg.Name)
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// isPascalCase reports whether a name is letters and digits starting with
+// an upper case letter
+func isPascalCase(name string) bool {
+	return startsWith(name, unicode.IsUpper) && alphanumeric(name)
+}
+
+// isCamelCase reports whether a name is letters and digits starting with a
+// lower case letter
+func isCamelCase(name string) bool {
+	return startsWith(name, unicode.IsLower) && alphanumeric(name)
+}
+
+// isUpperSnakeCase reports whether a name is upper case letters, digits and
+// single underscores between words
+func isUpperSnakeCase(name string) bool {
+	if !startsWith(name, unicode.IsUpper) || strings.HasSuffix(name, "_") || strings.Contains(name, "__") {
+		return false
+	}
+	for _, r := range name {
+		if !unicode.IsUpper(r) && !unicode.IsDigit(r) && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func startsWith(name string, is func(rune) bool) bool {
+	for _, r := range name {
+		return is(r)
+	}
+	return false
+}
+
+func alphanumeric(name string) bool {
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package schema
+
+import (
+	"testing"
+)
+
+func TestLintNamingConventions(t *testing.T) {
+	problems := Lint(parse(t, `
+type blog_post {
+  id: ID!
+  Title: String!
+  created_at: String
+  comments(max_count: Int): [String]
+}
+enum Status { DRAFT in_review PUBLISHED_ }
+input PostData { title: String! }
+input CommentInput { body: String! }
+type Query { post(id: ID!): blog_post }
+`))
+
+	want := []struct {
+		rule, path string
+		line       int
+	}{
+		{RuleTypeName, "blog_post", 2},
+		{RuleFieldName, "blog_post.Title", 4},
+		{RuleFieldName, "blog_post.created_at", 5},
+		{RuleFieldName, "blog_post.comments(max_count)", 6},
+		{RuleEnumValue, "Status.in_review", 8},
+		{RuleEnumValue, "Status.PUBLISHED_", 8},
+		{RuleInputName, "PostData", 9},
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("expected %d problems, got %v", len(want), problems)
+	}
+	for i, w := range want {
+		if p := problems[i]; p.Rule != w.rule || p.Path != w.path || p.Line != w.line {
+			t.Errorf("expected %s at %s:%d, got %s", w.rule, w.path, w.line, p)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package sdl
+
+import (
+	"fmt"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenName
+	tokenPunct
+	tokenString
+	tokenNumber
+)
+
+// token is a lexical token and where it starts.
+type token struct {
+	kind   tokenKind
+	text   string
+	line   int
+	column int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of file"
+	case tokenString:
+		return "string"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+// lexer splits a schema into tokens, skipping whitespace, commas and
+// comments.
+type lexer struct {
+	source []rune
+	pos    int
+	line   int
+	column int
+	peeked *token
+}
+
+func newLexer(source string) *lexer {
+	return &lexer{source: []rune(source), line: 1, column: 1}
+}
+
+// peek returns the next token without reading it.
+func (l *lexer) peek() (token, error) {
+	if l.peeked == nil {
+		t, err := l.scan()
+		if err != nil {
+			return t, err
+		}
+		l.peeked = &t
+	}
+	return *l.peeked, nil
+}
+
+// next reads the next token.
+func (l *lexer) next() (token, error) {
+	if l.peeked != nil {
+		t := *l.peeked
+		l.peeked = nil
+		return t, nil
+	}
+	return l.scan()
+}
+
+func (l *lexer) advance() rune {
+	r := l.source[l.pos]
+	l.pos++
+	if r == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
+	}
+	return r
+}
+
+func (l *lexer) startsWith(prefix string) bool {
+	end := l.pos + len(prefix)
+	if end > len(l.source) {
+		end = len(l.source)
+	}
+	return string(l.source[l.pos:end]) == prefix
+}
+
+func (l *lexer) scan() (token, error) {
+	// Skip whitespace, commas and comments
+	for l.pos < len(l.source) {
+		r := l.source[l.pos]
+		if r == '#' {
+			for l.pos < len(l.source) && l.source[l.pos] != '\n' {
+				l.advance()
+			}
+			continue
+		}
+		if r != ' ' && r != '\t' && r != '\n' && r != '\r' && r != ',' && r != '\ufeff' {
+			break
+		}
+		l.advance()
+	}
+
+	t := token{line: l.line, column: l.column}
+	if l.pos >= len(l.source) {
+		return t, nil
+	}
+
+	r := l.source[l.pos]
+	switch {
+	case r == '_' || isLetter(r):
+		start := l.pos
+		for l.pos < len(l.source) && (l.source[l.pos] == '_' || isLetter(l.source[l.pos]) || isDigit(l.source[l.pos])) {
+			l.advance()
+		}
+		t.kind, t.text = tokenName, string(l.source[start:l.pos])
+	case r == '-' || isDigit(r):
+		start := l.pos
+		l.advance()
+		for l.pos < len(l.source) && strings.ContainsRune("0123456789.eE+-", l.source[l.pos]) {
+			l.advance()
+		}
+		t.kind, t.text = tokenNumber, string(l.source[start:l.pos])
+	case l.startsWith(`"""`):
+		text, err := l.blockString()
+		if err != nil {
+			return t, err
+		}
+		t.kind, t.text = tokenString, text
+	case r == '"':
+		text, err := l.quotedString()
+		if err != nil {
+			return t, err
+		}
+		t.kind, t.text = tokenString, text
+	case l.startsWith("..."):
+		l.advance()
+		l.advance()
+		l.advance()
+		t.kind, t.text = tokenPunct, "..."
+	case strings.ContainsRune("!$&():=@[]{}|", r):
+		l.advance()
+		t.kind, t.text = tokenPunct, string(r)
+	default:
+		return t, &Error{Line: t.line, Column: t.column, Message: fmt.Sprintf("unexpected character %q", r)}
+	}
+	return t, nil
+}
+
+// quotedString reads a "..." string with its escapes.
+func (l *lexer) quotedString() (string, error) {
+	line, column := l.line, l.column
+	l.advance()
+
+	var b strings.Builder
+	for l.pos < len(l.source) {
+		r := l.advance()
+		switch r {
+		case '"':
+			return b.String(), nil
+		case '\n':
+			return "", &Error{Line: line, Column: column, Message: "unterminated string"}
+		case '\\':
+			if l.pos >= len(l.source) {
+				break
+			}
+			escaped := l.advance()
+			switch escaped {
+			case 'n':
+				b.WriteRune('\n')
+			case 't':
+				b.WriteRune('\t')
+			case 'r':
+				b.WriteRune('\r')
+			case 'b':
+				b.WriteRune('\b')
+			case 'f':
+				b.WriteRune('\f')
+			case 'u':
+				if l.pos+4 > len(l.source) {
+					return "", &Error{Line: l.line, Column: l.column, Message: "invalid unicode escape"}
+				}
+				var code rune
+				if _, err := fmt.Sscanf(string(l.source[l.pos:l.pos+4]), "%04x", &code); err != nil {
+					return "", &Error{Line: l.line, Column: l.column, Message: "invalid unicode escape"}
+				}
+				for i := 0; i < 4; i++ {
+					l.advance()
+				}
+				b.WriteRune(code)
+			default:
+				b.WriteRune(escaped)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return "", &Error{Line: line, Column: column, Message: "unterminated string"}
+}
+
+// blockString reads a """...""" string, removing the indentation its lines
+// share and its blank first and last lines.
+func (l *lexer) blockString() (string, error) {
+	line, column := l.line, l.column
+	for i := 0; i < 3; i++ {
+		l.advance()
+	}
+
+	var b strings.Builder
+	for l.pos < len(l.source) {
+		if l.startsWith(`\"""`) {
+			l.advance()
+			b.WriteString(`"""`)
+			l.advance()
+			l.advance()
+			l.advance()
+			continue
+		}
+		if l.startsWith(`"""`) {
+			l.advance()
+			l.advance()
+			l.advance()
+			return dedent(b.String()), nil
+		}
+		b.WriteRune(l.advance())
+	}
+	return "", &Error{Line: line, Column: column, Message: "unterminated block string"}
+}
+
+// dedent removes the common indentation of a block string's lines after
+// the first, and leading and trailing blank lines.
+func dedent(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i := 1; i < len(lines); i++ {
+		if len(lines[i]) >= indent && indent > 0 {
+			lines[i] = lines[i][indent:]
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isLetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
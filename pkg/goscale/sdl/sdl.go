@@ -0,0 +1,634 @@
+// Package sdl parses GraphQL schema definition language, such as a
+// schema.graphql file, into definitions that api.ParseSchema turns into a
+// Schema and gopm scaffolds projects from. It has no dependencies, so tools
+// can read schemas without a database driver.
+package sdl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Definition kinds
+const (
+	KindType      = "type"
+	KindInput     = "input"
+	KindInterface = "interface"
+	KindEnum      = "enum"
+	KindScalar    = "scalar"
+	KindUnion     = "union"
+)
+
+// Scalars are the built-in scalar types, with the DateTime, Time, BigInt
+// and JSON scalars GoScaleAPI adds.
+var Scalars = map[string]bool{
+	"ID":       true,
+	"String":   true,
+	"Int":      true,
+	"BigInt":   true,
+	"Float":    true,
+	"Boolean":  true,
+	"Time":     true,
+	"DateTime": true,
+	"JSON":     true,
+}
+
+// Document is a parsed schema.
+type Document struct {
+	// Definitions in the order they appear
+	Definitions []*Definition
+
+	// Operations maps "query", "mutation" and "subscription" to their root
+	// types, from the schema block or else the types named Query, Mutation
+	// and Subscription.
+	Operations map[string]string
+}
+
+// Definition is a type, input, interface, enum, scalar or union definition.
+type Definition struct {
+	Kind        string
+	Name        string
+	Description string
+
+	// Interfaces a type implements
+	Interfaces []string
+
+	// Fields of a type, input or interface
+	Fields []*Field
+
+	// Values of an enum
+	Values []string
+
+	// Types of a union
+	Types []string
+
+	Directives []*Directive
+
+	// Line and Column locate the definition in the source
+	Line   int
+	Column int
+}
+
+// Field is a field of a type, or an argument of a field.
+type Field struct {
+	Name        string
+	Description string
+
+	// Type in schema notation, such as "String!" or "[Post!]"
+	Type string
+
+	// Args of a field
+	Args []*Field
+
+	// Default is the source text of an argument's default value
+	Default string
+
+	Directives []*Directive
+
+	// Line and Column locate the field in the source
+	Line   int
+	Column int
+}
+
+// Directive is a directive applied to a definition or field, such as
+// @index(unique: true). Argument values are their source text, with
+// strings unquoted.
+type Directive struct {
+	Name string
+	Args map[string]string
+}
+
+// Error is a syntax error at a position of the source.
+type Error struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Message)
+}
+
+// Definition returns the definition named name, or nil.
+func (d *Document) Definition(name string) *Definition {
+	for _, definition := range d.Definitions {
+		if definition.Name == name {
+			return definition
+		}
+	}
+	return nil
+}
+
+// IsOperationType reports whether name is the query, mutation or
+// subscription root type.
+func (d *Document) IsOperationType(name string) bool {
+	for _, root := range d.Operations {
+		if root == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Field returns the field named name, or nil.
+func (d *Definition) Field(name string) *Field {
+	for _, field := range d.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// Directive returns the directive named name, or nil.
+func (d *Definition) Directive(name string) *Directive {
+	return findDirective(d.Directives, name)
+}
+
+// Directive returns the directive named name, or nil.
+func (f *Field) Directive(name string) *Directive {
+	return findDirective(f.Directives, name)
+}
+
+func findDirective(directives []*Directive, name string) *Directive {
+	for _, directive := range directives {
+		if directive.Name == name {
+			return directive
+		}
+	}
+	return nil
+}
+
+// NamedType returns the type a type refers to without list and non-null
+// markers, such as "Post" for "[Post!]!".
+func NamedType(typeName string) string {
+	return strings.Trim(typeName, "[]!")
+}
+
+// IsList reports whether a type is a list.
+func IsList(typeName string) bool {
+	return strings.HasPrefix(typeName, "[")
+}
+
+// IsNonNull reports whether a type is non-null.
+func IsNonNull(typeName string) bool {
+	return strings.HasSuffix(typeName, "!")
+}
+
+// Parse parses a schema. Operation types default to Query, Mutation and
+// Subscription when the schema has no schema block, and references to
+// undefined types are errors.
+func Parse(source string) (*Document, error) {
+	p := &parser{lexer: newLexer(source)}
+	doc := &Document{Operations: make(map[string]string)}
+	if err := p.parseDocument(doc); err != nil {
+		return nil, err
+	}
+
+	if len(doc.Operations) == 0 {
+		for operation, name := range map[string]string{"query": "Query", "mutation": "Mutation", "subscription": "Subscription"} {
+			if definition := doc.Definition(name); definition != nil && definition.Kind == KindType {
+				doc.Operations[operation] = name
+			}
+		}
+	}
+	if err := doc.check(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// check checks the names a document refers to are defined.
+func (d *Document) check() error {
+	defined := func(name string) bool {
+		return Scalars[name] || d.Definition(name) != nil
+	}
+	seen := make(map[string]bool)
+	for _, definition := range d.Definitions {
+		if seen[definition.Name] || Scalars[definition.Name] {
+			return &Error{Line: definition.Line, Column: definition.Column, Message: fmt.Sprintf("%s is defined twice", definition.Name)}
+		}
+		seen[definition.Name] = true
+
+		for _, name := range append(definition.Interfaces, definition.Types...) {
+			if !defined(name) {
+				return &Error{Line: definition.Line, Column: definition.Column, Message: fmt.Sprintf("%s refers to undefined type %s", definition.Name, name)}
+			}
+		}
+		for _, field := range definition.Fields {
+			for _, f := range append([]*Field{field}, field.Args...) {
+				if !defined(NamedType(f.Type)) {
+					return &Error{Line: f.Line, Column: f.Column, Message: fmt.Sprintf("%s.%s has undefined type %s", definition.Name, field.Name, NamedType(f.Type))}
+				}
+			}
+		}
+	}
+	for operation, name := range d.Operations {
+		if definition := d.Definition(name); definition == nil || definition.Kind != KindType {
+			return &Error{Line: 1, Column: 1, Message: fmt.Sprintf("%s type %s is not defined", operation, name)}
+		}
+	}
+	return nil
+}
+
+// parser parses the tokens of a lexer.
+type parser struct {
+	lexer *lexer
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return &Error{Line: t.line, Column: t.column, Message: fmt.Sprintf(format, args...)}
+}
+
+// expect reads a punctuator.
+func (p *parser) expect(punct string) error {
+	t, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	if t.kind != tokenPunct || t.text != punct {
+		return p.errorf(t, "expected %q, found %s", punct, t)
+	}
+	return nil
+}
+
+// name reads a name.
+func (p *parser) name() (token, error) {
+	t, err := p.lexer.next()
+	if err != nil {
+		return t, err
+	}
+	if t.kind != tokenName {
+		return t, p.errorf(t, "expected a name, found %s", t)
+	}
+	return t, nil
+}
+
+// acceptPunct reports whether the next token is the punctuator punct,
+// reading it if so.
+func (p *parser) acceptPunct(punct string) (bool, error) {
+	t, err := p.lexer.peek()
+	if err != nil {
+		return false, err
+	}
+	if t.kind == tokenPunct && t.text == punct {
+		p.lexer.next()
+		return true, nil
+	}
+	return false, nil
+}
+
+// takeDescription reads an optional description string.
+func (p *parser) takeDescription() (string, error) {
+	t, err := p.lexer.peek()
+	if err != nil {
+		return "", err
+	}
+	if t.kind != tokenString {
+		return "", nil
+	}
+	p.lexer.next()
+	return t.text, nil
+}
+
+func (p *parser) parseDocument(doc *Document) error {
+	for {
+		description, err := p.takeDescription()
+		if err != nil {
+			return err
+		}
+		t, err := p.lexer.next()
+		if err != nil {
+			return err
+		}
+		if t.kind == tokenEOF {
+			if description != "" {
+				return p.errorf(t, "description without a definition")
+			}
+			return nil
+		}
+		if t.kind != tokenName {
+			return p.errorf(t, "expected a definition, found %s", t)
+		}
+
+		definition := &Definition{Kind: t.text, Description: description, Line: t.line, Column: t.column}
+		switch t.text {
+		case "schema":
+			if err := p.parseSchema(doc); err != nil {
+				return err
+			}
+			continue
+		case KindType, KindInput, KindInterface, KindEnum, KindScalar, KindUnion:
+		case "extend", "directive":
+			return p.errorf(t, "%s definitions are not supported", t.text)
+		default:
+			return p.errorf(t, "expected a definition, found %s", t)
+		}
+
+		name, err := p.name()
+		if err != nil {
+			return err
+		}
+		definition.Name = name.text
+
+		if definition.Kind == KindType || definition.Kind == KindInterface {
+			if err := p.parseImplements(definition); err != nil {
+				return err
+			}
+		}
+		if definition.Directives, err = p.parseDirectives(); err != nil {
+			return err
+		}
+
+		switch definition.Kind {
+		case KindType, KindInput, KindInterface:
+			err = p.parseFields(definition)
+		case KindEnum:
+			err = p.parseEnumValues(definition)
+		case KindUnion:
+			err = p.parseUnionTypes(definition)
+		}
+		if err != nil {
+			return err
+		}
+		doc.Definitions = append(doc.Definitions, definition)
+	}
+}
+
+// parseSchema reads a schema block's operation types.
+func (p *parser) parseSchema(doc *Document) error {
+	if _, err := p.parseDirectives(); err != nil {
+		return err
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for {
+		if closed, err := p.acceptPunct("}"); err != nil || closed {
+			return err
+		}
+		operation, err := p.name()
+		if err != nil {
+			return err
+		}
+		switch operation.text {
+		case "query", "mutation", "subscription":
+		default:
+			return p.errorf(operation, "unknown operation %s", operation.text)
+		}
+		if err := p.expect(":"); err != nil {
+			return err
+		}
+		name, err := p.name()
+		if err != nil {
+			return err
+		}
+		doc.Operations[operation.text] = name.text
+	}
+}
+
+// parseImplements reads "implements A & B".
+func (p *parser) parseImplements(definition *Definition) error {
+	t, err := p.lexer.peek()
+	if err != nil || t.kind != tokenName || t.text != "implements" {
+		return err
+	}
+	p.lexer.next()
+	p.acceptPunct("&")
+	for {
+		name, err := p.name()
+		if err != nil {
+			return err
+		}
+		definition.Interfaces = append(definition.Interfaces, name.text)
+		if more, err := p.acceptPunct("&"); err != nil || !more {
+			return err
+		}
+	}
+}
+
+// parseFields reads a block of fields, with arguments for types and
+// interfaces.
+func (p *parser) parseFields(definition *Definition) error {
+	if open, err := p.acceptPunct("{"); err != nil || !open {
+		return err
+	}
+	for {
+		if closed, err := p.acceptPunct("}"); err != nil || closed {
+			return err
+		}
+		field, err := p.parseField(definition.Kind != KindInput)
+		if err != nil {
+			return err
+		}
+		if definition.Field(field.Name) != nil {
+			return &Error{Line: field.Line, Column: field.Column, Message: fmt.Sprintf("%s.%s is defined twice", definition.Name, field.Name)}
+		}
+		definition.Fields = append(definition.Fields, field)
+	}
+}
+
+// parseField reads a field, or an argument when args is false.
+func (p *parser) parseField(args bool) (*Field, error) {
+	description, err := p.takeDescription()
+	if err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	field := &Field{Name: name.text, Description: description, Line: name.line, Column: name.column}
+
+	if args {
+		if open, err := p.acceptPunct("("); err != nil {
+			return nil, err
+		} else if open {
+			for {
+				if closed, err := p.acceptPunct(")"); err != nil {
+					return nil, err
+				} else if closed {
+					break
+				}
+				arg, err := p.parseField(false)
+				if err != nil {
+					return nil, err
+				}
+				field.Args = append(field.Args, arg)
+			}
+		}
+	}
+
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	if field.Type, err = p.parseType(); err != nil {
+		return nil, err
+	}
+	if ok, err := p.acceptPunct("="); err != nil {
+		return nil, err
+	} else if ok {
+		if field.Default, err = p.parseValue(); err != nil {
+			return nil, err
+		}
+	}
+	if field.Directives, err = p.parseDirectives(); err != nil {
+		return nil, err
+	}
+	return field, nil
+}
+
+// parseType reads a type such as "[Post!]!".
+func (p *parser) parseType() (string, error) {
+	var typeName string
+	if list, err := p.acceptPunct("["); err != nil {
+		return "", err
+	} else if list {
+		element, err := p.parseType()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect("]"); err != nil {
+			return "", err
+		}
+		typeName = "[" + element + "]"
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return "", err
+		}
+		typeName = name.text
+	}
+
+	if nonNull, err := p.acceptPunct("!"); err != nil {
+		return "", err
+	} else if nonNull {
+		typeName += "!"
+	}
+	return typeName, nil
+}
+
+// parseValue reads a value literal, returning its source text with
+// strings unquoted.
+func (p *parser) parseValue() (string, error) {
+	t, err := p.lexer.next()
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case t.kind == tokenName, t.kind == tokenNumber, t.kind == tokenString:
+		return t.text, nil
+	case t.kind == tokenPunct && (t.text == "[" || t.text == "{"):
+		closing := map[string]string{"[": "]", "{": "}"}[t.text]
+		parts := []string{}
+		for {
+			if closed, err := p.acceptPunct(closing); err != nil {
+				return "", err
+			} else if closed {
+				if t.text == "[" {
+					return "[" + strings.Join(parts, ", ") + "]", nil
+				}
+				return "{" + strings.Join(parts, ", ") + "}", nil
+			}
+			if t.text == "{" {
+				name, err := p.name()
+				if err != nil {
+					return "", err
+				}
+				if err := p.expect(":"); err != nil {
+					return "", err
+				}
+				value, err := p.parseValue()
+				if err != nil {
+					return "", err
+				}
+				parts = append(parts, name.text+": "+value)
+				continue
+			}
+			value, err := p.parseValue()
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, value)
+		}
+	}
+	return "", p.errorf(t, "expected a value, found %s", t)
+}
+
+// parseDirectives reads the directives applied to a definition or field.
+func (p *parser) parseDirectives() ([]*Directive, error) {
+	var directives []*Directive
+	for {
+		if at, err := p.acceptPunct("@"); err != nil || !at {
+			return directives, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		directive := &Directive{Name: name.text, Args: make(map[string]string)}
+		if open, err := p.acceptPunct("("); err != nil {
+			return nil, err
+		} else if open {
+			for {
+				if closed, err := p.acceptPunct(")"); err != nil {
+					return nil, err
+				} else if closed {
+					break
+				}
+				arg, err := p.name()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(":"); err != nil {
+					return nil, err
+				}
+				if directive.Args[arg.text], err = p.parseValue(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		directives = append(directives, directive)
+	}
+}
+
+// parseEnumValues reads an enum's values.
+func (p *parser) parseEnumValues(definition *Definition) error {
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for {
+		if closed, err := p.acceptPunct("}"); err != nil || closed {
+			return err
+		}
+		if _, err := p.takeDescription(); err != nil {
+			return err
+		}
+		value, err := p.name()
+		if err != nil {
+			return err
+		}
+		if _, err := p.parseDirectives(); err != nil {
+			return err
+		}
+		definition.Values = append(definition.Values, value.text)
+	}
+}
+
+// parseUnionTypes reads "= A | B".
+func (p *parser) parseUnionTypes(definition *Definition) error {
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	p.acceptPunct("|")
+	for {
+		name, err := p.name()
+		if err != nil {
+			return err
+		}
+		definition.Types = append(definition.Types, name.text)
+		if more, err := p.acceptPunct("|"); err != nil || !more {
+			return err
+		}
+	}
+}
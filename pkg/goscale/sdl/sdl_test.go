@@ -0,0 +1,112 @@
+package sdl
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const blogSchema = `
+# The blog's schema
+"""
+A person who writes posts.
+  Names are shown as given.
+"""
+type User @table(name: "accounts") {
+  id: ID!
+  name: String!
+  email: String! @index(unique: true)
+  role: Role = EDITOR
+  posts(limit: Int = 10, tags: [String!] = ["go", "db"]): [Post!]!
+}
+
+"A blog post"
+type Post implements Node & Timestamped {
+  id: ID!, title: String!
+  "The \"body\" in Markdown"
+  body: String
+  author: User
+  createdAt: DateTime!
+}
+
+interface Node { id: ID! }
+interface Timestamped { createdAt: DateTime! }
+enum Role { ADMIN EDITOR VIEWER }
+scalar Email
+union SearchResult = | User | Post
+input PostInput { title: String!, body: String }
+
+schema { query: RootQuery mutation: Mutation }
+type RootQuery { user(id: ID!): User search(text: String!): [SearchResult!]! }
+type Mutation { createPost(input: PostInput!): Post }
+`
+
+func TestParse(t *testing.T) {
+	doc, err := Parse(blogSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user := doc.Definition("User")
+	if user.Kind != KindType || user.Description != "A person who writes posts.\n  Names are shown as given." || user.Line != 7 {
+		t.Fatalf("unexpected user %+v", user)
+	}
+	if table := user.Directive("table"); table == nil || table.Args["name"] != "accounts" {
+		t.Fatalf("expected the table directive, got %+v", table)
+	}
+	if index := user.Field("email").Directive("index"); index == nil || index.Args["unique"] != "true" {
+		t.Fatalf("expected the index directive, got %+v", index)
+	}
+	if role := user.Field("role"); role.Type != "Role" || role.Default != "EDITOR" {
+		t.Fatalf("unexpected role %+v", role)
+	}
+	posts := user.Field("posts")
+	if posts.Type != "[Post!]!" || len(posts.Args) != 2 || posts.Args[1].Type != "[String!]" || posts.Args[1].Default != "[go, db]" {
+		t.Fatalf("unexpected posts %+v", posts)
+	}
+	if NamedType(posts.Type) != "Post" || !IsList(posts.Type) || !IsNonNull(posts.Type) {
+		t.Fatalf("unexpected type helpers for %s", posts.Type)
+	}
+
+	post := doc.Definition("Post")
+	if !reflect.DeepEqual(post.Interfaces, []string{"Node", "Timestamped"}) || post.Description != "A blog post" ||
+		len(post.Fields) != 5 || post.Field("body").Description != `The "body" in Markdown` {
+		t.Fatalf("unexpected post %+v", post)
+	}
+	if role := doc.Definition("Role"); !reflect.DeepEqual(role.Values, []string{"ADMIN", "EDITOR", "VIEWER"}) {
+		t.Fatalf("unexpected enum %+v", role)
+	}
+	if union := doc.Definition("SearchResult"); !reflect.DeepEqual(union.Types, []string{"User", "Post"}) {
+		t.Fatalf("unexpected union %+v", union)
+	}
+	if doc.Definition("Email").Kind != KindScalar || doc.Definition("PostInput").Kind != KindInput {
+		t.Fatalf("expected the scalar and input")
+	}
+
+	if !reflect.DeepEqual(doc.Operations, map[string]string{"query": "RootQuery", "mutation": "Mutation"}) ||
+		!doc.IsOperationType("RootQuery") || doc.IsOperationType("User") {
+		t.Fatalf("unexpected operations %v", doc.Operations)
+	}
+	if defaults, _ := Parse("type Query { ping: String }"); defaults.Operations["query"] != "Query" {
+		t.Fatalf("expected Query to be the default query type")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for source, message := range map[string]string{
+		"type User { id: ID! name String }":       `1:26: expected ":", found "String"`,
+		"type User {\n  id: ID!\n  post: Post\n}": "3:3: User.post has undefined type Post",
+		"type User { id: ID! }\ntype User { }":    "2:1: User is defined twice",
+		"type User { id: ID! id: ID }":            "1:21: User.id is defined twice",
+		"extend type User { age: Int }":           "1:1: extend definitions are not supported",
+		`type User { "unterminated }`:             "1:13: unterminated string",
+		"type User { id: ID! } %":                 "1:23: unexpected character '%'",
+		"schema { query: Root }":                  "1:1: query type Root is not defined",
+		"type User { id: [ID! }":                  `1:22: expected "]", found "}"`,
+	} {
+		_, err := Parse(source)
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("%q: expected %q, got %v", source, message, err)
+		}
+	}
+}
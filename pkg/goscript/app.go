@@ -0,0 +1,478 @@
+package goscript
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Service is a long-running part of an App, such as an HTTP server, an API,
+// an edge node or a metrics collector.
+type Service interface {
+	// Name identifies the service in logs and health reports.
+	Name() string
+
+	// Start starts the service and returns once it is ready; any work
+	// continues in the background until Stop.
+	Start(ctx context.Context) error
+
+	// Stop stops the service, giving up on a graceful stop once ctx is done.
+	Stop(ctx context.Context) error
+}
+
+// HealthReporter is implemented by services able to report their health.
+// Health returns nil while the service is healthy.
+type HealthReporter interface {
+	Health(ctx context.Context) error
+}
+
+// failer is implemented by services that can fail while running, such as a
+// server whose listener breaks. The App shuts down when one does.
+type failer interface {
+	Failed() <-chan error
+}
+
+// App supervises the services of a process: it starts them in the order
+// they were added, aggregates their health and stops them in reverse order,
+// so the HTTP server added last stops taking requests before the API and
+// edge nodes it uses are closed.
+type App struct {
+	// ShutdownTimeout bounds stopping all the services; 30 seconds by
+	// default.
+	ShutdownTimeout time.Duration
+
+	// HealthTimeout bounds each health check; 5 seconds by default.
+	HealthTimeout time.Duration
+
+	// Signals stop the app run by RunUntilSignal; os.Interrupt and SIGTERM
+	// by default.
+	Signals []os.Signal
+
+	// Logger logs services starting and stopping; the standard logger if
+	// nil.
+	Logger *log.Logger
+
+	mutex    sync.Mutex
+	services []Service
+	started  []Service
+	state    string
+}
+
+// App states, reported as the status of its health.
+const (
+	AppStopped  = "stopped"
+	AppStarting = "starting"
+	AppRunning  = "running"
+	AppStopping = "stopping"
+)
+
+// NewApp creates an app supervising services.
+func NewApp(services ...Service) *App {
+	return &App{
+		ShutdownTimeout: 30 * time.Second,
+		HealthTimeout:   5 * time.Second,
+		services:        services,
+		state:           AppStopped,
+	}
+}
+
+// Add adds services, started after those already added. Services cannot be
+// added while the app runs.
+func (a *App) Add(services ...Service) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.state != AppStopped {
+		panic("goscript: services added to a running app")
+	}
+	a.services = append(a.services, services...)
+}
+
+// Services returns the services in start order.
+func (a *App) Services() []Service {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return append([]Service(nil), a.services...)
+}
+
+// Start starts the services in order. When one fails to start, those
+// already started are stopped and the error is returned.
+func (a *App) Start(ctx context.Context) error {
+	a.mutex.Lock()
+	if a.state != AppStopped {
+		a.mutex.Unlock()
+		return fmt.Errorf("app is %s", a.state)
+	}
+	a.state = AppStarting
+	services := append([]Service(nil), a.services...)
+	a.mutex.Unlock()
+
+	for _, service := range services {
+		a.logger().Printf("Starting %s", service.Name())
+		if err := service.Start(ctx); err != nil {
+			err = fmt.Errorf("starting %s: %w", service.Name(), err)
+			a.logger().Print(err)
+			a.shutdown()
+			return err
+		}
+
+		a.mutex.Lock()
+		a.started = append(a.started, service)
+		a.mutex.Unlock()
+	}
+
+	a.mutex.Lock()
+	a.state = AppRunning
+	a.mutex.Unlock()
+	return nil
+}
+
+// Stop stops the started services in reverse order, each getting what is
+// left of ctx. It carries on past failures and returns the first error.
+func (a *App) Stop(ctx context.Context) error {
+	a.mutex.Lock()
+	a.state = AppStopping
+	started := a.started
+	a.started = nil
+	a.mutex.Unlock()
+
+	var result error
+	for i := len(started) - 1; i >= 0; i-- {
+		service := started[i]
+		a.logger().Printf("Stopping %s", service.Name())
+		if err := service.Stop(ctx); err != nil {
+			err = fmt.Errorf("stopping %s: %w", service.Name(), err)
+			a.logger().Print(err)
+			if result == nil {
+				result = err
+			}
+		}
+	}
+
+	a.mutex.Lock()
+	a.state = AppStopped
+	a.mutex.Unlock()
+	return result
+}
+
+// Run starts the services, then runs until ctx is done or a service fails,
+// and stops them within ShutdownTimeout. It returns the error of a service
+// failing to start or while running, else that of stopping.
+func (a *App) Run(ctx context.Context) error {
+	if err := a.Start(ctx); err != nil {
+		return err
+	}
+
+	failed := make(chan error, 1)
+	watching, stopWatching := context.WithCancel(context.Background())
+	defer stopWatching()
+	for _, service := range a.Services() {
+		f, ok := service.(failer)
+		if !ok {
+			continue
+		}
+		go func(name string, f failer) {
+			select {
+			case err := <-f.Failed():
+				if err == nil {
+					err = errors.New("stopped unexpectedly")
+				}
+				select {
+				case failed <- fmt.Errorf("%s: %w", name, err):
+				default:
+				}
+			case <-watching.Done():
+			}
+		}(service.Name(), f)
+	}
+
+	var result error
+	select {
+	case <-ctx.Done():
+	case result = <-failed:
+		a.logger().Print(result)
+	}
+	stopWatching()
+
+	a.logger().Printf("Shutting down, waiting up to %s for services to stop", a.shutdownTimeout())
+	if err := a.shutdown(); result == nil {
+		result = err
+	}
+	return result
+}
+
+// RunUntilSignal runs the app until one of the Signals arrives.
+func (a *App) RunUntilSignal() error {
+	signals := a.Signals
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), signals...)
+	defer stop()
+
+	return a.Run(ctx)
+}
+
+// shutdown stops the services within ShutdownTimeout.
+func (a *App) shutdown() error {
+	ctx, cancel := context.WithTimeout(context.Background(), a.shutdownTimeout())
+	defer cancel()
+
+	return a.Stop(ctx)
+}
+
+// HealthReport is the health of an app and of each of its services.
+type HealthReport struct {
+	// Status is "ok" when the app runs and all its services are healthy,
+	// "unhealthy" when one is not, else the state of the app.
+	Status   string          `json:"status"`
+	Services []ServiceHealth `json:"services"`
+}
+
+// ServiceHealth is the health of one service.
+type ServiceHealth struct {
+	Name string `json:"name"`
+
+	// Status is "ok", "unhealthy", or "stopped" for a service not started.
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+
+	Duration time.Duration `json:"duration"`
+}
+
+// Healthy reports whether the app runs with all its services healthy.
+func (r HealthReport) Healthy() bool {
+	return r.Status == "ok"
+}
+
+// Health checks the services reporting their health, concurrently and each
+// within HealthTimeout.
+func (a *App) Health(ctx context.Context) HealthReport {
+	a.mutex.Lock()
+	state := a.state
+	services := append([]Service(nil), a.services...)
+	started := make(map[Service]bool, len(a.started))
+	for _, service := range a.started {
+		started[service] = true
+	}
+	a.mutex.Unlock()
+
+	report := HealthReport{Status: "ok", Services: make([]ServiceHealth, len(services))}
+	var wg sync.WaitGroup
+	for i, service := range services {
+		report.Services[i] = ServiceHealth{Name: service.Name(), Status: "ok"}
+		if !started[service] {
+			report.Services[i].Status = AppStopped
+			continue
+		}
+		reporter, ok := service.(HealthReporter)
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(health *ServiceHealth, reporter HealthReporter) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, a.healthTimeout())
+			defer cancel()
+
+			began := time.Now()
+			if err := reporter.Health(ctx); err != nil {
+				health.Status = "unhealthy"
+				health.Error = err.Error()
+			}
+			health.Duration = time.Since(began)
+		}(&report.Services[i], reporter)
+	}
+	wg.Wait()
+
+	if state != AppRunning {
+		report.Status = state
+		return report
+	}
+	for _, health := range report.Services {
+		if health.Status != "ok" {
+			report.Status = "unhealthy"
+		}
+	}
+	return report
+}
+
+// HealthHandler serves the health report as JSON, with status 200 when the
+// app is healthy and 503 otherwise, for load balancers and orchestrators.
+func (a *App) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := a.Health(r.Context())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if !report.Healthy() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+}
+
+func (a *App) shutdownTimeout() time.Duration {
+	if a.ShutdownTimeout <= 0 {
+		return 30 * time.Second
+	}
+	return a.ShutdownTimeout
+}
+
+func (a *App) healthTimeout() time.Duration {
+	if a.HealthTimeout <= 0 {
+		return 5 * time.Second
+	}
+	return a.HealthTimeout
+}
+
+func (a *App) logger() *log.Logger {
+	if a.Logger != nil {
+		return a.Logger
+	}
+	return log.Default()
+}
+
+// NewService creates a service from start and stop functions, either of
+// which may be nil, such as a jetpack AlertManager's:
+//
+//	goscript.NewService("alerts",
+//		func(ctx context.Context) error { alerts.Start(time.Minute); return nil },
+//		func(ctx context.Context) error { alerts.Stop(); return nil })
+func NewService(name string, start, stop func(context.Context) error) Service {
+	return &funcService{name: name, start: start, stop: stop}
+}
+
+type funcService struct {
+	name  string
+	start func(context.Context) error
+	stop  func(context.Context) error
+}
+
+func (s *funcService) Name() string {
+	return s.name
+}
+
+func (s *funcService) Start(ctx context.Context) error {
+	if s.start == nil {
+		return nil
+	}
+	return s.start(ctx)
+}
+
+func (s *funcService) Stop(ctx context.Context) error {
+	if s.stop == nil {
+		return nil
+	}
+	return s.stop(ctx)
+}
+
+// CloserService creates a service closed when the app stops, such as a
+// GoScaleAPI or an EdgeNode, which start when created.
+func CloserService(name string, closer io.Closer) Service {
+	return NewService(name, nil, func(context.Context) error {
+		return closer.Close()
+	})
+}
+
+// RunService creates a service running run in the background until the app
+// stops, which cancels its context. When run returns before then, the app
+// shuts down.
+func RunService(name string, run func(ctx context.Context) error) Service {
+	return &runService{name: name, run: run}
+}
+
+type runService struct {
+	name   string
+	run    func(context.Context) error
+	cancel context.CancelFunc
+	done   chan struct{}
+	failed chan error
+	err    error
+}
+
+func (s *runService) Name() string {
+	return s.name
+}
+
+func (s *runService) Start(ctx context.Context) error {
+	// The service outlives the context it was started with
+	runCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	s.failed = make(chan error, 1)
+
+	go func() {
+		defer close(s.done)
+		s.err = s.run(runCtx)
+		if runCtx.Err() == nil {
+			s.failed <- s.err
+		}
+	}()
+	return nil
+}
+
+func (s *runService) Stop(ctx context.Context) error {
+	s.cancel()
+	select {
+	case <-s.done:
+		if errors.Is(s.err, context.Canceled) {
+			return nil
+		}
+		return s.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *runService) Failed() <-chan error {
+	return s.failed
+}
+
+// Service returns the server as a service of an App. It listens when
+// started, so an address in use fails the start, and shuts down gracefully
+// when stopped.
+func (s *Server) Service() Service {
+	return &serverService{server: s}
+}
+
+type serverService struct {
+	server *Server
+	runService
+}
+
+func (s *serverService) Name() string {
+	return "http " + s.server.Addr
+}
+
+func (s *serverService) Start(ctx context.Context) error {
+	addr := s.server.Addr
+	if addr == "" {
+		addr = ":http"
+		if s.server.TLS() {
+			addr = ":https"
+		}
+	}
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	s.runService.name = s.Name()
+	s.runService.run = func(ctx context.Context) error {
+		return s.server.Serve(ctx, listener)
+	}
+	return s.runService.Start(ctx)
+}
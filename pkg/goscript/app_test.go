@@ -0,0 +1,193 @@
+package goscript
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder records the order services start and stop in.
+type recorder struct {
+	mutex  sync.Mutex
+	events []string
+}
+
+func (r *recorder) service(name string, startErr error) Service {
+	record := func(event string, err error) func(context.Context) error {
+		return func(context.Context) error {
+			r.mutex.Lock()
+			defer r.mutex.Unlock()
+			r.events = append(r.events, event+" "+name)
+			return err
+		}
+	}
+	return NewService(name, record("start", startErr), record("stop", nil))
+}
+
+func (r *recorder) recorded() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+func quietApp(services ...Service) *App {
+	app := NewApp(services...)
+	app.Logger = log.New(ioutil.Discard, "", 0)
+	return app
+}
+
+func TestAppStartsInOrderAndStopsInReverse(t *testing.T) {
+	var r recorder
+	app := quietApp(r.service("api", nil), r.service("edge", nil))
+	app.Add(r.service("http", nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- app.Run(ctx) }()
+	for app.Health(context.Background()).Status != "ok" {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"start api", "start edge", "start http", "stop http", "stop edge", "stop api"}
+	if got := r.recorded(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+func TestAppRollsBackFailedStart(t *testing.T) {
+	var r recorder
+	app := quietApp(r.service("api", nil), r.service("edge", errors.New("no capacity")), r.service("http", nil))
+
+	err := app.Run(context.Background())
+	if err == nil || err.Error() != "starting edge: no capacity" {
+		t.Fatalf("expected the start error, got %v", err)
+	}
+	want := []string{"start api", "start edge", "stop api"}
+	if got := r.recorded(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if status := app.Health(context.Background()).Status; status != AppStopped {
+		t.Fatalf("expected the app to be stopped, got %s", status)
+	}
+}
+
+func TestAppShutsDownWhenServiceFails(t *testing.T) {
+	var r recorder
+	crash := make(chan struct{})
+	worker := RunService("worker", func(ctx context.Context) error {
+		select {
+		case <-crash:
+			return errors.New("lost connection")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	app := quietApp(r.service("api", nil), worker)
+
+	done := make(chan error, 1)
+	go func() { done <- app.Run(context.Background()) }()
+	close(crash)
+
+	select {
+	case err := <-done:
+		if err == nil || err.Error() != "worker: lost connection" {
+			t.Fatalf("expected the failure, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the app to shut down")
+	}
+	if got := r.recorded(); !reflect.DeepEqual(got, []string{"start api", "stop api"}) {
+		t.Fatalf("expected the other services to stop, got %v", got)
+	}
+}
+
+func TestAppShutdownTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	stuck := RunService("stuck", func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+	app := quietApp(stuck)
+	app.ShutdownTimeout = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := app.Run(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the shutdown to time out, got %v", err)
+	}
+}
+
+type unhealthyService struct {
+	Service
+}
+
+func (unhealthyService) Health(ctx context.Context) error {
+	return errors.New("2 of 3 nodes down")
+}
+
+func TestAppHealthHandler(t *testing.T) {
+	var r recorder
+	edge := unhealthyService{r.service("edge", nil)}
+	app := quietApp(r.service("api", nil), edge)
+	handler := app.HealthHandler()
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest("GET", "/health", nil))
+	if response.Code != http.StatusServiceUnavailable || !strings.Contains(response.Body.String(), `"status":"stopped"`) {
+		t.Fatalf("expected a stopped app to be unavailable, got %d %s", response.Code, response.Body)
+	}
+
+	if err := app.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	defer app.Stop(context.Background())
+
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest("GET", "/health", nil))
+	var report HealthReport
+	if err := json.Unmarshal(response.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if response.Code != http.StatusServiceUnavailable || report.Status != "unhealthy" ||
+		report.Services[0].Status != "ok" || report.Services[1].Error != "2 of 3 nodes down" {
+		t.Fatalf("expected the edge to be unhealthy, got %d %+v", response.Code, report)
+	}
+}
+
+func TestServerService(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+
+	server := NewServer(taken.Addr().String(), http.NotFoundHandler())
+	server.Logger = log.New(ioutil.Discard, "", 0)
+	app := quietApp(server.Service())
+	if err := app.Start(context.Background()); err == nil {
+		t.Fatal("expected an address in use to fail the start")
+	}
+
+	server.Addr = "127.0.0.1:0"
+	if err := app.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.Stop(context.Background()); err != nil {
+		t.Fatalf("expected a graceful stop, got %v", err)
+	}
+}
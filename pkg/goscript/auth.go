@@ -0,0 +1,210 @@
+package goscript
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"html"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	sessionUserKey = "goscript.user"
+	sessionCSRFKey = "goscript.csrf"
+)
+
+// ErrBlocked is returned by Login when the session manager's Guard blocks
+// the user or the request's IP.
+var ErrBlocked = errors.New("goscript: login blocked after too many failed attempts")
+
+// AccessGuard blocks clients, such as after too many failed logins.
+// SessionManager.Middleware consults it on every request, and Login before
+// signing a user in.
+type AccessGuard interface {
+	// Blocked reports whether requests from ip, signed in as user or "", are
+	// refused, and for how long; zero is until they are unblocked.
+	Blocked(ip, user string) (bool, time.Duration)
+}
+
+// remoteIP is the IP a request came from. X-Forwarded-For is not trusted,
+// as any client can send it.
+func remoteIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// Login signs a user in for the rest of the session. It renews the session
+// ID, so an ID planted before the login cannot be used to take it over. It
+// returns ErrBlocked, signing no one in, when the session manager's Guard
+// blocks the user.
+func Login(r *http.Request, user string) error {
+	session := SessionFromContext(r.Context())
+	if session == nil {
+		return ErrNoSession
+	}
+	if session.guard != nil {
+		if blocked, _ := session.guard.Blocked(remoteIP(r), user); blocked {
+			return ErrBlocked
+		}
+	}
+	session.Renew()
+	session.Set(sessionUserKey, user)
+	// A token seen before the login is not trusted after it
+	session.Delete(sessionCSRFKey)
+	return nil
+}
+
+// Logout signs the user out and destroys the session.
+func Logout(r *http.Request) error {
+	session := SessionFromContext(r.Context())
+	if session == nil {
+		return ErrNoSession
+	}
+	session.Destroy()
+	return nil
+}
+
+// CurrentUser returns the user Login signed in, or "".
+func CurrentUser(r *http.Request) string {
+	session := SessionFromContext(r.Context())
+	if session == nil {
+		return ""
+	}
+	return session.GetString(sessionUserKey)
+}
+
+// RequireLogin lets only signed-in users through. Page requests are
+// redirected to loginPath, with the page to return to in the "next" query
+// parameter; other requests get 401.
+func RequireLogin(loginPath string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if CurrentUser(r) != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+				target := loginPath + "?next=" + url.QueryEscape(r.URL.RequestURI())
+				http.Redirect(w, r, target, http.StatusSeeOther)
+				return
+			}
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		})
+	}
+}
+
+// SafeRedirect returns next when it is a path on this site, such as the
+// "next" parameter RequireLogin adds, and fallback otherwise, so a login
+// form cannot be used to send users elsewhere. Backslashes and control
+// characters are refused, as browsers turn "/\evil.com" and
+// "/\t/evil.com" into "//evil.com".
+func SafeRedirect(next, fallback string) string {
+	if strings.IndexFunc(next, func(r rune) bool { return r < 0x20 || r == 0x7f || r == '\\' }) >= 0 {
+		return fallback
+	}
+	u, err := url.Parse(next)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Opaque != "" || !strings.HasPrefix(u.Path, "/") || strings.HasPrefix(u.Path, "//") {
+		return fallback
+	}
+	return next
+}
+
+// CSRFHeader and CSRFFormField name where CSRF looks for the token.
+const (
+	CSRFHeader    = "X-CSRF-Token"
+	CSRFFormField = "csrf_token"
+)
+
+// CSRF rejects unsafe requests, such as POST, that do not carry the
+// session's token in the X-CSRF-Token header or the csrf_token form field,
+// so other sites cannot submit forms as the user. It needs
+// SessionManager.Middleware to run first.
+func CSRF() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token := r.Header.Get(CSRFHeader)
+			if token == "" {
+				token = r.PostFormValue(CSRFFormField)
+			}
+			if !validCSRFToken(r, token) {
+				http.Error(w, "invalid CSRF token", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// CSRFToken returns a token for forms and scripts to send back with unsafe
+// requests. Each call masks the session's secret differently, so compressed
+// pages do not leak it.
+func CSRFToken(r *http.Request) string {
+	session := SessionFromContext(r.Context())
+	if session == nil {
+		return ""
+	}
+
+	secret, ok := decodeCSRF(session.GetString(sessionCSRFKey), 32)
+	if !ok {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(err)
+		}
+		session.Set(sessionCSRFKey, base64.RawURLEncoding.EncodeToString(secret))
+	}
+
+	masked := make([]byte, 64)
+	if _, err := rand.Read(masked[:32]); err != nil {
+		panic(err)
+	}
+	for i := range secret {
+		masked[32+i] = masked[i] ^ secret[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(masked)
+}
+
+// CSRFInput returns a hidden form input carrying CSRFToken.
+func CSRFInput(r *http.Request) string {
+	return `<input type="hidden" name="` + CSRFFormField + `" value="` + html.EscapeString(CSRFToken(r)) + `">`
+}
+
+// validCSRFToken unmasks a token and compares it with the session's secret.
+func validCSRFToken(r *http.Request, token string) bool {
+	session := SessionFromContext(r.Context())
+	if session == nil {
+		return false
+	}
+	secret, ok := decodeCSRF(session.GetString(sessionCSRFKey), 32)
+	if !ok {
+		return false
+	}
+	masked, ok := decodeCSRF(token, 64)
+	if !ok {
+		return false
+	}
+
+	unmasked := make([]byte, 32)
+	for i := range unmasked {
+		unmasked[i] = masked[i] ^ masked[32+i]
+	}
+	return subtle.ConstantTimeCompare(unmasked, secret) == 1
+}
+
+func decodeCSRF(value string, size int) ([]byte, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	return data, err == nil && len(data) == size
+}
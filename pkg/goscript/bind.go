@@ -0,0 +1,457 @@
+package goscript
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxBindBytes bounds the request bodies Bind reads.
+var MaxBindBytes int64 = 10 << 20
+
+// BindError reports a request Bind could not decode or that failed
+// validation.
+type BindError struct {
+	// Status is the HTTP status to answer with: 400, 413 for a body over
+	// MaxBindBytes or 415 for an unsupported content type.
+	Status int
+
+	Message string
+
+	// Fields lists the invalid fields, by the name the client sent.
+	Fields []FormError
+}
+
+// Error implements the error interface.
+func (e *BindError) Error() string {
+	if len(e.Fields) == 0 {
+		return e.Message
+	}
+	parts := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		parts[i] = field.Field + " " + field.Message
+	}
+	return e.Message + ": " + strings.Join(parts, ", ")
+}
+
+// WriteBindError answers a request Bind rejected with its status and a JSON
+// body, {"error": "...", "fields": [{"field": "...", "message": "..."}]}.
+// Other errors, such as a Bind target that is not a struct pointer, get 500.
+func WriteBindError(w http.ResponseWriter, err error) {
+	var bindErr *BindError
+	if !errors.As(err, &bindErr) {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	fields := bindErr.Fields
+	if fields == nil {
+		fields = []FormError{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(bindErr.Status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"error": bindErr.Message, "fields": fields})
+}
+
+// Bind fills the struct dst points to from a request and validates it. GET,
+// HEAD and DELETE requests bind their query string; others bind a JSON or
+// form body, by Content-Type. JSON uses the json tags; form and query
+// values use the form tag, else the json name, else the field name starting
+// in lower case. Fields are then checked against their validate tags:
+//
+//	Name  string `json:"name" validate:"required,max=50"`
+//	Email string `json:"email" validate:"required,email"`
+//	Role  string `json:"role" validate:"oneof=admin member"`
+//	Age   int    `json:"age" validate:"min=13"`
+//
+// min, max and len bound numbers by value and strings, slices and maps by
+// length. A *BindError reports what is wrong; pass it to WriteBindError.
+func Bind(r *http.Request, dst interface{}) error {
+	value := reflect.ValueOf(dst)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("goscript: Bind needs a pointer to a struct, got %T", dst)
+	}
+
+	var err error
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		err = bindValues(r.URL.Query(), value.Elem())
+	default:
+		err = bindBody(r, dst, value.Elem())
+	}
+	if err != nil {
+		return err
+	}
+	return Validate(dst)
+}
+
+// bindBody decodes a JSON or form body.
+func bindBody(r *http.Request, dst interface{}, target reflect.Value) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	switch {
+	case contentType == "application/json" || strings.HasSuffix(contentType, "+json"):
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, MaxBindBytes+1))
+		if err != nil {
+			return &BindError{Status: http.StatusBadRequest, Message: "could not read request body"}
+		}
+		if int64(len(body)) > MaxBindBytes {
+			return &BindError{Status: http.StatusRequestEntityTooLarge, Message: "request body too large"}
+		}
+		if len(strings.TrimSpace(string(body))) == 0 {
+			return nil
+		}
+		return jsonBindError(json.Unmarshal(body, dst))
+
+	case contentType == "application/x-www-form-urlencoded" || contentType == "multipart/form-data":
+		r.Body = http.MaxBytesReader(nil, r.Body, MaxBindBytes)
+		var err error
+		if contentType == "multipart/form-data" {
+			err = r.ParseMultipartForm(MaxBindBytes)
+		} else {
+			err = r.ParseForm()
+		}
+		if err != nil {
+			return &BindError{Status: http.StatusBadRequest, Message: "invalid form body"}
+		}
+		return bindValues(r.PostForm, target)
+
+	case contentType == "":
+		return &BindError{Status: http.StatusUnsupportedMediaType, Message: "missing Content-Type"}
+	default:
+		return &BindError{Status: http.StatusUnsupportedMediaType, Message: "unsupported Content-Type " + contentType}
+	}
+}
+
+// jsonBindError describes a JSON decoding error for the client.
+func jsonBindError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return &BindError{
+			Status:  http.StatusBadRequest,
+			Message: "invalid request",
+			Fields:  []FormError{{Field: typeErr.Field, Message: "must be " + describeType(typeErr.Type)}},
+		}
+	}
+	return &BindError{Status: http.StatusBadRequest, Message: "invalid JSON"}
+}
+
+// bindValues fills struct fields from form or query values.
+func bindValues(values url.Values, target reflect.Value) error {
+	var fields []FormError
+	structType := target.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		name, ok := bindName(field)
+		if !ok {
+			continue
+		}
+		raw, present := values[name]
+		if !present || len(raw) == 0 {
+			continue
+		}
+		if err := setField(target.Field(i), raw); err != nil {
+			fields = append(fields, FormError{Field: name, Message: err.Error()})
+		}
+	}
+	if len(fields) > 0 {
+		return &BindError{Status: http.StatusBadRequest, Message: "invalid request", Fields: fields}
+	}
+	return nil
+}
+
+// bindName returns the form name of a struct field; unexported fields and
+// those tagged "-" are not bound.
+func bindName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+	for _, key := range []string{"form", "json"} {
+		tag, found := field.Tag.Lookup(key)
+		if !found {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			return "", false
+		}
+		if name != "" {
+			return name, true
+		}
+	}
+	runes := []rune(field.Name)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes), true
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// setField parses form values into a field; slices take every value.
+func setField(field reflect.Value, raw []string) error {
+	if field.Kind() == reflect.Ptr {
+		element := reflect.New(field.Type().Elem())
+		if err := setField(element.Elem(), raw); err != nil {
+			return err
+		}
+		field.Set(element)
+		return nil
+	}
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(field.Type(), len(raw), len(raw))
+		for i, text := range raw {
+			if err := parseValue(slice.Index(i), text); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+	return parseValue(field, raw[0])
+}
+
+// parseValue parses one form value into a field of a basic type.
+func parseValue(field reflect.Value, text string) error {
+	switch {
+	case field.Type() == durationType:
+		duration, err := time.ParseDuration(text)
+		if err != nil {
+			return errors.New("must be a duration")
+		}
+		field.SetInt(int64(duration))
+		return nil
+	case field.Type() == timeType:
+		parsed, err := time.Parse(time.RFC3339, text)
+		if err != nil {
+			return errors.New("must be an RFC 3339 time")
+		}
+		field.Set(reflect.ValueOf(parsed))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(text)
+	case reflect.Bool:
+		// A checked checkbox sends "on"
+		parsed, err := strconv.ParseBool(text)
+		if text == "on" {
+			parsed, err = true, nil
+		}
+		if err != nil {
+			return errors.New("must be a boolean")
+		}
+		field.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(text, 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		field.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(text, 10, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be a positive integer")
+		}
+		field.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(text, field.Type().Bits())
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		field.SetFloat(parsed)
+	case reflect.Slice:
+		// []byte
+		field.SetBytes([]byte(text))
+	default:
+		return fmt.Errorf("cannot be bound from a form")
+	}
+	return nil
+}
+
+// describeType names a type for error messages.
+func describeType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+var emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+// Validate checks the fields of a struct, or a pointer to one, against
+// their validate tags, as Bind does, and returns a *BindError listing every
+// invalid field.
+func Validate(v interface{}) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("goscript: Validate needs a struct, got %T", v)
+	}
+
+	var fields []FormError
+	structType := value.Type()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		rules, found := field.Tag.Lookup("validate")
+		if !found || field.PkgPath != "" {
+			continue
+		}
+		name, ok := bindName(field)
+		if !ok {
+			name = field.Name
+		}
+		if message := checkRules(value.Field(i), rules); message != "" {
+			fields = append(fields, FormError{Field: name, Message: message})
+		}
+	}
+	if len(fields) > 0 {
+		return &BindError{Status: http.StatusBadRequest, Message: "invalid request", Fields: fields}
+	}
+	return nil
+}
+
+// checkRules returns the message of the first rule a value breaks, or "".
+func checkRules(value reflect.Value, rules string) string {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			if hasRule(rules, "required") {
+				return "is required"
+			}
+			return ""
+		}
+		value = value.Elem()
+	}
+
+	if hasRule(rules, "required") && value.IsZero() {
+		return "is required"
+	}
+	// Other rules check values that are present; numbers are always checked
+	if value.IsZero() && !isNumeric(value.Kind()) {
+		return ""
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg := rule, ""
+		if eq := strings.Index(rule, "="); eq >= 0 {
+			name, arg = rule[:eq], rule[eq+1:]
+		}
+
+		switch name {
+		case "", "required":
+		case "min", "max", "len":
+			if message := checkBound(value, name, arg); message != "" {
+				return message
+			}
+		case "email":
+			if !emailPattern.MatchString(value.String()) {
+				return "must be an email address"
+			}
+		case "url":
+			parsed, err := url.Parse(value.String())
+			if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+				return "must be a URL"
+			}
+		case "oneof":
+			if !wordIn(fmt.Sprint(value.Interface()), strings.Fields(arg)) {
+				return "must be one of " + strings.Join(strings.Fields(arg), ", ")
+			}
+		default:
+			panic(fmt.Sprintf("goscript: unknown validate rule %q", name))
+		}
+	}
+	return ""
+}
+
+// checkBound applies min, max or len to a number's value or a length.
+func checkBound(value reflect.Value, rule, arg string) string {
+	limit, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		panic(fmt.Sprintf("goscript: validate rule %s needs a number, got %q", rule, arg))
+	}
+
+	var measured float64
+	unit := ""
+	switch kind := value.Kind(); {
+	case kind == reflect.String:
+		measured, unit = float64(utf8.RuneCountInString(value.String())), " characters"
+	case kind == reflect.Slice || kind == reflect.Map || kind == reflect.Array:
+		measured, unit = float64(value.Len()), " items"
+	case kind >= reflect.Int && kind <= reflect.Int64:
+		measured = float64(value.Int())
+	case kind >= reflect.Uint && kind <= reflect.Uintptr:
+		measured = float64(value.Uint())
+	case kind == reflect.Float32 || kind == reflect.Float64:
+		measured = value.Float()
+	default:
+		return ""
+	}
+
+	switch {
+	case rule == "min" && measured < limit:
+		if unit == "" {
+			return "must be at least " + arg
+		}
+		return "must have at least " + arg + unit
+	case rule == "max" && measured > limit:
+		if unit == "" {
+			return "must be at most " + arg
+		}
+		return "must have at most " + arg + unit
+	case rule == "len" && measured != limit:
+		return "must have exactly " + arg + unit
+	}
+	return ""
+}
+
+func isNumeric(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64
+}
+
+func hasRule(rules, name string) bool {
+	for _, rule := range strings.Split(rules, ",") {
+		if rule == name {
+			return true
+		}
+	}
+	return false
+}
+
+func wordIn(word string, words []string) bool {
+	for _, candidate := range words {
+		if candidate == word {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package goscript
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type signup struct {
+	Name     string        `json:"name" validate:"required,max=10"`
+	Email    string        `json:"email" validate:"required,email"`
+	Role     string        `json:"role" validate:"oneof=admin member"`
+	Age      int           `json:"age" validate:"min=13"`
+	Tags     []string      `json:"tags" validate:"max=2"`
+	Remember bool          `form:"remember_me" json:"remember"`
+	Timeout  time.Duration `json:"-" form:"timeout"`
+	Invite   *string       `json:"invite"`
+}
+
+func bindRequest(method, target, contentType, body string) (*signup, error) {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	var dst signup
+	err := Bind(request, &dst)
+	return &dst, err
+}
+
+func TestBindJSON(t *testing.T) {
+	dst, err := bindRequest("POST", "/", "application/json; charset=utf-8",
+		`{"name":"ada","email":"ada@example.com","role":"admin","age":36,"tags":["a"],"remember":true,"invite":"x"}`)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if dst.Name != "ada" || dst.Age != 36 || !dst.Remember || dst.Invite == nil || *dst.Invite != "x" {
+		t.Fatalf("unexpected binding %+v", dst)
+	}
+
+	_, err = bindRequest("POST", "/", "application/json", `{"name":"ada","age":"old"}`)
+	bindErr, ok := err.(*BindError)
+	if !ok || bindErr.Status != http.StatusBadRequest || !reflect.DeepEqual(bindErr.Fields, []FormError{{Field: "age", Message: "must be an integer"}}) {
+		t.Fatalf("expected a field type error, got %#v", err)
+	}
+
+	if _, err = bindRequest("POST", "/", "text/plain", "name=ada"); err.(*BindError).Status != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415 for an unsupported body, got %v", err)
+	}
+}
+
+func TestBindFormAndQuery(t *testing.T) {
+	dst, err := bindRequest("POST", "/", "application/x-www-form-urlencoded",
+		"name=grace&email=grace%40example.com&age=40&tags=x&tags=y&remember_me=on&timeout=5s")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if dst.Name != "grace" || dst.Age != 40 || !reflect.DeepEqual(dst.Tags, []string{"x", "y"}) || !dst.Remember || dst.Timeout != 5*time.Second {
+		t.Fatalf("unexpected binding %+v", dst)
+	}
+
+	dst, err = bindRequest("GET", "/?name=linus&email=l%40example.com&age=50", "", "")
+	if err != nil || dst.Name != "linus" || dst.Age != 50 {
+		t.Fatalf("unexpected query binding %+v %v", dst, err)
+	}
+
+	_, err = bindRequest("GET", "/?name=linus&email=l%40example.com&age=fifty", "", "")
+	if bindErr, ok := err.(*BindError); !ok || bindErr.Fields[0] != (FormError{Field: "age", Message: "must be an integer"}) {
+		t.Fatalf("expected a parse error for age, got %v", err)
+	}
+}
+
+func TestBindValidation(t *testing.T) {
+	_, err := bindRequest("POST", "/", "application/json",
+		`{"name":"a very long name","email":"nope","role":"owner","age":9,"tags":["a","b","c"]}`)
+
+	recorder := httptest.NewRecorder()
+	WriteBindError(recorder, err)
+	if recorder.Code != http.StatusBadRequest || recorder.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected response %d %v", recorder.Code, recorder.Header())
+	}
+
+	var body struct {
+		Error  string      `json:"error"`
+		Fields []FormError `json:"fields"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	expected := []FormError{
+		{Field: "name", Message: "must have at most 10 characters"},
+		{Field: "email", Message: "must be an email address"},
+		{Field: "role", Message: "must be one of admin, member"},
+		{Field: "age", Message: "must be at least 13"},
+		{Field: "tags", Message: "must have at most 2 items"},
+	}
+	if body.Error != "invalid request" || !reflect.DeepEqual(body.Fields, expected) {
+		t.Fatalf("unexpected error body %s", recorder.Body.String())
+	}
+
+	// Optional fields are only checked when present
+	if err := Validate(signup{Name: "ada", Email: "ada@example.com", Age: 20}); err != nil {
+		t.Fatalf("expected optional fields to pass, got %v", err)
+	}
+	if err := Validate(signup{Age: 20}); err == nil || !strings.Contains(err.Error(), "name is required") {
+		t.Fatalf("expected required errors, got %v", err)
+	}
+}
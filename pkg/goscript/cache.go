@@ -0,0 +1,424 @@
+package goscript
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/cache"
+)
+
+// maxBufferedBody is the largest response ETag and ResponseCache buffer;
+// larger ones are streamed as they are written, without either.
+const maxBufferedBody = 1 << 20
+
+// ETag adds an ETag, a hash of the body, to successful GET and HEAD
+// responses that have none, and answers 304 Not Modified when the client's
+// If-None-Match has it. The page is still rendered, but not sent again.
+// Responses are buffered, so streaming handlers should not use it.
+func ETag() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !bufferable(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			writer := &bufferWriter{ResponseWriter: w}
+			next.ServeHTTP(writer, r)
+			if writer.streamed {
+				return
+			}
+			if writer.status == http.StatusOK && w.Header().Get("ETag") == "" {
+				w.Header().Set("ETag", bodyETag(writer.body.Bytes()))
+			}
+			writeBuffered(w, r, writer.status, writer.body.Bytes())
+		})
+	}
+}
+
+// CacheOptions configures a ResponseCache.
+type CacheOptions struct {
+	// TTL is how long a response is reused; one minute by default.
+	TTL time.Duration
+
+	// VaryHeaders lists request headers that change the response, such as
+	// Accept-Language or Cookie for pages showing who is logged in. Each
+	// value gets its own entry.
+	VaryHeaders []string
+
+	// VaryQuery lists the query parameters that change the response; others
+	// are ignored. Nil keys entries by the whole query.
+	VaryQuery []string
+
+	// MaxEntries caps the number of responses kept; 1000 by default.
+	MaxEntries int
+
+	// Store keeps the responses instead of the cache itself, such as a
+	// cache.Redis shared by every instance of the app, which then share
+	// purges too. MaxEntries does not apply.
+	Store cache.Cache
+}
+
+// ResponseCache keeps the successful GET responses of the routes it wraps,
+// so mostly static pages are rendered once per TTL instead of per request.
+// Responses are keyed by path, which includes the route parameters, and by
+// the query and headers the options vary by. Responses setting cookies or
+// marked no-store or private are not kept. Kept responses get an ETag, so
+// clients holding them get 304 Not Modified.
+type ResponseCache struct {
+	options CacheOptions
+
+	mu      sync.Mutex
+	entries map[string]*cachedResponse
+	now     func() time.Time
+}
+
+type cachedResponse struct {
+	path    string
+	header  http.Header
+	body    []byte
+	stored  time.Time
+	expires time.Time
+}
+
+// storedResponse is a cachedResponse as a Store keeps it.
+type storedResponse struct {
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+	Stored time.Time   `json:"stored"`
+}
+
+// NewResponseCache creates a cache. Apply its Middleware to a group, or to a
+// single route with WithMiddleware:
+//
+//	cache := goscript.NewResponseCache(goscript.CacheOptions{TTL: 5 * time.Minute})
+//	router.GET("/docs/:page", goscript.WithMiddleware(docsPage, cache.Middleware()))
+func NewResponseCache(options CacheOptions) *ResponseCache {
+	if options.TTL <= 0 {
+		options.TTL = time.Minute
+	}
+	if options.MaxEntries <= 0 {
+		options.MaxEntries = 1000
+	}
+	for i, name := range options.VaryHeaders {
+		options.VaryHeaders[i] = http.CanonicalHeaderKey(name)
+	}
+
+	return &ResponseCache{options: options, entries: make(map[string]*cachedResponse), now: time.Now}
+}
+
+// Middleware serves kept responses and keeps new ones. Responses say
+// whether they were kept in an X-Cache header of HIT or MISS.
+func (c *ResponseCache) Middleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !bufferable(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			for _, name := range c.options.VaryHeaders {
+				w.Header().Add("Vary", name)
+			}
+
+			key := c.key(r)
+			if entry := c.get(key); entry != nil {
+				header := w.Header()
+				for name, values := range entry.header {
+					header[name] = values
+				}
+				header.Set("X-Cache", "HIT")
+				header.Set("Age", strconv.Itoa(int(c.now().Sub(entry.stored)/time.Second)))
+				writeBuffered(w, r, http.StatusOK, entry.body)
+				return
+			}
+
+			w.Header().Set("X-Cache", "MISS")
+			writer := &bufferWriter{ResponseWriter: w}
+			next.ServeHTTP(writer, r)
+			if writer.streamed {
+				return
+			}
+
+			if writer.status == http.StatusOK {
+				if w.Header().Get("ETag") == "" {
+					w.Header().Set("ETag", bodyETag(writer.body.Bytes()))
+				}
+				if r.Method == http.MethodGet && cacheable(w.Header()) {
+					c.put(key, r.URL.Path, w.Header(), writer.body.Bytes())
+				}
+			}
+			writeBuffered(w, r, writer.status, writer.body.Bytes())
+		})
+	}
+}
+
+// Purge drops the responses kept for paths starting with a prefix, such as
+// a page that was just edited, and returns how many there were. "/" drops
+// them all. With a Store, prefixes match whole path segments, so "/docs"
+// drops "/docs/intro" but not "/docsets", and the count is not known.
+func (c *ResponseCache) Purge(prefix string) int {
+	if c.options.Store != nil {
+		c.options.Store.Invalidate(context.Background(), pathTag(prefix))
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	purged := 0
+	for key, entry := range c.entries {
+		if strings.HasPrefix(entry.path, prefix) {
+			delete(c.entries, key)
+			purged++
+		}
+	}
+	return purged
+}
+
+// Len returns the number of responses kept, including expired ones not yet
+// dropped, or 0 with a Store.
+func (c *ResponseCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// key identifies a request's response. HEAD requests share GET's.
+func (c *ResponseCache) key(r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(r.URL.Path)
+
+	query := r.URL.Query()
+	if c.options.VaryQuery != nil {
+		kept := url.Values{}
+		for _, name := range c.options.VaryQuery {
+			if values, ok := query[name]; ok {
+				kept[name] = values
+			}
+		}
+		query = kept
+	}
+	if len(query) > 0 {
+		// Encode sorts by name, so parameter order does not matter
+		b.WriteString("?" + query.Encode())
+	}
+
+	for _, name := range c.options.VaryHeaders {
+		b.WriteString("\n" + name + ": " + strings.Join(r.Header.Values(name), ", "))
+	}
+	return b.String()
+}
+
+func (c *ResponseCache) get(key string) *cachedResponse {
+	if c.options.Store != nil {
+		data, found, err := c.options.Store.Get(context.Background(), storeKey(key))
+		var stored storedResponse
+		if err != nil || !found || json.Unmarshal(data, &stored) != nil {
+			return nil
+		}
+		return &cachedResponse{header: stored.Header, body: stored.Body, stored: stored.Stored}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	if !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		return nil
+	}
+	return entry
+}
+
+func (c *ResponseCache) put(key, path string, header http.Header, body []byte) {
+	now := c.now()
+	entry := &cachedResponse{
+		path:    path,
+		header:  header.Clone(),
+		body:    append([]byte(nil), body...),
+		stored:  now,
+		expires: now.Add(c.options.TTL),
+	}
+	// The per-request headers are set again on each hit
+	for _, name := range []string{"X-Cache", "Age", "Vary", "Date", RequestIDHeader} {
+		delete(entry.header, name)
+	}
+
+	if c.options.Store != nil {
+		data, err := json.Marshal(storedResponse{Header: entry.header, Body: entry.body, Stored: now})
+		if err == nil {
+			c.options.Store.Set(context.Background(), storeKey(key), data, c.options.TTL, pathTags(path)...)
+		}
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.options.MaxEntries {
+		c.evict(now)
+	}
+	c.entries[key] = entry
+}
+
+// evict drops expired responses or, if none have expired, the oldest.
+func (c *ResponseCache) evict(now time.Time) {
+	var oldestKey string
+	var oldest *cachedResponse
+	expired := false
+	for key, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, key)
+			expired = true
+		} else if oldest == nil || entry.stored.Before(oldest.stored) {
+			oldestKey, oldest = key, entry
+		}
+	}
+	if !expired && oldest != nil {
+		delete(c.entries, oldestKey)
+	}
+}
+
+// storeKey is the Store key of a response key, hashed as keys hold
+// header values.
+func storeKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "response:" + hex.EncodeToString(sum[:])
+}
+
+// pathTag is the Store tag of a path and the paths below it.
+func pathTag(path string) string {
+	if trimmed := strings.TrimRight(path, "/"); trimmed != "" {
+		return "response:" + trimmed
+	}
+	return "response:/"
+}
+
+// pathTags tags a response with its path and each path above it, so
+// purging any of them drops it.
+func pathTags(path string) []string {
+	tags := []string{pathTag("/")}
+	for i := 1; i < len(path); i++ {
+		if path[i] == '/' {
+			tags = append(tags, pathTag(path[:i]))
+		}
+	}
+	if !strings.HasSuffix(path, "/") {
+		tags = append(tags, pathTag(path))
+	}
+	return tags
+}
+
+// cacheable reports whether response headers allow keeping the response for
+// other requests.
+func cacheable(header http.Header) bool {
+	if len(header.Values("Set-Cookie")) > 0 {
+		return false
+	}
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			switch strings.ToLower(strings.TrimSpace(directive)) {
+			case "no-store", "private", "no-cache":
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// bufferable reports whether a response to a request may be buffered: a
+// GET or HEAD that is not upgrading the connection, as WebSockets do.
+func bufferable(r *http.Request) bool {
+	return (r.Method == http.MethodGet || r.Method == http.MethodHead) && r.Header.Get("Upgrade") == ""
+}
+
+// bufferWriter holds a response body until the handler returns, or streams
+// it once it passes maxBufferedBody.
+type bufferWriter struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	streamed bool
+}
+
+func (w *bufferWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *bufferWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.streamed {
+		return w.ResponseWriter.Write(data)
+	}
+	if w.body.Len()+len(data) <= maxBufferedBody {
+		return w.body.Write(data)
+	}
+
+	w.streamed = true
+	w.ResponseWriter.WriteHeader(w.status)
+	if _, err := w.ResponseWriter.Write(w.body.Bytes()); err != nil {
+		return 0, err
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// writeBuffered sends a buffered response, or 304 Not Modified when it is
+// successful and the request's If-None-Match has its ETag.
+func writeBuffered(w http.ResponseWriter, r *http.Request, status int, body []byte) {
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	header := w.Header()
+	if status == http.StatusOK && etagMatches(r.Header.Get("If-None-Match"), header.Get("ETag")) {
+		header.Del("Content-Type")
+		header.Del("Content-Length")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
+
+// bodyETag returns a strong ETag hashing a body.
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:12]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header lists an ETag,
+// comparing weakly as RFC 9110 asks.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,194 @@
+// Package cache is a key/value cache with expiry and tags, kept in memory
+// or in Redis so several instances share entries and invalidations.
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Cache keeps values for a while. A zero TTL keeps a value until it is
+// deleted or evicted. Tags group entries, such as those built from one
+// record, so Invalidate can drop them together.
+type Cache interface {
+	// Get returns a value and whether it was found
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error
+
+	Delete(ctx context.Context, keys ...string) error
+
+	// TTL returns how long a value is kept for, zero if it does not
+	// expire, and whether it was found
+	TTL(ctx context.Context, key string) (time.Duration, bool, error)
+
+	// Invalidate deletes the entries with any of the tags
+	Invalidate(ctx context.Context, tags ...string) error
+}
+
+// Memory is a Cache within the process.
+type Memory struct {
+	mutex      sync.Mutex
+	entries    map[string]*memoryEntry
+	tags       map[string]map[string]bool
+	maxEntries int
+	now        func() time.Time
+}
+
+// memoryEntry is a value, when it expires and its tags.
+type memoryEntry struct {
+	value   []byte
+	stored  time.Time
+	expires time.Time
+	tags    []string
+}
+
+var _ Cache = (*Memory)(nil)
+
+// NewMemory creates a cache keeping up to maxEntries values, evicting the
+// expired ones or else the oldest when full; 0 keeps 10000.
+func NewMemory(maxEntries int) *Memory {
+	if maxEntries <= 0 {
+		maxEntries = 10000
+	}
+	return &Memory{
+		entries:    make(map[string]*memoryEntry),
+		tags:       make(map[string]map[string]bool),
+		maxEntries: maxEntries,
+		now:        time.Now,
+	}
+}
+
+// Get returns a value that has not expired.
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entry := m.entry(key)
+	if entry == nil {
+		return nil, false, nil
+	}
+	return append([]byte(nil), entry.value...), true, nil
+}
+
+// Set stores a value.
+func (m *Memory) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := m.now()
+	entry := &memoryEntry{value: append([]byte(nil), value...), stored: now, tags: tags}
+	if ttl > 0 {
+		entry.expires = now.Add(ttl)
+	}
+	if _, ok := m.entries[key]; ok {
+		m.remove(key)
+	} else if len(m.entries) >= m.maxEntries {
+		m.evict(now)
+	}
+
+	m.entries[key] = entry
+	for _, tag := range tags {
+		if m.tags[tag] == nil {
+			m.tags[tag] = make(map[string]bool)
+		}
+		m.tags[tag][key] = true
+	}
+	return nil
+}
+
+// Delete removes values.
+func (m *Memory) Delete(ctx context.Context, keys ...string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, key := range keys {
+		m.remove(key)
+	}
+	return nil
+}
+
+// TTL returns how long a value has left.
+func (m *Memory) TTL(ctx context.Context, key string) (time.Duration, bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entry := m.entry(key)
+	if entry == nil {
+		return 0, false, nil
+	}
+	if entry.expires.IsZero() {
+		return 0, true, nil
+	}
+	return entry.expires.Sub(m.now()), true, nil
+}
+
+// Invalidate removes the values with any of the tags.
+func (m *Memory) Invalidate(ctx context.Context, tags ...string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, tag := range tags {
+		for key := range m.tags[tag] {
+			m.remove(key)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of values kept, including expired ones not yet
+// dropped.
+func (m *Memory) Len() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return len(m.entries)
+}
+
+// entry returns a value that has not expired, dropping it if it has; the
+// caller holds the mutex.
+func (m *Memory) entry(key string) *memoryEntry {
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil
+	}
+	if !entry.expires.IsZero() && !m.now().Before(entry.expires) {
+		m.remove(key)
+		return nil
+	}
+	return entry
+}
+
+// remove drops a value and its tags; the caller holds the mutex.
+func (m *Memory) remove(key string) {
+	entry, ok := m.entries[key]
+	if !ok {
+		return
+	}
+	delete(m.entries, key)
+	for _, tag := range entry.tags {
+		delete(m.tags[tag], key)
+		if len(m.tags[tag]) == 0 {
+			delete(m.tags, tag)
+		}
+	}
+}
+
+// evict drops expired values or, if none have expired, the oldest.
+func (m *Memory) evict(now time.Time) {
+	var oldestKey string
+	var oldest *memoryEntry
+	expired := false
+	for key, entry := range m.entries {
+		if !entry.expires.IsZero() && !now.Before(entry.expires) {
+			m.remove(key)
+			expired = true
+		} else if oldest == nil || entry.stored.Before(oldest.stored) {
+			oldestKey, oldest = key, entry
+		}
+	}
+	if !expired && oldest != nil {
+		m.remove(oldestKey)
+	}
+}
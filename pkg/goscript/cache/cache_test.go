@@ -0,0 +1,233 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	memory := NewMemory(3)
+	memory.now = func() time.Time { return now }
+	testCache(t, memory, func(d time.Duration) { now = now.Add(d) })
+
+	for i := 0; i < 5; i++ {
+		memory.Set(context.Background(), strconv.Itoa(i), nil, 0)
+		now = now.Add(time.Second)
+	}
+	if _, found, _ := memory.Get(context.Background(), "1"); found || memory.Len() != 3 {
+		t.Fatalf("expected the oldest values to be evicted, %d kept", memory.Len())
+	}
+}
+
+func TestRedis(t *testing.T) {
+	server := newFakeRedis(t)
+	first, err := DialRedis("redis://" + server.address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	testCache(t, first, server.advance)
+
+	// Another instance sees the first one's entries and invalidations
+	second, err := DialRedis("redis://" + server.address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer second.Close()
+	ctx := context.Background()
+	first.Set(ctx, "page:/about", []byte("<h1>About</h1>"), time.Minute, "pages")
+	if value, found, err := second.Get(ctx, "page:/about"); err != nil || !found || string(value) != "<h1>About</h1>" {
+		t.Fatalf("expected the entry to be shared, got %q %v %v", value, found, err)
+	}
+	second.Invalidate(ctx, "pages")
+	if _, found, _ := first.Get(ctx, "page:/about"); found {
+		t.Fatal("expected the invalidation to be shared")
+	}
+	if left := server.ttl("goscript:cache:tag:pages"); left != -2 {
+		t.Fatalf("expected the tag to be deleted, has ttl %d", left)
+	}
+}
+
+// testCache checks the behaviour every Cache shares; advance moves its clock.
+func testCache(t *testing.T, cache Cache, advance func(time.Duration)) {
+	ctx := context.Background()
+	cache.Set(ctx, "user:1", []byte(`{"name":"Ada"}`), time.Minute, "users", "user:1")
+	cache.Set(ctx, "user:2", []byte(`{"name":"Grace"}`), 2*time.Minute, "users")
+	cache.Set(ctx, "settings", []byte(`{}`), 0)
+
+	if value, found, err := cache.Get(ctx, "user:1"); err != nil || !found || string(value) != `{"name":"Ada"}` {
+		t.Fatalf("unexpected user:1 %q %v %v", value, found, err)
+	}
+	if ttl, found, _ := cache.TTL(ctx, "user:2"); !found || ttl <= time.Minute || ttl > 2*time.Minute {
+		t.Fatalf("unexpected ttl %v %v", ttl, found)
+	}
+	if ttl, found, _ := cache.TTL(ctx, "settings"); !found || ttl != 0 {
+		t.Fatalf("expected settings to be kept for good, got %v %v", ttl, found)
+	}
+
+	advance(90 * time.Second)
+	if _, found, _ := cache.Get(ctx, "user:1"); found {
+		t.Fatal("expected user:1 to expire")
+	}
+	if _, found, _ := cache.Get(ctx, "user:2"); !found {
+		t.Fatal("expected user:2 to be kept")
+	}
+
+	cache.Invalidate(ctx, "users")
+	if _, found, _ := cache.Get(ctx, "user:2"); found {
+		t.Fatal("expected invalidating users to drop user:2")
+	}
+	cache.Delete(ctx, "settings")
+	if _, found, _ := cache.TTL(ctx, "settings"); found {
+		t.Fatal("expected settings to be deleted")
+	}
+}
+
+// fakeRedis is a server speaking just enough Redis for the cache.
+type fakeRedis struct {
+	address string
+	mutex   sync.Mutex
+	strings map[string]string
+	sets    map[string]map[string]bool
+	expires map[string]time.Time
+	now     time.Time
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	server := &fakeRedis{
+		address: listener.Addr().String(),
+		strings: map[string]string{},
+		sets:    map[string]map[string]bool{},
+		expires: map[string]time.Time{},
+		now:     time.Now(),
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *fakeRedis) advance(d time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.now = s.now.Add(d)
+}
+
+func (s *fakeRedis) ttl(key string) int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.pttl(key)
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		count, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		args := make([]string, count)
+		for i := range args {
+			line, _ = reader.ReadString('\n')
+			size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			data := make([]byte, size+2)
+			io.ReadFull(reader, data)
+			args[i] = string(data[:size])
+		}
+		s.mutex.Lock()
+		reply := s.run(args)
+		s.mutex.Unlock()
+		io.WriteString(conn, reply)
+	}
+}
+
+func (s *fakeRedis) run(args []string) string {
+	for key, expires := range s.expires {
+		if !s.now.Before(expires) {
+			s.del(key)
+		}
+	}
+	switch args[0] {
+	case "GET":
+		if value, ok := s.strings[args[1]]; ok {
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+		}
+		return "$-1\r\n"
+	case "SET":
+		s.del(args[1])
+		s.strings[args[1]] = args[2]
+		if len(args) == 5 {
+			ms, _ := strconv.Atoi(args[4])
+			s.expires[args[1]] = s.now.Add(time.Duration(ms) * time.Millisecond)
+		}
+		return "+OK\r\n"
+	case "DEL":
+		for _, key := range args[1:] {
+			s.del(key)
+		}
+		return ":1\r\n"
+	case "PTTL":
+		return fmt.Sprintf(":%d\r\n", s.pttl(args[1]))
+	case "PEXPIRE":
+		ms, _ := strconv.Atoi(args[2])
+		s.expires[args[1]] = s.now.Add(time.Duration(ms) * time.Millisecond)
+		return ":1\r\n"
+	case "PERSIST":
+		delete(s.expires, args[1])
+		return ":1\r\n"
+	case "SADD":
+		if s.sets[args[1]] == nil {
+			s.sets[args[1]] = map[string]bool{}
+		}
+		s.sets[args[1]][args[2]] = true
+		return ":1\r\n"
+	case "SMEMBERS":
+		reply := fmt.Sprintf("*%d\r\n", len(s.sets[args[1]]))
+		for member := range s.sets[args[1]] {
+			reply += fmt.Sprintf("$%d\r\n%s\r\n", len(member), member)
+		}
+		return reply
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func (s *fakeRedis) pttl(key string) int64 {
+	_, isString := s.strings[key]
+	_, isSet := s.sets[key]
+	if !isString && !isSet {
+		return -2
+	}
+	if expires, ok := s.expires[key]; ok {
+		return int64(expires.Sub(s.now) / time.Millisecond)
+	}
+	return -1
+}
+
+func (s *fakeRedis) del(key string) {
+	delete(s.strings, key)
+	delete(s.sets, key)
+	delete(s.expires, key)
+}
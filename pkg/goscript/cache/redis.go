@@ -0,0 +1,215 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/internal/redis"
+)
+
+// maxIdleConns is how many connections a Redis cache keeps open between
+// commands.
+const maxIdleConns = 8
+
+// Redis is a Cache in Redis, shared by every instance using the same
+// server and Prefix. Tags are Redis sets of the keys they group.
+type Redis struct {
+	// Prefix is put before keys; "goscript:cache:" by default
+	Prefix string
+
+	options redis.Options
+	mutex   sync.Mutex
+	idle    []*redis.Conn
+	closed  bool
+}
+
+var _ Cache = (*Redis)(nil)
+
+// DialRedis connects to Redis at an address such as
+// "redis://:password@localhost:6379/0".
+func DialRedis(address string) (*Redis, error) {
+	options, err := redis.ParseURL(address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := redis.Dial(options)
+	if err != nil {
+		return nil, err
+	}
+	return &Redis{Prefix: "goscript:cache:", options: options, idle: []*redis.Conn{conn}}, nil
+}
+
+// Get returns a value.
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	var value []byte
+	found := false
+	err := r.with(ctx, func(conn *redis.Conn) error {
+		reply, err := conn.Do("GET", r.Prefix+key)
+		if text, ok := reply.(string); ok {
+			value, found = []byte(text), true
+		}
+		return err
+	})
+	return value, found, err
+}
+
+// Set stores a value and adds its key to its tags, which are kept as long
+// as their longest-lived value.
+func (r *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags ...string) error {
+	return r.with(ctx, func(conn *redis.Conn) error {
+		command := []string{"SET", r.Prefix + key, string(value)}
+		if ttl > 0 {
+			command = append(command, "PX", strconv.FormatInt(milliseconds(ttl), 10))
+		}
+		if _, err := conn.Do(command...); err != nil {
+			return err
+		}
+
+		for _, tag := range tags {
+			tagKey := r.tagKey(tag)
+			// PTTL is -2 for a new set and -1 for one that does not expire
+			reply, err := conn.Do("PTTL", tagKey)
+			if err != nil {
+				return err
+			}
+			left, _ := reply.(int64)
+			if _, err := conn.Do("SADD", tagKey, r.Prefix+key); err != nil {
+				return err
+			}
+			switch {
+			case ttl <= 0 && left >= 0:
+				_, err = conn.Do("PERSIST", tagKey)
+			case ttl > 0 && left != -1 && left < milliseconds(ttl):
+				_, err = conn.Do("PEXPIRE", tagKey, strconv.FormatInt(milliseconds(ttl), 10))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Delete removes values.
+func (r *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.with(ctx, func(conn *redis.Conn) error {
+		command := []string{"DEL"}
+		for _, key := range keys {
+			command = append(command, r.Prefix+key)
+		}
+		_, err := conn.Do(command...)
+		return err
+	})
+}
+
+// TTL returns how long a value has left.
+func (r *Redis) TTL(ctx context.Context, key string) (time.Duration, bool, error) {
+	var left int64
+	err := r.with(ctx, func(conn *redis.Conn) error {
+		reply, err := conn.Do("PTTL", r.Prefix+key)
+		left, _ = reply.(int64)
+		return err
+	})
+	switch {
+	case err != nil || left == -2:
+		return 0, false, err
+	case left == -1:
+		return 0, true, nil
+	}
+	return time.Duration(left) * time.Millisecond, true, nil
+}
+
+// Invalidate removes the values with any of the tags, on every instance.
+func (r *Redis) Invalidate(ctx context.Context, tags ...string) error {
+	return r.with(ctx, func(conn *redis.Conn) error {
+		for _, tag := range tags {
+			tagKey := r.tagKey(tag)
+			reply, err := conn.Do("SMEMBERS", tagKey)
+			if err != nil {
+				return err
+			}
+			command := []string{"DEL", tagKey}
+			members, _ := reply.([]interface{})
+			for _, member := range members {
+				if key, ok := member.(string); ok {
+					command = append(command, key)
+				}
+			}
+			if _, err := conn.Do(command...); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close closes the idle connections; commands fail afterwards.
+func (r *Redis) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.closed = true
+	for _, conn := range r.idle {
+		conn.Close()
+	}
+	r.idle = nil
+	return nil
+}
+
+// tagKey is the key of a tag's set.
+func (r *Redis) tagKey(tag string) string {
+	return r.Prefix + "tag:" + tag
+}
+
+// with runs commands on an idle connection, or a new one, and keeps it for
+// later unless it failed.
+func (r *Redis) with(ctx context.Context, run func(conn *redis.Conn) error) error {
+	r.mutex.Lock()
+	if r.closed {
+		r.mutex.Unlock()
+		return errors.New("cache: Redis cache closed")
+	}
+	var conn *redis.Conn
+	if n := len(r.idle); n > 0 {
+		conn, r.idle = r.idle[n-1], r.idle[:n-1]
+	}
+	r.mutex.Unlock()
+
+	if conn == nil {
+		var err error
+		if conn, err = redis.Dial(r.options); err != nil {
+			return err
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	err := run(conn)
+	var redisErr redis.Error
+	if err != nil && !errors.As(err, &redisErr) {
+		conn.Close()
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.closed || len(r.idle) >= maxIdleConns {
+		conn.Close()
+	} else {
+		r.idle = append(r.idle, conn)
+	}
+	return err
+}
+
+// milliseconds rounds a TTL up to whole milliseconds.
+func milliseconds(ttl time.Duration) int64 {
+	return int64((ttl + time.Millisecond - 1) / time.Millisecond)
+}
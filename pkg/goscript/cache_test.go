@@ -0,0 +1,113 @@
+package goscript
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/cache"
+)
+
+func TestETag(t *testing.T) {
+	router := NewRouter()
+	router.GET("/page", WithMiddleware(echoRoute("page"), ETag()))
+
+	first := serve(router, "GET", "/page")
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" || first.Body.String() != "page map[]" {
+		t.Fatalf("expected a tagged page, got %d %q %q", first.Code, etag, first.Body.String())
+	}
+
+	request := httptest.NewRequest("GET", "/page", nil)
+	request.Header.Set("If-None-Match", `"other", W/`+etag)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusNotModified || recorder.Body.Len() != 0 {
+		t.Fatalf("expected 304 for a matching ETag, got %d %q", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestResponseCache(t *testing.T) {
+	renders := 0
+	cache := NewResponseCache(CacheOptions{TTL: time.Minute, VaryHeaders: []string{"accept-language"}, VaryQuery: []string{"page"}})
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	router := NewRouter()
+	router.GET("/docs/:name", WithMiddleware(func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		renders++
+		if params["name"] == "private" {
+			w.Header().Set("Cache-Control", "private")
+		}
+		fmt.Fprintf(w, "%s %s %d", params["name"], r.Header.Get("Accept-Language"), renders)
+	}, cache.Middleware()))
+
+	get := func(path, language string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("GET", path, nil)
+		request.Header.Set("Accept-Language", language)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	first := get("/docs/intro?page=1&utm=mail", "en")
+	if first.Header().Get("X-Cache") != "MISS" || first.Body.String() != "intro en 1" || first.Header().Get("Vary") != "Accept-Language" {
+		t.Fatalf("unexpected first response %v %q", first.Header(), first.Body.String())
+	}
+
+	now = now.Add(5 * time.Second)
+	hit := get("/docs/intro?utm=web&page=1", "en")
+	if hit.Header().Get("X-Cache") != "HIT" || hit.Body.String() != "intro en 1" || hit.Header().Get("Age") != "5" {
+		t.Fatalf("expected the kept response, got %v %q", hit.Header(), hit.Body.String())
+	}
+	if body := get("/docs/intro?page=1", "fr").Body.String(); body != "intro fr 2" {
+		t.Fatalf("expected a separate entry per language, got %q", body)
+	}
+	if body := get("/docs/other?page=1", "en").Body.String(); body != "other en 3" {
+		t.Fatalf("expected a separate entry per route parameter, got %q", body)
+	}
+	get("/docs/private", "en")
+	if body := get("/docs/private", "en").Body.String(); body != "private en 5" {
+		t.Fatalf("expected private responses not to be kept, got %q", body)
+	}
+
+	now = now.Add(time.Minute)
+	if body := get("/docs/intro?page=1", "en").Body.String(); body != "intro en 6" {
+		t.Fatalf("expected an expired response to render again, got %q", body)
+	}
+	if purged := cache.Purge("/docs/"); purged != 3 || cache.Len() != 0 {
+		t.Fatalf("expected three responses purged, got %d", purged)
+	}
+}
+
+func TestResponseCacheStore(t *testing.T) {
+	store := cache.NewMemory(0)
+	renders := 0
+	instances := []*Router{}
+	caches := []*ResponseCache{}
+	for i := 0; i < 2; i++ {
+		responses := NewResponseCache(CacheOptions{Store: store})
+		router := NewRouter()
+		router.GET("/docs/:name", WithMiddleware(func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+			renders++
+			fmt.Fprintf(w, "%s %d", params["name"], renders)
+		}, responses.Middleware()))
+		instances = append(instances, router)
+		caches = append(caches, responses)
+	}
+
+	if body := serve(instances[0], "GET", "/docs/intro").Body.String(); body != "intro 1" {
+		t.Fatalf("unexpected first render %q", body)
+	}
+	shared := serve(instances[1], "GET", "/docs/intro")
+	if shared.Header().Get("X-Cache") != "HIT" || shared.Body.String() != "intro 1" {
+		t.Fatalf("expected the other instance to serve the kept response, got %v %q", shared.Header(), shared.Body.String())
+	}
+
+	caches[1].Purge("/docs")
+	if body := serve(instances[0], "GET", "/docs/intro").Body.String(); body != "intro 2" {
+		t.Fatalf("expected the purge to reach every instance, got %q", body)
+	}
+}
@@ -0,0 +1,571 @@
+// Package cli runs command line tools such as gopm: nested commands, typed
+// flags that can be set from the environment, generated help and shell
+// completion. A tool declares its commands and runs the one named by the
+// arguments:
+//
+//	root := &cli.Command{
+//		Name:  "gopm",
+//		Short: "Go Package Manager",
+//		Commands: []*cli.Command{{
+//			Name:  "audit",
+//			Usage: "[dir]",
+//			Short: "Check dependencies for known vulnerabilities",
+//			Flags: []*cli.Flag{{Name: "json", Usage: "Print the report as JSON", Value: false}},
+//			Args:  cli.MaxArgs(1),
+//			Run: func(c *cli.Context) error {
+//				return audit(c.Arg(0), c.Bool("json"))
+//			},
+//		}},
+//	}
+//	root.Main()
+//
+// Every tool gets "help [command]", -h and --help, and "completion bash|zsh".
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Command is a command of a tool, or the tool itself at the root.
+type Command struct {
+	Name string
+
+	// Usage describes the arguments after the name, such as "<model> [output]"
+	Usage string
+
+	// Short is the one-line description shown in lists of commands, and
+	// Long the description shown in the command's help
+	Short string
+	Long  string
+
+	// Group titles the commands listed together in their parent's help, such
+	// as "Database Commands"
+	Group    string
+	Examples []string
+	Hidden   bool
+
+	// Flags apply to the command and its subcommands
+	Flags    []*Flag
+	Commands []*Command
+
+	// Args checks the arguments, such as ExactArgs(1); any are accepted when
+	// it is nil
+	Args func(args []string) error
+
+	// Before runs ahead of the command and of each of its subcommands, from
+	// the root down, such as to apply flags shared by the whole tool
+	Before func(c *Context) error
+
+	// Run runs the command; commands without one show their help
+	Run func(c *Context) error
+
+	// Stdout and Stderr are where help, completion and errors are written,
+	// os.Stdout and os.Stderr when nil. Only the root's are used.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	parent *Command
+
+	// rawArgs leaves the arguments unparsed, flags included
+	rawArgs bool
+}
+
+// Flag is a flag of a command. Its Value is the default and sets its type:
+// bool, string, int, float64, time.Duration or []string. Lists take a value
+// for each use of the flag, or comma-separated values from the environment.
+type Flag struct {
+	Name    string
+	Short   string
+	Aliases []string
+	Usage   string
+	Value   interface{}
+
+	// Env names the environment variable the flag falls back to when it is
+	// not on the command line
+	Env string
+
+	// Choices are the values a string flag accepts, offered by completion
+	Choices []string
+
+	// Placeholder names the value in help, such as "file"
+	Placeholder string
+	Hidden      bool
+}
+
+// Context is a parsed command line, given to the command it names.
+type Context struct {
+	Command *Command
+	Args    []string
+	Stdout  io.Writer
+	Stderr  io.Writer
+
+	values map[*Flag]interface{}
+	set    map[*Flag]bool
+}
+
+// ErrHelp is returned by Parse when the command line asks for help.
+var ErrHelp = errors.New("help requested")
+
+// UsageError is a mistake in the command line, reported with the usage of
+// the command.
+type UsageError struct {
+	Command *Command
+	Err     error
+}
+
+func (e *UsageError) Error() string { return e.Err.Error() }
+
+func (e *UsageError) Unwrap() error { return e.Err }
+
+// Usagef returns a UsageError, for commands to refuse their arguments.
+func Usagef(format string, args ...interface{}) error {
+	return &UsageError{Err: fmt.Errorf(format, args...)}
+}
+
+// ExitError ends the tool with a status code, after printing Err when set.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error { return e.Err }
+
+// Exit returns an ExitError ending the tool with code without a message,
+// such as when a check failed after printing its report.
+func Exit(code int) error {
+	return &ExitError{Code: code}
+}
+
+// Path returns the names of the command and its parents, such as
+// "gopm jetpack budget".
+func (c *Command) Path() string {
+	if c.parent == nil {
+		return c.Name
+	}
+	return c.parent.Path() + " " + c.Name
+}
+
+// Root returns the tool's command.
+func (c *Command) Root() *Command {
+	for c.parent != nil {
+		c = c.parent
+	}
+	return c
+}
+
+// Find returns the subcommand with a name, or nil.
+func (c *Command) Find(name string) *Command {
+	for _, sub := range c.Commands {
+		if sub.Name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// Main runs the command named by os.Args and exits with its status.
+func (c *Command) Main() {
+	os.Exit(c.Exit(c.Execute(os.Args[1:])))
+}
+
+// Execute parses args and runs the command they name, or writes its help.
+func (c *Command) Execute(args []string) error {
+	ctx, err := c.Parse(args)
+	if errors.Is(err, ErrHelp) {
+		return ctx.Command.WriteHelp(ctx.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+
+	if ctx.Command.Run == nil {
+		ctx.Command.WriteHelp(ctx.Stdout)
+		return Exit(1)
+	}
+
+	var chain []*Command
+	for cmd := ctx.Command; cmd != nil; cmd = cmd.parent {
+		chain = append([]*Command{cmd}, chain...)
+	}
+	for _, cmd := range chain {
+		if cmd.Before != nil {
+			if err := cmd.Before(ctx); err != nil {
+				return ctx.usage(err)
+			}
+		}
+	}
+	return ctx.usage(ctx.Command.Run(ctx))
+}
+
+// Exit writes an error returned by Execute and returns the status code to
+// exit with: 0 without an error, 2 for usage errors and 1 for the others
+// unless an ExitError gives its own.
+func (c *Command) Exit(err error) int {
+	if err == nil {
+		return 0
+	}
+	stderr := c.stderr()
+
+	var exit *ExitError
+	if errors.As(err, &exit) {
+		if exit.Err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", exit.Err)
+		}
+		return exit.Code
+	}
+
+	fmt.Fprintf(stderr, "Error: %v\n", err)
+	var usage *UsageError
+	if errors.As(err, &usage) {
+		cmd := usage.Command
+		if cmd == nil {
+			cmd = c
+		}
+		fmt.Fprintf(stderr, "Usage: %s\n", cmd.UsageLine())
+		if cmd.parent == nil {
+			fmt.Fprintf(stderr, "Run '%s help' for more information.\n", cmd.Name)
+		} else {
+			fmt.Fprintf(stderr, "Run '%s help %s' for more information.\n", c.Name, strings.TrimPrefix(cmd.Path(), c.Name+" "))
+		}
+		return 2
+	}
+	return 1
+}
+
+// Parse finds the command args name and parses its flags and arguments.
+// Flags of a command also apply to its subcommands, and may come before
+// or after the arguments; "--" ends the flags.
+func (c *Command) Parse(args []string) (*Context, error) {
+	c.init()
+	ctx := &Context{
+		Command: c,
+		Stdout:  c.stdout(),
+		Stderr:  c.stderr(),
+		values:  map[*Flag]interface{}{},
+		set:     map[*Flag]bool{},
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case ctx.Command.rawArgs:
+			ctx.Args = append(ctx.Args, args[i:]...)
+			i = len(args)
+		case arg == "--":
+			ctx.Args = append(ctx.Args, args[i+1:]...)
+			i = len(args)
+		case len(arg) > 1 && arg[0] == '-':
+			name, value, hasValue := strings.TrimLeft(arg, "-"), "", false
+			if equals := strings.IndexByte(name, '='); equals >= 0 {
+				name, value, hasValue = name[:equals], name[equals+1:], true
+			}
+			flag := ctx.Command.flag(name)
+			if flag == nil {
+				if name == "help" || name == "h" {
+					return ctx, ErrHelp
+				}
+				return ctx, ctx.usage(Usagef("unknown flag %s", arg))
+			}
+
+			if _, isBool := flag.Value.(bool); !isBool && !hasValue {
+				i++
+				if i >= len(args) {
+					return ctx, ctx.usage(Usagef("missing value for %s", arg))
+				}
+				value = args[i]
+			} else if isBool && !hasValue {
+				value = "true"
+			}
+			if err := ctx.setFlag(flag, value, false); err != nil {
+				return ctx, ctx.usage(Usagef("invalid value %q for %s: %v", value, arg, err))
+			}
+		case len(ctx.Args) == 0 && len(ctx.Command.Commands) > 0:
+			sub := ctx.Command.Find(arg)
+			if sub == nil {
+				if ctx.Command.Run == nil {
+					return ctx, ctx.usage(Usagef("unknown command %q for %q", arg, ctx.Command.Path()))
+				}
+				ctx.Args = append(ctx.Args, arg)
+				continue
+			}
+			ctx.Command = sub
+		default:
+			ctx.Args = append(ctx.Args, arg)
+		}
+	}
+
+	for cmd := ctx.Command; cmd != nil; cmd = cmd.parent {
+		for _, flag := range cmd.Flags {
+			if ctx.set[flag] || flag.Env == "" {
+				continue
+			}
+			if value, ok := os.LookupEnv(flag.Env); ok && value != "" {
+				if err := ctx.setFlag(flag, value, true); err != nil {
+					return ctx, ctx.usage(Usagef("invalid value %q for $%s: %v", value, flag.Env, err))
+				}
+			}
+		}
+	}
+
+	if ctx.Command.Args != nil {
+		if err := ctx.Command.Args(ctx.Args); err != nil {
+			return ctx, ctx.usage(Usagef("%v", err))
+		}
+	} else if ctx.Command.Run == nil && len(ctx.Args) > 0 {
+		return ctx, ctx.usage(Usagef("unknown command %q for %q", ctx.Args[0], ctx.Command.Path()))
+	}
+	return ctx, nil
+}
+
+// init links the commands to their parents, adds the help and completion
+// commands to the root and checks the flags are declared correctly.
+func (c *Command) init() {
+	if c.parent == nil {
+		if c.Find("help") == nil {
+			c.Commands = append(c.Commands, helpCommand())
+		}
+		if c.Find("completion") == nil {
+			c.Commands = append(c.Commands, completionCommand(), completeCommand())
+		}
+	}
+	for _, flag := range c.Flags {
+		switch flag.Value.(type) {
+		case bool, string, int, float64, time.Duration, []string:
+		default:
+			panic(fmt.Sprintf("cli: flag --%s of %s has unsupported type %T", flag.Name, c.Path(), flag.Value))
+		}
+	}
+	for _, sub := range c.Commands {
+		sub.parent = c
+		sub.init()
+	}
+}
+
+// flag returns the flag of the command or its parents with a name, or nil.
+func (c *Command) flag(name string) *Flag {
+	for cmd := c; cmd != nil; cmd = cmd.parent {
+		for _, flag := range cmd.Flags {
+			if flag.Name == name || (flag.Short != "" && flag.Short == name) {
+				return flag
+			}
+			for _, alias := range flag.Aliases {
+				if alias == name {
+					return flag
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Command) stdout() io.Writer {
+	if root := c.Root(); root.Stdout != nil {
+		return root.Stdout
+	}
+	return os.Stdout
+}
+
+func (c *Command) stderr() io.Writer {
+	if root := c.Root(); root.Stderr != nil {
+		return root.Stderr
+	}
+	return os.Stderr
+}
+
+// setFlag parses a value of a flag. The command line replaces lists from
+// the environment or the defaults, then adds to them.
+func (c *Context) setFlag(flag *Flag, text string, fromEnv bool) error {
+	var value interface{}
+	var err error
+	switch flag.Value.(type) {
+	case bool:
+		value, err = strconv.ParseBool(text)
+	case string:
+		value = text
+	case int:
+		value, err = strconv.Atoi(text)
+	case float64:
+		value, err = strconv.ParseFloat(text, 64)
+	case time.Duration:
+		value, err = time.ParseDuration(text)
+	case []string:
+		var items []string
+		if fromEnv {
+			for _, item := range strings.Split(text, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+		} else {
+			items = []string{text}
+		}
+		if previous, ok := c.values[flag].([]string); ok && c.set[flag] {
+			items = append(previous, items...)
+		}
+		value = items
+	}
+	if err != nil {
+		if numErr, ok := err.(*strconv.NumError); ok {
+			err = numErr.Err
+		}
+		return err
+	}
+
+	if len(flag.Choices) > 0 {
+		values, _ := value.([]string)
+		if text, ok := value.(string); ok {
+			values = []string{text}
+		}
+		for _, v := range values {
+			if !contains(flag.Choices, v) {
+				return fmt.Errorf("expected %s", strings.Join(flag.Choices, ", "))
+			}
+		}
+	}
+
+	c.values[flag] = value
+	if !fromEnv {
+		c.set[flag] = true
+	}
+	return nil
+}
+
+// usage fills in the command of a UsageError.
+func (c *Context) usage(err error) error {
+	var usage *UsageError
+	if errors.As(err, &usage) && usage.Command == nil {
+		usage.Command = c.Command
+	}
+	return err
+}
+
+// value returns the value of a flag, panicking when the command has no
+// such flag as that is a mistake in the tool.
+func (c *Context) value(name string) interface{} {
+	flag := c.Command.flag(name)
+	if flag == nil {
+		panic(fmt.Sprintf("cli: %s has no flag --%s", c.Command.Path(), name))
+	}
+	if value, ok := c.values[flag]; ok {
+		return value
+	}
+	return flag.Value
+}
+
+// String returns the value of a string flag.
+func (c *Context) String(name string) string {
+	value, _ := c.value(name).(string)
+	return value
+}
+
+// Bool returns the value of a bool flag.
+func (c *Context) Bool(name string) bool {
+	value, _ := c.value(name).(bool)
+	return value
+}
+
+// Int returns the value of an int flag.
+func (c *Context) Int(name string) int {
+	value, _ := c.value(name).(int)
+	return value
+}
+
+// Float returns the value of a float64 flag.
+func (c *Context) Float(name string) float64 {
+	value, _ := c.value(name).(float64)
+	return value
+}
+
+// Duration returns the value of a time.Duration flag.
+func (c *Context) Duration(name string) time.Duration {
+	value, _ := c.value(name).(time.Duration)
+	return value
+}
+
+// Strings returns the values of a []string flag.
+func (c *Context) Strings(name string) []string {
+	value, _ := c.value(name).([]string)
+	return value
+}
+
+// IsSet reports whether a flag was given on the command line.
+func (c *Context) IsSet(name string) bool {
+	c.value(name)
+	return c.set[c.Command.flag(name)]
+}
+
+// Arg returns the argument at index i, or "" when there are fewer.
+func (c *Context) Arg(i int) string {
+	if i < len(c.Args) {
+		return c.Args[i]
+	}
+	return ""
+}
+
+// NoArgs refuses any arguments.
+func NoArgs(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("unexpected argument %q", args[0])
+	}
+	return nil
+}
+
+// ExactArgs accepts exactly n arguments.
+func ExactArgs(n int) func(args []string) error {
+	return RangeArgs(n, n)
+}
+
+// MinArgs accepts n or more arguments.
+func MinArgs(n int) func(args []string) error {
+	return RangeArgs(n, -1)
+}
+
+// MaxArgs accepts up to n arguments.
+func MaxArgs(n int) func(args []string) error {
+	return RangeArgs(0, n)
+}
+
+// RangeArgs accepts between min and max arguments, or min or more when max
+// is negative.
+func RangeArgs(min, max int) func(args []string) error {
+	return func(args []string) error {
+		switch {
+		case len(args) < min && max < 0:
+			return fmt.Errorf("missing arguments, expected at least %s", plural(min, "argument"))
+		case len(args) < min:
+			return fmt.Errorf("missing arguments, expected %s", plural(min, "argument"))
+		case max >= 0 && len(args) > max:
+			return fmt.Errorf("unexpected argument %q", args[max])
+		}
+		return nil
+	}
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTool returns a tool recording the context its commands run with.
+func testTool(ran **Context) *Command {
+	record := func(c *Context) error {
+		*ran = c
+		return nil
+	}
+	return &Command{
+		Name:  "tool",
+		Short: "A test tool",
+		Flags: []*Flag{{Name: "registry", Usage: "Registry URL", Value: "https://registry.example", Env: "TOOL_REGISTRY"}},
+		Commands: []*Command{
+			{
+				Name:  "build",
+				Usage: "[packages...]",
+				Short: "Build packages",
+				Group: "Build Commands",
+				Flags: []*Flag{
+					{Name: "output", Short: "o", Usage: "Output file", Value: "", Placeholder: "file"},
+					{Name: "race", Usage: "Detect races", Value: false},
+					{Name: "jobs", Short: "j", Usage: "Parallel jobs", Value: 4, Env: "TOOL_JOBS"},
+					{Name: "timeout", Usage: "Build timeout", Value: time.Minute},
+					{Name: "tag", Aliases: []string{"tags"}, Usage: "Build tags", Value: []string{}, Env: "TOOL_TAGS"},
+					{Name: "mode", Usage: "Build mode", Value: "debug", Choices: []string{"debug", "release"}},
+				},
+				Run: record,
+			},
+			{
+				Name:  "db",
+				Short: "Database commands",
+				Commands: []*Command{
+					{Name: "migrate", Usage: "<version>", Short: "Run migrations", Args: ExactArgs(1), Run: record},
+				},
+			},
+		},
+	}
+}
+
+func TestParseFlags(t *testing.T) {
+	var ran *Context
+	tool := testTool(&ran)
+	t.Setenv("TOOL_JOBS", "2")
+	t.Setenv("TOOL_TAGS", "a, b")
+
+	err := tool.Execute([]string{"--registry=http://local", "build", "-o", "out.bin", "./cmd", "--race", "--timeout", "90s", "--tags", "x", "--tag=y", "--", "--literal"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ran.Command.Path() != "tool build" || !reflect.DeepEqual(ran.Args, []string{"./cmd", "--literal"}) {
+		t.Fatalf("unexpected command %s %v", ran.Command.Path(), ran.Args)
+	}
+	if ran.String("registry") != "http://local" || ran.String("output") != "out.bin" || !ran.Bool("race") ||
+		ran.Int("jobs") != 2 || ran.Duration("timeout") != 90*time.Second || ran.String("mode") != "debug" {
+		t.Fatalf("unexpected flags %v", ran.values)
+	}
+	if tags := ran.Strings("tag"); !reflect.DeepEqual(tags, []string{"x", "y"}) {
+		t.Fatalf("expected the command line to replace the environment's tags, got %v", tags)
+	}
+	if !ran.IsSet("output") || ran.IsSet("jobs") {
+		t.Fatalf("expected only command line flags to be set")
+	}
+
+	if err := tool.Execute([]string{"build"}); err != nil || !reflect.DeepEqual(ran.Strings("tag"), []string{"a", "b"}) {
+		t.Fatalf("expected the environment's tags, got %v %v", ran.Strings("tag"), err)
+	}
+
+	for args, message := range map[string]string{
+		"build --bogus":     "unknown flag --bogus",
+		"build --jobs":      "missing value for --jobs",
+		"build --jobs ten":  `invalid value "ten" for --jobs: invalid syntax`,
+		"build --mode fast": `invalid value "fast" for --mode: expected debug, release`,
+		"deploy":            `unknown command "deploy" for "tool"`,
+		"db migrate":        "missing arguments, expected 1 argument",
+		"db migrate 1 2":    `unexpected argument "2"`,
+		"db rollback":       `unknown command "rollback" for "tool db"`,
+		"completion fish":   `unsupported shell "fish"`,
+		"help db bogus":     `unknown command "bogus" for "tool db"`,
+	} {
+		var usage *UsageError
+		err := tool.Execute(strings.Fields(args))
+		if !errors.As(err, &usage) || !strings.Contains(err.Error(), message) {
+			t.Fatalf("%s: expected a usage error with %q, got %v", args, message, err)
+		}
+	}
+}
+
+func TestHelpAndExit(t *testing.T) {
+	var ran *Context
+	tool := testTool(&ran)
+	var stdout, stderr bytes.Buffer
+	tool.Stdout, tool.Stderr = &stdout, &stderr
+
+	if err := tool.Execute([]string{"build", "--help"}); err != nil || ran != nil {
+		t.Fatalf("expected help instead of running, got %v", err)
+	}
+	for _, want := range []string{
+		"tool build - Build packages",
+		"Usage:\n  tool build [packages...] [flags]",
+		"-o, --output file",
+		"-j, --jobs int",
+		"Parallel jobs (default 4) [$TOOL_JOBS]",
+		"--mode debug|release",
+		"Global Flags:",
+		"--registry string",
+	} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Fatalf("expected the help to contain %q, got:\n%s", want, stdout.String())
+		}
+	}
+
+	stdout.Reset()
+	if err := tool.Execute([]string{"help"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Build Commands:\n  build  Build packages", "Commands:\n  db", "completion", "Run 'tool help <command>'"} {
+		if !strings.Contains(stdout.String(), want) {
+			t.Fatalf("expected the help to contain %q, got:\n%s", want, stdout.String())
+		}
+	}
+	if strings.Contains(stdout.String(), "__complete") {
+		t.Fatalf("expected hidden commands to be left out of help")
+	}
+
+	if code := tool.Exit(tool.Execute([]string{"db"})); code != 1 {
+		t.Fatalf("expected a command group without a subcommand to fail, got %d", code)
+	}
+	if code := tool.Exit(tool.Execute([]string{"db", "migrate"})); code != 2 ||
+		!strings.Contains(stderr.String(), "Usage: tool db migrate <version>\nRun 'tool help db migrate'") {
+		t.Fatalf("expected a usage error, got %d %q", code, stderr.String())
+	}
+	if code := tool.Exit(&ExitError{Code: 3, Err: errors.New("failed")}); code != 3 || !strings.HasSuffix(stderr.String(), "Error: failed\n") {
+		t.Fatalf("expected the exit code and message, got %d %q", code, stderr.String())
+	}
+}
+
+func TestComplete(t *testing.T) {
+	var ran *Context
+	tool := testTool(&ran)
+	for words, want := range map[string][]string{
+		"":                      {"build", "db", "help", "completion"},
+		"b":                     {"build"},
+		"db ":                   {"migrate"},
+		"build --r":             {"--race", "--registry"},
+		"build --mode ":         {"debug", "release"},
+		"build --mode=debug ./": nil,
+		"build ./cmd ":          nil,
+	} {
+		got := tool.Complete(strings.Split(words, " "))
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%q: expected %v, got %v", words, want, got)
+		}
+	}
+
+	var stdout bytes.Buffer
+	tool.Stdout = &stdout
+	if err := tool.Execute([]string{"__complete", "build", "--"}); err != nil || !strings.Contains(stdout.String(), "--output\n") {
+		t.Fatalf("expected the flags, got %q %v", stdout.String(), err)
+	}
+	stdout.Reset()
+	if err := tool.Execute([]string{"completion", "bash"}); err != nil || !strings.Contains(stdout.String(), "complete -F _tool tool") {
+		t.Fatalf("expected the bash script, got %q %v", stdout.String(), err)
+	}
+}
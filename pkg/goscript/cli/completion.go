@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// bashCompletion asks the tool for the candidates of the word being
+// completed, falling back to file names. Words are split on spaces only,
+// so commands such as css:build complete despite ":" breaking bash words.
+const bashCompletion = `# bash completion for %[1]s
+# Load it with: source <(%[1]s completion bash)
+_%[2]s() {
+	local line="${COMP_LINE:0:COMP_POINT}"
+	local -a words
+	read -ra words <<< "$line"
+	if [[ "$line" == *" " ]]; then
+		words+=("")
+	fi
+	local cur="${words[${#words[@]}-1]}"
+
+	local IFS=$'\n'
+	COMPREPLY=($(%[1]s __complete "${words[@]:1}" 2>/dev/null))
+	if [[ ${#COMPREPLY[@]} -eq 0 ]]; then
+		COMPREPLY=($(compgen -f -- "$cur"))
+		return
+	fi
+	if [[ "$cur" == *:* && "$COMP_WORDBREAKS" == *:* ]]; then
+		local prefix="${cur%%"${cur##*:}"}"
+		COMPREPLY=("${COMPREPLY[@]#"$prefix"}")
+	fi
+}
+complete -F _%[2]s %[1]s
+`
+
+// zshCompletion does the same for zsh, from fpath or sourced.
+const zshCompletion = `#compdef %[1]s
+# Load it with: source <(%[1]s completion zsh)
+_%[2]s() {
+	local -a candidates
+	candidates=("${(@f)$(%[1]s __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+	if [[ -z "${candidates[1]}" ]]; then
+		_files
+	else
+		compadd -Q -- "${candidates[@]}"
+	fi
+}
+if [[ "${funcstack[1]}" == "_%[2]s" ]]; then
+	_%[2]s "$@"
+else
+	compdef _%[2]s %[1]s
+fi
+`
+
+// completionCommand writes the completion script of a shell.
+func completionCommand() *Command {
+	return &Command{
+		Name:  "completion",
+		Usage: "bash|zsh",
+		Short: "Print the shell completion script",
+		Long: "Prints the script completing commands, flags and flag values in bash or zsh. " +
+			"Load it from ~/.bashrc or ~/.zshrc as its first lines show.",
+		Args: ExactArgs(1),
+		Run: func(c *Context) error {
+			root := c.Command.Root()
+			function := strings.NewReplacer("-", "_", ".", "_").Replace(root.Name)
+			switch c.Args[0] {
+			case "bash":
+				fmt.Fprintf(c.Stdout, bashCompletion, root.Name, function)
+			case "zsh":
+				fmt.Fprintf(c.Stdout, zshCompletion, root.Name, function)
+			default:
+				return Usagef("unsupported shell %q, expected bash or zsh", c.Args[0])
+			}
+			return nil
+		},
+	}
+}
+
+// completeCommand is what the completion scripts run: given the words
+// after the tool's name, the last being completed, it prints the
+// candidates one per line.
+func completeCommand() *Command {
+	return &Command{
+		Name:    "__complete",
+		Hidden:  true,
+		rawArgs: true,
+		Run: func(c *Context) error {
+			for _, candidate := range c.Command.Root().Complete(c.Args) {
+				fmt.Fprintln(c.Stdout, candidate)
+			}
+			return nil
+		},
+	}
+}
+
+// Complete returns the candidates for the last of words, typed after the
+// tool's name: the values of the flag before it, the flags when it starts
+// with "-", else the subcommands. None means any file will do.
+func (c *Command) Complete(words []string) []string {
+	c.init()
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	typed, current := words[:len(words)-1], words[len(words)-1]
+
+	cmd := c
+	args := 0
+	for i := 0; i < len(typed); i++ {
+		word := typed[i]
+		switch {
+		case word == "--":
+			return nil
+		case strings.HasPrefix(word, "-"):
+			flag := cmd.flag(strings.TrimLeft(word, "-"))
+			if flag == nil || strings.Contains(word, "=") {
+				continue
+			}
+			if _, isBool := flag.Value.(bool); !isBool {
+				if i == len(typed)-1 {
+					return matching(flag.Choices, current)
+				}
+				i++
+			}
+		case args == 0 && cmd.Find(word) != nil:
+			cmd = cmd.Find(word)
+		default:
+			args++
+		}
+	}
+
+	var candidates []string
+	if strings.HasPrefix(current, "-") {
+		for parent := cmd; parent != nil; parent = parent.parent {
+			for _, flag := range parent.visibleFlags() {
+				candidates = append(candidates, "--"+flag.Name)
+			}
+		}
+		return matching(append(candidates, "--help"), current)
+	}
+	if args == 0 {
+		for _, sub := range cmd.Commands {
+			if !sub.Hidden {
+				candidates = append(candidates, sub.Name)
+			}
+		}
+	}
+	return matching(candidates, current)
+}
+
+// matching returns the candidates starting with prefix.
+func matching(candidates []string, prefix string) []string {
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches
+}
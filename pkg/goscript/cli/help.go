@@ -0,0 +1,215 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// UsageLine returns the synopsis of the command, such as
+// "gopm audit [dir] [flags]".
+func (c *Command) UsageLine() string {
+	line := c.Path()
+	if c.Run == nil && len(c.Commands) > 0 {
+		line += " <command>"
+	}
+	if c.Usage != "" {
+		line += " " + c.Usage
+	}
+	if len(c.visibleFlags()) > 0 {
+		line += " [flags]"
+	}
+	return line
+}
+
+// WriteHelp writes the help of the command: its description, usage,
+// subcommands by group, flags and examples.
+func (c *Command) WriteHelp(w io.Writer) error {
+	c.Root().init()
+	var b strings.Builder
+
+	title := c.Path()
+	if c.Short != "" {
+		title += " - " + c.Short
+	}
+	fmt.Fprintf(&b, "%s\n\nUsage:\n  %s\n", title, c.UsageLine())
+	if c.Long != "" {
+		fmt.Fprintf(&b, "\n%s\n", wrap(strings.TrimSpace(c.Long), 76))
+	}
+
+	var groups []string
+	byGroup := map[string][]*Command{}
+	for _, sub := range c.Commands {
+		if sub.Hidden {
+			continue
+		}
+		group := sub.Group
+		if group == "" {
+			group = "Commands"
+		}
+		if _, ok := byGroup[group]; !ok {
+			groups = append(groups, group)
+		}
+		byGroup[group] = append(byGroup[group], sub)
+	}
+	for _, group := range groups {
+		fmt.Fprintf(&b, "\n%s:\n", group)
+		table := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+		for _, sub := range byGroup[group] {
+			fmt.Fprintf(table, "  %s\t%s\n", sub.Name, sub.Short)
+		}
+		table.Flush()
+	}
+
+	flags := c.visibleFlags()
+	var inherited []*Flag
+	for parent := c.parent; parent != nil; parent = parent.parent {
+		inherited = append(inherited, parent.visibleFlags()...)
+	}
+	writeFlags(&b, "Flags", append(flags, &Flag{Name: "help", Short: "h", Usage: "Show help", Value: false}))
+	if len(inherited) > 0 {
+		writeFlags(&b, "Global Flags", inherited)
+	}
+
+	if len(c.Examples) > 0 {
+		b.WriteString("\nExamples:\n")
+		for _, example := range c.Examples {
+			fmt.Fprintf(&b, "  %s\n", example)
+		}
+	}
+
+	if len(byGroup) > 0 {
+		root := c.Root()
+		path := strings.TrimPrefix(c.Path()+" ", root.Name+" ")
+		fmt.Fprintf(&b, "\nRun '%s help %s<command>' for more information on a command.\n", root.Name, path)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeFlags writes a table of flags with their value types, defaults and
+// environment variables.
+func writeFlags(b *strings.Builder, title string, flags []*Flag) {
+	fmt.Fprintf(b, "\n%s:\n", title)
+	table := tabwriter.NewWriter(b, 0, 0, 2, ' ', 0)
+	for _, flag := range flags {
+		names := "    --" + flag.Name
+		if flag.Short != "" {
+			names = "-" + flag.Short + ", --" + flag.Name
+		}
+		if placeholder := flag.placeholder(); placeholder != "" {
+			names += " " + placeholder
+		}
+
+		usage := flag.Usage
+		if value := flag.defaultText(); value != "" {
+			usage += " (default " + value + ")"
+		}
+		if flag.Env != "" {
+			usage += " [$" + flag.Env + "]"
+		}
+		fmt.Fprintf(table, "  %s\t%s\n", names, strings.TrimSpace(usage))
+	}
+	table.Flush()
+}
+
+// placeholder names the value of a flag in help.
+func (f *Flag) placeholder() string {
+	switch {
+	case f.Placeholder != "":
+		return f.Placeholder
+	case len(f.Choices) > 0 && len(f.Choices) <= 4:
+		return strings.Join(f.Choices, "|")
+	}
+	switch f.Value.(type) {
+	case string:
+		return "string"
+	case int:
+		return "int"
+	case float64:
+		return "number"
+	case time.Duration:
+		return "duration"
+	case []string:
+		return "list"
+	}
+	return ""
+}
+
+// defaultText returns the default of a flag for help, or "" when it is the
+// zero value.
+func (f *Flag) defaultText() string {
+	switch value := f.Value.(type) {
+	case string:
+		return value
+	case int:
+		if value != 0 {
+			return fmt.Sprint(value)
+		}
+	case float64:
+		if value != 0 {
+			return fmt.Sprint(value)
+		}
+	case time.Duration:
+		if value != 0 {
+			return value.String()
+		}
+	case []string:
+		return strings.Join(value, ",")
+	}
+	return ""
+}
+
+// wrap breaks text into lines of up to width characters at spaces.
+func wrap(text string, width int) string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			if line != "" && len(line)+1+len(word) > width {
+				lines = append(lines, line)
+				line = ""
+			}
+			if line != "" {
+				line += " "
+			}
+			line += word
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// visibleFlags returns the flags shown in help.
+func (c *Command) visibleFlags() []*Flag {
+	var flags []*Flag
+	for _, flag := range c.Flags {
+		if !flag.Hidden {
+			flags = append(flags, flag)
+		}
+	}
+	return flags
+}
+
+// helpCommand writes the help of the tool or of the command named by its
+// arguments.
+func helpCommand() *Command {
+	return &Command{
+		Name:  "help",
+		Usage: "[command]",
+		Short: "Show help for a command",
+		Run: func(c *Context) error {
+			cmd := c.Command.Root()
+			for _, name := range c.Args {
+				sub := cmd.Find(name)
+				if sub == nil {
+					return Usagef("unknown command %q for %q", name, cmd.Path())
+				}
+				cmd = sub
+			}
+			return cmd.WriteHelp(c.Stdout)
+		},
+	}
+}
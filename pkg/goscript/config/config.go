@@ -0,0 +1,447 @@
+// Package config loads Config structs from YAML, TOML and JSON files and
+// environment variables, validates them and reloads them when the files
+// change. Defaults are the values the struct holds before loading, such as
+// those a module's DefaultConfig returns:
+//
+//	loader := config.New("APP", "app.yaml", "app.local.yaml")
+//	apiConfig := api.DefaultConfig()
+//	err := loader.Section("api").Load(apiConfig)
+//
+// Settings are named by a field's config tag, else its json tag, else its
+// name in snake_case, so APIConfig.MaxConcurrent is api.max_concurrent in
+// files and APP_API_MAX_CONCURRENT in the environment.
+package config
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/naming"
+)
+
+// Validator is implemented by configs that check their values. Load calls
+// Validate on the config and on each config nested in it.
+type Validator interface {
+	Validate() error
+}
+
+// Loader loads configs from files and the environment.
+type Loader struct {
+	// Files are read in order, later ones overriding earlier ones. Files
+	// that do not exist are skipped, so local overrides can be optional.
+	// The extension picks the format: .yaml, .yml, .toml or .json.
+	Files []string
+
+	// EnvPrefix starts the names of the environment variables overriding
+	// files, such as "APP"; empty reads none
+	EnvPrefix string
+
+	// Interval is how often Watch checks the files; two seconds by default
+	Interval time.Duration
+
+	// OnError is called by Watch with reloads that failed, the previous
+	// config staying in use
+	OnError func(err error)
+
+	// section is the path of the settings Load reads
+	section []string
+
+	// lookupEnv reads the environment; os.LookupEnv unless a test sets it
+	lookupEnv func(name string) (string, bool)
+}
+
+// New creates a loader reading the files and the environment variables
+// starting with envPrefix.
+func New(envPrefix string, files ...string) *Loader {
+	return &Loader{Files: files, EnvPrefix: envPrefix, Interval: 2 * time.Second}
+}
+
+// Section returns a loader reading the settings under a name, such as "api"
+// for api.timeout in files and APP_API_TIMEOUT in the environment.
+func (l *Loader) Section(name string) *Loader {
+	section := *l
+	section.section = append(append([]string(nil), l.section...), strings.Split(name, ".")...)
+	return &section
+}
+
+// Load sets the fields of the struct target points to from the files, then
+// from the environment, and validates it. Settings the files do not know
+// are an error, to catch typos.
+func (l *Loader) Load(target interface{}) error {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config: Load needs a pointer to a struct, got %T", target)
+	}
+
+	settings, err := l.read()
+	if err != nil {
+		return err
+	}
+	for _, name := range l.section {
+		nested, ok := settings[name].(map[string]interface{})
+		if !ok {
+			nested = map[string]interface{}{}
+		}
+		settings = nested
+	}
+
+	path := strings.Join(l.section, ".")
+	if err := decode(value.Elem(), settings, path); err != nil {
+		return err
+	}
+	if l.EnvPrefix != "" {
+		env := append([]string{l.EnvPrefix}, l.section...)
+		if err := l.applyEnv(value.Elem(), strings.ToUpper(strings.Join(env, "_")), path); err != nil {
+			return err
+		}
+	}
+	return validate(value, path)
+}
+
+// read parses and merges the files.
+func (l *Loader) read() (map[string]interface{}, error) {
+	settings := map[string]interface{}{}
+	for _, file := range l.Files {
+		data, err := ioutil.ReadFile(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var parsed map[string]interface{}
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".yaml", ".yml":
+			parsed, err = parseYAML(string(data))
+		case ".toml":
+			parsed, err = parseTOML(string(data))
+		case ".json":
+			decoder := json.NewDecoder(bytes.NewReader(data))
+			decoder.UseNumber()
+			err = decoder.Decode(&parsed)
+		default:
+			err = fmt.Errorf("unknown format")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("config: %s: %v", file, err)
+		}
+		merge(settings, parsed)
+	}
+	return settings, nil
+}
+
+// merge copies the settings of src into dst, merging nested tables.
+func merge(dst, src map[string]interface{}) {
+	for key, value := range src {
+		nested, isTable := value.(map[string]interface{})
+		existing, hasTable := dst[key].(map[string]interface{})
+		if isTable && hasTable {
+			merge(existing, nested)
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+// field is a struct field and the name of its setting.
+type field struct {
+	value reflect.Value
+	name  string
+}
+
+// fields returns the settable fields of a struct by setting name.
+func fields(value reflect.Value) []field {
+	kind := value.Type()
+	result := []field{}
+	for i := 0; i < kind.NumField(); i++ {
+		structField := kind.Field(i)
+		if structField.PkgPath != "" {
+			continue
+		}
+		if name := settingName(structField); name != "" {
+			result = append(result, field{value: value.Field(i), name: name})
+		}
+	}
+	return result
+}
+
+// settingName names a field's setting, or returns "" for fields tagged
+// config:"-" and funcs, channels and mutexes, which are not settings.
+func settingName(structField reflect.StructField) string {
+	switch structField.Type.Kind() {
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return ""
+	}
+	if structField.Type.PkgPath() == "sync" {
+		return ""
+	}
+	for _, tag := range []string{"config", "json"} {
+		if name := strings.Split(structField.Tag.Get(tag), ",")[0]; name == "-" {
+			return ""
+		} else if name != "" {
+			return name
+		}
+	}
+	return naming.SnakeCase(structField.Name)
+}
+
+// decode sets a struct's fields from a table of settings.
+func decode(value reflect.Value, settings map[string]interface{}, path string) error {
+	known := map[string]field{}
+	for _, f := range fields(value) {
+		known[f.name] = f
+	}
+	for name, setting := range settings {
+		f, ok := known[name]
+		if !ok {
+			return fmt.Errorf("config: unknown setting %s", join(path, name))
+		}
+		if err := set(f.value, setting, join(path, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// set stores a setting, as parsed from a file or read from the
+// environment as a string, in a field.
+func set(value reflect.Value, setting interface{}, path string) error {
+	if setting == nil {
+		value.Set(reflect.Zero(value.Type()))
+		return nil
+	}
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		return set(value.Elem(), setting, path)
+	}
+
+	text, isText := setting.(string)
+	if isText && value.Type() != durationType && reflect.PtrTo(value.Type()).Implements(textUnmarshalerType) {
+		if err := value.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text)); err != nil {
+			return fmt.Errorf("config: %s: %v", path, err)
+		}
+		return nil
+	}
+	invalid := func() error {
+		return fmt.Errorf("config: %s: cannot use %#v as %s", path, setting, value.Type())
+	}
+
+	if value.Type() == durationType {
+		if !isText {
+			return fmt.Errorf("config: %s: use a duration such as \"30s\", not %#v", path, setting)
+		}
+		duration, err := time.ParseDuration(text)
+		if err != nil {
+			return fmt.Errorf("config: %s: %v", path, err)
+		}
+		value.SetInt(int64(duration))
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		switch setting.(type) {
+		case string, bool, json.Number:
+			value.SetString(fmt.Sprint(setting))
+		default:
+			return invalid()
+		}
+	case reflect.Bool:
+		switch typed := setting.(type) {
+		case bool:
+			value.SetBool(typed)
+		case string:
+			parsed, err := strconv.ParseBool(typed)
+			if err != nil {
+				return invalid()
+			}
+			value.SetBool(parsed)
+		default:
+			return invalid()
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, err := strconv.ParseInt(numberText(setting), 10, 64)
+		if err != nil || value.OverflowInt(number) {
+			return invalid()
+		}
+		value.SetInt(number)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		number, err := strconv.ParseUint(numberText(setting), 10, 64)
+		if err != nil || value.OverflowUint(number) {
+			return invalid()
+		}
+		value.SetUint(number)
+	case reflect.Float32, reflect.Float64:
+		number, err := strconv.ParseFloat(numberText(setting), 64)
+		if err != nil {
+			return invalid()
+		}
+		value.SetFloat(number)
+	case reflect.Slice:
+		items, ok := setting.([]interface{})
+		if isText {
+			// Lists in the environment are comma separated
+			items = []interface{}{}
+			for _, item := range strings.Split(text, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+		} else if !ok {
+			return invalid()
+		}
+		slice := reflect.MakeSlice(value.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := set(slice.Index(i), item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		value.Set(slice)
+	case reflect.Map:
+		table, ok := setting.(map[string]interface{})
+		if !ok || value.Type().Key().Kind() != reflect.String {
+			return invalid()
+		}
+		if value.IsNil() {
+			value.Set(reflect.MakeMap(value.Type()))
+		}
+		for key, item := range table {
+			element := reflect.New(value.Type().Elem()).Elem()
+			if existing := value.MapIndex(reflect.ValueOf(key).Convert(value.Type().Key())); existing.IsValid() {
+				element.Set(existing)
+			}
+			if err := set(element, item, join(path, key)); err != nil {
+				return err
+			}
+			value.SetMapIndex(reflect.ValueOf(key).Convert(value.Type().Key()), element)
+		}
+	case reflect.Struct:
+		table, ok := setting.(map[string]interface{})
+		if !ok {
+			return invalid()
+		}
+		return decode(value, table, path)
+	case reflect.Interface:
+		if !reflect.TypeOf(setting).AssignableTo(value.Type()) {
+			return invalid()
+		}
+		value.Set(reflect.ValueOf(setting))
+	default:
+		return invalid()
+	}
+	return nil
+}
+
+// numberText formats a number for parsing; files give numbers as
+// json.Number and the environment as strings.
+func numberText(setting interface{}) string {
+	switch setting.(type) {
+	case json.Number, string:
+		return fmt.Sprint(setting)
+	}
+	return ""
+}
+
+// applyEnv sets the fields named by environment variables, descending into
+// nested structs.
+func (l *Loader) applyEnv(value reflect.Value, prefix, path string) error {
+	lookup := l.lookupEnv
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+
+	for _, f := range fields(value) {
+		name := prefix + "_" + strings.ToUpper(strings.ReplaceAll(f.name, ".", "_"))
+		target := f.value
+		if target.Kind() == reflect.Ptr && target.Type().Elem().Kind() == reflect.Struct {
+			if target.IsNil() {
+				if !l.envHas(name + "_") {
+					continue
+				}
+				target.Set(reflect.New(target.Type().Elem()))
+			}
+			target = target.Elem()
+		}
+		if target.Kind() == reflect.Struct && !reflect.PtrTo(target.Type()).Implements(textUnmarshalerType) {
+			if err := l.applyEnv(target, name, join(path, f.name)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if setting, ok := lookup(name); ok {
+			if err := set(f.value, setting, name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// envHas reports whether a variable starting with prefix is set.
+func (l *Loader) envHas(prefix string) bool {
+	if l.lookupEnv != nil {
+		// Tests set lookupEnv without listing the environment
+		return true
+	}
+	for _, variable := range os.Environ() {
+		if strings.HasPrefix(variable, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// validate calls Validate on a config and the configs nested in it.
+func validate(value reflect.Value, path string) error {
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		if value.Elem().Kind() == reflect.Struct {
+			for _, f := range fields(value.Elem()) {
+				nested := f.value
+				if nested.Kind() == reflect.Struct {
+					nested = nested.Addr()
+				}
+				if err := validate(nested, join(path, f.name)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if validator, ok := value.Interface().(Validator); ok && value.Kind() == reflect.Ptr {
+		if err := validator.Validate(); err != nil {
+			if path == "" {
+				return fmt.Errorf("config: %v", err)
+			}
+			return fmt.Errorf("config: %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// join appends a setting's name to a path.
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
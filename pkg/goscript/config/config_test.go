@@ -0,0 +1,296 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type dbConfig struct {
+	ConnectionString string
+	MaxConnections   int
+	QueryTimeout     time.Duration
+	Replicas         []string
+}
+
+func (c *dbConfig) Validate() error {
+	if c.MaxConnections <= 0 {
+		return errors.New("max_connections must be positive")
+	}
+	return nil
+}
+
+type appConfig struct {
+	Name     string
+	Version  string
+	Debug    bool
+	Ratio    float64
+	Started  time.Time
+	Labels   map[string]string
+	DB       *dbConfig `config:"db"`
+	Internal string    `config:"-"`
+	Routes   []struct {
+		Path  string
+		Limit int
+	}
+}
+
+func defaults() *appConfig {
+	return &appConfig{Name: "demo", DB: &dbConfig{ConnectionString: "localhost:5432", MaxConnections: 10}}
+}
+
+func write(t *testing.T, dir, name, data string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFormats(t *testing.T) {
+	dir := t.TempDir()
+	want := &appConfig{
+		Name: "shop", Version: "1.10", Debug: true, Ratio: 0.5,
+		Started: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+		Labels:  map[string]string{"team": "web", "tier": "1"},
+		DB:      &dbConfig{ConnectionString: "db:5432", MaxConnections: 1000000, QueryTimeout: 5 * time.Second, Replicas: []string{"a", "b"}},
+		Routes: []struct {
+			Path  string
+			Limit int
+		}{{"/api", 100}, {"/admin", 5}},
+	}
+
+	for name, data := range map[string]string{
+		"app.yaml": `
+# The shop
+name: shop
+version: 1.10
+debug: true   # for now
+ratio: 0.5
+started: "2024-03-01T09:00:00Z"
+labels:
+  team: web
+  tier: 1
+db:
+  connection_string: 'db:5432'
+  max_connections: 1000000
+  query_timeout: 5s
+  replicas: [a, "b"]
+routes:
+  - path: /api
+    limit: 100
+  - path: /admin
+    limit: 5
+`,
+		"app.toml": `
+name = "shop" # the shop
+version = "1.10"
+debug = true
+ratio = 0.5
+started = 2024-03-01T09:00:00Z
+labels = { team = "web", tier = "1" }
+
+[db]
+connection_string = "db:5432"
+max_connections = 1_000_000
+query_timeout = "5s"
+replicas = [
+  "a",
+  'b',
+]
+
+[[routes]]
+path = "/api"
+limit = 100
+
+[[routes]]
+path = "/admin"
+limit = 5
+`,
+		"app.json": `{"name": "shop", "version": "1.10", "debug": true, "ratio": 0.5, "started": "2024-03-01T09:00:00Z",
+			"labels": {"team": "web", "tier": "1"},
+			"db": {"connection_string": "db:5432", "max_connections": 1000000, "query_timeout": "5s", "replicas": ["a", "b"]},
+			"routes": [{"path": "/api", "limit": 100}, {"path": "/admin", "limit": 5}]}`,
+	} {
+		got := defaults()
+		if err := New("", write(t, dir, name, data)).Load(got); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: expected %+v %+v, got %+v %+v", name, want, want.DB, got, got.DB)
+		}
+	}
+}
+
+func TestLoadOverridesAndErrors(t *testing.T) {
+	dir := t.TempDir()
+	base := write(t, dir, "app.yaml", "name: shop\ndb:\n  max_connections: 50\n  replicas:\n  - a\n")
+	local := write(t, dir, "app.local.toml", "[db]\nquery_timeout = \"2s\"\n")
+	env := map[string]string{"APP_DB_MAX_CONNECTIONS": "75", "APP_DB_REPLICAS": "x, y", "APP_DEBUG": "true"}
+
+	loader := New("APP", base, local, filepath.Join(dir, "missing.yaml"))
+	loader.lookupEnv = func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+	got := defaults()
+	if err := loader.Load(got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "shop" || !got.Debug || got.DB.ConnectionString != "localhost:5432" || got.DB.MaxConnections != 75 ||
+		got.DB.QueryTimeout != 2*time.Second || !reflect.DeepEqual(got.DB.Replicas, []string{"x", "y"}) {
+		t.Fatalf("unexpected config %+v %+v", got, got.DB)
+	}
+
+	section := &dbConfig{MaxConnections: 1}
+	if err := loader.Section("db").Load(section); err != nil || section.MaxConnections != 75 || section.QueryTimeout != 2*time.Second {
+		t.Fatalf("unexpected section %+v %v", section, err)
+	}
+
+	env["APP_DB_MAX_CONNECTIONS"] = "0"
+	if err := loader.Load(defaults()); err == nil || err.Error() != "config: db: max_connections must be positive" {
+		t.Fatalf("expected the nested config to be validated, got %v", err)
+	}
+	for data, message := range map[string]string{
+		"nmae: shop":                  "unknown setting nmae",
+		"db:\n  query_timeout: 5":     `db.query_timeout: use a duration such as "30s"`,
+		"db:\n  max_connections: ten": "db.max_connections: cannot use",
+		"name: a\n   debug: true":     "line 2: unexpected indentation",
+		"internal: x":                 "unknown setting internal",
+	} {
+		err := New("", write(t, dir, "bad.yaml", data)).Load(defaults())
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Fatalf("%q: expected an error with %q, got %v", data, message, err)
+		}
+	}
+}
+
+func TestYAMLBlocks(t *testing.T) {
+	parsed, err := parseYAML(`
+motd: |
+  Welcome!
+  # not a comment
+
+  Bye
+summary: >-
+  one
+  two
+quote: "it's # here"
+plain: don't # comment
+empty:
+nested:
+  - - a
+    - b
+  -
+    key: value
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"motd":    "Welcome!\n# not a comment\n\nBye\n",
+		"summary": "one two",
+		"quote":   "it's # here",
+		"plain":   "don't",
+		"empty":   nil,
+		"nested":  []interface{}{[]interface{}{"a", "b"}, map[string]interface{}{"key": "value"}},
+	}
+	if !reflect.DeepEqual(parsed, want) {
+		t.Fatalf("expected %#v, got %#v", want, parsed)
+	}
+}
+
+func TestTOMLMultilineStrings(t *testing.T) {
+	parsed, err := parseTOML(`
+s = '''multi
+line'''
+motd = """
+Welcome, "guest"!\t# not a comment
+Bye"""
+joined = """one \
+    two"""
+regex = '''
+^\d+ # kept
+''' # a comment
+quoted = """she said "hi\"""""
+after = 1
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"s":      "multi\nline",
+		"motd":   "Welcome, \"guest\"!\t# not a comment\nBye",
+		"joined": "one two",
+		"regex":  "^\\d+ # kept\n",
+		"quoted": `she said "hi""`,
+		"after":  json.Number("1"),
+	}
+	if !reflect.DeepEqual(parsed, want) {
+		t.Fatalf("expected %#v, got %#v", want, parsed)
+	}
+
+	for data, message := range map[string]string{
+		"s = \"\"\"open\nstill open": "line 1: unterminated string",
+		"s = '''a\nb''' extra":       "line 2: unexpected extra",
+		`s = """bad \q escape"""`:    "line 1: invalid escape in string",
+	} {
+		if _, err := parseTOML(data); err == nil || err.Error() != message {
+			t.Fatalf("%q: expected %q, got %v", data, message, err)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	file := write(t, dir, "app.yaml", "name: shop\n")
+	loader := New("", file)
+	loader.Interval = 5 * time.Millisecond
+	errs := make(chan error, 1)
+	loader.OnError = func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+
+	current := defaults()
+	if err := loader.Load(current); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan []string, 1)
+	go loader.Watch(ctx, current, func() interface{} { return defaults() }, func(next interface{}, changed []string) {
+		if next.(*appConfig).DB.MaxConnections == 20 {
+			changes <- changed
+		}
+	})
+
+	time.Sleep(20 * time.Millisecond)
+	write(t, dir, "app.yaml", "name: shop\ndb:\n  max_connections: -1\n")
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "max_connections must be positive") {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the invalid reload to fail")
+	}
+
+	write(t, dir, "app.yaml", "name: store\ndb:\n  max_connections: 20\n")
+	select {
+	case changed := <-changes:
+		if !reflect.DeepEqual(changed, []string{"db.max_connections", "name"}) {
+			t.Fatalf("unexpected changes %v", changed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the reload")
+	}
+}
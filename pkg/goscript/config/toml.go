@@ -0,0 +1,394 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML parses the TOML configs are written in: [tables],
+// [[arrays of tables]], dotted keys, strings, numbers, booleans, dates
+// (as strings), arrays and inline tables. Multi-line strings, in triple
+// quotes, may be the values of keys but not the items of arrays or inline
+// tables.
+func parseTOML(data string) (map[string]interface{}, error) {
+	document := map[string]interface{}{}
+	table := document
+	lines := strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		number := i + 1
+		line := strings.TrimSpace(stripTOMLComment(lines[i]))
+		if line == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "[["):
+			if !strings.HasSuffix(line, "]]") {
+				return nil, fmt.Errorf("line %d: invalid table %s", number, line)
+			}
+			keys, err := splitTOMLKey(line[2:len(line)-2], number)
+			if err != nil {
+				return nil, err
+			}
+			parent, err := tomlTable(document, keys[:len(keys)-1], number)
+			if err != nil {
+				return nil, err
+			}
+			last := keys[len(keys)-1]
+			array, ok := parent[last].([]interface{})
+			if parent[last] != nil && !ok {
+				return nil, fmt.Errorf("line %d: %s is not an array of tables", number, last)
+			}
+			table = map[string]interface{}{}
+			parent[last] = append(array, table)
+		case strings.HasPrefix(line, "["):
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid table %s", number, line)
+			}
+			keys, err := splitTOMLKey(line[1:len(line)-1], number)
+			if err != nil {
+				return nil, err
+			}
+			if table, err = tomlTable(document, keys, number); err != nil {
+				return nil, err
+			}
+		default:
+			equals := tomlKeyEnd(line)
+			if equals < 0 {
+				return nil, fmt.Errorf("line %d: expected key = value", number)
+			}
+			keys, err := splitTOMLKey(line[:equals], number)
+			if err != nil {
+				return nil, err
+			}
+
+			// Multi-line strings run to their closing quotes, and arrays
+			// and inline tables may span lines until they close
+			var value interface{}
+			text := strings.TrimSpace(line[equals+1:])
+			if strings.HasPrefix(text, "'''") || strings.HasPrefix(text, `"""`) {
+				raw := strings.TrimSpace(lines[i])
+				if value, i, err = parseTOMLMultiline(lines, i, strings.TrimSpace(raw[tomlKeyEnd(raw)+1:])); err != nil {
+					return nil, err
+				}
+			} else {
+				for !tomlBalanced(text) && i+1 < len(lines) {
+					i++
+					text += " " + strings.TrimSpace(stripTOMLComment(lines[i]))
+				}
+				var rest string
+				if value, rest, err = parseTOMLValue(text, number); err != nil {
+					return nil, err
+				}
+				if strings.TrimSpace(rest) != "" {
+					return nil, fmt.Errorf("line %d: unexpected %s", number, rest)
+				}
+			}
+
+			parent, err := tomlTable(table, keys[:len(keys)-1], number)
+			if err != nil {
+				return nil, err
+			}
+			key := keys[len(keys)-1]
+			if _, exists := parent[key]; exists {
+				return nil, fmt.Errorf("line %d: %s is set twice", number, key)
+			}
+			parent[key] = value
+		}
+	}
+	return document, nil
+}
+
+// parseTOMLMultiline parses the multi-line string starting text, the value
+// of a key on line i, and returns the line it ends on. A newline right
+// after the opening quotes is left out. Literal strings, in single quotes,
+// are kept as written; basic strings, in double quotes, take escapes, and a
+// backslash ending a line joins it to the next text.
+func parseTOMLMultiline(lines []string, i int, text string) (string, int, error) {
+	number := i + 1
+	delimiter, body := text[:3], text[3:]
+	end := tomlMultilineEnd(body, delimiter[0])
+	for ; end < 0; end = tomlMultilineEnd(body, delimiter[0]) {
+		if i+1 >= len(lines) {
+			return "", i, fmt.Errorf("line %d: unterminated string", number)
+		}
+		i++
+		body += "\n" + lines[i]
+	}
+	if rest := strings.TrimSpace(stripTOMLComment(body[end+3:])); rest != "" {
+		return "", i, fmt.Errorf("line %d: unexpected %s", i+1, rest)
+	}
+
+	value := strings.TrimPrefix(body[:end], "\n")
+	if delimiter == "'''" {
+		return value, i, nil
+	}
+	var b strings.Builder
+	for value != "" {
+		if strings.HasPrefix(value, "\\") {
+			if trimmed := strings.TrimLeft(value[1:], " \t"); strings.HasPrefix(trimmed, "\n") {
+				value = strings.TrimLeft(trimmed, " \t\n")
+				continue
+			}
+		}
+		if value[0] == '"' {
+			b.WriteByte('"')
+			value = value[1:]
+			continue
+		}
+		r, _, tail, err := strconv.UnquoteChar(value, '"')
+		if err != nil {
+			return "", i, fmt.Errorf("line %d: invalid escape in string", number)
+		}
+		b.WriteRune(r)
+		value = tail
+	}
+	return b.String(), i, nil
+}
+
+// tomlMultilineEnd returns the index of the quotes closing a multi-line
+// string in body, or -1. Up to two more quotes before them are part of
+// the string.
+func tomlMultilineEnd(body string, quote byte) int {
+	for i := 0; i+3 <= len(body); i++ {
+		switch {
+		case body[i] == '\\' && quote == '"':
+			i++
+		case body[i] == quote && body[i+1] == quote && body[i+2] == quote:
+			for extra := 0; extra < 2 && i+3 < len(body) && body[i+3] == quote; extra++ {
+				i++
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// tomlTable returns the table at a path of keys, creating missing tables;
+// an array of tables gives its last table.
+func tomlTable(table map[string]interface{}, keys []string, number int) (map[string]interface{}, error) {
+	for _, key := range keys {
+		switch existing := table[key].(type) {
+		case nil:
+			nested := map[string]interface{}{}
+			table[key] = nested
+			table = nested
+		case map[string]interface{}:
+			table = existing
+		case []interface{}:
+			last, ok := existing[len(existing)-1].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("line %d: %s is not a table", number, key)
+			}
+			table = last
+		default:
+			return nil, fmt.Errorf("line %d: %s is not a table", number, key)
+		}
+	}
+	return table, nil
+}
+
+// splitTOMLKey splits a dotted key, whose parts may be quoted.
+func splitTOMLKey(text string, number int) ([]string, error) {
+	var keys []string
+	for _, part := range splitOutsideQuotes(text, '.') {
+		part = strings.TrimSpace(part)
+		if strings.HasPrefix(part, `"`) {
+			unquoted, err := strconv.Unquote(part)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid key %s", number, part)
+			}
+			part = unquoted
+		} else if strings.HasPrefix(part, "'") && strings.HasSuffix(part, "'") && len(part) > 1 {
+			part = part[1 : len(part)-1]
+		}
+		if part == "" {
+			return nil, fmt.Errorf("line %d: empty key in %s", number, text)
+		}
+		keys = append(keys, part)
+	}
+	return keys, nil
+}
+
+// parseTOMLValue parses the value at the start of text and returns what
+// follows it.
+func parseTOMLValue(text string, number int) (interface{}, string, error) {
+	text = strings.TrimLeft(text, " \t")
+	if text == "" {
+		return nil, "", fmt.Errorf("line %d: missing value", number)
+	}
+
+	switch text[0] {
+	case '"':
+		end := 1
+		for ; end < len(text) && text[end] != '"'; end++ {
+			if text[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(text) {
+			return nil, "", fmt.Errorf("line %d: unterminated string", number)
+		}
+		value, err := strconv.Unquote(text[:end+1])
+		if err != nil {
+			return nil, "", fmt.Errorf("line %d: invalid string %s", number, text[:end+1])
+		}
+		return value, text[end+1:], nil
+	case '\'':
+		end := strings.IndexByte(text[1:], '\'')
+		if end < 0 {
+			return nil, "", fmt.Errorf("line %d: unterminated string", number)
+		}
+		return text[1 : end+1], text[end+2:], nil
+	case '[':
+		items := []interface{}{}
+		rest := strings.TrimLeft(text[1:], " \t")
+		for !strings.HasPrefix(rest, "]") {
+			item, after, err := parseTOMLValue(rest, number)
+			if err != nil {
+				return nil, "", err
+			}
+			items = append(items, item)
+			rest = strings.TrimLeft(after, " \t")
+			if strings.HasPrefix(rest, ",") {
+				rest = strings.TrimLeft(rest[1:], " \t")
+			} else if !strings.HasPrefix(rest, "]") {
+				return nil, "", fmt.Errorf("line %d: expected , or ] in array", number)
+			}
+		}
+		return items, rest[1:], nil
+	case '{':
+		table := map[string]interface{}{}
+		rest := strings.TrimLeft(text[1:], " \t")
+		for !strings.HasPrefix(rest, "}") {
+			equals := tomlKeyEnd(rest)
+			if equals < 0 {
+				return nil, "", fmt.Errorf("line %d: expected key = value in inline table", number)
+			}
+			keys, err := splitTOMLKey(rest[:equals], number)
+			if err != nil {
+				return nil, "", err
+			}
+			value, after, err := parseTOMLValue(rest[equals+1:], number)
+			if err != nil {
+				return nil, "", err
+			}
+			parent, err := tomlTable(table, keys[:len(keys)-1], number)
+			if err != nil {
+				return nil, "", err
+			}
+			parent[keys[len(keys)-1]] = value
+			rest = strings.TrimLeft(after, " \t")
+			if strings.HasPrefix(rest, ",") {
+				rest = strings.TrimLeft(rest[1:], " \t")
+			} else if !strings.HasPrefix(rest, "}") {
+				return nil, "", fmt.Errorf("line %d: expected , or } in inline table", number)
+			}
+		}
+		return table, rest[1:], nil
+	}
+
+	end := strings.IndexAny(text, ",]}")
+	if end < 0 {
+		end = len(text)
+	}
+	word := strings.TrimSpace(text[:end])
+	switch word {
+	case "true":
+		return true, text[end:], nil
+	case "false":
+		return false, text[end:], nil
+	}
+	if plain := strings.ReplaceAll(word, "_", ""); plain != "" {
+		if _, err := strconv.ParseFloat(plain, 64); err == nil {
+			return json.Number(plain), text[end:], nil
+		}
+	}
+	// Dates and times are kept as text, which time.Time settings parse
+	if len(word) >= 10 && word[4] == '-' && word[7] == '-' {
+		return word, text[end:], nil
+	}
+	return nil, "", fmt.Errorf("line %d: invalid value %s", number, word)
+}
+
+// tomlKeyEnd returns the index of the = ending a key, outside quotes.
+func tomlKeyEnd(text string) int {
+	parts := splitOutsideQuotes(text, '=')
+	if len(parts) < 2 {
+		return -1
+	}
+	return len(parts[0])
+}
+
+// tomlBalanced reports whether the arrays and inline tables of a value
+// are all closed.
+func tomlBalanced(text string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// splitOutsideQuotes splits text at a separator outside quoted strings.
+func splitOutsideQuotes(text string, separator byte) []string {
+	var parts []string
+	start := 0
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == separator:
+			parts = append(parts, text[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, text[start:])
+}
+
+// stripTOMLComment removes a "#" comment outside quoted strings.
+func stripTOMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
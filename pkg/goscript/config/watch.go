@@ -0,0 +1,103 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Watch reloads the config when the files change, until ctx is done. Each
+// reload loads into a fresh config from defaults, such as
+// api.DefaultConfig() wrapped in a func; if it loads and validates,
+// onChange gets it with the settings that changed, and the next reload is
+// compared with it. Failed reloads go to OnError and change nothing. Run
+// it in a goroutine:
+//
+//	current := api.DefaultConfig()
+//	loader.Section("api").Load(current)
+//	go loader.Section("api").Watch(ctx, current, func() interface{} { return api.DefaultConfig() },
+//		func(next interface{}, changed []string) { log.Printf("api config changed: %v", changed) })
+func (l *Loader) Watch(ctx context.Context, current interface{}, defaults func() interface{}, onChange func(config interface{}, changed []string)) {
+	interval := l.Interval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	seen := l.fingerprint()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		fingerprint := l.fingerprint()
+		if fingerprint == seen {
+			continue
+		}
+		seen = fingerprint
+
+		next := defaults()
+		if err := l.Load(next); err != nil {
+			if l.OnError != nil {
+				l.OnError(err)
+			}
+			continue
+		}
+		if changed := Changed(current, next); len(changed) > 0 {
+			current = next
+			onChange(next, changed)
+		}
+	}
+}
+
+// fingerprint hashes the contents of the files, missing ones included.
+func (l *Loader) fingerprint() [sha256.Size]byte {
+	hash := sha256.New()
+	for _, file := range l.Files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			hash.Write([]byte{0})
+			continue
+		}
+		hash.Write([]byte{1})
+		hash.Write(data)
+		hash.Write([]byte{0})
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], hash.Sum(nil))
+	return sum
+}
+
+// Changed lists the settings that differ between two configs of the same
+// type, by their dotted names, sorted.
+func Changed(previous, next interface{}) []string {
+	changed := []string{}
+	diff(reflect.ValueOf(previous), reflect.ValueOf(next), "", &changed)
+	sort.Strings(changed)
+	return changed
+}
+
+// diff adds the paths of the settings differing between two values.
+func diff(previous, next reflect.Value, path string, changed *[]string) {
+	for previous.Kind() == reflect.Ptr && next.Kind() == reflect.Ptr && !previous.IsNil() && !next.IsNil() {
+		previous, next = previous.Elem(), next.Elem()
+	}
+	if previous.Kind() != reflect.Struct || next.Kind() != reflect.Struct || previous.Type() != next.Type() ||
+		reflect.PtrTo(previous.Type()).Implements(textUnmarshalerType) {
+		if !reflect.DeepEqual(previous.Interface(), next.Interface()) {
+			*changed = append(*changed, path)
+		}
+		return
+	}
+
+	nextFields := fields(next)
+	for i, f := range fields(previous) {
+		diff(f.value, nextFields[i].value, join(path, f.name), changed)
+	}
+}
@@ -0,0 +1,326 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a line of YAML without its indentation and comment.
+type yamlLine struct {
+	number int
+	indent int
+	text   string
+}
+
+// yamlParser parses the block YAML configs are written in: nested
+// mappings, "- " lists, flow lists such as [a, b], scalars and "|" and ">"
+// text blocks. Anchors, tags and multiple documents are not supported.
+type yamlParser struct {
+	lines []yamlLine
+	raw   []string
+	next  int
+}
+
+// parseYAML parses a YAML document whose top level is a mapping.
+func parseYAML(data string) (map[string]interface{}, error) {
+	p := &yamlParser{raw: strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n")}
+	for i, raw := range p.raw {
+		if strings.HasPrefix(raw, "\t") {
+			return nil, fmt.Errorf("line %d: indent with spaces, not tabs", i+1)
+		}
+		text := strings.TrimRight(stripComment(raw), " ")
+		trimmed := strings.TrimLeft(text, " ")
+		if trimmed == "" || trimmed == "---" {
+			continue
+		}
+		p.lines = append(p.lines, yamlLine{number: i + 1, indent: len(text) - len(trimmed), text: trimmed})
+	}
+	if len(p.lines) == 0 {
+		return map[string]interface{}{}, nil
+	}
+
+	value, err := p.block(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.next < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.next].number)
+	}
+	document, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("the document must be a mapping")
+	}
+	return document, nil
+}
+
+// block parses the mapping or list starting at the next line, whose lines
+// are indented by indent.
+func (p *yamlParser) block(indent int) (interface{}, error) {
+	if strings.HasPrefix(p.lines[p.next].text+" ", "- ") {
+		return p.list(indent)
+	}
+	return p.mapping(indent, map[string]interface{}{})
+}
+
+// mapping parses "key: value" lines into mapping.
+func (p *yamlParser) mapping(indent int, mapping map[string]interface{}) (interface{}, error) {
+	for p.next < len(p.lines) && p.lines[p.next].indent == indent {
+		line := p.lines[p.next]
+		if strings.HasPrefix(line.text+" ", "- ") {
+			return nil, fmt.Errorf("line %d: list item in a mapping", line.number)
+		}
+		key, rest, err := splitKey(line)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := mapping[key]; exists {
+			return nil, fmt.Errorf("line %d: %s is set twice", line.number, key)
+		}
+		p.next++
+
+		if mapping[key], err = p.value(line, indent, rest); err != nil {
+			return nil, err
+		}
+	}
+	return mapping, nil
+}
+
+// list parses "- item" lines.
+func (p *yamlParser) list(indent int) (interface{}, error) {
+	items := []interface{}{}
+	for p.next < len(p.lines) && p.lines[p.next].indent == indent && strings.HasPrefix(p.lines[p.next].text+" ", "- ") {
+		line := p.lines[p.next]
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		p.next++
+
+		// "- key: value" starts a mapping indented past the dash, and
+		// "- - item" a list
+		_, _, keyErr := splitKey(yamlLine{text: rest})
+		isMapping := keyErr == nil && !isQuoted(rest) && !strings.HasPrefix(rest, "[")
+		if isMapping || strings.HasPrefix(rest+" ", "- ") {
+			itemIndent := indent + len(line.text) - len(rest)
+			p.next--
+			p.lines[p.next] = yamlLine{number: line.number, indent: itemIndent, text: rest}
+			item, err := p.block(itemIndent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+
+		item, err := p.value(line, indent, rest)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// value parses what follows a key or a dash: a scalar on the line, a text
+// block, or a nested block on the lines below.
+func (p *yamlParser) value(line yamlLine, indent int, rest string) (interface{}, error) {
+	if rest == "|" || rest == ">" || rest == "|-" || rest == ">-" {
+		return p.text(line, indent, rest), nil
+	}
+	if rest != "" {
+		return parseYAMLScalar(rest, line.number)
+	}
+	if p.next < len(p.lines) {
+		next := p.lines[p.next]
+		// Lists may sit at the key's indentation
+		if next.indent > indent || (next.indent == indent && strings.HasPrefix(next.text+" ", "- ")) {
+			return p.block(next.indent)
+		}
+	}
+	return nil, nil
+}
+
+// text reads a "|" block keeping newlines, or a ">" block folding them
+// into spaces, from the raw lines indented past indent.
+func (p *yamlParser) text(line yamlLine, indent int, style string) string {
+	var lines []string
+	blockIndent := -1
+	for i := line.number; i < len(p.raw); i++ {
+		raw := strings.TrimRight(p.raw[i], " ")
+		trimmed := strings.TrimLeft(raw, " ")
+		if trimmed == "" {
+			lines = append(lines, "")
+			continue
+		}
+		lineIndent := len(raw) - len(trimmed)
+		if lineIndent <= indent {
+			break
+		}
+		if blockIndent < 0 {
+			blockIndent = lineIndent
+		}
+		lines = append(lines, raw[min(blockIndent, lineIndent):])
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	// Skip the parsed lines the block covered
+	last := line.number + len(lines)
+	for p.next < len(p.lines) && p.lines[p.next].number <= last {
+		p.next++
+	}
+
+	separator := "\n"
+	if strings.HasPrefix(style, ">") {
+		separator = " "
+	}
+	text := strings.Join(lines, separator)
+	if !strings.HasSuffix(style, "-") && text != "" {
+		text += "\n"
+	}
+	return text
+}
+
+// splitKey splits "key: value" into the key and the value.
+func splitKey(line yamlLine) (string, string, error) {
+	text := line.text
+	if isQuoted(text) {
+		end := strings.IndexByte(text[1:], text[0]) + 1
+		if end > 0 && strings.HasPrefix(text[end+1:], ":") {
+			key, err := parseYAMLScalar(text[:end+1], line.number)
+			if err != nil {
+				return "", "", err
+			}
+			return fmt.Sprint(key), strings.TrimSpace(text[end+2:]), nil
+		}
+	}
+
+	i := strings.Index(text+" ", ": ")
+	if i <= 0 {
+		return "", "", fmt.Errorf("line %d: expected key: value", line.number)
+	}
+	return text[:i], strings.TrimSpace(text[i+1:]), nil
+}
+
+// parseYAMLScalar parses a quoted string, a flow list, a boolean, a null,
+// a number or a plain string.
+func parseYAMLScalar(text string, number int) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(text, `"`):
+		value, err := strconv.Unquote(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid string %s", number, text)
+		}
+		return value, nil
+	case strings.HasPrefix(text, "'"):
+		if len(text) < 2 || !strings.HasSuffix(text, "'") {
+			return nil, fmt.Errorf("line %d: invalid string %s", number, text)
+		}
+		return strings.ReplaceAll(text[1:len(text)-1], "''", "'"), nil
+	case strings.HasPrefix(text, "["):
+		if !strings.HasSuffix(text, "]") {
+			return nil, fmt.Errorf("line %d: lists must close on their line", number)
+		}
+		items := []interface{}{}
+		for _, part := range splitFlow(text[1 : len(text)-1]) {
+			item, err := parseYAMLScalar(part, number)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case text == "{}":
+		return map[string]interface{}{}, nil
+	}
+
+	switch text {
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	case "null", "Null", "NULL", "~":
+		return nil, nil
+	}
+	// Numbers keep their text, so a string setting such as version: 1.10
+	// is not read as 1.1
+	if _, err := strconv.ParseFloat(text, 64); err == nil {
+		return json.Number(text), nil
+	}
+	return text, nil
+}
+
+// splitFlow splits the items of a flow list at commas outside quotes and
+// nested lists.
+func splitFlow(text string) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(text[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(text[start:]); last != "" {
+		parts = append(parts, last)
+	}
+	return parts
+}
+
+// stripComment removes a "#" comment, which starts the line or follows a
+// space, outside quoted strings. Quotes start strings only where a value
+// does, so apostrophes in plain text are kept.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && startsValue(line[:i]):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// startsValue reports whether a value starts after text: at the start of
+// the line or after a colon, dash, comma or bracket.
+func startsValue(text string) bool {
+	text = strings.TrimRight(text, " ")
+	return text == "" || strings.ContainsAny(text[len(text)-1:], ":-,[{")
+}
+
+// isQuoted reports whether text starts with a quote.
+func isQuoted(text string) bool {
+	return strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'")
+}
+
+// min returns the smaller of two ints.
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,90 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned by brokers used after Close.
+var ErrClosed = errors.New("events: broker closed")
+
+// Broker carries the messages of buses in different processes. Publish
+// sends a message to every bus connected to the broker, including the
+// sender's, which Receive delivers them to.
+type Broker interface {
+	Publish(ctx context.Context, topic string, message []byte) error
+
+	// Receive starts delivering the messages published on any topic; it is
+	// called once, by Bus.Connect
+	Receive(deliver func(topic string, message []byte)) error
+
+	Close() error
+}
+
+// MemoryBroker connects buses within a process, for tests.
+type MemoryBroker struct {
+	mutex     sync.RWMutex
+	receivers []func(topic string, message []byte)
+	closed    bool
+}
+
+var _ Broker = (*MemoryBroker)(nil)
+
+// NewMemoryBroker creates a broker without connected buses.
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{}
+}
+
+// Publish delivers a message to every connected bus.
+func (m *MemoryBroker) Publish(ctx context.Context, topic string, message []byte) error {
+	m.mutex.RLock()
+	closed, receivers := m.closed, m.receivers
+	m.mutex.RUnlock()
+
+	if closed {
+		return ErrClosed
+	}
+	for _, deliver := range receivers {
+		deliver(topic, message)
+	}
+	return nil
+}
+
+// Receive connects a bus.
+func (m *MemoryBroker) Receive(deliver func(topic string, message []byte)) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+	m.receivers = append(m.receivers, deliver)
+	return nil
+}
+
+// Close stops delivering messages.
+func (m *MemoryBroker) Close() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.closed = true
+	m.receivers = nil
+	return nil
+}
+
+// redial calls dial until it succeeds or closed reports true, waiting
+// longer after each failure, up to ten seconds.
+func redial(closed func() bool, dial func() error) {
+	delay := 100 * time.Millisecond
+	for !closed() {
+		if dial() == nil {
+			return
+		}
+		time.Sleep(delay)
+		if delay *= 2; delay > 10*time.Second {
+			delay = 10 * time.Second
+		}
+	}
+}
@@ -0,0 +1,271 @@
+// Package events is an in-process publish/subscribe bus that database
+// changes, edge sync and API subscriptions share. A Broker, such as NATS or
+// Redis, carries events between the buses of several instances.
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event is a value published on a topic. Data is the published value on
+// the bus that published it, and json.RawMessage on the buses a broker
+// delivered it to; Decode reads either.
+type Event struct {
+	ID     string      `json:"id"`
+	Topic  string      `json:"topic"`
+	Time   time.Time   `json:"time"`
+	Source string      `json:"source"`
+	Data   interface{} `json:"data"`
+}
+
+// Decode stores the event's data in the value v points to.
+func (e Event) Decode(v interface{}) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return errors.New("events: Decode needs a non-nil pointer")
+	}
+	if e.Data != nil {
+		data := reflect.ValueOf(e.Data)
+		if data.Type().AssignableTo(target.Elem().Type()) {
+			target.Elem().Set(data)
+			return nil
+		}
+	}
+
+	raw, ok := e.Data.(json.RawMessage)
+	if !ok {
+		var err error
+		if raw, err = json.Marshal(e.Data); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// Handler is called with each event on the topics it subscribed to. It runs
+// in the publisher's goroutine, or the broker's for remote events, so slow
+// work should be handed off.
+type Handler func(ctx context.Context, event Event)
+
+// Bus delivers events to the subscribers of their topic. Topics are dot
+// separated, such as "db.shop.orders.insert". Subscriptions may use "*" for
+// one token and a trailing ">" for one or more, as in "db.shop.*.insert"
+// and "db.>".
+type Bus struct {
+	id            string
+	mutex         sync.RWMutex
+	subscriptions map[int]*subscription
+	lastID        int
+	broker        Broker
+
+	// OnError is called with errors publishing to the broker, and with
+	// remote messages that could not be read; they are dropped otherwise
+	OnError func(err error)
+}
+
+// subscription is a pattern and the handler of its events.
+type subscription struct {
+	pattern []string
+	handler Handler
+}
+
+// NewBus creates a bus delivering events within the process; Connect a
+// broker to share them with other instances.
+func NewBus() *Bus {
+	return &Bus{id: newID(), subscriptions: make(map[int]*subscription)}
+}
+
+// ID identifies the bus as the source of the events it publishes.
+func (b *Bus) ID() string {
+	return b.id
+}
+
+// Subscribe calls handler with each event whose topic matches pattern,
+// until the returned function is called.
+func (b *Bus) Subscribe(pattern string, handler Handler) (unsubscribe func()) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.lastID++
+	id := b.lastID
+	b.subscriptions[id] = &subscription{pattern: strings.Split(pattern, "."), handler: handler}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mutex.Lock()
+			defer b.mutex.Unlock()
+			delete(b.subscriptions, id)
+		})
+	}
+}
+
+// SubscribeChan sends the events matching pattern to a channel with the
+// given buffer, dropping those a slow reader has no room for. Unsubscribing
+// closes the channel.
+func (b *Bus) SubscribeChan(pattern string, buffer int) (<-chan Event, func()) {
+	events := make(chan Event, buffer)
+	var mutex sync.Mutex
+	closed := false
+
+	unsubscribe := b.Subscribe(pattern, func(ctx context.Context, event Event) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case events <- event:
+		default:
+		}
+	})
+	return events, func() {
+		unsubscribe()
+		mutex.Lock()
+		defer mutex.Unlock()
+		if !closed {
+			closed = true
+			close(events)
+		}
+	}
+}
+
+// On subscribes a function taking a context and the event's data as its own
+// type, such as func(ctx context.Context, order Order). Events whose data
+// does not decode into that type are reported to OnError.
+func (b *Bus) On(pattern string, fn interface{}) (func(), error) {
+	value := reflect.ValueOf(fn)
+	kind := value.Type()
+	contextType := reflect.TypeOf((*context.Context)(nil)).Elem()
+	if kind.Kind() != reflect.Func || kind.NumIn() != 2 || kind.In(0) != contextType || kind.NumOut() != 0 {
+		return nil, fmt.Errorf("events: On needs a func(context.Context, T), got %s", kind)
+	}
+
+	dataType := kind.In(1)
+	return b.Subscribe(pattern, func(ctx context.Context, event Event) {
+		data := reflect.New(dataType)
+		if err := event.Decode(data.Interface()); err != nil {
+			b.reportError(fmt.Errorf("events: decoding %s: %v", event.Topic, err))
+			return
+		}
+		value.Call([]reflect.Value{reflect.ValueOf(ctx), data.Elem()})
+	}), nil
+}
+
+// Publish delivers data to the subscribers of topic, and to the other
+// instances through the broker if one is connected.
+func (b *Bus) Publish(ctx context.Context, topic string, data interface{}) error {
+	if topic == "" || strings.ContainsAny(topic, "*> ") {
+		return fmt.Errorf("events: invalid topic %q", topic)
+	}
+	event := Event{ID: newID(), Topic: topic, Time: time.Now(), Source: b.id, Data: data}
+	b.deliver(ctx, event)
+
+	b.mutex.RLock()
+	broker := b.broker
+	b.mutex.RUnlock()
+	if broker == nil {
+		return nil
+	}
+
+	message, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := broker.Publish(ctx, topic, message); err != nil {
+		b.reportError(err)
+		return err
+	}
+	return nil
+}
+
+// Connect shares the bus's events with the other buses connected to the
+// broker. Events come back from the broker once; the bus skips its own.
+func (b *Bus) Connect(broker Broker) error {
+	b.mutex.Lock()
+	b.broker = broker
+	b.mutex.Unlock()
+
+	return broker.Receive(func(topic string, message []byte) {
+		var event struct {
+			Event
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(message, &event); err != nil {
+			b.reportError(fmt.Errorf("events: reading a message on %s: %v", topic, err))
+			return
+		}
+		if event.Source == b.id {
+			return
+		}
+		event.Event.Data = event.Data
+		b.deliver(context.Background(), event.Event)
+	})
+}
+
+// Close closes the broker, if one is connected.
+func (b *Bus) Close() error {
+	b.mutex.Lock()
+	broker := b.broker
+	b.broker = nil
+	b.mutex.Unlock()
+
+	if broker == nil {
+		return nil
+	}
+	return broker.Close()
+}
+
+// deliver calls the handlers of the subscriptions matching the event.
+func (b *Bus) deliver(ctx context.Context, event Event) {
+	topic := strings.Split(event.Topic, ".")
+
+	b.mutex.RLock()
+	handlers := []Handler{}
+	for _, subscription := range b.subscriptions {
+		if Match(subscription.pattern, topic) {
+			handlers = append(handlers, subscription.handler)
+		}
+	}
+	b.mutex.RUnlock()
+
+	for _, handler := range handlers {
+		handler(ctx, event)
+	}
+}
+
+// reportError passes err to OnError, if set.
+func (b *Bus) reportError(err error) {
+	if b.OnError != nil {
+		b.OnError(err)
+	}
+}
+
+// Match reports whether a topic's tokens match a pattern's.
+func Match(pattern, topic []string) bool {
+	for i, token := range pattern {
+		if token == ">" {
+			return i == len(pattern)-1 && len(topic) > i
+		}
+		if i >= len(topic) || (token != "*" && token != topic[i]) {
+			return false
+		}
+	}
+	return len(pattern) == len(topic)
+}
+
+// newID returns a random identifier.
+func newID() string {
+	bytes := make([]byte, 12)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
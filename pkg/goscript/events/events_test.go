@@ -0,0 +1,172 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type order struct {
+	ID    int
+	Total float64
+}
+
+func TestBusPatternsAndTypedHandlers(t *testing.T) {
+	bus := NewBus()
+	ctx := context.Background()
+
+	var mutex sync.Mutex
+	seen := map[string][]string{}
+	for _, pattern := range []string{"db.shop.orders.insert", "db.shop.*.insert", "db.>", "db.*"} {
+		pattern := pattern
+		bus.Subscribe(pattern, func(ctx context.Context, event Event) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			seen[pattern] = append(seen[pattern], event.Topic)
+		})
+	}
+
+	var orders []order
+	unsubscribe, err := bus.On("db.shop.orders.insert", func(ctx context.Context, o order) {
+		orders = append(orders, o)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bus.On("x", func(o order) {}); err == nil {
+		t.Fatal("expected a handler without a context to be refused")
+	}
+
+	bus.Publish(ctx, "db.shop.orders.insert", order{ID: 1, Total: 9.5})
+	bus.Publish(ctx, "db.shop.users.insert", map[string]interface{}{"ID": 2})
+	bus.Publish(ctx, "db.shop", nil)
+	unsubscribe()
+	bus.Publish(ctx, "db.shop.orders.insert", order{ID: 3})
+
+	for pattern, want := range map[string]int{"db.shop.orders.insert": 2, "db.shop.*.insert": 3, "db.>": 4, "db.*": 1} {
+		if len(seen[pattern]) != want {
+			t.Fatalf("expected %s to see %d events, got %v", pattern, want, seen[pattern])
+		}
+	}
+	if len(orders) != 1 || orders[0].Total != 9.5 {
+		t.Fatalf("expected one decoded order, got %+v", orders)
+	}
+	if err := bus.Publish(ctx, "db.*", nil); err == nil {
+		t.Fatal("expected a wildcard topic to be refused")
+	}
+
+	events, stop := bus.SubscribeChan("api.>", 1)
+	bus.Publish(ctx, "api.prices", 1)
+	bus.Publish(ctx, "api.prices", 2)
+	stop()
+	if event := <-events; event.Data != 1 {
+		t.Fatalf("expected the first event, got %+v", event)
+	}
+	if _, ok := <-events; ok {
+		t.Fatal("expected the full channel to drop the second event and close")
+	}
+}
+
+func TestBusesShareEventsThroughBroker(t *testing.T) {
+	broker := NewMemoryBroker()
+	first, second := NewBus(), NewBus()
+	first.Connect(broker)
+	second.Connect(broker)
+
+	received := map[*Bus][]order{}
+	for _, bus := range []*Bus{first, second} {
+		bus := bus
+		bus.On("orders.created", func(ctx context.Context, o order) {
+			received[bus] = append(received[bus], o)
+		})
+	}
+
+	first.Publish(context.Background(), "orders.created", order{ID: 7, Total: 12})
+	if len(received[first]) != 1 || len(received[second]) != 1 || received[second][0] != (order{ID: 7, Total: 12}) {
+		t.Fatalf("expected each bus to receive the order once, got %v", received)
+	}
+
+	first.Close()
+	if err := second.Publish(context.Background(), "orders.created", order{}); err != ErrClosed {
+		t.Fatalf("expected publishing through a closed broker to fail, got %v", err)
+	}
+}
+
+func TestNATSBroker(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// A server speaking just enough NATS to pass publishes to every client
+	var mutex sync.Mutex
+	var clients []net.Conn
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mutex.Lock()
+			clients = append(clients, conn)
+			mutex.Unlock()
+			io.WriteString(conn, "INFO {}\r\nPING\r\n")
+
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					fields := strings.Fields(line)
+					if len(fields) == 3 && fields[0] == "PUB" {
+						size, _ := strconv.Atoi(fields[2])
+						payload := make([]byte, size+2)
+						io.ReadFull(reader, payload)
+						mutex.Lock()
+						for _, client := range clients {
+							io.WriteString(client, "MSG "+fields[1]+" 1 "+fields[2]+"\r\n"+string(payload))
+						}
+						mutex.Unlock()
+					}
+				}
+			}()
+		}
+	}()
+
+	source, bus := NewBus(), NewBus()
+	events, stop := bus.SubscribeChan("edge.>", 1)
+	defer stop()
+	for _, b := range []*Bus{source, bus} {
+		broker, err := DialNATS("nats://" + listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Connect(broker); err != nil {
+			t.Fatal(err)
+		}
+		defer b.Close()
+	}
+
+	if err := source.Publish(context.Background(), "edge.eu.sync", order{ID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		var o order
+		if err := event.Decode(&o); err != nil || o.ID != 1 || event.Source != source.ID() {
+			t.Fatalf("unexpected event %+v %v", event, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the event")
+	}
+}
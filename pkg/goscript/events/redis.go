@@ -0,0 +1,167 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/internal/redis"
+)
+
+// RedisBroker carries events over Redis pub/sub, publishing each on the
+// channel of its topic under Prefix. Redis needs one connection to publish
+// and another to receive.
+type RedisBroker struct {
+	// Prefix is put before topics to make channels; "goscript." by default
+	Prefix string
+
+	options redis.Options
+
+	mutex      sync.Mutex
+	publisher  *redis.Conn
+	subscriber *redis.Conn
+	deliver    func(topic string, message []byte)
+	closed     bool
+}
+
+var _ Broker = (*RedisBroker)(nil)
+
+// DialRedis connects to Redis at an address such as
+// "redis://:password@localhost:6379/0". The broker reconnects by itself
+// when a connection drops; events published meanwhile are lost.
+func DialRedis(address string) (*RedisBroker, error) {
+	options, err := redis.ParseURL(address)
+	if err != nil {
+		return nil, err
+	}
+	broker := &RedisBroker{Prefix: "goscript.", options: options}
+	if broker.publisher, err = redis.Dial(options); err != nil {
+		return nil, err
+	}
+	return broker, nil
+}
+
+// Publish sends a message on the topic's channel, reconnecting once if the
+// connection dropped.
+func (r *RedisBroker) Publish(ctx context.Context, topic string, message []byte) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return ErrClosed
+	}
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if r.publisher == nil {
+			if r.publisher, err = redis.Dial(r.options); err != nil {
+				return err
+			}
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			r.publisher.SetDeadline(deadline)
+		}
+		_, err = r.publisher.Do("PUBLISH", r.Prefix+topic, string(message))
+		if err == nil {
+			r.publisher.SetDeadline(time.Time{})
+			return nil
+		}
+		var redisErr redis.Error
+		if errors.As(err, &redisErr) {
+			return err
+		}
+		r.publisher.Close()
+		r.publisher = nil
+	}
+	return err
+}
+
+// Receive subscribes to every channel under the prefix.
+func (r *RedisBroker) Receive(deliver func(topic string, message []byte)) error {
+	r.mutex.Lock()
+	if r.closed {
+		r.mutex.Unlock()
+		return ErrClosed
+	}
+	r.deliver = deliver
+	r.mutex.Unlock()
+
+	return r.subscribe()
+}
+
+// subscribe opens the receiving connection and reads from it.
+func (r *RedisBroker) subscribe() error {
+	c, err := redis.Dial(r.options)
+	if err != nil {
+		return err
+	}
+	if err := c.Write("PSUBSCRIBE", r.Prefix+"*"); err != nil {
+		c.Close()
+		return err
+	}
+
+	r.mutex.Lock()
+	if r.closed {
+		r.mutex.Unlock()
+		c.Close()
+		return ErrClosed
+	}
+	r.subscriber = c
+	r.mutex.Unlock()
+
+	go r.read(c)
+	return nil
+}
+
+// read delivers pmessage replies until the connection drops, then
+// reconnects.
+func (r *RedisBroker) read(c *redis.Conn) {
+	for {
+		reply, err := c.Read()
+		if err != nil {
+			break
+		}
+		values, ok := reply.([]interface{})
+		if !ok || len(values) != 4 {
+			continue
+		}
+		if kind, _ := values[0].(string); kind != "pmessage" {
+			continue
+		}
+		channel, _ := values[2].(string)
+		message, _ := values[3].(string)
+
+		r.mutex.Lock()
+		deliver := r.deliver
+		r.mutex.Unlock()
+		deliver(strings.TrimPrefix(channel, r.Prefix), []byte(message))
+	}
+	c.Close()
+
+	if !r.isClosed() {
+		redial(r.isClosed, r.subscribe)
+	}
+}
+
+// Close closes both connections.
+func (r *RedisBroker) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.closed = true
+	for _, c := range []*redis.Conn{r.publisher, r.subscriber} {
+		if c != nil {
+			c.Close()
+		}
+	}
+	return nil
+}
+
+// isClosed reports whether Close was called.
+func (r *RedisBroker) isClosed() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.closed
+}
@@ -0,0 +1,385 @@
+package goscript
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+// Renderer renders a piece of HTML, such as a Component, a gouix component
+// or a gouix root.
+type Renderer interface {
+	Render() string
+}
+
+// RenderFunc adapts a function to a Renderer, for example a gouix root's
+// RenderHydratable.
+type RenderFunc func() string
+
+// Render calls f.
+func (f RenderFunc) Render() string {
+	return f()
+}
+
+// HTML is markup rendered as it is.
+type HTML string
+
+// Render returns the markup.
+func (h HTML) Render() string {
+	return string(h)
+}
+
+// StyleSource generates a <style> tag, as a *gocsx.Gocsx does.
+type StyleSource interface {
+	GenerateStyleTag() string
+}
+
+// PanelSource generates markup shown over the page, as a Jetpack
+// PerformancePanel does.
+type PanelSource interface {
+	GenerateHTML() (string, error)
+}
+
+// HeadContributor is implemented by page bodies that set their own title,
+// meta tags, styles or scripts. It is called after the body renders.
+type HeadContributor interface {
+	ContributeHead(head *Head)
+}
+
+type headEntry struct {
+	key  string
+	html string
+
+	// asset is the URL of a script or stylesheet added without an
+	// integrity hash, which Layout.Render adds when it serves the file.
+	asset string
+	tag   func(integrity string) string
+}
+
+// Head collects the title and tags of a document's <head>, and scripts to
+// run at the end of its <body>. Tags have keys, so adding one again, such as
+// a page's description over the layout's, replaces it.
+type Head struct {
+	Title string
+
+	entries []headEntry
+	tail    []headEntry
+}
+
+// NewHead creates an empty head.
+func NewHead() *Head {
+	return &Head{}
+}
+
+func setEntry(entries []headEntry, entry headEntry) []headEntry {
+	for i := range entries {
+		if entries[i].key == entry.key {
+			entries[i] = entry
+			return entries
+		}
+	}
+	return append(entries, entry)
+}
+
+// Meta sets a <meta name> tag, such as "description".
+func (h *Head) Meta(name, content string) {
+	h.Raw("meta:"+name, `<meta name="`+html.EscapeString(name)+`" content="`+html.EscapeString(content)+`">`)
+}
+
+// Property sets a <meta property> tag, such as Open Graph's "og:title".
+func (h *Head) Property(property, content string) {
+	h.Raw("property:"+property, `<meta property="`+html.EscapeString(property)+`" content="`+html.EscapeString(content)+`">`)
+}
+
+// Link adds a <link> tag, such as a canonical URL or an icon.
+func (h *Head) Link(rel, href string) {
+	h.Raw("link:"+rel+":"+href, `<link rel="`+html.EscapeString(rel)+`" href="`+html.EscapeString(href)+`">`)
+}
+
+// Stylesheet links a stylesheet. A layout serving it from its Assets adds
+// its integrity hash.
+func (h *Head) Stylesheet(href string) {
+	h.entries = setEntry(h.entries, headEntry{
+		key:   "link:stylesheet:" + href,
+		html:  stylesheetTag(href, ""),
+		asset: href,
+		tag:   func(integrity string) string { return stylesheetTag(href, integrity) },
+	})
+}
+
+// StylesheetIntegrity links a stylesheet, such as one on a CDN, that
+// browsers only apply if it matches integrity, as core.SRIHash returns.
+func (h *Head) StylesheetIntegrity(href, integrity string) {
+	h.Raw("link:stylesheet:"+href, stylesheetTag(href, integrity))
+}
+
+func stylesheetTag(href, integrity string) string {
+	return `<link rel="stylesheet" href="` + html.EscapeString(href) + `"` + integrityAttrs(integrity) + `>`
+}
+
+// Style adds inline CSS under a key.
+func (h *Head) Style(key, css string) {
+	h.Raw("style:"+key, "<style>"+css+"</style>")
+}
+
+// Script adds a deferred script, which runs once the document is parsed. A
+// layout serving it from its Assets adds its integrity hash.
+func (h *Head) Script(src string) {
+	h.entries = setEntry(h.entries, headEntry{
+		key:   "script:" + src,
+		html:  scriptTag(src, ""),
+		asset: src,
+		tag:   func(integrity string) string { return scriptTag(src, integrity) },
+	})
+}
+
+// ScriptIntegrity adds a deferred script, such as one on a CDN, that
+// browsers only run if it matches integrity, as core.SRIHash returns.
+func (h *Head) ScriptIntegrity(src, integrity string) {
+	h.Raw("script:"+src, scriptTag(src, integrity))
+}
+
+func scriptTag(src, integrity string) string {
+	return `<script src="` + html.EscapeString(src) + `"` + integrityAttrs(integrity) + ` defer></script>`
+}
+
+// integrityAttrs are the attributes of a tag loading an asset that must
+// match integrity; crossorigin has browsers fetch cross-origin assets in a
+// way they can check.
+func integrityAttrs(integrity string) string {
+	if integrity == "" {
+		return ""
+	}
+	return ` integrity="` + html.EscapeString(integrity) + `" crossorigin="anonymous"`
+}
+
+// Raw adds trusted markup to the head under a key.
+func (h *Head) Raw(key, markup string) {
+	h.entries = setEntry(h.entries, headEntry{key: key, html: markup})
+}
+
+// BodyEnd adds trusted markup at the end of the body under a key, for
+// scripts that need the page's elements, such as a gouix hub's ScriptTag.
+func (h *Head) BodyEnd(key, markup string) {
+	h.tail = setEntry(h.tail, headEntry{key: key, html: markup})
+}
+
+// Merge adds another head's title and tags, replacing those with the same
+// keys.
+func (h *Head) Merge(other *Head) {
+	if other == nil {
+		return
+	}
+	if other.Title != "" {
+		h.Title = other.Title
+	}
+	for _, entry := range other.entries {
+		h.entries = setEntry(h.entries, entry)
+	}
+	for _, entry := range other.tail {
+		h.tail = setEntry(h.tail, entry)
+	}
+}
+
+// Render returns the markup of the head's contents.
+func (h *Head) Render() string {
+	var b strings.Builder
+	if h.Title != "" {
+		b.WriteString("<title>" + html.EscapeString(h.Title) + "</title>\n")
+	}
+	for _, entry := range h.entries {
+		b.WriteString(entry.html + "\n")
+	}
+	return b.String()
+}
+
+// RenderBodyEnd returns the markup added with BodyEnd.
+func (h *Head) RenderBodyEnd() string {
+	var b strings.Builder
+	for _, entry := range h.tail {
+		b.WriteString(entry.html + "\n")
+	}
+	return b.String()
+}
+
+// Page is one page of a site: its head and the component rendering its body.
+type Page struct {
+	Head *Head
+	Body Renderer
+
+	// Status is the HTTP status to answer with; 200 by default.
+	Status int
+}
+
+// NewPage creates a page with a title and body.
+func NewPage(title string, body Renderer) *Page {
+	return &Page{Head: &Head{Title: title}, Body: body}
+}
+
+// Layout is the document pages share: the <html> shell, the head tags every
+// page has, and the markup around each page's body, such as navigation.
+type Layout struct {
+	// Lang is the document language; "en" by default.
+	Lang string
+
+	// Head holds the tags every page has. Pages replace tags with the same
+	// keys, and their title replaces its title.
+	Head *Head
+
+	// TitleFormat formats page titles, such as "%s | My Site".
+	TitleFormat string
+
+	// Wrap places a page's rendered body in the layout's markup; nil
+	// leaves it as it is.
+	Wrap func(page *Page, body string) string
+
+	styles []StyleSource
+	panels []PanelSource
+	assets []*StaticFiles
+}
+
+// NewLayout creates a layout whose head sets the viewport for mobile
+// browsers.
+func NewLayout() *Layout {
+	head := NewHead()
+	head.Meta("viewport", "width=device-width, initial-scale=1")
+	return &Layout{Lang: "en", Head: head}
+}
+
+// Styles adds the CSS of style sources, such as gocsx instances, to every
+// page. Each is generated when a page renders, so it includes the classes
+// used so far.
+func (l *Layout) Styles(sources ...StyleSource) {
+	l.styles = append(l.styles, sources...)
+}
+
+// Panels adds panels, such as a Jetpack performance panel, at the end of
+// every page.
+func (l *Layout) Panels(sources ...PanelSource) {
+	l.panels = append(l.panels, sources...)
+}
+
+// Assets has the layout add integrity hashes to the scripts and stylesheets
+// that pages add with Head.Script and Head.Stylesheet and that files serve,
+// so browsers refuse them if they are changed on the way.
+func (l *Layout) Assets(files ...*StaticFiles) {
+	l.assets = append(l.assets, files...)
+}
+
+// integrity returns the integrity hash of an asset URL that one of the
+// layout's Assets serves, or "".
+func (l *Layout) integrity(src string) string {
+	for _, files := range l.assets {
+		if integrity, err := files.Integrity(src); err == nil {
+			return integrity
+		}
+	}
+	return ""
+}
+
+// Render renders a page into a complete HTML document.
+func (l *Layout) Render(page *Page) (string, error) {
+	return l.RenderContext(context.Background(), page)
+}
+
+// RenderContext renders a page like Render. When ctx has a CSP nonce, as
+// the Jetpack security middleware sets, the inline scripts and styles of
+// the head and panels get it, while those of the body do not.
+func (l *Layout) RenderContext(ctx context.Context, page *Page) (string, error) {
+	body := ""
+	if page.Body != nil {
+		body = page.Body.Render()
+	}
+
+	head := NewHead()
+	head.Merge(l.Head)
+	for i, source := range l.styles {
+		head.Raw(fmt.Sprintf("styles:%d", i), source.GenerateStyleTag())
+	}
+	head.Merge(page.Head)
+	if contributor, ok := page.Body.(HeadContributor); ok {
+		contributor.ContributeHead(head)
+	}
+	for i, source := range l.panels {
+		panel, err := source.GenerateHTML()
+		if err != nil {
+			return "", err
+		}
+		head.BodyEnd(fmt.Sprintf("panel:%d", i), panel)
+	}
+
+	if len(l.assets) > 0 {
+		for i, entry := range head.entries {
+			if entry.asset == "" {
+				continue
+			}
+			if integrity := l.integrity(entry.asset); integrity != "" {
+				head.entries[i].html = entry.tag(integrity)
+			}
+		}
+	}
+
+	if page.Head != nil && page.Head.Title != "" && l.TitleFormat != "" {
+		head.Title = fmt.Sprintf(l.TitleFormat, page.Head.Title)
+	}
+	if l.Wrap != nil {
+		body = l.Wrap(page, body)
+	}
+
+	lang := l.Lang
+	if lang == "" {
+		lang = "en"
+	}
+
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html>` + "\n")
+	b.WriteString(`<html lang="` + html.EscapeString(lang) + `">` + "\n")
+	b.WriteString("<head>\n<meta charset=\"utf-8\">\n")
+	nonce := core.CSPNonce(ctx)
+	b.WriteString(core.AddNonce(head.Render(), nonce))
+	b.WriteString("</head>\n<body>\n")
+	b.WriteString(body)
+	b.WriteString("\n")
+	b.WriteString(core.AddNonce(head.RenderBodyEnd(), nonce))
+	b.WriteString("</body>\n</html>\n")
+	return b.String(), nil
+}
+
+// Write renders a page and sends it as the response to a request. The
+// response gets an ETag, so a client already holding the same document gets
+// 304 Not Modified instead.
+func (l *Layout) Write(w http.ResponseWriter, r *http.Request, page *Page) error {
+	document, err := l.RenderContext(r.Context(), page)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+
+	status := page.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if status == http.StatusOK {
+		w.Header().Set("ETag", bodyETag([]byte(document)))
+	}
+	writeBuffered(w, r, status, []byte(document))
+	return nil
+}
+
+// Handler returns a route handler rendering the page build returns for a
+// request. An error from build answers 500.
+func (l *Layout) Handler(build func(r *http.Request, params map[string]string) (*Page, error)) RouteHandler {
+	return func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		page, err := build(r, params)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		l.Write(w, r, page)
+	}
+}
@@ -0,0 +1,145 @@
+package goscript
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+type styleTag string
+
+func (s styleTag) GenerateStyleTag() string { return string(s) }
+
+type panel struct{ err error }
+
+func (p panel) GenerateHTML() (string, error) { return `<div id="panel"></div>`, p.err }
+
+type article struct{ title string }
+
+func (a article) Render() string { return "<article>" + a.title + "</article>" }
+
+func (a article) ContributeHead(head *Head) {
+	head.Property("og:title", a.title)
+}
+
+func TestLayoutRender(t *testing.T) {
+	layout := NewLayout()
+	layout.TitleFormat = "%s | Site"
+	layout.Head.Meta("description", "A site")
+	layout.Head.Stylesheet("/static/site.css")
+	layout.Styles(styleTag("<style>.p-4{padding:1rem}</style>"))
+	layout.Panels(panel{})
+	layout.Wrap = func(page *Page, body string) string { return "<main>" + body + "</main>" }
+
+	page := NewPage("Tom & Jerry", article{title: "Chase"})
+	page.Head.Meta("description", "A <cartoon>")
+	page.Head.BodyEnd("live", `<script src="/live.js"></script>`)
+
+	document, err := layout.Render(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		`<html lang="en">`,
+		`<title>Tom &amp; Jerry | Site</title>`,
+		`<meta name="viewport"`,
+		`<meta name="description" content="A &lt;cartoon&gt;">`,
+		`<link rel="stylesheet" href="/static/site.css">`,
+		`<style>.p-4{padding:1rem}</style>`,
+		`<meta property="og:title" content="Chase">`,
+		"<main><article>Chase</article></main>",
+		`<script src="/live.js"></script>` + "\n" + `<div id="panel"></div>` + "\n</body>",
+	} {
+		if !strings.Contains(document, expected) {
+			t.Fatalf("expected %q in document:\n%s", expected, document)
+		}
+	}
+	if strings.Contains(document, `content="A site"`) {
+		t.Fatalf("expected the page description to replace the layout's:\n%s", document)
+	}
+}
+
+func TestLayoutHandler(t *testing.T) {
+	layout := NewLayout()
+	layout.Panels(panel{})
+
+	router := NewRouter()
+	router.GET("/posts/:id", layout.Handler(func(r *http.Request, params map[string]string) (*Page, error) {
+		if params["id"] == "0" {
+			return nil, errors.New("no post")
+		}
+		page := NewPage("Post", HTML("<p>"+params["id"]+"</p>"))
+		if params["id"] == "draft" {
+			page.Status = http.StatusAccepted
+		}
+		return page, nil
+	}))
+
+	if code := serve(router, "GET", "/posts/draft").Code; code != http.StatusAccepted {
+		t.Fatalf("expected the page status, got %d", code)
+	}
+
+	recorder := serve(router, "GET", "/posts/7")
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "text/html; charset=utf-8" || !strings.Contains(recorder.Body.String(), "<p>7</p>") {
+		t.Fatalf("unexpected response %d %v %s", recorder.Code, recorder.Header(), recorder.Body.String())
+	}
+
+	request := httptest.NewRequest("GET", "/posts/7", nil)
+	request.Header.Set("If-None-Match", recorder.Header().Get("ETag"))
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for an unchanged page, got %d", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/posts/0", nil))
+	if recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 when the page fails, got %d", recorder.Code)
+	}
+
+	layout.Panels(panel{err: errors.New("panel failed")})
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/posts/7", nil))
+	if recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 when a panel fails, got %d", recorder.Code)
+	}
+}
+
+func TestLayoutIntegrityAndNonce(t *testing.T) {
+	router := NewRouter()
+	assets := router.Static("/assets", staticDir(t))
+
+	layout := NewLayout()
+	layout.Assets(assets)
+	layout.Styles(styleTag("<style>.p-4{padding:1rem}</style>"))
+	layout.Panels(panel{})
+
+	page := NewPage("App", HTML(`<script>alert("user")</script>`))
+	page.Head.Script("/assets/app.js?v=2")
+	page.Head.Script("/assets/missing.js")
+	page.Head.ScriptIntegrity("https://cdn.example.com/lib.js", "sha384-abc")
+
+	ctx := core.WithCSPNonce(context.Background(), "n0nce")
+	document, err := layout.RenderContext(ctx, page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	integrity := core.SRIHash([]byte("console.log(1)"))
+	for _, expected := range []string{
+		`<script nonce="n0nce" src="/assets/app.js?v=2" integrity="` + integrity + `" crossorigin="anonymous" defer></script>`,
+		`<script nonce="n0nce" src="/assets/missing.js" defer></script>`,
+		`<script nonce="n0nce" src="https://cdn.example.com/lib.js" integrity="sha384-abc" crossorigin="anonymous" defer></script>`,
+		`<style nonce="n0nce">.p-4{padding:1rem}</style>`,
+		`<script>alert("user")</script>`,
+	} {
+		if !strings.Contains(document, expected) {
+			t.Fatalf("expected %q in document:\n%s", expected, document)
+		}
+	}
+}
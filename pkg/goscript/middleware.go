@@ -0,0 +1,335 @@
+package goscript
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/reqctx"
+)
+
+// RequestIDHeader carries the ID of a request between services.
+const RequestIDHeader = reqctx.RequestIDHeader
+
+// RequestIDFromContext returns the ID RequestID gave the request, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	return reqctx.RequestID(ctx)
+}
+
+// RequestID gives each request an ID, taken from its X-Request-ID header or
+// generated, and a trace, continued from its traceparent header or started.
+// They are stored in the request context, where reqctx finds them for the
+// other modules, and the ID is echoed in the response. Requests from the
+// trusted proxies, addresses or CIDR ranges of the app's own services such
+// as its edge nodes, also carry who they are for; see reqctx.Trusted.
+func RequestID(trustedProxies ...string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := reqctx.Ensure(reqctx.ExtractRequest(r, trustedProxies))
+			w.Header().Set(RequestIDHeader, reqctx.RequestID(ctx))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Logger logs the method, path, status, size and duration of each request,
+// with its request ID when RequestID runs first. A nil logger uses the
+// standard logger.
+func Logger(logger *log.Logger) Middleware {
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+
+			status := recorder.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			logger.Printf("%s%s %s %d %dB %s", reqctx.LogPrefix(r.Context()), r.Method, r.URL.RequestURI(), status, recorder.size, time.Since(start))
+		})
+	}
+}
+
+// Recoverer turns a panicking handler into a 500 response and logs the panic
+// with its stack. A nil logger uses the standard logger.
+func Recoverer(logger *log.Logger) Middleware {
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			recorder := &statusRecorder{ResponseWriter: w}
+			defer func() {
+				value := recover()
+				if value == nil {
+					return
+				}
+				// The server aborts the response quietly for this one
+				if err, ok := value.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+					panic(value)
+				}
+
+				logger.Printf("%spanic serving %s %s: %v\n%s", reqctx.LogPrefix(r.Context()), r.Method, r.URL.Path, value, debug.Stack())
+
+				if recorder.status == 0 {
+					http.Error(recorder, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				}
+			}()
+
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
+
+// Gzip compresses responses for clients accepting gzip at a compress/gzip
+// level. Responses that set their own Content-Encoding, and upgraded
+// connections such as WebSockets, are left alone.
+func Gzip(level int) Middleware {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		level = gzip.DefaultCompression
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !acceptsGzip(r) || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			writer := &gzipWriter{statusRecorder: statusRecorder{ResponseWriter: w}, level: level}
+			defer writer.Close()
+			next.ServeHTTP(writer, r)
+		})
+	}
+}
+
+// acceptsGzip reports whether the Accept-Encoding header allows gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(fields[0]) != "gzip" {
+			continue
+		}
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// gzipWriter compresses the body unless the handler encoded it already.
+type gzipWriter struct {
+	statusRecorder
+	level    int
+	gzip     *gzip.Writer
+	decided  bool
+	compress bool
+}
+
+// decide picks whether to compress once the headers are final.
+func (w *gzipWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		return
+	}
+	w.compress = true
+	header.Set("Content-Encoding", "gzip")
+	header.Del("Content-Length")
+	w.gzip, _ = gzip.NewWriterLevel(w.ResponseWriter, w.level)
+}
+
+func (w *gzipWriter) WriteHeader(status int) {
+	// Bodiless responses are not encoded
+	if status == http.StatusNoContent || status == http.StatusNotModified || status < 200 {
+		w.decided = true
+	}
+	w.decide()
+	w.statusRecorder.WriteHeader(status)
+}
+
+func (w *gzipWriter) Write(data []byte) (int, error) {
+	w.decide()
+	if w.status == 0 {
+		w.statusRecorder.WriteHeader(http.StatusOK)
+	}
+	if !w.compress {
+		return w.statusRecorder.Write(data)
+	}
+	w.size += len(data)
+	return w.gzip.Write(data)
+}
+
+func (w *gzipWriter) Flush() {
+	if w.compress {
+		w.gzip.Flush()
+	}
+	w.statusRecorder.Flush()
+}
+
+// Close finishes the gzip stream.
+func (w *gzipWriter) Close() error {
+	if w.compress {
+		return w.gzip.Close()
+	}
+	return nil
+}
+
+// CORSOptions configures the CORS middleware.
+type CORSOptions struct {
+	// AllowedOrigins lists the origins allowed to call the server; "*"
+	// allows any.
+	AllowedOrigins []string
+
+	// AllowedMethods defaults to GET, HEAD, POST, PUT, PATCH and DELETE.
+	AllowedMethods []string
+
+	// AllowedHeaders lists the request headers allowed; if empty, the headers
+	// a preflight asks for are allowed.
+	AllowedHeaders []string
+
+	// ExposedHeaders lists the response headers scripts may read.
+	ExposedHeaders []string
+
+	AllowCredentials bool
+
+	// MaxAge is how long browsers may cache a preflight response.
+	MaxAge time.Duration
+}
+
+// CORS answers preflight requests and adds the CORS headers to responses for
+// allowed origins. Requests from other origins pass through without them, so
+// browsers block their scripts from reading the response. Add it with
+// Router.Use rather than to a group, so preflight requests, which no route
+// matches, reach it.
+func CORS(options CORSOptions) Middleware {
+	methods := options.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			header := w.Header()
+			header.Add("Vary", "Origin")
+
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if origin == "" || !options.allows(origin) {
+				if preflight {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if options.allows("*") && !options.AllowCredentials {
+				header.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				header.Set("Access-Control-Allow-Origin", origin)
+			}
+			if options.AllowCredentials {
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if !preflight {
+				if len(options.ExposedHeaders) > 0 {
+					header.Set("Access-Control-Expose-Headers", strings.Join(options.ExposedHeaders, ", "))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			if len(options.AllowedHeaders) > 0 {
+				header.Set("Access-Control-Allow-Headers", strings.Join(options.AllowedHeaders, ", "))
+			} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+				header.Set("Access-Control-Allow-Headers", requested)
+			}
+			if options.MaxAge > 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(int(options.MaxAge/time.Second)))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// allows reports whether an origin is in AllowedOrigins.
+func (o CORSOptions) allows(origin string) bool {
+	for _, allowed := range o.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// statusRecorder remembers the status and size of a response, and keeps the
+// Flusher and Hijacker of the writer it wraps.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
+func (w *statusRecorder) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("goscript: response does not support hijacking")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return hijacker.Hijack()
+}
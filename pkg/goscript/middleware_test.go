@@ -0,0 +1,167 @@
+package goscript
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/reqctx"
+)
+
+// trace appends a name to the X-Trace header before running the handler
+func trace(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Trace", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestRouterMiddlewareOrder(t *testing.T) {
+	router := NewRouter()
+	router.Use(trace("router"))
+	api := router.Group("/api")
+	api.Use(trace("api"))
+	admin := api.Group("/admin")
+	admin.Use(trace("admin"))
+	admin.GET("/stats", echoRoute("stats"))
+	router.GET("/", echoRoute("home"))
+
+	recorder := serve(router, "GET", "/api/admin/stats")
+	if got := strings.Join(recorder.Header()["X-Trace"], ","); got != "router,api,admin" {
+		t.Fatalf("expected outer middleware first, got %s", got)
+	}
+	if got := strings.Join(serve(router, "GET", "/").Header()["X-Trace"], ","); got != "router" {
+		t.Fatalf("expected group middleware to stay in its group, got %s", got)
+	}
+	if got := strings.Join(serve(router, "GET", "/missing").Header()["X-Trace"], ","); got != "router" {
+		t.Fatalf("expected router middleware on unmatched requests, got %s", got)
+	}
+}
+
+func TestRecovererAndLogger(t *testing.T) {
+	var logs bytes.Buffer
+	logger := log.New(&logs, "", 0)
+
+	router := NewRouter()
+	router.Use(RequestID(), Logger(logger), Recoverer(logger))
+	router.GET("/boom", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		panic("boom")
+	})
+
+	request := httptest.NewRequest("GET", "/boom", nil)
+	request.Header.Set(RequestIDHeader, "req-1")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", recorder.Code)
+	}
+	if id := recorder.Header().Get(RequestIDHeader); id != "req-1" {
+		t.Fatalf("expected the request ID to be echoed, got %q", id)
+	}
+	output := logs.String()
+	if !strings.Contains(output, "[req-1] panic serving GET /boom: boom") || !strings.Contains(output, "[req-1] GET /boom 500") {
+		t.Fatalf("unexpected log output %s", output)
+	}
+
+	request = httptest.NewRequest("GET", "/boom", nil)
+	request.Header.Set(RequestIDHeader, "bad\nid")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if id := recorder.Header().Get(RequestIDHeader); len(id) != 32 {
+		t.Fatalf("expected a generated request ID, got %q", id)
+	}
+}
+
+func TestRequestIDTrustedProxies(t *testing.T) {
+	router := NewRouter()
+	router.Use(RequestID("10.0.0.0/8"))
+	router.GET("/whoami", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		w.Write([]byte(reqctx.Principal(r.Context())))
+	})
+
+	for remote, want := range map[string]string{"10.1.2.3:4000": "ada", "203.0.113.9:4000": ""} {
+		request := httptest.NewRequest("GET", "/whoami", nil)
+		request.RemoteAddr = remote
+		request.Header.Set(reqctx.PrincipalHeader, "ada")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		if got := recorder.Body.String(); got != want {
+			t.Fatalf("expected principal %q from %s, got %q", want, remote, got)
+		}
+	}
+}
+
+func TestGzip(t *testing.T) {
+	router := NewRouter()
+	router.Use(Gzip(gzip.BestSpeed))
+	router.GET("/text", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		w.Write([]byte(strings.Repeat("hello ", 100)))
+	})
+
+	request := httptest.NewRequest("GET", "/text", nil)
+	request.Header.Set("Accept-Encoding", "br, gzip;q=0.8")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	if recorder.Header().Get("Content-Encoding") != "gzip" || recorder.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("expected a gzip response, got headers %v", recorder.Header())
+	}
+	reader, err := gzip.NewReader(recorder.Body)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	body, _ := ioutil.ReadAll(reader)
+	if string(body) != strings.Repeat("hello ", 100) {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	request.Header.Set("Accept-Encoding", "gzip;q=0")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Header().Get("Content-Encoding") != "" || !strings.HasPrefix(recorder.Body.String(), "hello") {
+		t.Fatalf("expected a plain response when gzip is refused")
+	}
+}
+
+func TestCORS(t *testing.T) {
+	router := NewRouter()
+	router.Use(CORS(CORSOptions{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}))
+	router.POST("/api", echoRoute("api"))
+
+	preflight := httptest.NewRequest("OPTIONS", "/api", nil)
+	preflight.Header.Set("Origin", "https://app.example.com")
+	preflight.Header.Set("Access-Control-Request-Method", "POST")
+	preflight.Header.Set("Access-Control-Request-Headers", "Content-Type")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, preflight)
+
+	header := recorder.Header()
+	if recorder.Code != http.StatusNoContent ||
+		header.Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		header.Get("Access-Control-Allow-Credentials") != "true" ||
+		header.Get("Access-Control-Allow-Headers") != "Content-Type" ||
+		header.Get("Access-Control-Max-Age") != "600" {
+		t.Fatalf("unexpected preflight response %d %v", recorder.Code, header)
+	}
+
+	request := httptest.NewRequest("POST", "/api", nil)
+	request.Header.Set("Origin", "https://evil.example.com")
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Header().Get("Access-Control-Allow-Origin") != "" || recorder.Code != http.StatusOK {
+		t.Fatalf("expected no CORS headers for another origin, got %v", recorder.Header())
+	}
+}
@@ -0,0 +1,28 @@
+// Package naming converts Go and GraphQL names, such as "BlogPost", to the
+// forms other modules name things by: the tables and columns GoScaleAPI
+// migrates, fakes and gopm scaffolds, and the keys of config files.
+package naming
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SnakeCase converts a name such as "BlogPost", "createdAt" or
+// "DBConnectionString" to "blog_post", "created_at" or
+// "db_connection_string". A run of capitals is one word, ending before a
+// capital followed by a lower case letter.
+func SnakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
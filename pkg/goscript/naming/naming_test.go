@@ -0,0 +1,20 @@
+package naming
+
+import "testing"
+
+func TestSnakeCase(t *testing.T) {
+	for name, want := range map[string]string{
+		"BlogPost":           "blog_post",
+		"createdAt":          "created_at",
+		"DBConnectionString": "db_connection_string",
+		"userID":             "user_id",
+		"HTTPServer":         "http_server",
+		"id":                 "id",
+		"already_snake":      "already_snake",
+		"":                   "",
+	} {
+		if got := SnakeCase(name); got != want {
+			t.Errorf("SnakeCase(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
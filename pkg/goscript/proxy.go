@@ -0,0 +1,166 @@
+package goscript
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReverseProxy forwards requests to another server, such as an API running
+// as its own service. Mount it on a router to serve both from one origin:
+//
+//	proxy, err := goscript.NewReverseProxy("http://localhost:9000/v1")
+//	router.Mount("/api", proxy)
+//
+// Set its fields before it serves the first request.
+type ReverseProxy struct {
+	// Target is the server requests go to. Its path prefixes theirs and its
+	// query is added to theirs.
+	Target *url.URL
+
+	// Rewrite, if set, changes the path sent to the target, after a mount
+	// prefix is stripped.
+	Rewrite func(path string) string
+
+	// Headers are set on every forwarded request, such as an API key.
+	Headers http.Header
+
+	// PreserveHost sends the client's Host header instead of the target's.
+	PreserveHost bool
+
+	// Timeout is how long the target has to start its response; 30 seconds
+	// by default. DialTimeout is how long connecting to it may take; 10
+	// seconds by default.
+	Timeout     time.Duration
+	DialTimeout time.Duration
+
+	// Transport sends the forwarded requests; one using the timeouts above
+	// by default.
+	Transport http.RoundTripper
+
+	// Logger logs failed requests; the standard logger if nil.
+	Logger *log.Logger
+
+	once  sync.Once
+	proxy *httputil.ReverseProxy
+}
+
+// NewReverseProxy creates a proxy to a target URL, such as
+// "http://localhost:9000".
+func NewReverseProxy(target string) (*ReverseProxy, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, errors.New("goscript: proxy target " + target + " must be an absolute URL")
+	}
+
+	return &ReverseProxy{Target: u}, nil
+}
+
+// ServeHTTP forwards a request and copies back the response. The target
+// learns the client's address, host and protocol from the X-Forwarded-For,
+// X-Forwarded-Host and X-Forwarded-Proto headers. It answers 504 Gateway
+// Timeout when the target is too slow and 502 Bad Gateway when it fails.
+func (p *ReverseProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.once.Do(p.init)
+	p.proxy.ServeHTTP(w, r)
+}
+
+func (p *ReverseProxy) init() {
+	transport := p.Transport
+	if transport == nil {
+		timeout := p.Timeout
+		if timeout <= 0 {
+			timeout = 30 * time.Second
+		}
+		dialTimeout := p.DialTimeout
+		if dialTimeout <= 0 {
+			dialTimeout = 10 * time.Second
+		}
+
+		defaultTransport := http.DefaultTransport.(*http.Transport).Clone()
+		defaultTransport.DialContext = (&net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}).DialContext
+		defaultTransport.ResponseHeaderTimeout = timeout
+		transport = defaultTransport
+	}
+
+	p.proxy = &httputil.ReverseProxy{
+		Director:     p.direct,
+		Transport:    transport,
+		ErrorLog:     p.Logger,
+		ErrorHandler: p.fail,
+		// Stream responses, such as server-sent events, as they arrive
+		FlushInterval: -1,
+	}
+}
+
+// direct points a request at the target.
+func (p *ReverseProxy) direct(req *http.Request) {
+	path := req.URL.Path
+	if p.Rewrite != nil {
+		path = p.Rewrite(path)
+	}
+
+	req.Header.Set("X-Forwarded-Host", req.Host)
+	if req.TLS != nil {
+		req.Header.Set("X-Forwarded-Proto", "https")
+	} else {
+		req.Header.Set("X-Forwarded-Proto", "http")
+	}
+
+	req.URL.Scheme = p.Target.Scheme
+	req.URL.Host = p.Target.Host
+	req.URL.Path = strings.TrimSuffix(p.Target.Path, "/") + "/" + strings.TrimPrefix(path, "/")
+	req.URL.RawPath = ""
+	if p.Target.RawQuery != "" {
+		if req.URL.RawQuery == "" {
+			req.URL.RawQuery = p.Target.RawQuery
+		} else {
+			req.URL.RawQuery = p.Target.RawQuery + "&" + req.URL.RawQuery
+		}
+	}
+	if !p.PreserveHost {
+		req.Host = p.Target.Host
+	}
+
+	// Without one, Go's client would send its own User-Agent
+	if _, ok := req.Header["User-Agent"]; !ok {
+		req.Header.Set("User-Agent", "")
+	}
+	for name, values := range p.Headers {
+		req.Header[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+}
+
+// fail answers a request the target could not.
+func (p *ReverseProxy) fail(w http.ResponseWriter, r *http.Request, err error) {
+	// The client went away, so there is no one to answer
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+
+	p.logger().Printf("goscript: proxying %s %s to %s: %v", r.Method, r.URL.Path, p.Target.Host, err)
+
+	status := http.StatusBadGateway
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		status = http.StatusGatewayTimeout
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+func (p *ReverseProxy) logger() *log.Logger {
+	if p.Logger != nil {
+		return p.Logger
+	}
+	return log.Default()
+}
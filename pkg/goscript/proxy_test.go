@@ -0,0 +1,56 @@
+package goscript
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReverseProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		fmt.Fprintf(w, "%s %s?%s host=%s forwarded=%s,%s key=%s", r.Method, r.URL.Path, r.URL.RawQuery,
+			r.Host, r.Header.Get("X-Forwarded-Host"), r.Header.Get("X-Forwarded-Proto"), r.Header.Get("X-Api-Key"))
+	}))
+	defer backend.Close()
+
+	proxy, err := NewReverseProxy(backend.URL + "/v1?client=web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy.Rewrite = func(path string) string { return strings.TrimPrefix(path, "/legacy") }
+	proxy.Headers = http.Header{"X-Api-Key": {"secret"}}
+	proxy.Timeout = 50 * time.Millisecond
+	proxy.Logger = log.New(ioutil.Discard, "", 0)
+
+	router := NewRouter()
+	router.Mount("/api", proxy)
+
+	recorder := serve(router, "POST", "http://example.com/api/legacy/users?page=2")
+	expected := "POST /v1/users?client=web&page=2 host=" + strings.TrimPrefix(backend.URL, "http://") + " forwarded=example.com,http key=secret"
+	if recorder.Code != http.StatusOK || recorder.Body.String() != expected {
+		t.Fatalf("expected %q, got %d %q", expected, recorder.Code, recorder.Body.String())
+	}
+
+	if code := serve(router, "GET", "/api/slow").Code; code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504 for a slow target, got %d", code)
+	}
+
+	down, _ := NewReverseProxy("http://127.0.0.1:1")
+	down.Logger = proxy.Logger
+	router.Mount("/down", down)
+	if code := serve(router, "GET", "/down").Code; code != http.StatusBadGateway {
+		t.Fatalf("expected 502 for an unreachable target, got %d", code)
+	}
+
+	if _, err := NewReverseProxy("/relative"); err == nil {
+		t.Fatalf("expected a relative target to be refused")
+	}
+}
@@ -0,0 +1,281 @@
+// Package reqctx carries who a request is for and how to trace it through
+// every module that handles it: the goscript router's middleware, GoScaleAPI
+// resolvers, GoScaleDB's statement log and change events, edge nodes and the
+// writes they queue, and Jetpack's error reports and audit log. Values are
+// kept in the context.Context the modules already pass along, extracted from
+// the headers of incoming requests and injected into those of outgoing ones.
+package reqctx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers the values travel in between services
+const (
+	// RequestIDHeader carries the ID of a request
+	RequestIDHeader = "X-Request-ID"
+
+	// TraceParentHeader carries the trace and the caller's span, in the
+	// W3C Trace Context format: "00-<trace ID>-<span ID>-<flags>"
+	TraceParentHeader = "traceparent"
+
+	// PrincipalHeader and TenantHeader carry who a request is for. They are
+	// only read by ExtractTrusted, from the app's own services.
+	PrincipalHeader = "X-Principal"
+	TenantHeader    = "X-Tenant-ID"
+)
+
+// Info is what is known about the request a context belongs to. Empty
+// fields are unknown.
+type Info struct {
+	// Principal is who the request is made by, such as the signed-in user
+	// or the service calling
+	Principal string `json:"principal,omitempty"`
+
+	// Tenant is the customer or organization whose data the request is for
+	Tenant string `json:"tenant,omitempty"`
+
+	// RequestID identifies the request in logs, across services
+	RequestID string `json:"request_id,omitempty"`
+
+	// TraceID identifies the trace the request is part of, and SpanID the
+	// work this service does for it
+	TraceID string `json:"trace_id,omitempty"`
+	SpanID  string `json:"span_id,omitempty"`
+}
+
+type infoKey struct{}
+
+// From returns the request info of a context
+func From(ctx context.Context) Info {
+	info, _ := ctx.Value(infoKey{}).(Info)
+	return info
+}
+
+// With returns a context of the request info describes, replacing any
+// info ctx has
+func With(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, infoKey{}, info)
+}
+
+// WithPrincipal returns a context of a request made by principal, such as
+// the user an authentication middleware signed in
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	info := From(ctx)
+	info.Principal = principal
+	return With(ctx, info)
+}
+
+// WithTenant returns a context of a request for tenant's data
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	info := From(ctx)
+	info.Tenant = tenant
+	return With(ctx, info)
+}
+
+// Principal returns who the request is made by, or ""
+func Principal(ctx context.Context) string {
+	return From(ctx).Principal
+}
+
+// Tenant returns the tenant the request is for, or ""
+func Tenant(ctx context.Context) string {
+	return From(ctx).Tenant
+}
+
+// RequestID returns the request's ID, or ""
+func RequestID(ctx context.Context) string {
+	return From(ctx).RequestID
+}
+
+// TraceID returns the request's trace ID, or ""
+func TraceID(ctx context.Context) string {
+	return From(ctx).TraceID
+}
+
+// Extract returns a context with the request ID and trace of an incoming
+// request's headers, ignoring invalid ones. Who the request is for is not
+// read, as any client could claim to be anyone; see ExtractTrusted.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	info := From(ctx)
+	if id := header.Get(RequestIDHeader); ValidID(id) {
+		info.RequestID = id
+	}
+	if trace := parseTraceParent(header.Get(TraceParentHeader)); trace != "" {
+		info.TraceID = trace
+	}
+	return With(ctx, info)
+}
+
+// ExtractTrusted is Extract for requests from the app's own services, such
+// as an edge node forwarding writes to the origin, which also carry the
+// principal and tenant. Only use it behind authentication of the service.
+func ExtractTrusted(ctx context.Context, header http.Header) context.Context {
+	ctx = Extract(ctx, header)
+	info := From(ctx)
+	if principal := header.Get(PrincipalHeader); ValidID(principal) {
+		info.Principal = principal
+	}
+	if tenant := header.Get(TenantHeader); ValidID(tenant) {
+		info.Tenant = tenant
+	}
+	return With(ctx, info)
+}
+
+// ExtractRequest extracts the request info of an incoming request: with
+// ExtractTrusted when it comes from one of the trusted proxies, see
+// Trusted, and with Extract otherwise.
+func ExtractRequest(r *http.Request, trustedProxies []string) context.Context {
+	if Trusted(r, trustedProxies) {
+		return ExtractTrusted(r.Context(), r.Header)
+	}
+	return Extract(r.Context(), r.Header)
+}
+
+// Trusted reports whether a request comes from one of the trusted proxies,
+// the addresses or CIDR ranges of the app's own services, such as
+// "10.0.0.7" or "10.0.0.0/8", by the address it connected from. Forwarded
+// headers are not read, as clients can set them; entries that do not parse
+// trust nothing.
+func Trusted(r *http.Request, trustedProxies []string) bool {
+	if len(trustedProxies) == 0 {
+		return false
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, proxy := range trustedProxies {
+		if _, network, err := net.ParseCIDR(proxy); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if trusted := net.ParseIP(proxy); trusted != nil && trusted.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Inject sets the headers of an outgoing request, such as one to another
+// service, from the request info of ctx
+func Inject(ctx context.Context, header http.Header) {
+	info := From(ctx)
+	set := func(name, value string) {
+		if value != "" {
+			header.Set(name, value)
+		}
+	}
+	set(RequestIDHeader, info.RequestID)
+	set(PrincipalHeader, info.Principal)
+	set(TenantHeader, info.Tenant)
+	if info.TraceID != "" {
+		span := info.SpanID
+		if span == "" {
+			span = newID(8)
+		}
+		header.Set(TraceParentHeader, "00-"+info.TraceID+"-"+span+"-01")
+	}
+}
+
+// Ensure returns a context with a request ID and a trace, generating those
+// it lacks, and a new span for the work this service does
+func Ensure(ctx context.Context) context.Context {
+	info := From(ctx)
+	if info.RequestID == "" {
+		info.RequestID = newID(16)
+	}
+	if info.TraceID == "" {
+		info.TraceID = newID(16)
+	}
+	info.SpanID = newID(8)
+	return With(ctx, info)
+}
+
+// LogPrefix returns the request ID, tenant and principal of ctx to start a
+// log line with, such as "[4bf92f35 tenant=acme user=ada] ", or "" when none
+// is set
+func LogPrefix(ctx context.Context) string {
+	info := From(ctx)
+	var parts []string
+	if info.RequestID != "" {
+		parts = append(parts, info.RequestID)
+	}
+	if info.Tenant != "" {
+		parts = append(parts, "tenant="+info.Tenant)
+	}
+	if info.Principal != "" {
+		parts = append(parts, "user="+info.Principal)
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "[" + strings.Join(parts, " ") + "] "
+}
+
+// ValidID accepts short values of printable ASCII without spaces, so a
+// client cannot inject control characters into logs
+func ValidID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// NewRequestID returns a random request ID
+func NewRequestID() string {
+	return newID(16)
+}
+
+// parseTraceParent returns the trace ID of a traceparent header, or "" if
+// it is not valid
+func parseTraceParent(value string) string {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return ""
+	}
+	trace, span := parts[1], parts[2]
+	if !isHex(trace, 32) || !isHex(span, 16) || trace == strings.Repeat("0", 32) {
+		return ""
+	}
+	return trace
+}
+
+// isHex reports whether s is n lower case hex digits
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !(s[i] >= '0' && s[i] <= '9' || s[i] >= 'a' && s[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// newID returns size random bytes in hex
+func newID(size int) string {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		id := strconv.FormatInt(time.Now().UnixNano(), 16)
+		return strings.Repeat("0", 2*size-len(id)) + id
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,83 @@
+package reqctx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExtractAndInject(t *testing.T) {
+	header := http.Header{}
+	header.Set(RequestIDHeader, "req-1")
+	header.Set(TraceParentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	header.Set(PrincipalHeader, "mallory")
+
+	ctx := Extract(context.Background(), header)
+	if info := From(ctx); info.RequestID != "req-1" || info.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || info.Principal != "" {
+		t.Fatalf("expected the ID and trace but not the principal, got %+v", info)
+	}
+	if Principal(ExtractTrusted(context.Background(), header)) != "mallory" {
+		t.Fatal("expected trusted requests to carry their principal")
+	}
+
+	ctx = Ensure(WithTenant(WithPrincipal(ctx, "ada"), "acme"))
+	out := http.Header{}
+	Inject(ctx, out)
+	if out.Get(RequestIDHeader) != "req-1" || out.Get(PrincipalHeader) != "ada" || out.Get(TenantHeader) != "acme" {
+		t.Fatalf("unexpected headers %v", out)
+	}
+	if want := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + From(ctx).SpanID + "-01"; out.Get(TraceParentHeader) != want {
+		t.Fatalf("expected the trace continued from this span, got %q", out.Get(TraceParentHeader))
+	}
+	if prefix := LogPrefix(ctx); prefix != "[req-1 tenant=acme user=ada] " {
+		t.Fatalf("unexpected log prefix %q", prefix)
+	}
+}
+
+func TestEnsureAndInvalidHeaders(t *testing.T) {
+	header := http.Header{}
+	header.Set(RequestIDHeader, "bad\nid")
+	header.Set(TraceParentHeader, "00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+
+	ctx := Extract(context.Background(), header)
+	if info := From(ctx); info.RequestID != "" || info.TraceID != "" {
+		t.Fatalf("expected invalid headers ignored, got %+v", info)
+	}
+	ctx = Ensure(ctx)
+	if info := From(ctx); len(info.RequestID) != 32 || len(info.TraceID) != 32 || len(info.SpanID) != 16 {
+		t.Fatalf("expected generated IDs, got %+v", info)
+	}
+	if LogPrefix(context.Background()) != "" {
+		t.Fatal("expected no prefix without request info")
+	}
+}
+
+func TestExtractRequest(t *testing.T) {
+	proxies := []string{"10.0.0.0/8", "192.168.1.7", "::1", "not an address"}
+	for remote, trusted := range map[string]bool{
+		"10.1.2.3:4000":    true,
+		"192.168.1.7:4000": true,
+		"192.168.1.8:4000": false,
+		"[::1]:4000":       true,
+		"203.0.113.9:4000": false,
+		"10.1.2.3":         true,
+		"":                 false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remote
+		r.Header.Set(PrincipalHeader, "ada")
+		if got := Trusted(r, proxies); got != trusted {
+			t.Errorf("Trusted(%q) = %v, want %v", remote, got, trusted)
+		}
+		if principal := Principal(ExtractRequest(r, proxies)); (principal == "ada") != trusted {
+			t.Errorf("ExtractRequest(%q) read principal %q", remote, principal)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.1.2.3:4000"
+	if Trusted(r, nil) {
+		t.Fatal("expected no proxies to trust nothing")
+	}
+}
@@ -1,8 +1,14 @@
 package goscript
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
 	"strings"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
 )
 
 type RouteHandler func(w http.ResponseWriter, r *http.Request, params map[string]string)
@@ -11,23 +17,62 @@ type Route struct {
 	Method  string
 	Path    string
 	Handler RouteHandler
+
+	// group is the innermost group the route was registered on, if any
+	group *RouteGroup
+}
+
+// Middleware wraps a handler with behavior shared by many routes, such as
+// logging or authentication.
+type Middleware func(http.Handler) http.Handler
+
+// WithMiddleware wraps a single route's handler with middleware, run in the
+// order given after the router's and its groups', as in
+// router.GET("/docs", goscript.WithMiddleware(docs, cache.Middleware())).
+func WithMiddleware(handler RouteHandler, middleware ...Middleware) RouteHandler {
+	return func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		var next http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler(w, r, params)
+		})
+		for i := len(middleware) - 1; i >= 0; i-- {
+			next = middleware[i](next)
+		}
+		next.ServeHTTP(w, r)
+	}
 }
 
 type Router struct {
 	routes     []Route
-	middleware []func(http.HandlerFunc) http.HandlerFunc
+	middleware []Middleware
 }
 
 func NewRouter() *Router {
 	return &Router{}
 }
 
-func (r *Router) Use(middleware func(http.HandlerFunc) http.HandlerFunc) {
-	r.middleware = append(r.middleware, middleware)
+// Use adds middleware run on every request, in the order added, including
+// requests no route matches.
+func (r *Router) Use(middleware ...Middleware) {
+	r.middleware = append(r.middleware, middleware...)
 }
 
+// Handle registers a handler for a method and path. A segment starting with
+// ':' captures one path segment, as in /users/:id. A last segment starting
+// with '*' captures the rest of the path, as in /files/*path; a bare '*'
+// stores it under "*".
 func (r *Router) Handle(method, path string, handler RouteHandler) {
-	r.routes = append(r.routes, Route{Method: method, Path: path, Handler: handler})
+	r.handle(method, path, handler, nil)
+}
+
+func (r *Router) handle(method, path string, handler RouteHandler, group *RouteGroup) {
+	segments := splitPath(path)
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "*") && i != len(segments)-1 {
+			panic(fmt.Sprintf("goscript: wildcard %q must be the last segment of %q", segment, path))
+		}
+	}
+
+	r.routes = append(r.routes, Route{Method: method, Path: path, Handler: handler, group: group})
 }
 
 func (r *Router) GET(path string, handler RouteHandler) {
@@ -42,51 +87,266 @@ func (r *Router) PUT(path string, handler RouteHandler) {
 	r.Handle("PUT", path, handler)
 }
 
+func (r *Router) PATCH(path string, handler RouteHandler) {
+	r.Handle("PATCH", path, handler)
+}
+
 func (r *Router) DELETE(path string, handler RouteHandler) {
 	r.Handle("DELETE", path, handler)
 }
 
+// anyMethod is the method of mounted handlers, which answer every method.
+const anyMethod = "*"
+
+// Mount passes every request below a path prefix, whatever its method, to a
+// handler, such as a GoScaleAPI, a ReverseProxy or another Router. The
+// handler sees the path with the prefix stripped. Routes registered below the
+// prefix still win, as they are more specific.
+func (r *Router) Mount(prefix string, handler http.Handler) {
+	r.handle(anyMethod, joinPath(prefix, "*"), mountHandler(joinPath(prefix, ""), handler), nil)
+}
+
+// Group returns a group of routes sharing a path prefix.
+func (r *Router) Group(prefix string) *RouteGroup {
+	return &RouteGroup{router: r, prefix: joinPath("", prefix)}
+}
+
+// RouteGroup registers routes under a shared path prefix and middleware.
+type RouteGroup struct {
+	router     *Router
+	parent     *RouteGroup
+	prefix     string
+	middleware []Middleware
+}
+
+// Group returns a nested group whose prefix and middleware extend this one.
+func (g *RouteGroup) Group(prefix string) *RouteGroup {
+	return &RouteGroup{router: g.router, parent: g, prefix: joinPath(g.prefix, prefix)}
+}
+
+// Use adds middleware run, after the router's and any enclosing group's, on
+// the requests routed to the group.
+func (g *RouteGroup) Use(middleware ...Middleware) {
+	g.middleware = append(g.middleware, middleware...)
+}
+
+// Handle registers a handler for a method and a path below the prefix.
+func (g *RouteGroup) Handle(method, path string, handler RouteHandler) {
+	g.router.handle(method, joinPath(g.prefix, path), handler, g)
+}
+
+// Mount passes every request below a path under the prefix to a handler,
+// after the group's middleware, as Router.Mount does.
+func (g *RouteGroup) Mount(prefix string, handler http.Handler) {
+	mounted := joinPath(g.prefix, prefix)
+	g.router.handle(anyMethod, joinPath(mounted, "*"), mountHandler(mounted, handler), g)
+}
+
+func (g *RouteGroup) GET(path string, handler RouteHandler) {
+	g.Handle("GET", path, handler)
+}
+
+func (g *RouteGroup) POST(path string, handler RouteHandler) {
+	g.Handle("POST", path, handler)
+}
+
+func (g *RouteGroup) PUT(path string, handler RouteHandler) {
+	g.Handle("PUT", path, handler)
+}
+
+func (g *RouteGroup) PATCH(path string, handler RouteHandler) {
+	g.Handle("PATCH", path, handler)
+}
+
+func (g *RouteGroup) DELETE(path string, handler RouteHandler) {
+	g.Handle("DELETE", path, handler)
+}
+
+// ServeHTTP runs the router's middleware and then the most specific route
+// matching the request: static segments win over parameters, and parameters
+// over wildcards. GET routes also answer HEAD. A path registered only for
+// other methods gets 405 Method Not Allowed with an Allow header.
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	for _, route := range r.routes {
-		if route.Method == req.Method {
-			params, ok := matchPath(route.Path, req.URL.Path)
-			if ok {
-				handler := func(w http.ResponseWriter, r *http.Request) {
-					route.Handler(w, r, params)
-				}
-
-				// Apply middleware
-				for i := len(r.middleware) - 1; i >= 0; i-- {
-					handler = r.middleware[i](handler)
-				}
-
-				handler(w, req)
-				return
+	var handler http.Handler = http.HandlerFunc(r.dispatch)
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		handler = r.middleware[i](handler)
+	}
+
+	handler.ServeHTTP(w, req)
+}
+
+// dispatch runs the route matching a request through its groups' middleware.
+func (r *Router) dispatch(w http.ResponseWriter, req *http.Request) {
+	match, matchParams, allowed := r.find(req.Method, req.URL.Path)
+	if match == nil && req.Method == "HEAD" {
+		match, matchParams, _ = r.find("GET", req.URL.Path)
+	}
+
+	if match == nil {
+		if len(allowed) > 0 {
+			if allowed["GET"] {
+				allowed["HEAD"] = true
+			}
+			methods := make([]string, 0, len(allowed))
+			for method := range allowed {
+				methods = append(methods, method)
 			}
+			sort.Strings(methods)
+			w.Header().Set("Allow", strings.Join(methods, ", "))
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
 		}
+		http.NotFound(w, req)
+		return
 	}
 
-	http.NotFound(w, req)
+	// Label the request for Jetpack's HTTP metrics, with the prefixes of the
+	// routers it is mounted under
+	prefix, _ := req.Context().Value(mountPrefixKey{}).(string)
+	core.SetRoute(req, joinPath(prefix, match.Path))
+
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		match.Handler(w, r, matchParams)
+	})
+
+	// Inner groups' middleware runs closest to the handler
+	for group := match.group; group != nil; group = group.parent {
+		for i := len(group.middleware) - 1; i >= 0; i-- {
+			handler = group.middleware[i](handler)
+		}
+	}
+
+	handler.ServeHTTP(w, req)
 }
 
-func matchPath(routePath, requestPath string) (map[string]string, bool) {
-	routeParts := strings.Split(strings.Trim(routePath, "/"), "/")
-	requestParts := strings.Split(strings.Trim(requestPath, "/"), "/")
+// find returns the most specific route for a method and path, with its
+// parameters, and the methods of every route matching the path.
+func (r *Router) find(method, path string) (*Route, map[string]string, map[string]bool) {
+	var match *Route
+	var matchParams map[string]string
+	var matchScore []int
+	allowed := make(map[string]bool)
 
-	if len(routeParts) != len(requestParts) {
-		return nil, false
+	for i := range r.routes {
+		route := &r.routes[i]
+		params, score, ok := matchPath(route.Path, path)
+		if !ok {
+			continue
+		}
+
+		if route.Method != anyMethod {
+			allowed[route.Method] = true
+		}
+		if (route.Method == method || route.Method == anyMethod) && (match == nil || moreSpecific(score, matchScore)) {
+			match, matchParams, matchScore = route, params, score
+		}
 	}
 
+	return match, matchParams, allowed
+}
+
+type mountPrefixKey struct{}
+
+// mountHandler calls a mounted handler with the mount prefix stripped from
+// the request path.
+func mountHandler(prefix string, handler http.Handler) RouteHandler {
+	strip := func(path string) string {
+		if prefix != "/" {
+			path = strings.TrimPrefix(path, prefix)
+		}
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		return path
+	}
+
+	return func(w http.ResponseWriter, req *http.Request, params map[string]string) {
+		outer, _ := req.Context().Value(mountPrefixKey{}).(string)
+		mounted := req.WithContext(context.WithValue(req.Context(), mountPrefixKey{}, joinPath(outer, prefix)))
+		mounted.URL = new(url.URL)
+		*mounted.URL = *req.URL
+		mounted.URL.Path = strip(req.URL.Path)
+		if req.URL.RawPath != "" {
+			mounted.URL.RawPath = strip(req.URL.RawPath)
+		}
+		handler.ServeHTTP(w, mounted)
+	}
+}
+
+// matchPath matches a request path against a route path. It returns the
+// captured parameters and a score per route segment: 2 for static, 1 for a
+// parameter and 0 for a wildcard.
+func matchPath(routePath, requestPath string) (map[string]string, []int, bool) {
+	routeParts := splitPath(routePath)
+	requestParts := splitPath(requestPath)
+
 	params := make(map[string]string)
+	score := make([]int, 0, len(routeParts))
 
 	for i, routePart := range routeParts {
+		if strings.HasPrefix(routePart, "*") {
+			name := routePart[1:]
+			if name == "" {
+				name = "*"
+			}
+			if i < len(requestParts) {
+				params[name] = strings.Join(requestParts[i:], "/")
+			} else {
+				params[name] = ""
+			}
+			return params, append(score, 0), true
+		}
+
+		if i >= len(requestParts) {
+			return nil, nil, false
+		}
 		if strings.HasPrefix(routePart, ":") {
 			params[routePart[1:]] = requestParts[i]
-		} else if routePart != requestParts[i] {
-			return nil, false
+			score = append(score, 1)
+		} else if routePart == requestParts[i] {
+			score = append(score, 2)
+		} else {
+			return nil, nil, false
 		}
 	}
 
-	return params, true
+	if len(routeParts) != len(requestParts) {
+		return nil, nil, false
+	}
+
+	return params, score, true
 }
 
+// moreSpecific reports whether a route score beats another, comparing
+// segment by segment from the start of the path. Scores only differ in
+// length past that when a wildcard matched nothing, as /files/* does for
+// /files, and then the route without it wins.
+func moreSpecific(score, other []int) bool {
+	for i := 0; i < len(score) && i < len(other); i++ {
+		if score[i] != other[i] {
+			return score[i] > other[i]
+		}
+	}
+	return len(score) < len(other)
+}
+
+// splitPath returns the segments of a path; the root path has none.
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// joinPath joins a group prefix and a path into a rooted path.
+func joinPath(prefix, path string) string {
+	joined := strings.Trim(prefix, "/")
+	if trimmed := strings.Trim(path, "/"); trimmed != "" {
+		if joined != "" {
+			joined += "/"
+		}
+		joined += trimmed
+	}
+	return "/" + joined
+}
@@ -0,0 +1,138 @@
+package goscript
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+// echoRoute writes the route name and its parameters
+func echoRoute(name string) RouteHandler {
+	return func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		fmt.Fprintf(w, "%s %v", name, params)
+	}
+}
+
+func serve(router *Router, method, path string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+	return recorder
+}
+
+func TestRouterParamsAndWildcards(t *testing.T) {
+	router := NewRouter()
+	router.GET("/users/:id", echoRoute("user"))
+	router.GET("/users/new", echoRoute("new"))
+	router.GET("/files/*path", echoRoute("files"))
+	router.GET("/files/public/*", echoRoute("public"))
+	router.GET("/", echoRoute("home"))
+
+	cases := map[string]string{
+		"/users/42":               "user map[id:42]",
+		"/users/new":              "new map[]",
+		"/files/docs/a/b.txt":     "files map[path:docs/a/b.txt]",
+		"/files/public/style.css": "public map[*:style.css]",
+		"/":                       "home map[]",
+	}
+	for path, expected := range cases {
+		if body := serve(router, "GET", path).Body.String(); body != expected {
+			t.Fatalf("%s: expected %q, got %q", path, expected, body)
+		}
+	}
+
+	if code := serve(router, "GET", "/users/42/posts").Code; code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown path, got %d", code)
+	}
+}
+
+func TestRouterGroups(t *testing.T) {
+	router := NewRouter()
+	api := router.Group("/api")
+	v1 := api.Group("v1/")
+	v1.GET("/users/:id", echoRoute("user"))
+	v1.POST("", echoRoute("root"))
+
+	if body := serve(router, "GET", "/api/v1/users/7").Body.String(); body != "user map[id:7]" {
+		t.Fatalf("expected the grouped route, got %q", body)
+	}
+	if body := serve(router, "POST", "/api/v1").Body.String(); body != "root map[]" {
+		t.Fatalf("expected the group root, got %q", body)
+	}
+}
+
+func TestRouterMethodNotAllowed(t *testing.T) {
+	router := NewRouter()
+	router.GET("/posts/:id", echoRoute("show"))
+	router.DELETE("/posts/:id", echoRoute("delete"))
+
+	recorder := serve(router, "PUT", "/posts/1")
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", recorder.Code)
+	}
+	if allow := recorder.Header().Get("Allow"); allow != "DELETE, GET, HEAD" {
+		t.Fatalf("unexpected Allow header %q", allow)
+	}
+
+	if code := serve(router, "HEAD", "/posts/1").Code; code != http.StatusOK {
+		t.Fatalf("expected GET routes to answer HEAD, got %d", code)
+	}
+}
+
+func TestRouterMount(t *testing.T) {
+	api := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "api %s %s", r.Method, r.URL.Path)
+	})
+
+	router := NewRouter()
+	router.Mount("/api", api)
+	router.GET("/api/health", echoRoute("health"))
+	router.Group("/admin").Mount("/", api)
+	router.Mount("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "site %s", r.URL.Path)
+	}))
+	router.GET("/", echoRoute("home"))
+
+	cases := map[string]string{
+		"POST /api":            "api POST /",
+		"PUT /api/users/7":     "api PUT /users/7",
+		"GET /api/health":      "health map[]",
+		"DELETE /admin/users/": "api DELETE /users/",
+		"GET /about":           "site /about",
+		"GET /":                "home map[]",
+	}
+	for request, expected := range cases {
+		parts := strings.SplitN(request, " ", 2)
+		if body := serve(router, parts[0], parts[1]).Body.String(); body != expected {
+			t.Fatalf("%s: expected %q, got %q", request, expected, body)
+		}
+	}
+}
+
+func TestRouterJetpackRoutes(t *testing.T) {
+	jp := core.NewJetpack()
+	users := NewRouter()
+	users.GET("/:id", echoRoute("user"))
+
+	router := NewRouter()
+	router.Use(jp.HTTPMiddleware)
+	router.Group("/api").Mount("/users", users)
+	router.GET("/", echoRoute("home"))
+
+	for _, path := range []string{"/", "/api/users/1", "/api/users/2", "/missing"} {
+		serve(router, "GET", path)
+	}
+	for name, count := range map[string]int{
+		"http_requests":                    4,
+		"http_requests@GET /":              1,
+		"http_requests@GET /api/users/:id": 2,
+		"http_responses_4xx":               1,
+	} {
+		if stats, err := jp.GetMetricStats(name, 0); err != nil || stats.Count != count {
+			t.Fatalf("expected %d values of %s, got %+v %v", count, name, stats, err)
+		}
+	}
+}
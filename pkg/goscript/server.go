@@ -0,0 +1,259 @@
+package goscript
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Server runs an http.Handler until the process is told to stop, then stops
+// accepting connections and lets in-flight requests finish.
+type Server struct {
+	// Addr is the address to listen on, such as ":8080".
+	Addr string
+
+	Handler http.Handler
+
+	// ReadHeaderTimeout bounds the time to read request headers. The
+	// default, 10 seconds, guards against slow clients holding connections.
+	ReadHeaderTimeout time.Duration
+
+	// ReadTimeout and WriteTimeout bound reading a whole request and writing
+	// its response. They default to none, so streamed pages and WebSockets
+	// are not cut off.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// IdleTimeout is how long a keep-alive connection waits for the next
+	// request; 2 minutes by default.
+	IdleTimeout time.Duration
+
+	// ShutdownTimeout is how long in-flight requests get to finish once the
+	// server stops; 30 seconds by default. Connections still open after it
+	// are closed.
+	ShutdownTimeout time.Duration
+
+	// Signals stop the server; os.Interrupt and SIGTERM by default.
+	Signals []os.Signal
+
+	// CertFile and KeyFile serve HTTPS from a certificate and key.
+	CertFile string
+	KeyFile  string
+
+	// TLSConfig serves HTTPS with its certificates; CertFile and KeyFile are
+	// added to it.
+	TLSConfig *tls.Config
+
+	// GetCertificate serves HTTPS with certificates obtained on demand, such
+	// as an ACME client's, for example autocert.Manager.GetCertificate.
+	GetCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+
+	// RedirectAddr, such as ":80", serves redirects to HTTPS when the server
+	// uses TLS.
+	RedirectAddr string
+
+	// ChallengeHandler wraps the redirect handler to answer ACME HTTP-01
+	// challenges, for example autocert.Manager.HTTPHandler.
+	ChallengeHandler func(http.Handler) http.Handler
+
+	// DisableHTTP2 turns off HTTP/2, which HTTPS serves by default.
+	DisableHTTP2 bool
+
+	// Logger logs when the server starts and stops; the standard logger if
+	// nil.
+	Logger *log.Logger
+
+	onShutdown []func()
+}
+
+// NewServer creates a server for a handler with the default timeouts.
+func NewServer(addr string, handler http.Handler) *Server {
+	return &Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		ShutdownTimeout:   30 * time.Second,
+	}
+}
+
+// OnShutdown registers a function called when the server starts shutting
+// down, such as closing WebSockets, which the server does not track.
+func (s *Server) OnShutdown(f func()) {
+	s.onShutdown = append(s.onShutdown, f)
+}
+
+// TLS reports whether the server serves HTTPS.
+func (s *Server) TLS() bool {
+	return s.CertFile != "" || s.TLSConfig != nil || s.GetCertificate != nil
+}
+
+// ListenAndServe serves until one of the Signals arrives, then shuts down
+// gracefully. It returns nil after a clean shutdown.
+func (s *Server) ListenAndServe() error {
+	signals := s.Signals
+	if len(signals) == 0 {
+		signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), signals...)
+	defer stop()
+
+	return s.Run(ctx)
+}
+
+// Run serves until ctx is done, then shuts down gracefully.
+func (s *Server) Run(ctx context.Context) error {
+	addr := s.Addr
+	if addr == "" {
+		addr = ":http"
+		if s.TLS() {
+			addr = ":https"
+		}
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, listener)
+}
+
+// Serve serves connections from listener until ctx is done, then shuts down
+// gracefully.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	server := s.httpServer()
+	for _, f := range s.onShutdown {
+		server.RegisterOnShutdown(f)
+	}
+
+	errs := make(chan error, 2)
+	go func() {
+		if s.TLS() {
+			errs <- server.ServeTLS(listener, s.CertFile, s.KeyFile)
+		} else {
+			errs <- server.Serve(listener)
+		}
+	}()
+
+	var redirect *http.Server
+	if s.TLS() && s.RedirectAddr != "" {
+		redirect = s.redirectServer()
+		go func() {
+			errs <- redirect.ListenAndServe()
+		}()
+	}
+
+	scheme := "http"
+	if s.TLS() {
+		scheme = "https"
+	}
+	s.logger().Printf("Serving %s on %s", scheme, listener.Addr())
+
+	select {
+	case err := <-errs:
+		// A listener failed; stop the other one too
+		s.shutdown(server, redirect)
+		return err
+	case <-ctx.Done():
+	}
+
+	s.logger().Printf("Shutting down, waiting up to %s for requests to finish", s.shutdownTimeout())
+	return s.shutdown(server, redirect)
+}
+
+// shutdown stops the servers, closing connections still open after
+// ShutdownTimeout.
+func (s *Server) shutdown(servers ...*http.Server) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout())
+	defer cancel()
+
+	var result error
+	for _, server := range servers {
+		if server == nil {
+			continue
+		}
+		if err := server.Shutdown(ctx); err != nil {
+			server.Close()
+			if result == nil && !errors.Is(err, http.ErrServerClosed) {
+				result = err
+			}
+		}
+	}
+	return result
+}
+
+// httpServer creates the http.Server for the settings.
+func (s *Server) httpServer() *http.Server {
+	server := &http.Server{
+		Addr:              s.Addr,
+		Handler:           s.Handler,
+		ReadHeaderTimeout: s.ReadHeaderTimeout,
+		ReadTimeout:       s.ReadTimeout,
+		WriteTimeout:      s.WriteTimeout,
+		IdleTimeout:       s.IdleTimeout,
+		ErrorLog:          s.Logger,
+	}
+
+	if s.TLS() {
+		config := &tls.Config{MinVersion: tls.VersionTLS12}
+		if s.TLSConfig != nil {
+			config = s.TLSConfig.Clone()
+		}
+		if s.GetCertificate != nil {
+			config.GetCertificate = s.GetCertificate
+		}
+		server.TLSConfig = config
+	}
+	if s.DisableHTTP2 {
+		// A non-nil, empty map turns off the automatic HTTP/2 setup
+		server.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	}
+	return server
+}
+
+// redirectServer creates the server sending plain HTTP requests to HTTPS.
+func (s *Server) redirectServer() *http.Server {
+	_, port, _ := net.SplitHostPort(s.Addr)
+
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" && port != "https" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+	if s.ChallengeHandler != nil {
+		handler = s.ChallengeHandler(handler)
+	}
+
+	return &http.Server{
+		Addr:              s.RedirectAddr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       s.IdleTimeout,
+		ErrorLog:          s.Logger,
+	}
+}
+
+func (s *Server) shutdownTimeout() time.Duration {
+	if s.ShutdownTimeout <= 0 {
+		return 30 * time.Second
+	}
+	return s.ShutdownTimeout
+}
+
+func (s *Server) logger() *log.Logger {
+	if s.Logger != nil {
+		return s.Logger
+	}
+	return log.Default()
+}
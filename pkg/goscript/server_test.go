@@ -0,0 +1,107 @@
+package goscript
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServerDrainsRequestsOnShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	server := NewServer("", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	}))
+	server.Logger = log.New(ioutil.Discard, "", 0)
+
+	shutdownCalled := make(chan struct{})
+	server.OnShutdown(func() { close(shutdownCalled) })
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(ctx, listener) }()
+
+	responses := make(chan string, 1)
+	go func() {
+		response, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			responses <- err.Error()
+			return
+		}
+		defer response.Body.Close()
+		body, _ := ioutil.ReadAll(response.Body)
+		responses <- string(body)
+	}()
+
+	<-started
+	cancel()
+	<-shutdownCalled
+
+	select {
+	case err := <-served:
+		t.Fatalf("expected the server to wait for the request, returned %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	if body := <-responses; body != "done" {
+		t.Fatalf("expected the in-flight request to finish, got %q", body)
+	}
+	if err := <-served; err != nil {
+		t.Fatalf("expected a clean shutdown, got %v", err)
+	}
+	if _, err := net.Dial("tcp", listener.Addr().String()); err == nil {
+		t.Fatalf("expected the listener to be closed")
+	}
+}
+
+func TestServerTLSWithHTTP2(t *testing.T) {
+	// Borrow the test server's certificate and a client trusting it
+	test := httptest.NewUnstartedServer(nil)
+	test.EnableHTTP2 = true
+	test.StartTLS()
+	certificate := test.TLS.Certificates[0]
+	client := test.Client()
+	test.Close()
+
+	server := NewServer("127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	server.Logger = log.New(ioutil.Discard, "", 0)
+	server.TLSConfig = &tls.Config{Certificates: []tls.Certificate{certificate}}
+
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(ctx, listener) }()
+
+	response, err := client.Get("https://" + listener.Addr().String())
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	body, _ := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if string(body) != "HTTP/2.0" {
+		t.Fatalf("expected HTTP/2 over TLS, got %s", body)
+	}
+
+	cancel()
+	if err := <-served; err != nil {
+		t.Fatalf("expected a clean shutdown, got %v", err)
+	}
+}
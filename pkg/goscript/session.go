@@ -0,0 +1,421 @@
+package goscript
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/reqctx"
+)
+
+// ErrNoSession is returned by the session helpers when the request did not
+// pass through SessionManager.Middleware.
+var ErrNoSession = errors.New("goscript: no session in request context; add SessionManager.Middleware")
+
+// maxCookieSize is the largest cookie browsers are guaranteed to keep.
+const maxCookieSize = 4096
+
+// Session holds the values of one visitor between requests. Values are stored
+// as JSON, so numbers read back as float64 and structs as maps.
+type Session struct {
+	mu      sync.Mutex
+	id      string
+	oldID   string
+	values  map[string]interface{}
+	changed bool
+	guard   AccessGuard
+}
+
+// ID returns the session ID, or "" for a new session until it is saved.
+func (s *Session) ID() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.id
+}
+
+// Get returns a value, or nil if the session has none.
+func (s *Session) Get(key string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.values[key]
+}
+
+// GetString returns a string value, or "" if the session has none.
+func (s *Session) GetString(key string) string {
+	value, _ := s.Get(key).(string)
+	return value
+}
+
+// Set stores a value; it must encode as JSON.
+func (s *Session) Set(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	s.changed = true
+}
+
+// Delete removes a value.
+func (s *Session) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.values[key]; ok {
+		delete(s.values, key)
+		s.changed = true
+	}
+}
+
+// Renew gives the session a new ID, keeping its values. Call it when the
+// visitor's privileges change, such as on login, so an ID planted before
+// cannot be used to take the session over.
+func (s *Session) Renew() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.oldID == "" {
+		s.oldID = s.id
+	}
+	s.id = newSessionID()
+	s.changed = true
+}
+
+// Destroy drops the session's values, removes it from the store and clears
+// the cookie. Values set afterwards start a new session.
+func (s *Session) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.oldID == "" {
+		s.oldID = s.id
+	}
+	s.id = ""
+	s.values = make(map[string]interface{})
+	s.changed = true
+}
+
+type sessionKey struct{}
+
+// SessionFromContext returns the session SessionManager.Middleware loaded
+// for the request, or nil.
+func SessionFromContext(ctx context.Context) *Session {
+	session, _ := ctx.Value(sessionKey{}).(*Session)
+	return session
+}
+
+// SessionManager loads sessions from a signed, encrypted cookie and saves
+// them when the response starts.
+type SessionManager struct {
+	// CookieName names the session cookie; "goscript_session" by default.
+	CookieName string
+
+	// Path and Domain scope the cookie; Path is "/" by default.
+	Path   string
+	Domain string
+
+	// MaxAge is how long a session lasts after it was last saved; 24 hours
+	// by default.
+	MaxAge time.Duration
+
+	// Secure sends the cookie over HTTPS only; set it when serving HTTPS.
+	Secure bool
+
+	// SameSite defaults to http.SameSiteLaxMode, so the cookie is not sent
+	// with cross-site form posts.
+	SameSite http.SameSite
+
+	// Store keeps session values on the server, and the cookie only their ID.
+	// Without one, the values travel in the cookie itself, limited to 4KB.
+	Store SessionStore
+
+	// Logger logs sessions that fail to save; the standard logger if nil.
+	Logger *log.Logger
+
+	// Guard, when set, refuses requests from blocked IPs and users with 429
+	// Too Many Requests, and Login for blocked users.
+	Guard AccessGuard
+
+	keys []cipher.AEAD
+}
+
+// NewSessionManager creates a session manager encrypting cookies with the
+// first key and accepting cookies from any, so keys can be rotated. Keys
+// should be at least 32 random bytes; a nil store keeps sessions in the
+// cookie.
+func NewSessionManager(store SessionStore, keys ...[]byte) *SessionManager {
+	if len(keys) == 0 {
+		panic("goscript: NewSessionManager needs at least one key")
+	}
+
+	m := &SessionManager{
+		CookieName: "goscript_session",
+		Path:       "/",
+		MaxAge:     24 * time.Hour,
+		SameSite:   http.SameSiteLaxMode,
+		Store:      store,
+	}
+	for _, key := range keys {
+		// Hashing gives any key the length AES-256 needs
+		sum := sha256.Sum256(key)
+		block, err := aes.NewCipher(sum[:])
+		if err != nil {
+			panic(err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			panic(err)
+		}
+		m.keys = append(m.keys, aead)
+	}
+	return m
+}
+
+// sessionCookie is the sealed content of the cookie.
+type sessionCookie struct {
+	ID      string                 `json:"id"`
+	Values  map[string]interface{} `json:"values,omitempty"`
+	Expires int64                  `json:"expires"`
+}
+
+// Middleware loads the request's session into its context, where
+// SessionFromContext finds it, and saves it before the response is written.
+// With a Guard, requests from blocked IPs and users are refused.
+func (m *SessionManager) Middleware() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session, err := m.load(r)
+			if err != nil {
+				m.logger().Printf("goscript: loading session: %v", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
+			if m.Guard != nil {
+				session.guard = m.Guard
+				if blocked, retry := m.Guard.Blocked(remoteIP(r), session.GetString(sessionUserKey)); blocked {
+					if retry > 0 {
+						w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+					}
+					http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+					return
+				}
+			}
+
+			ctx := context.WithValue(r.Context(), sessionKey{}, session)
+			// The signed-in user is who the request is made by in every module
+			if user := session.GetString(sessionUserKey); user != "" {
+				ctx = reqctx.WithPrincipal(ctx, user)
+			}
+			writer := &sessionWriter{statusRecorder: statusRecorder{ResponseWriter: w}, manager: m, request: r, session: session}
+			next.ServeHTTP(writer, r.WithContext(ctx))
+			writer.commit()
+		})
+	}
+}
+
+// load opens the session cookie, or starts an empty session.
+func (m *SessionManager) load(r *http.Request) (*Session, error) {
+	session := &Session{values: make(map[string]interface{})}
+
+	cookie, err := r.Cookie(m.CookieName)
+	if err != nil {
+		return session, nil
+	}
+	var content sessionCookie
+	if !m.open(cookie.Value, &content) || time.Now().Unix() >= content.Expires {
+		return session, nil
+	}
+
+	if m.Store == nil {
+		session.id = content.ID
+		if content.Values != nil {
+			session.values = content.Values
+		}
+		return session, nil
+	}
+
+	data, err := m.Store.Load(r.Context(), content.ID)
+	if errors.Is(err, ErrSessionNotFound) {
+		return session, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &session.values); err != nil {
+		// A record the application can no longer read starts over
+		session.values = make(map[string]interface{})
+		return session, nil
+	}
+	session.id = content.ID
+	return session, nil
+}
+
+// save stores a changed session and sets or clears its cookie.
+func (m *SessionManager) save(w http.ResponseWriter, r *http.Request, session *Session) error {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if !session.changed {
+		return nil
+	}
+	session.changed = false
+
+	if m.Store != nil && session.oldID != "" {
+		if err := m.Store.Delete(r.Context(), session.oldID); err != nil {
+			return err
+		}
+	}
+	session.oldID = ""
+
+	if len(session.values) == 0 {
+		if m.Store != nil && session.id != "" {
+			if err := m.Store.Delete(r.Context(), session.id); err != nil {
+				return err
+			}
+		}
+		session.id = ""
+		http.SetCookie(w, m.cookie("", -1))
+		return nil
+	}
+
+	if session.id == "" {
+		session.id = newSessionID()
+	}
+	expires := time.Now().Add(m.maxAge()).Truncate(time.Second)
+
+	content := sessionCookie{ID: session.id, Expires: expires.Unix()}
+	if m.Store == nil {
+		content.Values = session.values
+	} else {
+		data, err := json.Marshal(session.values)
+		if err != nil {
+			return err
+		}
+		if err := m.Store.Save(r.Context(), session.id, data, expires); err != nil {
+			return err
+		}
+	}
+
+	value, err := m.seal(content)
+	if err != nil {
+		return err
+	}
+	if len(m.CookieName)+len(value) > maxCookieSize {
+		return errors.New("goscript: session too large for a cookie; use a SessionStore")
+	}
+	http.SetCookie(w, m.cookie(value, int(m.maxAge()/time.Second)))
+	return nil
+}
+
+func (m *SessionManager) cookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     m.CookieName,
+		Value:    value,
+		Path:     m.Path,
+		Domain:   m.Domain,
+		MaxAge:   maxAge,
+		Secure:   m.Secure,
+		HttpOnly: true,
+		SameSite: m.SameSite,
+	}
+}
+
+// seal encrypts and authenticates the cookie content with the first key. The
+// cookie name is bound in, so a value cannot be moved to another cookie.
+func (m *SessionManager) seal(content sessionCookie) (string, error) {
+	plain, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+	aead := m.keys[0]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plain)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plain, []byte(m.CookieName))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts a cookie value sealed with any of the keys.
+func (m *SessionManager) open(value string, content *sessionCookie) bool {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return false
+	}
+	for _, aead := range m.keys {
+		if len(sealed) < aead.NonceSize() {
+			continue
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		plain, err := aead.Open(nil, nonce, ciphertext, []byte(m.CookieName))
+		if err != nil {
+			continue
+		}
+		return json.Unmarshal(plain, content) == nil
+	}
+	return false
+}
+
+func (m *SessionManager) maxAge() time.Duration {
+	if m.MaxAge <= 0 {
+		return 24 * time.Hour
+	}
+	return m.MaxAge
+}
+
+func (m *SessionManager) logger() *log.Logger {
+	if m.Logger != nil {
+		return m.Logger
+	}
+	return log.Default()
+}
+
+func newSessionID() string {
+	var buf [32]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf[:])
+}
+
+// sessionWriter saves the session just before the response headers go out,
+// the last moment its cookie can be set.
+type sessionWriter struct {
+	statusRecorder
+	manager   *SessionManager
+	request   *http.Request
+	session   *Session
+	committed bool
+}
+
+func (w *sessionWriter) commit() {
+	if w.committed {
+		return
+	}
+	w.committed = true
+	if err := w.manager.save(w.ResponseWriter, w.request, w.session); err != nil {
+		w.manager.logger().Printf("goscript: saving session: %v", err)
+	}
+}
+
+func (w *sessionWriter) WriteHeader(status int) {
+	w.commit()
+	w.statusRecorder.WriteHeader(status)
+}
+
+func (w *sessionWriter) Write(data []byte) (int, error) {
+	w.commit()
+	return w.statusRecorder.Write(data)
+}
+
+func (w *sessionWriter) Flush() {
+	w.commit()
+	w.statusRecorder.Flush()
+}
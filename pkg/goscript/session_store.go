@@ -0,0 +1,156 @@
+package goscript
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// ErrSessionNotFound is returned by SessionStore.Load for a missing or
+// expired session.
+var ErrSessionNotFound = errors.New("goscript: session not found")
+
+// SessionStore keeps session values on the server, keyed by session ID.
+type SessionStore interface {
+	// Load returns the data of an unexpired session, or ErrSessionNotFound.
+	Load(ctx context.Context, id string) ([]byte, error)
+
+	// Save stores the data of a session until it expires.
+	Save(ctx context.Context, id string, data []byte, expires time.Time) error
+
+	// Delete removes a session; deleting a missing one is not an error.
+	Delete(ctx context.Context, id string) error
+}
+
+// MemorySessionStore keeps sessions in memory, for a single server process.
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]memorySession
+	swept    time.Time
+}
+
+type memorySession struct {
+	data    []byte
+	expires time.Time
+}
+
+// NewMemorySessionStore creates an empty in-memory store.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]memorySession)}
+}
+
+func (s *MemorySessionStore) Load(ctx context.Context, id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok || !time.Now().Before(session.expires) {
+		delete(s.sessions, id)
+		return nil, ErrSessionNotFound
+	}
+	return append([]byte(nil), session.data...), nil
+}
+
+func (s *MemorySessionStore) Save(ctx context.Context, id string, data []byte, expires time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[id] = memorySession{data: append([]byte(nil), data...), expires: expires}
+
+	// Abandoned sessions are dropped once a minute at most
+	if now := time.Now(); now.Sub(s.swept) > time.Minute {
+		s.swept = now
+		for id, session := range s.sessions {
+			if !now.Before(session.expires) {
+				delete(s.sessions, id)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *MemorySessionStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+	return nil
+}
+
+// Len returns the number of sessions held, including expired ones not yet
+// dropped.
+func (s *MemorySessionStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// SessionDB is the part of a database DBSessionStore uses; a
+// *db.GoScaleDB provides it.
+type SessionDB interface {
+	Query(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error)
+	Execute(ctx context.Context, query string, args ...interface{}) (int64, error)
+}
+
+var tableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// DBSessionStore keeps sessions in a PostgreSQL table, such as one of
+// GoScaleDB, so every server behind a load balancer shares them.
+type DBSessionStore struct {
+	db    SessionDB
+	table string
+}
+
+// NewDBSessionStore creates a store using a table, optionally qualified by
+// its schema. CreateTable creates it.
+func NewDBSessionStore(db SessionDB, table string) *DBSessionStore {
+	if !tableName.MatchString(table) {
+		panic(fmt.Sprintf("goscript: invalid session table name %q", table))
+	}
+	return &DBSessionStore{db: db, table: table}
+}
+
+// CreateTable creates the session table if it does not exist.
+func (s *DBSessionStore) CreateTable(ctx context.Context) error {
+	_, err := s.db.Execute(ctx, fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (id TEXT PRIMARY KEY, data TEXT NOT NULL, expires_at BIGINT NOT NULL)", s.table))
+	return err
+}
+
+func (s *DBSessionStore) Load(ctx context.Context, id string) ([]byte, error) {
+	rows, err := s.db.Query(ctx, fmt.Sprintf("SELECT data FROM %s WHERE id = $1 AND expires_at > $2", s.table), id, time.Now().Unix())
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrSessionNotFound
+	}
+
+	switch data := rows[0]["data"].(type) {
+	case string:
+		return []byte(data), nil
+	case []byte:
+		return data, nil
+	default:
+		return nil, fmt.Errorf("goscript: unexpected session data type %T", data)
+	}
+}
+
+func (s *DBSessionStore) Save(ctx context.Context, id string, data []byte, expires time.Time) error {
+	_, err := s.db.Execute(ctx, fmt.Sprintf(
+		"INSERT INTO %s (id, data, expires_at) VALUES ($1, $2, $3) "+
+			"ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data, expires_at = EXCLUDED.expires_at", s.table),
+		id, string(data), expires.Unix())
+	return err
+}
+
+func (s *DBSessionStore) Delete(ctx context.Context, id string) error {
+	_, err := s.db.Execute(ctx, fmt.Sprintf("DELETE FROM %s WHERE id = $1", s.table), id)
+	return err
+}
+
+// DeleteExpired removes expired sessions and returns how many there were.
+// Run it periodically; expired sessions are never loaded either way.
+func (s *DBSessionStore) DeleteExpired(ctx context.Context) (int64, error) {
+	return s.db.Execute(ctx, fmt.Sprintf("DELETE FROM %s WHERE expires_at <= $1", s.table), time.Now().Unix())
+}
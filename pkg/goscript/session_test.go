@@ -0,0 +1,236 @@
+package goscript
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sessionApp serves login, logout, a protected page and a form endpoint
+func sessionApp(store SessionStore) *Router {
+	sessions := NewSessionManager(store, []byte("0123456789abcdef0123456789abcdef"))
+	router := NewRouter()
+	router.Use(sessions.Middleware(), CSRF())
+	router.GET("/token", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		w.Write([]byte(CSRFToken(r)))
+	})
+	router.POST("/login", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		Login(r, r.PostFormValue("user"))
+		w.Write([]byte(SessionFromContext(r.Context()).ID()))
+	})
+	router.POST("/logout", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		Logout(r)
+	})
+	account := router.Group("/account")
+	account.Use(RequireLogin("/login"))
+	account.GET("", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		w.Write([]byte("hello " + CurrentUser(r)))
+	})
+	return router
+}
+
+// browser keeps the cookies a router sets between requests
+type browser struct {
+	router  *Router
+	cookies map[string]*http.Cookie
+}
+
+func (b *browser) do(method, path string, form url.Values, header http.Header) *httptest.ResponseRecorder {
+	var request *http.Request
+	if form != nil {
+		request = httptest.NewRequest(method, path, strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		request = httptest.NewRequest(method, path, nil)
+	}
+	for name, values := range header {
+		for _, value := range values {
+			request.Header.Add(name, value)
+		}
+	}
+	for _, cookie := range b.cookies {
+		request.AddCookie(cookie)
+	}
+
+	recorder := httptest.NewRecorder()
+	b.router.ServeHTTP(recorder, request)
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			delete(b.cookies, cookie.Name)
+		} else {
+			b.cookies[cookie.Name] = cookie
+		}
+	}
+	return recorder
+}
+
+func (b *browser) login(t *testing.T, user string) string {
+	t.Helper()
+	token := b.do("GET", "/token", nil, nil).Body.String()
+	recorder := b.do("POST", "/login", url.Values{"user": {user}, CSRFFormField: {token}}, nil)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected the login to succeed, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	return recorder.Body.String()
+}
+
+func TestCookieSessionLogin(t *testing.T) {
+	b := &browser{router: sessionApp(nil), cookies: map[string]*http.Cookie{}}
+
+	recorder := b.do("GET", "/account", nil, http.Header{"Accept": {"text/html"}})
+	if recorder.Code != http.StatusSeeOther || recorder.Header().Get("Location") != "/login?next=%2Faccount" {
+		t.Fatalf("expected a redirect to the login page, got %d %v", recorder.Code, recorder.Header())
+	}
+	if code := b.do("GET", "/account", nil, nil).Code; code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for an API request, got %d", code)
+	}
+
+	b.login(t, "ada")
+	cookie := b.cookies["goscript_session"]
+	if cookie == nil || !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode || strings.Contains(cookie.Value, "ada") {
+		t.Fatalf("expected an encrypted HttpOnly cookie, got %+v", cookie)
+	}
+	if body := b.do("GET", "/account", nil, nil).Body.String(); body != "hello ada" {
+		t.Fatalf("expected the signed-in page, got %q", body)
+	}
+
+	// Tampering with the cookie drops the session
+	tampered := *cookie
+	tampered.Value = cookie.Value[:len(cookie.Value)-2] + "AA"
+	b.cookies["goscript_session"] = &tampered
+	if code := b.do("GET", "/account", nil, nil).Code; code != http.StatusUnauthorized {
+		t.Fatalf("expected a tampered cookie to be rejected, got %d", code)
+	}
+}
+
+func TestStoreSessionRenewAndLogout(t *testing.T) {
+	store := NewMemorySessionStore()
+	b := &browser{router: sessionApp(store), cookies: map[string]*http.Cookie{}}
+
+	b.do("GET", "/token", nil, nil)
+	if store.Len() != 1 {
+		t.Fatalf("expected the CSRF secret to start a session, got %d", store.Len())
+	}
+	first := b.cookies["goscript_session"].Value
+
+	id := b.login(t, "grace")
+	if store.Len() != 1 || id == "" {
+		t.Fatalf("expected the login to replace the session, got %d sessions", store.Len())
+	}
+	if b.cookies["goscript_session"].Value == first {
+		t.Fatalf("expected a new cookie after login")
+	}
+	if body := b.do("GET", "/account", nil, nil).Body.String(); body != "hello grace" {
+		t.Fatalf("expected the signed-in page, got %q", body)
+	}
+
+	token := b.do("GET", "/token", nil, nil).Body.String()
+	b.do("POST", "/logout", nil, http.Header{CSRFHeader: {token}})
+	if store.Len() != 0 || b.cookies["goscript_session"] != nil {
+		t.Fatalf("expected logout to delete the session and its cookie, got %d sessions", store.Len())
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	b := &browser{router: sessionApp(nil), cookies: map[string]*http.Cookie{}}
+
+	if code := b.do("POST", "/logout", nil, nil).Code; code != http.StatusForbidden {
+		t.Fatalf("expected 403 without a token, got %d", code)
+	}
+
+	first := b.do("GET", "/token", nil, nil).Body.String()
+	second := b.do("GET", "/token", nil, nil).Body.String()
+	if first == second {
+		t.Fatalf("expected each token to be masked differently")
+	}
+	if code := b.do("POST", "/logout", nil, http.Header{CSRFHeader: {first}}).Code; code != http.StatusOK {
+		t.Fatalf("expected a valid token to pass, got %d", code)
+	}
+
+	other := &browser{router: b.router, cookies: map[string]*http.Cookie{}}
+	other.do("GET", "/token", nil, nil)
+	if code := other.do("POST", "/logout", nil, http.Header{CSRFHeader: {second}}).Code; code != http.StatusForbidden {
+		t.Fatalf("expected another session's token to be rejected, got %d", code)
+	}
+}
+
+func TestSafeRedirect(t *testing.T) {
+	cases := map[string]string{
+		"/account?tab=1":        "/account?tab=1",
+		"//evil.example.com":    "/",
+		"/\\evil.example.com":   "/",
+		"https://evil.example":  "/",
+		"/\t/evil.example.com":  "/",
+		"/\n/evil.example.com":  "/",
+		"/\r/evil.example.com":  "/",
+		"/%09/evil.example.com": "/%09/evil.example.com",
+		"/a\\b":                 "/",
+		"/\x7f":                 "/",
+		"///evil.example.com":   "/",
+		"evil.example.com":      "/",
+		"javascript:alert(1)":   "/",
+		"":                      "/",
+	}
+	for next, expected := range cases {
+		if got := SafeRedirect(next, "/"); got != expected {
+			t.Fatalf("%q: expected %q, got %q", next, expected, got)
+		}
+	}
+}
+
+// guardFunc is an AccessGuard blocking what its function does
+type guardFunc func(ip, user string) (bool, time.Duration)
+
+func (f guardFunc) Blocked(ip, user string) (bool, time.Duration) {
+	return f(ip, user)
+}
+
+func TestSessionGuard(t *testing.T) {
+	blocked := map[string]bool{}
+	sessions := NewSessionManager(nil, []byte("0123456789abcdef0123456789abcdef"))
+	sessions.Guard = guardFunc(func(ip, user string) (bool, time.Duration) {
+		if blocked["ip:"+ip] {
+			return true, 90500 * time.Millisecond
+		}
+		return blocked["user:"+user], 0
+	})
+	router := NewRouter()
+	router.Use(sessions.Middleware())
+	router.POST("/login", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		if err := Login(r, r.PostFormValue("user")); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+		}
+	})
+	router.GET("/me", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		w.Write([]byte(CurrentUser(r)))
+	})
+	b := &browser{router: router, cookies: map[string]*http.Cookie{}}
+
+	blocked["user:mallory"] = true
+	if recorder := b.do("POST", "/login", url.Values{"user": {"mallory"}}, nil); recorder.Code != http.StatusForbidden || !strings.Contains(recorder.Body.String(), ErrBlocked.Error()) {
+		t.Fatalf("expected the blocked user's login to be refused, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	if body := b.do("GET", "/me", nil, nil).Body.String(); body != "" {
+		t.Fatalf("expected no one to be signed in, got %q", body)
+	}
+
+	// Blocking a signed-in user refuses their requests
+	b.do("POST", "/login", url.Values{"user": {"ada"}}, nil)
+	if body := b.do("GET", "/me", nil, nil).Body.String(); body != "ada" {
+		t.Fatalf("expected ada to be signed in, got %q", body)
+	}
+	blocked["user:ada"] = true
+	if recorder := b.do("GET", "/me", nil, nil); recorder.Code != http.StatusTooManyRequests || recorder.Header().Get("Retry-After") != "" {
+		t.Fatalf("expected the blocked user to be refused until unblocked, got %d %v", recorder.Code, recorder.Header())
+	}
+
+	// Blocking the IP refuses everyone, for as long as the block lasts
+	blocked["ip:192.0.2.1"] = true
+	other := &browser{router: router, cookies: map[string]*http.Cookie{}}
+	if recorder := other.do("GET", "/me", nil, nil); recorder.Code != http.StatusTooManyRequests || recorder.Header().Get("Retry-After") != "91" {
+		t.Fatalf("expected the blocked IP to be refused for 91 seconds, got %d %v", recorder.Code, recorder.Header())
+	}
+}
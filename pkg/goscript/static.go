@@ -0,0 +1,223 @@
+package goscript
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+// StaticFiles serves the files of a directory.
+type StaticFiles struct {
+	// Dir is the directory the files are served from.
+	Dir string
+
+	// MaxAge is how long browsers may use a file without asking again. Zero
+	// sends no-cache, so browsers revalidate each use with the ETag.
+	MaxAge time.Duration
+
+	// Immutable marks files as never changing, for fingerprinted assets.
+	Immutable bool
+
+	// Fallback is a file, such as "index.html", served for page requests that
+	// match no file, so a single-page app can route them in the browser.
+	Fallback string
+
+	// Prefix is the path the files are served below, which Integrity
+	// strips from asset URLs.
+	Prefix string
+
+	mutex  sync.Mutex
+	hashes map[string]assetHash
+}
+
+// assetHash is the integrity hash of a file as it was when hashed.
+type assetHash struct {
+	size      int64
+	modTime   time.Time
+	integrity string
+}
+
+// Static serves the files under dir below a path prefix, such as
+// router.Static("/assets", "public"). Files get an ETag and Last-Modified,
+// so browsers revalidate cheaply, and paths cannot reach outside dir.
+// Mounted at "/" with a Fallback, it serves a single-page app: other routes
+// still win, as they are more specific.
+func (r *Router) Static(prefix, dir string) *StaticFiles {
+	files := &StaticFiles{Dir: dir, Prefix: prefix}
+	r.GET(joinPath(prefix, "*filepath"), func(w http.ResponseWriter, req *http.Request, params map[string]string) {
+		files.serve(w, req, params["filepath"])
+	})
+	return files
+}
+
+// Integrity returns the Subresource Integrity hash of the file an asset
+// URL, such as "/assets/app.js?v=2", names below Prefix. Hashes are kept
+// until the file changes.
+func (s *StaticFiles) Integrity(src string) (string, error) {
+	if i := strings.IndexAny(src, "?#"); i >= 0 {
+		src = src[:i]
+	}
+	prefix := strings.TrimSuffix(s.Prefix, "/") + "/"
+	if !strings.HasPrefix(src, prefix) {
+		return "", fmt.Errorf("%s is not below %s", src, prefix)
+	}
+	name := strings.TrimPrefix(src, prefix)
+
+	file, info, ok := s.open(name)
+	if !ok {
+		return "", fmt.Errorf("%s is not served", src)
+	}
+	defer file.Close()
+
+	s.mutex.Lock()
+	cached, ok := s.hashes[name]
+	s.mutex.Unlock()
+	if ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.integrity, nil
+	}
+
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	integrity := core.SRIHash(data)
+
+	s.mutex.Lock()
+	if s.hashes == nil {
+		s.hashes = make(map[string]assetHash)
+	}
+	s.hashes[name] = assetHash{size: info.Size(), modTime: info.ModTime(), integrity: integrity}
+	s.mutex.Unlock()
+	return integrity, nil
+}
+
+// ServeHTTP serves the file named by the request path.
+func (s *StaticFiles) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.serve(w, r, r.URL.Path)
+}
+
+// serve writes the file at a slash-separated name, its directory's
+// index.html, or the fallback.
+func (s *StaticFiles) serve(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if file, info, ok := s.open(name); ok {
+		defer file.Close()
+		s.write(w, r, file, info, s.cacheControl())
+		return
+	}
+
+	if s.Fallback != "" && wantsPage(r, name) {
+		if file, info, ok := s.open(s.Fallback); ok {
+			defer file.Close()
+			// The page links to the current assets, so it is always revalidated
+			s.write(w, r, file, info, "no-cache")
+			return
+		}
+	}
+
+	http.NotFound(w, r)
+}
+
+// open opens a regular file inside Dir, or the index.html of a directory.
+func (s *StaticFiles) open(name string) (*os.File, os.FileInfo, bool) {
+	if strings.ContainsAny(name, "\x00\\") {
+		return nil, nil, false
+	}
+
+	// Cleaning a rooted path drops any ".." above the root
+	cleaned := path.Clean("/" + name)
+	for _, segment := range strings.Split(cleaned, "/") {
+		// Dotfiles, such as .env or .git, are never served
+		if strings.HasPrefix(segment, ".") {
+			return nil, nil, false
+		}
+	}
+
+	full, ok := s.resolve(filepath.FromSlash(cleaned))
+	if !ok {
+		return nil, nil, false
+	}
+	info, err := os.Stat(full)
+	if err != nil {
+		return nil, nil, false
+	}
+	if info.IsDir() {
+		if full, ok = s.resolve(filepath.Join(filepath.FromSlash(cleaned), "index.html")); !ok {
+			return nil, nil, false
+		}
+		if info, err = os.Stat(full); err != nil || info.IsDir() {
+			return nil, nil, false
+		}
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil, false
+	}
+
+	file, err := os.Open(full)
+	if err != nil {
+		return nil, nil, false
+	}
+	return file, info, true
+}
+
+// resolve returns the real path of a name below Dir, following symbolic
+// links, and whether it stays inside Dir.
+func (s *StaticFiles) resolve(name string) (string, bool) {
+	root, err := filepath.EvalSymlinks(s.Dir)
+	if err != nil {
+		return "", false
+	}
+	full, err := filepath.EvalSymlinks(filepath.Join(root, name))
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(root, full)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return full, true
+}
+
+// write sends a file with its validators; http.ServeContent answers
+// conditional and range requests.
+func (s *StaticFiles) write(w http.ResponseWriter, r *http.Request, file *os.File, info os.FileInfo, cacheControl string) {
+	header := w.Header()
+	header.Set("Cache-Control", cacheControl)
+	header.Set("ETag", fmt.Sprintf(`W/"%x-%x"`, info.Size(), info.ModTime().UnixNano()))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
+
+// cacheControl returns the Cache-Control header for files.
+func (s *StaticFiles) cacheControl() string {
+	if s.MaxAge <= 0 {
+		return "no-cache"
+	}
+	value := "public, max-age=" + strconv.Itoa(int(s.MaxAge/time.Second))
+	if s.Immutable {
+		value += ", immutable"
+	}
+	return value
+}
+
+// wantsPage reports whether a request is a page navigation rather than a
+// missing asset: it accepts HTML, or its path has no file extension.
+func wantsPage(r *http.Request, name string) bool {
+	if strings.Contains(r.Header.Get("Accept"), "text/html") {
+		return true
+	}
+	return path.Ext(name) == ""
+}
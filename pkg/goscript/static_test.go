@@ -0,0 +1,97 @@
+package goscript
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// staticDir creates a public directory with a few files next to a secret
+func staticDir(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	public := filepath.Join(root, "public")
+	files := map[string]string{
+		"public/app.js":          "console.log(1)",
+		"public/index.html":      "<h1>App</h1>",
+		"public/docs/index.html": "<h1>Docs</h1>",
+		"public/.env":            "SECRET=1",
+		"secret.txt":             "secret",
+	}
+	for name, content := range files {
+		full := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "secret.txt"), filepath.Join(public, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	return public
+}
+
+func TestStaticFiles(t *testing.T) {
+	router := NewRouter()
+	assets := router.Static("/assets", staticDir(t))
+	assets.MaxAge = time.Hour
+	assets.Immutable = true
+
+	recorder := serve(router, "GET", "/assets/app.js")
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "console.log(1)" {
+		t.Fatalf("unexpected response %d %q", recorder.Code, recorder.Body.String())
+	}
+	if cache := recorder.Header().Get("Cache-Control"); cache != "public, max-age=3600, immutable" {
+		t.Fatalf("unexpected Cache-Control %q", cache)
+	}
+	etag := recorder.Header().Get("ETag")
+	if etag == "" || recorder.Header().Get("Last-Modified") == "" {
+		t.Fatalf("expected validators, got %v", recorder.Header())
+	}
+
+	request := httptest.NewRequest("GET", "/assets/app.js", nil)
+	request.Header.Set("If-None-Match", etag)
+	recorder = httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for a matching ETag, got %d", recorder.Code)
+	}
+
+	if body := serve(router, "GET", "/assets/docs/").Body.String(); body != "<h1>Docs</h1>" {
+		t.Fatalf("expected the directory index, got %q", body)
+	}
+
+	for _, path := range []string{"/assets/../secret.txt", "/assets/../../secret.txt", "/assets/link.txt", "/assets/.env", "/assets/missing.js"} {
+		if code := serve(router, "GET", path).Code; code != http.StatusNotFound {
+			t.Fatalf("%s: expected 404, got %d", path, code)
+		}
+	}
+}
+
+func TestStaticSPAFallback(t *testing.T) {
+	router := NewRouter()
+	router.GET("/api/status", echoRoute("status"))
+	router.Static("/", staticDir(t)).Fallback = "index.html"
+
+	request := httptest.NewRequest("GET", "/settings/profile", nil)
+	request.Header.Set("Accept", "text/html")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Body.String() != "<h1>App</h1>" || recorder.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("expected the app page, got %d %q", recorder.Code, recorder.Body.String())
+	}
+
+	if body := serve(router, "GET", "/api/status").Body.String(); body != "status map[]" {
+		t.Fatalf("expected routes to win over the fallback, got %q", body)
+	}
+	if code := serve(router, "GET", "/missing.js").Code; code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing asset, got %d", code)
+	}
+}
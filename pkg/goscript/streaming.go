@@ -0,0 +1,314 @@
+package goscript
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrStreamingUnsupported is returned for a response writer that cannot
+// flush, such as one a ResponseCache buffers.
+var ErrStreamingUnsupported = errors.New("goscript: response writer cannot flush")
+
+// ErrStreamClosed is returned when writing to a closed stream.
+var ErrStreamClosed = errors.New("goscript: stream closed")
+
+// StreamWriter writes a response in pieces the client receives as they are
+// written, instead of when the handler returns. Writers that cannot flush
+// still work, but send when their buffer fills.
+type StreamWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// NewStreamWriter creates a stream writer for a response.
+func NewStreamWriter(w http.ResponseWriter) *StreamWriter {
+	flusher, _ := w.(http.Flusher)
+	return &StreamWriter{w: w, flusher: flusher}
+}
+
+// CanFlush reports whether the response writer can flush.
+func (s *StreamWriter) CanFlush() bool {
+	return s.flusher != nil
+}
+
+// Write writes data and flushes it to the client.
+func (s *StreamWriter) Write(data []byte) (int, error) {
+	n, err := s.w.Write(data)
+	if err != nil {
+		return n, err
+	}
+	s.Flush()
+	return n, nil
+}
+
+// Flush sends what has been written so far.
+func (s *StreamWriter) Flush() {
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+}
+
+// Event is a server-sent event.
+type Event struct {
+	// ID lets a reconnecting client resume; browsers send the last one back
+	// in the Last-Event-ID header.
+	ID string
+
+	// Name is the event type browsers dispatch; "message" if empty.
+	Name string
+
+	// Data is sent as it is when it is a string or []byte, and as JSON
+	// otherwise.
+	Data interface{}
+
+	// Retry tells the browser how long to wait before reconnecting.
+	Retry time.Duration
+}
+
+// EventStream sends server-sent events, which browsers receive with
+// EventSource:
+//
+//	router.GET("/events", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+//		events, err := goscript.NewEventStream(w, r)
+//		if err != nil {
+//			http.Error(w, err.Error(), http.StatusInternalServerError)
+//			return
+//		}
+//		defer events.Close()
+//		events.KeepAlive(15 * time.Second)
+//		for update := range updates {
+//			if err := events.Send(goscript.Event{Name: "update", Data: update}); err != nil {
+//				return
+//			}
+//		}
+//	})
+//
+// Its methods are safe to call from several goroutines.
+type EventStream struct {
+	mu     sync.Mutex
+	stream *StreamWriter
+	r      *http.Request
+	closed bool
+	done   chan struct{}
+}
+
+// NewEventStream starts an event stream as the response to a request. It
+// returns ErrStreamingUnsupported, having written nothing, when the writer
+// cannot flush.
+func NewEventStream(w http.ResponseWriter, r *http.Request) (*EventStream, error) {
+	stream := NewStreamWriter(w)
+	if !stream.CanFlush() {
+		return nil, ErrStreamingUnsupported
+	}
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// Proxies such as nginx would otherwise hold events back
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	stream.Flush()
+
+	events := &EventStream{stream: stream, r: r, done: make(chan struct{})}
+	go func() {
+		select {
+		case <-r.Context().Done():
+			events.Close()
+		case <-events.done:
+		}
+	}()
+	return events, nil
+}
+
+// LastEventID returns the ID of the last event a reconnecting client saw, or
+// "".
+func (s *EventStream) LastEventID() string {
+	return s.r.Header.Get("Last-Event-ID")
+}
+
+// Done is closed when the client goes away or the stream is closed.
+func (s *EventStream) Done() <-chan struct{} {
+	return s.done
+}
+
+// Send sends an event.
+func (s *EventStream) Send(event Event) error {
+	var b strings.Builder
+	if event.ID != "" {
+		b.WriteString("id: " + singleLine(event.ID) + "\n")
+	}
+	if event.Name != "" {
+		b.WriteString("event: " + singleLine(event.Name) + "\n")
+	}
+	if event.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(int64(event.Retry/time.Millisecond), 10) + "\n")
+	}
+
+	var data string
+	switch value := event.Data.(type) {
+	case string:
+		data = value
+	case []byte:
+		data = string(value)
+	default:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		data = string(encoded)
+	}
+	// Each line of the data gets its own field; browsers join them with
+	// newlines
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+
+	return s.write(b.String())
+}
+
+// Comment sends a comment, which clients ignore; it keeps idle connections
+// from timing out.
+func (s *EventStream) Comment(text string) error {
+	return s.write(": " + singleLine(text) + "\n\n")
+}
+
+// KeepAlive sends a comment at an interval until the stream closes.
+func (s *EventStream) KeepAlive(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+				if s.Comment("keep-alive") != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// Close ends the stream. Call it before the handler returns, so KeepAlive
+// stops writing.
+func (s *EventStream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+}
+
+func (s *EventStream) write(message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrStreamClosed
+	}
+	_, err := s.stream.Write([]byte(message))
+	return err
+}
+
+// singleLine drops line breaks, which would end an event field.
+func singleLine(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+// JSONArrayWriter streams a JSON array one element at a time, such as the
+// rows of a large query, so the whole result is never held in memory:
+//
+//	rows := goscript.NewJSONArrayWriter(w)
+//	err := database.QueryEach(r.Context(), "SELECT * FROM posts", func(row map[string]interface{}) error {
+//		return rows.Write(row)
+//	})
+//	if err != nil {
+//		log.Print(err)
+//		return
+//	}
+//	rows.Close()
+//
+// Once the first element is sent the status cannot change, so a stream
+// that fails midway is left without its closing bracket, and clients see
+// invalid JSON rather than a short result.
+type JSONArrayWriter struct {
+	// FlushEvery is how many elements are written between flushes; 100 by
+	// default.
+	FlushEvery int
+
+	stream  *StreamWriter
+	w       http.ResponseWriter
+	count   int
+	started bool
+	closed  bool
+}
+
+// NewJSONArrayWriter creates an array writer for a response.
+func NewJSONArrayWriter(w http.ResponseWriter) *JSONArrayWriter {
+	return &JSONArrayWriter{FlushEvery: 100, stream: NewStreamWriter(w), w: w}
+}
+
+// Write adds an element to the array.
+func (a *JSONArrayWriter) Write(v interface{}) error {
+	if a.closed {
+		return ErrStreamClosed
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	separator := ","
+	if !a.started {
+		a.start()
+		separator = "["
+	}
+	if _, err := a.w.Write(append([]byte(separator), data...)); err != nil {
+		return err
+	}
+
+	a.count++
+	if a.FlushEvery <= 1 || a.count%a.FlushEvery == 0 {
+		a.stream.Flush()
+	}
+	return nil
+}
+
+// Count returns the number of elements written.
+func (a *JSONArrayWriter) Count() int {
+	return a.count
+}
+
+// Close ends the array and flushes it; an array with no elements is sent as
+// [].
+func (a *JSONArrayWriter) Close() error {
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+
+	end := "]"
+	if !a.started {
+		a.start()
+		end = "[]"
+	}
+	_, err := a.stream.Write([]byte(end))
+	return err
+}
+
+func (a *JSONArrayWriter) start() {
+	a.started = true
+	if a.w.Header().Get("Content-Type") == "" {
+		a.w.Header().Set("Content-Type", "application/json")
+	}
+}
@@ -0,0 +1,79 @@
+package goscript
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventStream(t *testing.T) {
+	router := NewRouter()
+	router.GET("/events", func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		events, err := NewEventStream(w, r)
+		if err != nil {
+			t.Errorf("unexpected error %v", err)
+			return
+		}
+		defer events.Close()
+
+		events.Send(Event{ID: "7", Name: "greeting", Data: "hello\nworld", Retry: 2 * time.Second})
+		events.Send(Event{Data: map[string]string{"last": events.LastEventID()}})
+		events.Comment("bye")
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	request, _ := http.NewRequest("GET", server.URL+"/events", nil)
+	request.Header.Set("Last-Event-ID", "6")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected headers %v", response.Header)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	expected := "id: 7\nevent: greeting\nretry: 2000\ndata: hello\ndata: world\n\n" +
+		"data: {\"last\":\"6\"}\n\n" +
+		": bye\n"
+	if got := strings.Join(lines, "\n"); got != expected {
+		t.Fatalf("expected events\n%q\ngot\n%q", expected, got)
+	}
+}
+
+func TestJSONArrayWriter(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	rows := NewJSONArrayWriter(recorder)
+	rows.FlushEvery = 2
+	for i := 0; i < 3; i++ {
+		if err := rows.Write(map[string]int{"id": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows.Close()
+	if recorder.Body.String() != `[{"id":0},{"id":1},{"id":2}]` || recorder.Header().Get("Content-Type") != "application/json" || !recorder.Flushed {
+		t.Fatalf("unexpected array %q %v", recorder.Body.String(), recorder.Header())
+	}
+	if err := rows.Write(1); err != ErrStreamClosed {
+		t.Fatalf("expected writes after Close to fail, got %v", err)
+	}
+
+	recorder = httptest.NewRecorder()
+	NewJSONArrayWriter(recorder).Close()
+	if recorder.Body.String() != "[]" {
+		t.Fatalf("expected an empty array, got %q", recorder.Body.String())
+	}
+
+	if _, err := NewEventStream(&bufferWriter{ResponseWriter: recorder}, httptest.NewRequest("GET", "/", nil)); err != ErrStreamingUnsupported {
+		t.Fatalf("expected a buffered writer to be refused, got %v", err)
+	}
+}
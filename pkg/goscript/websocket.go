@@ -0,0 +1,570 @@
+package goscript
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/reqctx"
+)
+
+// WebSocket message types (RFC 6455).
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+)
+
+// WebSocket close codes (RFC 6455).
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+const (
+	opContinuation = 0x0
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+// webSocketGUID is appended to the client key to compute Sec-WebSocket-Accept
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// ErrWebSocketClosed is returned by reads and writes once the connection is
+// closed, by either side.
+var ErrWebSocketClosed = errors.New("goscript: websocket closed")
+
+// WebSocketHandler serves an upgraded connection; the connection closes when
+// it returns.
+type WebSocketHandler func(ws *WebSocket, params map[string]string)
+
+// Upgrader accepts WebSocket connections.
+type Upgrader struct {
+	// CheckOrigin decides whether to accept a connection; nil allows
+	// same-origin browsers and clients sending no Origin.
+	CheckOrigin func(r *http.Request) bool
+
+	// PingInterval is how often the server pings, keeping idle connections
+	// open through proxies; 30 seconds by default. A connection that
+	// sends nothing, not even a pong, for two intervals is closed.
+	PingInterval time.Duration
+
+	// MaxMessageSize bounds a message from the client; 1MB by default.
+	MaxMessageSize int
+
+	// SendQueue is how many messages Send buffers for a slow client before
+	// dropping it; 64 by default.
+	SendQueue int
+}
+
+// WS serves WebSocket connections at a path, which takes parameters like
+// any route. Middleware added with Use runs before the upgrade, so sessions
+// and logins can guard it.
+func (r *Router) WS(path string, handler WebSocketHandler) *Upgrader {
+	upgrader := &Upgrader{}
+	r.GET(path, upgrader.route(handler))
+	return upgrader
+}
+
+// WS serves WebSocket connections at a path within the group.
+func (g *RouteGroup) WS(path string, handler WebSocketHandler) *Upgrader {
+	upgrader := &Upgrader{}
+	g.GET(path, upgrader.route(handler))
+	return upgrader
+}
+
+func (u *Upgrader) route(handler WebSocketHandler) RouteHandler {
+	return func(w http.ResponseWriter, r *http.Request, params map[string]string) {
+		ws, err := u.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		handler(ws, params)
+	}
+}
+
+// Upgrade performs the server side of the handshake. On failure it has
+// written an error response.
+func (u *Upgrader) Upgrade(w http.ResponseWriter, r *http.Request) (*WebSocket, error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return nil, fmt.Errorf("goscript: websocket method %s not allowed", r.Method)
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+		return nil, errors.New("goscript: not a websocket upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusBadRequest)
+		return nil, errors.New("goscript: unsupported websocket version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("goscript: missing websocket key")
+	}
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return nil, errors.New("goscript: websocket origin not allowed")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("goscript: response does not support hijacking")
+	}
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha1.Sum([]byte(key + webSocketGUID))
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(hash[:]) + "\r\n\r\n"
+	conn.SetDeadline(time.Time{})
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	ws := &WebSocket{
+		ID:             reqctx.NewRequestID(),
+		conn:           conn,
+		reader:         buffered.Reader,
+		request:        r,
+		ctx:            ctx,
+		cancel:         cancel,
+		pingInterval:   u.PingInterval,
+		maxMessageSize: u.MaxMessageSize,
+	}
+	if ws.pingInterval <= 0 {
+		ws.pingInterval = 30 * time.Second
+	}
+	if ws.maxMessageSize <= 0 {
+		ws.maxMessageSize = 1 << 20
+	}
+	queue := u.SendQueue
+	if queue <= 0 {
+		queue = 64
+	}
+	ws.send = make(chan []byte, queue)
+	go ws.writeLoop()
+	return ws, nil
+}
+
+// headerContains reports whether a comma-separated header contains token.
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header[http.CanonicalHeaderKey(name)] {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameOrigin reports whether a request's Origin matches its Host. Requests
+// without an Origin, from clients other than browsers, are allowed.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(parsed.Host, r.Host)
+}
+
+// WebSocket is a server-side WebSocket connection. Reads belong to one
+// goroutine, usually the handler's; writes are safe from any.
+type WebSocket struct {
+	// ID identifies the connection, for example in a WebSocketHub.
+	ID string
+
+	conn           net.Conn
+	reader         *bufio.Reader
+	request        *http.Request
+	ctx            context.Context
+	cancel         context.CancelFunc
+	pingInterval   time.Duration
+	maxMessageSize int
+	send           chan []byte
+	writeMutex     sync.Mutex
+	closeOnce      sync.Once
+}
+
+// Request returns the upgraded request.
+func (ws *WebSocket) Request() *http.Request {
+	return ws.request
+}
+
+// Context returns a context carrying the request's values, such as its
+// session, that is canceled when the connection closes.
+func (ws *WebSocket) Context() context.Context {
+	return ws.ctx
+}
+
+// ReadMessage returns the next text or binary message. Pings are answered,
+// and a close frame is acknowledged before ErrWebSocketClosed is returned.
+// Handlers that only write must still read, so control frames are handled.
+func (ws *WebSocket) ReadMessage() (int, []byte, error) {
+	var messageType int
+	var message []byte
+
+	for {
+		fin, opcode, payload, err := ws.readFrame()
+		if err != nil {
+			closed := ws.ctx.Err() != nil || errors.Is(err, io.EOF)
+			ws.CloseWithReason(CloseProtocolError, "")
+			if closed {
+				return 0, nil, ErrWebSocketClosed
+			}
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			if err := ws.writeFrame(opPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			ws.CloseWithReason(CloseNormal, "")
+			return 0, nil, ErrWebSocketClosed
+		case TextMessage, BinaryMessage:
+			if message != nil {
+				ws.CloseWithReason(CloseProtocolError, "")
+				return 0, nil, errors.New("goscript: expected websocket continuation frame")
+			}
+			messageType = int(opcode)
+			message = payload
+		case opContinuation:
+			if message == nil {
+				ws.CloseWithReason(CloseProtocolError, "")
+				return 0, nil, errors.New("goscript: unexpected websocket continuation frame")
+			}
+			if len(message)+len(payload) > ws.maxMessageSize {
+				ws.CloseWithReason(CloseMessageTooBig, "")
+				return 0, nil, errors.New("goscript: websocket message too large")
+			}
+			message = append(message, payload...)
+		default:
+			ws.CloseWithReason(CloseProtocolError, "")
+			return 0, nil, fmt.Errorf("goscript: unknown websocket opcode %d", opcode)
+		}
+
+		if fin {
+			return messageType, message, nil
+		}
+	}
+}
+
+// ReadJSON reads the next message and decodes it as JSON into v.
+func (ws *WebSocket) ReadJSON(v interface{}) error {
+	_, data, err := ws.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteMessage sends a text or binary message, waiting until it is written.
+func (ws *WebSocket) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("goscript: invalid websocket message type %d", messageType)
+	}
+	return ws.writeFrame(byte(messageType), data)
+}
+
+// WriteJSON sends v encoded as a JSON text message.
+func (ws *WebSocket) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ws.writeFrame(TextMessage, data)
+}
+
+// Send queues a text message without waiting. A client too slow to keep up
+// with its queue is disconnected, so it reconnects and resyncs rather than
+// holding up the sender; Send then returns false.
+func (ws *WebSocket) Send(data []byte) bool {
+	select {
+	case <-ws.ctx.Done():
+		return false
+	case ws.send <- data:
+		return true
+	default:
+		go ws.CloseWithReason(CloseGoingAway, "send queue full")
+		return false
+	}
+}
+
+// Close closes the connection normally.
+func (ws *WebSocket) Close() error {
+	return ws.CloseWithReason(CloseNormal, "")
+}
+
+// CloseWithReason sends a close frame with a code and reason, then closes
+// the connection.
+func (ws *WebSocket) CloseWithReason(code int, reason string) error {
+	var err error
+	ws.closeOnce.Do(func() {
+		payload := make([]byte, 2, 2+len(reason))
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		ws.writeFrame(opClose, append(payload, reason...))
+		ws.cancel()
+		err = ws.conn.Close()
+	})
+	return err
+}
+
+// readFrame reads one frame header and payload.
+func (ws *WebSocket) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	// Any frame, including a pong, shows the client is alive
+	ws.conn.SetReadDeadline(time.Now().Add(2 * ws.pingInterval))
+
+	var header [2]byte
+	if _, err = io.ReadFull(ws.reader, header[:]); err != nil {
+		return
+	}
+
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, errors.New("goscript: websocket reserved bits set")
+	}
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, errors.New("goscript: websocket client frames must be masked")
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var extended [2]byte
+		if _, err = io.ReadFull(ws.reader, extended[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(extended[:]))
+	case 127:
+		var extended [8]byte
+		if _, err = io.ReadFull(ws.reader, extended[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(extended[:])
+	}
+	if length > uint64(ws.maxMessageSize) {
+		return false, 0, nil, errors.New("goscript: websocket message too large")
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(ws.reader, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(ws.reader, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a single unmasked frame.
+func (ws *WebSocket) writeFrame(opcode byte, payload []byte) error {
+	ws.writeMutex.Lock()
+	defer ws.writeMutex.Unlock()
+	if ws.ctx.Err() != nil {
+		return ErrWebSocketClosed
+	}
+
+	header := []byte{0x80 | opcode, 0}
+	switch length := len(payload); {
+	case length < 126:
+		header[1] = byte(length)
+	case length <= 0xFFFF:
+		header[1] = 126
+		header = append(header, byte(length>>8), byte(length))
+	default:
+		header[1] = 127
+		var extended [8]byte
+		binary.BigEndian.PutUint64(extended[:], uint64(length))
+		header = append(header, extended[:]...)
+	}
+
+	ws.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := ws.conn.Write(append(header, payload...))
+	return err
+}
+
+// writeLoop sends queued messages and keepalive pings.
+func (ws *WebSocket) writeLoop() {
+	ticker := time.NewTicker(ws.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ws.ctx.Done():
+			return
+		case data := <-ws.send:
+			if err := ws.writeFrame(TextMessage, data); err != nil {
+				ws.CloseWithReason(CloseGoingAway, "")
+				return
+			}
+		case <-ticker.C:
+			if err := ws.writeFrame(opPing, nil); err != nil {
+				ws.CloseWithReason(CloseGoingAway, "")
+				return
+			}
+		}
+	}
+}
+
+// WebSocketHub groups connections by topic to broadcast to them, such as
+// every client watching a document. Connections leave their topics when
+// they close.
+type WebSocketHub struct {
+	mu      sync.RWMutex
+	topics  map[string]map[*WebSocket]bool
+	sockets map[*WebSocket]map[string]bool
+}
+
+// NewWebSocketHub creates an empty hub.
+func NewWebSocketHub() *WebSocketHub {
+	return &WebSocketHub{
+		topics:  make(map[string]map[*WebSocket]bool),
+		sockets: make(map[*WebSocket]map[string]bool),
+	}
+}
+
+// Join adds a connection to topics.
+func (h *WebSocketHub) Join(ws *WebSocket, topics ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	joined, known := h.sockets[ws]
+	if !known {
+		joined = make(map[string]bool)
+		h.sockets[ws] = joined
+		go func() {
+			<-ws.Context().Done()
+			h.remove(ws)
+		}()
+	}
+	for _, topic := range topics {
+		if h.topics[topic] == nil {
+			h.topics[topic] = make(map[*WebSocket]bool)
+		}
+		h.topics[topic][ws] = true
+		joined[topic] = true
+	}
+}
+
+// Leave removes a connection from topics.
+func (h *WebSocketHub) Leave(ws *WebSocket, topics ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, topic := range topics {
+		delete(h.topics[topic], ws)
+		if len(h.topics[topic]) == 0 {
+			delete(h.topics, topic)
+		}
+		delete(h.sockets[ws], topic)
+	}
+}
+
+func (h *WebSocketHub) remove(ws *WebSocket) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for topic := range h.sockets[ws] {
+		delete(h.topics[topic], ws)
+		if len(h.topics[topic]) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+	delete(h.sockets, ws)
+}
+
+// Broadcast queues a text message for every connection in a topic and
+// returns how many accepted it.
+func (h *WebSocketHub) Broadcast(topic string, data []byte) int {
+	h.mu.RLock()
+	sockets := make([]*WebSocket, 0, len(h.topics[topic]))
+	for ws := range h.topics[topic] {
+		sockets = append(sockets, ws)
+	}
+	h.mu.RUnlock()
+
+	sent := 0
+	for _, ws := range sockets {
+		if ws.Send(data) {
+			sent++
+		}
+	}
+	return sent
+}
+
+// BroadcastJSON broadcasts v encoded as JSON.
+func (h *WebSocketHub) BroadcastJSON(topic string, v interface{}) (int, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+	return h.Broadcast(topic, data), nil
+}
+
+// Count returns the number of connections in a topic.
+func (h *WebSocketHub) Count(topic string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.topics[topic])
+}
+
+// Close disconnects every connection, telling clients the server is going
+// away. Register it with Server.OnShutdown, as the server does not track
+// upgraded connections.
+func (h *WebSocketHub) Close() {
+	h.mu.RLock()
+	sockets := make([]*WebSocket, 0, len(h.sockets))
+	for ws := range h.sockets {
+		sockets = append(sockets, ws)
+	}
+	h.mu.RUnlock()
+
+	for _, ws := range sockets {
+		ws.CloseWithReason(CloseGoingAway, "server shutting down")
+	}
+}
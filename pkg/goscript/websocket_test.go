@@ -0,0 +1,181 @@
+package goscript
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsClient is a minimal WebSocket client speaking masked frames
+type wsClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dialWS(t *testing.T, server *httptest.Server, path string) *wsClient {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	request := "GET " + path + " HTTP/1.1\r\nHost: example.com\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"
+	if _, err := conn.Write([]byte(request)); err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("handshake response: %v", err)
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols || response.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected handshake %d %v", response.StatusCode, response.Header)
+	}
+	return &wsClient{conn: conn, reader: reader}
+}
+
+func (c *wsClient) write(t *testing.T, opcode byte, payload string) {
+	t.Helper()
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask...)
+	for i := 0; i < len(payload); i++ {
+		frame = append(frame, payload[i]^mask[i%4])
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func (c *wsClient) read(t *testing.T) (byte, string) {
+	t.Helper()
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		var extended [2]byte
+		io.ReadFull(c.reader, extended[:])
+		length = int(binary.BigEndian.Uint16(extended[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return header[0] & 0x0F, string(payload)
+}
+
+func TestWebSocketRoute(t *testing.T) {
+	router := NewRouter()
+	router.Use(trace("router"))
+	router.WS("/echo/:room", func(ws *WebSocket, params map[string]string) {
+		for {
+			var message map[string]string
+			if err := ws.ReadJSON(&message); err != nil {
+				return
+			}
+			message["room"] = params["room"]
+			ws.WriteJSON(message)
+		}
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	client := dialWS(t, server, "/echo/lobby")
+	client.write(t, opPing, "hi")
+	if opcode, payload := client.read(t); opcode != opPong || payload != "hi" {
+		t.Fatalf("expected a pong, got %d %q", opcode, payload)
+	}
+	client.write(t, TextMessage, `{"text":"hello"}`)
+	if _, payload := client.read(t); payload != `{"room":"lobby","text":"hello"}` {
+		t.Fatalf("unexpected echo %q", payload)
+	}
+
+	client.write(t, opClose, "\x03\xe8")
+	if opcode, _ := client.read(t); opcode != opClose {
+		t.Fatalf("expected the close to be acknowledged, got %d", opcode)
+	}
+
+	response, err := http.Get(server.URL + "/echo/lobby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusUpgradeRequired {
+		t.Fatalf("expected 426 for a plain request, got %d", response.StatusCode)
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	router := NewRouter()
+	router.WS("/ws", func(ws *WebSocket, params map[string]string) {})
+
+	request := httptest.NewRequest("GET", "http://example.com/ws", nil)
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Upgrade", "websocket")
+	request.Header.Set("Sec-WebSocket-Version", "13")
+	request.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	request.Header.Set("Origin", "https://evil.example.com")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("expected a cross-origin upgrade to be refused, got %d", recorder.Code)
+	}
+}
+
+func TestWebSocketHub(t *testing.T) {
+	hub := NewWebSocketHub()
+	joined := make(chan bool, 2)
+
+	router := NewRouter()
+	router.WS("/topics/:topic", func(ws *WebSocket, params map[string]string) {
+		hub.Join(ws, params["topic"])
+		joined <- true
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	news := dialWS(t, server, "/topics/news")
+	sports := dialWS(t, server, "/topics/sports")
+	<-joined
+	<-joined
+
+	if sent := hub.Broadcast("news", []byte("extra")); sent != 1 {
+		t.Fatalf("expected one news subscriber, got %d", sent)
+	}
+	if _, payload := news.read(t); payload != "extra" {
+		t.Fatalf("unexpected broadcast %q", payload)
+	}
+
+	sports.write(t, opClose, "\x03\xe8")
+	sports.read(t)
+	deadline := time.Now().Add(2 * time.Second)
+	for hub.Count("sports") != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a closed connection to leave its topic")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	hub.Close()
+	if opcode, payload := news.read(t); opcode != opClose || !strings.Contains(payload, "shutting down") {
+		t.Fatalf("expected a going-away close, got %d %q", opcode, payload)
+	}
+}
@@ -0,0 +1,550 @@
+package gouix
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A11yRule names an accessibility check
+type A11yRule string
+
+const (
+	// A11yImageAlt requires images to have alt text
+	A11yImageAlt A11yRule = "image-alt"
+
+	// A11yLabel requires form controls to have a label
+	A11yLabel A11yRule = "label"
+
+	// A11yARIARole requires role attributes to name a WAI-ARIA role
+	A11yARIARole A11yRule = "aria-role"
+
+	// A11yARIAAttr requires aria-* attributes to be defined by WAI-ARIA and
+	// references to point at existing elements
+	A11yARIAAttr A11yRule = "aria-attr"
+
+	// A11yARIAHidden forbids hiding focusable elements from assistive
+	// technology
+	A11yARIAHidden A11yRule = "aria-hidden-focus"
+
+	// A11yContrast requires text to meet the WCAG AA contrast ratio
+	A11yContrast A11yRule = "contrast"
+
+	// A11yHeadingOrder forbids skipping heading levels
+	A11yHeadingOrder A11yRule = "heading-order"
+)
+
+// A11ySeverity is how serious an accessibility issue is
+type A11ySeverity string
+
+const (
+	// A11yError makes content unusable for some users
+	A11yError A11ySeverity = "error"
+
+	// A11yWarning makes content harder to use
+	A11yWarning A11ySeverity = "warning"
+)
+
+// A11yIssue is a problem found by CheckA11y
+type A11yIssue struct {
+	Rule     A11yRule
+	Severity A11ySeverity
+
+	// Element is the start tag of the offending element
+	Element string
+
+	// Message explains the problem
+	Message string
+}
+
+// String describes the issue on one line
+func (i A11yIssue) String() string {
+	return fmt.Sprintf("%s: %s: %s", i.Rule, i.Element, i.Message)
+}
+
+// A11yOptions configures CheckA11y
+type A11yOptions struct {
+	// Colors is a Gocsx theme palette, such as
+	// core.DefaultConfig().Theme.Colors, used to resolve text-* and bg-*
+	// classes in the contrast check
+	Colors map[string]map[string]string
+
+	// Ignore lists rules that are not checked
+	Ignore []A11yRule
+}
+
+// ignores reports whether a rule is skipped
+func (o A11yOptions) ignores(rule A11yRule) bool {
+	for _, ignored := range o.Ignore {
+		if ignored == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckA11y analyzes rendered HTML for common accessibility problems: images
+// without alt text, unlabelled form controls, misused ARIA roles and
+// attributes, insufficient text contrast and skipped heading levels. Issues
+// are returned in document order.
+func CheckA11y(markup string, options A11yOptions) ([]A11yIssue, error) {
+	nodes, err := ParseHTML(markup)
+	if err != nil {
+		return nil, err
+	}
+
+	checker := &a11yChecker{options: options, ids: map[string]bool{}, labelled: map[string]bool{}}
+	checker.collect(nodes)
+	checker.walk(nodes, a11yScope{foreground: defaultForeground, background: defaultBackground})
+	return checker.issues, nil
+}
+
+// CheckComponentA11y renders a component and checks its HTML
+func CheckComponentA11y(component Component, options A11yOptions) ([]A11yIssue, error) {
+	return CheckA11y(component.Render(), options)
+}
+
+// a11yScope is what an element inherits from its ancestors
+type a11yScope struct {
+	foreground rgb
+	background rgb
+
+	// styled is set once an ancestor sets a color, so unstyled markup is not
+	// checked against assumed browser defaults
+	styled bool
+
+	label  bool
+	hidden bool
+}
+
+type a11yChecker struct {
+	options  A11yOptions
+	issues   []A11yIssue
+	ids      map[string]bool
+	labelled map[string]bool
+	heading  int
+}
+
+// collect records element IDs and the IDs labels point at
+func (c *a11yChecker) collect(nodes []*VNode) {
+	for _, node := range nodes {
+		if node.Type != ElementNode {
+			continue
+		}
+		if id, ok := node.Attr("id"); ok && id != "" {
+			c.ids[id] = true
+		}
+		if node.Tag == "label" {
+			if target, ok := node.Attr("for"); ok && target != "" {
+				c.labelled[target] = true
+			}
+		}
+		c.collect(node.Children)
+	}
+}
+
+func (c *a11yChecker) report(rule A11yRule, severity A11ySeverity, node *VNode, format string, args ...interface{}) {
+	if c.options.ignores(rule) {
+		return
+	}
+	c.issues = append(c.issues, A11yIssue{
+		Rule:     rule,
+		Severity: severity,
+		Element:  startTag(node),
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *a11yChecker) walk(nodes []*VNode, scope a11yScope) {
+	for _, node := range nodes {
+		if node.Type != ElementNode || node.Tag == "script" || node.Tag == "style" {
+			continue
+		}
+
+		inner := scope
+		if node.Tag == "label" {
+			inner.label = true
+		}
+		if hidden, _ := node.Attr("aria-hidden"); hidden == "true" {
+			inner.hidden = true
+		}
+		c.applyColors(node, &inner)
+
+		c.checkImage(node)
+		c.checkLabel(node, scope)
+		c.checkARIA(node, scope)
+		c.checkHeading(node)
+		c.checkContrast(node, inner)
+
+		if !rawTextElements[node.Tag] {
+			c.walk(node.Children, inner)
+		}
+	}
+}
+
+func (c *a11yChecker) checkImage(node *VNode) {
+	switch node.Tag {
+	case "img":
+		if _, ok := node.Attr("alt"); ok {
+			return
+		}
+		if role, _ := node.Attr("role"); role == "presentation" || role == "none" {
+			return
+		}
+	case "input":
+		// Image buttons need a name; an empty alt is not decorative here
+		if kind, _ := node.Attr("type"); !strings.EqualFold(kind, "image") {
+			return
+		}
+		if alt, _ := node.Attr("alt"); strings.TrimSpace(alt) != "" {
+			return
+		}
+	default:
+		return
+	}
+
+	if !hasAccessibleName(node) {
+		c.report(A11yImageAlt, A11yError, node, "image has no alt text; use alt=\"\" if it is decorative")
+	}
+}
+
+// unlabelledInputs are input types that need no label
+var unlabelledInputs = map[string]bool{"hidden": true, "submit": true, "reset": true, "button": true, "image": true}
+
+func (c *a11yChecker) checkLabel(node *VNode, scope a11yScope) {
+	switch node.Tag {
+	case "input":
+		kind, _ := node.Attr("type")
+		if unlabelledInputs[strings.ToLower(kind)] {
+			return
+		}
+	case "select", "textarea":
+	default:
+		return
+	}
+
+	if scope.label || hasAccessibleName(node) {
+		return
+	}
+	if id, _ := node.Attr("id"); id != "" && c.labelled[id] {
+		return
+	}
+	c.report(A11yLabel, A11yError, node, "form control has no label; add a <label for> or aria-label")
+}
+
+// hasAccessibleName reports whether ARIA attributes name the element
+func hasAccessibleName(node *VNode) bool {
+	for _, name := range []string{"aria-label", "aria-labelledby", "title"} {
+		if value, _ := node.Attr(name); strings.TrimSpace(value) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *a11yChecker) checkARIA(node *VNode, scope a11yScope) {
+	if role, ok := node.Attr("role"); ok {
+		if !validRole(role) {
+			c.report(A11yARIARole, A11yError, node, "%q is not a WAI-ARIA role", role)
+		}
+	}
+
+	for _, attr := range node.Attrs {
+		if !strings.HasPrefix(attr.Name, "aria-") {
+			continue
+		}
+		if !ariaAttributes[attr.Name] {
+			c.report(A11yARIAAttr, A11yError, node, "%s is not a WAI-ARIA attribute", attr.Name)
+			continue
+		}
+		if ariaReferences[attr.Name] {
+			for _, id := range strings.Fields(attr.Value) {
+				if !c.ids[id] {
+					c.report(A11yARIAAttr, A11yError, node, "%s refers to missing element #%s", attr.Name, id)
+				}
+			}
+		}
+	}
+
+	hidden, _ := node.Attr("aria-hidden")
+	if (scope.hidden || hidden == "true") && focusable(node) {
+		c.report(A11yARIAHidden, A11yError, node, "focusable element is hidden from assistive technology by aria-hidden")
+	}
+}
+
+// validRole reports whether a role attribute names a role; like browsers, the
+// first known token of a fallback list is used
+func validRole(role string) bool {
+	for _, token := range strings.Fields(role) {
+		if ariaRoles[token] {
+			return true
+		}
+	}
+	return false
+}
+
+// focusable reports whether an element takes keyboard focus
+func focusable(node *VNode) bool {
+	if tabindex, ok := node.Attr("tabindex"); ok {
+		index, err := strconv.Atoi(strings.TrimSpace(tabindex))
+		return err != nil || index >= 0
+	}
+	if _, disabled := node.Attr("disabled"); disabled {
+		return false
+	}
+
+	switch node.Tag {
+	case "a", "area":
+		_, ok := node.Attr("href")
+		return ok
+	case "button", "select", "textarea", "iframe":
+		return true
+	case "input":
+		kind, _ := node.Attr("type")
+		return !strings.EqualFold(kind, "hidden")
+	}
+	return false
+}
+
+func (c *a11yChecker) checkHeading(node *VNode) {
+	if len(node.Tag) != 2 || node.Tag[0] != 'h' || node.Tag[1] < '1' || node.Tag[1] > '6' {
+		return
+	}
+
+	level := int(node.Tag[1] - '0')
+	if c.heading != 0 && level > c.heading+1 {
+		c.report(A11yHeadingOrder, A11yWarning, node, "h%d follows h%d, skipping a level", level, c.heading)
+	}
+	c.heading = level
+}
+
+// largeText are elements whose default size counts as large text in WCAG
+var largeText = map[string]bool{"h1": true, "h2": true, "h3": true}
+
+func (c *a11yChecker) checkContrast(node *VNode, scope a11yScope) {
+	if !scope.styled || !hasText(node) {
+		return
+	}
+
+	minimum := 4.5
+	if largeText[node.Tag] {
+		minimum = 3
+	}
+	if ratio := contrastRatio(scope.foreground, scope.background); ratio < minimum {
+		c.report(A11yContrast, A11yError, node, "text contrast %.2f:1 (%s on %s) is below %.1f:1",
+			ratio, scope.foreground, scope.background, minimum)
+	}
+}
+
+// hasText reports whether an element directly contains visible text
+func hasText(node *VNode) bool {
+	for _, child := range node.Children {
+		if child.Type == TextNode && strings.TrimSpace(child.Text) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// applyColors updates the scope with the colors an element sets through its
+// style attribute or Gocsx text-* and bg-* classes
+func (c *a11yChecker) applyColors(node *VNode, scope *a11yScope) {
+	class, _ := node.Attr("class")
+	for _, name := range strings.Fields(class) {
+		if strings.HasPrefix(name, "text-") {
+			if color, ok := c.themeColor(name[len("text-"):]); ok {
+				scope.foreground, scope.styled = color, true
+			}
+		} else if strings.HasPrefix(name, "bg-") {
+			if color, ok := c.themeColor(name[len("bg-"):]); ok {
+				scope.background, scope.styled = color, true
+			}
+		}
+	}
+
+	style, _ := node.Attr("style")
+	for _, declaration := range strings.Split(style, ";") {
+		parts := strings.SplitN(declaration, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		property := strings.ToLower(strings.TrimSpace(parts[0]))
+		color, ok := parseColor(parts[1])
+		if !ok {
+			continue
+		}
+		switch property {
+		case "color":
+			scope.foreground, scope.styled = color, true
+		case "background-color", "background":
+			scope.background, scope.styled = color, true
+		}
+	}
+}
+
+// themeColor resolves a Gocsx color name such as "primary-500"
+func (c *a11yChecker) themeColor(name string) (rgb, bool) {
+	if color, ok := namedColors[name]; ok {
+		return color, true
+	}
+
+	dash := strings.LastIndexByte(name, '-')
+	if dash < 0 {
+		return rgb{}, false
+	}
+	value, ok := c.options.Colors[name[:dash]][name[dash+1:]]
+	if !ok {
+		return rgb{}, false
+	}
+	return parseColor(value)
+}
+
+// rgb is an opaque sRGB color
+type rgb [3]uint8
+
+var (
+	defaultForeground = rgb{0, 0, 0}
+	defaultBackground = rgb{255, 255, 255}
+)
+
+// namedColors are the CSS color keywords most used for text and backgrounds
+var namedColors = map[string]rgb{
+	"black":  {0, 0, 0},
+	"white":  {255, 255, 255},
+	"gray":   {128, 128, 128},
+	"grey":   {128, 128, 128},
+	"silver": {192, 192, 192},
+	"red":    {255, 0, 0},
+	"green":  {0, 128, 0},
+	"blue":   {0, 0, 255},
+	"yellow": {255, 255, 0},
+	"orange": {255, 165, 0},
+	"purple": {128, 0, 128},
+}
+
+// String formats the color as hex
+func (c rgb) String() string {
+	return fmt.Sprintf("#%02x%02x%02x", c[0], c[1], c[2])
+}
+
+// parseColor parses an opaque hex, rgb() or named CSS color
+func parseColor(value string) (rgb, bool) {
+	value = strings.ToLower(strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(value), "!important")))
+
+	if color, ok := namedColors[value]; ok {
+		return color, true
+	}
+
+	if strings.HasPrefix(value, "#") {
+		hex := value[1:]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if len(hex) != 6 {
+			return rgb{}, false
+		}
+		n, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return rgb{}, false
+		}
+		return rgb{uint8(n >> 16), uint8(n >> 8), uint8(n)}, true
+	}
+
+	for _, prefix := range []string{"rgb(", "rgba("} {
+		if !strings.HasPrefix(value, prefix) || !strings.HasSuffix(value, ")") {
+			continue
+		}
+		parts := strings.FieldsFunc(value[len(prefix):len(value)-1], func(r rune) bool {
+			return r == ',' || r == ' ' || r == '/'
+		})
+		if len(parts) < 3 || len(parts) > 4 {
+			return rgb{}, false
+		}
+		if len(parts) == 4 {
+			// Translucent colors depend on what is behind them
+			if alpha, err := strconv.ParseFloat(parts[3], 64); err != nil || alpha < 1 {
+				return rgb{}, false
+			}
+		}
+		var color rgb
+		for i := 0; i < 3; i++ {
+			channel, err := strconv.Atoi(parts[i])
+			if err != nil || channel < 0 || channel > 255 {
+				return rgb{}, false
+			}
+			color[i] = uint8(channel)
+		}
+		return color, true
+	}
+
+	return rgb{}, false
+}
+
+// luminance is the WCAG relative luminance of a color
+func (c rgb) luminance() float64 {
+	var channels [3]float64
+	for i, value := range c {
+		v := float64(value) / 255
+		if v <= 0.03928 {
+			channels[i] = v / 12.92
+		} else {
+			channels[i] = math.Pow((v+0.055)/1.055, 2.4)
+		}
+	}
+	return 0.2126*channels[0] + 0.7152*channels[1] + 0.0722*channels[2]
+}
+
+// contrastRatio is the WCAG contrast ratio of two colors, from 1 to 21
+func contrastRatio(a, b rgb) float64 {
+	la, lb := a.luminance(), b.luminance()
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// startTag returns an element's start tag, shortened for messages
+func startTag(node *VNode) string {
+	markup := node.HTML()
+	if end := strings.IndexByte(markup, '>'); end >= 0 {
+		markup = markup[:end+1]
+	}
+	if len(markup) > 80 {
+		markup = markup[:76] + " ...>"
+	}
+	return markup
+}
+
+// ariaRoles are the WAI-ARIA 1.2 roles
+var ariaRoles = wordSet(`alert alertdialog application article banner blockquote button caption cell
+	checkbox code columnheader combobox complementary contentinfo definition deletion dialog
+	directory document emphasis feed figure form generic grid gridcell group heading img insertion
+	link list listbox listitem log main marquee math menu menubar menuitem menuitemcheckbox
+	menuitemradio meter navigation none note option paragraph presentation progressbar radio
+	radiogroup region row rowgroup rowheader scrollbar search searchbox separator slider spinbutton
+	status strong subscript superscript switch tab table tablist tabpanel term textbox time timer
+	toolbar tooltip tree treegrid treeitem`)
+
+// ariaAttributes are the WAI-ARIA 1.2 states and properties
+var ariaAttributes = wordSet(`aria-activedescendant aria-atomic aria-autocomplete aria-braillelabel
+	aria-brailleroledescription aria-busy aria-checked aria-colcount aria-colindex aria-colindextext
+	aria-colspan aria-controls aria-current aria-describedby aria-description aria-details
+	aria-disabled aria-dropeffect aria-errormessage aria-expanded aria-flowto aria-grabbed
+	aria-haspopup aria-hidden aria-invalid aria-keyshortcuts aria-label aria-labelledby aria-level
+	aria-live aria-modal aria-multiline aria-multiselectable aria-orientation aria-owns
+	aria-placeholder aria-posinset aria-pressed aria-readonly aria-relevant aria-required
+	aria-roledescription aria-rowcount aria-rowindex aria-rowindextext aria-rowspan aria-selected
+	aria-setsize aria-sort aria-valuemax aria-valuemin aria-valuenow aria-valuetext`)
+
+// ariaReferences are the attributes holding element IDs
+var ariaReferences = wordSet(`aria-activedescendant aria-controls aria-describedby aria-details
+	aria-errormessage aria-flowto aria-labelledby aria-owns`)
+
+func wordSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(words) {
+		set[word] = true
+	}
+	return set
+}
@@ -0,0 +1,144 @@
+package gouix
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+// rules lists the rules of issues in order
+func rules(issues []A11yIssue) []string {
+	names := make([]string, len(issues))
+	for i, issue := range issues {
+		names[i] = string(issue.Rule)
+	}
+	return names
+}
+
+func TestCheckA11yFindsIssues(t *testing.T) {
+	markup := `<main>
+		<h1>Shop</h1>
+		<img src="logo.png">
+		<img src="divider.png" alt="">
+		<h3>Deals</h3>
+		<label>Search <input type="search" name="q"></label>
+		<input type="email" id="email">
+		<label for="plan">Plan</label><select id="plan"></select>
+		<textarea></textarea>
+		<input type="hidden" name="token">
+		<div role="buton" aria-lable="Close"></div>
+		<div role="switch button" aria-checked="false" aria-describedby="missing"></div>
+		<div aria-hidden="true"><a href="/cart">Cart</a><span>icon</span></div>
+	</main>`
+
+	issues, err := CheckA11y(markup, A11yOptions{})
+	if err != nil {
+		t.Fatalf("CheckA11y returned error: %v", err)
+	}
+
+	expected := []string{"image-alt", "heading-order", "label", "label", "aria-role", "aria-attr", "aria-attr", "aria-hidden-focus"}
+	if got := rules(issues); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected %v, got %v", expected, issues)
+	}
+	if issues[0].Element != `<img src="logo.png"/>` {
+		t.Errorf("expected the offending start tag, got %q", issues[0].Element)
+	}
+	if issues[1].Severity != A11yWarning || issues[1].Message != "h3 follows h1, skipping a level" {
+		t.Errorf("unexpected heading issue %+v", issues[1])
+	}
+	if issues[3].Element != "<textarea>" {
+		t.Errorf("expected the textarea to need a label, got %+v", issues[3])
+	}
+
+	issues, err = CheckA11y(markup, A11yOptions{Ignore: []A11yRule{A11yLabel, A11yARIAAttr}})
+	if err != nil {
+		t.Fatalf("CheckA11y returned error: %v", err)
+	}
+	if len(issues) != 4 {
+		t.Errorf("expected ignored rules to be skipped, got %v", issues)
+	}
+}
+
+func TestCheckA11yContrast(t *testing.T) {
+	colors := map[string]map[string]string{
+		"primary": {"100": "#e0f2fe", "900": "#0c4a6e"},
+		"neutral": {"400": "#9ca3af"},
+	}
+
+	markup := `<div class="bg-primary-900 text-primary-100">
+		<p>Readable</p>
+		<p class="text-neutral-400">Too faint</p>
+		<h2 style="color: #9ca3af">Large enough</h2>
+	</div>
+	<p style="color: rgb(170, 170, 170); background: white">Grey on white</p>
+	<p style="color: rgba(0, 0, 0, 0.4)">Translucent</p>
+	<p>Unstyled</p>`
+
+	issues, err := CheckA11y(markup, A11yOptions{Colors: colors})
+	if err != nil {
+		t.Fatalf("CheckA11y returned error: %v", err)
+	}
+	if len(issues) != 2 {
+		t.Fatalf("expected two contrast issues, got %v", issues)
+	}
+	if issues[0].Element != `<p class="text-neutral-400">` || !strings.Contains(issues[0].Message, "(#9ca3af on #0c4a6e) is below 4.5:1") {
+		t.Errorf("unexpected theme color issue %+v", issues[0])
+	}
+	if !strings.Contains(issues[1].Message, "2.32:1 (#aaaaaa on #ffffff)") {
+		t.Errorf("unexpected inline style issue %+v", issues[1])
+	}
+
+	// Without the palette the theme classes cannot be resolved
+	issues, err = CheckA11y(markup, A11yOptions{})
+	if err != nil {
+		t.Fatalf("CheckA11y returned error: %v", err)
+	}
+	if len(issues) != 2 || issues[0].Element != `<h2 style="color: #9ca3af">` {
+		t.Errorf("expected grey text on the default background to fail, got %v", issues)
+	}
+}
+
+// avatar renders an image whose alt text is state
+type avatar struct {
+	*HyperComponent
+}
+
+func (a *avatar) Render() string {
+	props := Props{"src": "me.png"}
+	if alt := a.GetState("alt").(string); alt != "" {
+		props["alt"] = alt
+	}
+	return CreateElement("img", props)
+}
+
+func TestAuditToJetpack(t *testing.T) {
+	jp := core.NewJetpack()
+	profile := &avatar{NewHyperComponent("avatar", nil, map[string]interface{}{"alt": ""})}
+
+	issues, err := AuditToJetpack(jp, profile, A11yOptions{})
+	if err != nil || len(issues) != 1 {
+		t.Fatalf("expected one issue, got %v, %v", issues, err)
+	}
+	reported := jp.GetAccessibilityIssues()
+	if len(reported) != 1 || reported[0].Component != "avatar" || reported[0].Rule != "image-alt" || reported[0].Source != "gouix" {
+		t.Fatalf("unexpected Jetpack issues %+v", reported)
+	}
+	if panel := jp.GetPanelData()["accessibility"].([]core.AccessibilityIssue); len(panel) != 1 {
+		t.Errorf("expected the issue in the panel data, got %+v", panel)
+	}
+	if value, err := jp.GetMetricLatest("gouix_a11y_issues"); err != nil || value != 1 {
+		t.Errorf("expected one open issue, got %v, %v", value, err)
+	}
+
+	profile.SetState("alt", "Profile photo")
+	if _, err := AuditToJetpack(jp, profile, A11yOptions{}); err != nil {
+		t.Fatalf("AuditToJetpack returned error: %v", err)
+	}
+	if reported := jp.GetAccessibilityIssues(); len(reported) != 0 {
+		t.Errorf("expected the fix to clear the issue, got %+v", reported)
+	}
+	if value, _ := jp.GetMetricLatest("gouix_a11y_issues"); value != 0 {
+		t.Errorf("expected no open issues, got %v", value)
+	}
+}
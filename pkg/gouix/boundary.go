@@ -0,0 +1,166 @@
+package gouix
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+)
+
+// RenderError describes a panic while rendering a component
+type RenderError struct {
+	// Component whose render failed; for a boundary, the direct child
+	Component ComponentID
+
+	// Value passed to panic
+	Value interface{}
+
+	// Stack trace of the panic
+	Stack string
+}
+
+// Error implements the error interface
+func (e *RenderError) Error() string {
+	return fmt.Sprintf("gouix: rendering %q panicked: %v", e.Component, e.Value)
+}
+
+// Unwrap returns the panic value if it was an error
+func (e *RenderError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// renderErrorHandlers are called for every render failure
+var renderErrorHandlers = struct {
+	handlers map[int]func(*RenderError)
+	nextID   int
+	mutex    sync.RWMutex
+}{handlers: make(map[int]func(*RenderError))}
+
+// OnRenderError registers a handler called whenever a component's render
+// panics, for example to log or report it. It returns a function that removes
+// the handler.
+func OnRenderError(handler func(err *RenderError)) func() {
+	renderErrorHandlers.mutex.Lock()
+	defer renderErrorHandlers.mutex.Unlock()
+
+	id := renderErrorHandlers.nextID
+	renderErrorHandlers.nextID++
+	renderErrorHandlers.handlers[id] = handler
+
+	return func() {
+		renderErrorHandlers.mutex.Lock()
+		defer renderErrorHandlers.mutex.Unlock()
+
+		delete(renderErrorHandlers.handlers, id)
+	}
+}
+
+// reportRenderError calls the render error handlers in registration order
+func reportRenderError(err *RenderError) {
+	renderErrorHandlers.mutex.RLock()
+	ids := make([]int, 0, len(renderErrorHandlers.handlers))
+	for id := range renderErrorHandlers.handlers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	handlers := make([]func(*RenderError), len(ids))
+	for i, id := range ids {
+		handlers[i] = renderErrorHandlers.handlers[id]
+	}
+	renderErrorHandlers.mutex.RUnlock()
+
+	for _, handler := range handlers {
+		handler(err)
+	}
+}
+
+// renderSafely renders a child, turning a panic into a RenderError
+func renderSafely(child interface{}) (content string, renderErr *RenderError) {
+	defer func() {
+		if value := recover(); value != nil {
+			renderErr = &RenderError{Value: value, Stack: string(debug.Stack())}
+			if component, ok := child.(Component); ok {
+				renderErr.Component = component.GetID()
+			}
+		}
+	}()
+
+	return renderChildren([]interface{}{child}), nil
+}
+
+// DefaultErrorFallback renders a generic message in place of a failed subtree
+func DefaultErrorFallback(err *RenderError) string {
+	return CreateElement("div", Props{"class": "gouix-error", "role": "alert"}, "Something went wrong.")
+}
+
+// ErrorBoundary renders its children and, if one of them panics, renders a
+// fallback in their place so the rest of the page still renders. Each new
+// failure is reported to the OnRenderError handlers; the children are tried
+// again on the next render.
+type ErrorBoundary struct {
+	BaseComponent
+	fallback func(err *RenderError) string
+	failure  *RenderError
+	onError  func(err *RenderError)
+	errMutex sync.RWMutex
+}
+
+// NewErrorBoundary creates an error boundary; a nil fallback renders
+// DefaultErrorFallback
+func NewErrorBoundary(id ComponentID, fallback func(err *RenderError) string, children ...interface{}) *ErrorBoundary {
+	if fallback == nil {
+		fallback = DefaultErrorFallback
+	}
+
+	boundary := &ErrorBoundary{fallback: fallback}
+	boundary.init(id, nil, children...)
+	return boundary
+}
+
+// OnError sets a function called when a child of this boundary fails
+func (b *ErrorBoundary) OnError(handler func(err *RenderError)) {
+	b.errMutex.Lock()
+	defer b.errMutex.Unlock()
+
+	b.onError = handler
+}
+
+// Err returns the failure shown by the last render, or nil
+func (b *ErrorBoundary) Err() *RenderError {
+	b.errMutex.RLock()
+	defer b.errMutex.RUnlock()
+
+	return b.failure
+}
+
+// Render implements the Component interface
+func (b *ErrorBoundary) Render() string {
+	var content string
+	for _, child := range b.GetChildren() {
+		rendered, renderErr := renderSafely(child)
+		if renderErr != nil {
+			b.errMutex.Lock()
+			previous := b.failure
+			b.failure = renderErr
+			onError := b.onError
+			b.errMutex.Unlock()
+
+			// A subtree that keeps failing the same way is reported once
+			if previous == nil || previous.Component != renderErr.Component || fmt.Sprint(previous.Value) != fmt.Sprint(renderErr.Value) {
+				reportRenderError(renderErr)
+				if onError != nil {
+					onError(renderErr)
+				}
+			}
+			return b.fallback(renderErr)
+		}
+		content += rendered
+	}
+
+	b.errMutex.Lock()
+	b.failure = nil
+	b.errMutex.Unlock()
+
+	return content
+}
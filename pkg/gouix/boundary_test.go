@@ -0,0 +1,91 @@
+package gouix
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+// flakyComponent panics while broken is set
+type flakyComponent struct {
+	BaseComponent
+	broken bool
+}
+
+func (f *flakyComponent) Render() string {
+	if f.broken {
+		var items []string
+		return items[3] // index out of range
+	}
+	return "<p>ok</p>"
+}
+
+func newFlaky(id ComponentID, broken bool) *flakyComponent {
+	flaky := &flakyComponent{broken: broken}
+	flaky.init(id, nil)
+	return flaky
+}
+
+func TestErrorBoundaryRendersFallback(t *testing.T) {
+	jp := core.NewJetpack()
+	stop := ReportToJetpack(jp)
+	defer stop()
+
+	flaky := newFlaky("widget", true)
+	boundary := NewErrorBoundary("boundary", func(err *RenderError) string {
+		return "<p>" + string(err.Component) + " failed</p>"
+	}, flaky)
+	page := NewProvider("page", CreateContext(nil), nil,
+		newFlaky("header", false),
+		boundary,
+	)
+
+	if html := page.Render(); html != "<p>ok</p><p>widget failed</p>" {
+		t.Errorf("expected the rest of the page to render, got %s", html)
+	}
+	if boundary.Err() == nil || !strings.Contains(boundary.Err().Stack, "flakyComponent") {
+		t.Errorf("expected the failure with its stack, got %+v", boundary.Err())
+	}
+
+	// Repeated failures are reported once
+	page.Render()
+	reports := jp.GetErrors()
+	if len(reports) != 1 || reports[0].Source != "gouix" || reports[0].Component != "widget" {
+		t.Fatalf("expected one Jetpack report, got %+v", reports)
+	}
+	if latest, _ := jp.GetMetricLatest("gouix_errors"); latest != 1 {
+		t.Errorf("expected the error to be counted, got %v", latest)
+	}
+
+	// The children are tried again on the next render
+	flaky.broken = false
+	if html := page.Render(); html != "<p>ok</p><p>ok</p>" || boundary.Err() != nil {
+		t.Errorf("expected the boundary to recover, got %s", html)
+	}
+}
+
+func TestRootRecoversFromRenderPanics(t *testing.T) {
+	var reported []*RenderError
+	stop := OnRenderError(func(err *RenderError) { reported = append(reported, err) })
+	defer stop()
+
+	flaky := newFlaky("widget", false)
+	root := NewRoot("app", flaky)
+	root.Render()
+
+	flaky.broken = true
+	patchSet, err := root.Refresh()
+
+	var renderErr *RenderError
+	if !errors.As(err, &renderErr) || len(patchSet.Patches) != 0 {
+		t.Fatalf("expected a RenderError without patches, got %v", err)
+	}
+	if html := root.Render(); !strings.Contains(html, "<p>ok</p>") {
+		t.Errorf("expected the previous content to be kept, got %s", html)
+	}
+	if len(reported) != 2 {
+		t.Errorf("expected each failed render to be reported, got %d", len(reported))
+	}
+}
@@ -34,22 +34,22 @@ type BaseCanvasElement struct {
 
 // NewCanvasElement creates a new canvas element
 func NewCanvasElement(id ComponentID, shape string, props Props) *BaseCanvasElement {
-        base := NewBaseComponent(id, props)
+        element := &BaseCanvasElement{
+                shape:       shape,
+                fillStyle:   "#000000",
+                strokeStyle: "#000000",
+                lineWidth:   1.0,
+        }
+        element.init(id, props)
         
         // Set default position and size
-        base.SetPosition(0, 0, 0)
-        base.SetSize(100, 100)
+        element.SetPosition(0, 0, 0)
+        element.SetSize(100, 100)
         
         // Enable drag by default for canvas elements
-        base.EnableDrag(nil)
-        
-        return &BaseCanvasElement{
-                BaseComponent: *base,
-                shape:         shape,
-                fillStyle:     "#000000",
-                strokeStyle:   "#000000",
-                lineWidth:     1.0,
-        }
+        element.EnableDrag(nil)
+        
+        return element
 }
 
 // Contains checks if a point is inside the element
@@ -153,14 +153,14 @@ func NewCanvas(id ComponentID, width, height int, props Props) *Canvas {
         props["width"] = width
         props["height"] = height
         
-        base := NewBaseComponent(id, props)
-        
-        return &Canvas{
-                BaseComponent: *base,
-                elements:      make([]CanvasElement, 0),
-                width:         width,
-                height:        height,
+        canvas := &Canvas{
+                elements: make([]CanvasElement, 0),
+                width:    width,
+                height:   height,
         }
+        canvas.init(id, props)
+        
+        return canvas
 }
 
 // GetSize returns the size of the canvas
@@ -0,0 +1,249 @@
+package gouix
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+)
+
+// DOMAdapter is the page a Client renders its roots into. WebAssembly builds
+// use the browser's, from NewBrowserDOM; a MemoryDOM renders them anywhere
+// else, such as in tests.
+type DOMAdapter interface {
+	// Version returns the data-gouix-version of a root's container, or "" if
+	// it has none or is not on the page
+	Version(root string) string
+
+	// Apply applies a patch set to its root's container and records the
+	// version of the new content
+	Apply(set PatchSet, version string) error
+}
+
+// Client runs components where it is compiled, rendering their roots into a
+// DOMAdapter. Built for WebAssembly, the same components that render the page
+// on the server handle its events in the browser, without a round trip. A
+// root rendered with RenderHydratable is kept as it is when the client
+// renders the same content.
+type Client struct {
+	// OnError is called with the errors of events and patches; nil ignores
+	// them
+	OnError func(error)
+
+	dom         DOMAdapter
+	hub         *LiveHub
+	roots       []*Root
+	unsubscribe []func()
+	done        chan struct{}
+	closeOnce   sync.Once
+	mutex       sync.Mutex
+}
+
+// NewClient creates a client rendering into dom
+func NewClient(dom DOMAdapter) *Client {
+	return &Client{dom: dom, hub: NewLiveHub(), done: make(chan struct{})}
+}
+
+// Mount runs a component in the container of the root with the given ID,
+// hydrating the server's render of it
+func (c *Client) Mount(id string, component Component) *Root {
+	root := c.hub.Mount(id, component)
+	unsubscribe := root.Hydrate(c.dom.Version(root.ID), func(set PatchSet) {
+		if err := c.dom.Apply(set, root.Version()); err != nil {
+			c.report(err)
+		}
+	})
+
+	c.mutex.Lock()
+	c.roots = append(c.roots, root)
+	c.unsubscribe = append(c.unsubscribe, unsubscribe)
+	c.mutex.Unlock()
+
+	return root
+}
+
+// Register makes a component reachable by events, refreshing the given roots
+// after each, as LiveHub.Register
+func (c *Client) Register(component Component, roots ...*Root) {
+	c.hub.Register(component, roots...)
+}
+
+// Roots returns the mounted roots
+func (c *Client) Roots() []*Root {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return append([]*Root(nil), c.roots...)
+}
+
+// Owns reports whether the client runs the component an event is addressed
+// to; events for any other go to the server
+func (c *Client) Owns(id ComponentID) bool {
+	_, ok := c.hub.resolve(id)
+	return ok
+}
+
+// Dispatch delivers an event to its component and patches the roots
+// displaying it
+func (c *Client) Dispatch(event Event) error {
+	return c.hub.Dispatch(event)
+}
+
+// dispatch dispatches an event from the page, reporting its error
+func (c *Client) dispatch(event Event) {
+	if err := c.Dispatch(event); err != nil {
+		c.report(err)
+	}
+}
+
+// report passes an error to OnError
+func (c *Client) report(err error) {
+	if c.OnError != nil {
+		c.OnError(err)
+	}
+}
+
+// Close unmounts the roots and stops the client
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		c.mutex.Lock()
+		roots, unsubscribe := c.roots, c.unsubscribe
+		c.roots, c.unsubscribe = nil, nil
+		c.mutex.Unlock()
+
+		for _, stop := range unsubscribe {
+			stop()
+		}
+		for _, root := range roots {
+			root.Close()
+		}
+		close(c.done)
+	})
+}
+
+// memoryRoot is a root container of a MemoryDOM
+type memoryRoot struct {
+	nodes   []*VNode
+	version string
+}
+
+// MemoryDOM is a DOMAdapter keeping the roots' content as virtual DOM,
+// patched as the browser runtime patches the page. It runs a Client outside
+// the browser, such as to check that it hydrates the server's HTML.
+type MemoryDOM struct {
+	roots map[string]*memoryRoot
+	mutex sync.Mutex
+}
+
+// NewMemoryDOM creates an empty page
+func NewMemoryDOM() *MemoryDOM {
+	return &MemoryDOM{roots: make(map[string]*memoryRoot)}
+}
+
+// Load adds the root containers in HTML, such as the output of
+// RenderHydratable, to the page
+func (d *MemoryDOM) Load(markup string) error {
+	nodes, err := ParseHTML(markup)
+	if err != nil {
+		return err
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var load func(nodes []*VNode)
+	load = func(nodes []*VNode) {
+		for _, node := range nodes {
+			if id, ok := node.Attr("data-gouix-root"); ok && node.Type == ElementNode {
+				version, _ := node.Attr("data-gouix-version")
+				d.roots[id] = &memoryRoot{nodes: cloneNodes(node.Children), version: version}
+				continue
+			}
+			load(node.Children)
+		}
+	}
+	load(nodes)
+	return nil
+}
+
+// Version returns the version of a root's content
+func (d *MemoryDOM) Version(root string) string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if container, ok := d.roots[root]; ok {
+		return container.version
+	}
+	return ""
+}
+
+// Apply patches a root's content. Like the browser runtime, it refuses roots
+// that are not on the page.
+func (d *MemoryDOM) Apply(set PatchSet, version string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	container, ok := d.roots[set.Root]
+	if !ok {
+		return fmt.Errorf("root %q is not on the page", set.Root)
+	}
+	nodes, err := ApplyPatches(container.nodes, set.Patches)
+	if err != nil {
+		return err
+	}
+	container.nodes = nodes
+	container.version = version
+	return nil
+}
+
+// HTML returns the content of a root's container
+func (d *MemoryDOM) HTML(root string) string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	container, ok := d.roots[root]
+	if !ok {
+		return ""
+	}
+	var b strings.Builder
+	for _, node := range container.nodes {
+		b.WriteString(node.HTML())
+	}
+	return b.String()
+}
+
+// WasmScriptTag returns the scripts that start a client built for
+// WebAssembly: execURL serves the wasm_exec.js of the Go release it was built
+// with, and moduleURL the module. Include it after LiveHub.ScriptTag, whose
+// runtime the client patches the page with; events reach the server until
+// the module has started.
+func WasmScriptTag(execURL, moduleURL string) string {
+	encoded, _ := json.Marshal(moduleURL)
+	return `<script src="` + html.EscapeString(execURL) + `"></script>` +
+		"<script>" + WasmRuntime + "\n_gouix.wasm(" + string(encoded) + ");</script>"
+}
+
+// WasmRuntime is the client-side script that loads a client built for
+// WebAssembly. _gouix.wasm(url) instantiates the module with the Go class of
+// wasm_exec.js and runs it; Client.Run then takes over the events of its
+// components and fires gouix:client on the document.
+const WasmRuntime = `(function() {
+  var g = window._gouix = window._gouix || {};
+
+  g.wasm = function(url) {
+    if (typeof Go === 'undefined') {
+      if (window.console) console.error('gouix: load wasm_exec.js before _gouix.wasm');
+      return;
+    }
+    var go = new Go(), fetched = fetch(url);
+    var loading = WebAssembly.instantiateStreaming ?
+      WebAssembly.instantiateStreaming(fetched, go.importObject) :
+      fetched.then(function(response) { return response.arrayBuffer(); }).then(function(bytes) {
+        return WebAssembly.instantiate(bytes, go.importObject);
+      });
+    loading.then(function(result) { go.run(result.instance); }, function(err) {
+      if (window.console) console.error('gouix: ' + err);
+    });
+  };
+})();`
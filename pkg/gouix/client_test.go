@@ -0,0 +1,67 @@
+package gouix
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClientHydratesServerRender(t *testing.T) {
+	// The server renders the page
+	page := NewRoot("counter", newHydratedCounter(1)).RenderHydratable()
+
+	// and the client runs the same component on it
+	dom := NewMemoryDOM()
+	if err := dom.Load("<main>" + page + "</main>"); err != nil {
+		t.Fatal(err)
+	}
+	rendered := dom.HTML("counter")
+	if rendered == "" {
+		t.Fatalf("expected the root loaded from %s", page)
+	}
+
+	counter := newHydratedCounter(1)
+	counter.On("increment", func(event Event) interface{} {
+		counter.SetState("count", counter.GetState("count").(int)+1)
+		return nil
+	})
+	var errs []error
+	client := NewClient(dom)
+	client.OnError = func(err error) { errs = append(errs, err) }
+	root := client.Mount("counter", counter)
+
+	if dom.HTML("counter") != rendered || dom.Version("counter") != root.Version() {
+		t.Fatalf("expected the server's render kept")
+	}
+	if !client.Owns("counter") || client.Owns("elsewhere") {
+		t.Fatalf("expected the client to own only its components")
+	}
+
+	if err := client.Dispatch(Event{Type: "increment", Target: "counter"}); err != nil {
+		t.Fatal(err)
+	}
+	if html := dom.HTML("counter"); !strings.Contains(html, `<p id="counter-count">2</p>`) {
+		t.Fatalf("expected the event to patch the page, got %s", html)
+	}
+	if dom.Version("counter") != root.Version() || len(errs) != 0 {
+		t.Fatalf("expected the version patched too, got errors %v", errs)
+	}
+
+	// A root that is not on the page is reported
+	client.Mount("missing", newHydratedCounter(0))
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), `"missing" is not on the page`) {
+		t.Fatalf("expected the missing root reported, got %v", errs)
+	}
+
+	client.Close()
+	counter.SetState("count", 5)
+	if strings.Contains(dom.HTML("counter"), ">5<") {
+		t.Fatalf("expected no patches after Close")
+	}
+}
+
+func TestWasmScriptTag(t *testing.T) {
+	tag := WasmScriptTag("/static/wasm_exec.js", "/static/app.wasm")
+	if !strings.HasPrefix(tag, `<script src="/static/wasm_exec.js"></script><script>`) || !strings.HasSuffix(tag, `_gouix.wasm("/static/app.wasm");</script>`) {
+		t.Fatalf("unexpected scripts %s", tag)
+	}
+}
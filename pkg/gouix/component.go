@@ -3,6 +3,7 @@ package gouix
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -95,11 +96,23 @@ type BaseComponent struct {
 	size        *Size
 	dragConfig  *DragConfig
 	touchConfig *TouchConfig
+	listeners   map[int]func()
+	nextID      int
+	mounted     bool
+	hooks       map[string][]func()
 	mutex       sync.RWMutex
 }
 
 // NewBaseComponent creates a new BaseComponent
 func NewBaseComponent(id ComponentID, props Props, children ...interface{}) *BaseComponent {
+	b := &BaseComponent{}
+	b.init(id, props, children...)
+	return b
+}
+
+// init initializes a BaseComponent in place, so components embedding it do not
+// copy its mutex
+func (b *BaseComponent) init(id ComponentID, props Props, children ...interface{}) {
 	if props == nil {
 		props = Props{}
 	}
@@ -119,15 +132,13 @@ func NewBaseComponent(id ComponentID, props Props, children ...interface{}) *Bas
 		Gestures: []string{"tap", "doubletap", "longpress", "swipe"},
 	}
 	
-	return &BaseComponent{
-		id:          id,
-		props:       props,
-		children:    children,
-		state:       make(map[string]interface{}),
-		events:      make(map[string][]EventHandler),
-		dragConfig:  dragConfig,
-		touchConfig: touchConfig,
-	}
+	b.id = id
+	b.props = props
+	b.children = children
+	b.state = make(map[string]interface{})
+	b.events = make(map[string][]EventHandler)
+	b.dragConfig = dragConfig
+	b.touchConfig = touchConfig
 }
 
 // GetID returns the component ID
@@ -137,6 +148,8 @@ func (b *BaseComponent) GetID() ComponentID {
 
 // GetProps returns component props
 func (b *BaseComponent) GetProps() Props {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
 	return b.props
 }
 
@@ -148,14 +161,48 @@ func (b *BaseComponent) GetChildren() []interface{} {
 // SetState updates component state
 func (b *BaseComponent) SetState(key string, value interface{}) {
 	b.mutex.Lock()
-	defer b.mutex.Unlock()
-	
 	oldValue := b.state[key]
 	b.state[key] = value
+	b.mutex.Unlock()
 	
 	// Trigger re-render if value changed
 	if !reflect.DeepEqual(oldValue, value) {
-		// In a real implementation, this would trigger a re-render
+		b.notifyStateChange()
+	}
+}
+
+// OnStateChange registers a listener called after the component state changes,
+// such as a Root re-rendering the component. It returns an unsubscribe function.
+func (b *BaseComponent) OnStateChange(listener func()) func() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	
+	if b.listeners == nil {
+		b.listeners = make(map[int]func())
+	}
+	id := b.nextID
+	b.nextID++
+	b.listeners[id] = listener
+	
+	return func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		
+		delete(b.listeners, id)
+	}
+}
+
+// notifyStateChange calls the state change listeners
+func (b *BaseComponent) notifyStateChange() {
+	b.mutex.RLock()
+	listeners := make([]func(), 0, len(b.listeners))
+	for _, listener := range b.listeners {
+		listeners = append(listeners, listener)
+	}
+	b.mutex.RUnlock()
+	
+	for _, listener := range listeners {
+		listener()
 	}
 }
 
@@ -174,15 +221,20 @@ func (b *BaseComponent) On(eventType string, handler EventHandler) {
 	b.events[eventType] = append(b.events[eventType], handler)
 }
 
-// HandleEvent handles an event
+// HandleEvent handles an event. The "dragstart" and "dragend" events sent by
+// the drag runtime also run the DragConfig handlers while drag is enabled.
 func (b *BaseComponent) HandleEvent(event Event) interface{} {
 	b.mutex.RLock()
-	handlers, exists := b.events[event.Type]
-	b.mutex.RUnlock()
-	
-	if !exists {
-		return nil
+	handlers := append([]EventHandler(nil), b.events[event.Type]...)
+	if b.dragConfig != nil && b.dragConfig.Enabled {
+		switch event.Type {
+		case "dragstart":
+			handlers = appendHandler(handlers, b.dragConfig.OnDragStart)
+		case "dragend":
+			handlers = appendHandler(handlers, b.dragConfig.OnDragEnd)
+		}
 	}
+	b.mutex.RUnlock()
 	
 	var result interface{}
 	for _, handler := range handlers {
@@ -192,6 +244,14 @@ func (b *BaseComponent) HandleEvent(event Event) interface{} {
 	return result
 }
 
+// appendHandler appends handler if it is set
+func appendHandler(handlers []EventHandler, handler EventHandler) []EventHandler {
+	if handler == nil {
+		return handlers
+	}
+	return append(handlers, handler)
+}
+
 // EnableDrag enables drag functionality
 func (b *BaseComponent) EnableDrag(config *DragConfig) {
 	if config == nil {
@@ -264,22 +324,118 @@ func (b *BaseComponent) Render() string {
 	return ""
 }
 
-// Mount implements the Component interface
+// OnMount registers a hook run when the component is mounted, after its
+// first render, such as starting a ticker or a subscription
+func (b *BaseComponent) OnMount(hook func()) {
+	b.addHook("mount", hook)
+}
+
+// OnUpdate registers a hook run after a re-render changed the component's output
+func (b *BaseComponent) OnUpdate(hook func()) {
+	b.addHook("update", hook)
+}
+
+// OnUnmount registers a hook run when the component is unmounted, to release
+// what OnMount started. Hooks run in reverse order of registration.
+func (b *BaseComponent) OnUnmount(hook func()) {
+	b.addHook("unmount", hook)
+}
+
+// addHook registers a lifecycle hook
+func (b *BaseComponent) addHook(phase string, hook func()) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	
+	if b.hooks == nil {
+		b.hooks = make(map[string][]func())
+	}
+	b.hooks[phase] = append(b.hooks[phase], hook)
+}
+
+// runHooks runs the hooks of a lifecycle phase
+func (b *BaseComponent) runHooks(phase string) {
+	b.mutex.RLock()
+	hooks := make([]func(), len(b.hooks[phase]))
+	copy(hooks, b.hooks[phase])
+	b.mutex.RUnlock()
+	
+	if phase == "unmount" {
+		for i := len(hooks) - 1; i >= 0; i-- {
+			hooks[i]()
+		}
+		return
+	}
+	for _, hook := range hooks {
+		hook()
+	}
+}
+
+// IsMounted reports whether the component is mounted
+func (b *BaseComponent) IsMounted() bool {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.mounted
+}
+
+// Mount implements the Component interface. It mounts the component children
+// and then runs the OnMount hooks; mounting twice has no effect.
 func (b *BaseComponent) Mount() {
-	// Default implementation does nothing
+	b.mutex.Lock()
+	if b.mounted {
+		b.mutex.Unlock()
+		return
+	}
+	b.mounted = true
+	children := b.children
+	b.mutex.Unlock()
+	
+	for _, child := range children {
+		if component, ok := child.(Component); ok {
+			component.Mount()
+		}
+	}
+	b.runHooks("mount")
 }
 
-// Unmount implements the Component interface
+// Unmount implements the Component interface. It runs the OnUnmount hooks and
+// then unmounts the component children.
 func (b *BaseComponent) Unmount() {
-	// Default implementation does nothing
+	b.mutex.Lock()
+	if !b.mounted {
+		b.mutex.Unlock()
+		return
+	}
+	b.mounted = false
+	children := b.children
+	b.mutex.Unlock()
+	
+	b.runHooks("unmount")
+	for i := len(children) - 1; i >= 0; i-- {
+		if component, ok := children[i].(Component); ok {
+			component.Unmount()
+		}
+	}
 }
 
-// Update implements the Component interface
+// Update implements the Component interface. It replaces the props and
+// re-renders roots showing the component.
 func (b *BaseComponent) Update(nextProps Props) bool {
+	if nextProps != nil {
+		b.mutex.Lock()
+		b.props = nextProps
+		b.mutex.Unlock()
+	}
+	b.notifyStateChange()
+	
 	// Default implementation always updates
 	return true
 }
 
+// updated runs the OnUpdate hooks after a re-render changed the output
+func (b *BaseComponent) updated() {
+	b.runHooks("update")
+}
+
 // FunctionalComponent represents a function that renders a component
 type FunctionalComponent func(props Props, children ...interface{}) string
 
@@ -341,6 +497,39 @@ func renderChild(child interface{}) string {
 	}
 }
 
+// renderChildren renders children in order, flattening slices
+func renderChildren(children []interface{}) string {
+	var result strings.Builder
+	for _, child := range children {
+		if child != nil && reflect.TypeOf(child).Kind() == reflect.Slice {
+			items := reflect.ValueOf(child)
+			for i := 0; i < items.Len(); i++ {
+				result.WriteString(renderChild(items.Index(i).Interface()))
+			}
+			continue
+		}
+		result.WriteString(renderChild(child))
+	}
+	return result.String()
+}
+
+// voidElements are the HTML5 elements without a closing tag
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"param": true, "source": true, "track": true, "wbr": true,
+}
+
+// sortedKeys returns the keys of a props or style map in sorted order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // CreateElement creates an HTML element
 func CreateElement(component interface{}, props Props, children ...interface{}) string {
 	var result strings.Builder
@@ -350,9 +539,10 @@ func CreateElement(component interface{}, props Props, children ...interface{})
 		result.WriteString("<")
 		result.WriteString(c)
 		
-		// Handle props/attributes
+		// Handle props/attributes in a stable order so renders can be diffed
 		if props != nil {
-			for key, value := range props {
+			for _, key := range sortedKeys(props) {
+				value := props[key]
 				// Special handling for event handlers
 				if strings.HasPrefix(key, "on") && strings.HasPrefix(fmt.Sprintf("%T", value), "func(") {
 					// In a real implementation, this would register event handlers
@@ -364,8 +554,8 @@ func CreateElement(component interface{}, props Props, children ...interface{})
 				if key == "style" && reflect.TypeOf(value).Kind() == reflect.Map {
 					styleMap := value.(map[string]interface{})
 					var styleStr strings.Builder
-					for sk, sv := range styleMap {
-						styleStr.WriteString(fmt.Sprintf("%s:%v;", sk, sv))
+					for _, sk := range sortedKeys(styleMap) {
+						styleStr.WriteString(fmt.Sprintf("%s:%v;", sk, styleMap[sk]))
 					}
 					result.WriteString(fmt.Sprintf(" style=\"%s\"", styleStr.String()))
 					continue
@@ -400,14 +590,7 @@ func CreateElement(component interface{}, props Props, children ...interface{})
 		
 		// Handle self-closing tags
 		if len(children) == 0 {
-			// Self-closing tags in HTML5
-			selfClosingTags := map[string]bool{
-				"area": true, "base": true, "br": true, "col": true, "embed": true,
-				"hr": true, "img": true, "input": true, "link": true, "meta": true,
-				"param": true, "source": true, "track": true, "wbr": true,
-			}
-			
-			if _, isSelfClosing := selfClosingTags[c]; isSelfClosing {
+			if voidElements[c] {
 				result.WriteString("/>")
 			} else {
 				result.WriteString("></")
@@ -0,0 +1,163 @@
+package gouix
+
+import (
+	"bytes"
+	"reflect"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// Context carries a value, such as a theme, the signed-in user or an API
+// client, down a component tree without passing it through props
+type Context struct {
+	defaultValue interface{}
+}
+
+// CreateContext creates a context whose value is defaultValue wherever no
+// Provider supplies another
+func CreateContext(defaultValue interface{}) *Context {
+	return &Context{defaultValue: defaultValue}
+}
+
+// contextFrame is a value provided while a Provider renders
+type contextFrame struct {
+	context *Context
+	value   interface{}
+}
+
+// contextStacks holds the providers being rendered by each goroutine.
+// Components render each other through plain Render calls, so a provider's
+// scope is the time its children take to render on the calling goroutine;
+// keying by goroutine keeps concurrent renders of different roots apart.
+var contextStacks = struct {
+	stacks map[uint64][]contextFrame
+	mutex  sync.Mutex
+}{stacks: make(map[uint64][]contextFrame)}
+
+// goroutineID returns the ID of the calling goroutine
+func goroutineID() uint64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	fields := bytes.Fields(bytes.TrimPrefix(buf[:n], []byte("goroutine ")))
+	if len(fields) == 0 {
+		return 0
+	}
+	id, _ := strconv.ParseUint(string(fields[0]), 10, 64)
+	return id
+}
+
+// pushContext enters a provider's scope and returns the function leaving it
+func pushContext(context *Context, value interface{}) func() {
+	id := goroutineID()
+
+	contextStacks.mutex.Lock()
+	contextStacks.stacks[id] = append(contextStacks.stacks[id], contextFrame{context: context, value: value})
+	contextStacks.mutex.Unlock()
+
+	return func() {
+		contextStacks.mutex.Lock()
+		defer contextStacks.mutex.Unlock()
+
+		stack := contextStacks.stacks[id]
+		if len(stack) <= 1 {
+			delete(contextStacks.stacks, id)
+			return
+		}
+		contextStacks.stacks[id] = stack[:len(stack)-1]
+	}
+}
+
+// UseContext returns the value of the nearest Provider of context rendering
+// the calling component, or the context's default value. Call it from Render.
+func UseContext(context *Context) interface{} {
+	id := goroutineID()
+
+	contextStacks.mutex.Lock()
+	defer contextStacks.mutex.Unlock()
+
+	stack := contextStacks.stacks[id]
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i].context == context {
+			return stack[i].value
+		}
+	}
+	return context.defaultValue
+}
+
+// Provider supplies a context value to the components it renders. Only
+// children that are components see the value: strings have already been
+// rendered by the time they are passed in.
+type Provider struct {
+	BaseComponent
+	context    *Context
+	value      interface{}
+	valueMutex sync.RWMutex
+}
+
+// NewProvider creates a provider of value for context
+func NewProvider(id ComponentID, context *Context, value interface{}, children ...interface{}) *Provider {
+	provider := &Provider{context: context, value: value}
+	provider.init(id, nil, children...)
+	return provider
+}
+
+// Value returns the provided value
+func (p *Provider) Value() interface{} {
+	p.valueMutex.RLock()
+	defer p.valueMutex.RUnlock()
+
+	return p.value
+}
+
+// SetValue changes the provided value and re-renders roots showing the provider
+func (p *Provider) SetValue(value interface{}) {
+	p.valueMutex.Lock()
+	changed := !reflect.DeepEqual(p.value, value)
+	p.value = value
+	p.valueMutex.Unlock()
+
+	if changed {
+		p.notifyStateChange()
+	}
+}
+
+// FindComponent finds a component among the provider's children, so browser
+// events reach components rendered inside it
+func (p *Provider) FindComponent(id ComponentID) Component {
+	var children []interface{}
+	for _, child := range p.GetChildren() {
+		if child != nil && reflect.TypeOf(child).Kind() == reflect.Slice {
+			items := reflect.ValueOf(child)
+			for i := 0; i < items.Len(); i++ {
+				children = append(children, items.Index(i).Interface())
+			}
+			continue
+		}
+		children = append(children, child)
+	}
+
+	for _, child := range children {
+		component, ok := child.(Component)
+		if !ok {
+			continue
+		}
+		if component.GetID() == id {
+			return component
+		}
+		if finder, ok := component.(ComponentFinder); ok {
+			if found := finder.FindComponent(id); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+// Render implements the Component interface
+func (p *Provider) Render() string {
+	leave := pushContext(p.context, p.Value())
+	defer leave()
+
+	return renderChildren(p.GetChildren())
+}
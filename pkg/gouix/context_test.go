@@ -0,0 +1,97 @@
+package gouix
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// themedButton renders the current theme
+type themedButton struct {
+	BaseComponent
+	theme *Context
+}
+
+func (b *themedButton) Render() string {
+	return fmt.Sprintf("<button class=\"%v\">OK</button>", UseContext(b.theme))
+}
+
+func newThemedButton(id ComponentID, theme *Context) *themedButton {
+	button := &themedButton{theme: theme}
+	button.init(id, nil)
+	return button
+}
+
+func TestContextProviders(t *testing.T) {
+	theme := CreateContext("light")
+
+	if html := newThemedButton("a", theme).Render(); html != `<button class="light">OK</button>` {
+		t.Errorf("expected the default value outside a provider, got %s", html)
+	}
+
+	inner := NewProvider("inner", theme, "blue", newThemedButton("b", theme))
+	outer := NewProvider("outer", theme, "dark",
+		newThemedButton("c", theme),
+		[]Component{inner},
+		newThemedButton("d", theme),
+	)
+
+	expected := `<button class="dark">OK</button><button class="blue">OK</button><button class="dark">OK</button>`
+	if html := outer.Render(); html != expected {
+		t.Errorf("expected %s, got %s", expected, html)
+	}
+
+	if outer.FindComponent("b") == nil || outer.FindComponent("d") == nil || outer.FindComponent("x") != nil {
+		t.Errorf("expected FindComponent to search nested providers")
+	}
+
+	// The scope ends with the provider's render
+	if value := UseContext(theme); value != "light" {
+		t.Errorf("expected the default value after rendering, got %v", value)
+	}
+}
+
+func TestContextIsolatedBetweenGoroutines(t *testing.T) {
+	theme := CreateContext("light")
+
+	var wg sync.WaitGroup
+	errs := make(chan string, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			value := fmt.Sprintf("theme-%d", i)
+			provider := NewProvider("p", theme, value, newThemedButton("b", theme))
+			for j := 0; j < 50; j++ {
+				if html := provider.Render(); html != `<button class="`+value+`">OK</button>` {
+					errs <- html
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for html := range errs {
+		t.Errorf("render saw another goroutine's value: %s", html)
+	}
+}
+
+func TestProviderSetValuePatchesRoot(t *testing.T) {
+	theme := CreateContext("light")
+	provider := NewProvider("app", theme, "light", newThemedButton("b", theme))
+
+	root := NewRoot("app", provider)
+	root.Render()
+
+	var patches []PatchSet
+	root.OnPatch(func(patchSet PatchSet) { patches = append(patches, patchSet) })
+
+	provider.SetValue("dark")
+	provider.SetValue("dark")
+
+	if len(patches) != 1 || patches[0].Patches[0].Op != PatchSetAttr || patches[0].Patches[0].Value != "dark" {
+		t.Errorf("expected one class patch, got %+v", patches)
+	}
+}
@@ -0,0 +1,704 @@
+package gouix
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DevtoolsNode is a component in the devtools tree
+type DevtoolsNode struct {
+	ID    ComponentID            `json:"id,omitempty"`
+	Type  string                 `json:"type"`
+	Props map[string]interface{} `json:"props,omitempty"`
+	State map[string]interface{} `json:"state,omitempty"`
+
+	// Renders counts the renders of the root showing the component since
+	// devtools were enabled, each of which rendered the component
+	Renders int `json:"renders"`
+
+	// Changes counts the state changes the component reported
+	Changes int `json:"changes"`
+
+	// History is the component's recorded state transitions, for components
+	// that can record them
+	History *DevtoolsHistory `json:"history,omitempty"`
+
+	Children []*DevtoolsNode `json:"children,omitempty"`
+}
+
+// DevtoolsHistory is a component's recorded state transitions and the one
+// its state is at
+type DevtoolsHistory struct {
+	Position    int               `json:"position"`
+	Transitions []StateTransition `json:"transitions"`
+}
+
+// DevtoolsRoot is a mounted root and its component tree
+type DevtoolsRoot struct {
+	ID        string        `json:"id"`
+	Version   string        `json:"version"`
+	Renders   int           `json:"renders"`
+	Component *DevtoolsNode `json:"component"`
+}
+
+// DevtoolsEvent is an entry of the devtools event log: a browser event
+// dispatched to a component, a render that changed a root, a render that
+// panicked, an action on a watched store or a jump through a component's
+// history
+type DevtoolsEvent struct {
+	Time   time.Time              `json:"time"`
+	Kind   string                 `json:"kind"`
+	Target string                 `json:"target"`
+	Type   string                 `json:"type,omitempty"`
+	Data   map[string]interface{} `json:"data,omitempty"`
+	Error  string                 `json:"error,omitempty"`
+}
+
+// DevtoolsSnapshot is what the overlay shows: the roots' component trees,
+// the watched stores' state and the event log, oldest first
+type DevtoolsSnapshot struct {
+	Roots  []DevtoolsRoot   `json:"roots"`
+	Stores map[string]State `json:"stores,omitempty"`
+	Events []DevtoolsEvent  `json:"events"`
+}
+
+// Devtools inspects the roots of a LiveHub for development: the live
+// component tree with each component's props and state, how often each
+// re-rendered, and a log of the events that made them. Enable it with
+// hub.EnableDevtools; the hub's script then includes an overlay showing it,
+// separate from the Jetpack panel.
+//
+// Components that can record their state transitions, such as
+// HyperComponents, record them once devtools see them. The overlay steps
+// through a component's history and replays it, so a bug can be reproduced
+// from the states that led to it.
+type Devtools struct {
+	// Interval batches the updates sent to overlays while components change
+	Interval time.Duration
+
+	hub          *LiveHub
+	limit        int
+	renders      map[ComponentID]int
+	changes      map[ComponentID]int
+	rootRenders  map[string]int
+	watching     map[ComponentID]bool
+	travels      map[ComponentID]*TimeTravel
+	events       []DevtoolsEvent
+	stores       map[string]*Store
+	listeners    map[int]func(DevtoolsSnapshot)
+	nextListener int
+	pending      bool
+	mutex        sync.Mutex
+}
+
+// EnableDevtools turns on dev mode: the hub starts recording its roots'
+// renders and events, keeping the last limit events (100 for zero), and
+// ScriptTag adds the devtools overlay to the page. Enabling it again
+// returns the same devtools.
+func (h *LiveHub) EnableDevtools(limit int) *Devtools {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	h.mutex.Lock()
+	if h.devtools == nil {
+		h.devtools = &Devtools{
+			Interval:    100 * time.Millisecond,
+			hub:         h,
+			renders:     make(map[ComponentID]int),
+			changes:     make(map[ComponentID]int),
+			rootRenders: make(map[string]int),
+			watching:    make(map[ComponentID]bool),
+			travels:     make(map[ComponentID]*TimeTravel),
+			stores:      make(map[string]*Store),
+			listeners:   make(map[int]func(DevtoolsSnapshot)),
+		}
+		OnRenderError(h.devtools.renderFailed)
+	}
+	devtools := h.devtools
+	roots := make([]*Root, 0, len(h.roots))
+	for _, root := range h.roots {
+		roots = append(roots, root)
+	}
+	h.mutex.Unlock()
+
+	devtools.mutex.Lock()
+	devtools.limit = limit
+	if len(devtools.events) > limit {
+		devtools.events = devtools.events[len(devtools.events)-limit:]
+	}
+	devtools.mutex.Unlock()
+
+	for _, root := range roots {
+		root.observeRenders(devtools.rendered)
+	}
+	return devtools
+}
+
+// Devtools returns the hub's devtools, or nil outside dev mode
+func (h *LiveHub) Devtools() *Devtools {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	return h.devtools
+}
+
+// WatchStore shows a store's state under name and logs its actions. It
+// returns a function that stops watching it.
+func (d *Devtools) WatchStore(name string, store *Store) func() {
+	d.mutex.Lock()
+	d.stores[name] = store
+	d.mutex.Unlock()
+
+	stop := store.OnEvent(func(event StoreEvent) {
+		data := map[string]interface{}{"changes": event.Changes}
+		if event.Payload != nil {
+			data["payload"] = event.Payload
+		}
+		d.record(DevtoolsEvent{Kind: "store", Target: name, Type: event.Action, Data: inspectValues(data)})
+	})
+	return func() {
+		stop()
+		d.mutex.Lock()
+		if d.stores[name] == store {
+			delete(d.stores, name)
+		}
+		d.mutex.Unlock()
+		d.changed()
+	}
+}
+
+// Snapshot returns the roots' component trees, the watched stores and the
+// event log
+func (d *Devtools) Snapshot() DevtoolsSnapshot {
+	d.hub.mutex.RLock()
+	roots := make([]*Root, 0, len(d.hub.roots))
+	for _, root := range d.hub.roots {
+		roots = append(roots, root)
+	}
+	d.hub.mutex.RUnlock()
+	sort.Slice(roots, func(i, j int) bool { return roots[i].ID < roots[j].ID })
+
+	d.mutex.Lock()
+	stores := make(map[string]*Store, len(d.stores))
+	for name, store := range d.stores {
+		stores[name] = store
+	}
+	d.mutex.Unlock()
+
+	snapshot := DevtoolsSnapshot{Roots: make([]DevtoolsRoot, 0, len(roots))}
+	for _, root := range roots {
+		tree := d.inspect(root.component, make(map[Component]bool))
+		d.mutex.Lock()
+		renders := d.rootRenders[root.ID]
+		d.mutex.Unlock()
+		snapshot.Roots = append(snapshot.Roots, DevtoolsRoot{ID: root.ID, Version: root.Version(), Renders: renders, Component: tree})
+	}
+	if len(stores) > 0 {
+		snapshot.Stores = make(map[string]State, len(stores))
+		for name, store := range stores {
+			snapshot.Stores[name] = State(inspectValues(store.State()))
+		}
+	}
+
+	d.mutex.Lock()
+	snapshot.Events = append([]DevtoolsEvent{}, d.events...)
+	d.mutex.Unlock()
+	return snapshot
+}
+
+// OnChange calls listener with a new snapshot after components render or
+// events are logged, at most once per Interval. It returns a function that
+// removes the listener.
+func (d *Devtools) OnChange(listener func(DevtoolsSnapshot)) func() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	id := d.nextListener
+	d.nextListener++
+	d.listeners[id] = listener
+
+	return func() {
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+
+		delete(d.listeners, id)
+	}
+}
+
+// inspect builds the devtools node of a component and its children,
+// watching it for state changes
+func (d *Devtools) inspect(component Component, seen map[Component]bool) *DevtoolsNode {
+	node := &DevtoolsNode{ID: component.GetID(), Type: componentType(component)}
+	if isComparable(component) {
+		if seen[component] {
+			return node
+		}
+		seen[component] = true
+	}
+	node.Props = inspectValues(component.GetProps())
+	if snapshotter, ok := component.(StateSnapshotter); ok {
+		node.State = inspectValues(snapshotter.StateSnapshot())
+	}
+	d.watch(component)
+
+	d.mutex.Lock()
+	node.Renders = d.renders[node.ID]
+	node.Changes = d.changes[node.ID]
+	travel := d.travels[node.ID]
+	d.mutex.Unlock()
+	if travel != nil {
+		node.History = inspectHistory(travel)
+	}
+
+	for _, child := range childComponents(component.GetChildren()) {
+		node.Children = append(node.Children, d.inspect(child, seen))
+	}
+	return node
+}
+
+// watch counts the state changes of a component reporting them, and
+// records its state transitions if it can
+func (d *Devtools) watch(component Component) {
+	notifier, ok := component.(StateNotifier)
+	id := component.GetID()
+	if !ok || id == "" {
+		return
+	}
+
+	d.mutex.Lock()
+	watched := d.watching[id]
+	d.watching[id] = true
+	limit := d.limit
+	d.mutex.Unlock()
+	if watched {
+		return
+	}
+
+	if traveler, ok := component.(TimeTraveler); ok {
+		travel := traveler.EnableTimeTravel(limit)
+		d.mutex.Lock()
+		d.travels[id] = travel
+		d.mutex.Unlock()
+	}
+
+	notifier.OnStateChange(func() {
+		d.mutex.Lock()
+		d.changes[id]++
+		d.mutex.Unlock()
+	})
+}
+
+// TimeTravel returns the recorded history of a component devtools have
+// seen, or nil if it does not record one
+func (d *Devtools) TimeTravel(id ComponentID) *TimeTravel {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.travels[id]
+}
+
+// Travel restores a component's state to a position in its history, as the
+// overlay does, and logs it
+func (d *Devtools) Travel(id ComponentID, position int) error {
+	travel := d.TimeTravel(id)
+	if travel == nil {
+		return fmt.Errorf("gouix: %q has no recorded history", id)
+	}
+	if err := travel.Jump(position); err != nil {
+		return err
+	}
+	d.record(DevtoolsEvent{Kind: "travel", Target: string(id), Data: map[string]interface{}{"position": position}})
+	return nil
+}
+
+// Replay replays a component's history from the start, one transition each
+// interval, and logs it. It blocks until the replay ends.
+func (d *Devtools) Replay(id ComponentID, interval time.Duration) error {
+	travel := d.TimeTravel(id)
+	if travel == nil {
+		return fmt.Errorf("gouix: %q has no recorded history", id)
+	}
+	d.record(DevtoolsEvent{Kind: "travel", Target: string(id), Type: "replay", Data: map[string]interface{}{"transitions": len(travel.Transitions())}})
+	return travel.Replay(interval)
+}
+
+// inspectHistory returns a component's history with its states as JSON
+// would show them
+func inspectHistory(travel *TimeTravel) *DevtoolsHistory {
+	history := &DevtoolsHistory{Position: travel.Position(), Transitions: travel.Transitions()}
+	for i, transition := range history.Transitions {
+		transition.Before = State(inspectValues(transition.Before))
+		transition.After = State(inspectValues(transition.After))
+		if transition.Payload != nil {
+			transition.Payload = inspectValues(map[string]interface{}{"payload": transition.Payload})["payload"]
+		}
+		history.Transitions[i] = transition
+	}
+	return history
+}
+
+// rendered counts a render of a root and of every component it showed
+func (d *Devtools) rendered(root *Root, patchSet PatchSet) {
+	ids := make(map[ComponentID]bool)
+	d.collect(root.component, ids, make(map[Component]bool))
+
+	d.mutex.Lock()
+	d.rootRenders[root.ID]++
+	for id := range ids {
+		d.renders[id]++
+	}
+	d.mutex.Unlock()
+
+	if len(patchSet.Patches) > 0 {
+		d.record(DevtoolsEvent{Kind: "render", Target: root.ID, Data: map[string]interface{}{"patches": len(patchSet.Patches)}})
+		return
+	}
+	d.changed()
+}
+
+// renderFailed logs a render that panicked
+func (d *Devtools) renderFailed(err *RenderError) {
+	d.record(DevtoolsEvent{Kind: "error", Target: string(err.Component), Error: fmt.Sprint(err.Value)})
+}
+
+// dispatched logs an event dispatched to a component, before the renders
+// it causes
+func (d *Devtools) dispatched(event Event) {
+	d.record(DevtoolsEvent{Kind: "event", Target: string(event.Target), Type: event.Type, Data: inspectValues(event.Data)})
+}
+
+// dispatchFailed logs an event that could not be delivered; render panics
+// are logged by renderFailed
+func (d *Devtools) dispatchFailed(event Event, err error) {
+	var renderErr *RenderError
+	if errors.As(err, &renderErr) {
+		return
+	}
+	d.record(DevtoolsEvent{Kind: "error", Target: string(event.Target), Type: event.Type, Error: err.Error()})
+}
+
+// record appends an entry to the event log
+func (d *Devtools) record(entry DevtoolsEvent) {
+	entry.Time = time.Now()
+
+	d.mutex.Lock()
+	d.events = append(d.events, entry)
+	if len(d.events) > d.limit {
+		d.events = d.events[len(d.events)-d.limit:]
+	}
+	d.mutex.Unlock()
+
+	d.changed()
+}
+
+// changed schedules sending a snapshot to the listeners, unless one is
+// already due
+func (d *Devtools) changed() {
+	d.mutex.Lock()
+	if d.pending || len(d.listeners) == 0 {
+		d.mutex.Unlock()
+		return
+	}
+	d.pending = true
+	d.mutex.Unlock()
+
+	time.AfterFunc(d.Interval, d.flush)
+}
+
+// flush sends a snapshot to the listeners, in registration order
+func (d *Devtools) flush() {
+	d.mutex.Lock()
+	d.pending = false
+	ids := make([]int, 0, len(d.listeners))
+	for id := range d.listeners {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	listeners := make([]func(DevtoolsSnapshot), len(ids))
+	for i, id := range ids {
+		listeners[i] = d.listeners[id]
+	}
+	d.mutex.Unlock()
+
+	if len(listeners) == 0 {
+		return
+	}
+	snapshot := d.Snapshot()
+	for _, listener := range listeners {
+		listener(snapshot)
+	}
+}
+
+// childComponents returns the components among children, flattening slices
+func childComponents(children []interface{}) []Component {
+	var components []Component
+	for _, child := range children {
+		if component, ok := child.(Component); ok {
+			components = append(components, component)
+			continue
+		}
+		if child != nil && reflect.TypeOf(child).Kind() == reflect.Slice {
+			items := reflect.ValueOf(child)
+			for i := 0; i < items.Len(); i++ {
+				if component, ok := items.Index(i).Interface().(Component); ok {
+					components = append(components, component)
+				}
+			}
+		}
+	}
+	return components
+}
+
+// collect adds the IDs of a component and its descendants to ids, watching
+// those new to the tree for state changes
+func (d *Devtools) collect(component Component, ids map[ComponentID]bool, seen map[Component]bool) {
+	if isComparable(component) {
+		if seen[component] {
+			return
+		}
+		seen[component] = true
+	}
+	if id := component.GetID(); id != "" {
+		ids[id] = true
+	}
+	d.watch(component)
+	for _, child := range childComponents(component.GetChildren()) {
+		d.collect(child, ids, seen)
+	}
+}
+
+// isComparable reports whether a component can be a map key, as pointers
+// can and func components cannot
+func isComparable(component Component) bool {
+	return reflect.TypeOf(component).Comparable()
+}
+
+// componentType names the Go type of a component, without the pointer
+func componentType(component Component) string {
+	t := reflect.TypeOf(component)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.String()
+}
+
+// inspectValues returns the values of a map as JSON would show them. Values
+// that cannot be encoded, such as event handler funcs, are shown by type.
+func inspectValues(values map[string]interface{}) map[string]interface{} {
+	if len(values) == 0 {
+		return nil
+	}
+	inspected := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		if data, err := json.Marshal(value); err == nil {
+			inspected[key] = json.RawMessage(data)
+		} else {
+			inspected[key] = fmt.Sprintf("(%T)", value)
+		}
+	}
+	return inspected
+}
+
+// DevtoolsRuntime is the client-side script of the devtools overlay.
+// _gouix.devtools(endpoint) connects to a LiveHub in dev mode and shows a
+// toggle in the page's corner; the overlay lists the component tree of
+// every root with each component's props, state and render counts, and the
+// event log. Selecting a component outlines its element when it is marked
+// with data-gouix-component, and shows its recorded state transitions: a
+// click on one, or the step buttons, moves the component's state there, and
+// replay plays them again from the start. Ctrl+Shift+D toggles the overlay
+// too.
+const DevtoolsRuntime = `(function() {
+  var g = window._gouix = window._gouix || {};
+  var socket = null, url = null, delay = 500, snapshot = null, selected = null, tab = 'components', panel = null, body = null, outline = null;
+
+  function el(tag, cls, text) {
+    var e = document.createElement(tag);
+    if (cls) e.className = cls;
+    if (text !== undefined) e.textContent = text;
+    return e;
+  }
+
+  function json(value) {
+    return JSON.stringify(value, null, 2);
+  }
+
+  function highlight(id) {
+    if (outline) outline.style.outline = outline.getAttribute('data-gouix-outline') || '';
+    outline = id ? document.querySelector('[data-gouix-component="' + (window.CSS ? CSS.escape(id) : id) + '"]') : null;
+    if (outline) {
+      outline.setAttribute('data-gouix-outline', outline.style.outline);
+      outline.style.outline = '2px solid #7c3aed';
+    }
+  }
+
+  function tree(node, depth, list) {
+    var row = el('div', 'gdt-node' + (selected === node.id && node.id ? ' gdt-selected' : ''));
+    row.style.paddingLeft = (depth * 12 + 6) + 'px';
+    row.appendChild(el('span', 'gdt-type', node.type));
+    if (node.id) row.appendChild(el('span', 'gdt-id', '#' + node.id));
+    row.appendChild(el('span', 'gdt-count', node.renders + ' renders'));
+    row.onclick = function() { selected = node.id; highlight(node.id); draw(); };
+    list.appendChild(row);
+    if (selected && selected === node.id) {
+      ['props', 'state'].forEach(function(key) {
+        if (!node[key]) return;
+        var pre = el('pre', 'gdt-values', key + ' ' + json(node[key]));
+        pre.style.marginLeft = (depth * 12 + 18) + 'px';
+        list.appendChild(pre);
+      });
+      var counts = el('div', 'gdt-meta', node.changes + ' state changes');
+      counts.style.marginLeft = (depth * 12 + 18) + 'px';
+      list.appendChild(counts);
+      if (node.history) history(node, depth, list);
+    }
+    (node.children || []).forEach(function(child) { tree(child, depth + 1, list); });
+  }
+
+  function send(message) {
+    if (socket && socket.readyState === 1) socket.send(JSON.stringify(message));
+  }
+
+  // changed lists the state keys a transition changed
+  function changed(t) {
+    return Object.keys(t.after || {}).filter(function(key) {
+      return json((t.before || {})[key]) !== json(t.after[key]);
+    });
+  }
+
+  function history(node, depth, list) {
+    var h = node.history, box = el('div', 'gdt-history');
+    box.style.marginLeft = (depth * 12 + 18) + 'px';
+    var bar = el('div', 'gdt-meta', 'history ' + h.position + '/' + h.transitions.length + ' ');
+    [['◀', h.position - 1], ['▶', h.position + 1]].forEach(function(step) {
+      var button = el('button', 'gdt-step', step[0]);
+      button.disabled = step[1] < 0 || step[1] > h.transitions.length;
+      button.onclick = function() { send({type: 'travel', target: node.id, position: step[1]}); };
+      bar.appendChild(button);
+    });
+    var replay = el('button', 'gdt-step', 'replay');
+    replay.disabled = !h.transitions.length;
+    replay.onclick = function() { send({type: 'replay', target: node.id}); };
+    bar.appendChild(replay);
+    box.appendChild(bar);
+
+    var rows = [{action: 'initial'}].concat(h.transitions);
+    rows.forEach(function(t, position) {
+      var row = el('div', 'gdt-transition' + (position === h.position ? ' gdt-selected' : '') + (position > h.position ? ' gdt-future' : ''));
+      if (t.time) row.appendChild(el('span', 'gdt-meta', new Date(t.time).toLocaleTimeString()));
+      row.appendChild(el('span', 'gdt-kind', t.action));
+      if (t.after) row.appendChild(el('span', 'gdt-id', changed(t).join(', ')));
+      row.title = t.after ? json(t.after) : '';
+      row.onclick = function() { send({type: 'travel', target: node.id, position: position}); };
+      box.appendChild(row);
+    });
+    list.appendChild(box);
+  }
+
+  function draw() {
+    if (!body || panel.hidden) return;
+    body.textContent = '';
+    if (!snapshot) { body.appendChild(el('div', 'gdt-meta', 'Connecting…')); return; }
+    if (tab === 'components') {
+      snapshot.roots.forEach(function(root) {
+        body.appendChild(el('div', 'gdt-root', 'root ' + root.id + ' · ' + root.renders + ' renders'));
+        tree(root.component, 0, body);
+      });
+      Object.keys(snapshot.stores || {}).sort().forEach(function(name) {
+        body.appendChild(el('div', 'gdt-root', 'store ' + name));
+        body.appendChild(el('pre', 'gdt-values', json(snapshot.stores[name])));
+      });
+      return;
+    }
+    snapshot.events.slice().reverse().forEach(function(event) {
+      var row = el('div', 'gdt-event' + (event.error ? ' gdt-failed' : ''));
+      row.appendChild(el('span', 'gdt-meta', new Date(event.time).toLocaleTimeString()));
+      row.appendChild(el('span', 'gdt-kind', event.kind));
+      row.appendChild(el('span', 'gdt-id', event.target + (event.type ? ' ' + event.type : '')));
+      if (event.error) row.appendChild(el('div', 'gdt-error', event.error));
+      if (event.data) row.appendChild(el('pre', 'gdt-values', json(event.data)));
+      body.appendChild(row);
+    });
+  }
+
+  function build() {
+    var style = el('style');
+    style.textContent = '#gouix-devtools{position:fixed;right:12px;bottom:12px;z-index:2147483646;font:12px/1.4 ui-monospace,monospace;color:#e5e7eb}' +
+      '#gouix-devtools .gdt-toggle{background:#7c3aed;color:#fff;border:0;border-radius:4px;padding:4px 8px;cursor:pointer;float:right}' +
+      '#gouix-devtools .gdt-panel{clear:both;width:420px;max-height:60vh;overflow:auto;background:#111827;border-radius:6px;margin-bottom:6px;box-shadow:0 8px 24px rgba(0,0,0,.4)}' +
+      '#gouix-devtools .gdt-tabs button{background:none;border:0;color:#9ca3af;padding:6px 10px;cursor:pointer}' +
+      '#gouix-devtools .gdt-tabs .gdt-active{color:#fff;border-bottom:2px solid #7c3aed}' +
+      '#gouix-devtools .gdt-node{cursor:pointer;padding:2px 6px;white-space:nowrap}#gouix-devtools .gdt-node:hover,#gouix-devtools .gdt-selected{background:#1f2937}' +
+      '#gouix-devtools .gdt-id{color:#c4b5fd;margin:0 6px}#gouix-devtools .gdt-count,#gouix-devtools .gdt-meta{color:#6b7280;margin-right:6px}' +
+      '#gouix-devtools .gdt-root{padding:6px;color:#fcd34d;border-top:1px solid #374151}#gouix-devtools .gdt-kind{color:#6ee7b7}' +
+      '#gouix-devtools .gdt-event{padding:4px 6px;border-top:1px solid #1f2937}#gouix-devtools .gdt-failed .gdt-kind,#gouix-devtools .gdt-error{color:#fca5a5}' +
+      '#gouix-devtools pre{margin:2px 6px;white-space:pre-wrap;color:#d1d5db}' +
+      '#gouix-devtools .gdt-step{background:#374151;color:#e5e7eb;border:0;border-radius:3px;margin-left:4px;cursor:pointer}#gouix-devtools .gdt-step:disabled{opacity:.4;cursor:default}' +
+      '#gouix-devtools .gdt-transition{cursor:pointer;padding:1px 6px;white-space:nowrap}#gouix-devtools .gdt-transition:hover{background:#1f2937}#gouix-devtools .gdt-future{opacity:.5}';
+    document.head.appendChild(style);
+
+    var container = el('div');
+    container.id = 'gouix-devtools';
+    panel = el('div', 'gdt-panel');
+    panel.hidden = true;
+    var tabs = el('div', 'gdt-tabs');
+    ['components', 'events'].forEach(function(name) {
+      var button = el('button', name === tab ? 'gdt-active' : '', name);
+      button.onclick = function() {
+        tab = name;
+        Array.prototype.forEach.call(tabs.children, function(b) { b.className = b === button ? 'gdt-active' : ''; });
+        draw();
+      };
+      tabs.appendChild(button);
+    });
+    body = el('div');
+    panel.appendChild(tabs);
+    panel.appendChild(body);
+    var toggle = el('button', 'gdt-toggle', 'GoUIX');
+    toggle.onclick = function() { g.toggleDevtools(); };
+    container.appendChild(panel);
+    container.appendChild(toggle);
+    document.body.appendChild(container);
+    document.addEventListener('keydown', function(e) {
+      if (e.ctrlKey && e.shiftKey && (e.key === 'D' || e.key === 'd')) { e.preventDefault(); g.toggleDevtools(); }
+    });
+  }
+
+  function open() {
+    socket = new WebSocket(url);
+    socket.onopen = function() {
+      delay = 500;
+      socket.send(JSON.stringify({type: 'devtools'}));
+    };
+    socket.onmessage = function(e) {
+      var message = JSON.parse(e.data);
+      if (message.type === 'devtools') { snapshot = message.devtools; draw(); }
+    };
+    socket.onclose = function() {
+      socket = null;
+      setTimeout(open, delay);
+      delay = Math.min(delay * 2, 10000);
+    };
+  }
+
+  g.toggleDevtools = function() {
+    if (!panel) return;
+    panel.hidden = !panel.hidden;
+    if (panel.hidden) highlight(null);
+    draw();
+  };
+
+  g.devtools = function(endpoint) {
+    var loc = window.location;
+    url = /^wss?:/.test(endpoint) ? endpoint : (loc.protocol === 'https:' ? 'wss://' : 'ws://') + loc.host + endpoint;
+    if (document.readyState === 'loading') document.addEventListener('DOMContentLoaded', build);
+    else build();
+    if (!socket) open();
+  };
+})();`
@@ -0,0 +1,91 @@
+package gouix
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// devtoolsPanel renders its children in a section
+type devtoolsPanel struct {
+	BaseComponent
+}
+
+func (p *devtoolsPanel) Render() string {
+	return CreateElement("section", nil, renderChildren(p.GetChildren()))
+}
+
+func TestDevtoolsInspectsTreeAndEvents(t *testing.T) {
+	counter := &patchCounter{HyperComponent: HyperComponent{store: NewStore(map[string]interface{}{"count": 0})}}
+	counter.init("counter", Props{"label": "Clicks", "onclick": func() {}})
+	counter.On("increment", func(event Event) interface{} {
+		counter.SetState("count", counter.GetState("count").(int)+1)
+		return nil
+	})
+	panel := &devtoolsPanel{}
+	panel.init("panel", nil, counter)
+
+	hub := NewLiveHub()
+	if strings.Contains(hub.ScriptTag("/live"), "_gouix.devtools(") {
+		t.Fatal("expected no devtools overlay outside dev mode")
+	}
+	root := hub.Mount("home", panel)
+	hub.Register(counter, root)
+	devtools := hub.EnableDevtools(10)
+	if !strings.Contains(hub.ScriptTag("/live"), `_gouix.devtools("/live");`) {
+		t.Fatal("expected the devtools overlay in dev mode")
+	}
+	root.Render()
+
+	server := httptest.NewServer(hub)
+	defer server.Close()
+	defer hub.Close()
+
+	client := dialTestWS(t, server, "/")
+	client.send(t, liveMessage{Type: "devtools"})
+	if message := client.receive(t); message.Type != "devtools" || len(message.Devtools.Roots) != 1 {
+		t.Fatalf("expected a devtools snapshot, got %+v", message)
+	}
+
+	client.send(t, liveMessage{Type: "event", Target: "counter", Event: "increment", Data: map[string]interface{}{"by": 1}})
+	message := client.receive(t)
+	if message.Type != "devtools" {
+		t.Fatalf("expected a devtools update, got %+v", message)
+	}
+
+	home := message.Devtools.Roots[0]
+	if home.ID != "home" || home.Renders != 2 || home.Component.Type != "gouix.devtoolsPanel" || len(home.Component.Children) != 1 {
+		t.Fatalf("unexpected root %+v", home)
+	}
+	node := home.Component.Children[0]
+	state, _ := json.Marshal(node.State)
+	props, _ := json.Marshal(node.Props)
+	if node.ID != "counter" || node.Renders != 2 || node.Changes != 1 || string(state) != `{"count":1}` {
+		t.Errorf("unexpected counter node %+v, state %s", node, state)
+	}
+	if string(props) != `{"label":"Clicks","onclick":"(func())"}` {
+		t.Errorf("expected handlers to be shown by type, got %s", props)
+	}
+
+	// The first render, then the event and the render it caused
+	events := message.Devtools.Events
+	if len(events) != 3 || events[1].Kind != "event" || events[1].Type != "increment" || events[2].Kind != "render" || events[2].Target != "home" {
+		t.Fatalf("expected the event and the render it caused, got %+v", events)
+	}
+
+	// Unknown targets and store actions are logged too
+	hub.Dispatch(Event{Type: "increment", Target: "missing"})
+	store := NewStore(map[string]interface{}{"theme": "light"})
+	devtools.WatchStore("settings", store)
+	store.Set("theme", "dark")
+
+	snapshot := devtools.Snapshot()
+	if theme, _ := json.Marshal(snapshot.Stores["settings"]["theme"]); string(theme) != `"dark"` {
+		t.Errorf("expected the store's state, got %s", theme)
+	}
+	last := snapshot.Events[len(snapshot.Events)-2:]
+	if last[0].Kind != "error" || !strings.Contains(last[0].Error, "unknown component") || last[1].Kind != "store" || last[1].Target != "settings" {
+		t.Errorf("unexpected events %+v", last)
+	}
+}
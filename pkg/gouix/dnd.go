@@ -0,0 +1,521 @@
+package gouix
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+)
+
+// DragItem is what a drag carries from a draggable element to a drop zone
+type DragItem struct {
+	// Type lets drop zones accept only some items, such as "card"
+	Type string `json:"type"`
+
+	// Data is passed to the drop handler, after a round trip through JSON
+	Data interface{} `json:"data,omitempty"`
+
+	// Source is the component the item is dragged from. It receives
+	// "dragstart" and "dragend" events, which run its DragConfig handlers.
+	Source ComponentID `json:"source,omitempty"`
+}
+
+// Draggable makes the outermost element of markup draggable, with mouse,
+// pen or touch, carrying item. An element inside it marked with
+// data-gouix-drag-handle limits where a drag can start.
+func Draggable(item DragItem, markup string) string {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return markup
+	}
+	return addAttributes(markup, fmt.Sprintf(` data-gouix-drag="%s"`, html.EscapeString(string(data))))
+}
+
+// Drop describes an item dropped on a drop zone
+type Drop struct {
+	Item DragItem
+
+	// Index is the position among the zone's draggable elements where the
+	// item was dropped
+	Index int
+}
+
+// dropFromEvent reads a drop sent by the client runtime
+func dropFromEvent(event Event) Drop {
+	drop := Drop{Item: DragItem{Data: event.Data["data"]}}
+	drop.Item.Type, _ = event.Data["type"].(string)
+	if source, ok := event.Data["source"].(string); ok {
+		drop.Item.Source = ComponentID(source)
+	}
+	drop.Index = eventInt(event.Data["index"])
+	return drop
+}
+
+// eventInt reads a number from event data, which JSON decodes as float64
+func eventInt(value interface{}) int {
+	switch v := value.(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
+
+// DropZone renders its children in an area that draggable elements can be
+// dropped on. The zone is highlighted with the gouix-drop-active class while
+// an item it accepts is dragged over it, and the drop is sent to the server.
+type DropZone struct {
+	BaseComponent
+
+	// Tag is the zone's element, "div" by default
+	Tag string
+
+	// Class is added to the zone's element
+	Class string
+
+	accepts []string
+	onDrop  func(drop Drop) error
+	mutex   sync.RWMutex
+}
+
+// NewDropZone creates a drop zone for items of the given types, or of any
+// type if none are given
+func NewDropZone(id ComponentID, accepts []string, children ...interface{}) *DropZone {
+	zone := &DropZone{Tag: "div", accepts: accepts}
+	zone.init(id, nil, children...)
+
+	zone.On("drop", func(event Event) interface{} {
+		return zone.Drop(dropFromEvent(event))
+	})
+
+	return zone
+}
+
+// OnDrop sets the function called with each accepted drop
+func (z *DropZone) OnDrop(handler func(drop Drop) error) {
+	z.mutex.Lock()
+	defer z.mutex.Unlock()
+
+	z.onDrop = handler
+}
+
+// Accepts reports whether items of a type can be dropped on the zone
+func (z *DropZone) Accepts(itemType string) bool {
+	if len(z.accepts) == 0 {
+		return true
+	}
+	for _, accepted := range z.accepts {
+		if accepted == itemType {
+			return true
+		}
+	}
+	return false
+}
+
+// Drop delivers a drop to the handler, as the client runtime does. Items of a
+// type the zone does not accept are rejected.
+func (z *DropZone) Drop(drop Drop) error {
+	if !z.Accepts(drop.Item.Type) {
+		return fmt.Errorf("gouix: drop zone %q does not accept %q items", z.GetID(), drop.Item.Type)
+	}
+
+	z.mutex.RLock()
+	handler := z.onDrop
+	z.mutex.RUnlock()
+
+	if handler == nil {
+		return nil
+	}
+	return handler(drop)
+}
+
+// Render implements the Component interface
+func (z *DropZone) Render() string {
+	class := "gouix-dropzone"
+	if z.Class != "" {
+		class += " " + z.Class
+	}
+
+	return CreateElement(z.Tag, Props{
+		"id":                  html.EscapeString(string(z.GetID())),
+		"class":               class,
+		"data-gouix-dropzone": html.EscapeString(string(z.GetID())),
+		"data-gouix-accepts":  html.EscapeString(strings.Join(z.accepts, " ")),
+	}, z.GetChildren()...)
+}
+
+// Reorder describes an item moved within a sortable list
+type Reorder struct {
+	// Key of the moved item
+	Key string
+
+	// From and To are the item's positions before and after the move
+	From int
+	To   int
+
+	// Keys is the new order
+	Keys []string
+}
+
+// SortableList renders items, identified by keys, that can be reordered by
+// dragging them or, when an item has focus, with Alt and the arrow keys. The
+// client runtime sends the move to the server, which reorders the keys and
+// patches the list, so the order is never changed only in the browser.
+type SortableList struct {
+	BaseComponent
+
+	// Tag is the list element, "ul" by default; items are rendered in "li"
+	// elements unless it is changed
+	Tag     string
+	ItemTag string
+
+	// Axis is "y" for a vertical list or "x" for a horizontal one
+	Axis string
+
+	// ItemType is the drag type of the items, and the type of items from
+	// elsewhere the list accepts; it defaults to the list's ID. Lists sharing
+	// a type can exchange items.
+	ItemType string
+
+	keys       []string
+	renderItem func(key string, index int) string
+	onReorder  func(reorder Reorder) error
+	onDrop     func(drop Drop) error
+	mutex      sync.RWMutex
+}
+
+// NewSortableList creates a sortable list of keys, each rendered by renderItem
+func NewSortableList(id ComponentID, keys []string, renderItem func(key string, index int) string) *SortableList {
+	list := &SortableList{
+		Tag:        "ul",
+		ItemTag:    "li",
+		Axis:       "y",
+		ItemType:   string(id),
+		keys:       append([]string(nil), keys...),
+		renderItem: renderItem,
+	}
+	list.init(id, nil)
+
+	list.On("reorder", func(event Event) interface{} {
+		key, _ := event.Data["key"].(string)
+		return list.Move(key, eventInt(event.Data["to"]))
+	})
+	list.On("drop", func(event Event) interface{} {
+		drop := dropFromEvent(event)
+		if drop.Item.Type != list.ItemType {
+			return fmt.Errorf("gouix: sortable list %q does not accept %q items", list.GetID(), drop.Item.Type)
+		}
+
+		list.mutex.RLock()
+		handler := list.onDrop
+		list.mutex.RUnlock()
+
+		if handler == nil {
+			return nil
+		}
+		return handler(drop)
+	})
+
+	return list
+}
+
+// OnReorder sets the function called after an item is moved. If it returns an
+// error the move is undone.
+func (l *SortableList) OnReorder(handler func(reorder Reorder) error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.onReorder = handler
+}
+
+// OnDrop sets the function called when an item from another list or
+// draggable is dropped on the list. The list does not change by itself; the
+// handler usually removes the key from its source and inserts it at the
+// drop's index.
+func (l *SortableList) OnDrop(handler func(drop Drop) error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.onDrop = handler
+}
+
+// Keys returns the keys in their current order
+func (l *SortableList) Keys() []string {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	return append([]string(nil), l.keys...)
+}
+
+// SetKeys replaces the items
+func (l *SortableList) SetKeys(keys []string) {
+	l.mutex.Lock()
+	l.keys = append([]string(nil), keys...)
+	l.mutex.Unlock()
+
+	l.notifyStateChange()
+}
+
+// Insert adds an item at index, for example one dropped from another list
+func (l *SortableList) Insert(key string, index int) {
+	l.mutex.Lock()
+	l.keys = insertKey(l.keys, key, index)
+	l.mutex.Unlock()
+
+	l.notifyStateChange()
+}
+
+// Remove removes an item and reports whether it was in the list
+func (l *SortableList) Remove(key string) bool {
+	l.mutex.Lock()
+	index := indexOfKey(l.keys, key)
+	if index >= 0 {
+		l.keys = append(l.keys[:index:index], l.keys[index+1:]...)
+	}
+	l.mutex.Unlock()
+
+	if index < 0 {
+		return false
+	}
+	l.notifyStateChange()
+	return true
+}
+
+// Move moves an item to index, clamped to the list, as the client runtime
+// does when an item is dropped
+func (l *SortableList) Move(key string, to int) error {
+	l.mutex.Lock()
+	from := indexOfKey(l.keys, key)
+	if from < 0 {
+		l.mutex.Unlock()
+		return fmt.Errorf("gouix: sortable list %q has no item %q", l.GetID(), key)
+	}
+
+	previous := l.keys
+	remaining := append(append([]string(nil), l.keys[:from]...), l.keys[from+1:]...)
+	l.keys = insertKey(remaining, key, to)
+	reorder := Reorder{Key: key, From: from, To: indexOfKey(l.keys, key), Keys: append([]string(nil), l.keys...)}
+	handler := l.onReorder
+	l.mutex.Unlock()
+
+	if reorder.From == reorder.To {
+		return nil
+	}
+
+	if handler != nil {
+		if err := handler(reorder); err != nil {
+			l.mutex.Lock()
+			l.keys = previous
+			l.mutex.Unlock()
+			return err
+		}
+	}
+
+	l.notifyStateChange()
+	return nil
+}
+
+// insertKey returns keys with key inserted at index, clamped to the slice
+func insertKey(keys []string, key string, index int) []string {
+	if index < 0 {
+		index = 0
+	}
+	if index > len(keys) {
+		index = len(keys)
+	}
+
+	result := make([]string, 0, len(keys)+1)
+	result = append(result, keys[:index]...)
+	result = append(result, key)
+	return append(result, keys[index:]...)
+}
+
+func indexOfKey(keys []string, key string) int {
+	for i, k := range keys {
+		if k == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// Render implements the Component interface
+func (l *SortableList) Render() string {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	id := string(l.GetID())
+	items := make([]string, len(l.keys))
+	for i, key := range l.keys {
+		item := CreateElement(l.ItemTag, Props{
+			"data-key": html.EscapeString(key),
+			"tabindex": "0",
+		}, l.renderItem(key, i))
+		items[i] = Draggable(DragItem{Type: l.ItemType, Data: key, Source: l.GetID()}, item)
+	}
+
+	return CreateElement(l.Tag, Props{
+		"id":                  html.EscapeString(id),
+		"class":               "gouix-sortable",
+		"data-gouix-sortable": html.EscapeString(id),
+		"data-gouix-accepts":  html.EscapeString(l.ItemType),
+		"data-gouix-axis":     html.EscapeString(l.Axis),
+	}, items)
+}
+
+// DragRuntime is the client-side script for drag and drop. It uses pointer
+// events, so mouse, pen and touch drags work the same way. A drag starts when
+// a data-gouix-drag element moves a few pixels; a copy of it follows the
+// pointer, and drop zones and sortable lists accepting the item's type get
+// the gouix-drop-active class. Dropping sends a "drop" event to the zone, or
+// a "reorder" event to the list the item came from, through the live runtime.
+// The item's source component is sent "dragstart" and "dragend" events.
+const DragRuntime = `(function() {
+  var g = window._gouix = window._gouix || {};
+  var threshold = 5, pending = null, drag = null;
+
+  var style = document.createElement('style');
+  style.textContent = '[data-gouix-drag],[data-gouix-drag-handle]{cursor:grab;touch-action:none}' +
+    '.gouix-dragging{opacity:.4}.gouix-drag-ghost{position:fixed;pointer-events:none;z-index:10000;opacity:.85;margin:0}';
+  document.head.appendChild(style);
+
+  function item(el) {
+    try { return JSON.parse(el.getAttribute('data-gouix-drag')); } catch (e) { return null; }
+  }
+
+  function zoneId(zone) {
+    return zone.getAttribute('data-gouix-dropzone') || zone.getAttribute('data-gouix-sortable');
+  }
+
+  function accepts(zone, type) {
+    var accepted = zone.getAttribute('data-gouix-accepts');
+    return !accepted || accepted.split(/\s+/).indexOf(type) >= 0;
+  }
+
+  function zoneAt(x, y, type) {
+    for (var el = document.elementFromPoint(x, y); el && el.getAttribute; el = el.parentNode) {
+      if ((el.hasAttribute('data-gouix-dropzone') || el.hasAttribute('data-gouix-sortable')) && accepts(el, type)) return el;
+    }
+    return null;
+  }
+
+  function items(zone) {
+    return Array.prototype.filter.call(zone.querySelectorAll('[data-gouix-drag]'), function(el) {
+      return el.parentNode.closest('[data-gouix-dropzone],[data-gouix-sortable]') === zone;
+    });
+  }
+
+  // index is where a drop lands among the zone's items, ignoring the dragged one
+  function index(zone, x, y, dragged) {
+    var horizontal = zone.getAttribute('data-gouix-axis') === 'x', position = 0;
+    items(zone).forEach(function(el) {
+      if (el === dragged) return;
+      var rect = el.getBoundingClientRect();
+      if (horizontal ? x > rect.left + rect.width / 2 : y > rect.top + rect.height / 2) position++;
+    });
+    return position;
+  }
+
+  function notify(source, type, data) {
+    if (source && g.dispatchEvent) g.dispatchEvent(source, type, data);
+  }
+
+  function highlight(zone) {
+    if (drag.zone === zone) return;
+    if (drag.zone) drag.zone.classList.remove('gouix-drop-active');
+    if (zone) zone.classList.add('gouix-drop-active');
+    drag.zone = zone;
+  }
+
+  function start(e) {
+    var el = pending.el, rect = el.getBoundingClientRect();
+    var ghost = el.cloneNode(true);
+    ghost.removeAttribute('id');
+    ghost.classList.add('gouix-drag-ghost');
+    ghost.style.width = rect.width + 'px';
+    ghost.style.height = rect.height + 'px';
+    document.body.appendChild(ghost);
+    el.classList.add('gouix-dragging');
+    drag = {el: el, item: pending.item, ghost: ghost, dx: pending.x - rect.left, dy: pending.y - rect.top, zone: null};
+    pending = null;
+    notify(drag.item.source, 'dragstart', {type: drag.item.type, data: drag.item.data});
+  }
+
+  function finish(dropped) {
+    var d = drag;
+    drag = null;
+    d.ghost.parentNode.removeChild(d.ghost);
+    d.el.classList.remove('gouix-dragging');
+    if (d.zone) d.zone.classList.remove('gouix-drop-active');
+    if (!dropped || !d.zone) {
+      notify(d.item.source, 'dragend', {type: d.item.type, data: d.item.data, dropped: false});
+      return;
+    }
+    var id = zoneId(d.zone), at = index(d.zone, dropped.x, dropped.y, d.el);
+    if (d.zone.hasAttribute('data-gouix-sortable') && id === d.item.source) {
+      g.dispatchEvent(id, 'reorder', {key: d.item.data, to: at});
+    } else {
+      g.dispatchEvent(id, 'drop', {type: d.item.type, data: d.item.data, source: d.item.source || '', index: at});
+    }
+    notify(d.item.source, 'dragend', {type: d.item.type, data: d.item.data, dropped: true, zone: id});
+  }
+
+  document.addEventListener('pointerdown', function(e) {
+    if (drag || (e.pointerType === 'mouse' && e.button !== 0)) return;
+    var el = e.target.closest && e.target.closest('[data-gouix-drag]');
+    if (!el) return;
+    if (el.querySelector('[data-gouix-drag-handle]') && !e.target.closest('[data-gouix-drag-handle]')) return;
+    if (e.target.closest('input,textarea,select,[contenteditable]')) return;
+    var parsed = item(el);
+    if (parsed) pending = {el: el, item: parsed, x: e.clientX, y: e.clientY};
+  });
+
+  document.addEventListener('pointermove', function(e) {
+    if (pending && Math.abs(e.clientX - pending.x) + Math.abs(e.clientY - pending.y) > threshold) start(e);
+    if (!drag) return;
+    e.preventDefault();
+    drag.ghost.style.left = (e.clientX - drag.dx) + 'px';
+    drag.ghost.style.top = (e.clientY - drag.dy) + 'px';
+    highlight(zoneAt(e.clientX, e.clientY, drag.item.type));
+  }, {passive: false});
+
+  document.addEventListener('pointerup', function(e) {
+    pending = null;
+    if (drag) finish({x: e.clientX, y: e.clientY});
+  });
+
+  document.addEventListener('pointercancel', function() {
+    pending = null;
+    if (drag) finish(null);
+  });
+
+  document.addEventListener('keydown', function(e) {
+    if (e.key === 'Escape' && drag) { finish(null); return; }
+    if (!e.altKey) return;
+    var el = e.target.closest && e.target.closest('[data-gouix-drag]');
+    var list = el && el.parentNode.closest('[data-gouix-sortable]');
+    if (!list) return;
+    var horizontal = list.getAttribute('data-gouix-axis') === 'x';
+    var step = {ArrowUp: -1, ArrowDown: 1, ArrowLeft: -1, ArrowRight: 1}[e.key];
+    if (!step || (horizontal !== (e.key === 'ArrowLeft' || e.key === 'ArrowRight'))) return;
+    e.preventDefault();
+    var to = items(list).indexOf(el) + step, key = item(el).data;
+    if (to < 0) return;
+    g.dispatchEvent(list.getAttribute('data-gouix-sortable'), 'reorder', {key: key, to: to});
+    // Keep focus on the moved item once the server patches the list
+    g.afterPatch = function() {
+      var moved = list.querySelector('[data-key="' + String(key).replace(/"/g, '\\"') + '"]');
+      if (moved) moved.focus();
+      g.afterPatch = null;
+    };
+  });
+
+  var apply = g.applyPatches;
+  if (apply) g.applyPatches = function(set) {
+    var applied = apply(set);
+    if (applied && g.afterPatch) g.afterPatch();
+    return applied;
+  };
+})();`
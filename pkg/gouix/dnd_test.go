@@ -0,0 +1,132 @@
+package gouix
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDraggable(t *testing.T) {
+	markup := Draggable(DragItem{Type: "card", Data: map[string]interface{}{"id": 7}, Source: "board"}, `<div class="card">Card</div>`)
+
+	expected := `<div data-gouix-drag="{&#34;type&#34;:&#34;card&#34;,&#34;data&#34;:{&#34;id&#34;:7},&#34;source&#34;:&#34;board&#34;}" class="card">Card</div>`
+	if markup != expected {
+		t.Fatalf("expected %s, got %s", expected, markup)
+	}
+	if plain := Draggable(DragItem{Type: "card"}, "text"); plain != "text" {
+		t.Errorf("expected markup without an element to be unchanged, got %q", plain)
+	}
+}
+
+func TestDropZone(t *testing.T) {
+	zone := NewDropZone("trash", []string{"card", "file"}, `<p>Drop here</p>`)
+
+	var drops []Drop
+	zone.OnDrop(func(drop Drop) error {
+		drops = append(drops, drop)
+		return nil
+	})
+
+	expected := `<div class="gouix-dropzone" data-gouix-accepts="card file" data-gouix-dropzone="trash" id="trash"><p>Drop here</p></div>`
+	if html := zone.Render(); html != expected {
+		t.Fatalf("expected %s, got %s", expected, html)
+	}
+
+	result := zone.HandleEvent(Event{Type: "drop", Target: "trash", Data: map[string]interface{}{
+		"type": "card", "data": "card-1", "source": "board", "index": float64(2),
+	}})
+	if result != nil {
+		t.Fatalf("expected the drop to be accepted, got %v", result)
+	}
+	if len(drops) != 1 || drops[0].Item != (DragItem{Type: "card", Data: "card-1", Source: "board"}) || drops[0].Index != 2 {
+		t.Fatalf("unexpected drops %+v", drops)
+	}
+
+	if err := zone.Drop(Drop{Item: DragItem{Type: "user"}}); err == nil || len(drops) != 1 {
+		t.Errorf("expected an item of another type to be rejected, got %v", err)
+	}
+	if !NewDropZone("any", nil).Accepts("user") {
+		t.Errorf("expected a zone without types to accept anything")
+	}
+}
+
+func TestSortableList(t *testing.T) {
+	list := NewSortableList("tasks", []string{"a", "b", "c"}, func(key string, index int) string {
+		return CreateElement("span", nil, strings.ToUpper(key))
+	})
+
+	html := list.Render()
+	if !strings.HasPrefix(html, `<ul class="gouix-sortable" data-gouix-accepts="tasks" data-gouix-axis="y" data-gouix-sortable="tasks" id="tasks"><li data-gouix-drag="{&#34;type&#34;:&#34;tasks&#34;,&#34;data&#34;:&#34;a&#34;,&#34;source&#34;:&#34;tasks&#34;}" data-key="a" tabindex="0"><span>A</span></li>`) {
+		t.Fatalf("unexpected markup %s", html)
+	}
+
+	var reorders []Reorder
+	list.OnReorder(func(reorder Reorder) error {
+		reorders = append(reorders, reorder)
+		if reorder.To == 0 {
+			return errors.New("pinned")
+		}
+		return nil
+	})
+
+	changes := 0
+	list.OnStateChange(func() { changes++ })
+
+	list.HandleEvent(Event{Type: "reorder", Data: map[string]interface{}{"key": "a", "to": float64(2)}})
+	if keys := list.Keys(); !reflect.DeepEqual(keys, []string{"b", "c", "a"}) {
+		t.Fatalf("expected a to move last, got %v", keys)
+	}
+	if len(reorders) != 1 || reorders[0].From != 0 || reorders[0].To != 2 || changes != 1 {
+		t.Fatalf("unexpected reorders %+v after %d changes", reorders, changes)
+	}
+
+	if err := list.Move("c", 9); err != nil || !reflect.DeepEqual(list.Keys(), []string{"b", "a", "c"}) {
+		t.Fatalf("expected the index to be clamped, got %v, %v", list.Keys(), err)
+	}
+	if err := list.Move("a", 0); err == nil || !reflect.DeepEqual(list.Keys(), []string{"b", "a", "c"}) {
+		t.Fatalf("expected the rejected move to be undone, got %v, %v", list.Keys(), err)
+	}
+	if err := list.Move("z", 0); err == nil {
+		t.Errorf("expected an error for a missing key")
+	}
+
+	list.Insert("d", 1)
+	if !list.Remove("b") || list.Remove("b") || !reflect.DeepEqual(list.Keys(), []string{"d", "a", "c"}) {
+		t.Errorf("unexpected keys after insert and remove %v", list.Keys())
+	}
+
+	var dropped []Drop
+	list.OnDrop(func(drop Drop) error {
+		dropped = append(dropped, drop)
+		return nil
+	})
+	list.HandleEvent(Event{Type: "drop", Data: map[string]interface{}{"type": "tasks", "data": "e", "source": "done"}})
+	list.HandleEvent(Event{Type: "drop", Data: map[string]interface{}{"type": "files", "data": "f"}})
+	if len(dropped) != 1 || dropped[0].Item.Source != "done" {
+		t.Errorf("expected only the matching drop, got %+v", dropped)
+	}
+}
+
+func TestDragConfigHandlers(t *testing.T) {
+	component := NewBaseComponent("card", nil)
+
+	var events []string
+	record := func(event Event) interface{} {
+		events = append(events, event.Type)
+		return nil
+	}
+	component.On("dragstart", record)
+	component.HandleEvent(Event{Type: "dragstart"})
+
+	component.EnableDrag(&DragConfig{OnDragStart: record, OnDragEnd: record})
+	component.HandleEvent(Event{Type: "dragstart"})
+	component.HandleEvent(Event{Type: "dragend"})
+
+	component.DisableDrag()
+	component.HandleEvent(Event{Type: "dragend"})
+
+	if expected := []string{"dragstart", "dragstart", "dragstart", "dragend"}; !reflect.DeepEqual(events, expected) {
+		t.Errorf("expected %v, got %v", expected, events)
+	}
+}
@@ -0,0 +1,218 @@
+package gouix
+
+import (
+	"encoding/json"
+	"html"
+	"sync"
+)
+
+// MenuItem is an entry of a Dropdown
+type MenuItem struct {
+	// Key is passed to the OnSelect functions
+	Key string
+
+	// Label is the text of the item
+	Label string
+
+	// Disabled items can be focused but not selected
+	Disabled bool
+}
+
+// Dropdown is a menu button: a button that opens a menu of actions below
+// it. The button is wired with aria-haspopup, aria-expanded and
+// aria-controls, and the menu is a RovingFocus group of menu items labelled
+// by the button. Opening the menu focuses its first item, or its last when
+// opened with the up arrow; Escape closes it and returns focus to the
+// button, and Tab or a click elsewhere closes it.
+type Dropdown struct {
+	BaseComponent
+
+	// Label is the text of the button
+	Label string
+
+	items     []MenuItem
+	open      bool
+	focusLast bool
+	onSelect  []func(key string)
+	mutex     sync.RWMutex
+}
+
+// NewDropdown creates a closed dropdown menu
+func NewDropdown(id ComponentID, label string, items ...MenuItem) *Dropdown {
+	d := &Dropdown{Label: label, items: items}
+	d.init(id, nil)
+
+	d.On("toggle", func(event Event) interface{} {
+		if d.IsOpen() {
+			d.Close()
+		} else {
+			d.Open()
+		}
+		return nil
+	})
+	d.On("open", func(event Event) interface{} {
+		focus, _ := event.Data["focus"].(string)
+		d.show(focus == "last")
+		return nil
+	})
+	d.On("close", func(event Event) interface{} {
+		d.Close()
+		return nil
+	})
+	d.On("select", func(event Event) interface{} {
+		key, _ := event.Data["key"].(string)
+		d.Select(key)
+		return nil
+	})
+
+	return d
+}
+
+// Open shows the menu
+func (d *Dropdown) Open() {
+	d.show(false)
+}
+
+func (d *Dropdown) show(focusLast bool) {
+	d.mutex.Lock()
+	d.open = true
+	d.focusLast = focusLast
+	d.mutex.Unlock()
+
+	d.notifyStateChange()
+}
+
+// Close hides the menu
+func (d *Dropdown) Close() {
+	d.mutex.Lock()
+	wasOpen := d.open
+	d.open = false
+	d.mutex.Unlock()
+
+	if wasOpen {
+		d.notifyStateChange()
+	}
+}
+
+// IsOpen reports whether the menu is shown
+func (d *Dropdown) IsOpen() bool {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	return d.open
+}
+
+// Items returns the menu's items
+func (d *Dropdown) Items() []MenuItem {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	return append([]MenuItem(nil), d.items...)
+}
+
+// SetItems replaces the menu's items
+func (d *Dropdown) SetItems(items ...MenuItem) {
+	d.mutex.Lock()
+	d.items = items
+	d.mutex.Unlock()
+
+	d.notifyStateChange()
+}
+
+// OnSelect adds a function called with the key of the item selected
+func (d *Dropdown) OnSelect(handler func(key string)) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.onSelect = append(d.onSelect, handler)
+}
+
+// Select closes the menu and calls the OnSelect functions with an item's
+// key, and reports whether the item could be selected; disabled and unknown
+// items are not
+func (d *Dropdown) Select(key string) bool {
+	d.mutex.RLock()
+	found := false
+	for _, item := range d.items {
+		if item.Key == key && !item.Disabled {
+			found = true
+			break
+		}
+	}
+	handlers := append([]func(string){}, d.onSelect...)
+	d.mutex.RUnlock()
+
+	if !found {
+		return false
+	}
+	d.Close()
+	for _, handler := range handlers {
+		handler(key)
+	}
+	return true
+}
+
+// Render implements the Component interface
+func (d *Dropdown) Render() string {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	id := string(d.GetID())
+	buttonID := html.EscapeString(id + "-button")
+	menuID := html.EscapeString(id + "-menu")
+
+	items := make([]interface{}, len(d.items))
+	for i, item := range d.items {
+		key, _ := json.Marshal(item.Key)
+		props := Props{
+			"type":                   "button",
+			"role":                   "menuitem",
+			"class":                  "gouix-menu-item",
+			"tabindex":               "-1",
+			"data-gouix-roving-item": true,
+		}
+		if item.Disabled {
+			props["aria-disabled"] = "true"
+		} else {
+			props["onclick"] = dispatchJS(d.GetID(), "select", "{key: "+string(key)+"}")
+		}
+		items[i] = CreateElement("li", Props{"role": "none"},
+			CreateElement("button", props, html.EscapeString(item.Label)),
+		)
+	}
+
+	expanded := "false"
+	menu := Props{
+		"id":                menuID,
+		"role":              "menu",
+		"class":             "gouix-dropdown-menu",
+		"aria-labelledby":   buttonID,
+		"data-gouix-menu":   true,
+		"data-gouix-roving": string(Vertical),
+		"hidden":            true,
+	}
+	if d.open {
+		expanded = "true"
+		delete(menu, "hidden")
+		if d.focusLast {
+			menu["data-gouix-focus"] = "last"
+		}
+	}
+
+	return CreateElement("div", Props{
+		"id":                  html.EscapeString(id),
+		"class":               "gouix-dropdown",
+		"data-gouix-dropdown": html.EscapeString(id),
+	},
+		CreateElement("button", Props{
+			"type":          "button",
+			"id":            buttonID,
+			"class":         "gouix-dropdown-button",
+			"aria-haspopup": "menu",
+			"aria-expanded": expanded,
+			"aria-controls": menuID,
+			"onclick":       dispatchJS(d.GetID(), "toggle", "{}"),
+		}, html.EscapeString(d.Label)),
+		CreateElement("ul", menu, items...),
+	)
+}
@@ -0,0 +1,56 @@
+package gouix
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDropdownOpensAndSelects(t *testing.T) {
+	dropdown := NewDropdown("actions", "Actions",
+		MenuItem{Key: "rename", Label: "Rename"},
+		MenuItem{Key: "delete", Label: "Delete", Disabled: true},
+	)
+	var selected []string
+	dropdown.OnSelect(func(key string) { selected = append(selected, key) })
+
+	hub := NewLiveHub()
+	root := hub.Mount("actions", dropdown)
+	html := root.Render()
+	for _, expected := range []string{
+		`<div class="gouix-dropdown" data-gouix-dropdown="actions" id="actions">`,
+		`<button aria-controls="actions-menu" aria-expanded="false" aria-haspopup="menu" class="gouix-dropdown-button" id="actions-button" onclick=`,
+		`<ul aria-labelledby="actions-button" class="gouix-dropdown-menu" data-gouix-menu data-gouix-roving="vertical" hidden id="actions-menu" role="menu">`,
+		`<li role="none"><button aria-disabled="true" class="gouix-menu-item" data-gouix-roving-item role="menuitem" tabindex="-1" type="button">Delete</button></li>`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("expected %s in %s", expected, html)
+		}
+	}
+	if issues, err := CheckA11y(html, A11yOptions{}); err != nil || len(issues) != 0 {
+		t.Errorf("expected an accessible dropdown, got %v, %v", issues, err)
+	}
+
+	if err := hub.Dispatch(Event{Type: "open", Target: "actions", Data: map[string]interface{}{"focus": "last"}}); err != nil {
+		t.Fatalf("Dispatch returned error: %v", err)
+	}
+	html = dropdown.Render()
+	if !dropdown.IsOpen() || !strings.Contains(html, `aria-expanded="true"`) || !strings.Contains(html, `data-gouix-focus="last" data-gouix-menu data-gouix-roving="vertical" id="actions-menu"`) {
+		t.Fatalf("expected an open menu focusing its last item, got %s", html)
+	}
+
+	if dropdown.Select("delete") || !dropdown.IsOpen() {
+		t.Error("expected a disabled item not to be selected")
+	}
+	if err := hub.Dispatch(Event{Type: "select", Target: "actions", Data: map[string]interface{}{"key": "rename"}}); err != nil {
+		t.Fatalf("Dispatch returned error: %v", err)
+	}
+	if dropdown.IsOpen() || strings.Join(selected, ",") != "rename" {
+		t.Errorf("expected selecting to close the menu, got open %v and %v", dropdown.IsOpen(), selected)
+	}
+
+	dropdown.HandleEvent(Event{Type: "toggle"})
+	dropdown.HandleEvent(Event{Type: "toggle"})
+	if dropdown.IsOpen() {
+		t.Error("expected toggling twice to close the menu")
+	}
+}
@@ -0,0 +1,622 @@
+package gouix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Validator checks a field value. It receives all form values for rules that
+// compare fields, and returns an error whose message is shown to the user.
+type Validator func(value interface{}, values map[string]interface{}) error
+
+// Required rejects empty values, unchecked checkboxes and blank strings
+func Required(message string) Validator {
+	if message == "" {
+		message = "This field is required"
+	}
+	return func(value interface{}, _ map[string]interface{}) error {
+		switch v := value.(type) {
+		case nil:
+			return errors.New(message)
+		case string:
+			if strings.TrimSpace(v) == "" {
+				return errors.New(message)
+			}
+		case bool:
+			if !v {
+				return errors.New(message)
+			}
+		}
+		return nil
+	}
+}
+
+// Pattern rejects non-empty values that do not match a regular expression.
+// It panics if the expression is invalid.
+func Pattern(expr, message string) Validator {
+	re := regexp.MustCompile(expr)
+	if message == "" {
+		message = "Invalid format"
+	}
+	return func(value interface{}, _ map[string]interface{}) error {
+		text := fmt.Sprint(value)
+		if value == nil || text == "" {
+			return nil
+		}
+		if !re.MatchString(text) {
+			return errors.New(message)
+		}
+		return nil
+	}
+}
+
+// MinLength rejects non-empty strings shorter than n characters
+func MinLength(n int, message string) Validator {
+	if message == "" {
+		message = fmt.Sprintf("Must be at least %d characters", n)
+	}
+	return func(value interface{}, _ map[string]interface{}) error {
+		text, _ := value.(string)
+		if text != "" && utf8.RuneCountInString(text) < n {
+			return errors.New(message)
+		}
+		return nil
+	}
+}
+
+// MaxLength rejects strings longer than n characters
+func MaxLength(n int, message string) Validator {
+	if message == "" {
+		message = fmt.Sprintf("Must be at most %d characters", n)
+	}
+	return func(value interface{}, _ map[string]interface{}) error {
+		text, _ := value.(string)
+		if utf8.RuneCountInString(text) > n {
+			return errors.New(message)
+		}
+		return nil
+	}
+}
+
+// FieldErrors maps field names to messages. Submit handlers and mutations
+// return it to report server-side validation errors next to the fields.
+type FieldErrors map[string]string
+
+// Error implements the error interface
+func (e FieldErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	messages := make([]string, len(names))
+	for i, name := range names {
+		messages[i] = name + ": " + e[name]
+	}
+	return strings.Join(messages, "; ")
+}
+
+// FieldErrors returns the errors by field
+func (e FieldErrors) FieldErrors() map[string]string {
+	return e
+}
+
+// fieldErrorer is implemented by errors that carry per-field messages
+type fieldErrorer interface {
+	FieldErrors() map[string]string
+}
+
+// StateBinder is a component whose state a form reads and writes
+type StateBinder interface {
+	GetState(key string) interface{}
+	SetState(key string, value interface{})
+}
+
+// Field describes a form input
+type Field struct {
+	// Name of the value, also used as the state key when the form is bound
+	Name string
+
+	// Label shown with the input
+	Label string
+
+	// Input type: text, email, password, number, checkbox, textarea or select
+	Type string
+
+	// Placeholder text
+	Placeholder string
+
+	// Options for a select
+	Options []string
+
+	// Initial value
+	Initial interface{}
+
+	// Validators run in order; the first error is shown
+	Validators []Validator
+}
+
+// Form renders fields bound to values and validates them as the user types.
+// Errors appear once a field has been touched (blurred) or a submit was
+// attempted. Browser events reach the form through the live runtime.
+type Form struct {
+	BaseComponent
+
+	// SubmitLabel is the submit button text
+	SubmitLabel string
+
+	// SuccessMessage is shown after a successful submit
+	SuccessMessage string
+
+	fields     []*Field
+	values     map[string]interface{}
+	initial    map[string]interface{}
+	errors     map[string]string
+	touched    map[string]bool
+	formError  string
+	submitting bool
+	submitted  bool
+	attempted  bool
+	result     interface{}
+	binding    StateBinder
+	onSubmit   func(values map[string]interface{}) error
+	onSuccess  func(result interface{})
+	mutex      sync.RWMutex
+}
+
+// NewForm creates a form with the given fields
+func NewForm(id ComponentID, fields ...*Field) *Form {
+	form := &Form{
+		SubmitLabel: "Submit",
+		fields:      fields,
+		values:      make(map[string]interface{}),
+		initial:     make(map[string]interface{}),
+		errors:      make(map[string]string),
+		touched:     make(map[string]bool),
+	}
+	form.init(id, nil)
+
+	for _, field := range fields {
+		if field.Type == "" {
+			field.Type = "text"
+		}
+		form.values[field.Name] = field.Initial
+		form.initial[field.Name] = field.Initial
+	}
+
+	form.On("input", func(event Event) interface{} {
+		name, _ := event.Data["name"].(string)
+		form.SetValue(name, event.Data["value"])
+		return nil
+	})
+	form.On("blur", func(event Event) interface{} {
+		name, _ := event.Data["name"].(string)
+		form.Touch(name)
+		return nil
+	})
+	form.On("submit", func(event Event) interface{} {
+		return form.Submit()
+	})
+
+	return form
+}
+
+// field returns a field by name
+func (f *Form) field(name string) *Field {
+	for _, field := range f.fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// Bind keeps the form values in a component's state. Values already in the
+// state replace the fields' initial values.
+func (f *Form) Bind(target StateBinder) {
+	f.mutex.Lock()
+	f.binding = target
+	for _, field := range f.fields {
+		if value := target.GetState(field.Name); value != nil {
+			f.values[field.Name] = value
+			f.initial[field.Name] = value
+		}
+	}
+	f.mutex.Unlock()
+
+	f.notifyStateChange()
+}
+
+// OnSubmit sets the function called with the values of a valid form. An
+// error implementing FieldErrors() is shown next to the fields; any other
+// error is shown above the submit button.
+func (f *Form) OnSubmit(handler func(values map[string]interface{}) error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.onSubmit = handler
+}
+
+// SubmitTo submits the form values as the parameters of a mutation, such as a
+// GoScaleAPI resolver from GetResolvers()["mutation:createUser"]. The
+// mutation's result is available from Result.
+func (f *Form) SubmitTo(mutation func(ctx context.Context, params map[string]interface{}) (interface{}, error)) {
+	f.OnSubmit(func(values map[string]interface{}) error {
+		result, err := mutation(context.Background(), values)
+		if err != nil {
+			return err
+		}
+
+		f.mutex.Lock()
+		f.result = result
+		f.mutex.Unlock()
+		return nil
+	})
+}
+
+// OnSuccess sets a function called after a successful submit with the
+// mutation result, if any
+func (f *Form) OnSuccess(handler func(result interface{})) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.onSuccess = handler
+}
+
+// convert turns a browser value into the field's value type
+func (field *Field) convert(value interface{}) interface{} {
+	switch field.Type {
+	case "checkbox":
+		if checked, ok := value.(bool); ok {
+			return checked
+		}
+		return value == "on" || value == "true"
+	case "number":
+		if text, ok := value.(string); ok {
+			if number, err := strconv.ParseFloat(strings.TrimSpace(text), 64); err == nil {
+				return number
+			}
+		}
+	}
+	return value
+}
+
+// validateField runs a field's validators; the caller holds the lock
+func (f *Form) validateField(field *Field) {
+	delete(f.errors, field.Name)
+	for _, validator := range field.Validators {
+		if err := validator(f.values[field.Name], f.values); err != nil {
+			f.errors[field.Name] = err.Error()
+			return
+		}
+	}
+}
+
+// SetValue changes a value, updates the bound state and revalidates the field
+func (f *Form) SetValue(name string, value interface{}) {
+	f.mutex.Lock()
+	field := f.field(name)
+	if field == nil {
+		f.mutex.Unlock()
+		return
+	}
+
+	value = field.convert(value)
+	f.values[name] = value
+	f.submitted = false
+	f.validateField(field)
+	binding := f.binding
+	f.mutex.Unlock()
+
+	if binding != nil {
+		binding.SetState(name, value)
+	}
+	f.notifyStateChange()
+}
+
+// Touch marks a field as visited, so its errors are shown
+func (f *Form) Touch(name string) {
+	f.mutex.Lock()
+	field := f.field(name)
+	if field == nil || f.touched[name] {
+		f.mutex.Unlock()
+		return
+	}
+	f.touched[name] = true
+	f.validateField(field)
+	f.mutex.Unlock()
+
+	f.notifyStateChange()
+}
+
+// Validate checks every field and reports whether the form is valid
+func (f *Form) Validate() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, field := range f.fields {
+		f.validateField(field)
+	}
+	return len(f.errors) == 0
+}
+
+// Submit validates the form and calls the submit handler. It returns the
+// handler's error, or a FieldErrors if validation failed.
+func (f *Form) Submit() error {
+	f.mutex.Lock()
+	if f.submitting {
+		f.mutex.Unlock()
+		return errors.New("gouix: form is already submitting")
+	}
+
+	f.attempted = true
+	f.formError = ""
+	for _, field := range f.fields {
+		f.validateField(field)
+	}
+	if len(f.errors) > 0 {
+		errs := make(FieldErrors, len(f.errors))
+		for name, message := range f.errors {
+			errs[name] = message
+		}
+		f.mutex.Unlock()
+
+		f.notifyStateChange()
+		return errs
+	}
+
+	handler := f.onSubmit
+	values := f.copyValues()
+	f.submitting = true
+	f.mutex.Unlock()
+
+	// Show the submitting state while the handler runs
+	f.notifyStateChange()
+
+	var err error
+	if handler != nil {
+		err = handler(values)
+	}
+
+	f.mutex.Lock()
+	f.submitting = false
+	var fieldErrs fieldErrorer
+	if err == nil {
+		// The submitted values are the new baseline for dirty tracking
+		f.submitted = true
+		f.attempted = false
+		f.initial = values
+		f.touched = make(map[string]bool)
+	} else if errors.As(err, &fieldErrs) {
+		for name, message := range fieldErrs.FieldErrors() {
+			if f.field(name) != nil {
+				f.errors[name] = message
+			} else {
+				f.formError = message
+			}
+		}
+	} else {
+		f.formError = err.Error()
+	}
+	onSuccess := f.onSuccess
+	result := f.result
+	f.mutex.Unlock()
+
+	if err == nil && onSuccess != nil {
+		onSuccess(result)
+	}
+	f.notifyStateChange()
+
+	return err
+}
+
+// Reset restores the initial values and clears errors and touched state
+func (f *Form) Reset() {
+	f.mutex.Lock()
+	for name, value := range f.initial {
+		f.values[name] = value
+	}
+	f.errors = make(map[string]string)
+	f.touched = make(map[string]bool)
+	f.formError = ""
+	f.attempted = false
+	f.submitted = false
+	binding := f.binding
+	values := f.copyValues()
+	f.mutex.Unlock()
+
+	if binding != nil {
+		for name, value := range values {
+			binding.SetState(name, value)
+		}
+	}
+	f.notifyStateChange()
+}
+
+// copyValues returns a copy of the values; the caller holds the lock
+func (f *Form) copyValues() map[string]interface{} {
+	values := make(map[string]interface{}, len(f.values))
+	for name, value := range f.values {
+		values[name] = value
+	}
+	return values
+}
+
+// Values returns a copy of the current values
+func (f *Form) Values() map[string]interface{} {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	return f.copyValues()
+}
+
+// Value returns a field's current value
+func (f *Form) Value(name string) interface{} {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	return f.values[name]
+}
+
+// Errors returns the current validation errors by field
+func (f *Form) Errors() map[string]string {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	errs := make(map[string]string, len(f.errors))
+	for name, message := range f.errors {
+		errs[name] = message
+	}
+	return errs
+}
+
+// Result returns the result of the last successful mutation
+func (f *Form) Result() interface{} {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	return f.result
+}
+
+// IsTouched reports whether a field has been visited
+func (f *Form) IsTouched(name string) bool {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	return f.touched[name]
+}
+
+// IsDirty reports whether a field differs from its initial value
+func (f *Form) IsDirty(name string) bool {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	return !reflect.DeepEqual(f.values[name], f.initial[name])
+}
+
+// Dirty reports whether any field differs from its initial value
+func (f *Form) Dirty() bool {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	return !reflect.DeepEqual(f.values, f.initial)
+}
+
+// dispatchJS returns an inline handler sending a form event to the server
+func (f *Form) dispatchJS(event, data string) string {
+	return dispatchJS(f.GetID(), event, data)
+}
+
+// renderField renders one field; the caller holds the read lock
+func (f *Form) renderField(field *Field) string {
+	id := string(f.GetID()) + "-" + field.Name
+	name, _ := json.Marshal(field.Name)
+	value := f.values[field.Name]
+	text := ""
+	if value != nil {
+		text = fmt.Sprint(value)
+	}
+
+	message := ""
+	if f.touched[field.Name] || f.attempted {
+		message = f.errors[field.Name]
+	}
+
+	props := Props{
+		"id":     html.EscapeString(id),
+		"name":   html.EscapeString(field.Name),
+		"onblur": f.dispatchJS("blur", "{name: "+string(name)+"}"),
+	}
+	if field.Placeholder != "" {
+		props["placeholder"] = html.EscapeString(field.Placeholder)
+	}
+	if message != "" {
+		props["aria-invalid"] = "true"
+		props["aria-describedby"] = html.EscapeString(id + "-error")
+	}
+
+	var input string
+	switch field.Type {
+	case "textarea":
+		props["oninput"] = f.dispatchJS("input", "{name: "+string(name)+", value: this.value}")
+		input = CreateElement("textarea", props, html.EscapeString(text))
+	case "select":
+		props["onchange"] = f.dispatchJS("input", "{name: "+string(name)+", value: this.value}")
+		options := make([]string, len(field.Options))
+		for i, option := range field.Options {
+			options[i] = CreateElement("option", Props{
+				"value":    html.EscapeString(option),
+				"selected": option == text,
+			}, html.EscapeString(option))
+		}
+		input = CreateElement("select", props, options)
+	case "checkbox":
+		props["type"] = "checkbox"
+		props["checked"] = value == true
+		props["onchange"] = f.dispatchJS("input", "{name: "+string(name)+", value: this.checked}")
+		input = CreateElement("input", props)
+	default:
+		props["type"] = html.EscapeString(field.Type)
+		props["value"] = html.EscapeString(text)
+		props["oninput"] = f.dispatchJS("input", "{name: "+string(name)+", value: this.value}")
+		input = CreateElement("input", props)
+	}
+
+	class := "gouix-field"
+	if message != "" {
+		class += " gouix-field-invalid"
+	}
+
+	children := []interface{}{}
+	if field.Label != "" {
+		children = append(children, CreateElement("label", Props{"for": html.EscapeString(id)}, html.EscapeString(field.Label)))
+	}
+	children = append(children, input)
+	if message != "" {
+		children = append(children, CreateElement("p", Props{
+			"id":    html.EscapeString(id + "-error"),
+			"class": "gouix-field-error",
+		}, html.EscapeString(message)))
+	}
+
+	return CreateElement("div", Props{"class": class}, children...)
+}
+
+// Render implements the Component interface
+func (f *Form) Render() string {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	children := make([]interface{}, 0, len(f.fields)+3)
+	for _, field := range f.fields {
+		children = append(children, f.renderField(field))
+	}
+
+	if f.formError != "" {
+		children = append(children, CreateElement("p", Props{"class": "gouix-form-error", "role": "alert"}, html.EscapeString(f.formError)))
+	}
+	if f.submitted && f.SuccessMessage != "" {
+		children = append(children, CreateElement("p", Props{"class": "gouix-form-success", "role": "status"}, html.EscapeString(f.SuccessMessage)))
+	}
+	children = append(children, CreateElement("button", Props{
+		"type":     "submit",
+		"disabled": f.submitting,
+	}, html.EscapeString(f.SubmitLabel)))
+
+	return CreateElement("form", Props{
+		"id":         html.EscapeString(string(f.GetID())),
+		"novalidate": true,
+		"onsubmit":   "event.preventDefault(); " + f.dispatchJS("submit", "{}"),
+	}, children...)
+}
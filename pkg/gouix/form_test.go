@@ -0,0 +1,147 @@
+package gouix
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newSignupForm() *Form {
+	return NewForm("signup",
+		&Field{Name: "email", Label: "Email", Type: "email", Validators: []Validator{
+			Required(""),
+			Pattern(`^[^@\s]+@[^@\s]+$`, "Enter a valid email"),
+		}},
+		&Field{Name: "password", Label: "Password", Type: "password", Validators: []Validator{
+			Required(""),
+			MinLength(8, ""),
+		}},
+		&Field{Name: "confirm", Label: "Confirm", Type: "password", Validators: []Validator{
+			func(value interface{}, values map[string]interface{}) error {
+				if value != values["password"] {
+					return errors.New("Passwords do not match")
+				}
+				return nil
+			},
+		}},
+		&Field{Name: "terms", Label: "I agree", Type: "checkbox", Initial: false},
+	)
+}
+
+func TestFormValidationAndTouched(t *testing.T) {
+	form := newSignupForm()
+
+	form.HandleEvent(Event{Type: "input", Data: map[string]interface{}{"name": "email", "value": "nope"}})
+	if html := form.Render(); strings.Contains(html, "Enter a valid email") {
+		t.Errorf("errors should be hidden until the field is touched")
+	}
+
+	form.HandleEvent(Event{Type: "blur", Data: map[string]interface{}{"name": "email"}})
+	html := form.Render()
+	if !strings.Contains(html, `<p class="gouix-field-error" id="signup-email-error">Enter a valid email</p>`) {
+		t.Errorf("expected the email error after blur, got %s", html)
+	}
+	if !strings.Contains(html, `aria-invalid="true"`) {
+		t.Errorf("expected the input to be marked invalid")
+	}
+
+	if !form.IsTouched("email") || !form.IsDirty("email") || form.IsDirty("password") {
+		t.Errorf("unexpected touched/dirty state")
+	}
+
+	form.HandleEvent(Event{Type: "input", Data: map[string]interface{}{"name": "terms", "value": true}})
+	if form.Value("terms") != true {
+		t.Errorf("expected the checkbox value to be a bool, got %#v", form.Value("terms"))
+	}
+}
+
+func TestFormSubmitToMutation(t *testing.T) {
+	form := newSignupForm()
+
+	var params map[string]interface{}
+	form.SubmitTo(func(ctx context.Context, p map[string]interface{}) (interface{}, error) {
+		params = p
+		if p["email"] == "taken@example.com" {
+			return nil, FieldErrors{"email": "Already registered"}
+		}
+		return map[string]interface{}{"id": 1}, nil
+	})
+	form.SuccessMessage = "Welcome!"
+
+	// Invalid forms are not submitted and show every error
+	err := form.Submit()
+	if _, ok := err.(FieldErrors); !ok || params != nil {
+		t.Fatalf("expected validation errors without a submit, got %v", err)
+	}
+	if html := form.Render(); !strings.Contains(html, "This field is required") {
+		t.Errorf("expected errors after a submit attempt, got %s", html)
+	}
+
+	form.SetValue("email", "taken@example.com")
+	form.SetValue("password", "secret123")
+	form.SetValue("confirm", "secret123")
+	form.Submit()
+	if form.Errors()["email"] != "Already registered" {
+		t.Errorf("expected the server error on the email field, got %v", form.Errors())
+	}
+
+	form.SetValue("email", "new@example.com")
+	if err := form.Submit(); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if params["email"] != "new@example.com" || params["password"] != "secret123" {
+		t.Errorf("unexpected mutation params %v", params)
+	}
+	if result := form.Result().(map[string]interface{}); result["id"] != 1 {
+		t.Errorf("unexpected result %v", result)
+	}
+	if form.Dirty() {
+		t.Errorf("a successful submit should reset dirty tracking")
+	}
+	if html := form.Render(); !strings.Contains(html, "Welcome!") {
+		t.Errorf("expected the success message, got %s", html)
+	}
+
+	form.OnSubmit(func(map[string]interface{}) error { return errors.New("service unavailable") })
+	form.Submit()
+	if html := form.Render(); !strings.Contains(html, `<p class="gouix-form-error" role="alert">service unavailable</p>`) {
+		t.Errorf("expected the form error, got %s", html)
+	}
+}
+
+func TestFormBindsComponentState(t *testing.T) {
+	profile := NewBaseComponent("profile", nil)
+	profile.SetState("email", "me@example.com")
+
+	form := newSignupForm()
+	form.Bind(profile)
+
+	if form.Value("email") != "me@example.com" || form.Dirty() {
+		t.Errorf("expected the bound state as the initial value, got %v", form.Values())
+	}
+	if html := form.Render(); !strings.Contains(html, `value="me@example.com"`) {
+		t.Errorf("expected the bound value in the input, got %s", html)
+	}
+
+	form.SetValue("email", "you@example.com")
+	if profile.GetState("email") != "you@example.com" {
+		t.Errorf("expected the form to write the component state, got %v", profile.GetState("email"))
+	}
+
+	form.Reset()
+	if profile.GetState("email") != "me@example.com" {
+		t.Errorf("expected Reset to restore the component state, got %v", profile.GetState("email"))
+	}
+}
+
+func TestFormEscapesValues(t *testing.T) {
+	form := NewForm("f", &Field{Name: "q", Initial: `"><script>`})
+	html := form.Render()
+	if strings.Contains(html, "<script>") {
+		t.Errorf("expected values to be escaped, got %s", html)
+	}
+	if _, err := ParseHTML(html); err != nil {
+		t.Errorf("rendered form does not parse: %v", err)
+	}
+}
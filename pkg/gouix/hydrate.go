@@ -0,0 +1,199 @@
+package gouix
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"strings"
+)
+
+// StateSnapshotter is implemented by components whose state can be embedded
+// in server-rendered HTML
+type StateSnapshotter interface {
+	StateSnapshot() map[string]interface{}
+}
+
+// StateSnapshot returns a copy of the component state
+func (b *BaseComponent) StateSnapshot() map[string]interface{} {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	state := make(map[string]interface{}, len(b.state))
+	for key, value := range b.state {
+		state[key] = value
+	}
+	return state
+}
+
+// StateSnapshot returns the values of the component's store
+func (h *HyperComponent) StateSnapshot() map[string]interface{} {
+	return h.store.State()
+}
+
+// Hydratable adds hydration markers to the outermost element of a component's
+// rendered HTML: its ID, and its props and state as JSON. The client runtime
+// reads them back with _gouix.component(id) instead of asking the server, and
+// because they are part of the render, state changes keep them up to date.
+// Values that cannot be encoded as JSON, such as event handler funcs, are left
+// out.
+func Hydratable(component Component, markup string) string {
+	var attrs strings.Builder
+	fmt.Fprintf(&attrs, ` data-gouix-component="%s"`, html.EscapeString(string(component.GetID())))
+	if props := encodeValues(component.GetProps()); props != "" {
+		fmt.Fprintf(&attrs, ` data-gouix-props="%s"`, html.EscapeString(props))
+	}
+	if snapshotter, ok := component.(StateSnapshotter); ok {
+		if state := encodeValues(snapshotter.StateSnapshot()); state != "" {
+			fmt.Fprintf(&attrs, ` data-gouix-state="%s"`, html.EscapeString(state))
+		}
+	}
+
+	return addAttributes(markup, attrs.String())
+}
+
+// addAttributes inserts attributes, with a leading space, into the first start
+// tag of markup
+func addAttributes(markup, attrs string) string {
+	start := strings.IndexByte(markup, '<')
+	if start < 0 || start+1 >= len(markup) || !isTagStart(markup[start+1]) {
+		return markup
+	}
+	end := start + 1
+	for end < len(markup) && !isSpace(markup[end]) && markup[end] != '>' && markup[end] != '/' {
+		end++
+	}
+
+	return markup[:end] + attrs + markup[end:]
+}
+
+// encodeValues encodes the JSON-compatible entries of a map, or returns "" if
+// there are none
+func encodeValues(values map[string]interface{}) string {
+	encodable := make(map[string]json.RawMessage, len(values))
+	for key, value := range values {
+		if data, err := json.Marshal(value); err == nil {
+			encodable[key] = data
+		}
+	}
+	if len(encodable) == 0 {
+		return ""
+	}
+
+	data, err := json.Marshal(encodable)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// contentVersion identifies rendered content, so a client can tell the server
+// which render its DOM shows
+func contentVersion(content string) string {
+	hash := fnv.New64a()
+	hash.Write([]byte(content))
+	return fmt.Sprintf("%016x", hash.Sum64())
+}
+
+// HydrateRuntime is the client-side script that hydrates server-rendered
+// roots. _gouix.hydrate(endpoint) binds the events declared with
+// data-gouix-on and connects to the LiveHub, which keeps the server-rendered
+// DOM when it is still current instead of replacing it. Components marked
+// with Hydratable are read with _gouix.component(id). It requires
+// PatchRuntime and LiveRuntime.
+//
+// data-gouix-on lists "domEvent:componentEvent" pairs, such as
+// "click:increment input:setName". The event goes to the component named by
+// data-gouix-target, or else the nearest enclosing data-gouix-component, with
+// the element's value and checked state, or a form's values on submit.
+const HydrateRuntime = `(function() {
+  var g = window._gouix = window._gouix || {};
+  var bound = {};
+
+  function parse(el, name) {
+    var value = el.getAttribute(name);
+    if (!value) return {};
+    try { return JSON.parse(value); } catch (e) { return {}; }
+  }
+
+  function find(id) {
+    var all = document.querySelectorAll('[data-gouix-component]');
+    for (var i = 0; i < all.length; i++) {
+      if (all[i].getAttribute('data-gouix-component') === id) return all[i];
+    }
+    return null;
+  }
+
+  g.component = function(id) {
+    var el = find(id);
+    if (!el) return null;
+    return {id: id, element: el, props: parse(el, 'data-gouix-props'), state: parse(el, 'data-gouix-state')};
+  };
+
+  function binding(el, type) {
+    var pairs = (el.getAttribute('data-gouix-on') || '').split(/\s+/);
+    for (var i = 0; i < pairs.length; i++) {
+      var pair = pairs[i].split(':');
+      if (pair.length === 2 && pair[0] === type) return pair[1];
+    }
+    return null;
+  }
+
+  function eventData(el, e) {
+    var data = {};
+    if (e.type === 'submit' && el.elements) {
+      e.preventDefault();
+      for (var i = 0; i < el.elements.length; i++) {
+        var field = el.elements[i];
+        if (!field.name || ((field.type === 'checkbox' || field.type === 'radio') && !field.checked)) continue;
+        data[field.name] = field.value;
+      }
+      return data;
+    }
+    if ('value' in el) data.value = el.value;
+    if (el.type === 'checkbox' || el.type === 'radio') data.checked = el.checked;
+    return data;
+  }
+
+  function handle(e) {
+    for (var el = e.target; el && el.getAttribute; el = el.parentNode) {
+      var name = binding(el, e.type);
+      if (!name) continue;
+      var owner = el.closest('[data-gouix-component]');
+      var target = el.getAttribute('data-gouix-target') || (owner && owner.getAttribute('data-gouix-component'));
+      if (target) g.dispatchEvent(target, name, eventData(el, e));
+      return;
+    }
+  }
+
+  function bind(scope) {
+    var els = scope.querySelectorAll('[data-gouix-on]');
+    for (var i = 0; i < els.length; i++) {
+      (els[i].getAttribute('data-gouix-on') || '').split(/\s+/).forEach(function(pair) {
+        var type = pair.split(':')[0];
+        if (!type || bound[type]) return;
+        bound[type] = true;
+        // Capture, as focus and blur do not bubble
+        document.addEventListener(type, handle, true);
+      });
+    }
+  }
+
+  var apply = g.applyPatches;
+  g.applyPatches = function(set) {
+    var applied = apply(set);
+    var root = document.querySelector('[data-gouix-root="' + set.root + '"]');
+    if (applied && root) bind(root);
+    return applied;
+  };
+
+  g.hydrate = function(endpoint) {
+    var roots = document.querySelectorAll('[data-gouix-root]');
+    for (var i = 0; i < roots.length; i++) {
+      bind(roots[i]);
+      roots[i].setAttribute('data-gouix-hydrated', '');
+    }
+    document.dispatchEvent(new CustomEvent('gouix:hydrate'));
+    if (endpoint) g.connect(endpoint);
+  };
+})();`
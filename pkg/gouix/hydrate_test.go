@@ -0,0 +1,121 @@
+package gouix
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// hydratedCounter renders its markup with hydration markers
+type hydratedCounter struct {
+	patchCounter
+}
+
+func (c *hydratedCounter) Render() string {
+	return Hydratable(c, c.patchCounter.Render())
+}
+
+func newHydratedCounter(count int) *hydratedCounter {
+	counter := &hydratedCounter{patchCounter{HyperComponent: HyperComponent{store: NewStore(map[string]interface{}{"count": count})}}}
+	counter.init("counter", Props{"label": "Clicks", "onchange": func() {}})
+	return counter
+}
+
+func TestHydratableMarksComponent(t *testing.T) {
+	counter := newHydratedCounter(3)
+
+	nodes, err := ParseHTML(counter.Render())
+	if err != nil {
+		t.Fatalf("ParseHTML: %v", err)
+	}
+	element := nodes[0]
+	if id, _ := element.Attr("id"); id != "counter" {
+		t.Fatalf("expected the markers on the outermost element, got %s", element.HTML())
+	}
+	if id, _ := element.Attr("data-gouix-component"); id != "counter" {
+		t.Errorf("expected the component ID marker, got %q", id)
+	}
+
+	var props, state map[string]interface{}
+	value, _ := element.Attr("data-gouix-props")
+	if err := json.Unmarshal([]byte(value), &props); err != nil {
+		t.Fatalf("decode props %q: %v", value, err)
+	}
+	if len(props) != 1 || props["label"] != "Clicks" {
+		t.Errorf("expected only the encodable props, got %v", props)
+	}
+	value, _ = element.Attr("data-gouix-state")
+	if err := json.Unmarshal([]byte(value), &state); err != nil {
+		t.Fatalf("decode state %q: %v", value, err)
+	}
+	if state["count"] != 3.0 {
+		t.Errorf("expected the serialized count, got %v", state)
+	}
+
+	if html := Hydratable(counter, "plain text"); html != "plain text" {
+		t.Errorf("expected markup without an element to be left alone, got %s", html)
+	}
+}
+
+func TestRootHydrateSkipsCurrentSnapshot(t *testing.T) {
+	counter := newHydratedCounter(1)
+	root := NewRoot("counter", counter)
+
+	html := root.RenderHydratable()
+	version := regexp.MustCompile(`data-gouix-version="([0-9a-f]+)"`).FindStringSubmatch(html)
+	if version == nil || version[1] != root.Version() {
+		t.Fatalf("expected the content version in %s", html)
+	}
+
+	var received []PatchSet
+	root.Hydrate(version[1], func(patchSet PatchSet) { received = append(received, patchSet) })
+	if len(received) != 0 {
+		t.Fatalf("expected no snapshot for a current client, got %+v", received)
+	}
+
+	counter.SetState("count", 2)
+	if len(received) != 1 || len(received[0].Patches) != 2 {
+		t.Fatalf("expected the count and state marker patches, got %+v", received)
+	}
+	if root.Version() == version[1] {
+		t.Errorf("expected the version to change with the content")
+	}
+
+	// A client showing an older render gets the current content
+	var stale []PatchSet
+	root.Hydrate(version[1], func(patchSet PatchSet) { stale = append(stale, patchSet) })
+	if len(stale) != 1 || !strings.Contains(stale[0].Patches[0].HTML, ">2</p>") {
+		t.Fatalf("expected a snapshot for a stale client, got %+v", stale)
+	}
+}
+
+func TestLiveHubHydrate(t *testing.T) {
+	counter := newHydratedCounter(0)
+	counter.On("increment", func(event Event) interface{} {
+		counter.SetState("count", counter.GetState("count").(int)+1)
+		return nil
+	})
+
+	hub := NewLiveHub()
+	root := hub.Mount("counter", counter)
+	root.RenderHydratable()
+
+	server := httptest.NewServer(hub)
+	defer server.Close()
+	defer hub.Close()
+
+	client := dialTestWS(t, server, "/")
+	client.send(t, liveMessage{Type: "hydrate", Versions: map[string]string{"counter": root.Version()}})
+	client.send(t, liveMessage{Type: "event", Target: "counter", Event: "increment"})
+
+	// The first message is the patch for the event, not a snapshot
+	update := client.receive(t)
+	if update.Type != "patch" || len(update.Patches) == 0 || len(update.Patches[0].Path) == 0 {
+		t.Fatalf("expected patches for the event, got %+v", update)
+	}
+	if update.Version != root.Version() {
+		t.Errorf("expected the patch to carry version %s, got %s", root.Version(), update.Version)
+	}
+}
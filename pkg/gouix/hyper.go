@@ -170,23 +170,43 @@ func (e *Effect) Dispose() {
         e.unsubscribes = nil
 }
 
-// Store represents a hyper(reactive) state store
+// Store represents a hyper(reactive) state store. A store can be owned by one
+// component or shared by many as the application's global state; see store.go
+// for slices, actions and selectors.
 type Store struct {
         state    map[string]*Signal
         computed map[string]*Computed
+        types    map[string]reflect.Type
+        reducers map[string]Reducer
         mutex    sync.RWMutex
+        
+        // Serializes Dispatch and Mutate
+        updateMutex sync.Mutex
+        
+        // Guards selector notification, batching and devtools
+        selections    map[int]*selection
+        nextSelection int
+        batchDepth    int
+        dirty         bool
+        notifying     bool
+        dispatching   bool
+        devtools      *storeDevtools
+        notifyMutex   sync.Mutex
 }
 
 // NewStore creates a new store with initial state
 func NewStore(initialState map[string]interface{}) *Store {
         store := &Store{
-                state:    make(map[string]*Signal),
-                computed: make(map[string]*Computed),
+                state:      make(map[string]*Signal),
+                computed:   make(map[string]*Computed),
+                types:      make(map[string]reflect.Type),
+                reducers:   make(map[string]Reducer),
+                selections: make(map[int]*selection),
         }
         
         // Initialize state
         for key, value := range initialState {
-                store.state[key] = NewSignal(value)
+                store.state[key] = store.newSignal(key, value)
         }
         
         return store
@@ -200,14 +220,22 @@ func (s *Store) Get(key string) *Signal {
         return s.state[key]
 }
 
-// Set updates a state value
+// Set updates a state value. It panics if key is a typed slice and value has
+// a different type; use Mutate to get an error instead.
 func (s *Store) Set(key string, value interface{}) {
+        if err := s.checkType(key, value); err != nil {
+                panic(err)
+        }
+        
         s.mutex.Lock()
         
         // Create signal if it doesn't exist
         if _, exists := s.state[key]; !exists {
-                s.state[key] = NewSignal(value)
+                s.state[key] = s.newSignal(key, value)
                 s.mutex.Unlock()
+                
+                s.record(StoreEvent{Action: "set", Changes: []StoreChange{{Key: key, Next: value}}})
+                s.changed()
                 return
         }
         
@@ -263,8 +291,10 @@ func (s *Store) Subscribe(key string, observer Observer) func() {
         if !exists {
                 // Create signal if it doesn't exist
                 s.mutex.Lock()
-                signal = NewSignal(nil)
-                s.state[key] = signal
+                if signal, exists = s.state[key]; !exists {
+                        signal = s.newSignal(key, nil)
+                        s.state[key] = signal
+                }
                 s.mutex.Unlock()
         }
         
@@ -283,22 +313,27 @@ func (s *Store) Dispose() {
         
         s.state = make(map[string]*Signal)
         s.computed = make(map[string]*Computed)
+        s.types = make(map[string]reflect.Type)
+        s.reducers = make(map[string]Reducer)
 }
 
 // HyperComponent is a component that uses hyper(reactive) state
 type HyperComponent struct {
         BaseComponent
         store *Store
+        
+        // Records state transitions in dev mode; see EnableTimeTravel
+        timeTravel *TimeTravel
 }
 
 // NewHyperComponent creates a new hyper(reactive) component
 func NewHyperComponent(id ComponentID, props Props, initialState map[string]interface{}) *HyperComponent {
-        base := NewBaseComponent(id, props)
-        
-        return &HyperComponent{
-                BaseComponent: *base,
-                store:         NewStore(initialState),
+        component := &HyperComponent{
+                store: NewStore(initialState),
         }
+        component.init(id, props)
+        
+        return component
 }
 
 // GetStore returns the component's store
@@ -313,7 +348,13 @@ func (h *HyperComponent) GetState(key string) interface{} {
 
 // SetState updates a state value
 func (h *HyperComponent) SetState(key string, value interface{}) {
+        oldValue := h.store.GetValue(key)
         h.store.Set(key, value)
+        
+        // Re-render roots showing this component
+        if !reflect.DeepEqual(oldValue, value) {
+                h.notifyStateChange()
+        }
 }
 
 // Watch subscribes to changes in a state key
@@ -323,8 +364,12 @@ func (h *HyperComponent) Watch(key string, observer Observer) func() {
 
 // Unmount cleans up the component
 func (h *HyperComponent) Unmount() {
-        h.store.Dispose()
+        // Run the unmount hooks while the state is still available
         h.BaseComponent.Unmount()
+        if travel := h.TimeTravel(); travel != nil {
+                travel.Stop()
+        }
+        h.store.Dispose()
 }
 
 // currentHookComponent is the component currently being rendered
@@ -0,0 +1,43 @@
+package gouix
+
+import (
+	"fmt"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+// ReportToJetpack reports render failures caught by error boundaries and
+// roots to Jetpack, where they are counted in the "gouix_errors" metric. It
+// returns a function that stops reporting.
+func ReportToJetpack(jp *core.Jetpack) func() {
+	return OnRenderError(func(err *RenderError) {
+		jp.ReportError(core.ErrorReport{
+			Source:    "gouix",
+			Component: string(err.Component),
+			Message:   fmt.Sprint(err.Value),
+			Stack:     err.Stack,
+		})
+	})
+}
+
+// AuditToJetpack checks a component's rendered HTML for accessibility issues
+// and shows them in the Jetpack panel, replacing the component's previous
+// audit. Call it again to refresh the panel after the component changes.
+func AuditToJetpack(jp *core.Jetpack, component Component, options A11yOptions) ([]A11yIssue, error) {
+	issues, err := CheckComponentA11y(component, options)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := make([]core.AccessibilityIssue, len(issues))
+	for i, issue := range issues {
+		reports[i] = core.AccessibilityIssue{
+			Rule:     string(issue.Rule),
+			Severity: string(issue.Severity),
+			Element:  issue.Element,
+			Message:  issue.Message,
+		}
+	}
+	jp.ReportAccessibility("gouix", string(component.GetID()), reports)
+	return issues, nil
+}
@@ -0,0 +1,551 @@
+package gouix
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Orientation is the arrow keys that move focus in a RovingFocus group
+type Orientation string
+
+const (
+	// Horizontal moves focus with the left and right arrow keys, reversed in
+	// right-to-left text
+	Horizontal Orientation = "horizontal"
+	// Vertical moves focus with the up and down arrow keys
+	Vertical Orientation = "vertical"
+	// Both moves focus with all four arrow keys, as in a toolbar wrapping
+	// over several rows
+	Both Orientation = "both"
+)
+
+// FocusTrap keeps keyboard focus within the outermost element of markup
+// while it is in the page, as a Modal does: focus moves into it when it
+// appears, Tab cycles within it, and focus returns to where it was when it
+// is removed. The name identifies the trap across patches.
+func FocusTrap(name string, markup string) string {
+	return addAttributes(markup, ` data-gouix-focus-trap="`+html.EscapeString(name)+`"`)
+}
+
+// RovingFocus makes the outermost element of markup a composite widget,
+// such as a toolbar, a tab list or a menu, that is a single stop in the tab
+// order. Its items are the elements marked with RovingItem: the arrow keys
+// of orientation move focus between them, wrapping around, Home and End move
+// to the first and last, and Tab leaves the group. Tabbing back in returns
+// to the item focused last.
+func RovingFocus(orientation Orientation, markup string) string {
+	return addAttributes(markup, ` data-gouix-roving="`+html.EscapeString(string(orientation))+`"`)
+}
+
+// RovingItem marks the outermost element of markup as an item of the
+// enclosing RovingFocus group. The active item is the one tabbing into the
+// group focuses; the runtime picks the first when none is.
+func RovingItem(active bool, markup string) string {
+	tabindex := "-1"
+	if active {
+		tabindex = "0"
+	}
+	return addAttributes(markup, ` data-gouix-roving-item tabindex="`+tabindex+`"`)
+}
+
+// dispatchJS returns an inline handler sending an event to a component
+func dispatchJS(id ComponentID, event, data string) string {
+	encoded, _ := json.Marshal(string(id))
+	return html.EscapeString("_gouix.dispatchEvent(" + string(encoded) + ", '" + event + "', " + data + ")")
+}
+
+// Shortcut is a key combination bound in a Keymap
+type Shortcut struct {
+	// Keys is the combination, normalized as modifiers in the order mod,
+	// ctrl, alt, shift and meta, then the key, such as "mod+shift+k"
+	Keys string `json:"keys"`
+
+	// Description says what the shortcut does, for Keymap.Help
+	Description string `json:"-"`
+
+	// Target is the id of the element the shortcut activates instead of
+	// sending an event: it is clicked, or focused if it is a form field
+	Target string `json:"target,omitempty"`
+
+	handler func()
+}
+
+// modifierOrder is the order of the modifiers in a normalized combination
+var modifierOrder = []string{"mod", "ctrl", "alt", "shift", "meta"}
+
+// keyAliases are the other names accepted for modifiers and keys
+var keyAliases = map[string]string{
+	"control": "ctrl", "cmd": "meta", "command": "meta", "option": "alt",
+	"esc": "escape", "return": "enter", "del": "delete", "spacebar": "space",
+	"up": "arrowup", "down": "arrowdown", "left": "arrowleft", "right": "arrowright",
+}
+
+// namedKeys are the keys named by more than one character
+var namedKeys = wordSet(`escape enter tab space backspace delete insert arrowup arrowdown
+	arrowleft arrowright home end pageup pagedown f1 f2 f3 f4 f5 f6 f7 f8 f9 f10 f11 f12`)
+
+// normalizeKeys checks a combination such as "Ctrl+Shift+K" and returns it
+// normalized, as "ctrl+shift+k"
+func normalizeKeys(keys string) (string, error) {
+	parts := keyParts(strings.ToLower(strings.TrimSpace(keys)))
+	key := parts[len(parts)-1]
+	if alias, ok := keyAliases[key]; ok {
+		key = alias
+	}
+	if len([]rune(key)) != 1 && !namedKeys[key] {
+		return "", fmt.Errorf("gouix: shortcut %q has no key", keys)
+	}
+
+	held := make(map[string]bool)
+	for _, part := range parts[:len(parts)-1] {
+		if alias, ok := keyAliases[part]; ok {
+			part = alias
+		}
+		known := false
+		for _, modifier := range modifierOrder {
+			known = known || part == modifier
+		}
+		if !known || held[part] {
+			return "", fmt.Errorf("gouix: shortcut %q has an unknown or repeated modifier %q", keys, part)
+		}
+		held[part] = true
+	}
+
+	var normalized []string
+	for _, modifier := range modifierOrder {
+		if held[modifier] {
+			normalized = append(normalized, modifier)
+		}
+	}
+	return strings.Join(append(normalized, key), "+"), nil
+}
+
+// keyParts splits a combination at its plus signs; "ctrl++" binds the plus
+// key
+func keyParts(keys string) []string {
+	if keys == "+" {
+		return []string{"+"}
+	}
+	if strings.HasSuffix(keys, "++") {
+		return append(strings.Split(strings.TrimSuffix(keys, "++"), "+"), "+")
+	}
+	return strings.Split(keys, "+")
+}
+
+// Keymap binds keyboard shortcuts to handlers on the server, or to elements
+// of the page. Render it in a root mounted on the live hub, or mount it
+// itself; a keymap rendered inside a focus trap, such as a Modal, is only
+// active while the trap is the topmost one, and the others are inactive
+// meanwhile. Shortcuts without ctrl, alt, meta or mod do not fire while the
+// user types in a text field.
+//
+//	keys := gouix.NewKeymap("keys")
+//	keys.Bind("mod+k", "Search", func() { palette.Open() })
+//	keys.BindTarget("n", "New document", "new-document")
+type Keymap struct {
+	BaseComponent
+
+	shortcuts []Shortcut
+	mutex     sync.RWMutex
+}
+
+// NewKeymap creates an empty keymap
+func NewKeymap(id ComponentID) *Keymap {
+	keymap := &Keymap{}
+	keymap.init(id, nil)
+
+	keymap.On("shortcut", func(event Event) interface{} {
+		keys, _ := event.Data["keys"].(string)
+		keymap.Trigger(keys)
+		return nil
+	})
+
+	return keymap
+}
+
+// Bind calls handler when the keys are pressed, such as "mod+k", where mod
+// is Cmd on Apple devices and Ctrl elsewhere, "shift+arrowup" or "?".
+// Characters typed with Shift, such as "?", match whether or not the
+// combination lists Shift. Binding keys again replaces their shortcut.
+func (k *Keymap) Bind(keys, description string, handler func()) error {
+	return k.bind(Shortcut{Keys: keys, Description: description, handler: handler})
+}
+
+// BindTarget makes the keys click the element with the id target, or focus
+// it if it is a form field, without a round trip to the server. The runtime
+// announces the shortcut on the element with aria-keyshortcuts.
+func (k *Keymap) BindTarget(keys, description, target string) error {
+	return k.bind(Shortcut{Keys: keys, Description: description, Target: target})
+}
+
+func (k *Keymap) bind(shortcut Shortcut) error {
+	keys, err := normalizeKeys(shortcut.Keys)
+	if err != nil {
+		return err
+	}
+	shortcut.Keys = keys
+
+	k.mutex.Lock()
+	replaced := false
+	for i := range k.shortcuts {
+		if k.shortcuts[i].Keys == keys {
+			k.shortcuts[i] = shortcut
+			replaced = true
+		}
+	}
+	if !replaced {
+		k.shortcuts = append(k.shortcuts, shortcut)
+	}
+	k.mutex.Unlock()
+
+	k.notifyStateChange()
+	return nil
+}
+
+// Unbind removes the shortcut of the keys and reports whether there was one
+func (k *Keymap) Unbind(keys string) bool {
+	normalized, err := normalizeKeys(keys)
+	if err != nil {
+		return false
+	}
+
+	k.mutex.Lock()
+	found := false
+	for i, shortcut := range k.shortcuts {
+		if shortcut.Keys == normalized {
+			k.shortcuts = append(k.shortcuts[:i:i], k.shortcuts[i+1:]...)
+			found = true
+			break
+		}
+	}
+	k.mutex.Unlock()
+
+	if found {
+		k.notifyStateChange()
+	}
+	return found
+}
+
+// Shortcuts returns the bound shortcuts in the order they were bound
+func (k *Keymap) Shortcuts() []Shortcut {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	return append([]Shortcut(nil), k.shortcuts...)
+}
+
+// Trigger runs the handler bound to the keys, as the runtime does when they
+// are pressed, and reports whether there was one
+func (k *Keymap) Trigger(keys string) bool {
+	normalized, err := normalizeKeys(keys)
+	if err != nil {
+		return false
+	}
+	for _, shortcut := range k.Shortcuts() {
+		if shortcut.Keys == normalized && shortcut.handler != nil {
+			shortcut.handler()
+			return true
+		}
+	}
+	return false
+}
+
+// Help renders the shortcuts and their descriptions as a list, for a
+// keyboard help dialog. The runtime shows mod as ⌘ on Apple devices.
+func (k *Keymap) Help() string {
+	shortcuts := k.Shortcuts()
+	sort.SliceStable(shortcuts, func(i, j int) bool {
+		return shortcuts[i].Description < shortcuts[j].Description
+	})
+
+	items := make([]interface{}, 0, 2*len(shortcuts))
+	for _, shortcut := range shortcuts {
+		var keys []string
+		for _, key := range keyParts(shortcut.Keys) {
+			props := Props{}
+			if key == "mod" {
+				props["data-gouix-mod"] = true
+			}
+			keys = append(keys, CreateElement("kbd", props, html.EscapeString(keyLabel(key))))
+		}
+		items = append(items,
+			CreateElement("dt", nil, strings.Join(keys, " + ")),
+			CreateElement("dd", nil, html.EscapeString(shortcut.Description)),
+		)
+	}
+	return CreateElement("dl", Props{"class": "gouix-shortcuts"}, items...)
+}
+
+// keyLabel is how a key of a normalized combination is shown
+func keyLabel(key string) string {
+	switch key {
+	case "mod", "ctrl":
+		return "Ctrl"
+	case "meta":
+		return "Meta"
+	case "arrowup":
+		return "↑"
+	case "arrowdown":
+		return "↓"
+	case "arrowleft":
+		return "←"
+	case "arrowright":
+		return "→"
+	case "pageup":
+		return "Page Up"
+	case "pagedown":
+		return "Page Down"
+	}
+	if len(key) == 1 {
+		return strings.ToUpper(key)
+	}
+	return strings.ToUpper(key[:1]) + key[1:]
+}
+
+// Render implements the Component interface. The keymap is a hidden element
+// listing the shortcuts for the runtime.
+func (k *Keymap) Render() string {
+	shortcuts := k.Shortcuts()
+	if shortcuts == nil {
+		shortcuts = []Shortcut{}
+	}
+	data, _ := json.Marshal(shortcuts)
+	id := html.EscapeString(string(k.GetID()))
+	return CreateElement("div", Props{
+		"id":                   id,
+		"hidden":               true,
+		"data-gouix-keymap":    id,
+		"data-gouix-shortcuts": html.EscapeString(string(data)),
+	})
+}
+
+// KeyboardRuntime is the client-side script for keyboard navigation. It
+// moves focus within RovingFocus groups and clicks the focused item of those
+// marked data-gouix-roving-activate, as tab lists select the tab focused.
+// It opens, closes and focuses the menus of Dropdowns, and returns focus to
+// their button when they close. It runs the shortcuts of the active Keymaps
+// and sets aria-keyshortcuts on their targets. It requires LayerRuntime,
+// which it asks for the topmost focus trap.
+const KeyboardRuntime = `(function() {
+  var g = window._gouix = window._gouix || {};
+  var apple = /Mac|iPhone|iPad|iPod/.test(navigator.platform || navigator.userAgent);
+  var typing = 'input:not([type=checkbox]):not([type=radio]):not([type=button]):not([type=submit]),textarea,select,[contenteditable]';
+  var ariaNames = {ctrl: 'Control', alt: 'Alt', shift: 'Shift', meta: 'Meta', space: 'Space', escape: 'Escape', enter: 'Enter',
+    tab: 'Tab', backspace: 'Backspace', delete: 'Delete', insert: 'Insert', arrowup: 'ArrowUp', arrowdown: 'ArrowDown',
+    arrowleft: 'ArrowLeft', arrowright: 'ArrowRight', home: 'Home', end: 'End', pageup: 'PageUp', pagedown: 'PageDown'};
+
+  function items(group) {
+    return Array.prototype.filter.call(group.querySelectorAll('[data-gouix-roving-item]'), function(item) {
+      return item.closest('[data-gouix-roving]') === group && !item.disabled && !item.closest('[hidden]');
+    });
+  }
+
+  // rove makes item the group's tab stop, focusing and activating it if asked
+  function rove(group, item, focus) {
+    items(group).forEach(function(other) {
+      other.setAttribute('tabindex', other === item ? '0' : '-1');
+    });
+    if (!focus) return;
+    item.focus();
+    if (group.hasAttribute('data-gouix-roving-activate') && item.getAttribute('aria-disabled') !== 'true' &&
+        item.getAttribute('aria-selected') !== 'true') item.click();
+  }
+
+  function moveKey(group, key) {
+    var orientation = group.getAttribute('data-gouix-roving') || 'both';
+    var rtl = getComputedStyle(group).direction === 'rtl';
+    var next = rtl ? 'ArrowLeft' : 'ArrowRight', prev = rtl ? 'ArrowRight' : 'ArrowLeft';
+    if (key === 'Home') return 'first';
+    if (key === 'End') return 'last';
+    if (orientation !== 'vertical' && key === next) return 1;
+    if (orientation !== 'vertical' && key === prev) return -1;
+    if (orientation !== 'horizontal' && key === 'ArrowDown') return 1;
+    if (orientation !== 'horizontal' && key === 'ArrowUp') return -1;
+    return 0;
+  }
+
+  function roving(e) {
+    var item = e.target.closest && e.target.closest('[data-gouix-roving-item]');
+    var group = item && item.closest('[data-gouix-roving]');
+    if (!group) return false;
+    var list = items(group), move = moveKey(group, e.key);
+    if (!move || !list.length) return false;
+    var at = list.indexOf(item), to;
+    if (move === 'first') to = 0;
+    else if (move === 'last') to = list.length - 1;
+    else to = (at + move + list.length) % list.length;
+    e.preventDefault();
+    rove(group, list[to], true);
+    return true;
+  }
+
+  function dropdown(el) {
+    return el && el.closest && el.closest('[data-gouix-dropdown]');
+  }
+
+  function button(menu) {
+    return document.querySelector('[aria-controls="' + menu.id + '"]');
+  }
+
+  function menus(e) {
+    var root = dropdown(e.target);
+    if (!root) return false;
+    var id = root.getAttribute('data-gouix-dropdown');
+    var menu = root.querySelector('[data-gouix-menu]');
+    if (e.target.hasAttribute('aria-haspopup')) {
+      if (e.key !== 'ArrowDown' && e.key !== 'ArrowUp') return false;
+      e.preventDefault();
+      if (menu && !menu.hidden) {
+        var list = items(menu);
+        if (list.length) rove(menu, list[e.key === 'ArrowUp' ? list.length - 1 : 0], true);
+      } else g.dispatchEvent(id, 'open', {focus: e.key === 'ArrowUp' ? 'last' : 'first'});
+      return true;
+    }
+    if (!menu || menu.hidden) return false;
+    if (e.key === 'Escape') {
+      e.preventDefault();
+      var b = button(menu);
+      if (b) b.focus();
+      g.dispatchEvent(id, 'close', {});
+      return true;
+    }
+    if (e.key === 'Tab') g.dispatchEvent(id, 'close', {});
+    return false;
+  }
+
+  // keyName is the name of the key pressed as a Keymap writes it
+  function keyName(e) {
+    var key = e.key === ' ' ? 'space' : e.key.toLowerCase();
+    return key === 'esc' ? 'escape' : key;
+  }
+
+  function matches(keys, e) {
+    var parts = keys === '+' ? ['+'] : keys.replace(/\+\+$/, '+plus').split('+');
+    var key = parts.pop();
+    if (key === 'plus') key = '+';
+    if (key !== keyName(e)) return false;
+    var want = {ctrl: false, alt: false, shift: false, meta: false};
+    parts.forEach(function(m) { want[m === 'mod' ? (apple ? 'meta' : 'ctrl') : m] = true; });
+    // Shift is part of characters such as "?", so only letters and named keys check it
+    var shifted = key.length === 1 && key.toLowerCase() === key.toUpperCase();
+    return want.ctrl === e.ctrlKey && want.alt === e.altKey && want.meta === e.metaKey &&
+      (shifted || want.shift === e.shiftKey);
+  }
+
+  function active(keymap) {
+    var trap = g.focusTrap && g.focusTrap();
+    return !trap || trap.contains(keymap);
+  }
+
+  function shortcuts(e) {
+    var keymaps = document.querySelectorAll('[data-gouix-keymap]');
+    var inField = e.target.closest && e.target.closest(typing);
+    for (var i = keymaps.length - 1; i >= 0; i--) {
+      if (!active(keymaps[i])) continue;
+      var list = [];
+      try { list = JSON.parse(keymaps[i].getAttribute('data-gouix-shortcuts') || '[]'); } catch (err) {}
+      for (var j = 0; j < list.length; j++) {
+        var s = list[j];
+        if (!matches(s.keys, e)) continue;
+        if (inField && !e.ctrlKey && !e.altKey && !e.metaKey) return;
+        e.preventDefault();
+        if (s.target) {
+          var target = document.getElementById(s.target);
+          if (target && target.matches(typing)) target.focus();
+          else if (target) target.click();
+        } else g.dispatchEvent(keymaps[i].getAttribute('data-gouix-keymap'), 'shortcut', {keys: s.keys});
+        return;
+      }
+    }
+  }
+
+  function ariaKeys(keys) {
+    var parts = keys === '+' ? ['+'] : keys.replace(/\+\+$/, '+plus').split('+');
+    return parts.map(function(part) {
+      if (part === 'mod') part = apple ? 'meta' : 'ctrl';
+      if (part === 'plus') return '+';
+      return ariaNames[part] || (part.length === 1 ? part.toUpperCase() : part.charAt(0).toUpperCase() + part.slice(1));
+    }).join('+');
+  }
+
+  function sync() {
+    Array.prototype.forEach.call(document.querySelectorAll('[data-gouix-roving]'), function(group) {
+      var list = items(group);
+      if (list.length && !list.some(function(item) { return item.getAttribute('tabindex') === '0'; })) {
+        list[0].setAttribute('tabindex', '0');
+      }
+    });
+
+    Array.prototype.forEach.call(document.querySelectorAll('[data-gouix-menu]'), function(menu) {
+      var shown = !menu.hidden;
+      if (shown === !!menu._gouixShown) return;
+      menu._gouixShown = shown;
+      var list = items(menu);
+      if (shown && list.length) {
+        rove(menu, list[menu.getAttribute('data-gouix-focus') === 'last' ? list.length - 1 : 0], true);
+      } else if (!shown) {
+        var focused = document.activeElement;
+        var b = button(menu);
+        if (b && (!focused || focused === document.body || menu.contains(focused))) b.focus();
+      }
+    });
+
+    Array.prototype.forEach.call(document.querySelectorAll('[data-gouix-keymap]'), function(keymap) {
+      var list = [];
+      try { list = JSON.parse(keymap.getAttribute('data-gouix-shortcuts') || '[]'); } catch (err) {}
+      list.forEach(function(s) {
+        var target = s.target && document.getElementById(s.target);
+        if (target) target.setAttribute('aria-keyshortcuts', ariaKeys(s.keys));
+      });
+    });
+
+    if (apple) {
+      Array.prototype.forEach.call(document.querySelectorAll('kbd[data-gouix-mod]'), function(kbd) {
+        kbd.textContent = '⌘';
+      });
+    }
+  }
+
+  // Capture, so an open menu handles Escape before the layers dismiss a modal
+  document.addEventListener('keydown', function(e) {
+    if (e.defaultPrevented || e.isComposing) return;
+    if (menus(e) || roving(e)) return;
+    shortcuts(e);
+  }, true);
+
+  document.addEventListener('focusin', function(e) {
+    var item = e.target.closest && e.target.closest('[data-gouix-roving-item]');
+    var group = item && item.closest('[data-gouix-roving]');
+    if (group) rove(group, item, false);
+  });
+
+  document.addEventListener('focusout', function(e) {
+    var root = dropdown(e.target);
+    var menu = root && root.querySelector('[data-gouix-menu]');
+    if (menu && !menu.hidden && e.relatedTarget && !root.contains(e.relatedTarget)) {
+      g.dispatchEvent(root.getAttribute('data-gouix-dropdown'), 'close', {});
+    }
+  });
+
+  document.addEventListener('click', function(e) {
+    var inside = dropdown(e.target);
+    Array.prototype.forEach.call(document.querySelectorAll('[data-gouix-dropdown]'), function(root) {
+      var menu = root.querySelector('[data-gouix-menu]');
+      if (root !== inside && menu && !menu.hidden) g.dispatchEvent(root.getAttribute('data-gouix-dropdown'), 'close', {});
+    });
+  });
+
+  var apply = g.applyPatches;
+  g.applyPatches = function(set) {
+    var applied = apply(set);
+    if (applied) sync();
+    return applied;
+  };
+
+  if (document.readyState === 'loading') document.addEventListener('DOMContentLoaded', sync);
+  else sync();
+})();`
@@ -0,0 +1,98 @@
+package gouix
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeKeys(t *testing.T) {
+	for keys, want := range map[string]string{
+		"mod+k":            "mod+k",
+		"Shift+Ctrl+K":     "ctrl+shift+k",
+		"cmd+option+Up":    "alt+meta+arrowup",
+		"?":                "?",
+		"ctrl++":           "ctrl++",
+		"+":                "+",
+		" esc ":            "escape",
+		"Control+Shift+F5": "ctrl+shift+f5",
+	} {
+		if normalized, err := normalizeKeys(keys); err != nil || normalized != want {
+			t.Errorf("normalizeKeys(%q) = %q, %v; want %q", keys, normalized, err, want)
+		}
+	}
+
+	for _, keys := range []string{"", "ctrl", "ctrl+", "hyper+k", "ctrl+ctrl+k", "ctrl+enterr"} {
+		if normalized, err := normalizeKeys(keys); err == nil {
+			t.Errorf("normalizeKeys(%q) = %q, want an error", keys, normalized)
+		}
+	}
+}
+
+func TestKeymapRunsShortcutsThroughHub(t *testing.T) {
+	keymap := NewKeymap("keys")
+	searches := 0
+	if err := keymap.Bind("Mod+K", "Search", func() { searches++ }); err != nil {
+		t.Fatal(err)
+	}
+	if err := keymap.BindTarget("n", "New document", "new-document"); err != nil {
+		t.Fatal(err)
+	}
+	if err := keymap.Bind("ctrl+nothing", "Broken", func() {}); err == nil {
+		t.Fatal("expected an unknown key to be rejected")
+	}
+
+	hub := NewLiveHub()
+	root := hub.Mount("keys", keymap)
+	html := root.Render()
+	expected := `data-gouix-shortcuts="[{&#34;keys&#34;:&#34;mod+k&#34;},{&#34;keys&#34;:&#34;n&#34;,&#34;target&#34;:&#34;new-document&#34;}]" hidden id="keys"`
+	if !strings.Contains(html, expected) {
+		t.Fatalf("expected %s in %s", expected, html)
+	}
+
+	if err := hub.Dispatch(Event{Type: "shortcut", Target: "keys", Data: map[string]interface{}{"keys": "mod+k"}}); err != nil {
+		t.Fatalf("Dispatch returned error: %v", err)
+	}
+	if searches != 1 {
+		t.Fatalf("expected the handler to run once, ran %d times", searches)
+	}
+	if keymap.Trigger("n") {
+		t.Error("expected a shortcut with a target to have no handler")
+	}
+
+	help := keymap.Help()
+	for _, expected := range []string{
+		`<dt><kbd>N</kbd></dt><dd>New document</dd>`,
+		`<dt><kbd data-gouix-mod>Ctrl</kbd> + <kbd>K</kbd></dt><dd>Search</dd>`,
+	} {
+		if !strings.Contains(help, expected) {
+			t.Errorf("expected %s in %s", expected, help)
+		}
+	}
+
+	if !keymap.Unbind("mod+k") || keymap.Unbind("mod+k") || len(keymap.Shortcuts()) != 1 {
+		t.Errorf("expected the shortcut to be unbound once, got %+v", keymap.Shortcuts())
+	}
+}
+
+func TestFocusHelpersMarkElements(t *testing.T) {
+	toolbar := RovingFocus(Horizontal, CreateElement("div", Props{"role": "toolbar", "aria-label": "Format"},
+		RovingItem(true, CreateElement("button", nil, "Bold")),
+		RovingItem(false, CreateElement("button", nil, "Italic")),
+	))
+	expected := `<div data-gouix-roving="horizontal" aria-label="Format" role="toolbar"><button data-gouix-roving-item tabindex="0">Bold</button><button data-gouix-roving-item tabindex="-1">Italic</button></div>`
+	if toolbar != expected {
+		t.Fatalf("expected %s, got %s", expected, toolbar)
+	}
+
+	drawer := FocusTrap("drawer", CreateElement("aside", nil, CreateElement("button", nil, "Close")))
+	if !strings.HasPrefix(drawer, `<aside data-gouix-focus-trap="drawer">`) {
+		t.Fatalf("expected a focus trap, got %s", drawer)
+	}
+}
+
+func TestScriptTagIncludesKeyboardRuntime(t *testing.T) {
+	script := NewLiveHub().ScriptTag("/live")
+	if !strings.Contains(script, KeyboardRuntime) || strings.Index(script, KeyboardRuntime) < strings.Index(script, LayerRuntime) {
+		t.Fatal("expected the keyboard runtime after the layer runtime")
+	}
+}
@@ -0,0 +1,78 @@
+package gouix
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// clockComponent starts a "ticker" on mount and stops it on unmount
+type clockComponent struct {
+	BaseComponent
+	events []string
+}
+
+func (c *clockComponent) Render() string {
+	return fmt.Sprintf("<p>%v</p>", c.GetState("time"))
+}
+
+func newClock(id ComponentID, children ...interface{}) *clockComponent {
+	clock := &clockComponent{}
+	clock.init(id, nil, children...)
+	clock.OnMount(func() {
+		clock.events = append(clock.events, "mount")
+		// Hooks may change state; the root re-renders afterwards
+		clock.SetState("time", 1)
+	})
+	clock.OnUpdate(func() { clock.events = append(clock.events, "update") })
+	clock.OnUnmount(func() { clock.events = append(clock.events, "stop ticker") })
+	clock.OnUnmount(func() { clock.events = append(clock.events, "unsubscribe") })
+	return clock
+}
+
+func TestRootRunsLifecycleHooks(t *testing.T) {
+	child := newClock("child")
+	clock := newClock("clock", child)
+
+	root := NewRoot("clock", clock)
+	html := root.Render()
+
+	if !clock.IsMounted() || !child.IsMounted() {
+		t.Fatalf("expected the first render to mount the component and its children")
+	}
+	if strings.Contains(html, "<p>1</p>") {
+		t.Errorf("expected mount hooks to run after the first render, got %s", html)
+	}
+	if got := strings.Join(clock.events, ","); got != "mount,update" {
+		t.Errorf("expected the state set on mount to cause an update, got %s", got)
+	}
+
+	// Output that did not change does not run the update hooks
+	root.Refresh()
+	root.Render()
+	if got := strings.Join(clock.events, ","); got != "mount,update" {
+		t.Errorf("unexpected hooks %s", got)
+	}
+
+	clock.Update(Props{"format": "24h"})
+	if clock.GetProps()["format"] != "24h" {
+		t.Errorf("expected Update to replace the props")
+	}
+
+	root.Close()
+	if clock.IsMounted() || child.IsMounted() {
+		t.Errorf("expected Close to unmount the component and its children")
+	}
+	if got := strings.Join(clock.events, ","); got != "mount,update,unsubscribe,stop ticker" {
+		t.Errorf("expected unmount hooks in reverse order, got %s", got)
+	}
+	if got := strings.Join(child.events, ","); !strings.HasSuffix(got, "unsubscribe,stop ticker") {
+		t.Errorf("expected the child's unmount hooks to run, got %s", got)
+	}
+
+	// Unmounting twice does not run the hooks again
+	clock.Unmount()
+	if len(clock.events) != 4 {
+		t.Errorf("expected no hooks on a second unmount, got %v", clock.events)
+	}
+}
@@ -0,0 +1,552 @@
+package gouix
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ComponentFinder is implemented by components that own child components, so
+// browser events addressed to a child can be routed through its parent
+type ComponentFinder interface {
+	FindComponent(id ComponentID) Component
+}
+
+// liveMessage is a message of the live-update protocol, in either direction:
+//
+//	{"type":"subscribe","roots":["home"],"styles":12}
+//	{"type":"hydrate","versions":{"home":"9f86d081884c7d65"},"styles":12}
+//	{"type":"unsubscribe","roots":["home"]}
+//	{"type":"event","target":"counter-1","event":"increment","data":{}}
+//	{"type":"devtools"}
+//	{"type":"travel","target":"counter-1","position":3}
+//	{"type":"replay","target":"counter-1"}
+//	{"type":"patch","root":"home","version":"...","patches":[...],"css":"...","styles":13}
+//	{"type":"devtools","devtools":{"roots":[...],"events":[...]}}
+//	{"type":"error","message":"..."}
+type liveMessage struct {
+	Type     string                 `json:"type"`
+	Root     string                 `json:"root,omitempty"`
+	Roots    []string               `json:"roots,omitempty"`
+	Versions map[string]string      `json:"versions,omitempty"`
+	Version  string                 `json:"version,omitempty"`
+	Patches  []Patch                `json:"patches,omitempty"`
+	Target   ComponentID            `json:"target,omitempty"`
+	Event    string                 `json:"event,omitempty"`
+	Data     map[string]interface{} `json:"data,omitempty"`
+	Message  string                 `json:"message,omitempty"`
+	Devtools *DevtoolsSnapshot      `json:"devtools,omitempty"`
+
+	// Position is where in its devtools history to move a component
+	Position int `json:"position,omitempty"`
+
+	// Styles is the version of Styles the client has, and CSS the rules
+	// added since that a patch's classes may need
+	Styles int    `json:"styles,omitempty"`
+	CSS    string `json:"css,omitempty"`
+}
+
+// liveTarget is a component that receives browser events and the roots that
+// display it
+type liveTarget struct {
+	component Component
+	roots     []*Root
+}
+
+// LiveHub connects mounted roots to browsers over WebSocket. Browser events are
+// dispatched to component handlers, and the patches produced by the resulting
+// state changes are streamed to every client subscribed to the affected root.
+type LiveHub struct {
+	// CheckOrigin decides whether to accept a connection; nil allows only
+	// same-origin browsers
+	CheckOrigin func(r *http.Request) bool
+
+	// PingInterval keeps idle connections open through proxies
+	PingInterval time.Duration
+
+	roots      map[string]*Root
+	components map[ComponentID]liveTarget
+	clients    map[*liveClient]bool
+	devtools   *Devtools
+	mutex      sync.RWMutex
+
+	// Serializes event handlers
+	dispatchMutex sync.Mutex
+}
+
+// NewLiveHub creates a live-update hub
+func NewLiveHub() *LiveHub {
+	return &LiveHub{
+		PingInterval: 30 * time.Second,
+		roots:        make(map[string]*Root),
+		components:   make(map[ComponentID]liveTarget),
+		clients:      make(map[*liveClient]bool),
+	}
+}
+
+// Mount creates a root for a component and registers the component for events
+func (h *LiveHub) Mount(id string, component Component) *Root {
+	root := NewRoot(id, component)
+
+	h.mutex.Lock()
+	h.roots[root.ID] = root
+	devtools := h.devtools
+	h.mutex.Unlock()
+
+	if devtools != nil {
+		root.observeRenders(devtools.rendered)
+	}
+
+	h.Register(component, root)
+
+	return root
+}
+
+// Register makes a component reachable by browser events. After each event
+// the given roots are refreshed, which also picks up changes made without
+// SetState.
+func (h *LiveHub) Register(component Component, roots ...*Root) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	target := h.components[component.GetID()]
+	target.component = component
+	target.roots = append(target.roots, roots...)
+	h.components[component.GetID()] = target
+}
+
+// Root returns a mounted root by ID
+func (h *LiveHub) Root(id string) *Root {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	return h.roots[id]
+}
+
+// resolve finds the component an event is addressed to
+func (h *LiveHub) resolve(id ComponentID) (liveTarget, bool) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	if target, ok := h.components[id]; ok {
+		return target, true
+	}
+
+	for _, root := range h.roots {
+		if finder, ok := root.component.(ComponentFinder); ok {
+			if component := finder.FindComponent(id); component != nil {
+				return liveTarget{component: component, roots: []*Root{root}}, true
+			}
+		}
+	}
+
+	return liveTarget{}, false
+}
+
+// Dispatch delivers an event to its target component and refreshes the roots
+// displaying it
+func (h *LiveHub) Dispatch(event Event) error {
+	devtools := h.Devtools()
+	if devtools != nil {
+		devtools.dispatched(event)
+	}
+	err := h.dispatch(event)
+	if err != nil && devtools != nil {
+		devtools.dispatchFailed(event, err)
+	}
+	return err
+}
+
+// dispatch delivers an event for Dispatch
+func (h *LiveHub) dispatch(event Event) error {
+	target, ok := h.resolve(event.Target)
+	if !ok {
+		return fmt.Errorf("unknown component %q", event.Target)
+	}
+
+	h.dispatchMutex.Lock()
+	target.component.HandleEvent(event)
+	h.dispatchMutex.Unlock()
+
+	for _, root := range target.roots {
+		if _, err := root.Refresh(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ServeHTTP upgrades the request to a WebSocket and serves the live-update
+// protocol until the client disconnects
+func (h *LiveHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	checkOrigin := h.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		return
+	}
+
+	client := &liveClient{
+		hub:           h,
+		conn:          conn,
+		send:          make(chan []byte, 64),
+		done:          make(chan struct{}),
+		subscriptions: make(map[string]func()),
+	}
+
+	h.mutex.Lock()
+	h.clients[client] = true
+	h.mutex.Unlock()
+
+	go client.writeLoop(h.PingInterval)
+	client.readLoop()
+}
+
+// Close disconnects every client
+func (h *LiveHub) Close() {
+	h.mutex.RLock()
+	clients := make([]*liveClient, 0, len(h.clients))
+	for client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mutex.RUnlock()
+
+	for _, client := range clients {
+		client.close()
+	}
+}
+
+// ScriptTag returns the client runtime wired to the hub's endpoint, for
+// example "/_gouix/live". Include it once per page after the roots; it
+// hydrates them and then connects. In dev mode it also shows the devtools
+// overlay.
+func (h *LiveHub) ScriptTag(endpoint string) string {
+	encoded, _ := json.Marshal(endpoint)
+	devtools := ""
+	if h.Devtools() != nil {
+		devtools = "\n" + DevtoolsRuntime + "\n_gouix.devtools(" + string(encoded) + ");"
+	}
+	return "<script>" + PatchRuntime + "\n" + HydrateRuntime + "\n" + LiveRuntime + "\n" + RouterRuntime +
+		"\n" + DragRuntime + "\n" + TransitionRuntime + "\n" + VirtualRuntime +
+		"\n" + LayerRuntime + "\n" + KeyboardRuntime + "\n_gouix.hydrate(" + string(encoded) + ");" + devtools + "</script>"
+}
+
+// liveClient is one browser connection
+type liveClient struct {
+	hub           *LiveHub
+	conn          *wsConn
+	send          chan []byte
+	done          chan struct{}
+	closeOnce     sync.Once
+	subscriptions map[string]func()
+	devtools      func()
+	mutex         sync.Mutex
+
+	// Version of Styles the browser has
+	styles      int
+	stylesMutex sync.Mutex
+}
+
+// enqueue queues a message without blocking the caller
+func (c *liveClient) enqueue(message liveMessage) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+
+	select {
+	case <-c.done:
+	case c.send <- data:
+	default:
+		// The client cannot keep up; drop it so it reconnects and resyncs
+		go c.close()
+	}
+}
+
+// writeLoop sends queued messages and keepalive pings
+func (c *liveClient) writeLoop(pingInterval time.Duration) {
+	var ping <-chan time.Time
+	if pingInterval > 0 {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case data := <-c.send:
+			if err := c.conn.WriteText(data); err != nil {
+				c.close()
+				return
+			}
+		case <-ping:
+			if err := c.conn.Ping(); err != nil {
+				c.close()
+				return
+			}
+		}
+	}
+}
+
+// readLoop handles client messages until the connection closes
+func (c *liveClient) readLoop() {
+	defer c.close()
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var message liveMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			c.enqueue(liveMessage{Type: "error", Message: "invalid message"})
+			continue
+		}
+
+		if message.Styles > 0 {
+			c.stylesMutex.Lock()
+			c.styles = message.Styles
+			c.stylesMutex.Unlock()
+		}
+
+		switch message.Type {
+		case "subscribe":
+			for _, id := range message.Roots {
+				c.subscribe(id, "")
+			}
+		case "hydrate":
+			for id, version := range message.Versions {
+				c.subscribe(id, version)
+			}
+		case "unsubscribe":
+			for _, id := range message.Roots {
+				c.unsubscribe(id)
+			}
+		case "event":
+			event := Event{Type: message.Event, Target: message.Target, Data: message.Data, Bubbles: true}
+			if err := c.hub.Dispatch(event); err != nil {
+				c.enqueue(liveMessage{Type: "error", Message: err.Error()})
+			}
+		case "devtools":
+			c.inspect()
+		case "travel", "replay":
+			c.travel(message)
+		default:
+			c.enqueue(liveMessage{Type: "error", Message: fmt.Sprintf("unknown message type %q", message.Type)})
+		}
+	}
+}
+
+// subscribe starts streaming a root's patches. Unless the client shows the
+// given version of the content, they begin with the current content.
+func (c *liveClient) subscribe(id, version string) {
+	root := c.hub.Root(id)
+	if root == nil {
+		c.enqueue(liveMessage{Type: "error", Message: fmt.Sprintf("unknown root %q", id)})
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, subscribed := c.subscriptions[id]; subscribed {
+		return
+	}
+
+	// Listeners run while the root holds its render lock, so Version matches
+	// the patch set
+	c.subscriptions[id] = root.Hydrate(version, func(patchSet PatchSet) {
+		message := liveMessage{Type: "patch", Root: patchSet.Root, Version: root.Version(), Patches: patchSet.Patches}
+		message.CSS, message.Styles = c.newStyles()
+		c.enqueue(message)
+	})
+}
+
+// newStyles returns the rules added to Styles since the browser's version,
+// such as those of classes generated by the render being sent, and the
+// version it has with them
+func (c *liveClient) newStyles() (string, int) {
+	c.stylesMutex.Lock()
+	defer c.stylesMutex.Unlock()
+
+	version := Styles.Version()
+	if version <= c.styles {
+		return "", 0
+	}
+	css := Styles.CSSSince(c.styles)
+	c.styles = version
+	return css, version
+}
+
+// unsubscribe stops streaming a root's patches
+func (c *liveClient) unsubscribe(id string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if unsubscribe, ok := c.subscriptions[id]; ok {
+		unsubscribe()
+		delete(c.subscriptions, id)
+	}
+}
+
+// inspect streams devtools snapshots to the client, starting with the
+// current one
+func (c *liveClient) inspect() {
+	devtools := c.hub.Devtools()
+	if devtools == nil {
+		c.enqueue(liveMessage{Type: "error", Message: "devtools are not enabled"})
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.devtools != nil {
+		return
+	}
+	c.devtools = devtools.OnChange(func(snapshot DevtoolsSnapshot) {
+		c.enqueue(liveMessage{Type: "devtools", Devtools: &snapshot})
+	})
+	snapshot := devtools.Snapshot()
+	c.enqueue(liveMessage{Type: "devtools", Devtools: &snapshot})
+}
+
+// travel moves a component through its devtools history, or replays it in
+// the background
+func (c *liveClient) travel(message liveMessage) {
+	devtools := c.hub.Devtools()
+	if devtools == nil {
+		c.enqueue(liveMessage{Type: "error", Message: "devtools are not enabled"})
+		return
+	}
+
+	if message.Type == "replay" {
+		go func() {
+			if err := devtools.Replay(message.Target, 4*devtools.Interval); err != nil {
+				c.enqueue(liveMessage{Type: "error", Message: err.Error()})
+			}
+		}()
+		return
+	}
+	if err := devtools.Travel(message.Target, message.Position); err != nil {
+		c.enqueue(liveMessage{Type: "error", Message: err.Error()})
+	}
+}
+
+// close ends the connection and releases its subscriptions
+func (c *liveClient) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+
+		c.mutex.Lock()
+		for id, unsubscribe := range c.subscriptions {
+			unsubscribe()
+			delete(c.subscriptions, id)
+		}
+		if c.devtools != nil {
+			c.devtools()
+			c.devtools = nil
+		}
+		c.mutex.Unlock()
+
+		c.hub.mutex.Lock()
+		delete(c.hub.clients, c)
+		c.hub.mutex.Unlock()
+	})
+}
+
+// LiveRuntime is the client-side script that connects to a LiveHub. It
+// subscribes every [data-gouix-root] on the page but those a WebAssembly
+// Client runs, marked data-gouix-client, sends events from
+// _gouix.dispatchEvent to the server, applies the patches streamed back and
+// reconnects with backoff. Roots record the version of their content in
+// data-gouix-version, so on reconnect only roots that changed meanwhile are
+// resynced. Patches bring the rules of the Styles classes generated since
+// the page's Styles.LinkTag, which are added to the page. It requires
+// PatchRuntime.
+const LiveRuntime = `(function() {
+  var g = window._gouix = window._gouix || {};
+  var socket = null, url = null, delay = 500, queue = [], roots = {};
+  var sheet = document.querySelector('[data-scoped-version]');
+  var styles = sheet ? +sheet.getAttribute('data-scoped-version') : 0;
+
+  function send(message) {
+    if (socket && socket.readyState === 1) socket.send(JSON.stringify(message));
+    else if (queue.length < 100) queue.push(message);
+  }
+
+  function open() {
+    socket = new WebSocket(url);
+    socket.onopen = function() {
+      delay = 500;
+      // Roots run by a WebAssembly client are patched by it
+      document.querySelectorAll('[data-gouix-root]:not([data-gouix-client])').forEach(function(el) {
+        roots[el.getAttribute('data-gouix-root')] = true;
+      });
+      var ids = [], versions = {}, hydrating = false;
+      Object.keys(roots).forEach(function(id) {
+        var el = document.querySelector('[data-gouix-root="' + id + '"]');
+        var version = el && el.getAttribute('data-gouix-version');
+        if (version) { versions[id] = version; hydrating = true; }
+        else ids.push(id);
+      });
+      if (ids.length) socket.send(JSON.stringify({type: 'subscribe', roots: ids, styles: styles}));
+      if (hydrating) socket.send(JSON.stringify({type: 'hydrate', versions: versions, styles: styles}));
+      var pending = queue;
+      queue = [];
+      pending.forEach(send);
+    };
+    socket.onmessage = function(e) {
+      var message = JSON.parse(e.data);
+      if (message.css) {
+        var style = document.createElement('style');
+        style.textContent = message.css;
+        document.head.appendChild(style);
+        styles = message.styles;
+      }
+      if (message.type === 'patch' && g.applyPatches(message) && message.version) {
+        document.querySelector('[data-gouix-root="' + message.root + '"]').setAttribute('data-gouix-version', message.version);
+      }
+      else if (message.type === 'error' && window.console) console.error('gouix: ' + message.message);
+    };
+    socket.onclose = function() {
+      socket = null;
+      setTimeout(open, delay);
+      delay = Math.min(delay * 2, 10000);
+    };
+  }
+
+  g.connect = function(endpoint) {
+    var loc = window.location;
+    url = /^wss?:/.test(endpoint) ? endpoint : (loc.protocol === 'https:' ? 'wss://' : 'ws://') + loc.host + endpoint;
+    if (!socket) open();
+  };
+
+  g.subscribe = function(root) {
+    roots[root] = true;
+    send({type: 'subscribe', roots: [root]});
+  };
+
+  g.unsubscribe = function(root) {
+    delete roots[root];
+    send({type: 'unsubscribe', roots: [root]});
+  };
+
+  g.dispatchEvent = function(componentId, eventType, data) {
+    send({type: 'event', target: componentId, event: eventType, data: data || {}});
+  };
+})();`
@@ -0,0 +1,164 @@
+package gouix
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testWSClient is a minimal WebSocket client for exercising LiveHub
+type testWSClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func dialTestWS(t *testing.T, server *httptest.Server, path string) *testWSClient {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+
+	request := "GET " + path + " HTTP/1.1\r\nHost: " + strings.TrimPrefix(server.URL, "http://") + "\r\n" +
+		"Upgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Version: 13\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"
+	if _, err := conn.Write([]byte(request)); err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("handshake response: %v", err)
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", response.StatusCode)
+	}
+	if accept := response.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected Sec-WebSocket-Accept %q", accept)
+	}
+
+	return &testWSClient{conn: conn, reader: reader}
+}
+
+func (c *testWSClient) send(t *testing.T, message interface{}) {
+	payload, _ := json.Marshal(message)
+	mask := []byte{1, 2, 3, 4}
+
+	frame := []byte{0x81}
+	if len(payload) < 126 {
+		frame = append(frame, 0x80|byte(len(payload)))
+	} else {
+		frame = append(frame, 0x80|126, byte(len(payload)>>8), byte(len(payload)))
+	}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	if _, err := c.conn.Write(frame); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+}
+
+func (c *testWSClient) receive(t *testing.T) liveMessage {
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		var extended [2]byte
+		io.ReadFull(c.reader, extended[:])
+		length = int(binary.BigEndian.Uint16(extended[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		t.Fatalf("receive payload: %v", err)
+	}
+
+	var message liveMessage
+	if err := json.Unmarshal(payload, &message); err != nil {
+		t.Fatalf("decode %s: %v", payload, err)
+	}
+	return message
+}
+
+func TestLiveHubStreamsPatchesForEvents(t *testing.T) {
+	counter := &patchCounter{HyperComponent: HyperComponent{store: NewStore(map[string]interface{}{"count": 0})}}
+	counter.init("counter", nil)
+	counter.On("increment", func(event Event) interface{} {
+		counter.SetState("count", counter.GetState("count").(int)+1)
+		return nil
+	})
+
+	hub := NewLiveHub()
+	root := hub.Mount("counter", counter)
+	root.Render()
+
+	server := httptest.NewServer(hub)
+	defer server.Close()
+	defer hub.Close()
+
+	client := dialTestWS(t, server, "/")
+	client.send(t, liveMessage{Type: "subscribe", Roots: []string{"counter"}})
+
+	snapshot := client.receive(t)
+	if snapshot.Type != "patch" || len(snapshot.Patches) != 1 || len(snapshot.Patches[0].Path) != 0 {
+		t.Fatalf("expected a full snapshot, got %+v", snapshot)
+	}
+
+	client.send(t, liveMessage{Type: "event", Target: "counter", Event: "increment"})
+
+	update := client.receive(t)
+	if update.Root != "counter" || len(update.Patches) != 1 {
+		t.Fatalf("expected a single patch, got %+v", update)
+	}
+	if patch := update.Patches[0]; patch.Op != PatchSetText || patch.Value != "1" {
+		t.Fatalf("unexpected patch %+v", patch)
+	}
+
+	client.send(t, liveMessage{Type: "event", Target: "missing", Event: "increment"})
+	if message := client.receive(t); message.Type != "error" {
+		t.Fatalf("expected an error for an unknown component, got %+v", message)
+	}
+
+	// A reconnecting client resyncs from a snapshot of the current state
+	client.conn.Close()
+	counter.SetState("count", 5)
+
+	client = dialTestWS(t, server, "/")
+	client.send(t, liveMessage{Type: "subscribe", Roots: []string{"counter"}})
+	snapshot = client.receive(t)
+	if !strings.Contains(snapshot.Patches[0].HTML, ">5</p>") {
+		t.Fatalf("expected snapshot with count 5, got %s", snapshot.Patches[0].HTML)
+	}
+}
+
+func TestLiveHubRejectsCrossOrigin(t *testing.T) {
+	server := httptest.NewServer(NewLiveHub())
+	defer server.Close()
+
+	request, _ := http.NewRequest("GET", server.URL, nil)
+	request.Header.Set("Origin", "http://evil.example")
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Upgrade", "websocket")
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", response.StatusCode)
+	}
+}
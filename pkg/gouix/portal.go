@@ -0,0 +1,701 @@
+package gouix
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Layer is a document-level layer portals render into
+type Layer string
+
+const (
+	// LayerPopover holds menus and popovers
+	LayerPopover Layer = "popover"
+	// LayerModal holds dialogs, above popovers
+	LayerModal Layer = "modal"
+	// LayerToast holds notifications, above dialogs
+	LayerToast Layer = "toast"
+	// LayerTooltip holds tooltips, above everything else
+	LayerTooltip Layer = "tooltip"
+)
+
+// layerOrder lists the layers from the bottom up; each is stacked 100 above
+// the one below, starting at a z-index of 1000
+var layerOrder = []Layer{LayerPopover, LayerModal, LayerToast, LayerTooltip}
+
+// Layers renders the open portals of a page, each in its layer, so that
+// dialogs, tooltips and notifications are stacked above the page and each
+// other without depending on where their owners are rendered. Mount it on
+// the live hub and render it at the end of the body:
+//
+//	layers := gouix.NewLayers("layers")
+//	overlay := hub.Mount("layers", layers)
+//	fmt.Fprint(w, root.Render(), overlay.Render(), hub.ScriptTag("/_gouix/live"))
+//
+// Events addressed to a portal, or to a component rendered in one, reach it
+// through the mounted Layers.
+type Layers struct {
+	BaseComponent
+
+	entries  []layerEntry
+	sequence int
+	mutex    sync.RWMutex
+}
+
+// NewLayers creates the layers of a page
+func NewLayers(id ComponentID) *Layers {
+	layers := &Layers{}
+	layers.init(id, nil)
+	return layers
+}
+
+// layerEntry is a portal and the component rendering it, such as a Modal
+type layerEntry struct {
+	portal    *Portal
+	component Component
+}
+
+// add registers a portal created for these layers
+func (l *Layers) add(portal *Portal, component Component) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.entries = append(l.entries, layerEntry{portal: portal, component: component})
+}
+
+// raise returns the next opening order, which stacks a portal above those
+// opened before it in the same layer
+func (l *Layers) raise() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.sequence++
+	return l.sequence
+}
+
+// stack returns the open portals from the bottom of the stack to the top
+func (l *Layers) stack() []layerEntry {
+	l.mutex.RLock()
+	entries := append([]layerEntry(nil), l.entries...)
+	l.mutex.RUnlock()
+
+	var open []layerEntry
+	for _, entry := range entries {
+		if entry.portal.IsOpen() {
+			open = append(open, entry)
+		}
+	}
+
+	rank := make(map[Layer]int, len(layerOrder))
+	for i, layer := range layerOrder {
+		rank[layer] = i
+	}
+	sort.SliceStable(open, func(i, j int) bool {
+		a, b := open[i].portal, open[j].portal
+		if a.Layer != b.Layer {
+			return rank[a.Layer] < rank[b.Layer]
+		}
+		return a.order() < b.order()
+	})
+	return open
+}
+
+// FindComponent returns a portal, or a component rendered in an open one, so
+// the live hub can route browser events to it
+func (l *Layers) FindComponent(id ComponentID) Component {
+	l.mutex.RLock()
+	entries := append([]layerEntry(nil), l.entries...)
+	l.mutex.RUnlock()
+
+	for _, entry := range entries {
+		if entry.component.GetID() == id {
+			return entry.component
+		}
+		for _, child := range entry.portal.GetChildren() {
+			component, ok := child.(Component)
+			if !ok {
+				continue
+			}
+			if component.GetID() == id {
+				return component
+			}
+			if finder, ok := component.(ComponentFinder); ok {
+				if found := finder.FindComponent(id); found != nil {
+					return found
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Render implements the Component interface. Every layer is rendered, even
+// when empty, so opening a portal only inserts it into its layer.
+func (l *Layers) Render() string {
+	byLayer := make(map[Layer][]interface{})
+	for _, entry := range l.stack() {
+		layer := entry.portal.Layer
+		byLayer[layer] = append(byLayer[layer], entry.component.Render())
+	}
+
+	layers := make([]string, len(layerOrder))
+	for i, layer := range layerOrder {
+		layers[i] = CreateElement("div", Props{
+			"class":            "gouix-layer",
+			"data-gouix-layer": string(layer),
+			"style":            fmt.Sprintf("position:fixed;top:0;left:0;width:0;height:0;z-index:%d", 1000+100*i),
+		}, byLayer[layer]...)
+	}
+
+	return CreateElement("div", Props{
+		"id":    html.EscapeString(string(l.GetID())),
+		"class": "gouix-layers",
+	}, layers)
+}
+
+// Portal renders its children in a layer of the page rather than where its
+// owner is rendered. Owners keep a reference to open and close it; they do
+// not render it themselves.
+type Portal struct {
+	BaseComponent
+
+	// Layer is the layer the portal is rendered in
+	Layer Layer
+
+	// TrapFocus moves keyboard focus into the portal when it opens, keeps it
+	// there and makes the rest of the page inert until it closes, when focus
+	// returns to where it was
+	TrapFocus bool
+
+	// Dismissible lets the user close the portal with Escape, or with an
+	// element marked data-gouix-dismiss inside it
+	Dismissible bool
+
+	layers  *Layers
+	content func() string
+	open    bool
+	opened  int
+	onClose []func()
+	mutex   sync.RWMutex
+}
+
+// NewPortal creates a closed portal rendering children in a layer
+func NewPortal(id ComponentID, layers *Layers, layer Layer, children ...interface{}) *Portal {
+	portal := newPortal(id, layers, layer, children...)
+	portal.content = func() string {
+		return renderChildren(portal.GetChildren())
+	}
+	layers.add(portal, portal)
+	return portal
+}
+
+// newPortal creates a portal whose content the caller sets, and which the
+// caller adds to the layers
+func newPortal(id ComponentID, layers *Layers, layer Layer, children ...interface{}) *Portal {
+	portal := &Portal{Layer: layer, layers: layers}
+	portal.init(id, nil, children...)
+
+	portal.On("close", func(event Event) interface{} {
+		portal.Close()
+		return nil
+	})
+
+	return portal
+}
+
+// Open shows the portal above those already open in its layer
+func (p *Portal) Open() {
+	opened := p.layers.raise()
+
+	p.mutex.Lock()
+	p.open = true
+	p.opened = opened
+	p.mutex.Unlock()
+
+	p.layers.notifyStateChange()
+}
+
+// Close hides the portal and calls the OnClose functions if it was open
+func (p *Portal) Close() {
+	p.mutex.Lock()
+	wasOpen := p.open
+	p.open = false
+	handlers := append([]func(){}, p.onClose...)
+	p.mutex.Unlock()
+
+	if !wasOpen {
+		return
+	}
+	p.layers.notifyStateChange()
+	for _, handler := range handlers {
+		handler()
+	}
+}
+
+// IsOpen reports whether the portal is shown
+func (p *Portal) IsOpen() bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	return p.open
+}
+
+// OnClose adds a function called when the portal closes, including when the
+// user dismisses it
+func (p *Portal) OnClose(handler func()) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.onClose = append(p.onClose, handler)
+}
+
+// Refresh renders the layers again after the portal's content changed
+func (p *Portal) Refresh() {
+	p.layers.notifyStateChange()
+}
+
+func (p *Portal) order() int {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	return p.opened
+}
+
+// Render implements the Component interface. It renders the portal's element
+// for its Layers.
+func (p *Portal) Render() string {
+	id := html.EscapeString(string(p.GetID()))
+	props := Props{
+		"id":                id,
+		"class":             "gouix-portal",
+		"data-gouix-portal": id,
+	}
+	if p.TrapFocus {
+		props["data-gouix-trap"] = true
+	}
+	if p.Dismissible {
+		props["data-gouix-dismissible"] = true
+	}
+	return CreateElement("div", props, p.content())
+}
+
+// Modal is a dialog in the modal layer. It traps focus while open, and the
+// user can dismiss it with Escape, the close button or the backdrop unless
+// Dismissible is turned off.
+type Modal struct {
+	*Portal
+
+	// Title labels the dialog
+	Title string
+}
+
+// NewModal creates a closed modal dialog with a title and children
+func NewModal(id ComponentID, layers *Layers, title string, children ...interface{}) *Modal {
+	modal := &Modal{Portal: newPortal(id, layers, LayerModal, children...), Title: title}
+	modal.TrapFocus = true
+	modal.Dismissible = true
+	modal.content = modal.renderDialog
+	layers.add(modal.Portal, modal)
+	return modal
+}
+
+// renderDialog renders the backdrop and the dialog
+func (m *Modal) renderDialog() string {
+	id := html.EscapeString(string(m.GetID()))
+
+	var backdrop, closeButton string
+	if m.Dismissible {
+		backdrop = CreateElement("div", Props{
+			"class":              "gouix-modal-backdrop",
+			"data-gouix-dismiss": true,
+			"style":              "position:fixed;top:0;right:0;bottom:0;left:0;background:rgba(0,0,0,0.5)",
+		})
+		closeButton = CreateElement("button", Props{
+			"type":               "button",
+			"class":              "gouix-modal-close",
+			"aria-label":         "Close",
+			"data-gouix-dismiss": true,
+		}, "&times;")
+	} else {
+		backdrop = CreateElement("div", Props{
+			"class": "gouix-modal-backdrop",
+			"style": "position:fixed;top:0;right:0;bottom:0;left:0;background:rgba(0,0,0,0.5)",
+		})
+	}
+
+	return backdrop + CreateElement("div", Props{
+		"class":           "gouix-modal",
+		"role":            "dialog",
+		"aria-modal":      "true",
+		"aria-labelledby": id + "-title",
+		"tabindex":        "-1",
+		"style":           "position:fixed;top:50%;left:50%;transform:translate(-50%,-50%);max-width:calc(100vw - 32px);max-height:calc(100vh - 32px);overflow:auto",
+	},
+		CreateElement("h2", Props{"id": id + "-title", "class": "gouix-modal-title"}, html.EscapeString(m.Title)),
+		closeButton,
+		renderChildren(m.GetChildren()),
+	)
+}
+
+// Tooltip describes an element of the page, shown next to it in the tooltip
+// layer while the element is hovered or focused. The runtime links the two
+// with aria-describedby and hides the tooltip on Escape.
+type Tooltip struct {
+	*Portal
+
+	// Placement is "top" or "bottom"; the runtime flips it when there is no
+	// room
+	Placement string
+
+	anchor string
+	text   string
+}
+
+// NewTooltip creates a tooltip for the element with the id anchor
+func NewTooltip(id ComponentID, layers *Layers, anchor, text string) *Tooltip {
+	tooltip := &Tooltip{Portal: newPortal(id, layers, LayerTooltip), Placement: "top", anchor: anchor, text: text}
+	tooltip.content = func() string {
+		return html.EscapeString(tooltip.Text())
+	}
+	layers.add(tooltip.Portal, tooltip)
+	tooltip.Open()
+	return tooltip
+}
+
+// Text returns the tooltip's text
+func (t *Tooltip) Text() string {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.text
+}
+
+// SetText changes the tooltip's text
+func (t *Tooltip) SetText(text string) {
+	t.mutex.Lock()
+	t.text = text
+	t.mutex.Unlock()
+
+	t.Refresh()
+}
+
+// Render implements the Component interface
+func (t *Tooltip) Render() string {
+	id := html.EscapeString(string(t.GetID()))
+	return CreateElement("div", Props{
+		"id":                   id,
+		"class":                "gouix-portal gouix-tooltip",
+		"role":                 "tooltip",
+		"data-gouix-portal":    id,
+		"data-gouix-anchor":    html.EscapeString(t.anchor),
+		"data-gouix-placement": html.EscapeString(t.Placement),
+		"hidden":               true,
+		"style":                "position:fixed;pointer-events:none",
+	}, t.content())
+}
+
+// Toast is a notification shown by a Toaster
+type Toast struct {
+	Key     string
+	Message string
+
+	// Kind is "info", "success", "warning" or "error"; errors are announced
+	// immediately rather than politely
+	Kind string
+}
+
+// Toaster shows toasts in the toast layer, newest last. Each toast is
+// dismissed after Duration or when the user closes it.
+type Toaster struct {
+	*Portal
+
+	// Duration is how long a toast is shown, 5 seconds by default; 0 keeps
+	// toasts until they are dismissed
+	Duration time.Duration
+
+	toasts []Toast
+	next   int
+}
+
+// NewToaster creates the toaster of a page
+func NewToaster(id ComponentID, layers *Layers) *Toaster {
+	toaster := &Toaster{Portal: newPortal(id, layers, LayerToast), Duration: 5 * time.Second}
+	toaster.content = toaster.renderToasts
+
+	toaster.On("dismiss", func(event Event) interface{} {
+		key, _ := event.Data["key"].(string)
+		toaster.Dismiss(key)
+		return nil
+	})
+
+	layers.add(toaster.Portal, toaster)
+	toaster.Open()
+	return toaster
+}
+
+// Show adds a toast and returns its key
+func (t *Toaster) Show(message, kind string) string {
+	if kind == "" {
+		kind = "info"
+	}
+
+	t.mutex.Lock()
+	t.next++
+	key := string(t.GetID()) + "-" + strconv.Itoa(t.next)
+	t.toasts = append(t.toasts, Toast{Key: key, Message: message, Kind: kind})
+	duration := t.Duration
+	t.mutex.Unlock()
+
+	if duration > 0 {
+		time.AfterFunc(duration, func() { t.Dismiss(key) })
+	}
+	t.Refresh()
+	return key
+}
+
+// Dismiss removes a toast and reports whether it was shown
+func (t *Toaster) Dismiss(key string) bool {
+	t.mutex.Lock()
+	found := false
+	for i, toast := range t.toasts {
+		if toast.Key == key {
+			t.toasts = append(t.toasts[:i:i], t.toasts[i+1:]...)
+			found = true
+			break
+		}
+	}
+	t.mutex.Unlock()
+
+	if found {
+		t.Refresh()
+	}
+	return found
+}
+
+// Toasts returns the toasts shown
+func (t *Toaster) Toasts() []Toast {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return append([]Toast(nil), t.toasts...)
+}
+
+// renderToasts renders the toasts in a live region, animated in and out
+func (t *Toaster) renderToasts() string {
+	toasts := t.Toasts()
+	items := make([]interface{}, len(toasts))
+	for i, toast := range toasts {
+		props := Props{
+			"class":    "gouix-toast gouix-toast-" + html.EscapeString(toast.Kind),
+			"data-key": html.EscapeString(toast.Key),
+		}
+		if toast.Kind == "error" {
+			props["role"] = "alert"
+		}
+		items[i] = CreateElement("div", props,
+			CreateElement("span", nil, html.EscapeString(toast.Message)),
+			CreateElement("button", Props{
+				"type":               "button",
+				"aria-label":         "Dismiss",
+				"data-gouix-dismiss": html.EscapeString(toast.Key),
+			}, "&times;"),
+		)
+	}
+
+	return CreateElement("div", Props{
+		"class":      "gouix-toasts",
+		"role":       "region",
+		"aria-label": "Notifications",
+		"aria-live":  "polite",
+		"style":      "position:fixed;right:16px;bottom:16px;display:flex;flex-direction:column;gap:8px",
+	}, AnimatePresence(Props{"name": "slide", "move": true}, items...))
+}
+
+// LayerRuntime is the client-side script for portals. When a portal with
+// data-gouix-trap, or an element marked with FocusTrap, appears it remembers
+// the focused element, focuses the first focusable element inside and makes
+// the other roots inert; Tab cycles within the topmost one, and focus
+// returns when it is removed. _gouix.focusTrap() returns the topmost one. Escape, or a
+// click on data-gouix-dismiss, sends "close" to the topmost dismissible
+// portal, or "dismiss" with the attribute's value as the key. Tooltips are
+// shown next to their anchor while it is hovered or focused.
+const LayerRuntime = `(function() {
+  var g = window._gouix = window._gouix || {};
+  var traps = [], tooltip = null;
+  var focusable = 'a[href],area[href],button:not([disabled]),input:not([disabled]):not([type=hidden]),' +
+    'select:not([disabled]),textarea:not([disabled]),iframe,[contenteditable],[tabindex]:not([tabindex="-1"])';
+
+  function portal(id) {
+    return document.querySelector('[data-gouix-portal="' + id + '"]');
+  }
+
+  // trapped finds a trap by key: a portal's id, or "trap:" and a FocusTrap's name
+  function trapped(key) {
+    if (key.indexOf('trap:') !== 0) return portal(key);
+    return document.querySelector('[data-gouix-focus-trap="' + key.slice(5) + '"]');
+  }
+
+  function focusables(el) {
+    return Array.prototype.filter.call(el.querySelectorAll(focusable), function(f) {
+      return !f.closest('[hidden]') && f.getClientRects().length > 0;
+    });
+  }
+
+  function focusInto(el) {
+    var target = el.querySelector('[autofocus]') || focusables(el)[0] || el.querySelector('[tabindex="-1"]') || el;
+    if (!target.hasAttribute('tabindex') && !target.matches(focusable)) target.setAttribute('tabindex', '-1');
+    target.focus();
+  }
+
+  function top() {
+    return traps.length ? trapped(traps[traps.length - 1].id) : null;
+  }
+  g.focusTrap = top;
+
+  // inert hides the roots behind the topmost trap from keyboards and screen readers
+  function inert() {
+    var trap = top();
+    Array.prototype.forEach.call(document.querySelectorAll('[data-gouix-root]'), function(root) {
+      var blocked = !!trap && !root.contains(trap);
+      if (blocked === !!root._gouixInert) return;
+      root._gouixInert = blocked;
+      if (blocked) {
+        root.setAttribute('inert', '');
+        root.setAttribute('aria-hidden', 'true');
+      } else {
+        root.removeAttribute('inert');
+        root.removeAttribute('aria-hidden');
+      }
+    });
+  }
+
+  function sync() {
+    var open = Array.prototype.map.call(document.querySelectorAll('[data-gouix-portal][data-gouix-trap],[data-gouix-focus-trap]'), function(el) {
+      return el.hasAttribute('data-gouix-focus-trap') ? 'trap:' + el.getAttribute('data-gouix-focus-trap') : el.getAttribute('data-gouix-portal');
+    });
+    for (var i = traps.length - 1; i >= 0; i--) {
+      if (open.indexOf(traps[i].id) >= 0) continue;
+      var closed = traps.splice(i, 1)[0];
+      if (i === traps.length && closed.restore && closed.restore.isConnected) closed.restore.focus();
+    }
+    open.forEach(function(id) {
+      if (traps.some(function(trap) { return trap.id === id; })) return;
+      traps.push({id: id, restore: document.activeElement});
+      focusInto(trapped(id));
+    });
+    inert();
+
+    Array.prototype.forEach.call(document.querySelectorAll('[data-gouix-anchor]'), function(tip) {
+      var anchor = document.getElementById(tip.getAttribute('data-gouix-anchor'));
+      if (!anchor) return;
+      var described = (anchor.getAttribute('aria-describedby') || '').split(/\s+/);
+      if (described.indexOf(tip.id) < 0) anchor.setAttribute('aria-describedby', described.concat(tip.id).join(' ').trim());
+    });
+  }
+
+  function show(tip, anchor) {
+    tooltip = tip;
+    tip.hidden = false;
+    var a = anchor.getBoundingClientRect(), t = tip.getBoundingClientRect(), gap = 8;
+    var y = a.top - t.height - gap;
+    if (tip.getAttribute('data-gouix-placement') === 'bottom' || y < 0) y = a.bottom + gap;
+    if (y + t.height > window.innerHeight) y = Math.max(0, a.top - t.height - gap);
+    var x = Math.min(Math.max(4, a.left + a.width / 2 - t.width / 2), window.innerWidth - t.width - 4);
+    tip.style.left = x + 'px';
+    tip.style.top = y + 'px';
+  }
+
+  function hide() {
+    if (tooltip) tooltip.hidden = true;
+    tooltip = null;
+  }
+
+  function tipFor(el) {
+    for (; el && el.getAttribute; el = el.parentNode) {
+      if (!el.id) continue;
+      var id = window.CSS && CSS.escape ? CSS.escape(el.id) : el.id;
+      var tip = document.querySelector('[data-gouix-anchor="' + id + '"]');
+      if (tip) return {tip: tip, anchor: el};
+    }
+    return null;
+  }
+
+  function enter(e) {
+    var found = tipFor(e.target);
+    if (found) show(found.tip, found.anchor);
+  }
+
+  function leave(e) {
+    if (tooltip && !(e.relatedTarget && tipFor(e.relatedTarget))) hide();
+  }
+
+  function dismiss(el, key) {
+    var id = el.getAttribute('data-gouix-portal');
+    if (!g.dispatchEvent) return;
+    if (key) g.dispatchEvent(id, 'dismiss', {key: key});
+    else if (el.hasAttribute('data-gouix-dismissible')) g.dispatchEvent(id, 'close', {});
+  }
+
+  document.addEventListener('pointerover', enter);
+  document.addEventListener('pointerout', leave);
+  document.addEventListener('focusin', function(e) {
+    var trap = top();
+    if (trap && !trap.contains(e.target)) {
+      focusInto(trap);
+      return;
+    }
+    enter(e);
+  });
+  document.addEventListener('focusout', leave);
+
+  document.addEventListener('click', function(e) {
+    var button = e.target.closest && e.target.closest('[data-gouix-dismiss]');
+    var el = button && button.closest('[data-gouix-portal]');
+    if (el) dismiss(el, button.getAttribute('data-gouix-dismiss'));
+  });
+
+  document.addEventListener('keydown', function(e) {
+    if (e.defaultPrevented) return;
+    if (e.key === 'Escape') {
+      if (tooltip) {
+        hide();
+        return;
+      }
+      var dismissible = document.querySelectorAll('[data-gouix-portal][data-gouix-dismissible]');
+      if (dismissible.length) {
+        e.preventDefault();
+        dismiss(dismissible[dismissible.length - 1]);
+      }
+      return;
+    }
+    var trap = top();
+    if (e.key !== 'Tab' || !trap) return;
+    var list = focusables(trap);
+    if (!list.length) {
+      e.preventDefault();
+      return;
+    }
+    var first = list[0], last = list[list.length - 1];
+    if (e.shiftKey && (document.activeElement === first || !trap.contains(document.activeElement))) {
+      e.preventDefault();
+      last.focus();
+    } else if (!e.shiftKey && document.activeElement === last) {
+      e.preventDefault();
+      first.focus();
+    }
+  });
+
+  var apply = g.applyPatches;
+  g.applyPatches = function(set) {
+    var applied = apply(set);
+    if (applied) sync();
+    return applied;
+  };
+
+  if (document.readyState === 'loading') document.addEventListener('DOMContentLoaded', sync);
+  else sync();
+})();`
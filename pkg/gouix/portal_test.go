@@ -0,0 +1,166 @@
+package gouix
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// portalOrder returns the IDs of the rendered portals from the bottom up
+func portalOrder(t *testing.T, markup string) []string {
+	t.Helper()
+
+	nodes, err := ParseHTML(markup)
+	if err != nil {
+		t.Fatalf("parse layers: %v", err)
+	}
+	var ids []string
+	for _, layer := range nodes[0].Children {
+		for _, portal := range layer.Children {
+			if id, ok := portal.Attr("data-gouix-portal"); ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+func TestLayersStackPortals(t *testing.T) {
+	layers := NewLayers("layers")
+	confirm := NewModal("confirm", layers, "Delete file?", CreateElement("p", nil, "This cannot be undone."))
+	menu := NewPortal("menu", layers, LayerPopover, CreateElement("ul", nil, "Menu"))
+	details := NewModal("details", layers, "Details")
+
+	empty := layers.Render()
+	if portalOrder(t, empty) != nil || strings.Count(empty, `class="gouix-layer"`) != 4 {
+		t.Fatalf("expected four empty layers, got %s", empty)
+	}
+	if !strings.Contains(empty, `data-gouix-layer="modal" style="position:fixed;top:0;left:0;width:0;height:0;z-index:1100"`) {
+		t.Errorf("expected the modal layer above the popover layer, got %s", empty)
+	}
+
+	details.Open()
+	menu.Open()
+	confirm.Open()
+	if order := strings.Join(portalOrder(t, layers.Render()), ","); order != "menu,details,confirm" {
+		t.Fatalf("expected the last modal opened on top, got %s", order)
+	}
+
+	details.Open()
+	if order := strings.Join(portalOrder(t, layers.Render()), ","); order != "menu,confirm,details" {
+		t.Fatalf("expected opening again to raise the modal, got %s", order)
+	}
+}
+
+func TestModalClosesThroughHub(t *testing.T) {
+	layers := NewLayers("layers")
+	button := NewBaseComponent("ok", nil)
+	modal := NewModal("confirm", layers, "Saved", button)
+
+	closed := 0
+	modal.OnClose(func() { closed++ })
+
+	hub := NewLiveHub()
+	root := hub.Mount("layers", layers)
+	root.Render()
+	var patches []PatchSet
+	root.OnPatch(func(patchSet PatchSet) {
+		patches = append(patches, patchSet)
+	})
+
+	modal.Open()
+	if len(patches) != 1 {
+		t.Fatalf("expected opening to patch the layers, got %d patch sets", len(patches))
+	}
+	html := layers.Render()
+	for _, expected := range []string{
+		`<div class="gouix-portal" data-gouix-dismissible data-gouix-portal="confirm" data-gouix-trap id="confirm">`,
+		`<div aria-labelledby="confirm-title" aria-modal="true" class="gouix-modal" role="dialog" style=`,
+		`<h2 class="gouix-modal-title" id="confirm-title">Saved</h2>`,
+		`<button aria-label="Close" class="gouix-modal-close" data-gouix-dismiss type="button">&times;</button>`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("expected %s in %s", expected, html)
+		}
+	}
+	if issues, err := CheckA11y(html, A11yOptions{}); err != nil || len(issues) != 0 {
+		t.Errorf("expected an accessible modal, got %v, %v", issues, err)
+	}
+	if layers.FindComponent("ok") != button {
+		t.Errorf("expected to find the component in the modal")
+	}
+
+	if err := hub.Dispatch(Event{Type: "close", Target: "confirm"}); err != nil {
+		t.Fatalf("Dispatch returned error: %v", err)
+	}
+	modal.Close() // already closed, so OnClose is not called again
+	if modal.IsOpen() || closed != 1 || portalOrder(t, layers.Render()) != nil {
+		t.Errorf("expected the modal to close once, got open %v after %d closes", modal.IsOpen(), closed)
+	}
+
+	modal.Dismissible = false
+	modal.Open()
+	if html := layers.Render(); strings.Contains(html, "data-gouix-dismiss") {
+		t.Errorf("expected no way to dismiss the modal, got %s", html)
+	}
+}
+
+func TestTooltip(t *testing.T) {
+	layers := NewLayers("layers")
+	tooltip := NewTooltip("save-tip", layers, "save", "Save <draft>")
+
+	expected := `<div class="gouix-portal gouix-tooltip" data-gouix-anchor="save" data-gouix-placement="top" data-gouix-portal="save-tip" hidden id="save-tip" role="tooltip" style="position:fixed;pointer-events:none">Save &lt;draft&gt;</div>`
+	if html := layers.Render(); !strings.Contains(html, `z-index:1300">`+expected) {
+		t.Fatalf("expected %s in the tooltip layer, got %s", expected, html)
+	}
+
+	tooltip.SetText("Saved")
+	if html := layers.Render(); !strings.Contains(html, ">Saved</div>") {
+		t.Errorf("expected the new text, got %s", html)
+	}
+}
+
+func TestToaster(t *testing.T) {
+	layers := NewLayers("layers")
+	toaster := NewToaster("toasts", layers)
+	toaster.Duration = 0
+
+	first := toaster.Show("Saved", "")
+	toaster.Show("Upload failed", "error")
+
+	html := layers.Render()
+	for _, expected := range []string{
+		`<div aria-label="Notifications" aria-live="polite" class="gouix-toasts" role="region"`,
+		`<div class="gouix-toast gouix-toast-info" data-key="toasts-1"><span>Saved</span><button aria-label="Dismiss" data-gouix-dismiss="toasts-1" type="button">&times;</button></div>`,
+		`<div class="gouix-toast gouix-toast-error" data-key="toasts-2" role="alert">`,
+		`data-gouix-move data-gouix-transition="slide"`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("expected %s in %s", expected, html)
+		}
+	}
+
+	toaster.HandleEvent(Event{Type: "dismiss", Data: map[string]interface{}{"key": first}})
+	if toasts := toaster.Toasts(); len(toasts) != 1 || toasts[0].Kind != "error" {
+		t.Fatalf("expected the first toast to be dismissed, got %+v", toasts)
+	}
+	if toaster.Dismiss(first) {
+		t.Errorf("expected a dismissed toast to be gone")
+	}
+
+	toaster.Duration = 10 * time.Millisecond
+	toaster.Show("Copied", "success")
+	deadline := time.Now().Add(time.Second)
+	for len(toaster.Toasts()) > 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if toasts := toaster.Toasts(); len(toasts) != 1 {
+		t.Errorf("expected the toast to dismiss itself, got %+v", toasts)
+	}
+}
+
+func TestScriptTagIncludesLayerRuntime(t *testing.T) {
+	if !strings.Contains(NewLiveHub().ScriptTag("/live"), LayerRuntime) {
+		t.Errorf("expected the script tag to load the layer runtime")
+	}
+}
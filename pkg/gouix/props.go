@@ -0,0 +1,282 @@
+package gouix
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// PropError reports a prop that could not be bound to a props struct
+type PropError struct {
+	// Prop is the name of the prop, such as "initialCount"
+	Prop string
+
+	// Field is the struct field it binds to, such as "InitialCount"
+	Field string
+
+	Err error
+}
+
+// Error implements the error interface
+func (e *PropError) Error() string {
+	return fmt.Sprintf("gouix: prop %q (%s): %v", e.Prop, e.Field, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *PropError) Unwrap() error {
+	return e.Err
+}
+
+// propField is an exported field of a props struct and how it is bound
+type propField struct {
+	index    int
+	name     string
+	required bool
+	fallback string
+	hasValue bool
+}
+
+// propFields lists the bound fields of a props struct type
+func propFields(structType reflect.Type) []propField {
+	var fields []propField
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name, required, ok := PropName(field.Name, field.Tag)
+		if !ok {
+			continue
+		}
+		fallback, hasValue := field.Tag.Lookup("default")
+		fields = append(fields, propField{
+			index:    i,
+			name:     name,
+			required: required,
+			fallback: fallback,
+			hasValue: hasValue,
+		})
+	}
+	return fields
+}
+
+// PropName returns the prop a struct field binds to and whether it is
+// required. The name comes from the field's prop tag, or is the field name
+// starting in lower case; prop:"-" leaves the field out. Options follow the
+// name:
+//
+//	Title string `prop:"title,required"`
+//	Count int    `default:"1"`
+func PropName(field string, tag reflect.StructTag) (name string, required bool, ok bool) {
+	name, options := lowerFirst(field), ""
+	if value, found := tag.Lookup("prop"); found {
+		if value == "-" {
+			return "", false, false
+		}
+		if comma := strings.Index(value, ","); comma >= 0 {
+			value, options = value[:comma], value[comma+1:]
+		}
+		if value != "" {
+			name = value
+		}
+	}
+	return name, wordSet(strings.Replace(options, ",", " ", -1))["required"], true
+}
+
+// lowerFirst returns a field name as a prop name, InitialCount as
+// initialCount and ID as id
+func lowerFirst(name string) string {
+	runes := []rune(name)
+	for i := range runes {
+		if !unicode.IsUpper(runes[i]) {
+			break
+		}
+		// Keep the last capital of an acronym followed by a word: URLPath
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// BindProps fills the struct target points to from props. Missing or nil
+// props take the field's default tag, or keep the zero value; a missing prop
+// tagged required is an error. Values are converted to the field's type, so
+// JSON numbers bind to int fields and attribute strings to numbers, booleans
+// and durations. A *PropError reports the first prop that does not fit.
+func BindProps(props Props, target interface{}) error {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("gouix: BindProps needs a pointer to a struct, got %T", target)
+	}
+	value = value.Elem()
+
+	for _, field := range propFields(value.Type()) {
+		destination := value.Field(field.index)
+		name := value.Type().Field(field.index).Name
+
+		prop, present := props[field.name]
+		if !present || prop == nil {
+			if field.required {
+				return &PropError{Prop: field.name, Field: name, Err: fmt.Errorf("required")}
+			}
+			if !field.hasValue {
+				continue
+			}
+			if err := convertProp(field.fallback, destination); err != nil {
+				return &PropError{Prop: field.name, Field: name, Err: fmt.Errorf("default: %v", err)}
+			}
+			continue
+		}
+
+		if err := convertProp(prop, destination); err != nil {
+			return &PropError{Prop: field.name, Field: name, Err: err}
+		}
+	}
+	return nil
+}
+
+// MustBindProps is like BindProps but panics on error, for constructors whose
+// props are written in code
+func MustBindProps(props Props, target interface{}) {
+	if err := BindProps(props, target); err != nil {
+		panic(err)
+	}
+}
+
+// PropsOf returns the props of a props struct, or a pointer to one, with
+// their prop names. Fields holding their zero value are left out, so a
+// component binding the props applies its defaults to them.
+func PropsOf(source interface{}) Props {
+	value := reflect.ValueOf(source)
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return Props{}
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return Props{}
+	}
+
+	props := Props{}
+	for _, field := range propFields(value.Type()) {
+		if fieldValue := value.Field(field.index); !fieldValue.IsZero() {
+			props[field.name] = fieldValue.Interface()
+		}
+	}
+	return props
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// convertProp stores value in destination, converting it to its type
+func convertProp(value interface{}, destination reflect.Value) error {
+	source := reflect.ValueOf(value)
+	target := destination.Type()
+
+	if source.Type().AssignableTo(target) {
+		destination.Set(source)
+		return nil
+	}
+
+	if text, ok := value.(string); ok {
+		return parseProp(text, destination)
+	}
+
+	switch target.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if !isNumber(source.Kind()) {
+			break
+		}
+		converted := source.Convert(target)
+		// JSON decodes every number as float64; refuse to drop a fraction
+		// or overflow rather than bind a different number
+		if back := converted.Convert(source.Type()); back.Interface() != source.Interface() {
+			return fmt.Errorf("%v does not fit in %s", value, target)
+		}
+		destination.Set(converted)
+		return nil
+	case reflect.Slice:
+		if source.Kind() != reflect.Slice {
+			break
+		}
+		items := reflect.MakeSlice(target, source.Len(), source.Len())
+		for i := 0; i < source.Len(); i++ {
+			if err := convertProp(source.Index(i).Interface(), items.Index(i)); err != nil {
+				return fmt.Errorf("item %d: %v", i, err)
+			}
+		}
+		destination.Set(items)
+		return nil
+	}
+
+	if source.Type().ConvertibleTo(target) && source.Kind() == target.Kind() {
+		destination.Set(source.Convert(target))
+		return nil
+	}
+	return fmt.Errorf("cannot use %T as %s", value, target)
+}
+
+// parseProp stores a string, such as an attribute value, in destination
+func parseProp(text string, destination reflect.Value) error {
+	target := destination.Type()
+	if target == durationType {
+		duration, err := time.ParseDuration(text)
+		if err != nil {
+			return err
+		}
+		destination.SetInt(int64(duration))
+		return nil
+	}
+
+	switch target.Kind() {
+	case reflect.String:
+		destination.SetString(text)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		destination.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(strings.TrimSpace(text), 10, target.Bits())
+		if err != nil {
+			return err
+		}
+		destination.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(strings.TrimSpace(text), 10, target.Bits())
+		if err != nil {
+			return err
+		}
+		destination.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(text), target.Bits())
+		if err != nil || math.IsNaN(parsed) {
+			return fmt.Errorf("invalid number %q", text)
+		}
+		destination.SetFloat(parsed)
+	default:
+		return fmt.Errorf("cannot use a string as %s", target)
+	}
+	return nil
+}
+
+func isNumber(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package gouix
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type buttonProps struct {
+	Label    string `prop:"label,required"`
+	Size     int    `default:"2"`
+	Disabled bool
+	Delay    time.Duration `default:"150ms"`
+	Tags     []string
+	URLPath  string
+	Internal string `prop:"-"`
+	hidden   string
+}
+
+func TestBindProps(t *testing.T) {
+	var props buttonProps
+	err := BindProps(Props{
+		"label":    "Save",
+		"disabled": "true",
+		"tags":     []interface{}{"primary", "wide"},
+		"urlPath":  "/save",
+		"Internal": "ignored",
+	}, &props)
+	if err != nil {
+		t.Fatalf("BindProps returned error: %v", err)
+	}
+
+	expected := buttonProps{Label: "Save", Size: 2, Disabled: true, Delay: 150 * time.Millisecond, Tags: []string{"primary", "wide"}, URLPath: "/save"}
+	if !reflect.DeepEqual(props, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, props)
+	}
+
+	// JSON numbers bind to ints when they are whole
+	if err := BindProps(Props{"label": "Go", "size": float64(3)}, &props); err != nil || props.Size != 3 {
+		t.Errorf("expected size 3, got %d, %v", props.Size, err)
+	}
+
+	var propErr *PropError
+	if err := BindProps(Props{"label": "Go", "size": 2.5}, &props); !errors.As(err, &propErr) || propErr.Prop != "size" || propErr.Field != "Size" {
+		t.Errorf("expected a fraction to be refused, got %v", err)
+	}
+	if err := BindProps(Props{"size": 1}, &props); !errors.As(err, &propErr) || propErr.Prop != "label" {
+		t.Errorf("expected the missing label to be reported, got %v", err)
+	}
+	if err := BindProps(Props{"label": 7}, &props); err == nil {
+		t.Errorf("expected a number to be refused for a string")
+	}
+	if err := BindProps(Props{}, props); err == nil {
+		t.Errorf("expected an error for a struct that is not a pointer")
+	}
+}
+
+func TestPropsOf(t *testing.T) {
+	props := PropsOf(&buttonProps{Label: "Save", Tags: []string{"primary"}, Internal: "x"})
+	expected := Props{"label": "Save", "tags": []string{"primary"}}
+	if !reflect.DeepEqual(props, expected) {
+		t.Fatalf("expected %v, got %v", expected, props)
+	}
+
+	// Round trip through the map, with the defaults for the fields left out
+	var bound buttonProps
+	if err := BindProps(props, &bound); err != nil || bound.Size != 2 || bound.Label != "Save" {
+		t.Errorf("unexpected round trip %+v, %v", bound, err)
+	}
+}
+
+func TestPropName(t *testing.T) {
+	for field, expected := range map[string]string{"InitialCount": "initialCount", "ID": "id", "URLPath": "urlPath", "X": "x"} {
+		if name, _, _ := PropName(field, ""); name != expected {
+			t.Errorf("expected %s for %s, got %s", expected, field, name)
+		}
+	}
+	if name, required, ok := PropName("Title", `prop:"heading,required"`); name != "heading" || !required || !ok {
+		t.Errorf("unexpected tagged prop %s, %v, %v", name, required, ok)
+	}
+}
@@ -0,0 +1,491 @@
+package gouix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNoQueryClient is the error of a query or mutation used outside a
+// QueryProvider
+var ErrNoQueryClient = errors.New("gouix: no QueryClient provided")
+
+// APIError is a GoScaleAPI endpoint's answer to an operation it could not run
+type APIError struct {
+	Operation string
+	Status    int
+	Message   string
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	return fmt.Sprintf("gouix: %s: %d %s", e.Operation, e.Status, e.Message)
+}
+
+// APIClient sends operations to a GoScaleAPI endpoint. Operations are named
+// like the API's resolvers, such as "query:getUser" or "mutation:createUser".
+type APIClient struct {
+	// Endpoint is the URL the API is served on, such as "http://api/api"
+	Endpoint string
+
+	// Handler serves requests in process instead, such as a *api.GoScaleAPI
+	// rendering pages on the same server
+	Handler http.Handler
+
+	// HTTPClient sends requests to Endpoint; http.DefaultClient if nil
+	HTTPClient *http.Client
+
+	// Header is added to every request, such as an Authorization header
+	Header http.Header
+}
+
+// NewAPIClient creates a client for the API served at endpoint
+func NewAPIClient(endpoint string) *APIClient {
+	return &APIClient{Endpoint: endpoint, Header: make(http.Header)}
+}
+
+// NewLocalAPIClient creates a client calling an API handler in process
+func NewLocalAPIClient(handler http.Handler) *APIClient {
+	return &APIClient{Endpoint: "/", Handler: handler, Header: make(http.Header)}
+}
+
+// Do runs an operation and returns its data
+func (c *APIClient) Do(ctx context.Context, operation string, variables map[string]interface{}) (interface{}, error) {
+	name := operation
+	if colon := strings.Index(name, ":"); colon >= 0 {
+		name = name[colon+1:]
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     name,
+		"variables": variables,
+		"operation": operation,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, values := range c.Header {
+		request.Header[key] = values
+	}
+
+	status, payload, err := c.send(request)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, &APIError{Operation: operation, Status: status, Message: strings.TrimSpace(string(payload))}
+	}
+
+	var response struct {
+		Data interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &response); err != nil {
+		return nil, fmt.Errorf("gouix: %s: %v", operation, err)
+	}
+	return response.Data, nil
+}
+
+// send serves or sends a request and returns the response status and body
+func (c *APIClient) send(request *http.Request) (int, []byte, error) {
+	if c.Handler != nil {
+		response := &bufferedResponse{header: make(http.Header), status: http.StatusOK}
+		c.Handler.ServeHTTP(response, request)
+		return response.status, response.body.Bytes(), nil
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer response.Body.Close()
+
+	payload, err := ioutil.ReadAll(response.Body)
+	return response.StatusCode, payload, err
+}
+
+// bufferedResponse collects the response of a handler served in process
+type bufferedResponse struct {
+	header      http.Header
+	body        bytes.Buffer
+	status      int
+	wroteHeader bool
+}
+
+func (r *bufferedResponse) Header() http.Header {
+	return r.header
+}
+
+func (r *bufferedResponse) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+	return r.body.Write(data)
+}
+
+func (r *bufferedResponse) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+}
+
+// QueryResult is the state of a query when a component renders
+type QueryResult struct {
+	// Data is the last data fetched, kept while the query is fetched again
+	Data interface{}
+
+	// Err is the error of the last fetch
+	Err error
+
+	// Loading is true while a fetch is in flight
+	Loading bool
+}
+
+// queryFetch is one request for a query, shared by everyone asking for the
+// query while it is in flight
+type queryFetch struct {
+	done chan struct{}
+	data interface{}
+	err  error
+}
+
+// queryEntry is a cached query
+type queryEntry struct {
+	operation string
+	variables map[string]interface{}
+	data      interface{}
+	err       error
+	fetched   bool
+	fetchedAt time.Time
+	stale     bool
+	inFlight  *queryFetch
+	roots     map[*Root]bool
+}
+
+// QueryClient caches the results of API queries for a component tree. Give
+// each tree, such as each signed-in session, its own client through a
+// QueryProvider, so cached data is never shared between them.
+type QueryClient struct {
+	// StaleTime is how long a result is used before a render fetches it again
+	// in the background. Zero keeps results until they are invalidated.
+	StaleTime time.Duration
+
+	api       *APIClient
+	queries   map[string]*queryEntry
+	mutations map[string]*Mutation
+	mutex     sync.Mutex
+}
+
+// NewQueryClient creates a query cache backed by an API client
+func NewQueryClient(api *APIClient) *QueryClient {
+	return &QueryClient{
+		api:       api,
+		queries:   make(map[string]*queryEntry),
+		mutations: make(map[string]*Mutation),
+	}
+}
+
+// queryKey identifies a query by its operation and variables. JSON sorts
+// map keys, so equal variables give equal keys.
+func queryKey(operation string, variables map[string]interface{}) string {
+	encoded, err := json.Marshal(variables)
+	if err != nil {
+		encoded = []byte(fmt.Sprint(variables))
+	}
+	return operation + "\x00" + string(encoded)
+}
+
+// entry returns the cache entry of a query; the caller holds mutex
+func (c *QueryClient) entry(operation string, variables map[string]interface{}) *queryEntry {
+	key := queryKey(operation, variables)
+	entry := c.queries[key]
+	if entry == nil {
+		entry = &queryEntry{operation: operation, variables: variables, roots: make(map[*Root]bool)}
+		c.queries[key] = entry
+	}
+	return entry
+}
+
+// start fetches a query unless a fetch is in flight, and returns the fetch;
+// the caller holds mutex
+func (c *QueryClient) start(entry *queryEntry) *queryFetch {
+	if entry.inFlight != nil {
+		return entry.inFlight
+	}
+	fetch := &queryFetch{done: make(chan struct{})}
+	entry.inFlight = fetch
+	entry.stale = false
+
+	go c.run(entry, fetch)
+	return fetch
+}
+
+// run fetches a query and refreshes the roots showing it
+func (c *QueryClient) run(entry *queryEntry, fetch *queryFetch) {
+	data, err := c.api.Do(context.Background(), entry.operation, entry.variables)
+
+	c.mutex.Lock()
+	fetch.data, fetch.err = data, err
+	if err == nil {
+		entry.data = data
+	}
+	entry.err = err
+	entry.fetched = true
+	entry.fetchedAt = time.Now()
+	entry.inFlight = nil
+
+	roots := make([]*Root, 0, len(entry.roots))
+	for root := range entry.roots {
+		roots = append(roots, root)
+	}
+	// Invalidated while in flight, so the data may predate the change
+	if entry.stale && len(roots) > 0 {
+		c.start(entry)
+	}
+	c.mutex.Unlock()
+
+	// Refresh before finishing, so a stream waiting for the fetch has the
+	// patches queued when it wakes
+	for _, root := range roots {
+		root.Refresh()
+	}
+	close(fetch.done)
+}
+
+// Fetch returns the data of a query, from the cache if it was fetched
+// already. Callers asking for a query in flight share its request.
+func (c *QueryClient) Fetch(ctx context.Context, operation string, variables map[string]interface{}) (interface{}, error) {
+	c.mutex.Lock()
+	entry := c.entry(operation, variables)
+	if entry.fetched && !entry.stale && entry.inFlight == nil {
+		data, err := entry.data, entry.err
+		c.mutex.Unlock()
+		return data, err
+	}
+	fetch := c.start(entry)
+	c.mutex.Unlock()
+
+	select {
+	case <-fetch.done:
+		return fetch.data, fetch.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// use returns the state of a query for a render by root, fetching it if it is
+// missing or stale
+func (c *QueryClient) use(root *Root, operation string, variables map[string]interface{}) QueryResult {
+	c.mutex.Lock()
+	entry := c.entry(operation, variables)
+	if root != nil {
+		entry.roots[root] = true
+	}
+	switch {
+	case entry.inFlight != nil:
+	case !entry.fetched, entry.stale, c.StaleTime > 0 && time.Since(entry.fetchedAt) >= c.StaleTime:
+		c.start(entry)
+	}
+	result := QueryResult{Data: entry.data, Err: entry.err, Loading: entry.inFlight != nil}
+	fetch := entry.inFlight
+	c.mutex.Unlock()
+
+	if root != nil && fetch != nil {
+		root.suspended(fetch.done)
+	}
+	return result
+}
+
+// Invalidate marks the cached results of the given operations, or of every
+// query if none are given, as stale. Queries shown by a root are fetched
+// again, keeping their data until the new data arrives; the others are
+// dropped.
+func (c *QueryClient) Invalidate(operations ...string) {
+	match := wordSet(strings.Join(operations, " "))
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for key, entry := range c.queries {
+		if len(operations) > 0 && !match[entry.operation] {
+			continue
+		}
+		if len(entry.roots) == 0 && entry.inFlight == nil {
+			delete(c.queries, key)
+			continue
+		}
+		entry.stale = true
+		if entry.inFlight == nil {
+			c.start(entry)
+		}
+	}
+}
+
+// Mutation returns the mutation of an operation. The client keeps one per
+// operation, so every render gets the same one.
+func (c *QueryClient) Mutation(operation string) *Mutation {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	mutation := c.mutations[operation]
+	if mutation == nil {
+		mutation = &Mutation{client: c, operation: operation, roots: make(map[*Root]bool)}
+		c.mutations[operation] = mutation
+	}
+	return mutation
+}
+
+// Mutation runs an API mutation and keeps the state of its last run
+type Mutation struct {
+	// Invalidates lists the query operations fetched again after the
+	// mutation succeeds. When empty, every query of the client is.
+	Invalidates []string
+
+	client    *QueryClient
+	operation string
+	data      interface{}
+	err       error
+	pending   bool
+	roots     map[*Root]bool
+	mutex     sync.Mutex
+}
+
+// Mutate runs the mutation with variables and returns its data. The roots
+// showing the mutation are refreshed when it starts and when it settles, and
+// on success the cached queries it invalidates are fetched again.
+func (m *Mutation) Mutate(ctx context.Context, variables map[string]interface{}) (interface{}, error) {
+	if m.client == nil {
+		return nil, ErrNoQueryClient
+	}
+
+	m.mutex.Lock()
+	if m.pending {
+		m.mutex.Unlock()
+		return nil, fmt.Errorf("gouix: %s is already running", m.operation)
+	}
+	m.pending = true
+	m.mutex.Unlock()
+	m.refresh()
+
+	data, err := m.client.api.Do(ctx, m.operation, variables)
+
+	m.mutex.Lock()
+	m.pending = false
+	m.data, m.err = data, err
+	m.mutex.Unlock()
+
+	if err == nil {
+		m.client.Invalidate(m.Invalidates...)
+	}
+	m.refresh()
+	return data, err
+}
+
+// refresh re-renders the roots showing the mutation
+func (m *Mutation) refresh() {
+	m.mutex.Lock()
+	roots := make([]*Root, 0, len(m.roots))
+	for root := range m.roots {
+		roots = append(roots, root)
+	}
+	m.mutex.Unlock()
+
+	for _, root := range roots {
+		root.Refresh()
+	}
+}
+
+// Data returns the data of the last successful run
+func (m *Mutation) Data() interface{} {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.data
+}
+
+// Err returns the error of the last run
+func (m *Mutation) Err() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.err
+}
+
+// Pending reports whether the mutation is running
+func (m *Mutation) Pending() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.pending
+}
+
+// Reset forgets the result of the last run
+func (m *Mutation) Reset() {
+	m.mutex.Lock()
+	m.data, m.err = nil, nil
+	m.mutex.Unlock()
+
+	m.refresh()
+}
+
+// queryClientContext holds the QueryClient of a component tree
+var queryClientContext = CreateContext(nil)
+
+// NewQueryProvider creates a provider of client to the components it renders
+func NewQueryProvider(id ComponentID, client *QueryClient, children ...interface{}) *Provider {
+	return NewProvider(id, queryClientContext, client, children...)
+}
+
+// UseQueryClient returns the QueryClient of the nearest QueryProvider, or nil.
+// Call it from Render.
+func UseQueryClient() *QueryClient {
+	client, _ := UseContext(queryClientContext).(*QueryClient)
+	return client
+}
+
+// UseQuery returns the state of an API query for the rendering component.
+// The first render starts the fetch and sees Loading; when the data arrives,
+// the roots that rendered the query are refreshed. Components asking for the
+// same operation and variables share one request and one cached result.
+// Call it from Render.
+func UseQuery(operation string, variables map[string]interface{}) QueryResult {
+	client := UseQueryClient()
+	if client == nil {
+		return QueryResult{Err: ErrNoQueryClient}
+	}
+	root, _ := UseContext(rootContext).(*Root)
+	return client.use(root, operation, variables)
+}
+
+// UseMutation returns the mutation of an API operation for the rendering
+// component, so the roots rendering it are refreshed as it runs. Every render
+// returns the same mutation, so a component may keep it to call Mutate from
+// its event handlers. Call it from Render.
+func UseMutation(operation string) *Mutation {
+	client := UseQueryClient()
+	if client == nil {
+		return &Mutation{operation: operation, roots: make(map[*Root]bool)}
+	}
+
+	mutation := client.Mutation(operation)
+	if root, _ := UseContext(rootContext).(*Root); root != nil {
+		mutation.mutex.Lock()
+		mutation.roots[root] = true
+		mutation.mutex.Unlock()
+	}
+	return mutation
+}
@@ -0,0 +1,243 @@
+package gouix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAPI answers operations like a GoScaleAPI, counting the requests and
+// holding each one until release is closed
+type fakeAPI struct {
+	release chan struct{}
+	users   []string
+	calls   map[string]int
+	mutex   sync.Mutex
+}
+
+func newFakeAPI() *fakeAPI {
+	release := make(chan struct{})
+	close(release)
+	return &fakeAPI{release: release, users: []string{"Ada"}, calls: make(map[string]int)}
+}
+
+func (a *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Variables map[string]interface{} `json:"variables"`
+		Operation string                 `json:"operation"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a.mutex.Lock()
+	a.calls[request.Operation]++
+	release := a.release
+	a.mutex.Unlock()
+	<-release
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var data interface{}
+	switch request.Operation {
+	case "query:listUsers":
+		data = strings.Join(a.users, ",")
+	case "query:getUser":
+		data = map[string]interface{}{"id": request.Variables["id"], "name": "Ada"}
+	case "mutation:createUser":
+		a.users = append(a.users, request.Variables["name"].(string))
+		data = map[string]interface{}{"id": len(a.users)}
+	default:
+		http.Error(w, "Unknown operation", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+// hold makes the API wait before answering until the returned function is
+// called
+func (a *fakeAPI) hold() func() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	release := make(chan struct{})
+	a.release = release
+	return func() { close(release) }
+}
+
+func (a *fakeAPI) callCount(operation string) int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.calls[operation]
+}
+
+// userList renders the users query
+type userList struct {
+	BaseComponent
+}
+
+func newUserList(id ComponentID) *userList {
+	list := &userList{}
+	list.init(id, nil)
+	return list
+}
+
+func (l *userList) Render() string {
+	result := UseQuery("query:listUsers", nil)
+	switch {
+	case result.Err != nil:
+		return "<p>" + result.Err.Error() + "</p>"
+	case result.Data == nil:
+		return "<p>Loading</p>"
+	}
+	return fmt.Sprintf("<p>%v</p>", result.Data)
+}
+
+// signupButton creates a user when clicked
+type signupButton struct {
+	BaseComponent
+	mutation *Mutation
+}
+
+func newSignupButton(id ComponentID) *signupButton {
+	button := &signupButton{}
+	button.init(id, nil)
+	button.On("click", func(event Event) interface{} {
+		data, _ := button.mutation.Mutate(context.Background(), map[string]interface{}{"name": "Grace"})
+		return data
+	})
+	return button
+}
+
+func (b *signupButton) Render() string {
+	b.mutation = UseMutation("mutation:createUser")
+	b.mutation.Invalidates = []string{"query:listUsers"}
+	if b.mutation.Pending() {
+		return "<button disabled>Saving</button>"
+	}
+	return "<button>Sign up</button>"
+}
+
+// eventually polls condition until it holds or a second has passed
+func eventually(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestAPIClient(t *testing.T) {
+	api := newFakeAPI()
+
+	local := NewLocalAPIClient(api)
+	data, err := local.Do(context.Background(), "query:getUser", map[string]interface{}{"id": "7"})
+	if err != nil || data.(map[string]interface{})["id"] != "7" {
+		t.Fatalf("unexpected result %v, %v", data, err)
+	}
+
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	remote := NewAPIClient(server.URL)
+	if data, err := remote.Do(context.Background(), "query:listUsers", nil); err != nil || data != "Ada" {
+		t.Errorf("unexpected result over HTTP %v, %v", data, err)
+	}
+
+	_, err = remote.Do(context.Background(), "query:missing", nil)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound || apiErr.Message != "Unknown operation" {
+		t.Errorf("expected an API error, got %v", err)
+	}
+}
+
+func TestUseQueryDedupesAndRefreshes(t *testing.T) {
+	api := newFakeAPI()
+	release := api.hold()
+
+	client := NewQueryClient(NewLocalAPIClient(api))
+	page := NewQueryProvider("page", client, newUserList("a"), newUserList("b"))
+	root := NewRoot("page", page)
+
+	if html := root.Render(); strings.Count(html, "<p>Loading</p>") != 2 {
+		t.Fatalf("expected both lists to load, got %s", html)
+	}
+	var mutex sync.Mutex
+	var patches []PatchSet
+	root.OnPatch(func(patchSet PatchSet) {
+		mutex.Lock()
+		patches = append(patches, patchSet)
+		mutex.Unlock()
+	})
+	eventually(t, func() bool { return api.callCount("query:listUsers") == 1 })
+
+	release()
+	eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(patches) > 0
+	})
+	if html := root.Render(); strings.Count(html, "<p>Ada</p>") != 2 {
+		t.Errorf("expected the data to render, got %s", html)
+	}
+	if calls := api.callCount("query:listUsers"); calls != 1 {
+		t.Errorf("expected one request for both lists, got %d", calls)
+	}
+
+	// Cached data is used without another request
+	if data, err := client.Fetch(context.Background(), "query:listUsers", nil); data != "Ada" || err != nil {
+		t.Errorf("unexpected cached result %v, %v", data, err)
+	}
+	if calls := api.callCount("query:listUsers"); calls != 1 {
+		t.Errorf("expected the cache to answer, got %d requests", calls)
+	}
+}
+
+func TestMutationInvalidatesQueries(t *testing.T) {
+	api := newFakeAPI()
+	client := NewQueryClient(NewLocalAPIClient(api))
+	button := newSignupButton("signup")
+	root := NewRoot("page", NewQueryProvider("page", client, newUserList("users"), button))
+
+	root.Render()
+	eventually(t, func() bool { return strings.Contains(root.Render(), "<p>Ada</p>") })
+
+	button.HandleEvent(Event{Type: "click"})
+	mutation := button.mutation
+	if data := mutation.Data(); mutation.Err() != nil || data.(map[string]interface{})["id"] != float64(2) || mutation.Pending() {
+		t.Fatalf("unexpected mutation result %v, %v", data, mutation.Err())
+	}
+	if client.Mutation("mutation:createUser") != mutation {
+		t.Errorf("expected one mutation per operation")
+	}
+
+	// The list keeps its data while the query is fetched again
+	eventually(t, func() bool { return strings.Contains(root.Render(), "<p>Ada,Grace</p>") })
+	if calls := api.callCount("query:listUsers"); calls != 2 {
+		t.Errorf("expected the list to be fetched again once, got %d requests", calls)
+	}
+}
+
+func TestUseQueryWithoutProvider(t *testing.T) {
+	if html := newUserList("users").Render(); html != "<p>"+ErrNoQueryClient.Error()+"</p>" {
+		t.Errorf("expected an error outside a provider, got %s", html)
+	}
+	if _, err := UseMutation("mutation:createUser").Mutate(context.Background(), nil); err != ErrNoQueryClient {
+		t.Errorf("expected an error outside a provider, got %v", err)
+	}
+}
@@ -0,0 +1,513 @@
+package gouix
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// ErrRouteNotFound is returned by Navigate when no route matches a location
+var ErrRouteNotFound = errors.New("gouix: no route matches")
+
+// RouteComponent creates the component for a matched route
+type RouteComponent func(match *RouteMatch) Component
+
+// Route maps a path pattern to a component. Patterns are split into segments;
+// ":name" captures a segment as a parameter and a final "*" captures the rest
+// of the path. Child paths are relative to their parent, and a child with an
+// empty path matches the parent's own path.
+type Route struct {
+	// Path pattern, such as "/users/:id"
+	Path string
+
+	// Component creates the route's component
+	Component RouteComponent
+
+	// Lazy returns the route's component constructor the first time the route
+	// is matched or prefetched; used when Component is nil
+	Lazy func() RouteComponent
+
+	// Child routes rendered in this route's outlet
+	Children []*Route
+
+	lazyOnce sync.Once
+}
+
+// component returns the route's constructor, loading a lazy route once
+func (r *Route) component() RouteComponent {
+	if r.Component != nil {
+		return r.Component
+	}
+	if r.Lazy == nil {
+		return nil
+	}
+	r.lazyOnce.Do(func() {
+		r.Component = r.Lazy()
+	})
+	return r.Component
+}
+
+// RouteMatch describes a matched route within the current location
+type RouteMatch struct {
+	// Route that matched
+	Route *Route
+
+	// Path matched by this route and its parents
+	Path string
+
+	// Parameters captured by this route and its parents
+	Params map[string]string
+
+	// Query parameters of the location
+	Query url.Values
+
+	// Component of the matched child route, rendered by Outlet
+	child  Component
+	router *Router
+}
+
+// Param returns a path parameter. A component kept across navigations sees
+// the parameters of the current location.
+func (m *RouteMatch) Param(name string) string {
+	m.router.mutex.RLock()
+	defer m.router.mutex.RUnlock()
+
+	return m.Params[name]
+}
+
+// Navigate navigates the router the route matched in, for example to show
+// a record after a form saved it
+func (m *RouteMatch) Navigate(location string) error {
+	return m.router.Navigate(location)
+}
+
+// Outlet renders the matched child route. Parent route components call it
+// where nested routes should appear.
+func (m *RouteMatch) Outlet() string {
+	m.router.mutex.RLock()
+	child := m.child
+	m.router.mutex.RUnlock()
+
+	if child == nil {
+		return ""
+	}
+	return child.Render()
+}
+
+// activeRoute is a matched route and the component created for it
+type activeRoute struct {
+	match       *RouteMatch
+	component   Component
+	unsubscribe func()
+}
+
+// Router renders the component of the route matching the current location.
+// In the browser, the runtime included by LiveHub.ScriptTag turns Link clicks
+// and history navigation into "navigate" events, so only the changed parts of
+// the page are patched. Server-side navigation updates the browser's address bar.
+type Router struct {
+	BaseComponent
+
+	// NotFound renders locations without a matching route
+	NotFound RouteComponent
+
+	routes   []*Route
+	location string
+	active   []*activeRoute
+	mutex    sync.RWMutex
+}
+
+// NewRouter creates a router at "/"
+func NewRouter(id ComponentID, routes ...*Route) *Router {
+	router := &Router{routes: routes}
+	router.init(id, nil)
+
+	router.On("navigate", func(event Event) interface{} {
+		location, _ := event.Data["path"].(string)
+		return router.Navigate(location)
+	})
+	router.On("prefetch", func(event Event) interface{} {
+		location, _ := event.Data["path"].(string)
+		router.Prefetch(location)
+		return nil
+	})
+
+	router.Navigate("/")
+
+	return router
+}
+
+// splitPath splits a path into its non-empty segments
+func splitPath(path string) []string {
+	segments := make([]string, 0)
+	for _, segment := range strings.Split(path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+// matchRoutes finds the first chain of routes, outermost first, matching the
+// remaining path segments
+func matchRoutes(routes []*Route, segments []string, params map[string]string) ([]*Route, map[string]string) {
+	for _, route := range routes {
+		pattern := splitPath(route.Path)
+		captured := make(map[string]string, len(params))
+		for name, value := range params {
+			captured[name] = value
+		}
+
+		rest, ok := matchSegments(pattern, segments, captured)
+		if !ok {
+			continue
+		}
+
+		if len(route.Children) > 0 {
+			if chain, childParams := matchRoutes(route.Children, rest, captured); chain != nil {
+				return append([]*Route{route}, chain...), childParams
+			}
+		}
+		if len(rest) == 0 {
+			return []*Route{route}, captured
+		}
+	}
+	return nil, nil
+}
+
+// matchSegments matches a pattern against the start of segments and returns
+// the segments left over
+func matchSegments(pattern, segments []string, params map[string]string) ([]string, bool) {
+	for i, part := range pattern {
+		if part == "*" {
+			params["*"] = strings.Join(segments[i:], "/")
+			return nil, true
+		}
+		if i >= len(segments) {
+			return nil, false
+		}
+		if strings.HasPrefix(part, ":") {
+			params[part[1:]] = segments[i]
+		} else if part != segments[i] {
+			return nil, false
+		}
+	}
+	return segments[len(pattern):], true
+}
+
+// resolve matches a location to a chain of routes
+func (r *Router) resolve(location string) ([]*Route, map[string]string, *url.URL, error) {
+	parsed, err := url.Parse(location)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	segments := splitPath(parsed.Path)
+	for i, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segments[i] = unescaped
+		}
+	}
+
+	chain, params := matchRoutes(r.routes, segments, map[string]string{})
+	if chain == nil {
+		return nil, nil, parsed, ErrRouteNotFound
+	}
+	return chain, params, parsed, nil
+}
+
+// Navigate renders the route matching location, a path with an optional query
+// string. Components of routes that stay matched with the same parameters are
+// kept, so their state survives navigation. If nothing matches, NotFound is
+// rendered and ErrRouteNotFound is returned, for example to answer a deep link
+// with a 404 status.
+func (r *Router) Navigate(location string) error {
+	if location == "" {
+		location = "/"
+	}
+
+	chain, params, parsed, err := r.resolve(location)
+	if parsed == nil {
+		return err
+	}
+
+	// Load lazy routes before taking the lock
+	for _, route := range chain {
+		route.component()
+	}
+
+	r.mutex.Lock()
+
+	// Build the new active chain, reusing unchanged components
+	active := make([]*activeRoute, 0, len(chain)+1)
+	kept := 0
+	matchedPath := ""
+	for _, route := range chain {
+		if part := strings.Trim(route.Path, "/"); part != "" {
+			matchedPath = strings.TrimSuffix(matchedPath, "/") + "/" + part
+		} else if matchedPath == "" {
+			matchedPath = "/"
+		}
+		match := &RouteMatch{
+			Route:  route,
+			Path:   matchedPath,
+			Params: params,
+			Query:  parsed.Query(),
+			router: r,
+		}
+
+		// Routes without a component pass their outlet through
+		if route.component() == nil {
+			continue
+		}
+
+		j := len(active)
+		if j == kept && j < len(r.active) && r.active[j].match.Route == route && sameParams(r.active[j].match, route, params) {
+			previous := r.active[j]
+			*previous.match = *match
+			active = append(active, previous)
+			kept++
+			continue
+		}
+
+		active = append(active, &activeRoute{match: match})
+	}
+	if chain == nil && r.NotFound != nil {
+		active = append(active, &activeRoute{match: &RouteMatch{
+			Path:   parsed.Path,
+			Params: map[string]string{},
+			Query:  parsed.Query(),
+			router: r,
+		}})
+	}
+
+	removed := r.active[kept:]
+	r.active = active
+	r.location = parsed.RequestURI()
+	r.mutex.Unlock()
+
+	for _, previous := range removed {
+		if previous.unsubscribe != nil {
+			previous.unsubscribe()
+		}
+		previous.component.Unmount()
+	}
+
+	// Create the new components outside the lock, as they may use the router
+	for i := kept; i < len(active); i++ {
+		constructor := r.NotFound
+		if active[i].match.Route != nil {
+			constructor = active[i].match.Route.component()
+		}
+		component := constructor(active[i].match)
+		component.Mount()
+
+		var unsubscribe func()
+		if notifier, ok := component.(StateNotifier); ok {
+			unsubscribe = notifier.OnStateChange(r.notifyStateChange)
+		}
+
+		r.mutex.Lock()
+		active[i].component = component
+		active[i].unsubscribe = unsubscribe
+		r.mutex.Unlock()
+	}
+
+	// Link each match to the component rendered in its outlet
+	r.mutex.Lock()
+	for i := range active {
+		active[i].match.child = nil
+		if i+1 < len(active) {
+			active[i].match.child = active[i+1].component
+		}
+	}
+	r.mutex.Unlock()
+
+	r.notifyStateChange()
+
+	return err
+}
+
+// sameParams reports whether the parameters a route's pattern captured are
+// unchanged
+func sameParams(match *RouteMatch, route *Route, params map[string]string) bool {
+	for _, part := range splitPath(route.Path) {
+		name := ""
+		if part == "*" {
+			name = "*"
+		} else if strings.HasPrefix(part, ":") {
+			name = part[1:]
+		}
+		if name != "" && match.Params[name] != params[name] {
+			return false
+		}
+	}
+	// Parent parameters are compared by the parent's own check
+	return true
+}
+
+// Prefetch loads the lazy routes a location would render, so a following
+// navigation does not wait for them
+func (r *Router) Prefetch(location string) {
+	chain, _, _, err := r.resolve(location)
+	if err != nil {
+		return
+	}
+	for _, route := range chain {
+		route.component()
+	}
+}
+
+// Location returns the current path and query string
+func (r *Router) Location() string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.location
+}
+
+// Matches returns the matched routes, outermost first
+func (r *Router) Matches() []*RouteMatch {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	matches := make([]*RouteMatch, len(r.active))
+	for i, active := range r.active {
+		matches[i] = active.match
+	}
+	return matches
+}
+
+// FindComponent returns an active route component, or a component found
+// through one, so the live hub can route browser events to it
+func (r *Router) FindComponent(id ComponentID) Component {
+	r.mutex.RLock()
+	active := make([]*activeRoute, len(r.active))
+	copy(active, r.active)
+	r.mutex.RUnlock()
+
+	for _, route := range active {
+		if route.component == nil {
+			continue
+		}
+		if route.component.GetID() == id {
+			return route.component
+		}
+		if finder, ok := route.component.(ComponentFinder); ok {
+			if component := finder.FindComponent(id); component != nil {
+				return component
+			}
+		}
+	}
+	return nil
+}
+
+// Render implements the Component interface
+func (r *Router) Render() string {
+	r.mutex.RLock()
+	location := r.location
+	var top Component
+	if len(r.active) > 0 {
+		top = r.active[0].component
+	}
+	r.mutex.RUnlock()
+
+	content := ""
+	if top != nil {
+		content = top.Render()
+	}
+
+	return "<div data-gouix-router=\"" + html.EscapeString(string(r.GetID())) +
+		"\" data-gouix-location=\"" + html.EscapeString(location) + "\">" + content + "</div>"
+}
+
+// Link renders an anchor that the router runtime navigates without a page
+// load. With the "prefetch" prop set, the target's lazy routes are loaded
+// when the pointer or focus reaches the link. The "router" prop selects the
+// router by ID when a page has several.
+func Link(href string, props Props, children ...interface{}) string {
+	attributes := Props{}
+	for key, value := range props {
+		attributes[key] = value
+	}
+
+	if prefetch, _ := attributes["prefetch"].(bool); prefetch {
+		attributes["data-gouix-prefetch"] = true
+	}
+	if router, ok := attributes["router"]; ok {
+		attributes["data-gouix-router-target"] = html.EscapeString(fmt.Sprint(router))
+	}
+	delete(attributes, "prefetch")
+	delete(attributes, "router")
+
+	attributes["href"] = html.EscapeString(href)
+	attributes["data-gouix-link"] = true
+
+	return CreateElement("a", attributes, children...)
+}
+
+// RouterRuntime is the client-side script for Router. It is included by
+// LiveHub.ScriptTag and requires LiveRuntime.
+const RouterRuntime = `(function() {
+  var g = window._gouix = window._gouix || {};
+  var prefetched = {};
+
+  function router(el) {
+    var id = el && el.getAttribute('data-gouix-router-target');
+    var node = id ? document.querySelector('[data-gouix-router="' + id + '"]') : document.querySelector('[data-gouix-router]');
+    return node && node.getAttribute('data-gouix-router');
+  }
+
+  function sameOrigin(link) {
+    return link.origin === window.location.origin && (!link.target || link.target === '_self');
+  }
+
+  g.navigate = function(path, replace) {
+    var id = router();
+    if (!id) { window.location.assign(path); return; }
+    if (replace) history.replaceState({gouix: true}, '', path);
+    else history.pushState({gouix: true}, '', path);
+    g.dispatchEvent(id, 'navigate', {path: path});
+  };
+
+  document.addEventListener('click', function(e) {
+    if (e.defaultPrevented || e.button !== 0 || e.metaKey || e.ctrlKey || e.shiftKey || e.altKey) return;
+    var link = e.target.closest && e.target.closest('a[data-gouix-link]');
+    if (!link || !sameOrigin(link) || !router(link)) return;
+    e.preventDefault();
+    var path = link.pathname + link.search + link.hash;
+    if (path !== window.location.pathname + window.location.search + window.location.hash) g.navigate(path);
+  });
+
+  function prefetch(e) {
+    var link = e.target.closest && e.target.closest('a[data-gouix-prefetch]');
+    if (!link || !sameOrigin(link)) return;
+    var path = link.pathname + link.search, id = router(link);
+    if (!id || prefetched[path]) return;
+    prefetched[path] = true;
+    g.dispatchEvent(id, 'prefetch', {path: path});
+  }
+  document.addEventListener('mouseover', prefetch);
+  document.addEventListener('focusin', prefetch);
+  document.addEventListener('touchstart', prefetch, {passive: true});
+
+  window.addEventListener('popstate', function() {
+    var id = router();
+    if (id) g.dispatchEvent(id, 'navigate', {path: window.location.pathname + window.location.search});
+  });
+
+  // Follow navigation started on the server, such as a redirect after a submit
+  new MutationObserver(function(mutations) {
+    mutations.forEach(function(m) {
+      var node = m.target;
+      if (m.type !== 'attributes' || !node.getAttribute) return;
+      var location = node.getAttribute('data-gouix-location');
+      if (location && location !== window.location.pathname + window.location.search) {
+        history.pushState({gouix: true}, '', location);
+      }
+    });
+  }).observe(document.documentElement, {attributes: true, attributeFilter: ['data-gouix-location'], subtree: true});
+})();`
@@ -0,0 +1,183 @@
+package gouix
+
+import (
+	"strings"
+	"testing"
+)
+
+// pageComponent renders a route match with a render function
+type pageComponent struct {
+	BaseComponent
+	match   *RouteMatch
+	render  func(match *RouteMatch) string
+	mounted bool
+}
+
+func (p *pageComponent) Render() string {
+	return p.render(p.match)
+}
+
+func (p *pageComponent) Mount() {
+	p.mounted = true
+}
+
+func (p *pageComponent) Unmount() {
+	p.mounted = false
+}
+
+// page returns a route component counting how often it is created
+func page(id ComponentID, created *int, render func(match *RouteMatch) string) RouteComponent {
+	return func(match *RouteMatch) Component {
+		*created++
+		component := &pageComponent{match: match, render: render}
+		component.init(id, nil)
+		return component
+	}
+}
+
+func newTestRouter(layouts, users *int, lazyLoads *int) *Router {
+	return NewRouter("app",
+		&Route{
+			Path: "/",
+			Component: page("layout", layouts, func(match *RouteMatch) string {
+				return "<main>" + match.Outlet() + "</main>"
+			}),
+			Children: []*Route{
+				{Path: "", Component: page("home", new(int), func(*RouteMatch) string { return "<h1>Home</h1>" })},
+				{Path: "users/:id", Component: page("user", users, func(match *RouteMatch) string {
+					return "<h1>User " + match.Param("id") + "</h1>" + match.Outlet()
+				}), Children: []*Route{
+					{Path: ""},
+					{Path: "posts", Component: page("posts", new(int), func(match *RouteMatch) string {
+						return "<ul data-tab=\"" + match.Query.Get("tab") + "\"></ul>"
+					})},
+				}},
+				{Path: "settings", Lazy: func() RouteComponent {
+					*lazyLoads++
+					return page("settings", new(int), func(*RouteMatch) string { return "<h1>Settings</h1>" })
+				}},
+				{Path: "files/*", Component: page("files", new(int), func(match *RouteMatch) string {
+					return "<p>" + match.Param("*") + "</p>"
+				})},
+			},
+		},
+	)
+}
+
+func TestRouterNestedRoutes(t *testing.T) {
+	var layouts, users, lazyLoads int
+	router := newTestRouter(&layouts, &users, &lazyLoads)
+
+	if html := router.Render(); !strings.Contains(html, "<main><h1>Home</h1></main>") {
+		t.Errorf("unexpected home render %s", html)
+	}
+
+	if err := router.Navigate("/users/42/posts?tab=recent"); err != nil {
+		t.Fatalf("Navigate: %v", err)
+	}
+	html := router.Render()
+	if !strings.Contains(html, "<main><h1>User 42</h1><ul data-tab=\"recent\"></ul></main>") {
+		t.Errorf("unexpected nested render %s", html)
+	}
+	if !strings.Contains(html, `data-gouix-location="/users/42/posts?tab=recent"`) {
+		t.Errorf("expected the location in the render, got %s", html)
+	}
+
+	// The layout and user pages survive navigation within them
+	router.Navigate("/users/42")
+	if layouts != 1 || users != 1 {
+		t.Errorf("expected components to be kept, created layout %d and user %d times", layouts, users)
+	}
+	if html := router.Render(); !strings.Contains(html, "<main><h1>User 42</h1></main>") {
+		t.Errorf("unexpected render %s", html)
+	}
+
+	// A new parameter creates a new page and unmounts the old one
+	previous := router.FindComponent("user").(*pageComponent)
+	router.Navigate("/users/7")
+	if users != 2 || !strings.Contains(router.Render(), "User 7") {
+		t.Errorf("expected a new user page for a new id")
+	}
+	if previous.mounted {
+		t.Errorf("expected the previous user page to be unmounted")
+	}
+
+	var match *RouteMatch
+	router.NotFound = func(m *RouteMatch) Component {
+		match = m
+		return page("missing", new(int), func(*RouteMatch) string { return "" })(m)
+	}
+	router.Navigate("/nope")
+	if err := match.Navigate("/users/4"); err != nil || !strings.Contains(router.Render(), "User 4") {
+		t.Errorf("expected a match to navigate its router, got %v", err)
+	}
+	router.NotFound = nil
+
+	router.Navigate("/files/docs/a%20b.txt")
+	if html := router.Render(); !strings.Contains(html, "<p>docs/a b.txt</p>") {
+		t.Errorf("expected the wildcard to capture the rest of the path, got %s", html)
+	}
+}
+
+func TestRouterLazyRoutesAndNotFound(t *testing.T) {
+	var layouts, users, lazyLoads int
+	router := newTestRouter(&layouts, &users, &lazyLoads)
+
+	if lazyLoads != 0 {
+		t.Fatalf("lazy route loaded before use")
+	}
+	router.Prefetch("/settings")
+	router.Navigate("/settings")
+	if lazyLoads != 1 || !strings.Contains(router.Render(), "Settings") {
+		t.Errorf("expected the lazy route to load once and render, loaded %d times", lazyLoads)
+	}
+
+	router.NotFound = page("missing", new(int), func(match *RouteMatch) string { return "<h1>No " + match.Path + "</h1>" })
+	if err := router.Navigate("/nope"); err != ErrRouteNotFound {
+		t.Errorf("expected ErrRouteNotFound, got %v", err)
+	}
+	if html := router.Render(); !strings.Contains(html, "<h1>No /nope</h1>") {
+		t.Errorf("unexpected not found render %s", html)
+	}
+}
+
+func TestRouterEventsPatchRoot(t *testing.T) {
+	var layouts, users, lazyLoads int
+	router := newTestRouter(&layouts, &users, &lazyLoads)
+
+	root := NewRoot("app", router)
+	root.Render()
+
+	var patches []PatchSet
+	root.OnPatch(func(patchSet PatchSet) { patches = append(patches, patchSet) })
+
+	router.HandleEvent(Event{Type: "navigate", Target: "app", Data: map[string]interface{}{"path": "/users/3"}})
+
+	if len(patches) != 1 {
+		t.Fatalf("expected one patch set, got %d", len(patches))
+	}
+	var location, title bool
+	for _, patch := range patches[0].Patches {
+		if patch.Op == PatchSetAttr && patch.Name == "data-gouix-location" && patch.Value == "/users/3" {
+			location = true
+		}
+		if strings.Contains(patch.HTML, "User 3") || patch.Value == "User 3" {
+			title = true
+		}
+	}
+	if !location || !title {
+		t.Errorf("expected location and content patches, got %+v", patches[0].Patches)
+	}
+
+	if component := router.FindComponent("user"); component == nil {
+		t.Errorf("expected FindComponent to return the active user page")
+	}
+}
+
+func TestLink(t *testing.T) {
+	html := Link("/users/1", Props{"prefetch": true, "class": "nav"}, "User")
+	expected := `<a class="nav" data-gouix-link data-gouix-prefetch href="/users/1">User</a>`
+	if html != expected {
+		t.Errorf("expected %s, got %s", expected, html)
+	}
+}
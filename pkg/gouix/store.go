@@ -0,0 +1,416 @@
+package gouix
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Action describes a state change dispatched to a store
+type Action struct {
+	Type    string      `json:"type"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+// Reducer computes the next value of a slice from its current value and an
+// action. Reducers must not modify the current value in place.
+type Reducer func(state interface{}, action Action) interface{}
+
+// State is a snapshot of a store's values
+type State map[string]interface{}
+
+// Selector derives a value from a state snapshot
+type Selector func(state State) interface{}
+
+// SelectKey returns a selector for a single state key
+func SelectKey(key string) Selector {
+	return func(state State) interface{} {
+		return state[key]
+	}
+}
+
+// StoreChange is one value changed by a store event
+type StoreChange struct {
+	Key      string      `json:"key"`
+	Previous interface{} `json:"previous"`
+	Next     interface{} `json:"next"`
+}
+
+// StoreEvent is a devtools log entry for a change to a store
+type StoreEvent struct {
+	Time    time.Time     `json:"time"`
+	Action  string        `json:"action"`
+	Payload interface{}   `json:"payload,omitempty"`
+	Changes []StoreChange `json:"changes"`
+}
+
+// selection is a selector subscription and the last value it produced
+type selection struct {
+	selector Selector
+	observer Observer
+	value    interface{}
+}
+
+// storeDevtools keeps a bounded event history and the event listeners
+type storeDevtools struct {
+	limit     int
+	history   []StoreEvent
+	listeners map[int]func(StoreEvent)
+	nextID    int
+}
+
+// newSignal creates a signal for key that reports its changes to the store
+func (s *Store) newSignal(key string, value interface{}) *Signal {
+	signal := NewSignal(value)
+	signal.Subscribe(func(newValue, oldValue interface{}) {
+		s.notifyMutex.Lock()
+		quiet := s.dispatching
+		s.notifyMutex.Unlock()
+
+		// Dispatch logs its changes as one event
+		if !quiet {
+			s.record(StoreEvent{Action: "set", Changes: []StoreChange{{Key: key, Previous: oldValue, Next: newValue}}})
+		}
+		s.changed()
+	})
+	return signal
+}
+
+// checkType reports an error if key is a typed slice that cannot hold value
+func (s *Store) checkType(key string, value interface{}) error {
+	s.mutex.RLock()
+	typ := s.types[key]
+	s.mutex.RUnlock()
+
+	if typ == nil {
+		return nil
+	}
+	if value == nil {
+		switch typ.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func, reflect.Chan:
+			return nil
+		}
+		return fmt.Errorf("gouix: slice %q holds %s, got nil", key, typ)
+	}
+	if !reflect.TypeOf(value).AssignableTo(typ) {
+		return fmt.Errorf("gouix: slice %q holds %s, got %T", key, typ, value)
+	}
+	return nil
+}
+
+// AddSlice adds a typed part of the state. Every later value must have the
+// type of initial. The reducer, if not nil, computes the slice's next value
+// for each dispatched action.
+func (s *Store) AddSlice(name string, initial interface{}, reducer Reducer) error {
+	s.mutex.Lock()
+	if _, exists := s.state[name]; exists {
+		s.mutex.Unlock()
+		return fmt.Errorf("gouix: store already has %q", name)
+	}
+
+	s.state[name] = s.newSignal(name, initial)
+	if initial != nil {
+		s.types[name] = reflect.TypeOf(initial)
+	}
+	if reducer != nil {
+		s.reducers[name] = reducer
+	}
+	s.mutex.Unlock()
+
+	s.record(StoreEvent{Action: "@@init", Changes: []StoreChange{{Key: name, Next: initial}}})
+	s.changed()
+
+	return nil
+}
+
+// Dispatch runs every slice reducer for an action and applies the results
+// together, so selectors see a single change. Nothing is applied if a reducer
+// returns a value of the wrong type.
+func (s *Store) Dispatch(action Action) error {
+	s.updateMutex.Lock()
+	defer s.updateMutex.Unlock()
+
+	s.mutex.RLock()
+	names := make([]string, 0, len(s.reducers))
+	reducers := make(map[string]Reducer, len(s.reducers))
+	signals := make(map[string]*Signal, len(s.reducers))
+	for name, reducer := range s.reducers {
+		names = append(names, name)
+		reducers[name] = reducer
+		signals[name] = s.state[name]
+	}
+	s.mutex.RUnlock()
+	sort.Strings(names)
+
+	// Reduce everything before applying anything
+	changes := make([]StoreChange, 0, len(names))
+	for _, name := range names {
+		signal := signals[name]
+		if signal == nil {
+			continue
+		}
+
+		previous := signal.Get()
+		next := reducers[name](previous, action)
+		if err := s.checkType(name, next); err != nil {
+			return fmt.Errorf("%v (reducing %q)", err, action.Type)
+		}
+		if !reflect.DeepEqual(previous, next) {
+			changes = append(changes, StoreChange{Key: name, Previous: previous, Next: next})
+		}
+	}
+
+	s.Batch(func() {
+		s.notifyMutex.Lock()
+		s.dispatching = true
+		s.notifyMutex.Unlock()
+
+		for _, change := range changes {
+			signals[change.Key].Set(change.Next)
+		}
+
+		s.notifyMutex.Lock()
+		s.dispatching = false
+		s.notifyMutex.Unlock()
+	})
+
+	s.record(StoreEvent{Action: action.Type, Payload: action.Payload, Changes: changes})
+
+	return nil
+}
+
+// Mutate replaces a value with the result of mutator, which receives the
+// current value. Mutations are serialized with Dispatch.
+func (s *Store) Mutate(key string, mutator func(value interface{}) interface{}) error {
+	s.updateMutex.Lock()
+	defer s.updateMutex.Unlock()
+
+	next := mutator(s.GetValue(key))
+	if err := s.checkType(key, next); err != nil {
+		return err
+	}
+
+	s.Set(key, next)
+	return nil
+}
+
+// Batch runs fn and notifies selector subscribers once afterwards, however
+// many values fn changed
+func (s *Store) Batch(fn func()) {
+	s.notifyMutex.Lock()
+	s.batchDepth++
+	s.notifyMutex.Unlock()
+
+	defer func() {
+		s.notifyMutex.Lock()
+		s.batchDepth--
+		pending := s.batchDepth == 0 && s.dirty
+		s.notifyMutex.Unlock()
+
+		if pending {
+			s.changed()
+		}
+	}()
+
+	fn()
+}
+
+// State returns a snapshot of the store's values
+func (s *Store) State() State {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	state := make(State, len(s.state))
+	for key, signal := range s.state {
+		state[key] = signal.Get()
+	}
+	return state
+}
+
+// Select returns the value a selector derives from the current state
+func (s *Store) Select(selector Selector) interface{} {
+	return selector(s.State())
+}
+
+// SubscribeSelector calls observer whenever the value derived by selector
+// changes. It returns a function that ends the subscription.
+func (s *Store) SubscribeSelector(selector Selector, observer Observer) func() {
+	value := s.Select(selector)
+
+	s.notifyMutex.Lock()
+	id := s.nextSelection
+	s.nextSelection++
+	s.selections[id] = &selection{selector: selector, observer: observer, value: value}
+	s.notifyMutex.Unlock()
+
+	return func() {
+		s.notifyMutex.Lock()
+		delete(s.selections, id)
+		s.notifyMutex.Unlock()
+	}
+}
+
+// changed re-runs the selectors after a state change. Changes made while the
+// selectors run, including by their observers, cause another pass instead of
+// a nested one.
+func (s *Store) changed() {
+	s.notifyMutex.Lock()
+	if s.batchDepth > 0 || s.notifying {
+		s.dirty = true
+		s.notifyMutex.Unlock()
+		return
+	}
+
+	s.notifying = true
+	for {
+		s.dirty = false
+		s.notifyMutex.Unlock()
+
+		s.runSelectors()
+
+		s.notifyMutex.Lock()
+		if !s.dirty || s.batchDepth > 0 {
+			break
+		}
+	}
+	s.notifying = false
+	s.notifyMutex.Unlock()
+}
+
+// runSelectors calls the observers of every selection whose value changed
+func (s *Store) runSelectors() {
+	state := s.State()
+
+	s.notifyMutex.Lock()
+	ids := make([]int, 0, len(s.selections))
+	for id := range s.selections {
+		ids = append(ids, id)
+	}
+	s.notifyMutex.Unlock()
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		s.notifyMutex.Lock()
+		sel, ok := s.selections[id]
+		s.notifyMutex.Unlock()
+		if !ok {
+			continue
+		}
+
+		value := sel.selector(state)
+
+		s.notifyMutex.Lock()
+		previous := sel.value
+		changed := !reflect.DeepEqual(previous, value)
+		sel.value = value
+		s.notifyMutex.Unlock()
+
+		if changed {
+			sel.observer(value, previous)
+		}
+	}
+}
+
+// EnableDevtools starts logging store events, keeping the last limit events
+// for History. A limit of zero keeps no history but still calls OnEvent
+// listeners.
+func (s *Store) EnableDevtools(limit int) {
+	s.notifyMutex.Lock()
+	defer s.notifyMutex.Unlock()
+
+	if s.devtools == nil {
+		s.devtools = &storeDevtools{listeners: make(map[int]func(StoreEvent))}
+	}
+	s.devtools.limit = limit
+	if len(s.devtools.history) > limit {
+		s.devtools.history = s.devtools.history[len(s.devtools.history)-limit:]
+	}
+}
+
+// History returns the logged events, oldest first
+func (s *Store) History() []StoreEvent {
+	s.notifyMutex.Lock()
+	defer s.notifyMutex.Unlock()
+
+	if s.devtools == nil {
+		return nil
+	}
+	history := make([]StoreEvent, len(s.devtools.history))
+	copy(history, s.devtools.history)
+	return history
+}
+
+// OnEvent calls listener for every store event, enabling devtools if needed.
+// It returns a function that removes the listener.
+func (s *Store) OnEvent(listener func(StoreEvent)) func() {
+	s.notifyMutex.Lock()
+	if s.devtools == nil {
+		s.devtools = &storeDevtools{listeners: make(map[int]func(StoreEvent))}
+	}
+	id := s.devtools.nextID
+	s.devtools.nextID++
+	s.devtools.listeners[id] = listener
+	s.notifyMutex.Unlock()
+
+	return func() {
+		s.notifyMutex.Lock()
+		delete(s.devtools.listeners, id)
+		s.notifyMutex.Unlock()
+	}
+}
+
+// LogEvents writes every store event to w as a line of JSON
+func (s *Store) LogEvents(w io.Writer) func() {
+	return s.OnEvent(func(event StoreEvent) {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return
+		}
+		w.Write(append(data, '\n'))
+	})
+}
+
+// record logs an event if devtools are enabled
+func (s *Store) record(event StoreEvent) {
+	s.notifyMutex.Lock()
+	devtools := s.devtools
+	if devtools == nil {
+		s.notifyMutex.Unlock()
+		return
+	}
+
+	event.Time = time.Now()
+	if devtools.limit > 0 {
+		devtools.history = append(devtools.history, event)
+		if len(devtools.history) > devtools.limit {
+			devtools.history = devtools.history[len(devtools.history)-devtools.limit:]
+		}
+	}
+
+	ids := make([]int, 0, len(devtools.listeners))
+	for id := range devtools.listeners {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	listeners := make([]func(StoreEvent), 0, len(ids))
+	for _, id := range ids {
+		listeners = append(listeners, devtools.listeners[id])
+	}
+	s.notifyMutex.Unlock()
+
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+// Connect re-renders the component whenever the part of store picked by
+// selector changes, by notifying its state change listeners such as a Root.
+// It returns a function that disconnects the component.
+func (b *BaseComponent) Connect(store *Store, selector Selector) func() {
+	return store.SubscribeSelector(selector, func(_, _ interface{}) {
+		b.notifyStateChange()
+	})
+}
@@ -0,0 +1,195 @@
+package gouix
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type todo struct {
+	Title string
+	Done  bool
+}
+
+func todosReducer(state interface{}, action Action) interface{} {
+	todos := state.([]todo)
+	switch action.Type {
+	case "todos/add":
+		next := make([]todo, len(todos), len(todos)+1)
+		copy(next, todos)
+		return append(next, todo{Title: action.Payload.(string)})
+	case "todos/toggle":
+		next := make([]todo, len(todos))
+		copy(next, todos)
+		index := action.Payload.(int)
+		next[index].Done = !next[index].Done
+		return next
+	}
+	return todos
+}
+
+func filterReducer(state interface{}, action Action) interface{} {
+	if action.Type == "filter/set" {
+		return action.Payload
+	}
+	return state
+}
+
+func newTodoStore(t *testing.T) *Store {
+	store := NewStore(nil)
+	if err := store.AddSlice("todos", []todo{}, todosReducer); err != nil {
+		t.Fatalf("AddSlice: %v", err)
+	}
+	if err := store.AddSlice("filter", "all", filterReducer); err != nil {
+		t.Fatalf("AddSlice: %v", err)
+	}
+	return store
+}
+
+func remaining(state State) interface{} {
+	count := 0
+	for _, item := range state["todos"].([]todo) {
+		if !item.Done {
+			count++
+		}
+	}
+	return count
+}
+
+func TestStoreDispatchAndSelectors(t *testing.T) {
+	store := newTodoStore(t)
+
+	var seen []interface{}
+	unsubscribe := store.SubscribeSelector(remaining, func(newValue, _ interface{}) {
+		seen = append(seen, newValue)
+	})
+
+	store.Dispatch(Action{Type: "todos/add", Payload: "write docs"})
+	store.Dispatch(Action{Type: "todos/add", Payload: "ship"})
+	store.Dispatch(Action{Type: "filter/set", Payload: "active"}) // remaining unchanged
+	store.Dispatch(Action{Type: "todos/toggle", Payload: 0})
+
+	if len(seen) != 3 || seen[0] != 1 || seen[1] != 2 || seen[2] != 1 {
+		t.Fatalf("expected selector values [1 2 1], got %v", seen)
+	}
+	if store.Select(SelectKey("filter")) != "active" {
+		t.Errorf("expected filter to be active, got %v", store.Select(SelectKey("filter")))
+	}
+
+	unsubscribe()
+	store.Dispatch(Action{Type: "todos/toggle", Payload: 1})
+	if len(seen) != 3 {
+		t.Errorf("unsubscribed selector was called")
+	}
+}
+
+func TestStoreTypedSlices(t *testing.T) {
+	store := newTodoStore(t)
+
+	if err := store.AddSlice("todos", []todo{}, nil); err == nil {
+		t.Errorf("expected an error adding a duplicate slice")
+	}
+
+	if err := store.Dispatch(Action{Type: "filter/set", Payload: 3}); err == nil {
+		t.Fatalf("expected an error for a reducer returning the wrong type")
+	}
+	if store.GetValue("filter") != "all" {
+		t.Errorf("failed dispatch should not change state, got %v", store.GetValue("filter"))
+	}
+
+	if err := store.Mutate("filter", func(value interface{}) interface{} { return 1 }); err == nil {
+		t.Errorf("expected an error for a mutator returning the wrong type")
+	}
+	if err := store.Mutate("filter", func(value interface{}) interface{} { return "done" }); err != nil {
+		t.Errorf("Mutate: %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected Set with the wrong type to panic")
+		}
+	}()
+	store.Set("filter", false)
+}
+
+func TestStoreBatchNotifiesOnce(t *testing.T) {
+	store := NewStore(map[string]interface{}{"x": 0, "y": 0})
+
+	calls := 0
+	store.SubscribeSelector(func(state State) interface{} {
+		return state["x"].(int) + state["y"].(int)
+	}, func(_, _ interface{}) {
+		calls++
+	})
+
+	store.Batch(func() {
+		store.Set("x", 1)
+		store.Set("y", 2)
+	})
+
+	if calls != 1 {
+		t.Errorf("expected one notification for a batch, got %d", calls)
+	}
+}
+
+func TestStoreDevtools(t *testing.T) {
+	store := newTodoStore(t)
+	store.EnableDevtools(2)
+
+	var log bytes.Buffer
+	store.LogEvents(&log)
+
+	store.Dispatch(Action{Type: "todos/add", Payload: "a"})
+	store.Set("filter", "done")
+	store.Dispatch(Action{Type: "todos/add", Payload: "b"})
+
+	history := store.History()
+	if len(history) != 2 {
+		t.Fatalf("expected history limited to 2 events, got %d", len(history))
+	}
+	if history[0].Action != "set" || history[0].Changes[0].Key != "filter" {
+		t.Errorf("unexpected event %+v", history[0])
+	}
+	if history[1].Action != "todos/add" || len(history[1].Changes) != 1 {
+		t.Errorf("expected a dispatch to log one event with its changes, got %+v", history[1])
+	}
+
+	if lines := strings.Count(log.String(), "\n"); lines != 3 {
+		t.Errorf("expected 3 logged events, got %d:\n%s", lines, log.String())
+	}
+}
+
+// remainingView renders a value selected from a shared store
+type remainingView struct {
+	BaseComponent
+	store *Store
+}
+
+func (v *remainingView) Render() string {
+	return fmt.Sprintf("<p>%d left</p>", v.store.Select(remaining))
+}
+
+func TestConnectedComponentRerenders(t *testing.T) {
+	store := newTodoStore(t)
+
+	view := &remainingView{store: store}
+	view.init("remaining", nil)
+	view.Connect(store, remaining)
+
+	root := NewRoot("remaining", view)
+	root.Render()
+
+	var patches []PatchSet
+	root.OnPatch(func(patchSet PatchSet) { patches = append(patches, patchSet) })
+
+	store.Dispatch(Action{Type: "todos/add", Payload: "a"})
+	store.Dispatch(Action{Type: "filter/set", Payload: "active"}) // not selected
+
+	if len(patches) != 1 {
+		t.Fatalf("expected one patch set, got %d", len(patches))
+	}
+	if patch := patches[0].Patches[0]; patch.Op != PatchSetText || patch.Value != "1 left" {
+		t.Errorf("unexpected patch %+v", patch)
+	}
+}
@@ -0,0 +1,198 @@
+package gouix
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// rootContext holds the Root rendering the current component tree, so
+// components deep in the tree can refresh it
+var rootContext = CreateContext(nil)
+
+// streamChunk is a patch set waiting to be streamed, with the content version
+// it leads to
+type streamChunk struct {
+	patchSet PatchSet
+	version  string
+}
+
+// renderStream tracks the loads a streamed page waits for and the patches
+// their results produce
+type renderStream struct {
+	pending []<-chan struct{}
+	chunks  []streamChunk
+	wake    chan struct{}
+	closed  chan struct{}
+	mutex   sync.Mutex
+}
+
+func newRenderStream() *renderStream {
+	return &renderStream{wake: make(chan struct{}, 1), closed: make(chan struct{})}
+}
+
+// signal wakes the streaming loop
+func (s *renderStream) signal() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// wait adds a load to wait for
+func (s *renderStream) wait(done <-chan struct{}) {
+	s.mutex.Lock()
+	s.pending = append(s.pending, done)
+	s.mutex.Unlock()
+
+	go func() {
+		select {
+		case <-done:
+			s.signal()
+		case <-s.closed:
+		}
+	}()
+}
+
+// push queues a patch set
+func (s *renderStream) push(patchSet PatchSet, version string) {
+	s.mutex.Lock()
+	s.chunks = append(s.chunks, streamChunk{patchSet: patchSet, version: version})
+	s.mutex.Unlock()
+
+	s.signal()
+}
+
+// settled reports whether every load has settled
+func (s *renderStream) settled() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, done := range s.pending {
+		select {
+		case <-done:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// take returns and clears the queued patch sets
+func (s *renderStream) take() []streamChunk {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	chunks := s.chunks
+	s.chunks = nil
+	return chunks
+}
+
+// suspended makes the streams of the root wait for a load started while
+// rendering it
+func (r *Root) suspended(done <-chan struct{}) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for stream := range r.streams {
+		stream.wait(done)
+	}
+}
+
+// Stream writes a page in parts: head, the container with fallbacks for every
+// pending Suspense, then a script swapping in each subtree as its data
+// resolves, and finally tail once everything has settled. Each part is
+// flushed when w is an http.Flusher, so the browser can show the shell before
+// slow queries finish. The container is marked for hydration like
+// RenderHydratable; put the hub's ScriptTag in tail. Stream returns early with
+// the context's error if ctx is done first, such as when the client goes away.
+func (r *Root) Stream(ctx context.Context, w io.Writer, head, tail string) error {
+	stream := newRenderStream()
+
+	r.mutex.Lock()
+	if r.streams == nil {
+		r.streams = make(map[*renderStream]bool)
+	}
+	r.streams[stream] = true
+	r.mutex.Unlock()
+
+	defer func() {
+		r.mutex.Lock()
+		delete(r.streams, stream)
+		r.mutex.Unlock()
+		close(stream.closed)
+	}()
+
+	content, version := r.render()
+
+	// Listeners run under the render lock, so Version matches the patch set
+	unsubscribe := r.Hydrate(version, func(patchSet PatchSet) {
+		stream.push(patchSet, r.Version())
+	})
+	defer unsubscribe()
+
+	if _, err := io.WriteString(w, head+r.container(content, version)); err != nil {
+		return err
+	}
+	flush(w)
+
+	runtime := false
+	for {
+		// Check before writing: a load queues its patches before it settles
+		settled := stream.settled()
+
+		for _, chunk := range stream.take() {
+			if !runtime {
+				if _, err := io.WriteString(w, "<script>"+PatchRuntime+"\n"+StreamRuntime+"</script>"); err != nil {
+					return err
+				}
+				runtime = true
+			}
+
+			patchSet, _ := json.Marshal(chunk.patchSet)
+			version, _ := json.Marshal(chunk.version)
+			if _, err := io.WriteString(w, "<script>_gouix.resolve("+string(patchSet)+", "+string(version)+");</script>"); err != nil {
+				return err
+			}
+		}
+		flush(w)
+
+		if settled {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-stream.wake:
+		}
+	}
+
+	if _, err := io.WriteString(w, tail); err != nil {
+		return err
+	}
+	flush(w)
+
+	return nil
+}
+
+// flush sends buffered output to the client
+func flush(w io.Writer) {
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// StreamRuntime is the client-side script that applies the patch sets of a
+// streamed page as they arrive. Root.Stream includes it when needed; it
+// requires PatchRuntime.
+const StreamRuntime = `(function() {
+  var g = window._gouix = window._gouix || {};
+
+  g.resolve = function(set, version) {
+    if (!g.applyPatches(set) || !version) return;
+    document.querySelector('[data-gouix-root="' + set.root + '"]').setAttribute('data-gouix-version', version);
+  };
+})();`
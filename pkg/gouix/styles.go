@@ -0,0 +1,32 @@
+package gouix
+
+import (
+	"github.com/davidjeba/goscript/pkg/gocsx/scoped"
+)
+
+// Styles collects the scoped CSS of the classes components generate with
+// Class. Serve it as a stylesheet and link it from the page with
+// Styles.LinkTag; a LiveHub sends connected browsers the rules of classes
+// generated after their page was rendered along with the patches using them.
+//
+//	http.Handle("/gouix.css", gouix.Styles)
+var Styles = scoped.NewSheet()
+
+// Class returns a scoped class for a component's style, such as
+// "counter-3f9a1c2e", in place of an inline style attribute. The style is a
+// Style, a map of CSS properties or a struct of them; components rendering
+// the same style share its class and its rules in Styles.
+//
+//	gouix.CreateElement("button", gouix.Props{
+//		"class": gouix.Class("counter-button", gouix.Style{
+//			"padding": "8px 16px",
+//			":hover":  gouix.Style{"background-color": "#e0e0e0"},
+//		}),
+//	}, "+")
+func Class(name string, style interface{}) string {
+	return Styles.Class(name, style)
+}
+
+// Style is a component's style for Class: CSS properties and their values,
+// and nested styles keyed by selector, such as ":hover" or "@media (...)"
+type Style = scoped.Style
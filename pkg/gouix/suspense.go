@@ -0,0 +1,205 @@
+package gouix
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sync"
+)
+
+// Loader loads the data a Suspense waits for. The result is rendered like a
+// child: a Component, a string of HTML or a slice of them. The context is
+// cancelled when the Suspense is unmounted or reloaded.
+type Loader func(ctx context.Context) (interface{}, error)
+
+// suspenseStatus is the state of a Suspense's load
+type suspenseStatus int
+
+const (
+	suspenseIdle suspenseStatus = iota
+	suspenseLoading
+	suspenseResolved
+	suspenseFailed
+)
+
+// suspenseLoad is one run of a loader
+type suspenseLoad struct {
+	done     chan struct{}
+	cancel   context.CancelFunc
+	doneOnce sync.Once
+}
+
+// finish marks the load as settled
+func (l *suspenseLoad) finish() {
+	l.doneOnce.Do(func() {
+		l.cancel()
+		close(l.done)
+	})
+}
+
+// Suspense renders a fallback while its data loads and the loaded content once
+// it resolves. Loading starts on the first render; when it settles, the roots
+// that rendered the Suspense are refreshed, so live clients receive the
+// content as patches and a streamed page has it swapped in. If the loader
+// fails, Render panics with its error, so the nearest ErrorBoundary renders
+// its fallback in place of the content.
+type Suspense struct {
+	BaseComponent
+	fallback      interface{}
+	loader        Loader
+	status        suspenseStatus
+	result        interface{}
+	err           error
+	load          *suspenseLoad
+	roots         map[*Root]bool
+	suspenseMutex sync.Mutex
+}
+
+// NewSuspense creates a Suspense rendering fallback until loader resolves
+func NewSuspense(id ComponentID, fallback interface{}, loader Loader) *Suspense {
+	suspense := &Suspense{fallback: fallback, loader: loader, roots: make(map[*Root]bool)}
+	suspense.init(id, nil)
+	return suspense
+}
+
+// Render implements the Component interface
+func (s *Suspense) Render() string {
+	root, _ := UseContext(rootContext).(*Root)
+
+	s.suspenseMutex.Lock()
+	if root != nil {
+		s.roots[root] = true
+	}
+	if s.status == suspenseIdle {
+		s.start()
+	}
+	status, result, err, load := s.status, s.result, s.err, s.load
+	s.suspenseMutex.Unlock()
+
+	id := html.EscapeString(string(s.GetID()))
+	switch status {
+	case suspenseResolved:
+		return `<div data-gouix-suspense="` + id + `" style="display:contents">` +
+			renderChildren([]interface{}{result}) + `</div>`
+	case suspenseFailed:
+		panic(err)
+	}
+
+	if root != nil {
+		root.suspended(load.done)
+	}
+	return `<div data-gouix-suspense="` + id + `" style="display:contents" aria-busy="true">` +
+		renderChildren([]interface{}{s.fallback}) + `</div>`
+}
+
+// start runs the loader; the caller holds suspenseMutex
+func (s *Suspense) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	load := &suspenseLoad{done: make(chan struct{}), cancel: cancel}
+	s.status = suspenseLoading
+	s.load = load
+
+	go s.run(ctx, load)
+}
+
+// run loads the data and refreshes the roots showing the Suspense
+func (s *Suspense) run(ctx context.Context, load *suspenseLoad) {
+	defer load.finish()
+
+	result, err := s.call(ctx)
+
+	s.suspenseMutex.Lock()
+	if s.load != load {
+		// Reloaded or unmounted meanwhile
+		s.suspenseMutex.Unlock()
+		return
+	}
+	s.result, s.err = result, err
+	s.status = suspenseResolved
+	if err != nil {
+		s.status = suspenseFailed
+	}
+	roots := make([]*Root, 0, len(s.roots))
+	for root := range s.roots {
+		roots = append(roots, root)
+	}
+	s.suspenseMutex.Unlock()
+
+	// Refresh before finishing, so a stream waiting for the load has the
+	// patches queued when it wakes
+	s.notifyStateChange()
+	for _, root := range roots {
+		root.Refresh()
+	}
+}
+
+// call runs the loader, turning a panic into an error
+func (s *Suspense) call(ctx context.Context) (result interface{}, err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = fmt.Errorf("gouix: loading %q panicked: %v", s.GetID(), value)
+		}
+	}()
+
+	return s.loader(ctx)
+}
+
+// Done returns a channel closed when the current load settles. It is nil
+// before the first render.
+func (s *Suspense) Done() <-chan struct{} {
+	s.suspenseMutex.Lock()
+	defer s.suspenseMutex.Unlock()
+
+	if s.load == nil {
+		return nil
+	}
+	return s.load.done
+}
+
+// Result returns the loaded value or error; both are nil while loading
+func (s *Suspense) Result() (interface{}, error) {
+	s.suspenseMutex.Lock()
+	defer s.suspenseMutex.Unlock()
+
+	return s.result, s.err
+}
+
+// Reload discards the loaded value and re-renders the roots showing the
+// Suspense, which show the fallback and load again
+func (s *Suspense) Reload() {
+	s.suspenseMutex.Lock()
+	roots := make([]*Root, 0, len(s.roots))
+	for root := range s.roots {
+		roots = append(roots, root)
+	}
+	s.reset()
+	s.suspenseMutex.Unlock()
+
+	s.notifyStateChange()
+	for _, root := range roots {
+		root.Refresh()
+	}
+}
+
+// reset cancels a running load and forgets the result; the caller holds
+// suspenseMutex
+func (s *Suspense) reset() {
+	if s.load != nil {
+		s.load.finish()
+	}
+	s.status = suspenseIdle
+	s.result, s.err, s.load = nil, nil, nil
+}
+
+// Unmount implements the Component interface. It cancels a running load.
+func (s *Suspense) Unmount() {
+	s.BaseComponent.Unmount()
+
+	s.suspenseMutex.Lock()
+	defer s.suspenseMutex.Unlock()
+
+	if s.status == suspenseLoading {
+		s.reset()
+	}
+	s.roots = make(map[*Root]bool)
+}
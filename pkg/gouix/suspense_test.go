@@ -0,0 +1,152 @@
+package gouix
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flushRecorder records what was written before each flush
+type flushRecorder struct {
+	bytes.Buffer
+	flushes []string
+	onFlush func()
+}
+
+func (f *flushRecorder) Flush() {
+	f.flushes = append(f.flushes, f.String())
+	if f.onFlush != nil {
+		f.onFlush()
+	}
+}
+
+// waitFor loads value once release is closed
+func waitFor(release chan struct{}, value interface{}, err error) Loader {
+	return func(ctx context.Context) (interface{}, error) {
+		select {
+		case <-release:
+			return value, err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func TestSuspenseRefreshesRootWhenResolved(t *testing.T) {
+	release := make(chan struct{})
+	suspense := NewSuspense("stats", "<p>Loading…</p>", waitFor(release, "<p>42 users</p>", nil))
+
+	// Nested, so the root only hears of the load through the Suspense
+	page := NewProvider("page", CreateContext(nil), nil, suspense)
+	root := NewRoot("page", page)
+
+	if html := root.Render(); !strings.Contains(html, `aria-busy="true"><p>Loading…</p></div>`) {
+		t.Fatalf("expected the fallback, got %s", html)
+	}
+
+	patches := make(chan PatchSet, 1)
+	root.OnPatch(func(patchSet PatchSet) { patches <- patchSet })
+
+	close(release)
+	<-suspense.Done()
+
+	select {
+	case patchSet := <-patches:
+		if patch := patchSet.Patches[len(patchSet.Patches)-1]; patch.Op != PatchSetText || patch.Value != "42 users" {
+			t.Errorf("expected the loaded content to be patched in, got %+v", patchSet.Patches)
+		}
+	default:
+		t.Fatalf("expected a patch set once loaded")
+	}
+	if result, err := suspense.Result(); result != "<p>42 users</p>" || err != nil {
+		t.Errorf("unexpected result %v, %v", result, err)
+	}
+}
+
+func TestSuspenseLoaderErrorReachesBoundary(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	suspense := NewSuspense("stats", "…", waitFor(release, nil, errors.New("query failed")))
+	boundary := NewErrorBoundary("guard", func(err *RenderError) string {
+		return "<p>" + err.Unwrap().Error() + "</p>"
+	}, suspense)
+
+	root := NewRoot("guard", boundary)
+	root.Render()
+	<-suspense.Done()
+
+	if html := root.Render(); !strings.Contains(html, "<p>query failed</p>") {
+		t.Errorf("expected the boundary fallback, got %s", html)
+	}
+}
+
+func TestRootStreamFlushesShellFirst(t *testing.T) {
+	users := make(chan struct{})
+	orders := make(chan struct{})
+	usersSuspense := NewSuspense("users", "<p>…</p>", waitFor(users, "<p>Users</p>", nil))
+	ordersSuspense := NewSuspense("orders", "<p>…</p>", waitFor(orders, "<p>Orders</p>", nil))
+	page := NewProvider("page", CreateContext(nil), nil, usersSuspense, ordersSuspense)
+	root := NewRoot("page", page)
+
+	w := &flushRecorder{}
+	w.onFlush = func() {
+		// Resolve one load per flush, after the shell is out
+		switch len(w.flushes) {
+		case 1:
+			close(orders)
+		case 2:
+			close(users)
+		}
+	}
+
+	if err := root.Stream(context.Background(), w, "<html><body>", "</body></html>"); err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+
+	shell := w.flushes[0]
+	if !strings.HasPrefix(shell, `<html><body><div data-gouix-root="page" data-gouix-version=`) ||
+		strings.Contains(shell, "Users") || strings.Contains(shell, "Orders") {
+		t.Fatalf("expected only the shell in the first flush, got %s", shell)
+	}
+
+	output := w.String()
+	if !strings.HasSuffix(output, "</body></html>") {
+		t.Errorf("expected the tail last, got %s", output)
+	}
+	if strings.Count(output, "_gouix.resolve(") < 2 || !strings.Contains(output, `"value":"Users"`) || !strings.Contains(output, `"value":"Orders"`) {
+		t.Errorf("expected both subtrees streamed as patches, got %s", output)
+	}
+	if strings.Count(output, "g.applyPatches = function") != 1 {
+		t.Errorf("expected the runtime to be written once")
+	}
+	if !strings.Contains(output, `"`+root.Version()+`");</script>`) {
+		t.Errorf("expected the last patch to carry the final version %s", root.Version())
+	}
+}
+
+func TestRootStreamStopsWhenContextDone(t *testing.T) {
+	suspense := NewSuspense("slow", "…", waitFor(make(chan struct{}), "", nil))
+	root := NewRoot("slow", suspense)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	var w flushRecorder
+	if err := root.Stream(ctx, &w, "", "</body>"); err != context.DeadlineExceeded {
+		t.Fatalf("expected the deadline error, got %v", err)
+	}
+	if strings.HasSuffix(w.String(), "</body>") {
+		t.Errorf("expected no tail after the context ended")
+	}
+
+	done := suspense.Done()
+	root.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("expected unmounting to cancel the load")
+	}
+}
@@ -0,0 +1,211 @@
+package gouix
+
+import (
+	"encoding/json"
+	"html"
+	"sync"
+)
+
+// Tab is a tab of Tabs
+type Tab struct {
+	// Key identifies the tab; it is part of element IDs, so use letters,
+	// digits and dashes
+	Key string
+
+	// Label is the text of the tab
+	Label string
+
+	// Content is rendered in the panel while the tab is selected: a
+	// Component, markup, or a slice of them
+	Content interface{}
+
+	// Disabled tabs can be focused but not selected
+	Disabled bool
+}
+
+// Tabs shows one of several panels, chosen with a tab list. The tab list is
+// a RovingFocus group: the arrow keys move between the tabs and select the
+// one focused, Home and End select the first and last, and Tab moves on to
+// the panel. The tabs, tab list and panel are wired with their ARIA roles,
+// aria-selected, aria-controls and aria-labelledby.
+type Tabs struct {
+	BaseComponent
+
+	// Label names the tab list for assistive technology
+	Label string
+
+	// Orientation is the direction of the tab list and its arrow keys,
+	// Horizontal by default
+	Orientation Orientation
+
+	// Manual lets the arrow keys only move focus; the focused tab is then
+	// selected with Enter or Space. Use it when selecting a tab is slow.
+	Manual bool
+
+	tabs     []Tab
+	selected string
+	onChange []func(key string)
+	mutex    sync.RWMutex
+}
+
+// NewTabs creates tabs with the first one that is not disabled selected
+func NewTabs(id ComponentID, tabs ...Tab) *Tabs {
+	t := &Tabs{Orientation: Horizontal, tabs: tabs}
+	t.init(id, nil)
+	for _, tab := range tabs {
+		if !tab.Disabled {
+			t.selected = tab.Key
+			break
+		}
+	}
+
+	t.On("select", func(event Event) interface{} {
+		key, _ := event.Data["key"].(string)
+		t.Select(key)
+		return nil
+	})
+
+	return t
+}
+
+// Select selects a tab and reports whether it could; disabled and unknown
+// tabs are not selected. The OnChange functions are called when the
+// selection changes.
+func (t *Tabs) Select(key string) bool {
+	t.mutex.Lock()
+	found := false
+	for _, tab := range t.tabs {
+		if tab.Key == key && !tab.Disabled {
+			found = true
+			break
+		}
+	}
+	changed := found && t.selected != key
+	if changed {
+		t.selected = key
+	}
+	handlers := append([]func(string){}, t.onChange...)
+	t.mutex.Unlock()
+
+	if changed {
+		t.notifyStateChange()
+		for _, handler := range handlers {
+			handler(key)
+		}
+	}
+	return found
+}
+
+// Selected returns the key of the selected tab
+func (t *Tabs) Selected() string {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	return t.selected
+}
+
+// OnChange adds a function called with the key of the tab selected
+func (t *Tabs) OnChange(handler func(key string)) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.onChange = append(t.onChange, handler)
+}
+
+// FindComponent returns the selected tab's content, or a component inside
+// it, so the live hub can route browser events to it
+func (t *Tabs) FindComponent(id ComponentID) Component {
+	t.mutex.RLock()
+	var content interface{}
+	for _, tab := range t.tabs {
+		if tab.Key == t.selected {
+			content = tab.Content
+		}
+	}
+	t.mutex.RUnlock()
+
+	component, ok := content.(Component)
+	if !ok {
+		return nil
+	}
+	if component.GetID() == id {
+		return component
+	}
+	if finder, ok := component.(ComponentFinder); ok {
+		return finder.FindComponent(id)
+	}
+	return nil
+}
+
+// Render implements the Component interface
+func (t *Tabs) Render() string {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	id := string(t.GetID())
+	panelID := html.EscapeString(id + "-panel")
+	orientation := t.Orientation
+	if orientation == "" {
+		orientation = Horizontal
+	}
+
+	buttons := make([]interface{}, len(t.tabs))
+	var content interface{}
+	selectedTab := ""
+	for i, tab := range t.tabs {
+		tabID := html.EscapeString(id + "-tab-" + tab.Key)
+		key, _ := json.Marshal(tab.Key)
+		selected := tab.Key == t.selected
+		props := Props{
+			"type":                   "button",
+			"role":                   "tab",
+			"id":                     tabID,
+			"class":                  "gouix-tab",
+			"aria-selected":          "false",
+			"tabindex":               "-1",
+			"data-gouix-roving-item": true,
+		}
+		if selected {
+			props["aria-selected"] = "true"
+			props["aria-controls"] = panelID
+			props["tabindex"] = "0"
+			content = tab.Content
+			selectedTab = tabID
+		}
+		if tab.Disabled {
+			props["aria-disabled"] = "true"
+		} else {
+			props["onclick"] = dispatchJS(t.GetID(), "select", "{key: "+string(key)+"}")
+		}
+		buttons[i] = CreateElement("button", props, html.EscapeString(tab.Label))
+	}
+
+	list := Props{
+		"role":              "tablist",
+		"class":             "gouix-tablist",
+		"aria-orientation":  html.EscapeString(string(orientation)),
+		"data-gouix-roving": html.EscapeString(string(orientation)),
+	}
+	if t.Label != "" {
+		list["aria-label"] = html.EscapeString(t.Label)
+	}
+	if !t.Manual {
+		list["data-gouix-roving-activate"] = true
+	}
+
+	children := []interface{}{CreateElement("div", list, buttons...)}
+	if selectedTab != "" {
+		children = append(children, CreateElement("div", Props{
+			"role":            "tabpanel",
+			"id":              panelID,
+			"class":           "gouix-tabpanel",
+			"aria-labelledby": selectedTab,
+			"tabindex":        "0",
+		}, renderChildren([]interface{}{content})))
+	}
+
+	return CreateElement("div", Props{
+		"id":    html.EscapeString(id),
+		"class": "gouix-tabs gouix-tabs-" + html.EscapeString(string(orientation)),
+	}, children...)
+}
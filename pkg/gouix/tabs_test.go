@@ -0,0 +1,60 @@
+package gouix
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTabsSelectThroughHub(t *testing.T) {
+	profile := NewBaseComponent("profile-form", nil)
+	tabs := NewTabs("settings",
+		Tab{Key: "general", Label: "General", Content: "<p>General settings</p>"},
+		Tab{Key: "profile", Label: "Profile", Content: profile},
+		Tab{Key: "billing", Label: "Billing", Disabled: true},
+	)
+	tabs.Label = "Settings"
+
+	var changes []string
+	tabs.OnChange(func(key string) { changes = append(changes, key) })
+
+	html := tabs.Render()
+	for _, expected := range []string{
+		`<div aria-label="Settings" aria-orientation="horizontal" class="gouix-tablist" data-gouix-roving="horizontal" data-gouix-roving-activate role="tablist">`,
+		`<button aria-controls="settings-panel" aria-selected="true" class="gouix-tab" data-gouix-roving-item id="settings-tab-general" onclick=`,
+		`role="tab" tabindex="0" type="button">General</button>`,
+		`<button aria-disabled="true" aria-selected="false" class="gouix-tab" data-gouix-roving-item id="settings-tab-billing" role="tab" tabindex="-1" type="button">Billing</button>`,
+		`<div aria-labelledby="settings-tab-general" class="gouix-tabpanel" id="settings-panel" role="tabpanel" tabindex="0"><p>General settings</p></div>`,
+	} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("expected %s in %s", expected, html)
+		}
+	}
+	if issues, err := CheckA11y(html, A11yOptions{}); err != nil || len(issues) != 0 {
+		t.Errorf("expected accessible tabs, got %v, %v", issues, err)
+	}
+
+	hub := NewLiveHub()
+	hub.Mount("settings", tabs)
+	if err := hub.Dispatch(Event{Type: "select", Target: "settings", Data: map[string]interface{}{"key": "profile"}}); err != nil {
+		t.Fatalf("Dispatch returned error: %v", err)
+	}
+	if tabs.Selected() != "profile" || tabs.FindComponent("profile-form") != profile {
+		t.Fatalf("expected the profile tab and its form, got %s", tabs.Selected())
+	}
+	if html := tabs.Render(); !strings.Contains(html, `aria-labelledby="settings-tab-profile"`) {
+		t.Errorf("expected the panel to be labelled by the profile tab, got %s", html)
+	}
+
+	if tabs.Select("billing") || tabs.Select("missing") || !tabs.Select("profile") {
+		t.Error("expected only enabled tabs to be selected")
+	}
+	if strings.Join(changes, ",") != "profile" {
+		t.Errorf("expected one change, got %v", changes)
+	}
+
+	tabs.Manual = true
+	tabs.Orientation = Vertical
+	if html := tabs.Render(); strings.Contains(html, "data-gouix-roving-activate") || !strings.Contains(html, `aria-orientation="vertical"`) {
+		t.Errorf("expected a vertical tab list selecting on Enter, got %s", html)
+	}
+}
@@ -0,0 +1,56 @@
+package testing
+
+import (
+	"os"
+
+	"github.com/davidjeba/goscript/pkg/gouix"
+)
+
+// A11yEnv is the environment variable that makes every Screen check its DOM
+// for accessibility issues after each render, as "gopm uix:test --a11y" does
+const A11yEnv = "GOUIX_A11Y"
+
+// A11y configures the accessibility checks, for example with the theme colors
+// of the application
+var A11y gouix.A11yOptions
+
+// AssertAccessible checks the current DOM for accessibility issues and fails
+// the test for each one
+func (s *Screen) AssertAccessible() {
+	s.t.Helper()
+
+	for _, issue := range s.checkA11y() {
+		s.t.Errorf("accessibility: %s", issue)
+	}
+}
+
+// auditA11y reports issues not seen before when A11yEnv is set, so an issue
+// is reported once however many times the screen rerenders
+func (s *Screen) auditA11y() {
+	s.t.Helper()
+
+	if os.Getenv(A11yEnv) == "" {
+		return
+	}
+	for _, issue := range s.checkA11y() {
+		key := issue.String()
+		if s.a11yReported[key] {
+			continue
+		}
+		if s.a11yReported == nil {
+			s.a11yReported = make(map[string]bool)
+		}
+		s.a11yReported[key] = true
+		s.t.Errorf("accessibility: %s", issue)
+	}
+}
+
+func (s *Screen) checkA11y() []gouix.A11yIssue {
+	s.t.Helper()
+
+	issues, err := gouix.CheckA11y(s.HTML(), A11y)
+	if err != nil {
+		s.t.Fatalf("checking accessibility: %v", err)
+	}
+	return issues
+}
@@ -0,0 +1,113 @@
+package testing
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/davidjeba/goscript/pkg/gouix"
+)
+
+// Drag drags the element matching from onto the element matching to
+func (s *Screen) Drag(from, to string) {
+	s.t.Helper()
+	s.Get(from).DragTo(s.Get(to))
+}
+
+// DragTo drags the element, or the draggable element containing it, onto
+// target, as the drag runtime does. Dropped on an item of a drop zone or
+// sortable list, it takes that item's position; dropped on the zone itself,
+// it goes last. The source component receives "dragstart" and "dragend".
+func (e *Element) DragTo(target *Element) {
+	t := e.screen.t
+	t.Helper()
+
+	dragged := e.Closest("[data-gouix-drag]")
+	if dragged == nil {
+		t.Fatalf("%s is not draggable", e.HTML())
+	}
+	var item gouix.DragItem
+	if err := json.Unmarshal([]byte(dragged.Attr("data-gouix-drag")), &item); err != nil {
+		t.Fatalf("invalid data-gouix-drag on %s: %v", dragged.HTML(), err)
+	}
+
+	var zone *Element
+	for element := target; element != nil && zone == nil; element = element.parent {
+		if (element.HasAttr("data-gouix-dropzone") || element.HasAttr("data-gouix-sortable")) && accepts(element, item.Type) {
+			zone = element
+		}
+	}
+	if zone == nil {
+		t.Fatalf("no drop zone accepting %q items at %s", item.Type, target.HTML())
+	}
+
+	items := zoneItems(zone)
+	index := len(items)
+	if over := target.Closest("[data-gouix-drag]"); over != nil {
+		for i, candidate := range items {
+			if candidate.Node == over.Node {
+				index = i
+			}
+		}
+	}
+
+	e.screen.notify(item.Source, "dragstart", map[string]interface{}{"type": item.Type, "data": item.Data})
+
+	id := zone.Attr("data-gouix-dropzone")
+	if zone.HasAttr("data-gouix-sortable") && zone.Attr("data-gouix-sortable") == string(item.Source) {
+		id = zone.Attr("data-gouix-sortable")
+		e.screen.Dispatch(gouix.ComponentID(id), "reorder", map[string]interface{}{"key": item.Data, "to": float64(index)})
+	} else {
+		if id == "" {
+			id = zone.Attr("data-gouix-sortable")
+		}
+		e.screen.Dispatch(gouix.ComponentID(id), "drop", map[string]interface{}{
+			"type":   item.Type,
+			"data":   item.Data,
+			"source": string(item.Source),
+			"index":  float64(index),
+		})
+	}
+
+	e.screen.notify(item.Source, "dragend", map[string]interface{}{"type": item.Type, "data": item.Data, "dropped": true, "zone": id})
+}
+
+// accepts reports whether a zone takes items of a type
+func accepts(zone *Element, itemType string) bool {
+	accepted := zone.Attr("data-gouix-accepts")
+	if accepted == "" {
+		return true
+	}
+	for _, name := range strings.Fields(accepted) {
+		if name == itemType {
+			return true
+		}
+	}
+	return false
+}
+
+// zoneItems returns the draggable elements that belong to a zone rather than
+// to a zone nested in it
+func zoneItems(zone *Element) []*Element {
+	var items []*Element
+	for _, candidate := range zone.QueryAll("[data-gouix-drag]") {
+		owner := candidate.parent
+		for owner != nil && owner != zone && !owner.HasAttr("data-gouix-dropzone") && !owner.HasAttr("data-gouix-sortable") {
+			owner = owner.parent
+		}
+		if owner == zone {
+			items = append(items, candidate)
+		}
+	}
+	return items
+}
+
+// notify sends a drag notification to the item's source, which like the
+// runtime's is dropped if no component handles it
+func (s *Screen) notify(source gouix.ComponentID, event string, data map[string]interface{}) {
+	if source == "" {
+		return
+	}
+	if err := s.hub.Dispatch(gouix.Event{Type: event, Target: source, Data: data, Bubbles: true}); err == nil {
+		s.Rerender()
+	}
+}
@@ -0,0 +1,506 @@
+package testing
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/davidjeba/goscript/pkg/gouix"
+)
+
+// Element is an element of a Screen's DOM
+type Element struct {
+	// Node is the element's virtual DOM node
+	Node *gouix.VNode
+
+	parent *Element
+	screen *Screen
+}
+
+// Tag returns the element's tag name
+func (e *Element) Tag() string {
+	return e.Node.Tag
+}
+
+// Attr returns the value of an attribute, or "" if it is not set
+func (e *Element) Attr(name string) string {
+	value, _ := e.Node.Attr(name)
+	return value
+}
+
+// HasAttr reports whether an attribute is set
+func (e *Element) HasAttr(name string) bool {
+	_, ok := e.Node.Attr(name)
+	return ok
+}
+
+// Text returns the element's text content
+func (e *Element) Text() string {
+	return textContent(e.Node.Children)
+}
+
+// HTML returns the element's HTML
+func (e *Element) HTML() string {
+	return e.Node.HTML()
+}
+
+// Parent returns the parent element, or nil at the top of the root
+func (e *Element) Parent() *Element {
+	return e.parent
+}
+
+// Closest returns the element or its nearest ancestor matching a single
+// compound selector, such as "form" or "[data-gouix-component]"
+func (e *Element) Closest(selector string) *Element {
+	compound := parseSelector(selector)
+	for element := e; element != nil; element = element.parent {
+		if element.matches(compound[len(compound)-1]) {
+			return element
+		}
+	}
+	return nil
+}
+
+// Query returns the first descendant matching selector, or nil
+func (e *Element) Query(selector string) *Element {
+	if matches := e.QueryAll(selector); len(matches) > 0 {
+		return matches[0]
+	}
+	return nil
+}
+
+// QueryAll returns the descendants matching selector in document order
+func (e *Element) QueryAll(selector string) []*Element {
+	return queryAll(e.screen, e, e.Node.Children, parseSelector(selector))
+}
+
+// Query returns the first element matching selector, or nil. Selectors combine
+// tag names, #id, .class, [attr] and [attr=value] with descendant (space) and
+// child (>) combinators.
+func (s *Screen) Query(selector string) *Element {
+	s.t.Helper()
+
+	if matches := s.QueryAll(selector); len(matches) > 0 {
+		return matches[0]
+	}
+	return nil
+}
+
+// QueryAll returns the elements matching selector in document order
+func (s *Screen) QueryAll(selector string) []*Element {
+	s.t.Helper()
+
+	return queryAll(s, nil, s.dom(), parseSelector(selector))
+}
+
+// Get returns the first element matching selector and fails the test if there
+// is none
+func (s *Screen) Get(selector string) *Element {
+	s.t.Helper()
+
+	element := s.Query(selector)
+	if element == nil {
+		s.t.Fatalf("no element matches %s in %s", selector, s.HTML())
+	}
+	return element
+}
+
+// GetByText returns the innermost element whose text contains text and fails
+// the test if there is none
+func (s *Screen) GetByText(text string) *Element {
+	s.t.Helper()
+
+	var found *Element
+	var walk func(parent *Element, nodes []*gouix.VNode)
+	walk = func(parent *Element, nodes []*gouix.VNode) {
+		for _, node := range nodes {
+			if node.Type != gouix.ElementNode || !strings.Contains(textContent([]*gouix.VNode{node}), text) {
+				continue
+			}
+			element := &Element{Node: node, parent: parent, screen: s}
+			found = element
+			walk(element, node.Children)
+			return
+		}
+	}
+	walk(nil, s.dom())
+
+	if found == nil {
+		s.t.Fatalf("no element contains the text %q in %s", text, s.HTML())
+	}
+	return found
+}
+
+// Click clicks the element matching selector
+func (s *Screen) Click(selector string) {
+	s.t.Helper()
+	s.Get(selector).Click()
+}
+
+// Input types value into the element matching selector
+func (s *Screen) Input(selector, value string) {
+	s.t.Helper()
+	s.Get(selector).Input(value)
+}
+
+// Submit submits the form matching selector, or the form containing it
+func (s *Screen) Submit(selector string) {
+	s.t.Helper()
+	s.Get(selector).Submit()
+}
+
+// Click fires a click on the element
+func (e *Element) Click() {
+	e.screen.t.Helper()
+	e.Fire("click", nil)
+}
+
+// Input fires an input event with value, or a change event for elements that
+// only handle changes, such as a select
+func (e *Element) Input(value string) {
+	e.screen.t.Helper()
+
+	if e.handler("input") != nil {
+		e.Fire("input", value)
+		return
+	}
+	e.Fire("change", value)
+}
+
+// Submit fires a submit event on the form containing the element
+func (e *Element) Submit() {
+	e.screen.t.Helper()
+
+	form := e.Closest("form")
+	if form == nil {
+		e.screen.t.Fatalf("%s is not in a form", e.HTML())
+	}
+	form.Fire("submit", nil)
+}
+
+// Fire fires a DOM event on the element. Like the browser it bubbles until an
+// element handles it, with an inline _gouix.dispatchEvent handler or a
+// data-gouix-on binding, and the resulting event is dispatched to the server
+// component. value stands for the element's value.
+func (e *Element) Fire(domEvent string, value interface{}) {
+	t := e.screen.t
+	t.Helper()
+
+	handler := e.handler(domEvent)
+	if handler == nil {
+		t.Fatalf("no %s handler on %s or its ancestors", domEvent, e.HTML())
+	}
+	target, event, data, err := handler(value)
+	if err != nil {
+		t.Fatalf("%s handler on %s: %v", domEvent, e.HTML(), err)
+	}
+	e.screen.Dispatch(target, event, data)
+}
+
+// eventHandler turns a fired value into the event sent to the server
+type eventHandler func(value interface{}) (gouix.ComponentID, string, map[string]interface{}, error)
+
+// dispatchCall matches inline handlers written by components
+var dispatchCall = regexp.MustCompile(`_gouix\.dispatchEvent\(\s*(?:'([^']*)'|"((?:[^"\\]|\\.)*)")\s*,\s*['"]([^'"]+)['"]\s*(?:,\s*(\{.*\}))?\s*\)`)
+
+// bareKey matches unquoted keys of a JavaScript object literal
+var bareKey = regexp.MustCompile(`([{,]\s*)([A-Za-z_$][\w$]*)\s*:`)
+
+// handler finds the handler for a DOM event on the element or its ancestors
+func (e *Element) handler(domEvent string) eventHandler {
+	for element := e; element != nil; element = element.parent {
+		if script, ok := element.Node.Attr("on" + domEvent); ok {
+			if match := dispatchCall.FindStringSubmatch(script); match != nil {
+				return element.inlineHandler(match)
+			}
+		}
+		if name := binding(element, domEvent); name != "" {
+			return element.boundHandler(domEvent, name)
+		}
+	}
+	return nil
+}
+
+// inlineHandler evaluates an inline _gouix.dispatchEvent call
+func (e *Element) inlineHandler(match []string) eventHandler {
+	return func(value interface{}) (gouix.ComponentID, string, map[string]interface{}, error) {
+		target := match[1]
+		if match[2] != "" {
+			if err := json.Unmarshal([]byte(`"`+match[2]+`"`), &target); err != nil {
+				return "", "", nil, err
+			}
+		}
+
+		data := map[string]interface{}{}
+		if literal := match[4]; literal != "" {
+			// Quote keys first, so substituted values are left alone
+			literal = bareKey.ReplaceAllString(literal, `$1"$2":`)
+			encoded, _ := json.Marshal(value)
+			if value == nil {
+				encoded, _ = json.Marshal(e.Attr("value"))
+			}
+			literal = strings.ReplaceAll(literal, "this.value", string(encoded))
+			literal = strings.ReplaceAll(literal, "this.checked", fmt.Sprint(e.HasAttr("checked")))
+			if err := json.Unmarshal([]byte(literal), &data); err != nil {
+				return "", "", nil, fmt.Errorf("cannot evaluate %s: %v", match[4], err)
+			}
+		}
+
+		return gouix.ComponentID(target), match[3], data, nil
+	}
+}
+
+// binding returns the component event a data-gouix-on attribute binds to a DOM
+// event
+func binding(e *Element, domEvent string) string {
+	for _, pair := range strings.Fields(e.Attr("data-gouix-on")) {
+		parts := strings.Split(pair, ":")
+		if len(parts) == 2 && parts[0] == domEvent {
+			return parts[1]
+		}
+	}
+	return ""
+}
+
+// boundHandler sends a data-gouix-on event the way the hydration runtime does
+func (e *Element) boundHandler(domEvent, name string) eventHandler {
+	return func(value interface{}) (gouix.ComponentID, string, map[string]interface{}, error) {
+		target := e.Attr("data-gouix-target")
+		if target == "" {
+			if owner := e.Closest("[data-gouix-component]"); owner != nil {
+				target = owner.Attr("data-gouix-component")
+			}
+		}
+		if target == "" {
+			return "", "", nil, fmt.Errorf("no data-gouix-target or enclosing data-gouix-component")
+		}
+
+		data := map[string]interface{}{}
+		switch {
+		case domEvent == "submit" && e.Tag() == "form":
+			for _, field := range e.QueryAll("[name]") {
+				if fieldValue, ok := field.formValue(); ok {
+					data[field.Attr("name")] = fieldValue
+				}
+			}
+		case value != nil:
+			data["value"] = value
+		case e.HasAttr("value"):
+			data["value"] = e.Attr("value")
+		}
+		if kind := e.Attr("type"); kind == "checkbox" || kind == "radio" {
+			data["checked"] = e.HasAttr("checked")
+		}
+
+		return gouix.ComponentID(target), name, data, nil
+	}
+}
+
+// formValue returns the value a form field submits
+func (e *Element) formValue() (string, bool) {
+	switch e.Tag() {
+	case "textarea":
+		return e.Text(), true
+	case "select":
+		options := e.QueryAll("option")
+		for _, option := range options {
+			if option.HasAttr("selected") {
+				return option.Attr("value"), true
+			}
+		}
+		if len(options) > 0 {
+			return options[0].Attr("value"), true
+		}
+		return "", true
+	case "input":
+		if kind := e.Attr("type"); (kind == "checkbox" || kind == "radio") && !e.HasAttr("checked") {
+			return "", false
+		}
+		return e.Attr("value"), true
+	}
+	return "", false
+}
+
+// textContent concatenates the text of nodes and their descendants
+func textContent(nodes []*gouix.VNode) string {
+	var b strings.Builder
+	var walk func(nodes []*gouix.VNode)
+	walk = func(nodes []*gouix.VNode) {
+		for _, node := range nodes {
+			switch node.Type {
+			case gouix.TextNode:
+				b.WriteString(node.Text)
+			case gouix.ElementNode:
+				if node.Tag != "script" && node.Tag != "style" {
+					walk(node.Children)
+				}
+			}
+		}
+	}
+	walk(nodes)
+	return b.String()
+}
+
+// compoundSelector matches a single element
+type compoundSelector struct {
+	tag     string
+	id      string
+	classes []string
+	attrs   []attrSelector
+
+	// child is true when the element must be a direct child of the element
+	// matched by the previous compound
+	child bool
+}
+
+// attrSelector matches [name] or [name=value]
+type attrSelector struct {
+	name     string
+	value    string
+	hasValue bool
+}
+
+// selectorToken splits a selector into compounds and combinators
+var selectorToken = regexp.MustCompile(`\[[^\]]*\]|>|[^\s>\[]+|\s+`)
+
+// simpleToken splits a compound into its parts
+var simpleToken = regexp.MustCompile(`^[A-Za-z][\w-]*|#[\w-]+|\.[\w-]+|\[[^\]]*\]`)
+
+// parseSelector parses a selector into compounds, outermost first
+func parseSelector(selector string) []compoundSelector {
+	var compounds []compoundSelector
+	current := compoundSelector{}
+	empty := true
+	child := false
+
+	finish := func() {
+		if !empty {
+			current.child = child
+			compounds = append(compounds, current)
+			current = compoundSelector{}
+			empty = true
+			child = false
+		}
+	}
+
+	for _, token := range selectorToken.FindAllString(strings.TrimSpace(selector), -1) {
+		switch {
+		case strings.TrimSpace(token) == "":
+			finish()
+		case token == ">":
+			finish()
+			child = true
+		case strings.HasPrefix(token, "["):
+			current.attrs = append(current.attrs, parseAttrSelector(token))
+			empty = false
+		default:
+			for _, part := range simpleToken.FindAllString(token, -1) {
+				switch part[0] {
+				case '#':
+					current.id = part[1:]
+				case '.':
+					current.classes = append(current.classes, part[1:])
+				case '[':
+					current.attrs = append(current.attrs, parseAttrSelector(part))
+				default:
+					current.tag = strings.ToLower(part)
+				}
+			}
+			empty = false
+		}
+	}
+	finish()
+
+	if len(compounds) == 0 {
+		compounds = append(compounds, compoundSelector{})
+	}
+	return compounds
+}
+
+// parseAttrSelector parses "[name]" or "[name=value]"
+func parseAttrSelector(token string) attrSelector {
+	body := strings.TrimSuffix(strings.TrimPrefix(token, "["), "]")
+	eq := strings.IndexByte(body, '=')
+	if eq < 0 {
+		return attrSelector{name: strings.TrimSpace(body)}
+	}
+	value := strings.TrimSpace(body[eq+1:])
+	value = strings.Trim(value, `"'`)
+	return attrSelector{name: strings.TrimSpace(body[:eq]), value: value, hasValue: true}
+}
+
+// matches reports whether the element matches a compound selector
+func (e *Element) matches(compound compoundSelector) bool {
+	if e.Node.Type != gouix.ElementNode {
+		return false
+	}
+	if compound.tag != "" && e.Node.Tag != compound.tag {
+		return false
+	}
+	if compound.id != "" && e.Attr("id") != compound.id {
+		return false
+	}
+	classes := strings.Fields(e.Attr("class"))
+	for _, class := range compound.classes {
+		found := false
+		for _, c := range classes {
+			if c == class {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, attr := range compound.attrs {
+		value, ok := e.Node.Attr(attr.name)
+		if !ok || (attr.hasValue && value != attr.value) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesChain reports whether the element matches the last compound and its
+// ancestors, up to but excluding scope, match the ones before it
+func (e *Element) matchesChain(compounds []compoundSelector, scope *Element) bool {
+	last := len(compounds) - 1
+	if !e.matches(compounds[last]) {
+		return false
+	}
+	if last == 0 {
+		return true
+	}
+
+	for ancestor := e.parent; ancestor != nil && ancestor != scope; ancestor = ancestor.parent {
+		if ancestor.matchesChain(compounds[:last], scope) {
+			return true
+		}
+		if compounds[last].child {
+			break
+		}
+	}
+	return false
+}
+
+// queryAll returns the elements under nodes matching the compounds
+func queryAll(s *Screen, scope *Element, nodes []*gouix.VNode, compounds []compoundSelector) []*Element {
+	var matches []*Element
+	var walk func(parent *Element, nodes []*gouix.VNode)
+	walk = func(parent *Element, nodes []*gouix.VNode) {
+		for _, node := range nodes {
+			if node.Type != gouix.ElementNode {
+				continue
+			}
+			element := &Element{Node: node, parent: parent, screen: s}
+			if element.matchesChain(compounds, scope) {
+				matches = append(matches, element)
+			}
+			walk(element, node.Children)
+		}
+	}
+	walk(scope, nodes)
+	return matches
+}
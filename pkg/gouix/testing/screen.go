@@ -0,0 +1,260 @@
+// Package testing renders GoUIX components for tests. A Screen keeps the DOM a
+// browser would show, updated only through the patches the server sends, so
+// tests exercise the same render, event and diff path as a live page:
+//
+//	screen := uixtesting.Render(t, NewCounter("counter", nil))
+//	screen.Click("#counter-increment")
+//	screen.AssertText("#counter-count", "1")
+//	screen.AssertState("count", 1)
+//	screen.MatchSnapshot()
+package testing
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/davidjeba/goscript/pkg/gouix"
+)
+
+// T is the part of *testing.T a Screen uses
+type T interface {
+	Helper()
+	Name() string
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+	Cleanup(func())
+}
+
+// Screen is a rendered component with the DOM its patches produce
+type Screen struct {
+	// Component is the rendered component
+	Component gouix.Component
+
+	// Root renders the component and diffs its output
+	Root *gouix.Root
+
+	t       T
+	hub     *gouix.LiveHub
+	nodes   []*gouix.VNode
+	patches []gouix.PatchSet
+	err     error
+	mutex   sync.Mutex
+
+	a11yReported map[string]bool
+}
+
+// Render renders a component and mounts it like a page would. Events reach the
+// component and its descendants; the root is closed when the test ends.
+func Render(t T, component gouix.Component) *Screen {
+	t.Helper()
+
+	id := string(component.GetID())
+	if id == "" {
+		id = "screen"
+	}
+
+	hub := gouix.NewLiveHub()
+	root := hub.Mount(id, component)
+	registerDescendants(hub, root, component.GetChildren())
+
+	s := &Screen{Component: component, Root: root, t: t, hub: hub}
+
+	nodes, err := containerChildren(root.Render())
+	if err != nil {
+		t.Fatalf("rendering %s: %v", id, err)
+	}
+	s.nodes = nodes
+
+	root.OnPatch(s.apply)
+	t.Cleanup(root.Close)
+
+	s.auditA11y()
+	return s
+}
+
+// registerDescendants makes the component children reachable by events
+func registerDescendants(hub *gouix.LiveHub, root *gouix.Root, children []interface{}) {
+	for _, child := range children {
+		if child != nil && reflect.TypeOf(child).Kind() == reflect.Slice {
+			items := reflect.ValueOf(child)
+			nested := make([]interface{}, items.Len())
+			for i := range nested {
+				nested[i] = items.Index(i).Interface()
+			}
+			registerDescendants(hub, root, nested)
+			continue
+		}
+
+		component, ok := child.(gouix.Component)
+		if !ok {
+			continue
+		}
+		if component.GetID() != "" {
+			hub.Register(component, root)
+		}
+		registerDescendants(hub, root, component.GetChildren())
+	}
+}
+
+// containerChildren parses a root's HTML and returns the container's children
+func containerChildren(html string) ([]*gouix.VNode, error) {
+	nodes, err := gouix.ParseHTML(html)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) != 1 {
+		return nil, fmt.Errorf("expected a single container, got %d nodes", len(nodes))
+	}
+	return nodes[0].Children, nil
+}
+
+// Register makes components that are not children of the rendered component,
+// such as ones rendered by a function, reachable by events
+func (s *Screen) Register(components ...gouix.Component) {
+	for _, component := range components {
+		s.hub.Register(component, s.Root)
+	}
+}
+
+// apply applies a patch set to the DOM
+func (s *Screen) apply(patchSet gouix.PatchSet) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.patches = append(s.patches, patchSet)
+	if s.err != nil {
+		return
+	}
+	nodes, err := gouix.ApplyPatches(s.nodes, patchSet.Patches)
+	if err != nil {
+		s.err = fmt.Errorf("applying %+v: %v", patchSet.Patches, err)
+		return
+	}
+	s.nodes = nodes
+}
+
+// dom returns the current DOM, failing the test if a patch could not be applied
+func (s *Screen) dom() []*gouix.VNode {
+	s.t.Helper()
+
+	s.mutex.Lock()
+	nodes, err := s.nodes, s.err
+	s.mutex.Unlock()
+
+	if err != nil {
+		s.t.Fatalf("patches could not be applied: %v", err)
+	}
+	return nodes
+}
+
+// Rerender renders the component again and checks that the DOM built from
+// patches matches the fresh render. Events rerender automatically.
+func (s *Screen) Rerender() {
+	s.t.Helper()
+
+	rendered, err := containerChildren(s.Root.Render())
+	if err != nil {
+		s.t.Fatalf("rendering %s: %v", s.Root.ID, err)
+	}
+	if patches := gouix.Diff(s.dom(), rendered); len(patches) != 0 {
+		s.t.Fatalf("patches left the DOM out of sync with the render; it still needs %+v", patches)
+	}
+	s.auditA11y()
+}
+
+// Patches returns the patch sets sent since the component was rendered
+func (s *Screen) Patches() []gouix.PatchSet {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]gouix.PatchSet(nil), s.patches...)
+}
+
+// HTML returns the current HTML inside the root container
+func (s *Screen) HTML() string {
+	s.t.Helper()
+
+	var b strings.Builder
+	for _, node := range s.dom() {
+		b.WriteString(node.HTML())
+	}
+	return b.String()
+}
+
+// Text returns the text content of the page
+func (s *Screen) Text() string {
+	s.t.Helper()
+
+	return textContent(s.dom())
+}
+
+// Dispatch sends an event to a component, as the client runtime does, and
+// rerenders
+func (s *Screen) Dispatch(target gouix.ComponentID, event string, data map[string]interface{}) {
+	s.t.Helper()
+
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	if err := s.hub.Dispatch(gouix.Event{Type: event, Target: target, Data: data, Bubbles: true}); err != nil {
+		s.t.Fatalf("dispatching %s to %s: %v", event, target, err)
+	}
+	s.Rerender()
+}
+
+// State returns a state value of the rendered component
+func (s *Screen) State(key string) interface{} {
+	s.t.Helper()
+
+	stateful, ok := s.Component.(interface{ GetState(key string) interface{} })
+	if !ok {
+		s.t.Fatalf("%T has no state", s.Component)
+	}
+	return stateful.GetState(key)
+}
+
+// AssertState checks a state value of the rendered component
+func (s *Screen) AssertState(key string, want interface{}) {
+	s.t.Helper()
+
+	if got := s.State(key); !reflect.DeepEqual(got, want) {
+		s.t.Errorf("state %q: expected %#v, got %#v", key, want, got)
+	}
+}
+
+// AssertText checks the trimmed text content of the element matching selector
+func (s *Screen) AssertText(selector, want string) {
+	s.t.Helper()
+
+	if got := strings.TrimSpace(s.Get(selector).Text()); got != want {
+		s.t.Errorf("text of %s: expected %q, got %q", selector, want, got)
+	}
+}
+
+// AssertContains checks that the page text contains text
+func (s *Screen) AssertContains(text string) {
+	s.t.Helper()
+
+	if page := s.Text(); !strings.Contains(page, text) {
+		s.t.Errorf("expected the page to contain %q, got %q", text, page)
+	}
+}
+
+// AssertMissing checks that no element matches selector
+func (s *Screen) AssertMissing(selector string) {
+	s.t.Helper()
+
+	if element := s.Query(selector); element != nil {
+		s.t.Errorf("expected no element matching %s, found %s", selector, element.HTML())
+	}
+}
+
+// MatchSnapshot compares the current HTML with the test's golden file; see
+// MatchSnapshot
+func (s *Screen) MatchSnapshot(name ...string) {
+	s.t.Helper()
+
+	MatchSnapshot(s.t, strings.Join(name, "_"), s.HTML())
+}
@@ -0,0 +1,249 @@
+package testing_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/davidjeba/goscript/pkg/gouix"
+	uixtesting "github.com/davidjeba/goscript/pkg/gouix/testing"
+)
+
+// counter uses inline handlers, like the components in pkg/components
+type counter struct {
+	*gouix.HyperComponent
+}
+
+func newCounter() *counter {
+	c := &counter{gouix.NewHyperComponent("counter", nil, map[string]interface{}{"count": 0})}
+	c.On("increment", func(event gouix.Event) interface{} {
+		step := 1
+		if value, ok := event.Data["step"].(float64); ok {
+			step = int(value)
+		}
+		c.SetState("count", c.GetState("count").(int)+step)
+		return nil
+	})
+	return c
+}
+
+func (c *counter) Render() string {
+	return gouix.CreateElement("div", gouix.Props{"class": "counter", "id": "counter"},
+		gouix.CreateElement("h2", nil, "Counter"),
+		gouix.CreateElement("p", gouix.Props{"class": "count"}, fmt.Sprint(c.GetState("count"))),
+		gouix.CreateElement("button", gouix.Props{
+			"id":      "increment",
+			"onclick": "_gouix.dispatchEvent('counter', 'increment', {step: 2})",
+		}, gouix.CreateElement("span", nil, "+2")),
+	)
+}
+
+// recorder captures failures instead of failing the test
+type recorder struct {
+	*testing.T
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestScreenClickUpdatesState(t *testing.T) {
+	screen := uixtesting.Render(t, newCounter())
+
+	screen.AssertText("div.counter > p.count", "0")
+
+	// Clicks bubble from the span to the button's handler
+	screen.Get("#increment span").Click()
+	screen.Click("button")
+
+	screen.AssertText(".count", "4")
+	screen.AssertState("count", 4)
+	screen.AssertContains("Counter4")
+	if patches := screen.Patches(); len(patches) != 2 {
+		t.Errorf("expected one patch set per click, got %d", len(patches))
+	}
+
+	check := &recorder{T: t}
+	failing := uixtesting.Render(check, newCounter())
+	failing.AssertText(".count", "1")
+	failing.AssertMissing("button")
+	if len(check.errors) != 2 {
+		t.Errorf("expected both assertions to fail, got %v", check.errors)
+	}
+}
+
+func TestScreenFormEvents(t *testing.T) {
+	form := gouix.NewForm("signup",
+		&gouix.Field{Name: "email", Label: "Email", Type: "email", Validators: []gouix.Validator{gouix.Required("Email is required")}},
+		&gouix.Field{Name: "plan", Label: "Plan", Type: "select", Options: []string{"free", "pro"}},
+	)
+	var submitted map[string]interface{}
+	form.OnSubmit(func(values map[string]interface{}) error {
+		submitted = values
+		return nil
+	})
+
+	screen := uixtesting.Render(t, form)
+
+	screen.Submit("button")
+	screen.GetByText("Email is required")
+
+	screen.Input("#signup-email", "ada@example.com")
+	screen.Input("#signup-plan", "pro")
+	screen.Submit("form")
+
+	if submitted["email"] != "ada@example.com" || submitted["plan"] != "pro" {
+		t.Errorf("unexpected submitted values %v", submitted)
+	}
+	if value := screen.Get("#signup-email").Attr("value"); value != "ada@example.com" {
+		t.Errorf("expected the input value to be patched in, got %q", value)
+	}
+}
+
+func TestScreenHydrationBindings(t *testing.T) {
+	toggle := gouix.NewHyperComponent("toggle", nil, map[string]interface{}{"on": false})
+	toggle.On("flip", func(event gouix.Event) interface{} {
+		toggle.SetState("on", !toggle.GetState("on").(bool))
+		return nil
+	})
+	view := gouix.NewProvider("app", gouix.CreateContext(nil), nil, gouix.FunctionalComponent(func(gouix.Props, ...interface{}) string {
+		return gouix.Hydratable(toggle, gouix.CreateElement("label", nil,
+			gouix.CreateElement("input", gouix.Props{"type": "checkbox", "checked": toggle.GetState("on"), "data-gouix-on": "change:flip"}),
+		))
+	}))
+
+	screen := uixtesting.Render(t, view)
+	screen.Register(toggle)
+
+	screen.Get("[data-gouix-component=toggle] input").Fire("change", nil)
+	if !screen.Get("input").HasAttr("checked") {
+		t.Errorf("expected the checkbox to be checked, got %s", screen.HTML())
+	}
+}
+
+func TestScreenAccessibility(t *testing.T) {
+	// The counter's button has a text label and its heading starts at h2
+	uixtesting.Render(t, newCounter()).AssertAccessible()
+
+	search := gouix.FunctionalComponent(func(gouix.Props, ...interface{}) string {
+		return gouix.CreateElement("form", nil, gouix.CreateElement("input", gouix.Props{"type": "search"}))
+	})
+
+	check := &recorder{T: t}
+	uixtesting.Render(check, search).AssertAccessible()
+	if len(check.errors) != 1 || !strings.Contains(check.errors[0], "accessibility: label: <input type=\"search\"/>") {
+		t.Errorf("expected the unlabelled input to fail, got %v", check.errors)
+	}
+
+	// With the environment variable set every render is checked
+	os.Setenv(uixtesting.A11yEnv, "1")
+	defer os.Unsetenv(uixtesting.A11yEnv)
+
+	check = &recorder{T: t}
+	screen := uixtesting.Render(check, search)
+	screen.Rerender()
+	if len(check.errors) != 1 {
+		t.Errorf("expected the issue to be reported once, got %v", check.errors)
+	}
+}
+
+func TestScreenDragAndDrop(t *testing.T) {
+	item := func(key string, index int) string {
+		return gouix.CreateElement("span", nil, key)
+	}
+	todo := gouix.NewSortableList("todo", []string{"write", "test", "ship"}, item)
+	done := gouix.NewSortableList("done", nil, item)
+	todo.ItemType, done.ItemType = "task", "task"
+	done.OnDrop(func(drop gouix.Drop) error {
+		key := drop.Item.Data.(string)
+		todo.Remove(key)
+		done.Insert(key, drop.Index)
+		return nil
+	})
+	trash := gouix.NewDropZone("trash", []string{"task"}, "Trash")
+	var trashed []interface{}
+	trash.OnDrop(func(drop gouix.Drop) error {
+		trashed = append(trashed, drop.Item.Data)
+		todo.Remove(drop.Item.Data.(string))
+		return nil
+	})
+
+	screen := uixtesting.Render(t, gouix.NewProvider("board", gouix.CreateContext(nil), nil, gouix.FunctionalComponent(func(gouix.Props, ...interface{}) string {
+		return gouix.CreateElement("div", nil, todo, done, trash)
+	})))
+	screen.Register(todo, done, trash)
+
+	screen.Drag("#todo [data-key=write] span", "#todo [data-key=ship]")
+	screen.AssertText("#todo", "testshipwrite")
+
+	screen.Drag("#todo [data-key=ship]", "#done")
+	screen.Drag("[data-key=test]", "[data-key=ship]")
+	screen.AssertText("#todo", "write")
+	screen.AssertText("#done", "testship")
+
+	screen.Drag("[data-key=write]", "#trash")
+	if len(trashed) != 1 || trashed[0] != "write" {
+		t.Errorf("expected write to be trashed, got %v", trashed)
+	}
+	screen.AssertMissing("#todo li")
+}
+
+func TestScreenScroll(t *testing.T) {
+	list := gouix.NewVirtualList("log", 500, func(index int) string {
+		return fmt.Sprintf("Line %d", index)
+	})
+	list.Height, list.ItemHeight, list.Overscan = 100, 20, 0
+
+	screen := uixtesting.Render(t, gouix.NewProvider("page", gouix.CreateContext(nil), nil, gouix.FunctionalComponent(func(gouix.Props, ...interface{}) string {
+		return gouix.CreateElement("main", nil, list)
+	})))
+	screen.Register(list)
+
+	screen.AssertText("#log [data-index]", "Line 0")
+	screen.Scroll("#log", 2000)
+	screen.AssertText("#log [data-index]", "Line 100")
+	screen.AssertMissing("[data-index=0]")
+}
+
+func TestMatchSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	previous := uixtesting.SnapshotDir
+	uixtesting.SnapshotDir = dir
+	defer func() { uixtesting.SnapshotDir = previous }()
+
+	screen := uixtesting.Render(t, newCounter())
+
+	os.Setenv(uixtesting.UpdateSnapshotsEnv, "1")
+	screen.MatchSnapshot("initial")
+	os.Unsetenv(uixtesting.UpdateSnapshotsEnv)
+
+	data, err := os.ReadFile(filepath.Join(dir, "TestMatchSnapshot_initial.html"))
+	if err != nil {
+		t.Fatalf("expected the snapshot to be written: %v", err)
+	}
+	expected := strings.Join([]string{
+		`<div class="counter" id="counter">`,
+		`  <h2>Counter</h2>`,
+		`  <p class="count">0</p>`,
+		`  <button id="increment" onclick="_gouix.dispatchEvent(&#39;counter&#39;, &#39;increment&#39;, {step: 2})">`,
+		`    <span>+2</span>`,
+		`  </button>`,
+		`</div>`,
+		``,
+	}, "\n")
+	if string(data) != expected {
+		t.Fatalf("unexpected snapshot:\n%s", data)
+	}
+
+	screen.MatchSnapshot("initial")
+
+	check := &recorder{T: t}
+	screen.Click("#increment")
+	uixtesting.MatchSnapshot(check, "initial", screen.HTML())
+	if len(check.errors) != 1 || !strings.Contains(check.errors[0], "line 3:\n-   <p class=\"count\">0</p>\n+   <p class=\"count\">2</p>") {
+		t.Errorf("expected a mismatch on the count line, got %v", check.errors)
+	}
+}
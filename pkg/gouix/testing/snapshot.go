@@ -0,0 +1,125 @@
+package testing
+
+import (
+	"fmt"
+	"html"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/davidjeba/goscript/pkg/gouix"
+)
+
+// UpdateSnapshotsEnv is the environment variable that makes MatchSnapshot
+// write golden files instead of comparing with them, as
+// "gopm uix:test --update" does
+const UpdateSnapshotsEnv = "GOUIX_UPDATE_SNAPSHOTS"
+
+// SnapshotDir is where golden files are kept, relative to the package under
+// test
+var SnapshotDir = filepath.Join("testdata", "snapshots")
+
+// unsafeFileChars are replaced in snapshot file names
+var unsafeFileChars = regexp.MustCompile(`[^\w.-]+`)
+
+// MatchSnapshot compares HTML, pretty-printed, with the golden file named
+// after the test and name. Set UpdateSnapshotsEnv to create or update the
+// file after checking the change is intended.
+func MatchSnapshot(t T, name string, markup string) {
+	t.Helper()
+
+	file := unsafeFileChars.ReplaceAllString(t.Name(), "_")
+	if name != "" {
+		file += "_" + unsafeFileChars.ReplaceAllString(name, "_")
+	}
+	path := filepath.Join(SnapshotDir, file+".html")
+
+	got, err := Pretty(markup)
+	if err != nil {
+		t.Fatalf("snapshot %s: %v", path, err)
+	}
+
+	if os.Getenv(UpdateSnapshotsEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("snapshot %s: %v", path, err)
+		}
+		if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatalf("snapshot %s: %v", path, err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Fatalf("snapshot %s does not exist; run with %s=1 to create it", path, UpdateSnapshotsEnv)
+	}
+	if err != nil {
+		t.Fatalf("snapshot %s: %v", path, err)
+	}
+
+	if got != string(want) {
+		t.Errorf("snapshot %s does not match:\n%s\nrun with %s=1 to update it", path, diffLines(string(want), got), UpdateSnapshotsEnv)
+	}
+}
+
+// diffLines describes the first line where two snapshots differ
+func diffLines(want, got string) string {
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return fmt.Sprintf("line %d:\n- %s\n+ %s", i+1, w, g)
+		}
+	}
+	return ""
+}
+
+// Pretty formats HTML one element per line with two-space indentation, so
+// snapshots diff readably. Whitespace between elements is dropped; an element
+// holding only text stays on one line.
+func Pretty(markup string) (string, error) {
+	nodes, err := gouix.ParseHTML(markup)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	writePretty(&b, nodes, 0)
+	return b.String(), nil
+}
+
+func writePretty(b *strings.Builder, nodes []*gouix.VNode, depth int) {
+	indent := strings.Repeat("  ", depth)
+
+	for _, node := range nodes {
+		switch node.Type {
+		case gouix.TextNode:
+			if text := strings.TrimSpace(node.Text); text != "" {
+				b.WriteString(indent + html.EscapeString(text) + "\n")
+			}
+		case gouix.CommentNode:
+			b.WriteString(indent + "<!--" + node.Text + "-->\n")
+		default:
+			if len(node.Children) == 0 || (len(node.Children) == 1 && node.Children[0].Type == gouix.TextNode) {
+				b.WriteString(indent + node.HTML() + "\n")
+				continue
+			}
+
+			open := node.HTML()
+			open = open[:strings.IndexByte(open, '>')+1]
+			b.WriteString(indent + open + "\n")
+			writePretty(b, node.Children, depth+1)
+			b.WriteString(indent + "</" + node.Tag + ">\n")
+		}
+	}
+}
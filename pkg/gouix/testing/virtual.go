@@ -0,0 +1,24 @@
+package testing
+
+import "github.com/davidjeba/goscript/pkg/gouix"
+
+// Scroll scrolls the virtual list matching selector, or containing the
+// element it matches, to top pixels
+func (s *Screen) Scroll(selector string, top int) {
+	s.t.Helper()
+	s.Get(selector).Scroll(top)
+}
+
+// Scroll scrolls the virtual list the element belongs to, as the client
+// runtime reports it. Rows are not measured, so the list keeps estimating
+// their height.
+func (e *Element) Scroll(top int) {
+	t := e.screen.t
+	t.Helper()
+
+	list := e.Closest("[data-gouix-virtual]")
+	if list == nil {
+		t.Fatalf("%s is not in a virtual list", e.HTML())
+	}
+	e.screen.Dispatch(gouix.ComponentID(list.Attr("data-gouix-virtual")), "scroll", map[string]interface{}{"top": float64(top)})
+}
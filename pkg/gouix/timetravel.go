@@ -0,0 +1,270 @@
+package gouix
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// StateTransition is a recorded change to a component's state: the store
+// action that made it, such as "set" or a dispatched action's type, and the
+// state before and after
+type StateTransition struct {
+	Time    time.Time   `json:"time"`
+	Action  string      `json:"action"`
+	Payload interface{} `json:"payload,omitempty"`
+	Before  State       `json:"before"`
+	After   State       `json:"after"`
+}
+
+// Recording is the state transitions a TimeTravel recorded, to attach to a
+// bug report and load into another session to reproduce it
+type Recording struct {
+	Component   ComponentID       `json:"component"`
+	Initial     State             `json:"initial"`
+	Transitions []StateTransition `json:"transitions"`
+}
+
+// TimeTraveler is implemented by components that can record their state
+// transitions, as HyperComponent does. Devtools turn recording on for every
+// such component they inspect.
+type TimeTraveler interface {
+	EnableTimeTravel(limit int) *TimeTravel
+}
+
+// TimeTravel records the state transitions of a HyperComponent, keeping the
+// last ones, and moves the component back and forth between them. Moving
+// restores the recorded state and re-renders the component; a change made
+// while rewound discards the transitions after it, as undo does.
+type TimeTravel struct {
+	id          ComponentID
+	store       *Store
+	notify      func()
+	limit       int
+	initial     State
+	transitions []StateTransition
+	position    int
+	restoring   bool
+	stop        func()
+	mutex       sync.Mutex
+}
+
+// EnableTimeTravel starts recording the component's state transitions,
+// keeping the last limit (100 for zero). It is meant for dev mode; Devtools
+// call it for the components they inspect. Enabling it again returns the
+// same recorder with the new limit.
+func (h *HyperComponent) EnableTimeTravel(limit int) *TimeTravel {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.timeTravel == nil {
+		h.timeTravel = &TimeTravel{id: h.GetID(), store: h.store, notify: h.notifyStateChange, initial: h.store.State()}
+		h.timeTravel.stop = h.store.OnEvent(h.timeTravel.record)
+	}
+	h.timeTravel.setLimit(limit)
+	return h.timeTravel
+}
+
+// TimeTravel returns the component's recorder, or nil if it is not
+// recording
+func (h *HyperComponent) TimeTravel() *TimeTravel {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	return h.timeTravel
+}
+
+// setLimit keeps the last limit transitions
+func (t *TimeTravel) setLimit(limit int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.limit = limit
+	t.trim()
+}
+
+// trim drops the oldest transitions beyond the limit; the state after the
+// last one dropped becomes the initial state. The caller holds the lock.
+func (t *TimeTravel) trim() {
+	drop := len(t.transitions) - t.limit
+	if drop <= 0 {
+		return
+	}
+	t.initial = t.transitions[drop-1].After
+	t.transitions = append([]StateTransition(nil), t.transitions[drop:]...)
+	t.position -= drop
+	if t.position < 0 {
+		t.position = 0
+	}
+}
+
+// record appends a transition for a store event
+func (t *TimeTravel) record(event StoreEvent) {
+	after := t.store.State()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.restoring {
+		return
+	}
+	t.transitions = append(t.transitions[:t.position], StateTransition{
+		Time:    event.Time,
+		Action:  event.Action,
+		Payload: event.Payload,
+		Before:  t.stateAt(t.position),
+		After:   after,
+	})
+	t.position = len(t.transitions)
+	t.trim()
+}
+
+// stateAt returns the state at a position; the caller holds the lock
+func (t *TimeTravel) stateAt(position int) State {
+	if position == 0 {
+		return t.initial
+	}
+	return t.transitions[position-1].After
+}
+
+// Transitions returns the recorded transitions, oldest first
+func (t *TimeTravel) Transitions() []StateTransition {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return append([]StateTransition(nil), t.transitions...)
+}
+
+// Position returns how many of the transitions the component's state is
+// after: 0 before the first, and len(Transitions()) when it is current
+func (t *TimeTravel) Position() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.position
+}
+
+// Jump restores the state at a position and re-renders the component
+func (t *TimeTravel) Jump(position int) error {
+	t.mutex.Lock()
+	if position < 0 || position > len(t.transitions) {
+		count := len(t.transitions)
+		t.mutex.Unlock()
+		return fmt.Errorf("gouix: %q has no position %d of %d", t.id, position, count)
+	}
+	state := t.stateAt(position)
+	t.restoring = true
+	t.mutex.Unlock()
+
+	err := restoreState(t.store, state)
+
+	t.mutex.Lock()
+	t.restoring = false
+	if err == nil {
+		t.position = position
+	}
+	t.mutex.Unlock()
+
+	if err != nil {
+		return err
+	}
+	t.notify()
+	return nil
+}
+
+// Back rewinds one transition and reports whether there was one
+func (t *TimeTravel) Back() bool {
+	position := t.Position()
+	return position > 0 && t.Jump(position-1) == nil
+}
+
+// Forward replays one transition and reports whether there was one
+func (t *TimeTravel) Forward() bool {
+	position := t.Position()
+	return position < len(t.Transitions()) && t.Jump(position+1) == nil
+}
+
+// Replay rewinds to the start and replays every transition recorded so far,
+// one each interval, so a bug can be watched as it happens. It blocks until
+// the last one is replayed.
+func (t *TimeTravel) Replay(interval time.Duration) error {
+	end := len(t.Transitions())
+	for position := 0; position <= end; position++ {
+		if position > 0 {
+			time.Sleep(interval)
+		}
+		if err := t.Jump(position); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Recording returns the recorded transitions
+func (t *TimeTravel) Recording() Recording {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return Recording{Component: t.id, Initial: t.initial, Transitions: append([]StateTransition(nil), t.transitions...)}
+}
+
+// Load replaces the transitions with a recording, such as one decoded from
+// a bug report, and restores its initial state, ready to step through it
+func (t *TimeTravel) Load(recording Recording) error {
+	t.mutex.Lock()
+	t.initial = recording.Initial
+	t.transitions = append([]StateTransition(nil), recording.Transitions...)
+	t.position = len(t.transitions)
+	t.trim()
+	t.mutex.Unlock()
+
+	return t.Jump(0)
+}
+
+// Stop stops recording; the transitions recorded stay available
+func (t *TimeTravel) Stop() {
+	if t.stop != nil {
+		t.stop()
+	}
+}
+
+// restoreState sets a store's values to a recorded state in one batch.
+// Values decoded from JSON are converted back to the types of typed slices,
+// and keys the state does not have keep their value.
+func restoreState(store *Store, state State) error {
+	values := make(map[string]interface{}, len(state))
+	for key, value := range state {
+		store.mutex.RLock()
+		typ := store.types[key]
+		store.mutex.RUnlock()
+
+		if typ != nil && value != nil && !reflect.TypeOf(value).AssignableTo(typ) {
+			data, err := json.Marshal(value)
+			if err != nil {
+				return fmt.Errorf("gouix: restore %q: %v", key, err)
+			}
+			converted := reflect.New(typ)
+			if err := json.Unmarshal(data, converted.Interface()); err != nil {
+				return fmt.Errorf("gouix: restore %q: %v", key, err)
+			}
+			value = converted.Elem().Interface()
+		}
+		if err := store.checkType(key, value); err != nil {
+			return err
+		}
+		values[key] = value
+	}
+
+	store.Batch(func() {
+		for key, value := range values {
+			store.Set(key, value)
+		}
+	})
+	return nil
+}
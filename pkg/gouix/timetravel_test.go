@@ -0,0 +1,183 @@
+package gouix
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTravelCounter() *patchCounter {
+	counter := &patchCounter{HyperComponent: HyperComponent{store: NewStore(map[string]interface{}{"count": 0})}}
+	counter.init("counter", nil)
+	return counter
+}
+
+func TestTimeTravelRewindsAndReplays(t *testing.T) {
+	counter := newTravelCounter()
+	travel := counter.EnableTimeTravel(0)
+	if counter.TimeTravel() != travel || counter.EnableTimeTravel(10) != travel {
+		t.Fatal("expected one recorder per component")
+	}
+
+	for i := 1; i <= 3; i++ {
+		counter.SetState("count", i)
+	}
+	transitions := travel.Transitions()
+	if len(transitions) != 3 || travel.Position() != 3 {
+		t.Fatalf("expected 3 transitions, got %+v", transitions)
+	}
+	if second := transitions[1]; second.Action != "set" || second.Before["count"] != 1 || second.After["count"] != 2 {
+		t.Fatalf("unexpected transition %+v", second)
+	}
+
+	if !travel.Back() || !travel.Back() || counter.GetState("count") != 1 || travel.Position() != 1 {
+		t.Fatalf("expected to rewind to 1, got %v", counter.GetState("count"))
+	}
+	if !strings.Contains(counter.Render(), `<p id="counter-count">1</p>`) {
+		t.Errorf("expected the rewound state rendered, got %s", counter.Render())
+	}
+	if len(travel.Transitions()) != 3 {
+		t.Error("expected rewinding not to be recorded")
+	}
+	if !travel.Forward() || counter.GetState("count") != 2 {
+		t.Errorf("expected to replay to 2, got %v", counter.GetState("count"))
+	}
+	if err := travel.Jump(4); err == nil {
+		t.Error("expected an error past the last transition")
+	}
+
+	// A change while rewound discards the transitions after it
+	counter.SetState("count", 10)
+	transitions = travel.Transitions()
+	if len(transitions) != 3 || transitions[2].Before["count"] != 2 || transitions[2].After["count"] != 10 || travel.Forward() {
+		t.Fatalf("expected the future to be replaced, got %+v", transitions)
+	}
+
+	if err := travel.Replay(0); err != nil || counter.GetState("count") != 10 || travel.Position() != 3 {
+		t.Fatalf("expected a replay to end on the last state, got %v (%v)", counter.GetState("count"), err)
+	}
+}
+
+func TestTimeTravelKeepsTheLastTransitions(t *testing.T) {
+	counter := newTravelCounter()
+	travel := counter.EnableTimeTravel(2)
+	for i := 1; i <= 5; i++ {
+		counter.SetState("count", i)
+	}
+
+	if len(travel.Transitions()) != 2 || travel.Position() != 2 {
+		t.Fatalf("expected 2 transitions, got %+v", travel.Transitions())
+	}
+	if err := travel.Jump(0); err != nil || counter.GetState("count") != 3 {
+		t.Fatalf("expected the oldest kept state to be 3, got %v (%v)", counter.GetState("count"), err)
+	}
+
+	travel.Stop()
+	counter.SetState("count", 7)
+	if len(travel.Transitions()) != 2 {
+		t.Error("expected nothing recorded once stopped")
+	}
+}
+
+func TestTimeTravelLoadsRecordings(t *testing.T) {
+	todos := &patchCounter{HyperComponent: HyperComponent{store: NewStore(nil)}}
+	todos.init("todos", nil)
+	store := todos.GetStore()
+	if err := store.AddSlice("items", []string{}, func(state interface{}, action Action) interface{} {
+		if action.Type != "add" {
+			return state
+		}
+		return append(append([]string{}, state.([]string)...), action.Payload.(string))
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	travel := todos.EnableTimeTravel(0)
+	store.Dispatch(Action{Type: "add", Payload: "milk"})
+	store.Dispatch(Action{Type: "add", Payload: "eggs"})
+
+	data, err := json.Marshal(travel.Recording())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recording Recording
+	if err := json.Unmarshal(data, &recording); err != nil {
+		t.Fatal(err)
+	}
+	if recording.Component != "todos" || len(recording.Transitions) != 2 || recording.Transitions[1].Action != "add" || recording.Transitions[1].Payload != "eggs" {
+		t.Fatalf("unexpected recording %s", data)
+	}
+
+	// Load it into a fresh session, decoded values and all
+	store.Dispatch(Action{Type: "add", Payload: "bread"})
+	if err := travel.Load(recording); err != nil {
+		t.Fatal(err)
+	}
+	if items := store.GetValue("items"); !reflect.DeepEqual(items, []string{}) {
+		t.Fatalf("expected the initial state, got %#v", items)
+	}
+	if !travel.Forward() || !travel.Forward() {
+		t.Fatal("expected to step through the recording")
+	}
+	if items := store.GetValue("items"); !reflect.DeepEqual(items, []string{"milk", "eggs"}) {
+		t.Fatalf("expected the typed slice restored, got %#v", items)
+	}
+}
+
+func TestDevtoolsTravelThroughHistory(t *testing.T) {
+	counter := newTravelCounter()
+	counter.On("increment", func(event Event) interface{} {
+		counter.SetState("count", counter.GetState("count").(int)+1)
+		return nil
+	})
+	panel := &devtoolsPanel{}
+	panel.init("panel", nil, counter)
+
+	hub := NewLiveHub()
+	root := hub.Mount("home", panel)
+	hub.Register(counter, root)
+	devtools := hub.EnableDevtools(10)
+	root.Render()
+
+	server := httptest.NewServer(hub)
+	defer server.Close()
+	defer hub.Close()
+
+	client := dialTestWS(t, server, "/")
+	client.send(t, liveMessage{Type: "devtools"})
+	client.receive(t)
+	for i := 0; i < 2; i++ {
+		client.send(t, liveMessage{Type: "event", Target: "counter", Event: "increment"})
+		client.receive(t)
+	}
+
+	node := devtools.Snapshot().Roots[0].Component.Children[0]
+	if node.History == nil || node.History.Position != 2 || len(node.History.Transitions) != 2 {
+		t.Fatalf("expected the counter's history, got %+v", node.History)
+	}
+
+	client.send(t, liveMessage{Type: "travel", Target: "counter", Position: 1})
+	message := client.receive(t)
+	if message.Type != "devtools" {
+		t.Fatalf("expected a devtools update, got %+v", message)
+	}
+	if counter.GetState("count") != 1 || !strings.Contains(root.Render(), `<p id="counter-count">1</p>`) {
+		t.Fatalf("expected the counter rewound, got %v", counter.GetState("count"))
+	}
+	logged := false
+	for _, event := range message.Devtools.Events {
+		logged = logged || (event.Kind == "travel" && event.Target == "counter")
+	}
+	if !logged {
+		t.Errorf("expected the travel logged, got %+v", message.Devtools.Events)
+	}
+
+	if err := devtools.Travel("counter", 5); err == nil {
+		t.Error("expected an error past the history")
+	}
+	if err := devtools.Travel("panel", 0); err == nil {
+		t.Error("expected an error for a component without history")
+	}
+}
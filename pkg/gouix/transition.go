@@ -0,0 +1,284 @@
+package gouix
+
+import (
+	"html"
+	"sync"
+)
+
+// Motion holds the named durations and easings transitions refer to. The
+// defaults match the Gocsx theme; use SetMotionTheme to apply a customized
+// one, such as core.DefaultConfig().Theme.
+type Motion struct {
+	Durations map[string]string
+	Easings   map[string]string
+}
+
+// motionTheme is the theme transitions resolve names with
+var motionTheme = struct {
+	Motion
+	mutex sync.RWMutex
+}{Motion: Motion{
+	Durations: map[string]string{
+		"75": "75ms", "100": "100ms", "150": "150ms", "200": "200ms",
+		"300": "300ms", "500": "500ms", "700": "700ms", "1000": "1000ms",
+	},
+	Easings: map[string]string{
+		"linear": "linear",
+		"in":     "cubic-bezier(0.4, 0, 1, 1)",
+		"out":    "cubic-bezier(0, 0, 0.2, 1)",
+		"in-out": "cubic-bezier(0.4, 0, 0.2, 1)",
+	},
+}}
+
+// SetMotionTheme replaces the named durations and easings, for example with
+// a Gocsx theme's Durations and Easings
+func SetMotionTheme(durations, easings map[string]string) {
+	motionTheme.mutex.Lock()
+	defer motionTheme.mutex.Unlock()
+
+	motionTheme.Durations = durations
+	motionTheme.Easings = easings
+}
+
+// resolveMotion looks a value up in the theme, so "300" becomes "300ms";
+// other values, such as "250ms", are used as they are
+func resolveMotion(values map[string]string, value string) string {
+	if resolved, ok := values[value]; ok {
+		return resolved
+	}
+	return value
+}
+
+// AnimatePresence animates its children as they come and go. The transition
+// runtime gives an inserted child the enter classes and keeps a removed one
+// in the page until its exit classes have played, outside the DOM the server
+// patches. Props:
+//
+//	name      class prefix, "fade" by default; the runtime styles "fade",
+//	          "slide" and "scale", and others are styled by the application
+//	duration  a theme duration such as "300", or a CSS time; "200" by default
+//	easing    a theme easing such as "out", or a CSS timing function
+//	move      animate keyed (data-key) children to their new position when
+//	          the list is reordered, using FLIP
+//	tag       the wrapping element, "div" by default. A div does not affect
+//	          layout; other tags, such as "ul", are laid out normally.
+//
+// A child entering gets the classes <name>-enter-from and <name>-enter-active,
+// then <name>-enter-to on the next frame; leaving works the same with exit.
+func AnimatePresence(props Props, children ...interface{}) string {
+	return presence(props, children)
+}
+
+// Transition shows its children when the "show" prop is true and animates
+// them in and out like AnimatePresence, which documents the other props
+func Transition(props Props, children ...interface{}) string {
+	if show, _ := props["show"].(bool); !show {
+		children = nil
+	}
+	return presence(props, children)
+}
+
+func presence(props Props, children []interface{}) string {
+	name := stringProp(props, "name", "fade")
+	duration := stringProp(props, "duration", "200")
+	easing := stringProp(props, "easing", "in-out")
+	tag := stringProp(props, "tag", "div")
+
+	motionTheme.mutex.RLock()
+	duration = resolveMotion(motionTheme.Durations, duration)
+	easing = resolveMotion(motionTheme.Easings, easing)
+	motionTheme.mutex.RUnlock()
+
+	attrs := Props{
+		"data-gouix-transition": html.EscapeString(name),
+		"data-gouix-duration":   html.EscapeString(duration),
+		"data-gouix-easing":     html.EscapeString(easing),
+	}
+	if move, _ := props["move"].(bool); move {
+		attrs["data-gouix-move"] = true
+	}
+	if tag == "div" {
+		attrs["style"] = "display:contents"
+	}
+	for _, key := range []string{"id", "class"} {
+		if value, ok := props[key]; ok {
+			attrs[key] = value
+		}
+	}
+
+	content := renderChildren(children)
+	if content == "" {
+		return CreateElement(tag, attrs)
+	}
+	return CreateElement(tag, attrs, content)
+}
+
+// stringProp returns a non-empty string prop or a default
+func stringProp(props Props, key, fallback string) string {
+	if value, ok := props[key].(string); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// TransitionRuntime is the client-side script that plays the transitions of
+// AnimatePresence and Transition while patches are applied. It requires
+// PatchRuntime. Elements removed by a patch are put back, marked
+// data-gouix-exiting, until their exit has played; they are taken out before
+// the next patch set, so patch paths always match the server's DOM. Keyed
+// children that only moved are not exited and entered again but slide to
+// their new place. Users who prefer reduced motion see no animation.
+const TransitionRuntime = `(function() {
+  var g = window._gouix = window._gouix || {};
+  var exiting = [], removed = null, added = null;
+  var reduced = window.matchMedia && window.matchMedia('(prefers-reduced-motion: reduce)');
+
+  var style = document.createElement('style');
+  style.textContent = '[data-gouix-exiting]{pointer-events:none}' +
+    '.fade-enter-active,.fade-exit-active,.slide-enter-active,.slide-exit-active,.scale-enter-active,.scale-exit-active{transition-property:opacity,transform}' +
+    '.fade-enter-from,.fade-exit-to{opacity:0}' +
+    '.slide-enter-from,.slide-exit-to{opacity:0;transform:translateY(8px)}' +
+    '.scale-enter-from,.scale-exit-to{opacity:0;transform:scale(.95)}';
+  document.head.appendChild(style);
+
+  function ms(value) {
+    var n = parseFloat(value);
+    if (isNaN(n)) return 200;
+    return /ms$/.test(value) ? n : /s$/.test(value) ? n * 1000 : n;
+  }
+
+  function settings(container) {
+    return {
+      name: container.getAttribute('data-gouix-transition'),
+      duration: ms(container.getAttribute('data-gouix-duration')),
+      easing: container.getAttribute('data-gouix-easing') || 'ease'
+    };
+  }
+
+  function presence(el) {
+    var parent = el.parentNode;
+    return parent && parent.nodeType === 1 && parent.hasAttribute('data-gouix-transition') ? parent : null;
+  }
+
+  function frame(fn) {
+    requestAnimationFrame(function() { requestAnimationFrame(fn); });
+  }
+
+  function play(el, s, phase, done) {
+    var from = s.name + '-' + phase + '-from', active = s.name + '-' + phase + '-active', to = s.name + '-' + phase + '-to';
+    el.style.transitionDuration = s.duration + 'ms';
+    el.style.transitionTimingFunction = s.easing;
+    el.classList.add(from, active);
+    frame(function() {
+      el.classList.remove(from);
+      el.classList.add(to);
+    });
+    el._gouixDone = setTimeout(function() {
+      el.classList.remove(active, to);
+      el.style.transitionDuration = el.style.transitionTimingFunction = '';
+      if (done) done();
+    }, s.duration);
+  }
+
+  function finishExit(el) {
+    clearTimeout(el._gouixDone);
+    if (el.parentNode) el.parentNode.removeChild(el);
+  }
+
+  function exit(record) {
+    var el = record.el, parent = record.parent;
+    if (!parent.isConnected) return;
+    if (record.prev && record.prev.parentNode === parent) parent.insertBefore(el, record.prev.nextSibling);
+    else if (record.next && record.next.parentNode === parent) parent.insertBefore(el, record.next);
+    else parent.appendChild(el);
+    el.setAttribute('data-gouix-exiting', '');
+    el.setAttribute('aria-hidden', 'true');
+    exiting.push(el);
+    play(el, settings(parent), 'exit', function() {
+      exiting.splice(exiting.indexOf(el), 1);
+      finishExit(el);
+    });
+  }
+
+  function keyed(container) {
+    var positions = {};
+    Array.prototype.forEach.call(container.children, function(el) {
+      var key = el.getAttribute('data-key');
+      if (key !== null && !el.hasAttribute('data-gouix-exiting')) positions[key] = el.getBoundingClientRect();
+    });
+    return positions;
+  }
+
+  function snapshot(root) {
+    return Array.prototype.map.call(root.querySelectorAll('[data-gouix-move]'), function(container) {
+      return {container: container, positions: keyed(container)};
+    });
+  }
+
+  function has(container, key) {
+    return Array.prototype.some.call(container.children, function(el) {
+      return el.getAttribute('data-key') === key && !el.hasAttribute('data-gouix-exiting');
+    });
+  }
+
+  // flip slides keyed children from their old position to the new one
+  function flip(before) {
+    before.forEach(function(entry) {
+      var container = entry.container;
+      if (!container.isConnected) return;
+      var s = settings(container);
+      Array.prototype.forEach.call(container.children, function(el) {
+        var key = el.getAttribute('data-key'), old = key !== null && entry.positions[key];
+        if (!old || el.hasAttribute('data-gouix-exiting')) return;
+        var now = el.getBoundingClientRect(), dx = old.left - now.left, dy = old.top - now.top;
+        if (!dx && !dy) return;
+        el.style.transition = 'none';
+        el.style.transform = 'translate(' + dx + 'px,' + dy + 'px)';
+        frame(function() {
+          el.style.transition = 'transform ' + s.duration + 'ms ' + s.easing;
+          el.style.transform = '';
+          setTimeout(function() { el.style.transition = ''; }, s.duration);
+        });
+      });
+    });
+  }
+
+  g.onRemove = function(el) {
+    var container = el.nodeType === 1 && presence(el);
+    if (removed && container) removed.push({el: el, parent: container, prev: el.previousSibling, next: el.nextSibling});
+  };
+
+  g.onInsert = function(el) {
+    if (added && el.nodeType === 1 && presence(el)) added.push(el);
+  };
+
+  var apply = g.applyPatches;
+  g.applyPatches = function(set) {
+    var root = document.querySelector('[data-gouix-root="' + set.root + '"]');
+    // Patch paths count only the elements the server knows about
+    exiting.filter(function(el) { return root && root.contains(el); }).forEach(function(el) {
+      exiting.splice(exiting.indexOf(el), 1);
+      finishExit(el);
+    });
+
+    var before = root ? snapshot(root) : [];
+    removed = [];
+    added = [];
+    var applied = apply(set), gone = removed, fresh = added;
+    removed = added = null;
+    if (!applied || (reduced && reduced.matches)) return applied;
+
+    gone.slice().reverse().forEach(function(record) {
+      var key = record.el.getAttribute('data-key');
+      if (key !== null && has(record.parent, key)) return;
+      exit(record);
+    });
+    fresh.forEach(function(el) {
+      var key = el.getAttribute('data-key'), container = el.parentNode;
+      var known = key !== null && before.some(function(entry) { return entry.container === container && entry.positions[key]; });
+      if (!known) play(el, settings(container), 'enter');
+    });
+    flip(before);
+    return applied;
+  };
+})();`
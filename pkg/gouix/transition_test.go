@@ -0,0 +1,88 @@
+package gouix
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestTransition(t *testing.T) {
+	shown := Transition(Props{"show": true, "name": "slide", "duration": "300", "easing": "out"}, CreateElement("p", nil, "Saved"))
+	expected := `<div data-gouix-duration="300ms" data-gouix-easing="cubic-bezier(0, 0, 0.2, 1)" data-gouix-transition="slide" style="display:contents"><p>Saved</p></div>`
+	if shown != expected {
+		t.Fatalf("expected %s, got %s", expected, shown)
+	}
+
+	hidden := Transition(Props{"name": "slide", "duration": "300", "easing": "out"}, CreateElement("p", nil, "Saved"))
+	expected = `<div data-gouix-duration="300ms" data-gouix-easing="cubic-bezier(0, 0, 0.2, 1)" data-gouix-transition="slide" style="display:contents"></div>`
+	if hidden != expected {
+		t.Fatalf("expected an empty wrapper, got %s", hidden)
+	}
+}
+
+func TestAnimatePresence(t *testing.T) {
+	list := AnimatePresence(Props{"tag": "ul", "move": true, "id": "todos", "duration": "250ms"},
+		CreateElement("li", Props{"data-key": "a"}, "A"),
+		CreateElement("li", Props{"data-key": "b"}, "B"),
+	)
+	expected := `<ul data-gouix-duration="250ms" data-gouix-easing="cubic-bezier(0.4, 0, 0.2, 1)" data-gouix-move data-gouix-transition="fade" id="todos"><li data-key="a">A</li><li data-key="b">B</li></ul>`
+	if list != expected {
+		t.Fatalf("expected %s, got %s", expected, list)
+	}
+}
+
+func TestSetMotionTheme(t *testing.T) {
+	defer SetMotionTheme(motionTheme.Durations, motionTheme.Easings)
+
+	SetMotionTheme(map[string]string{"slow": "2s"}, map[string]string{"bounce": "cubic-bezier(0.5, 1.5, 0.5, 1)"})
+	markup := AnimatePresence(Props{"duration": "slow", "easing": "bounce"})
+	if !strings.Contains(markup, `data-gouix-duration="2s"`) || !strings.Contains(markup, `data-gouix-easing="cubic-bezier(0.5, 1.5, 0.5, 1)"`) {
+		t.Errorf("expected the custom theme to be used, got %s", markup)
+	}
+}
+
+// toast shows a message in a transition while it is open
+type toast struct {
+	*HyperComponent
+}
+
+func (c *toast) Render() string {
+	open, _ := c.GetState("open").(bool)
+	return CreateElement("section", Props{"id": "toast"},
+		Transition(Props{"show": open}, CreateElement("p", nil, "Saved")),
+	)
+}
+
+func TestTransitionPatches(t *testing.T) {
+	component := &toast{NewHyperComponent("toast", nil, map[string]interface{}{"open": false})}
+	root := NewRoot("", component)
+	root.Render()
+
+	var received []PatchSet
+	root.OnPatch(func(patchSet PatchSet) {
+		received = append(received, patchSet)
+	})
+
+	component.SetState("open", true)
+	component.SetState("open", false)
+	if len(received) != 2 {
+		t.Fatalf("expected 2 patch sets, got %d", len(received))
+	}
+
+	// The wrapper stays in place, so the runtime sees the child come and go
+	for i, op := range []string{"insert", "remove"} {
+		data, err := json.Marshal(received[i].Patches)
+		if err != nil {
+			t.Fatalf("marshal patches: %v", err)
+		}
+		if !strings.Contains(string(data), `"op":"`+op+`","path":[0,0`) {
+			t.Errorf("expected an %s inside the transition, got %s", op, data)
+		}
+	}
+}
+
+func TestScriptTagIncludesTransitionRuntime(t *testing.T) {
+	if !strings.Contains(NewLiveHub().ScriptTag("/live"), TransitionRuntime) {
+		t.Errorf("expected the script tag to load the transition runtime")
+	}
+}
@@ -0,0 +1,480 @@
+package gouix
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// Command is an undoable change to a store, executed through a History.
+// Execute is called again to redo the command after it is undone.
+type Command interface {
+	// Name describes the command to the user, as in "Undo Move"
+	Name() string
+
+	Execute(store *Store) error
+	Undo(store *Store) error
+}
+
+// Coalescer is implemented by commands that can merge with the command
+// executed before them, such as the keystrokes typed into one field, so that
+// a single undo reverts them all. Coalesce returns the merged command, or
+// false if the two do not merge.
+type Coalescer interface {
+	Coalesce(previous Command) (Command, bool)
+}
+
+// setCommand sets one value, as returned by SetValue
+type setCommand struct {
+	key      string
+	value    interface{}
+	previous interface{}
+}
+
+// SetValue returns a command that sets a store value. Consecutive SetValue
+// commands for the same key coalesce.
+func SetValue(key string, value interface{}) Command {
+	return &setCommand{key: key, value: value}
+}
+
+func (c *setCommand) Name() string {
+	return "Set " + c.key
+}
+
+func (c *setCommand) Execute(store *Store) error {
+	return store.Mutate(c.key, func(previous interface{}) interface{} {
+		c.previous = previous
+		return c.value
+	})
+}
+
+func (c *setCommand) Undo(store *Store) error {
+	return store.Mutate(c.key, func(interface{}) interface{} {
+		return c.previous
+	})
+}
+
+func (c *setCommand) Coalesce(previous Command) (Command, bool) {
+	p, ok := previous.(*setCommand)
+	if !ok || p.key != c.key {
+		return nil, false
+	}
+	return &setCommand{key: c.key, value: c.value, previous: p.previous}, true
+}
+
+// changeCommand runs a function once and afterwards restores the values it
+// changed, as returned by Change
+type changeCommand struct {
+	name   string
+	fn     func(store *Store)
+	done   bool
+	before State
+	after  State
+}
+
+// Change returns a command that runs fn, which may change any number of
+// values, as one batch. The values fn changes are recorded, so undoing
+// restores them and redoing sets them again without running fn. Consecutive
+// Change commands with the same name coalesce.
+func Change(name string, fn func(store *Store)) Command {
+	return &changeCommand{name: name, fn: fn}
+}
+
+func (c *changeCommand) Name() string {
+	return c.name
+}
+
+func (c *changeCommand) Execute(store *Store) error {
+	if c.done {
+		return restoreState(store, c.after)
+	}
+
+	before := store.State()
+	store.Batch(func() {
+		c.fn(store)
+	})
+	after := store.State()
+
+	c.before, c.after = make(State), make(State)
+	for key, value := range after {
+		if previous, ok := before[key]; !ok || !reflect.DeepEqual(previous, value) {
+			c.before[key] = previous
+			c.after[key] = value
+		}
+	}
+	c.done = true
+	return nil
+}
+
+func (c *changeCommand) Undo(store *Store) error {
+	return restoreState(store, c.before)
+}
+
+func (c *changeCommand) Coalesce(previous Command) (Command, bool) {
+	p, ok := previous.(*changeCommand)
+	if !ok || p.name != c.name {
+		return nil, false
+	}
+
+	merged := &changeCommand{name: c.name, done: true, before: make(State), after: make(State)}
+	for key, value := range p.before {
+		merged.before[key] = value
+	}
+	for key, value := range c.before {
+		if _, ok := merged.before[key]; !ok {
+			merged.before[key] = value
+		}
+	}
+	for _, after := range []State{p.after, c.after} {
+		for key, value := range after {
+			merged.after[key] = value
+		}
+	}
+	return merged, true
+}
+
+// dispatchCommand dispatches an action and undoes it with its inverse, as
+// returned by Dispatched
+type dispatchCommand struct {
+	action  Action
+	inverse Action
+}
+
+// Dispatched returns a command that dispatches action and is undone by
+// dispatching inverse, such as "todos/remove" for "todos/add"
+func Dispatched(action, inverse Action) Command {
+	return &dispatchCommand{action: action, inverse: inverse}
+}
+
+func (c *dispatchCommand) Name() string {
+	return c.action.Type
+}
+
+func (c *dispatchCommand) Execute(store *Store) error {
+	return store.Dispatch(c.action)
+}
+
+func (c *dispatchCommand) Undo(store *Store) error {
+	return store.Dispatch(c.inverse)
+}
+
+// groupCommand is the commands executed within History.Group
+type groupCommand struct {
+	name     string
+	commands []Command
+}
+
+func (c *groupCommand) Name() string {
+	return c.name
+}
+
+func (c *groupCommand) Execute(store *Store) error {
+	for i, command := range c.commands {
+		if err := command.Execute(store); err != nil {
+			undoAll(store, c.commands[:i])
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *groupCommand) Undo(store *Store) error {
+	for i := len(c.commands) - 1; i >= 0; i-- {
+		if err := c.commands[i].Undo(store); err != nil {
+			for _, command := range c.commands[i+1:] {
+				command.Execute(store)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// undoAll undoes executed commands, last first, ignoring errors
+func undoAll(store *Store, commands []Command) {
+	for i := len(commands) - 1; i >= 0; i-- {
+		commands[i].Undo(store)
+	}
+}
+
+// History executes commands on a store and keeps them on undo and redo
+// stacks, so editor-style applications can undo changes to their global
+// state without each component tracking its own. Executing a command clears
+// the redo stack, and only the last limit commands can be undone.
+//
+// Commands that implement Coalescer, as SetValue and Change do, merge with
+// the command executed just before them if it came within CoalesceWindow,
+// so that typing a word is undone at once rather than letter by letter.
+// Seal ends the merging early, such as when a field loses focus.
+type History struct {
+	// CoalesceWindow is how soon after the previous command a command must
+	// be executed to merge with it; zero merges regardless of time
+	CoalesceWindow time.Duration
+
+	store     *Store
+	limit     int
+	undo      []Command
+	redo      []Command
+	last      time.Time
+	sealed    bool
+	group     *groupCommand
+	listeners map[int]func()
+	nextID    int
+	mutex     sync.Mutex
+}
+
+// NewHistory creates a history of the commands executed on store, keeping
+// the last limit (100 for zero)
+func NewHistory(store *Store, limit int) *History {
+	if limit <= 0 {
+		limit = 100
+	}
+	return &History{
+		CoalesceWindow: time.Second,
+		store:          store,
+		limit:          limit,
+		listeners:      make(map[int]func()),
+	}
+}
+
+// Execute executes a command and pushes it on the undo stack. Nothing is
+// recorded if the command fails.
+func (h *History) Execute(command Command) error {
+	if err := command.Execute(h.store); err != nil {
+		return err
+	}
+
+	h.mutex.Lock()
+	if h.group != nil {
+		h.group.commands = append(h.group.commands, command)
+		h.mutex.Unlock()
+		return nil
+	}
+	h.push(command, true)
+	h.mutex.Unlock()
+
+	h.changed()
+	return nil
+}
+
+// Change executes a Change command
+func (h *History) Change(name string, fn func(store *Store)) error {
+	return h.Execute(Change(name, fn))
+}
+
+// push pushes an executed command, merging it with the previous one if it
+// can, and clears the redo stack. The caller holds the lock.
+func (h *History) push(command Command, coalesce bool) {
+	now := time.Now()
+	if coalescer, ok := command.(Coalescer); ok && coalesce && !h.sealed && len(h.undo) > 0 &&
+		(h.CoalesceWindow == 0 || now.Sub(h.last) <= h.CoalesceWindow) {
+		if merged, ok := coalescer.Coalesce(h.undo[len(h.undo)-1]); ok {
+			command = merged
+			h.undo = h.undo[:len(h.undo)-1]
+		}
+	}
+
+	h.undo = append(h.undo, command)
+	if len(h.undo) > h.limit {
+		h.undo = append([]Command(nil), h.undo[len(h.undo)-h.limit:]...)
+	}
+	h.redo = nil
+	h.last = now
+	h.sealed = false
+}
+
+// Group runs fn and records the commands it executes through the history as
+// one command named name, undone and redone together. If fn returns an
+// error, the commands it executed are undone and nothing is recorded.
+// Groups within fn join the outer group.
+func (h *History) Group(name string, fn func() error) error {
+	h.mutex.Lock()
+	if h.group != nil {
+		h.mutex.Unlock()
+		return fn()
+	}
+	group := &groupCommand{name: name}
+	h.group = group
+	h.mutex.Unlock()
+
+	err := fn()
+
+	h.mutex.Lock()
+	h.group = nil
+	if err != nil || len(group.commands) == 0 {
+		h.mutex.Unlock()
+		undoAll(h.store, group.commands)
+		return err
+	}
+	h.push(group, false)
+	h.mutex.Unlock()
+
+	h.changed()
+	return nil
+}
+
+// Undo undoes the last command and moves it to the redo stack. If the
+// command fails to undo, it stays on the undo stack.
+func (h *History) Undo() error {
+	h.mutex.Lock()
+	if len(h.undo) == 0 {
+		h.mutex.Unlock()
+		return fmt.Errorf("gouix: nothing to undo")
+	}
+	command := h.undo[len(h.undo)-1]
+	h.undo = h.undo[:len(h.undo)-1]
+	h.mutex.Unlock()
+
+	if err := command.Undo(h.store); err != nil {
+		h.mutex.Lock()
+		h.undo = append(h.undo, command)
+		h.mutex.Unlock()
+		return fmt.Errorf("gouix: undo %s: %v", command.Name(), err)
+	}
+
+	h.mutex.Lock()
+	h.redo = append(h.redo, command)
+	h.sealed = true
+	h.mutex.Unlock()
+
+	h.changed()
+	return nil
+}
+
+// Redo executes the last undone command again and moves it back to the
+// undo stack
+func (h *History) Redo() error {
+	h.mutex.Lock()
+	if len(h.redo) == 0 {
+		h.mutex.Unlock()
+		return fmt.Errorf("gouix: nothing to redo")
+	}
+	command := h.redo[len(h.redo)-1]
+	h.redo = h.redo[:len(h.redo)-1]
+	h.mutex.Unlock()
+
+	if err := command.Execute(h.store); err != nil {
+		h.mutex.Lock()
+		h.redo = append(h.redo, command)
+		h.mutex.Unlock()
+		return fmt.Errorf("gouix: redo %s: %v", command.Name(), err)
+	}
+
+	h.mutex.Lock()
+	h.undo = append(h.undo, command)
+	h.sealed = true
+	h.mutex.Unlock()
+
+	h.changed()
+	return nil
+}
+
+// CanUndo reports whether there is a command to undo
+func (h *History) CanUndo() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return len(h.undo) > 0
+}
+
+// CanRedo reports whether there is a command to redo
+func (h *History) CanRedo() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return len(h.redo) > 0
+}
+
+// UndoName returns the name of the command Undo would undo, or "" if there
+// is none, such as for an "Undo Move" menu item
+func (h *History) UndoName() string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if len(h.undo) == 0 {
+		return ""
+	}
+	return h.undo[len(h.undo)-1].Name()
+}
+
+// RedoName returns the name of the command Redo would execute, or ""
+func (h *History) RedoName() string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if len(h.redo) == 0 {
+		return ""
+	}
+	return h.redo[len(h.redo)-1].Name()
+}
+
+// Seal keeps the next command from merging with the last one
+func (h *History) Seal() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.sealed = true
+}
+
+// Clear empties both stacks, such as after a document is saved or loaded
+func (h *History) Clear() {
+	h.mutex.Lock()
+	h.undo, h.redo = nil, nil
+	h.mutex.Unlock()
+
+	h.changed()
+}
+
+// OnChange calls listener whenever the stacks change, such as to enable the
+// undo and redo buttons. It returns a function that removes the listener.
+func (h *History) OnChange(listener func()) func() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	id := h.nextID
+	h.nextID++
+	h.listeners[id] = listener
+
+	return func() {
+		h.mutex.Lock()
+		defer h.mutex.Unlock()
+
+		delete(h.listeners, id)
+	}
+}
+
+// changed calls the change listeners
+func (h *History) changed() {
+	h.mutex.Lock()
+	listeners := make([]func(), 0, len(h.listeners))
+	for id := 0; id < h.nextID; id++ {
+		if listener, ok := h.listeners[id]; ok {
+			listeners = append(listeners, listener)
+		}
+	}
+	h.mutex.Unlock()
+
+	for _, listener := range listeners {
+		listener()
+	}
+}
+
+// BindKeys binds mod+z to Undo, and mod+shift+z and mod+y to Redo
+func (h *History) BindKeys(keymap *Keymap) error {
+	bindings := []struct {
+		keys, description string
+		handler           func() error
+	}{
+		{"mod+z", "Undo", h.Undo},
+		{"mod+shift+z", "Redo", h.Redo},
+		{"mod+y", "Redo", h.Redo},
+	}
+	for _, binding := range bindings {
+		handler := binding.handler
+		if err := keymap.Bind(binding.keys, binding.description, func() { handler() }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package gouix
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHistoryUndoRedo(t *testing.T) {
+	store := NewStore(nil)
+	store.AddSlice("todos", []todo{}, func(state interface{}, action Action) interface{} {
+		todos := todosReducer(state, action).([]todo)
+		if action.Type == "todos/pop" {
+			return todos[:len(todos)-1]
+		}
+		return todos
+	})
+	store.AddSlice("count", 0, nil)
+
+	history := NewHistory(store, 0)
+	changes := 0
+	history.OnChange(func() { changes++ })
+
+	add := func(title string) Command {
+		return Dispatched(Action{Type: "todos/add", Payload: title}, Action{Type: "todos/pop"})
+	}
+
+	if err := history.Execute(add("Write docs")); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	history.Execute(add("Ship"))
+	if err := history.Change("Count", func(s *Store) { s.Set("count", 2) }); err != nil {
+		t.Fatalf("Change: %v", err)
+	}
+
+	if name := history.UndoName(); name != "Count" {
+		t.Fatalf("expected to undo Count, got %q", name)
+	}
+	history.Undo()
+	history.Undo()
+	if got := store.GetValue("todos").([]todo); len(got) != 1 || store.GetValue("count") != 0 {
+		t.Fatalf("expected one todo and no count after undoing twice, got %v and %v", got, store.GetValue("count"))
+	}
+
+	if err := history.Redo(); err != nil {
+		t.Fatalf("Redo: %v", err)
+	}
+	if got := store.GetValue("todos").([]todo); len(got) != 2 || history.RedoName() != "Count" {
+		t.Fatalf("expected the second todo back with Count to redo, got %v and %q", got, history.RedoName())
+	}
+
+	// A new command discards what could be redone
+	history.Execute(SetValue("filter", "done"))
+	if history.CanRedo() {
+		t.Fatalf("expected nothing to redo after a new command")
+	}
+	if err := history.Redo(); err == nil {
+		t.Fatalf("expected an error redoing nothing")
+	}
+	if changes != 7 {
+		t.Fatalf("expected 7 change notifications, got %d", changes)
+	}
+}
+
+func TestHistoryCoalescesAndLimits(t *testing.T) {
+	store := NewStore(map[string]interface{}{"title": ""})
+	history := NewHistory(store, 3)
+	history.CoalesceWindow = 0
+
+	for _, title := range []string{"H", "He", "Hey"} {
+		history.Execute(SetValue("title", title))
+	}
+	history.Seal()
+	history.Execute(SetValue("title", "Hey!"))
+
+	history.Undo()
+	if got := store.GetValue("title"); got != "Hey" {
+		t.Fatalf("expected the sealed edit undone alone, got %q", got)
+	}
+	history.Undo()
+	if got := store.GetValue("title"); got != "" || history.CanUndo() {
+		t.Fatalf("expected the typing undone at once, got %q", got)
+	}
+
+	// Only the last limit commands stay undoable
+	history.Redo()
+	history.Redo()
+	for i := 0; i < 5; i++ {
+		history.Seal()
+		history.Change("Rename", func(s *Store) { s.Set("title", i) })
+	}
+	undone := 0
+	for history.CanUndo() {
+		history.Undo()
+		undone++
+	}
+	if undone != 3 || store.GetValue("title") != 1 {
+		t.Fatalf("expected 3 undos back to 1, got %d back to %v", undone, store.GetValue("title"))
+	}
+}
+
+func TestHistoryGroup(t *testing.T) {
+	store := NewStore(map[string]interface{}{"x": 0, "y": 0})
+	history := NewHistory(store, 0)
+
+	err := history.Group("Move", func() error {
+		history.Execute(SetValue("x", 10))
+		return history.Execute(SetValue("y", 20))
+	})
+	if err != nil || history.UndoName() != "Move" {
+		t.Fatalf("expected a Move command, got %q (%v)", history.UndoName(), err)
+	}
+	history.Undo()
+	if store.GetValue("x") != 0 || store.GetValue("y") != 0 {
+		t.Fatalf("expected the move undone at once, got %v", store.State())
+	}
+
+	// A failed group is rolled back and not recorded
+	err = history.Group("Broken", func() error {
+		history.Execute(SetValue("x", 5))
+		return errors.New("invalid position")
+	})
+	if err == nil || store.GetValue("x") != 0 || history.UndoName() != "" {
+		t.Fatalf("expected the group rolled back, got x %v and %q (%v)", store.GetValue("x"), history.UndoName(), err)
+	}
+
+	keymap := NewKeymap("editor")
+	if err := history.BindKeys(keymap); err != nil {
+		t.Fatalf("BindKeys: %v", err)
+	}
+	history.Redo()
+	keymap.Trigger("mod+z")
+	if store.GetValue("x") != 0 || !history.CanRedo() {
+		t.Fatalf("expected mod+z to undo, got %v", store.State())
+	}
+}
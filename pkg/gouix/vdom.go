@@ -0,0 +1,873 @@
+package gouix
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// VNodeType is the kind of a virtual DOM node
+type VNodeType int
+
+const (
+	// ElementNode is an HTML element
+	ElementNode VNodeType = iota
+	// TextNode is a run of text
+	TextNode
+	// CommentNode is an HTML comment
+	CommentNode
+)
+
+// Attr is an element attribute
+type Attr struct {
+	Name  string
+	Value string
+}
+
+// VNode is a node of the virtual DOM. Children mirror the browser's childNodes,
+// including whitespace text and comments, so patch paths address the same
+// nodes on both sides.
+type VNode struct {
+	Type     VNodeType
+	Tag      string
+	Attrs    []Attr
+	Text     string
+	Children []*VNode
+}
+
+// Attr returns the value of an attribute and whether it is present
+func (n *VNode) Attr(name string) (string, bool) {
+	for _, attr := range n.Attrs {
+		if attr.Name == name {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+// key identifies an element across renders; elements with different keys are
+// replaced rather than patched
+func (n *VNode) key() string {
+	if key, ok := n.Attr("data-key"); ok {
+		return key
+	}
+	key, _ := n.Attr("id")
+	return key
+}
+
+// HTML renders the node back to HTML
+func (n *VNode) HTML() string {
+	var b strings.Builder
+	n.writeHTML(&b)
+	return b.String()
+}
+
+func (n *VNode) writeHTML(b *strings.Builder) {
+	switch n.Type {
+	case TextNode:
+		b.WriteString(html.EscapeString(n.Text))
+	case CommentNode:
+		b.WriteString("<!--")
+		b.WriteString(n.Text)
+		b.WriteString("-->")
+	default:
+		b.WriteString("<")
+		b.WriteString(n.Tag)
+		for _, attr := range n.Attrs {
+			b.WriteString(" ")
+			b.WriteString(attr.Name)
+			b.WriteString(`="`)
+			b.WriteString(html.EscapeString(attr.Value))
+			b.WriteString(`"`)
+		}
+		if voidElements[n.Tag] {
+			b.WriteString("/>")
+			return
+		}
+		b.WriteString(">")
+		if rawTextElements[n.Tag] {
+			for _, child := range n.Children {
+				b.WriteString(child.Text)
+			}
+		} else {
+			for _, child := range n.Children {
+				child.writeHTML(b)
+			}
+		}
+		b.WriteString("</")
+		b.WriteString(n.Tag)
+		b.WriteString(">")
+	}
+}
+
+// rawTextElements hold unparsed text
+var rawTextElements = map[string]bool{"script": true, "style": true, "textarea": true, "title": true}
+
+// ParseHTML parses rendered component HTML into virtual DOM nodes. It handles
+// the markup produced by CreateElement and ordinary hand-written HTML; unclosed
+// elements are closed at the end of input.
+func ParseHTML(source string) ([]*VNode, error) {
+	root := &VNode{Type: ElementNode}
+	stack := []*VNode{root}
+	i := 0
+
+	appendNode := func(node *VNode) {
+		parent := stack[len(stack)-1]
+		parent.Children = append(parent.Children, node)
+	}
+
+	appendText := func(text string) {
+		if text == "" {
+			return
+		}
+		parent := stack[len(stack)-1]
+		// Merge adjacent text, as the browser does
+		if count := len(parent.Children); count > 0 && parent.Children[count-1].Type == TextNode {
+			parent.Children[count-1].Text += text
+			return
+		}
+		appendNode(&VNode{Type: TextNode, Text: text})
+	}
+
+	for i < len(source) {
+		lt := strings.IndexByte(source[i:], '<')
+		if lt < 0 {
+			appendText(html.UnescapeString(source[i:]))
+			break
+		}
+		appendText(html.UnescapeString(source[i : i+lt]))
+		i += lt
+
+		rest := source[i:]
+		switch {
+		case strings.HasPrefix(rest, "<!--"):
+			end := strings.Index(rest[4:], "-->")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment at offset %d", i)
+			}
+			appendNode(&VNode{Type: CommentNode, Text: rest[4 : 4+end]})
+			i += 4 + end + 3
+
+		case strings.HasPrefix(rest, "<!"), strings.HasPrefix(rest, "<?"):
+			// Doctype and processing instructions are not part of the DOM tree
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated declaration at offset %d", i)
+			}
+			i += end + 1
+
+		case strings.HasPrefix(rest, "</"):
+			end := strings.IndexByte(rest, '>')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated end tag at offset %d", i)
+			}
+			tag := strings.ToLower(strings.TrimSpace(rest[2:end]))
+			i += end + 1
+
+			// Close the nearest matching element; stray end tags are ignored
+			for depth := len(stack) - 1; depth > 0; depth-- {
+				if stack[depth].Tag == tag {
+					stack = stack[:depth]
+					break
+				}
+			}
+
+		case len(rest) > 1 && isTagStart(rest[1]):
+			node, length, selfClosing, err := parseStartTag(rest)
+			if err != nil {
+				return nil, fmt.Errorf("%v at offset %d", err, i)
+			}
+			i += length
+			appendNode(node)
+
+			if selfClosing || voidElements[node.Tag] {
+				continue
+			}
+
+			if rawTextElements[node.Tag] {
+				closing := "</" + node.Tag
+				end := strings.Index(strings.ToLower(source[i:]), closing)
+				if end < 0 {
+					end = len(source) - i
+				}
+				if end > 0 {
+					text := source[i : i+end]
+					if node.Tag == "textarea" || node.Tag == "title" {
+						text = html.UnescapeString(text)
+					}
+					node.Children = []*VNode{{Type: TextNode, Text: text}}
+				}
+				i += end
+				if gt := strings.IndexByte(source[i:], '>'); gt >= 0 {
+					i += gt + 1
+				}
+				continue
+			}
+
+			stack = append(stack, node)
+
+		default:
+			// A lone '<' is text
+			appendText("<")
+			i++
+		}
+	}
+
+	return root.Children, nil
+}
+
+// isTagStart reports whether c can start a tag name
+func isTagStart(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// parseStartTag parses "<tag attr=value ...>" and returns the node, the number
+// of bytes consumed and whether the tag was written as self-closing
+func parseStartTag(source string) (*VNode, int, bool, error) {
+	i := 1
+	for i < len(source) && !isSpace(source[i]) && source[i] != '>' && source[i] != '/' {
+		i++
+	}
+	node := &VNode{Type: ElementNode, Tag: strings.ToLower(source[1:i])}
+
+	for {
+		for i < len(source) && isSpace(source[i]) {
+			i++
+		}
+		if i >= len(source) {
+			return nil, 0, false, fmt.Errorf("unterminated <%s> tag", node.Tag)
+		}
+
+		switch {
+		case source[i] == '>':
+			return node, i + 1, false, nil
+		case strings.HasPrefix(source[i:], "/>"):
+			return node, i + 2, true, nil
+		case source[i] == '/':
+			i++
+			continue
+		}
+
+		start := i
+		for i < len(source) && !isSpace(source[i]) && source[i] != '=' && source[i] != '>' && !strings.HasPrefix(source[i:], "/>") {
+			i++
+		}
+		name := strings.ToLower(source[start:i])
+
+		for i < len(source) && isSpace(source[i]) {
+			i++
+		}
+
+		value := ""
+		if i < len(source) && source[i] == '=' {
+			i++
+			for i < len(source) && isSpace(source[i]) {
+				i++
+			}
+			if i < len(source) && (source[i] == '"' || source[i] == '\'') {
+				quote := source[i]
+				end := strings.IndexByte(source[i+1:], quote)
+				if end < 0 {
+					return nil, 0, false, fmt.Errorf("unterminated attribute %s", name)
+				}
+				value = source[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				start := i
+				for i < len(source) && !isSpace(source[i]) && source[i] != '>' {
+					i++
+				}
+				value = source[start:i]
+			}
+		}
+
+		if name != "" {
+			if _, exists := node.Attr(name); !exists {
+				node.Attrs = append(node.Attrs, Attr{Name: name, Value: html.UnescapeString(value)})
+			}
+		}
+	}
+}
+
+// isSpace reports whether c is HTML whitespace
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// PatchOp is a DOM patch operation
+type PatchOp string
+
+const (
+	// PatchReplace replaces the node at Path with HTML; an empty Path replaces
+	// the whole content of the root container
+	PatchReplace PatchOp = "replace"
+	// PatchInsert inserts HTML as the child at Path, shifting later siblings
+	PatchInsert PatchOp = "insert"
+	// PatchRemove removes the node at Path
+	PatchRemove PatchOp = "remove"
+	// PatchSetAttr sets attribute Name to Value on the element at Path
+	PatchSetAttr PatchOp = "setAttr"
+	// PatchRemoveAttr removes attribute Name from the element at Path
+	PatchRemoveAttr PatchOp = "removeAttr"
+	// PatchSetText sets the text of the text or comment node at Path
+	PatchSetText PatchOp = "text"
+)
+
+// Patch is a single DOM update. Path is the list of child indexes from the
+// root container to the target node; patches apply in order.
+type Patch struct {
+	Op    PatchOp `json:"op"`
+	Path  []int   `json:"path"`
+	Name  string  `json:"name,omitempty"`
+	Value string  `json:"value,omitempty"`
+	HTML  string  `json:"html,omitempty"`
+}
+
+// PatchSet is the patch list for one root, as sent to the client runtime
+type PatchSet struct {
+	Root    string  `json:"root"`
+	Patches []Patch `json:"patches"`
+}
+
+// Diff returns the patches that turn the old children of a container into the
+// new ones
+func Diff(oldNodes, newNodes []*VNode) []Patch {
+	var patches []Patch
+	diffChildren(&patches, nil, oldNodes, newNodes)
+	return patches
+}
+
+// childPath returns a copy of path extended with index
+func childPath(path []int, index int) []int {
+	result := make([]int, len(path)+1)
+	copy(result, path)
+	result[len(path)] = index
+	return result
+}
+
+func diffChildren(patches *[]Patch, path []int, oldNodes, newNodes []*VNode) {
+	common := len(oldNodes)
+	if len(newNodes) < common {
+		common = len(newNodes)
+	}
+
+	for i := 0; i < common; i++ {
+		diffNode(patches, childPath(path, i), oldNodes[i], newNodes[i])
+	}
+
+	for i := common; i < len(newNodes); i++ {
+		*patches = append(*patches, Patch{Op: PatchInsert, Path: childPath(path, i), HTML: newNodes[i].HTML()})
+	}
+
+	// Remove from the end so earlier indexes stay valid
+	for i := len(oldNodes) - 1; i >= common; i-- {
+		*patches = append(*patches, Patch{Op: PatchRemove, Path: childPath(path, i)})
+	}
+}
+
+func diffNode(patches *[]Patch, path []int, oldNode, newNode *VNode) {
+	if oldNode.Type != newNode.Type || oldNode.Tag != newNode.Tag || oldNode.key() != newNode.key() {
+		*patches = append(*patches, Patch{Op: PatchReplace, Path: path, HTML: newNode.HTML()})
+		return
+	}
+
+	if newNode.Type != ElementNode {
+		if oldNode.Text != newNode.Text {
+			*patches = append(*patches, Patch{Op: PatchSetText, Path: path, Value: newNode.Text})
+		}
+		return
+	}
+
+	for _, attr := range newNode.Attrs {
+		if value, ok := oldNode.Attr(attr.Name); !ok || value != attr.Value {
+			*patches = append(*patches, Patch{Op: PatchSetAttr, Path: path, Name: attr.Name, Value: attr.Value})
+		}
+	}
+	for _, attr := range oldNode.Attrs {
+		if _, ok := newNode.Attr(attr.Name); !ok {
+			*patches = append(*patches, Patch{Op: PatchRemoveAttr, Path: path, Name: attr.Name})
+		}
+	}
+
+	// Raw text is replaced wholesale; a script must not run half-patched
+	if rawTextElements[newNode.Tag] {
+		if oldNode.HTML() != newNode.HTML() {
+			*patches = append(*patches, Patch{Op: PatchReplace, Path: path, HTML: newNode.HTML()})
+		}
+		return
+	}
+
+	diffChildren(patches, path, oldNode.Children, newNode.Children)
+}
+
+// ApplyPatches applies patches to a copy of the children of a container, as
+// the client runtime applies them to the DOM, and returns the new children
+func ApplyPatches(nodes []*VNode, patches []Patch) ([]*VNode, error) {
+	container := &VNode{Type: ElementNode, Children: cloneNodes(nodes)}
+
+	for _, patch := range patches {
+		if patch.Op == PatchReplace && len(patch.Path) == 0 {
+			children, err := ParseHTML(patch.HTML)
+			if err != nil {
+				return nil, err
+			}
+			container.Children = children
+			continue
+		}
+		if len(patch.Path) == 0 {
+			return nil, fmt.Errorf("%s patch without a path", patch.Op)
+		}
+
+		parent := container
+		for _, index := range patch.Path[:len(patch.Path)-1] {
+			if index < 0 || index >= len(parent.Children) {
+				return nil, fmt.Errorf("%s patch path %v does not exist", patch.Op, patch.Path)
+			}
+			parent = parent.Children[index]
+		}
+		index := patch.Path[len(patch.Path)-1]
+		limit := len(parent.Children)
+		if patch.Op == PatchInsert {
+			limit++
+		}
+		if index < 0 || index >= limit {
+			return nil, fmt.Errorf("%s patch path %v does not exist", patch.Op, patch.Path)
+		}
+
+		switch patch.Op {
+		case PatchReplace, PatchInsert:
+			fragment, err := ParseHTML(patch.HTML)
+			if err != nil {
+				return nil, err
+			}
+			end := index
+			if patch.Op == PatchReplace {
+				end++
+			}
+			children := make([]*VNode, 0, len(parent.Children)+len(fragment))
+			children = append(children, parent.Children[:index]...)
+			children = append(children, fragment...)
+			parent.Children = append(children, parent.Children[end:]...)
+		case PatchRemove:
+			parent.Children = append(parent.Children[:index:index], parent.Children[index+1:]...)
+		case PatchSetAttr:
+			target := parent.Children[index]
+			found := false
+			for i := range target.Attrs {
+				if target.Attrs[i].Name == patch.Name {
+					target.Attrs[i].Value = patch.Value
+					found = true
+					break
+				}
+			}
+			if !found {
+				target.Attrs = append(target.Attrs, Attr{Name: patch.Name, Value: patch.Value})
+			}
+		case PatchRemoveAttr:
+			target := parent.Children[index]
+			for i := range target.Attrs {
+				if target.Attrs[i].Name == patch.Name {
+					target.Attrs = append(target.Attrs[:i:i], target.Attrs[i+1:]...)
+					break
+				}
+			}
+		case PatchSetText:
+			parent.Children[index].Text = patch.Value
+		default:
+			return nil, fmt.Errorf("unknown patch op %q", patch.Op)
+		}
+	}
+
+	return container.Children, nil
+}
+
+// cloneNodes deep-copies nodes
+func cloneNodes(nodes []*VNode) []*VNode {
+	if nodes == nil {
+		return nil
+	}
+	clones := make([]*VNode, len(nodes))
+	for i, node := range nodes {
+		clone := *node
+		clone.Attrs = append([]Attr(nil), node.Attrs...)
+		clone.Children = cloneNodes(node.Children)
+		clones[i] = &clone
+	}
+	return clones
+}
+
+// StateNotifier is implemented by components that report state changes
+type StateNotifier interface {
+	OnStateChange(listener func()) func()
+}
+
+// Root renders a component into a container element and turns each later
+// render into patches against the previous one
+type Root struct {
+	// ID of the container element
+	ID string
+
+	component    Component
+	content      string
+	version      string
+	tree         []*VNode
+	rendered     bool
+	baseline     bool
+	listeners    map[int]func(PatchSet)
+	nextListener int
+	unsubscribe  func()
+	streams      map[*renderStream]bool
+	observer     func(*Root, PatchSet)
+	mutex        sync.Mutex
+
+	// Held while rendering and emitting so listeners see patch sets in order
+	refreshMutex sync.Mutex
+}
+
+// NewRoot creates a root for a component. When the component reports state
+// changes the root re-renders it and sends the resulting patches to its
+// listeners.
+func NewRoot(id string, component Component) *Root {
+	if id == "" {
+		id = string(component.GetID())
+	}
+
+	root := &Root{ID: id, component: component, listeners: make(map[int]func(PatchSet))}
+
+	if notifier, ok := component.(StateNotifier); ok {
+		root.unsubscribe = notifier.OnStateChange(func() {
+			root.Refresh()
+		})
+	}
+
+	return root
+}
+
+// Render renders the full container HTML. Listeners first receive any patches
+// since the previous render, so every client stays on the same baseline.
+func (r *Root) Render() string {
+	content, _ := r.render()
+	return fmt.Sprintf(`<div data-gouix-root="%s">%s</div>`, html.EscapeString(r.ID), content)
+}
+
+// RenderHydratable renders the container HTML marked with the version of its
+// content. A client hydrating the page sends the version back when it
+// connects, and keeps the server-rendered DOM if nothing changed since.
+func (r *Root) RenderHydratable() string {
+	content, version := r.render()
+	return r.container(content, version)
+}
+
+// container wraps content in the container marked with its version
+func (r *Root) container(content, version string) string {
+	return fmt.Sprintf(`<div data-gouix-root="%s" data-gouix-version="%s">%s</div>`,
+		html.EscapeString(r.ID), version, content)
+}
+
+// render renders the component for a full page and returns the content and
+// its version
+func (r *Root) render() (string, string) {
+	r.refreshMutex.Lock()
+	first := !r.isRendered()
+	patchSet, content, _ := r.update()
+	r.emit(patchSet)
+	version := r.Version()
+	r.refreshMutex.Unlock()
+
+	r.afterRender(first, patchSet)
+
+	return content, version
+}
+
+// Version identifies the last rendered content
+func (r *Root) Version() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.version
+}
+
+// Refresh re-renders the component and sends the patches since the last
+// render to the listeners. If the new output cannot be parsed the error is
+// returned and the next successful refresh replaces the whole content.
+func (r *Root) Refresh() (PatchSet, error) {
+	r.refreshMutex.Lock()
+	first := !r.isRendered()
+	patchSet, _, err := r.update()
+	if err != nil {
+		r.refreshMutex.Unlock()
+		return patchSet, err
+	}
+	r.emit(patchSet)
+	r.refreshMutex.Unlock()
+
+	r.afterRender(first, patchSet)
+
+	return patchSet, nil
+}
+
+// lifecycleUpdater is implemented by components embedding BaseComponent
+type lifecycleUpdater interface {
+	updated()
+}
+
+// afterRender runs lifecycle hooks once the render lock is released, so hooks
+// may change state: the first render mounts the component, and later renders
+// that changed the output run its update hooks
+func (r *Root) afterRender(first bool, patchSet PatchSet) {
+	r.mutex.Lock()
+	observer := r.observer
+	r.mutex.Unlock()
+	if observer != nil {
+		observer(r, patchSet)
+	}
+
+	if first {
+		r.component.Mount()
+		return
+	}
+	if len(patchSet.Patches) > 0 {
+		if updater, ok := r.component.(lifecycleUpdater); ok {
+			updater.updated()
+		}
+	}
+}
+
+// update renders the component and diffs it against the baseline; the caller
+// holds refreshMutex. If rendering panics, the error is reported and the
+// previous content is kept.
+func (r *Root) update() (PatchSet, string, error) {
+	leave := pushContext(rootContext, r)
+	content, renderErr := renderSafely(r.component)
+	leave()
+	if renderErr != nil {
+		reportRenderError(renderErr)
+
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+
+		r.rendered = true
+		return PatchSet{Root: r.ID}, r.content, renderErr
+	}
+	tree, err := ParseHTML(content)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	patchSet := PatchSet{Root: r.ID}
+	r.version = contentVersion(content)
+	r.content = content
+	r.rendered = true
+
+	if err != nil {
+		r.baseline = false
+		return patchSet, content, err
+	}
+
+	if r.baseline {
+		patchSet.Patches = Diff(r.tree, tree)
+	} else {
+		patchSet.Patches = []Patch{{Op: PatchReplace, Path: []int{}, HTML: content}}
+	}
+	r.tree = tree
+	r.baseline = true
+
+	return patchSet, content, nil
+}
+
+// OnPatch registers a listener for patches produced by state changes. It
+// returns a function that removes the listener.
+func (r *Root) OnPatch(listener func(PatchSet)) func() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.addListener(listener)
+}
+
+// Subscribe registers a listener whose first patch set replaces the client's
+// content with the current render. No patches are lost or repeated between
+// that snapshot and the patch sets that follow. It returns a function that
+// removes the listener.
+func (r *Root) Subscribe(listener func(PatchSet)) func() {
+	return r.Hydrate("", listener)
+}
+
+// Hydrate registers a listener for a client already showing the content with
+// the given version, such as a page from RenderHydratable. The snapshot is
+// only sent if the content has changed since; otherwise the listener receives
+// just the patch sets that follow. It returns a function that removes the
+// listener.
+func (r *Root) Hydrate(version string, listener func(PatchSet)) func() {
+	r.refreshMutex.Lock()
+	first := !r.isRendered()
+	if first {
+		r.update()
+	}
+
+	r.mutex.Lock()
+	current := version != "" && version == r.version
+	snapshot := PatchSet{Root: r.ID, Patches: []Patch{{Op: PatchReplace, Path: []int{}, HTML: r.content}}}
+	unsubscribe := r.addListener(listener)
+	r.mutex.Unlock()
+
+	if !current {
+		listener(snapshot)
+	}
+	r.refreshMutex.Unlock()
+
+	if first {
+		r.afterRender(true, PatchSet{Root: r.ID})
+	}
+
+	return unsubscribe
+}
+
+// observeRenders calls observer after each render, such as devtools counting
+// them, with the patches it produced
+func (r *Root) observeRenders(observer func(*Root, PatchSet)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.observer = observer
+}
+
+// isRendered reports whether the component has been rendered
+func (r *Root) isRendered() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.rendered
+}
+
+// addListener adds a listener; the caller holds mutex
+func (r *Root) addListener(listener func(PatchSet)) func() {
+	if r.listeners == nil {
+		r.listeners = make(map[int]func(PatchSet))
+	}
+	id := r.nextListener
+	r.nextListener++
+	r.listeners[id] = listener
+
+	return func() {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+
+		delete(r.listeners, id)
+	}
+}
+
+// emit sends a non-empty patch set to the listeners, in registration order.
+// Listeners must not call back into the root.
+func (r *Root) emit(patchSet PatchSet) {
+	if len(patchSet.Patches) == 0 {
+		return
+	}
+
+	r.mutex.Lock()
+	ids := make([]int, 0, len(r.listeners))
+	for id := range r.listeners {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	listeners := make([]func(PatchSet), len(ids))
+	for i, id := range ids {
+		listeners[i] = r.listeners[id]
+	}
+	r.mutex.Unlock()
+
+	for _, listener := range listeners {
+		listener(patchSet)
+	}
+}
+
+// Close stops listening for state changes, removes all listeners and unmounts
+// the component
+func (r *Root) Close() {
+	r.mutex.Lock()
+	if r.unsubscribe != nil {
+		r.unsubscribe()
+		r.unsubscribe = nil
+	}
+	r.listeners = make(map[int]func(PatchSet))
+	r.mutex.Unlock()
+
+	r.component.Unmount()
+}
+
+// PatchRuntime is the client-side script that applies patch sets to the DOM.
+// Include it once per page; patch sets are applied with
+// _gouix.applyPatches(patchSet).
+const PatchRuntime = `(function() {
+  var g = window._gouix = window._gouix || {};
+
+  function node(root, path) {
+    var n = root;
+    for (var i = 0; i < path.length && n; i++) n = n.childNodes[path[i]];
+    return n;
+  }
+
+  function fragment(html) {
+    var t = document.createElement('template');
+    t.innerHTML = html;
+    return t.content;
+  }
+
+  // insert and remove tell other runtimes, such as transitions, which nodes
+  // a patch adds and takes away
+  function insert(parent, html, before) {
+    var content = fragment(html), nodes = Array.prototype.slice.call(content.childNodes);
+    parent.insertBefore(content, before);
+    if (g.onInsert) nodes.forEach(g.onInsert);
+  }
+
+  function remove(target) {
+    if (g.onRemove) g.onRemove(target);
+    target.parentNode.removeChild(target);
+  }
+
+  g.applyPatches = function(set) {
+    var root = document.querySelector('[data-gouix-root="' + set.root + '"]');
+    if (!root) return false;
+    (set.patches || []).forEach(function(p) {
+      var target, parent;
+      switch (p.op) {
+      case 'replace':
+        if (!p.path.length) { root.innerHTML = p.html; break; }
+        target = node(root, p.path);
+        if (!target) break;
+        insert(target.parentNode, p.html, target);
+        remove(target);
+        break;
+      case 'insert':
+        parent = node(root, p.path.slice(0, -1));
+        if (parent) insert(parent, p.html, parent.childNodes[p.path[p.path.length - 1]] || null);
+        break;
+      case 'remove':
+        target = node(root, p.path);
+        if (target) remove(target);
+        break;
+      case 'setAttr':
+        target = node(root, p.path);
+        if (!target) break;
+        target.setAttribute(p.name, p.value || '');
+        // Leave the input being typed in alone; its events are still in flight
+        if (p.name === 'value' && 'value' in target && target !== document.activeElement) target.value = p.value || '';
+        if (p.name === 'checked') target.checked = true;
+        break;
+      case 'removeAttr':
+        target = node(root, p.path);
+        if (!target) break;
+        target.removeAttribute(p.name);
+        if (p.name === 'checked') target.checked = false;
+        break;
+      case 'text':
+        target = node(root, p.path);
+        if (target) target.nodeValue = p.value || '';
+        break;
+      }
+    });
+    return true;
+  };
+})();`
@@ -0,0 +1,136 @@
+package gouix
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseHTMLRoundTrip(t *testing.T) {
+	source := `<div class="card" id="c1"><h2>Title &amp; more</h2><!-- note --><img src="a.png"/><br>` +
+		`<p>Count: <b>3</b></p><script>if (a < b) {}</script></div> `
+
+	nodes, err := ParseHTML(source)
+	if err != nil {
+		t.Fatalf("ParseHTML returned error: %v", err)
+	}
+	if len(nodes) != 2 || nodes[1].Type != TextNode {
+		t.Fatalf("expected an element and a trailing text node, got %d nodes", len(nodes))
+	}
+
+	div := nodes[0]
+	if len(div.Children) != 6 {
+		t.Fatalf("expected 6 children, got %d", len(div.Children))
+	}
+	if div.Children[0].Children[0].Text != "Title & more" {
+		t.Fatalf("expected entities to be decoded, got %q", div.Children[0].Children[0].Text)
+	}
+	if div.Children[1].Type != CommentNode || div.Children[2].Tag != "img" || div.Children[3].Tag != "br" {
+		t.Fatalf("unexpected children %+v", div.Children)
+	}
+	if script := div.Children[5]; script.Children[0].Text != "if (a < b) {}" {
+		t.Fatalf("expected raw script text, got %q", script.Children[0].Text)
+	}
+
+	again, err := ParseHTML(div.HTML())
+	if err != nil {
+		t.Fatalf("ParseHTML of rendered HTML returned error: %v", err)
+	}
+	if patches := Diff(nodes[:1], again); len(patches) != 0 {
+		t.Fatalf("expected no patches after a round trip, got %+v", patches)
+	}
+}
+
+func TestDiffProducesMinimalPatches(t *testing.T) {
+	oldNodes, _ := ParseHTML(`<ul class="list"><li>a</li><li>b</li><li>c</li></ul>`)
+	newNodes, _ := ParseHTML(`<ul class="list big"><li>a</li><li>B</li></ul>`)
+
+	patches := Diff(oldNodes, newNodes)
+	expected := []Patch{
+		{Op: PatchSetAttr, Path: []int{0}, Name: "class", Value: "list big"},
+		{Op: PatchSetText, Path: []int{0, 1, 0}, Value: "B"},
+		{Op: PatchRemove, Path: []int{0, 2}},
+	}
+
+	if fmt.Sprint(patches) != fmt.Sprint(expected) {
+		t.Fatalf("expected %+v, got %+v", expected, patches)
+	}
+
+	// Elements with different keys are replaced, not patched
+	oldNodes, _ = ParseHTML(`<div id="a">x</div>`)
+	newNodes, _ = ParseHTML(`<div id="b">x</div><p>new</p>`)
+	patches = Diff(oldNodes, newNodes)
+	if len(patches) != 2 || patches[0].Op != PatchReplace || patches[1].Op != PatchInsert || patches[1].HTML != "<p>new</p>" {
+		t.Fatalf("unexpected patches %+v", patches)
+	}
+}
+
+func TestApplyPatchesMatchesDiff(t *testing.T) {
+	oldNodes, _ := ParseHTML(`<ul class="list"><li>a</li><li>b</li><li>c</li></ul><p id="x">old</p>`)
+	newNodes, _ := ParseHTML(`<ul><li>a</li><li>B</li></ul><p id="y">new</p><footer>end</footer>`)
+
+	patched, err := ApplyPatches(oldNodes, Diff(oldNodes, newNodes))
+	if err != nil {
+		t.Fatalf("ApplyPatches: %v", err)
+	}
+	if patches := Diff(patched, newNodes); len(patches) != 0 {
+		t.Fatalf("expected the patched tree to match, still differs by %+v", patches)
+	}
+	if oldNodes[0].Children[1].Children[0].Text != "b" {
+		t.Errorf("expected the original nodes to be left unchanged")
+	}
+
+	if _, err := ApplyPatches(oldNodes, []Patch{{Op: PatchRemove, Path: []int{5}}}); err == nil {
+		t.Errorf("expected an error for a missing path")
+	}
+}
+
+// patchCounter is a hyper(reactive) component for root tests
+type patchCounter struct {
+	HyperComponent
+}
+
+func (c *patchCounter) Render() string {
+	return CreateElement("div", Props{"id": "counter", "class": "counter"},
+		CreateElement("h2", nil, "Counter"),
+		CreateElement("p", Props{"id": "counter-count"}, fmt.Sprint(c.GetState("count"))),
+	)
+}
+
+func TestRootPatchesOnSetState(t *testing.T) {
+	counter := &patchCounter{HyperComponent: HyperComponent{store: NewStore(map[string]interface{}{"count": 1})}}
+	counter.init("counter", nil)
+
+	root := NewRoot("", counter)
+	html := root.Render()
+	if !strings.HasPrefix(html, `<div data-gouix-root="counter">`) {
+		t.Fatalf("unexpected root HTML %s", html)
+	}
+
+	var received []PatchSet
+	root.OnPatch(func(patchSet PatchSet) {
+		received = append(received, patchSet)
+	})
+
+	counter.SetState("count", 2)
+	counter.SetState("count", 2) // unchanged, no patch
+
+	if len(received) != 1 {
+		t.Fatalf("expected 1 patch set, got %d", len(received))
+	}
+
+	data, err := json.Marshal(received[0])
+	if err != nil {
+		t.Fatalf("marshal patch set: %v", err)
+	}
+	if string(data) != `{"root":"counter","patches":[{"op":"text","path":[0,1,0],"value":"2"}]}` {
+		t.Fatalf("unexpected patch JSON %s", data)
+	}
+
+	root.Close()
+	counter.SetState("count", 3)
+	if len(received) != 1 {
+		t.Fatalf("expected no patches after Close")
+	}
+}
@@ -0,0 +1,548 @@
+package gouix
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// VirtualList renders only the rows of a large list that are in or near its
+// scrolling viewport, with spacers standing in for the rest. The client
+// runtime reports the scroll position and the measured height of the rows it
+// shows; rows not measured yet are estimated from the average, so rows of
+// different heights keep their place as the user scrolls.
+type VirtualList struct {
+	BaseComponent
+
+	// Tag is the scrolling element and ItemTag the element of each row, both
+	// "div" by default
+	Tag     string
+	ItemTag string
+
+	// Class is added to the scrolling element
+	Class string
+
+	// Height is the height of the viewport in pixels, 400 by default
+	Height int
+
+	// ItemHeight is the estimated row height in pixels, used until rows have
+	// been measured; 40 by default
+	ItemHeight int
+
+	// Overscan is the number of rows rendered above and below the viewport,
+	// so fast scrolling does not show empty space; 5 by default
+	Overscan int
+
+	// Threshold is how many rows from the end the next page of an infinite
+	// scroll is loaded; 10 by default
+	Threshold int
+
+	count         int
+	renderItem    func(index int) string
+	scrollTop     int
+	viewport      int
+	heights       map[int]int
+	scrollRequest int
+	pager         *InfiniteScroll
+	mutex         sync.RWMutex
+}
+
+// NewVirtualList creates a virtual list of count rows, each rendered by
+// renderItem
+func NewVirtualList(id ComponentID, count int, renderItem func(index int) string) *VirtualList {
+	list := &VirtualList{
+		Tag:        "div",
+		ItemTag:    "div",
+		Height:     400,
+		ItemHeight: 40,
+		Overscan:   5,
+		Threshold:  10,
+		count:      count,
+		renderItem: renderItem,
+		heights:    make(map[int]int),
+	}
+	list.init(id, nil)
+
+	list.On("scroll", func(event Event) interface{} {
+		heights := make(map[int]int)
+		if measured, ok := event.Data["heights"].(map[string]interface{}); ok {
+			for key, value := range measured {
+				if index, err := strconv.Atoi(key); err == nil {
+					heights[index] = eventInt(value)
+				}
+			}
+		}
+		return list.Scroll(eventInt(event.Data["top"]), eventInt(event.Data["height"]), heights)
+	})
+
+	return list
+}
+
+// Count returns the number of rows
+func (l *VirtualList) Count() int {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	return l.count
+}
+
+// SetCount changes the number of rows, for example after items are added.
+// Measured heights of rows past the end are forgotten.
+func (l *VirtualList) SetCount(count int) {
+	l.mutex.Lock()
+	l.count = count
+	for index := range l.heights {
+		if index >= count {
+			delete(l.heights, index)
+		}
+	}
+	l.mutex.Unlock()
+
+	l.notifyStateChange()
+}
+
+// ScrollTop returns the scroll position in pixels. It is rendered with the
+// list, so a page rendered again, or reconnecting, restores it.
+func (l *VirtualList) ScrollTop() int {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	return l.scrollTop
+}
+
+// ScrollToIndex scrolls the list in the browser so the row at index is at
+// the top of the viewport
+func (l *VirtualList) ScrollToIndex(index int) {
+	l.mutex.Lock()
+	if index >= l.count {
+		index = l.count - 1
+	}
+	if index < 0 {
+		index = 0
+	}
+	l.scrollTop = l.offset(index)
+	l.scrollRequest++
+	l.mutex.Unlock()
+
+	l.notifyStateChange()
+}
+
+// Scroll records the scroll position, the viewport height (0 keeps Height)
+// and newly measured row heights, as the client runtime reports them. The
+// list is rendered again only if the rows shown change. Near the end of an
+// infinite scroll the next page is loaded, and an error loading it is
+// returned.
+func (l *VirtualList) Scroll(top, viewport int, heights map[int]int) error {
+	l.mutex.Lock()
+	before := l.window()
+	if top < 0 {
+		top = 0
+	}
+	l.scrollTop = top
+	if viewport > 0 {
+		l.viewport = viewport
+	}
+	for index, height := range heights {
+		if index >= 0 && index < l.count && height > 0 {
+			l.heights[index] = height
+		}
+	}
+	after := l.window()
+	pager := l.pager
+	loadMore := pager != nil && after.end >= l.count-l.Threshold
+	l.mutex.Unlock()
+
+	if after != before {
+		l.notifyStateChange()
+	}
+	if loadMore && pager.HasMore() {
+		return pager.LoadMore(context.Background())
+	}
+	return nil
+}
+
+// UseInfiniteScroll pages the list's rows from pager: the first page is
+// loaded now, unless it already has been, and the next one whenever the user
+// scrolls within Threshold rows of the end. Render rows with pager.Item.
+func (l *VirtualList) UseInfiniteScroll(pager *InfiniteScroll) error {
+	l.mutex.Lock()
+	l.pager = pager
+	l.mutex.Unlock()
+
+	pager.OnLoad(func(items []interface{}) {
+		l.SetCount(len(items))
+	})
+
+	if len(pager.Items()) > 0 || !pager.HasMore() {
+		l.SetCount(len(pager.Items()))
+		return nil
+	}
+	return pager.LoadMore(context.Background())
+}
+
+// listWindow is the range of rows rendered and the space around them
+type listWindow struct {
+	start, end    int
+	before, after int
+}
+
+// estimate is the height assumed for rows not measured yet; the caller holds
+// the lock
+func (l *VirtualList) estimate() int {
+	if len(l.heights) == 0 {
+		return l.ItemHeight
+	}
+	total := 0
+	for _, height := range l.heights {
+		total += height
+	}
+	return total / len(l.heights)
+}
+
+// offset is the position of the top of a row; the caller holds the lock
+func (l *VirtualList) offset(index int) int {
+	estimate := l.estimate()
+	offset := index * estimate
+	for measured, height := range l.heights {
+		if measured < index {
+			offset += height - estimate
+		}
+	}
+	return offset
+}
+
+// window works out which rows are in or near the viewport; the caller holds
+// the lock
+func (l *VirtualList) window() listWindow {
+	if l.count == 0 {
+		return listWindow{}
+	}
+
+	viewport := l.viewport
+	if viewport == 0 {
+		viewport = l.Height
+	}
+
+	// The first row whose bottom is below the top of the viewport, and the
+	// first row starting below its bottom
+	first := sort.Search(l.count, func(i int) bool { return l.offset(i+1) > l.scrollTop })
+	last := sort.Search(l.count, func(i int) bool { return l.offset(i) >= l.scrollTop+viewport })
+
+	start := first - l.Overscan
+	if start < 0 {
+		start = 0
+	}
+	end := last + l.Overscan
+	if end > l.count {
+		end = l.count
+	}
+
+	return listWindow{
+		start:  start,
+		end:    end,
+		before: l.offset(start),
+		after:  l.offset(l.count) - l.offset(end),
+	}
+}
+
+// Render implements the Component interface
+func (l *VirtualList) Render() string {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	window := l.window()
+	rows := make([]string, 0, window.end-window.start+2)
+	rows = append(rows, spacer(window.before))
+	for i := window.start; i < window.end; i++ {
+		rows = append(rows, CreateElement(l.ItemTag, Props{
+			"role":          "listitem",
+			"data-index":    strconv.Itoa(i),
+			"aria-posinset": strconv.Itoa(i + 1),
+			"aria-setsize":  strconv.Itoa(l.count),
+		}, l.renderItem(i)))
+	}
+	rows = append(rows, spacer(window.after))
+
+	class := "gouix-virtual-list"
+	if l.Class != "" {
+		class += " " + l.Class
+	}
+
+	id := string(l.GetID())
+	return CreateElement(l.Tag, Props{
+		"id":                        html.EscapeString(id),
+		"class":                     class,
+		"role":                      "list",
+		"style":                     fmt.Sprintf("height:%dpx;overflow-y:auto", l.Height),
+		"data-gouix-virtual":        html.EscapeString(id),
+		"data-gouix-estimate":       strconv.Itoa(l.estimate()),
+		"data-gouix-scroll-top":     strconv.Itoa(l.scrollTop),
+		"data-gouix-scroll-request": strconv.Itoa(l.scrollRequest),
+	}, rows)
+}
+
+// spacer stands in for the rows that are not rendered
+func spacer(height int) string {
+	return CreateElement("div", Props{
+		"aria-hidden":       "true",
+		"data-gouix-spacer": true,
+		"style":             fmt.Sprintf("height:%dpx", height),
+	})
+}
+
+// Page is one page of items and the cursor of the page after it
+type Page struct {
+	Items   []interface{}
+	Cursor  string
+	HasMore bool
+}
+
+// ParsePage reads a page from a query result in the connection format:
+//
+//	{"edges": [{"node": ..., "cursor": "..."}],
+//	 "pageInfo": {"hasNextPage": true, "endCursor": "..."}}
+//
+// A result with "nodes" rather than "edges", a Page, or a plain slice, which
+// is the last page, are accepted too.
+func ParsePage(result interface{}) (Page, error) {
+	switch value := result.(type) {
+	case Page:
+		return value, nil
+	case *Page:
+		return *value, nil
+	case []interface{}:
+		return Page{Items: value}, nil
+	case map[string]interface{}:
+		var page Page
+		if edges, ok := value["edges"].([]interface{}); ok {
+			for _, edge := range edges {
+				fields, ok := edge.(map[string]interface{})
+				if !ok {
+					return Page{}, fmt.Errorf("gouix: connection edge is %T, not an object", edge)
+				}
+				page.Items = append(page.Items, fields["node"])
+				if cursor, ok := fields["cursor"].(string); ok {
+					page.Cursor = cursor
+				}
+			}
+		} else if nodes, ok := value["nodes"].([]interface{}); ok {
+			page.Items = nodes
+		} else {
+			return Page{}, fmt.Errorf("gouix: query result has no edges or nodes")
+		}
+
+		if info, ok := value["pageInfo"].(map[string]interface{}); ok {
+			page.HasMore, _ = info["hasNextPage"].(bool)
+			if cursor, ok := info["endCursor"].(string); ok {
+				page.Cursor = cursor
+			}
+		}
+		return page, nil
+	}
+	return Page{}, fmt.Errorf("gouix: cannot read a page from %T", result)
+}
+
+// InfiniteScroll loads the pages of a query one after the other, passing the
+// page size as "first" and the cursor of the previous page as "after", the
+// way connections are paged. Use it with VirtualList.UseInfiniteScroll.
+type InfiniteScroll struct {
+	// PageSize is the number of items asked for per page, 20 by default
+	PageSize int
+
+	query   func(ctx context.Context, params map[string]interface{}) (interface{}, error)
+	params  map[string]interface{}
+	items   []interface{}
+	cursor  string
+	hasMore bool
+	loading bool
+	err     error
+	onLoad  []func(items []interface{})
+	mutex   sync.RWMutex
+}
+
+// NewInfiniteScroll creates an infinite scroll over query, such as a
+// GoScaleAPI resolver from GetResolvers()["query:posts"], called with params
+// and the paging parameters
+func NewInfiniteScroll(query func(ctx context.Context, params map[string]interface{}) (interface{}, error), params map[string]interface{}) *InfiniteScroll {
+	return &InfiniteScroll{
+		PageSize: 20,
+		query:    query,
+		params:   params,
+		hasMore:  true,
+	}
+}
+
+// OnLoad adds a function called with all the items after a page is loaded
+func (s *InfiniteScroll) OnLoad(handler func(items []interface{})) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.onLoad = append(s.onLoad, handler)
+}
+
+// LoadMore loads the next page, unless the last one has been loaded or one is
+// loading already. A failed page can be loaded again.
+func (s *InfiniteScroll) LoadMore(ctx context.Context) error {
+	s.mutex.Lock()
+	if s.loading || !s.hasMore {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.loading = true
+	params := make(map[string]interface{}, len(s.params)+2)
+	for key, value := range s.params {
+		params[key] = value
+	}
+	params["first"] = s.PageSize
+	if s.cursor != "" {
+		params["after"] = s.cursor
+	}
+	s.mutex.Unlock()
+
+	result, err := s.query(ctx, params)
+	var page Page
+	if err == nil {
+		page, err = ParsePage(result)
+	}
+
+	s.mutex.Lock()
+	s.loading = false
+	s.err = err
+	if err != nil {
+		s.mutex.Unlock()
+		return err
+	}
+	s.items = append(s.items, page.Items...)
+	s.cursor = page.Cursor
+	s.hasMore = page.HasMore && page.Cursor != ""
+	items := append([]interface{}(nil), s.items...)
+	handlers := append([]func([]interface{}){}, s.onLoad...)
+	s.mutex.Unlock()
+
+	for _, handler := range handlers {
+		handler(items)
+	}
+	return nil
+}
+
+// Reset forgets the loaded pages, for example after the params change
+func (s *InfiniteScroll) Reset(params map[string]interface{}) {
+	s.mutex.Lock()
+	s.params = params
+	s.items = nil
+	s.cursor = ""
+	s.hasMore = true
+	s.err = nil
+	handlers := append([]func([]interface{}){}, s.onLoad...)
+	s.mutex.Unlock()
+
+	for _, handler := range handlers {
+		handler(nil)
+	}
+}
+
+// Items returns the items loaded so far
+func (s *InfiniteScroll) Items() []interface{} {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return append([]interface{}(nil), s.items...)
+}
+
+// Item returns a loaded item, or nil
+func (s *InfiniteScroll) Item(index int) interface{} {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if index < 0 || index >= len(s.items) {
+		return nil
+	}
+	return s.items[index]
+}
+
+// HasMore reports whether there are pages left to load
+func (s *InfiniteScroll) HasMore() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.hasMore
+}
+
+// Loading reports whether a page is being loaded
+func (s *InfiniteScroll) Loading() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.loading
+}
+
+// Err returns the error loading the last page, if any
+func (s *InfiniteScroll) Err() error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.err
+}
+
+// VirtualRuntime is the client-side script for virtual lists. It reports the
+// scroll position of each data-gouix-virtual element, at most once a frame,
+// with the heights of rows it has not measured yet. A list rendered on a new
+// page or after reconnecting is scrolled back to its data-gouix-scroll-top,
+// and ScrollToIndex scrolls it by changing data-gouix-scroll-request.
+const VirtualRuntime = `(function() {
+  var g = window._gouix = window._gouix || {};
+
+  function measure(list) {
+    var measured = list._gouixMeasured = list._gouixMeasured || {}, heights = {}, changed = false;
+    Array.prototype.forEach.call(list.querySelectorAll('[data-index]'), function(row) {
+      var index = row.getAttribute('data-index'), height = row.offsetHeight;
+      if (height && measured[index] !== height) {
+        measured[index] = heights[index] = height;
+        changed = true;
+      }
+    });
+    return changed ? heights : null;
+  }
+
+  function report(list, force) {
+    var heights = measure(list);
+    if (!heights && !force) return;
+    if (g.dispatchEvent) g.dispatchEvent(list.getAttribute('data-gouix-virtual'), 'scroll', {top: Math.round(list.scrollTop), height: list.clientHeight, heights: heights || {}});
+  }
+
+  function setup(list) {
+    var request = list.getAttribute('data-gouix-scroll-request');
+    if (!list._gouixVirtual || list._gouixRequest !== request) {
+      list.scrollTop = parseInt(list.getAttribute('data-gouix-scroll-top'), 10) || 0;
+    }
+    list._gouixRequest = request;
+    if (!list._gouixVirtual) {
+      list._gouixVirtual = true;
+      list.addEventListener('scroll', function() {
+        if (list._gouixFrame) return;
+        list._gouixFrame = requestAnimationFrame(function() {
+          list._gouixFrame = null;
+          report(list, true);
+        });
+      }, {passive: true});
+    }
+    report(list, false);
+  }
+
+  function scan() {
+    Array.prototype.forEach.call(document.querySelectorAll('[data-gouix-virtual]'), setup);
+  }
+
+  var apply = g.applyPatches;
+  g.applyPatches = function(set) {
+    var applied = apply(set);
+    if (applied) scan();
+    return applied;
+  };
+
+  if (document.readyState === 'loading') document.addEventListener('DOMContentLoaded', scan);
+  else scan();
+})();`
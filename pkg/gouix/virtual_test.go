@@ -0,0 +1,203 @@
+package gouix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// rowIndexes returns the data-index of the rows a virtual list rendered
+func rowIndexes(t *testing.T, markup string) []string {
+	t.Helper()
+
+	nodes, err := ParseHTML(markup)
+	if err != nil {
+		t.Fatalf("parse list: %v", err)
+	}
+	var indexes []string
+	for _, child := range nodes[0].Children {
+		if index, ok := child.Attr("data-index"); ok {
+			indexes = append(indexes, index)
+		}
+	}
+	return indexes
+}
+
+func TestVirtualListRendersVisibleRows(t *testing.T) {
+	list := NewVirtualList("rows", 1000, func(index int) string {
+		return fmt.Sprintf("Row %d", index)
+	})
+	list.Height = 100
+	list.ItemHeight = 20
+	list.Overscan = 2
+
+	html := list.Render()
+	if !strings.HasPrefix(html, `<div class="gouix-virtual-list" data-gouix-estimate="20" data-gouix-scroll-request="0" data-gouix-scroll-top="0" data-gouix-virtual="rows" id="rows" role="list" style="height:100px;overflow-y:auto"><div aria-hidden="true" data-gouix-spacer style="height:0px"></div>`) {
+		t.Fatalf("unexpected markup %s", html)
+	}
+	if indexes := rowIndexes(t, html); !reflect.DeepEqual(indexes, []string{"0", "1", "2", "3", "4", "5", "6"}) {
+		t.Fatalf("expected the first rows, got %v", indexes)
+	}
+	if !strings.Contains(html, `<div aria-hidden="true" data-gouix-spacer style="height:19860px"></div></div>`) {
+		t.Errorf("expected a spacer for the remaining rows, got %s", html)
+	}
+	if !strings.Contains(html, `<div aria-posinset="1" aria-setsize="1000" data-index="0" role="listitem">Row 0</div>`) {
+		t.Errorf("expected rows with their position in the list, got %s", html)
+	}
+
+	changes := 0
+	list.OnStateChange(func() { changes++ })
+
+	list.HandleEvent(Event{Type: "scroll", Data: map[string]interface{}{"top": float64(1010), "height": float64(100)}})
+	html = list.Render()
+	if indexes := rowIndexes(t, html); !reflect.DeepEqual(indexes, []string{"48", "49", "50", "51", "52", "53", "54", "55", "56", "57"}) {
+		t.Fatalf("expected rows around row 50, got %v", indexes)
+	}
+	if !strings.Contains(html, `style="height:960px"`) || !strings.Contains(html, `data-gouix-scroll-top="1010"`) {
+		t.Errorf("expected the rows above to be replaced by a spacer, got %s", html)
+	}
+
+	// Scrolling within the same rows does not render the list again
+	list.Scroll(1015, 0, nil)
+	if changes != 1 || list.ScrollTop() != 1015 {
+		t.Errorf("expected a single change, got %d at %d", changes, list.ScrollTop())
+	}
+}
+
+func TestVirtualListEstimatesHeights(t *testing.T) {
+	list := NewVirtualList("rows", 100, func(index int) string { return "" })
+	list.Height = 100
+	list.ItemHeight = 20
+	list.Overscan = 0
+
+	// Measured rows change the estimate of the others
+	list.HandleEvent(Event{Type: "scroll", Data: map[string]interface{}{
+		"top":     float64(0),
+		"heights": map[string]interface{}{"0": float64(50), "1": float64(50), "2": float64(50)},
+	}})
+
+	html := list.Render()
+	if indexes := rowIndexes(t, html); !reflect.DeepEqual(indexes, []string{"0", "1"}) {
+		t.Fatalf("expected two tall rows to fill the viewport, got %v", indexes)
+	}
+	if !strings.Contains(html, `data-gouix-estimate="50"`) || !strings.Contains(html, `style="height:4900px"`) {
+		t.Errorf("expected the rest to be estimated at 50px, got %s", html)
+	}
+
+	list.ScrollToIndex(10)
+	if list.ScrollTop() != 500 || !strings.Contains(list.Render(), `data-gouix-scroll-request="1"`) {
+		t.Errorf("expected a scroll request to row 10, got %d", list.ScrollTop())
+	}
+	list.SetCount(0)
+	list.ScrollToIndex(10)
+	if list.ScrollTop() != 0 || rowIndexes(t, list.Render()) != nil {
+		t.Errorf("expected an empty list at the top, got %d", list.ScrollTop())
+	}
+}
+
+func TestParsePage(t *testing.T) {
+	page, err := ParsePage(map[string]interface{}{
+		"edges": []interface{}{
+			map[string]interface{}{"node": "a", "cursor": "c1"},
+			map[string]interface{}{"node": "b", "cursor": "c2"},
+		},
+		"pageInfo": map[string]interface{}{"hasNextPage": true, "endCursor": "c2"},
+	})
+	if err != nil || !reflect.DeepEqual(page, Page{Items: []interface{}{"a", "b"}, Cursor: "c2", HasMore: true}) {
+		t.Fatalf("unexpected page %+v, %v", page, err)
+	}
+
+	page, err = ParsePage([]interface{}{"x"})
+	if err != nil || page.HasMore || len(page.Items) != 1 {
+		t.Errorf("expected a plain slice to be the last page, got %+v, %v", page, err)
+	}
+	if _, err := ParsePage(map[string]interface{}{"total": 3}); err == nil {
+		t.Errorf("expected an error for a result without items")
+	}
+}
+
+// postsQuery pages through total posts like a connection resolver
+func postsQuery(total int, calls *[]map[string]interface{}) func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	return func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		*calls = append(*calls, params)
+		if params["fail"] == true {
+			return nil, errors.New("unavailable")
+		}
+
+		start := 0
+		if after, ok := params["after"].(string); ok {
+			fmt.Sscanf(after, "post-%d", &start)
+			start++
+		}
+		end := start + params["first"].(int)
+		if end > total {
+			end = total
+		}
+
+		edges := []interface{}{}
+		for i := start; i < end; i++ {
+			edges = append(edges, map[string]interface{}{"node": fmt.Sprintf("Post %d", i), "cursor": fmt.Sprintf("post-%d", i)})
+		}
+		return map[string]interface{}{
+			"edges":    edges,
+			"pageInfo": map[string]interface{}{"hasNextPage": end < total, "endCursor": fmt.Sprintf("post-%d", end-1)},
+		}, nil
+	}
+}
+
+func TestVirtualListInfiniteScroll(t *testing.T) {
+	var calls []map[string]interface{}
+	pager := NewInfiniteScroll(postsQuery(45, &calls), map[string]interface{}{"tag": "go"})
+
+	list := NewVirtualList("feed", 0, func(index int) string {
+		return pager.Item(index).(string)
+	})
+	list.Height = 200
+	list.ItemHeight = 20
+	list.Threshold = 3
+
+	if err := list.UseInfiniteScroll(pager); err != nil {
+		t.Fatalf("UseInfiniteScroll returned error: %v", err)
+	}
+	if list.Count() != 20 || !reflect.DeepEqual(calls[0], map[string]interface{}{"tag": "go", "first": 20}) {
+		t.Fatalf("expected the first page, got %d rows after %v", list.Count(), calls)
+	}
+
+	// Far from the end nothing more is loaded
+	list.Scroll(0, 0, nil)
+	if len(calls) != 1 {
+		t.Fatalf("expected no request, got %v", calls)
+	}
+
+	list.Scroll(100, 0, nil)
+	if list.Count() != 40 || calls[1]["after"] != "post-19" {
+		t.Fatalf("expected the second page, got %d rows after %v", list.Count(), calls)
+	}
+	if !strings.Contains(list.Render(), "Post 19") {
+		t.Errorf("expected the loaded rows to render")
+	}
+
+	list.ScrollToIndex(39)
+	list.Scroll(list.ScrollTop(), 0, nil)
+	list.Scroll(list.ScrollTop(), 0, nil)
+	if list.Count() != 45 || pager.HasMore() || len(calls) != 3 {
+		t.Errorf("expected all 45 posts in 3 requests, got %d rows after %d requests", list.Count(), len(calls))
+	}
+
+	pager.Reset(map[string]interface{}{"fail": true})
+	if list.Count() != 0 {
+		t.Errorf("expected the reset to empty the list, got %d rows", list.Count())
+	}
+	if err := list.Scroll(0, 0, nil); err == nil || pager.Err() == nil || !pager.HasMore() {
+		t.Errorf("expected the failed page to be returned and retried later, got %v", err)
+	}
+}
+
+func TestScriptTagIncludesVirtualRuntime(t *testing.T) {
+	if !strings.Contains(NewLiveHub().ScriptTag("/live"), VirtualRuntime) {
+		t.Errorf("expected the script tag to load the virtual list runtime")
+	}
+}
@@ -0,0 +1,139 @@
+//go:build js && wasm
+// +build js,wasm
+
+package gouix
+
+import (
+	"encoding/json"
+	"fmt"
+	"syscall/js"
+)
+
+// BrowserDOM is the DOMAdapter of the page a WebAssembly build runs in. It
+// patches the page with its _gouix.applyPatches, so patched content has its
+// events bound and its transitions run as for the server's patches; the page
+// needs the runtime of LiveHub.ScriptTag.
+type BrowserDOM struct {
+	document js.Value
+}
+
+// NewBrowserDOM returns the adapter of the page
+func NewBrowserDOM() *BrowserDOM {
+	return &BrowserDOM{document: js.Global().Get("document")}
+}
+
+// container returns a root's container, or null if it is not on the page
+func (d *BrowserDOM) container(root string) js.Value {
+	return rootContainer(d.document, root)
+}
+
+// rootContainer finds a root's container in document
+func rootContainer(document js.Value, root string) js.Value {
+	selector := `[data-gouix-root="` + js.Global().Get("CSS").Call("escape", root).String() + `"]`
+	return document.Call("querySelector", selector)
+}
+
+// Version returns the data-gouix-version of a root's container
+func (d *BrowserDOM) Version(root string) string {
+	container := d.container(root)
+	if container.IsNull() {
+		return ""
+	}
+	version := container.Call("getAttribute", "data-gouix-version")
+	if version.IsNull() {
+		return ""
+	}
+	return version.String()
+}
+
+// Apply patches a root's container with the page's runtime
+func (d *BrowserDOM) Apply(set PatchSet, version string) error {
+	runtime := js.Global().Get("_gouix")
+	if runtime.IsUndefined() || runtime.Get("applyPatches").Type() != js.TypeFunction {
+		return fmt.Errorf("the page has no gouix runtime to patch root %q with", set.Root)
+	}
+
+	data, err := json.Marshal(set)
+	if err != nil {
+		return err
+	}
+	if !runtime.Call("applyPatches", js.Global().Get("JSON").Call("parse", string(data))).Truthy() {
+		return fmt.Errorf("root %q is not on the page", set.Root)
+	}
+	d.container(set.Root).Call("setAttribute", "data-gouix-version", version)
+	return nil
+}
+
+// Run hands the client the page's events and blocks until Close, keeping the
+// module running. The roots are marked data-gouix-client and unsubscribed from
+// the server, and _gouix.dispatchEvent delivers the events of the client's
+// components to it, passing any others on to the server. Call it from main
+// once the roots are mounted:
+//
+//	func main() {
+//		client := gouix.NewClient(gouix.NewBrowserDOM())
+//		client.Mount("home", NewHome())
+//		client.Run()
+//	}
+func (c *Client) Run() {
+	global := js.Global()
+	runtime := global.Get("_gouix")
+	if runtime.IsUndefined() {
+		runtime = global.Get("Object").New()
+		global.Set("_gouix", runtime)
+	}
+
+	document := global.Get("document")
+	for _, root := range c.Roots() {
+		if container := rootContainer(document, root.ID); !container.IsNull() {
+			container.Call("setAttribute", "data-gouix-client", "")
+		}
+		if unsubscribe := runtime.Get("unsubscribe"); unsubscribe.Type() == js.TypeFunction {
+			unsubscribe.Invoke(root.ID)
+		}
+	}
+
+	server := runtime.Get("dispatchEvent")
+	dispatch := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) < 2 {
+			return nil
+		}
+		event := Event{Type: args[1].String(), Target: ComponentID(args[0].String())}
+		if !c.Owns(event.Target) {
+			if server.Type() == js.TypeFunction {
+				forwarded := make([]interface{}, len(args))
+				for i, arg := range args {
+					forwarded[i] = arg
+				}
+				server.Invoke(forwarded...)
+			}
+			return nil
+		}
+		if len(args) > 2 {
+			event.Data = jsData(args[2])
+		}
+
+		// Handlers may block, as on a request, which a callback must not
+		go c.dispatch(event)
+		return nil
+	})
+	runtime.Set("dispatchEvent", dispatch)
+	document.Call("dispatchEvent", global.Get("CustomEvent").New("gouix:client"))
+
+	<-c.done
+
+	runtime.Set("dispatchEvent", server)
+	dispatch.Release()
+}
+
+// jsData converts an event's data from the page, through JSON
+func jsData(value js.Value) map[string]interface{} {
+	if value.Type() != js.TypeObject {
+		return nil
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(js.Global().Get("JSON").Call("stringify", value).String()), &data); err != nil {
+		return nil
+	}
+	return data
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"sort"
+	"time"
+)
+
+// AccessibilityIssue describes a problem found by an accessibility audit
+type AccessibilityIssue struct {
+	// Source names the subsystem that ran the audit, such as "gouix"
+	Source string `json:"source"`
+
+	// Component that was audited
+	Component string `json:"component"`
+
+	// Rule that failed, such as "image-alt" or "contrast"
+	Rule string `json:"rule"`
+
+	// Severity is "error" or "warning"
+	Severity string `json:"severity"`
+
+	// Element is the markup of the offending element
+	Element string `json:"element,omitempty"`
+
+	// Message describes the problem
+	Message string `json:"message"`
+
+	// Timestamp of the audit
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ReportAccessibility records the result of auditing a component, replacing
+// the issues of its previous audit; an empty result clears them. The number of
+// open issues of the source is kept in the "<source>_a11y_issues" metric.
+func (jp *Jetpack) ReportAccessibility(source, component string, issues []AccessibilityIssue) {
+	now := time.Now()
+
+	jp.mutex.Lock()
+	if jp.accessibility == nil {
+		jp.accessibility = make(map[string][]AccessibilityIssue)
+	}
+
+	key := source + "/" + component
+	if len(issues) == 0 {
+		delete(jp.accessibility, key)
+	} else {
+		recorded := make([]AccessibilityIssue, len(issues))
+		for i, issue := range issues {
+			issue.Source, issue.Component = source, component
+			if issue.Timestamp.IsZero() {
+				issue.Timestamp = now
+			}
+			recorded[i] = issue
+		}
+		jp.accessibility[key] = recorded
+	}
+
+	open := 0
+	for _, reported := range jp.accessibility {
+		if len(reported) > 0 && reported[0].Source == source {
+			open += len(reported)
+		}
+	}
+
+	name := source + "_a11y_issues"
+	_, registered := jp.Metrics[name]
+	jp.mutex.Unlock()
+
+	if !registered {
+		jp.RegisterMetric(MetricA11yIssues, name, "Accessibility issues found by "+source, "issues", nil, []string{source})
+	}
+	jp.RecordMetric(name, float64(open))
+}
+
+// GetAccessibilityIssues returns the issues of the latest audits, ordered by
+// source and component
+func (jp *Jetpack) GetAccessibilityIssues() []AccessibilityIssue {
+	jp.mutex.RLock()
+	defer jp.mutex.RUnlock()
+
+	return jp.accessibilityIssues()
+}
+
+// accessibilityIssues flattens the audits; the caller holds the mutex
+func (jp *Jetpack) accessibilityIssues() []AccessibilityIssue {
+	keys := make([]string, 0, len(jp.accessibility))
+	for key := range jp.accessibility {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	issues := make([]AccessibilityIssue, 0)
+	for _, key := range keys {
+		issues = append(issues, jp.accessibility[key]...)
+	}
+	return issues
+}
@@ -0,0 +1,622 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultAlertWindow is the window rule statistics cover by default
+const DefaultAlertWindow = 5 * time.Minute
+
+// DefaultNotifyTimeout bounds each notification sent over the network
+const DefaultNotifyTimeout = 10 * time.Second
+
+// Comparison compares a metric's value to a rule's threshold
+type Comparison string
+
+const (
+	Above        Comparison = ">"
+	AboveOrEqual Comparison = ">="
+	Below        Comparison = "<"
+	BelowOrEqual Comparison = "<="
+	Equal        Comparison = "=="
+	NotEqual     Comparison = "!="
+)
+
+// compare reports whether value compares to threshold as c says
+func (c Comparison) compare(value, threshold float64) (bool, error) {
+	switch c {
+	case Above:
+		return value > threshold, nil
+	case AboveOrEqual:
+		return value >= threshold, nil
+	case Below:
+		return value < threshold, nil
+	case BelowOrEqual:
+		return value <= threshold, nil
+	case Equal:
+		return value == threshold, nil
+	case NotEqual:
+		return value != threshold, nil
+	}
+	return false, fmt.Errorf("unknown comparison %q", string(c))
+}
+
+// AlertState is where an alert is in its lifecycle
+type AlertState string
+
+const (
+	// AlertInactive alerts have their condition false
+	AlertInactive AlertState = "inactive"
+
+	// AlertPending alerts have their condition true for less than the rule's
+	// For duration
+	AlertPending AlertState = "pending"
+
+	// AlertFiring alerts have their condition true for the rule's For
+	// duration; notifiers are told when an alert starts firing
+	AlertFiring AlertState = "firing"
+
+	// AlertResolved alerts were firing and have their condition false again;
+	// notifiers are told when an alert resolves
+	AlertResolved AlertState = "resolved"
+)
+
+// AlertRule fires an alert when a statistic of a metric compares to a
+// threshold for a while, such as the p95 of api_latency above 200ms for 5
+// minutes
+type AlertRule struct {
+	// Name identifies the rule
+	Name string `json:"name"`
+
+	// Metric is the name of the metric the rule watches
+	Metric string `json:"metric"`
+
+	// Stat is the statistic of the metric compared: "latest" (the default),
+	// "mean", "min", "max", "p50", "p95", "p99", "count" or "rate"
+	Stat string `json:"stat,omitempty"`
+
+	// Window is how far back the statistic looks, DefaultAlertWindow if zero
+	Window time.Duration `json:"window,omitempty"`
+
+	// Labels limits the values summarized to those matching every filter,
+	// such as those pushed by the nodes of a region
+	Labels []LabelFilter `json:"labels,omitempty"`
+
+	Comparison Comparison `json:"comparison"`
+	Threshold  float64    `json:"threshold"`
+
+	// For is how long the condition must hold before the alert fires; until
+	// then it is pending
+	For time.Duration `json:"for,omitempty"`
+
+	// Description is added to notifications
+	Description string `json:"description,omitempty"`
+
+	// Channels names the notifiers told about the alert; empty tells all
+	Channels []string `json:"channels,omitempty"`
+}
+
+// validate checks a rule can be evaluated
+func (rule AlertRule) validate() error {
+	if rule.Name == "" {
+		return fmt.Errorf("alert rule needs a name")
+	}
+	if rule.Metric == "" {
+		return fmt.Errorf("alert rule %s needs a metric", rule.Name)
+	}
+	if _, err := rule.Comparison.compare(0, 0); err != nil {
+		return fmt.Errorf("alert rule %s: %v", rule.Name, err)
+	}
+	if _, err := statValue(&MetricStats{}, rule.Stat); err != nil {
+		return fmt.Errorf("alert rule %s: %v", rule.Name, err)
+	}
+	for _, filter := range rule.Labels {
+		if filter.Label == "" {
+			return fmt.Errorf("alert rule %s: label filter needs a label", rule.Name)
+		}
+	}
+	return nil
+}
+
+// statValue picks the named statistic out of stats
+func statValue(stats *MetricStats, stat string) (float64, error) {
+	switch stat {
+	case "", "latest":
+		return stats.Latest, nil
+	case "mean":
+		return stats.Mean, nil
+	case "min":
+		return stats.Min, nil
+	case "max":
+		return stats.Max, nil
+	case "p50":
+		return stats.P50, nil
+	case "p95":
+		return stats.P95, nil
+	case "p99":
+		return stats.P99, nil
+	case "count":
+		return float64(stats.Count), nil
+	case "rate":
+		return stats.Rate, nil
+	}
+	return 0, fmt.Errorf("unknown statistic %q", stat)
+}
+
+// Alert is the state of one rule
+type Alert struct {
+	Rule        string     `json:"rule"`
+	Metric      string     `json:"metric"`
+	Stat        string     `json:"stat"`
+	Comparison  Comparison `json:"comparison"`
+	Threshold   float64    `json:"threshold"`
+	Description string     `json:"description,omitempty"`
+	State       AlertState `json:"state"`
+
+	// Value is the statistic at the last evaluation
+	Value float64 `json:"value"`
+
+	// Unit is the unit of the metric
+	Unit string `json:"unit,omitempty"`
+
+	// ActiveAt is when the condition became true
+	ActiveAt time.Time `json:"active_at,omitempty"`
+
+	// FiredAt is when the alert last started firing
+	FiredAt time.Time `json:"fired_at,omitempty"`
+
+	// ResolvedAt is when the alert last resolved
+	ResolvedAt time.Time `json:"resolved_at,omitempty"`
+
+	channels []string
+}
+
+// Message describes the alert in one line, such as "[FIRING] slow_api:
+// api_latency p95 is 250 ms (> 200)"
+func (a Alert) Message() string {
+	value := fmt.Sprintf("%g", a.Value)
+	if a.Unit != "" {
+		value += " " + a.Unit
+	}
+	message := fmt.Sprintf("[%s] %s: %s %s is %s (%s %g)",
+		strings.ToUpper(string(a.State)), a.Rule, a.Metric, a.Stat, value, a.Comparison, a.Threshold)
+	if a.Description != "" {
+		message += " - " + a.Description
+	}
+	return message
+}
+
+// Notifier tells someone an alert started firing or resolved
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// NotifierFunc adapts a function to a Notifier
+type NotifierFunc func(ctx context.Context, alert Alert) error
+
+// Notify calls f
+func (f NotifierFunc) Notify(ctx context.Context, alert Alert) error {
+	return f(ctx, alert)
+}
+
+// WebhookNotifier posts alerts as JSON to a URL
+type WebhookNotifier struct {
+	URL string
+
+	// Headers are added to each request, such as an Authorization header
+	Headers map[string]string
+
+	// Client sends the requests, http.DefaultClient if nil
+	Client *http.Client
+}
+
+// Notify posts the alert
+func (n *WebhookNotifier) Notify(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, n.Client, n.URL, n.Headers, alert)
+}
+
+// SlackNotifier posts alerts to a Slack incoming webhook
+type SlackNotifier struct {
+	WebhookURL string
+
+	// Channel and Username override the webhook's defaults when set
+	Channel  string
+	Username string
+
+	// Client sends the requests, http.DefaultClient if nil
+	Client *http.Client
+}
+
+// Notify posts the alert's message
+func (n *SlackNotifier) Notify(ctx context.Context, alert Alert) error {
+	payload := map[string]string{"text": alert.Message()}
+	if n.Channel != "" {
+		payload["channel"] = n.Channel
+	}
+	if n.Username != "" {
+		payload["username"] = n.Username
+	}
+	return postJSON(ctx, n.Client, n.WebhookURL, nil, payload)
+}
+
+// EmailNotifier mails alerts through an SMTP server
+type EmailNotifier struct {
+	// Addr is the host:port of the SMTP server
+	Addr string
+
+	// Auth authenticates with the server, such as smtp.PlainAuth; nil sends
+	// without authenticating
+	Auth smtp.Auth
+
+	From string
+	To   []string
+
+	// send delivers the message, smtp.SendMail if nil
+	send func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// Notify mails the alert. smtp.SendMail takes no context, so a slow server
+// is only bounded by its own timeouts.
+func (n *EmailNotifier) Notify(ctx context.Context, alert Alert) error {
+	if len(n.To) == 0 {
+		return fmt.Errorf("email notifier has no recipients")
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", alert.Message())
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\n\r\n", alert.Message())
+	fmt.Fprintf(&msg, "Rule: %s\r\nMetric: %s\r\nValue: %g %s\r\n", alert.Rule, alert.Metric, alert.Value, alert.Unit)
+	if !alert.ActiveAt.IsZero() {
+		fmt.Fprintf(&msg, "Active since: %s\r\n", alert.ActiveAt.Format(time.RFC3339))
+	}
+
+	send := n.send
+	if send == nil {
+		send = smtp.SendMail
+	}
+	return send(n.Addr, n.Auth, n.From, n.To, msg.Bytes())
+}
+
+// postJSON posts payload as JSON and fails on a non-2xx response
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("notifying %s: %s", url, resp.Status)
+	}
+	return nil
+}
+
+// AlertManager evaluates alert rules against Jetpack's metrics and tells
+// notifiers when alerts fire and resolve. Failed notifications are reported
+// as Jetpack errors.
+type AlertManager struct {
+	Jetpack *Jetpack
+
+	// NotifyTimeout bounds each notification
+	NotifyTimeout time.Duration
+
+	mutex     sync.Mutex
+	rules     map[string]AlertRule
+	alerts    map[string]*Alert
+	notifiers map[string]Notifier
+	stop      chan struct{}
+
+	// now is the clock rules are evaluated by
+	now func() time.Time
+}
+
+// NewAlertManager creates an alert manager for jp's metrics
+func NewAlertManager(jp *Jetpack) *AlertManager {
+	return &AlertManager{
+		Jetpack:       jp,
+		NotifyTimeout: DefaultNotifyTimeout,
+		rules:         make(map[string]AlertRule),
+		alerts:        make(map[string]*Alert),
+		notifiers:     make(map[string]Notifier),
+		now:           time.Now,
+	}
+}
+
+// AddRule adds a rule, or replaces the rule of the same name and resets its
+// alert
+func (am *AlertManager) AddRule(rule AlertRule) error {
+	return am.AddRuleContext(context.Background(), rule)
+}
+
+// AddRuleContext adds a rule like AddRule, auditing the change as made by
+// the actor of ctx
+func (am *AlertManager) AddRuleContext(ctx context.Context, rule AlertRule) error {
+	if err := rule.validate(); err != nil {
+		return err
+	}
+	if rule.Stat == "" {
+		rule.Stat = "latest"
+	}
+	if rule.Window <= 0 {
+		rule.Window = DefaultAlertWindow
+	}
+
+	am.mutex.Lock()
+	var before interface{}
+	if old, ok := am.rules[rule.Name]; ok {
+		before = old
+	}
+	am.rules[rule.Name] = rule
+	delete(am.alerts, rule.Name)
+	am.mutex.Unlock()
+
+	am.audit(ctx, rule.Name, before, rule)
+	return nil
+}
+
+// RemoveRule removes a rule and its alert
+func (am *AlertManager) RemoveRule(name string) {
+	am.RemoveRuleContext(context.Background(), name)
+}
+
+// RemoveRuleContext removes a rule like RemoveRule, auditing the change as
+// made by the actor of ctx
+func (am *AlertManager) RemoveRuleContext(ctx context.Context, name string) {
+	am.mutex.Lock()
+	old, ok := am.rules[name]
+	delete(am.rules, name)
+	delete(am.alerts, name)
+	am.mutex.Unlock()
+
+	if ok {
+		am.audit(ctx, name, old, nil)
+	}
+}
+
+// audit records a change of a rule in Jetpack's audit log
+func (am *AlertManager) audit(ctx context.Context, name string, before, after interface{}) {
+	if am.Jetpack != nil {
+		am.Jetpack.AuditChange(ctx, "alerts", name, before, after)
+	}
+}
+
+// Rules returns the rules, sorted by name
+func (am *AlertManager) Rules() []AlertRule {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	rules := make([]AlertRule, 0, len(am.rules))
+	for _, rule := range am.rules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+	return rules
+}
+
+// AddNotifier adds a notifier under a name rules can list in Channels
+func (am *AlertManager) AddNotifier(name string, notifier Notifier) {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	am.notifiers[name] = notifier
+}
+
+// RemoveNotifier removes a notifier
+func (am *AlertManager) RemoveNotifier(name string) {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	delete(am.notifiers, name)
+}
+
+// Alerts returns the alerts of the rules evaluated so far, sorted by rule
+func (am *AlertManager) Alerts() []Alert {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	alerts := make([]Alert, 0, len(am.alerts))
+	for _, alert := range am.alerts {
+		alerts = append(alerts, *alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].Rule < alerts[j].Rule })
+	return alerts
+}
+
+// Firing returns the alerts that are firing
+func (am *AlertManager) Firing() []Alert {
+	var firing []Alert
+	for _, alert := range am.Alerts() {
+		if alert.State == AlertFiring {
+			firing = append(firing, alert)
+		}
+	}
+	return firing
+}
+
+// Evaluate evaluates every rule once, moves alerts between states and
+// notifies about alerts that started firing or resolved
+func (am *AlertManager) Evaluate() {
+	now := am.now()
+
+	// Metrics are read without holding the manager's lock, so reading the
+	// alerts never waits on Jetpack
+	type result struct {
+		rule   AlertRule
+		value  float64
+		unit   string
+		active bool
+	}
+	var results []result
+	for _, rule := range am.Rules() {
+		r := result{rule: rule}
+		if metric, err := am.Jetpack.GetMetric(rule.Metric); err == nil {
+			metric.mutex.RLock()
+			r.unit = metric.Unit
+			var stats *MetricStats
+			if metric.store != nil && len(rule.Labels) > 0 {
+				var stores []*metricStore
+				for _, series := range metric.matchingSeries(rule.Labels) {
+					stores = append(stores, series.store)
+				}
+				if len(stores) > 0 {
+					stats = summarize(rule.Metric, now, rule.Window, stores...)
+				}
+			} else if metric.store != nil {
+				stats = metric.store.stats(rule.Metric, now, rule.Window)
+			}
+			metric.mutex.RUnlock()
+
+			// Without values in the window there is nothing to compare
+			if stats != nil && stats.Count > 0 {
+				r.value, _ = statValue(stats, rule.Stat)
+				r.active, _ = rule.Comparison.compare(r.value, rule.Threshold)
+			}
+		}
+		results = append(results, r)
+	}
+
+	var notify []Alert
+	am.mutex.Lock()
+	for _, r := range results {
+		if _, ok := am.rules[r.rule.Name]; !ok {
+			continue
+		}
+		alert, ok := am.alerts[r.rule.Name]
+		if !ok {
+			alert = &Alert{State: AlertInactive}
+			am.alerts[r.rule.Name] = alert
+		}
+		alert.Rule = r.rule.Name
+		alert.Metric = r.rule.Metric
+		alert.Stat = r.rule.Stat
+		alert.Comparison = r.rule.Comparison
+		alert.Threshold = r.rule.Threshold
+		alert.Description = r.rule.Description
+		alert.Value = r.value
+		alert.Unit = r.unit
+		alert.channels = r.rule.Channels
+
+		switch {
+		case r.active && (alert.State == AlertInactive || alert.State == AlertResolved):
+			alert.State = AlertPending
+			alert.ActiveAt = now
+			fallthrough
+		case r.active && alert.State == AlertPending:
+			if now.Sub(alert.ActiveAt) >= r.rule.For {
+				alert.State = AlertFiring
+				alert.FiredAt = now
+				notify = append(notify, *alert)
+			}
+		case !r.active && alert.State == AlertPending:
+			alert.State = AlertInactive
+			alert.ActiveAt = time.Time{}
+		case !r.active && alert.State == AlertFiring:
+			alert.State = AlertResolved
+			alert.ResolvedAt = now
+			notify = append(notify, *alert)
+		}
+	}
+	am.mutex.Unlock()
+
+	for _, alert := range notify {
+		am.notify(alert)
+	}
+}
+
+// notify tells the alert's notifiers about it, one at a time
+func (am *AlertManager) notify(alert Alert) {
+	am.mutex.Lock()
+	notifiers := make(map[string]Notifier)
+	if len(alert.channels) == 0 {
+		for name, notifier := range am.notifiers {
+			notifiers[name] = notifier
+		}
+	} else {
+		for _, name := range alert.channels {
+			if notifier, ok := am.notifiers[name]; ok {
+				notifiers[name] = notifier
+			}
+		}
+	}
+	am.mutex.Unlock()
+
+	for name, notifier := range notifiers {
+		ctx, cancel := context.WithTimeout(context.Background(), am.NotifyTimeout)
+		err := notifier.Notify(ctx, alert)
+		cancel()
+		if err != nil {
+			am.Jetpack.ReportError(ErrorReport{
+				Source:    "jetpack",
+				Component: "alerts",
+				Message:   fmt.Sprintf("notifying %s about %s: %v", name, alert.Rule, err),
+			})
+		}
+	}
+}
+
+// Start evaluates the rules every interval until Stop is called
+func (am *AlertManager) Start(interval time.Duration) {
+	am.mutex.Lock()
+	if am.stop != nil {
+		am.mutex.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	am.stop = stop
+	am.mutex.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				am.Evaluate()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops evaluating the rules
+func (am *AlertManager) Stop() {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	if am.stop != nil {
+		close(am.stop)
+		am.stop = nil
+	}
+}
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAlertLifecycle(t *testing.T) {
+	jp := NewJetpack()
+	jp.RegisterMetric(MetricAPILatency, "api_latency", "API latency", "ms", nil, nil)
+
+	now := time.Now()
+	jp.Alerts.now = func() time.Time { return now }
+	var notified []Alert
+	jp.Alerts.AddNotifier("test", NotifierFunc(func(ctx context.Context, alert Alert) error {
+		notified = append(notified, alert)
+		return nil
+	}))
+	err := jp.Alerts.AddRule(AlertRule{Name: "slow_api", Metric: "api_latency", Comparison: Above, Threshold: 200, For: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := func() AlertState {
+		alerts := jp.Alerts.Alerts()
+		if len(alerts) != 1 {
+			t.Fatalf("expected one alert, got %+v", alerts)
+		}
+		return alerts[0].State
+	}
+
+	jp.RecordMetric("api_latency", 250)
+	jp.Alerts.Evaluate()
+	if state() != AlertPending || len(notified) != 0 {
+		t.Fatalf("expected a pending alert, got %s with %d notifications", state(), len(notified))
+	}
+
+	now = now.Add(time.Minute)
+	jp.Alerts.Evaluate()
+	if state() != AlertFiring || len(notified) != 1 || notified[0].State != AlertFiring || notified[0].Value != 250 {
+		t.Fatalf("expected a firing alert, got %s with %+v", state(), notified)
+	}
+	if firing := jp.Alerts.Firing(); len(firing) != 1 {
+		t.Fatalf("expected one firing alert, got %+v", firing)
+	}
+
+	// Firing notifies once however long it lasts
+	jp.Alerts.Evaluate()
+	if len(notified) != 1 {
+		t.Fatalf("expected no repeated notification, got %d", len(notified))
+	}
+
+	jp.RecordMetric("api_latency", 100)
+	jp.Alerts.Evaluate()
+	if state() != AlertResolved || len(notified) != 2 || notified[1].State != AlertResolved {
+		t.Fatalf("expected a resolved alert, got %s with %+v", state(), notified)
+	}
+	if !strings.HasPrefix(notified[1].Message(), "[RESOLVED] slow_api: api_latency latest is 100 ms (> 200)") {
+		t.Fatalf("unexpected message %q", notified[1].Message())
+	}
+}
+
+func TestAlertPendingClears(t *testing.T) {
+	jp := NewJetpack()
+	jp.RegisterMetric(MetricFPS, "fps", "Frames per second", "fps", nil, nil)
+	jp.Alerts.AddRule(AlertRule{Name: "janky", Metric: "fps", Stat: "min", Window: time.Minute, Comparison: Below, Threshold: 30, For: time.Hour})
+
+	jp.RecordMetric("fps", 20)
+	jp.Alerts.Evaluate()
+	if alerts := jp.Alerts.Alerts(); alerts[0].State != AlertPending {
+		t.Fatalf("expected a pending alert, got %+v", alerts)
+	}
+
+	// The window moves past the low value
+	jp.Alerts.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	jp.Alerts.Evaluate()
+	if alerts := jp.Alerts.Alerts(); alerts[0].State != AlertInactive || !alerts[0].ActiveAt.IsZero() {
+		t.Fatalf("expected the alert to clear without firing, got %+v", alerts)
+	}
+}
+
+func TestAlertRuleValidation(t *testing.T) {
+	jp := NewJetpack()
+	for _, rule := range []AlertRule{
+		{Metric: "fps", Comparison: Below},
+		{Name: "a", Comparison: Below},
+		{Name: "a", Metric: "fps", Comparison: "~"},
+		{Name: "a", Metric: "fps", Comparison: Below, Stat: "median"},
+	} {
+		if err := jp.Alerts.AddRule(rule); err == nil {
+			t.Fatalf("expected %+v to be rejected", rule)
+		}
+	}
+}
+
+func TestAlertChannels(t *testing.T) {
+	jp := NewJetpack()
+	jp.RegisterMetric(MetricErrorRate, "errors", "Errors", "errors", nil, nil)
+
+	var webhook, slack map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		switch r.URL.Path {
+		case "/hook":
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			webhook = payload
+		case "/slack":
+			slack = payload
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	var mailed string
+	jp.Alerts.AddNotifier("webhook", &WebhookNotifier{URL: server.URL + "/hook", Headers: map[string]string{"Authorization": "Bearer secret"}})
+	jp.Alerts.AddNotifier("slack", &SlackNotifier{WebhookURL: server.URL + "/slack", Channel: "#ops"})
+	jp.Alerts.AddNotifier("email", &EmailNotifier{Addr: "mail:25", From: "jetpack@example.com", To: []string{"ops@example.com"},
+		send: func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+			mailed = string(msg)
+			return nil
+		}})
+	jp.Alerts.AddNotifier("broken", &WebhookNotifier{URL: server.URL + "/missing"})
+	jp.Alerts.AddRule(AlertRule{Name: "errors", Metric: "errors", Stat: "count", Comparison: AboveOrEqual, Threshold: 1, Channels: []string{"webhook", "slack", "email"}})
+	jp.Alerts.AddRule(AlertRule{Name: "any_errors", Metric: "errors", Stat: "count", Comparison: AboveOrEqual, Threshold: 1, Channels: []string{"broken"}})
+
+	jp.RecordMetric("errors", 1)
+	jp.Alerts.Evaluate()
+
+	if webhook["rule"] != "errors" || webhook["state"] != "firing" {
+		t.Fatalf("unexpected webhook payload %+v", webhook)
+	}
+	if slack["channel"] != "#ops" || !strings.HasPrefix(slack["text"].(string), "[FIRING] errors: errors count is 1") {
+		t.Fatalf("unexpected slack payload %+v", slack)
+	}
+	if !strings.Contains(mailed, "To: ops@example.com\r\n") || !strings.Contains(mailed, "Subject: [FIRING] errors") {
+		t.Fatalf("unexpected email %q", mailed)
+	}
+
+	// The broken notifier's failure is reported
+	errors := jp.GetErrors()
+	if len(errors) != 1 || errors[0].Component != "alerts" || !strings.Contains(errors[0].Message, "404") {
+		t.Fatalf("expected the failed notification to be reported, got %+v", errors)
+	}
+}
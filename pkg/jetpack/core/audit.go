@@ -0,0 +1,497 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/reqctx"
+)
+
+// AuditEntry is a change to a setting, chained to the entry before it by
+// PrevHash so that editing or dropping an entry breaks the chain
+type AuditEntry struct {
+	Seq       int64     `json:"seq"`
+	Time      time.Time `json:"time"`
+	Actor     string    `json:"actor"`
+	Component string    `json:"component"`
+	Setting   string    `json:"setting"`
+
+	// Old and New are the setting's JSON before and after the change; Old is
+	// null for a setting added and New for one removed
+	Old json.RawMessage `json:"old"`
+	New json.RawMessage `json:"new"`
+
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+// hash is the SHA-256 of the entry's fields but Hash, or their
+// HMAC-SHA256 with key when one is given
+func (e AuditEntry) hash(key []byte) string {
+	sum := sha256.New()
+	if len(key) > 0 {
+		sum = hmac.New(sha256.New, key)
+	}
+	for _, field := range []string{
+		strconv.FormatInt(e.Seq, 10),
+		e.Time.UTC().Format(time.RFC3339Nano),
+		e.Actor,
+		e.Component,
+		e.Setting,
+		string(e.Old),
+		string(e.New),
+		e.PrevHash,
+	} {
+		// Length prefixes keep fields from running into each other
+		fmt.Fprintf(sum, "%d:%s\n", len(field), field)
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// AuditHead is the latest entry of an audit log, which an AuditAnchor
+// keeps outside its store
+type AuditHead struct {
+	Seq  int64  `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// AuditAnchor keeps the head of an audit log outside its store, such as in
+// a file on another host or in an object store with retention, so Verify
+// notices the latest entries dropped or the log rewritten by someone who
+// can write to the store
+type AuditAnchor interface {
+	// SaveHead records the head after an entry is appended
+	SaveHead(ctx context.Context, head AuditHead) error
+
+	// Head returns the head last saved, or a zero head if there is none
+	Head(ctx context.Context) (AuditHead, error)
+}
+
+// FileAuditAnchor keeps the head of an audit log in a JSON file, which
+// should be on storage the log's database users cannot write to
+type FileAuditAnchor struct {
+	Path string
+}
+
+// SaveHead replaces the file with the head
+func (a FileAuditAnchor) SaveHead(ctx context.Context, head AuditHead) error {
+	data, err := json.Marshal(head)
+	if err != nil {
+		return err
+	}
+	temp := a.Path + ".tmp"
+	if err := ioutil.WriteFile(temp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(temp, a.Path)
+}
+
+// Head reads the head from the file, if it exists
+func (a FileAuditAnchor) Head(ctx context.Context) (AuditHead, error) {
+	var head AuditHead
+	data, err := ioutil.ReadFile(a.Path)
+	if os.IsNotExist(err) {
+		return head, nil
+	}
+	if err != nil {
+		return head, err
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return head, fmt.Errorf("reading the audit head %s: %v", a.Path, err)
+	}
+	return head, nil
+}
+
+// AuditQuery filters audit entries; zero fields match everything
+type AuditQuery struct {
+	Component string
+	Setting   string
+	Actor     string
+	From      time.Time
+	To        time.Time
+
+	// Limit keeps the latest Limit entries matching
+	Limit int
+}
+
+// matches reports whether an entry matches the query
+func (q AuditQuery) matches(e AuditEntry) bool {
+	return (q.Component == "" || e.Component == q.Component) &&
+		(q.Setting == "" || e.Setting == q.Setting) &&
+		(q.Actor == "" || e.Actor == q.Actor) &&
+		(q.From.IsZero() || !e.Time.Before(q.From)) &&
+		(q.To.IsZero() || !e.Time.After(q.To))
+}
+
+// AuditStore keeps audit entries, such as in GoScaleDB. Stores only ever
+// append; they do not update or delete entries.
+type AuditStore interface {
+	// Append stores an entry, failing if its Seq is taken
+	Append(ctx context.Context, entry AuditEntry) error
+
+	// Last returns the entry with the highest Seq, or nil if there are none
+	Last(ctx context.Context) (*AuditEntry, error)
+
+	// Query returns the entries matching query, oldest first
+	Query(ctx context.Context, query AuditQuery) ([]AuditEntry, error)
+}
+
+// MemoryAuditStore keeps audit entries in memory, for as long as the process
+// runs
+type MemoryAuditStore struct {
+	mutex   sync.Mutex
+	entries []AuditEntry
+}
+
+// NewMemoryAuditStore creates an empty in-memory audit store
+func NewMemoryAuditStore() *MemoryAuditStore {
+	return &MemoryAuditStore{}
+}
+
+// Append stores an entry
+func (s *MemoryAuditStore) Append(ctx context.Context, entry AuditEntry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if n := len(s.entries); n > 0 && entry.Seq <= s.entries[n-1].Seq {
+		return fmt.Errorf("audit entry %d is already taken", entry.Seq)
+	}
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+// Last returns the latest entry
+func (s *MemoryAuditStore) Last(ctx context.Context) (*AuditEntry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.entries) == 0 {
+		return nil, nil
+	}
+	last := s.entries[len(s.entries)-1]
+	return &last, nil
+}
+
+// Query returns the entries matching query
+func (s *MemoryAuditStore) Query(ctx context.Context, query AuditQuery) ([]AuditEntry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entries := []AuditEntry{}
+	for _, entry := range s.entries {
+		if query.matches(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	if query.Limit > 0 && len(entries) > query.Limit {
+		entries = entries[len(entries)-query.Limit:]
+	}
+	return entries, nil
+}
+
+// auditActorKey is the context key of the actor changes are made by
+type auditActorKey struct{}
+
+// WithAuditActor returns a context whose changes are audited as made by
+// actor, such as the signed-in user
+func WithAuditActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+// AuditActor is the actor of WithAuditActor, or else the principal reqctx
+// finds in ctx, such as the signed-in user, or "system" for changes the app
+// makes itself
+func AuditActor(ctx context.Context) string {
+	if actor, ok := ctx.Value(auditActorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	if principal := reqctx.Principal(ctx); principal != "" {
+		return principal
+	}
+	return "system"
+}
+
+// AuditLog records who changed which setting when, from what to what, in a
+// hash chain that Verify checks for tampering. A plain hash chain only
+// shows entries edited or dropped in the middle: someone who can write to
+// the store can also recompute every hash. Set Key, kept outside the store,
+// so they cannot, and Anchor, so dropping the latest entries shows too.
+type AuditLog struct {
+	Store AuditStore
+
+	// Key, when set, keys the chain's hashes as HMAC-SHA256, so only those
+	// holding it can compute them. Keep it in the app's secrets, not the
+	// store. Entries recorded without a key do not verify with one.
+	Key []byte
+
+	// Anchor, when set, keeps the head of the log after each entry, for
+	// Verify to check the log still ends with it. Without one, Verify checks
+	// against the head this AuditLog last appended, if any.
+	Anchor AuditAnchor
+
+	// Redact, when set, rewrites the old and new JSON of each change before
+	// it is stored, such as to mask personal data
+	Redact func(component, setting string, value json.RawMessage) json.RawMessage
+
+	mutex sync.Mutex
+	head  AuditHead
+	now   func() time.Time
+}
+
+// NewAuditLog creates an audit log kept in store
+func NewAuditLog(store AuditStore) *AuditLog {
+	return &AuditLog{Store: store, now: time.Now}
+}
+
+// Record appends a change of a component's setting, made by the actor of
+// ctx. Changes leaving the setting's JSON as it was are not recorded, and
+// return a nil entry.
+func (l *AuditLog) Record(ctx context.Context, component, setting string, before, after interface{}) (*AuditEntry, error) {
+	oldJSON, err := json.Marshal(before)
+	if err != nil {
+		return nil, fmt.Errorf("auditing %s.%s: %v", component, setting, err)
+	}
+	newJSON, err := json.Marshal(after)
+	if err != nil {
+		return nil, fmt.Errorf("auditing %s.%s: %v", component, setting, err)
+	}
+	if bytes.Equal(oldJSON, newJSON) {
+		return nil, nil
+	}
+	return l.append(ctx, component, setting, oldJSON, newJSON)
+}
+
+// RecordChanges compares before and after, structs or maps such as a
+// config before and after it changed, and records a change for each JSON
+// field that differs
+func (l *AuditLog) RecordChanges(ctx context.Context, component string, before, after interface{}) ([]AuditEntry, error) {
+	oldFields, err := jsonFields(before)
+	if err != nil {
+		return nil, fmt.Errorf("auditing %s: %v", component, err)
+	}
+	newFields, err := jsonFields(after)
+	if err != nil {
+		return nil, fmt.Errorf("auditing %s: %v", component, err)
+	}
+
+	settings := make([]string, 0, len(newFields))
+	for setting := range newFields {
+		settings = append(settings, setting)
+	}
+	for setting := range oldFields {
+		if _, ok := newFields[setting]; !ok {
+			settings = append(settings, setting)
+		}
+	}
+	sort.Strings(settings)
+
+	var entries []AuditEntry
+	for _, setting := range settings {
+		oldJSON, newJSON := oldFields[setting], newFields[setting]
+		if bytes.Equal(oldJSON, newJSON) {
+			continue
+		}
+		if oldJSON == nil {
+			oldJSON = json.RawMessage("null")
+		}
+		if newJSON == nil {
+			newJSON = json.RawMessage("null")
+		}
+		entry, err := l.append(ctx, component, setting, oldJSON, newJSON)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, nil
+}
+
+// jsonFields splits the JSON object of v into its fields
+func jsonFields(v interface{}) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("%T is not a JSON object", v)
+	}
+	return fields, nil
+}
+
+// append chains an entry to the latest one and stores it
+func (l *AuditLog) append(ctx context.Context, component, setting string, before, after json.RawMessage) (*AuditEntry, error) {
+	if l.Redact != nil {
+		before, after = l.Redact(component, setting, before), l.Redact(component, setting, after)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	last, err := l.Store.Last(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entry := AuditEntry{
+		Seq: 1,
+		// Stores such as databases keep microseconds at best
+		Time:      l.now().UTC().Truncate(time.Microsecond),
+		Actor:     AuditActor(ctx),
+		Component: component,
+		Setting:   setting,
+		Old:       before,
+		New:       after,
+	}
+	if last != nil {
+		entry.Seq = last.Seq + 1
+		entry.PrevHash = last.Hash
+	}
+	entry.Hash = entry.hash(l.Key)
+
+	if err := l.Store.Append(ctx, entry); err != nil {
+		return nil, err
+	}
+	l.head = AuditHead{Seq: entry.Seq, Hash: entry.Hash}
+	if l.Anchor != nil {
+		if err := l.Anchor.SaveHead(ctx, l.head); err != nil {
+			return &entry, fmt.Errorf("anchoring audit entry %d: %v", entry.Seq, err)
+		}
+	}
+	return &entry, nil
+}
+
+// Query returns the entries matching query, oldest first
+func (l *AuditLog) Query(ctx context.Context, query AuditQuery) ([]AuditEntry, error) {
+	return l.Store.Query(ctx, query)
+}
+
+// Verify walks the whole log and returns an error naming the first entry
+// that was edited, dropped or inserted out of the chain, or saying the log
+// no longer ends with the head Anchor keeps
+func (l *AuditLog) Verify(ctx context.Context) error {
+	entries, err := l.Store.Query(ctx, AuditQuery{})
+	if err != nil {
+		return err
+	}
+	l.mutex.Lock()
+	head := l.head
+	l.mutex.Unlock()
+	if l.Anchor != nil {
+		if head, err = l.Anchor.Head(ctx); err != nil {
+			return err
+		}
+	}
+
+	prev := AuditEntry{}
+	for _, entry := range entries {
+		switch {
+		case entry.Seq != prev.Seq+1:
+			return fmt.Errorf("audit entry %d follows entry %d; entries are missing", entry.Seq, prev.Seq)
+		case entry.PrevHash != prev.Hash:
+			return fmt.Errorf("audit entry %d is not chained to entry %d", entry.Seq, prev.Seq)
+		case entry.Hash != entry.hash(l.Key):
+			return fmt.Errorf("audit entry %d was modified", entry.Seq)
+		}
+		prev = entry
+	}
+
+	switch {
+	case prev.Seq < head.Seq:
+		return fmt.Errorf("audit log ends at entry %d, but had %d; entries are missing", prev.Seq, head.Seq)
+	case head.Seq > 0 && entries[head.Seq-1].Hash != head.Hash:
+		return fmt.Errorf("audit entry %d does not match the anchored head; the log was rewritten", head.Seq)
+	}
+	return nil
+}
+
+// Handler serves the log for forensic review: GET returns the entries
+// matching the component, setting, actor, from, to (RFC 3339) and limit
+// query parameters as JSON, and GET with verify=1 whether the chain is
+// intact. It does no authorization; mount it behind the app's admin login.
+func (l *AuditLog) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		params := r.URL.Query()
+		if params.Get("verify") != "" {
+			result := map[string]interface{}{"valid": true}
+			if err := l.Verify(r.Context()); err != nil {
+				result = map[string]interface{}{"valid": false, "error": err.Error()}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(result)
+			return
+		}
+
+		query := AuditQuery{
+			Component: params.Get("component"),
+			Setting:   params.Get("setting"),
+			Actor:     params.Get("actor"),
+		}
+		for name, t := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+			if value := params.Get(name); value != "" {
+				parsed, err := time.Parse(time.RFC3339, value)
+				if err != nil {
+					http.Error(w, fmt.Sprintf("invalid %s %q", name, value), http.StatusBadRequest)
+					return
+				}
+				*t = parsed
+			}
+		}
+		if value := params.Get("limit"); value != "" {
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 0 {
+				http.Error(w, fmt.Sprintf("invalid limit %q", value), http.StatusBadRequest)
+				return
+			}
+			query.Limit = limit
+		}
+
+		entries, err := l.Query(r.Context(), query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entries)
+	})
+}
+
+// AuditChange records a change of a component's setting in the audit log,
+// reporting failures to record it as Jetpack errors
+func (jp *Jetpack) AuditChange(ctx context.Context, component, setting string, before, after interface{}) {
+	if jp.Audit == nil {
+		return
+	}
+	if _, err := jp.Audit.Record(ctx, component, setting, before, after); err != nil {
+		jp.ReportError(ErrorReport{Source: "jetpack", Component: "audit", Message: err.Error()})
+	}
+}
+
+// AuditChanges records the fields of a component's config that differ
+// between before and after in the audit log, reporting failures to record
+// them as Jetpack errors
+func (jp *Jetpack) AuditChanges(ctx context.Context, component string, before, after interface{}) {
+	if jp.Audit == nil {
+		return
+	}
+	if _, err := jp.Audit.RecordChanges(ctx, component, before, after); err != nil {
+		jp.ReportError(ErrorReport{Source: "jetpack", Component: "audit", Message: err.Error()})
+	}
+}
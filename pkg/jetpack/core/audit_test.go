@@ -0,0 +1,217 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/reqctx"
+)
+
+func TestAuditLogChain(t *testing.T) {
+	store := NewMemoryAuditStore()
+	log := NewAuditLog(store)
+	now := time.Date(2024, 3, 1, 9, 0, 0, 123456789, time.UTC)
+	log.now = func() time.Time { return now }
+	ctx := WithAuditActor(context.Background(), "ada")
+
+	entry, err := log.Record(ctx, "security", "auto_fix", false, true)
+	if err != nil || entry == nil || entry.Seq != 1 || entry.Actor != "ada" || entry.PrevHash != "" || string(entry.New) != "true" {
+		t.Fatalf("unexpected entry %+v %v", entry, err)
+	}
+	if !entry.Time.Equal(now.Truncate(time.Microsecond)) {
+		t.Fatalf("expected the time to be kept to the microsecond, got %v", entry.Time)
+	}
+	if entry, err := log.Record(ctx, "security", "auto_fix", true, true); entry != nil || err != nil {
+		t.Fatalf("expected an unchanged setting not to be recorded, got %+v %v", entry, err)
+	}
+
+	type config struct {
+		Interval time.Duration `json:"interval"`
+		Paths    []string      `json:"paths"`
+		Target   string        `json:"target,omitempty"`
+	}
+	now = now.Add(time.Hour)
+	entries, err := log.RecordChanges(context.Background(), "security",
+		config{Interval: time.Hour, Paths: []string{"/assets/"}, Target: "https://example.com"},
+		config{Interval: time.Hour, Paths: []string{"/assets/", "/public/"}})
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("expected the paths and target to be recorded, got %+v %v", entries, err)
+	}
+	if entries[0].Setting != "paths" || entries[0].Actor != "system" || entries[0].PrevHash != entry.Hash {
+		t.Fatalf("expected the paths change chained to the first entry, got %+v", entries[0])
+	}
+	if entries[1].Setting != "target" || string(entries[1].Old) != `"https://example.com"` || string(entries[1].New) != "null" {
+		t.Fatalf("expected the target to be removed, got %+v", entries[1])
+	}
+	if err := log.Verify(context.Background()); err != nil {
+		t.Fatalf("expected the chain to be intact, got %v", err)
+	}
+
+	if found, _ := log.Query(context.Background(), AuditQuery{Actor: "ada"}); len(found) != 1 || found[0].Seq != 1 {
+		t.Fatalf("expected ada's change, got %+v", found)
+	}
+	if found, _ := log.Query(context.Background(), AuditQuery{From: now.Add(-time.Minute), Limit: 1}); len(found) != 1 || found[0].Seq != 3 {
+		t.Fatalf("expected the latest change of the last minute, got %+v", found)
+	}
+
+	// Editing or dropping entries breaks the chain
+	store.entries[1].New = json.RawMessage(`["/"]`)
+	if err := log.Verify(context.Background()); err == nil || err.Error() != "audit entry 2 was modified" {
+		t.Fatalf("expected the edit to be found, got %v", err)
+	}
+	store.entries = append(store.entries[:1], store.entries[2:]...)
+	if err := log.Verify(context.Background()); err == nil || !strings.Contains(err.Error(), "entries are missing") {
+		t.Fatalf("expected the dropped entry to be found, got %v", err)
+	}
+}
+
+func TestAuditLogKeyAndAnchor(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryAuditStore()
+	anchor := FileAuditAnchor{Path: filepath.Join(t.TempDir(), "audit-head.json")}
+	log := NewAuditLog(store)
+	log.Key = []byte("kept in the app's secrets")
+	log.Anchor = anchor
+	for _, value := range []int{1, 2, 3} {
+		if _, err := log.Record(ctx, "security", "threshold", value-1, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if head, err := anchor.Head(ctx); err != nil || head.Seq != 3 || head.Hash != store.entries[2].Hash {
+		t.Fatalf("expected the head anchored, got %+v %v", head, err)
+	}
+
+	// Another process with the key and the anchor verifies the log
+	verifier := NewAuditLog(store)
+	verifier.Key, verifier.Anchor = log.Key, anchor
+	if err := verifier.Verify(ctx); err != nil {
+		t.Fatalf("expected the log to verify, got %v", err)
+	}
+
+	// Rewriting an entry and recomputing the chain without the key is found
+	rewritten := append([]AuditEntry(nil), store.entries...)
+	rewritten[1].New = json.RawMessage("20")
+	for i := 1; i < len(rewritten); i++ {
+		rewritten[i].PrevHash = rewritten[i-1].Hash
+		rewritten[i].Hash = rewritten[i].hash(nil)
+	}
+	entries := store.entries
+	store.entries = rewritten
+	if err := verifier.Verify(ctx); err == nil || err.Error() != "audit entry 2 was modified" {
+		t.Fatalf("expected the rewrite found, got %v", err)
+	}
+
+	// So is dropping the latest entries, which leaves an intact chain
+	store.entries = entries[:2]
+	if err := verifier.Verify(ctx); err == nil || err.Error() != "audit log ends at entry 2, but had 3; entries are missing" {
+		t.Fatalf("expected the dropped entry found, got %v", err)
+	}
+	verifier.Anchor = nil
+	if err := verifier.Verify(ctx); err != nil {
+		t.Fatalf("expected a log without an anchor to verify, got %v", err)
+	}
+	if err := log.Verify(ctx); err == nil || !strings.Contains(err.Error(), "entries are missing") {
+		t.Fatalf("expected the log's own head to find the dropped entry, got %v", err)
+	}
+
+	// And rewriting the log from the anchored head on, with the key
+	forged := append([]AuditEntry(nil), entries...)
+	forged[2].New = json.RawMessage("30")
+	forged[2].Hash = forged[2].hash(log.Key)
+	store.entries = forged
+	verifier.Anchor = anchor
+	if err := verifier.Verify(ctx); err == nil || err.Error() != "audit entry 3 does not match the anchored head; the log was rewritten" {
+		t.Fatalf("expected the rewritten head found, got %v", err)
+	}
+}
+
+func TestAuditLogRedact(t *testing.T) {
+	log := NewAuditLog(NewMemoryAuditStore())
+	log.Redact = func(component, setting string, value json.RawMessage) json.RawMessage {
+		if setting == "email" {
+			return json.RawMessage(`"***"`)
+		}
+		return value
+	}
+
+	entries, err := log.RecordChanges(context.Background(), "User",
+		map[string]string{"email": "ada@example.com", "name": "Ada"},
+		map[string]string{"email": "ada@example.org", "name": "Ada L"})
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("expected the email and name to be recorded, got %+v %v", entries, err)
+	}
+	if string(entries[0].Old) != `"***"` || string(entries[0].New) != `"***"` || string(entries[1].New) != `"Ada L"` {
+		t.Fatalf("expected only the email to be redacted, got %+v", entries)
+	}
+	if err := log.Verify(context.Background()); err != nil {
+		t.Fatalf("expected the redacted chain to verify, got %v", err)
+	}
+}
+
+func TestAuditAlertRules(t *testing.T) {
+	jp := NewJetpack()
+	ctx := WithAuditActor(context.Background(), "ops@example.com")
+	rule := AlertRule{Name: "slow", Metric: "api_latency", Comparison: Above, Threshold: 200}
+	if err := jp.Alerts.AddRuleContext(ctx, rule); err != nil {
+		t.Fatal(err)
+	}
+	jp.Alerts.AddRule(rule)
+	rule.Threshold = 500
+	jp.Alerts.AddRuleContext(ctx, rule)
+	jp.Alerts.RemoveRuleContext(ctx, "slow")
+	jp.Alerts.RemoveRule("missing")
+
+	entries, err := jp.Audit.Query(context.Background(), AuditQuery{Component: "alerts"})
+	if err != nil || len(entries) != 3 {
+		t.Fatalf("expected the add, change and removal, got %+v %v", entries, err)
+	}
+	if string(entries[0].Old) != "null" || !strings.Contains(string(entries[1].New), `"threshold":500`) || string(entries[2].New) != "null" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+	for _, entry := range entries {
+		if entry.Actor != "ops@example.com" || entry.Setting != "slow" {
+			t.Fatalf("unexpected entry %+v", entry)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	jp.Audit.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/audit?component=alerts&limit=2", nil))
+	var served []AuditEntry
+	if err := json.Unmarshal(w.Body.Bytes(), &served); err != nil || len(served) != 2 || served[1].Seq != entries[2].Seq {
+		t.Fatalf("expected the latest 2 entries, got %s %v", w.Body.String(), err)
+	}
+	w = httptest.NewRecorder()
+	jp.Audit.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/audit?verify=1", nil))
+	if strings.TrimSpace(w.Body.String()) != `{"valid":true}` {
+		t.Fatalf("expected the chain to verify, got %s", w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	jp.Audit.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/audit?from=yesterday", nil))
+	if w.Code != 400 {
+		t.Fatalf("expected an invalid time to be refused, got %d", w.Code)
+	}
+}
+
+func TestAuditActorFallsBackToThePrincipal(t *testing.T) {
+	ctx := reqctx.WithPrincipal(context.Background(), "ada")
+	if actor := AuditActor(ctx); actor != "ada" {
+		t.Fatalf("expected the request's principal, got %q", actor)
+	}
+	if actor := AuditActor(WithAuditActor(ctx, "ops")); actor != "ops" {
+		t.Fatalf("expected WithAuditActor to win, got %q", actor)
+	}
+	if actor := AuditActor(context.Background()); actor != "system" {
+		t.Fatalf("expected system without a principal, got %q", actor)
+	}
+
+	jp := NewJetpack()
+	jp.ReportErrorContext(reqctx.With(ctx, reqctx.Info{Principal: "ada", RequestID: "req-1"}), ErrorReport{Source: "api", Message: "boom"})
+	if reports := jp.GetErrors(); len(reports) != 1 || reports[0].Request.RequestID != "req-1" {
+		t.Fatalf("expected the report to carry its request, got %+v", reports)
+	}
+}
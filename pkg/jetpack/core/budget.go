@@ -0,0 +1,359 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultBudgetFile is the budget config gopm's CI mode reads by default
+const DefaultBudgetFile = "jetpack.budgets.json"
+
+// Stats of a metric a budget can cap
+const (
+	BudgetLatest = "latest"
+	BudgetMean   = "mean"
+	BudgetP50    = "p50"
+	BudgetP95    = "p95"
+	BudgetP99    = "p99"
+	BudgetMax    = "max"
+)
+
+// Budget caps one measurement of the app, such as the p95 API latency, the
+// LCP or the size of the CSS bundle. It measures exactly one of Metric,
+// Files or Measure.
+type Budget struct {
+	// Name labels the budget in results; empty uses the metric or files
+	Name string `json:"name,omitempty"`
+
+	// Metric is the Jetpack metric capped, summarized by Stat over Window;
+	// Stat defaults to the latest value and Window to the config's
+	Metric string        `json:"metric,omitempty"`
+	Stat   string        `json:"stat,omitempty"`
+	Window time.Duration `json:"-"`
+
+	// Files are glob patterns, such as "static/js/*.js", whose total size
+	// in bytes is capped
+	Files []string `json:"files,omitempty"`
+
+	// Measure measures the capped value itself, such as the length of the
+	// CSS gocsx generates
+	Measure func() (float64, error) `json:"-"`
+
+	// Unit describes the value in results; metrics default to theirs and
+	// files to bytes
+	Unit string `json:"unit,omitempty"`
+
+	// Max is the most the value may be
+	Max float64 `json:"max"`
+}
+
+// BudgetConfig declares the budgets CheckBudgets checks
+type BudgetConfig struct {
+	Budgets []Budget
+
+	// Window is the window metric budgets without their own are summarized
+	// over; zero covers everything retained
+	Window time.Duration
+
+	// Dir is the directory Files are relative to; empty is the working
+	// directory
+	Dir string
+}
+
+// BudgetResult is how a measurement did against its budget
+type BudgetResult struct {
+	Name   string  `json:"name"`
+	Stat   string  `json:"stat,omitempty"`
+	Unit   string  `json:"unit,omitempty"`
+	Max    float64 `json:"max"`
+	Actual float64 `json:"actual"`
+
+	// Delta is how far over the budget the value is, negative when under,
+	// and DeltaPercent the same as a percentage of Max
+	Delta        float64 `json:"delta"`
+	DeltaPercent float64 `json:"delta_percent"`
+
+	// Passed is false when the value is over the budget or could not be
+	// measured, Error saying why
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BudgetReport is the results of checking budgets, in the order they were
+// declared
+type BudgetReport struct {
+	Passed  bool           `json:"passed"`
+	Results []BudgetResult `json:"results"`
+}
+
+// LoadBudgetConfig reads a budget config from a JSON file such as
+//
+//	{"window": "1h", "budgets": [
+//		{"name": "bundle", "files": ["static/js/*.js"], "max": 250000},
+//		{"name": "css", "files": ["static/css/gocsx.css"], "max": 50000},
+//		{"metric": "largest_contentful_paint", "stat": "p95", "max": 2500},
+//		{"metric": "http_request_duration", "stat": "p95", "window": "15m", "max": 300}
+//	]}
+//
+// Files are relative to the file's directory.
+func LoadBudgetConfig(path string) (BudgetConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return BudgetConfig{}, err
+	}
+
+	var file struct {
+		Window  string `json:"window"`
+		Budgets []struct {
+			Budget
+			Window string `json:"window"`
+		} `json:"budgets"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return BudgetConfig{}, fmt.Errorf("budgets %s: %v", path, err)
+	}
+
+	config := BudgetConfig{Dir: filepath.Dir(path)}
+	if config.Window, err = parseBudgetWindow(file.Window); err != nil {
+		return BudgetConfig{}, fmt.Errorf("budgets %s: %v", path, err)
+	}
+	for _, entry := range file.Budgets {
+		budget := entry.Budget
+		if budget.Window, err = parseBudgetWindow(entry.Window); err != nil {
+			return BudgetConfig{}, fmt.Errorf("budgets %s: %s: %v", path, budget.label(), err)
+		}
+		config.Budgets = append(config.Budgets, budget)
+	}
+	return config, nil
+}
+
+// parseBudgetWindow parses a window such as "15m"; empty is zero
+func parseBudgetWindow(window string) (time.Duration, error) {
+	if window == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(window)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid window %q", window)
+	}
+	return duration, nil
+}
+
+// CheckBudgets measures every budget of config, reporting each one's value
+// and how far it is over or under. A budget whose value cannot be measured,
+// such as a metric without values, fails. An error is returned only when
+// config itself is invalid.
+func (jp *Jetpack) CheckBudgets(config BudgetConfig) (*BudgetReport, error) {
+	for _, budget := range config.Budgets {
+		if err := budget.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &BudgetReport{Passed: true, Results: make([]BudgetResult, 0, len(config.Budgets))}
+	for _, budget := range config.Budgets {
+		result := BudgetResult{Name: budget.label(), Unit: budget.Unit, Max: budget.Max}
+
+		actual, err := jp.measureBudget(budget, config, &result)
+		if err == nil && (math.IsNaN(actual) || math.IsInf(actual, 0)) {
+			err = fmt.Errorf("measured %v", actual)
+		}
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Actual = actual
+			result.Delta = actual - budget.Max
+			if budget.Max != 0 {
+				result.DeltaPercent = result.Delta / budget.Max * 100
+			}
+			result.Passed = actual <= budget.Max
+		}
+
+		report.Passed = report.Passed && result.Passed
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+// measureBudget measures the value a budget caps, filling in the stat and
+// unit of result
+func (jp *Jetpack) measureBudget(budget Budget, config BudgetConfig, result *BudgetResult) (float64, error) {
+	switch {
+	case budget.Measure != nil:
+		return budget.Measure()
+	case len(budget.Files) > 0:
+		if result.Unit == "" {
+			result.Unit = "bytes"
+		}
+		return budgetFilesSize(config.Dir, budget.Files)
+	}
+
+	result.Stat = budget.Stat
+	if result.Stat == "" {
+		result.Stat = BudgetLatest
+	}
+	if metric, err := jp.GetMetric(budget.Metric); err == nil && result.Unit == "" {
+		result.Unit = metric.Unit
+	}
+
+	window := budget.Window
+	if window == 0 {
+		window = config.Window
+	}
+	stats, err := jp.GetMetricStatsBetween(context.Background(), budget.Metric, time.Now(), window)
+	if err != nil {
+		return 0, err
+	}
+	if stats.Count == 0 {
+		return 0, fmt.Errorf("metric %s has no values", budget.Metric)
+	}
+
+	switch result.Stat {
+	case BudgetMean:
+		return stats.Mean, nil
+	case BudgetP50:
+		return stats.P50, nil
+	case BudgetP95:
+		return stats.P95, nil
+	case BudgetP99:
+		return stats.P99, nil
+	case BudgetMax:
+		return stats.Max, nil
+	}
+	return stats.Latest, nil
+}
+
+// budgetFilesSize sums the sizes of the files matching patterns, failing
+// when a pattern matches nothing so a moved bundle does not pass unnoticed
+func budgetFilesSize(dir string, patterns []string) (float64, error) {
+	var size int64
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return 0, err
+		}
+		if len(matches) == 0 {
+			return 0, fmt.Errorf("no files match %s", pattern)
+		}
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return 0, err
+			}
+			if info.IsDir() || seen[match] {
+				continue
+			}
+			seen[match] = true
+			size += info.Size()
+		}
+	}
+	return float64(size), nil
+}
+
+// label names a budget in results
+func (b Budget) label() string {
+	switch {
+	case b.Name != "":
+		return b.Name
+	case b.Metric != "":
+		return b.Metric
+	}
+	return strings.Join(b.Files, ", ")
+}
+
+// validate checks a budget measures exactly one thing and caps it sensibly
+func (b Budget) validate() error {
+	sources := 0
+	for _, set := range []bool{b.Metric != "", len(b.Files) > 0, b.Measure != nil} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("budget %q: set exactly one of a metric, files or a measure", b.label())
+	}
+
+	switch b.Stat {
+	case "", BudgetLatest, BudgetMean, BudgetP50, BudgetP95, BudgetP99, BudgetMax:
+	default:
+		return fmt.Errorf("budget %q: unknown stat %q", b.label(), b.Stat)
+	}
+	if b.Stat != "" && b.Metric == "" {
+		return fmt.Errorf("budget %q: a stat only applies to a metric", b.label())
+	}
+	if b.Max < 0 || math.IsNaN(b.Max) || math.IsInf(b.Max, 0) {
+		return fmt.Errorf("budget %q: invalid max %v", b.label(), b.Max)
+	}
+	for _, pattern := range b.Files {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("budget %q: invalid pattern %q", b.label(), pattern)
+		}
+	}
+	return nil
+}
+
+// String formats the report for a terminal, one line per budget
+func (r *BudgetReport) String() string {
+	var report strings.Builder
+	for _, result := range r.Results {
+		status := "PASS"
+		if !result.Passed {
+			status = "FAIL"
+		}
+		name := result.Name
+		if result.Stat != "" {
+			name += " (" + result.Stat + ")"
+		}
+
+		if result.Error != "" {
+			fmt.Fprintf(&report, "%s  %s: %s\n", status, name, result.Error)
+			continue
+		}
+		fmt.Fprintf(&report, "%s  %s: %s of %s%s, %s%s%%\n", status, name,
+			formatBudgetValue(result.Actual), formatBudgetValue(result.Max), budgetUnit(result.Unit),
+			budgetSign(result.Delta), formatBudgetValue(result.DeltaPercent))
+	}
+
+	passed := 0
+	for _, result := range r.Results {
+		if result.Passed {
+			passed++
+		}
+	}
+	fmt.Fprintf(&report, "%d of %d budgets passed\n", passed, len(r.Results))
+	return report.String()
+}
+
+// formatBudgetValue formats a value with at most two decimals
+func formatBudgetValue(value float64) string {
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64)
+}
+
+func budgetUnit(unit string) string {
+	if unit == "" {
+		return ""
+	}
+	return " " + unit
+}
+
+func budgetSign(delta float64) string {
+	if delta > 0 {
+		return "+"
+	}
+	return ""
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckBudgets(t *testing.T) {
+	dir := t.TempDir()
+	ioutil.WriteFile(filepath.Join(dir, "app.js"), make([]byte, 1500), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "vendor.js"), make([]byte, 500), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "gocsx.css"), make([]byte, 800), 0644)
+	config := `{"window": "1h", "budgets": [
+		{"name": "bundle", "files": ["*.js"], "max": 2500},
+		{"name": "css", "files": ["gocsx.css"], "max": 500},
+		{"metric": "largest_contentful_paint", "stat": "p95", "max": 2500},
+		{"name": "api", "metric": "http_request_duration", "stat": "p95", "window": "15m", "max": 300},
+		{"name": "fonts", "files": ["fonts/*.woff2"], "max": 100000}
+	]}`
+	path := filepath.Join(dir, DefaultBudgetFile)
+	ioutil.WriteFile(path, []byte(config), 0644)
+
+	budgets, err := LoadBudgetConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if budgets.Dir != dir || budgets.Window.Minutes() != 60 || budgets.Budgets[3].Window.Minutes() != 15 {
+		t.Fatalf("unexpected config %+v", budgets)
+	}
+
+	jp := NewJetpack()
+	jp.RegisterMetric(MetricLargestContentful, "largest_contentful_paint", "Largest contentful paint", "ms", nil, nil)
+	for _, value := range []float64{1800, 2000, 2200} {
+		jp.RecordMetric("largest_contentful_paint", value)
+	}
+	jp.RegisterMetric(MetricAPILatency, "http_request_duration", "HTTP request duration", "ms", nil, nil)
+	for i := 0; i < 100; i++ {
+		jp.RecordMetric("http_request_duration", 400)
+	}
+
+	report, err := jp.CheckBudgets(budgets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Passed || len(report.Results) != 5 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	bundle, css, lcp, api, fonts := report.Results[0], report.Results[1], report.Results[2], report.Results[3], report.Results[4]
+	if !bundle.Passed || bundle.Actual != 2000 || bundle.Delta != -500 || bundle.DeltaPercent != -20 || bundle.Unit != "bytes" {
+		t.Fatalf("unexpected bundle result %+v", bundle)
+	}
+	if css.Passed || css.Actual != 800 || css.Delta != 300 || css.DeltaPercent != 60 {
+		t.Fatalf("unexpected css result %+v", css)
+	}
+	if !lcp.Passed || lcp.Name != "largest_contentful_paint" || lcp.Stat != BudgetP95 || lcp.Unit != "ms" || lcp.Actual < 2000 || lcp.Actual > 2200 {
+		t.Fatalf("unexpected lcp result %+v", lcp)
+	}
+	if api.Passed || api.Actual != 400 || api.Delta != 100 {
+		t.Fatalf("unexpected api result %+v", api)
+	}
+	if fonts.Passed || !strings.Contains(fonts.Error, "no files match") {
+		t.Fatalf("expected a budget without files to fail, got %+v", fonts)
+	}
+
+	text := report.String()
+	for _, line := range []string{"PASS  bundle: 2000 of 2500 bytes, -20%", "FAIL  css: 800 of 500 bytes, +60%", "FAIL  fonts: no files match", "2 of 5 budgets passed"} {
+		if !strings.Contains(text, line) {
+			t.Fatalf("expected %q in the report, got\n%s", line, text)
+		}
+	}
+
+	// A measure caps values Jetpack does not record, such as generated CSS
+	report, _ = jp.CheckBudgets(BudgetConfig{Budgets: []Budget{
+		{Name: "generated css", Measure: func() (float64, error) { return float64(len(".btn{color:red}")), nil }, Max: 100},
+		{Name: "broken", Measure: func() (float64, error) { return 0, errors.New("gocsx failed") }, Max: 100},
+		{Metric: "missing", Max: 1},
+	}})
+	if !report.Results[0].Passed || report.Results[1].Passed || report.Results[1].Error != "gocsx failed" || report.Results[2].Passed {
+		t.Fatalf("unexpected report %+v", report)
+	}
+}
+
+func TestCheckBudgetsValidation(t *testing.T) {
+	jp := NewJetpack()
+	for _, budget := range []Budget{
+		{Name: "nothing", Max: 1},
+		{Metric: "fps", Files: []string{"*.js"}, Max: 1},
+		{Metric: "fps", Stat: "p90", Max: 1},
+		{Files: []string{"*.js"}, Stat: BudgetP95, Max: 1},
+		{Files: []string{"["}, Max: 1},
+		{Metric: "fps", Max: -1},
+	} {
+		if _, err := jp.CheckBudgets(BudgetConfig{Budgets: []Budget{budget}}); err == nil {
+			t.Fatalf("expected %+v to be refused", budget)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), DefaultBudgetFile)
+	for _, config := range []string{`{"budgets": [{"metric": "fps", "maximum": 1}]}`, `{"window": "soon"}`} {
+		ioutil.WriteFile(path, []byte(config), 0644)
+		if _, err := LoadBudgetConfig(path); err == nil {
+			t.Fatalf("expected %s to be refused", config)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"errors"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/config"
+)
+
+// Config holds the Jetpack settings that can be loaded from files and the
+// environment, and applied again when they change.
+type Config struct {
+	DevMode          bool
+	PanelVisible     bool
+	PanelPosition    string
+	PanelOpacity     float64
+	RefreshRate      time.Duration
+	AlertThreshold   float64
+	ExportEnabled    bool
+	ExportEndpoint   string
+	ExportInterval   time.Duration
+	MetricResolution time.Duration
+	MetricRetention  time.Duration
+}
+
+// panelPositions are the corners the performance panel can sit in
+var panelPositions = map[string]bool{"top-left": true, "top-right": true, "bottom-left": true, "bottom-right": true}
+
+// DefaultConfig returns the settings NewJetpack starts with
+func DefaultConfig() *Config {
+	return &Config{
+		PanelPosition:    "bottom-right",
+		PanelOpacity:     0.8,
+		RefreshRate:      time.Second,
+		AlertThreshold:   0.9,
+		ExportInterval:   time.Minute,
+		MetricResolution: DefaultMetricResolution,
+		MetricRetention:  DefaultMetricRetention,
+	}
+}
+
+// LoadConfig loads Jetpack's settings over DefaultConfig, such as from the
+// "jetpack" section of the app's config:
+//
+//	jetpackConfig, err := core.LoadConfig(loader.Section("jetpack"))
+func LoadConfig(loader *config.Loader) (*Config, error) {
+	c := DefaultConfig()
+	if err := loader.Load(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Validate checks the settings Jetpack cannot run with
+func (c *Config) Validate() error {
+	switch {
+	case !panelPositions[c.PanelPosition]:
+		return errors.New("panel_position must be top-left, top-right, bottom-left or bottom-right")
+	case c.PanelOpacity < 0 || c.PanelOpacity > 1:
+		return errors.New("panel_opacity must be between 0 and 1")
+	case c.RefreshRate <= 0 || c.ExportInterval <= 0:
+		return errors.New("refresh_rate and export_interval must be positive")
+	case c.AlertThreshold < 0 || c.AlertThreshold > 1:
+		return errors.New("alert_threshold must be between 0 and 1")
+	case c.ExportEnabled && c.ExportEndpoint == "":
+		return errors.New("export_endpoint is required when export_enabled is set")
+	case c.MetricResolution <= 0 || c.MetricRetention < c.MetricResolution:
+		return errors.New("metric_resolution must be positive and metric_retention at least as long")
+	}
+	return nil
+}
+
+// NewJetpackWithConfig creates a Jetpack with the given settings
+func NewJetpackWithConfig(c *Config) *Jetpack {
+	jp := NewJetpack()
+	jp.ApplyConfig(c)
+	return jp
+}
+
+// ApplyConfig changes the settings of a running Jetpack, such as when a
+// watched config file changes. The metric resolution and retention apply
+// to metrics registered afterwards.
+func (jp *Jetpack) ApplyConfig(c *Config) {
+	jp.mutex.Lock()
+	defer jp.mutex.Unlock()
+
+	jp.DevMode = c.DevMode
+	jp.PanelVisible = c.PanelVisible
+	jp.PanelPosition = c.PanelPosition
+	jp.PanelOpacity = c.PanelOpacity
+	jp.RefreshRate = c.RefreshRate
+	jp.AlertThreshold = c.AlertThreshold
+	jp.ExportEnabled = c.ExportEnabled
+	jp.ExportEndpoint = c.ExportEndpoint
+	jp.ExportInterval = c.ExportInterval
+	jp.MetricResolution = c.MetricResolution
+	jp.MetricRetention = c.MetricRetention
+}
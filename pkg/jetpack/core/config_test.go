@@ -0,0 +1,39 @@
+package core
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/config"
+)
+
+func TestLoadConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.yaml")
+	data := "jetpack:\n  panel_position: top-left\n  refresh_rate: 250ms\n"
+	if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadConfig(config.New("", file).Section("jetpack"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.PanelPosition != "top-left" || c.RefreshRate != 250*time.Millisecond || c.PanelOpacity != 0.8 {
+		t.Fatalf("expected the file over the defaults, got %+v", c)
+	}
+
+	jp := NewJetpackWithConfig(c)
+	if jp.PanelPosition != "top-left" || jp.RefreshRate != 250*time.Millisecond {
+		t.Fatalf("expected the config to be applied, got %s %v", jp.PanelPosition, jp.RefreshRate)
+	}
+
+	data = "jetpack:\n  panel_position: middle\n"
+	if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(config.New("", file).Section("jetpack")); err == nil || !strings.Contains(err.Error(), "panel_position") {
+		t.Fatalf("expected an invalid panel position to fail, got %v", err)
+	}
+}
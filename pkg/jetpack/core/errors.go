@@ -0,0 +1,93 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/reqctx"
+)
+
+// DefaultMaxErrors is the number of error reports kept by default
+const DefaultMaxErrors = 100
+
+// ErrorReport describes a failure reported by an application component
+type ErrorReport struct {
+	// Source names the subsystem that failed, such as "gouix"
+	Source string `json:"source"`
+
+	// Component that failed, if any
+	Component string `json:"component,omitempty"`
+
+	// Message describes the failure
+	Message string `json:"message"`
+
+	// Stack trace at the time of the failure
+	Stack string `json:"stack,omitempty"`
+
+	// Timestamp of the failure
+	Timestamp time.Time `json:"timestamp"`
+
+	// Request the failure happened in, if any, with its ID, trace,
+	// principal and tenant; see ReportErrorContext
+	Request reqctx.Info `json:"request"`
+}
+
+// errorLog keeps the most recent error reports
+type errorLog struct {
+	reports []ErrorReport
+	max     int
+}
+
+// ReportError records a failure and counts it in the error rate metric of its
+// source, named "<source>_errors"
+func (jp *Jetpack) ReportError(report ErrorReport) {
+	if report.Timestamp.IsZero() {
+		report.Timestamp = time.Now()
+	}
+
+	jp.mutex.Lock()
+	if jp.errors.max == 0 {
+		jp.errors.max = DefaultMaxErrors
+	}
+	jp.errors.reports = append(jp.errors.reports, report)
+	if len(jp.errors.reports) > jp.errors.max {
+		jp.errors.reports = jp.errors.reports[len(jp.errors.reports)-jp.errors.max:]
+	}
+
+	name := report.Source + "_errors"
+	_, registered := jp.Metrics[name]
+	jp.mutex.Unlock()
+
+	if !registered {
+		jp.RegisterMetric(MetricErrorRate, name, "Errors reported by "+report.Source, "errors", nil, []string{report.Source})
+	}
+	jp.RecordMetric(name, 1)
+}
+
+// ReportErrorContext reports a failure in the request of ctx, so it can be
+// traced to the request's logs, spans and change events
+func (jp *Jetpack) ReportErrorContext(ctx context.Context, report ErrorReport) {
+	report.Request = reqctx.From(ctx)
+	jp.ReportError(report)
+}
+
+// GetErrors returns the recent error reports, oldest first
+func (jp *Jetpack) GetErrors() []ErrorReport {
+	jp.mutex.RLock()
+	defer jp.mutex.RUnlock()
+
+	reports := make([]ErrorReport, len(jp.errors.reports))
+	copy(reports, jp.errors.reports)
+	return reports
+}
+
+// SetMaxErrors sets how many error reports are kept
+func (jp *Jetpack) SetMaxErrors(max int) {
+	jp.mutex.Lock()
+	defer jp.mutex.Unlock()
+
+	jp.errors.max = max
+	if max > 0 && len(jp.errors.reports) > max {
+		jp.errors.reports = jp.errors.reports[len(jp.errors.reports)-max:]
+	}
+}
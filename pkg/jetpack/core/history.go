@@ -0,0 +1,237 @@
+package core
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maxPendingSamples caps the samples waiting to be written to the history;
+// while the history cannot keep up, the oldest are dropped
+const maxPendingSamples = 100000
+
+// MetricSample is a value of a named metric, as written to a MetricHistory
+type MetricSample struct {
+	Metric    string    `json:"metric"`
+	Value     float64   `json:"value"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// MetricHistory persists metric values beyond the in-memory retention and
+// across restarts, such as in GoScaleDB time-series tables
+type MetricHistory interface {
+	// Write stores samples
+	Write(ctx context.Context, samples []MetricSample) error
+
+	// Query returns a metric's values between from and to, oldest first. A
+	// positive step averages them into one value per step, timestamped with
+	// the start of the step.
+	Query(ctx context.Context, metric string, from, to time.Time, step time.Duration) ([]MetricValue, error)
+}
+
+// historyBuffer holds the samples recorded since the last write
+type historyBuffer struct {
+	mutex   sync.Mutex
+	pending []MetricSample
+	dropped int
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// buffer queues a value for the history, if there is one
+func (jp *Jetpack) buffer(name string, value MetricValue) {
+	if jp.History == nil {
+		return
+	}
+
+	jp.history.mutex.Lock()
+	defer jp.history.mutex.Unlock()
+
+	jp.history.pending = append(jp.history.pending, MetricSample{Metric: name, Value: value.Value, Timestamp: value.Timestamp})
+	if over := len(jp.history.pending) - maxPendingSamples; over > 0 {
+		jp.history.pending = append(jp.history.pending[:0], jp.history.pending[over:]...)
+		jp.history.dropped += over
+	}
+}
+
+// FlushHistory writes the values recorded since the last flush to the
+// history. Values that fail to be written are kept for the next flush.
+func (jp *Jetpack) FlushHistory(ctx context.Context) error {
+	if jp.History == nil {
+		return nil
+	}
+
+	jp.history.mutex.Lock()
+	samples := jp.history.pending
+	jp.history.pending = nil
+	jp.history.mutex.Unlock()
+
+	if len(samples) == 0 {
+		return nil
+	}
+	if err := jp.History.Write(ctx, samples); err != nil {
+		jp.history.mutex.Lock()
+		jp.history.pending = append(samples, jp.history.pending...)
+		if over := len(jp.history.pending) - maxPendingSamples; over > 0 {
+			jp.history.pending = jp.history.pending[over:]
+			jp.history.dropped += over
+		}
+		jp.history.mutex.Unlock()
+		return err
+	}
+	return nil
+}
+
+// StartHistory flushes recorded values to the history every interval until
+// StopHistory is called. Failed flushes are reported as Jetpack errors.
+func (jp *Jetpack) StartHistory(interval time.Duration) {
+	jp.history.mutex.Lock()
+	defer jp.history.mutex.Unlock()
+
+	if jp.History == nil || jp.history.stop != nil {
+		return
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	jp.history.stop, jp.history.done = stop, done
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				if err := jp.FlushHistory(ctx); err != nil {
+					jp.ReportError(ErrorReport{Source: "jetpack", Component: "history", Message: err.Error()})
+				}
+				cancel()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// StopHistory stops the periodic flushes and flushes what is left
+func (jp *Jetpack) StopHistory(ctx context.Context) error {
+	jp.history.mutex.Lock()
+	stop, done := jp.history.stop, jp.history.done
+	jp.history.stop, jp.history.done = nil, nil
+	jp.history.mutex.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	return jp.FlushHistory(ctx)
+}
+
+// DroppedSamples returns how many values were dropped because the history
+// fell behind
+func (jp *Jetpack) DroppedSamples() int {
+	jp.history.mutex.Lock()
+	defer jp.history.mutex.Unlock()
+
+	return jp.history.dropped
+}
+
+// LoadHistory restores the registered metrics' values within
+// MetricRetention from the history, so stats survive a restart. Call it
+// after registering the metrics and before recording new values.
+func (jp *Jetpack) LoadHistory(ctx context.Context) error {
+	if jp.History == nil {
+		return nil
+	}
+
+	jp.mutex.RLock()
+	metrics := make(map[string]*Metric, len(jp.Metrics))
+	for name, metric := range jp.Metrics {
+		metrics[name] = metric
+	}
+	jp.mutex.RUnlock()
+
+	to := time.Now()
+	from := to.Add(-jp.MetricRetention)
+	for name, metric := range metrics {
+		values, err := jp.History.Query(ctx, name, from, to, 0)
+		if err != nil {
+			return err
+		}
+
+		metric.mutex.Lock()
+		if metric.store == nil {
+			metric.store = newMetricStore(jp.MetricResolution, jp.MetricRetention)
+		}
+		for _, value := range values {
+			metric.store.add(value)
+		}
+		if len(values) > maxRecentValues {
+			values = values[len(values)-maxRecentValues:]
+		}
+		metric.Values = append(values, metric.Values...)
+		if len(metric.Values) > maxRecentValues {
+			metric.Values = metric.Values[len(metric.Values)-maxRecentValues:]
+		}
+		metric.mutex.Unlock()
+	}
+	return nil
+}
+
+// GetMetricHistory returns a metric's values between from and to averaged
+// per step, oldest first. It reads the history when there is one, and
+// otherwise what the in-memory storage still retains.
+func (jp *Jetpack) GetMetricHistory(ctx context.Context, name string, from, to time.Time, step time.Duration) ([]MetricValue, error) {
+	if jp.History != nil {
+		return jp.History.Query(ctx, name, from, to, step)
+	}
+
+	metric, err := jp.GetMetric(name)
+	if err != nil {
+		return nil, err
+	}
+
+	metric.mutex.RLock()
+	defer metric.mutex.RUnlock()
+
+	if metric.store == nil {
+		return []MetricValue{}, nil
+	}
+	return metric.store.series(from, to, step), nil
+}
+
+// series averages the buckets between from and to per step, oldest first;
+// steps shorter than the resolution use the resolution
+func (s *metricStore) series(from, to time.Time, step time.Duration) []MetricValue {
+	if step < s.resolution {
+		step = s.resolution
+	}
+
+	type bin struct {
+		count int
+		sum   float64
+	}
+	bins := make(map[int64]*bin)
+	for i := range s.buckets {
+		bucket := &s.buckets[i]
+		if bucket.count == 0 || bucket.start.Before(from.Truncate(s.resolution)) || bucket.start.After(to) {
+			continue
+		}
+
+		start := bucket.start.Truncate(step).UnixNano()
+		if bins[start] == nil {
+			bins[start] = &bin{}
+		}
+		bins[start].count += bucket.count
+		bins[start].sum += bucket.sum
+	}
+
+	series := make([]MetricValue, 0, len(bins))
+	for start, bin := range bins {
+		series = append(series, MetricValue{Value: bin.sum / float64(bin.count), Timestamp: time.Unix(0, start).UTC()})
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].Timestamp.Before(series[j].Timestamp) })
+	return series
+}
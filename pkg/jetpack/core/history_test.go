@@ -0,0 +1,125 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryHistory keeps samples in memory, failing writes while broken
+type memoryHistory struct {
+	mutex   sync.Mutex
+	samples []MetricSample
+	broken  bool
+}
+
+func (h *memoryHistory) Write(ctx context.Context, samples []MetricSample) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.broken {
+		return errors.New("database unavailable")
+	}
+	h.samples = append(h.samples, samples...)
+	return nil
+}
+
+func (h *memoryHistory) Query(ctx context.Context, metric string, from, to time.Time, step time.Duration) ([]MetricValue, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	values := []MetricValue{}
+	for _, sample := range h.samples {
+		if sample.Metric == metric && !sample.Timestamp.Before(from) && !sample.Timestamp.After(to) {
+			values = append(values, MetricValue{Value: sample.Value, Timestamp: sample.Timestamp})
+		}
+	}
+	return values, nil
+}
+
+func TestHistoryFlushAndLoad(t *testing.T) {
+	history := &memoryHistory{broken: true}
+	jp := NewJetpack()
+	jp.History = history
+	jp.RegisterMetric(MetricAPILatency, "api_latency", "API latency", "ms", nil, nil)
+	jp.RecordMetric("api_latency", 10)
+	jp.RecordMetric("api_latency", 30)
+
+	// Failed writes are retried on the next flush
+	if err := jp.FlushHistory(context.Background()); err == nil {
+		t.Fatalf("expected the write to fail")
+	}
+	history.broken = false
+	jp.RecordMetric("api_latency", 20)
+	if err := jp.StopHistory(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(history.samples) != 3 || history.samples[0].Value != 10 || history.samples[2].Value != 20 {
+		t.Fatalf("unexpected samples %+v", history.samples)
+	}
+
+	// A new process restores the values
+	restarted := NewJetpack()
+	restarted.History = history
+	restarted.RegisterMetric(MetricAPILatency, "api_latency", "API latency", "ms", nil, nil)
+	if err := restarted.LoadHistory(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	stats, _ := restarted.GetMetricStats("api_latency", 0)
+	if stats.Count != 3 || stats.Mean != 20 || stats.Latest != 20 {
+		t.Fatalf("unexpected restored stats %+v", stats)
+	}
+	if latest, _ := restarted.GetMetricLatest("api_latency"); latest != 20 {
+		t.Fatalf("expected the latest value to be restored, got %v", latest)
+	}
+	if err := restarted.FlushHistory(context.Background()); err != nil || len(history.samples) != 3 {
+		t.Fatalf("expected restored values not to be written again, got %d samples", len(history.samples))
+	}
+
+	// Reports read the history, even for metrics this process never recorded
+	history.samples = append(history.samples, MetricSample{Metric: "old_metric", Value: 5, Timestamp: time.Now().Add(-2 * time.Hour)})
+	restarted.RegisterMetric(MetricAPILatency, "old_metric", "Old metric", "ms", nil, nil)
+	report, err := restarted.GenerateReport(context.Background(), 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(report, "api_latency: 3 values, mean 20.00") || !strings.Contains(report, "old_metric: 1 values") {
+		t.Fatalf("unexpected report %q", report)
+	}
+}
+
+func TestHistoryDropsWhenBehind(t *testing.T) {
+	jp := NewJetpack()
+	jp.History = &memoryHistory{broken: true}
+	jp.RegisterMetric(MetricAPILatency, "api_latency", "API latency", "ms", nil, nil)
+	for i := 0; i < maxPendingSamples+5; i++ {
+		jp.RecordMetric("api_latency", float64(i))
+	}
+
+	if dropped := jp.DroppedSamples(); dropped != 5 {
+		t.Fatalf("expected 5 dropped samples, got %d", dropped)
+	}
+	if jp.history.pending[0].Value != 5 {
+		t.Fatalf("expected the oldest samples to be dropped, got %v first", jp.history.pending[0].Value)
+	}
+}
+
+func TestGetMetricHistoryInMemory(t *testing.T) {
+	jp := NewJetpack()
+	metric := jp.RegisterMetric(MetricAPILatency, "api_latency", "API latency", "ms", nil, nil)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 120; i++ {
+		metric.store.add(MetricValue{Value: float64(i / 60), Timestamp: start.Add(time.Duration(i) * time.Second)})
+	}
+
+	series, err := jp.GetMetricHistory(context.Background(), "api_latency", start, start.Add(time.Hour), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 2 || series[0].Value != 0 || series[1].Value != 1 || !series[1].Timestamp.Equal(start.Add(time.Minute)) {
+		t.Fatalf("unexpected series %+v", series)
+	}
+}
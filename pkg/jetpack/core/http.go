@@ -0,0 +1,227 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// HTTP metric types the middleware records besides the backend ones above
+	MetricRequestSize  MetricType = "request_size"
+	MetricResponseSize MetricType = "response_size"
+)
+
+// maxHTTPRoutes caps the routes recorded separately; requests to later
+// routes are only counted in the overall metrics
+const maxHTTPRoutes = 200
+
+type routeKey struct{}
+
+// requestRoute carries the route a router matched back out to the middleware
+type requestRoute struct {
+	route string
+}
+
+// SetRoute labels a request with the route pattern that handled it, such as
+// "/posts/:id". Routers call it once they match a request, so HTTPMiddleware
+// records the request per route; it does nothing outside the middleware.
+func SetRoute(r *http.Request, route string) {
+	if label, ok := r.Context().Value(routeKey{}).(*requestRoute); ok {
+		label.route = route
+	}
+}
+
+// httpMetrics records requests as Jetpack metrics
+type httpMetrics struct {
+	jp     *Jetpack
+	mutex  sync.Mutex
+	routes map[string]bool
+}
+
+// HTTPMiddleware records every request handled by next as Jetpack metrics:
+// http_requests, http_request_duration in ms, http_request_size and
+// http_response_size in bytes, and http_responses_2xx and the other status
+// classes. Requests whose router labels them with SetRoute, as the goscript
+// router and GoScaleAPI do, are also recorded per route, such as
+// "http_request_duration@GET /posts/:id". The overall values are labeled
+// with the method, status class and route, so GetMetricBreakdown can break
+// them down by any of them. Hijacked connections, such as WebSockets, are
+// counted but not timed.
+//
+// Requests given an ID by goscript.RequestID also have their spans, and
+// those handlers start with StartSpan, kept in jp.Timelines. The ID is sent
+// to the browser in a Server-Timing header, so the RUM timeline can be
+// correlated with them.
+func (jp *Jetpack) HTTPMiddleware(next http.Handler) http.Handler {
+	metrics := &httpMetrics{jp: jp, routes: make(map[string]bool)}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		label := &requestRoute{}
+		trace := &requestTrace{}
+		body := &countingReader{ReadCloser: r.Body}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = body
+		}
+		recorder := &statusRecorder{ResponseWriter: w, onHeader: serverTiming}
+
+		ctx := context.WithValue(context.WithValue(r.Context(), routeKey{}, label), traceKey{}, trace)
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		requestSize := body.size
+		if r.ContentLength > requestSize {
+			requestSize = r.ContentLength
+		}
+		duration := time.Since(start)
+		metrics.record(r.Method, label.route, recorder, requestSize, duration)
+
+		if requestID := recorder.Header().Get(requestIDHeader); requestID != "" && jp.Timelines != nil {
+			span := Span{
+				Name:       describeRequest(r, label.route),
+				Start:      start,
+				Duration:   float64(duration) / float64(time.Millisecond),
+				Attributes: map[string]string{"status": strconv.Itoa(recorder.statusCode())},
+			}
+			trace.record(jp.Timelines, requestID, span)
+		}
+	})
+}
+
+// record records a request overall and for its route
+func (m *httpMetrics) record(method, route string, recorder *statusRecorder, requestSize int64, duration time.Duration) {
+	class := strconv.Itoa(recorder.statusCode()/100) + "xx"
+
+	suffixes := []string{""}
+	tags := []string{"http"}
+	labels := Labels{"method": method, "status": class}
+	if route != "" && m.route(method+" "+route) {
+		suffixes = append(suffixes, "@"+method+" "+route)
+		tags = append(tags, "route:"+method+" "+route)
+		labels["route"] = method + " " + route
+	}
+
+	for i, suffix := range suffixes {
+		tags := tags[:i+1]
+		labels := labels
+		if suffix != "" {
+			labels = nil
+		}
+		m.recordValue(MetricAPIThroughput, "http_requests"+suffix, "HTTP requests", "requests", tags, labels, 1)
+		m.recordValue(MetricAPIThroughput, "http_responses_"+class+suffix, "HTTP responses with "+class+" status", "responses", tags, labels, 1)
+		if recorder.hijacked {
+			continue
+		}
+		m.recordValue(MetricAPILatency, "http_request_duration"+suffix, "HTTP request duration", "ms", tags, labels, float64(duration)/float64(time.Millisecond))
+		m.recordValue(MetricRequestSize, "http_request_size"+suffix, "HTTP request body size", "bytes", tags, labels, float64(requestSize))
+		m.recordValue(MetricResponseSize, "http_response_size"+suffix, "HTTP response body size", "bytes", tags, labels, float64(recorder.size))
+	}
+}
+
+// recordValue records a value with its labels, registering its metric the
+// first time it is seen
+func (m *httpMetrics) recordValue(metricType MetricType, name, description, unit string, tags []string, labels Labels, value float64) {
+	m.mutex.Lock()
+	if _, err := m.jp.GetMetric(name); err != nil {
+		m.jp.RegisterMetric(metricType, name, description, unit, nil, append([]string(nil), tags...))
+	}
+	m.mutex.Unlock()
+
+	m.jp.RecordMetricWithLabels(name, value, labels)
+}
+
+// route reports whether a route is recorded separately, which it is unless
+// maxHTTPRoutes other routes are
+func (m *httpMetrics) route(route string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !m.routes[route] {
+		if len(m.routes) >= maxHTTPRoutes {
+			return false
+		}
+		m.routes[route] = true
+	}
+	return true
+}
+
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	io.ReadCloser
+	size int64
+}
+
+func (r *countingReader) Read(data []byte) (int, error) {
+	n, err := r.ReadCloser.Read(data)
+	r.size += int64(n)
+	return n, err
+}
+
+// statusRecorder remembers the status and size of a response, and keeps the
+// Flusher and Hijacker of the writer it wraps
+type statusRecorder struct {
+	http.ResponseWriter
+	status   int
+	size     int
+	hijacked bool
+
+	// onHeader, if set, may add to the header before it is written
+	onHeader func(http.Header)
+}
+
+// statusCode returns the status of the response, which is 200 if the
+// handler wrote none
+func (w *statusRecorder) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// setStatus remembers the status of the header about to be written
+func (w *statusRecorder) setStatus(status int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = status
+	if w.onHeader != nil {
+		w.onHeader(w.Header())
+	}
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.setStatus(status)
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(data []byte) (int, error) {
+	w.setStatus(http.StatusOK)
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
+func (w *statusRecorder) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.setStatus(http.StatusOK)
+		flusher.Flush()
+	}
+}
+
+func (w *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("jetpack: response does not support hijacking")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	w.hijacked = true
+	return hijacker.Hijack()
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPMiddleware(t *testing.T) {
+	jp := NewJetpack()
+	handler := jp.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/posts/7":
+			SetRoute(r, "/posts/:id")
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Write(append([]byte("saved "), body...))
+		case "/boom":
+			SetRoute(r, "/boom")
+			http.Error(w, "boom", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	for _, path := range []string{"/posts/7", "/posts/7", "/boom", "/missing"} {
+		r := httptest.NewRequest(http.MethodPut, path, strings.NewReader("hello"))
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	counts := map[string]int{
+		"http_requests":                     4,
+		"http_responses_2xx":                2,
+		"http_responses_4xx":                1,
+		"http_responses_5xx":                1,
+		"http_requests@PUT /posts/:id":      2,
+		"http_responses_5xx@PUT /boom":      1,
+		"http_request_duration@PUT /boom":   1,
+		"http_response_size@PUT /posts/:id": 2,
+		"http_request_size@PUT /posts/:id":  2,
+		"http_responses_2xx@PUT /posts/:id": 2,
+	}
+	for name, count := range counts {
+		stats, err := jp.GetMetricStats(name, 0)
+		if err != nil || stats.Count != count {
+			t.Fatalf("expected %d values of %s, got %+v %v", count, name, stats, err)
+		}
+	}
+	if size, _ := jp.GetMetricLatest("http_response_size@PUT /posts/:id"); size != float64(len("saved hello")) {
+		t.Fatalf("unexpected response size %v", size)
+	}
+	if size, _ := jp.GetMetricLatest("http_request_size@PUT /posts/:id"); size != 5 {
+		t.Fatalf("unexpected request size %v", size)
+	}
+	if _, err := jp.GetMetric("http_requests@PUT /missing"); err == nil {
+		t.Fatalf("expected unlabeled requests to be recorded overall only")
+	}
+	if metric, _ := jp.GetMetric("http_requests@PUT /boom"); len(metric.Tags) != 2 || metric.Tags[1] != "route:PUT /boom" {
+		t.Fatalf("unexpected tags %v", metric.Tags)
+	}
+
+	// The overall metrics break down by route and status class
+	routes, _ := jp.GetMetricBreakdown("http_requests", 0, []string{"route"})
+	if len(routes) != 3 || routes[0].Labels["route"] != "PUT /posts/:id" || routes[0].Count != 2 || routes[1].Labels["route"] != "" {
+		t.Fatalf("unexpected breakdown %+v", routes)
+	}
+	if failed, _ := jp.GetMetricStats("http_requests", 0, LabelNotEquals("status", "2xx"), LabelFilter{Label: "route"}); failed.Count != 1 {
+		t.Fatalf("expected one failed routed request, got %+v", failed)
+	}
+}
+
+func TestHTTPMiddlewareRouteCap(t *testing.T) {
+	jp := NewJetpack()
+	handler := jp.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetRoute(r, r.URL.Path)
+	}))
+
+	for i := 0; i < maxHTTPRoutes+10; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/r"+strings.Repeat("x", i), nil))
+	}
+	routes := 0
+	for name := range jp.Metrics {
+		if strings.HasPrefix(name, "http_requests@") {
+			routes++
+		}
+	}
+	if routes != maxHTTPRoutes {
+		t.Fatalf("expected %d routes, got %d", maxHTTPRoutes, routes)
+	}
+	if stats, _ := jp.GetMetricStats("http_requests", 0); stats.Count != maxHTTPRoutes+10 {
+		t.Fatalf("expected every request counted overall, got %d", stats.Count)
+	}
+}
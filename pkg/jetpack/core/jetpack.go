@@ -3,6 +3,7 @@ package core
 import (
 	"encoding/json"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 )
@@ -25,6 +26,8 @@ const (
 	MetricResourceSize   MetricType = "resource_size"
 	MetricJSExecution    MetricType = "js_execution_time"
 	MetricDOMSize        MetricType = "dom_size"
+	MetricDrawCalls      MetricType = "draw_calls"
+	MetricA11yIssues     MetricType = "a11y_issues"
 	
 	// Backend metric types
 	MetricAPILatency     MetricType = "api_latency"
@@ -66,6 +69,12 @@ type Metric struct {
 	Alert       bool          `json:"alert"`
 	Tags        []string      `json:"tags"`
 	mutex       sync.RWMutex
+	
+	// store keeps every value recorded within the retention for stats
+	store *metricStore
+	
+	// series keeps the values recorded with each label set, by Labels.String
+	series map[string]*metricSeries
 }
 
 // Jetpack is the main performance monitoring system
@@ -78,9 +87,45 @@ type Jetpack struct {
 	RefreshRate    time.Duration
 	AlertThreshold float64
 	AlertCallback  func(metric *Metric)
+	
+	// Alerts evaluates alert rules against the metrics and notifies about
+	// the alerts they fire
+	Alerts *AlertManager
+	
+	// Synthetic runs synthetic checks against the app and records their
+	// availability and latency
+	Synthetic *SyntheticMonitor
 	ExportEnabled  bool
 	ExportEndpoint string
 	ExportInterval time.Duration
+	
+	// MetricResolution and MetricRetention size the storage of metrics
+	// registered afterwards: values are grouped into buckets of
+	// MetricResolution, kept for MetricRetention
+	MetricResolution time.Duration
+	MetricRetention  time.Duration
+	
+	// History, when set, persists recorded values once StartHistory or
+	// FlushHistory writes them
+	History MetricHistory
+	
+	// Timelines keeps the spans of requests and the RUM timelines of page
+	// views, for the timeline viewer
+	Timelines *TimelineStore
+	
+	// Audit records changes to alert rules, the security config and the
+	// panel settings, in memory unless its Store is replaced
+	Audit *AuditLog
+	
+	// Push, when set, receives the metrics other nodes push, such as edge
+	// nodes, and lists them in the panel
+	Push *PushGateway
+	
+	history        historyBuffer
+	pushers        pusherList
+	errors         errorLog
+	usage          usageKeys
+	accessibility  map[string][]AccessibilityIssue
 	mutex          sync.RWMutex
 	
 	// Components
@@ -145,8 +190,15 @@ func NewJetpack() *Jetpack {
 		ExportEnabled:  false,
 		ExportEndpoint: "",
 		ExportInterval: time.Minute,
+		MetricResolution: DefaultMetricResolution,
+		MetricRetention:  DefaultMetricRetention,
 	}
 	
+	jp.Alerts = NewAlertManager(jp)
+	jp.Synthetic = NewSyntheticMonitor(jp)
+	jp.Timelines = NewTimelineStore()
+	jp.Audit = NewAuditLog(NewMemoryAuditStore())
+	
 	// Initialize components
 	jp.Frontend = &FrontendMonitor{
 		Jetpack:        jp,
@@ -243,6 +295,7 @@ func (jp *Jetpack) RegisterMetric(metricType MetricType, name, description, unit
 		Threshold:   threshold,
 		Alert:       false,
 		Tags:        tags,
+		store:       newMetricStore(jp.MetricResolution, jp.MetricRetention),
 	}
 	
 	jp.Metrics[name] = metric
@@ -263,8 +316,33 @@ func (jp *Jetpack) GetMetric(name string) (*Metric, error) {
 	return metric, nil
 }
 
+// MetricNames lists the registered metrics by name
+func (jp *Jetpack) MetricNames() []string {
+	jp.mutex.RLock()
+	defer jp.mutex.RUnlock()
+	
+	names := make([]string, 0, len(jp.Metrics))
+	for name := range jp.Metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	
+	return names
+}
+
 // RecordMetric records a metric value
 func (jp *Jetpack) RecordMetric(name string, value float64) error {
+	return jp.recordMetric(name, value, nil)
+}
+
+// recordMetric records a metric value, and for its label set if it has one
+func (jp *Jetpack) recordMetric(name string, value float64, labels Labels) error {
+	return jp.recordMetricAt(name, value, labels, time.Now())
+}
+
+// recordMetricAt records a value taken at timestamp, such as one pushed by
+// another node. Values older than the store keeps are skipped.
+func (jp *Jetpack) recordMetricAt(name string, value float64, labels Labels, timestamp time.Time) error {
 	metric, err := jp.GetMetric(name)
 	if err != nil {
 		return err
@@ -275,10 +353,27 @@ func (jp *Jetpack) RecordMetric(name string, value float64) error {
 	
 	metricValue := MetricValue{
 		Value:     value,
-		Timestamp: time.Now(),
+		Timestamp: timestamp,
 	}
 	
+	if metric.store == nil {
+		metric.store = newMetricStore(jp.MetricResolution, jp.MetricRetention)
+	}
+	if time.Since(timestamp) >= metric.store.retention() {
+		return fmt.Errorf("value of metric %s at %s is past its retention", name, timestamp.Format(time.RFC3339))
+	}
+	
+	// Values keeps the latest raw values; older ones live on in the store
 	metric.Values = append(metric.Values, metricValue)
+	if len(metric.Values) > maxRecentValues {
+		metric.Values = append(metric.Values[:0], metric.Values[len(metric.Values)-maxRecentValues:]...)
+	}
+	metric.store.add(metricValue)
+	if len(labels) > 0 {
+		metric.recordSeries(labels, metricValue)
+	}
+	jp.buffer(name, metricValue)
+	jp.bufferPush(name, metricValue, labels)
 	
 	// Check threshold
 	if metric.Threshold != nil && value >= *metric.Threshold {
@@ -293,26 +388,14 @@ func (jp *Jetpack) RecordMetric(name string, value float64) error {
 	return nil
 }
 
-// GetMetricAverage gets the average value of a metric
+// GetMetricAverage gets the average value of a metric over its retention
 func (jp *Jetpack) GetMetricAverage(name string) (float64, error) {
-	metric, err := jp.GetMetric(name)
+	stats, err := jp.GetMetricStats(name, 0)
 	if err != nil {
 		return 0, err
 	}
 	
-	metric.mutex.RLock()
-	defer metric.mutex.RUnlock()
-	
-	if len(metric.Values) == 0 {
-		return 0, nil
-	}
-	
-	var sum float64
-	for _, value := range metric.Values {
-		sum += value.Value
-	}
-	
-	return sum / float64(len(metric.Values)), nil
+	return stats.Mean, nil
 }
 
 // GetMetricLatest gets the latest value of a metric
@@ -405,6 +488,10 @@ func (jp *Jetpack) GetPanelData() map[string]interface{} {
 			metricData["threshold"] = *metric.Threshold
 		}
 		
+		if metric.store != nil {
+			metricData["stats"] = metric.store.stats(name, time.Now(), 5*time.Minute)
+		}
+		
 		metric.mutex.RUnlock()
 		
 		metrics[name] = metricData
@@ -412,5 +499,28 @@ func (jp *Jetpack) GetPanelData() map[string]interface{} {
 	
 	data["metrics"] = metrics
 	
+	// Add alerts
+	if jp.Alerts != nil {
+		data["alerts"] = jp.Alerts.Alerts()
+	}
+	
+	// Add the results of the synthetic checks
+	if jp.Synthetic != nil {
+		data["synthetic"] = jp.Synthetic.Results()
+	}
+	
+	// Add the nodes pushing their metrics
+	if jp.Push != nil {
+		data["nodes"] = jp.Push.Nodes()
+	}
+	
+	// Add recent errors
+	errors := make([]ErrorReport, len(jp.errors.reports))
+	copy(errors, jp.errors.reports)
+	data["errors"] = errors
+	
+	// Add accessibility issues
+	data["accessibility"] = jp.accessibilityIssues()
+	
 	return data
 }
\ No newline at end of file
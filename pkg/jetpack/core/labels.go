@@ -0,0 +1,213 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxMetricSeries caps the label sets a metric records separately; values
+// with later label sets are only counted in the metric overall
+const maxMetricSeries = 1000
+
+// Labels are the dimensions of a recorded value, such as its route or edge
+// node. A metric's Tags describe the metric as a whole; labels tell apart
+// the values recorded under it.
+type Labels map[string]string
+
+// String returns the labels as sorted name=value pairs, such as
+// "node=edge-1,route=GET /posts"
+func (l Labels) String() string {
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + l[name]
+	}
+	return strings.Join(pairs, ",")
+}
+
+// LabelFilter selects the values recorded with a label: those whose label
+// has Value, or any other value with Exclude. An empty Value selects the
+// values that have the label at all, or with Exclude those that do not.
+type LabelFilter struct {
+	Label   string `json:"label"`
+	Value   string `json:"value,omitempty"`
+	Exclude bool   `json:"exclude,omitempty"`
+}
+
+// LabelEquals selects the values whose label has value
+func LabelEquals(label, value string) LabelFilter {
+	return LabelFilter{Label: label, Value: value}
+}
+
+// LabelNotEquals selects the values whose label is missing or has another
+// value
+func LabelNotEquals(label, value string) LabelFilter {
+	return LabelFilter{Label: label, Value: value, Exclude: true}
+}
+
+// ParseLabelFilter parses a filter written as "route=GET /posts",
+// "node!=edge-1", "node" or "!node"
+func ParseLabelFilter(text string) (LabelFilter, error) {
+	text = strings.TrimSpace(text)
+	var filter LabelFilter
+	switch i := strings.Index(text, "="); {
+	case i > 0 && text[i-1] == '!':
+		filter = LabelFilter{Label: text[:i-1], Value: text[i+1:], Exclude: true}
+	case i >= 0:
+		filter = LabelFilter{Label: text[:i], Value: text[i+1:]}
+	case strings.HasPrefix(text, "!"):
+		filter = LabelFilter{Label: text[1:], Exclude: true}
+	default:
+		filter = LabelFilter{Label: text}
+	}
+	filter.Label = strings.TrimSpace(filter.Label)
+	filter.Value = strings.TrimSpace(filter.Value)
+	if filter.Label == "" {
+		return LabelFilter{}, fmt.Errorf("label filter %q names no label", text)
+	}
+	return filter, nil
+}
+
+// Match reports whether labels are selected by the filter
+func (f LabelFilter) Match(labels Labels) bool {
+	value, ok := labels[f.Label]
+	matched := ok && (f.Value == "" || value == f.Value)
+	return matched != f.Exclude
+}
+
+// metricSeries is the values a metric recorded with one label set
+type metricSeries struct {
+	labels Labels
+	store  *metricStore
+}
+
+// RecordMetricWithLabels records a metric value with the labels telling it
+// apart from the metric's other values, such as
+// Labels{"route": "GET /posts"}. The value counts in the metric overall, as
+// RecordMetric's do, and in the stats of the label filters it matches.
+// Each label set is stored separately, up to maxMetricSeries per metric.
+// Only overall values are written to the History.
+func (jp *Jetpack) RecordMetricWithLabels(name string, value float64, labels Labels) error {
+	return jp.recordMetric(name, value, labels)
+}
+
+// recordSeries records a value for its label set, unless the metric already
+// has maxMetricSeries others; the caller holds the metric's lock
+func (m *Metric) recordSeries(labels Labels, value MetricValue) {
+	key := labels.String()
+	series, ok := m.series[key]
+	if !ok {
+		if len(m.series) >= maxMetricSeries {
+			return
+		}
+		if m.series == nil {
+			m.series = make(map[string]*metricSeries)
+		}
+		copied := make(Labels, len(labels))
+		for name, value := range labels {
+			copied[name] = value
+		}
+		series = &metricSeries{labels: copied, store: newMetricStore(m.store.resolution, m.store.retention())}
+		m.series[key] = series
+	}
+	series.store.add(value)
+}
+
+// matchingSeries returns the stores of a metric's label sets matching the
+// filters, with their labels; the caller holds the metric's lock
+func (m *Metric) matchingSeries(filters []LabelFilter) []*metricSeries {
+	keys := make([]string, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var matched []*metricSeries
+	for _, key := range keys {
+		series := m.series[key]
+		match := true
+		for _, filter := range filters {
+			match = match && filter.Match(series.labels)
+		}
+		if match {
+			matched = append(matched, series)
+		}
+	}
+	return matched
+}
+
+// GetMetricBreakdown summarizes a metric over the last window for each
+// combination of values of the labels in by, aggregating across the other
+// labels, such as the latency per route or per edge node. Only values
+// matching the filters count. Each result carries its label values in
+// Labels, and the busiest come first; values missing a label in by are
+// grouped under its empty value.
+func (jp *Jetpack) GetMetricBreakdown(name string, window time.Duration, by []string, filters ...LabelFilter) ([]*MetricStats, error) {
+	metric, err := jp.GetMetric(name)
+	if err != nil {
+		return nil, err
+	}
+
+	metric.mutex.RLock()
+	defer metric.mutex.RUnlock()
+
+	groups := make(map[string][]*metricStore)
+	groupLabels := make(map[string]Labels)
+	for _, series := range metric.matchingSeries(filters) {
+		labels := make(Labels, len(by))
+		for _, label := range by {
+			labels[label] = series.labels[label]
+		}
+		key := labels.String()
+		groups[key] = append(groups[key], series.store)
+		groupLabels[key] = labels
+	}
+
+	now := time.Now()
+	breakdown := make([]*MetricStats, 0, len(groups))
+	for key, stores := range groups {
+		stats := summarize(name, now, window, stores...)
+		if stats.Count == 0 {
+			continue
+		}
+		stats.Labels = groupLabels[key]
+		breakdown = append(breakdown, stats)
+	}
+	sort.Slice(breakdown, func(i, j int) bool {
+		if breakdown[i].Count != breakdown[j].Count {
+			return breakdown[i].Count > breakdown[j].Count
+		}
+		return breakdown[i].Labels.String() < breakdown[j].Labels.String()
+	})
+	return breakdown, nil
+}
+
+// MetricLabelValues lists the values a metric recorded for a label, such
+// as its routes, in order
+func (jp *Jetpack) MetricLabelValues(name, label string) ([]string, error) {
+	metric, err := jp.GetMetric(name)
+	if err != nil {
+		return nil, err
+	}
+
+	metric.mutex.RLock()
+	defer metric.mutex.RUnlock()
+
+	seen := make(map[string]bool)
+	values := []string{}
+	for _, series := range metric.series {
+		if value, ok := series.labels[label]; ok && !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+	sort.Strings(values)
+	return values, nil
+}
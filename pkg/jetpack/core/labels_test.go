@@ -0,0 +1,92 @@
+package core
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMetricLabels(t *testing.T) {
+	jp := NewJetpack()
+	jp.RegisterMetric(MetricAPILatency, "edge_latency", "Edge request latency", "ms", nil, []string{"edge"})
+
+	values := []struct {
+		value  float64
+		labels Labels
+	}{
+		{10, Labels{"node": "edge-1", "route": "GET /posts"}},
+		{20, Labels{"node": "edge-1", "route": "GET /users"}},
+		{30, Labels{"node": "edge-2", "route": "GET /posts"}},
+		{50, Labels{"node": "edge-2", "route": "GET /posts"}},
+		{90, nil},
+	}
+	for _, v := range values {
+		if err := jp.RecordMetricWithLabels("edge_latency", v.value, v.labels); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if all, _ := jp.GetMetricStats("edge_latency", 0); all.Count != 5 || all.Max != 90 {
+		t.Fatalf("expected every value overall, got %+v", all)
+	}
+	posts, _ := jp.GetMetricStats("edge_latency", 0, LabelEquals("route", "GET /posts"))
+	if posts.Count != 3 || posts.Mean != 30 || posts.Latest != 50 {
+		t.Fatalf("expected the values of GET /posts across nodes, got %+v", posts)
+	}
+	if other, _ := jp.GetMetricStats("edge_latency", 0, LabelEquals("route", "GET /posts"), LabelNotEquals("node", "edge-2")); other.Count != 1 || other.Max != 10 {
+		t.Fatalf("expected the filters to combine, got %+v", other)
+	}
+	if none, _ := jp.GetMetricStats("edge_latency", 0, LabelEquals("node", "edge-9")); none.Count != 0 {
+		t.Fatalf("expected no values, got %+v", none)
+	}
+
+	nodes, err := jp.GetMetricBreakdown("edge_latency", 0, []string{"node"})
+	if err != nil || len(nodes) != 2 {
+		t.Fatalf("expected a group per node, got %+v %v", nodes, err)
+	}
+	if nodes[0].Labels["node"] != "edge-1" || nodes[0].Count != 2 || nodes[1].Labels["node"] != "edge-2" || nodes[1].Mean != 40 {
+		t.Fatalf("unexpected breakdown %+v %+v", nodes[0], nodes[1])
+	}
+	both, _ := jp.GetMetricBreakdown("edge_latency", 0, []string{"node", "route"}, LabelEquals("route", "GET /posts"))
+	if len(both) != 2 || both[0].Labels.String() != "node=edge-2,route=GET /posts" || both[0].Count != 2 {
+		t.Fatalf("unexpected breakdown %+v", both)
+	}
+
+	if routes, _ := jp.MetricLabelValues("edge_latency", "route"); len(routes) != 2 || routes[0] != "GET /posts" {
+		t.Fatalf("unexpected label values %v", routes)
+	}
+	if _, err := jp.GetMetricBreakdown("missing", 0, nil); err == nil {
+		t.Fatal("expected an error for an unknown metric")
+	}
+}
+
+func TestMetricSeriesCap(t *testing.T) {
+	jp := NewJetpack()
+	jp.RegisterMetric(MetricAPIThroughput, "requests", "Requests", "requests", nil, nil)
+	for i := 0; i < maxMetricSeries+10; i++ {
+		jp.RecordMetricWithLabels("requests", 1, Labels{"id": fmt.Sprint(i)})
+	}
+
+	metric, _ := jp.GetMetric("requests")
+	if len(metric.series) != maxMetricSeries {
+		t.Fatalf("expected %d label sets, got %d", maxMetricSeries, len(metric.series))
+	}
+	if all, _ := jp.GetMetricStats("requests", 0); all.Count != maxMetricSeries+10 {
+		t.Fatalf("expected every value overall, got %d", all.Count)
+	}
+}
+
+func TestParseLabelFilter(t *testing.T) {
+	for text, want := range map[string]LabelFilter{
+		"route=GET /posts": {Label: "route", Value: "GET /posts"},
+		"node != edge-1":   {Label: "node", Value: "edge-1", Exclude: true},
+		"node":             {Label: "node"},
+		"!node":            {Label: "node", Exclude: true},
+	} {
+		if got, err := ParseLabelFilter(text); err != nil || got != want {
+			t.Errorf("%q: expected %+v, got %+v %v", text, want, got, err)
+		}
+	}
+	if _, err := ParseLabelFilter("=x"); err == nil {
+		t.Error("expected an error for a filter without a label")
+	}
+}
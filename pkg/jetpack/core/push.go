@@ -0,0 +1,488 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPushEndpoint is the path the push gateway is served at by default
+const DefaultPushEndpoint = "/_jetpack/push"
+
+// DefaultPushBatch is the number of values a Pusher sends in one request
+// by default
+const DefaultPushBatch = 5000
+
+// maxPushBytes caps the size of a batch once decompressed
+const maxPushBytes = 16 << 20
+
+// maxPushNodes caps the nodes a gateway tracks by default
+const maxPushNodes = 1000
+
+// PushedValue is a value in a MetricBatch, with the labels it was recorded
+// with
+type PushedValue struct {
+	Value     float64   `json:"value"`
+	Timestamp time.Time `json:"timestamp"`
+	Labels    Labels    `json:"labels,omitempty"`
+}
+
+// PushedMetric is a metric's values in a MetricBatch, with what the
+// gateway needs to register the metric the first time it sees it
+type PushedMetric struct {
+	Name        string        `json:"name"`
+	Type        MetricType    `json:"type"`
+	Description string        `json:"description,omitempty"`
+	Unit        string        `json:"unit,omitempty"`
+	Tags        []string      `json:"tags,omitempty"`
+	Values      []PushedValue `json:"values"`
+}
+
+// MetricBatch is the values a Pusher sends the push gateway at once
+type MetricBatch struct {
+	// Node names the process pushing, such as an edge node's ID
+	Node string `json:"node"`
+
+	// Labels are added to every value, such as the node's region
+	Labels Labels `json:"labels,omitempty"`
+
+	// Interval is how often the node pushes, for the gateway to tell when
+	// it stopped
+	Interval time.Duration `json:"interval,omitempty"`
+
+	// Dropped counts the values the node dropped so far, while the gateway
+	// could not be reached for long
+	Dropped int `json:"dropped,omitempty"`
+
+	Metrics []PushedMetric `json:"metrics"`
+}
+
+// pushedSample is a value waiting to be pushed
+type pushedSample struct {
+	name  string
+	value PushedValue
+}
+
+// pusherList is the pushers values are buffered for
+type pusherList struct {
+	mutex   sync.RWMutex
+	pushers []*Pusher
+}
+
+// bufferPush queues a value for each pusher
+func (jp *Jetpack) bufferPush(name string, value MetricValue, labels Labels) {
+	jp.pushers.mutex.RLock()
+	defer jp.pushers.mutex.RUnlock()
+
+	for _, pusher := range jp.pushers.pushers {
+		pusher.add(name, value, labels)
+	}
+}
+
+// Pusher sends the values recorded in a Jetpack to a central Jetpack's
+// PushGateway, such as from each edge node, so its panel and alerts cover
+// the whole fleet. Values are sent in gzipped batches every interval, and
+// kept for the next push while the gateway cannot be reached.
+type Pusher struct {
+	Jetpack *Jetpack
+
+	// URL is the gateway's, such as "https://app.example.com/_jetpack/push"
+	URL string
+
+	// Node names the process and Labels are added to its values at the
+	// gateway, such as {"region": "eu-west"}
+	Node   string
+	Labels Labels
+
+	// Token is sent as a bearer token, for a gateway with one
+	Token string
+
+	// Client sends the batches; nil uses a client with a 30s timeout
+	Client *http.Client
+
+	// MaxBatch caps the values sent in one request
+	MaxBatch int
+
+	mutex    sync.Mutex
+	pending  []pushedSample
+	dropped  int
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewPusher creates a pusher sending the values recorded in jp from now on
+// to the gateway at url as node
+func NewPusher(jp *Jetpack, url, node string) *Pusher {
+	pusher := &Pusher{Jetpack: jp, URL: url, Node: node, MaxBatch: DefaultPushBatch}
+
+	jp.pushers.mutex.Lock()
+	jp.pushers.pushers = append(jp.pushers.pushers, pusher)
+	jp.pushers.mutex.Unlock()
+	return pusher
+}
+
+// add queues a value, dropping the oldest while the gateway cannot keep up
+func (p *Pusher) add(name string, value MetricValue, labels Labels) {
+	sample := pushedSample{name: name, value: PushedValue{Value: value.Value, Timestamp: value.Timestamp}}
+	if len(labels) > 0 {
+		sample.value.Labels = make(Labels, len(labels))
+		for label, value := range labels {
+			sample.value.Labels[label] = value
+		}
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.pending = append(p.pending, sample)
+	if over := len(p.pending) - maxPendingSamples; over > 0 {
+		p.pending = append(p.pending[:0], p.pending[over:]...)
+		p.dropped += over
+	}
+}
+
+// Dropped returns how many values were dropped because the gateway could
+// not be reached
+func (p *Pusher) Dropped() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.dropped
+}
+
+// Flush pushes the values recorded since the last push. Values that fail
+// to be sent are kept for the next flush.
+func (p *Pusher) Flush(ctx context.Context) error {
+	p.mutex.Lock()
+	samples := p.pending
+	p.pending = nil
+	p.mutex.Unlock()
+
+	size := p.MaxBatch
+	if size <= 0 {
+		size = DefaultPushBatch
+	}
+	for start := 0; start < len(samples); start += size {
+		end := start + size
+		if end > len(samples) {
+			end = len(samples)
+		}
+		if err := p.send(ctx, samples[start:end]); err != nil {
+			p.requeue(samples[start:])
+			return err
+		}
+	}
+	return nil
+}
+
+// requeue puts samples that failed to be sent back before those recorded
+// since
+func (p *Pusher) requeue(samples []pushedSample) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.pending = append(append([]pushedSample(nil), samples...), p.pending...)
+	if over := len(p.pending) - maxPendingSamples; over > 0 {
+		p.pending = p.pending[over:]
+		p.dropped += over
+	}
+}
+
+// batch groups samples by metric, with the metrics' descriptions
+func (p *Pusher) batch(samples []pushedSample) MetricBatch {
+	p.mutex.Lock()
+	batch := MetricBatch{Node: p.Node, Labels: p.Labels, Interval: p.interval, Dropped: p.dropped}
+	p.mutex.Unlock()
+
+	index := make(map[string]int)
+	for _, sample := range samples {
+		i, ok := index[sample.name]
+		if !ok {
+			i = len(batch.Metrics)
+			index[sample.name] = i
+			pushed := PushedMetric{Name: sample.name}
+			if metric, err := p.Jetpack.GetMetric(sample.name); err == nil {
+				metric.mutex.RLock()
+				pushed.Type, pushed.Description, pushed.Unit, pushed.Tags = metric.Type, metric.Description, metric.Unit, metric.Tags
+				metric.mutex.RUnlock()
+			}
+			batch.Metrics = append(batch.Metrics, pushed)
+		}
+		batch.Metrics[i].Values = append(batch.Metrics[i].Values, sample.value)
+	}
+	return batch
+}
+
+// send posts a batch of samples to the gateway, gzipped
+func (p *Pusher) send(ctx context.Context, samples []pushedSample) error {
+	var body bytes.Buffer
+	writer := gzip.NewWriter(&body)
+	if err := json.NewEncoder(writer).Encode(p.batch(samples)); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, &body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Content-Encoding", "gzip")
+	if p.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+p.Token)
+	}
+
+	client := p.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(response.Body, 4<<10))
+	if response.StatusCode/100 != 2 {
+		return fmt.Errorf("pushing metrics to %s: %s", p.URL, response.Status)
+	}
+	return nil
+}
+
+// Start pushes the recorded values every interval until Stop is called.
+// Failed pushes are reported as Jetpack errors.
+func (p *Pusher) Start(interval time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.stop != nil {
+		return
+	}
+	stop, done := make(chan struct{}), make(chan struct{})
+	p.stop, p.done, p.interval = stop, done, interval
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				if err := p.Flush(ctx); err != nil {
+					p.Jetpack.ReportError(ErrorReport{Source: "jetpack", Component: "push", Message: err.Error()})
+				}
+				cancel()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic pushes and pushes what is left
+func (p *Pusher) Stop(ctx context.Context) error {
+	p.mutex.Lock()
+	stop, done := p.stop, p.done
+	p.stop, p.done = nil, nil
+	p.mutex.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+	return p.Flush(ctx)
+}
+
+// PushedNode is a node that pushes its metrics to the gateway
+type PushedNode struct {
+	Node     string        `json:"node"`
+	Labels   Labels        `json:"labels,omitempty"`
+	LastPush time.Time     `json:"last_push"`
+	Interval time.Duration `json:"interval,omitempty"`
+
+	// Values counts the values received from the node and Dropped those
+	// it reported dropping
+	Values  int64 `json:"values"`
+	Dropped int   `json:"dropped"`
+
+	// Stale is set once the node missed three pushes
+	Stale bool `json:"stale"`
+}
+
+// PushGateway records the metrics Pushers send into its Jetpack, each value
+// labeled with the "node" it came from, so GetMetricBreakdown and alert
+// rules can tell the nodes apart while the metrics cover them all
+type PushGateway struct {
+	Jetpack *Jetpack
+
+	// Endpoint is the path the gateway is served at
+	Endpoint string
+
+	// Token, when set, must be sent by the pushers as a bearer token
+	Token string
+
+	// MaxNodes caps the nodes tracked; batches from others are refused
+	MaxNodes int
+
+	mutex    sync.Mutex
+	nodes    map[string]*PushedNode
+	register sync.Mutex
+	now      func() time.Time
+}
+
+// NewPushGateway creates a gateway recording into jp, whose panel data
+// lists the nodes pushing to it; an empty endpoint uses DefaultPushEndpoint
+func NewPushGateway(jp *Jetpack, endpoint string) *PushGateway {
+	if endpoint == "" {
+		endpoint = DefaultPushEndpoint
+	}
+	gateway := &PushGateway{
+		Jetpack:  jp,
+		Endpoint: endpoint,
+		MaxNodes: maxPushNodes,
+		nodes:    make(map[string]*PushedNode),
+		now:      time.Now,
+	}
+	jp.mutex.Lock()
+	jp.Push = gateway
+	jp.mutex.Unlock()
+	return gateway
+}
+
+// ServeHTTP records a batch. Serve it at the endpoint for POST requests,
+// outside any CSRF protection, as pushers carry no token but Token.
+func (g *PushGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if g.Token != "" {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(g.Token)) != 1 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var body io.Reader = r.Body
+	if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, "invalid batch", http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+	var batch MetricBatch
+	if err := json.NewDecoder(io.LimitReader(body, maxPushBytes)).Decode(&batch); err != nil {
+		http.Error(w, "invalid batch", http.StatusBadRequest)
+		return
+	}
+	if err := g.Record(batch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Record records the values of a batch, registering the metrics the
+// gateway's Jetpack does not have yet. Values that cannot be real, or are
+// older than the metrics keep, are skipped.
+func (g *PushGateway) Record(batch MetricBatch) error {
+	if batch.Node == "" || len(batch.Node) > 200 {
+		return fmt.Errorf("batch names no valid node")
+	}
+	now := g.now()
+
+	g.mutex.Lock()
+	node, ok := g.nodes[batch.Node]
+	if !ok {
+		if len(g.nodes) >= g.MaxNodes {
+			g.mutex.Unlock()
+			return fmt.Errorf("too many nodes pushing, %s is not tracked", batch.Node)
+		}
+		node = &PushedNode{Node: batch.Node}
+		g.nodes[batch.Node] = node
+	}
+	node.Labels, node.Interval, node.Dropped, node.LastPush = batch.Labels, batch.Interval, batch.Dropped, now
+	g.mutex.Unlock()
+
+	var recorded int64
+	for _, pushed := range batch.Metrics {
+		if pushed.Name == "" {
+			continue
+		}
+		g.ensure(pushed)
+		for _, value := range pushed.Values {
+			if math.IsNaN(value.Value) || math.IsInf(value.Value, 0) {
+				continue
+			}
+			// A node's clock running ahead does not put values in the future
+			timestamp := value.Timestamp
+			if timestamp.IsZero() || timestamp.After(now) {
+				timestamp = now
+			}
+
+			labels := make(Labels, len(value.Labels)+len(batch.Labels)+1)
+			for name, label := range batch.Labels {
+				labels[name] = label
+			}
+			for name, label := range value.Labels {
+				labels[name] = label
+			}
+			labels["node"] = batch.Node
+			if g.Jetpack.recordMetricAt(pushed.Name, value.Value, labels, timestamp) == nil {
+				recorded++
+			}
+		}
+	}
+
+	g.mutex.Lock()
+	node.Values += recorded
+	g.mutex.Unlock()
+	return nil
+}
+
+// ensure registers a pushed metric the first time it is seen
+func (g *PushGateway) ensure(pushed PushedMetric) {
+	// Not g.mutex, which GetPanelData takes while holding the Jetpack's
+	g.register.Lock()
+	defer g.register.Unlock()
+
+	if _, err := g.Jetpack.GetMetric(pushed.Name); err == nil {
+		return
+	}
+	g.Jetpack.RegisterMetric(pushed.Type, pushed.Name, pushed.Description, pushed.Unit, nil, pushed.Tags)
+}
+
+// Nodes returns the nodes pushing to the gateway, by name
+func (g *PushGateway) Nodes() []PushedNode {
+	now := g.now()
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	nodes := make([]PushedNode, 0, len(g.nodes))
+	for _, node := range g.nodes {
+		copied := *node
+		copied.Stale = node.Interval > 0 && now.Sub(node.LastPush) > 3*node.Interval
+		nodes = append(nodes, copied)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Node < nodes[j].Node })
+	return nodes
+}
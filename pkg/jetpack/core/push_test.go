@@ -0,0 +1,128 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPushToGateway(t *testing.T) {
+	central := NewJetpack()
+	gateway := NewPushGateway(central, "")
+	gateway.Token = "secret"
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+
+	push := func(node, region string, values ...float64) *Pusher {
+		jp := NewJetpack()
+		jp.RegisterMetric(MetricAPILatency, "edge_response_time", "Edge response time", "ms", nil, nil)
+		pusher := NewPusher(jp, server.URL, node)
+		pusher.Labels = Labels{"region": region}
+		pusher.Token = "secret"
+		for _, value := range values {
+			jp.RecordMetricWithLabels("edge_response_time", value, Labels{"route": "/posts"})
+		}
+		if err := pusher.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		return pusher
+	}
+	push("edge-1", "eu-west", 10, 20)
+	push("edge-2", "us-east", 300)
+
+	overall, err := central.GetMetricStats("edge_response_time", 0)
+	if err != nil || overall.Count != 3 || overall.Max != 300 {
+		t.Fatalf("unexpected fleet stats %+v %v", overall, err)
+	}
+	metric, _ := central.GetMetric("edge_response_time")
+	if metric.Unit != "ms" {
+		t.Fatalf("expected the metric to be registered as pushed, got unit %q", metric.Unit)
+	}
+	breakdown, err := central.GetMetricBreakdown("edge_response_time", 0, []string{"node"}, LabelEquals("route", "/posts"))
+	if err != nil || len(breakdown) != 2 || breakdown[0].Labels["node"] != "edge-1" || breakdown[0].Count != 2 {
+		t.Fatalf("unexpected breakdown by node %+v %v", breakdown, err)
+	}
+
+	nodes := gateway.Nodes()
+	if len(nodes) != 2 || nodes[0].Node != "edge-1" || nodes[0].Values != 2 || nodes[1].Labels["region"] != "us-east" {
+		t.Fatalf("unexpected nodes %+v", nodes)
+	}
+	if _, ok := central.GetPanelData()["nodes"]; !ok {
+		t.Fatalf("expected the panel data to list the nodes")
+	}
+
+	// Alerts can watch the nodes of a region only
+	central.Alerts.AddRule(AlertRule{Name: "slow_eu", Metric: "edge_response_time", Stat: "max", Comparison: Above, Threshold: 100, Labels: []LabelFilter{LabelEquals("region", "eu-west")}})
+	central.Alerts.AddRule(AlertRule{Name: "slow_us", Metric: "edge_response_time", Stat: "max", Comparison: Above, Threshold: 100, Labels: []LabelFilter{LabelEquals("region", "us-east")}})
+	central.Alerts.Evaluate()
+	for _, alert := range central.Alerts.Alerts() {
+		if (alert.Rule == "slow_us") != (alert.State == AlertFiring) {
+			t.Fatalf("unexpected alert %+v", alert)
+		}
+	}
+}
+
+func TestPusherKeepsValuesUntilSent(t *testing.T) {
+	central := NewJetpack()
+	gateway := NewPushGateway(central, "")
+	gateway.Token = "secret"
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+
+	jp := NewJetpack()
+	jp.RegisterMetric(MetricAPILatency, "edge_errors", "Edge errors", "", nil, nil)
+	pusher := NewPusher(jp, server.URL, "edge-1")
+	jp.RecordMetric("edge_errors", 1)
+
+	if err := pusher.Flush(context.Background()); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected the push to be refused without the token, got %v", err)
+	}
+	pusher.Token = "secret"
+	jp.RecordMetric("edge_errors", 0)
+	if err := pusher.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err := central.GetMetricStats("edge_errors", 0); err != nil || stats.Count != 2 {
+		t.Fatalf("expected both values once the push succeeded, got %+v %v", stats, err)
+	}
+}
+
+func TestPushGatewayRecord(t *testing.T) {
+	jp := NewJetpack()
+	gateway := NewPushGateway(jp, "")
+	now := time.Now()
+	gateway.now = func() time.Time { return now }
+
+	err := gateway.Record(MetricBatch{Node: "edge-1", Interval: 10 * time.Second, Metrics: []PushedMetric{{
+		Name: "edge_requests",
+		Values: []PushedValue{
+			{Value: 1, Timestamp: now.Add(-time.Second)},
+			{Value: 2, Timestamp: now.Add(-48 * time.Hour)},
+			{Value: 3, Timestamp: now.Add(time.Hour)},
+		},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, _ := jp.GetMetricStats("edge_requests", 0)
+	if stats.Count != 2 || stats.Latest != 3 {
+		t.Fatalf("expected values past retention skipped and future ones moved to now, got %+v", stats)
+	}
+	if err := gateway.Record(MetricBatch{}); err == nil {
+		t.Fatalf("expected a batch without a node to be refused")
+	}
+
+	now = now.Add(time.Minute)
+	if nodes := gateway.Nodes(); len(nodes) != 1 || !nodes[0].Stale || nodes[0].Values != 2 {
+		t.Fatalf("expected a stale node, got %+v", nodes)
+	}
+
+	recorder := httptest.NewRecorder()
+	gateway.ServeHTTP(recorder, httptest.NewRequest("GET", DefaultPushEndpoint, nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for GET, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// GetMetricStatsBetween summarizes a metric's values over the window
+// ending at to. It reads the history when there is one, so the window may
+// reach past MetricRetention and before a restart; otherwise it is
+// GetMetricStats over what is still retained.
+func (jp *Jetpack) GetMetricStatsBetween(ctx context.Context, name string, to time.Time, window time.Duration) (*MetricStats, error) {
+	if jp.History == nil {
+		return jp.GetMetricStats(name, window)
+	}
+	if window <= 0 {
+		window = jp.MetricRetention
+	}
+
+	values, err := jp.History.Query(ctx, name, to.Add(-window), to, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// A store covering just the window summarizes the values the same way
+	// the in-memory storage does
+	store := newMetricStore(window/100, window)
+	for _, value := range values {
+		store.add(value)
+	}
+	return store.stats(name, to, window), nil
+}
+
+// GenerateReport generates a human-readable report of every metric over
+// the window ending now, such as the last day
+func (jp *Jetpack) GenerateReport(ctx context.Context, window time.Duration) (string, error) {
+	jp.mutex.RLock()
+	names := make([]string, 0, len(jp.Metrics))
+	units := make(map[string]string, len(jp.Metrics))
+	for name, metric := range jp.Metrics {
+		names = append(names, name)
+		units[name] = metric.Unit
+	}
+	jp.mutex.RUnlock()
+	sort.Strings(names)
+
+	now := time.Now()
+	var report strings.Builder
+	fmt.Fprintf(&report, "Jetpack Report for the last %s\n", window)
+	fmt.Fprintf(&report, "Generated at: %s\n\n", now.Format(time.RFC1123))
+
+	for _, name := range names {
+		stats, err := jp.GetMetricStatsBetween(ctx, name, now, window)
+		if err != nil {
+			return "", err
+		}
+		if stats.Count == 0 {
+			continue
+		}
+		fmt.Fprintf(&report, "  %s: %d values, mean %.2f, p50 %.2f, p95 %.2f, p99 %.2f, max %.2f %s\n",
+			name, stats.Count, stats.Mean, stats.P50, stats.P95, stats.P99, stats.Max, units[name])
+	}
+
+	return report.String(), nil
+}
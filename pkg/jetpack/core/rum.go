@@ -0,0 +1,371 @@
+package core
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	// Real user metric types the browser beacon reports besides the frontend
+	// ones above
+	MetricTTFB       MetricType = "time_to_first_byte"
+	MetricINP        MetricType = "interaction_to_next_paint"
+	MetricUserTiming MetricType = "user_timing"
+)
+
+// DefaultRUMEndpoint is the path the browser beacon posts to by default
+const DefaultRUMEndpoint = "/_jetpack/rum"
+
+// maxBeaconBytes caps the size of a beacon
+const maxBeaconBytes = 64 << 10
+
+// maxBeaconMarks caps the custom marks recorded from one beacon
+const maxBeaconMarks = 20
+
+// rumMetric describes a metric the beacon collects
+type rumMetric struct {
+	metricType  MetricType
+	description string
+	unit        string
+}
+
+// rumMetrics lists the metrics the beacon collects, by name
+var rumMetrics = map[string]rumMetric{
+	"time_to_first_byte":        {MetricTTFB, "Time to first byte", "ms"},
+	"first_paint":               {MetricFirstPaint, "First paint", "ms"},
+	"first_contentful_paint":    {MetricFirstContentful, "First contentful paint", "ms"},
+	"largest_contentful_paint":  {MetricLargestContentful, "Largest contentful paint", "ms"},
+	"cumulative_layout_shift":   {MetricCLS, "Cumulative layout shift", "score"},
+	"interaction_to_next_paint": {MetricINP, "Interaction to next paint", "ms"},
+	"total_blocking_time":       {MetricTBT, "Total blocking time", "ms"},
+	"page_load":                 {MetricPageLoad, "Page load time", "ms"},
+	"fps":                       {MetricFPS, "Frames per second", "fps"},
+	"memory_usage":              {MetricMemoryUsage, "JavaScript heap in use", "MB"},
+	"dom_size":                  {MetricDOMSize, "Elements in the page", "elements"},
+	"network_requests":          {MetricNetworkRequests, "Resources loaded by the page", "requests"},
+	"resource_size":             {MetricResourceSize, "Bytes transferred for resources", "KB"},
+}
+
+// RUMBeacon is what the browser beacon posts once a page is hidden
+type RUMBeacon struct {
+	// Page is the path of the page
+	Page string `json:"page"`
+
+	// Metrics maps the names of rumMetrics to their values
+	Metrics map[string]float64 `json:"metrics"`
+
+	// Marks maps the names of performance.mark calls to their times in
+	// milliseconds since navigation
+	Marks map[string]float64 `json:"marks"`
+
+	// RequestID is the ID of the request that loaded the page, which
+	// HTTPMiddleware sends in a Server-Timing header
+	RequestID string `json:"request_id"`
+
+	// TimeOrigin is when the page started loading, in ms since the Unix
+	// epoch, and Timeline the navigations, clicks, errors, long tasks and
+	// requests that happened since
+	TimeOrigin float64         `json:"time_origin"`
+	Timeline   []TimelineEvent `json:"timeline"`
+}
+
+// RUM records real user monitoring: a script in the page measures web
+// vitals and the application's performance marks in the browser, and posts
+// them to the RUM handler, which records them as Jetpack metrics. Each is
+// recorded under its own name, such as "largest_contentful_paint", and per
+// page, as "largest_contentful_paint@/posts/:id". Marks are recorded as
+// "mark_<name>". The page view's timeline of events is kept in the
+// Jetpack's Timelines.
+type RUM struct {
+	Jetpack *Jetpack
+
+	// Endpoint is the path the beacon posts to
+	Endpoint string
+
+	// Route maps a page's path to the name it is recorded under, such as
+	// "/posts/42" to "/posts/:id", so pages of one route share metrics;
+	// nil records pages by path
+	Route func(page string) string
+
+	// MaxPages caps the pages recorded separately; later pages are only
+	// counted in the overall metrics
+	MaxPages int
+
+	// SampleRate is the fraction of page views that send a beacon
+	SampleRate float64
+
+	mutex sync.Mutex
+	pages map[string]bool
+}
+
+// NewRUM creates a real user monitor recording into jp; an empty endpoint
+// uses DefaultRUMEndpoint
+func NewRUM(jp *Jetpack, endpoint string) *RUM {
+	if endpoint == "" {
+		endpoint = DefaultRUMEndpoint
+	}
+
+	return &RUM{
+		Jetpack:    jp,
+		Endpoint:   endpoint,
+		MaxPages:   100,
+		SampleRate: 1,
+		pages:      make(map[string]bool),
+	}
+}
+
+// ServeHTTP records a beacon. Serve it at the endpoint for POST requests,
+// outside any CSRF protection, as beacons carry no token.
+func (rum *RUM) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var beacon RUMBeacon
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBeaconBytes)).Decode(&beacon); err != nil {
+		http.Error(w, "invalid beacon", http.StatusBadRequest)
+		return
+	}
+
+	rum.Record(beacon)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Record records the values of a beacon, skipping unknown metrics and
+// values that cannot be real, and keeps its timeline
+func (rum *RUM) Record(beacon RUMBeacon) {
+	page := rum.page(beacon.Page)
+
+	if rum.Jetpack.Timelines != nil {
+		if timeline := newTimeline(beacon); timeline != nil {
+			rum.Jetpack.Timelines.addTimeline(timeline)
+		}
+	}
+
+	for name, value := range beacon.Metrics {
+		metric, ok := rumMetrics[name]
+		if !ok || !validMeasurement(value) {
+			continue
+		}
+		rum.record(name, page, metric, value)
+	}
+
+	recorded := 0
+	for name, value := range beacon.Marks {
+		name = markName(name)
+		if name == "" || !validMeasurement(value) {
+			continue
+		}
+		if recorded++; recorded > maxBeaconMarks {
+			break
+		}
+		rum.record("mark_"+name, page, rumMetric{MetricUserTiming, "Time to the " + name + " mark", "ms"}, value)
+	}
+}
+
+// record records a value overall and for its page
+func (rum *RUM) record(name, page string, metric rumMetric, value float64) {
+	rum.ensure(name, metric, []string{"rum"})
+	rum.Jetpack.RecordMetric(name, value)
+
+	if page != "" {
+		pageName := name + "@" + page
+		rum.ensure(pageName, metric, []string{"rum", "page:" + page})
+		rum.Jetpack.RecordMetric(pageName, value)
+	}
+}
+
+// ensure registers a metric the first time it is seen; beacons arriving
+// together would otherwise register it twice and lose values
+func (rum *RUM) ensure(name string, metric rumMetric, tags []string) {
+	rum.mutex.Lock()
+	defer rum.mutex.Unlock()
+
+	if _, err := rum.Jetpack.GetMetric(name); err == nil {
+		return
+	}
+	rum.Jetpack.RegisterMetric(metric.metricType, name, metric.description, metric.unit, nil, tags)
+}
+
+// page returns the name a page is recorded under, or "" once MaxPages
+// other pages are recorded
+func (rum *RUM) page(path string) string {
+	if path == "" || !strings.HasPrefix(path, "/") || len(path) > 200 {
+		return ""
+	}
+	if rum.Route != nil {
+		path = rum.Route(path)
+	}
+
+	rum.mutex.Lock()
+	defer rum.mutex.Unlock()
+
+	if rum.pages == nil {
+		rum.pages = make(map[string]bool)
+	}
+	if !rum.pages[path] {
+		if len(rum.pages) >= rum.MaxPages {
+			return ""
+		}
+		rum.pages[path] = true
+	}
+	return path
+}
+
+// validMeasurement rejects values no browser measures, such as negative
+// times
+func validMeasurement(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0) && value >= 0 && value < 1e9
+}
+
+// markName keeps the letters, digits, dashes and underscores of a mark's
+// name, or returns "" for names too long to be a real mark
+func markName(name string) string {
+	if len(name) > 64 {
+		return ""
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r == ' ' || r == '.' || r == ':':
+			return '_'
+		}
+		return -1
+	}, name)
+}
+
+// ScriptTag returns the script that measures the page and posts the beacon;
+// add it at the end of the body of each page
+func (rum *RUM) ScriptTag() string {
+	endpoint, _ := json.Marshal(rum.Endpoint)
+	sampleRate, _ := json.Marshal(rum.SampleRate)
+	return "<script>" + strings.NewReplacer(
+		"__ENDPOINT__", string(endpoint),
+		"__SAMPLE_RATE__", string(sampleRate),
+	).Replace(rumScript) + "</script>"
+}
+
+// rumScript measures web vitals with PerformanceObserver and sends them,
+// once, when the page is hidden
+const rumScript = `(function () {
+  if (!window.performance || Math.random() >= __SAMPLE_RATE__) return;
+  var metrics = {}, marks = {}, timeline = [], sent = false;
+  function requestID(entry) {
+    var timings = (entry && entry.serverTiming) || [];
+    for (var i = 0; i < timings.length; i++) {
+      if (timings[i].name === "jetpack") return timings[i].description;
+    }
+  }
+  function track(type, time, detail, duration, id) {
+    if (timeline.length >= 200) return;
+    timeline.push({ type: type, time: time, detail: String(detail || "").slice(0, 200), duration: duration || 0, request_id: id || "" });
+  }
+  function describe(element) {
+    var name = element.tagName ? element.tagName.toLowerCase() : "";
+    if (element.id) name += "#" + element.id;
+    if (typeof element.className === "string" && element.className.trim()) name += "." + element.className.trim().split(/\s+/).join(".");
+    return name;
+  }
+  function observe(type, callback) {
+    try {
+      new PerformanceObserver(function (list) { list.getEntries().forEach(callback); })
+        .observe({ type: type, buffered: true });
+    } catch (e) {}
+  }
+  observe("paint", function (entry) {
+    metrics[entry.name] = entry.startTime;
+  });
+  observe("largest-contentful-paint", function (entry) {
+    metrics.largest_contentful_paint = entry.startTime;
+  });
+  observe("layout-shift", function (entry) {
+    if (!entry.hadRecentInput) metrics.cumulative_layout_shift = (metrics.cumulative_layout_shift || 0) + entry.value;
+  });
+  observe("longtask", function (entry) {
+    metrics.total_blocking_time = (metrics.total_blocking_time || 0) + Math.max(0, entry.duration - 50);
+    track("longtask", entry.startTime, entry.name, entry.duration);
+  });
+  observe("event", function (entry) {
+    if (entry.interactionId) metrics.interaction_to_next_paint = Math.max(metrics.interaction_to_next_paint || 0, entry.duration);
+  });
+
+  var frames = 0, samples = [], second = performance.now();
+  function frame(now) {
+    frames++;
+    if (now - second >= 1000) {
+      samples.push(frames * 1000 / (now - second));
+      frames = 0;
+      second = now;
+    }
+    if (!sent) requestAnimationFrame(frame);
+  }
+  requestAnimationFrame(frame);
+
+  addEventListener("click", function (event) {
+    if (event.target instanceof Element) track("click", performance.now(), describe(event.target));
+  }, true);
+  addEventListener("error", function (event) {
+    if (event.message) track("error", performance.now(), event.message + (event.filename ? " (" + event.filename + ":" + event.lineno + ")" : ""));
+  });
+  addEventListener("unhandledrejection", function (event) {
+    track("error", performance.now(), "Unhandled rejection: " + (event.reason && event.reason.message || event.reason));
+  });
+  function navigated() {
+    track("navigation", performance.now(), location.pathname);
+  }
+  addEventListener("popstate", navigated);
+  if (history.pushState) {
+    var pushState = history.pushState;
+    history.pushState = function () {
+      var result = pushState.apply(this, arguments);
+      navigated();
+      return result;
+    };
+  }
+
+  function send() {
+    if (sent) return;
+    sent = true;
+    var navigation = performance.getEntriesByType("navigation")[0];
+    track("navigation", 0, location.pathname, navigation && navigation.duration, requestID(navigation));
+    if (navigation) {
+      metrics.time_to_first_byte = navigation.responseStart;
+      if (navigation.loadEventEnd > 0) metrics.page_load = navigation.loadEventEnd;
+    }
+    if (metrics["first-paint"] !== undefined) metrics.first_paint = metrics["first-paint"];
+    if (metrics["first-contentful-paint"] !== undefined) metrics.first_contentful_paint = metrics["first-contentful-paint"];
+    delete metrics["first-paint"];
+    delete metrics["first-contentful-paint"];
+    if (samples.length) metrics.fps = samples.reduce(function (a, b) { return a + b; }, 0) / samples.length;
+    if (performance.memory) metrics.memory_usage = performance.memory.usedJSHeapSize / 1048576;
+    metrics.dom_size = document.getElementsByTagName("*").length;
+    var resources = performance.getEntriesByType("resource");
+    metrics.network_requests = resources.length;
+    metrics.resource_size = resources.reduce(function (total, entry) { return total + (entry.transferSize || 0); }, 0) / 1024;
+    resources.forEach(function (entry) {
+      if (entry.initiatorType === "fetch" || entry.initiatorType === "xmlhttprequest") {
+        track("request", entry.startTime, entry.name.replace(location.origin, ""), entry.duration, requestID(entry));
+      }
+    });
+    timeline.sort(function (a, b) { return a.time - b.time; });
+    performance.getEntriesByType("mark").forEach(function (entry) { marks[entry.name] = entry.startTime; });
+
+    var body = JSON.stringify({
+      page: location.pathname, metrics: metrics, marks: marks,
+      request_id: requestID(navigation) || "", time_origin: performance.timeOrigin || 0, timeline: timeline
+    });
+    if (!(navigator.sendBeacon && navigator.sendBeacon(__ENDPOINT__, new Blob([body], { type: "application/json" })))) {
+      fetch(__ENDPOINT__, { method: "POST", body: body, keepalive: true, headers: { "Content-Type": "application/json" } });
+    }
+  }
+  document.addEventListener("visibilitychange", function () {
+    if (document.visibilityState === "hidden") send();
+  });
+  addEventListener("pagehide", send);
+})();`
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRUMHandler(t *testing.T) {
+	jp := NewJetpack()
+	rum := NewRUM(jp, "")
+	rum.MaxPages = 1
+	rum.Route = func(page string) string {
+		if strings.HasPrefix(page, "/posts/") {
+			return "/posts/:id"
+		}
+		return page
+	}
+
+	post := func(body string) int {
+		recorder := httptest.NewRecorder()
+		rum.ServeHTTP(recorder, httptest.NewRequest("POST", DefaultRUMEndpoint, strings.NewReader(body)))
+		return recorder.Code
+	}
+
+	if code := post(`{"page":"/posts/42","metrics":{"largest_contentful_paint":1200,"fps":-3,"bogus":1},"marks":{"app hydrated":300}}`); code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", code)
+	}
+	post(`{"page":"/posts/7","metrics":{"largest_contentful_paint":800}}`)
+	post(`{"page":"/about","metrics":{"largest_contentful_paint":400}}`)
+
+	overall, err := jp.GetMetricStats("largest_contentful_paint", 0)
+	if err != nil || overall.Count != 3 || overall.Max != 1200 {
+		t.Fatalf("unexpected overall stats %+v %v", overall, err)
+	}
+	page, err := jp.GetMetricStats("largest_contentful_paint@/posts/:id", 0)
+	if err != nil || page.Count != 2 || page.Mean != 1000 {
+		t.Fatalf("unexpected page stats %+v %v", page, err)
+	}
+	if _, err := jp.GetMetric("largest_contentful_paint@/about"); err == nil {
+		t.Fatalf("expected pages past MaxPages to be recorded overall only")
+	}
+	if latest, err := jp.GetMetricLatest("mark_app_hydrated"); err != nil || latest != 300 {
+		t.Fatalf("expected the mark to be recorded, got %v %v", latest, err)
+	}
+	for _, name := range []string{"fps", "bogus"} {
+		if _, err := jp.GetMetric(name); err == nil {
+			t.Fatalf("expected %s to be skipped", name)
+		}
+	}
+
+	if code := post(`not json`); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for a bad beacon, got %d", code)
+	}
+	recorder := httptest.NewRecorder()
+	rum.ServeHTTP(recorder, httptest.NewRequest("GET", DefaultRUMEndpoint, nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for GET, got %d", recorder.Code)
+	}
+}
+
+func TestRUMScriptTag(t *testing.T) {
+	rum := NewRUM(NewJetpack(), "/metrics/rum")
+	rum.SampleRate = 0.5
+	script := rum.ScriptTag()
+	if !strings.HasPrefix(script, "<script>") || !strings.Contains(script, `sendBeacon("/metrics/rum"`) || !strings.Contains(script, "Math.random() >= 0.5") {
+		t.Fatalf("unexpected script %s", script)
+	}
+}
@@ -0,0 +1,163 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// sriAlgorithms are the hashes integrity metadata may use, weakest first, as
+// browsers only check the strongest one listed
+var sriAlgorithms = []struct {
+	name string
+	hash func() hash.Hash
+}{
+	{"sha256", sha256.New},
+	{"sha384", sha512.New384},
+	{"sha512", sha512.New},
+}
+
+// SRIHash returns the Subresource Integrity metadata of an asset, its
+// SHA-384 digest, for the integrity attribute of the tag loading it
+func SRIHash(data []byte) string {
+	sum := sha512.Sum384(data)
+	return "sha384-" + base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// VerifySRI checks an asset against integrity metadata, such as an
+// integrity attribute holds. As in browsers, only the digests of the
+// strongest algorithm listed count, and any one of them may match.
+func VerifySRI(integrity string, data []byte) error {
+	strongest := -1
+	var digests []string
+	for _, token := range strings.Fields(integrity) {
+		// Options such as ?ct=... follow the digest
+		token = strings.SplitN(token, "?", 2)[0]
+		dash := strings.Index(token, "-")
+		if dash < 0 {
+			continue
+		}
+		for i, algorithm := range sriAlgorithms {
+			if !strings.EqualFold(token[:dash], algorithm.name) || i < strongest {
+				continue
+			}
+			if i > strongest {
+				strongest, digests = i, nil
+			}
+			digests = append(digests, token[dash+1:])
+		}
+	}
+	if strongest < 0 {
+		return fmt.Errorf("integrity %q has no sha256, sha384 or sha512 digest", integrity)
+	}
+
+	h := sriAlgorithms[strongest].hash()
+	h.Write(data)
+	actual := base64.StdEncoding.EncodeToString(h.Sum(nil))
+	for _, digest := range digests {
+		if subtle.ConstantTimeCompare([]byte(digest), []byte(actual)) == 1 {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s digest %s does not match integrity %q", sriAlgorithms[strongest].name, actual, integrity)
+}
+
+// cspNonceKey is the context key of a request's CSP nonce
+type cspNonceKey struct{}
+
+// WithCSPNonce returns a context carrying the nonce the
+// Content-Security-Policy of its response allows inline scripts and styles
+// with
+func WithCSPNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, cspNonceKey{}, nonce)
+}
+
+// CSPNonce is the nonce of WithCSPNonce, or "" when the response's policy
+// has none
+func CSPNonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(cspNonceKey{}).(string)
+	return nonce
+}
+
+// AddNonce adds a nonce attribute to the <script> and <style> tags of
+// trusted markup, such as the panel's, that have none. The contents of the
+// elements are skipped, so tags written by scripts are left alone. Never
+// add nonces to markup holding user content, as that would let scripts
+// injected into it run.
+func AddNonce(markup, nonce string) string {
+	if nonce == "" {
+		return markup
+	}
+
+	var b strings.Builder
+	lower := lowerASCII(markup)
+	i := 0
+	for i < len(markup) {
+		start, name := nextRawTextTag(lower, i)
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(markup[start:], '>')
+		if end < 0 {
+			break
+		}
+		end += start
+
+		b.WriteString(markup[i : start+1+len(name)])
+		if !strings.Contains(lower[start:end], "nonce=") {
+			b.WriteString(` nonce="` + nonce + `"`)
+		}
+		b.WriteString(markup[start+1+len(name) : end+1])
+		i = end + 1
+
+		// Skip to the end tag, as the element's text is not markup
+		closing := strings.Index(lower[i:], "</"+name)
+		if closing < 0 {
+			b.WriteString(markup[i:])
+			return b.String()
+		}
+		b.WriteString(markup[i : i+closing])
+		i += closing
+	}
+	b.WriteString(markup[i:])
+	return b.String()
+}
+
+// nextRawTextTag finds the next <script> or <style> start tag in lowercased
+// markup from i, returning its index and name, or -1
+func nextRawTextTag(lower string, i int) (int, string) {
+	for {
+		start := strings.IndexByte(lower[i:], '<')
+		if start < 0 {
+			return -1, ""
+		}
+		start += i
+		for _, name := range []string{"script", "style"} {
+			if !strings.HasPrefix(lower[start+1:], name) {
+				continue
+			}
+			after := start + 1 + len(name)
+			if after < len(lower) && strings.ContainsRune(" \t\n\r\f/>", rune(lower[after])) {
+				return start, name
+			}
+		}
+		i = start + 1
+	}
+}
+
+// lowerASCII lowercases the ASCII letters of s, keeping its byte offsets,
+// which strings.ToLower does not for some other letters
+func lowerASCII(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
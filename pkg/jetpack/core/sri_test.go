@@ -0,0 +1,50 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVerifySRI(t *testing.T) {
+	script := []byte("alert(1)")
+	integrity := SRIHash(script)
+	if !strings.HasPrefix(integrity, "sha384-") {
+		t.Fatalf("expected a sha384 hash, got %s", integrity)
+	}
+	if err := VerifySRI(integrity, script); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifySRI(integrity, []byte("alert(2)")); err == nil {
+		t.Fatalf("expected a changed script to fail")
+	}
+
+	// Only the strongest algorithm counts, and any of its digests
+	sha256 := "sha256-bhHHL3z2vDgxUt0W3dWQOrprscmda2Y5pLsLg4GF+pI="
+	if err := VerifySRI(sha256+" sha384-wrong", script); err == nil {
+		t.Fatalf("expected the wrong sha384 digest to fail despite the right sha256 one")
+	}
+	if err := VerifySRI("sha384-wrong "+integrity+"?ct=application/javascript", script); err != nil {
+		t.Fatalf("expected one matching digest to pass, got %v", err)
+	}
+	if err := VerifySRI(sha256+" md5-abc", script); err != nil {
+		t.Fatalf("expected unknown algorithms to be ignored, got %v", err)
+	}
+	if err := VerifySRI("md5-abc", script); err == nil || !strings.Contains(err.Error(), "no sha256") {
+		t.Fatalf("expected integrity without a known algorithm to fail, got %v", err)
+	}
+}
+
+func TestAddNonce(t *testing.T) {
+	markup := `<STYLE>.a{}</STYLE><script src="/a.js"></script>` +
+		`<script nonce="other">1</script>` +
+		`<script>document.write("<script>x</script>")</script><scripts>`
+	want := `<STYLE nonce="abc">.a{}</STYLE><script nonce="abc" src="/a.js"></script>` +
+		`<script nonce="other">1</script>` +
+		`<script nonce="abc">document.write("<script>x</script>")</script><scripts>`
+	if got := AddNonce(markup, "abc"); got != want {
+		t.Fatalf("unexpected markup\n got %s\nwant %s", got, want)
+	}
+	if got := AddNonce(markup, ""); got != markup {
+		t.Fatalf("expected no nonce to leave the markup alone, got %s", got)
+	}
+}
@@ -0,0 +1,242 @@
+package core
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultMetricResolution is the span of time each stored bucket covers
+	DefaultMetricResolution = 10 * time.Second
+
+	// DefaultMetricRetention is how far back metric stats can look
+	DefaultMetricRetention = time.Hour
+
+	// maxRecentValues caps Metric.Values, which keeps the raw latest values
+	maxRecentValues = 100
+
+	// maxBucketSamples caps the values a bucket keeps for percentiles; busier
+	// buckets keep a uniform random sample of them
+	maxBucketSamples = 256
+)
+
+// MetricStats summarizes the values a metric recorded over a window
+type MetricStats struct {
+	Name   string        `json:"name"`
+	Window time.Duration `json:"window"`
+	Count  int           `json:"count"`
+	Min    float64       `json:"min"`
+	Max    float64       `json:"max"`
+	Mean   float64       `json:"mean"`
+	P50    float64       `json:"p50"`
+	P95    float64       `json:"p95"`
+	P99    float64       `json:"p99"`
+	Latest float64       `json:"latest"`
+
+	// Rate is how fast the value changed, in units per second, from the
+	// first value in the window to the last
+	Rate float64 `json:"rate"`
+
+	// Labels are the label values of a GetMetricBreakdown group
+	Labels Labels `json:"labels,omitempty"`
+}
+
+// metricBucket aggregates the values recorded in one span of time
+type metricBucket struct {
+	start   time.Time
+	count   int
+	sum     float64
+	min     float64
+	max     float64
+	first   MetricValue
+	last    MetricValue
+	samples []float64
+}
+
+// metricStore keeps a metric's values in a ring of time buckets, so memory
+// stays bounded however often the metric is recorded
+type metricStore struct {
+	resolution time.Duration
+	buckets    []metricBucket
+	random     *rand.Rand
+}
+
+func newMetricStore(resolution, retention time.Duration) *metricStore {
+	if resolution <= 0 {
+		resolution = DefaultMetricResolution
+	}
+	if retention < resolution {
+		retention = DefaultMetricRetention
+	}
+
+	return &metricStore{
+		resolution: resolution,
+		buckets:    make([]metricBucket, int(retention/resolution)),
+		random:     rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// retention returns how far back the store can look
+func (s *metricStore) retention() time.Duration {
+	return time.Duration(len(s.buckets)) * s.resolution
+}
+
+// add records a value in the bucket for its time, reusing the bucket once
+// its previous span has fallen out of retention. Values older than the
+// retention, arriving late, are dropped.
+func (s *metricStore) add(value MetricValue) {
+	start := value.Timestamp.Truncate(s.resolution)
+	bucket := &s.buckets[int((start.UnixNano()/int64(s.resolution))%int64(len(s.buckets)))]
+	if start.Before(bucket.start) {
+		// A late value from a span the ring has moved past would wipe
+		// the newer span's values
+		return
+	}
+	if !bucket.start.Equal(start) {
+		*bucket = metricBucket{start: start, min: value.Value, max: value.Value, first: value, samples: bucket.samples[:0]}
+	}
+
+	bucket.count++
+	bucket.sum += value.Value
+	bucket.min = math.Min(bucket.min, value.Value)
+	bucket.max = math.Max(bucket.max, value.Value)
+	if !value.Timestamp.Before(bucket.last.Timestamp) {
+		bucket.last = value
+	}
+	if value.Timestamp.Before(bucket.first.Timestamp) {
+		bucket.first = value
+	}
+
+	// Reservoir sampling keeps every value equally likely to be in the sample
+	if len(bucket.samples) < maxBucketSamples {
+		bucket.samples = append(bucket.samples, value.Value)
+	} else if i := s.random.Intn(bucket.count); i < maxBucketSamples {
+		bucket.samples[i] = value.Value
+	}
+}
+
+// stats summarizes the buckets overlapping the window ending at now. A
+// window of zero, or past the retention, covers the whole retention.
+func (s *metricStore) stats(name string, now time.Time, window time.Duration) *MetricStats {
+	return summarize(name, now, window, s)
+}
+
+// summarize summarizes the buckets of stores sharing a resolution and
+// retention, such as the label sets of a metric, as if their values were
+// recorded in one
+func summarize(name string, now time.Time, window time.Duration, stores ...*metricStore) *MetricStats {
+	retention := DefaultMetricRetention
+	if len(stores) > 0 {
+		retention = stores[0].retention()
+	}
+	if window <= 0 || window > retention {
+		window = retention
+	}
+	stats := &MetricStats{Name: name, Window: window}
+
+	from := now.Add(-window)
+	var first, last MetricValue
+	var sum float64
+	var samples []float64
+	var weights []float64
+	for _, s := range stores {
+		for i := range s.buckets {
+			bucket := &s.buckets[i]
+			if bucket.count == 0 || !bucket.start.Add(s.resolution).After(from) || bucket.start.After(now) {
+				continue
+			}
+
+			if stats.Count == 0 {
+				stats.Min, stats.Max = bucket.min, bucket.max
+				first, last = bucket.first, bucket.last
+			}
+			stats.Count += bucket.count
+			sum += bucket.sum
+			stats.Min = math.Min(stats.Min, bucket.min)
+			stats.Max = math.Max(stats.Max, bucket.max)
+			if bucket.first.Timestamp.Before(first.Timestamp) {
+				first = bucket.first
+			}
+			if !bucket.last.Timestamp.Before(last.Timestamp) {
+				last = bucket.last
+			}
+
+			// Sampled buckets stand for more values than they keep
+			weight := float64(bucket.count) / float64(len(bucket.samples))
+			for _, sample := range bucket.samples {
+				samples = append(samples, sample)
+				weights = append(weights, weight)
+			}
+		}
+	}
+	if stats.Count == 0 {
+		return stats
+	}
+
+	stats.Mean = sum / float64(stats.Count)
+	stats.Latest = last.Value
+	if elapsed := last.Timestamp.Sub(first.Timestamp).Seconds(); elapsed > 0 {
+		stats.Rate = (last.Value - first.Value) / elapsed
+	}
+	stats.P50, stats.P95, stats.P99 = percentiles(samples, weights)
+	return stats
+}
+
+// percentiles returns the values below which 50%, 95% and 99% of the
+// weighted samples fall
+func percentiles(samples, weights []float64) (p50, p95, p99 float64) {
+	fractions := []float64{0.50, 0.95, 0.99}
+	order := make([]int, len(samples))
+	var total float64
+	for i := range order {
+		order[i] = i
+		total += weights[i]
+	}
+	sort.Slice(order, func(a, b int) bool { return samples[order[a]] < samples[order[b]] })
+
+	results := make([]float64, len(fractions))
+	for f, fraction := range fractions {
+		target := fraction * total
+		var seen float64
+		for _, i := range order {
+			seen += weights[i]
+			results[f] = samples[i]
+			if seen >= target {
+				break
+			}
+		}
+	}
+	return results[0], results[1], results[2]
+}
+
+// GetMetricStats summarizes a metric over the last window, such as
+// 5*time.Minute or time.Hour; zero covers everything still retained. With
+// label filters, such as LabelEquals("route", "GET /posts"), only the values
+// recorded with matching labels by RecordMetricWithLabels count, aggregated
+// across their other labels.
+func (jp *Jetpack) GetMetricStats(name string, window time.Duration, filters ...LabelFilter) (*MetricStats, error) {
+	metric, err := jp.GetMetric(name)
+	if err != nil {
+		return nil, err
+	}
+
+	metric.mutex.RLock()
+	defer metric.mutex.RUnlock()
+
+	if metric.store == nil {
+		return &MetricStats{Name: name, Window: window}, nil
+	}
+	if len(filters) > 0 {
+		var stores []*metricStore
+		for _, series := range metric.matchingSeries(filters) {
+			stores = append(stores, series.store)
+		}
+		if len(stores) == 0 {
+			stores = []*metricStore{newMetricStore(metric.store.resolution, metric.store.retention())}
+		}
+		return summarize(name, time.Now(), window, stores...), nil
+	}
+	return metric.store.stats(name, time.Now(), window), nil
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetricStoreStats(t *testing.T) {
+	store := newMetricStore(10*time.Second, time.Minute)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// 100 values a second apart: 1, 2, ... 100
+	for i := 1; i <= 100; i++ {
+		store.add(MetricValue{Value: float64(i), Timestamp: start.Add(time.Duration(i-1) * time.Second)})
+	}
+	now := start.Add(99 * time.Second)
+
+	// Only the last minute is retained
+	all := store.stats("latency", now, 0)
+	if all.Window != time.Minute || all.Count != 60 || all.Min != 41 || all.Max != 100 || all.Latest != 100 {
+		t.Fatalf("unexpected retained stats %+v", all)
+	}
+	if all.P50 != 70 || all.P95 != 97 || all.P99 != 100 || all.Mean != 70.5 {
+		t.Fatalf("unexpected percentiles %+v", all)
+	}
+	if all.Rate != 1 {
+		t.Fatalf("expected a rate of 1 per second, got %v", all.Rate)
+	}
+
+	// The bucket the window starts in counts whole
+	recent := store.stats("latency", now, 20*time.Second)
+	if recent.Count != 30 || recent.Min != 71 {
+		t.Fatalf("unexpected windowed stats %+v", recent)
+	}
+
+	if empty := store.stats("latency", now.Add(time.Hour), 0); empty.Count != 0 {
+		t.Fatalf("expected expired values to be dropped, got %+v", empty)
+	}
+}
+
+func TestMetricStoreSampling(t *testing.T) {
+	store := newMetricStore(time.Minute, time.Hour)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 1; i <= 10000; i++ {
+		store.add(MetricValue{Value: float64(i % 100), Timestamp: now})
+	}
+
+	for _, bucket := range store.buckets {
+		if len(bucket.samples) > maxBucketSamples {
+			t.Fatalf("expected a bounded sample, got %d values", len(bucket.samples))
+		}
+	}
+	stats := store.stats("fps", now, time.Minute)
+	if stats.Count != 10000 || stats.Min != 0 || stats.Max != 99 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if stats.P50 < 40 || stats.P50 > 60 || stats.P99 < 90 {
+		t.Fatalf("expected sampled percentiles near the true ones, got %+v", stats)
+	}
+}
+
+func TestMetricStoreOutOfOrder(t *testing.T) {
+	store := newMetricStore(10*time.Second, time.Minute)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	// A late value from the same span is counted, but is not the latest
+	store.add(MetricValue{Value: 5, Timestamp: start.Add(5 * time.Minute)})
+	store.add(MetricValue{Value: 3, Timestamp: start.Add(5*time.Minute - 2*time.Second)})
+	store.add(MetricValue{Value: 4, Timestamp: start.Add(5*time.Minute + time.Second)})
+
+	// A value a whole retention older maps to the same bucket, and is
+	// dropped rather than replacing the newer values
+	store.add(MetricValue{Value: 100, Timestamp: start.Add(4 * time.Minute)})
+
+	stats := store.stats("latency", start.Add(5*time.Minute+time.Second), 0)
+	if stats.Count != 3 || stats.Max != 5 || stats.Min != 3 || stats.Latest != 4 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	// A newer span still takes the bucket over
+	store.add(MetricValue{Value: 7, Timestamp: start.Add(6 * time.Minute)})
+	if stats := store.stats("latency", start.Add(6*time.Minute), 0); stats.Count != 1 || stats.Latest != 7 {
+		t.Fatalf("expected the newer span to replace the bucket, got %+v", stats)
+	}
+}
+
+func TestGetMetricStats(t *testing.T) {
+	jp := NewJetpack()
+	jp.RegisterMetric(MetricAPILatency, "api_latency", "API latency", "ms", nil, nil)
+	for i := 0; i < maxRecentValues+50; i++ {
+		jp.RecordMetric("api_latency", 10)
+	}
+
+	stats, err := jp.GetMetricStats("api_latency", 5*time.Minute)
+	if err != nil || stats.Count != maxRecentValues+50 || stats.P99 != 10 {
+		t.Fatalf("unexpected stats %+v %v", stats, err)
+	}
+	if metric, _ := jp.GetMetric("api_latency"); len(metric.Values) != maxRecentValues {
+		t.Fatalf("expected raw values to be capped, got %d", len(metric.Values))
+	}
+	if _, err := jp.GetMetricStats("missing", 0); err == nil {
+		t.Fatalf("expected an error for an unknown metric")
+	}
+}
@@ -0,0 +1,397 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricAvailability is the metric type of synthetic checks' availability:
+// 1 while a check passes, 0 while it fails
+const MetricAvailability MetricType = "availability"
+
+const (
+	// DefaultCheckInterval and DefaultCheckTimeout are how often a check
+	// runs and how long it may take, unless it says otherwise
+	DefaultCheckInterval = time.Minute
+	DefaultCheckTimeout  = 10 * time.Second
+
+	// maxCheckBody caps the response body a check reads for its assertions
+	maxCheckBody = 1 << 20
+)
+
+// SyntheticCheck is a request the server makes periodically to check the
+// app is up, such as to its home page or a health endpoint
+type SyntheticCheck struct {
+	// Name identifies the check; its metrics are synthetic_up@<name> and
+	// synthetic_latency@<name>
+	Name string `json:"name"`
+
+	// URL, Method, Headers and Body make the request; Method is GET if empty
+	URL     string            `json:"url"`
+	Method  string            `json:"method,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+
+	// Interval is how often the check runs, and Timeout how long it may take
+	Interval time.Duration `json:"interval,omitempty"`
+	Timeout  time.Duration `json:"timeout,omitempty"`
+
+	// ExpectStatus is the status the response must have; zero accepts any
+	// 2xx or 3xx status
+	ExpectStatus int `json:"expect_status,omitempty"`
+
+	// ExpectBody is text the response body must contain, and
+	// ExpectBodyPattern a regular expression it must match
+	ExpectBody        string `json:"expect_body,omitempty"`
+	ExpectBodyPattern string `json:"expect_body_pattern,omitempty"`
+
+	// MaxLatency fails responses slower than it; zero accepts any
+	MaxLatency time.Duration `json:"max_latency,omitempty"`
+
+	// AlertAfter, when positive, adds an alert rule that fires once the
+	// check has failed for that long, and tells AlertChannels, or every
+	// notifier if empty
+	AlertAfter    time.Duration `json:"alert_after,omitempty"`
+	AlertChannels []string      `json:"alert_channels,omitempty"`
+
+	// Run, when set, is called instead of requesting URL; an error fails the
+	// check
+	Run func(ctx context.Context) error `json:"-"`
+}
+
+// NewGoScaleCheck creates a check running a GoScale operation with
+// variables against the GoScale API served at endpoint
+func NewGoScaleCheck(name, endpoint, operation string, variables map[string]interface{}) (SyntheticCheck, error) {
+	body, err := json.Marshal(map[string]interface{}{"operation": operation, "variables": variables})
+	if err != nil {
+		return SyntheticCheck{}, err
+	}
+
+	return SyntheticCheck{
+		Name:         name,
+		URL:          endpoint,
+		Method:       http.MethodPost,
+		Headers:      map[string]string{"Content-Type": "application/json"},
+		Body:         string(body),
+		ExpectStatus: http.StatusOK,
+		ExpectBody:   `"data"`,
+	}, nil
+}
+
+// CheckResult is the outcome of a check's latest run
+type CheckResult struct {
+	Check   string    `json:"check"`
+	Time    time.Time `json:"time"`
+	Up      bool      `json:"up"`
+	Status  int       `json:"status,omitempty"`
+	Latency float64   `json:"latency"` // in ms
+	Error   string    `json:"error,omitempty"`
+
+	// DownSince is when the check started failing, while it fails
+	DownSince time.Time `json:"down_since,omitempty"`
+}
+
+// syntheticCheck is a check with its compiled pattern and schedule
+type syntheticCheck struct {
+	SyntheticCheck
+	pattern *regexp.Regexp
+	stop    chan struct{}
+}
+
+// SyntheticMonitor runs synthetic checks and records their availability and
+// latency as Jetpack metrics, so alert rules, the panel and reports see
+// them
+type SyntheticMonitor struct {
+	Jetpack *Jetpack
+
+	// Client makes the checks' requests
+	Client *http.Client
+
+	mutex   sync.Mutex
+	checks  map[string]*syntheticCheck
+	results map[string]CheckResult
+	running bool
+}
+
+// NewSyntheticMonitor creates a synthetic monitor recording into jp
+func NewSyntheticMonitor(jp *Jetpack) *SyntheticMonitor {
+	return &SyntheticMonitor{
+		Jetpack: jp,
+		Client:  &http.Client{},
+		checks:  make(map[string]*syntheticCheck),
+		results: make(map[string]CheckResult),
+	}
+}
+
+// AddCheck adds a check, or replaces the check of the same name. Once the
+// monitor is started, the check runs right away and then every Interval.
+func (sm *SyntheticMonitor) AddCheck(check SyntheticCheck) error {
+	if check.Name == "" {
+		return fmt.Errorf("synthetic check needs a name")
+	}
+	if check.Run == nil {
+		parsed, err := url.Parse(check.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("synthetic check %s: invalid URL %q", check.Name, check.URL)
+		}
+	}
+	if check.Method == "" {
+		check.Method = http.MethodGet
+	}
+	if check.Interval <= 0 {
+		check.Interval = DefaultCheckInterval
+	}
+	if check.Timeout <= 0 {
+		check.Timeout = DefaultCheckTimeout
+	}
+
+	added := &syntheticCheck{SyntheticCheck: check}
+	if check.ExpectBodyPattern != "" {
+		pattern, err := regexp.Compile(check.ExpectBodyPattern)
+		if err != nil {
+			return fmt.Errorf("synthetic check %s: %v", check.Name, err)
+		}
+		added.pattern = pattern
+	}
+
+	upMetric := "synthetic_up@" + check.Name
+	tags := []string{"synthetic", "check:" + check.Name}
+	if _, err := sm.Jetpack.GetMetric(upMetric); err != nil {
+		sm.Jetpack.RegisterMetric(MetricAvailability, upMetric, "Whether the "+check.Name+" check passes", "up", nil, tags)
+		sm.Jetpack.RegisterMetric(MetricAPILatency, "synthetic_latency@"+check.Name, "Latency of the "+check.Name+" check", "ms", nil, tags)
+	}
+
+	if sm.Jetpack.Alerts != nil {
+		rule := "synthetic_down@" + check.Name
+		if check.AlertAfter > 0 {
+			err := sm.Jetpack.Alerts.AddRule(AlertRule{
+				Name:        rule,
+				Metric:      upMetric,
+				Comparison:  Below,
+				Threshold:   1,
+				For:         check.AlertAfter,
+				Description: check.Name + " is down",
+				Channels:    check.AlertChannels,
+			})
+			if err != nil {
+				return err
+			}
+		} else {
+			sm.Jetpack.Alerts.RemoveRule(rule)
+		}
+	}
+
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	if previous, ok := sm.checks[check.Name]; ok && previous.stop != nil {
+		close(previous.stop)
+	}
+	sm.checks[check.Name] = added
+	if sm.running {
+		sm.schedule(added)
+	}
+	return nil
+}
+
+// RemoveCheck stops and removes a check, and its alert rule
+func (sm *SyntheticMonitor) RemoveCheck(name string) {
+	sm.mutex.Lock()
+	if check, ok := sm.checks[name]; ok && check.stop != nil {
+		close(check.stop)
+	}
+	delete(sm.checks, name)
+	delete(sm.results, name)
+	sm.mutex.Unlock()
+
+	if sm.Jetpack.Alerts != nil {
+		sm.Jetpack.Alerts.RemoveRule("synthetic_down@" + name)
+	}
+}
+
+// Results returns the latest result of every check that has run, by name
+func (sm *SyntheticMonitor) Results() []CheckResult {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	results := make([]CheckResult, 0, len(sm.results))
+	for _, result := range sm.results {
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Check < results[j].Check })
+	return results
+}
+
+// RunCheck runs a check once, records its result and evaluates the alert
+// rules, so downtime alerts fire without waiting for the alert manager
+func (sm *SyntheticMonitor) RunCheck(ctx context.Context, name string) (CheckResult, error) {
+	sm.mutex.Lock()
+	check, ok := sm.checks[name]
+	sm.mutex.Unlock()
+	if !ok {
+		return CheckResult{}, fmt.Errorf("synthetic check %s not found", name)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	status, err := sm.run(ctx, check)
+	latency := time.Since(start)
+	if err == nil && check.MaxLatency > 0 && latency > check.MaxLatency {
+		err = fmt.Errorf("took %s, over %s", latency.Round(time.Millisecond), check.MaxLatency)
+	}
+
+	result := CheckResult{
+		Check:   name,
+		Time:    start,
+		Up:      err == nil,
+		Status:  status,
+		Latency: float64(latency) / float64(time.Millisecond),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+
+	sm.mutex.Lock()
+	if _, ok := sm.checks[name]; !ok {
+		// Removed while it ran
+		sm.mutex.Unlock()
+		return result, nil
+	}
+	if !result.Up {
+		result.DownSince = start
+		if previous, ok := sm.results[name]; ok && !previous.Up {
+			result.DownSince = previous.DownSince
+		}
+	}
+	sm.results[name] = result
+	sm.mutex.Unlock()
+
+	up := 0.0
+	if result.Up {
+		up = 1
+	}
+	sm.Jetpack.RecordMetric("synthetic_up@"+name, up)
+	sm.Jetpack.RecordMetric("synthetic_latency@"+name, result.Latency)
+	if check.AlertAfter > 0 && sm.Jetpack.Alerts != nil {
+		sm.Jetpack.Alerts.Evaluate()
+	}
+	return result, nil
+}
+
+// run makes a check's request and asserts on its response, returning the
+// response's status
+func (sm *SyntheticMonitor) run(ctx context.Context, check *syntheticCheck) (int, error) {
+	if check.Run != nil {
+		return 0, check.Run(ctx)
+	}
+
+	var body io.Reader
+	if check.Body != "" {
+		body = strings.NewReader(check.Body)
+	}
+	req, err := http.NewRequest(check.Method, check.URL, body)
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+	for name, value := range check.Headers {
+		req.Header.Set(name, value)
+	}
+
+	client := sm.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if check.ExpectStatus != 0 && resp.StatusCode != check.ExpectStatus {
+		return resp.StatusCode, fmt.Errorf("status %d, expected %d", resp.StatusCode, check.ExpectStatus)
+	}
+	if check.ExpectStatus == 0 && (resp.StatusCode < 200 || resp.StatusCode >= 400) {
+		return resp.StatusCode, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	if check.ExpectBody == "" && check.pattern == nil {
+		io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxCheckBody))
+		return resp.StatusCode, nil
+	}
+	content, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxCheckBody))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if check.ExpectBody != "" && !strings.Contains(string(content), check.ExpectBody) {
+		return resp.StatusCode, fmt.Errorf("body does not contain %q", check.ExpectBody)
+	}
+	if check.pattern != nil && !check.pattern.Match(content) {
+		return resp.StatusCode, fmt.Errorf("body does not match %q", check.ExpectBodyPattern)
+	}
+	return resp.StatusCode, nil
+}
+
+// Start runs every check right away and then every Interval until Stop is
+// called
+func (sm *SyntheticMonitor) Start() {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	if sm.running {
+		return
+	}
+	sm.running = true
+	for _, check := range sm.checks {
+		sm.schedule(check)
+	}
+}
+
+// Stop stops running the checks
+func (sm *SyntheticMonitor) Stop() {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	sm.running = false
+	for _, check := range sm.checks {
+		if check.stop != nil {
+			close(check.stop)
+			check.stop = nil
+		}
+	}
+}
+
+// schedule runs a check every Interval until its stop channel closes; the
+// caller holds the mutex
+func (sm *SyntheticMonitor) schedule(check *syntheticCheck) {
+	stop := make(chan struct{})
+	check.stop = stop
+
+	go func() {
+		ticker := time.NewTicker(check.Interval)
+		defer ticker.Stop()
+
+		for {
+			if _, err := sm.RunCheck(context.Background(), check.Name); err != nil {
+				return
+			}
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
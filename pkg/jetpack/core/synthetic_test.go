@@ -0,0 +1,146 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyntheticChecks(t *testing.T) {
+	healthy := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			if !healthy {
+				http.Error(w, "database unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`{"status": "ok", "version": "1.4.2"}`))
+		case "/goscale":
+			body, _ := ioutil.ReadAll(r.Body)
+			if r.Method != http.MethodPost || !strings.Contains(string(body), `"operation":"getUser"`) {
+				http.Error(w, "Unknown operation", http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{"data": {"id": 1}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	jp := NewJetpack()
+	now := time.Now()
+	jp.Alerts.now = func() time.Time { return now }
+	var notified []Alert
+	jp.Alerts.AddNotifier("test", NotifierFunc(func(ctx context.Context, alert Alert) error {
+		notified = append(notified, alert)
+		return nil
+	}))
+
+	checks := []SyntheticCheck{
+		{Name: "health", URL: server.URL + "/health", ExpectBody: `"status": "ok"`, AlertAfter: time.Minute},
+		{Name: "version", URL: server.URL + "/health", ExpectBodyPattern: `"version": "1\.\d+`},
+		{Name: "missing", URL: server.URL + "/missing", ExpectStatus: http.StatusOK},
+		{Name: "queue", Run: func(ctx context.Context) error { return errors.New("queue stalled") }},
+	}
+	goscale, err := NewGoScaleCheck("get_user", server.URL+"/goscale", "getUser", map[string]interface{}{"id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks = append(checks, goscale)
+	for _, check := range checks {
+		if err := jp.Synthetic.AddCheck(check); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, check := range checks {
+		if _, err := jp.Synthetic.RunCheck(context.Background(), check.Name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results := jp.Synthetic.Results()
+	up := map[string]bool{}
+	for _, result := range results {
+		up[result.Check] = result.Up
+	}
+	if !up["health"] || !up["version"] || !up["get_user"] || up["missing"] || up["queue"] {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if results[2].Check != "missing" || results[2].Status != http.StatusNotFound || results[2].Error != "status 404, expected 200" {
+		t.Fatalf("unexpected result %+v", results[2])
+	}
+	if latency, _ := jp.GetMetricLatest("synthetic_latency@health"); latency <= 0 {
+		t.Fatalf("expected the latency to be recorded, got %v", latency)
+	}
+
+	// Downtime fires an alert once it lasts AlertAfter
+	healthy = false
+	result, _ := jp.Synthetic.RunCheck(context.Background(), "health")
+	if result.Up || result.Status != http.StatusServiceUnavailable || len(notified) != 0 {
+		t.Fatalf("expected the check to fail without alerting yet, got %+v %v", result, notified)
+	}
+	downSince := result.DownSince
+	now = now.Add(2 * time.Minute)
+	result, _ = jp.Synthetic.RunCheck(context.Background(), "health")
+	if !result.DownSince.Equal(downSince) {
+		t.Fatalf("expected the check to stay down since %v, got %v", downSince, result.DownSince)
+	}
+	if len(notified) != 1 || notified[0].Rule != "synthetic_down@health" || notified[0].State != AlertFiring {
+		t.Fatalf("expected a downtime alert, got %+v", notified)
+	}
+
+	healthy = true
+	jp.Synthetic.RunCheck(context.Background(), "health")
+	if len(notified) != 2 || notified[1].State != AlertResolved {
+		t.Fatalf("expected the alert to resolve, got %+v", notified)
+	}
+
+	jp.Synthetic.RemoveCheck("health")
+	if _, err := jp.Synthetic.RunCheck(context.Background(), "health"); err == nil {
+		t.Fatalf("expected the removed check to be gone")
+	}
+	for _, rule := range jp.Alerts.Rules() {
+		if rule.Name == "synthetic_down@health" {
+			t.Fatalf("expected the check's alert rule to be removed")
+		}
+	}
+}
+
+func TestSyntheticCheckValidation(t *testing.T) {
+	jp := NewJetpack()
+	for _, check := range []SyntheticCheck{
+		{URL: "http://localhost/"},
+		{Name: "relative", URL: "/health"},
+		{Name: "pattern", URL: "http://localhost/", ExpectBodyPattern: "("},
+	} {
+		if err := jp.Synthetic.AddCheck(check); err == nil {
+			t.Fatalf("expected %+v to be refused", check)
+		}
+	}
+}
+
+func TestSyntheticMonitorSchedule(t *testing.T) {
+	jp := NewJetpack()
+	runs := make(chan struct{}, 10)
+	jp.Synthetic.AddCheck(SyntheticCheck{Name: "tick", Interval: 10 * time.Millisecond, Run: func(ctx context.Context) error {
+		runs <- struct{}{}
+		return nil
+	}})
+
+	jp.Synthetic.Start()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-runs:
+		case <-time.After(time.Second):
+			t.Fatalf("expected the check to run every interval")
+		}
+	}
+	jp.Synthetic.Stop()
+}
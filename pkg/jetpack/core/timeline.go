@@ -0,0 +1,392 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript/reqctx"
+)
+
+// DefaultTimelineEndpoint is the path the timeline viewer is served at by
+// default
+const DefaultTimelineEndpoint = "/_jetpack/timeline"
+
+// requestIDHeader is the header goscript.RequestID sets to the ID of each
+// request
+const requestIDHeader = reqctx.RequestIDHeader
+
+const (
+	// maxTimelineEvents caps the events kept from one page view
+	maxTimelineEvents = 200
+
+	// maxRequestSpans caps the spans kept for one request
+	maxRequestSpans = 100
+
+	// maxTimelineDetail caps the length of an event's detail
+	maxTimelineDetail = 200
+)
+
+// Kinds of frontend events a timeline records
+const (
+	TimelineNavigation = "navigation"
+	TimelineClick      = "click"
+	TimelineError      = "error"
+	TimelineLongTask   = "longtask"
+	TimelineRequest    = "request"
+)
+
+var timelineEventTypes = map[string]bool{
+	TimelineNavigation: true,
+	TimelineClick:      true,
+	TimelineError:      true,
+	TimelineLongTask:   true,
+	TimelineRequest:    true,
+}
+
+// Span is a timed operation on the backend: the handling of a request, or
+// work done for it such as a query
+type Span struct {
+	RequestID  string            `json:"request_id"`
+	Name       string            `json:"name"`
+	Start      time.Time         `json:"start"`
+	Duration   float64           `json:"duration"` // in ms
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// TimelineEvent is something that happened in a page, such as a click or a
+// request it made
+type TimelineEvent struct {
+	Type string `json:"type"`
+
+	// Time is when the event happened, in ms since the page's time origin,
+	// and Duration how long it lasted
+	Time     float64 `json:"time"`
+	Duration float64 `json:"duration,omitempty"`
+
+	// Detail describes the event: the path navigated to or requested, the
+	// element clicked or the error message
+	Detail string `json:"detail,omitempty"`
+
+	// RequestID is the ID of the backend request a navigation or request
+	// event made, when the backend sent one
+	RequestID string `json:"request_id,omitempty"`
+
+	// Spans are the backend spans of RequestID, filled in by the viewer
+	Spans []Span `json:"spans,omitempty"`
+}
+
+// Timeline is the events of one page view, as sent with its RUM beacon
+type Timeline struct {
+	// RequestID is the ID of the request that loaded the page
+	RequestID string `json:"request_id,omitempty"`
+	Page      string `json:"page"`
+
+	// TimeOrigin is when the page started loading, in ms since the Unix
+	// epoch by the browser's clock
+	TimeOrigin float64         `json:"time_origin"`
+	Received   time.Time       `json:"received"`
+	Events     []TimelineEvent `json:"events"`
+}
+
+// TimelineView correlates a request with the page view it belongs to: the
+// page's timeline, its events' backend spans attached
+type TimelineView struct {
+	RequestID string `json:"request_id"`
+
+	// Spans are the backend spans of the request itself
+	Spans []Span `json:"spans"`
+
+	// Timeline is the page view that loaded or made the request, if its
+	// beacon arrived
+	Timeline *Timeline `json:"timeline,omitempty"`
+}
+
+// TimelineStore keeps the backend spans of recent requests and the
+// timelines of recent page views, for the viewer to correlate
+type TimelineStore struct {
+	// MaxRequests caps the requests whose spans are kept, and MaxTimelines
+	// the page views; the oldest are dropped first
+	MaxRequests  int
+	MaxTimelines int
+
+	mutex     sync.Mutex
+	spans     map[string][]Span
+	requests  []string
+	timelines []*Timeline
+}
+
+// NewTimelineStore creates a store keeping the last 1000 requests and 200
+// page views
+func NewTimelineStore() *TimelineStore {
+	return &TimelineStore{
+		MaxRequests:  1000,
+		MaxTimelines: 200,
+		spans:        make(map[string][]Span),
+	}
+}
+
+// addSpans keeps spans for a request
+func (s *TimelineStore) addSpans(requestID string, spans []Span) {
+	if requestID == "" || len(spans) == 0 {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.spans == nil {
+		s.spans = make(map[string][]Span)
+	}
+	if _, ok := s.spans[requestID]; !ok {
+		s.requests = append(s.requests, requestID)
+		for len(s.requests) > s.MaxRequests {
+			delete(s.spans, s.requests[0])
+			s.requests = s.requests[1:]
+		}
+	}
+	kept := append(s.spans[requestID], spans...)
+	if len(kept) > maxRequestSpans {
+		kept = kept[:maxRequestSpans]
+	}
+	s.spans[requestID] = kept
+}
+
+// addTimeline keeps a page view's timeline
+func (s *TimelineStore) addTimeline(timeline *Timeline) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.timelines = append(s.timelines, timeline)
+	if over := len(s.timelines) - s.MaxTimelines; over > 0 {
+		s.timelines = append(s.timelines[:0], s.timelines[over:]...)
+	}
+}
+
+// View returns the spans of a request and the timeline of the page view
+// that loaded or made it, or nil when neither is known
+func (s *TimelineStore) View(requestID string) *TimelineView {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	view := &TimelineView{RequestID: requestID, Spans: s.spansOf(requestID)}
+	for i := len(s.timelines) - 1; i >= 0 && view.Timeline == nil; i-- {
+		if s.timelines[i].includes(requestID) {
+			view.Timeline = s.correlate(s.timelines[i])
+		}
+	}
+	if view.Timeline == nil && len(view.Spans) == 0 {
+		return nil
+	}
+	return view
+}
+
+// Latest returns the timeline of the last page view whose beacon arrived,
+// its events' spans attached, or nil before any
+func (s *TimelineStore) Latest() *Timeline {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(s.timelines) == 0 {
+		return nil
+	}
+	return s.correlate(s.timelines[len(s.timelines)-1])
+}
+
+// spansOf copies the spans of a request
+func (s *TimelineStore) spansOf(requestID string) []Span {
+	return append([]Span{}, s.spans[requestID]...)
+}
+
+// correlate copies a timeline, attaching to each event the spans of the
+// request it made
+func (s *TimelineStore) correlate(timeline *Timeline) *Timeline {
+	correlated := *timeline
+	correlated.Events = make([]TimelineEvent, len(timeline.Events))
+	for i, event := range timeline.Events {
+		if event.RequestID != "" {
+			event.Spans = s.spansOf(event.RequestID)
+		}
+		correlated.Events[i] = event
+	}
+	return &correlated
+}
+
+// includes reports whether the page view loaded or made a request
+func (t *Timeline) includes(requestID string) bool {
+	if t.RequestID == requestID {
+		return true
+	}
+	for _, event := range t.Events {
+		if event.RequestID == requestID {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeHTTP serves the view of the request given by the request_id query
+// parameter as JSON
+func (s *TimelineStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	requestID := r.URL.Query().Get("request_id")
+	if requestID == "" {
+		http.Error(w, "request_id is required", http.StatusBadRequest)
+		return
+	}
+	view := s.View(requestID)
+	if view == nil {
+		http.Error(w, "no spans or timeline for request "+requestID, http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(view)
+}
+
+// newTimeline builds a timeline from a beacon, keeping at most
+// maxTimelineEvents of the events it knows, or returns nil if none are left
+func newTimeline(beacon RUMBeacon) *Timeline {
+	events := make([]TimelineEvent, 0, len(beacon.Timeline))
+	for _, event := range beacon.Timeline {
+		if len(events) == maxTimelineEvents {
+			break
+		}
+		if !timelineEventTypes[event.Type] || !validMeasurement(event.Time) || !validMeasurement(event.Duration) {
+			continue
+		}
+		if len(event.Detail) > maxTimelineDetail {
+			event.Detail = event.Detail[:maxTimelineDetail]
+		}
+		if !validTimingID(event.RequestID) {
+			event.RequestID = ""
+		}
+		event.Spans = nil
+		events = append(events, event)
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	requestID := beacon.RequestID
+	if !validTimingID(requestID) {
+		requestID = ""
+	}
+	page := beacon.Page
+	if len(page) > maxTimelineDetail {
+		page = page[:maxTimelineDetail]
+	}
+	return &Timeline{
+		RequestID:  requestID,
+		Page:       page,
+		TimeOrigin: beacon.TimeOrigin,
+		Received:   time.Now(),
+		Events:     events,
+	}
+}
+
+// validTimingID accepts request IDs of letters, digits, dots, dashes and
+// underscores, which can be quoted in a Server-Timing header as they are
+func validTimingID(id string) bool {
+	if len(id) > 128 {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// serverTiming tells the browser the ID of the request, which the RUM
+// script reads from the navigation and resource timing entries
+func serverTiming(header http.Header) {
+	if id := header.Get(requestIDHeader); id != "" && validTimingID(id) {
+		header.Add("Server-Timing", `jetpack;desc="`+id+`"`)
+	}
+}
+
+type traceKey struct{}
+
+// requestTrace collects the spans a request's handlers start
+type requestTrace struct {
+	mutex sync.Mutex
+	spans []Span
+}
+
+// ActiveSpan is a span that has started but not ended
+type ActiveSpan struct {
+	trace *requestTrace
+	span  Span
+}
+
+// StartSpan starts a span of the request ctx belongs to, such as a query it
+// runs; End it when the work is done. HTTPMiddleware keeps the request's
+// spans under its request ID for the timeline viewer. Outside the
+// middleware the span is not kept.
+func StartSpan(ctx context.Context, name string) *ActiveSpan {
+	trace, _ := ctx.Value(traceKey{}).(*requestTrace)
+	return &ActiveSpan{trace: trace, span: Span{Name: name, Start: time.Now()}}
+}
+
+// SetAttribute describes the span, such as with the table it queried
+func (s *ActiveSpan) SetAttribute(key, value string) {
+	if s.span.Attributes == nil {
+		s.span.Attributes = make(map[string]string)
+	}
+	s.span.Attributes[key] = value
+}
+
+// SetError marks the span as failed with err, if err is not nil
+func (s *ActiveSpan) SetError(err error) {
+	if err != nil {
+		s.span.Error = err.Error()
+	}
+}
+
+// End ends the span
+func (s *ActiveSpan) End() {
+	if s.trace == nil {
+		return
+	}
+	s.span.Duration = float64(time.Since(s.span.Start)) / float64(time.Millisecond)
+
+	s.trace.mutex.Lock()
+	defer s.trace.mutex.Unlock()
+
+	if len(s.trace.spans) < maxRequestSpans {
+		s.trace.spans = append(s.trace.spans, s.span)
+	}
+	s.trace = nil
+}
+
+// record keeps the spans of a handled request under its ID, the request's
+// own span first
+func (t *requestTrace) record(store *TimelineStore, requestID string, request Span) {
+	t.mutex.Lock()
+	spans := append([]Span{request}, t.spans...)
+	t.mutex.Unlock()
+
+	for i := range spans {
+		spans[i].RequestID = requestID
+	}
+	store.addSpans(requestID, spans)
+}
+
+// describeRequest names a request's span by its method and route, or path
+func describeRequest(r *http.Request, route string) string {
+	if route == "" {
+		route = r.URL.Path
+	}
+	return strings.TrimSpace(r.Method + " " + route)
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTimelineCorrelatesSpans(t *testing.T) {
+	jp := NewJetpack()
+	handler := jp.HTTPMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// What goscript.RequestID does
+		w.Header().Set("X-Request-ID", r.Header.Get("X-Request-ID"))
+		SetRoute(r, "/api/posts")
+
+		span := StartSpan(r.Context(), "db.query")
+		span.SetAttribute("table", "posts")
+		span.SetError(errors.New("timeout"))
+		span.End()
+		span.End()
+
+		w.Write([]byte("ok"))
+	}))
+
+	for _, id := range []string{"page-1", "fetch-1", `bad"id`} {
+		r := httptest.NewRequest(http.MethodGet, "/api/posts", nil)
+		r.Header.Set("X-Request-ID", id)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		timing := w.Header().Get("Server-Timing")
+		if id == `bad"id` && timing != "" {
+			t.Fatalf("expected no Server-Timing for an ID that cannot be quoted, got %s", timing)
+		} else if id != `bad"id` && timing != `jetpack;desc="`+id+`"` {
+			t.Fatalf("unexpected Server-Timing %q", timing)
+		}
+	}
+
+	rum := NewRUM(jp, "")
+	beacon := `{"page": "/posts", "request_id": "page-1", "time_origin": 1700000000000, "timeline": [
+		{"type": "navigation", "time": 0, "duration": 300, "detail": "/posts", "request_id": "page-1"},
+		{"type": "click", "time": 1200, "detail": "button#load-more"},
+		{"type": "request", "time": 1210, "duration": 80, "detail": "/api/posts", "request_id": "fetch-1",
+			"spans": [{"name": "forged"}]},
+		{"type": "longtask", "time": 1400, "duration": 120},
+		{"type": "error", "time": 1500, "detail": "TypeError: x is undefined"},
+		{"type": "keypress", "time": 1600},
+		{"type": "click", "time": -5}
+	]}`
+	w := httptest.NewRecorder()
+	rum.ServeHTTP(w, httptest.NewRequest(http.MethodPost, rum.Endpoint, strings.NewReader(beacon)))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+
+	// The page's fetch leads to the page view, with the spans of its requests
+	w = httptest.NewRecorder()
+	jp.Timelines.ServeHTTP(w, httptest.NewRequest(http.MethodGet, DefaultTimelineEndpoint+"?request_id=fetch-1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var view TimelineView
+	if err := json.NewDecoder(w.Body).Decode(&view); err != nil {
+		t.Fatal(err)
+	}
+	if len(view.Spans) != 2 || view.Spans[0].Name != "GET /api/posts" || view.Spans[0].Attributes["status"] != "200" {
+		t.Fatalf("unexpected spans %+v", view.Spans)
+	}
+	if query := view.Spans[1]; query.Name != "db.query" || query.Attributes["table"] != "posts" || query.Error != "timeout" || query.RequestID != "fetch-1" {
+		t.Fatalf("unexpected query span %+v", query)
+	}
+	if view.Timeline == nil || view.Timeline.RequestID != "page-1" || view.Timeline.Page != "/posts" || len(view.Timeline.Events) != 5 {
+		t.Fatalf("unexpected timeline %+v", view.Timeline)
+	}
+	events := view.Timeline.Events
+	if len(events[0].Spans) != 2 || events[0].Spans[0].RequestID != "page-1" || len(events[1].Spans) != 0 {
+		t.Fatalf("expected the navigation's spans to be attached, got %+v", events[:2])
+	}
+	if len(events[2].Spans) != 2 || events[2].Spans[0].Name == "forged" {
+		t.Fatalf("expected the request's own spans to be attached, got %+v", events[2].Spans)
+	}
+
+	for query, status := range map[string]int{"": http.StatusBadRequest, "?request_id=unknown": http.StatusNotFound} {
+		w := httptest.NewRecorder()
+		jp.Timelines.ServeHTTP(w, httptest.NewRequest(http.MethodGet, DefaultTimelineEndpoint+query, nil))
+		if w.Code != status {
+			t.Fatalf("expected %d for %q, got %d", status, query, w.Code)
+		}
+	}
+}
+
+func TestTimelineStoreLimits(t *testing.T) {
+	store := NewTimelineStore()
+	store.MaxRequests = 2
+	store.MaxTimelines = 1
+	if store.Latest() != nil {
+		t.Fatalf("expected no latest timeline before any beacon")
+	}
+
+	for _, id := range []string{"a", "b", "c"} {
+		store.addSpans(id, []Span{{RequestID: id, Name: "GET /"}})
+	}
+	if store.View("a") != nil || store.View("c") == nil {
+		t.Fatalf("expected the oldest request's spans to be dropped")
+	}
+
+	store.addTimeline(&Timeline{RequestID: "a", Events: []TimelineEvent{{Type: TimelineNavigation}}})
+	store.addTimeline(&Timeline{RequestID: "b", Events: []TimelineEvent{{Type: TimelineNavigation}}})
+	if view := store.View("b"); view == nil || view.Timeline == nil {
+		t.Fatalf("expected the newest timeline to be kept")
+	}
+	if store.View("a") != nil {
+		t.Fatalf("expected the oldest timeline to be dropped")
+	}
+	if latest := store.Latest(); latest == nil || latest.RequestID != "b" || len(latest.Events) != 1 {
+		t.Fatalf("unexpected latest timeline %+v", latest)
+	}
+
+	// Spans started outside the middleware go nowhere
+	span := StartSpan(httptest.NewRequest(http.MethodGet, "/", nil).Context(), "orphan")
+	span.End()
+}
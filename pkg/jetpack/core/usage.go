@@ -0,0 +1,198 @@
+package core
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxUsageKeys caps the operations, and separately the consumers, recorded
+// by RecordOperation; calls of later ones are dropped
+const maxUsageKeys = 200
+
+const (
+	// Prefixes of the metrics RecordOperation records per operation and per
+	// consumer, each followed by "@" and the operation or consumer
+	operationMetricPrefix = "operation_"
+	consumerMetricPrefix  = "consumer_"
+)
+
+// OperationCall is one call of an API operation, such as a GoScale API
+// query, as RecordOperation records it
+type OperationCall struct {
+	Operation string
+
+	// Consumer identifies the caller, such as by its API key; calls
+	// without one are only recorded per operation
+	Consumer string
+
+	Duration     time.Duration
+	RequestSize  int64
+	ResponseSize int64
+	Failed       bool
+}
+
+// UsageStats summarizes the calls of an operation, or by a consumer, over
+// a window
+type UsageStats struct {
+	Name      string  `json:"name"`
+	Calls     int     `json:"calls"`
+	Errors    int     `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+
+	// Latencies are in ms
+	MeanLatency float64 `json:"mean_latency"`
+	P95Latency  float64 `json:"p95_latency"`
+	MaxLatency  float64 `json:"max_latency"`
+
+	// Sizes are the mean bytes of a request and a response body
+	RequestSize  float64 `json:"request_size"`
+	ResponseSize float64 `json:"response_size"`
+}
+
+// usageKeys remembers the operations and consumers recorded so far
+type usageKeys struct {
+	mutex sync.Mutex
+	keys  map[string]bool
+}
+
+// RecordOperation records a call as Jetpack metrics, both for its
+// operation, such as "operation_duration@query:user", and for its consumer,
+// such as "consumer_duration@key_1a2b3c". Each has a duration in ms, an
+// errors metric that is 1 for failed calls and 0 otherwise, and request and
+// response sizes in bytes. Like every metric, they are persisted by the
+// History, so OperationUsage can look back past MetricRetention.
+func (jp *Jetpack) RecordOperation(call OperationCall) {
+	if call.Operation != "" && jp.usageKey(operationMetricPrefix, call.Operation) {
+		jp.recordUsage(operationMetricPrefix, call.Operation, "operation:"+call.Operation, call)
+	}
+	if call.Consumer != "" && jp.usageKey(consumerMetricPrefix, call.Consumer) {
+		jp.recordUsage(consumerMetricPrefix, call.Consumer, "consumer:"+call.Consumer, call)
+	}
+}
+
+// usageKey reports whether an operation or consumer is recorded, which it
+// is unless maxUsageKeys others are
+func (jp *Jetpack) usageKey(prefix, name string) bool {
+	jp.usage.mutex.Lock()
+	defer jp.usage.mutex.Unlock()
+
+	if jp.usage.keys == nil {
+		jp.usage.keys = make(map[string]bool)
+	}
+	key := prefix + name
+	if !jp.usage.keys[key] {
+		count := 0
+		for other := range jp.usage.keys {
+			if strings.HasPrefix(other, prefix) {
+				count++
+			}
+		}
+		if count >= maxUsageKeys {
+			return false
+		}
+		jp.usage.keys[key] = true
+	}
+	return true
+}
+
+// recordUsage records a call's metrics under one prefix and name
+func (jp *Jetpack) recordUsage(prefix, name, tag string, call OperationCall) {
+	failed := 0.0
+	if call.Failed {
+		failed = 1
+	}
+
+	suffix := "@" + name
+	tags := []string{"api", tag}
+	jp.recordUsageValue(MetricAPILatency, prefix+"duration"+suffix, "API call duration", "ms", tags, float64(call.Duration)/float64(time.Millisecond))
+	jp.recordUsageValue(MetricErrorRate, prefix+"errors"+suffix, "API calls that failed", "errors", tags, failed)
+	jp.recordUsageValue(MetricRequestSize, prefix+"request_size"+suffix, "API request body size", "bytes", tags, float64(call.RequestSize))
+	jp.recordUsageValue(MetricResponseSize, prefix+"response_size"+suffix, "API response body size", "bytes", tags, float64(call.ResponseSize))
+}
+
+// recordUsageValue records a value, registering its metric the first time
+// it is seen
+func (jp *Jetpack) recordUsageValue(metricType MetricType, name, description, unit string, tags []string, value float64) {
+	jp.usage.mutex.Lock()
+	if _, err := jp.GetMetric(name); err != nil {
+		jp.RegisterMetric(metricType, name, description, unit, nil, tags)
+	}
+	jp.usage.mutex.Unlock()
+
+	jp.RecordMetric(name, value)
+}
+
+// OperationUsage summarizes the calls of each operation recorded by
+// RecordOperation over the window ending now, busiest first. It reads the
+// History when there is one, as GetMetricStatsBetween does.
+func (jp *Jetpack) OperationUsage(ctx context.Context, window time.Duration) ([]UsageStats, error) {
+	return jp.usageStats(ctx, operationMetricPrefix, window)
+}
+
+// ConsumerUsage summarizes the calls by each consumer recorded by
+// RecordOperation over the window ending now, busiest first
+func (jp *Jetpack) ConsumerUsage(ctx context.Context, window time.Duration) ([]UsageStats, error) {
+	return jp.usageStats(ctx, consumerMetricPrefix, window)
+}
+
+// usageStats summarizes the names recorded under a prefix
+func (jp *Jetpack) usageStats(ctx context.Context, prefix string, window time.Duration) ([]UsageStats, error) {
+	now := time.Now()
+	durationPrefix := prefix + "duration@"
+
+	usage := []UsageStats{}
+	for _, metric := range jp.MetricNames() {
+		if !strings.HasPrefix(metric, durationPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(metric, durationPrefix)
+		suffix := "@" + name
+
+		duration, err := jp.GetMetricStatsBetween(ctx, metric, now, window)
+		if err != nil {
+			return nil, err
+		}
+		if duration.Count == 0 {
+			continue
+		}
+		stats := UsageStats{
+			Name:        name,
+			Calls:       duration.Count,
+			MeanLatency: duration.Mean,
+			P95Latency:  duration.P95,
+			MaxLatency:  duration.Max,
+		}
+		if errors, err := jp.GetMetricStatsBetween(ctx, prefix+"errors"+suffix, now, window); err == nil {
+			stats.ErrorRate = errors.Mean
+			stats.Errors = int(math.Round(errors.Mean * float64(errors.Count)))
+		}
+		if size, err := jp.GetMetricStatsBetween(ctx, prefix+"request_size"+suffix, now, window); err == nil {
+			stats.RequestSize = size.Mean
+		}
+		if size, err := jp.GetMetricStatsBetween(ctx, prefix+"response_size"+suffix, now, window); err == nil {
+			stats.ResponseSize = size.Mean
+		}
+		usage = append(usage, stats)
+	}
+
+	sort.SliceStable(usage, func(i, j int) bool {
+		if usage[i].Calls != usage[j].Calls {
+			return usage[i].Calls > usage[j].Calls
+		}
+		return usage[i].Name < usage[j].Name
+	})
+	return usage, nil
+}
+
+// SlowestUsage returns a copy of usage sorted by p95 latency, slowest first
+func SlowestUsage(usage []UsageStats) []UsageStats {
+	slowest := append([]UsageStats(nil), usage...)
+	sort.SliceStable(slowest, func(i, j int) bool {
+		return slowest[i].P95Latency > slowest[j].P95Latency
+	})
+	return slowest
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestOperationUsage(t *testing.T) {
+	jp := NewJetpack()
+	calls := []OperationCall{
+		{Operation: "query:user", Consumer: "key_a", Duration: 10 * time.Millisecond, RequestSize: 40, ResponseSize: 100},
+		{Operation: "query:user", Consumer: "key_b", Duration: 30 * time.Millisecond, RequestSize: 60, ResponseSize: 300},
+		{Operation: "query:user", Duration: 20 * time.Millisecond, Failed: true},
+		{Operation: "mutation:createPost", Consumer: "key_a", Duration: 200 * time.Millisecond},
+	}
+	for _, call := range calls {
+		jp.RecordOperation(call)
+	}
+
+	operations, err := jp.OperationUsage(context.Background(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(operations) != 2 || operations[0].Name != "query:user" || operations[0].Calls != 3 {
+		t.Fatalf("expected the busiest operation first, got %+v", operations)
+	}
+	user := operations[0]
+	if user.Errors != 1 || user.ErrorRate < 0.33 || user.ErrorRate > 0.34 {
+		t.Fatalf("unexpected errors %+v", user)
+	}
+	if user.MeanLatency != 20 || user.MaxLatency != 30 || user.RequestSize != 100.0/3 {
+		t.Fatalf("unexpected latencies or sizes %+v", user)
+	}
+	if slowest := SlowestUsage(operations); slowest[0].Name != "mutation:createPost" || operations[0].Name != "query:user" {
+		t.Fatalf("expected the slowest operation first in a copy, got %+v", slowest)
+	}
+
+	consumers, err := jp.ConsumerUsage(context.Background(), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(consumers) != 2 || consumers[0].Name != "key_a" || consumers[0].Calls != 2 || consumers[1].Calls != 1 {
+		t.Fatalf("unexpected consumers %+v", consumers)
+	}
+}
+
+func TestOperationUsageCapsKeys(t *testing.T) {
+	jp := NewJetpack()
+	for i := 0; i < maxUsageKeys+10; i++ {
+		jp.RecordOperation(OperationCall{Operation: "query:op" + string(rune('a'+i%26)) + string(rune('a'+i/26))})
+	}
+	operations, _ := jp.OperationUsage(context.Background(), 0)
+	if len(operations) != maxUsageKeys {
+		t.Fatalf("expected %d operations, got %d", maxUsageKeys, len(operations))
+	}
+}
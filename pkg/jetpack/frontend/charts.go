@@ -0,0 +1,83 @@
+package frontend
+
+import (
+	"html/template"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/gocsx/charts"
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+// chartWidth and chartHeight size the charts to the extension's cards
+const (
+	chartWidth  = 760
+	chartHeight = 300
+)
+
+// chartTheme returns the charts' theme for the panel's
+func (pp *PerformancePanel) chartTheme() charts.Theme {
+	if pp.Config.Theme == "dark" {
+		return charts.DarkTheme()
+	}
+	return charts.LightTheme()
+}
+
+// performanceChart charts the last hour of the selected metrics, from the
+// history selectedMetricsData gets for them
+func (pp *PerformancePanel) performanceChart(metrics []map[string]interface{}) (template.HTML, error) {
+	chart := charts.New(charts.Line, chartWidth, chartHeight)
+	chart.Theme = pp.chartTheme()
+
+	units := make(map[string]bool)
+	for _, metric := range metrics {
+		name, _ := metric["name"].(string)
+		unit, _ := metric["unit"].(string)
+		history, _ := metric["history"].([]core.MetricValue)
+
+		series := charts.Series{Name: name}
+		for _, value := range history {
+			series.Points = append(series.Points, charts.Point{Time: value.Timestamp, Value: value.Value})
+		}
+		chart.Series = append(chart.Series, series)
+		units[unit] = true
+	}
+
+	// Tooltips show the unit when the metrics share one
+	if len(units) == 1 {
+		for unit := range units {
+			chart.Unit = unit
+		}
+	}
+	return chart.HTML()
+}
+
+// waterfallChart charts the navigation and requests of the last page view
+// whose RUM beacon arrived, a bar for each in the order they started
+func (pp *PerformancePanel) waterfallChart() (template.HTML, error) {
+	chart := charts.New(charts.Bar, chartWidth, chartHeight)
+	chart.Theme = pp.chartTheme()
+	chart.Unit = "ms"
+
+	var timeline *core.Timeline
+	if pp.Jetpack.Timelines != nil {
+		timeline = pp.Jetpack.Timelines.Latest()
+	}
+	if timeline != nil {
+		chart.Title = "Requests of " + timeline.Page
+		origin := time.Unix(0, int64(timeline.TimeOrigin*float64(time.Millisecond)))
+
+		var series charts.Series
+		for _, event := range timeline.Events {
+			if event.Type != core.TimelineNavigation && event.Type != core.TimelineRequest {
+				continue
+			}
+			series.Points = append(series.Points, charts.Point{
+				Time:  origin.Add(time.Duration(event.Time * float64(time.Millisecond))),
+				Value: event.Duration,
+				Label: event.Detail,
+			})
+		}
+		chart.Series = []charts.Series{series}
+	}
+	return chart.HTML()
+}
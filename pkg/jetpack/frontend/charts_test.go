@@ -0,0 +1,59 @@
+package frontend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+func TestExtensionCharts(t *testing.T) {
+	jp := core.NewJetpack()
+	jp.RegisterMetric(core.MetricFPS, "fps", "Frames per second", "fps", nil, nil)
+	jp.RecordMetric("fps", 58)
+
+	rum := core.NewRUM(jp, "")
+	beacon := `{"page": "/posts", "time_origin": 1700000000000, "timeline": [
+		{"type": "navigation", "time": 0, "duration": 300, "detail": "/posts"},
+		{"type": "click", "time": 1200, "detail": "button#load-more"},
+		{"type": "request", "time": 1210, "duration": 80, "detail": "/api/posts"}
+	]}`
+	rum.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, rum.Endpoint, strings.NewReader(beacon)))
+
+	panel := NewPerformancePanel(jp)
+	html, err := panel.GenerateExtensionHTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(html, `<canvas class="gocsx-chart"`) != 2 || strings.Contains(html, "will be displayed here") {
+		t.Fatalf("expected both charts in place of the placeholders")
+	}
+	for _, want := range []string{
+		`aria-label="Requests of /posts"`,
+		`/api/posts · 80 ms`, // a request's tooltip
+		`fps · `,             // the metric's history
+		"window.gocsxCanvas", // the runtime that draws them
+	} {
+		if !strings.Contains(html, want) {
+			t.Fatalf("expected the panel to contain %q", want)
+		}
+	}
+	if strings.Contains(html, "button#load-more") {
+		t.Fatalf("expected only the navigation and requests in the waterfall")
+	}
+
+	files, err := panel.GenerateExtension(ExtensionOptions{FeedURL: "ws://localhost:8080/jetpack/api/ws"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(files["panel.js"]), "data-gocsx-tooltips") {
+		t.Fatalf("expected panel.js to draw the charts")
+	}
+
+	panel.Config.ShowCharts = false
+	if html, err := panel.GenerateExtensionHTML(); err != nil || strings.Contains(html, "<canvas") || !strings.Contains(html, "Charts are turned off") {
+		t.Fatalf("expected no charts when they are turned off, got %v", err)
+	}
+}
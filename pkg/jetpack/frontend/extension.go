@@ -0,0 +1,248 @@
+package frontend
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/davidjeba/goscript/pkg/gocsx/charts"
+)
+
+// extensionVersion matches the versions Chrome accepts: one to four
+// dot-separated numbers
+var extensionVersion = regexp.MustCompile(`^[0-9]{1,5}(\.[0-9]{1,5}){0,3}$`)
+
+// ExtensionOptions configure the Chrome extension GenerateExtension builds
+type ExtensionOptions struct {
+	Name    string
+	Version string
+
+	// FeedURL is the WebSocket feed of the running app's PanelAPI, such as
+	// "ws://localhost:8080/jetpack/api/ws"
+	FeedURL string
+}
+
+// GenerateExtension builds a Manifest V3 Chrome extension that adds a
+// Jetpack panel to DevTools. The panel shows the metrics as they are now,
+// and its background service worker streams live values to it from the
+// app's feed while it is open. It returns the extension's files by name;
+// WriteExtension writes them to a directory to load unpacked.
+func (pp *PerformancePanel) GenerateExtension(options ExtensionOptions) (map[string][]byte, error) {
+	if options.Name == "" {
+		options.Name = "Jetpack Performance Monitor"
+	}
+	if options.Version == "" {
+		options.Version = "1.0.0"
+	}
+	if !extensionVersion.MatchString(options.Version) {
+		return nil, fmt.Errorf("invalid extension version %q", options.Version)
+	}
+
+	feed, err := url.Parse(options.FeedURL)
+	if err != nil || (feed.Scheme != "ws" && feed.Scheme != "wss") || feed.Host == "" {
+		return nil, fmt.Errorf("invalid feed URL %q: it must be a ws:// or wss:// URL", options.FeedURL)
+	}
+	origin := "http://" + feed.Host + "/*"
+	if feed.Scheme == "wss" {
+		origin = "https://" + feed.Host + "/*"
+	}
+
+	manifest, err := json.MarshalIndent(map[string]interface{}{
+		"manifest_version": 3,
+		"name":             options.Name,
+		"version":          options.Version,
+		"description":      "Live performance metrics from a running GoScript app",
+		"devtools_page":    "devtools.html",
+		"background":       map[string]string{"service_worker": "background.js"},
+		"host_permissions": []string{origin},
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	panel, err := pp.extensionHTML(true)
+	if err != nil {
+		return nil, err
+	}
+
+	feedURL, err := json.Marshal(feed.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string][]byte{
+		"manifest.json": append(manifest, '\n'),
+		"background.js": []byte(strings.Replace(extensionBackgroundScript, "__FEED_URL__", string(feedURL), 1)),
+		"devtools.html": []byte(extensionDevtoolsHTML),
+		"devtools.js":   []byte(extensionDevtoolsScript),
+		"panel.html":    []byte(panel),
+		"panel.js":      []byte(extensionPanelScript + extensionLiveScript),
+	}, nil
+}
+
+// WriteExtension writes the extension GenerateExtension builds to dir,
+// creating it if needed
+func (pp *PerformancePanel) WriteExtension(dir string, options ExtensionOptions) error {
+	files, err := pp.GenerateExtension(options)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extensionPanelScript is the panel's script but for its live values: its
+// tabs and filter, and the runtime that draws its charts
+const extensionPanelScript = extensionScript + charts.Runtime
+
+// extensionScript switches the extension's tabs and filters its metrics
+const extensionScript = `// Tab switching
+document.querySelectorAll('.tab').forEach(tab => {
+	tab.addEventListener('click', () => {
+		const tabId = tab.getAttribute('data-tab');
+		
+		// Update active tab
+		document.querySelectorAll('.tab').forEach(t => t.classList.remove('active'));
+		tab.classList.add('active');
+		
+		// Update active content
+		document.querySelectorAll('.tab-content').forEach(content => content.classList.remove('active'));
+		document.getElementById(tabId + '-tab').classList.add('active');
+	});
+});
+
+// Metrics filtering
+const metricsFilter = document.getElementById('metrics-filter');
+if (metricsFilter) {
+	metricsFilter.addEventListener('input', (e) => {
+		const filter = e.target.value.toLowerCase();
+		const metricRows = document.querySelectorAll('.metric-row');
+		
+		metricRows.forEach(row => {
+			const name = row.querySelector('td:first-child').textContent.toLowerCase();
+			if (name.includes(filter)) {
+				row.style.display = 'table-row';
+			} else {
+				row.style.display = 'none';
+			}
+		});
+	});
+}
+`
+
+// extensionLiveScript shows the live values the background service worker
+// relays to the panel
+const extensionLiveScript = `
+// Live values from the running app, relayed by the background service worker
+const port = chrome.runtime.connect({ name: 'jetpack-panel' });
+
+function showMetric(metric) {
+	const value = metric.latest_value === undefined ? '' : metric.latest_value;
+	let item = document.querySelector('.metric-item[data-metric="' + CSS.escape(metric.name) + '"]');
+	if (!item) {
+		item = document.createElement('div');
+		item.className = 'metric-item';
+		item.setAttribute('data-metric', metric.name);
+		['metric-name', 'metric-value', 'metric-description'].forEach(className => {
+			const element = document.createElement('div');
+			element.className = className;
+			item.appendChild(element);
+		});
+		item.querySelector('.metric-name').textContent = metric.name;
+		item.querySelector('.metric-description').textContent = metric.description;
+		document.querySelector('.metrics-grid').appendChild(item);
+	}
+	item.classList.toggle('alert', metric.alert);
+	item.querySelector('.metric-value').classList.toggle('alert', metric.alert);
+	item.querySelector('.metric-value').textContent = value + ' ' + metric.unit;
+	
+	const row = document.querySelector('.metric-row[data-metric="' + CSS.escape(metric.name) + '"]');
+	if (row) {
+		row.querySelector('.metric-row-value').textContent = value;
+	}
+}
+
+port.onMessage.addListener(message => {
+	if (message.type === 'status') {
+		document.getElementById('feed-status').textContent = message.connected ? 'Live' : 'Disconnected, retrying…';
+	} else if (message.type === 'metrics') {
+		message.metrics.forEach(showMetric);
+		document.getElementById('last-update').textContent = new Date(message.last_update).toLocaleString();
+	}
+});
+`
+
+// extensionBackgroundScript keeps a connection to the app's feed while a
+// panel is open, and relays its messages to the open panels
+const extensionBackgroundScript = `const FEED_URL = __FEED_URL__;
+
+const panels = new Set();
+let socket = null;
+let latest = null;
+let retryDelay = 1000;
+
+function broadcast(message) {
+  panels.forEach((port) => port.postMessage(message));
+}
+
+function connect() {
+  if (socket || panels.size === 0) return;
+
+  socket = new WebSocket(FEED_URL);
+  socket.onopen = () => {
+    retryDelay = 1000;
+    broadcast({ type: "status", connected: true });
+  };
+  socket.onmessage = (event) => {
+    latest = JSON.parse(event.data);
+    broadcast(latest);
+  };
+  socket.onclose = () => {
+    socket = null;
+    broadcast({ type: "status", connected: false });
+    if (panels.size > 0) {
+      setTimeout(connect, retryDelay);
+      retryDelay = Math.min(retryDelay * 2, 30000);
+    }
+  };
+}
+
+chrome.runtime.onConnect.addListener((port) => {
+  if (port.name !== "jetpack-panel") return;
+
+  panels.add(port);
+  port.postMessage({ type: "status", connected: socket !== null && socket.readyState === WebSocket.OPEN });
+  if (latest) port.postMessage(latest);
+  connect();
+
+  port.onDisconnect.addListener(() => {
+    panels.delete(port);
+    if (panels.size === 0 && socket) socket.close();
+  });
+});
+`
+
+// extensionDevtoolsHTML and extensionDevtoolsScript add the panel to
+// DevTools
+const extensionDevtoolsHTML = `<!DOCTYPE html>
+<html>
+<head><meta charset="UTF-8"></head>
+<body><script src="devtools.js"></script></body>
+</html>
+`
+
+const extensionDevtoolsScript = `chrome.devtools.panels.create("Jetpack", "", "panel.html");
+`
@@ -0,0 +1,140 @@
+package frontend
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+func TestGenerateExtension(t *testing.T) {
+	jp := core.NewJetpack()
+	jp.RegisterMetric(core.MetricFPS, "fps", "Frames per second", "fps", nil, nil)
+	panel := NewPerformancePanel(jp)
+
+	files, err := panel.GenerateExtension(ExtensionOptions{FeedURL: "ws://localhost:8080/jetpack/api/ws"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var manifest struct {
+		ManifestVersion int               `json:"manifest_version"`
+		Version         string            `json:"version"`
+		DevtoolsPage    string            `json:"devtools_page"`
+		Background      map[string]string `json:"background"`
+		HostPermissions []string          `json:"host_permissions"`
+	}
+	if err := json.Unmarshal(files["manifest.json"], &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.ManifestVersion != 3 || manifest.Version != "1.0.0" || manifest.Background["service_worker"] != "background.js" ||
+		len(manifest.HostPermissions) != 1 || manifest.HostPermissions[0] != "http://localhost:8080/*" {
+		t.Fatalf("unexpected manifest %+v", manifest)
+	}
+	for _, name := range []string{manifest.DevtoolsPage, manifest.Background["service_worker"], "devtools.js", "panel.html", "panel.js"} {
+		if len(files[name]) == 0 {
+			t.Fatalf("expected %s in the extension", name)
+		}
+	}
+
+	// Extensions may not run inline scripts
+	html := string(files["panel.html"])
+	if strings.Count(html, "<script") != 1 || !strings.Contains(html, `<script src="panel.js"></script>`) {
+		t.Fatalf("expected panel.html to load only panel.js")
+	}
+	if standalone, err := panel.GenerateExtensionHTML(); err != nil || !strings.Contains(standalone, "const jetpackPanelData") || !strings.Contains(standalone, "// Tab switching") {
+		t.Fatalf("expected the standalone HTML to keep its inline script, got %v", err)
+	}
+	if !strings.Contains(string(files["background.js"]), `const FEED_URL = "ws://localhost:8080/jetpack/api/ws";`) {
+		t.Fatalf("expected the feed URL in background.js")
+	}
+	if !strings.Contains(string(files["panel.js"]), "chrome.runtime.connect") {
+		t.Fatalf("expected panel.js to connect to the background service worker")
+	}
+
+	for _, options := range []ExtensionOptions{
+		{FeedURL: "http://localhost:8080/jetpack/api/ws"},
+		{FeedURL: "ws://localhost:8080/ws", Version: "1.0-beta"},
+	} {
+		if _, err := panel.GenerateExtension(options); err == nil {
+			t.Fatalf("expected %+v to be refused", options)
+		}
+	}
+
+	dir := filepath.Join(t.TempDir(), "extension")
+	if err := panel.WriteExtension(dir, ExtensionOptions{FeedURL: "wss://app.example.com/jetpack/api/ws"}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(filepath.Join(dir, "manifest.json")); err != nil || !strings.Contains(string(data), "https://app.example.com/*") {
+		t.Fatalf("expected the manifest to be written, got %s %v", data, err)
+	}
+}
+
+func TestPanelAPIFeed(t *testing.T) {
+	api := newTestPanelAPI(t, "")
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	dial := func(origin string) (*http.Response, *bufio.Reader) {
+		conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		request := "GET " + DefaultPanelAPIEndpoint + "/ws HTTP/1.1\r\nHost: localhost\r\nOrigin: " + origin + "\r\n" +
+			"Connection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"
+		if _, err := conn.Write([]byte(request)); err != nil {
+			t.Fatal(err)
+		}
+		reader := bufio.NewReader(conn)
+		response, err := http.ReadResponse(reader, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response, reader
+	}
+
+	if response, _ := dial("https://evil.example.com"); response.StatusCode == http.StatusSwitchingProtocols {
+		t.Fatalf("expected other sites to be refused")
+	}
+
+	response, reader := dial("chrome-extension://abcdefghijklmnop")
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected the extension to connect, got %d", response.StatusCode)
+	}
+
+	var header [2]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	length := int(header[1] & 0x7F)
+	if length == 126 {
+		var extended [2]byte
+		io.ReadFull(reader, extended[:])
+		length = int(binary.BigEndian.Uint16(extended[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		t.Fatal(err)
+	}
+
+	var message feedMessage
+	if err := json.Unmarshal(payload, &message); err != nil {
+		t.Fatal(err)
+	}
+	if message.Type != "metrics" || len(message.Metrics) != 1 || message.Metrics[0]["name"] != "fps" || message.Metrics[0]["latest_value"] != 60.0 {
+		t.Fatalf("unexpected message %+v", message)
+	}
+}
@@ -1,8 +1,13 @@
 package frontend
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/davidjeba/goscript/pkg/jetpack/core"
@@ -30,8 +35,18 @@ type LighthouseResult struct {
 	LighthouseVersion string             `json:"lighthouse_version"`
 	UserAgent     string                 `json:"user_agent"`
 	Environment   map[string]interface{} `json:"environment"`
+	
+	// Opportunities are the audits that estimate savings, largest first
+	Opportunities []LighthouseOpportunity `json:"opportunities"`
 }
 
+// DefaultLighthouseEndpoint is the path the performance panel runs audits
+// through
+const DefaultLighthouseEndpoint = "/_jetpack/lighthouse"
+
+// ErrLighthouseDisabled is returned when audits run with Config.Enabled off
+var ErrLighthouseDisabled = errors.New("lighthouse: audits are disabled")
+
 // LighthouseMonitor integrates with Google Lighthouse for web performance analysis
 type LighthouseMonitor struct {
 	Jetpack       *core.Jetpack
@@ -41,6 +56,20 @@ type LighthouseMonitor struct {
 	RunCount      int
 	AutoRunEnabled bool
 	AutoRunInterval time.Duration
+	
+	// Runner runs the audits in headless Chrome
+	Runner *LighthouseRunner
+	
+	// MaxResults caps the results kept in Results, oldest dropped first
+	MaxResults int
+	
+	// BaseURL is the site audits run from the panel are resolved against;
+	// empty audits the host the panel's request came to
+	BaseURL string
+	
+	mutex    sync.RWMutex
+	runMutex sync.Mutex
+	stop     chan struct{}
 }
 
 // NewLighthouseMonitor creates a new Lighthouse monitor
@@ -59,78 +88,48 @@ func NewLighthouseMonitor(jetpack *core.Jetpack) *LighthouseMonitor {
 		Results:       make([]*LighthouseResult, 0),
 		AutoRunEnabled: false,
 		AutoRunInterval: time.Hour,
+		Runner:        NewLighthouseRunner(),
+		MaxResults:    50,
 	}
 }
 
 // RunAudit runs a Lighthouse audit on the specified URL
 func (lm *LighthouseMonitor) RunAudit(url string) (*LighthouseResult, error) {
-	// In a real implementation, this would call the Lighthouse API
-	// For now, we'll simulate a result
+	return lm.RunAuditContext(context.Background(), url)
+}
+
+// RunAuditContext runs a Lighthouse audit on the specified URL, giving up
+// when ctx is done. Audits run one at a time; later ones wait their turn.
+func (lm *LighthouseMonitor) RunAuditContext(ctx context.Context, url string) (*LighthouseResult, error) {
+	if !lm.Config.Enabled {
+		return nil, ErrLighthouseDisabled
+	}
 	
-	result := &LighthouseResult{
-		URL: url,
-		Categories: map[string]float64{
-			"performance":    0.85,
-			"accessibility":  0.92,
-			"best-practices": 0.87,
-			"seo":            0.95,
-			"pwa":            0.65,
-		},
-		Audits: map[string]interface{}{
-			"first-contentful-paint": map[string]interface{}{
-				"id":    "first-contentful-paint",
-				"title": "First Contentful Paint",
-				"description": "First Contentful Paint marks the time at which the first text or image is painted",
-				"score": 0.89,
-				"displayValue": "1.2 s",
-				"numericValue": 1243,
-			},
-			"speed-index": map[string]interface{}{
-				"id":    "speed-index",
-				"title": "Speed Index",
-				"description": "Speed Index shows how quickly the contents of a page are visibly populated",
-				"score": 0.87,
-				"displayValue": "1.8 s",
-				"numericValue": 1823,
-			},
-			"largest-contentful-paint": map[string]interface{}{
-				"id":    "largest-contentful-paint",
-				"title": "Largest Contentful Paint",
-				"description": "Largest Contentful Paint marks the time at which the largest text or image is painted",
-				"score": 0.82,
-				"displayValue": "2.1 s",
-				"numericValue": 2134,
-			},
-			"total-blocking-time": map[string]interface{}{
-				"id":    "total-blocking-time",
-				"title": "Total Blocking Time",
-				"description": "Sum of all time periods between FCP and Time to Interactive, when task length exceeded 50ms",
-				"score": 0.75,
-				"displayValue": "120 ms",
-				"numericValue": 120,
-			},
-			"cumulative-layout-shift": map[string]interface{}{
-				"id":    "cumulative-layout-shift",
-				"title": "Cumulative Layout Shift",
-				"description": "Cumulative Layout Shift measures the movement of visible elements within the viewport",
-				"score": 0.92,
-				"displayValue": "0.05",
-				"numericValue": 0.05,
-			},
-		},
-		Timestamp: time.Now(),
-		LighthouseVersion: "9.6.0",
-		UserAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36",
-		Environment: map[string]interface{}{
-			"networkUserAgent": "Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5 Build/MRA58N) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Mobile Safari/537.36",
-			"hostUserAgent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36",
-			"benchmarkIndex": 1000,
-		},
+	lm.runMutex.Lock()
+	defer lm.runMutex.Unlock()
+	
+	runner := lm.Runner
+	if runner == nil {
+		runner = NewLighthouseRunner()
+	}
+	result, err := runner.Run(ctx, url, lm.Config)
+	if err != nil {
+		lm.Jetpack.ReportError(core.ErrorReport{
+			Source:    "jetpack",
+			Component: "lighthouse",
+			Message:   err.Error(),
+		})
+		return nil, err
 	}
 	
+	lm.mutex.Lock()
 	lm.Results = append(lm.Results, result)
+	if lm.MaxResults > 0 && len(lm.Results) > lm.MaxResults {
+		lm.Results = append(lm.Results[:0], lm.Results[len(lm.Results)-lm.MaxResults:]...)
+	}
 	lm.LastRunTime = time.Now()
 	lm.RunCount++
+	lm.mutex.Unlock()
 	
 	// Record metrics in Jetpack
 	lm.recordMetricsFromResult(result)
@@ -220,35 +219,69 @@ func (lm *LighthouseMonitor) recordMetricsFromResult(result *LighthouseResult) {
 			}
 		}
 	}
+	
+	// Record the time each opportunity would save
+	for _, opportunity := range result.Opportunities {
+		metricName := fmt.Sprintf("lighthouse_%s_savings", strings.Replace(opportunity.ID, "-", "_", -1))
+		if _, err := lm.Jetpack.GetMetric(metricName); err != nil {
+			lm.Jetpack.RegisterMetric(
+				core.MetricType("lighthouse_opportunity"),
+				metricName,
+				opportunity.Title,
+				"ms",
+				nil,
+				[]string{"lighthouse", "opportunity"},
+			)
+		}
+		lm.Jetpack.RecordMetric(metricName, opportunity.SavingsMs)
+	}
 }
 
-// StartAutoRun starts automatically running Lighthouse audits
+// StartAutoRun starts automatically running Lighthouse audits every
+// AutoRunInterval
 func (lm *LighthouseMonitor) StartAutoRun(url string) {
-	if !lm.AutoRunEnabled {
-		lm.AutoRunEnabled = true
+	lm.mutex.Lock()
+	defer lm.mutex.Unlock()
+	
+	if lm.stop != nil {
+		return
+	}
+	stop := make(chan struct{})
+	lm.stop = stop
+	lm.AutoRunEnabled = true
+	
+	go func() {
+		ticker := time.NewTicker(lm.AutoRunInterval)
+		defer ticker.Stop()
 		
-		go func() {
-			ticker := time.NewTicker(lm.AutoRunInterval)
-			defer ticker.Stop()
-			
-			for range ticker.C {
-				if !lm.AutoRunEnabled {
-					break
-				}
-				
+		for {
+			select {
+			case <-ticker.C:
 				lm.RunAudit(url)
+			case <-stop:
+				return
 			}
-		}()
-	}
+		}
+	}()
 }
 
 // StopAutoRun stops automatically running Lighthouse audits
 func (lm *LighthouseMonitor) StopAutoRun() {
+	lm.mutex.Lock()
+	defer lm.mutex.Unlock()
+	
+	if lm.stop != nil {
+		close(lm.stop)
+		lm.stop = nil
+	}
 	lm.AutoRunEnabled = false
 }
 
 // GetLatestResult gets the latest Lighthouse result
 func (lm *LighthouseMonitor) GetLatestResult() *LighthouseResult {
+	lm.mutex.RLock()
+	defer lm.mutex.RUnlock()
+	
 	if len(lm.Results) == 0 {
 		return nil
 	}
@@ -256,6 +289,64 @@ func (lm *LighthouseMonitor) GetLatestResult() *LighthouseResult {
 	return lm.Results[len(lm.Results)-1]
 }
 
+// GetResults gets the kept Lighthouse results, oldest first
+func (lm *LighthouseMonitor) GetResults() []*LighthouseResult {
+	lm.mutex.RLock()
+	defer lm.mutex.RUnlock()
+	
+	results := make([]*LighthouseResult, len(lm.Results))
+	copy(results, lm.Results)
+	return results
+}
+
+// ServeHTTP serves the performance panel's audits: GET returns the kept
+// results, and POST audits the page at the "path" form value and returns
+// its result. Serve it at DefaultLighthouseEndpoint in development only, as
+// each audit starts a browser.
+func (lm *LighthouseMonitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body interface{}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		body = lm.GetResults()
+	case http.MethodPost:
+		path := r.FormValue("path")
+		if path == "" {
+			path = "/"
+		}
+		if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") {
+			http.Error(w, "invalid path", http.StatusBadRequest)
+			return
+		}
+		
+		result, err := lm.RunAuditContext(r.Context(), lm.auditURL(r, path))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		body = result
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+// auditURL resolves path against BaseURL, or against the host r came to
+func (lm *LighthouseMonitor) auditURL(r *http.Request, path string) string {
+	if lm.BaseURL != "" {
+		return strings.TrimSuffix(lm.BaseURL, "/") + path
+	}
+	
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + path
+}
+
 // ExportResultToJSON exports a Lighthouse result to JSON
 func (lm *LighthouseMonitor) ExportResultToJSON(result *LighthouseResult) (string, error) {
 	data, err := json.MarshalIndent(result, "", "  ")
@@ -282,7 +373,7 @@ func (lm *LighthouseMonitor) GenerateReport(result *LighthouseResult) string {
 	}
 	
 	report += "\nKey Metrics:\n"
-	for auditID, audit := range result.Audits {
+	for _, audit := range result.Audits {
 		if auditMap, ok := audit.(map[string]interface{}); ok {
 			if title, ok := auditMap["title"].(string); ok {
 				if displayValue, ok := auditMap["displayValue"].(string); ok {
@@ -294,5 +385,12 @@ func (lm *LighthouseMonitor) GenerateReport(result *LighthouseResult) string {
 		}
 	}
 	
+	if len(result.Opportunities) > 0 {
+		report += "\nOpportunities:\n"
+		for _, opportunity := range result.Opportunities {
+			report += fmt.Sprintf("  %s: %.0f ms\n", opportunity.Title, opportunity.SavingsMs)
+		}
+	}
+	
 	return report
 }
\ No newline at end of file
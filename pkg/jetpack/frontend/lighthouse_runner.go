@@ -0,0 +1,353 @@
+package frontend
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrChromeNotFound is returned when no Chrome or Chromium binary is found
+var ErrChromeNotFound = errors.New("lighthouse: chrome not found, set LighthouseRunner.ChromePath or CHROME_PATH")
+
+// ErrLighthouseNotFound is returned when the lighthouse CLI is not installed
+var ErrLighthouseNotFound = errors.New("lighthouse: lighthouse CLI not found, install it with npm install -g lighthouse")
+
+// chromeNames are the binaries tried, in order, when ChromePath is empty
+var chromeNames = []string{"google-chrome", "google-chrome-stable", "chromium", "chromium-browser", "chrome"}
+
+// LighthouseOpportunity is an audit that estimates how much faster the page
+// would load if fixed, such as "render-blocking-resources"
+type LighthouseOpportunity struct {
+	ID           string  `json:"id"`
+	Title        string  `json:"title"`
+	Score        float64 `json:"score"`
+	DisplayValue string  `json:"display_value,omitempty"`
+	SavingsMs    float64 `json:"savings_ms"`
+	SavingsBytes float64 `json:"savings_bytes"`
+}
+
+// LighthouseRunner runs Lighthouse audits: it starts headless Chrome with
+// remote debugging, then has the lighthouse CLI audit the page through
+// Chrome's DevTools protocol port
+type LighthouseRunner struct {
+	// ChromePath is the Chrome binary; empty uses CHROME_PATH or the first
+	// of chromeNames on the PATH
+	ChromePath string
+
+	// ChromeFlags are passed to Chrome besides the remote debugging flags;
+	// containers running as root usually need "--no-sandbox" added
+	ChromeFlags []string
+
+	// LighthousePath is the lighthouse CLI; empty looks it up on the PATH
+	LighthousePath string
+
+	// StartTimeout bounds how long Chrome may take to open its DevTools port
+	StartTimeout time.Duration
+}
+
+// NewLighthouseRunner creates a runner using the Chrome and lighthouse found
+// on the machine
+func NewLighthouseRunner() *LighthouseRunner {
+	return &LighthouseRunner{
+		ChromeFlags: []string{
+			"--headless=new",
+			"--disable-gpu",
+			"--disable-dev-shm-usage",
+			"--no-first-run",
+			"--no-default-browser-check",
+		},
+		StartTimeout: 30 * time.Second,
+	}
+}
+
+// Run audits url with config. Chrome is started for the audit and stopped
+// afterwards, so audits never share caches or cookies.
+func (lr *LighthouseRunner) Run(ctx context.Context, url string, config LighthouseConfig) (*LighthouseResult, error) {
+	chrome, err := lr.chromePath()
+	if err != nil {
+		return nil, err
+	}
+	lighthouse := lr.LighthousePath
+	if lighthouse == "" {
+		if lighthouse, err = exec.LookPath("lighthouse"); err != nil {
+			return nil, ErrLighthouseNotFound
+		}
+	}
+
+	if config.MaxWaitTime > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, lr.StartTimeout+time.Duration(config.MaxWaitTime)*time.Second*2)
+		defer cancel()
+	}
+
+	port, stop, err := lr.startChrome(ctx, chrome)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, lighthouse, lighthouseArgs(url, port, config)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("lighthouse: auditing %s: %v", url, ctx.Err())
+		}
+		return nil, fmt.Errorf("lighthouse: auditing %s: %v: %s", url, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return parseLighthouseReport(stdout.Bytes())
+}
+
+// chromePath finds the Chrome binary
+func (lr *LighthouseRunner) chromePath() (string, error) {
+	if lr.ChromePath != "" {
+		return lr.ChromePath, nil
+	}
+	if path := os.Getenv("CHROME_PATH"); path != "" {
+		return path, nil
+	}
+	for _, name := range chromeNames {
+		if path, err := exec.LookPath(name); err == nil {
+			return path, nil
+		}
+	}
+	return "", ErrChromeNotFound
+}
+
+// startChrome starts Chrome with a fresh profile and returns its DevTools
+// port once it answers, and a function stopping it
+func (lr *LighthouseRunner) startChrome(ctx context.Context, chrome string) (int, func(), error) {
+	profile, err := ioutil.TempDir("", "jetpack-lighthouse-")
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Port 0 has Chrome pick a free port and write it to DevToolsActivePort
+	args := append([]string{"--remote-debugging-port=0", "--user-data-dir=" + profile}, lr.ChromeFlags...)
+	cmd := exec.CommandContext(ctx, chrome, append(args, "about:blank")...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(profile)
+		return 0, nil, fmt.Errorf("lighthouse: starting chrome: %v", err)
+	}
+
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	stop := func() {
+		cmd.Process.Kill()
+		<-exited
+		os.RemoveAll(profile)
+	}
+
+	timeout := lr.StartTimeout
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	tick := time.NewTicker(50 * time.Millisecond)
+	defer tick.Stop()
+
+	for {
+		if port, ok := devToolsPort(profile); ok && devToolsReady(ctx, port) {
+			return port, stop, nil
+		}
+
+		select {
+		case <-tick.C:
+		case <-exited:
+			stop()
+			return 0, nil, fmt.Errorf("lighthouse: chrome exited: %s", strings.TrimSpace(stderr.String()))
+		case <-deadline.C:
+			stop()
+			return 0, nil, fmt.Errorf("lighthouse: chrome did not open its DevTools port within %s", timeout)
+		case <-ctx.Done():
+			stop()
+			return 0, nil, ctx.Err()
+		}
+	}
+}
+
+// devToolsPort reads the port Chrome wrote to its profile
+func devToolsPort(profile string) (int, bool) {
+	data, err := ioutil.ReadFile(filepath.Join(profile, "DevToolsActivePort"))
+	if err != nil {
+		return 0, false
+	}
+	line := strings.SplitN(string(data), "\n", 2)[0]
+	port, err := strconv.Atoi(strings.TrimSpace(line))
+	return port, err == nil && port > 0
+}
+
+// devToolsReady reports whether the DevTools protocol answers on port
+func devToolsReady(ctx context.Context, port int) bool {
+	req, err := http.NewRequest(http.MethodGet, "http://127.0.0.1:"+strconv.Itoa(port)+"/json/version", nil)
+	if err != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	var version struct {
+		WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+	}
+	return resp.StatusCode == http.StatusOK && json.NewDecoder(resp.Body).Decode(&version) == nil && version.WebSocketDebuggerURL != ""
+}
+
+// lighthouseArgs builds the lighthouse command line for config
+func lighthouseArgs(url string, port int, config LighthouseConfig) []string {
+	args := []string{
+		url,
+		"--port=" + strconv.Itoa(port),
+		"--output=json",
+		"--output-path=stdout",
+		"--quiet",
+	}
+	if len(config.Categories) > 0 {
+		args = append(args, "--only-categories="+strings.Join(config.Categories, ","))
+	}
+	if len(config.OnlyAudits) > 0 {
+		args = append(args, "--only-audits="+strings.Join(config.OnlyAudits, ","))
+	}
+	if len(config.SkipAudits) > 0 {
+		args = append(args, "--skip-audits="+strings.Join(config.SkipAudits, ","))
+	}
+	if config.Locale != "" {
+		args = append(args, "--locale="+config.Locale)
+	}
+	if config.FormFactor == "desktop" {
+		args = append(args, "--preset=desktop")
+	} else if config.FormFactor != "" {
+		args = append(args, "--form-factor="+config.FormFactor)
+	}
+	if !config.Throttling {
+		args = append(args, "--throttling-method=provided")
+	}
+	if config.MaxWaitTime > 0 {
+		args = append(args, "--max-wait-for-load="+strconv.Itoa(config.MaxWaitTime*1000))
+	}
+	return args
+}
+
+// lighthouseReport is the part of Lighthouse's JSON report Jetpack keeps
+type lighthouseReport struct {
+	LighthouseVersion string                            `json:"lighthouseVersion"`
+	RequestedURL      string                            `json:"requestedUrl"`
+	FinalURL          string                            `json:"finalUrl"`
+	FinalDisplayedURL string                            `json:"finalDisplayedUrl"`
+	FetchTime         time.Time                         `json:"fetchTime"`
+	UserAgent         string                            `json:"userAgent"`
+	Environment       map[string]interface{}            `json:"environment"`
+	Categories        map[string]lighthouseCategory     `json:"categories"`
+	Audits            map[string]map[string]interface{} `json:"audits"`
+	RuntimeError      *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"runtimeError"`
+}
+
+type lighthouseCategory struct {
+	Title string   `json:"title"`
+	Score *float64 `json:"score"`
+}
+
+// auditFields are the fields of each audit kept in LighthouseResult.Audits;
+// the details Lighthouse adds are too large to keep a history of
+var auditFields = []string{"id", "title", "description", "score", "displayValue", "numericValue", "numericUnit"}
+
+// parseLighthouseReport converts Lighthouse's JSON report into a result
+func parseLighthouseReport(data []byte) (*LighthouseResult, error) {
+	var report lighthouseReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("lighthouse: reading report: %v", err)
+	}
+	if report.RuntimeError != nil && report.RuntimeError.Code != "" && report.RuntimeError.Code != "NO_ERROR" {
+		return nil, fmt.Errorf("lighthouse: %s: %s", report.RuntimeError.Code, report.RuntimeError.Message)
+	}
+
+	result := &LighthouseResult{
+		URL:               report.FinalDisplayedURL,
+		Categories:        make(map[string]float64),
+		Audits:            make(map[string]interface{}),
+		Opportunities:     make([]LighthouseOpportunity, 0),
+		Timestamp:         report.FetchTime,
+		LighthouseVersion: report.LighthouseVersion,
+		UserAgent:         report.UserAgent,
+		Environment:       report.Environment,
+	}
+	if result.URL == "" {
+		result.URL = report.FinalURL
+	}
+	if result.URL == "" {
+		result.URL = report.RequestedURL
+	}
+	if result.Timestamp.IsZero() {
+		result.Timestamp = time.Now()
+	}
+
+	// Categories without a score failed to run and are left out
+	for id, category := range report.Categories {
+		if category.Score != nil {
+			result.Categories[id] = *category.Score
+		}
+	}
+
+	for id, audit := range report.Audits {
+		kept := make(map[string]interface{})
+		for _, field := range auditFields {
+			if value, ok := audit[field]; ok && value != nil {
+				kept[field] = value
+			}
+		}
+		result.Audits[id] = kept
+
+		if opportunity, ok := auditOpportunity(id, audit); ok {
+			result.Opportunities = append(result.Opportunities, opportunity)
+		}
+	}
+	sort.SliceStable(result.Opportunities, func(i, j int) bool {
+		return result.Opportunities[i].SavingsMs > result.Opportunities[j].SavingsMs
+	})
+
+	return result, nil
+}
+
+// auditOpportunity returns the savings an opportunity audit estimates, if
+// it found any
+func auditOpportunity(id string, audit map[string]interface{}) (LighthouseOpportunity, bool) {
+	details, _ := audit["details"].(map[string]interface{})
+	if details == nil || details["type"] != "opportunity" {
+		return LighthouseOpportunity{}, false
+	}
+
+	opportunity := LighthouseOpportunity{ID: id}
+	opportunity.Title, _ = audit["title"].(string)
+	opportunity.Score, _ = audit["score"].(float64)
+	opportunity.DisplayValue, _ = audit["displayValue"].(string)
+	opportunity.SavingsMs, _ = details["overallSavingsMs"].(float64)
+	opportunity.SavingsBytes, _ = details["overallSavingsBytes"].(float64)
+	return opportunity, opportunity.SavingsMs > 0 || opportunity.SavingsBytes > 0
+}
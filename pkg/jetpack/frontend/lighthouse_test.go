@@ -0,0 +1,192 @@
+package frontend
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+const lighthouseReportJSON = `{
+	"lighthouseVersion": "12.0.0",
+	"requestedUrl": "http://localhost:8080/",
+	"finalDisplayedUrl": "http://localhost:8080/",
+	"fetchTime": "2024-01-01T12:00:00.000Z",
+	"userAgent": "HeadlessChrome",
+	"runtimeError": {"code": "NO_ERROR"},
+	"categories": {
+		"performance": {"title": "Performance", "score": 0.42},
+		"seo": {"title": "SEO", "score": 0.91},
+		"pwa": {"title": "PWA", "score": null}
+	},
+	"audits": {
+		"largest-contentful-paint": {"id": "largest-contentful-paint", "title": "Largest Contentful Paint", "score": 0.3, "numericValue": 4200, "displayValue": "4.2 s"},
+		"render-blocking-resources": {"id": "render-blocking-resources", "title": "Eliminate render-blocking resources", "score": 0.4,
+			"details": {"type": "opportunity", "overallSavingsMs": 850, "items": [{"url": "/app.css"}]}},
+		"unused-javascript": {"id": "unused-javascript", "title": "Reduce unused JavaScript", "score": 0.5,
+			"details": {"type": "opportunity", "overallSavingsMs": 1200, "overallSavingsBytes": 51200}},
+		"uses-text-compression": {"id": "uses-text-compression", "title": "Enable text compression", "score": 1,
+			"details": {"type": "opportunity", "overallSavingsMs": 0}}
+	}
+}`
+
+// fakeLighthouse installs stand-ins for Chrome, which serves the DevTools
+// version endpoint from devtools, and for the lighthouse CLI, which prints
+// the report
+func fakeLighthouse(t *testing.T, devtools *httptest.Server) *LighthouseRunner {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake browser is a shell script")
+	}
+	dir, err := ioutil.TempDir("", "lighthouse-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	port := devtools.URL[strings.LastIndex(devtools.URL, ":")+1:]
+	report := filepath.Join(dir, "report.json")
+	chrome := filepath.Join(dir, "chrome")
+	lighthouse := filepath.Join(dir, "lighthouse")
+	files := map[string]string{
+		report: lighthouseReportJSON,
+		chrome: `#!/bin/sh
+for arg; do
+	case "$arg" in --user-data-dir=*) profile="${arg#--user-data-dir=}" ;; esac
+done
+printf '` + port + `\n/devtools/browser/1\n' > "$profile/DevToolsActivePort"
+exec sleep 60
+`,
+		lighthouse: `#!/bin/sh
+case "$*" in *--port=` + port + `*) cat "` + report + `" ;; *) echo "wrong port: $*" >&2; exit 1 ;; esac
+`,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(name, []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	runner := NewLighthouseRunner()
+	runner.ChromePath = chrome
+	runner.LighthousePath = lighthouse
+	return runner
+}
+
+func devToolsServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/json/version" {
+			w.Write([]byte(`{"Browser": "HeadlessChrome", "webSocketDebuggerUrl": "ws://127.0.0.1/devtools/browser/1"}`))
+			return
+		}
+		http.NotFound(w, r)
+	}))
+}
+
+func TestLighthouseMonitorRunAudit(t *testing.T) {
+	devtools := devToolsServer()
+	defer devtools.Close()
+
+	jp := core.NewJetpack()
+	monitor := NewLighthouseMonitor(jp)
+	monitor.Runner = fakeLighthouse(t, devtools)
+	monitor.MaxResults = 1
+
+	for i := 0; i < 2; i++ {
+		if _, err := monitor.RunAudit("http://localhost:8080/"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if results := monitor.GetResults(); len(results) != 1 || monitor.RunCount != 2 {
+		t.Fatalf("expected one kept result of two runs, got %d of %d", len(results), monitor.RunCount)
+	}
+
+	result := monitor.GetLatestResult()
+	if len(result.Categories) != 2 || result.Categories["performance"] != 0.42 || result.LighthouseVersion != "12.0.0" {
+		t.Fatalf("unexpected categories %+v", result)
+	}
+	if audit := result.Audits["render-blocking-resources"].(map[string]interface{}); audit["details"] != nil {
+		t.Fatalf("expected audit details to be dropped, got %+v", audit)
+	}
+	if len(result.Opportunities) != 2 || result.Opportunities[0].ID != "unused-javascript" || result.Opportunities[1].SavingsMs != 850 {
+		t.Fatalf("unexpected opportunities %+v", result.Opportunities)
+	}
+
+	for name, want := range map[string]float64{
+		"lighthouse_performance_score":                 0.42,
+		"lighthouse_largest_contentful_paint":          4200,
+		"lighthouse_unused_javascript_savings":         1200,
+		"lighthouse_render_blocking_resources_savings": 850,
+	} {
+		if got, err := jp.GetMetricLatest(name); err != nil || got != want {
+			t.Fatalf("expected %s to be %v, got %v %v", name, want, got, err)
+		}
+	}
+
+	scores := lighthouseScores(result)
+	if len(scores) != 2 || scores[0]["score"] != 42 || scores[0]["rating"] != "poor" || scores[1]["rating"] != "good" {
+		t.Fatalf("unexpected panel scores %+v", scores)
+	}
+}
+
+func TestLighthouseMonitorServeHTTP(t *testing.T) {
+	devtools := devToolsServer()
+	defer devtools.Close()
+
+	monitor := NewLighthouseMonitor(core.NewJetpack())
+	monitor.Runner = fakeLighthouse(t, devtools)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, DefaultLighthouseEndpoint, strings.NewReader(url.Values{"path": {"//evil.example"}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	monitor.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected another host to be refused, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, DefaultLighthouseEndpoint, strings.NewReader("path=/posts"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	monitor.ServeHTTP(w, r)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"lighthouse_version":"12.0.0"`) {
+		t.Fatalf("unexpected audit response %d %s", w.Code, w.Body)
+	}
+	if got := monitor.auditURL(r, "/posts"); got != "http://example.com/posts" {
+		t.Fatalf("expected the request's host to be audited, got %s", got)
+	}
+
+	w = httptest.NewRecorder()
+	monitor.ServeHTTP(w, httptest.NewRequest(http.MethodGet, DefaultLighthouseEndpoint, nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "[{") {
+		t.Fatalf("expected the kept results, got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestLighthouseRunnerFailures(t *testing.T) {
+	devtools := devToolsServer()
+	defer devtools.Close()
+
+	runner := fakeLighthouse(t, devtools)
+	runner.ChromePath = "/nonexistent/chrome"
+	if _, err := runner.Run(context.Background(), "http://localhost/", LighthouseConfig{}); err == nil {
+		t.Fatalf("expected a missing browser to fail")
+	}
+
+	if _, err := parseLighthouseReport([]byte(`{"runtimeError": {"code": "NO_FCP", "message": "The page did not paint"}}`)); err == nil || !strings.Contains(err.Error(), "NO_FCP") {
+		t.Fatalf("expected the runtime error to be returned, got %v", err)
+	}
+
+	args := strings.Join(lighthouseArgs("http://localhost/", 9222, LighthouseConfig{Categories: []string{"performance", "seo"}, FormFactor: "desktop", MaxWaitTime: 45}), " ")
+	for _, want := range []string{"--port=9222", "--only-categories=performance,seo", "--preset=desktop", "--throttling-method=provided", "--max-wait-for-load=45000"} {
+		if !strings.Contains(args, want) {
+			t.Fatalf("expected %s in %s", want, args)
+		}
+	}
+}
@@ -0,0 +1,432 @@
+package frontend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscript"
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+// DefaultPanelAPIEndpoint is where the panel calls its PanelAPI by default
+const DefaultPanelAPIEndpoint = "/jetpack/api"
+
+// Bounds of the refresh rate, in ms, the panel accepts
+const (
+	minRefreshRate = 100
+	maxRefreshRate = 60000
+)
+
+// maxSettingsSize caps the request bodies the API reads
+const maxSettingsSize = 64 << 10
+
+// The positions, themes and tabs the panel can be shown with
+var (
+	panelPositions = map[string]bool{"top-left": true, "top-right": true, "bottom-left": true, "bottom-right": true}
+	panelThemes    = map[string]bool{"dark": true, "light": true}
+	panelTabs      = map[string]bool{"overview": true, "metrics": true, "lighthouse": true, "accessibility": true, "api": true, "settings": true}
+)
+
+// PanelSettings are what a user changes from the panel
+type PanelSettings struct {
+	Visible         bool     `json:"visible"`
+	Collapsed       bool     `json:"collapsed"`
+	SelectedTab     string   `json:"selected_tab"`
+	SelectedMetrics []string `json:"selected_metrics"`
+	Position        string   `json:"position"`
+	Theme           string   `json:"theme"`
+	Opacity         float64  `json:"opacity"`
+	RefreshRate     int      `json:"refresh_rate"`
+	ShowCharts      bool     `json:"show_charts"`
+	ShowAlerts      bool     `json:"show_alerts"`
+}
+
+// Settings returns the panel's current settings
+func (pp *PerformancePanel) Settings() PanelSettings {
+	return PanelSettings{
+		Visible:         pp.Visible,
+		Collapsed:       pp.Collapsed,
+		SelectedTab:     pp.SelectedTab,
+		SelectedMetrics: append([]string(nil), pp.SelectedMetrics...),
+		Position:        pp.Config.Position,
+		Theme:           pp.Config.Theme,
+		Opacity:         pp.Config.Opacity,
+		RefreshRate:     pp.Config.RefreshRate,
+		ShowCharts:      pp.Config.ShowCharts,
+		ShowAlerts:      pp.Config.ShowAlerts,
+	}
+}
+
+// ApplySettings validates settings and applies them to the panel, leaving
+// it unchanged when they are invalid
+func (pp *PerformancePanel) ApplySettings(settings PanelSettings) error {
+	switch {
+	case !panelPositions[settings.Position]:
+		return fmt.Errorf("invalid position %q", settings.Position)
+	case !panelThemes[settings.Theme]:
+		return fmt.Errorf("invalid theme %q", settings.Theme)
+	case !panelTabs[settings.SelectedTab]:
+		return fmt.Errorf("invalid tab %q", settings.SelectedTab)
+	case settings.Opacity < 0 || settings.Opacity > 1:
+		return fmt.Errorf("opacity %v is not between 0 and 1", settings.Opacity)
+	case settings.RefreshRate < minRefreshRate || settings.RefreshRate > maxRefreshRate:
+		return fmt.Errorf("refresh rate %d is not between %d and %d ms", settings.RefreshRate, minRefreshRate, maxRefreshRate)
+	case len(settings.SelectedMetrics) > pp.Config.MaxMetrics:
+		return fmt.Errorf("at most %d metrics can be selected", pp.Config.MaxMetrics)
+	}
+
+	pp.Visible = settings.Visible
+	pp.Collapsed = settings.Collapsed
+	pp.SelectedTab = settings.SelectedTab
+	pp.SelectedMetrics = append([]string(nil), settings.SelectedMetrics...)
+	pp.Config.Position = settings.Position
+	pp.Config.Theme = settings.Theme
+	pp.Config.Opacity = settings.Opacity
+	pp.Config.RefreshRate = settings.RefreshRate
+	pp.Config.ShowCharts = settings.ShowCharts
+	pp.Config.ShowAlerts = settings.ShowAlerts
+	return nil
+}
+
+// PanelAPI is the backend the panel's controls call, served under the
+// panel's APIEndpoint:
+//
+//	GET    /data      the panel data as JSON
+//	GET    /panel     the panel's HTML, rendered with the current settings
+//	GET    /settings  the settings as JSON
+//	PUT    /settings  changes the settings given in a JSON object
+//	DELETE /settings  resets the settings to the panel's initial ones
+//	POST   /metrics   selects or unselects {"metric": name}
+//	GET    /stream    server-sent "metrics" events with the selected
+//	                  metrics, every RefreshRate
+//	GET    /ws        the same as WebSocket messages, for the Chrome
+//	                  extension GenerateExtension builds
+//
+// Like the panel, it is meant for development; do not serve it publicly.
+// Once the API serves a panel, render the panel through the API too.
+type PanelAPI struct {
+	Panel *PerformancePanel
+
+	// SettingsPath is a JSON file the settings are saved to, so they
+	// survive restarts; empty keeps them in memory
+	SettingsPath string
+
+	mutex    sync.Mutex
+	defaults PanelSettings
+}
+
+// NewPanelAPI creates the API for a panel, restoring the settings saved to
+// settingsPath if there are any
+func NewPanelAPI(panel *PerformancePanel, settingsPath string) (*PanelAPI, error) {
+	api := &PanelAPI{
+		Panel:        panel,
+		SettingsPath: settingsPath,
+		defaults:     panel.Settings(),
+	}
+	if settingsPath == "" {
+		return api, nil
+	}
+
+	data, err := ioutil.ReadFile(settingsPath)
+	if os.IsNotExist(err) {
+		return api, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	settings := panel.Settings()
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("reading panel settings: %v", err)
+	}
+	if err := panel.ApplySettings(settings); err != nil {
+		return nil, fmt.Errorf("reading panel settings: %v", err)
+	}
+	return api, nil
+}
+
+// InjectIntoHTML injects the panel into an HTML page with its current
+// settings
+func (api *PanelAPI) InjectIntoHTML(html string) (string, error) {
+	return api.InjectIntoHTMLWithNonce(html, "")
+}
+
+// InjectIntoHTMLWithNonce injects the panel like InjectIntoHTML, giving its
+// inline script and style the nonce of a strict Content-Security-Policy
+func (api *PanelAPI) InjectIntoHTMLWithNonce(html, nonce string) (string, error) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+
+	return api.Panel.InjectIntoHTMLWithNonce(html, nonce)
+}
+
+// ServeHTTP serves the API, whether or not a router stripped the endpoint
+// from the path
+func (api *PanelAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	api.mutex.Lock()
+	endpoint := strings.TrimSuffix(api.Panel.Config.APIEndpoint, "/")
+	api.mutex.Unlock()
+
+	switch strings.TrimPrefix(r.URL.Path, endpoint) {
+	case "/data":
+		if api.allow(w, r, http.MethodGet) {
+			api.mutex.Lock()
+			data := api.Panel.GetPanelData()
+			api.mutex.Unlock()
+			writeJSON(w, data)
+		}
+	case "/panel":
+		if api.allow(w, r, http.MethodGet) {
+			api.mutex.Lock()
+			html, err := api.Panel.GenerateHTML()
+			api.mutex.Unlock()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(html))
+		}
+	case "/settings":
+		if api.allow(w, r, http.MethodGet, http.MethodPut, http.MethodDelete) {
+			api.settings(w, r)
+		}
+	case "/metrics":
+		if api.allow(w, r, http.MethodPost) {
+			api.toggleMetric(w, r)
+		}
+	case "/stream":
+		if api.allow(w, r, http.MethodGet) {
+			api.stream(w, r)
+		}
+	case "/ws":
+		api.feed(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// allow reports whether r uses one of methods, answering 405 if not; GET
+// allows HEAD
+func (api *PanelAPI) allow(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method || (method == http.MethodGet && r.Method == http.MethodHead) {
+			return true
+		}
+	}
+
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	return false
+}
+
+// settings reads, changes or resets the settings
+func (api *PanelAPI) settings(w http.ResponseWriter, r *http.Request) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+
+	before := api.Panel.Settings()
+	switch r.Method {
+	case http.MethodPut:
+		// Fields left out of the body keep their current values
+		settings := api.Panel.Settings()
+		decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSettingsSize))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&settings); err != nil {
+			http.Error(w, "invalid settings: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := api.Panel.ApplySettings(settings); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	case http.MethodDelete:
+		api.Panel.ApplySettings(api.defaults)
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		api.Panel.Jetpack.AuditChanges(auditContext(r), "panel", before, api.Panel.Settings())
+		if err := api.save(); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	writeJSON(w, api.Panel.Settings())
+}
+
+// toggleMetric selects a metric that is not selected, and unselects one
+// that is
+func (api *PanelAPI) toggleMetric(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Metric string `json:"metric"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSettingsSize)).Decode(&body); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := api.Panel.Jetpack.GetMetric(body.Metric); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+
+	before := api.Panel.Settings()
+	selected := false
+	for _, name := range api.Panel.SelectedMetrics {
+		if name == body.Metric {
+			selected = true
+		}
+	}
+	if selected {
+		api.Panel.UnselectMetric(body.Metric)
+	} else {
+		api.Panel.SelectMetric(body.Metric)
+	}
+	api.Panel.Jetpack.AuditChanges(auditContext(r), "panel", before, api.Panel.Settings())
+
+	if err := api.save(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, api.Panel.Settings())
+}
+
+// auditContext is the context of r, whose changes are audited as made by
+// the signed-in user or, without one, the address the request came from
+func auditContext(r *http.Request) context.Context {
+	actor := goscript.CurrentUser(r)
+	if actor == "" {
+		actor = r.RemoteAddr
+	}
+	return core.WithAuditActor(r.Context(), actor)
+}
+
+// stream sends the selected metrics every RefreshRate until the client
+// goes away
+func (api *PanelAPI) stream(w http.ResponseWriter, r *http.Request) {
+	events, err := goscript.NewEventStream(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer events.Close()
+
+	for {
+		// Settings changed meanwhile apply from the next event on
+		api.mutex.Lock()
+		metrics := api.Panel.selectedMetricsData()
+		refreshRate := time.Duration(api.Panel.Config.RefreshRate) * time.Millisecond
+		api.mutex.Unlock()
+
+		if err := events.Send(goscript.Event{Name: "metrics", Data: metrics}); err != nil {
+			return
+		}
+
+		timer := time.NewTimer(refreshRate)
+		select {
+		case <-timer.C:
+		case <-events.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// feedUpgrader accepts the page's own connections and the extension's
+var feedUpgrader = &goscript.Upgrader{
+	CheckOrigin: func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || strings.HasPrefix(origin, "chrome-extension://") {
+			return true
+		}
+		parsed, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(parsed.Host, r.Host)
+	},
+}
+
+// feedMessage is a message of the WebSocket feed
+type feedMessage struct {
+	Type       string                   `json:"type"`
+	Metrics    []map[string]interface{} `json:"metrics"`
+	LastUpdate time.Time                `json:"last_update"`
+}
+
+// feed sends the selected metrics over a WebSocket every RefreshRate until
+// the client goes away
+func (api *PanelAPI) feed(w http.ResponseWriter, r *http.Request) {
+	ws, err := feedUpgrader.Upgrade(w, r)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+
+	// Read, so pings are answered and the connection notices the client
+	// leaving
+	go func() {
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				ws.Close()
+				return
+			}
+		}
+	}()
+
+	for {
+		api.mutex.Lock()
+		message := feedMessage{Type: "metrics", Metrics: api.Panel.selectedMetricsData(), LastUpdate: time.Now()}
+		refreshRate := time.Duration(api.Panel.Config.RefreshRate) * time.Millisecond
+		api.mutex.Unlock()
+
+		if err := ws.WriteJSON(message); err != nil {
+			return
+		}
+
+		timer := time.NewTimer(refreshRate)
+		select {
+		case <-timer.C:
+		case <-ws.Context().Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// save writes the settings to SettingsPath, if set; the caller holds the
+// mutex
+func (api *PanelAPI) save() error {
+	if api.SettingsPath == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(api.Panel.Settings(), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write a temporary file first, so a crash never leaves half a file
+	tmp := api.SettingsPath + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("saving panel settings: %v", err)
+	}
+	if err := os.Rename(tmp, api.SettingsPath); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("saving panel settings: %v", err)
+	}
+	return nil
+}
+
+// writeJSON writes v as a JSON response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
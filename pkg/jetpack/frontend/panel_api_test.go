@@ -0,0 +1,162 @@
+package frontend
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+func newTestPanelAPI(t *testing.T, settingsPath string) *PanelAPI {
+	jp := core.NewJetpack()
+	jp.RegisterMetric(core.MetricFPS, "fps", "Frames per second", "fps", nil, nil)
+	jp.RegisterMetric(core.MetricAPILatency, "api_latency", "API latency", "ms", nil, nil)
+	jp.RecordMetric("fps", 60)
+
+	api, err := NewPanelAPI(NewPerformancePanel(jp), settingsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api
+}
+
+func panelRequest(api *PanelAPI, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, httptest.NewRequest(method, DefaultPanelAPIEndpoint+path, strings.NewReader(body)))
+	return w
+}
+
+func TestPanelAPISettings(t *testing.T) {
+	settingsPath := filepath.Join(t.TempDir(), "panel.json")
+	api := newTestPanelAPI(t, settingsPath)
+
+	// Fields left out keep their values
+	w := panelRequest(api, http.MethodPut, "/settings", `{"theme": "light", "refresh_rate": 500, "selected_tab": "metrics"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var settings PanelSettings
+	json.NewDecoder(w.Body).Decode(&settings)
+	if settings.Theme != "light" || settings.RefreshRate != 500 || settings.SelectedTab != "metrics" || settings.Position != "bottom-right" {
+		t.Fatalf("unexpected settings %+v", settings)
+	}
+
+	for _, body := range []string{`{"theme": "blue"}`, `{"refresh_rate": 5}`, `{"colour": "red"}`, `{`} {
+		if w := panelRequest(api, http.MethodPut, "/settings", body); w.Code != http.StatusBadRequest {
+			t.Fatalf("expected %s to be refused, got %d", body, w.Code)
+		}
+	}
+	if api.Panel.Config.Theme != "light" {
+		t.Fatalf("expected refused settings to leave the panel unchanged")
+	}
+
+	// Metrics are toggled, and only registered ones
+	if w := panelRequest(api, http.MethodPost, "/metrics", `{"metric": "api_latency"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if w := panelRequest(api, http.MethodPost, "/metrics", `{"metric": "fps"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if w := panelRequest(api, http.MethodPost, "/metrics", `{"metric": "missing"}`); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+	selected := strings.Join(api.Panel.SelectedMetrics, ",")
+	if selected != "memory_usage,page_load,first_contentful_paint,api_latency" {
+		t.Fatalf("unexpected selected metrics %s", selected)
+	}
+
+	// Each change is audited, as made by the request's address
+	entries, err := api.Panel.Jetpack.Audit.Query(context.Background(), core.AuditQuery{Component: "panel"})
+	changed := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Actor != "192.0.2.1:1234" {
+			t.Fatalf("unexpected actor %+v", entry)
+		}
+		changed = append(changed, entry.Setting)
+	}
+	if err != nil || strings.Join(changed, ",") != "refresh_rate,selected_tab,theme,selected_metrics,selected_metrics" {
+		t.Fatalf("unexpected audited changes %v %v", changed, err)
+	}
+
+	// The settings survive a restart
+	restarted := newTestPanelAPI(t, settingsPath)
+	if got := restarted.Panel.Settings(); got.Theme != "light" || got.RefreshRate != 500 || strings.Join(got.SelectedMetrics, ",") != selected {
+		t.Fatalf("unexpected restored settings %+v", got)
+	}
+
+	// Resetting restores the initial settings
+	if w := panelRequest(api, http.MethodDelete, "/settings", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if api.Panel.Config.Theme != "dark" || api.Panel.SelectedTab != "overview" || len(api.Panel.SelectedMetrics) != 4 {
+		t.Fatalf("expected the settings to be reset, got %+v", api.Panel.Settings())
+	}
+
+	if w := panelRequest(api, http.MethodPost, "/settings", "{}"); w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, PUT, DELETE" {
+		t.Fatalf("expected 405, got %d", w.Code)
+	}
+	if w := panelRequest(api, http.MethodGet, "/missing", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestPanelAPIRendersEveryTab(t *testing.T) {
+	api := newTestPanelAPI(t, "")
+	api.Panel.Jetpack.RecordOperation(core.OperationCall{Operation: "query:user", Duration: 12 * time.Millisecond})
+
+	for tab := range panelTabs {
+		if w := panelRequest(api, http.MethodPut, "/settings", `{"selected_tab": "`+tab+`"}`); w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body)
+		}
+		w := panelRequest(api, http.MethodGet, "/panel", "")
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `id="jetpack-performance-panel"`) {
+			t.Fatalf("expected the %s tab to render, got %d: %s", tab, w.Code, w.Body)
+		}
+		if tab == "metrics" && !strings.Contains(w.Body.String(), "checked") {
+			t.Fatalf("expected the selected metrics to be checked")
+		}
+		if tab == "api" && strings.Count(w.Body.String(), ">query:user</td>") != 2 {
+			t.Fatalf("expected the operation among the top and slowest operations")
+		}
+	}
+
+	if _, err := api.Panel.GenerateExtensionHTML(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPanelAPIStream(t *testing.T) {
+	api := newTestPanelAPI(t, "")
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	response, err := http.Get(server.URL + DefaultPanelAPIEndpoint + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected content type %s", response.Header.Get("Content-Type"))
+	}
+	reader := bufio.NewReader(response.Body)
+	for _, want := range []string{"event: metrics\n", "data: "} {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(line, want) {
+			t.Fatalf("expected %q, got %q", want, line)
+		}
+		if want == "data: " && !strings.Contains(line, `"latest_value":60`) {
+			t.Fatalf("expected the fps value, got %q", line)
+		}
+	}
+}
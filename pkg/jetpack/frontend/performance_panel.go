@@ -1,8 +1,8 @@
 package frontend
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
 	"html/template"
 	"strings"
 	"time"
@@ -24,6 +24,14 @@ type PanelConfig struct {
 	Resizable     bool     `json:"resizable"`
 	Collapsible   bool     `json:"collapsible"`
 	DefaultMetrics []string `json:"default_metrics"`
+	
+	// LighthouseEndpoint is where the Lighthouse tab runs audits, served by
+	// a LighthouseMonitor
+	LighthouseEndpoint string `json:"lighthouse_endpoint"`
+	
+	// APIEndpoint is where the panel saves its settings and streams its
+	// metrics, served by a PanelAPI
+	APIEndpoint string `json:"api_endpoint"`
 }
 
 // PerformancePanel represents the floating performance panel
@@ -37,6 +45,9 @@ type PerformancePanel struct {
 	SelectedTab   string
 	SelectedMetrics []string
 	LastUpdate    time.Time
+	
+	// Lighthouse supplies the Lighthouse tab's scores; nil shows none
+	Lighthouse *LighthouseMonitor
 }
 
 // NewPerformancePanel creates a new performance panel
@@ -65,6 +76,8 @@ func NewPerformancePanel(jetpack *core.Jetpack) *PerformancePanel {
 				"api_latency",
 				"error_rate",
 			},
+			LighthouseEndpoint: DefaultLighthouseEndpoint,
+			APIEndpoint:        DefaultPanelAPIEndpoint,
 		},
 		Visible:       true,
 		Collapsed:     false,
@@ -194,6 +207,36 @@ func (pp *PerformancePanel) GetPanelData() map[string]interface{} {
 	data["last_update"] = pp.LastUpdate
 	
 	// Add selected metrics data
+	data["selected_metrics"] = pp.selectedMetricsData()
+	
+	// Add all available metrics for selection
+	data["available_metrics"] = pp.Jetpack.MetricNames()
+	
+	// Add accessibility issues from the latest audits
+	data["accessibility"] = pp.Jetpack.GetAccessibilityIssues()
+	
+	// Add the busiest and slowest API operations of the last five minutes
+	operations, _ := pp.Jetpack.OperationUsage(context.Background(), 5*time.Minute)
+	data["top_operations"] = firstUsage(operations, maxPanelOperations)
+	data["slowest_operations"] = firstUsage(core.SlowestUsage(operations), maxPanelOperations)
+	
+	// Add the scores and opportunities of the latest Lighthouse audit
+	data["lighthouse_scores"] = []map[string]interface{}{}
+	data["lighthouse_opportunities"] = []LighthouseOpportunity{}
+	if pp.Lighthouse != nil {
+		if result := pp.Lighthouse.GetLatestResult(); result != nil {
+			data["lighthouse_scores"] = lighthouseScores(result)
+			data["lighthouse_opportunities"] = result.Opportunities
+			data["lighthouse_timestamp"] = result.Timestamp
+		}
+	}
+	
+	return data
+}
+
+// selectedMetricsData gets the values, stats and history of the selected
+// metrics
+func (pp *PerformancePanel) selectedMetricsData() []map[string]interface{} {
 	selectedMetricsData := make([]map[string]interface{}, 0, len(pp.SelectedMetrics))
 	for _, metricName := range pp.SelectedMetrics {
 		metric, err := pp.Jetpack.GetMetric(metricName)
@@ -221,20 +264,68 @@ func (pp *PerformancePanel) GetPanelData() map[string]interface{} {
 			metricData["average_value"] = avgValue
 		}
 		
+		// Get percentiles and trend over the last five minutes
+		stats, err := pp.Jetpack.GetMetricStats(metricName, 5*time.Minute)
+		if err == nil {
+			metricData["stats"] = stats
+		}
+		
+		// Get the last hour, a value a minute, for the chart
+		history, err := pp.Jetpack.GetMetricHistory(context.Background(), metricName, pp.LastUpdate.Add(-time.Hour), pp.LastUpdate, time.Minute)
+		if err == nil {
+			metricData["history"] = history
+		}
+		
 		selectedMetricsData = append(selectedMetricsData, metricData)
 	}
 	
-	data["selected_metrics"] = selectedMetricsData
-	
-	// Add all available metrics for selection
-	availableMetrics := make([]string, 0)
-	for name := range pp.Jetpack.Metrics {
-		availableMetrics = append(availableMetrics, name)
+	return selectedMetricsData
+}
+
+// maxPanelOperations caps the operations the API tab lists in each table
+const maxPanelOperations = 5
+
+// firstUsage returns at most the first n of usage
+func firstUsage(usage []core.UsageStats, n int) []core.UsageStats {
+	if len(usage) > n {
+		return usage[:n]
 	}
-	
-	data["available_metrics"] = availableMetrics
-	
-	return data
+	return usage
+}
+
+// lighthouseCategoryTitles names the categories in the order they are shown
+var lighthouseCategoryTitles = []struct{ id, title string }{
+	{"performance", "Performance"},
+	{"accessibility", "Accessibility"},
+	{"best-practices", "Best Practices"},
+	{"seo", "SEO"},
+	{"pwa", "PWA"},
+}
+
+// lighthouseScores lists a result's category scores out of 100, rated the
+// way Lighthouse colors them
+func lighthouseScores(result *LighthouseResult) []map[string]interface{} {
+	scores := make([]map[string]interface{}, 0, len(result.Categories))
+	for _, category := range lighthouseCategoryTitles {
+		score, ok := result.Categories[category.id]
+		if !ok {
+			continue
+		}
+		
+		rating := "poor"
+		if score >= 0.9 {
+			rating = "good"
+		} else if score >= 0.5 {
+			rating = "average"
+		}
+		scores = append(scores, map[string]interface{}{
+			"id":     category.id,
+			"title":  category.title,
+			"score":  int(score*100 + 0.5),
+			"rating": rating,
+		})
+	}
+	return scores
 }
 
 // GenerateHTML generates the HTML for the performance panel
@@ -353,6 +444,30 @@ func (pp *PerformancePanel) GenerateHTML() (string, error) {
 			">
 			Lighthouse
 		</div>
+		<div class="jetpack-panel-tab {{if eq .selected_tab "accessibility"}}active{{end}}" 
+			onclick="jetpackSelectTab('accessibility')" 
+			style="
+				padding: 5px 10px;
+				cursor: pointer;
+				{{if eq .selected_tab "accessibility"}}
+					background-color: {{if eq .theme "dark"}}rgba(60, 60, 60, 0.8){{else}}rgba(250, 250, 250, 0.8){{end}};
+					border-bottom: 2px solid #4285f4;
+				{{end}}
+			">
+			A11y
+		</div>
+		<div class="jetpack-panel-tab {{if eq .selected_tab "api"}}active{{end}}" 
+			onclick="jetpackSelectTab('api')" 
+			style="
+				padding: 5px 10px;
+				cursor: pointer;
+				{{if eq .selected_tab "api"}}
+					background-color: {{if eq .theme "dark"}}rgba(60, 60, 60, 0.8){{else}}rgba(250, 250, 250, 0.8){{end}};
+					border-bottom: 2px solid #4285f4;
+				{{end}}
+			">
+			API
+		</div>
 		<div class="jetpack-panel-tab {{if eq .selected_tab "settings"}}active{{end}}" 
 			onclick="jetpackSelectTab('settings')" 
 			style="
@@ -381,7 +496,7 @@ func (pp *PerformancePanel) GenerateHTML() (string, error) {
 					gap: 10px;
 				">
 					{{range .selected_metrics}}
-						<div class="jetpack-metric-card" style="
+						<div class="jetpack-metric-card" data-jetpack-metric="{{.name}}" style="
 							background-color: {{if eq $.theme "dark"}}rgba(50, 50, 50, 0.8){{else}}rgba(245, 245, 245, 0.8){{end}};
 							border-radius: 4px;
 							padding: 8px;
@@ -408,6 +523,14 @@ func (pp *PerformancePanel) GenerateHTML() (string, error) {
 							">
 								Avg: {{.average_value}} {{.unit}}
 							</div>
+							{{with .stats}}{{if .Count}}
+								<div class="jetpack-metric-percentiles" style="
+									font-size: 10px;
+									color: {{if eq $.theme "dark"}}#aaa{{else}}#777{{end}};
+								">
+									p50 {{printf "%.1f" .P50}} · p95 {{printf "%.1f" .P95}} · p99 {{printf "%.1f" .P99}}
+								</div>
+							{{end}}{{end}}
 						</div>
 					{{end}}
 				</div>
@@ -470,17 +593,17 @@ func (pp *PerformancePanel) GenerateHTML() (string, error) {
 					border-radius: 4px;
 					padding: 8px;
 				">
-					{{range .available_metrics}}
+					{{range $name := .available_metrics}}
 						<div class="jetpack-metric-item" style="
 							margin-bottom: 5px;
 							padding: 5px;
 							border-radius: 3px;
 							cursor: pointer;
 							background-color: {{if eq $.theme "dark"}}rgba(60, 60, 60, 0.8){{else}}rgba(255, 255, 255, 0.8){{end}};
-						" onclick="jetpackToggleMetric('{{.}}')">
+						" onclick="jetpackToggleMetric({{$name}})">
 							<div style="display: flex; justify-content: space-between; align-items: center;">
-								<span>{{.}}</span>
-								<input type="checkbox" {{range $.selected_metrics}}{{if eq . $.}}checked{{end}}{{end}}>
+								<span>{{$name}}</span>
+								<input type="checkbox" {{range $.selected_metrics}}{{if eq .name $name}}checked{{end}}{{end}}>
 							</div>
 						</div>
 					{{end}}
@@ -497,59 +620,36 @@ func (pp *PerformancePanel) GenerateHTML() (string, error) {
 					gap: 10px;
 					margin-bottom: 15px;
 				">
+					{{range .lighthouse_scores}}
 					<div class="jetpack-lighthouse-score" style="
-						background-color: {{if eq .theme "dark"}}rgba(50, 50, 50, 0.8){{else}}rgba(245, 245, 245, 0.8){{end}};
-						border-radius: 4px;
-						padding: 8px;
-						text-align: center;
-					">
-						<div style="font-weight: bold; margin-bottom: 5px;">Performance</div>
-						<div style="
-							font-size: 24px;
-							font-weight: bold;
-							color: #4caf50;
-						">85</div>
-					</div>
-					<div class="jetpack-lighthouse-score" style="
-						background-color: {{if eq .theme "dark"}}rgba(50, 50, 50, 0.8){{else}}rgba(245, 245, 245, 0.8){{end}};
-						border-radius: 4px;
-						padding: 8px;
-						text-align: center;
-					">
-						<div style="font-weight: bold; margin-bottom: 5px;">Accessibility</div>
-						<div style="
-							font-size: 24px;
-							font-weight: bold;
-							color: #4caf50;
-						">92</div>
-					</div>
-					<div class="jetpack-lighthouse-score" style="
-						background-color: {{if eq .theme "dark"}}rgba(50, 50, 50, 0.8){{else}}rgba(245, 245, 245, 0.8){{end}};
+						background-color: {{if eq $.theme "dark"}}rgba(50, 50, 50, 0.8){{else}}rgba(245, 245, 245, 0.8){{end}};
 						border-radius: 4px;
 						padding: 8px;
 						text-align: center;
 					">
-						<div style="font-weight: bold; margin-bottom: 5px;">Best Practices</div>
+						<div style="font-weight: bold; margin-bottom: 5px;">{{.title}}</div>
 						<div style="
 							font-size: 24px;
 							font-weight: bold;
-							color: #4caf50;
-						">87</div>
+							color: {{if eq .rating "good"}}#4caf50{{else if eq .rating "average"}}#ff9800{{else}}#f44336{{end}};
+						">{{.score}}</div>
 					</div>
-					<div class="jetpack-lighthouse-score" style="
-						background-color: {{if eq .theme "dark"}}rgba(50, 50, 50, 0.8){{else}}rgba(245, 245, 245, 0.8){{end}};
-						border-radius: 4px;
-						padding: 8px;
-						text-align: center;
-					">
-						<div style="font-weight: bold; margin-bottom: 5px;">SEO</div>
-						<div style="
-							font-size: 24px;
-							font-weight: bold;
-							color: #4caf50;
-						">95</div>
+					{{else}}
+					<div style="grid-column: 1 / -1; text-align: center; opacity: 0.7;">No audit run yet</div>
+					{{end}}
+				</div>
+				
+				{{if .lighthouse_opportunities}}
+				<h3 style="margin: 0 0 10px 0; font-size: 14px;">Opportunities</h3>
+				<div class="jetpack-lighthouse-opportunities" style="margin-bottom: 15px;">
+					{{range .lighthouse_opportunities}}
+					<div style="display: flex; justify-content: space-between; padding: 4px 0;">
+						<span>{{.Title}}</span>
+						<span style="font-weight: bold;">{{printf "%.0f" .SavingsMs}} ms</span>
 					</div>
+					{{end}}
 				</div>
+				{{end}}
 				
 				<button onclick="jetpackRunLighthouse()" style="
 					background-color: #4285f4;
@@ -639,6 +739,102 @@ func (pp *PerformancePanel) GenerateHTML() (string, error) {
 			</div>
 		{{end}}
 		
+		{{if eq .selected_tab "accessibility"}}
+			<div class="jetpack-panel-section">
+				<h3 style="margin: 0 0 10px 0; font-size: 14px;">Accessibility Issues</h3>
+				<div class="jetpack-a11y-list" style="
+					max-height: 300px;
+					overflow-y: auto;
+					background-color: {{if eq .theme "dark"}}rgba(50, 50, 50, 0.8){{else}}rgba(245, 245, 245, 0.8){{end}};
+					border-radius: 4px;
+					padding: 8px;
+				">
+					{{range .accessibility}}
+						<div class="jetpack-a11y-item" style="
+							margin-bottom: 5px;
+							padding-bottom: 5px;
+							border-bottom: 1px solid {{if eq $.theme "dark"}}#444{{else}}#ddd{{end}};
+						">
+							<div style="display: flex; justify-content: space-between;">
+								<span style="font-weight: bold; color: {{if eq .Severity "error"}}#f44336{{else}}#ff9800{{end}};">{{.Rule}}</span>
+								<span>{{.Component}}</span>
+							</div>
+							<div>{{.Message}}</div>
+							<code style="font-size: 10px; color: {{if eq $.theme "dark"}}#aaa{{else}}#777{{end}};">{{.Element}}</code>
+						</div>
+					{{else}}
+						<div style="text-align: center; padding: 10px; color: {{if eq $.theme "dark"}}#aaa{{else}}#777{{end}};">
+							No accessibility issues found
+						</div>
+					{{end}}
+				</div>
+			</div>
+		{{end}}
+		
+		{{if eq .selected_tab "api"}}
+			<div class="jetpack-panel-section">
+				<h3 style="margin: 0 0 10px 0; font-size: 14px;">Top Operations</h3>
+				<table class="jetpack-api-top" style="
+					width: 100%;
+					border-collapse: collapse;
+					background-color: {{if eq .theme "dark"}}rgba(50, 50, 50, 0.8){{else}}rgba(245, 245, 245, 0.8){{end}};
+					border-radius: 4px;
+				">
+					<tr style="text-align: left; color: {{if eq .theme "dark"}}#aaa{{else}}#777{{end}};">
+						<th style="padding: 4px;">Operation</th>
+						<th style="padding: 4px;">Calls</th>
+						<th style="padding: 4px;">p95</th>
+						<th style="padding: 4px;">Errors</th>
+					</tr>
+					{{range .top_operations}}
+						<tr style="border-top: 1px solid {{if eq $.theme "dark"}}#444{{else}}#ddd{{end}};">
+							<td style="padding: 4px; word-break: break-all;">{{.Name}}</td>
+							<td style="padding: 4px;">{{.Calls}}</td>
+							<td style="padding: 4px;">{{printf "%.1f" .P95Latency}} ms</td>
+							<td style="padding: 4px; {{if .Errors}}color: #f44336;{{end}}">{{.Errors}}</td>
+						</tr>
+					{{else}}
+						<tr>
+							<td colspan="4" style="text-align: center; padding: 10px; color: {{if eq $.theme "dark"}}#aaa{{else}}#777{{end}};">
+								No API calls in the last five minutes
+							</td>
+						</tr>
+					{{end}}
+				</table>
+			</div>
+			
+			<div class="jetpack-panel-section" style="margin-top: 15px;">
+				<h3 style="margin: 0 0 10px 0; font-size: 14px;">Slowest Operations</h3>
+				<table class="jetpack-api-slowest" style="
+					width: 100%;
+					border-collapse: collapse;
+					background-color: {{if eq .theme "dark"}}rgba(50, 50, 50, 0.8){{else}}rgba(245, 245, 245, 0.8){{end}};
+					border-radius: 4px;
+				">
+					<tr style="text-align: left; color: {{if eq .theme "dark"}}#aaa{{else}}#777{{end}};">
+						<th style="padding: 4px;">Operation</th>
+						<th style="padding: 4px;">Calls</th>
+						<th style="padding: 4px;">p95</th>
+						<th style="padding: 4px;">Errors</th>
+					</tr>
+					{{range .slowest_operations}}
+						<tr style="border-top: 1px solid {{if eq $.theme "dark"}}#444{{else}}#ddd{{end}};">
+							<td style="padding: 4px; word-break: break-all;">{{.Name}}</td>
+							<td style="padding: 4px;">{{.Calls}}</td>
+							<td style="padding: 4px;">{{printf "%.1f" .P95Latency}} ms</td>
+							<td style="padding: 4px; {{if .Errors}}color: #f44336;{{end}}">{{.Errors}}</td>
+						</tr>
+					{{else}}
+						<tr>
+							<td colspan="4" style="text-align: center; padding: 10px; color: {{if eq $.theme "dark"}}#aaa{{else}}#777{{end}};">
+								No API calls in the last five minutes
+							</td>
+						</tr>
+					{{end}}
+				</table>
+			</div>
+		{{end}}
+		
 		{{if eq .selected_tab "settings"}}
 			<div class="jetpack-panel-section">
 				<h3 style="margin: 0 0 10px 0; font-size: 14px;">Panel Settings</h3>
@@ -682,14 +878,14 @@ func (pp *PerformancePanel) GenerateHTML() (string, error) {
 					<div class="jetpack-setting-item" style="margin-bottom: 10px;">
 						<label style="display: block; margin-bottom: 5px;">Opacity: {{.opacity}}</label>
 						<input type="range" id="jetpack-opacity-setting" min="0.1" max="1" step="0.1" value="{{.opacity}}" 
-							oninput="jetpackUpdateSetting('opacity', this.value)" style="
+							onchange="jetpackUpdateSetting('opacity', this.value)" style="
 							width: 100%;
 						">
 					</div>
 					
 					<div class="jetpack-setting-item" style="margin-bottom: 10px;">
 						<label style="display: block; margin-bottom: 5px;">Refresh Rate (ms)</label>
-						<input type="number" id="jetpack-refresh-setting" min="100" max="10000" step="100" value="{{.Config.refresh_rate}}" 
+						<input type="number" id="jetpack-refresh-setting" min="100" max="10000" step="100" value="{{.Config.RefreshRate}}" 
 							onchange="jetpackUpdateSetting('refresh_rate', this.value)" style="
 							width: 100%;
 							padding: 5px;
@@ -702,7 +898,7 @@ func (pp *PerformancePanel) GenerateHTML() (string, error) {
 					
 					<div class="jetpack-setting-item" style="margin-bottom: 10px;">
 						<label style="display: flex; align-items: center;">
-							<input type="checkbox" id="jetpack-charts-setting" {{if .Config.show_charts}}checked{{end}} 
+							<input type="checkbox" id="jetpack-charts-setting" {{if .Config.ShowCharts}}checked{{end}} 
 								onchange="jetpackUpdateSetting('show_charts', this.checked)" style="
 								margin-right: 5px;
 							">
@@ -712,7 +908,7 @@ func (pp *PerformancePanel) GenerateHTML() (string, error) {
 					
 					<div class="jetpack-setting-item" style="margin-bottom: 10px;">
 						<label style="display: flex; align-items: center;">
-							<input type="checkbox" id="jetpack-alerts-setting" {{if .Config.show_alerts}}checked{{end}} 
+							<input type="checkbox" id="jetpack-alerts-setting" {{if .Config.ShowAlerts}}checked{{end}} 
 								onchange="jetpackUpdateSetting('show_alerts', this.checked)" style="
 								margin-right: 5px;
 							">
@@ -742,9 +938,51 @@ func (pp *PerformancePanel) GenerateHTML() (string, error) {
 	// Store panel data
 	const jetpackPanelData = {{.dataJSON}};
 	
+	// The panel's backend, which keeps its settings and streams its metrics
+	const jetpackAPI = {{.Config.APIEndpoint}};
+	
+	function jetpackRequest(method, path, body) {
+		const options = { method: method };
+		if (body !== undefined) {
+			options.headers = { 'Content-Type': 'application/json' };
+			options.body = JSON.stringify(body);
+		}
+		return fetch(jetpackAPI + path, options).then(response => {
+			if (!response.ok) {
+				return response.text().then(message => { throw new Error(message); });
+			}
+			return response;
+		});
+	}
+	
+	// Replace the panel with a fresh render from the backend
+	function jetpackRefreshPanel() {
+		return jetpackRequest('GET', '/panel')
+			.then(response => response.text())
+			.then(html => {
+				const container = document.createElement('div');
+				container.innerHTML = html;
+				const current = document.getElementById('jetpack-performance-panel');
+				const updated = container.querySelector('#jetpack-performance-panel');
+				if (!updated) {
+					current.remove();
+					return;
+				}
+				current.replaceWith(updated);
+				jetpackBindPanel();
+			});
+	}
+	
+	function jetpackSaveSettings(settings) {
+		return jetpackRequest('PUT', '/settings', settings)
+			.then(jetpackRefreshPanel)
+			.catch(error => console.error('Jetpack settings update failed:', error));
+	}
+	
 	// Panel functions
 	function jetpackHidePanel() {
 		document.getElementById('jetpack-performance-panel').style.display = 'none';
+		jetpackSaveSettings({ visible: false });
 	}
 	
 	function jetpackToggleCollapse() {
@@ -762,51 +1000,83 @@ func (pp *PerformancePanel) GenerateHTML() (string, error) {
 		// Update button text
 		const button = panel.querySelector('.jetpack-panel-controls button:first-child');
 		button.textContent = isCollapsed ? '▲' : '▼';
+		
+		jetpackSaveSettings({ collapsed: !isCollapsed });
 	}
 	
 	function jetpackSelectTab(tab) {
-		// This would be handled by the backend in a real implementation
-		console.log('Selected tab:', tab);
+		jetpackSaveSettings({ selected_tab: tab });
 	}
 	
 	function jetpackToggleMetric(metric) {
-		// This would be handled by the backend in a real implementation
-		console.log('Toggled metric:', metric);
+		jetpackRequest('POST', '/metrics', { metric: metric })
+			.then(jetpackRefreshPanel)
+			.catch(error => console.error('Jetpack metric update failed:', error));
 	}
 	
 	function jetpackUpdateSetting(setting, value) {
-		// This would be handled by the backend in a real implementation
-		console.log('Updated setting:', setting, value);
+		const settings = {};
+		if (setting === 'opacity') {
+			settings[setting] = parseFloat(value);
+		} else if (setting === 'refresh_rate') {
+			settings[setting] = parseInt(value, 10);
+		} else {
+			settings[setting] = value;
+		}
+		jetpackSaveSettings(settings);
 	}
 	
 	function jetpackResetSettings() {
-		// This would be handled by the backend in a real implementation
-		console.log('Reset settings');
+		jetpackRequest('DELETE', '/settings')
+			.then(jetpackRefreshPanel)
+			.catch(error => console.error('Jetpack settings reset failed:', error));
 	}
 	
 	function jetpackRunLighthouse() {
-		// This would be handled by the backend in a real implementation
-		console.log('Running Lighthouse audit');
+		// Audit this page, then reload to show the new scores
+		fetch({{.Config.LighthouseEndpoint}}, {
+			method: 'POST',
+			body: new URLSearchParams({ path: location.pathname })
+		}).then(response => {
+			if (!response.ok) {
+				return response.text().then(message => { throw new Error(message); });
+			}
+			location.reload();
+		}).catch(error => console.error('Lighthouse audit failed:', error));
 	}
 	
-	// Make panel draggable
-	const panel = document.getElementById('jetpack-performance-panel');
-	const header = panel.querySelector('.jetpack-panel-header');
+	// Show the values streamed at the refresh rate
+	function jetpackShowMetrics(metrics) {
+		metrics.forEach(metric => {
+			document.querySelectorAll('[data-jetpack-metric]').forEach(card => {
+				if (card.getAttribute('data-jetpack-metric') !== metric.name) return;
+				
+				const value = card.querySelector('.jetpack-metric-value');
+				if (value && metric.latest_value !== undefined) {
+					value.textContent = metric.latest_value + ' ' + metric.unit;
+				}
+				const average = card.querySelector('.jetpack-metric-avg');
+				if (average && metric.average_value !== undefined) {
+					average.textContent = 'Avg: ' + metric.average_value + ' ' + metric.unit;
+				}
+			});
+		});
+	}
 	
-	let isDragging = false;
-	let offsetX, offsetY;
+	if (window.EventSource && !window.jetpackStream) {
+		window.jetpackStream = new EventSource(jetpackAPI + '/stream');
+		window.jetpackStream.addEventListener('metrics', (e) => jetpackShowMetrics(JSON.parse(e.data)));
+	}
 	
-	header.addEventListener('mousedown', (e) => {
-		isDragging = true;
-		offsetX = e.clientX - panel.getBoundingClientRect().left;
-		offsetY = e.clientY - panel.getBoundingClientRect().top;
-	});
+	// Make panel draggable
+	let jetpackDrag = null;
 	
 	document.addEventListener('mousemove', (e) => {
-		if (!isDragging) return;
+		if (!jetpackDrag) return;
 		
-		const x = e.clientX - offsetX;
-		const y = e.clientY - offsetY;
+		const panel = document.getElementById('jetpack-performance-panel');
+		const x = e.clientX - jetpackDrag.offsetX;
+		const y = e.clientY - jetpackDrag.offsetY;
 		
 		panel.style.left = x + 'px';
 		panel.style.top = y + 'px';
@@ -815,26 +1085,41 @@ func (pp *PerformancePanel) GenerateHTML() (string, error) {
 	});
 	
 	document.addEventListener('mouseup', () => {
-		isDragging = false;
+		jetpackDrag = null;
 	});
 	
-	// Filter metrics
-	const filterInput = document.getElementById('jetpack-metrics-filter');
-	if (filterInput) {
-		filterInput.addEventListener('input', (e) => {
-			const filter = e.target.value.toLowerCase();
-			const metricItems = document.querySelectorAll('.jetpack-metric-item');
-			
-			metricItems.forEach(item => {
-				const metricName = item.textContent.trim().toLowerCase();
-				if (metricName.includes(filter)) {
-					item.style.display = 'block';
-				} else {
-					item.style.display = 'none';
-				}
-			});
+	// Wire up the panel, again each time it is refreshed
+	function jetpackBindPanel() {
+		const panel = document.getElementById('jetpack-performance-panel');
+		const header = panel.querySelector('.jetpack-panel-header');
+		
+		header.addEventListener('mousedown', (e) => {
+			jetpackDrag = {
+				offsetX: e.clientX - panel.getBoundingClientRect().left,
+				offsetY: e.clientY - panel.getBoundingClientRect().top
+			};
 		});
+		
+		// Filter metrics
+		const filterInput = document.getElementById('jetpack-metrics-filter');
+		if (filterInput) {
+			filterInput.addEventListener('input', (e) => {
+				const filter = e.target.value.toLowerCase();
+				const metricItems = document.querySelectorAll('.jetpack-metric-item');
+				
+				metricItems.forEach(item => {
+					const metricName = item.textContent.trim().toLowerCase();
+					if (metricName.includes(filter)) {
+						item.style.display = 'block';
+					} else {
+						item.style.display = 'none';
+					}
+				});
+			});
+		}
 	}
+	
+	jetpackBindPanel();
 </script>
 `
 	
@@ -856,6 +1141,11 @@ func (pp *PerformancePanel) GenerateHTML() (string, error) {
 		"selected_tab":     pp.SelectedTab,
 		"selected_metrics": data["selected_metrics"],
 		"available_metrics": data["available_metrics"],
+		"accessibility":    data["accessibility"],
+		"lighthouse_scores": data["lighthouse_scores"],
+		"lighthouse_opportunities": data["lighthouse_opportunities"],
+		"top_operations":   data["top_operations"],
+		"slowest_operations": data["slowest_operations"],
 		"Config":           pp.Config,
 		"dataJSON":         template.JS(string(dataJSON)),
 	})
@@ -868,6 +1158,13 @@ func (pp *PerformancePanel) GenerateHTML() (string, error) {
 
 // InjectIntoHTML injects the performance panel into an HTML page
 func (pp *PerformancePanel) InjectIntoHTML(html string) (string, error) {
+	return pp.InjectIntoHTMLWithNonce(html, "")
+}
+
+// InjectIntoHTMLWithNonce injects the panel like InjectIntoHTML, giving its
+// inline script and style a nonce, such as core.CSPNonce finds in the
+// request's context, so a strict Content-Security-Policy allows them
+func (pp *PerformancePanel) InjectIntoHTMLWithNonce(html, nonce string) (string, error) {
 	if !pp.Visible {
 		return html, nil
 	}
@@ -876,6 +1173,7 @@ func (pp *PerformancePanel) InjectIntoHTML(html string) (string, error) {
 	if err != nil {
 		return html, err
 	}
+	panelHTML = core.AddNonce(panelHTML, nonce)
 	
 	// Find the closing body tag
 	bodyCloseIndex := strings.LastIndex(html, "</body>")
@@ -890,6 +1188,12 @@ func (pp *PerformancePanel) InjectIntoHTML(html string) (string, error) {
 
 // GenerateExtensionHTML generates the HTML for the Chrome extension
 func (pp *PerformancePanel) GenerateExtensionHTML() (string, error) {
+	return pp.extensionHTML(false)
+}
+
+// extensionHTML generates the extension's HTML; packaged HTML loads its
+// script from panel.js, as extensions may not run inline scripts
+func (pp *PerformancePanel) extensionHTML(packaged bool) (string, error) {
 	data := pp.GetPanelData()
 	
 	// Convert data to JSON for JavaScript
@@ -1186,7 +1490,7 @@ func (pp *PerformancePanel) GenerateExtensionHTML() (string, error) {
 		<div class="header">
 			<h1>Jetpack Performance Monitor</h1>
 			<div>
-				<span>Last updated: {{.last_update}}</span>
+				{{if .packaged}}<span id="feed-status">Connecting…</span> · {{end}}<span>Last updated: <span id="last-update">{{.last_update}}</span></span>
 			</div>
 		</div>
 		
@@ -1203,7 +1507,7 @@ func (pp *PerformancePanel) GenerateExtensionHTML() (string, error) {
 				<h2>Key Metrics</h2>
 				<div class="metrics-grid">
 					{{range .selected_metrics}}
-						<div class="metric-item {{if .alert}}alert{{end}}">
+						<div class="metric-item {{if .alert}}alert{{end}}" data-metric="{{.name}}">
 							<div class="metric-name">{{.name}}</div>
 							<div class="metric-value {{if .alert}}alert{{end}}">{{.latest_value}} {{.unit}}</div>
 							<div class="metric-description">{{.description}}</div>
@@ -1215,10 +1519,13 @@ func (pp *PerformancePanel) GenerateExtensionHTML() (string, error) {
 			<div class="card">
 				<h2>Performance Overview</h2>
 				<div class="chart-container">
-					<!-- Chart would be rendered here using a library like Chart.js -->
+					{{if .performance_chart}}
+					{{.performance_chart}}
+					{{else}}
 					<div style="text-align: center; padding: 100px 0; color: {{if eq $.theme "dark"}}#aaa{{else}}#777{{end}};">
-						Performance chart will be displayed here
+						Charts are turned off in the settings
 					</div>
+					{{end}}
 				</div>
 			</div>
 			
@@ -1284,9 +1591,9 @@ func (pp *PerformancePanel) GenerateExtensionHTML() (string, error) {
 						</thead>
 						<tbody>
 							{{range .selected_metrics}}
-								<tr class="metric-row">
+								<tr class="metric-row" data-metric="{{.name}}">
 									<td style="padding: 10px; border-bottom: 1px solid {{if eq $.theme "dark"}}#444{{else}}#eee{{end}};">{{.name}}</td>
-									<td style="padding: 10px; border-bottom: 1px solid {{if eq $.theme "dark"}}#444{{else}}#eee{{end}};">{{.latest_value}}</td>
+									<td class="metric-row-value" style="padding: 10px; border-bottom: 1px solid {{if eq $.theme "dark"}}#444{{else}}#eee{{end}};">{{.latest_value}}</td>
 									<td style="padding: 10px; border-bottom: 1px solid {{if eq $.theme "dark"}}#444{{else}}#eee{{end}};">{{.unit}}</td>
 									<td style="padding: 10px; border-bottom: 1px solid {{if eq $.theme "dark"}}#444{{else}}#eee{{end}};">{{.type}}</td>
 									<td style="padding: 10px; border-bottom: 1px solid {{if eq $.theme "dark"}}#444{{else}}#eee{{end}};">
@@ -1319,22 +1626,14 @@ func (pp *PerformancePanel) GenerateExtensionHTML() (string, error) {
 			<div class="card">
 				<h2>Lighthouse Scores</h2>
 				<div class="lighthouse-scores">
+					{{range .lighthouse_scores}}
 					<div class="lighthouse-score">
-						<div class="score-circle good">85</div>
-						<div class="score-label">Performance</div>
-					</div>
-					<div class="lighthouse-score">
-						<div class="score-circle good">92</div>
-						<div class="score-label">Accessibility</div>
-					</div>
-					<div class="lighthouse-score">
-						<div class="score-circle good">87</div>
-						<div class="score-label">Best Practices</div>
-					</div>
-					<div class="lighthouse-score">
-						<div class="score-circle good">95</div>
-						<div class="score-label">SEO</div>
+						<div class="score-circle {{.rating}}">{{.score}}</div>
+						<div class="score-label">{{.title}}</div>
 					</div>
+					{{else}}
+					<div class="score-label">No audit run yet</div>
+					{{end}}
 				</div>
 				
 				<div style="text-align: center; margin-bottom: 20px;">
@@ -1515,6 +1814,11 @@ func (pp *PerformancePanel) GenerateExtensionHTML() (string, error) {
 			
 			<div class="card">
 				<h2>Waterfall Chart</h2>
+				{{if .waterfall_chart}}
+				<div class="chart-container">
+					{{.waterfall_chart}}
+				</div>
+				{{else}}
 				<div style="
 					height: 300px;
 					background-color: {{if eq .theme "dark"}}#3d3d3d{{else}}#f9f9f9{{end}};
@@ -1524,8 +1828,9 @@ func (pp *PerformancePanel) GenerateExtensionHTML() (string, error) {
 					justify-content: center;
 					color: {{if eq .theme "dark"}}#aaa{{else}}#777{{end}};
 				">
-					Waterfall chart will be displayed here
+					Charts are turned off in the settings
 				</div>
+				{{end}}
 			</div>
 		</div>
 		
@@ -1558,19 +1863,19 @@ func (pp *PerformancePanel) GenerateExtensionHTML() (string, error) {
 					
 					<div class="form-group">
 						<label for="refresh-setting">Refresh Rate (ms)</label>
-						<input type="number" id="refresh-setting" min="100" max="10000" step="100" value="{{.Config.refresh_rate}}">
+						<input type="number" id="refresh-setting" min="100" max="10000" step="100" value="{{.Config.RefreshRate}}">
 					</div>
 					
 					<div class="form-group">
 						<label>
-							<input type="checkbox" id="charts-setting" {{if .Config.show_charts}}checked{{end}}>
+							<input type="checkbox" id="charts-setting" {{if .Config.ShowCharts}}checked{{end}}>
 							Show Charts
 						</label>
 					</div>
 					
 					<div class="form-group">
 						<label>
-							<input type="checkbox" id="alerts-setting" {{if .Config.show_alerts}}checked{{end}}>
+							<input type="checkbox" id="alerts-setting" {{if .Config.ShowAlerts}}checked{{end}}>
 							Show Alerts
 						</label>
 					</div>
@@ -1616,47 +1921,33 @@ func (pp *PerformancePanel) GenerateExtensionHTML() (string, error) {
 		</div>
 	</div>
 	
+	{{if .packaged}}
+	<script src="panel.js"></script>
+	{{else}}
 	<script>
 		// Store panel data
 		const jetpackPanelData = {{.dataJSON}};
 		
-		// Tab switching
-		document.querySelectorAll('.tab').forEach(tab => {
-			tab.addEventListener('click', () => {
-				const tabId = tab.getAttribute('data-tab');
-				
-				// Update active tab
-				document.querySelectorAll('.tab').forEach(t => t.classList.remove('active'));
-				tab.classList.add('active');
-				
-				// Update active content
-				document.querySelectorAll('.tab-content').forEach(content => content.classList.remove('active'));
-				document.getElementById(tabId + '-tab').classList.add('active');
-			});
-		});
-		
-		// Metrics filtering
-		const metricsFilter = document.getElementById('metrics-filter');
-		if (metricsFilter) {
-			metricsFilter.addEventListener('input', (e) => {
-				const filter = e.target.value.toLowerCase();
-				const metricRows = document.querySelectorAll('.metric-row');
-				
-				metricRows.forEach(row => {
-					const name = row.querySelector('td:first-child').textContent.toLowerCase();
-					if (name.includes(filter)) {
-						row.style.display = 'table-row';
-					} else {
-						row.style.display = 'none';
-					}
-				});
-			});
-		}
+		{{.script}}
 	</script>
+	{{end}}
 </body>
 </html>
 `
 	
+	// Chart the selected metrics and the last page view's requests
+	var performanceChart, waterfallChart template.HTML
+	if pp.Config.ShowCharts {
+		performanceChart, err = pp.performanceChart(data["selected_metrics"].([]map[string]interface{}))
+		if err != nil {
+			return "", err
+		}
+		waterfallChart, err = pp.waterfallChart()
+		if err != nil {
+			return "", err
+		}
+	}
+	
 	// Create template
 	tmpl, err := template.New("extension").Parse(tmplStr)
 	if err != nil {
@@ -1673,8 +1964,13 @@ func (pp *PerformancePanel) GenerateExtensionHTML() (string, error) {
 		"selected_metrics": data["selected_metrics"],
 		"available_metrics": data["available_metrics"],
 		"last_update":      pp.LastUpdate.Format(time.RFC1123),
+		"lighthouse_scores": data["lighthouse_scores"],
+		"performance_chart": performanceChart,
+		"waterfall_chart":  waterfallChart,
 		"Config":           pp.Config,
 		"dataJSON":         template.JS(string(dataJSON)),
+		"script":           template.JS(extensionPanelScript),
+		"packaged":         packaged,
 	})
 	if err != nil {
 		return "", err
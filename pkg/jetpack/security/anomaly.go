@@ -0,0 +1,427 @@
+package security
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+const (
+	// maxClientRequests caps the requests remembered per client; rates
+	// above it count as it
+	maxClientRequests = 1000
+
+	// maxSuspiciousActivities caps the suspicious activities kept, the
+	// oldest dropped first
+	maxSuspiciousActivities = 100
+)
+
+// RequestActivity is a request the anomaly detector scores, with what its
+// client did within the detector's window, the request included
+type RequestActivity struct {
+	Time      time.Time
+	IP        string
+	Method    string
+	Path      string
+	Status    int
+	UserAgent string
+
+	// Requests is how many requests the IP sent, NotFound how many of
+	// them were answered 404 and Paths how many distinct paths they asked
+	// for
+	Requests int
+	NotFound int
+	Paths    int
+
+	// AuthFailures is how many failed logins TrackAuthFailure counted for
+	// the IP
+	AuthFailures int
+}
+
+// AnomalyScorer scores how suspicious a request is, explaining a nonzero
+// score with a reason. The detector sums the scores of its scorers.
+type AnomalyScorer interface {
+	Score(activity RequestActivity) (score float64, reason string)
+}
+
+// AnomalyScorerFunc adapts a function to an AnomalyScorer
+type AnomalyScorerFunc func(activity RequestActivity) (float64, string)
+
+// Score calls f
+func (f AnomalyScorerFunc) Score(activity RequestActivity) (float64, string) {
+	return f(activity)
+}
+
+// RateScorer flags clients sending more than Limit requests a window
+type RateScorer struct {
+	Limit int
+}
+
+// Score scores 1 over the limit
+func (s RateScorer) Score(activity RequestActivity) (float64, string) {
+	if s.Limit > 0 && activity.Requests > s.Limit {
+		return 1, fmt.Sprintf("%d requests, over the limit of %d", activity.Requests, s.Limit)
+	}
+	return 0, ""
+}
+
+// suspiciousPaths are probes for files and pages apps rarely serve, and
+// payloads of common attacks; paths are matched lowercased and unescaped
+var suspiciousPaths = []string{
+	"../", "/.env", "/.git", "/.ht", "/.aws", "/.ssh", "/wp-admin", "/wp-login", "/xmlrpc.php",
+	"/phpmyadmin", "/cgi-bin/", "/etc/passwd", "/proc/self", "<script", "javascript:",
+	"' or ", "union select", "${jndi:",
+}
+
+// PathScorer flags requests probing for files, admin pages and
+// vulnerabilities, unusual methods, and clients getting more than
+// NotFoundLimit 404s a window as scanners do
+type PathScorer struct {
+	NotFoundLimit int
+}
+
+// Score scores 1 for a probe or too many 404s, and 0.5 for an unusual method
+func (s PathScorer) Score(activity RequestActivity) (float64, string) {
+	var score float64
+	var reasons []string
+
+	path := strings.ToLower(activity.Path)
+	if unescaped, err := url.PathUnescape(path); err == nil {
+		path = unescaped
+	}
+	for _, pattern := range suspiciousPaths {
+		if strings.Contains(path, pattern) {
+			score++
+			reasons = append(reasons, fmt.Sprintf("probe for %q", pattern))
+			break
+		}
+	}
+
+	switch activity.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+	default:
+		score += 0.5
+		reasons = append(reasons, "unusual method "+activity.Method)
+	}
+
+	if s.NotFoundLimit > 0 && activity.NotFound > s.NotFoundLimit {
+		score++
+		reasons = append(reasons, fmt.Sprintf("%d not found responses", activity.NotFound))
+	}
+	return score, strings.Join(reasons, ", ")
+}
+
+// AuthFailureScorer flags requests from IPs failing to log in: 1 at Limit
+// failures a window, and a share of it below, so that failures add to
+// other signals
+type AuthFailureScorer struct {
+	Limit int
+}
+
+// Score scores the IP's failed logins
+func (s AuthFailureScorer) Score(activity RequestActivity) (float64, string) {
+	if s.Limit <= 0 || activity.AuthFailures == 0 {
+		return 0, ""
+	}
+	score := float64(activity.AuthFailures) / float64(s.Limit)
+	if score > 1 {
+		score = 1
+	}
+	return score, fmt.Sprintf("%d failed logins", activity.AuthFailures)
+}
+
+// Anomaly is a request scoring at or over the detector's threshold
+type Anomaly struct {
+	Time    time.Time `json:"time"`
+	IP      string    `json:"ip"`
+	Method  string    `json:"method"`
+	Path    string    `json:"path"`
+	Score   float64   `json:"score"`
+	Reasons []string  `json:"reasons"`
+}
+
+// String describes the anomaly for SuspiciousActivities
+func (a Anomaly) String() string {
+	return fmt.Sprintf("Suspicious request %s %s from IP %s scoring %.2f (%s) at %s",
+		a.Method, a.Path, a.IP, a.Score, strings.Join(a.Reasons, "; "), a.Time.Format(time.RFC3339))
+}
+
+// requestMark is a request remembered for a client's activity
+type requestMark struct {
+	time     time.Time
+	path     string
+	notFound bool
+}
+
+// clientActivity is what one IP did within the window
+type clientActivity struct {
+	requests     []requestMark
+	authFailures []time.Time
+	lastSeen     time.Time
+}
+
+// AnomalyDetector keeps a sliding window of every client's requests and
+// failed logins, scoring each request by it
+type AnomalyDetector struct {
+	// Window is how far back a client's activity counts
+	Window time.Duration
+
+	// Threshold is the total score at which a request is suspicious
+	Threshold float64
+
+	// Scorers score each request; their scores are summed
+	Scorers []AnomalyScorer
+
+	// MaxClients caps the clients tracked; the least recently seen are
+	// forgotten first
+	MaxClients int
+
+	mutex   sync.Mutex
+	clients map[string]*clientActivity
+}
+
+// NewAnomalyDetector creates a detector with a one minute window, flagging
+// clients sending over 600 requests, probing or getting over 30 404s, and
+// IPs failing to log in 5 times
+func NewAnomalyDetector() *AnomalyDetector {
+	return &AnomalyDetector{
+		Window:    time.Minute,
+		Threshold: 1,
+		Scorers: []AnomalyScorer{
+			RateScorer{Limit: 600},
+			PathScorer{NotFoundLimit: 30},
+			AuthFailureScorer{Limit: 5},
+		},
+		MaxClients: 10000,
+		clients:    make(map[string]*clientActivity),
+	}
+}
+
+// Observe records a request and scores it, returning the anomaly if it is
+// suspicious or nil. The activity fields of request are filled in from the
+// window.
+func (d *AnomalyDetector) Observe(request RequestActivity) *Anomaly {
+	if request.Time.IsZero() {
+		request.Time = time.Now()
+	}
+
+	d.mutex.Lock()
+	client := d.client(request.IP, request.Time)
+	client.requests = append(client.requests, requestMark{request.Time, request.Path, request.Status == http.StatusNotFound})
+	if len(client.requests) > maxClientRequests {
+		client.requests = append(client.requests[:0], client.requests[len(client.requests)-maxClientRequests:]...)
+	}
+	d.expire(client, request.Time)
+
+	paths := make(map[string]bool)
+	for _, mark := range client.requests {
+		paths[mark.path] = true
+		if mark.notFound {
+			request.NotFound++
+		}
+	}
+	request.Requests = len(client.requests)
+	request.Paths = len(paths)
+	request.AuthFailures = len(client.authFailures)
+	scorers := d.Scorers
+	threshold := d.Threshold
+	d.mutex.Unlock()
+
+	anomaly := &Anomaly{Time: request.Time, IP: request.IP, Method: request.Method, Path: request.Path}
+	for _, scorer := range scorers {
+		score, reason := scorer.Score(request)
+		if score <= 0 {
+			continue
+		}
+		anomaly.Score += score
+		if reason != "" {
+			anomaly.Reasons = append(anomaly.Reasons, reason)
+		}
+	}
+	if anomaly.Score < threshold || anomaly.Score == 0 {
+		return nil
+	}
+	return anomaly
+}
+
+// AuthFailure records a failed login from ip
+func (d *AnomalyDetector) AuthFailure(ip string, at time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	client := d.client(ip, at)
+	client.authFailures = append(client.authFailures, at)
+	if len(client.authFailures) > maxClientRequests {
+		client.authFailures = client.authFailures[1:]
+	}
+	d.expire(client, at)
+}
+
+// Prune forgets the clients idle for a window
+func (d *AnomalyDetector) Prune(now time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for ip, client := range d.clients {
+		if now.Sub(client.lastSeen) > d.Window {
+			delete(d.clients, ip)
+		}
+	}
+}
+
+// client returns the activity of ip, forgetting the least recently seen
+// client to make room for a new one. The caller holds the lock.
+func (d *AnomalyDetector) client(ip string, now time.Time) *clientActivity {
+	if d.clients == nil {
+		d.clients = make(map[string]*clientActivity)
+	}
+	client, ok := d.clients[ip]
+	if !ok {
+		if d.MaxClients > 0 && len(d.clients) >= d.MaxClients {
+			var oldest string
+			for other, activity := range d.clients {
+				if oldest == "" || activity.lastSeen.Before(d.clients[oldest].lastSeen) {
+					oldest = other
+				}
+			}
+			delete(d.clients, oldest)
+		}
+		client = &clientActivity{}
+		d.clients[ip] = client
+	}
+	client.lastSeen = now
+	return client
+}
+
+// expire drops a client's activity older than the window. The caller holds
+// the lock.
+func (d *AnomalyDetector) expire(client *clientActivity, now time.Time) {
+	since := now.Add(-d.Window)
+
+	i := 0
+	for i < len(client.requests) && client.requests[i].time.Before(since) {
+		i++
+	}
+	client.requests = client.requests[i:]
+
+	i = 0
+	for i < len(client.authFailures) && client.authFailures[i].Before(since) {
+		i++
+	}
+	client.authFailures = client.authFailures[i:]
+}
+
+// clientIP is the address a request came from. X-Forwarded-For is not
+// trusted, as any client can send it.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// statusWriter remembers the status of a response
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// Flush lets streaming handlers flush through the middleware
+func (w *statusWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets WebSocket handlers take over the connection
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("security: the response writer cannot be hijacked")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return hijacker.Hijack()
+}
+
+// observe scores a request, recording it as a suspicious activity and in
+// the anomaly_score metric when it is one. The suspicious_requests alert
+// rule fires on the metric.
+func (sm *SecurityMonitor) observe(request RequestActivity) {
+	anomaly := sm.Anomalies.Observe(request)
+	if anomaly == nil {
+		return
+	}
+
+	sm.mutex.Lock()
+	sm.addSuspiciousActivity(anomaly.String())
+	sm.updateSecurityMetrics()
+	sm.mutex.Unlock()
+
+	sm.ensureAnomalyMetric()
+	sm.Jetpack.RecordMetric("anomaly_score", anomaly.Score)
+	if sm.Jetpack.Alerts != nil {
+		sm.Jetpack.Alerts.Evaluate()
+	}
+}
+
+// ensureAnomalyMetric registers the anomaly_score metric and its alert rule
+// the first time a request is suspicious
+func (sm *SecurityMonitor) ensureAnomalyMetric() {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	if _, err := sm.Jetpack.GetMetric("anomaly_score"); err == nil {
+		return
+	}
+	sm.Jetpack.RegisterMetric(
+		core.MetricSuspiciousActivity,
+		"anomaly_score",
+		"Anomaly score of suspicious requests",
+		"score",
+		nil,
+		[]string{"security"},
+	)
+	if sm.Jetpack.Alerts != nil {
+		sm.Jetpack.Alerts.AddRule(core.AlertRule{
+			Name:        "suspicious_requests",
+			Metric:      "anomaly_score",
+			Stat:        "count",
+			Window:      sm.Anomalies.Window,
+			Comparison:  core.AboveOrEqual,
+			Threshold:   1,
+			Description: "Requests scored as suspicious by the security monitor",
+		})
+	}
+}
+
+// addSuspiciousActivity records a suspicious activity, dropping the oldest
+// past maxSuspiciousActivities. The caller holds the lock.
+func (sm *SecurityMonitor) addSuspiciousActivity(activity string) {
+	sm.SuspiciousActivities = append(sm.SuspiciousActivities, activity)
+	if over := len(sm.SuspiciousActivities) - maxSuspiciousActivities; over > 0 {
+		sm.SuspiciousActivities = append(sm.SuspiciousActivities[:0], sm.SuspiciousActivities[over:]...)
+	}
+}
@@ -0,0 +1,119 @@
+package security
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+func TestAnomalyDetector(t *testing.T) {
+	detector := NewAnomalyDetector()
+	detector.Scorers = []AnomalyScorer{RateScorer{Limit: 3}, PathScorer{NotFoundLimit: 2}, AuthFailureScorer{Limit: 4}}
+	now := time.Now()
+
+	// A normal client stays under every limit
+	for i := 0; i < 3; i++ {
+		if anomaly := detector.Observe(RequestActivity{Time: now, IP: "10.0.0.1", Method: http.MethodGet, Path: "/posts", Status: 200}); anomaly != nil {
+			t.Fatalf("expected a normal request not to be flagged, got %+v", anomaly)
+		}
+	}
+	anomaly := detector.Observe(RequestActivity{Time: now, IP: "10.0.0.1", Method: http.MethodGet, Path: "/posts", Status: 200})
+	if anomaly == nil || anomaly.Score != 1 || anomaly.Reasons[0] != "4 requests, over the limit of 3" {
+		t.Fatalf("expected the fourth request to be over the rate, got %+v", anomaly)
+	}
+
+	// The window slides
+	if anomaly := detector.Observe(RequestActivity{Time: now.Add(2 * time.Minute), IP: "10.0.0.1", Method: http.MethodGet, Path: "/posts", Status: 200}); anomaly != nil {
+		t.Fatalf("expected the old requests to have expired, got %+v", anomaly)
+	}
+
+	if anomaly := detector.Observe(RequestActivity{Time: now, IP: "10.0.0.2", Method: http.MethodGet, Path: "/static/%2e%2e/%2e%2e/etc/passwd", Status: 404}); anomaly == nil || !strings.Contains(anomaly.Reasons[0], "../") {
+		t.Fatalf("expected a path traversal to be flagged, got %+v", anomaly)
+	}
+
+	// Failed logins add to an unusual method
+	detector.AuthFailure("10.0.0.3", now)
+	detector.AuthFailure("10.0.0.3", now)
+	anomaly = detector.Observe(RequestActivity{Time: now, IP: "10.0.0.3", Method: "TRACE", Path: "/", Status: 405})
+	if anomaly == nil || anomaly.Score != 1 || len(anomaly.Reasons) != 2 {
+		t.Fatalf("expected the failed logins and method to add up, got %+v", anomaly)
+	}
+
+	// Custom scorers plug in
+	detector.Scorers = append(detector.Scorers, AnomalyScorerFunc(func(activity RequestActivity) (float64, string) {
+		if strings.Contains(activity.UserAgent, "sqlmap") {
+			return 1, "scanner user agent"
+		}
+		return 0, ""
+	}))
+	if anomaly := detector.Observe(RequestActivity{Time: now, IP: "10.0.0.4", Method: http.MethodGet, Path: "/", UserAgent: "sqlmap/1.7"}); anomaly == nil || anomaly.Reasons[0] != "scanner user agent" {
+		t.Fatalf("expected the custom scorer to flag the request, got %+v", anomaly)
+	}
+
+	detector.MaxClients = 4
+	detector.Observe(RequestActivity{Time: now.Add(time.Second), IP: "10.0.0.5", Method: http.MethodGet, Path: "/"})
+	if len(detector.clients) != 4 || detector.clients["10.0.0.5"] == nil {
+		t.Fatalf("expected the least recently seen client to be forgotten, got %d clients", len(detector.clients))
+	}
+	detector.Prune(now.Add(time.Hour))
+	if len(detector.clients) != 0 {
+		t.Fatalf("expected idle clients to be pruned")
+	}
+}
+
+func TestSecurityMiddlewareFlagsScanners(t *testing.T) {
+	jp := core.NewJetpack()
+	var alerts []core.Alert
+	jp.Alerts.AddNotifier("test", core.NotifierFunc(func(ctx context.Context, alert core.Alert) error {
+		alerts = append(alerts, alert)
+		return nil
+	}))
+	sm := NewSecurityMonitor(jp)
+	handler := sm.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" && !strings.HasPrefix(r.URL.Path, "/assets/") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+
+	request := func(path string) {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.RemoteAddr = "203.0.113.7:52100"
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	request("/")
+	request("/assets/app.js/.git")
+	if activities := sm.GetSuspiciousActivities(); len(activities) != 0 {
+		t.Fatalf("expected nothing suspicious yet, got %v", activities)
+	}
+
+	request("/.env")
+	activities := sm.GetSuspiciousActivities()
+	if len(activities) != 1 || !strings.Contains(activities[0], "GET /.env from IP 203.0.113.7") {
+		t.Fatalf("expected the probe to be recorded, got %v", activities)
+	}
+	if len(alerts) != 1 || alerts[0].Rule != "suspicious_requests" || alerts[0].State != core.AlertFiring {
+		t.Fatalf("expected the suspicious request to alert, got %+v", alerts)
+	}
+
+	// Failed logins from the same IP are correlated with its requests
+	for i := 0; i < 5; i++ {
+		sm.TrackAuthFailure("admin", "203.0.113.7")
+	}
+	request("/")
+	activities = sm.GetSuspiciousActivities()
+	if !strings.Contains(activities[len(activities)-1], "5 failed logins") {
+		t.Fatalf("expected the failed logins to flag the next request, got %v", activities)
+	}
+
+	sm.DetectAnomalies()
+	if activities := sm.GetSuspiciousActivities(); len(activities) != 0 {
+		t.Fatalf("expected a new detection period to start empty, got %v", activities)
+	}
+}
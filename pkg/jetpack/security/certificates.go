@@ -0,0 +1,229 @@
+package security
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+// MetricCertificateExpiry is the metric type of the days until a host's
+// certificate expires
+const MetricCertificateExpiry core.MetricType = "certificate_expiry"
+
+// DefaultCertificateExpiryDays are the days before expiry certificate alerts
+// fire at
+var DefaultCertificateExpiryDays = []int{30, 14, 7}
+
+// tlsDialTimeout is how long connecting to a host for its certificate may
+// take
+const tlsDialTimeout = 10 * time.Second
+
+// CertificateStatus is the certificate a host served at its latest check
+type CertificateStatus struct {
+	Host     string    `json:"host"`
+	Time     time.Time `json:"time"`
+	Subject  string    `json:"subject,omitempty"`
+	Issuer   string    `json:"issuer,omitempty"`
+	DNSNames []string  `json:"dns_names,omitempty"`
+	NotAfter time.Time `json:"not_after,omitempty"`
+	Error    string    `json:"error,omitempty"`
+
+	// DaysRemaining is the days until the first certificate of the chain
+	// expires, negative once it has
+	DaysRemaining float64 `json:"days_remaining"`
+}
+
+// dialTLS connects to host, on port 443 unless it has one, and returns the
+// connection's state. The chain is not verified, so that expired and
+// self-signed certificates can be reported rather than failing.
+func dialTLS(host string) (tls.ConnectionState, error) {
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, "443")
+	}
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: tlsDialTimeout}, "tcp", host, &tls.Config{
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer conn.Close()
+
+	state := conn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return state, fmt.Errorf("%s sent no certificate", host)
+	}
+	return state, nil
+}
+
+// firstExpiring is the certificate of a chain expiring first, as an expired
+// intermediate breaks the chain as much as an expired leaf
+func firstExpiring(chain []*x509.Certificate) *x509.Certificate {
+	first := chain[0]
+	for _, cert := range chain[1:] {
+		if cert.NotAfter.Before(first.NotAfter) {
+			first = cert
+		}
+	}
+	return first
+}
+
+// CheckCertificate checks when the certificate host serves expires
+func (sm *SecurityMonitor) CheckCertificate(host string) (CertificateStatus, error) {
+	status := CertificateStatus{Host: host, Time: time.Now()}
+	state, err := dialTLS(host)
+	if err != nil {
+		status.Error = err.Error()
+		return status, err
+	}
+
+	leaf := state.PeerCertificates[0]
+	expiring := firstExpiring(state.PeerCertificates)
+	status.Subject = leaf.Subject.String()
+	status.Issuer = leaf.Issuer.String()
+	status.DNSNames = leaf.DNSNames
+	status.NotAfter = expiring.NotAfter
+	status.DaysRemaining = expiring.NotAfter.Sub(status.Time).Hours() / 24
+	return status, nil
+}
+
+// CheckCertificates checks the certificates of Config.CertificateHosts,
+// records the days each has left in its cert_days_remaining@<host> metric
+// and evaluates the alert rules, so expiry alerts fire right away. A
+// certificate within the largest of Config.CertificateExpiryDays of expiring
+// is recorded as a vulnerability, fixed once it is renewed.
+func (sm *SecurityMonitor) CheckCertificates() []CertificateStatus {
+	sm.mutex.RLock()
+	hosts := append([]string(nil), sm.Config.CertificateHosts...)
+	sm.mutex.RUnlock()
+
+	statuses := make([]CertificateStatus, 0, len(hosts))
+	for _, host := range hosts {
+		status, err := sm.CheckCertificate(host)
+		if err != nil {
+			sm.Jetpack.ReportError(core.ErrorReport{
+				Source:    "jetpack",
+				Component: "security",
+				Message:   fmt.Sprintf("checking the certificate of %s: %v", host, err),
+			})
+		} else {
+			sm.ensureCertificateMetric(host)
+			sm.Jetpack.RecordMetric("cert_days_remaining@"+host, status.DaysRemaining)
+			sm.recordCertificate(status)
+		}
+		statuses = append(statuses, status)
+	}
+
+	if sm.Jetpack.Alerts != nil && len(hosts) > 0 {
+		sm.Jetpack.Alerts.Evaluate()
+	}
+	return statuses
+}
+
+// ensureCertificateMetric registers a host's cert_days_remaining metric, and
+// an alert rule for each of Config.CertificateExpiryDays, the first time its
+// certificate is checked
+func (sm *SecurityMonitor) ensureCertificateMetric(host string) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	metric := "cert_days_remaining@" + host
+	if _, err := sm.Jetpack.GetMetric(metric); err == nil {
+		return
+	}
+	sm.Jetpack.RegisterMetric(
+		MetricCertificateExpiry,
+		metric,
+		"Days until the certificate of "+host+" expires",
+		"days",
+		nil,
+		[]string{"security", "host:" + host},
+	)
+	if sm.Jetpack.Alerts == nil {
+		return
+	}
+	for _, days := range sm.certificateExpiryDays() {
+		sm.Jetpack.Alerts.AddRule(core.AlertRule{
+			Name:        fmt.Sprintf("cert_expiry_%dd@%s", days, host),
+			Metric:      metric,
+			Window:      2 * sm.Config.CertificateCheckInterval,
+			Comparison:  core.BelowOrEqual,
+			Threshold:   float64(days),
+			Description: fmt.Sprintf("The certificate of %s expires within %d days", host, days),
+		})
+	}
+}
+
+// certificateExpiryDays are Config.CertificateExpiryDays, or the defaults,
+// largest first. The caller holds the lock.
+func (sm *SecurityMonitor) certificateExpiryDays() []int {
+	days := sm.Config.CertificateExpiryDays
+	if len(days) == 0 {
+		days = DefaultCertificateExpiryDays
+	}
+	days = append([]int(nil), days...)
+	sort.Sort(sort.Reverse(sort.IntSlice(days)))
+	return days
+}
+
+// recordCertificate records a certificate close to expiring as a
+// vulnerability, rated by the days it has left, and marks it fixed once
+// renewed
+func (sm *SecurityMonitor) recordCertificate(status CertificateStatus) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	id := "certificate:" + status.Host
+	days := sm.certificateExpiryDays()
+	if status.DaysRemaining > float64(days[0]) {
+		if vuln, ok := sm.Vulnerabilities[id]; ok {
+			vuln.Fixed = true
+		}
+		sm.updateSecurityMetrics()
+		return
+	}
+
+	level := SecurityLevelLow
+	description := fmt.Sprintf("The certificate of %s expires in %.0f days, on %s", status.Host, status.DaysRemaining, status.NotAfter.Format("2006-01-02"))
+	switch {
+	case status.DaysRemaining <= 0:
+		level = SecurityLevelCritical
+		description = fmt.Sprintf("The certificate of %s expired on %s", status.Host, status.NotAfter.Format("2006-01-02"))
+	case status.DaysRemaining <= float64(days[len(days)-1]):
+		level = SecurityLevelHigh
+	case len(days) > 1 && status.DaysRemaining <= float64(days[len(days)-2]):
+		level = SecurityLevelMedium
+	}
+
+	detected := status.Time
+	if existing, ok := sm.Vulnerabilities[id]; ok && !existing.Fixed {
+		detected = existing.Timestamp
+	}
+	sm.Vulnerabilities[id] = &Vulnerability{
+		ID:          id,
+		Type:        VulnInsecureCrypto,
+		Level:       level,
+		Description: description,
+		Location:    status.Host,
+		Timestamp:   detected,
+		Remediation: "Renew the certificate, and automate renewal such as with ACME",
+	}
+	sm.updateSecurityMetrics()
+}
+
+// startCertificateMonitoring checks the certificates every
+// Config.CertificateCheckInterval
+func (sm *SecurityMonitor) startCertificateMonitoring() {
+	ticker := time.NewTicker(sm.Config.CertificateCheckInterval)
+	defer ticker.Stop()
+
+	sm.CheckCertificates()
+
+	for range ticker.C {
+		sm.CheckCertificates()
+	}
+}
@@ -0,0 +1,125 @@
+package security
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+// certificateExpiringIn creates a self-signed certificate for localhost
+// expiring after d
+func certificateExpiringIn(t *testing.T, d time.Duration) *tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(d),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestCheckCertificates(t *testing.T) {
+	var mutex sync.Mutex
+	cert := certificateExpiringIn(t, 10*24*time.Hour)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return cert, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	jp := core.NewJetpack()
+	var alerts []core.Alert
+	jp.Alerts.AddNotifier("test", core.NotifierFunc(func(ctx context.Context, alert core.Alert) error {
+		alerts = append(alerts, alert)
+		return nil
+	}))
+	sm := NewSecurityMonitor(jp)
+	host := listener.Addr().String()
+	sm.Config.CertificateHosts = []string{host, "127.0.0.1:1"}
+
+	statuses := sm.CheckCertificates()
+	if len(statuses) != 2 || statuses[0].DaysRemaining < 9.9 || statuses[0].DaysRemaining > 10 || statuses[0].Subject != "CN=localhost" {
+		t.Fatalf("unexpected statuses %+v", statuses)
+	}
+	if statuses[1].Error == "" {
+		t.Fatalf("expected the closed port to fail, got %+v", statuses[1])
+	}
+	if days, err := jp.GetMetricLatest("cert_days_remaining@" + host); err != nil || days < 9.9 {
+		t.Fatalf("expected the days remaining to be recorded, got %v %v", days, err)
+	}
+
+	// The 30 and 14 day alerts fire, the 7 day one waits
+	firing := make(map[string]bool)
+	for _, alert := range alerts {
+		firing[alert.Rule] = alert.State == core.AlertFiring
+	}
+	if len(alerts) != 2 || !firing["cert_expiry_30d@"+host] || !firing["cert_expiry_14d@"+host] {
+		t.Fatalf("expected the 30 and 14 day alerts to fire, got %+v", alerts)
+	}
+	vuln, err := sm.GetVulnerability("certificate:" + host)
+	if err != nil || vuln.Level != SecurityLevelMedium || vuln.Type != VulnInsecureCrypto {
+		t.Fatalf("expected the expiring certificate to be a vulnerability, got %+v %v", vuln, err)
+	}
+	if result, err := sm.CheckTLSConfiguration(host); err != nil || result["certificate"].(map[string]interface{})["days_remaining"].(float64) < 9.9 {
+		t.Fatalf("expected the TLS check to report the days remaining, got %v %v", result, err)
+	}
+
+	// Renewing resolves the alerts and fixes the vulnerability
+	mutex.Lock()
+	cert = certificateExpiringIn(t, 90*24*time.Hour)
+	mutex.Unlock()
+	sm.Config.CertificateHosts = []string{host}
+	sm.CheckCertificates()
+	if len(alerts) != 4 || alerts[2].State != core.AlertResolved || alerts[3].State != core.AlertResolved {
+		t.Fatalf("expected the alerts to resolve, got %+v", alerts)
+	}
+	if vuln, _ := sm.GetVulnerability("certificate:" + host); vuln == nil || !vuln.Fixed {
+		t.Fatalf("expected the renewed certificate to be fixed, got %+v", vuln)
+	}
+
+	// Expired certificates are critical
+	mutex.Lock()
+	cert = certificateExpiringIn(t, -time.Hour)
+	mutex.Unlock()
+	if statuses := sm.CheckCertificates(); statuses[0].DaysRemaining >= 0 {
+		t.Fatalf("expected negative days remaining, got %+v", statuses[0])
+	}
+	if vuln, _ := sm.GetVulnerability("certificate:" + host); vuln == nil || vuln.Level != SecurityLevelCritical || vuln.Fixed {
+		t.Fatalf("expected the expired certificate to be critical, got %+v", vuln)
+	}
+}
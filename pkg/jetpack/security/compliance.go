@@ -0,0 +1,543 @@
+package security
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+// MetricCompliance is the metric type of compliance scores
+const MetricCompliance core.MetricType = "compliance"
+
+// ComplianceStatus is the outcome of a compliance check
+type ComplianceStatus string
+
+const (
+	CompliancePass ComplianceStatus = "pass"
+	ComplianceFail ComplianceStatus = "fail"
+
+	// ComplianceSkipped checks lack the evidence to decide, such as header
+	// checks without Config.TargetURL; they do not count toward the score
+	ComplianceSkipped ComplianceStatus = "skipped"
+)
+
+// complianceWeights weigh the checks of each level in the score
+var complianceWeights = map[SecurityLevel]float64{
+	SecurityLevelCritical: 10,
+	SecurityLevelHigh:     5,
+	SecurityLevelMedium:   3,
+	SecurityLevelLow:      1,
+}
+
+// ComplianceEvidence is what the checks of a profile decide on, collected
+// once per run
+type ComplianceEvidence struct {
+	Config SecurityConfig
+
+	// Vulnerabilities are the monitor's open vulnerabilities
+	Vulnerabilities []*Vulnerability
+
+	// Middleware is whether the monitor's Middleware is in use
+	Middleware bool
+
+	// Headers are the response headers of Config.TargetURL, nil without one
+	// or when it could not be fetched
+	Headers http.Header
+
+	// TLS is the connection state of Config.TargetURL when it is HTTPS
+	TLS *tls.ConnectionState
+
+	// RedirectsToHTTPS is whether the plain HTTP URL of an HTTPS
+	// Config.TargetURL redirects to HTTPS, nil when unknown
+	RedirectsToHTTPS *bool
+
+	// Certificates are the certificates of Config.CertificateHosts
+	Certificates []CertificateStatus
+
+	// Errors are why evidence is missing
+	Errors []string
+}
+
+// ComplianceCheck is one control of a profile
+type ComplianceCheck struct {
+	// ID is the control's identifier in its standard, such as V14.4.3
+	ID          string
+	Title       string
+	Level       SecurityLevel
+	Remediation string
+
+	// Run decides the check from the evidence, describing what it found
+	Run func(evidence *ComplianceEvidence) (ComplianceStatus, string)
+}
+
+// ComplianceProfile is a standard's checklist
+type ComplianceProfile struct {
+	Name   string
+	Title  string
+	Checks []ComplianceCheck
+}
+
+var (
+	complianceMutex    sync.RWMutex
+	complianceProfiles = make(map[string]ComplianceProfile)
+)
+
+// RegisterComplianceProfile adds a profile, or replaces the profile of the
+// same name
+func RegisterComplianceProfile(profile ComplianceProfile) {
+	complianceMutex.Lock()
+	defer complianceMutex.Unlock()
+
+	complianceProfiles[profile.Name] = profile
+}
+
+// ComplianceProfiles returns the names of the registered profiles, sorted
+func ComplianceProfiles() []string {
+	complianceMutex.RLock()
+	defer complianceMutex.RUnlock()
+
+	names := make([]string, 0, len(complianceProfiles))
+	for name := range complianceProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ComplianceResult is the outcome of one check
+type ComplianceResult struct {
+	ID          string           `json:"id"`
+	Title       string           `json:"title"`
+	Level       SecurityLevel    `json:"level"`
+	Status      ComplianceStatus `json:"status"`
+	Evidence    string           `json:"evidence"`
+	Remediation string           `json:"remediation,omitempty"`
+}
+
+// ComplianceReport is the outcome of a profile's checks. Score is the share
+// of the decided checks that pass, in percent, weighing each by its level.
+type ComplianceReport struct {
+	Profile string             `json:"profile"`
+	Title   string             `json:"title"`
+	Time    time.Time          `json:"time"`
+	Score   float64            `json:"score"`
+	Passed  int                `json:"passed"`
+	Failed  int                `json:"failed"`
+	Skipped int                `json:"skipped"`
+	Results []ComplianceResult `json:"results"`
+	Errors  []string           `json:"errors,omitempty"`
+}
+
+// JSON encodes the report as indented JSON
+func (r *ComplianceReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// complianceTemplate renders a report as a standalone page
+var complianceTemplate = template.Must(template.New("compliance").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} compliance report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #ddd; padding: 6px 8px; text-align: left; vertical-align: top; }
+.pass { color: #1a7f37; } .fail { color: #cf222e; } .skipped { color: #888; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Generated at {{.Time.Format "2006-01-02 15:04:05 MST"}}</p>
+<p><strong>Score: {{printf "%.1f" .Score}}%</strong> &mdash; {{.Passed}} passed, {{.Failed}} failed, {{.Skipped}} skipped</p>
+{{if .Errors}}<ul>{{range .Errors}}<li>{{.}}</li>{{end}}</ul>{{end}}
+<table>
+<tr><th>Check</th><th>Title</th><th>Level</th><th>Status</th><th>Evidence</th><th>Remediation</th></tr>
+{{range .Results}}<tr><td>{{.ID}}</td><td>{{.Title}}</td><td>{{.Level}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{.Evidence}}</td><td>{{if eq .Status "fail"}}{{.Remediation}}{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// HTML renders the report as a standalone page
+func (r *ComplianceReport) HTML() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := complianceTemplate.Execute(&buf, r); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RunCompliance runs a profile's checks, records the score in the
+// compliance_score@<profile> metric and keeps the report for
+// ComplianceReport
+func (sm *SecurityMonitor) RunCompliance(profile string) (*ComplianceReport, error) {
+	complianceMutex.RLock()
+	p, ok := complianceProfiles[profile]
+	complianceMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown compliance profile %q, expected one of %s", profile, strings.Join(ComplianceProfiles(), ", "))
+	}
+
+	evidence := sm.collectEvidence()
+	report := &ComplianceReport{Profile: p.Name, Title: p.Title, Time: time.Now(), Errors: evidence.Errors}
+
+	var passed, total float64
+	for _, check := range p.Checks {
+		status, found := check.Run(evidence)
+		report.Results = append(report.Results, ComplianceResult{
+			ID:          check.ID,
+			Title:       check.Title,
+			Level:       check.Level,
+			Status:      status,
+			Evidence:    found,
+			Remediation: check.Remediation,
+		})
+		switch status {
+		case CompliancePass:
+			report.Passed++
+			passed += complianceWeights[check.Level]
+			total += complianceWeights[check.Level]
+		case ComplianceFail:
+			report.Failed++
+			total += complianceWeights[check.Level]
+		default:
+			report.Skipped++
+		}
+	}
+	if total > 0 {
+		report.Score = passed / total * 100
+	}
+
+	metric := "compliance_score@" + p.Name
+	sm.mutex.Lock()
+	if _, err := sm.Jetpack.GetMetric(metric); err != nil {
+		sm.Jetpack.RegisterMetric(MetricCompliance, metric, p.Title+" compliance score", "%", nil, []string{"security", "compliance"})
+	}
+	if sm.complianceReports == nil {
+		sm.complianceReports = make(map[string]*ComplianceReport)
+	}
+	sm.complianceReports[p.Name] = report
+	sm.mutex.Unlock()
+
+	sm.Jetpack.RecordMetric(metric, report.Score)
+	return report, nil
+}
+
+// ComplianceReport returns the latest report of a profile, or nil if it has
+// not run
+func (sm *SecurityMonitor) ComplianceReport(profile string) *ComplianceReport {
+	sm.mutex.RLock()
+	defer sm.mutex.RUnlock()
+
+	return sm.complianceReports[profile]
+}
+
+// collectEvidence gathers what the checks decide on: the monitor's state,
+// the response and TLS connection of Config.TargetURL, and the certificates
+// of Config.CertificateHosts
+func (sm *SecurityMonitor) collectEvidence() *ComplianceEvidence {
+	sm.mutex.RLock()
+	evidence := &ComplianceEvidence{Config: sm.Config, Middleware: sm.middleware}
+	for _, vuln := range sm.Vulnerabilities {
+		if !vuln.Fixed {
+			copied := *vuln
+			evidence.Vulnerabilities = append(evidence.Vulnerabilities, &copied)
+		}
+	}
+	sm.mutex.RUnlock()
+
+	if target := evidence.Config.TargetURL; target != "" {
+		// Certificates are checked on their own, so an invalid one does not
+		// hide the headers; redirects are not followed, so that they can be
+		// seen
+		client := &http.Client{
+			Timeout:       10 * time.Second,
+			Transport:     &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		}
+		defer client.CloseIdleConnections()
+		if resp, err := client.Get(target); err != nil {
+			evidence.Errors = append(evidence.Errors, fmt.Sprintf("fetching %s: %v", target, err))
+		} else {
+			resp.Body.Close()
+			evidence.Headers = resp.Header
+			evidence.TLS = resp.TLS
+		}
+
+		if parsed, err := url.Parse(target); err == nil && parsed.Scheme == "https" {
+			plain := *parsed
+			plain.Scheme = "http"
+			plain.Host = parsed.Hostname()
+			if resp, err := client.Get(plain.String()); err != nil {
+				evidence.Errors = append(evidence.Errors, fmt.Sprintf("fetching %s: %v", plain.String(), err))
+			} else {
+				resp.Body.Close()
+				redirects := resp.StatusCode >= 300 && resp.StatusCode < 400 && strings.HasPrefix(resp.Header.Get("Location"), "https://")
+				evidence.RedirectsToHTTPS = &redirects
+			}
+		}
+	}
+
+	for _, host := range evidence.Config.CertificateHosts {
+		status, err := sm.CheckCertificate(host)
+		if err != nil {
+			evidence.Errors = append(evidence.Errors, fmt.Sprintf("checking the certificate of %s: %v", host, err))
+			continue
+		}
+		evidence.Certificates = append(evidence.Certificates, status)
+	}
+	return evidence
+}
+
+// headerCheck passes when the response has a header containing want, any
+// value if empty
+func headerCheck(id, title string, level SecurityLevel, name, want string) ComplianceCheck {
+	return ComplianceCheck{
+		ID:          id,
+		Title:       title,
+		Level:       level,
+		Remediation: fmt.Sprintf("Send %s with every response, such as with SecurityMonitor.Middleware", name),
+		Run: func(evidence *ComplianceEvidence) (ComplianceStatus, string) {
+			if evidence.Headers == nil {
+				return ComplianceSkipped, "no response to check; set Config.TargetURL"
+			}
+			value := evidence.Headers.Get(name)
+			switch {
+			case value == "":
+				return ComplianceFail, name + " is missing"
+			case !strings.Contains(strings.ToLower(value), strings.ToLower(want)):
+				return ComplianceFail, fmt.Sprintf("%s is %q, expected it to contain %q", name, value, want)
+			}
+			return CompliancePass, fmt.Sprintf("%s: %s", name, value)
+		},
+	}
+}
+
+// vulnerabilityCheck passes when the monitor has no open vulnerability
+// matching
+func vulnerabilityCheck(id, title string, level SecurityLevel, remediation, kind string, matches func(*Vulnerability) bool) ComplianceCheck {
+	return ComplianceCheck{
+		ID:          id,
+		Title:       title,
+		Level:       level,
+		Remediation: remediation,
+		Run: func(evidence *ComplianceEvidence) (ComplianceStatus, string) {
+			var found []string
+			for _, vuln := range evidence.Vulnerabilities {
+				if matches(vuln) {
+					found = append(found, vuln.Location)
+				}
+			}
+			if len(found) > 0 {
+				sort.Strings(found)
+				return ComplianceFail, fmt.Sprintf("%d open %s: %s", len(found), kind, strings.Join(found, ", "))
+			}
+			return CompliancePass, "no open " + kind
+		},
+	}
+}
+
+// tlsVersionCheck passes when the target negotiates TLS 1.2 or later
+func tlsVersionCheck(id string) ComplianceCheck {
+	return ComplianceCheck{
+		ID:          id,
+		Title:       "Only TLS 1.2 or later is negotiated",
+		Level:       SecurityLevelHigh,
+		Remediation: "Set the server's minimum TLS version to 1.2",
+		Run: func(evidence *ComplianceEvidence) (ComplianceStatus, string) {
+			if evidence.TLS == nil {
+				return ComplianceSkipped, "no HTTPS connection to check; set Config.TargetURL to an https URL"
+			}
+			version := map[uint16]string{tls.VersionTLS10: "TLS 1.0", tls.VersionTLS11: "TLS 1.1", tls.VersionTLS12: "TLS 1.2", tls.VersionTLS13: "TLS 1.3"}[evidence.TLS.Version]
+			if evidence.TLS.Version < tls.VersionTLS12 {
+				return ComplianceFail, "negotiated " + version
+			}
+			return CompliancePass, "negotiated " + version
+		},
+	}
+}
+
+// cipherSuiteCheck passes when the target negotiates no insecure cipher
+// suite
+func cipherSuiteCheck(id string) ComplianceCheck {
+	return ComplianceCheck{
+		ID:          id,
+		Title:       "Only strong cipher suites are negotiated",
+		Level:       SecurityLevelHigh,
+		Remediation: "Disable the RC4, 3DES and CBC-SHA1 cipher suites",
+		Run: func(evidence *ComplianceEvidence) (ComplianceStatus, string) {
+			if evidence.TLS == nil {
+				return ComplianceSkipped, "no HTTPS connection to check; set Config.TargetURL to an https URL"
+			}
+			name := tls.CipherSuiteName(evidence.TLS.CipherSuite)
+			for _, suite := range tls.InsecureCipherSuites() {
+				if suite.ID == evidence.TLS.CipherSuite {
+					return ComplianceFail, "negotiated " + name
+				}
+			}
+			return CompliancePass, "negotiated " + name
+		},
+	}
+}
+
+// httpsCheck passes when the target is HTTPS and plain HTTP redirects to it
+func httpsCheck(id string) ComplianceCheck {
+	return ComplianceCheck{
+		ID:          id,
+		Title:       "TLS is used for all connections, and HTTP redirects to HTTPS",
+		Level:       SecurityLevelHigh,
+		Remediation: "Serve the app over HTTPS only, redirecting HTTP requests to it",
+		Run: func(evidence *ComplianceEvidence) (ComplianceStatus, string) {
+			target := evidence.Config.TargetURL
+			switch {
+			case target == "":
+				return ComplianceSkipped, "no URL to check; set Config.TargetURL"
+			case !strings.HasPrefix(target, "https://"):
+				return ComplianceFail, target + " is not HTTPS"
+			case evidence.RedirectsToHTTPS == nil:
+				return ComplianceSkipped, "could not fetch the HTTP URL"
+			case !*evidence.RedirectsToHTTPS:
+				return ComplianceFail, "HTTP is served without redirecting to HTTPS"
+			}
+			return CompliancePass, "HTTP redirects to HTTPS"
+		},
+	}
+}
+
+// certificateCheck passes when every certificate is valid for over 30 days
+func certificateCheck(id string) ComplianceCheck {
+	return ComplianceCheck{
+		ID:          id,
+		Title:       "Certificates are valid for more than 30 days",
+		Level:       SecurityLevelHigh,
+		Remediation: "Renew the certificates, and automate renewal such as with ACME",
+		Run: func(evidence *ComplianceEvidence) (ComplianceStatus, string) {
+			if len(evidence.Certificates) == 0 {
+				return ComplianceSkipped, "no certificates to check; set Config.CertificateHosts"
+			}
+			var expiring, valid []string
+			for _, cert := range evidence.Certificates {
+				summary := fmt.Sprintf("%s (%.0f days)", cert.Host, cert.DaysRemaining)
+				if cert.DaysRemaining <= 30 {
+					expiring = append(expiring, summary)
+				} else {
+					valid = append(valid, summary)
+				}
+			}
+			if len(expiring) > 0 {
+				return ComplianceFail, "expiring: " + strings.Join(expiring, ", ")
+			}
+			return CompliancePass, "valid: " + strings.Join(valid, ", ")
+		},
+	}
+}
+
+// monitoringCheck passes when anomaly detection and failed login tracking
+// run on the app's requests. Whether the middleware is in use can only be
+// told in the app's process, so it is skipped elsewhere, as in gopm.
+func monitoringCheck(id, title string) ComplianceCheck {
+	return ComplianceCheck{
+		ID:          id,
+		Title:       title,
+		Level:       SecurityLevelMedium,
+		Remediation: "Enable anomaly detection and auth tracking, serve the app through SecurityMonitor.Middleware and call TrackAuthFailure on failed logins",
+		Run: func(evidence *ComplianceEvidence) (ComplianceStatus, string) {
+			var missing []string
+			if !evidence.Config.AnomalyDetectionEnabled {
+				missing = append(missing, "anomaly detection is disabled")
+			}
+			if !evidence.Config.AuthTrackingEnabled {
+				missing = append(missing, "auth tracking is disabled")
+			}
+			if len(missing) > 0 {
+				return ComplianceFail, strings.Join(missing, ", ")
+			}
+			if !evidence.Middleware {
+				return ComplianceSkipped, "the middleware is not in use in this process"
+			}
+			return CompliancePass, "requests are scored and failed logins tracked"
+		},
+	}
+}
+
+func isDependencyVulnerability(vuln *Vulnerability) bool {
+	return vuln.Type == VulnOutdatedLibrary
+}
+
+func isSecretVulnerability(vuln *Vulnerability) bool {
+	return vuln.Type == VulnDataExposure
+}
+
+func isDebugVulnerability(vuln *Vulnerability) bool {
+	return vuln.Type == VulnMisconfiguration && (strings.HasPrefix(vuln.ID, "exposure:") || strings.HasSuffix(vuln.ID, ":debug-endpoint"))
+}
+
+func init() {
+	RegisterComplianceProfile(ComplianceProfile{
+		Name:  "owasp-asvs",
+		Title: "OWASP ASVS 4.0",
+		Checks: []ComplianceCheck{
+			monitoringCheck("V2.2.1", "Anti-automation controls protect against credential stuffing and brute force"),
+			vulnerabilityCheck("V2.10.4", "Secrets and API keys are not included in the source or responses", SecurityLevelCritical,
+				"Revoke and rotate the credentials, and load them from the environment or a secret store", "secret exposures", isSecretVulnerability),
+			httpsCheck("V9.1.1"),
+			cipherSuiteCheck("V9.1.2"),
+			tlsVersionCheck("V9.1.3"),
+			vulnerabilityCheck("V14.2.1", "All components are up to date", SecurityLevelHigh,
+				"Run gopm audit and update the vulnerable dependencies", "vulnerable dependencies", isDependencyVulnerability),
+			vulnerabilityCheck("V14.3.2", "Debug modes are disabled in production", SecurityLevelMedium,
+				"Stop serving pprof, expvar and repository files in production", "debug endpoints", isDebugVulnerability),
+			headerCheck("V14.4.3", "A Content-Security-Policy header is sent", SecurityLevelMedium, "Content-Security-Policy", ""),
+			headerCheck("V14.4.4", "X-Content-Type-Options: nosniff is sent", SecurityLevelLow, "X-Content-Type-Options", "nosniff"),
+			headerCheck("V14.4.5", "Strict-Transport-Security is sent", SecurityLevelMedium, "Strict-Transport-Security", "max-age="),
+			headerCheck("V14.4.6", "A Referrer-Policy header is sent", SecurityLevelLow, "Referrer-Policy", ""),
+			headerCheck("V14.4.7", "Framing by other origins is denied", SecurityLevelMedium, "X-Frame-Options", ""),
+		},
+	})
+
+	RegisterComplianceProfile(ComplianceProfile{
+		Name:  "cis",
+		Title: "CIS-style web server hardening",
+		Checks: []ComplianceCheck{
+			httpsCheck("tls-only"),
+			tlsVersionCheck("tls-protocols"),
+			cipherSuiteCheck("tls-ciphers"),
+			certificateCheck("tls-certificates"),
+			headerCheck("hsts", "HSTS is enabled with includeSubDomains", SecurityLevelMedium, "Strict-Transport-Security", "includeSubDomains"),
+			headerCheck("clickjacking", "Framing by other origins is denied", SecurityLevelMedium, "X-Frame-Options", ""),
+			{
+				ID:          "server-banner",
+				Title:       "Responses do not disclose server software versions",
+				Level:       SecurityLevelLow,
+				Remediation: "Stop sending version numbers in the Server and X-Powered-By headers",
+				Run: func(evidence *ComplianceEvidence) (ComplianceStatus, string) {
+					if evidence.Headers == nil {
+						return ComplianceSkipped, "no response to check; set Config.TargetURL"
+					}
+					for _, name := range []string{"Server", "X-Powered-By"} {
+						if value := evidence.Headers.Get(name); strings.ContainsAny(value, "0123456789") {
+							return ComplianceFail, fmt.Sprintf("%s: %s", name, value)
+						}
+					}
+					return CompliancePass, "no versions disclosed"
+				},
+			},
+			vulnerabilityCheck("patching", "Dependencies have no known vulnerabilities", SecurityLevelHigh,
+				"Run gopm audit and update the vulnerable dependencies", "vulnerable dependencies", isDependencyVulnerability),
+			vulnerabilityCheck("debug-endpoints", "Debug and repository files are not served", SecurityLevelMedium,
+				"Stop serving pprof, expvar and repository files in production", "debug endpoints", isDebugVulnerability),
+			monitoringCheck("monitoring", "Requests and failed logins are monitored"),
+		},
+	})
+}
@@ -0,0 +1,126 @@
+package security
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+func TestRunCompliance(t *testing.T) {
+	sm := NewSecurityMonitor(core.NewJetpack())
+	server := httptest.NewTLSServer(sm.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "nginx/1.18.0")
+		w.Write([]byte("ok"))
+	})))
+	defer server.Close()
+	sm.Config.TargetURL = server.URL
+	sm.Vulnerabilities["secret:config.yaml:3:aws-access-key"] = &Vulnerability{
+		ID: "secret:config.yaml:3:aws-access-key", Type: VulnDataExposure, Level: SecurityLevelCritical, Location: "config.yaml:3",
+	}
+	sm.Vulnerabilities["dependency:GO-1:golang.org/x/net"] = &Vulnerability{
+		ID: "dependency:GO-1:golang.org/x/net", Type: VulnOutdatedLibrary, Level: SecurityLevelHigh, Location: "go.mod", Fixed: true,
+	}
+
+	report, err := sm.RunCompliance("owasp-asvs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := make(map[string]ComplianceResult)
+	for _, result := range report.Results {
+		results[result.ID] = result
+	}
+	for id, want := range map[string]ComplianceStatus{
+		"V2.2.1":  CompliancePass,
+		"V2.10.4": ComplianceFail,
+		"V9.1.2":  CompliancePass,
+		"V9.1.3":  CompliancePass,
+		"V14.2.1": CompliancePass,
+		"V14.3.2": CompliancePass,
+		"V14.4.3": CompliancePass,
+		"V14.4.5": CompliancePass,
+		"V14.4.7": CompliancePass,
+	} {
+		if results[id].Status != want {
+			t.Fatalf("expected %s to be %s, got %+v", id, want, results[id])
+		}
+	}
+	if results["V2.10.4"].Evidence != "1 open secret exposures: config.yaml:3" || !strings.Contains(results["V14.4.5"].Evidence, "max-age=31536000") {
+		t.Fatalf("unexpected evidence %+v", results)
+	}
+	// Everything decided passes but the critical secret, weighing 10 of 42,
+	// unless something listens for plain HTTP to decide V9.1.1
+	if results["V9.1.1"].Status == ComplianceSkipped && (report.Failed != 1 || report.Skipped != 1 ||
+		report.Score < 76.1 || report.Score > 76.2 || len(report.Errors) != 1) {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if score, err := sm.Jetpack.GetMetricLatest("compliance_score@owasp-asvs"); err != nil || score != report.Score {
+		t.Fatalf("expected the score to be recorded, got %v %v", score, err)
+	}
+	if sm.ComplianceReport("owasp-asvs") != report {
+		t.Fatalf("expected the report to be kept")
+	}
+
+	cis, err := sm.RunCompliance("cis")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range cis.Results {
+		if result.ID == "server-banner" && (result.Status != ComplianceFail || result.Evidence != "Server: nginx/1.18.0") {
+			t.Fatalf("expected the server version to fail the banner check, got %+v", result)
+		}
+	}
+
+	data, err := report.JSON()
+	var decoded ComplianceReport
+	if err != nil || json.Unmarshal(data, &decoded) != nil || decoded.Profile != "owasp-asvs" || len(decoded.Results) != len(report.Results) {
+		t.Fatalf("expected the report to round-trip through JSON, got %s %v", data, err)
+	}
+	page, err := report.HTML()
+	if err != nil || !strings.Contains(string(page), `<td class="fail">fail</td>`) || !strings.Contains(string(page), "OWASP ASVS 4.0") {
+		t.Fatalf("unexpected HTML report %s %v", page, err)
+	}
+
+	if _, err := sm.RunCompliance("pci"); err == nil || !strings.Contains(err.Error(), "cis, owasp-asvs") {
+		t.Fatalf("expected an unknown profile to be refused, got %v", err)
+	}
+}
+
+func TestCheckComplianceCustomProfile(t *testing.T) {
+	RegisterComplianceProfile(ComplianceProfile{
+		Name:  "test-profile",
+		Title: "Test",
+		Checks: []ComplianceCheck{
+			{ID: "auto-fix", Title: "AutoFix is on", Level: SecurityLevelLow, Run: func(evidence *ComplianceEvidence) (ComplianceStatus, string) {
+				if evidence.Config.AutoFix {
+					return CompliancePass, "on"
+				}
+				return ComplianceFail, "off"
+			}},
+		},
+	})
+	defer func() {
+		complianceMutex.Lock()
+		delete(complianceProfiles, "test-profile")
+		complianceMutex.Unlock()
+	}()
+
+	sm := NewSecurityMonitor(core.NewJetpack())
+	sm.Config.ComplianceProfiles = []string{"test-profile", "missing"}
+	sm.CheckCompliance()
+	if report := sm.ComplianceReport("test-profile"); report == nil || report.Score != 0 || report.Results[0].Evidence != "off" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if errors := sm.Jetpack.GetErrors(); len(errors) != 1 || !strings.Contains(errors[0].Message, `"missing"`) {
+		t.Fatalf("expected the unknown profile to be reported, got %+v", errors)
+	}
+
+	sm.Config.AutoFix = true
+	sm.CheckCompliance()
+	if report := sm.ComplianceReport("test-profile"); report.Score != 100 {
+		t.Fatalf("expected the check to pass, got %+v", report)
+	}
+}
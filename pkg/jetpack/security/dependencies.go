@@ -0,0 +1,109 @@
+package security
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultAuditReport is where gopm audit writes its report, relative to the
+// project
+const DefaultAuditReport = ".gopm/audit.json"
+
+// auditReport is the part of gopm audit's report the monitor reads
+type auditReport struct {
+	Time       time.Time `json:"time"`
+	Advisories []struct {
+		ID       string   `json:"id"`
+		Aliases  []string `json:"aliases"`
+		Package  string   `json:"package"`
+		Version  string   `json:"version"`
+		Indirect bool     `json:"indirect"`
+		FixedIn  string   `json:"fixed_in"`
+		Severity string   `json:"severity"`
+		Summary  string   `json:"summary"`
+		URL      string   `json:"url"`
+	} `json:"advisories"`
+}
+
+// dependencyVulnerabilityID is the ID of the vulnerability an advisory
+// reports in a package
+func dependencyVulnerabilityID(advisory, pkg string) string {
+	return "dependency:" + advisory + ":" + pkg
+}
+
+// scanDependencies records a VulnOutdatedLibrary vulnerability for each
+// advisory of the audit report, and marks those no longer in it fixed. A
+// project that was never audited has none. The caller holds the lock.
+func (sm *SecurityMonitor) scanDependencies() error {
+	if sm.Config.AuditReport == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(sm.Config.AuditReport)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("reading the audit report: %v", err)
+	}
+	var report auditReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return fmt.Errorf("reading the audit report %s: %v", sm.Config.AuditReport, err)
+	}
+
+	found := make(map[string]bool)
+	for _, advisory := range report.Advisories {
+		id := dependencyVulnerabilityID(advisory.ID, advisory.Package)
+		found[id] = true
+
+		remediation := fmt.Sprintf("Update %s to %s or later with go get %s@%s", advisory.Package, advisory.FixedIn, advisory.Package, advisory.FixedIn)
+		if advisory.FixedIn == "" {
+			remediation = fmt.Sprintf("No version of %s fixes it yet; avoid the affected code or replace the dependency", advisory.Package)
+		}
+		location := "go.mod"
+		if advisory.Indirect {
+			location = "go.mod (indirect)"
+		}
+		detected := report.Time
+		if existing, ok := sm.Vulnerabilities[id]; ok && !existing.Fixed {
+			detected = existing.Timestamp
+		}
+
+		sm.Vulnerabilities[id] = &Vulnerability{
+			ID:          id,
+			Type:        VulnOutdatedLibrary,
+			Level:       advisoryLevel(advisory.Severity),
+			Description: fmt.Sprintf("%s@%s: %s", advisory.Package, advisory.Version, advisory.Summary),
+			Location:    location,
+			Timestamp:   detected,
+			Remediation: remediation,
+			References:  append([]string{advisory.URL}, advisory.Aliases...),
+			Package:     advisory.Package,
+			Version:     advisory.Version,
+			FixedIn:     advisory.FixedIn,
+		}
+	}
+
+	for id, vuln := range sm.Vulnerabilities {
+		if vuln.Type == VulnOutdatedLibrary && !found[id] {
+			vuln.Fixed = true
+		}
+	}
+	return nil
+}
+
+// advisoryLevel maps an advisory's severity to a level; advisories without
+// one, as most in the Go database are, count as high
+func advisoryLevel(severity string) SecurityLevel {
+	switch strings.ToUpper(severity) {
+	case "CRITICAL":
+		return SecurityLevelCritical
+	case "MODERATE", "MEDIUM":
+		return SecurityLevelMedium
+	case "LOW":
+		return SecurityLevelLow
+	}
+	return SecurityLevelHigh
+}
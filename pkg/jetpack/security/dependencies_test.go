@@ -0,0 +1,72 @@
+package security
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+func TestScanDependencies(t *testing.T) {
+	sm := NewSecurityMonitor(core.NewJetpack())
+	sm.Config.AuditReport = filepath.Join(t.TempDir(), DefaultAuditReport)
+
+	// A project that was never audited has no dependency findings
+	sm.ScanVulnerabilities()
+	if vulns := sm.GetVulnerabilities(); len(vulns) != 0 {
+		t.Fatalf("expected no vulnerabilities, got %+v", vulns)
+	}
+
+	write := func(report string) {
+		ioutil.WriteFile(sm.Config.AuditReport, []byte(report), 0644)
+	}
+	if err := os.MkdirAll(filepath.Dir(sm.Config.AuditReport), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write(`{"time": "2026-01-02T03:04:05Z", "modules": 12, "advisories": [
+		{"id": "GO-2023-1988", "aliases": ["CVE-2023-3978"], "package": "golang.org/x/net", "version": "v0.7.0",
+			"fixed_in": "v0.13.0", "summary": "Improper rendering of text nodes", "url": "https://pkg.go.dev/vuln/GO-2023-1988"},
+		{"id": "GHSA-xxxx", "package": "github.com/example/yaml", "version": "v1.0.0", "indirect": true,
+			"severity": "MODERATE", "summary": "Denial of service"}
+	]}`)
+	sm.ScanVulnerabilities()
+
+	net, err := sm.GetVulnerability("dependency:GO-2023-1988:golang.org/x/net")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if net.Type != VulnOutdatedLibrary || net.Level != SecurityLevelHigh || net.Package != "golang.org/x/net" ||
+		net.Version != "v0.7.0" || net.FixedIn != "v0.13.0" || net.Location != "go.mod" ||
+		!strings.Contains(net.Remediation, "go get golang.org/x/net@v0.13.0") || net.References[1] != "CVE-2023-3978" {
+		t.Fatalf("unexpected vulnerability %+v", net)
+	}
+	yaml, err := sm.GetVulnerability("dependency:GHSA-xxxx:github.com/example/yaml")
+	if err != nil || yaml.Level != SecurityLevelMedium || yaml.Location != "go.mod (indirect)" || !strings.Contains(yaml.Remediation, "No version") {
+		t.Fatalf("unexpected vulnerability %+v %v", yaml, err)
+	}
+
+	// Updating the dependency fixes it, and the scan after drops it
+	write(`{"time": "2026-01-03T03:04:05Z", "advisories": [
+		{"id": "GHSA-xxxx", "package": "github.com/example/yaml", "version": "v1.0.0", "severity": "MODERATE"}
+	]}`)
+	sm.ScanVulnerabilities()
+	if net, _ := sm.GetVulnerability("dependency:GO-2023-1988:golang.org/x/net"); net == nil || !net.Fixed {
+		t.Fatalf("expected the updated dependency to be fixed, got %+v", net)
+	}
+	if yaml, _ := sm.GetVulnerability("dependency:GHSA-xxxx:github.com/example/yaml"); yaml == nil || yaml.Fixed || yaml.Timestamp.Day() != 2 {
+		t.Fatalf("expected the open advisory to keep when it was found, got %+v", yaml)
+	}
+	sm.ScanVulnerabilities()
+	if _, err := sm.GetVulnerability("dependency:GO-2023-1988:golang.org/x/net"); err == nil {
+		t.Fatalf("expected the fixed vulnerability to be dropped")
+	}
+
+	write(`{"advisories": [`)
+	sm.ScanVulnerabilities()
+	if errors := sm.Jetpack.GetErrors(); len(errors) == 0 || !strings.Contains(errors[len(errors)-1].Message, "audit report") {
+		t.Fatalf("expected an unreadable report to be reported, got %+v", errors)
+	}
+}
@@ -0,0 +1,490 @@
+package security
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+// FilterAction is what the request filter does with a request matching a
+// rule
+type FilterAction string
+
+const (
+	// FilterBlock answers the request 403 Forbidden
+	FilterBlock FilterAction = "block"
+
+	// FilterChallenge answers the request with a page that sets a cookie
+	// from JavaScript and reloads, letting browsers through and stopping
+	// clients that run no scripts, as most scanners. Requests other than GET
+	// and HEAD cannot be reloaded, so they are blocked.
+	FilterChallenge FilterAction = "challenge"
+
+	// FilterLog only records the match
+	FilterLog FilterAction = "log"
+)
+
+// challengeCookie is the cookie a passed challenge sets
+const challengeCookie = "jetpack_challenge"
+
+// FilterRule is a signature of an attack; a request any of whose values
+// match one of Patterns matches the rule
+type FilterRule struct {
+	Name        string
+	Description string
+	Action      FilterAction
+	Patterns    []*regexp.Regexp
+}
+
+// DefaultFilterRules returns rules blocking SQL injection, XSS and path
+// traversal. Besides the signatures, they match the payloads the
+// vulnerability scanner tries.
+func DefaultFilterRules() []FilterRule {
+	return []FilterRule{
+		{
+			Name:        "sqli",
+			Description: "SQL injection",
+			Action:      FilterBlock,
+			Patterns: append(payloadPatterns(sqlInjectionPayloads),
+				regexp.MustCompile(`(?i)\bunion\b(\s+all)?\s+select\b`),
+				regexp.MustCompile(`(?i)'\s*(or|and)\s+'?\w+'?\s*=\s*'?\w+`),
+				regexp.MustCompile(`(?i)'\s*(--|#|/\*)`),
+				regexp.MustCompile(`(?i);\s*(drop|truncate|alter)\s+table\b`),
+				regexp.MustCompile(`(?i)\b(sleep|pg_sleep|benchmark)\s*\(|\bwaitfor\s+delay\b`),
+			),
+		},
+		{
+			Name:        "xss",
+			Description: "cross-site scripting",
+			Action:      FilterBlock,
+			Patterns: append(payloadPatterns(xssPayloads),
+				regexp.MustCompile(`(?i)<\s*(script|iframe|object|embed|svg)\b`),
+				regexp.MustCompile(`(?i)<[^>]*\bon[a-z]+\s*=`),
+				regexp.MustCompile(`(?i)\bjavascript\s*:`),
+			),
+		},
+		{
+			Name:        "path_traversal",
+			Description: "path traversal",
+			Action:      FilterBlock,
+			Patterns: []*regexp.Regexp{
+				regexp.MustCompile(`\.\.[/\\]|[/\\]\.\.$`),
+				regexp.MustCompile(`(?i)/etc/(passwd|shadow)\b|/proc/self/|\b[a-z]:\\windows\\`),
+			},
+		},
+	}
+}
+
+// payloadPatterns matches payloads case insensitively, with any run of
+// whitespace for their spaces
+func payloadPatterns(payloads []string) []*regexp.Regexp {
+	patterns := make([]*regexp.Regexp, len(payloads))
+	for i, payload := range payloads {
+		quoted := strings.Replace(regexp.QuoteMeta(payload), " ", `\s+`, -1)
+		patterns[i] = regexp.MustCompile("(?i)" + quoted)
+	}
+	return patterns
+}
+
+// FilterMatch is a request the filter matched a rule against
+type FilterMatch struct {
+	Time   time.Time    `json:"time"`
+	Rule   string       `json:"rule"`
+	Action FilterAction `json:"action"`
+	IP     string       `json:"ip"`
+	Method string       `json:"method"`
+	Path   string       `json:"path"`
+
+	// Location is where in the request the match is, such as "query id",
+	// and Value the value matching there
+	Location string `json:"location"`
+	Value    string `json:"value"`
+
+	// LogOnly is whether the request was let through as the filter only
+	// logs
+	LogOnly bool `json:"log_only"`
+}
+
+// String describes the match for SuspiciousActivities
+func (m FilterMatch) String() string {
+	verb := map[FilterAction]string{FilterBlock: "Blocked", FilterChallenge: "Challenged", FilterLog: "Logged"}[m.Action]
+	if m.LogOnly {
+		verb = "Logged"
+	}
+	value := m.Value
+	if len(value) > 100 {
+		value = value[:100] + "..."
+	}
+	return fmt.Sprintf("%s %s %s from IP %s: %s in %s %q at %s",
+		verb, m.Method, m.Path, m.IP, m.Rule, m.Location, value, m.Time.Format(time.RFC3339))
+}
+
+// RequestFilter blocks or challenges requests matching attack signatures in
+// their path, query, User-Agent and Referer headers, and form, JSON and text
+// bodies. Each match is counted in the waf_<rule> metric and recorded as a
+// suspicious activity of Monitor.
+type RequestFilter struct {
+	Monitor *SecurityMonitor
+
+	// Rules are the signatures requests are matched against, in order
+	Rules []FilterRule
+
+	// LogOnly records matches without blocking or challenging them, to
+	// try rules out before enforcing them
+	LogOnly bool
+
+	// AllowIPs are IPs and CIDR ranges, and AllowPaths path prefixes, whose
+	// requests are not filtered
+	AllowIPs   []string
+	AllowPaths []string
+
+	// MaxBodySize is how much of a body is inspected; 0 skips bodies
+	MaxBodySize int64
+
+	// ChallengeSecret signs the cookies of passed challenges
+	ChallengeSecret []byte
+
+	mutex   sync.Mutex
+	metrics map[string]bool
+}
+
+// NewRequestFilter creates a filter with the default rules, inspecting the
+// first 64KB of bodies, recording to monitor
+func NewRequestFilter(monitor *SecurityMonitor) *RequestFilter {
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	return &RequestFilter{
+		Monitor:         monitor,
+		Rules:           DefaultFilterRules(),
+		MaxBodySize:     64 << 10,
+		ChallengeSecret: secret,
+		metrics:         make(map[string]bool),
+	}
+}
+
+// Middleware filters the requests to next. Wrap it in the monitor's
+// Middleware, as in sm.Middleware(filter.Middleware(app)), for blocked
+// responses to get the security headers and count for anomaly detection.
+// The strictest match decides what happens to a request, and is recorded
+// along with the matches of log-only rules.
+func (f *RequestFilter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		matches := f.InspectAll(r)
+		match := strictest(matches)
+		if match == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		for i := range matches {
+			if &matches[i] == match || matches[i].Action == FilterLog {
+				f.record(matches[i])
+			}
+		}
+
+		switch {
+		case match.LogOnly || match.Action == FilterLog:
+			next.ServeHTTP(w, r)
+		case match.Action == FilterChallenge && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+			f.challenge(w, match.IP)
+		default:
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		}
+	})
+}
+
+// Inspect matches a request against the rules, returning the match of the
+// strictest: a blocking rule before a challenging one, and that before a
+// log-only one, the first in Rules among equals. It returns nil when no
+// rule matches.
+func (f *RequestFilter) Inspect(r *http.Request) *FilterMatch {
+	return strictest(f.InspectAll(r))
+}
+
+// InspectAll matches a request against every rule, returning a match for
+// each rule it matches, in the order of Rules. Requests from allowed IPs or
+// to allowed paths, and those passing a challenge of a challenging rule,
+// are not matched. A body it inspects is left for the handler to read.
+func (f *RequestFilter) InspectAll(r *http.Request) []FilterMatch {
+	ip := clientIP(r)
+	if f.allowed(ip, r.URL.Path) {
+		return nil
+	}
+
+	var matches []FilterMatch
+	values := f.values(r)
+	passed := f.passedChallenge(r, ip)
+	for _, rule := range f.Rules {
+		if rule.Action == FilterChallenge && passed {
+			continue
+		}
+		for _, value := range values {
+			if !rule.matches(value.value) {
+				continue
+			}
+			matches = append(matches, FilterMatch{
+				Time:     time.Now(),
+				Rule:     rule.Name,
+				Action:   rule.Action,
+				IP:       ip,
+				Method:   r.Method,
+				Path:     r.URL.RequestURI(),
+				Location: value.location,
+				Value:    value.value,
+				LogOnly:  f.LogOnly,
+			})
+			break
+		}
+	}
+	return matches
+}
+
+// strictest returns the match whose action is strictest, the first among
+// equals, or nil
+func strictest(matches []FilterMatch) *FilterMatch {
+	var match *FilterMatch
+	for i := range matches {
+		if match == nil || strictness(matches[i].Action) > strictness(match.Action) {
+			match = &matches[i]
+		}
+	}
+	return match
+}
+
+// strictness ranks actions; an unknown one blocks, as Middleware does
+func strictness(action FilterAction) int {
+	switch action {
+	case FilterLog:
+		return 0
+	case FilterChallenge:
+		return 1
+	}
+	return 2
+}
+
+// matches reports whether a value, or its URL decodings, match the rule
+func (rule FilterRule) matches(value string) bool {
+	for _, decoded := range decodings(value) {
+		for _, pattern := range rule.Patterns {
+			if pattern.MatchString(decoded) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decodings are a value and its decodings, up to twice, for payloads
+// encoded more than once
+func decodings(value string) []string {
+	values := []string{value}
+	for i := 0; i < 2; i++ {
+		decoded, err := url.QueryUnescape(value)
+		if err != nil || decoded == value {
+			break
+		}
+		values = append(values, decoded)
+		value = decoded
+	}
+	return values
+}
+
+// requestValue is a value of a request the rules are matched against
+type requestValue struct {
+	location string
+	value    string
+}
+
+// values are the values of a request the rules are matched against
+func (f *RequestFilter) values(r *http.Request) []requestValue {
+	values := []requestValue{{"path", r.URL.EscapedPath()}}
+	values = appendQuery(values, "query", r.URL.RawQuery)
+	for _, name := range []string{"User-Agent", "Referer"} {
+		if value := r.Header.Get(name); value != "" {
+			values = append(values, requestValue{"header " + name, value})
+		}
+	}
+	return append(values, f.bodyValues(r)...)
+}
+
+// appendQuery appends the names and values of a query, in name order
+func appendQuery(values []requestValue, location, rawQuery string) []requestValue {
+	query, _ := url.ParseQuery(rawQuery)
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values = append(values, requestValue{location + " " + name, name})
+		for _, value := range query[name] {
+			values = append(values, requestValue{location + " " + name, value})
+		}
+	}
+	return values
+}
+
+// bodyValues reads up to MaxBodySize of a form, JSON or text body, and puts
+// what it read back in front of the rest
+func (f *RequestFilter) bodyValues(r *http.Request) []requestValue {
+	if f.MaxBodySize <= 0 || r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "application/x-www-form-urlencoded", mediaType == "application/json",
+		strings.HasSuffix(mediaType, "+json"), strings.HasPrefix(mediaType, "text/"), strings.HasSuffix(mediaType, "xml"):
+	default:
+		return nil
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, f.MaxBodySize))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), r.Body), r.Body}
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		return appendQuery(nil, "body", string(data))
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var body interface{}
+		if json.Unmarshal(data, &body) == nil {
+			var values []requestValue
+			jsonStrings(body, func(value string) {
+				values = append(values, requestValue{"body", value})
+			})
+			return values
+		}
+	}
+	return []requestValue{{"body", string(data)}}
+}
+
+// jsonStrings calls fn with every string, and key, of a JSON value
+func jsonStrings(value interface{}, fn func(string)) {
+	switch value := value.(type) {
+	case string:
+		fn(value)
+	case []interface{}:
+		for _, item := range value {
+			jsonStrings(item, fn)
+		}
+	case map[string]interface{}:
+		for key, item := range value {
+			fn(key)
+			jsonStrings(item, fn)
+		}
+	}
+}
+
+// allowed reports whether a request from ip to path is allowlisted
+func (f *RequestFilter) allowed(ip, path string) bool {
+	for _, prefix := range f.AllowPaths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, allow := range f.AllowIPs {
+		if _, network, err := net.ParseCIDR(allow); err == nil {
+			if network.Contains(parsed) {
+				return true
+			}
+		} else if allowed := net.ParseIP(allow); allowed != nil && allowed.Equal(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// challengeToken is the cookie value passing the challenge for ip
+func (f *RequestFilter) challengeToken(ip string) string {
+	mac := hmac.New(sha256.New, f.ChallengeSecret)
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// passedChallenge reports whether a request carries the cookie of a passed
+// challenge
+func (f *RequestFilter) passedChallenge(r *http.Request, ip string) bool {
+	cookie, err := r.Cookie(challengeCookie)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(cookie.Value), []byte(f.challengeToken(ip)))
+}
+
+// challenge answers with a page setting the challenge cookie and reloading
+func (f *RequestFilter) challenge(w http.ResponseWriter, ip string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusForbidden)
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html><head><title>Checking your browser</title></head>
+<body><noscript>Enable JavaScript to continue.</noscript>
+<script>document.cookie = "%s=%s; path=/; SameSite=Lax"; location.reload();</script>
+</body></html>
+`, challengeCookie, f.challengeToken(ip))
+}
+
+// record counts a match in its rule's metric, and records it as a
+// suspicious activity
+func (f *RequestFilter) record(match FilterMatch) {
+	if f.Monitor == nil {
+		return
+	}
+	f.ensureMetric(match.Rule)
+	f.Monitor.Jetpack.RecordMetric("waf_"+match.Rule, 1)
+
+	f.Monitor.mutex.Lock()
+	f.Monitor.addSuspiciousActivity(match.String())
+	f.Monitor.updateSecurityMetrics()
+	f.Monitor.mutex.Unlock()
+}
+
+// ensureMetric registers the waf_<rule> metric the first time a rule matches
+func (f *RequestFilter) ensureMetric(rule string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.metrics == nil {
+		f.metrics = make(map[string]bool)
+	}
+	if f.metrics[rule] {
+		return
+	}
+	f.metrics[rule] = true
+	if _, err := f.Monitor.Jetpack.GetMetric("waf_" + rule); err == nil {
+		return
+	}
+	f.Monitor.Jetpack.RegisterMetric(
+		core.MetricSuspiciousActivity,
+		"waf_"+rule,
+		"Requests matching the "+rule+" request filter rule",
+		"requests",
+		nil,
+		[]string{"security", "waf"},
+	)
+}
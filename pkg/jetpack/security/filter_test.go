@@ -0,0 +1,184 @@
+package security
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+func TestRequestFilter(t *testing.T) {
+	sm := NewSecurityMonitor(core.NewJetpack())
+	filter := NewRequestFilter(sm)
+	var bodies []string
+	handler := filter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.Write([]byte("ok"))
+	}))
+
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		r.RemoteAddr = "198.51.100.9:40000"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	get := func(target string) int {
+		return serve(httptest.NewRequest(http.MethodGet, target, nil)).Code
+	}
+	post := func(contentType, body string) int {
+		r := httptest.NewRequest(http.MethodPost, "/comments", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		return serve(r).Code
+	}
+
+	// Ordinary requests pass, apostrophes and all
+	for _, target := range []string{"/", "/books?author=O'Reilly&q=rock+%26+roll", "/search?q=select+a+union+rep"} {
+		if code := get(target); code != http.StatusOK {
+			t.Fatalf("expected %s to pass, got %d", target, code)
+		}
+	}
+	if code := post("application/x-www-form-urlencoded", "comment=I+don't+know&rating=5"); code != http.StatusOK || bodies[len(bodies)-1] != "comment=I+don't+know&rating=5" {
+		t.Fatalf("expected the form to pass with its body intact, got %d %v", code, bodies)
+	}
+
+	for _, target := range []string{
+		"/users?id=" + url.QueryEscape("1' OR '1'='1"),
+		"/users?id=1%20UNION%20ALL%20SELECT%20password%20FROM%20users",
+		"/search?q=%253Cscript%253Ealert(1)%253C%252Fscript%253E",
+		"/static/%2e%2e/%2e%2e/etc/passwd",
+		"/download?file=..%5C..%5Cwindows%5Cwin.ini",
+	} {
+		if code := get(target); code != http.StatusForbidden {
+			t.Fatalf("expected %s to be blocked, got %d", target, code)
+		}
+	}
+	if code := post("application/json", `{"comment": {"text": "<img src=x onerror=alert(1)>"}}`); code != http.StatusForbidden {
+		t.Fatalf("expected the JSON XSS to be blocked, got %d", code)
+	}
+	if code := post("application/x-www-form-urlencoded", "name=admin'--&password=x"); code != http.StatusForbidden {
+		t.Fatalf("expected the form SQL injection to be blocked, got %d", code)
+	}
+
+	activities := sm.GetSuspiciousActivities()
+	if len(activities) != 7 || !strings.Contains(activities[0], `Blocked GET /users?id=1%27+OR+%271%27%3D%271 from IP 198.51.100.9: sqli in query id "1' OR '1'='1"`) {
+		t.Fatalf("unexpected suspicious activities %v", activities)
+	}
+	for rule, count := range map[string]int{"sqli": 3, "xss": 2, "path_traversal": 2} {
+		metric, err := sm.Jetpack.GetMetric("waf_" + rule)
+		if err != nil || len(metric.Values) != count {
+			t.Fatalf("expected %d waf_%s values, got %+v %v", count, rule, metric, err)
+		}
+	}
+
+	// Allowlisted clients and paths are not filtered
+	filter.AllowIPs = []string{"198.51.100.0/24"}
+	if code := get("/users?id=1'--"); code != http.StatusOK {
+		t.Fatalf("expected the allowlisted IP to pass, got %d", code)
+	}
+	filter.AllowIPs = []string{"203.0.113.1"}
+	filter.AllowPaths = []string{"/admin/sql"}
+	if code := get("/admin/sql?query=DROP+TABLE+x;+SELECT+1+UNION+SELECT+2"); code != http.StatusOK {
+		t.Fatalf("expected the allowlisted path to pass, got %d", code)
+	}
+
+	// Log-only mode records without blocking
+	filter.LogOnly = true
+	if code := get("/?q=javascript:alert(1)"); code != http.StatusOK {
+		t.Fatalf("expected log-only mode to let the request through, got %d", code)
+	}
+	activities = sm.GetSuspiciousActivities()
+	if !strings.HasPrefix(activities[len(activities)-1], "Logged GET") {
+		t.Fatalf("expected the match to be logged, got %v", activities)
+	}
+}
+
+func TestRequestFilterChallenge(t *testing.T) {
+	filter := NewRequestFilter(NewSecurityMonitor(core.NewJetpack()))
+	for i := range filter.Rules {
+		filter.Rules[i].Action = FilterChallenge
+	}
+	handler := filter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/?q=%3Cscript%3E", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	token := filter.challengeToken("192.0.2.1")
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), challengeCookie+"="+token) {
+		t.Fatalf("expected a challenge, got %d %s", w.Code, w.Body.String())
+	}
+
+	// The cookie the challenge sets lets the browser through
+	r = httptest.NewRequest(http.MethodGet, "/?q=%3Cscript%3E", nil)
+	r.AddCookie(&http.Cookie{Name: challengeCookie, Value: token})
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the passed challenge to let the request through, got %d", w.Code)
+	}
+
+	// It is tied to the IP, and a POST cannot be challenged
+	r.RemoteAddr = "192.0.2.2:1234"
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "<script>") {
+		t.Fatalf("expected another IP to be challenged, got %d", w.Code)
+	}
+	r = httptest.NewRequest(http.MethodPost, "/?q=%3Cscript%3E", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden || strings.Contains(w.Body.String(), "<script>") {
+		t.Fatalf("expected the POST to be blocked, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRequestFilterStrictestRule(t *testing.T) {
+	sm := NewSecurityMonitor(core.NewJetpack())
+	filter := NewRequestFilter(sm)
+	filter.Rules = append([]FilterRule{
+		{Name: "admin_probe", Action: FilterLog, Patterns: []*regexp.Regexp{regexp.MustCompile(`(?i)admin`)}},
+		{Name: "scanner", Action: FilterChallenge, Patterns: []*regexp.Regexp{regexp.MustCompile(`(?i)sqlmap`)}},
+	}, filter.Rules...)
+	handler := filter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	serve := func(target, userAgent string) int {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.Header.Set("User-Agent", userAgent)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	// A log-only rule ahead of a blocking one does not let the request through
+	if code := serve("/admin?id="+url.QueryEscape("1' OR '1'='1"), "sqlmap/1.7"); code != http.StatusForbidden {
+		t.Fatalf("expected the request blocked, got %d", code)
+	}
+	activities := sm.GetSuspiciousActivities()
+	if len(activities) != 2 || !strings.HasPrefix(activities[0], "Logged GET /admin") || !strings.Contains(activities[0], "admin_probe") ||
+		!strings.HasPrefix(activities[1], "Blocked GET /admin") || !strings.Contains(activities[1], "sqli") {
+		t.Fatalf("expected the log-only and the blocking matches recorded, got %v", activities)
+	}
+
+	// A challenging rule wins over a log-only one
+	r := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	r.Header.Set("User-Agent", "sqlmap/1.7")
+	if match := filter.Inspect(r); match == nil || match.Rule != "scanner" {
+		t.Fatalf("expected the challenge, got %+v", match)
+	}
+	if matches := filter.InspectAll(r); len(matches) != 2 || matches[0].Rule != "admin_probe" || matches[1].Rule != "scanner" {
+		t.Fatalf("expected both matches, got %+v", matches)
+	}
+
+	// A log-only match alone lets the request through
+	if code := serve("/admin", "curl/8.0"); code != http.StatusOK {
+		t.Fatalf("expected the request let through, got %d", code)
+	}
+}
@@ -0,0 +1,256 @@
+package security
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+// SecurityHeaders are the headers Middleware sends with every response. An
+// empty field is not sent.
+type SecurityHeaders struct {
+	ContentSecurityPolicy   string `json:"content_security_policy"`
+	StrictTransportSecurity string `json:"strict_transport_security"`
+	FrameOptions            string `json:"frame_options"`
+	ContentTypeOptions      string `json:"content_type_options"`
+	XSSProtection           string `json:"xss_protection"`
+	ReferrerPolicy          string `json:"referrer_policy"`
+	PermissionsPolicy       string `json:"permissions_policy"`
+}
+
+// DefaultSecurityHeaders returns headers that pass CheckSecurityHeaders. The
+// Content-Security-Policy only restricts framing, plugins and the base URL,
+// so inline scripts such as the performance panel's keep working; tighten
+// it with script-src and style-src once the app allows, turning on
+// Config.CSPNonce for the inline tags goscript layouts render.
+func DefaultSecurityHeaders() SecurityHeaders {
+	return SecurityHeaders{
+		ContentSecurityPolicy:   "frame-ancestors 'none'; object-src 'none'; base-uri 'self'",
+		StrictTransportSecurity: "max-age=31536000; includeSubDomains",
+		FrameOptions:            "DENY",
+		ContentTypeOptions:      "nosniff",
+		XSSProtection:           "1; mode=block",
+		ReferrerPolicy:          "no-referrer",
+		PermissionsPolicy:       "camera=(), microphone=(), geolocation=()",
+	}
+}
+
+// requiredHeaders are the headers CheckSecurityHeaders looks for, and the
+// value each must contain; empty accepts any value
+var requiredHeaders = map[string]string{
+	"Content-Security-Policy":   "",
+	"X-Content-Type-Options":    "nosniff",
+	"X-Frame-Options":           "DENY",
+	"X-XSS-Protection":          "1; mode=block",
+	"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
+	"Referrer-Policy":           "no-referrer",
+	"Permissions-Policy":        "",
+}
+
+// fields pairs each header's name with a pointer to its value
+func (h *SecurityHeaders) fields() map[string]*string {
+	return map[string]*string{
+		"Content-Security-Policy":   &h.ContentSecurityPolicy,
+		"Strict-Transport-Security": &h.StrictTransportSecurity,
+		"X-Frame-Options":           &h.FrameOptions,
+		"X-Content-Type-Options":    &h.ContentTypeOptions,
+		"X-XSS-Protection":          &h.XSSProtection,
+		"Referrer-Policy":           &h.ReferrerPolicy,
+		"Permissions-Policy":        &h.PermissionsPolicy,
+	}
+}
+
+// Middleware sends Config.Headers with every response next writes. The
+// handler may still replace any of them. With Config.AutoFix on, headers
+// that CheckSecurityHeaders finds missing or invalid are added to
+// Config.Headers, fixing them from the next response on.
+//
+// With Config.CSPNonce on, each response's Content-Security-Policy allows
+// inline scripts and styles by a fresh nonce, which core.CSPNonce finds in
+// the request's context.
+//
+// With Config.AnomalyDetectionEnabled on, it also has Anomalies score every
+// request outside Config.ExcludePaths once handled, recording the
+// suspicious ones.
+func (sm *SecurityMonitor) Middleware(next http.Handler) http.Handler {
+	sm.mutex.Lock()
+	sm.middleware = true
+	sm.mutex.Unlock()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := w.Header()
+
+		sm.mutex.RLock()
+		for name, value := range sm.Config.Headers.fields() {
+			if *value != "" {
+				header.Set(name, *value)
+			}
+		}
+		policy, nonced := sm.Config.Headers.ContentSecurityPolicy, sm.Config.CSPNonce
+		detect := sm.Config.AnomalyDetectionEnabled && !sm.excluded(r.URL.Path)
+		sm.mutex.RUnlock()
+
+		if nonced {
+			nonce, err := newNonce()
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			if policy != "" {
+				header.Set("Content-Security-Policy", policyWithNonce(policy, nonce))
+			}
+			r = r.WithContext(core.WithCSPNonce(r.Context(), nonce))
+		}
+
+		if !detect {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		recorder := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		sm.observe(RequestActivity{
+			Time:      time.Now(),
+			IP:        clientIP(r),
+			Method:    r.Method,
+			Path:      r.URL.RequestURI(),
+			Status:    recorder.status,
+			UserAgent: r.UserAgent(),
+		})
+	})
+}
+
+// newNonce returns 128 random bits for a CSP nonce
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// policyWithNonce adds a nonce to the script-src and style-src directives of
+// a policy. A policy restricting scripts or styles only by default-src gets
+// the directive with default-src's sources and the nonce, as default-src
+// does not apply once it is there.
+func policyWithNonce(policy, nonce string) string {
+	source := "'nonce-" + nonce + "'"
+	var directives []string
+	var defaults []string
+	restricted := false
+	found := map[string]bool{}
+	for _, directive := range strings.Split(policy, ";") {
+		fields := strings.Fields(directive)
+		if len(fields) == 0 {
+			continue
+		}
+		switch name := strings.ToLower(fields[0]); name {
+		case "default-src":
+			restricted = true
+			// 'none' only means something alone, so it is not carried over
+			if len(fields) != 2 || !strings.EqualFold(fields[1], "'none'") {
+				defaults = fields[1:]
+			}
+		case "script-src", "style-src":
+			found[name] = true
+			fields = append(fields, source)
+		}
+		directives = append(directives, strings.Join(fields, " "))
+	}
+	if restricted {
+		for _, name := range []string{"script-src", "style-src"} {
+			if !found[name] {
+				fields := append(append([]string{name}, defaults...), source)
+				directives = append(directives, strings.Join(fields, " "))
+			}
+		}
+	}
+	return strings.Join(directives, "; ")
+}
+
+// excluded reports whether a path is under Config.ExcludePaths. The caller
+// holds the lock.
+func (sm *SecurityMonitor) excluded(path string) bool {
+	for _, prefix := range sm.Config.ExcludePaths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerVulnerabilityID is the ID of the vulnerability of a missing or
+// invalid header
+func headerVulnerabilityID(name string) string {
+	return "missing-header:" + strings.ToLower(name)
+}
+
+// recordHeaderFindings records a vulnerability for each header the check of
+// url found missing or invalid, and marks those of valid headers fixed. With
+// Config.AutoFix on and Middleware in use, it fixes them.
+func (sm *SecurityMonitor) recordHeaderFindings(url string, headers http.Header) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	for name, expected := range requiredHeaders {
+		id := headerVulnerabilityID(name)
+		value := headers.Get(name)
+		if value != "" && strings.Contains(value, expected) {
+			if vuln, ok := sm.Vulnerabilities[id]; ok {
+				vuln.Fixed = true
+			}
+			continue
+		}
+
+		description := fmt.Sprintf("Missing %s header", name)
+		if value != "" {
+			description = fmt.Sprintf("%s header is %q, expected it to contain %q", name, value, expected)
+		}
+		vuln := &Vulnerability{
+			ID:          id,
+			Type:        VulnMissingHeaders,
+			Level:       headerLevel(name),
+			Description: description,
+			Location:    "HTTP Response Headers of " + url,
+			Timestamp:   time.Now(),
+			Remediation: fmt.Sprintf("Send %s with every response, such as with SecurityMonitor.Middleware", name),
+			References:  []string{"https://developer.mozilla.org/en-US/docs/Web/HTTP/Headers/" + name},
+		}
+		if sm.Config.AutoFix && sm.middleware {
+			sm.fixHeader(name, expected)
+			vuln.Fixed = true
+		}
+		sm.Vulnerabilities[id] = vuln
+	}
+
+	sm.updateSecurityMetrics()
+}
+
+// fixHeader has the middleware send a header with a value containing
+// expected: the configured one when it does, the default otherwise
+func (sm *SecurityMonitor) fixHeader(name, expected string) {
+	value := sm.Config.Headers.fields()[name]
+	if *value != "" && strings.Contains(*value, expected) {
+		return
+	}
+	defaults := DefaultSecurityHeaders()
+	*value = *defaults.fields()[name]
+}
+
+// headerLevel rates a missing header: the ones against clickjacking and
+// downgrades matter most
+func headerLevel(name string) SecurityLevel {
+	switch name {
+	case "Content-Security-Policy", "Strict-Transport-Security", "X-Frame-Options":
+		return SecurityLevelMedium
+	}
+	return SecurityLevelLow
+}
@@ -0,0 +1,65 @@
+package security
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+func TestSecurityHeadersAutoFix(t *testing.T) {
+	sm := NewSecurityMonitor(core.NewJetpack())
+	sm.Config.Headers.ContentSecurityPolicy = "default-src 'self'"
+	sm.Config.Headers.FrameOptions = ""
+	sm.Config.Headers.ReferrerPolicy = "origin"
+
+	server := httptest.NewServer(sm.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})))
+	defer server.Close()
+	sm.Config.TargetURL = server.URL
+
+	// Without AutoFix the missing and invalid headers stay vulnerabilities
+	sm.ScanVulnerabilities()
+	frame, err := sm.GetVulnerability("missing-header:x-frame-options")
+	if err != nil || frame.Fixed || frame.Type != VulnMissingHeaders || frame.Level != SecurityLevelMedium {
+		t.Fatalf("expected the missing X-Frame-Options to be found, got %+v %v", frame, err)
+	}
+	if referrer, err := sm.GetVulnerability("missing-header:referrer-policy"); err != nil || referrer.Fixed {
+		t.Fatalf("expected the invalid Referrer-Policy to be found, got %+v %v", referrer, err)
+	}
+	if _, err := sm.GetVulnerability("missing-header:content-security-policy"); err == nil {
+		t.Fatalf("expected the configured Content-Security-Policy to pass")
+	}
+
+	sm.Config.AutoFix = true
+	sm.ScanVulnerabilities()
+	if frame, _ := sm.GetVulnerability("missing-header:x-frame-options"); frame == nil || !frame.Fixed {
+		t.Fatalf("expected X-Frame-Options to be fixed, got %+v", frame)
+	}
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	for name, value := range map[string]string{
+		"X-Frame-Options":         "DENY",
+		"Referrer-Policy":         "no-referrer",
+		"Content-Security-Policy": "default-src 'self'",
+		"X-Content-Type-Options":  "nosniff",
+	} {
+		if got := resp.Header.Get(name); got != value {
+			t.Fatalf("expected %s to be %q, got %q", name, value, got)
+		}
+	}
+
+	// The next scan finds nothing left to fix
+	sm.ScanVulnerabilities()
+	for _, vuln := range sm.GetVulnerabilities() {
+		if vuln.Type == VulnMissingHeaders {
+			t.Fatalf("expected no header vulnerabilities, got %+v", vuln)
+		}
+	}
+}
@@ -0,0 +1,402 @@
+package security
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LockoutScope is what a lockout policy counts failed logins by
+type LockoutScope string
+
+const (
+	// LockoutIP locks out an IP failing to log in as any user, as password
+	// spraying does
+	LockoutIP LockoutScope = "ip"
+
+	// LockoutUser locks out a user failing to log in from any IP, as
+	// credential stuffing from many IPs does
+	LockoutUser LockoutScope = "user"
+
+	// LockoutUserIP locks out a user from one IP, leaving the user free to
+	// log in from elsewhere
+	LockoutUserIP LockoutScope = "user_ip"
+)
+
+// LockoutPolicy locks out a client failing to log in Threshold times within
+// Window for Cooldown
+type LockoutPolicy struct {
+	Scope     LockoutScope  `json:"scope"`
+	Threshold int           `json:"threshold"`
+	Window    time.Duration `json:"window"`
+	Cooldown  time.Duration `json:"cooldown"`
+}
+
+// DefaultLockoutPolicies lock out a user from an IP after 5 failures in 15
+// minutes, a user after 10 and an IP after 20, for 15 minutes, 15 minutes and
+// an hour
+func DefaultLockoutPolicies() []LockoutPolicy {
+	return []LockoutPolicy{
+		{Scope: LockoutUserIP, Threshold: 5, Window: 15 * time.Minute, Cooldown: 15 * time.Minute},
+		{Scope: LockoutUser, Threshold: 10, Window: 15 * time.Minute, Cooldown: 15 * time.Minute},
+		{Scope: LockoutIP, Threshold: 20, Window: 15 * time.Minute, Cooldown: time.Hour},
+	}
+}
+
+// Lockout is a blocked IP or user
+type Lockout struct {
+	// Key identifies the lockout for Unblock, such as "ip:203.0.113.7" or
+	// "user_ip:alice@203.0.113.7"
+	Key    string       `json:"key"`
+	Scope  LockoutScope `json:"scope"`
+	IP     string       `json:"ip,omitempty"`
+	User   string       `json:"user,omitempty"`
+	Reason string       `json:"reason"`
+	Since  time.Time    `json:"since"`
+
+	// Until is when the lockout ends; zero blocks until Unblock
+	Until time.Time `json:"until,omitempty"`
+}
+
+// lockoutKey identifies the failures and lockout of a scope's client
+func lockoutKey(scope LockoutScope, user, ip string) string {
+	switch scope {
+	case LockoutIP:
+		return "ip:" + ip
+	case LockoutUser:
+		return "user:" + user
+	}
+	return "user_ip:" + user + "@" + ip
+}
+
+// DefaultMaxTracked is how many clients a lockout manager counts failed
+// logins of by default
+const DefaultMaxTracked = 100000
+
+// lockoutSweepInterval is how often failures that left every policy's
+// window and ended lockouts are dropped
+const lockoutSweepInterval = time.Minute
+
+// LockoutManager counts failed logins by the scopes of its policies and
+// blocks the clients going over them. It is the AccessGuard of goscript's
+// session manager, so that blocked clients are refused.
+type LockoutManager struct {
+	// Policies are the thresholds failed logins are counted against
+	Policies []LockoutPolicy
+
+	// MaxTracked caps how many IPs, users and users at an IP failed logins
+	// are counted for, so spraying many of them cannot grow memory without
+	// bound; past it, those that failed least recently are forgotten.
+	// DefaultMaxTracked when not positive.
+	MaxTracked int
+
+	mutex    sync.Mutex
+	failures map[string][]time.Time
+	lockouts map[string]*Lockout
+	swept    time.Time
+	now      func() time.Time
+}
+
+// NewLockoutManager creates a lockout manager with the default policies
+func NewLockoutManager() *LockoutManager {
+	return &LockoutManager{
+		Policies:   DefaultLockoutPolicies(),
+		MaxTracked: DefaultMaxTracked,
+		failures:   make(map[string][]time.Time),
+		lockouts:   make(map[string]*Lockout),
+		now:        time.Now,
+	}
+}
+
+// Failure records a failed login as user from ip, returning the lockouts it
+// started
+func (m *LockoutManager) Failure(user, ip string) []Lockout {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := m.now()
+	if now.Sub(m.swept) >= lockoutSweepInterval {
+		m.sweep(now)
+	}
+	var started []Lockout
+	for _, policy := range m.Policies {
+		if policy.Threshold <= 0 || (policy.Scope != LockoutIP && user == "") {
+			continue
+		}
+		key := lockoutKey(policy.Scope, user, ip)
+
+		since := now.Add(-policy.Window)
+		failures, tracked := m.failures[key]
+		if !tracked && len(m.failures) >= m.maxTracked() {
+			m.sweep(now)
+			m.evict()
+		}
+		i := 0
+		for i < len(failures) && !failures[i].After(since) {
+			i++
+		}
+		failures = append(failures[i:], now)
+		if len(failures) > policy.Threshold {
+			failures = failures[len(failures)-policy.Threshold:]
+		}
+		m.failures[key] = failures
+
+		if len(failures) < policy.Threshold || m.active(key, now) != nil {
+			continue
+		}
+		lockout := &Lockout{
+			Key:    key,
+			Scope:  policy.Scope,
+			Reason: fmt.Sprintf("%d failed logins within %s", len(failures), policy.Window),
+			Since:  now,
+			Until:  now.Add(policy.Cooldown),
+		}
+		if policy.Scope != LockoutUser {
+			lockout.IP = ip
+		}
+		if policy.Scope != LockoutIP {
+			lockout.User = user
+		}
+		m.lockouts[key] = lockout
+		delete(m.failures, key)
+		started = append(started, *lockout)
+	}
+	return started
+}
+
+// maxTracked is MaxTracked, or its default
+func (m *LockoutManager) maxTracked() int {
+	if m.MaxTracked > 0 {
+		return m.MaxTracked
+	}
+	return DefaultMaxTracked
+}
+
+// sweep drops the failures older than every policy's window, and the
+// lockouts that ended. The caller holds the lock.
+func (m *LockoutManager) sweep(now time.Time) {
+	m.swept = now
+	var window time.Duration
+	for _, policy := range m.Policies {
+		if policy.Window > window {
+			window = policy.Window
+		}
+	}
+	since := now.Add(-window)
+	for key, failures := range m.failures {
+		if len(failures) == 0 || !failures[len(failures)-1].After(since) {
+			delete(m.failures, key)
+		}
+	}
+	for key := range m.lockouts {
+		m.active(key, now)
+	}
+}
+
+// evict forgets the clients that failed least recently until a tenth of
+// MaxTracked is free, so a full map is not scanned on every failure. The
+// caller holds the lock.
+func (m *LockoutManager) evict() {
+	keep := m.maxTracked() * 9 / 10
+	if len(m.failures) <= keep {
+		return
+	}
+	keys := make([]string, 0, len(m.failures))
+	for key := range m.failures {
+		keys = append(keys, key)
+	}
+	last := func(key string) time.Time {
+		failures := m.failures[key]
+		return failures[len(failures)-1]
+	}
+	sort.Slice(keys, func(i, j int) bool { return last(keys[i]).Before(last(keys[j])) })
+	for _, key := range keys[:len(keys)-keep] {
+		delete(m.failures, key)
+	}
+}
+
+// Success forgets the failed logins as user from ip, as after a successful
+// login. Lockouts stay until they end or are unblocked.
+func (m *LockoutManager) Success(user, ip string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.failures, lockoutKey(LockoutUserIP, user, ip))
+}
+
+// Blocked reports whether ip, or user from any or that IP, is locked out,
+// and for how long; zero is until unblocked
+func (m *LockoutManager) Blocked(ip, user string) (bool, time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := m.now()
+	keys := []string{lockoutKey(LockoutIP, "", ip)}
+	if user != "" {
+		keys = append(keys, lockoutKey(LockoutUser, user, ""), lockoutKey(LockoutUserIP, user, ip))
+	}
+
+	var blocked bool
+	var longest time.Duration
+	for _, key := range keys {
+		lockout := m.active(key, now)
+		if lockout == nil {
+			continue
+		}
+		if lockout.Until.IsZero() {
+			return true, 0
+		}
+		blocked = true
+		if remaining := lockout.Until.Sub(now); remaining > longest {
+			longest = remaining
+		}
+	}
+	return blocked, longest
+}
+
+// active returns the lockout of key if it has not ended, dropping it if it
+// has. The caller holds the lock.
+func (m *LockoutManager) active(key string, now time.Time) *Lockout {
+	lockout, ok := m.lockouts[key]
+	if !ok {
+		return nil
+	}
+	if !lockout.Until.IsZero() && !now.Before(lockout.Until) {
+		delete(m.lockouts, key)
+		return nil
+	}
+	return lockout
+}
+
+// Block locks out an IP or a user by hand, for duration or, if zero, until
+// unblocked; for LockoutUserIP, value is "user@ip"
+func (m *LockoutManager) Block(scope LockoutScope, value, reason string, duration time.Duration) (Lockout, error) {
+	lockout := Lockout{Scope: scope, Reason: reason}
+	switch scope {
+	case LockoutIP:
+		lockout.IP = value
+	case LockoutUser:
+		lockout.User = value
+	case LockoutUserIP:
+		at := strings.LastIndex(value, "@")
+		if at <= 0 || at == len(value)-1 {
+			return Lockout{}, fmt.Errorf("user_ip lockouts need a user@ip value, got %q", value)
+		}
+		lockout.User, lockout.IP = value[:at], value[at+1:]
+	default:
+		return Lockout{}, fmt.Errorf("unknown lockout scope %q", scope)
+	}
+	if value == "" {
+		return Lockout{}, fmt.Errorf("%s lockouts need a value", scope)
+	}
+	if lockout.Reason == "" {
+		lockout.Reason = "blocked by an administrator"
+	}
+	lockout.Key = lockoutKey(scope, lockout.User, lockout.IP)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	lockout.Since = m.now()
+	if duration > 0 {
+		lockout.Until = lockout.Since.Add(duration)
+	}
+	m.lockouts[lockout.Key] = &lockout
+	return lockout, nil
+}
+
+// Unblock ends the lockout of key and forgets its failures, returning
+// whether there was one
+func (m *LockoutManager) Unblock(key string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, ok := m.lockouts[key]
+	delete(m.lockouts, key)
+	delete(m.failures, key)
+	return ok
+}
+
+// Lockouts returns the lockouts that have not ended, the latest first
+func (m *LockoutManager) Lockouts() []Lockout {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := m.now()
+	lockouts := make([]Lockout, 0, len(m.lockouts))
+	for key := range m.lockouts {
+		if lockout := m.active(key, now); lockout != nil {
+			lockouts = append(lockouts, *lockout)
+		}
+	}
+	sort.Slice(lockouts, func(i, j int) bool {
+		if !lockouts[i].Since.Equal(lockouts[j].Since) {
+			return lockouts[i].Since.After(lockouts[j].Since)
+		}
+		return lockouts[i].Key < lockouts[j].Key
+	})
+	return lockouts
+}
+
+// lockoutRequest is the body of a block request to the admin handler
+type lockoutRequest struct {
+	Scope    LockoutScope `json:"scope"`
+	Value    string       `json:"value"`
+	Reason   string       `json:"reason"`
+	Duration string       `json:"duration"`
+}
+
+// Handler serves the lockouts for administration: GET lists them, POST
+// blocks the {"scope", "value", "reason", "duration"} of its JSON body, and
+// DELETE with a key query parameter unblocks. It does no authorization;
+// mount it behind the app's admin login.
+func (m *LockoutManager) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, m.Lockouts())
+		case http.MethodPost:
+			var request lockoutRequest
+			if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&request); err != nil {
+				http.Error(w, "invalid lockout: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			var duration time.Duration
+			if request.Duration != "" {
+				parsed, err := time.ParseDuration(request.Duration)
+				if err != nil || parsed < 0 {
+					http.Error(w, fmt.Sprintf("invalid duration %q", request.Duration), http.StatusBadRequest)
+					return
+				}
+				duration = parsed
+			}
+			lockout, err := m.Block(request.Scope, request.Value, request.Reason, duration)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			writeJSON(w, http.StatusCreated, lockout)
+		case http.MethodDelete:
+			key := r.URL.Query().Get("key")
+			if key == "" || !m.Unblock(key) {
+				http.Error(w, fmt.Sprintf("no lockout %q", key), http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// writeJSON writes value as a JSON response
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,176 @@
+package security
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+func TestLockoutPolicies(t *testing.T) {
+	m := NewLockoutManager()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	m.Policies = []LockoutPolicy{
+		{Scope: LockoutUserIP, Threshold: 3, Window: time.Minute, Cooldown: 10 * time.Minute},
+		{Scope: LockoutIP, Threshold: 4, Window: time.Minute, Cooldown: time.Hour},
+	}
+
+	// Failures older than the window do not count
+	m.Failure("ada", "198.51.100.1")
+	now = now.Add(2 * time.Minute)
+	m.Failure("ada", "198.51.100.1")
+	if started := m.Failure("ada", "198.51.100.1"); len(started) != 0 {
+		t.Fatalf("expected failures outside the window to be forgotten, got %+v", started)
+	}
+	started := m.Failure("ada", "198.51.100.1")
+	if len(started) != 1 || started[0].Key != "user_ip:ada@198.51.100.1" || started[0].Until != now.Add(10*time.Minute) {
+		t.Fatalf("expected ada to be locked out from the IP, got %+v", started)
+	}
+	if blocked, retry := m.Blocked("198.51.100.1", "ada"); !blocked || retry != 10*time.Minute {
+		t.Fatalf("expected ada to be blocked for 10 minutes, got %v %v", blocked, retry)
+	}
+	if blocked, _ := m.Blocked("198.51.100.2", "ada"); blocked {
+		t.Fatalf("expected ada to be free to log in from another IP")
+	}
+
+	// Spraying other users from the IP locks it out for everyone
+	if started := m.Failure("bob", "198.51.100.1"); len(started) != 1 || started[0].Key != "ip:198.51.100.1" {
+		t.Fatalf("expected the IP to be locked out, got %+v", started)
+	}
+	if blocked, retry := m.Blocked("198.51.100.1", ""); !blocked || retry != time.Hour {
+		t.Fatalf("expected the IP to be blocked for an hour, got %v %v", blocked, retry)
+	}
+
+	// Lockouts end after their cooldown
+	now = now.Add(time.Hour)
+	if blocked, _ := m.Blocked("198.51.100.1", "ada"); blocked {
+		t.Fatalf("expected the lockouts to have ended")
+	}
+	if lockouts := m.Lockouts(); len(lockouts) != 0 {
+		t.Fatalf("expected no lockouts, got %+v", lockouts)
+	}
+
+	// A successful login forgets the user's failures
+	m.Failure("ada", "198.51.100.1")
+	m.Failure("ada", "198.51.100.1")
+	m.Success("ada", "198.51.100.1")
+	if started := m.Failure("ada", "198.51.100.1"); len(started) != 0 {
+		t.Fatalf("expected the failures to be forgotten, got %+v", started)
+	}
+}
+
+func TestLockoutForgetsSprayedClients(t *testing.T) {
+	m := NewLockoutManager()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+	m.MaxTracked = 100
+	m.Policies = []LockoutPolicy{
+		{Scope: LockoutUserIP, Threshold: 3, Window: time.Minute, Cooldown: 10 * time.Minute},
+		{Scope: LockoutUser, Threshold: 5, Window: 5 * time.Minute, Cooldown: 10 * time.Minute},
+	}
+
+	// Spraying many users never tracks more than MaxTracked, forgetting
+	// those that failed least recently
+	m.Failure("ada", "198.51.100.1")
+	m.Failure("ada", "198.51.100.1")
+	for i := 0; i < 1000; i++ {
+		now = now.Add(time.Millisecond)
+		m.Failure(fmt.Sprintf("user%d", i), "203.0.113.9")
+		if len(m.failures) > m.MaxTracked {
+			t.Fatalf("expected at most %d clients tracked, got %d", m.MaxTracked, len(m.failures))
+		}
+	}
+	if _, ok := m.failures["user_ip:ada@198.51.100.1"]; ok {
+		t.Fatal("expected the oldest failures forgotten")
+	}
+	if started := m.Failure("user999", "203.0.113.9"); len(started) != 0 {
+		t.Fatalf("unexpected lockouts %+v", started)
+	}
+	if started := m.Failure("user999", "203.0.113.9"); len(started) != 1 {
+		t.Fatalf("expected recent failures still counted, got %+v", started)
+	}
+
+	// Failures past every policy's window are swept, keys and all
+	now = now.Add(5 * time.Minute)
+	m.Failure("grace", "198.51.100.2")
+	if len(m.failures) != 2 {
+		t.Fatalf("expected only grace's failures left, got %d keys", len(m.failures))
+	}
+	now = now.Add(20 * time.Minute)
+	m.Failure("grace", "198.51.100.2")
+	if len(m.lockouts) != 0 {
+		t.Fatalf("expected the ended lockout swept, got %+v", m.lockouts)
+	}
+}
+
+func TestLockoutAdministration(t *testing.T) {
+	m := NewLockoutManager()
+	if _, err := m.Block(LockoutUserIP, "ada", "", 0); err == nil {
+		t.Fatalf("expected a user_ip lockout without an IP to be refused")
+	}
+	if _, err := m.Block("host", "example.com", "", 0); err == nil {
+		t.Fatalf("expected an unknown scope to be refused")
+	}
+	lockout, err := m.Block(LockoutUser, "mallory", "", 0)
+	if err != nil || lockout.Key != "user:mallory" || lockout.Reason != "blocked by an administrator" {
+		t.Fatalf("unexpected lockout %+v %v", lockout, err)
+	}
+	if blocked, retry := m.Blocked("203.0.113.9", "mallory"); !blocked || retry != 0 {
+		t.Fatalf("expected mallory to be blocked until unblocked, got %v %v", blocked, retry)
+	}
+
+	handler := m.Handler()
+	request := httptest.NewRequest("POST", "/lockouts", strings.NewReader(`{"scope":"ip","value":"203.0.113.7","reason":"scraping","duration":"1h"}`))
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("expected the IP to be blocked, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	if blocked, retry := m.Blocked("203.0.113.7", ""); !blocked || retry <= 59*time.Minute {
+		t.Fatalf("expected the IP to be blocked for an hour, got %v %v", blocked, retry)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/lockouts", nil))
+	var lockouts []Lockout
+	if err := json.Unmarshal(recorder.Body.Bytes(), &lockouts); err != nil || len(lockouts) != 2 {
+		t.Fatalf("expected both lockouts to be listed, got %s %v", recorder.Body.String(), err)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("DELETE", "/lockouts?key=user:mallory", nil))
+	if recorder.Code != http.StatusNoContent {
+		t.Fatalf("expected mallory to be unblocked, got %d", recorder.Code)
+	}
+	if blocked, _ := m.Blocked("203.0.113.9", "mallory"); blocked {
+		t.Fatalf("expected mallory to be unblocked")
+	}
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("DELETE", "/lockouts?key=user:mallory", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected unblocking twice to find nothing, got %d", recorder.Code)
+	}
+}
+
+func TestTrackAuthFailureLocksOut(t *testing.T) {
+	sm := NewSecurityMonitor(core.NewJetpack())
+	for i := 0; i < 5; i++ {
+		sm.TrackAuthFailure("ada", "198.51.100.1")
+	}
+	if blocked, _ := sm.Lockouts.Blocked("198.51.100.1", "ada"); !blocked {
+		t.Fatalf("expected ada to be locked out after 5 failures")
+	}
+	var logged bool
+	for _, activity := range sm.GetSuspiciousActivities() {
+		logged = logged || strings.HasPrefix(activity, "Locked out user_ip:ada@198.51.100.1 until ")
+	}
+	if !logged {
+		t.Fatalf("expected the lockout to be logged, got %v", sm.GetSuspiciousActivities())
+	}
+}
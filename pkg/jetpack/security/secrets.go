@@ -0,0 +1,313 @@
+package security
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxScannedFileSize caps the size of the files the secret scan reads
+const maxScannedFileSize = 1 << 20
+
+// secretPattern is a kind of credential the secret scan looks for
+type secretPattern struct {
+	name        string
+	description string
+	level       SecurityLevel
+	pattern     *regexp.Regexp
+}
+
+// secretPatterns are the credentials the secret scan looks for. The generic
+// one only matches quoted values assigned to names such as password, so
+// that placeholders can be told apart.
+var secretPatterns = []secretPattern{
+	{"private-key", "Private key", SecurityLevelCritical,
+		regexp.MustCompile(`-----BEGIN ((RSA|DSA|EC|OPENSSH|PGP|ENCRYPTED) )?PRIVATE KEY( BLOCK)?-----`)},
+	{"aws-access-key", "AWS access key ID", SecurityLevelCritical,
+		regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"stripe-key", "Stripe live secret key", SecurityLevelCritical,
+		regexp.MustCompile(`\b[rs]k_live_[0-9A-Za-z]{24,}\b`)},
+	{"github-token", "GitHub token", SecurityLevelHigh,
+		regexp.MustCompile(`\bgh[pousr]_[0-9A-Za-z]{36}\b|\bgithub_pat_[0-9A-Za-z_]{82}\b`)},
+	{"slack-token", "Slack token", SecurityLevelHigh,
+		regexp.MustCompile(`\bxox[abprs]-[0-9A-Za-z-]{10,}\b`)},
+	{"google-api-key", "Google API key", SecurityLevelHigh,
+		regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}\b`)},
+	{"generic-secret", "Hardcoded secret", SecurityLevelMedium,
+		regexp.MustCompile(`(?i)\b(api_?key|apikey|secret(_?key)?|password|passwd|access_?token|auth_?token)\b["']?\s*(:=|=|:)\s*["']([^"'\s]{8,})["']`)},
+}
+
+// placeholders are parts of values that are not real secrets
+var placeholders = []string{"example", "changeme", "change_me", "placeholder", "your", "xxxx", "****", "${", "{{", "<", "os.getenv", "process.env"}
+
+// debugImports are packages whose import serves debug endpoints
+var debugImports = []struct {
+	pkg      string
+	endpoint string
+	level    SecurityLevel
+	pattern  *regexp.Regexp
+}{
+	{"net/http/pprof", "/debug/pprof/", SecurityLevelMedium, regexp.MustCompile(`^\s*(import\s+)?(_\s+)?"net/http/pprof"`)},
+	{"expvar", "/debug/vars", SecurityLevelLow, regexp.MustCompile(`^\s*(import\s+)?(_\s+)?"expvar"`)},
+}
+
+// debugPaths are the paths the response scan probes, with text the real
+// response contains and whether it is plain text rather than HTML, so that
+// apps answering every path with their index page are not flagged
+var debugPaths = []struct {
+	path        string
+	marker      string
+	plain       bool
+	description string
+	level       SecurityLevel
+}{
+	{"/debug/pprof/", "Types of profiles available", false, "pprof profiles are served", SecurityLevelHigh},
+	{"/debug/vars", `"memstats"`, true, "expvar variables are served", SecurityLevelMedium},
+	{"/.env", "=", true, "The .env file is served", SecurityLevelCritical},
+	{"/.git/config", "[core]", true, "The git repository is served", SecurityLevelHigh},
+}
+
+// skippedDirs are the directories the secret scan does not walk
+var skippedDirs = map[string]bool{
+	".git": true, ".gopm": true, "node_modules": true, "vendor": true, "testdata": true,
+}
+
+// ScanProject walks dir for credentials and for imports serving debug
+// endpoints, returning a vulnerability located at the file and line of each.
+// Binary files, files over 1MB and dependency directories are skipped.
+func ScanProject(dir string) ([]*Vulnerability, error) {
+	var vulns []*Vulnerability
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if path != dir && skippedDirs[info.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || info.Size() > maxScannedFileSize {
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		head := data
+		if len(head) > 512 {
+			head = head[:512]
+		}
+		if bytes.IndexByte(head, 0) >= 0 {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			rel = path
+		}
+		vulns = append(vulns, scanFile(filepath.ToSlash(rel), data)...)
+		return nil
+	})
+	return vulns, err
+}
+
+// scanFile scans a file's lines
+func scanFile(name string, data []byte) []*Vulnerability {
+	var vulns []*Vulnerability
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64<<10), maxScannedFileSize)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		location := fmt.Sprintf("%s:%d", name, line)
+
+		for _, found := range findSecrets(text) {
+			vulns = append(vulns, &Vulnerability{
+				ID:          "secret:" + location + ":" + found.pattern.name,
+				Type:        VulnDataExposure,
+				Level:       found.pattern.level,
+				Description: fmt.Sprintf("%s %s in %s", found.pattern.description, redact(found.value), name),
+				Location:    location,
+				Timestamp:   time.Now(),
+				Remediation: "Revoke and rotate the credential, remove it from the source and its history, and load it from the environment or a secret store",
+			})
+		}
+
+		if !strings.HasSuffix(name, ".go") {
+			continue
+		}
+		for _, debug := range debugImports {
+			if !debug.pattern.MatchString(text) {
+				continue
+			}
+			vulns = append(vulns, &Vulnerability{
+				ID:          "secret:" + location + ":debug-endpoint",
+				Type:        VulnMisconfiguration,
+				Level:       debug.level,
+				Description: fmt.Sprintf("%s imports %s, serving %s on the default mux", name, debug.pkg, debug.endpoint),
+				Location:    location,
+				Timestamp:   time.Now(),
+				Remediation: fmt.Sprintf("Serve %s only in development builds or on a separate, internal listener", debug.endpoint),
+			})
+		}
+	}
+	return vulns
+}
+
+// secretMatch is a credential found in text
+type secretMatch struct {
+	pattern secretPattern
+	value   string
+}
+
+// findSecrets finds the credentials in text, skipping placeholders
+func findSecrets(text string) []secretMatch {
+	var found []secretMatch
+	for _, pattern := range secretPatterns {
+		match := pattern.pattern.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+		value := match[0]
+		if pattern.name == "generic-secret" {
+			value = match[len(match)-1]
+			if isPlaceholder(value) {
+				continue
+			}
+		}
+		found = append(found, secretMatch{pattern, value})
+	}
+	return found
+}
+
+// isPlaceholder reports whether a value is an example rather than a secret
+func isPlaceholder(value string) bool {
+	lower := strings.ToLower(value)
+	for _, placeholder := range placeholders {
+		if strings.Contains(lower, placeholder) {
+			return true
+		}
+	}
+	return false
+}
+
+// redact keeps the first characters of a secret, enough to tell which one it
+// is
+func redact(secret string) string {
+	if len(secret) <= 8 {
+		return strings.Repeat("*", len(secret))
+	}
+	return secret[:4] + strings.Repeat("*", 8)
+}
+
+// ScanResponses fetches target and probes its debug paths, returning a
+// vulnerability for each credential in the page and each debug path served
+func ScanResponses(client *http.Client, target string) ([]*Vulnerability, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	body, _, err := fetch(client, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var vulns []*Vulnerability
+	for _, found := range findSecrets(body) {
+		vulns = append(vulns, &Vulnerability{
+			ID:          "exposure:" + target + ":" + found.pattern.name,
+			Type:        VulnDataExposure,
+			Level:       found.pattern.level,
+			Description: fmt.Sprintf("%s %s in the response of %s", found.pattern.description, redact(found.value), target),
+			Location:    target,
+			Timestamp:   time.Now(),
+			Remediation: "Revoke and rotate the credential, and keep it on the server",
+		})
+	}
+
+	base := strings.TrimSuffix(target, "/")
+	for _, debug := range debugPaths {
+		url := base + debug.path
+		body, status, err := fetch(client, url)
+		if err != nil || status != http.StatusOK || !strings.Contains(body, debug.marker) ||
+			debug.plain && strings.HasPrefix(strings.TrimSpace(body), "<") {
+			continue
+		}
+		vulns = append(vulns, &Vulnerability{
+			ID:          "exposure:" + url,
+			Type:        VulnMisconfiguration,
+			Level:       debug.level,
+			Description: fmt.Sprintf("%s at %s", debug.description, url),
+			Location:    url,
+			Timestamp:   time.Now(),
+			Remediation: fmt.Sprintf("Stop serving %s in production", debug.path),
+		})
+	}
+	return vulns, nil
+}
+
+// fetch gets a URL's body, up to 1MB, and status
+func fetch(client *http.Client, url string) (string, int, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxScannedFileSize))
+	return string(body), resp.StatusCode, err
+}
+
+// ScanSecrets scans Config.ProjectDir and the responses of Config.TargetURL,
+// recording what it finds as vulnerabilities and marking the findings no
+// longer found fixed. Either is skipped when empty.
+func (sm *SecurityMonitor) ScanSecrets() error {
+	sm.mutex.RLock()
+	dir, target := sm.Config.ProjectDir, sm.Config.TargetURL
+	sm.mutex.RUnlock()
+
+	if dir != "" {
+		vulns, err := ScanProject(dir)
+		if err != nil {
+			return fmt.Errorf("scanning %s for secrets: %v", dir, err)
+		}
+		sm.recordFindings("secret:", vulns)
+	}
+	if target != "" {
+		vulns, err := ScanResponses(nil, target)
+		if err != nil {
+			return fmt.Errorf("scanning the responses of %s: %v", target, err)
+		}
+		sm.recordFindings("exposure:", vulns)
+	}
+	return nil
+}
+
+// recordFindings records the vulnerabilities a scan found, keeping when those
+// already open were detected, and marks the others whose IDs start with
+// prefix fixed
+func (sm *SecurityMonitor) recordFindings(prefix string, vulns []*Vulnerability) {
+	sm.mutex.Lock()
+	defer sm.mutex.Unlock()
+
+	found := make(map[string]bool)
+	for _, vuln := range vulns {
+		found[vuln.ID] = true
+		if existing, ok := sm.Vulnerabilities[vuln.ID]; ok && !existing.Fixed {
+			vuln.Timestamp = existing.Timestamp
+		}
+		sm.Vulnerabilities[vuln.ID] = vuln
+	}
+	for id, vuln := range sm.Vulnerabilities {
+		if strings.HasPrefix(id, prefix) && !found[id] {
+			vuln.Fixed = true
+		}
+	}
+	sm.updateSecurityMetrics()
+}
@@ -0,0 +1,107 @@
+package security
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+func TestScanProject(t *testing.T) {
+	// The credentials are split so that scanning this repository finds none
+	awsKey := "AKIA" + "IOSFODNN7EXAMPLF"
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.go": "package main\n\nimport (\n\t\"net/http\"\n\t_ \"net/http/pprof\"\n)\n",
+		"config/prod.yaml": "region: eu-west-1\naws_key: " + awsKey + "\n" +
+			"pass" + "word: \"hunter2hunter2\"\ntoken: \"${TOKEN}\"\n",
+		"deploy/id_rsa":                 "-----BEGIN RSA " + "PRIVATE KEY-----\nMIIEpAIBAAKCAQEA\n",
+		"README.md":                     "Set api_key = \"your-api-key-here\" in the config.\n",
+		"node_modules/lib/index.js":     "const key = '" + awsKey + "'\n",
+		"assets/logo.png":               "\x89PNG\x00" + awsKey,
+		"internal/debug.go":             "package internal\n\n// expvar is not imported here\n",
+		"internal/testdata/fixture.txt": awsKey,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	vulns, err := ScanProject(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]*Vulnerability)
+	for _, vuln := range vulns {
+		found[vuln.ID] = vuln
+	}
+	if len(found) != 4 {
+		t.Fatalf("expected 4 findings, got %v", found)
+	}
+	if vuln := found["secret:config/prod.yaml:2:aws-access-key"]; vuln == nil || vuln.Level != SecurityLevelCritical ||
+		vuln.Location != "config/prod.yaml:2" || vuln.Description != "AWS access key ID AKIA******** in config/prod.yaml" {
+		t.Fatalf("unexpected AWS key finding %+v", vuln)
+	}
+	if vuln := found["secret:config/prod.yaml:3:generic-secret"]; vuln == nil || vuln.Level != SecurityLevelMedium || strings.Contains(vuln.Description, "hunter2") {
+		t.Fatalf("unexpected password finding %+v", vuln)
+	}
+	if vuln := found["secret:deploy/id_rsa:1:private-key"]; vuln == nil || vuln.Type != VulnDataExposure {
+		t.Fatalf("unexpected private key finding %+v", vuln)
+	}
+	if vuln := found["secret:main.go:5:debug-endpoint"]; vuln == nil || vuln.Type != VulnMisconfiguration || !strings.Contains(vuln.Description, "/debug/pprof/") {
+		t.Fatalf("unexpected debug endpoint finding %+v", vuln)
+	}
+}
+
+func TestScanSecrets(t *testing.T) {
+	pprof := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/":
+			w.Write([]byte(`<html><script>const stripe = "sk_` + `live_4eC39HqLyjWDarjtT1zdp7dc";</script></html>`))
+		case r.URL.Path == "/debug/pprof/" && pprof:
+			w.Write([]byte("<html>Types of profiles available: ...</html>"))
+		default:
+			// Like single page apps, answer every path with the index
+			w.Write([]byte("<!DOCTYPE html><html>a=b [core]</html>"))
+		}
+	}))
+	defer server.Close()
+
+	sm := NewSecurityMonitor(core.NewJetpack())
+	sm.Config.TargetURL = server.URL + "/"
+	sm.Config.ProjectDir = t.TempDir()
+	sm.Config.AuditReport = ""
+	sm.ScanVulnerabilities()
+
+	stripe, err := sm.GetVulnerability("exposure:" + server.URL + "/:stripe-key")
+	if err != nil || stripe.Level != SecurityLevelCritical {
+		t.Fatalf("expected the key in the page to be found, got %+v %v", stripe, err)
+	}
+	profiles, err := sm.GetVulnerability("exposure:" + server.URL + "/debug/pprof/")
+	if err != nil || profiles.Type != VulnMisconfiguration {
+		t.Fatalf("expected the served profiles to be found, got %+v %v", profiles, err)
+	}
+	for _, path := range []string{"/debug/vars", "/.env", "/.git/config"} {
+		if _, err := sm.GetVulnerability("exposure:" + server.URL + path); err == nil {
+			t.Fatalf("expected the index page at %s not to be flagged", path)
+		}
+	}
+
+	pprof = false
+	sm.ScanVulnerabilities()
+	if profiles, _ := sm.GetVulnerability("exposure:" + server.URL + "/debug/pprof/"); profiles == nil || !profiles.Fixed {
+		t.Fatalf("expected the profiles no longer served to be fixed, got %+v", profiles)
+	}
+	if stripe, _ := sm.GetVulnerability("exposure:" + server.URL + "/:stripe-key"); stripe == nil || stripe.Fixed {
+		t.Fatalf("expected the key still in the page to stay open, got %+v", stripe)
+	}
+}
@@ -1,6 +1,7 @@
 package security
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -49,6 +50,12 @@ type Vulnerability struct {
 	Remediation string            `json:"remediation"`
 	References  []string          `json:"references"`
 	Fixed       bool              `json:"fixed"`
+	
+	// Package, Version and FixedIn describe the dependency an outdated
+	// library vulnerability is in, and the version fixing it
+	Package     string            `json:"package,omitempty"`
+	Version     string            `json:"version,omitempty"`
+	FixedIn     string            `json:"fixed_in,omitempty"`
 }
 
 // SecurityConfig represents the configuration for security monitoring
@@ -63,6 +70,37 @@ type SecurityConfig struct {
 	AutoFix                bool          `json:"auto_fix"`
 	ReportPath             string        `json:"report_path"`
 	ExcludePaths           []string      `json:"exclude_paths"`
+	
+	// Headers are the security headers Middleware sends
+	Headers                SecurityHeaders `json:"headers"`
+	
+	// TargetURL is the app's URL, whose response headers vulnerability
+	// scans check; empty skips the check
+	TargetURL              string        `json:"target_url"`
+	
+	// AuditReport is the report gopm audit writes, whose advisories
+	// vulnerability scans report as outdated libraries; empty skips them
+	AuditReport            string        `json:"audit_report"`
+	
+	// ProjectDir is the project's source, which vulnerability scans walk
+	// for secrets and debug endpoints; empty skips the walk
+	ProjectDir             string        `json:"project_dir"`
+	
+	// CertificateHosts are the hosts, on port 443 unless given one, whose
+	// certificates are checked every CertificateCheckInterval, alerting
+	// CertificateExpiryDays days before they expire
+	CertificateHosts       []string      `json:"certificate_hosts"`
+	CertificateCheckInterval time.Duration `json:"certificate_check_interval"`
+	CertificateExpiryDays  []int         `json:"certificate_expiry_days"`
+	
+	// ComplianceProfiles are the profiles compliance checks run, such as
+	// owasp-asvs and cis
+	ComplianceProfiles     []string      `json:"compliance_profiles"`
+	
+	// CSPNonce has Middleware allow inline scripts and styles by a nonce
+	// made for each response, which it passes on in the request's context
+	// for the tags goscript layouts and the panel inject
+	CSPNonce               bool          `json:"csp_nonce"`
 }
 
 // SecurityMonitor monitors security vulnerabilities and issues
@@ -75,6 +113,20 @@ type SecurityMonitor struct {
 	LastScanTime    time.Time
 	ScanCount       int
 	mutex           sync.RWMutex
+	
+	// Anomalies scores the requests Middleware sees and the failed logins
+	// TrackAuthFailure counts, for DetectAnomalies
+	Anomalies       *AnomalyDetector
+	
+	// Lockouts locks out the IPs and users TrackAuthFailure counts too many
+	// failed logins for; set it as the Guard of goscript's session manager
+	Lockouts        *LockoutManager
+	
+	// middleware is whether Middleware is in use, so AutoFix can fix headers
+	middleware      bool
+	
+	// complianceReports are the latest reports of each compliance profile
+	complianceReports map[string]*ComplianceReport
 }
 
 // NewSecurityMonitor creates a new security monitor
@@ -92,13 +144,44 @@ func NewSecurityMonitor(jetpack *core.Jetpack) *SecurityMonitor {
 			AutoFix:                false,
 			ReportPath:             "security_report.json",
 			ExcludePaths:           []string{"/assets/", "/public/"},
+			Headers:                DefaultSecurityHeaders(),
+			AuditReport:            DefaultAuditReport,
+			CertificateCheckInterval: 6 * time.Hour,
+			CertificateExpiryDays:  DefaultCertificateExpiryDays,
+			ComplianceProfiles:     []string{"owasp-asvs"},
 		},
 		Vulnerabilities:     make(map[string]*Vulnerability),
 		AuthFailures:        make(map[string]int),
 		SuspiciousActivities: make([]string, 0),
 		LastScanTime:        time.Time{},
 		ScanCount:           0,
+		Anomalies:           NewAnomalyDetector(),
+		Lockouts:            NewLockoutManager(),
+	}
+}
+
+// UpdateConfig changes the config under the monitor's lock and records the
+// fields it changed in Jetpack's audit log, as changed by the actor of ctx
+func (sm *SecurityMonitor) UpdateConfig(ctx context.Context, update func(config *SecurityConfig)) {
+	sm.mutex.Lock()
+	// Marshal before updating, as update may change the config's slices in place
+	before, err := json.Marshal(sm.Config)
+	update(&sm.Config)
+	after, afterErr := json.Marshal(sm.Config)
+	sm.mutex.Unlock()
+	
+	if err == nil {
+		err = afterErr
 	}
+	if err != nil {
+		sm.Jetpack.ReportError(core.ErrorReport{
+			Source:    "jetpack",
+			Component: "security",
+			Message:   fmt.Sprintf("auditing the config: %v", err),
+		})
+		return
+	}
+	sm.Jetpack.AuditChanges(ctx, "security", json.RawMessage(before), json.RawMessage(after))
 }
 
 // StartMonitoring starts security monitoring
@@ -124,6 +207,11 @@ func (sm *SecurityMonitor) StartMonitoring() {
 	if sm.Config.ComplianceCheckEnabled {
 		go sm.startComplianceCheck()
 	}
+	
+	// Start certificate expiry checking
+	if len(sm.Config.CertificateHosts) > 0 {
+		go sm.startCertificateMonitoring()
+	}
 }
 
 // registerSecurityMetrics registers security metrics with Jetpack
@@ -212,14 +300,10 @@ func (sm *SecurityMonitor) startComplianceCheck() {
 // ScanVulnerabilities scans for vulnerabilities
 func (sm *SecurityMonitor) ScanVulnerabilities() {
 	sm.mutex.Lock()
-	defer sm.mutex.Unlock()
 	
 	sm.LastScanTime = time.Now()
 	sm.ScanCount++
 	
-	// In a real implementation, this would scan for actual vulnerabilities
-	// For now, we'll simulate finding some vulnerabilities
-	
 	// Clear fixed vulnerabilities
 	for id, vuln := range sm.Vulnerabilities {
 		if vuln.Fixed {
@@ -227,94 +311,86 @@ func (sm *SecurityMonitor) ScanVulnerabilities() {
 		}
 	}
 	
-	// Simulate finding vulnerabilities
-	vulnerabilities := []*Vulnerability{
-		{
-			ID:          "VULN-001",
-			Type:        VulnMissingHeaders,
-			Level:       SecurityLevelMedium,
-			Description: "Missing Content-Security-Policy header",
-			Location:    "HTTP Response Headers",
-			Timestamp:   time.Now(),
-			Remediation: "Add Content-Security-Policy header to all responses",
-			References:  []string{"https://developer.mozilla.org/en-US/docs/Web/HTTP/CSP"},
-			Fixed:       false,
-		},
-		{
-			ID:          "VULN-002",
-			Type:        VulnOutdatedLibrary,
-			Level:       SecurityLevelHigh,
-			Description: "Using outdated library with known vulnerabilities",
-			Location:    "package.json",
-			Timestamp:   time.Now(),
-			Remediation: "Update the library to the latest version",
-			References:  []string{"https://nvd.nist.gov/vuln/detail/CVE-2021-12345"},
-			Fixed:       false,
-		},
+	// Report the advisories of the dependencies
+	if err := sm.scanDependencies(); err != nil {
+		sm.Jetpack.ReportError(core.ErrorReport{
+			Source:    "jetpack",
+			Component: "security",
+			Message:   err.Error(),
+		})
 	}
 	
-	// Add vulnerabilities to the map
-	for _, vuln := range vulnerabilities {
-		if _, ok := sm.Vulnerabilities[vuln.ID]; !ok {
-			sm.Vulnerabilities[vuln.ID] = vuln
+	// Update metrics
+	sm.updateSecurityMetrics()
+	
+	target := sm.Config.TargetURL
+	sm.mutex.Unlock()
+	
+	// Check the app's headers, which takes the lock itself
+	if target != "" {
+		if _, err := sm.CheckSecurityHeaders(target); err != nil {
+			sm.Jetpack.ReportError(core.ErrorReport{
+				Source:    "jetpack",
+				Component: "security",
+				Message:   fmt.Sprintf("checking the security headers of %s: %v", target, err),
+			})
 		}
 	}
 	
-	// Update metrics
-	sm.updateSecurityMetrics()
+	// Check the integrity of the app's scripts and stylesheets
+	if err := sm.CheckSubresourceIntegrity(); err != nil {
+		sm.Jetpack.ReportError(core.ErrorReport{
+			Source:    "jetpack",
+			Component: "security",
+			Message:   err.Error(),
+		})
+	}
+	
+	// Look for exposed secrets and debug endpoints
+	if err := sm.ScanSecrets(); err != nil {
+		sm.Jetpack.ReportError(core.ErrorReport{
+			Source:    "jetpack",
+			Component: "security",
+			Message:   err.Error(),
+		})
+	}
 }
 
-// DetectAnomalies detects security anomalies
+// DetectAnomalies starts a new detection period: it forgets the clients
+// idle for the detector's window and clears the suspicious activities of
+// the last period. Requests are scored as Middleware sees them.
 func (sm *SecurityMonitor) DetectAnomalies() {
+	sm.Anomalies.Prune(time.Now())
+	
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
 	
-	// In a real implementation, this would analyze logs and detect anomalies
-	// For now, we'll simulate finding some anomalies
-	
-	// Clear old activities
 	sm.SuspiciousActivities = make([]string, 0)
 	
-	// Simulate finding anomalies
-	sm.SuspiciousActivities = append(sm.SuspiciousActivities,
-		fmt.Sprintf("Unusual login pattern detected from IP 192.168.1.100 at %s", time.Now().Format(time.RFC3339)),
-		fmt.Sprintf("Multiple failed API requests from IP 192.168.1.200 at %s", time.Now().Format(time.RFC3339)),
-	)
-	
 	// Update metrics
 	sm.updateSecurityMetrics()
 }
 
-// CheckCompliance checks security compliance
+// CheckCompliance runs the checks of Config.ComplianceProfiles, recording
+// their scores; ComplianceReport returns the reports
 func (sm *SecurityMonitor) CheckCompliance() {
-	sm.mutex.Lock()
-	defer sm.mutex.Unlock()
-	
-	// In a real implementation, this would check for compliance with security standards
-	// For now, we'll simulate a compliance check
-	
-	// Simulate compliance check
-	complianceIssues := []string{
-		"Missing HTTPS redirection",
-		"Weak TLS configuration",
-		"Missing rate limiting",
-	}
-	
-	// Log compliance issues
-	for _, issue := range complianceIssues {
-		fmt.Printf("Compliance issue: %s\n", issue)
-	}
-	
-	// Update metrics
-	securityScore := 100.0 - (float64(len(complianceIssues)) * 10.0)
-	if securityScore < 0 {
-		securityScore = 0
+	sm.mutex.RLock()
+	profiles := append([]string(nil), sm.Config.ComplianceProfiles...)
+	sm.mutex.RUnlock()
+	
+	for _, profile := range profiles {
+		if _, err := sm.RunCompliance(profile); err != nil {
+			sm.Jetpack.ReportError(core.ErrorReport{
+				Source:    "jetpack",
+				Component: "security",
+				Message:   err.Error(),
+			})
+		}
 	}
-	
-	sm.Jetpack.RecordMetric("security_score", securityScore)
 }
 
-// TrackAuthFailure tracks authentication failures
+// TrackAuthFailure tracks authentication failures, locking out the user or
+// IP once they go over a policy of Lockouts
 func (sm *SecurityMonitor) TrackAuthFailure(username, ipAddress string) {
 	sm.mutex.Lock()
 	defer sm.mutex.Unlock()
@@ -322,11 +398,19 @@ func (sm *SecurityMonitor) TrackAuthFailure(username, ipAddress string) {
 	key := fmt.Sprintf("%s:%s", username, ipAddress)
 	sm.AuthFailures[key]++
 	
+	// Correlate the failure with the IP's requests
+	sm.Anomalies.AuthFailure(ipAddress, time.Now())
+	
 	// Check for brute force attempts
 	if sm.AuthFailures[key] >= 5 {
-		sm.SuspiciousActivities = append(sm.SuspiciousActivities,
-			fmt.Sprintf("Possible brute force attack: %d failed login attempts for user %s from IP %s", 
-				sm.AuthFailures[key], username, ipAddress))
+		sm.addSuspiciousActivity(fmt.Sprintf("Possible brute force attack: %d failed login attempts for user %s from IP %s", 
+			sm.AuthFailures[key], username, ipAddress))
+	}
+	
+	// Lock out the clients going over a policy
+	for _, lockout := range sm.Lockouts.Failure(username, ipAddress) {
+		sm.addSuspiciousActivity(fmt.Sprintf("Locked out %s until %s: %s",
+			lockout.Key, lockout.Until.Format(time.RFC3339), lockout.Reason))
 	}
 	
 	// Update metrics
@@ -340,6 +424,7 @@ func (sm *SecurityMonitor) ResetAuthFailures(username, ipAddress string) {
 	
 	key := fmt.Sprintf("%s:%s", username, ipAddress)
 	delete(sm.AuthFailures, key)
+	sm.Lockouts.Success(username, ipAddress)
 	
 	// Update metrics
 	sm.updateSecurityMetrics()
@@ -483,16 +568,10 @@ func (sm *SecurityMonitor) GenerateReport() (string, error) {
 // CheckTLSConfiguration checks the TLS configuration of a server
 func (sm *SecurityMonitor) CheckTLSConfiguration(host string) (map[string]interface{}, error) {
 	// Connect to the server
-	conn, err := tls.Dial("tcp", host, &tls.Config{
-		InsecureSkipVerify: true,
-	})
+	state, err := dialTLS(host)
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Close()
-	
-	// Get the TLS connection state
-	state := conn.ConnectionState()
 	
 	// Check TLS version
 	var tlsVersion string
@@ -527,6 +606,7 @@ func (sm *SecurityMonitor) CheckTLSConfiguration(host string) (map[string]interf
 			"not_after":    cert.NotAfter,
 			"dns_names":    cert.DNSNames,
 			"serial_number": cert.SerialNumber.String(),
+			"days_remaining": firstExpiring(state.PeerCertificates).NotAfter.Sub(time.Now()).Hours() / 24,
 		},
 		"secure": state.Version >= tls.VersionTLS12,
 	}
@@ -534,7 +614,8 @@ func (sm *SecurityMonitor) CheckTLSConfiguration(host string) (map[string]interf
 	return result, nil
 }
 
-// CheckSecurityHeaders checks security headers in an HTTP response
+// CheckSecurityHeaders checks security headers in an HTTP response, recording
+// a vulnerability for each missing or invalid one
 func (sm *SecurityMonitor) CheckSecurityHeaders(url string) (map[string]interface{}, error) {
 	// Make a request to the URL
 	resp, err := http.Get(url)
@@ -546,17 +627,6 @@ func (sm *SecurityMonitor) CheckSecurityHeaders(url string) (map[string]interfac
 	// Check security headers
 	headers := resp.Header
 	
-	// Required security headers
-	requiredHeaders := map[string]string{
-		"Content-Security-Policy": "",
-		"X-Content-Type-Options": "nosniff",
-		"X-Frame-Options": "DENY",
-		"X-XSS-Protection": "1; mode=block",
-		"Strict-Transport-Security": "max-age=31536000; includeSubDomains",
-		"Referrer-Policy": "no-referrer",
-		"Permissions-Policy": "",
-	}
-	
 	// Check each header
 	headerResults := make(map[string]interface{})
 	for header, expectedValue := range requiredHeaders {
@@ -604,20 +674,36 @@ func (sm *SecurityMonitor) CheckSecurityHeaders(url string) (map[string]interfac
 		"percentage": (score / maxScore) * 100.0,
 	}
 	
+	sm.recordHeaderFindings(url, headers)
+	
 	return result, nil
 }
 
+// sqlInjectionPayloads are the SQL injection payloads the scanner tries and
+// the request filter blocks
+var sqlInjectionPayloads = []string{
+	"' OR '1'='1",
+	"1' OR '1'='1",
+	"' OR 1=1--",
+	"' OR 1=1#",
+	"') OR 1=1--",
+	"admin'--",
+}
+
+// xssPayloads are the XSS payloads the scanner tries and the request filter
+// blocks
+var xssPayloads = []string{
+	"<script>alert(1)</script>",
+	"<img src=x onerror=alert(1)>",
+	"<svg onload=alert(1)>",
+	"\"><script>alert(1)</script>",
+	"'><script>alert(1)</script>",
+	"javascript:alert(1)",
+}
+
 // ScanForSQLInjection scans for SQL injection vulnerabilities
 func (sm *SecurityMonitor) ScanForSQLInjection(url string, params map[string]string) (map[string]interface{}, error) {
-	// SQL injection payloads
-	payloads := []string{
-		"' OR '1'='1",
-		"1' OR '1'='1",
-		"' OR 1=1--",
-		"' OR 1=1#",
-		"') OR 1=1--",
-		"admin'--",
-	}
+	payloads := sqlInjectionPayloads
 	
 	// Build result
 	result := map[string]interface{}{
@@ -645,15 +731,7 @@ func (sm *SecurityMonitor) ScanForSQLInjection(url string, params map[string]str
 
 // ScanForXSS scans for XSS vulnerabilities
 func (sm *SecurityMonitor) ScanForXSS(url string, params map[string]string) (map[string]interface{}, error) {
-	// XSS payloads
-	payloads := []string{
-		"<script>alert(1)</script>",
-		"<img src=x onerror=alert(1)>",
-		"<svg onload=alert(1)>",
-		"\"><script>alert(1)</script>",
-		"'><script>alert(1)</script>",
-		"javascript:alert(1)",
-	}
+	payloads := xssPayloads
 	
 	// Build result
 	result := map[string]interface{}{
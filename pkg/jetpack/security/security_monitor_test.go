@@ -0,0 +1,26 @@
+package security
+
+import (
+	"context"
+	"testing"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+func TestUpdateConfigAudits(t *testing.T) {
+	sm := NewSecurityMonitor(core.NewJetpack())
+	ctx := core.WithAuditActor(context.Background(), "ada")
+	sm.UpdateConfig(ctx, func(config *SecurityConfig) {
+		config.AutoFix = true
+		config.ExcludePaths[0] = "/static/"
+	})
+	sm.UpdateConfig(ctx, func(config *SecurityConfig) {})
+
+	entries, err := sm.Jetpack.Audit.Query(context.Background(), core.AuditQuery{Component: "security"})
+	if err != nil || len(entries) != 2 || entries[0].Setting != "auto_fix" || entries[1].Setting != "exclude_paths" {
+		t.Fatalf("expected the two changed fields to be audited, got %+v %v", entries, err)
+	}
+	if string(entries[1].Old) != `["/assets/","/public/"]` || string(entries[1].New) != `["/static/","/public/"]` || entries[1].Actor != "ada" {
+		t.Fatalf("unexpected entry %+v", entries[1])
+	}
+}
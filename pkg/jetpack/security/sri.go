@@ -0,0 +1,144 @@
+package security
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+// maxAssetSize caps the size of the assets integrity is checked for
+const maxAssetSize = 32 << 20
+
+// The tags loading scripts and stylesheets, and their attributes
+var (
+	assetTagPattern = regexp.MustCompile(`(?is)<(script|link)\b([^>]*)>`)
+	attrPattern     = regexp.MustCompile(`(?s)([a-zA-Z-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+)
+
+// attributes parses the attributes of a tag
+func attributes(tag string) map[string]string {
+	attrs := make(map[string]string)
+	for _, match := range attrPattern.FindAllStringSubmatch(tag, -1) {
+		attrs[strings.ToLower(match[1])] = match[2] + match[3] + match[4]
+	}
+	return attrs
+}
+
+// ScanSubresourceIntegrity checks the scripts and stylesheets the page at
+// target loads: those with an integrity attribute must match it, as browsers
+// refuse them otherwise, and those from other origins should have one, so a
+// compromised CDN cannot change them
+func ScanSubresourceIntegrity(client *http.Client, target string) ([]*Vulnerability, error) {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	page, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	body, _, err := fetch(client, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var vulns []*Vulnerability
+	seen := make(map[string]bool)
+	for _, match := range assetTagPattern.FindAllStringSubmatch(body, -1) {
+		attrs := attributes(match[2])
+		ref := attrs["src"]
+		if strings.EqualFold(match[1], "link") {
+			if !strings.EqualFold(attrs["rel"], "stylesheet") {
+				continue
+			}
+			ref = attrs["href"]
+		}
+		if ref == "" {
+			continue
+		}
+		asset, err := page.Parse(ref)
+		if err != nil || (asset.Scheme != "http" && asset.Scheme != "https") || seen[asset.String()] {
+			continue
+		}
+		seen[asset.String()] = true
+
+		integrity, ok := attrs["integrity"]
+		if !ok {
+			if asset.Host == page.Host {
+				continue
+			}
+			vulns = append(vulns, &Vulnerability{
+				ID:          "sri:" + asset.String(),
+				Type:        VulnMisconfiguration,
+				Level:       SecurityLevelMedium,
+				Description: fmt.Sprintf("%s loads %s from another origin without an integrity attribute", target, asset),
+				Location:    target,
+				Timestamp:   time.Now(),
+				Remediation: fmt.Sprintf(`Add integrity="sha384-..." and crossorigin="anonymous" to the tag loading %s, such as with goscript's Head.ScriptIntegrity`, asset),
+				References:  []string{"https://developer.mozilla.org/en-US/docs/Web/Security/Subresource_Integrity"},
+			})
+			continue
+		}
+
+		data, err := fetchAsset(client, asset.String())
+		if err != nil {
+			continue
+		}
+		if err := core.VerifySRI(integrity, data); err != nil {
+			vulns = append(vulns, &Vulnerability{
+				ID:          "sri:" + asset.String(),
+				Type:        VulnMisconfiguration,
+				Level:       SecurityLevelHigh,
+				Description: fmt.Sprintf("%s fails its integrity check on %s: %v", asset, target, err),
+				Location:    target,
+				Timestamp:   time.Now(),
+				Remediation: "Find out why the asset changed; if the change is expected, regenerate its integrity hash",
+				References:  []string{"https://developer.mozilla.org/en-US/docs/Web/Security/Subresource_Integrity"},
+			})
+		}
+	}
+	return vulns, nil
+}
+
+// fetchAsset downloads an asset whole, failing for one over maxAssetSize
+// rather than checking part of it
+func fetchAsset(client *http.Client, address string) ([]byte, error) {
+	resp, err := client.Get(address)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", address, resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxAssetSize+1))
+	if err == nil && len(data) > maxAssetSize {
+		err = fmt.Errorf("%s is over %d bytes", address, maxAssetSize)
+	}
+	return data, err
+}
+
+// CheckSubresourceIntegrity checks the assets of Config.TargetURL with
+// ScanSubresourceIntegrity, recording what it finds as vulnerabilities and
+// marking the findings no longer found fixed
+func (sm *SecurityMonitor) CheckSubresourceIntegrity() error {
+	sm.mutex.RLock()
+	target := sm.Config.TargetURL
+	sm.mutex.RUnlock()
+
+	if target == "" {
+		return nil
+	}
+	vulns, err := ScanSubresourceIntegrity(nil, target)
+	if err != nil {
+		return fmt.Errorf("checking the subresource integrity of %s: %v", target, err)
+	}
+	sm.recordFindings("sri:", vulns)
+	return nil
+}
@@ -0,0 +1,78 @@
+package security
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+func TestScanSubresourceIntegrity(t *testing.T) {
+	script := "console.log(1)"
+	var page string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app.js", "/changed.js":
+			w.Write([]byte(script))
+		default:
+			w.Write([]byte(page))
+		}
+	}))
+	defer server.Close()
+
+	page = `<link rel="icon" href="https://cdn.example.com/favicon.ico">` +
+		`<link rel="stylesheet" href="https://cdn.example.com/site.css">` +
+		`<script src="/app.js" integrity="` + core.SRIHash([]byte(script)) + `"></script>` +
+		`<script src="/changed.js" integrity='` + core.SRIHash([]byte("console.log(2)")) + `'></script>` +
+		`<script src="/local.js"></script>`
+	vulns, err := ScanSubresourceIntegrity(nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]*Vulnerability)
+	for _, vuln := range vulns {
+		found[vuln.ID] = vuln
+	}
+	if len(found) != 2 {
+		t.Fatalf("expected 2 findings, got %v", found)
+	}
+	if vuln := found["sri:https://cdn.example.com/site.css"]; vuln == nil || vuln.Level != SecurityLevelMedium {
+		t.Fatalf("unexpected finding for the CDN stylesheet %+v", vuln)
+	}
+	if vuln := found["sri:"+server.URL+"/changed.js"]; vuln == nil || vuln.Level != SecurityLevelHigh || !strings.Contains(vuln.Description, "does not match") {
+		t.Fatalf("unexpected finding for the changed script %+v", vuln)
+	}
+}
+
+func TestMiddlewareCSPNonce(t *testing.T) {
+	sm := NewSecurityMonitor(core.NewJetpack())
+	sm.Config.Headers.ContentSecurityPolicy = "default-src 'self'; img-src *"
+	sm.Config.CSPNonce = true
+
+	var nonces []string
+	handler := sm.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonces = append(nonces, core.CSPNonce(r.Context()))
+	}))
+	var policies []string
+	for i := 0; i < 2; i++ {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/", nil))
+		policies = append(policies, recorder.Header().Get("Content-Security-Policy"))
+	}
+	if nonces[0] == "" || nonces[0] == nonces[1] {
+		t.Fatalf("expected a fresh nonce per response, got %q", nonces)
+	}
+	want := "default-src 'self'; img-src *; script-src 'self' 'nonce-" + nonces[0] + "'; style-src 'self' 'nonce-" + nonces[0] + "'"
+	if policies[0] != want {
+		t.Fatalf("unexpected policy\n got %s\nwant %s", policies[0], want)
+	}
+
+	if got := policyWithNonce("default-src 'none'; script-src 'strict-dynamic'", "n"); got != "default-src 'none'; script-src 'strict-dynamic' 'nonce-n'; style-src 'nonce-n'" {
+		t.Fatalf("unexpected policy %s", got)
+	}
+	if got := policyWithNonce("frame-ancestors 'none'", "n"); got != "frame-ancestors 'none'" {
+		t.Fatalf("expected a policy not restricting scripts to stay, got %s", got)
+	}
+}
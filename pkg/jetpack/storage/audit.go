@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscale/db"
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+// GoScaleAuditStore keeps the Jetpack audit log in a GoScaleDB table that
+// triggers make append-only: updates and deletes of its rows fail. The
+// triggers stop the application, not whoever owns the database, who can drop
+// them; set the AuditLog's Key and Anchor so Verify finds their changes too.
+type GoScaleAuditStore struct {
+	DB *db.GoScaleDB
+
+	// Schema and Table name the table the entries are kept in
+	Schema string
+	Table  string
+}
+
+var _ core.AuditStore = (*GoScaleAuditStore)(nil)
+
+// NewGoScaleAuditStore creates a store in database's jetpack.audit_log
+// table; call Init before using it
+func NewGoScaleAuditStore(database *db.GoScaleDB) *GoScaleAuditStore {
+	return &GoScaleAuditStore{
+		DB:     database,
+		Schema: "jetpack",
+		Table:  "audit_log",
+	}
+}
+
+// Init creates the table and the triggers refusing to change it. It can be
+// called on every start.
+func (s *GoScaleAuditStore) Init(ctx context.Context) error {
+	if !identifier.MatchString(s.Schema) || !identifier.MatchString(s.Table) {
+		return fmt.Errorf("storage: invalid table name %s.%s", s.Schema, s.Table)
+	}
+
+	statements := []string{
+		fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", s.Schema),
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			seq BIGINT PRIMARY KEY,
+			time TIMESTAMPTZ NOT NULL,
+			actor TEXT NOT NULL,
+			component TEXT NOT NULL,
+			setting TEXT NOT NULL,
+			old_value TEXT NOT NULL,
+			new_value TEXT NOT NULL,
+			prev_hash TEXT NOT NULL,
+			hash TEXT NOT NULL
+		)`, s.table()),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_component_time ON %s (component, time)", s.Table, s.table()),
+		fmt.Sprintf(`CREATE OR REPLACE FUNCTION %s.%s_append_only() RETURNS trigger AS $$
+		BEGIN
+			RAISE EXCEPTION '%s is append-only';
+		END
+		$$ LANGUAGE plpgsql`, s.Schema, s.Table, s.table()),
+		fmt.Sprintf("DROP TRIGGER IF EXISTS %s_append_only ON %s", s.Table, s.table()),
+		fmt.Sprintf("CREATE TRIGGER %s_append_only BEFORE UPDATE OR DELETE ON %s FOR EACH ROW EXECUTE FUNCTION %s.%s_append_only()",
+			s.Table, s.table(), s.Schema, s.Table),
+		fmt.Sprintf("DROP TRIGGER IF EXISTS %s_no_truncate ON %s", s.Table, s.table()),
+		fmt.Sprintf("CREATE TRIGGER %s_no_truncate BEFORE TRUNCATE ON %s FOR EACH STATEMENT EXECUTE FUNCTION %s.%s_append_only()",
+			s.Table, s.table(), s.Schema, s.Table),
+	}
+	for _, statement := range statements {
+		if _, err := s.DB.Execute(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// table returns the qualified table name
+func (s *GoScaleAuditStore) table() string {
+	return s.Schema + "." + s.Table
+}
+
+// Append inserts an entry; the primary key refuses a Seq another process
+// took first
+func (s *GoScaleAuditStore) Append(ctx context.Context, entry core.AuditEntry) error {
+	query := fmt.Sprintf("INSERT INTO %s (seq, time, actor, component, setting, old_value, new_value, prev_hash, hash) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)", s.table())
+	_, err := s.DB.Execute(ctx, query, entry.Seq, entry.Time, entry.Actor, entry.Component, entry.Setting,
+		string(entry.Old), string(entry.New), entry.PrevHash, entry.Hash)
+	return err
+}
+
+// Last returns the entry with the highest seq
+func (s *GoScaleAuditStore) Last(ctx context.Context) (*core.AuditEntry, error) {
+	entries, err := s.query(ctx, fmt.Sprintf("SELECT * FROM %s ORDER BY seq DESC LIMIT 1", s.table()))
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+// Query returns the entries matching query, oldest first
+func (s *GoScaleAuditStore) Query(ctx context.Context, query core.AuditQuery) ([]core.AuditEntry, error) {
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+	if query.Component != "" {
+		where("component = $%d", query.Component)
+	}
+	if query.Setting != "" {
+		where("setting = $%d", query.Setting)
+	}
+	if query.Actor != "" {
+		where("actor = $%d", query.Actor)
+	}
+	if !query.From.IsZero() {
+		where("time >= $%d", query.From)
+	}
+	if !query.To.IsZero() {
+		where("time <= $%d", query.To)
+	}
+
+	sql := "SELECT * FROM " + s.table()
+	if len(conditions) > 0 {
+		sql += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	if query.Limit > 0 {
+		// The latest Limit entries, put back in order
+		sql = fmt.Sprintf("SELECT * FROM (%s ORDER BY seq DESC LIMIT %d) latest", sql, query.Limit)
+	}
+	return s.query(ctx, sql+" ORDER BY seq", args...)
+}
+
+// query reads the entries a query selects
+func (s *GoScaleAuditStore) query(ctx context.Context, query string, args ...interface{}) ([]core.AuditEntry, error) {
+	entries := []core.AuditEntry{}
+	err := s.DB.QueryEach(ctx, query, func(row map[string]interface{}) error {
+		entry, err := auditEntry(row)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+		return nil
+	}, args...)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// auditEntry reads a row of the audit table
+func auditEntry(row map[string]interface{}) (core.AuditEntry, error) {
+	var entry core.AuditEntry
+
+	switch seq := row["seq"].(type) {
+	case int64:
+		entry.Seq = seq
+	case int32:
+		entry.Seq = int64(seq)
+	default:
+		return entry, fmt.Errorf("storage: unexpected audit seq %T", row["seq"])
+	}
+
+	switch t := row["time"].(type) {
+	case time.Time:
+		entry.Time = t.UTC()
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return entry, fmt.Errorf("storage: reading audit time: %v", err)
+		}
+		entry.Time = parsed.UTC()
+	default:
+		return entry, fmt.Errorf("storage: unexpected audit time %T", row["time"])
+	}
+
+	for column, field := range map[string]*string{
+		"actor":     &entry.Actor,
+		"component": &entry.Component,
+		"setting":   &entry.Setting,
+		"prev_hash": &entry.PrevHash,
+		"hash":      &entry.Hash,
+	} {
+		value, err := text(row, column)
+		if err != nil {
+			return entry, err
+		}
+		*field = value
+	}
+	for column, field := range map[string]*json.RawMessage{"old_value": &entry.Old, "new_value": &entry.New} {
+		value, err := text(row, column)
+		if err != nil {
+			return entry, err
+		}
+		*field = json.RawMessage(value)
+	}
+	return entry, nil
+}
+
+// text reads a text column, which drivers return as a string or bytes
+func text(row map[string]interface{}, column string) (string, error) {
+	switch value := row[column].(type) {
+	case string:
+		return value, nil
+	case []byte:
+		return string(value), nil
+	}
+	return "", fmt.Errorf("storage: unexpected audit %s %T", column, row[column])
+}
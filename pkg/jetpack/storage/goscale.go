@@ -0,0 +1,171 @@
+// Package storage persists Jetpack metrics and the audit log outside the
+// process, so their history survives restarts and reaches past the
+// in-memory retention.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/davidjeba/goscript/pkg/goscale/db"
+	"github.com/davidjeba/goscript/pkg/jetpack/core"
+)
+
+// DefaultRetention is how long GoScaleHistory keeps samples by default
+const DefaultRetention = 30 * 24 * time.Hour
+
+// identifier matches the schema and table names GoScaleHistory accepts,
+// which it writes into its SQL
+var identifier = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// GoScaleHistory keeps metric samples in a GoScaleDB time-series table,
+// a hypertable whose retention policy drops old samples
+type GoScaleHistory struct {
+	DB *db.GoScaleDB
+
+	// Schema and Table name the table the samples are kept in
+	Schema string
+	Table  string
+
+	// Retention is how long samples are kept; zero keeps them forever
+	Retention time.Duration
+
+	// BatchSize caps the samples written by one INSERT
+	BatchSize int
+}
+
+var _ core.MetricHistory = (*GoScaleHistory)(nil)
+
+// NewGoScaleHistory creates a history in database's jetpack.metric_samples
+// table; call Init before using it
+func NewGoScaleHistory(database *db.GoScaleDB) *GoScaleHistory {
+	return &GoScaleHistory{
+		DB:        database,
+		Schema:    "jetpack",
+		Table:     "metric_samples",
+		Retention: DefaultRetention,
+		BatchSize: 1000,
+	}
+}
+
+// Init creates the table, makes it a hypertable and sets its retention
+// policy. It can be called on every start.
+func (h *GoScaleHistory) Init(ctx context.Context) error {
+	if !identifier.MatchString(h.Schema) || !identifier.MatchString(h.Table) {
+		return fmt.Errorf("storage: invalid table name %s.%s", h.Schema, h.Table)
+	}
+	timeSeries := h.DB.TimeSeries()
+	if timeSeries == nil {
+		return fmt.Errorf("storage: GoScaleDB time series are disabled")
+	}
+
+	statements := []string{
+		fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", h.Schema),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (time TIMESTAMPTZ NOT NULL, metric TEXT NOT NULL, value DOUBLE PRECISION NOT NULL)", h.table()),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_metric_time ON %s (metric, time DESC)", h.Table, h.table()),
+	}
+	for _, statement := range statements {
+		if _, err := h.DB.Execute(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	if err := timeSeries.EnableTimeSeriesForTable(h.Schema, h.Table, "time"); err != nil {
+		return err
+	}
+	if h.Retention > 0 {
+		return timeSeries.SetRetentionPolicy(h.Schema, h.Table, h.Retention)
+	}
+	return nil
+}
+
+// table returns the qualified table name
+func (h *GoScaleHistory) table() string {
+	return h.Schema + "." + h.Table
+}
+
+// Write inserts samples, BatchSize at a time
+func (h *GoScaleHistory) Write(ctx context.Context, samples []core.MetricSample) error {
+	batchSize := h.BatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	for start := 0; start < len(samples); start += batchSize {
+		end := start + batchSize
+		if end > len(samples) {
+			end = len(samples)
+		}
+
+		values := make([]string, 0, end-start)
+		args := make([]interface{}, 0, 3*(end-start))
+		for i, sample := range samples[start:end] {
+			values = append(values, fmt.Sprintf("($%d, $%d, $%d)", 3*i+1, 3*i+2, 3*i+3))
+			args = append(args, sample.Timestamp, sample.Metric, sample.Value)
+		}
+
+		query := fmt.Sprintf("INSERT INTO %s (time, metric, value) VALUES %s", h.table(), strings.Join(values, ", "))
+		if _, err := h.DB.Execute(ctx, query, args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Query returns a metric's samples between from and to, averaged per step
+// with time_bucket when step is positive
+func (h *GoScaleHistory) Query(ctx context.Context, metric string, from, to time.Time, step time.Duration) ([]core.MetricValue, error) {
+	query := fmt.Sprintf("SELECT time, value FROM %s WHERE metric = $1 AND time >= $2 AND time <= $3 ORDER BY time", h.table())
+	args := []interface{}{metric, from, to}
+	if step > 0 {
+		query = fmt.Sprintf("SELECT time_bucket($4::interval, time) AS time, avg(value) AS value FROM %s WHERE metric = $1 AND time >= $2 AND time <= $3 GROUP BY 1 ORDER BY 1", h.table())
+		args = append(args, fmt.Sprintf("%d microseconds", step.Microseconds()))
+	}
+
+	values := []core.MetricValue{}
+	err := h.DB.QueryEach(ctx, query, func(row map[string]interface{}) error {
+		value, err := sampleValue(row)
+		if err != nil {
+			return err
+		}
+		values = append(values, value)
+		return nil
+	}, args...)
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// sampleValue reads a row of time and value columns
+func sampleValue(row map[string]interface{}) (core.MetricValue, error) {
+	var value core.MetricValue
+
+	switch t := row["time"].(type) {
+	case time.Time:
+		value.Timestamp = t
+	case string:
+		parsed, err := time.Parse(time.RFC3339Nano, t)
+		if err != nil {
+			return value, fmt.Errorf("storage: reading sample time: %v", err)
+		}
+		value.Timestamp = parsed
+	default:
+		return value, fmt.Errorf("storage: unexpected sample time %T", row["time"])
+	}
+
+	switch v := row["value"].(type) {
+	case float64:
+		value.Value = v
+	case float32:
+		value.Value = float64(v)
+	case int64:
+		value.Value = float64(v)
+	default:
+		return value, fmt.Errorf("storage: unexpected sample value %T", row["value"])
+	}
+	return value, nil
+}